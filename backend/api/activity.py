@@ -0,0 +1,72 @@
+"""Outlook activity log endpoint for FastAPI Email Helper API.
+
+GET /api/activity lists the mailbox changes the API has made (see
+backend.services.activity_log), newest first, filtered by operation and
+time range.
+"""
+
+from datetime import datetime, timezone
+from typing import Optional
+
+from fastapi import APIRouter, Depends, Query
+
+from backend.api.auth import get_current_user
+from backend.core.errors import InputValidationError, to_http_exception
+from backend.models.activity import ActivityListResponse
+from backend.models.user import UserInDB
+from backend.services.activity_log import ACTIVITY_OPERATIONS, ActivityLog, get_activity_log
+
+router = APIRouter()
+
+
+def _as_utc(value: Optional[datetime]) -> Optional[datetime]:
+    """Convert a filter time to naive UTC, as entries are stored; naive times are taken as UTC."""
+    if value is None or value.tzinfo is None:
+        return value
+    return value.astimezone(timezone.utc).replace(tzinfo=None)
+
+
+@router.get("/activity", response_model=ActivityListResponse)
+async def list_activity(
+    operation: Optional[str] = Query(None, description="Only this operation, e.g. move_email"),
+    since: Optional[datetime] = Query(None, description="Only entries at or after this ISO time (UTC if no offset)"),
+    until: Optional[datetime] = Query(None, description="Only entries before this ISO time (UTC if no offset)"),
+    limit: int = Query(50, ge=1, le=500, description="Number of entries to return"),
+    offset: int = Query(0, ge=0, description="Number of entries to skip"),
+    current_user: UserInDB = Depends(get_current_user),
+    activity_log: ActivityLog = Depends(get_activity_log)
+):
+    """List the mailbox changes made through the API, newest first.
+
+    Args:
+        operation: Operation filter (one of ACTIVITY_OPERATIONS)
+        since: Start of the time range
+        until: End of the time range
+        limit: Maximum number of entries to return (1-500)
+        offset: Number of entries to skip for pagination
+        current_user: Authenticated user
+        activity_log: Activity log instance
+
+    Returns:
+        Paginated activity log entries
+    """
+    try:
+        if operation is not None and operation not in ACTIVITY_OPERATIONS:
+            raise InputValidationError(
+                f"Unknown operation '{operation}'. Valid operations: {', '.join(ACTIVITY_OPERATIONS)}"
+            )
+        since, until = _as_utc(since), _as_utc(until)
+        if since is not None and until is not None and since >= until:
+            raise InputValidationError("since must be before until")
+
+        entries, total = await activity_log.list_activity(operation, since, until, limit, offset)
+        return ActivityListResponse(
+            entries=entries,
+            total=total,
+            offset=offset,
+            limit=limit,
+            has_more=offset + len(entries) < total
+        )
+
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list activity")
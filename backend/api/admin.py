@@ -0,0 +1,592 @@
+"""Admin endpoints for FastAPI Email Helper API.
+
+POST /api/admin/categories/migrate renames a category across the stored
+data (see backend.services.category_migration) and can re-apply the new
+category to the migrated emails in Outlook.
+
+GET /api/admin/jobs lists the periodic background jobs with when they last
+and next run; POST /api/admin/jobs/{name}/run starts one now (see
+backend.services.scheduler).
+
+POST /api/admin/maintenance vacuums and analyzes the database after large
+purges (see backend.services.database_maintenance).
+
+POST /api/admin/export-anonymized and POST /api/admin/wipe share or clear
+the stored data (see backend.services.data_admin); they take the
+X-API-Key header instead of a user's bearer token.
+
+GET /api/admin/training-export streams the user's corrected and confirmed
+classifications as JSON Lines for offline evaluation, and POST
+/api/admin/evaluate checks the current classifier against a sample of
+them (see backend.services.training_data).
+
+GET /api/admin/boilerplate lists the signatures and disclaimers learned
+per sender (see backend.services.boilerplate), POST
+/api/admin/boilerplate/learn learns them now, and DELETE
+/api/admin/boilerplate dismisses them so they are no longer stripped.
+
+/api/admin/snapshots keeps named copies of the user's classifications to
+compare against, or restore, after trying other rules or prompts (see
+backend.services.classification_snapshots).
+"""
+
+import os
+import re
+import tempfile
+from typing import Optional
+
+from fastapi import APIRouter, Depends, HTTPException, Query, Request, Response, status
+from fastapi.responses import FileResponse, StreamingResponse
+from starlette.background import BackgroundTask
+
+from backend.api.auth import get_current_user, require_admin_api_key
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import (
+    InputValidationError, NotFoundError, batch_status_code, item_status, to_http_exception
+)
+from backend.models.boilerplate import BoilerplateListResponse
+from backend.models.category import CATEGORY_NAME_PATTERN
+from backend.models.category_migration import CategoryMigrationRequest, CategoryMigrationResult
+from backend.models.data_admin import WipeRequest, WipeResponse
+from backend.models.email import BatchItemError
+from backend.models.maintenance import MaintenanceReport
+from backend.models.scheduled_job import ScheduledJobListResponse, ScheduledJobStatus
+from backend.models.snapshot import (
+    Snapshot, SnapshotComparison, SnapshotCreate, SnapshotListResponse, SnapshotRestoreRequest, SnapshotRestoreResponse
+)
+from backend.models.training import EvaluationReport, EvaluationRequest
+from backend.models.user import UserInDB
+from backend.services.boilerplate import BoilerplateStore, get_boilerplate_store
+from backend.services.category_migration import CategoryMigrationService, get_category_migration_service
+from backend.services.category_service import category_names
+from backend.services.classification_snapshots import DEFAULT_CHANGE_LIMIT, SnapshotService, get_snapshot_service
+from backend.services.database_maintenance import DatabaseMaintenance, get_database_maintenance
+from backend.services.data_admin import (
+    DataAdminService, check_wipe_confirmation, get_data_admin_service, wipe_confirmation
+)
+from backend.services.email_service import EmailService, cancel_on_disconnect
+from backend.services.scheduler import JobScheduler, get_job_scheduler
+from backend.services.training_data import (
+    DEFAULT_EXPORT_BYTES, MAX_EXPORT_BYTES, REDACTION_LEVELS, REDACTION_SECRETS, evaluate_classifier,
+    training_export_lines
+)
+
+router = APIRouter()
+
+# Shape of a category name outside the registered ones (allow_custom)
+CUSTOM_CATEGORY_PATTERN = re.compile(CATEGORY_NAME_PATTERN)
+
+SQLITE_MEDIA_TYPE = "application/vnd.sqlite3"
+ANONYMIZED_EXPORT_FILENAME = "email_helper_anonymized.db"
+
+JSON_LINES_MEDIA_TYPE = "application/x-ndjson"
+TRAINING_EXPORT_FILENAME = "email_helper_training.jsonl"
+
+
+@router.post("/admin/categories/migrate", response_model=CategoryMigrationResult)
+async def migrate_category(
+    request: Request,
+    response: Response,
+    migration: CategoryMigrationRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    migration_service: CategoryMigrationService = Depends(get_category_migration_service),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Rename a category in the stored emails, history, and saved views.
+    
+    The target must be a registered category (see /api/categories) unless
+    allow_custom is set, in which case it must be a lowercase snake_case
+    name. With update_outlook, each migrated email gets the new category
+    applied in Outlook after the database change is committed (spam is
+    quarantined instead when settings.spam_quarantine_days is set); emails
+    Outlook refuses are reported per item and the status is 207 when only
+    some of them failed.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        response: Outgoing response, whose status reflects Outlook failures
+        migration: From and to categories and options
+        current_user: Authenticated user
+        migration_service: Category migration service instance
+        email_service: Email service instance
+    
+    Returns:
+        Affected rows by table (counts only for a dry run) and Outlook results
+    """
+    try:
+        source, target = migration.from_category, migration.to_category
+        if source == target:
+            raise InputValidationError("from and to must be different categories")
+        valid_categories = category_names(migration_service.db)
+        if target not in valid_categories:
+            if not migration.allow_custom:
+                raise InputValidationError(
+                    f"Unknown category '{target}'. Valid categories: {', '.join(valid_categories)} "
+                    "(set allow_custom to migrate to a custom category)"
+                )
+            if not CUSTOM_CATEGORY_PATTERN.match(target):
+                raise InputValidationError(
+                    "Custom categories must be lowercase letters, digits, and underscores, starting with a letter"
+                )
+        
+        outcome = await migration_service.migrate(
+            current_user.id, source, target,
+            dry_run=migration.dry_run, update_outlook=migration.update_outlook
+        )
+        result = CategoryMigrationResult(
+            from_category=source,
+            to_category=target,
+            dry_run=migration.dry_run,
+            affected=outcome.affected,
+            migration_id=outcome.migration_id
+        )
+        if migration.dry_run or not migration.update_outlook:
+            return result
+        
+        for email_id in outcome.email_ids:
+            try:
+                applied = await cancel_on_disconnect(
+                    request, email_service.apply_category(email_id, target, current_user.id)
+                )
+                if not applied:
+                    result.errors.append(BatchItemError(
+                        email_id=email_id, error="Failed to apply category in Outlook",
+                        **item_status(status.HTTP_502_BAD_GATEWAY)
+                    ))
+            except NotImplementedError as e:
+                result.errors.append(BatchItemError(
+                    email_id=email_id, error=str(e), **item_status(status.HTTP_501_NOT_IMPLEMENTED)
+                ))
+            except HTTPException as e:
+                result.errors.append(BatchItemError(
+                    email_id=email_id, error=str(e.detail), **item_status(e.status_code)
+                ))
+        result.outlook_applied = len(outcome.email_ids) - len(result.errors)
+        if outcome.email_ids:
+            response.status_code = batch_status_code(
+                len(outcome.email_ids), [error.status_code for error in result.errors]
+            )
+        return result
+        
+    except Exception as e:
+        raise to_http_exception(e, "Category migration failed")
+
+
+@router.get("/admin/jobs", response_model=ScheduledJobListResponse)
+async def list_jobs(
+    current_user: UserInDB = Depends(get_current_user),
+    scheduler: JobScheduler = Depends(get_job_scheduler)
+):
+    """List the periodic background jobs and their last and next runs.
+    
+    Args:
+        current_user: Authenticated user
+        scheduler: Background job scheduler
+    
+    Returns:
+        Every registered job, by name
+    """
+    try:
+        return ScheduledJobListResponse(jobs=scheduler.statuses())
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list background jobs")
+
+
+@router.post(
+    "/admin/jobs/{name}/run", response_model=ScheduledJobStatus, status_code=status.HTTP_202_ACCEPTED
+)
+async def run_job(
+    name: str,
+    current_user: UserInDB = Depends(get_current_user),
+    scheduler: JobScheduler = Depends(get_job_scheduler)
+):
+    """Start a pass of a background job now.
+    
+    The pass runs in the background; poll GET /api/admin/jobs for its
+    result. A job that is already running is a 409.
+    
+    Args:
+        name: Job name, e.g. activity_prune
+        current_user: Authenticated user
+        scheduler: Background job scheduler
+    
+    Returns:
+        The job's status, running
+    """
+    try:
+        return scheduler.trigger(name)
+        
+    except Exception as e:
+        raise to_http_exception(e, f"Failed to run background job {name}")
+
+
+@router.post("/admin/maintenance", response_model=MaintenanceReport)
+async def maintain_database(
+    full: Optional[bool] = Query(
+        None, description="true forces a full VACUUM, false never runs one; unset decides by fragmentation"
+    ),
+    current_user: UserInDB = Depends(get_current_user),
+    maintenance: DatabaseMaintenance = Depends(get_database_maintenance)
+):
+    """Vacuum and analyze the database now.
+    
+    A full VACUUM runs when the share of free pages reaches
+    settings.database_vacuum_threshold (or with full=true); writes made
+    while it runs may fail with "database is locked" and should be
+    retried. A pass that is already running, including the scheduled
+    database_maintenance job, is a 409.
+    
+    Args:
+        full: Whether to force or forbid a full VACUUM
+        current_user: Authenticated user
+        maintenance: Database maintenance service
+    
+    Returns:
+        The vacuum run, fragmentation, database size before and after, and duration
+    """
+    try:
+        return await maintenance.run(full=full)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Database maintenance failed")
+
+
+@router.post(
+    "/admin/export-anonymized",
+    response_class=FileResponse,
+    dependencies=[Depends(require_admin_api_key)]
+)
+async def export_anonymized(data_service: DataAdminService = Depends(get_data_admin_service)):
+    """Download a copy of the database with mail content replaced by fakes.
+    
+    Subjects, bodies, addresses, and other mail text become HMAC fakes
+    (equal values keep equal fakes, so joins still work); categories,
+    confidences, timestamps, and task structure are kept. Requires the
+    X-API-Key header.
+    
+    Args:
+        data_service: Data admin service instance
+    
+    Returns:
+        The anonymized SQLite database file
+    """
+    handle, path = tempfile.mkstemp(suffix=".db", prefix="email_helper_anonymized_")
+    os.close(handle)
+    try:
+        await data_service.export_anonymized(path)
+        return FileResponse(
+            path,
+            media_type=SQLITE_MEDIA_TYPE,
+            filename=ANONYMIZED_EXPORT_FILENAME,
+            background=BackgroundTask(os.remove, path)
+        )
+        
+    except Exception as e:
+        os.remove(path)
+        raise to_http_exception(e, "Failed to export anonymized database")
+
+
+@router.post("/admin/wipe", response_model=WipeResponse, dependencies=[Depends(require_admin_api_key)])
+async def wipe_data(
+    request: WipeRequest,
+    data_service: DataAdminService = Depends(get_data_admin_service)
+):
+    """Delete the stored emails, tasks, and history, keeping settings.
+    
+    Without confirm nothing is deleted: the response has the rows that
+    would be and a confirmation token, valid for a few minutes, to send
+    back as confirm. Users, categories, saved views, folder profiles, and
+    user settings are kept. Requires the X-API-Key header.
+    
+    Args:
+        request: Confirmation token, if any
+        data_service: Data admin service instance
+    
+    Returns:
+        Deleted (or deletable) rows by table
+    """
+    try:
+        if request.confirm is None:
+            token, expires_at = wipe_confirmation()
+            return WipeResponse(
+                wiped=False, rows=await data_service.wipe_counts(), confirm=token, confirm_expires_at=expires_at
+            )
+        if not check_wipe_confirmation(request.confirm):
+            raise InputValidationError(
+                "Confirmation token is invalid or expired; call without confirm to get a new one"
+            )
+        return WipeResponse(wiped=True, rows=await data_service.wipe())
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to wipe stored data")
+
+
+@router.get("/admin/training-export", response_class=StreamingResponse)
+async def export_training_data(
+    redaction: str = Query(
+        REDACTION_SECRETS, description="secrets (mask credentials) or anonymized (also fake addresses and IDs)"
+    ),
+    max_bytes: int = Query(DEFAULT_EXPORT_BYTES, ge=1, le=MAX_EXPORT_BYTES, description="Most bytes to stream"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Stream the user's corrected and confirmed classifications as JSON Lines.
+    
+    Each line has a redacted subject, sender, and body excerpt, the human
+    category and whether it was a correction or a confirmation, and the
+    AI's original category, confidence, and prompt version. The stream
+    ends at the last whole line within max_bytes.
+    
+    Args:
+        redaction: Redaction level (see REDACTION_LEVELS)
+        max_bytes: Size cap of the output
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The JSON Lines file, streamed
+    """
+    try:
+        if redaction not in REDACTION_LEVELS:
+            raise InputValidationError(
+                f"Unknown redaction level '{redaction}'. Valid levels: {', '.join(REDACTION_LEVELS)}"
+            )
+        return StreamingResponse(
+            training_export_lines(email_service, current_user.id, redaction=redaction, max_bytes=max_bytes),
+            media_type=JSON_LINES_MEDIA_TYPE,
+            headers={"Content-Disposition": f'attachment; filename="{TRAINING_EXPORT_FILENAME}"'}
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to export training data")
+
+
+@router.post("/admin/evaluate", response_model=EvaluationReport)
+async def evaluate(
+    request: Request,
+    evaluation: EvaluationRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service),
+    ai_service = Depends(get_ai_service)
+):
+    """Classify a sample of human-labeled emails with the current classifier and report agreement.
+    
+    The sample is drawn from the emails GET /api/admin/training-export
+    would export; nothing is stored, so it can be run after every prompt
+    change and compared with stored_agreement, the agreement of the
+    categories the emails were given at the time.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        evaluation: Sample size and seed
+        current_user: Authenticated user
+        email_service: Email service instance
+        ai_service: AI service instance
+    
+    Returns:
+        Overall and per-category agreement with the human labels
+    """
+    try:
+        return await cancel_on_disconnect(
+            request,
+            evaluate_classifier(
+                ai_service, email_service, current_user.id, evaluation.sample_size, seed=evaluation.seed
+            )
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Classifier evaluation failed")
+
+
+@router.get("/admin/boilerplate", response_model=BoilerplateListResponse)
+async def list_boilerplate(
+    sender: Optional[str] = Query(None, description="Only blocks learned for this sender"),
+    current_user: UserInDB = Depends(get_current_user),
+    boilerplate_store: BoilerplateStore = Depends(get_boilerplate_store)
+):
+    """List the trailing blocks learned per sender and stripped before AI calls and previews.
+    
+    Args:
+        sender: Only this sender's blocks
+        current_user: Authenticated user
+        boilerplate_store: Boilerplate store instance
+    
+    Returns:
+        The blocks, by sender, longest first
+    """
+    try:
+        blocks = await boilerplate_store.list_boilerplate(current_user.id, sender)
+        return BoilerplateListResponse(blocks=blocks, total=len(blocks))
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list learned boilerplate")
+
+
+@router.post("/admin/boilerplate/learn", response_model=BoilerplateListResponse)
+async def learn_boilerplate(
+    sender: Optional[str] = Query(None, description="Only learn this sender's blocks"),
+    current_user: UserInDB = Depends(get_current_user),
+    boilerplate_store: BoilerplateStore = Depends(get_boilerplate_store)
+):
+    """Learn senders' boilerplate from the stored emails now.
+    
+    The boilerplate_learn background job does the same periodically.
+    Dismissed blocks are not learned again.
+    
+    Args:
+        sender: Only this sender (defaults to every sender with enough stored emails)
+        current_user: Authenticated user
+        boilerplate_store: Boilerplate store instance
+    
+    Returns:
+        The learned blocks
+    """
+    try:
+        if sender:
+            await boilerplate_store.learn_sender(current_user.id, sender)
+        else:
+            await boilerplate_store.learn_all(current_user.id)
+        blocks = await boilerplate_store.list_boilerplate(current_user.id, sender)
+        return BoilerplateListResponse(blocks=blocks, total=len(blocks))
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to learn boilerplate")
+
+
+@router.delete("/admin/boilerplate/{block_id}")
+async def delete_boilerplate(
+    block_id: int,
+    current_user: UserInDB = Depends(get_current_user),
+    boilerplate_store: BoilerplateStore = Depends(get_boilerplate_store)
+):
+    """Dismiss a learned block so it is no longer stripped or learned again."""
+    try:
+        if not await boilerplate_store.dismiss(current_user.id, block_id=block_id):
+            raise NotFoundError(f"Boilerplate {block_id} not found")
+        return {"message": "Boilerplate dismissed"}
+    except Exception as e:
+        raise to_http_exception(e, "Failed to delete boilerplate")
+
+
+@router.delete("/admin/boilerplate")
+async def delete_sender_boilerplate(
+    sender: str = Query(..., min_length=1, description="Sender whose blocks to dismiss"),
+    current_user: UserInDB = Depends(get_current_user),
+    boilerplate_store: BoilerplateStore = Depends(get_boilerplate_store)
+):
+    """Dismiss every block learned for a sender."""
+    try:
+        dismissed = await boilerplate_store.dismiss(current_user.id, sender=sender)
+        if not dismissed:
+            raise NotFoundError(f"No boilerplate learned for {sender}")
+        return {"message": f"Dismissed {dismissed} boilerplate blocks", "dismissed": dismissed}
+    except Exception as e:
+        raise to_http_exception(e, "Failed to delete boilerplate")
+
+
+@router.post("/admin/snapshots", response_model=Snapshot, status_code=status.HTTP_201_CREATED)
+async def create_snapshot(
+    snapshot: SnapshotCreate,
+    current_user: UserInDB = Depends(get_current_user),
+    snapshot_service: SnapshotService = Depends(get_snapshot_service)
+):
+    """Copy the user's stored classifications into a named snapshot.
+    
+    Each stored email's AI category, category, confidence, and folder is
+    kept, to compare against after trying other rules or prompts.
+    
+    Raises:
+        ConflictError: 409 if the name is taken or settings.snapshot_max_per_user
+            snapshots are kept already
+        InputValidationError: 422 if the user has more than settings.snapshot_max_emails emails
+    """
+    try:
+        return await snapshot_service.create(current_user.id, snapshot.name)
+    except Exception as e:
+        raise to_http_exception(e, "Failed to create snapshot")
+
+
+@router.get("/admin/snapshots", response_model=SnapshotListResponse)
+async def list_snapshots(
+    current_user: UserInDB = Depends(get_current_user),
+    snapshot_service: SnapshotService = Depends(get_snapshot_service)
+):
+    """List the user's classification snapshots, newest first."""
+    try:
+        snapshots = await snapshot_service.list(current_user.id)
+        return SnapshotListResponse(snapshots=snapshots, total=len(snapshots))
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list snapshots")
+
+
+@router.get("/admin/snapshots/{snapshot_id}/compare", response_model=SnapshotComparison)
+async def compare_snapshot(
+    snapshot_id: int,
+    limit: int = Query(DEFAULT_CHANGE_LIMIT, ge=1, le=5000, description="Most changed emails to list"),
+    current_user: UserInDB = Depends(get_current_user),
+    snapshot_service: SnapshotService = Depends(get_snapshot_service)
+):
+    """Diff a snapshot against the current stored classifications.
+    
+    Reports how many snapshot emails are still stored, gone, or changed
+    (category, AI category, or folder), emails stored since, each
+    category's churn (emails that left it or joined it; churn_rate is the
+    share of its snapshot emails that left), and the changed emails.
+    """
+    try:
+        comparison = await snapshot_service.compare(snapshot_id, current_user.id, limit)
+        if comparison is None:
+            raise NotFoundError(f"Snapshot {snapshot_id} not found")
+        return comparison
+    except Exception as e:
+        raise to_http_exception(e, "Failed to compare snapshot")
+
+
+@router.post("/admin/snapshots/{snapshot_id}/restore", response_model=SnapshotRestoreResponse)
+async def restore_snapshot(
+    snapshot_id: int,
+    restore: SnapshotRestoreRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    snapshot_service: SnapshotService = Depends(get_snapshot_service),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Set stored categories back to a snapshot's.
+    
+    Emails whose category differs from the snapshot's are corrected in one
+    transaction, with classification history recorded under the source
+    snapshot. Only the database changes; Outlook categories are left as
+    they are. A dry run only lists the emails that would change.
+    """
+    try:
+        found = await snapshot_service.restore_changes(snapshot_id, current_user.id)
+        if found is None:
+            raise NotFoundError(f"Snapshot {snapshot_id} not found")
+        changes, missing = found
+        if not restore.dry_run and changes:
+            result = await email_service.update_classifications(current_user.id, changes, source="snapshot")
+            missing = sorted(set(missing) | set(result.missing))
+            changes = [change for change in changes if change[0] not in result.missing]
+        return SnapshotRestoreResponse(
+            snapshot_id=snapshot_id,
+            dry_run=restore.dry_run,
+            restored=[email_id for email_id, _ in changes],
+            missing=missing
+        )
+    except Exception as e:
+        raise to_http_exception(e, "Failed to restore snapshot")
+
+
+@router.delete("/admin/snapshots/{snapshot_id}")
+async def delete_snapshot(
+    snapshot_id: int,
+    current_user: UserInDB = Depends(get_current_user),
+    snapshot_service: SnapshotService = Depends(get_snapshot_service)
+):
+    """Delete a classification snapshot."""
+    try:
+        if not await snapshot_service.delete(snapshot_id, current_user.id):
+            raise NotFoundError(f"Snapshot {snapshot_id} not found")
+        return {"message": "Snapshot deleted"}
+    except Exception as e:
+        raise to_http_exception(e, "Failed to delete snapshot")
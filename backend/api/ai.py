@@ -1,29 +1,96 @@
 """AI processing API endpoints for FastAPI Email Helper API.
 
 This module provides REST API endpoints for email classification, action item extraction,
-and summarization using existing AI processor functionality.
+summarization, and classification explanations using existing AI processor functionality.
+Endpoints that call the model are rate limited per user. GET /ai/traces/{email_id}
+returns the AI debug traces recorded for an email (see backend.services.ai_traces).
+GET /ai/calibration compares classifier confidence with how often users corrected
+the category (see backend.services.classification_store).
+POST /ai/search-query turns a natural language request into email search filters
+(see backend.services.search_query). POST /ai/holistic-analysis analyzes the newest
+inbox emails together, stores the run, and keeps the actions blocking others (see
+backend.services.holistic_analysis, backend.services.holistic_runs, and
+backend.services.blocking_items).
+POST /ai/extract-tasks creates tasks from several stored emails and reports
+them per email (see backend.services.task_extraction).
+Responses report how long their AI calls waited in the AI request queue as
+queue_wait_time (see backend.services.ai_queue).
 """
 
+import asyncio
+import logging
 import time
-from fastapi import APIRouter, Depends, HTTPException, status
+from typing import Any, Dict, Optional, Set
+
+from fastapi import APIRouter, Depends, Query, Response, status
 from fastapi.responses import JSONResponse
 
 from backend.models.ai_models import (
     EmailClassificationRequest, EmailClassificationResponse,
     ActionItemRequest, ActionItemResponse,
     SummaryRequest, SummaryResponse,
-    AIErrorResponse, AvailableTemplatesResponse
+    BatchSummaryRequest, BatchSummaryResponse, BatchSummaryItem,
+    ExplainClassificationRequest, ExplainClassificationResponse,
+    DraftReplyRequest, DraftReplyResponse,
+    SummaryBackfillRequest, SummaryBackfillResponse,
+    SearchQueryRequest, SearchQueryResponse,
+    HolisticAnalysisRequest, HolisticAnalysisResponse, HolisticAction,
+    AIErrorResponse, AvailableTemplatesResponse, CalibrationReport, clamp_importance_score
+)
+from backend.models.ai_trace import AITraceListResponse
+from backend.models.task import TaskExtractionRequest, TaskExtractionResponse, TaskExtractionResult
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.email_filters import validate_email_filters
+from backend.core.errors import (
+    AI_AUTH_MESSAGE, InputValidationError, NotFoundError, UpstreamAIError, batch_item_failure, batch_status_code,
+    item_status, to_http_exception
 )
-from backend.core.dependencies import get_ai_service
+from backend.core.prompts import prompt_library
+from backend.core.protection import ai_allowed, check_ai_allowed
+from backend.core.redaction import redact_email, redact_secrets
+from backend.core.rate_limit import RateLimiter
 from backend.api.auth import get_current_user
 from backend.models.user import User
+from backend.services.action_items import normalize_action_item
+from backend.services.ai_health import ai_health
+from backend.services.ai_queue import track_queue_wait
+from backend.services.ai_traces import AITraceStore, get_ai_trace_store, trace_email
+from backend.services.boilerplate import BoilerplateStore
+from backend.services.category_service import category_names
+from backend.services.classification_store import ClassificationStore, get_classification_store
+from backend.services.email_service import EmailService
+from backend.services.holistic_analysis import ANALYSIS_KEYS
+from backend.services.reply_drafter import DRAFT_TONES
+from backend.services.search_query import build_search_query
+from backend.services.summary_backfill import backfill_summaries, summarize_emails
+from backend.services.summary_modes import SUMMARY_TEMPLATES, SUMMARY_TYPES, summarize_conversation
+from backend.services.task_extraction import extract_email_tasks, summarize_results
+from backend.services.task_service import TaskService, get_task_service
+from backend.services.user_settings_service import user_clock
+from backend.services.websocket_manager import websocket_manager
+
+logger = logging.getLogger(__name__)
 
 # Create router with prefix and tags
 router = APIRouter(prefix="/ai", tags=["ai"])
 
+# Shared by every endpoint that calls the model
+ai_rate_limiter = RateLimiter(settings.ai_rate_limit_per_minute)
+
+
+async def ai_rate_limit(current_user: User = Depends(get_current_user)):
+    """Dependency enforcing the per-user AI rate limit."""
+    ai_rate_limiter.check(str(current_user.id))
+
+
+# Running backfills, kept referenced until they finish
+_backfill_tasks: Set[asyncio.Task] = set()
+
 
 @router.post(
     "/classify",
+    dependencies=[Depends(ai_rate_limit)],
     response_model=EmailClassificationResponse,
     summary="Classify email content",
     description="Classify email content using AI to determine category, confidence, and reasoning"
@@ -41,41 +108,41 @@ async def classify_email(
     try:
         start_time = time.time()
         
-        result = await ai_service.classify_email_async(
-            subject=request.subject,
-            content=request.content,
-            sender=request.sender,
-            context=request.context
-        )
+        subject, subject_redactions = redact_secrets(request.subject)
+        content, content_redactions = redact_secrets(request.content)
+        with track_queue_wait() as queue_wait:
+            result = await ai_service.classify_email_async(
+                subject=subject,
+                content=content,
+                sender=request.sender,
+                context=request.context
+            )
         
         processing_time = time.time() - start_time
         
         # Handle potential errors in the result
         if "error" in result:
-            raise HTTPException(
-                status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-                detail=f"AI classification failed: {result['error']}"
-            )
+            raise UpstreamAIError(f"AI classification failed: {result['error']}")
         
         return EmailClassificationResponse(
             category=result.get('category', 'work_relevant'),
             confidence=result.get('confidence', 0.5),
             reasoning=result.get('reasoning', 'Classification completed'),
+            importance_score=clamp_importance_score(result.get('importance_score')),
+            importance_justification=result.get('importance_justification'),
             alternative_categories=result.get('alternatives', []),
-            processing_time=processing_time
+            processing_time=processing_time,
+            queue_wait_time=queue_wait.seconds,
+            redactions_applied=subject_redactions + content_redactions
         )
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"AI processing failed: {str(e)}"
-        )
+        raise to_http_exception(e, "AI processing failed")
 
 
 @router.post(
     "/action-items",
+    dependencies=[Depends(ai_rate_limit)],
     response_model=ActionItemResponse,
     summary="Extract action items from email",
     description="Extract action items, deadlines, and urgency from email content"
@@ -91,20 +158,22 @@ async def extract_action_items(
     urgency levels, and relevant links.
     """
     try:
+        email_content, redactions = redact_secrets(request.email_content)
         result = await ai_service.extract_action_items(
-            email_content=request.email_content,
+            email_content=email_content,
             context=request.context
         )
         
         # Handle potential errors in the result
         if "error" in result and not result.get("action_items"):
-            raise HTTPException(
-                status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-                detail=f"Action item extraction failed: {result['error']}"
-            )
+            raise UpstreamAIError(f"Action item extraction failed: {result['error']}")
+        
+        # Services return normalized items; older callers and mocks may still return strings
+        items = [item for item in map(normalize_action_item, result.get('action_items') or []) if item]
         
         return ActionItemResponse(
-            action_items=result.get('action_items', []),
+            items=items,
+            action_items=[item["description"] for item in items],
             urgency=result.get('urgency', 'unknown'),
             deadline=result.get('deadline'),
             confidence=result.get('confidence', 0.0),
@@ -112,65 +181,638 @@ async def extract_action_items(
             action_required=result.get('action_required'),
             explanation=result.get('explanation'),
             relevance=result.get('relevance'),
-            links=result.get('links', [])
+            links=result.get('links', []),
+            redactions_applied=redactions
         )
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Action item extraction failed: {str(e)}"
-        )
+        raise to_http_exception(e, "Action item extraction failed")
 
 
 @router.post(
     "/summarize",
+    dependencies=[Depends(ai_rate_limit)],
     response_model=SummaryResponse,
     summary="Generate email summary",
-    description="Generate concise or detailed summaries of email content with key points"
+    description="Generate brief, detailed, or action-focused summaries of an email, or digest a whole thread"
 )
 async def summarize_email(
     request: SummaryRequest,
     current_user: User = Depends(get_current_user),
-    ai_service = Depends(get_ai_service)
+    ai_service = Depends(get_ai_service),
+    email_service: EmailService = Depends(get_email_service)
 ):
     """Generate email summary.
     
-    This endpoint creates brief or detailed summaries of email content,
-    extracting key points and providing confidence scores.
+    This endpoint creates brief, detailed, or action-focused summaries of
+    email content, extracting key points and providing confidence scores.
+    The thread type instead loads the stored conversation given by
+    conversation_id and digests it oldest message first, within the
+    thread_summary_max_tokens budget.
     """
     try:
+        if request.summary_type not in SUMMARY_TYPES:
+            raise InputValidationError(
+                f"Unknown summary_type '{request.summary_type}'. "
+                f"Valid types: {', '.join(SUMMARY_TYPES)}"
+            )
+        if request.summary_type == "thread":
+            if not request.conversation_id:
+                raise InputValidationError("conversation_id is required for thread summaries")
+        elif not request.email_content:
+            raise InputValidationError(f"email_content is required for {request.summary_type} summaries")
+        
         start_time = time.time()
         
-        result = await ai_service.generate_summary(
-            email_content=request.email_content,
-            summary_type=request.summary_type
-        )
+        with track_queue_wait() as queue_wait:
+            if request.summary_type == "thread":
+                result = await summarize_conversation(
+                    email_service,
+                    ai_service,
+                    request.conversation_id,
+                    user_id=current_user.id,
+                    max_tokens=settings.thread_summary_max_tokens
+                )
+                if result is None:
+                    raise NotFoundError(f"Conversation {request.conversation_id} not found")
+            else:
+                email_content, redactions = redact_secrets(request.email_content)
+                result = await ai_service.generate_summary(
+                    email_content=email_content,
+                    summary_type=request.summary_type
+                )
+                result = {**result, "redactions_applied": redactions}
         
         processing_time = time.time() - start_time
         
         # Handle potential errors in the result
         if "error" in result and result.get("confidence", 0) == 0.0:
-            raise HTTPException(
-                status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-                detail=f"Summarization failed: {result['error']}"
-            )
+            raise UpstreamAIError(f"Summarization failed: {result['error']}")
         
         return SummaryResponse(
             summary=result.get('summary', 'Unable to generate summary'),
             key_points=result.get('key_points', []),
             confidence=result.get('confidence', 0.0),
-            processing_time=processing_time
+            processing_time=processing_time,
+            queue_wait_time=queue_wait.seconds,
+            message_count=result.get('message_count'),
+            messages_omitted=result.get('messages_omitted'),
+            redactions_applied=result.get('redactions_applied', 0)
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Summarization failed")
+
+
+@router.post(
+    "/summarize/batch",
+    dependencies=[Depends(ai_rate_limit)],
+    response_model=BatchSummaryResponse,
+    summary="Summarize several stored emails",
+    description="Summarize stored emails given by ID or selected by category, read state, and age"
+)
+async def summarize_emails_batch(
+    request: BatchSummaryRequest,
+    response: Response,
+    current_user: User = Depends(get_current_user),
+    ai_service = Depends(get_ai_service),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Summarize several stored emails in one request.
+    
+    A selector is resolved to email IDs from the database, newest first.
+    Summaries are generated with bounded concurrency and returned keyed by
+    email ID; listed IDs that are not stored get an error entry, and
+    rights-managed emails a skipped entry.
+    
+    Unknown emails fail with 404 and AI failures with a retryable 502. The
+    status is 200 when every email was summarized, 207 when some were, and
+    the dominant item error's status when none were.
+    """
+    try:
+        if request.summary_type not in SUMMARY_TEMPLATES:
+            raise InputValidationError(
+                f"summary_type must be one of {', '.join(SUMMARY_TEMPLATES)} for batch summaries"
+            )
+        
+        selector = request.selector
+        if selector is not None:
+            valid_categories = category_names(email_service.db)
+            if selector.category is not None and selector.category not in valid_categories:
+                raise InputValidationError(
+                    f"Unknown category '{selector.category}'. "
+                    f"Valid categories: {', '.join(valid_categories)}"
+                )
+            try:
+                filters = validate_email_filters({
+                    "category": selector.category,
+                    "unread": True if selector.unread_only else None,
+                    "received_after": selector.since,
+                })
+            except ValueError as e:
+                raise InputValidationError(str(e))
+            
+            emails, _ = await email_service.search_stored_emails(
+                current_user.id, filters, limit=selector.limit
+            )
+            email_ids = [email["id"] for email in emails]
+        else:
+            email_ids = list(dict.fromkeys(request.email_ids))
+            stored = await email_service.get_stored_emails(email_ids, current_user.id)
+            emails = [stored[email_id] for email_id in email_ids if email_id in stored]
+        
+        start_time = time.time()
+        
+        with track_queue_wait() as queue_wait:
+            summaries = await summarize_emails(
+                await BoilerplateStore(email_service.db).strip_emails(current_user.id, emails),
+                ai_service,
+                summary_type=request.summary_type,
+                concurrency=settings.summary_backfill_concurrency
+            )
+        
+        processing_time = time.time() - start_time
+        
+        results = {}
+        for email_id in email_ids:
+            summary = summaries.get(email_id)
+            if summary is None:
+                results[email_id] = BatchSummaryItem(
+                    error="Email not found", **item_status(status.HTTP_404_NOT_FOUND)
+                )
+            elif "error" in summary:
+                results[email_id] = BatchSummaryItem(**summary, **item_status(status.HTTP_502_BAD_GATEWAY))
+            else:
+                results[email_id] = BatchSummaryItem(**summary)
+        failure_statuses = [item.status_code for item in results.values() if item.error]
+        failure_count = len(failure_statuses)
+        skipped_count = sum(1 for item in results.values() if item.skipped)
+        response.status_code = batch_status_code(len(results), failure_statuses)
+        
+        return BatchSummaryResponse(
+            selector=selector,
+            resolved_count=len(email_ids),
+            results=results,
+            success_count=len(results) - failure_count - skipped_count,
+            failure_count=failure_count,
+            skipped_count=skipped_count,
+            processing_time=processing_time,
+            queue_wait_time=queue_wait.seconds
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Batch summarization failed")
+
+
+@router.post(
+    "/extract-tasks",
+    dependencies=[Depends(ai_rate_limit)],
+    response_model=TaskExtractionResponse,
+    summary="Create tasks from several stored emails",
+    description="Extract the action items of stored emails and create tasks, reported per email"
+)
+async def extract_tasks_batch(
+    request: TaskExtractionRequest,
+    response: Response,
+    current_user: User = Depends(get_current_user),
+    ai_service = Depends(get_ai_service),
+    email_service: EmailService = Depends(get_email_service),
+    task_service: TaskService = Depends(get_task_service)
+):
+    """Create tasks from the action items of several stored emails.
+    
+    Results keep the order of email_ids, one per email, with the tasks
+    created from it and the action items skipped because the email already
+    had a task for them. Emails are extracted one at a time so a failure
+    only affects its own result.
+    
+    Unknown emails fail with 404 and AI failures with a retryable 502. The
+    status is 200 when every email was extracted, 207 when some were, and
+    the dominant item error's status when none were. The flat tasks list
+    is deprecated; read the tasks of each result instead.
+    """
+    try:
+        email_ids = list(dict.fromkeys(request.email_ids))
+        stored = await email_service.get_stored_emails(email_ids, current_user.id)
+        emails = {
+            email["id"]: email
+            for email in await BoilerplateStore(email_service.db).strip_emails(current_user.id, list(stored.values()))
+        }
+        
+        start_time = time.time()
+        
+        results = []
+        with track_queue_wait() as queue_wait:
+            for email_id in email_ids:
+                email = emails.get(email_id)
+                if email is None:
+                    results.append(TaskExtractionResult(
+                        email_id=email_id, error="Email not found", **item_status(status.HTTP_404_NOT_FOUND)
+                    ))
+                    continue
+                try:
+                    results.append(await extract_email_tasks(
+                        email, ai_service, task_service, current_user.id, context=request.context
+                    ))
+                except Exception as e:
+                    status_code, message = batch_item_failure(e, "Task extraction failed")
+                    results.append(TaskExtractionResult(
+                        email_id=email_id, error=message, **item_status(status_code)
+                    ))
+        
+        processing_time = time.time() - start_time
+        response.status_code = batch_status_code(
+            len(results), [result.status_code for result in results if result.error]
+        )
+        
+        return TaskExtractionResponse(
+            results=results,
+            summary=summarize_results(results),
+            tasks=[task for result in results for task in result.tasks],
+            processing_time=processing_time,
+            queue_wait_time=queue_wait.seconds
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Batch task extraction failed")
+
+
+@router.post(
+    "/explain",
+    dependencies=[Depends(ai_rate_limit)],
+    response_model=ExplainClassificationResponse,
+    summary="Explain a classification",
+    description="Compare a stored email's category against an alternative category"
+)
+async def explain_classification(
+    request: ExplainClassificationRequest,
+    current_user: User = Depends(get_current_user),
+    ai_service = Depends(get_ai_service),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Explain why an email got its category instead of another one.
+    
+    This endpoint reuses the email content and classification cached in the
+    database and asks the model for the evidence behind each category.
+    Rights-managed emails are refused with a 422.
+    """
+    try:
+        valid_categories = category_names(email_service.db)
+        if request.target_category not in valid_categories:
+            raise InputValidationError(
+                f"Unknown category '{request.target_category}'. "
+                f"Valid categories: {', '.join(valid_categories)}"
+            )
+        
+        email = await email_service.get_stored_email(request.email_id, current_user.id)
+        if email is None:
+            raise NotFoundError(f"Email {request.email_id} not found")
+        check_ai_allowed(email, request.email_id)
+        
+        current_category = email.get("category")
+        if not current_category:
+            raise InputValidationError(f"Email {request.email_id} has not been classified yet")
+        
+        start_time = time.time()
+        
+        redacted, redactions = redact_email({
+            "subject": email.get("subject") or "",
+            "content": await BoilerplateStore(email_service.db).strip_body(
+                current_user.id, email.get("sender"), email.get("content") or ""
+            )
+        })
+        with trace_email(request.email_id, current_user.id), track_queue_wait() as queue_wait:
+            result = await ai_service.explain_classification(
+                subject=redacted["subject"],
+                sender=email.get("sender") or "",
+                content=redacted["content"],
+                current_category=current_category,
+                current_reasoning=email.get("ai_reasoning"),
+                alternative_category=request.target_category,
+                received_date=str(email["received_date"]) if email.get("received_date") else None,
+                categories=valid_categories
+            )
+        
+        processing_time = time.time() - start_time
+        
+        if "error" in result:
+            raise UpstreamAIError(f"Classification explanation failed: {result['error']}")
+        
+        return ExplainClassificationResponse(
+            email_id=request.email_id,
+            current_category=current_category,
+            target_category=request.target_category,
+            supports_current=result.get("supports_current", []),
+            supports_alternative=result.get("supports_alternative", []),
+            verdict=result.get("verdict", "ambiguous"),
+            processing_time=processing_time,
+            queue_wait_time=queue_wait.seconds,
+            redactions_applied=redactions
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Classification explanation failed")
+
+
+@router.post(
+    "/draft-reply",
+    dependencies=[Depends(ai_rate_limit)],
+    response_model=DraftReplyResponse,
+    summary="Draft a reply",
+    description="Draft a reply to an email, optionally saving it to Outlook Drafts"
+)
+async def draft_reply(
+    request: DraftReplyRequest,
+    current_user: User = Depends(get_current_user),
+    ai_service = Depends(get_ai_service),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Draft a reply to an email in the user's voice.
+    
+    The email is read from the database, or from the mailbox if it has not
+    been stored yet. With save_as_draft the reply is saved to Outlook Drafts
+    for the user to review; it is never sent. Rights-managed emails are
+    refused with a 422 (see backend.core.protection).
+    """
+    try:
+        if request.tone not in DRAFT_TONES:
+            raise InputValidationError(
+                f"Unknown tone '{request.tone}'. Valid tones: {', '.join(DRAFT_TONES)}"
+            )
+        
+        email = await email_service.get_stored_email(request.email_id, current_user.id)
+        if email is not None:
+            content = email.get("content") or ""
+            received_date = email.get("received_date")
+        else:
+            email = await email_service.get_email_by_id(request.email_id)
+            if email is None:
+                raise NotFoundError(f"Email {request.email_id} not found")
+            content = email.get("body") or ""
+            received_date = email.get("received_time")
+        check_ai_allowed(email, request.email_id)
+        content = await BoilerplateStore(email_service.db).strip_body(current_user.id, email.get("sender"), content)
+        redacted, redactions = redact_email({"subject": email.get("subject") or "", "content": content})
+        
+        start_time = time.time()
+        
+        with trace_email(request.email_id, current_user.id), track_queue_wait() as queue_wait:
+            result = await ai_service.draft_reply(
+                subject=redacted["subject"],
+                sender=email.get("sender") or "",
+                content=redacted["content"],
+                tone=request.tone,
+                bullet_points=request.bullet_points,
+                received_date=str(received_date) if received_date else None
+            )
+        
+        if "error" in result:
+            raise UpstreamAIError(f"Reply drafting failed: {result['error']}")
+        
+        draft_id = None
+        if request.save_as_draft:
+            draft_id = await email_service.create_draft_reply(
+                request.email_id, result["subject"], result["body"]
+            )
+            if draft_id is None:
+                raise NotFoundError(f"Email {request.email_id} not found in mailbox")
+        
+        processing_time = time.time() - start_time
+        
+        return DraftReplyResponse(
+            email_id=request.email_id,
+            subject=result["subject"],
+            body=result["body"],
+            tone=request.tone,
+            saved_as_draft=draft_id is not None,
+            draft_id=draft_id,
+            processing_time=processing_time,
+            queue_wait_time=queue_wait.seconds,
+            redactions_applied=redactions
         )
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Summarization failed: {str(e)}"
+        raise to_http_exception(e, "Reply drafting failed")
+
+
+@router.post(
+    "/search-query",
+    dependencies=[Depends(ai_rate_limit)],
+    response_model=SearchQueryResponse,
+    summary="Build an email search from natural language",
+    description="Turn a request such as \"mails from dana about the reorg last month\" "
+                "into GET /api/emails/search parameters, optionally running the search"
+)
+async def generate_search_query(
+    request: SearchQueryRequest,
+    current_user: User = Depends(get_current_user),
+    ai_service = Depends(get_ai_service),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Turn a natural language request into email search filters.
+    
+    Relative dates are resolved against today in the user's time zone. The
+    model's filters are checked against the search schema; if it fails or
+    returns nothing usable, the request is searched as plain text and
+    fallback is true. With execute, the user's stored emails are searched
+    with the filters and returned inline.
+    """
+    try:
+        if not request.request.strip():
+            raise InputValidationError("Search request cannot be blank")
+        
+        start_time = time.time()
+        
+        with track_queue_wait() as queue_wait:
+            filters, fallback, dropped = await build_search_query(
+                ai_service,
+                request.request,
+                category_names(email_service.db),
+                user_clock(email_service.db, current_user.id).today()
+            )
+        
+        emails = total = None
+        if request.execute:
+            emails, total = await email_service.search_stored_emails(
+                current_user.id,
+                validate_email_filters(filters.model_dump(exclude={"q"}, exclude_none=True)),
+                limit=request.limit,
+                query=filters.q
+            )
+        
+        return SearchQueryResponse(
+            filters=filters,
+            fallback=fallback,
+            dropped=dropped,
+            emails=emails,
+            total=total,
+            processing_time=time.time() - start_time,
+            queue_wait_time=queue_wait.seconds
         )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Search query generation failed")
+
+
+@router.post(
+    "/holistic-analysis",
+    dependencies=[Depends(ai_rate_limit)],
+    response_model=HolisticAnalysisResponse,
+    summary="Analyze the inbox as a whole",
+    description="Find the actions still needed across the newest stored inbox emails, "
+                "keeping the ones other people are waiting on as blocking items"
+)
+async def analyze_inbox_holistically(
+    request: HolisticAnalysisRequest,
+    current_user: User = Depends(get_current_user),
+    ai_service = Depends(get_ai_service),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Analyze the newest stored inbox emails together.
+    
+    The model sees every email at once, so it can pick one canonical email
+    per topic and spot superseded, duplicate, and expired items. Protected
+    emails are left out and secrets are masked in the rest. Actions marked
+    blocking_others become blocking items (GET /api/emails/blocking), and
+    the user's earlier dismissals are sent along as corrections. The run
+    is stored, and POST /api/emails/analyses/{analysis_id}/apply applies
+    its cleanups.
+    """
+    try:
+        stored = await email_service.get_holistic_emails(current_user.id, request.limit)
+        allowed = [email for email in stored if ai_allowed(email)]
+        emails, redactions = [], 0
+        for email in allowed:
+            content = await BoilerplateStore(email_service.db).strip_body(
+                current_user.id, email.get("sender"), email.get("content") or ""
+            )
+            redacted, applied = redact_email({"subject": email.get("subject") or "", "content": content})
+            emails.append({**email, **redacted})
+            redactions += applied
+        
+        start_time = time.time()
+        
+        analysis: Dict[str, Any] = {}
+        if emails:
+            corrections = await email_service.get_blocking_corrections(current_user.id)
+            with track_queue_wait() as queue_wait:
+                analysis = await ai_service.analyze_inbox(
+                    emails,
+                    user_clock(email_service.db, current_user.id).today(),
+                    corrections
+                )
+            if "error" in analysis:
+                raise UpstreamAIError(f"Holistic analysis failed: {analysis['error']}")
+            queue_wait_time = queue_wait.seconds
+        else:
+            queue_wait_time = 0.0
+        
+        actions = analysis.get("truly_relevant_actions", [])
+        analyzed_ids = [email["id"] for email in emails]
+        blocking_count = await email_service.record_blocking_items(current_user.id, actions, analyzed_ids)
+        analysis_id = await email_service.record_holistic_analysis(
+            current_user.id, {key: analysis.get(key, []) for key in ANALYSIS_KEYS}, analyzed_ids
+        )
+        
+        return HolisticAnalysisResponse(
+            analysis_id=analysis_id,
+            analyzed=len(emails),
+            skipped_protected=len(stored) - len(allowed),
+            truly_relevant_actions=[HolisticAction(**action) for action in actions],
+            superseded_actions=analysis.get("superseded_actions", []),
+            duplicate_groups=analysis.get("duplicate_groups", []),
+            expired_items=analysis.get("expired_items", []),
+            blocking_count=blocking_count,
+            processing_time=time.time() - start_time,
+            queue_wait_time=queue_wait_time,
+            redactions_applied=redactions
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Holistic analysis failed")
+
+
+@router.post(
+    "/summaries/backfill",
+    response_model=SummaryBackfillResponse,
+    status_code=status.HTTP_202_ACCEPTED,
+    summary="Backfill missing one-line summaries",
+    description="Summarize stored emails that have no one-line summary, streaming progress over the WebSocket"
+)
+async def backfill_email_summaries(
+    request: SummaryBackfillRequest,
+    current_user: User = Depends(get_current_user),
+    ai_service = Depends(get_ai_service),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Start a one-line summary backfill for already-synced emails.
+    
+    Summaries are generated in the background; progress is sent to the
+    user's general processing WebSocket as summary_backfill_progress events.
+    Re-running the backfill resumes where an earlier run stopped.
+    
+    Each scheduled email costs one request of the AI rate limit, so a run
+    schedules at most what is left of the user's window (429 if nothing is);
+    the rest are left for a later run.
+    """
+    try:
+        valid_categories = category_names(email_service.db)
+        if request.category is not None and request.category not in valid_categories:
+            raise InputValidationError(
+                f"Unknown category '{request.category}'. "
+                f"Valid categories: {', '.join(valid_categories)}"
+            )
+        
+        missing = await email_service.count_missing_summaries(current_user.id, request.category)
+        wanted = min(missing, request.limit)
+        user_key = str(current_user.id)
+        
+        if request.dry_run:
+            remaining = ai_rate_limiter.remaining(user_key)
+            return SummaryBackfillResponse(
+                missing=missing,
+                scheduled=wanted if remaining is None else min(wanted, remaining),
+                dry_run=True
+            )
+        
+        granted = ai_rate_limiter.acquire(user_key, wanted)
+        emails = await email_service.get_emails_missing_summary(
+            current_user.id, request.category, granted
+        ) if granted else []
+        
+        async def _run_backfill():
+            await websocket_manager.send_backfill_progress(
+                user_key, {"status": "started", "total": len(emails)}
+            )
+            try:
+                totals = await backfill_summaries(
+                    emails,
+                    ai_service,
+                    email_service,
+                    current_user.id,
+                    concurrency=settings.summary_backfill_concurrency,
+                    on_progress=lambda progress: websocket_manager.send_backfill_progress(
+                        user_key, {"status": "running", **progress}
+                    )
+                )
+                await websocket_manager.send_backfill_progress(
+                    user_key, {"status": "completed", **totals}
+                )
+            except Exception as e:
+                logger.error(f"Summary backfill failed for user {user_key}: {e}")
+                await websocket_manager.send_backfill_progress(
+                    user_key, {"status": "failed", "error": str(e)}
+                )
+        
+        if emails:
+            task = asyncio.create_task(_run_backfill())
+            _backfill_tasks.add(task)
+            task.add_done_callback(_backfill_tasks.discard)
+        
+        return SummaryBackfillResponse(missing=missing, scheduled=len(emails), dry_run=False)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Summary backfill failed")
 
 
 @router.get(
@@ -186,21 +828,104 @@ async def get_available_templates(
     """Get available prompt templates.
     
     This endpoint returns a list of available prompty templates that can be used
-    for AI processing, along with their descriptions.
+    for AI processing, along with their descriptions and the temperature and
+    max_tokens each runs with once settings.ai_parameters is applied.
     """
     try:
         result = await ai_service.get_available_templates()
         
         return AvailableTemplatesResponse(
             templates=result.get('templates', []),
-            descriptions=result.get('descriptions', {})
+            descriptions=result.get('descriptions', {}),
+            layers=result.get('layers', {}),
+            parameters=result.get('parameters', {}),
+            errors=result.get('errors', {})
         )
         
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Failed to retrieve templates: {str(e)}"
-        )
+        raise to_http_exception(e, "Failed to retrieve templates")
+
+
+@router.post(
+    "/templates/reload",
+    response_model=AvailableTemplatesResponse,
+    summary="Reload prompt templates",
+    description="Re-read the bundled and user prompt directories and return the active templates"
+)
+async def reload_templates(
+    current_user: User = Depends(get_current_user),
+    ai_service = Depends(get_ai_service)
+):
+    """Reload prompt templates.
+    
+    Picks up user overrides added, edited, or removed since the templates
+    were last read, without restarting the server.
+    """
+    try:
+        await asyncio.get_event_loop().run_in_executor(None, prompt_library.reload)
+        return await get_available_templates(current_user, ai_service)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to reload templates")
+
+
+@router.get(
+    "/traces/{email_id}",
+    response_model=AITraceListResponse,
+    summary="Get an email's AI debug traces",
+    description="Prompts, raw model responses, and timings recorded for an email while AI debug tracing was on"
+)
+async def get_ai_traces(
+    email_id: str,
+    operation: Optional[str] = Query(None, description="Only traces of this prompt template"),
+    current_user: User = Depends(get_current_user),
+    trace_store: AITraceStore = Depends(get_ai_trace_store)
+):
+    """Get the AI calls recorded for an email, oldest first.
+    
+    Calls are only recorded while settings.ai_debug_traces is on or for
+    requests sent with the X-AI-Debug-Trace: 1 header, and are kept for
+    settings.ai_trace_retention_days.
+    
+    Raises:
+        NotFoundError: If no traces are recorded for the email
+    """
+    try:
+        traces = await trace_store.get_traces(email_id, current_user.id, operation=operation)
+        if not traces:
+            raise NotFoundError(f"No AI traces recorded for email {email_id}")
+        return AITraceListResponse(email_id=email_id, traces=traces)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve AI traces")
+
+
+@router.get(
+    "/calibration",
+    response_model=CalibrationReport,
+    summary="Get classifier confidence calibration",
+    description="Correction rate per confidence decile and category, with suggested confidence thresholds"
+)
+async def get_calibration(
+    days: int = Query(30, ge=1, le=365, description="Days of AI classifications to cover"),
+    target_precision: float = Query(
+        0.9, gt=0, le=1, description="Share of predictions at or above a suggested threshold that must be kept"
+    ),
+    current_user: User = Depends(get_current_user),
+    store: ClassificationStore = Depends(get_classification_store)
+):
+    """Report how often predictions at each confidence were corrected.
+    
+    A prediction is corrected when the user changed the email's category
+    after the AI classified it. Each category (and all of them together)
+    gets ten confidence buckets and the lowest bucket bound at which the
+    predictions at or above it reach target_precision.
+    """
+    try:
+        return await store.get_calibration_report(current_user.id, days=days, target_precision=target_precision)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to build calibration report")
 
 
 # Health check endpoint for AI services
@@ -224,6 +949,19 @@ async def ai_health_check(
         # Get available templates as a basic connectivity test
         templates_result = await ai_service.get_available_templates()
         template_count = len(templates_result.get('templates', []))
+        if ai_health.status == "auth_error":
+            # Listing templates never calls the model, so it cannot show the credential works again
+            return JSONResponse(
+                status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+                content={
+                    "status": "auth_error",
+                    "ai_processor_available": ai_service._initialized,
+                    "templates_available": template_count,
+                    "error": ai_health.last_error,
+                    "message": AI_AUTH_MESSAGE
+                }
+            )
+        await ai_health.record(True)
         
         return {
             "status": "healthy",
@@ -233,6 +971,7 @@ async def ai_health_check(
         }
         
     except Exception as e:
+        await ai_health.record(False, str(e))
         return JSONResponse(
             status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
             content={
@@ -1,11 +1,12 @@
 """Authentication endpoints for FastAPI Email Helper API."""
 
+import hmac
 import sqlite3
 from datetime import datetime, timedelta
 from typing import Optional
 
 from fastapi import APIRouter, Depends, HTTPException, status
-from fastapi.security import HTTPBearer, HTTPAuthorizationCredentials
+from fastapi.security import APIKeyHeader, HTTPBearer, HTTPAuthorizationCredentials
 from passlib.context import CryptContext
 from jose import JWTError, jwt
 
@@ -15,6 +16,7 @@ from backend.models.user import User, UserCreate, UserLogin, Token, TokenData, U
 
 router = APIRouter()
 security = HTTPBearer()
+api_key_header = APIKeyHeader(name="X-API-Key", auto_error=False)
 
 # Password hashing
 pwd_context = CryptContext(schemes=["bcrypt"], deprecated="auto")
@@ -133,6 +135,24 @@ async def get_current_user(
     return user
 
 
+async def require_admin_api_key(api_key: Optional[str] = Depends(api_key_header)) -> None:
+    """Require settings.admin_api_key in the X-API-Key header.
+
+    Endpoints using it are disabled (403) while no admin API key is set.
+    """
+    if not settings.admin_api_key:
+        raise HTTPException(
+            status_code=status.HTTP_403_FORBIDDEN,
+            detail="Admin API key endpoints are disabled; set ADMIN_API_KEY to enable them"
+        )
+    if not api_key or not hmac.compare_digest(api_key.encode("utf-8"), settings.admin_api_key.encode("utf-8")):
+        raise HTTPException(
+            status_code=status.HTTP_401_UNAUTHORIZED,
+            detail="Invalid or missing API key",
+            headers={"WWW-Authenticate": "ApiKey"},
+        )
+
+
 @router.post("/register", response_model=User, status_code=status.HTTP_201_CREATED)
 async def register(user: UserCreate, db: sqlite3.Connection = Depends(get_database)):
     """Register a new user."""
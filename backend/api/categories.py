@@ -0,0 +1,112 @@
+"""Classification category endpoints for FastAPI Email Helper API.
+
+Categories saved here are rendered into the classifier prompt and accepted
+wherever a category is validated (see backend.services.category_service).
+"""
+
+import sqlite3
+
+from fastapi import APIRouter, Depends
+
+from backend.api.auth import get_current_user
+from backend.core.errors import ConflictError, NotFoundError, to_http_exception
+from backend.models.category import Category, CategoryCreate, CategoryListResponse, CategoryUpdate
+from backend.models.user import UserInDB
+from backend.services.category_service import CategoryService, get_category_service
+
+router = APIRouter()
+
+
+@router.get("/categories", response_model=CategoryListResponse)
+async def list_categories(
+    current_user: UserInDB = Depends(get_current_user),
+    category_service: CategoryService = Depends(get_category_service)
+):
+    """List the categories the classifier chooses from, in prompt order."""
+    try:
+        categories = await category_service.list_categories()
+        return CategoryListResponse(categories=categories, total=len(categories))
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list categories")
+
+
+@router.post("/categories", response_model=Category, status_code=201)
+async def create_category(
+    category: CategoryCreate,
+    current_user: UserInDB = Depends(get_current_user),
+    category_service: CategoryService = Depends(get_category_service)
+):
+    """Add a category; the next classification already considers it."""
+    try:
+        try:
+            return await category_service.create_category(category)
+        except sqlite3.IntegrityError:
+            raise ConflictError(f"Category '{category.name}' already exists")
+    except Exception as e:
+        raise to_http_exception(e, "Failed to create category")
+
+
+@router.get("/categories/{name}", response_model=Category)
+async def get_category(
+    name: str,
+    current_user: UserInDB = Depends(get_current_user),
+    category_service: CategoryService = Depends(get_category_service)
+):
+    """Get a category."""
+    try:
+        category = await category_service.get_category(name)
+        if not category:
+            raise NotFoundError(f"Category '{name}' not found")
+        return category
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve category")
+
+
+@router.put("/categories/{name}", response_model=Category)
+async def update_category(
+    name: str,
+    updates: CategoryUpdate,
+    current_user: UserInDB = Depends(get_current_user),
+    category_service: CategoryService = Depends(get_category_service)
+):
+    """Update a category's rules, priority, or folder (built-in ones included)."""
+    try:
+        category = await category_service.update_category(name, updates)
+        if not category:
+            raise NotFoundError(f"Category '{name}' not found")
+        return category
+    except Exception as e:
+        raise to_http_exception(e, "Failed to update category")
+
+
+@router.delete("/categories/{name}")
+async def delete_category(
+    name: str,
+    current_user: UserInDB = Depends(get_current_user),
+    category_service: CategoryService = Depends(get_category_service)
+):
+    """Delete a user-defined category.
+
+    Built-in categories cannot be deleted, and neither can categories that
+    stored emails are still in; move those emails first with
+    POST /api/admin/categories/migrate.
+    """
+    try:
+        category = await category_service.get_category(name)
+        if not category:
+            raise NotFoundError(f"Category '{name}' not found")
+        if category.built_in:
+            raise ConflictError(
+                f"'{name}' is a built-in category and cannot be deleted; "
+                "migrate its emails with POST /api/admin/categories/migrate instead"
+            )
+        in_use = await category_service.count_emails(name)
+        if in_use:
+            raise ConflictError(
+                f"{in_use} stored emails are in '{name}'; "
+                "migrate them with POST /api/admin/categories/migrate first"
+            )
+        await category_service.delete_category(name)
+        return {"message": "Category deleted successfully"}
+    except Exception as e:
+        raise to_http_exception(e, "Failed to delete category")
@@ -0,0 +1,53 @@
+"""Configuration introspection endpoints for FastAPI Email Helper API."""
+
+from typing import Any, Dict, Optional
+
+from fastapi import APIRouter, Depends
+from pydantic import BaseModel
+
+from backend.core.config import Settings, get_settings
+from backend.database.connection import DatabaseManager, get_database_manager
+
+router = APIRouter()
+
+
+class ConfigResponse(BaseModel):
+    """Sanitized view of enabled features for the frontend."""
+    version: str
+    provider: str
+    com_backend: bool
+    ai_configured: bool
+    graph_configured: bool
+    ado_configured: bool
+    debug: bool
+    read_only: bool = False  # Changes are refused (see backend.core.read_only)
+    banner: Optional[str] = None  # Message the UI should show, e.g. about a database recovered at startup
+    database_recovery: Optional[Dict[str, Any]] = None  # See backend.database.recovery
+
+
+@router.get("/config", response_model=ConfigResponse)
+async def get_config(
+    settings: Settings = Depends(get_settings),
+    db: DatabaseManager = Depends(get_database_manager)
+):
+    """Get feature flags so the UI can hide unavailable features.
+    
+    No secrets or connection details are included; only whether each
+    integration is enabled, and whether the server is read-only so the
+    UI can disable its actions. If a corrupted database was replaced at
+    startup, banner describes it and database_recovery says where the
+    damaged file was kept.
+    """
+    recovery = db.recovery
+    return ConfigResponse(
+        version=settings.app_version,
+        provider=settings.provider_type,
+        com_backend=settings.use_com_backend,
+        ai_configured=settings.ai_configured,
+        graph_configured=settings.graph_configured,
+        ado_configured=settings.ado_configured,
+        debug=settings.debug,
+        read_only=settings.read_only,
+        banner=recovery.banner if recovery else None,
+        database_recovery=recovery.to_dict() if recovery else None
+    )
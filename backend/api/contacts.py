@@ -0,0 +1,65 @@
+"""Contact profile endpoints for FastAPI Email Helper API.
+
+GET /api/contacts lists the people the mailbox owner has sent mail to,
+counted from Sent Items by the contact_scan job (see
+backend.services.contacts), with the relationship hint classification
+uses for each; GET /api/contacts/{address} returns one of them.
+"""
+
+from fastapi import APIRouter, Depends, Query
+
+from backend.api.auth import get_current_user
+from backend.core.errors import NotFoundError, to_http_exception
+from backend.models.contact import ContactListResponse, ContactProfile
+from backend.models.user import UserInDB
+from backend.services.contacts import ContactStore, get_contact_store
+
+router = APIRouter()
+
+
+@router.get("/contacts", response_model=ContactListResponse)
+async def list_contacts(
+    limit: int = Query(50, ge=1, le=500, description="Number of contacts to return"),
+    offset: int = Query(0, ge=0, description="Number of contacts to skip"),
+    current_user: UserInDB = Depends(get_current_user),
+    contact_store: ContactStore = Depends(get_contact_store)
+):
+    """List contacts, most emailed first.
+
+    Args:
+        limit: Maximum number of contacts to return (1-500)
+        offset: Number of contacts to skip for pagination
+        current_user: Authenticated user
+        contact_store: Contact store instance
+
+    Returns:
+        Paginated contacts and when Sent Items was last scanned
+    """
+    try:
+        contacts, total, last_scan_at = await contact_store.list_contacts(limit, offset)
+        return ContactListResponse(
+            contacts=contacts,
+            total=total,
+            offset=offset,
+            limit=limit,
+            has_more=offset + len(contacts) < total,
+            last_scan_at=last_scan_at
+        )
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list contacts")
+
+
+@router.get("/contacts/{address}", response_model=ContactProfile)
+async def get_contact(
+    address: str,
+    current_user: UserInDB = Depends(get_current_user),
+    contact_store: ContactStore = Depends(get_contact_store)
+):
+    """Get the contact profile of an address (matched in any case)."""
+    try:
+        contact = await contact_store.get_contact(address)
+        if contact is None:
+            raise NotFoundError(f"No sent mail to '{address}'")
+        return contact
+    except Exception as e:
+        raise to_http_exception(e, "Failed to get contact")
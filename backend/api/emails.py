@@ -1,17 +1,67 @@
 """Email endpoints for FastAPI Email Helper API."""
 
+import mimetypes
+from datetime import datetime
 from typing import List, Optional, Dict, Any
-from fastapi import APIRouter, Depends, HTTPException, Query, status
+from fastapi import APIRouter, Depends, HTTPException, Query, Request, Response, status
 from pydantic import BaseModel
 
-from backend.services.email_provider import EmailProvider
-from backend.core.dependencies import get_email_provider
+from backend.core.email_filters import (
+    DEFAULT_SORT, project_email, stored_columns, validate_email_filters, validate_fields, validate_group_by,
+    validate_sort
+)
+from backend.core.links import extract_links
+from backend.core.sanitize import content_id, plain_text, rewrite_cid_references
+from backend.core.search import validate_query
+from backend.core.text_diff import diff_words
+from backend.services.category_service import CategoryService, category_names, get_category_service
+from backend.services.category_suggestions import suggest_categories
+from backend.services.dry_run import EMAIL_WRITES, TASK_WRITES, ChangePlan, SimulatedAIService, WriteInterceptor
+from backend.services.email_service import EmailService, cancel_on_disconnect
+from backend.services.folder_suggestions import suggest_folders
+from backend.services.sender_domains import add_domain_rule, domain_rule_text, normalize_domain
+from backend.services.outbox import STATUS_DEAD, STATUS_PENDING
+from backend.services.follow_up_detector import detect_awaiting_reply
+from backend.services.task_service import TaskService, get_task_service
+from backend.services.thread_classifier import classify_batch, raw_email_id
+from backend.services.unified_inbox import MailboxSource, get_mailbox_sources, get_unified_emails
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import (
+    APIError, ConflictError, InputValidationError, NotFoundError, batch_item_failure, batch_status_code,
+    item_status, to_http_exception
+)
+from backend.core.folder_scope import check_folder_in_scope
 from backend.api.auth import get_current_user
 from backend.models.user import UserInDB
-from backend.models.email import Email, EmailBatch, EmailBatchResult
+from backend.models.email import (
+    ArchiveAgedRequest, ArchiveAgedResponse, ArchiveCandidate, BatchItemError, BatchItemSkip,
+    BlockingDismissRequest, BlockingItem, BlockingItemListResponse,
+    CategorySuggestionsResponse, ClassificationCorrectionBatch,
+    ClassificationCorrectionResponse, ClassificationState, ClassificationUpdate, ClassificationUpdateResponse,
+    ConversationMergeRequest, ConversationMergeResponse, ConversationMuteResponse, ConversationUnmergeResponse,
+    DomainCategoryRequest, DomainCategoryResponse,
+    Email, EmailAgingReport, EmailBatch, EmailBatchResult, EmailCounters, EmailDiffChange, EmailDiffResponse,
+    EmailLinksResponse, EmailNote, EmailNoteCreate, EmailNoteListResponse, FolderHygieneCandidate,
+    FolderHygienePurgeRequest, FolderHygienePurgeResponse, FolderHygieneReport, FolderSuggestionsResponse,
+    HolisticApplyRequest, HolisticApplyResponse, HolisticArchiveCandidate, HolisticTaskCandidate,
+    InboxProgress, MoveBatchRevertResponse, OutlookCategoriesResponse, OutlookStore,
+    OutlookStoreListResponse,
+    OutlookStoreSelection, OutboxListResponse, PreviewBackfillResult, ReconcileReport, SenderDomainReport,
+    SenderReputation, StorageStats, ThreadParticipantsResponse, TriageStats, UnifiedEmailListResponse
+)
 
 router = APIRouter()
 
+# Inline attachments are served with their own type only when a browser renders it as an image alone;
+# anything else (SVG can carry scripts) is sent as a download
+INLINE_IMAGE_TYPES = frozenset({"image/bmp", "image/gif", "image/jpeg", "image/png", "image/webp"})
+INLINE_HEADERS = {
+    "Cache-Control": "private, max-age=3600",
+    "Content-Security-Policy": "default-src 'none'",
+    "X-Content-Type-Options": "nosniff",
+}
+
 
 class EmailListResponse(BaseModel):
     """Response model for email list endpoint."""
@@ -20,12 +70,15 @@ class EmailListResponse(BaseModel):
     offset: int
     limit: int
     has_more: bool
+    degraded: bool = False  # Served from stored emails because the provider was unavailable
 
 
 class EmailFolderResponse(BaseModel):
     """Response model for email folders endpoint."""
-    folders: List[Dict[str, str]]
+    folders: List[Dict[str, Any]]
     total: int
+    cache_age_seconds: Optional[float] = None  # Seconds since the counts were read; None if not cached
+    degraded: bool = False  # Counted from stored emails because the provider was unavailable
 
 
 class EmailOperationResponse(BaseModel):
@@ -33,6 +86,7 @@ class EmailOperationResponse(BaseModel):
     success: bool
     message: str
     email_id: Optional[str] = None
+    queued: bool = False  # Outlook was unavailable; the change waits in the outbox
 
 
 class ConversationResponse(BaseModel):
@@ -40,300 +94,2276 @@ class ConversationResponse(BaseModel):
     conversation_id: str
     emails: List[Dict[str, Any]]
     total: int
+    degraded: bool = False  # Served from stored emails because the provider was unavailable
+
+
+async def list_emails(
+    request: Request,
+    filters: Dict[str, Any],
+    sort: Optional[str],
+    limit: int,
+    offset: int,
+    current_user: UserInDB,
+    email_service: EmailService,
+    ai_service,
+    fields: Optional[List[str]] = None,
+    override: bool = False,
+    include_muted: bool = False,
+    group_by: Optional[str] = None
+) -> EmailListResponse:
+    """List emails for GET /emails and saved views.
+    
+    With only a folder, the folder is listed from the provider. Any other
+    filter searches stored emails instead; awaiting_reply=true first
+    re-checks stored threads for unanswered questions. With fields, each
+    email is projected onto those keys and stored searches select only the
+    matching columns. A folder excluded by settings.excluded_folders is
+    refused unless override is true. Emails of muted conversations are
+    left out unless include_muted is true. group_by=conversation searches
+    stored emails and pages over conversations instead (see
+    EmailService.get_emails_grouped_by_conversation), latest activity first.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        filters: Filter name to value (see backend.core.email_filters)
+        sort: Stored-search order (defaults to newest first, or oldest
+            first for awaiting_reply)
+        limit: Maximum number of emails to return
+        offset: Number of emails to skip
+        current_user: Authenticated user
+        email_service: Email service instance
+        ai_service: AI service for the optional awaiting-reply check
+        fields: Keys to keep, from validate_fields (default all)
+        override: List an excluded folder anyway
+        include_muted: Also list emails of muted conversations
+        group_by: One of EMAIL_GROUPINGS, or None to list emails
+    
+    Raises:
+        InputValidationError: If a filter, the sort, or the grouping is invalid
+        ExcludedFolderError: If the folder is excluded and override is false
+    """
+    try:
+        filters = validate_email_filters(filters)
+        for name in ("awaiting_reply", "to_me"):
+            if not filters.get(name):
+                filters.pop(name, None)
+        if validate_group_by(group_by) and sort is not None:
+            raise ValueError("Grouped lists are ordered by latest activity; leave sort out")
+        sort = validate_sort(sort or ("received_asc" if filters.get("awaiting_reply") else DEFAULT_SORT))
+    except ValueError as e:
+        raise InputValidationError(str(e))
+    
+    searches_stored = bool(set(filters) - {"folder"}) or group_by is not None
+    if not override:
+        check_folder_in_scope(
+            filters.get("folder") or (None if searches_stored else "Inbox"),
+            "Cannot list", "Pass override=true to list it anyway"
+        )
+    
+    my_address = settings.user_email or current_user.email
+    if searches_stored:
+        if filters.get("awaiting_reply"):
+            await cancel_on_disconnect(
+                request,
+                detect_awaiting_reply(
+                    email_service,
+                    current_user.id,
+                    my_address,
+                    settings.awaiting_reply_days,
+                    ai_service=ai_service if settings.awaiting_reply_ai_check else None
+                )
+            )
+        if group_by == "conversation":
+            listing = email_service.get_emails_grouped_by_conversation(
+                current_user.id, filters, limit=limit, offset=offset,
+                columns=stored_columns(fields), my_address=my_address, include_muted=include_muted
+            )
+        else:
+            listing = email_service.search_stored_emails(
+                current_user.id, filters, sort=sort, limit=limit, offset=offset,
+                columns=stored_columns(fields), my_address=my_address, include_muted=include_muted
+            )
+        emails, total = await cancel_on_disconnect(request, listing)
+        return EmailListResponse(
+            emails=emails,
+            total=total,
+            offset=offset,
+            limit=limit,
+            has_more=offset + len(emails) < total
+        )
+    
+    emails = await cancel_on_disconnect(
+        request,
+        email_service.get_emails(
+            folder_name=filters.get("folder", "Inbox"), count=limit, offset=offset, user_id=current_user.id
+        )
+    )
+    
+    # Calculate if there are more emails
+    has_more = len(emails) == limit
+    
+    if not include_muted:
+        muted = await email_service.get_muted_conversations(
+            current_user.id, (email.get("conversation_id") for email in emails)
+        )
+        emails = [email for email in emails if email.get("conversation_id") not in muted]
+    
+    if fields is None or "note_count" in fields:
+        counts = await email_service.get_note_counts(current_user.id, (email["id"] for email in emails))
+        emails = [{**email, "note_count": counts.get(email["id"], 0)} for email in emails]
+    
+    return EmailListResponse(
+        emails=[project_email(email, fields) for email in emails],
+        total=len(emails),
+        offset=offset,
+        limit=limit,
+        has_more=has_more,
+        degraded=email_service.degraded
+    )
 
 
 @router.get("/emails", response_model=EmailListResponse)
 async def get_emails(
-    folder: str = Query("Inbox", description="Email folder name"),
+    request: Request,
+    folder: Optional[str] = Query(None, description="Email folder name (default: Inbox)"),
     limit: int = Query(50, ge=1, le=100, description="Number of emails to retrieve"),
     offset: int = Query(0, ge=0, description="Number of emails to skip"),
+    awaiting_reply: bool = Query(False, description="Only threads awaiting your reply"),
+    to_me: bool = Query(False, description="Only stored emails sent to you with few other recipients"),
+    category: Optional[str] = Query(None, description="Only stored emails in this AI category"),
+    unread: Optional[bool] = Query(None, description="Only unread (true) or read (false) stored emails"),
+    flagged: Optional[bool] = Query(None, description="Only flagged (true) or unflagged (false) stored emails"),
+    sender: Optional[str] = Query(None, description="Only stored emails whose sender contains this text"),
+    received_after: Optional[str] = Query(None, description="ISO date/time or relative age such as 7d"),
+    received_before: Optional[str] = Query(None, description="ISO date/time or relative age such as 7d"),
+    sort: Optional[str] = Query(None, description="Stored-search order: received_desc, received_asc, sender, subject, importance"),
+    fields: Optional[str] = Query(None, description="Comma-separated email keys to return, e.g. id,subject,sender"),
+    override: bool = Query(False, description="List a folder excluded by settings.excluded_folders anyway"),
+    include_muted: bool = Query(False, description="Also list emails of muted conversations"),
+    group_by: Optional[str] = Query(None, description="conversation: one entry per stored conversation"),
     current_user: UserInDB = Depends(get_current_user),
-    provider: EmailProvider = Depends(get_email_provider)
+    email_service: EmailService = Depends(get_email_service),
+    ai_service = Depends(get_ai_service)
 ):
     """Get paginated list of emails from specified folder.
     
+    With awaiting_reply=true, stored threads are re-checked for unanswered
+    questions and the latest message of each one awaiting a reply is listed.
+    With to_me=true, only stored emails with your address in To and fewer
+    than settings.to_me_recipient_limit recipients are listed.
+    Category, read state, flag, sender, and received-date filters search
+    stored (already processed) emails instead of the provider folder.
+    fields limits each email to the listed keys (see EMAIL_FIELDS); leaving
+    out content and body keeps list payloads small. A folder excluded by
+    settings.excluded_folders is refused unless override=true. Emails of
+    muted conversations are left out unless include_muted=true. Every
+    email carries note_count, the number of notes on it.
+    With group_by=conversation, stored emails are listed one entry per
+    conversation: the latest email's fields plus conversation_count,
+    unread_in_thread, participants, and has_actionable, latest activity
+    first, with limit and offset counting conversations.
+    
     Args:
+        request: Incoming request, used to cancel work on client disconnect
         folder: Name of the email folder (default: Inbox)
         limit: Maximum number of emails to return (1-100)
         offset: Number of emails to skip for pagination
+        awaiting_reply: Only return emails awaiting the user's reply
+        to_me: Only return emails sent to the user with few other recipients
+        category: Stored-email category filter
+        unread: Stored-email read state filter
+        flagged: Stored-email flag filter
+        sender: Stored-email sender substring filter
+        received_after: Stored emails received at or after this time
+        received_before: Stored emails received before this time
+        sort: Order of stored-email results
+        fields: Comma-separated keys to keep in each email
+        override: List an excluded folder anyway
+        include_muted: Also list emails of muted conversations
+        group_by: Group stored emails by conversation
         current_user: Authenticated user
-        provider: Email provider instance
+        email_service: Email service instance
+        ai_service: AI service for the optional awaiting-reply check
     
     Returns:
-        Paginated list of emails with metadata
+        Paginated list of emails (or conversations) with metadata
+    
+    Raises:
+        APIError: 400 if fields names an unknown key
+        ExcludedFolderError: 403 if the folder is excluded and override is false
     """
     try:
-        emails = provider.get_emails(
-            folder_name=folder,
-            count=limit,
-            offset=offset
+        try:
+            projection = validate_fields(fields)
+        except ValueError as e:
+            raise APIError(status.HTTP_400_BAD_REQUEST, str(e))
+        filters = {
+            "folder": folder,
+            "awaiting_reply": awaiting_reply,
+            "to_me": to_me,
+            "category": category,
+            "unread": unread,
+            "flagged": flagged,
+            "sender": sender,
+            "received_after": received_after,
+            "received_before": received_before,
+        }
+        return await list_emails(
+            request, filters, sort, limit, offset, current_user, email_service, ai_service,
+            fields=projection, override=override, include_muted=include_muted, group_by=group_by
         )
         
-        # Calculate if there are more emails
-        has_more = len(emails) == limit
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve emails")
+
+
+@router.get("/emails/counters", response_model=EmailCounters)
+async def get_email_counters(
+    response: Response,
+    since: Optional[datetime] = Query(None, description="Only count emails received since this time"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get total and unread counts per AI category and folder for sidebar badges.
+    
+    Args:
+        response: Outgoing response, used to set caching headers
+        since: Only count emails received at or after this time
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Counts by category and folder, plus needs-review and flagged totals
+    """
+    try:
+        counters = await email_service.get_counters(current_user.id, since=since)
         
-        return EmailListResponse(
-            emails=emails,
-            total=len(emails),
-            offset=offset,
-            limit=limit,
-            has_more=has_more
-        )
+        # Badges tolerate slightly stale counts; let the client reuse them briefly
+        response.headers["Cache-Control"] = "private, max-age=15"
+        return counters
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Failed to retrieve emails: {str(e)}"
-        )
+        raise to_http_exception(e, "Failed to retrieve email counters")
 
 
-@router.get("/emails/{email_id}", response_model=Dict[str, Any])
-async def get_email(
-    email_id: str,
+@router.get("/emails/storage-stats", response_model=StorageStats)
+async def get_storage_stats(
     current_user: UserInDB = Depends(get_current_user),
-    provider: EmailProvider = Depends(get_email_provider)
+    email_service: EmailService = Depends(get_email_service)
 ):
-    """Get specific email by ID with full content.
+    """Get synced email count, database size, and attachment usage.
     
     Args:
-        email_id: Unique email identifier
         current_user: Authenticated user
-        provider: Email provider instance
+        email_service: Email service instance
     
     Returns:
-        Full email data including body content
+        Totals, attachment bytes by sender and folder, and the largest
+        emails with links to their detail endpoint
     """
     try:
-        email = provider.get_email_content(email_id)
+        return await email_service.get_storage_stats(current_user.id)
         
-        if not email:
-            raise HTTPException(
-                status_code=status.HTTP_404_NOT_FOUND,
-                detail=f"Email with ID '{email_id}' not found"
-            )
+    except Exception as e:
+        raise to_http_exception(e, "Failed to compute storage stats")
+
+
+@router.get("/emails/aging", response_model=EmailAgingReport)
+async def get_aging_report(
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get how long actionable emails have been waiting.
+    
+    Emails classified required_personal_action or team_action are
+    bucketed by days since they were received (0-2d, 3-7d, 8-14d, 15+d);
+    emails whose linked task is completed are left out.
+    
+    Args:
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Counts per age bucket and the ten oldest emails with their linked
+        task's status
+    """
+    try:
+        return await email_service.get_aging_report(current_user.id)
         
-        return email
+    except Exception as e:
+        raise to_http_exception(e, "Failed to compute email aging report")
+
+
+@router.get("/emails/folder-hygiene", response_model=FolderHygieneReport)
+async def get_folder_hygiene(
+    request: Request,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get how old the emails piling up in category folders are.
+    
+    Covers every folder a category files email into, other than the
+    inbox: the mail client's item count, the stored emails bucketed by
+    days since receipt (0-30d, 31-90d, 91-365d, 366+d), the folder's
+    retention from settings.folder_retention_days (or
+    folder_retention_default_days), and how many emails
+    POST /api/emails/folder-hygiene/purge would move for being past it.
+    Folders covered by settings.excluded_folders are marked excluded and
+    their emails are not read.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        One entry per category folder, by name
+    """
+    try:
+        return await cancel_on_disconnect(request, email_service.get_folder_hygiene(current_user.id))
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Failed to retrieve email: {str(e)}"
-        )
+        raise to_http_exception(e, "Failed to compute folder hygiene report")
 
 
-@router.post("/emails/{email_id}/mark-read", response_model=EmailOperationResponse)
-async def mark_email_as_read(
-    email_id: str,
+@router.get("/emails/domains", response_model=SenderDomainReport)
+async def get_sender_domains(
+    request: Request,
+    limit: int = Query(50, ge=1, le=500, description="Most domains returned, largest first"),
     current_user: UserInDB = Depends(get_current_user),
-    provider: EmailProvider = Depends(get_email_provider)
+    email_service: EmailService = Depends(get_email_service)
 ):
-    """Mark email as read.
+    """Get stored email totals per sender domain.
+    
+    Senders are grouped by registrable domain, so subdomains count toward
+    their parent and plus-addressing is ignored. Each domain has its
+    total, unread, and unclassified counts, its most common AI category,
+    and the share of its emails categorized spam_to_delete.
     
     Args:
-        email_id: Unique email identifier
+        request: Incoming request, used to cancel work on client disconnect
+        limit: Most domains returned
         current_user: Authenticated user
-        provider: Email provider instance
+        email_service: Email service instance
     
     Returns:
-        Operation result
+        Domains by email count, largest first, and how many there are in all
     """
     try:
-        success = provider.mark_as_read(email_id)
+        return await cancel_on_disconnect(request, email_service.get_sender_domains(current_user.id, limit))
         
-        if success:
-            return EmailOperationResponse(
-                success=True,
-                message="Email marked as read successfully",
-                email_id=email_id
-            )
-        else:
-            return EmailOperationResponse(
-                success=False,
-                message="Failed to mark email as read",
-                email_id=email_id
+    except Exception as e:
+        raise to_http_exception(e, "Failed to compute sender domains")
+
+
+@router.get("/emails/blocking", response_model=BlockingItemListResponse)
+async def get_blocking_items(
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """List the actions other people are waiting on, oldest first.
+    
+    Items come from POST /api/ai/holistic-analysis runs that marked an
+    action blocking_others, each with its canonical email and the latest
+    task made from that email. Items whose email is no longer stored,
+    whose task is completed or cancelled, that a later run stopped
+    flagging, or that were dismissed are left out.
+    """
+    try:
+        items = await email_service.get_blocking_items(current_user.id)
+        return BlockingItemListResponse(items=[BlockingItem(**item) for item in items], total=len(items))
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve blocking items")
+
+
+@router.post("/emails/blocking/{item_id}/dismiss")
+async def dismiss_blocking_item(
+    item_id: int,
+    dismissal: BlockingDismissRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Dismiss a blocking item as completed elsewhere or not actually blocking.
+    
+    The item stays dismissed when later runs flag its email again, and
+    the reason and note are passed to those runs as corrections.
+    
+    Raises:
+        NotFoundError: If the user has no such open item
+    """
+    try:
+        try:
+            await email_service.dismiss_blocking_item(
+                item_id, current_user.id, dismissal.reason, dismissal.note
             )
+        except LookupError as e:
+            raise NotFoundError(str(e))
+        return {"message": "Blocking item dismissed"}
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Failed to mark email as read: {str(e)}"
-        )
+        raise to_http_exception(e, "Failed to dismiss blocking item")
 
 
-@router.post("/emails/{email_id}/move", response_model=EmailOperationResponse)
-async def move_email(
-    email_id: str,
-    destination_folder: str = Query(..., description="Destination folder name"),
+@router.get("/emails/progress", response_model=InboxProgress)
+async def get_inbox_progress(
     current_user: UserInDB = Depends(get_current_user),
-    provider: EmailProvider = Depends(get_email_provider)
+    email_service: EmailService = Depends(get_email_service)
 ):
-    """Move email to another folder.
+    """Get today's progress toward an empty inbox.
+    
+    Counts, for today in the user's time zone, the inbox emails classified,
+    the stored emails categorized or moved in Outlook, and the tasks
+    completed, plus the inbox emails still without a category and how many
+    days in a row (at most 7) that count hit zero.
     
     Args:
-        email_id: Unique email identifier
-        destination_folder: Name of destination folder
         current_user: Authenticated user
-        provider: Email provider instance
+        email_service: Email service instance
     
     Returns:
-        Operation result
+        Today's counts, the streak, and the last 7 days of snapshots
     """
     try:
-        success = provider.move_email(email_id, destination_folder)
+        return await email_service.get_inbox_progress(current_user.id)
         
-        if success:
-            return EmailOperationResponse(
-                success=True,
-                message=f"Email moved to '{destination_folder}' successfully",
-                email_id=email_id
-            )
-        else:
-            return EmailOperationResponse(
-                success=False,
-                message=f"Failed to move email to '{destination_folder}'",
-                email_id=email_id
-            )
+    except Exception as e:
+        raise to_http_exception(e, "Failed to compute inbox progress")
+
+
+@router.get("/emails/senders/{sender}/reputation", response_model=SenderReputation)
+async def get_sender_reputation(
+    request: Request,
+    sender: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get a sender's reputation score with its component breakdown.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        sender: Sender address as stored on emails
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Sender score (None without history) and components
+    """
+    try:
+        return await cancel_on_disconnect(
+            request,
+            email_service.get_sender_reputation(sender, current_user.id)
+        )
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Failed to move email: {str(e)}"
+        raise to_http_exception(e, "Failed to compute sender reputation")
+
+
+@router.get("/emails/quarantine", response_model=EmailListResponse)
+async def get_quarantined_emails(
+    limit: int = Query(50, ge=1, le=100, description="Maximum emails to return"),
+    offset: int = Query(0, ge=0, description="Emails to skip"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """List spam waiting in quarantine, soonest to be purged first.
+    
+    Args:
+        limit: Maximum number of emails to return (1-100)
+        offset: Number of emails to skip for pagination
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Paginated quarantined emails with their quarantined_until time
+    """
+    try:
+        emails, total = await email_service.get_quarantined_emails(current_user.id, limit=limit, offset=offset)
+        return EmailListResponse(
+            emails=emails,
+            total=total,
+            offset=offset,
+            limit=limit,
+            has_more=offset + len(emails) < total
         )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve quarantined emails")
 
 
-@router.get("/folders", response_model=EmailFolderResponse)
-async def get_folders(
+@router.get("/emails/outbox", response_model=OutboxListResponse)
+async def get_outbox(
+    state: Optional[str] = Query(None, alias="status", description="Only pending or dead (dead-lettered) changes"),
     current_user: UserInDB = Depends(get_current_user),
-    provider: EmailProvider = Depends(get_email_provider)
+    email_service: EmailService = Depends(get_email_service)
 ):
-    """Get list of available email folders.
+    """List mailbox changes queued while Outlook was unavailable, oldest first.
+    
+    Pending changes are applied by the outbox_drain job once Outlook
+    answers again; dead-lettered ones failed settings.outbox_max_attempts
+    times and stay here until deleted.
     
     Args:
+        state: Only changes in this state (the status query parameter)
         current_user: Authenticated user
-        provider: Email provider instance
+        email_service: Email service instance
     
     Returns:
-        List of available folders with metadata
+        The queued changes and the pending and dead-lettered counts
     """
     try:
-        folders = provider.get_folders()
+        if state is not None and state not in (STATUS_PENDING, STATUS_DEAD):
+            raise InputValidationError(f"Unknown status '{state}'. Valid statuses: {STATUS_PENDING}, {STATUS_DEAD}")
+        entries, counts = await email_service.outbox.list_entries(state)
+        return OutboxListResponse(entries=entries, pending=counts[STATUS_PENDING], dead=counts[STATUS_DEAD])
         
-        return EmailFolderResponse(
-            folders=folders,
-            total=len(folders)
-        )
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve the outbox")
+
+
+@router.delete("/emails/outbox/{entry_id}")
+async def delete_outbox_entry(
+    entry_id: int,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Discard a queued or dead-lettered change without applying it."""
+    try:
+        if not await email_service.outbox.delete(entry_id):
+            raise NotFoundError(f"Outbox entry {entry_id} not found")
+        return {"message": "Outbox entry deleted successfully"}
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Failed to retrieve folders: {str(e)}"
+        raise to_http_exception(e, "Failed to delete outbox entry")
+
+
+@router.get("/emails/unified", response_model=UnifiedEmailListResponse)
+async def get_unified_email_list(
+    request: Request,
+    folder: str = Query("Inbox", description="Folder to read from every mailbox"),
+    limit: int = Query(50, ge=1, le=100, description="Maximum emails to return"),
+    cursor: Optional[str] = Query(None, description="next_cursor from the previous page"),
+    current_user: UserInDB = Depends(get_current_user),
+    sources: List[MailboxSource] = Depends(get_mailbox_sources)
+):
+    """List emails from the primary and shared mailboxes merged newest first.
+    
+    Each email carries the mailbox it came from. Mailboxes that fail are
+    listed in warnings instead of failing the request.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        folder: Folder name to read in each mailbox
+        limit: Maximum number of emails to return (1-100)
+        cursor: Opaque cursor from a previous page
+        current_user: Authenticated user
+        sources: Mailboxes to merge
+    
+    Returns:
+        Merged page with next_cursor and per-mailbox warnings
+    """
+    try:
+        return await cancel_on_disconnect(
+            request,
+            get_unified_emails(sources, folder=folder, limit=limit, cursor=cursor)
         )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve unified emails")
 
 
-@router.get("/conversations/{conversation_id}", response_model=ConversationResponse)
-async def get_conversation_thread(
-    conversation_id: str,
+@router.get("/emails/search", response_model=EmailListResponse)
+async def search_emails(
+    request: Request,
+    q: Optional[str] = Query(None, description="Text to find in the subject, body, sender, or notes"),
+    category: Optional[str] = Query(None, description="Only stored emails in this AI category"),
+    folder: Optional[str] = Query(None, description="Only stored emails in this folder"),
+    sender: Optional[str] = Query(None, description="Only stored emails whose sender contains this text"),
+    received_after: Optional[str] = Query(None, description="ISO date/time or relative age such as 7d"),
+    received_before: Optional[str] = Query(None, description="ISO date/time or relative age such as 7d"),
+    limit: int = Query(50, ge=1, le=100, description="Maximum emails to return"),
+    offset: int = Query(0, ge=0, description="Emails to skip"),
+    sort: Optional[str] = Query(None, description="Order: received_desc, received_asc, sender, subject, importance"),
     current_user: UserInDB = Depends(get_current_user),
-    provider: EmailProvider = Depends(get_email_provider)
+    email_service: EmailService = Depends(get_email_service)
 ):
-    """Get all emails in a conversation thread.
+    """Search stored emails for text in the subject, body, sender, or notes.
+    
+    Notes on an email are searched too. Each result has a search_meta
+    object with matched_fields (subject, content, sender, notes) and an
+    HTML-escaped snippet in which every match is
+    wrapped in <em></em>. Without q, emails matching the filters are
+    returned without search_meta; POST /api/ai/search-query produces these
+    parameters from a natural language request.
     
     Args:
-        conversation_id: Unique conversation identifier
+        request: Incoming request, used to cancel work on client disconnect
+        q: Text to search for, case-insensitively
+        category: Stored-email category filter
+        folder: Stored-email folder filter
+        sender: Stored-email sender substring filter
+        received_after: Stored emails received at or after this time
+        received_before: Stored emails received before this time
+        limit: Maximum number of emails to return (1-100)
+        offset: Number of emails to skip for pagination
+        sort: Order of results
         current_user: Authenticated user
-        provider: Email provider instance
+        email_service: Email service instance
     
     Returns:
-        All emails in the conversation thread
+        Paginated matching emails with search metadata
+    
+    Raises:
+        InputValidationError: If the query, a filter, or the sort is invalid,
+            or neither q nor a filter is given
     """
     try:
-        emails = provider.get_conversation_thread(conversation_id)
+        try:
+            filters = validate_email_filters({
+                "category": category, "folder": folder, "sender": sender,
+                "received_after": received_after, "received_before": received_before,
+            })
+            query = validate_query(q) if q is not None or not filters else None
+            sort = validate_sort(sort or DEFAULT_SORT)
+        except ValueError as e:
+            raise InputValidationError(str(e))
         
-        return ConversationResponse(
-            conversation_id=conversation_id,
+        emails, total = await cancel_on_disconnect(
+            request,
+            email_service.search_stored_emails(
+                current_user.id, filters, sort=sort, limit=limit, offset=offset, query=query
+            )
+        )
+        return EmailListResponse(
             emails=emails,
-            total=len(emails)
+            total=total,
+            offset=offset,
+            limit=limit,
+            has_more=offset + len(emails) < total
         )
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Failed to retrieve conversation: {str(e)}"
-        )
+        raise to_http_exception(e, "Failed to search emails")
 
 
-@router.post("/emails/batch-process", response_model=EmailBatchResult)
-async def batch_process_emails(
-    batch_request: EmailBatch,
+@router.get("/emails/outlook-categories", response_model=OutlookCategoriesResponse)
+async def get_outlook_categories(
+    force_colors: bool = Query(False, description="Recolor existing categories to the configured palette"),
     current_user: UserInDB = Depends(get_current_user),
-    provider: EmailProvider = Depends(get_email_provider)
+    email_service: EmailService = Depends(get_email_service)
 ):
-    """Batch process multiple emails for classification and analysis.
+    """Make sure Outlook has a colored category for each AI category.
+    
+    Missing categories are created with their color from
+    settings.outlook_category_colors. Categories the user already has keep
+    the color they chose unless force_colors is set.
     
     Args:
-        batch_request: Batch of emails to process
+        force_colors: Recolor existing categories that differ from the palette
         current_user: Authenticated user
-        provider: Email provider instance
+        email_service: Email service instance
     
     Returns:
-        Batch processing results
+        Effective category name -> color mapping
     """
     try:
-        # Get email content for each email ID in the batch
-        processed_emails = []
-        errors = []
+        categories = await email_service.ensure_categories(force_colors=force_colors)
+        return OutlookCategoriesResponse(categories=categories)
         
-        for email_data in batch_request.emails:
-            try:
-                if isinstance(email_data, dict) and "id" in email_data:
-                    email_id = email_data["id"]
-                    email_content = provider.get_email_content(email_id)
-                    if email_content:
-                        processed_emails.append(email_content)
-                elif isinstance(email_data, dict):
-                    # Already has email content
-                    processed_emails.append(email_data)
-                else:
-                    errors.append(f"Invalid email data format: {email_data}")
-            except Exception as e:
-                errors.append(f"Failed to process email: {str(e)}")
-        
-        # TODO: Integrate with AI processing for classification
-        # For now, return basic results
-        results = []
-        for email in processed_emails:
-            results.append({
-                "category": "unclassified",
-                "confidence": 0.5,
-                "reasoning": "Basic classification - AI integration pending",
-                "action_items": [],
-                "priority": "normal"
-            })
+    except NotImplementedError as e:
+        raise APIError(status.HTTP_501_NOT_IMPLEMENTED, str(e))
+    except Exception as e:
+        raise to_http_exception(e, "Failed to create Outlook categories")
+
+
+@router.get("/emails/stores", response_model=OutlookStoreListResponse)
+async def get_stores(
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """List the stores (mailboxes and data files) in the Outlook profile.
+    
+    Args:
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Each store's display name and ID, which one is the profile's
+        default, and which one folder and item operations use
+    """
+    try:
+        stores = await email_service.list_stores()
+        return OutlookStoreListResponse(stores=stores)
+        
+    except NotImplementedError as e:
+        raise APIError(status.HTTP_501_NOT_IMPLEMENTED, str(e))
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list Outlook stores")
+
+
+@router.post("/emails/stores/select", response_model=OutlookStore)
+async def select_store(
+    selection: OutlookStoreSelection,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Switch folder and item operations to another Outlook store.
+    
+    Cached folder lists and categories are re-read from the selected
+    store. The choice lasts until the API restarts, which goes back to
+    settings.outlook_store_name.
+    
+    Args:
+        selection: Display name of the store
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The selected store
+    """
+    try:
+        store = await email_service.select_store(selection.name)
+        return OutlookStore(**store)
+        
+    except NotImplementedError as e:
+        raise APIError(status.HTTP_501_NOT_IMPLEMENTED, str(e))
+    except Exception as e:
+        raise to_http_exception(e, f"Failed to select Outlook store {selection.name}")
+
+
+@router.get("/emails/{email_id}", response_model=Dict[str, Any])
+async def get_email(
+    request: Request,
+    email_id: str,
+    include_reputation: bool = Query(False, description="Include the sender's reputation score"),
+    include_raw: bool = Query(False, description="Include the unsanitized HTML body as raw_body"),
+    source: str = Query("auto", description="Where to look: auto (database, then Outlook), outlook, or database"),
+    persist: bool = Query(False, description="With source=auto, store an email found only in Outlook"),
+    rewrite_cid: bool = Query(False, description="Point cid: images at GET /api/emails/{id}/inline/{cid}"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get specific email by ID with full content.
+    
+    By default the stored copy is returned when there is one, and Outlook
+    is asked otherwise, so callers need not know which store has the
+    email. The response's source says which one answered: stored copies
+    carry content, Outlook emails carry body. With rewrite_cid, embedded
+    images of an HTML body load from the inline attachment endpoint
+    rather than rendering broken; raw bodies are left as they are. The
+    email's note_count and the text of its newest note, latest_note, are
+    always included.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        email_id: Unique email identifier
+        include_reputation: Add sender_score and sender_reputation to the response
+        include_raw: Add the unsanitized body as raw_body (for forensics)
+        source: Store to read from (see EMAIL_SOURCES)
+        persist: Store an email only Outlook has
+        rewrite_cid: Rewrite cid: image sources while sanitizing
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Full email data including the sanitized body content and its
+        source, with degraded set when the stored copy was served because
+        the provider was down
+    """
+    try:
+        email = await cancel_on_disconnect(
+            request,
+            email_service.get_email_by_id(
+                email_id, include_raw=include_raw, user_id=current_user.id, source=source, persist=persist
+            )
+        )
+        
+        if not email:
+            raise NotFoundError(f"Email with ID '{email_id}' not found")
+        notes = await email_service.get_notes(email_id, current_user.id)
+        email = {**email, "note_count": len(notes), "latest_note": notes[0]["text"] if notes else None}
+        if email_service.degraded:
+            email = {**email, "degraded": True}
+        if rewrite_cid:
+            rewritten = {field: rewrite_cid_references(email[field], email_id) for field in ("body", "content")
+                         if field in email}
+            email = {**email, **rewritten}
+        
+        if include_reputation and email.get("sender"):
+            reputation = await cancel_on_disconnect(
+                request,
+                email_service.get_sender_reputation(email["sender"], current_user.id)
+            )
+            email = {
+                **email,
+                "sender_score": reputation.score,
+                "sender_reputation": reputation.model_dump()
+            }
+        
+        return email
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve email")
+
+
+@router.post("/emails/{email_id}/notes", response_model=EmailNote, status_code=status.HTTP_201_CREATED)
+async def add_email_note(
+    email_id: str,
+    note: EmailNoteCreate,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Attach a free-text note to a stored email.
+    
+    Args:
+        email_id: Stored email
+        note: Text of the note
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The new note
+    
+    Raises:
+        NotFoundError: If the email is not stored
+    """
+    try:
+        try:
+            return EmailNote(**await email_service.add_note(email_id, current_user.id, note.text))
+        except LookupError as e:
+            raise NotFoundError(str(e))
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to add note")
+
+
+@router.get("/emails/{email_id}/notes", response_model=EmailNoteListResponse)
+async def get_email_notes(
+    email_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """List the notes on a stored email, newest first.
+    
+    Raises:
+        NotFoundError: If the email is not stored
+    """
+    try:
+        if await email_service.get_stored_email(email_id, current_user.id) is None:
+            raise NotFoundError(f"Email {email_id} not found")
+        notes = await email_service.get_notes(email_id, current_user.id)
+        return EmailNoteListResponse(email_id=email_id, notes=[EmailNote(**note) for note in notes], total=len(notes))
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve notes")
+
+
+@router.delete("/emails/{email_id}/notes/{note_id}")
+async def delete_email_note(
+    email_id: str,
+    note_id: int,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Delete one of the notes on an email."""
+    try:
+        try:
+            await email_service.delete_note(email_id, note_id, current_user.id)
+        except LookupError as e:
+            raise NotFoundError(str(e))
+        return {"message": "Note deleted successfully"}
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to delete note")
+
+
+@router.get("/emails/{email_id}/links", response_model=EmailLinksResponse)
+async def get_email_links(
+    request: Request,
+    email_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get the links mentioned in an email.
+    
+    Links come from both anchors and bare URLs in the body. Safe Links are
+    unwrapped, duplicates removed, and each link is classified by type.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        email_id: Unique email identifier
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Links in order of first appearance, with anchor text when available
+    """
+    try:
+        email = await cancel_on_disconnect(request, email_service.get_email_by_id(email_id))
+        
+        if not email:
+            raise NotFoundError(f"Email with ID '{email_id}' not found")
+        
+        links = extract_links(email.get("body") or email.get("content"))
+        return EmailLinksResponse(email_id=email_id, links=links, total=len(links))
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to extract email links")
+
+
+@router.get("/emails/{email_id}/inline/{cid}")
+async def get_inline_attachment(
+    email_id: str,
+    cid: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get the bytes of an image an HTML email embeds as cid:<cid>.
+    
+    GET /emails/{email_id}?rewrite_cid=true points the body's cid: images
+    here. The attachment is read from the mail client by Content-ID (with
+    or without angle brackets); without a MIME type from the provider, the
+    type is guessed from the file name. Types other than INLINE_IMAGE_TYPES
+    are sent as application/octet-stream downloads.
+    
+    Args:
+        email_id: Email the image is embedded in
+        cid: Content-ID of the inline attachment
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The attachment bytes
+    
+    Raises:
+        NotFoundError: If the email has no attachment with this Content-ID
+    """
+    try:
+        attachment = await email_service.get_inline_attachment(email_id, content_id(cid))
+        if not attachment:
+            raise NotFoundError(f"Email '{email_id}' has no inline attachment '{cid}'")
+        
+        media_type = attachment.get("content_type") or mimetypes.guess_type(attachment.get("name") or "")[0] or ""
+        media_type = media_type.split(";")[0].strip().lower()
+        headers = dict(INLINE_HEADERS)
+        if media_type not in INLINE_IMAGE_TYPES:
+            media_type = "application/octet-stream"
+            headers["Content-Disposition"] = "attachment"
+        return Response(content=attachment["data"], media_type=media_type, headers=headers)
+        
+    except NotImplementedError as e:
+        raise APIError(status.HTTP_501_NOT_IMPLEMENTED, str(e))
+    except Exception as e:
+        raise to_http_exception(e, "Failed to read inline attachment")
+
+
+@router.get("/emails/{email_id}/diff/{other_email_id}", response_model=EmailDiffResponse)
+async def get_email_diff(
+    request: Request,
+    email_id: str,
+    other_email_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get what changed between two emails, e.g. an announcement and its corrected resend.
+    
+    The plain-text bodies are compared word by word. Positions are
+    character offsets into the returned email_text and other_text; bodies
+    over settings.email_diff_max_kb are cut first and the diff says so.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        email_id: Email the changes are made to
+        other_email_id: Email with the changes
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Insertions and deletions in order, with the similarity of the bodies
+    """
+    try:
+        texts = []
+        for requested_id in (email_id, other_email_id):
+            email = await cancel_on_disconnect(request, email_service.get_email_by_id(requested_id))
+            if not email:
+                raise NotFoundError(f"Email with ID '{requested_id}' not found")
+            texts.append(plain_text(email.get("body") or email.get("content")))
+        
+        max_kb = settings.email_diff_max_kb
+        diff = diff_words(texts[0], texts[1], max_bytes=max_kb * 1024)
+        return EmailDiffResponse(
+            email_id=email_id,
+            other_email_id=other_email_id,
+            similarity=diff.similarity,
+            insertions=diff.insertions,
+            deletions=diff.deletions,
+            changes=[EmailDiffChange(**vars(change)) for change in diff.changes],
+            email_text=diff.old_text,
+            other_text=diff.new_text,
+            truncated=diff.truncated,
+            notice=f"Only the first {max_kb} KB of each body was compared" if diff.truncated else None
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to diff emails")
+
+
+@router.get("/emails/{email_id}/folder-suggestions", response_model=FolderSuggestionsResponse)
+async def get_folder_suggestions(
+    request: Request,
+    email_id: str,
+    ai: bool = Query(False, description="Also rank the mailbox's folders with the AI"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service),
+    ai_service = Depends(get_ai_service)
+):
+    """Suggest where to move an email being filed by hand.
+    
+    Suggestions come from the folder its AI category maps to, the folders
+    the sender's mail was moved to before, and with ai=true a model ranking
+    of the existing folders. Nothing is moved or stored.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        email_id: Unique email identifier
+        ai: Include AI-ranked folders
+        current_user: Authenticated user
+        email_service: Email service instance
+        ai_service: AI service for the optional ranking
+    
+    Returns:
+        Up to three suggestions with their source and confidence, best first
+    """
+    try:
+        suggestions = await cancel_on_disconnect(
+            request,
+            suggest_folders(email_service, email_id, current_user.id, ai_service=ai_service if ai else None)
+        )
+        
+        if suggestions is None:
+            raise NotFoundError(f"Email with ID '{email_id}' not found")
+        
+        return FolderSuggestionsResponse(email_id=email_id, suggestions=suggestions)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to suggest folders")
+
+
+@router.get("/emails/{email_id}/category-suggestions", response_model=CategorySuggestionsResponse)
+async def get_category_suggestions(
+    request: Request,
+    email_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Suggest categories for a stored email being classified by hand.
+    
+    Suggestions come from matching Outlook rules, the categories the
+    sender's mail was corrected to before, and the classifier's stored
+    alternatives, ranked locally without a model call. Nothing is changed.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        email_id: Unique email identifier
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Up to five suggestions with their source and score, best first
+    """
+    try:
+        suggestions = await cancel_on_disconnect(
+            request,
+            suggest_categories(email_service, email_id, current_user.id)
+        )
+        
+        if suggestions is None:
+            raise NotFoundError(f"Email with ID '{email_id}' not found")
+        
+        return suggestions
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to suggest categories")
+
+
+@router.post("/emails/{email_id}/mark-read", response_model=EmailOperationResponse)
+async def mark_email_as_read(
+    request: Request,
+    email_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Mark email as read.
+    
+    With the suppress_read_receipts setting enabled, read receipts the
+    sender requested are not sent. While Outlook is unavailable the change
+    is queued in the outbox and the response has queued set.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        email_id: Unique email identifier
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Operation result
+    """
+    try:
+        success = await cancel_on_disconnect(
+            request,
+            email_service.mark_as_read(email_id, suppress_read_receipt=settings.suppress_read_receipts)
+        )
+        
+        if email_service.queued:
+            return EmailOperationResponse(
+                success=True,
+                message="Outlook is unavailable; the email will be marked as read when it reconnects",
+                email_id=email_id,
+                queued=True
+            )
+        if success:
+            return EmailOperationResponse(
+                success=True,
+                message="Email marked as read successfully",
+                email_id=email_id
+            )
+        else:
+            return EmailOperationResponse(
+                success=False,
+                message="Failed to mark email as read",
+                email_id=email_id
+            )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to mark email as read")
+
+
+@router.post("/emails/{email_id}/move", response_model=EmailOperationResponse)
+async def move_email(
+    request: Request,
+    email_id: str,
+    destination_folder: str = Query(..., description="Destination folder name"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Move email to another folder.
+    
+    While Outlook is unavailable the move is queued in the outbox and the
+    response has queued set.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        email_id: Unique email identifier
+        destination_folder: Name of destination folder
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Operation result
+    
+    Raises:
+        ExcludedFolderError: 403 if settings.excluded_folders covers the destination
+    """
+    try:
+        success = await cancel_on_disconnect(
+            request,
+            email_service.move_email(email_id, destination_folder)
+        )
+        
+        if email_service.queued:
+            return EmailOperationResponse(
+                success=True,
+                message=f"Outlook is unavailable; the email will be moved to '{destination_folder}' when it reconnects",
+                email_id=email_id,
+                queued=True
+            )
+        if success:
+            return EmailOperationResponse(
+                success=True,
+                message=f"Email moved to '{destination_folder}' successfully",
+                email_id=email_id
+            )
+        else:
+            return EmailOperationResponse(
+                success=False,
+                message=f"Failed to move email to '{destination_folder}'",
+                email_id=email_id
+            )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to move email")
+
+
+@router.post("/emails/{email_id}/rescue", response_model=EmailOperationResponse)
+async def rescue_email(
+    request: Request,
+    email_id: str,
+    category: str = Query("work_relevant", description="Category the email should have had"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Move a quarantined email back to the inbox before it is purged.
+    
+    The chosen category is recorded as a user correction, so rescues count
+    against the classifier like any other fix.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        email_id: Quarantined email
+        category: Category recorded as the user's correction
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Operation result
+    """
+    try:
+        valid_categories = [c for c in category_names(email_service.db) if c != "spam_to_delete"]
+        if category not in valid_categories:
+            raise InputValidationError(
+                f"Unknown category '{category}'. Valid categories: {', '.join(valid_categories)}"
+            )
+        try:
+            success = await cancel_on_disconnect(
+                request, email_service.rescue_email(email_id, current_user.id, category)
+            )
+        except LookupError as e:
+            raise NotFoundError(str(e))
+        except ValueError as e:
+            raise ConflictError(str(e))
+        
+        if not success:
+            return EmailOperationResponse(
+                success=False,
+                message="Failed to move email back to the inbox",
+                email_id=email_id
+            )
+        return EmailOperationResponse(
+            success=True,
+            message=f"Email rescued to the inbox as {category}",
+            email_id=email_id
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to rescue email")
+
+
+@router.post("/emails/archive-aged", response_model=ArchiveAgedResponse)
+async def archive_aged_emails(
+    request: Request,
+    response: Response,
+    archive: ArchiveAgedRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Move stored inbox emails of some categories older than some days to the archive folder.
+    
+    Emails are selected from the database: still in the inbox, in one of
+    the categories, and received at least older_than_days ago, oldest
+    first (see EmailService.get_aged_emails). They are moved to
+    settings.archive_folder in one provider call, and the archived ones
+    are recorded as a move batch that POST
+    /api/emails/move-batches/{batch_id}/revert moves back. A dry run only
+    lists the candidates. The archive_aged background job runs the same
+    selection every archive_aged_interval_seconds with the archive_aged_*
+    settings.
+    
+    Emails the mail client did not move fail with a retryable 502. The
+    status is 200 when nothing failed, 207 when some emails failed, and
+    502 when all of them did.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        response: Outgoing response, whose status reflects item failures
+        archive: Categories, minimum age, and dry_run (defaults from settings)
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The candidates, per-item errors, and the move batch of the archived emails
+    
+    Raises:
+        ExcludedFolderError: 403 if settings.excluded_folders covers the archive folder
+    """
+    try:
+        categories = archive.categories if archive.categories is not None else settings.archive_aged_categories
+        if not categories:
+            raise InputValidationError("At least one category is required")
+        validate_categories(categories, email_service.db)
+        older_than_days = archive.older_than_days or settings.archive_aged_days
+        
+        outcome = await cancel_on_disconnect(
+            request,
+            email_service.archive_aged_emails(
+                current_user.id, categories, older_than_days, dry_run=archive.dry_run
+            )
+        )
+        errors = [
+            BatchItemError(
+                email_id=email_id, error="Failed to move email in the mail client",
+                **item_status(status.HTTP_502_BAD_GATEWAY)
+            )
+            for email_id in outcome.failed
+        ]
+        response.status_code = batch_status_code(
+            len(outcome.archived) + len(outcome.failed), [error.status_code for error in errors]
+        )
+        return ArchiveAgedResponse(
+            success_count=len(outcome.archived),
+            failure_count=len(errors),
+            errors=errors,
+            dry_run=archive.dry_run,
+            archive_folder=settings.archive_folder,
+            candidates=[ArchiveCandidate(**email) for email in outcome.candidates],
+            batch_id=outcome.batch_id
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to archive aged emails")
+
+
+@router.post("/emails/folder-hygiene/purge", response_model=FolderHygienePurgeResponse)
+async def purge_folder_hygiene(
+    request: Request,
+    response: Response,
+    purge: FolderHygienePurgeRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Move category folder emails older than the folder's retention out of the way.
+    
+    Candidates are the stored emails GET /api/emails/folder-hygiene counts
+    as suggested purges: received at least the folder's retention days
+    ago and not flagged, oldest first. They are moved to
+    settings.folder_hygiene_destination (Deleted Items) in one provider
+    call, and the purged ones are recorded as a move batch that POST
+    /api/emails/move-batches/{batch_id}/revert moves back. A dry run only
+    lists the candidates.
+    
+    Emails the mail client did not move fail with a retryable 502. The
+    status is 200 when nothing failed, 207 when some emails failed, and
+    502 when all of them did.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        response: Outgoing response, whose status reflects item failures
+        purge: Folders (defaults to every category folder not excluded) and dry_run
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The candidates, per-item errors, and the move batch of the purged emails
+    
+    Raises:
+        InputValidationError: 422 if a requested folder is not a category folder
+        ExcludedFolderError: 403 if settings.excluded_folders covers a requested
+            folder or the destination folder
+    """
+    try:
+        outcome = await cancel_on_disconnect(
+            request,
+            email_service.purge_folder_hygiene(current_user.id, purge.folders, dry_run=purge.dry_run)
+        )
+        errors = [
+            BatchItemError(
+                email_id=email_id, error="Failed to move email in the mail client",
+                **item_status(status.HTTP_502_BAD_GATEWAY)
+            )
+            for email_id in outcome.failed
+        ]
+        response.status_code = batch_status_code(
+            len(outcome.purged) + len(outcome.failed), [error.status_code for error in errors]
+        )
+        return FolderHygienePurgeResponse(
+            success_count=len(outcome.purged),
+            failure_count=len(errors),
+            errors=errors,
+            dry_run=purge.dry_run,
+            destination_folder=outcome.destination_folder,
+            candidates=[FolderHygieneCandidate(**email) for email in outcome.candidates],
+            batch_id=outcome.batch_id
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to purge category folders")
+
+
+@router.post("/emails/domains/{domain}/apply-category", response_model=DomainCategoryResponse)
+async def apply_domain_category(
+    request: Request,
+    response: Response,
+    domain: str,
+    apply: DomainCategoryRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service),
+    category_service: CategoryService = Depends(get_category_service)
+):
+    """Classify every unclassified stored email from a sender domain.
+    
+    Emails from the domain or its subdomains without a category get the
+    chosen one as a bulk correction. With apply_to_outlook they are also
+    filed in the mail client: moved into the category's folder as a move
+    batch that POST /api/emails/move-batches/{batch_id}/revert moves back,
+    or given the category when it has no folder. With create_rule, a line
+    filing the domain's mail into the category is added to its
+    classification rules, so future mail follows; a category that already
+    has the line is left alone. A dry run only lists the emails and the
+    rule line.
+    
+    Emails the mail client did not file fail with a retryable 502; their
+    stored category is still set. The status is 200 when nothing failed,
+    207 when some emails failed, and 502 when all of them did.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        response: Outgoing response, whose status reflects item failures
+        domain: Sender domain, e.g. contoso.com
+        apply: Category and options
+        current_user: Authenticated user
+        email_service: Email service instance
+        category_service: Category service whose rules get the domain's line
+    
+    Returns:
+        The classified emails, per-item errors, the move batch, and the rule line
+    
+    Raises:
+        InputValidationError: 422 for an invalid domain or unknown category
+        ExcludedFolderError: 403 if settings.excluded_folders covers the category's folder
+    """
+    try:
+        normalized = normalize_domain(domain)
+        if normalized is None or "." not in normalized:
+            raise InputValidationError(f"'{domain}' is not a domain")
+        valid_categories = category_names(email_service.db)
+        if apply.category not in valid_categories:
+            raise InputValidationError(
+                f"Unknown category '{apply.category}'. Valid categories: {', '.join(valid_categories)}"
+            )
+        
+        outcome = await cancel_on_disconnect(
+            request,
+            email_service.classify_domain(
+                current_user.id, normalized, apply.category,
+                apply_to_outlook=apply.apply_to_outlook, dry_run=apply.dry_run
+            )
+        )
+        rule_created = False
+        if apply.create_rule and not apply.dry_run:
+            rule_created = await add_domain_rule(category_service, apply.category, normalized)
+        
+        errors = [
+            BatchItemError(
+                email_id=email_id, error="Failed to file email in the mail client",
+                **item_status(status.HTTP_502_BAD_GATEWAY)
+            )
+            for email_id in outcome.failed
+        ]
+        response.status_code = batch_status_code(len(outcome.classified), [error.status_code for error in errors])
+        return DomainCategoryResponse(
+            success_count=len(outcome.classified) - len(errors),
+            failure_count=len(errors),
+            errors=errors,
+            domain=normalized,
+            category=apply.category,
+            dry_run=apply.dry_run,
+            candidates=outcome.candidates,
+            destination_folder=outcome.destination_folder,
+            batch_id=outcome.batch_id,
+            rule=domain_rule_text(normalized) if apply.create_rule else None,
+            rule_created=rule_created
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, f"Failed to classify emails from {domain}")
+
+
+@router.post("/emails/analyses/{analysis_id}/apply", response_model=HolisticApplyResponse)
+async def apply_holistic_analysis(
+    request: Request,
+    response: Response,
+    analysis_id: int,
+    apply: HolisticApplyRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Apply the cleanups a stored holistic analysis run suggested.
+    
+    Runs come from POST /api/ai/holistic-analysis. Emails it found
+    superseded, and the emails of its duplicate groups other than the one
+    kept, are moved to settings.archive_folder; open tasks made from the
+    emails it found expired are completed or cancelled. Only emails the
+    run analyzed and that are still stored outside the archive folder are
+    moved, so applying a run again does nothing new. Everything is
+    recorded as one move batch that POST
+    /api/emails/move-batches/{batch_id}/revert undoes, and in the activity
+    log.
+    
+    An email fails if it is no longer stored (404) or the mail client did
+    not move it (502, retryable). The status is 200 when nothing failed,
+    207 when some changes failed, and the dominant item error's status
+    when all of them did. A dry run only lists the intended changes and
+    the emails no longer stored, with status 200.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        response: Outgoing response, whose status reflects item failures
+        analysis_id: Stored run, from POST /api/ai/holistic-analysis
+        apply: Selected cleanups, task status, and dry_run
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The intended changes, per-item errors, the closed tasks, and the move batch
+    
+    Raises:
+        NotFoundError: 404 if the user has no such run
+        ExcludedFolderError: 403 if settings.excluded_folders covers the archive folder
+    """
+    try:
+        try:
+            outcome = await cancel_on_disconnect(
+                request,
+                email_service.apply_holistic_analysis(
+                    analysis_id, current_user.id,
+                    archive_superseded=apply.archive_superseded,
+                    merge_duplicates=apply.merge_duplicates,
+                    complete_expired_tasks=apply.complete_expired_tasks,
+                    task_status=apply.expired_task_status,
+                    dry_run=apply.dry_run
+                )
+            )
+        except LookupError as e:
+            raise NotFoundError(str(e))
+        
+        errors = [
+            BatchItemError(email_id=email_id, error="Email not found", **item_status(status.HTTP_404_NOT_FOUND))
+            for email_id in outcome.missing
+        ] + [
+            BatchItemError(
+                email_id=email_id, error="Failed to move email in the mail client",
+                **item_status(status.HTTP_502_BAD_GATEWAY)
+            )
+            for email_id in outcome.failed
+        ]
+        changed = len(outcome.archived) + len(outcome.tasks_closed)
+        if not apply.dry_run:
+            response.status_code = batch_status_code(changed + len(errors), [error.status_code for error in errors])
+        return HolisticApplyResponse(
+            success_count=changed,
+            failure_count=len(errors),
+            errors=errors,
+            analysis_id=analysis_id,
+            dry_run=apply.dry_run,
+            archive_folder=settings.archive_folder,
+            archive_candidates=[HolisticArchiveCandidate(**email) for email in outcome.archive_candidates],
+            task_candidates=[HolisticTaskCandidate(**task) for task in outcome.task_candidates],
+            expired_task_status=outcome.task_status,
+            tasks_closed=outcome.tasks_closed,
+            batch_id=outcome.batch_id
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to apply holistic analysis")
+
+
+@router.post("/emails/move-batches/{batch_id}/revert", response_model=MoveBatchRevertResponse)
+async def revert_move_batch(
+    request: Request,
+    response: Response,
+    batch_id: int,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Move the emails of a move batch back to the folders they came from.
+    
+    A batch is reverted once. Tasks the batch closed are reopened unless
+    their status changed since. An email fails if it is no longer stored
+    (404), was moved out of the batch's folder since (409), or the mail
+    client did not move it back (502, retryable). The status is 200 when
+    nothing failed, 207 when some emails failed, and the dominant item
+    error's status when all of them did.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        response: Outgoing response, whose status reflects item failures
+        batch_id: Move batch, e.g. from POST /api/emails/archive-aged
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Counts of restored and failed emails with per-item errors
+    """
+    try:
+        try:
+            outcome = await cancel_on_disconnect(
+                request, email_service.revert_move_batch(batch_id, current_user.id)
+            )
+        except LookupError as e:
+            raise NotFoundError(str(e))
+        except ValueError as e:
+            raise ConflictError(str(e))
+        
+        errors = [
+            BatchItemError(email_id=email_id, error="Email not found", **item_status(status.HTTP_404_NOT_FOUND))
+            for email_id in outcome.missing
+        ] + [
+            BatchItemError(
+                email_id=email_id, error="Email was moved since the batch", **item_status(status.HTTP_409_CONFLICT)
+            )
+            for email_id in outcome.moved_since
+        ] + [
+            BatchItemError(
+                email_id=email_id, error="Failed to move email back in the mail client",
+                **item_status(status.HTTP_502_BAD_GATEWAY)
+            )
+            for email_id in outcome.failed
+        ]
+        response.status_code = batch_status_code(
+            len(outcome.restored) + len(errors), [error.status_code for error in errors]
+        )
+        return MoveBatchRevertResponse(
+            batch_id=batch_id,
+            success_count=len(outcome.restored),
+            failure_count=len(errors),
+            errors=errors,
+            tasks_restored=outcome.tasks_restored,
+            tasks_changed_since=outcome.tasks_changed_since
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to revert move batch")
+
+
+@router.get("/folders", response_model=EmailFolderResponse)
+async def get_folders(
+    request: Request,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get list of available email folders.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        List of available folders with metadata and counts, and how old the
+        counts are if the provider served them from its cache
+    """
+    try:
+        folders = await cancel_on_disconnect(request, email_service.get_folders(user_id=current_user.id))
+        
+        return EmailFolderResponse(
+            folders=folders,
+            total=len(folders),
+            cache_age_seconds=None if email_service.degraded else email_service.folder_cache_age(),
+            degraded=email_service.degraded
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve folders")
+
+
+@router.get("/conversations/{conversation_id}", response_model=ConversationResponse)
+async def get_conversation_thread(
+    request: Request,
+    conversation_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get all emails in a conversation thread.
+    
+    Merged conversations return the emails of the whole merged group.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        conversation_id: Unique conversation identifier
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        All emails in the conversation thread
+    """
+    try:
+        emails = await cancel_on_disconnect(
+            request,
+            email_service.get_conversation(conversation_id, current_user.id)
+        )
+        
+        return ConversationResponse(
+            conversation_id=conversation_id,
+            emails=emails,
+            total=len(emails),
+            degraded=email_service.degraded
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve conversation")
+
+
+@router.get("/conversations/{conversation_id}/participants", response_model=ThreadParticipantsResponse)
+async def get_conversation_participants(
+    conversation_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get everyone who sent or received a message in a conversation thread.
+    
+    Participants in the same domain as the user's address are internal.
+    Messages not stored yet are read from the provider when it is reachable.
+    
+    Args:
+        conversation_id: Unique conversation identifier
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Participants with message counts and first and last activity
+    """
+    try:
+        result = await email_service.get_conversation_participants(
+            conversation_id, current_user.id, settings.user_email or current_user.email
+        )
+        if result.message_count == 0:
+            raise NotFoundError(f"Conversation '{conversation_id}' not found")
+        return result
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve conversation participants")
+
+
+@router.post("/conversations/merge", response_model=ConversationMergeResponse)
+async def merge_conversations(
+    merge_request: ConversationMergeRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Merge conversations whose threading the provider broke.
+    
+    Forwarded mail and some senders get a new ConversationID, splitting one
+    logical thread. The stored emails of every given conversation (or of the
+    conversations of the given emails) are rewritten to one canonical
+    conversation_id; unmerge restores the originals.
+    
+    Args:
+        merge_request: Conversation IDs and/or email IDs, at least two conversations in total
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The canonical conversation and the conversations merged into it
+    """
+    try:
+        try:
+            result = await email_service.merge_conversations(
+                current_user.id,
+                conversation_ids=merge_request.conversation_ids,
+                email_ids=merge_request.email_ids
+            )
+        except LookupError as e:
+            raise NotFoundError(str(e))
+        except ValueError as e:
+            raise InputValidationError(str(e))
+        return ConversationMergeResponse(**result)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to merge conversations")
+
+
+@router.post("/conversations/{conversation_id}/unmerge", response_model=ConversationUnmergeResponse)
+async def unmerge_conversation(
+    conversation_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Split a merged conversation back into its original conversations.
+    
+    Args:
+        conversation_id: The merged conversation or any conversation merged into it
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The restored conversation IDs
+    """
+    try:
+        result = await email_service.unmerge_conversation(conversation_id, current_user.id)
+        if not result["emails_updated"]:
+            raise NotFoundError(f"Conversation '{conversation_id}' is not merged")
+        return ConversationUnmergeResponse(**result)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to unmerge conversation")
+
+
+@router.post("/conversations/{conversation_id}/mute", response_model=ConversationMuteResponse)
+async def mute_conversation(
+    conversation_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Mute a conversation.
+    
+    Its emails are tagged fyi without the AI by batch processing, left out
+    of GET /emails unless include_muted=true, and not counted. Open tasks
+    made from the conversation drop to low priority with a note.
+    
+    Args:
+        conversation_id: Conversation to mute (any merged member's ID works)
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The muted conversation and how many tasks were downgraded
+    """
+    try:
+        result = await email_service.mute_conversation(conversation_id, current_user.id)
+        return ConversationMuteResponse(**result)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to mute conversation")
+
+
+@router.post("/conversations/{conversation_id}/unmute", response_model=ConversationMuteResponse)
+async def unmute_conversation(
+    conversation_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Unmute a conversation.
+    
+    Emails are classified and listed again; task priorities lowered by
+    muting are not restored.
+    
+    Args:
+        conversation_id: Muted conversation (any merged member's ID works)
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The conversation, no longer muted
+    """
+    try:
+        if not await email_service.unmute_conversation(conversation_id, current_user.id):
+            raise NotFoundError(f"Conversation '{conversation_id}' is not muted")
+        return ConversationMuteResponse(conversation_id=conversation_id, muted=False)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to unmute conversation")
+
+
+@router.post("/emails/reconcile", response_model=ReconcileReport)
+async def reconcile_emails(
+    request: Request,
+    folder: str = Query("Inbox", description="Stored folder to check"),
+    limit: int = Query(500, ge=1, le=5000, description="Most stored emails to check"),
+    apply: bool = Query(False, description="Fix the database to match the provider"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Report where stored emails have drifted from the provider.
+    
+    Checks the newest stored emails of a folder for emails deleted in
+    Outlook and for read state, folder, and category differences. With
+    apply=true the database side is fixed; Outlook is never changed.
+    Folders excluded by settings.excluded_folders are refused.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        folder: Stored folder to check
+        limit: Most stored emails to check
+        apply: Fix the database to match the provider
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Reconciliation report
+    """
+    try:
+        report = await cancel_on_disconnect(
+            request,
+            email_service.reconcile_folder(current_user.id, folder, limit=limit, apply=apply)
+        )
+        return ReconcileReport(**report)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to reconcile emails")
+
+
+@router.post("/emails/previews/backfill", response_model=PreviewBackfillResult)
+async def backfill_email_previews(
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Compute list previews for stored emails saved before previews existed.
+    
+    Emails stored since then get their preview when they are saved, so
+    this only needs to run once; running it again is a no-op.
+    
+    Args:
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Number of emails given a preview
+    """
+    try:
+        return PreviewBackfillResult(updated=await email_service.backfill_previews(current_user.id))
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to backfill previews")
+
+
+def validate_categories(categories, db) -> None:
+    """Reject categories the classifier does not know.
+
+    Args:
+        categories: Category names to check
+        db: Database store holding the categories table
+
+    Raises:
+        InputValidationError: If any category is unknown
+    """
+    valid_categories = category_names(db)
+    unknown = sorted(set(categories) - set(valid_categories))
+    if unknown:
+        raise InputValidationError(
+            f"Unknown categories: {', '.join(unknown)}. "
+            f"Valid categories: {', '.join(valid_categories)}"
+        )
+
+
+@router.get("/emails/{email_id}/classification", response_model=ClassificationState)
+async def get_email_classification(
+    email_id: str,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Get the stored classification of an email and its version.
+    
+    Args:
+        email_id: Stored email
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The email's classification and the version to send with corrections
+    """
+    try:
+        state = await email_service.get_classification_state(email_id, current_user.id)
+        if state is None:
+            raise NotFoundError(f"Email {email_id} not found")
+        return ClassificationState(**state)
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve classification")
+
+
+@router.put("/emails/{email_id}/classification", response_model=ClassificationUpdateResponse)
+async def update_email_classification(
+    email_id: str,
+    update: ClassificationUpdate,
+    apply_to_outlook: bool = Query(False, description="Also apply the category in Outlook"),
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Correct the category of one email.
+    
+    The change is recorded in the classification history as a user change.
+    An email the provider has but that is not stored yet is stored first.
+    With version (as returned with the email), the change is only applied
+    if nobody reclassified the email since it was read; otherwise the
+    response is 409 with the current classification under error.current.
+    
+    With apply_to_outlook, the category is also applied in Outlook. The
+    stored correction stands if that fails; the failure is returned in
+    warnings.
+    
+    Args:
+        email_id: Email to reclassify
+        update: New category and the version it was chosen against
+        apply_to_outlook: Also apply the category in Outlook
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        The email's new classification and version, whether Outlook was
+        updated, and warnings for what did not go through
+    """
+    try:
+        validate_categories([update.category], email_service.db)
+        result = await email_service.update_classification(
+            current_user.id, email_id, update.category,
+            expected_version=update.version, apply_to_outlook=apply_to_outlook
+        )
+        if result.missing:
+            raise NotFoundError(f"Email {email_id} not found")
+        if result.conflict is not None:
+            raise ConflictError(
+                f"Email {email_id} was reclassified since version {update.version} "
+                f"(now version {result.conflict['version']})",
+                current=result.conflict
+            )
+        warnings = []
+        if result.outlook_error:
+            warnings.append(f"Category stored but not applied in Outlook: {result.outlook_error}")
+        return ClassificationUpdateResponse(
+            **await email_service.get_classification_state(email_id, current_user.id),
+            outlook_applied=result.outlook_applied,
+            warnings=warnings
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to update classification")
+
+
+@router.put("/emails/classifications", response_model=ClassificationCorrectionResponse)
+async def update_email_classifications(
+    request: Request,
+    response: Response,
+    batch: ClassificationCorrectionBatch,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service)
+):
+    """Correct the category of several stored emails at once.
+    
+    All corrections are stored in one transaction and recorded in the
+    classification history as user changes. Unknown emails are reported
+    per item and do not stop the rest. A correction with a version only
+    applies if the email was not reclassified since that version. With
+    apply_to_outlook, stored corrections are also applied as Outlook
+    categories; spam is quarantined instead when
+    settings.spam_quarantine_days is set.
+    
+    An email fails if it is not stored (404), its version is stale (409,
+    with its current classification in conflicts), or its Outlook category
+    could not be applied (502, retryable). The status is 200 when nothing
+    failed, 207 when some emails failed, and the dominant item error's
+    status when all of them did.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        response: Outgoing response, whose status reflects item failures
+        batch: (email_id, category, version) corrections
+        current_user: Authenticated user
+        email_service: Email service instance
+    
+    Returns:
+        Counts of updated and failed emails with per-item errors and conflicts
+    """
+    try:
+        corrections = batch.classifications
+        validate_categories((c.category for c in corrections), email_service.db)
+        
+        changes = [(c.email_id, c.category) for c in corrections]
+        expected = {c.email_id: c.version for c in corrections if c.version is not None}
+        result = await email_service.update_classifications(
+            current_user.id, changes, expected_versions=expected
+        )
+        missing = set(result.missing)
+        errors = []
+        for email_id in email_ids:
+            if email_id in missing:
+                errors.append(BatchItemError(
+                    email_id=email_id, error="Email not found", **item_status(status.HTTP_404_NOT_FOUND)
+                ))
+            elif email_id in result.conflicts:
+                errors.append(BatchItemError(
+                    email_id=email_id, error="Email was reclassified since the given version",
+                    **item_status(status.HTTP_409_CONFLICT)
+                ))
+        
+        if batch.apply_to_outlook:
+            for email_id, category in changes:
+                if email_id in missing or email_id in result.conflicts:
+                    continue
+                try:
+                    applied = await cancel_on_disconnect(
+                        request, email_service.apply_category(email_id, category, current_user.id)
+                    )
+                    if not applied:
+                        errors.append(BatchItemError(
+                            email_id=email_id, error="Failed to apply category in Outlook",
+                            **item_status(status.HTTP_502_BAD_GATEWAY)
+                        ))
+                except NotImplementedError as e:
+                    errors.append(BatchItemError(
+                        email_id=email_id, error=str(e), **item_status(status.HTTP_501_NOT_IMPLEMENTED)
+                    ))
+                except HTTPException as e:
+                    errors.append(BatchItemError(
+                        email_id=email_id, error=str(e.detail), **item_status(e.status_code)
+                    ))
+        
+        response.status_code = batch_status_code(len(changes), [error.status_code for error in errors])
+        return ClassificationCorrectionResponse(
+            success_count=len(changes) - len(errors),
+            failure_count=len(errors),
+            errors=errors,
+            conflicts=[ClassificationState(**result.conflicts[email_id])
+                       for email_id in email_ids if email_id in result.conflicts]
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to update classifications")
+
+
+@router.post("/emails/batch-process", response_model=EmailBatchResult)
+async def batch_process_emails(
+    request: Request,
+    response: Response,
+    batch_request: EmailBatch,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service),
+    ai_service = Depends(get_ai_service),
+    task_service: TaskService = Depends(get_task_service)
+):
+    """Batch process multiple emails for classification and analysis.
+    
+    In thread mode, emails sharing a conversation are classified once using
+    the latest message and the rest inherit its classification. When
+    settings.auto_create_tasks is enabled, qualifying emails also get tasks
+    created from their action items. Unless skip_already_processed is
+    false, emails already classified with the current prompt version are
+    skipped. Raw items (subject, sender, content) are classified without a
+    provider lookup, reported under a content hash ID, and only stored when
+    save_to_database is true. Emails of muted conversations are tagged fyi
+    without the AI and counted in muted_count. Rights-managed emails never
+    reach the AI: they are listed in skipped with their reason and counted
+    in skipped_count, not failed_count.
+    
+    With classification_strategy two_stage, each email is triaged with a
+    short prompt first and only the ones the triage cannot settle go to the
+    full classifier; the result's triage block reports how many did and
+    the estimated tokens saved against single-stage classification.
+    
+    A dry run wraps the email and task services in write interceptors, so
+    nothing is stored or changed in the mailbox and the result's
+    change_plan lists what would have been; with simulate_ai the AI calls
+    are replaced by canned responses.
+    
+    The status is 200 when every email was classified, 207 when some were
+    not, and the dominant item error's status when none were. Emails that
+    are not found fail with 404; AI failures fail with a retryable 502.
+    
+    Args:
+        request: Incoming request, used to cancel work on client disconnect
+        response: Outgoing response, whose status reflects item failures
+        batch_request: Batch of emails to process
+        current_user: Authenticated user
+        email_service: Email service instance
+        ai_service: AI service used for classification
+        task_service: Task service used for automatic tasks
+    
+    Returns:
+        Batch processing results
+    """
+    try:
+        strategy = batch_request.classification_strategy or settings.classification_strategy
+        plan = ChangePlan() if batch_request.dry_run else None
+        if plan is not None:
+            email_service = WriteInterceptor(email_service, plan, EMAIL_WRITES)
+            task_service = WriteInterceptor(task_service, plan, TASK_WRITES)
+        if batch_request.simulate_ai:
+            ai_service = SimulatedAIService()
+        
+        # Look up provider emails; raw items go straight to the AI under their content hash
+        processed_emails = []
+        unsaved_ids = set()
+        errors = []
+        
+        already_processed = set()
+        skipped_count = 0
+        if batch_request.skip_already_processed:
+            already_processed = await email_service.get_processed_email_ids(
+                [item.email_id for item in batch_request.emails if item.email_id],
+                current_user.id
+            )
+        
+        for item in batch_request.emails:
+            if item.email_id is None:
+                email_id = raw_email_id(item.subject, item.sender, item.content)
+                processed_emails.append({
+                    "id": email_id, "subject": item.subject, "sender": item.sender, "body": item.content
+                })
+                if not batch_request.save_to_database:
+                    unsaved_ids.add(email_id)
+                continue
+            if item.email_id in already_processed:
+                skipped_count += 1
+                continue
+            try:
+                email_content = await cancel_on_disconnect(
+                    request,
+                    email_service.get_email_by_id(item.email_id, include_raw=True)
+                )
+                if email_content:
+                    processed_emails.append(email_content)
+                else:
+                    errors.append(BatchItemError(
+                        email_id=item.email_id, error="Email not found", **item_status(status.HTTP_404_NOT_FOUND)
+                    ))
+            except Exception as e:
+                status_code, message = batch_item_failure(e, "Failed to process email")
+                errors.append(BatchItemError(email_id=item.email_id, error=message, **item_status(status_code)))
+        
+        thread_mode = batch_request.thread_mode
+        if thread_mode is None:
+            thread_mode = settings.thread_classification
+        muted_conversations = await email_service.get_muted_conversations(
+            current_user.id, (email.get("conversation_id") for email in processed_emails)
+        )
+        
+        outcome = await cancel_on_disconnect(
+            request,
+            classify_batch(
+                processed_emails,
+                ai_service,
+                email_service,
+                current_user.id,
+                thread_mode=thread_mode,
+                context=batch_request.context,
+                digest_messages=settings.thread_digest_messages,
+                task_service=task_service,
+                auto_create_tasks=settings.auto_create_tasks,
+                unsaved_ids=unsaved_ids,
+                muted_conversations=muted_conversations,
+                strategy=strategy,
+                triage_threshold=settings.triage_confidence_threshold
+            )
+        )
+        errors += [
+            BatchItemError(email_id=email_id, error=error, **item_status(status.HTTP_502_BAD_GATEWAY))
+            for email_id, error in outcome.failures.items()
+        ]
+        response.status_code = batch_status_code(
+            len(batch_request.emails), [error.status_code for error in errors]
+        )
         
         return EmailBatchResult(
             processed_count=len(batch_request.emails),
-            successful_count=len(processed_emails),
+            successful_count=len(processed_emails) - len(outcome.failures) - len(outcome.skipped),
             failed_count=len(errors),
-            results=results,
-            errors=errors
+            results=outcome.results,
+            errors=errors,
+            ai_calls=outcome.ai_calls,
+            ai_calls_saved=outcome.ai_calls_saved,
+            skipped_count=skipped_count + len(outcome.skipped),
+            muted_count=outcome.muted,
+            skipped=[
+                BatchItemSkip(email_id=email_id, reason=reason) for email_id, reason in outcome.skipped.items()
+            ],
+            classification_strategy=strategy,
+            triage=TriageStats(
+                triaged=outcome.triaged,
+                detailed=outcome.detailed,
+                estimated_tokens=outcome.estimated_tokens,
+                estimated_single_stage_tokens=outcome.estimated_single_stage_tokens,
+                estimated_tokens_saved=outcome.estimated_single_stage_tokens - outcome.estimated_tokens
+            ) if strategy == "two_stage" else None,
+            change_plan=plan.to_dict() if plan is not None else None
         )
         
-    except HTTPException:
-        raise
     except Exception as e:
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail=f"Failed to batch process emails: {str(e)}"
-        )
\ No newline at end of file
+        raise to_http_exception(e, "Failed to batch process emails")
@@ -0,0 +1,92 @@
+"""Calendar feed endpoints for FastAPI Email Helper API.
+
+GET /api/feeds/tasks.ics is authenticated by the feed token in its query
+string rather than a bearer token, so calendar clients can subscribe to
+it directly (see backend.services.calendar_feed). The token endpoints
+use the normal authentication.
+"""
+
+from datetime import datetime
+from typing import Optional
+from urllib.parse import urlencode
+
+from fastapi import APIRouter, Depends, Header, Query, Request, Response
+
+from backend.api.auth import get_current_user
+from backend.core.errors import NotFoundError, to_http_exception
+from backend.models.calendar_feed import FeedToken, FeedTokenStatus
+from backend.models.user import UserInDB
+from backend.services.calendar_feed import CalendarFeedService, feed_etag, get_calendar_feed_service
+
+router = APIRouter()
+
+ICS_MEDIA_TYPE = "text/calendar; charset=utf-8"
+
+
+@router.get("/feeds/tasks.ics", response_class=Response)
+async def get_task_feed(
+    token: str = Query(..., min_length=1, description="Feed token from POST /api/feeds/token"),
+    if_none_match: Optional[str] = Header(None),
+    feed_service: CalendarFeedService = Depends(get_calendar_feed_service)
+):
+    """iCalendar feed of open tasks with due dates and detected deadlines.
+
+    The response carries an ETag of the feed text; a request whose
+    If-None-Match matches it gets 304 without a body. Unknown or revoked
+    tokens get 404.
+    """
+    try:
+        user_id = await feed_service.user_for_token(token)
+        if user_id is None:
+            raise NotFoundError("Feed not found")
+        body = await feed_service.render_feed(user_id)
+        etag = feed_etag(body)
+        headers = {"ETag": etag, "Cache-Control": "private, no-cache"}
+        if if_none_match and etag in (tag.strip() for tag in if_none_match.split(",")):
+            return Response(status_code=304, headers=headers)
+        return Response(content=body, media_type=ICS_MEDIA_TYPE, headers=headers)
+    except Exception as e:
+        raise to_http_exception(e, "Failed to build task feed")
+
+
+@router.get("/feeds/token", response_model=FeedTokenStatus)
+async def get_feed_token_status(
+    current_user: UserInDB = Depends(get_current_user),
+    feed_service: CalendarFeedService = Depends(get_calendar_feed_service)
+):
+    """Whether a feed token exists (the token itself cannot be read back)."""
+    try:
+        created_at = await feed_service.token_created_at(current_user.id)
+        return FeedTokenStatus(active=created_at is not None, created_at=created_at)
+    except Exception as e:
+        raise to_http_exception(e, "Failed to read feed token")
+
+
+@router.post("/feeds/token", response_model=FeedToken, status_code=201)
+async def rotate_feed_token(
+    request: Request,
+    current_user: UserInDB = Depends(get_current_user),
+    feed_service: CalendarFeedService = Depends(get_calendar_feed_service)
+):
+    """Generate a feed token, invalidating the previous feed URL."""
+    try:
+        token = await feed_service.rotate_token(current_user.id)
+        url = f"{request.url_for('get_task_feed')}?{urlencode({'token': token})}"
+        created_at = await feed_service.token_created_at(current_user.id)
+        return FeedToken(token=token, url=url, created_at=created_at or datetime.now())
+    except Exception as e:
+        raise to_http_exception(e, "Failed to generate feed token")
+
+
+@router.delete("/feeds/token")
+async def revoke_feed_token(
+    current_user: UserInDB = Depends(get_current_user),
+    feed_service: CalendarFeedService = Depends(get_calendar_feed_service)
+):
+    """Revoke the feed token so the feed URL stops working."""
+    try:
+        if not await feed_service.revoke_token(current_user.id):
+            raise NotFoundError("No feed token to revoke")
+        return {"message": "Feed token revoked"}
+    except Exception as e:
+        raise to_http_exception(e, "Failed to revoke feed token")
@@ -5,27 +5,90 @@ job queuing, WebSocket connections, and real-time status updates.
 """
 
 import logging
+import sqlite3
 from typing import List, Dict, Any, Optional
 from datetime import datetime
 
 from fastapi import APIRouter, HTTPException, WebSocket, WebSocketDisconnect, Depends, Query
-from pydantic import BaseModel, Field
+from pydantic import BaseModel, Field, model_validator
 
+from backend.core.config import settings
+from backend.core.folder_scope import check_folder_in_scope, is_excluded_folder
+from backend.models.dry_run import ChangePlanResult
+from backend.models.folder_profile import (
+    PIPELINE_STAGES, FolderProfile, FolderProfileCreate, FolderProfileUpdate,
+    normalize_folder_path, validate_stages
+)
+from backend.services.category_service import category_names
+from backend.services.classification_store import UNCLASSIFIED, ClassificationStore, get_classification_store
+from backend.services.email_service import MUTED_CATEGORY
+from backend.services.folder_profile_service import FolderProfileService, get_folder_profile_service
 from backend.services.job_queue import job_queue, ProcessingPipeline, ProcessingJob
 from backend.services.websocket_manager import websocket_manager
 from backend.workers.email_processor import email_processor_worker
 from backend.api.auth import get_current_user
+from backend.core.errors import ConflictError, InputValidationError, NotFoundError, to_http_exception
 
 logger = logging.getLogger(__name__)
 
 router = APIRouter()
 
+# Most email IDs POST /processing/start accepts in one run
+MAX_PIPELINE_EMAILS = 100
+
 
 # Request/Response Models
+class ProcessingCriteria(BaseModel):
+    """Selects stored emails to process instead of listing their IDs."""
+    folder: Optional[str] = Field(None, description="Folder to process (defaults to the request's folder)")
+    unread_only: bool = Field(False, description="Only unread emails")
+    since_days: Optional[int] = Field(None, ge=1, description="Only emails received in the last this many days")
+    categories: Optional[List[str]] = Field(
+        None, description=f'Only emails in these categories; "{UNCLASSIFIED}" matches emails without one'
+    )
+    limit: int = Field(100, ge=1, le=100, description="Maximum emails to process, newest first")
+
+
 class StartProcessingRequest(BaseModel):
-    """Request model for starting email processing."""
-    email_ids: List[str] = Field(..., description="List of email IDs to process")
+    """Request model for starting email processing.
+    
+    Give either email_ids or criteria, not both.
+    """
+    email_ids: Optional[List[str]] = Field(
+        None, min_length=1, max_length=MAX_PIPELINE_EMAILS, description="List of email IDs to process"
+    )
+    criteria: Optional[ProcessingCriteria] = Field(None, description="Resolve the emails to process from the database")
     priority: str = Field("medium", description="Processing priority (low, medium, high, urgent)")
+    folder: str = Field("Inbox", description="Folder the emails come from; selects its folder profile")
+    stages: Optional[List[str]] = Field(None, description="Pipeline stages (defaults from the folder profile)")
+    deployment: Optional[str] = Field(None, description="AI deployment (defaults from the folder profile)")
+    auto_apply_to_outlook: Optional[bool] = Field(
+        None, description="Apply results back to Outlook (defaults from the folder profile)"
+    )
+    skip_already_processed: bool = Field(
+        True, description="Skip emails already classified with the current prompt version"
+    )
+    max_tokens_budget: Optional[int] = Field(
+        None, ge=0,
+        description="AI tokens the run may use before remaining jobs are skipped "
+                    "(defaults to settings.pipeline_max_tokens_budget; 0 means unlimited)"
+    )
+    dry_run: bool = Field(
+        False, description="Run without changing Outlook or the database; the status reports the change plan"
+    )
+    simulate_ai: bool = Field(False, description="Use canned AI responses instead of AI calls (dry runs only)")
+    
+    @model_validator(mode="after")
+    def _one_input_form(self):
+        if (self.email_ids is None) == (self.criteria is None):
+            raise ValueError("Provide either email_ids or criteria")
+        return self
+    
+    @model_validator(mode="after")
+    def _simulate_only_dry_runs(self):
+        if self.simulate_ai and not self.dry_run:
+            raise ValueError("simulate_ai requires dry_run")
+        return self
 
 
 class ProcessingStatusResponse(BaseModel):
@@ -36,9 +99,35 @@ class ProcessingStatusResponse(BaseModel):
     email_count: int
     jobs_completed: int
     jobs_failed: int
+    jobs_skipped: int = 0  # Jobs not run because the token budget ran out
+    jobs_protected: int = 0  # Jobs skipped because their email is rights-managed (see core.protection)
     created_at: str
     started_at: Optional[str]
     completed_at: Optional[str]
+    folder: Optional[str] = None
+    stages: List[str] = []
+    deployment: Optional[str] = None
+    auto_apply_to_outlook: bool = False
+    profile_id: Optional[int] = None
+    tokens_consumed: int = 0
+    max_tokens_budget: int = 0  # 0 means unlimited
+    dry_run: bool = False
+    change_plan: Optional[ChangePlanResult] = None  # Dry runs only
+    criteria: Optional[ProcessingCriteria] = None  # Criteria runs only
+    resolved_email_ids: List[str] = []  # Emails the criteria resolved to, before skipping
+    matched_count: int = 0  # Emails the criteria matched
+    capped_count: int = 0  # Matching emails left out by the criteria's limit
+
+
+class ProcessingDiffResponse(BaseModel):
+    """Response model for what a pipeline run changed."""
+    pipeline_id: str
+    status: str
+    summary: Dict[str, int]
+    newly_classified: List[Dict[str, Any]] = []
+    category_changed: List[Dict[str, Any]] = []
+    tasks_created: List[Dict[str, Any]] = []
+    became_needs_review: List[Dict[str, Any]] = []
 
 
 class JobStatusResponse(BaseModel):
@@ -55,40 +144,287 @@ class JobStatusResponse(BaseModel):
     completed_at: Optional[str]
 
 
+def get_known_folders() -> Optional[List[str]]:
+    """FastAPI dependency listing top-level Outlook folder names when COM is available.
+    
+    Returns:
+        Folder names, or None if COM is disabled or Outlook is unreachable,
+        in which case folder paths are not validated
+    """
+    if not settings.use_com_backend:
+        return None
+    try:
+        from backend.core.dependencies import get_com_email_provider
+        return [
+            folder.get("name", "") for folder in get_com_email_provider().get_folders()
+            if "/" not in folder.get("path", "")
+        ]
+    except Exception as e:
+        logger.warning(f"Cannot list Outlook folders for validation: {e}")
+        return None
+
+
+def _check_folder_exists(folder_path: str, known_folders: Optional[List[str]]):
+    """Reject a folder path that does not exist in Outlook.
+    
+    Nested paths such as "Inbox/Recruiters" are checked by their
+    top-level folder.
+    """
+    if known_folders is None:
+        return
+    known = {name.casefold() for name in known_folders}
+    top_level = normalize_folder_path(folder_path).split("/")[0]
+    if top_level.casefold() not in known:
+        raise InputValidationError(f"Folder '{folder_path}' does not exist in Outlook")
+
+
 @router.post("/processing/start", response_model=Dict[str, Any])
 async def start_processing(
     request: StartProcessingRequest,
-    current_user: dict = Depends(get_current_user)
+    current_user: dict = Depends(get_current_user),
+    profile_service: FolderProfileService = Depends(get_folder_profile_service),
+    classification_store: ClassificationStore = Depends(get_classification_store)
 ):
-    """Start email processing pipeline for multiple emails."""
+    """Start email processing pipeline for multiple emails.
+    
+    Stages, deployment, and auto-apply that the request omits are taken
+    from the folder profile for request.folder, if there is one. Unless
+    skip_already_processed is false, emails already classified with the
+    current prompt version are left out of the pipeline. Once the run has
+    used max_tokens_budget AI tokens, its remaining jobs are skipped.
+    
+    A dry run records the writes it would make in a change plan, reported
+    by the status endpoint and the completion event, instead of making them.
+    
+    A request.folder excluded by settings.excluded_folders is refused with
+    403, and stored emails in excluded folders are left out of the pipeline.
+    Stored emails of muted conversations are tagged fyi without the AI
+    instead of processed (muted_count).
+    
+    Criteria are resolved to stored email IDs, newest first, before any
+    of the above; the resolved IDs are kept with the run, and the status
+    endpoint reports how many emails matched and how many the limit left
+    out. criteria.folder, if given, replaces request.folder.
+    """
     try:
         user_id = current_user.get("user_id", "anonymous")
+        criteria = request.criteria
+        folder = (criteria.folder if criteria else None) or request.folder
+        
+        check_folder_in_scope(folder, "Cannot process emails from")
+        
+        matched_count = 0
+        if criteria is not None:
+            valid_categories = [*category_names(classification_store.db), UNCLASSIFIED]
+            unknown = [category for category in criteria.categories or [] if category not in valid_categories]
+            if unknown:
+                raise InputValidationError(
+                    f"Unknown categories: {', '.join(unknown)}. Valid categories: {', '.join(valid_categories)}"
+                )
+            requested_ids, matched_count = await classification_store.find_email_ids(
+                user_id,
+                normalize_folder_path(folder),
+                unread_only=criteria.unread_only,
+                since_days=criteria.since_days,
+                categories=criteria.categories,
+                limit=criteria.limit
+            )
+        else:
+            requested_ids = list(request.email_ids)
         
-        if not request.email_ids:
-            raise HTTPException(status_code=400, detail="No email IDs provided")
-        
-        # Validate email IDs (basic validation)
-        if len(request.email_ids) > 100:
-            raise HTTPException(status_code=400, detail="Too many emails (max 100)")
+        try:
+            stages = validate_stages(request.stages)
+        except ValueError as e:
+            raise InputValidationError(str(e))
+        deployment = request.deployment
+        auto_apply = request.auto_apply_to_outlook
+        
+        profile = await profile_service.get_profile_for_folder(folder, user_id)
+        profile_id = None
+        if profile and (stages is None or deployment is None or auto_apply is None):
+            profile_id = profile.id
+            stages = stages or profile.default_stages
+            deployment = deployment if deployment is not None else profile.default_deployment
+            auto_apply = auto_apply if auto_apply is not None else profile.auto_apply_to_outlook
+        
+        max_tokens_budget = request.max_tokens_budget
+        if max_tokens_budget is None:
+            max_tokens_budget = settings.pipeline_max_tokens_budget
+        
+        stored_folders = await classification_store.get_stored_folders(requested_ids, user_id)
+        email_ids = [
+            email_id for email_id in requested_ids if not is_excluded_folder(stored_folders.get(email_id))
+        ]
+        excluded_count = len(requested_ids) - len(email_ids)
+        muted = await classification_store.tag_muted(email_ids, user_id, MUTED_CATEGORY, dry_run=request.dry_run)
+        email_ids = [email_id for email_id in email_ids if email_id not in muted]
+        muted_count = len(requested_ids) - excluded_count - len(email_ids)
+        if request.skip_already_processed:
+            processed = await classification_store.get_processed_ids(email_ids, user_id)
+            email_ids = [email_id for email_id in email_ids if email_id not in processed]
+        skipped_count = len(requested_ids) - excluded_count - muted_count - len(email_ids)
+        # Criteria runs report what matched and what the limit left out
+        criteria_counts = {
+            "matched_count": matched_count,
+            "capped_count": matched_count - len(requested_ids)
+        } if criteria is not None else {}
+        
+        if not email_ids:
+            if not requested_ids:
+                message = "No emails match the criteria"
+            elif not excluded_count + muted_count:
+                message = f"All {skipped_count} emails were already processed"
+            else:
+                message = (
+                    f"No emails to process ({excluded_count} in excluded folders, "
+                    f"{muted_count} in muted conversations, {skipped_count} already processed)"
+                )
+            return {
+                "pipeline_id": None,
+                "status": "skipped",
+                "email_count": 0,
+                "skipped_count": skipped_count,
+                "excluded_count": excluded_count,
+                "muted_count": muted_count,
+                **criteria_counts,
+                "stages": stages or PIPELINE_STAGES,
+                "profile_id": profile_id,
+                "message": message
+            }
         
         # Create processing pipeline
-        pipeline_id = await job_queue.create_pipeline(request.email_ids, user_id)
+        pipeline_id = await job_queue.create_pipeline(
+            email_ids,
+            user_id,
+            stages=stages,
+            folder=normalize_folder_path(folder),
+            deployment=deployment,
+            auto_apply_to_outlook=bool(auto_apply),
+            profile_id=profile_id,
+            max_tokens_budget=max_tokens_budget,
+            dry_run=request.dry_run,
+            simulate_ai=request.simulate_ai,
+            criteria=criteria.model_dump() if criteria is not None else None,
+            resolved_email_ids=requested_ids if criteria is not None else None,
+            matched_count=matched_count
+        )
+        pipeline = await job_queue.get_pipeline(pipeline_id)
         
         # Start worker if not running
         await email_processor_worker.start()
         
-        logger.info(f"Started processing pipeline {pipeline_id} for user {user_id} with {len(request.email_ids)} emails")
+        logger.info(
+            f"Started processing pipeline {pipeline_id} for user {user_id} with {len(email_ids)} emails "
+            f"({skipped_count} already processed, {excluded_count} in excluded folders, "
+            f"{muted_count} in muted conversations)"
+        )
         
         return {
             "pipeline_id": pipeline_id,
             "status": "started",
-            "email_count": len(request.email_ids),
-            "message": f"Processing started for {len(request.email_ids)} emails"
+            "email_count": len(email_ids),
+            "skipped_count": skipped_count,
+            "excluded_count": excluded_count,
+            "muted_count": muted_count,
+            **criteria_counts,
+            "stages": pipeline.stages,
+            "profile_id": pipeline.profile_id,
+            "max_tokens_budget": pipeline.max_tokens_budget,
+            "dry_run": pipeline.dry_run,
+            "message": f"{'Dry run' if pipeline.dry_run else 'Processing'} started for {len(email_ids)} emails"
         }
         
     except Exception as e:
-        logger.error(f"Failed to start processing: {e}")
-        raise HTTPException(status_code=500, detail=f"Failed to start processing: {str(e)}")
+        raise to_http_exception(e, "Failed to start processing")
+
+
+@router.get("/processing/folder-profiles", response_model=List[FolderProfile])
+async def list_folder_profiles(
+    current_user: dict = Depends(get_current_user),
+    profile_service: FolderProfileService = Depends(get_folder_profile_service)
+):
+    """List folder profiles."""
+    try:
+        user_id = current_user.get("user_id", "anonymous")
+        return await profile_service.list_profiles(user_id)
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list folder profiles")
+
+
+@router.post("/processing/folder-profiles", response_model=FolderProfile, status_code=201)
+async def create_folder_profile(
+    profile: FolderProfileCreate,
+    current_user: dict = Depends(get_current_user),
+    profile_service: FolderProfileService = Depends(get_folder_profile_service),
+    known_folders: Optional[List[str]] = Depends(get_known_folders)
+):
+    """Create processing defaults for a folder."""
+    try:
+        user_id = current_user.get("user_id", "anonymous")
+        _check_folder_exists(profile.folder_path, known_folders)
+        try:
+            return await profile_service.create_profile(profile, user_id)
+        except sqlite3.IntegrityError:
+            raise ConflictError(f"A profile for folder '{profile.folder_path}' already exists")
+    except Exception as e:
+        raise to_http_exception(e, "Failed to create folder profile")
+
+
+@router.get("/processing/folder-profiles/{profile_id}", response_model=FolderProfile)
+async def get_folder_profile(
+    profile_id: int,
+    current_user: dict = Depends(get_current_user),
+    profile_service: FolderProfileService = Depends(get_folder_profile_service)
+):
+    """Get a folder profile."""
+    try:
+        user_id = current_user.get("user_id", "anonymous")
+        profile = await profile_service.get_profile(profile_id, user_id)
+        if not profile:
+            raise NotFoundError("Folder profile not found")
+        return profile
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve folder profile")
+
+
+@router.put("/processing/folder-profiles/{profile_id}", response_model=FolderProfile)
+async def update_folder_profile(
+    profile_id: int,
+    updates: FolderProfileUpdate,
+    current_user: dict = Depends(get_current_user),
+    profile_service: FolderProfileService = Depends(get_folder_profile_service),
+    known_folders: Optional[List[str]] = Depends(get_known_folders)
+):
+    """Update a folder profile."""
+    try:
+        user_id = current_user.get("user_id", "anonymous")
+        if updates.folder_path is not None:
+            _check_folder_exists(updates.folder_path, known_folders)
+        try:
+            profile = await profile_service.update_profile(profile_id, updates, user_id)
+        except sqlite3.IntegrityError:
+            raise ConflictError(f"A profile for folder '{updates.folder_path}' already exists")
+        if not profile:
+            raise NotFoundError("Folder profile not found")
+        return profile
+    except Exception as e:
+        raise to_http_exception(e, "Failed to update folder profile")
+
+
+@router.delete("/processing/folder-profiles/{profile_id}")
+async def delete_folder_profile(
+    profile_id: int,
+    current_user: dict = Depends(get_current_user),
+    profile_service: FolderProfileService = Depends(get_folder_profile_service)
+):
+    """Delete a folder profile."""
+    try:
+        user_id = current_user.get("user_id", "anonymous")
+        if not await profile_service.delete_profile(profile_id, user_id):
+            raise NotFoundError("Folder profile not found")
+        return {"message": "Folder profile deleted successfully"}
+    except Exception as e:
+        raise to_http_exception(e, "Failed to delete folder profile")
 
 
 @router.get("/processing/{pipeline_id}/status", response_model=ProcessingStatusResponse)
@@ -101,7 +437,7 @@ async def get_processing_status(
         pipeline = await job_queue.get_pipeline(pipeline_id)
         
         if not pipeline:
-            raise HTTPException(status_code=404, detail="Pipeline not found")
+            raise NotFoundError("Pipeline not found")
         
         # Check user access (basic check)
         user_id = current_user.get("user_id", "anonymous")
@@ -111,6 +447,8 @@ async def get_processing_status(
         # Count job statuses
         jobs_completed = sum(1 for job in pipeline.jobs if job.status.value == "completed")
         jobs_failed = sum(1 for job in pipeline.jobs if job.status.value == "failed")
+        jobs_skipped = sum(1 for job in pipeline.jobs if job.status.value == "skipped_budget")
+        jobs_protected = sum(1 for job in pipeline.jobs if job.status.value == "skipped")
         
         return ProcessingStatusResponse(
             pipeline_id=pipeline.id,
@@ -119,16 +457,28 @@ async def get_processing_status(
             email_count=len(pipeline.email_ids),
             jobs_completed=jobs_completed,
             jobs_failed=jobs_failed,
+            jobs_skipped=jobs_skipped,
+            jobs_protected=jobs_protected,
             created_at=pipeline.created_at,
             started_at=pipeline.started_at,
-            completed_at=pipeline.completed_at
+            completed_at=pipeline.completed_at,
+            folder=pipeline.folder,
+            stages=pipeline.stages,
+            deployment=pipeline.deployment,
+            auto_apply_to_outlook=pipeline.auto_apply_to_outlook,
+            profile_id=pipeline.profile_id,
+            tokens_consumed=pipeline.tokens_consumed,
+            max_tokens_budget=pipeline.max_tokens_budget,
+            dry_run=pipeline.dry_run,
+            change_plan=pipeline.plan.to_dict() if pipeline.plan is not None else None,
+            criteria=pipeline.criteria,
+            resolved_email_ids=pipeline.resolved_email_ids,
+            matched_count=pipeline.matched_count,
+            capped_count=pipeline.matched_count - len(pipeline.resolved_email_ids) if pipeline.criteria else 0
         )
         
-    except HTTPException:
-        raise
     except Exception as e:
-        logger.error(f"Failed to get processing status: {e}")
-        raise HTTPException(status_code=500, detail=f"Failed to get processing status: {str(e)}")
+        raise to_http_exception(e, "Failed to get processing status")
 
 
 @router.get("/processing/{pipeline_id}/jobs", response_model=List[JobStatusResponse])
@@ -141,7 +491,7 @@ async def get_pipeline_jobs(
         pipeline = await job_queue.get_pipeline(pipeline_id)
         
         if not pipeline:
-            raise HTTPException(status_code=404, detail="Pipeline not found")
+            raise NotFoundError("Pipeline not found")
         
         # Check user access
         user_id = current_user.get("user_id", "anonymous")
@@ -165,11 +515,44 @@ async def get_pipeline_jobs(
         
         return jobs
         
-    except HTTPException:
-        raise
     except Exception as e:
-        logger.error(f"Failed to get pipeline jobs: {e}")
-        raise HTTPException(status_code=500, detail=f"Failed to get pipeline jobs: {str(e)}")
+        raise to_http_exception(e, "Failed to get pipeline jobs")
+
+
+@router.get("/processing/{pipeline_id}/diff", response_model=ProcessingDiffResponse)
+async def get_processing_diff(
+    pipeline_id: str,
+    current_user: dict = Depends(get_current_user)
+):
+    """Get what a pipeline run changed.
+    
+    Lists newly classified emails, category changes, created tasks, and
+    emails that now need review. The diff fills in as jobs finish.
+    """
+    try:
+        pipeline = await job_queue.get_pipeline(pipeline_id)
+        
+        if not pipeline:
+            raise NotFoundError("Pipeline not found")
+        
+        # Check user access
+        user_id = current_user.get("user_id", "anonymous")
+        if pipeline.user_id != user_id:
+            raise HTTPException(status_code=403, detail="Access denied")
+        
+        diff = pipeline.diff
+        return ProcessingDiffResponse(
+            pipeline_id=pipeline.id,
+            status=pipeline.status,
+            summary=diff.summary(),
+            newly_classified=diff.newly_classified,
+            category_changed=diff.category_changed,
+            tasks_created=diff.tasks_created,
+            became_needs_review=diff.became_needs_review
+        )
+        
+    except Exception as e:
+        raise to_http_exception(e, "Failed to get processing diff")
 
 
 @router.post("/processing/{pipeline_id}/cancel")
@@ -182,7 +565,7 @@ async def cancel_processing(
         pipeline = await job_queue.get_pipeline(pipeline_id)
         
         if not pipeline:
-            raise HTTPException(status_code=404, detail="Pipeline not found")
+            raise NotFoundError("Pipeline not found")
         
         # Check user access
         user_id = current_user.get("user_id", "anonymous")
@@ -200,13 +583,10 @@ async def cancel_processing(
             
             return {"message": "Pipeline cancelled successfully"}
         else:
-            raise HTTPException(status_code=400, detail="Failed to cancel pipeline")
+            raise ConflictError("Pipeline is not cancellable in its current state")
         
-    except HTTPException:
-        raise
     except Exception as e:
-        logger.error(f"Failed to cancel processing: {e}")
-        raise HTTPException(status_code=500, detail=f"Failed to cancel processing: {str(e)}")
+        raise to_http_exception(e, "Failed to cancel processing")
 
 
 @router.get("/processing/stats")
@@ -237,8 +617,7 @@ async def get_processing_stats(
         }
         
     except Exception as e:
-        logger.error(f"Failed to get processing stats: {e}")
-        raise HTTPException(status_code=500, detail=f"Failed to get processing stats: {str(e)}")
+        raise to_http_exception(e, "Failed to get processing stats")
 
 
 @router.websocket("/processing/ws/{pipeline_id}")
@@ -249,6 +628,9 @@ async def websocket_endpoint(
 ):
     """WebSocket endpoint for real-time processing updates."""
     try:
+        if not await websocket_manager.authorize(websocket):
+            return
+        
         # Basic authentication check (in production, use proper JWT validation)
         if not user_id:
             await websocket.close(code=4001, reason="Authentication required")
@@ -282,8 +664,22 @@ async def general_websocket_endpoint(
     websocket: WebSocket,
     user_id: str = Query(..., description="User ID for authentication")
 ):
-    """General WebSocket endpoint for processing updates."""
+    """General WebSocket endpoint for processing updates and notifications.
+    
+    Sends a hello with the server time and version on every connect; send
+    {"type": "subscribe_notifications", "event_types": [...]} to limit which
+    notifications this connection receives.
+    
+    Both WebSocket endpoints check the handshake's origin and API key before
+    upgrading (see handshake_rejection) and answer {"type": "ping"} messages
+    from the server's idle check with {"type": "pong"}. In read-only mode,
+    cancel_pipeline and retry_failed_jobs messages get an error with the
+    read_only code instead.
+    """
     try:
+        if not await websocket_manager.authorize(websocket):
+            return
+        
         # Basic authentication check
         if not user_id:
             await websocket.close(code=4001, reason="Authentication required")
@@ -0,0 +1,67 @@
+"""Outlook rule import endpoints for FastAPI Email Helper API.
+
+Existing Outlook rules that file mail into a category's folder become lines
+of that category's classification rules (see backend.services.rule_import).
+"""
+
+from fastapi import APIRouter, Depends, status
+
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_email_service
+from backend.core.errors import APIError, to_http_exception
+from backend.models.rule_import import RuleImportCandidatesResponse, RuleImportRequest, RuleImportResponse
+from backend.models.user import UserInDB
+from backend.services.category_service import CategoryService, category_folders, get_category_service
+from backend.services.email_service import EmailService
+from backend.services.rule_import import import_rules, plan_rule_import
+
+router = APIRouter()
+
+
+async def _plan(email_service: EmailService, category_service: CategoryService) -> RuleImportCandidatesResponse:
+    rules = await email_service.get_rules()
+    return plan_rule_import(rules, category_folders(category_service.db))
+
+
+@router.get("/rules/import-candidates", response_model=RuleImportCandidatesResponse)
+async def get_import_candidates(
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service),
+    category_service: CategoryService = Depends(get_category_service)
+):
+    """Map the mail client's rules to category classification rules.
+
+    A rule that moves mail into a category's folder on sender or subject
+    conditions is a candidate for that category; every other rule is
+    listed with the reasons it cannot be imported.
+    """
+    try:
+        return await _plan(email_service, category_service)
+
+    except NotImplementedError as e:
+        raise APIError(status.HTTP_501_NOT_IMPLEMENTED, str(e))
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list Outlook rule import candidates")
+
+
+@router.post("/rules/import", response_model=RuleImportResponse)
+async def import_outlook_rules(
+    request: RuleImportRequest,
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service),
+    category_service: CategoryService = Depends(get_category_service)
+):
+    """Add the selected candidates to their categories' classification rules.
+
+    Rules are re-read from the mail client, so a rule changed since the
+    candidates were listed is imported as it is now. Rules already
+    imported, unknown, or unmappable are reported as skipped.
+    """
+    try:
+        plan = await _plan(email_service, category_service)
+        return await import_rules(request.rules, plan, category_service)
+
+    except NotImplementedError as e:
+        raise APIError(status.HTTP_501_NOT_IMPLEMENTED, str(e))
+    except Exception as e:
+        raise to_http_exception(e, "Failed to import Outlook rules")
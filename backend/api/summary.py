@@ -0,0 +1,109 @@
+"""Tray summary endpoint for FastAPI Email Helper API.
+
+GET /api/summary answers a desktop companion's poll with the handful of
+numbers it shows: unread emails needing personal action, emails needing
+review, open blocking items (actions other people are waiting on), late
+and due tasks, the last pipeline run, and AI health. It reads only count
+queries and in-memory state, and caches each user's summary for
+settings.summary_cache_seconds so frequent polling stays cheap.
+"""
+
+from datetime import datetime
+
+from fastapi import APIRouter, Depends
+
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import AI_AUTH_MESSAGE, to_http_exception
+from backend.models.summary import (
+    AIHealthSummary, EmailSummary, PipelineSummary, SystemSummary, TaskSummary
+)
+from backend.models.user import UserInDB
+from backend.services.ai_health import ai_health
+from backend.services.email_service import EmailService
+from backend.services.job_queue import job_queue
+from backend.services.sender_reputation import TTLCache
+from backend.services.task_service import TaskService, get_task_service
+
+router = APIRouter()
+
+summary_cache: TTLCache[SystemSummary] = TTLCache(settings.summary_cache_seconds)
+
+
+def check_ai_health(ai_service) -> AIHealthSummary:
+    """Report whether the AI service can initialize, without calling the model.
+
+    A credential Azure rejected on the last AI call is reported as auth_error.
+    """
+    try:
+        ai_service._ensure_initialized()
+    except Exception as e:
+        return AIHealthSummary(status="unhealthy", error=str(e), as_of=datetime.now())
+    if ai_health.status == "auth_error":
+        return AIHealthSummary(status="auth_error", error=AI_AUTH_MESSAGE, as_of=datetime.now())
+    return AIHealthSummary(status="healthy", as_of=datetime.now())
+
+
+@router.get("/summary", response_model=SystemSummary)
+async def get_summary(
+    current_user: UserInDB = Depends(get_current_user),
+    email_service: EmailService = Depends(get_email_service),
+    task_service: TaskService = Depends(get_task_service),
+    ai_service=Depends(get_ai_service)
+):
+    """Get counts and status for a tray icon in one cheap call.
+
+    Each component carries an as_of time; a cached summary keeps the times
+    it was computed at.
+
+    Args:
+        current_user: Authenticated user
+        email_service: Email service instance
+        task_service: Task service instance
+        ai_service: AI service instance
+
+    Returns:
+        Email, task, pipeline, and AI health summaries
+    """
+    cached = summary_cache.get(current_user.id)
+    if cached is not None:
+        return cached
+
+    try:
+        counters = await email_service.get_counters(current_user.id)
+        action = counters.by_category.get("required_personal_action")
+        emails = EmailSummary(
+            unread_required_personal_action=action.unread if action else 0,
+            needs_review=counters.needs_review,
+            blocking_others=await email_service.count_blocking_items(current_user.id),
+            as_of=datetime.now()
+        )
+
+        due = await task_service.get_due_counts(current_user.id)
+        tasks = TaskSummary(**due, as_of=datetime.now())
+
+        pipeline = await job_queue.get_latest_pipeline(str(current_user.id))
+        if pipeline is None:
+            pipeline_summary = PipelineSummary(as_of=datetime.now())
+        else:
+            pipeline_summary = PipelineSummary(
+                pipeline_id=pipeline.id,
+                status=pipeline.status,
+                last_run_at=pipeline.completed_at or pipeline.started_at or pipeline.created_at,
+                as_of=datetime.now()
+            )
+
+        summary = SystemSummary(
+            emails=emails,
+            tasks=tasks,
+            pipeline=pipeline_summary,
+            ai=check_ai_health(ai_service),
+            generated_at=datetime.now()
+        )
+
+    except Exception as e:
+        raise to_http_exception(e, "Failed to build summary")
+
+    summary_cache.set(current_user.id, summary)
+    return summary
@@ -1,15 +1,19 @@
 """Task management API endpoints for Email Helper."""
 
 from typing import Optional, List
-from fastapi import APIRouter, Depends, HTTPException, Query
+from fastapi import APIRouter, Depends, Query, Response, status
 
 from backend.models.task import (
     Task, TaskCreate, TaskUpdate, TaskListResponse, 
-    BulkTaskUpdate, BulkTaskDelete
+    BulkTaskUpdate, BulkTaskDelete, BulkTaskUpdateResponse, BulkTaskDeleteResponse,
+    TaskBatchItemError
 )
 from backend.models.user import User
 from backend.services.task_service import TaskService, get_task_service
 from backend.api.auth import get_current_user
+from backend.core.errors import (
+    InputValidationError, NotFoundError, batch_status_code, item_status, to_http_exception
+)
 
 router = APIRouter()
 
@@ -24,31 +28,48 @@ async def create_task(
     try:
         result = await task_service.create_task(task, current_user.id)
         return result
-    except ValueError as e:
-        raise HTTPException(status_code=400, detail=str(e))
     except Exception as e:
-        raise HTTPException(status_code=500, detail="Failed to create task")
+        raise to_http_exception(e, "Failed to create task")
+
+
+# Related data GET /tasks can embed in each task
+TASK_INCLUDES = ("email",)
 
 
-@router.get("/tasks", response_model=TaskListResponse)
+# Unset fields are left out so tasks carry "email" only when it was requested
+@router.get("/tasks", response_model=TaskListResponse, response_model_exclude_unset=True)
 async def get_tasks(
     page: int = Query(1, ge=1, description="Page number"),
     limit: int = Query(20, ge=1, le=100, description="Items per page"),
     status: Optional[str] = Query(None, description="Filter by task status"),
     priority: Optional[str] = Query(None, description="Filter by task priority"),
     search: Optional[str] = Query(None, description="Search in title and description"),
+    source: Optional[str] = Query(None, description="Filter by task source (e.g. auto)"),
+    include: Optional[str] = Query(None, description="Comma-separated related data to embed: email"),
     current_user: User = Depends(get_current_user),
     task_service: TaskService = Depends(get_task_service)
 ):
-    """Get paginated list of tasks with filtering."""
+    """Get paginated list of tasks with filtering.
+    
+    include=email embeds the id, subject, sender, received time, and
+    one-line summary of the stored email each task came from, or null.
+    """
     try:
+        includes = {part.strip() for part in (include or "").split(",") if part.strip()}
+        unknown = includes - set(TASK_INCLUDES)
+        if unknown:
+            raise InputValidationError(
+                f"Unknown include {', '.join(sorted(unknown))}; expected one of: {', '.join(TASK_INCLUDES)}"
+            )
         result = await task_service.get_tasks_paginated(
             user_id=current_user.id,
             page=page,
             limit=limit,
             status=status,
             priority=priority,
-            search=search
+            search=search,
+            source=source,
+            include_email="email" in includes
         )
         
         return TaskListResponse(
@@ -59,7 +80,7 @@ async def get_tasks(
             has_next=result.has_next
         )
     except Exception as e:
-        raise HTTPException(status_code=500, detail="Failed to retrieve tasks")
+        raise to_http_exception(e, "Failed to retrieve tasks")
 
 
 @router.get("/tasks/{task_id}", response_model=Task)
@@ -69,10 +90,13 @@ async def get_task(
     task_service: TaskService = Depends(get_task_service)
 ):
     """Get a specific task by ID."""
-    task = await task_service.get_task(task_id, current_user.id)
-    if not task:
-        raise HTTPException(status_code=404, detail="Task not found")
-    return task
+    try:
+        task = await task_service.get_task(task_id, current_user.id)
+        if not task:
+            raise NotFoundError("Task not found")
+        return task
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve task")
 
 
 @router.put("/tasks/{task_id}", response_model=Task)
@@ -86,12 +110,10 @@ async def update_task(
     try:
         result = await task_service.update_task(task_id, updates, current_user.id)
         if not result:
-            raise HTTPException(status_code=404, detail="Task not found")
+            raise NotFoundError("Task not found")
         return result
-    except ValueError as e:
-        raise HTTPException(status_code=400, detail=str(e))
     except Exception as e:
-        raise HTTPException(status_code=500, detail="Failed to update task")
+        raise to_http_exception(e, "Failed to update task")
 
 
 @router.delete("/tasks/{task_id}")
@@ -104,48 +126,77 @@ async def delete_task(
     try:
         success = await task_service.delete_task(task_id, current_user.id)
         if not success:
-            raise HTTPException(status_code=404, detail="Task not found")
+            raise NotFoundError("Task not found")
         return {"message": "Task deleted successfully"}
     except Exception as e:
-        raise HTTPException(status_code=500, detail="Failed to delete task")
+        raise to_http_exception(e, "Failed to delete task")
 
 
-@router.post("/tasks/bulk-update", response_model=List[Task])
+def _not_found_errors(task_ids: List[int], found: List[int]) -> List[TaskBatchItemError]:
+    """Per-item 404 errors for requested tasks that were not found."""
+    found_ids = set(found)
+    return [
+        TaskBatchItemError(task_id=task_id, error="Task not found", **item_status(status.HTTP_404_NOT_FOUND))
+        for task_id in task_ids if task_id not in found_ids
+    ]
+
+
+@router.post("/tasks/bulk-update", response_model=BulkTaskUpdateResponse)
 async def bulk_update_tasks(
     bulk_update: BulkTaskUpdate,
+    response: Response,
     current_user: User = Depends(get_current_user),
     task_service: TaskService = Depends(get_task_service)
 ):
-    """Update multiple tasks at once."""
+    """Update multiple tasks at once.
+    
+    Tasks that do not exist fail with 404. The status is 200 when every
+    task was updated, 207 when some were, and 404 when none were.
+    """
     try:
-        results = await task_service.bulk_update_tasks(
-            bulk_update.task_ids,
+        task_ids = list(dict.fromkeys(bulk_update.task_ids))
+        tasks = await task_service.bulk_update_tasks(
+            task_ids,
             bulk_update.updates,
             current_user.id
         )
-        return results
+        errors = _not_found_errors(task_ids, [task.id for task in tasks])
+        response.status_code = batch_status_code(len(task_ids), [error.status_code for error in errors])
+        return BulkTaskUpdateResponse(
+            tasks=tasks,
+            success_count=len(tasks),
+            failure_count=len(errors),
+            errors=errors
+        )
     except Exception as e:
-        raise HTTPException(status_code=500, detail="Failed to bulk update tasks")
+        raise to_http_exception(e, "Failed to bulk update tasks")
 
 
-@router.post("/tasks/bulk-delete")
+@router.post("/tasks/bulk-delete", response_model=BulkTaskDeleteResponse)
 async def bulk_delete_tasks(
     bulk_delete: BulkTaskDelete,
+    response: Response,
     current_user: User = Depends(get_current_user),
     task_service: TaskService = Depends(get_task_service)
 ):
-    """Delete multiple tasks at once."""
+    """Delete multiple tasks at once.
+    
+    Tasks that do not exist fail with 404. The status is 200 when every
+    task was deleted, 207 when some were, and 404 when none were.
+    """
     try:
-        deleted_count = await task_service.bulk_delete_tasks(
-            bulk_delete.task_ids,
-            current_user.id
+        task_ids = list(dict.fromkeys(bulk_delete.task_ids))
+        deleted = await task_service.delete_tasks(task_ids, current_user.id)
+        errors = _not_found_errors(task_ids, deleted)
+        response.status_code = batch_status_code(len(task_ids), [error.status_code for error in errors])
+        return BulkTaskDeleteResponse(
+            message=f"Successfully deleted {len(deleted)} tasks",
+            deleted_count=len(deleted),
+            failure_count=len(errors),
+            errors=errors
         )
-        return {
-            "message": f"Successfully deleted {deleted_count} tasks",
-            "deleted_count": deleted_count
-        }
     except Exception as e:
-        raise HTTPException(status_code=500, detail="Failed to bulk delete tasks")
+        raise to_http_exception(e, "Failed to bulk delete tasks")
 
 
 @router.post("/tasks/{task_id}/link-email")
@@ -161,7 +212,7 @@ async def link_email_to_task(
         updates = TaskUpdate(email_id=email_id)
         result = await task_service.update_task(task_id, updates, current_user.id)
         if not result:
-            raise HTTPException(status_code=404, detail="Task not found")
+            raise NotFoundError("Task not found")
         return {"message": "Email linked to task successfully", "task": result}
     except Exception as e:
-        raise HTTPException(status_code=500, detail="Failed to link email to task")
\ No newline at end of file
+        raise to_http_exception(e, "Failed to link email to task")
\ No newline at end of file
@@ -0,0 +1,40 @@
+"""Per-user settings endpoints for FastAPI Email Helper API."""
+
+from fastapi import APIRouter, Depends
+
+from backend.api.auth import get_current_user
+from backend.core.errors import to_http_exception
+from backend.models.user import UserInDB, UserSettings, UserSettingsUpdate
+from backend.services.user_settings_service import UserSettingsService, get_user_settings_service
+
+router = APIRouter()
+
+
+@router.get("/user/settings", response_model=UserSettings)
+async def get_user_settings(
+    current_user: UserInDB = Depends(get_current_user),
+    settings_service: UserSettingsService = Depends(get_user_settings_service)
+):
+    """The current user's settings and the time zone in effect for them."""
+    try:
+        return await settings_service.get_settings(current_user.id)
+    except Exception as e:
+        raise to_http_exception(e, "Failed to read user settings")
+
+
+@router.patch("/user/settings", response_model=UserSettings)
+async def update_user_settings(
+    update: UserSettingsUpdate,
+    current_user: UserInDB = Depends(get_current_user),
+    settings_service: UserSettingsService = Depends(get_user_settings_service)
+):
+    """Update the current user's settings.
+
+    timezone is an IANA name (e.g. America/New_York); it decides which
+    tasks count as due today or overdue and when end-of-day due dates
+    fall. null returns to settings.timezone.
+    """
+    try:
+        return await settings_service.update_settings(current_user.id, update)
+    except Exception as e:
+        raise to_http_exception(e, "Failed to update user settings")
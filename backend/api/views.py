@@ -0,0 +1,115 @@
+"""Saved view (smart view) endpoints for FastAPI Email Helper API."""
+
+from typing import List
+from fastapi import APIRouter, Depends, Query, Request
+
+from backend.api.auth import get_current_user
+from backend.api.emails import EmailListResponse, list_emails
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import NotFoundError, to_http_exception
+from backend.models.saved_view import SavedView, SavedViewCreate, SavedViewUpdate
+from backend.models.user import UserInDB
+from backend.services.email_service import EmailService
+from backend.services.saved_view_service import SavedViewService, get_saved_view_service
+
+router = APIRouter()
+
+
+@router.post("/views", response_model=SavedView, status_code=201)
+async def create_view(
+    view: SavedViewCreate,
+    current_user: UserInDB = Depends(get_current_user),
+    view_service: SavedViewService = Depends(get_saved_view_service)
+):
+    """Save a filter combination as a view."""
+    try:
+        return await view_service.create_view(view, current_user.id)
+    except Exception as e:
+        raise to_http_exception(e, "Failed to create view")
+
+
+@router.get("/views", response_model=List[SavedView])
+async def list_views(
+    current_user: UserInDB = Depends(get_current_user),
+    view_service: SavedViewService = Depends(get_saved_view_service)
+):
+    """List saved views."""
+    try:
+        return await view_service.list_views(current_user.id)
+    except Exception as e:
+        raise to_http_exception(e, "Failed to list views")
+
+
+@router.get("/views/{view_id}", response_model=SavedView)
+async def get_view(
+    view_id: int,
+    current_user: UserInDB = Depends(get_current_user),
+    view_service: SavedViewService = Depends(get_saved_view_service)
+):
+    """Get a saved view."""
+    try:
+        view = await view_service.get_view(view_id, current_user.id)
+        if not view:
+            raise NotFoundError("View not found")
+        return view
+    except Exception as e:
+        raise to_http_exception(e, "Failed to retrieve view")
+
+
+@router.put("/views/{view_id}", response_model=SavedView)
+async def update_view(
+    view_id: int,
+    updates: SavedViewUpdate,
+    current_user: UserInDB = Depends(get_current_user),
+    view_service: SavedViewService = Depends(get_saved_view_service)
+):
+    """Update a saved view."""
+    try:
+        view = await view_service.update_view(view_id, updates, current_user.id)
+        if not view:
+            raise NotFoundError("View not found")
+        return view
+    except Exception as e:
+        raise to_http_exception(e, "Failed to update view")
+
+
+@router.delete("/views/{view_id}")
+async def delete_view(
+    view_id: int,
+    current_user: UserInDB = Depends(get_current_user),
+    view_service: SavedViewService = Depends(get_saved_view_service)
+):
+    """Delete a saved view."""
+    try:
+        if not await view_service.delete_view(view_id, current_user.id):
+            raise NotFoundError("View not found")
+        return {"message": "View deleted successfully"}
+    except Exception as e:
+        raise to_http_exception(e, "Failed to delete view")
+
+
+@router.get("/views/{view_id}/emails", response_model=EmailListResponse)
+async def get_view_emails(
+    request: Request,
+    view_id: int,
+    limit: int = Query(50, ge=1, le=100, description="Number of emails to retrieve"),
+    offset: int = Query(0, ge=0, description="Number of emails to skip"),
+    current_user: UserInDB = Depends(get_current_user),
+    view_service: SavedViewService = Depends(get_saved_view_service),
+    email_service: EmailService = Depends(get_email_service),
+    ai_service = Depends(get_ai_service)
+):
+    """Run a saved view.
+    
+    Returns exactly what GET /api/emails returns for the view's filters
+    and sort.
+    """
+    try:
+        view = await view_service.get_view(view_id, current_user.id)
+        if not view:
+            raise NotFoundError("View not found")
+        return await list_emails(
+            request, view.filters, view.sort, limit, offset, current_user, email_service, ai_service
+        )
+    except Exception as e:
+        raise to_http_exception(e, "Failed to run view")
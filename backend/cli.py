@@ -0,0 +1,405 @@
+"""Command line interface for FastAPI Email Helper API.
+
+Runs the API's work without the HTTP server, for scheduled jobs and
+scripts:
+
+    python -m backend.cli classify Inbox --limit 50
+    python -m backend.cli sync Inbox
+    python -m backend.cli tasks list --overdue
+    python -m backend.cli stats --json
+    python -m backend.cli backup
+
+Commands call EmailService, TaskService, and the database store directly
+and read the same settings as the server (config file, .env,
+environment). Each prints a table, or JSON with --json, and acts as the
+user named by --user: settings.cli_username when it is not given, or the
+only active user when there is exactly one.
+
+The CLI never prompts. A user it cannot resolve, or a mailbox or AI
+service that cannot sign in without interaction, ends the command with
+EXIT_AUTH. classify and sync change the database and honor
+settings.read_only like the API does.
+"""
+
+import argparse
+import asyncio
+import json
+import os
+import sys
+from typing import Any, List, Optional, Sequence, TextIO
+
+from fastapi import HTTPException
+
+from backend.api.auth import get_user_by_username
+from backend.core.config import ConfigValidationError, settings
+from backend.core.dependencies import get_ai_service, get_email_provider
+from backend.core.read_only import check_writable
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.database.recovery import check_integrity
+from backend.models.task import TaskStatus
+from backend.models.user import UserInDB
+from backend.services.email_service import EmailService
+from backend.services.task_service import TaskService
+from backend.services.thread_classifier import classify_batch
+
+EXIT_OK = 0
+EXIT_FAILURE = 1  # The command failed, or some emails could not be classified
+EXIT_USAGE = 2  # Invalid arguments (argparse's own exit code)
+EXIT_AUTH = 3  # No user to act as, or the mailbox or AI service is not signed in
+
+
+class CLIAuthError(Exception):
+    """The CLI has no user or service it may use without prompting."""
+
+
+def resolve_user(db: DatabaseManager, username: Optional[str] = None) -> UserInDB:
+    """The active user a command acts as.
+
+    Args:
+        db: Database store holding the users
+        username: --user, falling back to settings.cli_username
+
+    Raises:
+        CLIAuthError: If the user is unknown, or none is named and there is
+            not exactly one active user
+    """
+    username = username or settings.cli_username
+    with db.get_connection() as conn:
+        if username:
+            user = get_user_by_username(conn, username)
+            if user is None:
+                raise CLIAuthError(f"No active user named '{username}'")
+            return user
+        rows = conn.execute("SELECT username FROM users WHERE is_active = TRUE LIMIT 2").fetchall()
+        if len(rows) != 1:
+            raise CLIAuthError(
+                "Pass --user or set cli_username: "
+                + ("there are no active users" if not rows else "there is more than one active user")
+            )
+        return get_user_by_username(conn, rows[0]["username"])
+
+
+class CLIContext:
+    """Services and output streams a command runs with.
+
+    Services are created on first use, so commands that only read the
+    database never reach Outlook or the AI service; tests pass their own.
+    """
+
+    def __init__(
+        self,
+        db: Optional[DatabaseManager] = None,
+        email_service: Optional[EmailService] = None,
+        task_service: Optional[TaskService] = None,
+        ai_service=None,
+        out: Optional[TextIO] = None,
+        err: Optional[TextIO] = None
+    ):
+        self.db = db or get_default_manager()
+        self._email_service = email_service
+        self._task_service = task_service
+        self._ai_service = ai_service
+        self.out = out or sys.stdout
+        self.err = err or sys.stderr
+
+    @property
+    def email_service(self) -> EmailService:
+        """Email service over the configured provider, signed in without interaction.
+
+        Raises:
+            CLIAuthError: If the provider is unavailable or cannot sign in
+        """
+        if self._email_service is None:
+            try:
+                provider = get_email_provider()
+                # Empty credentials are the sign-in that needs no user (Outlook's own session)
+                signed_in = getattr(provider, "authenticated", True) or provider.authenticate({})
+            except HTTPException as e:
+                raise CLIAuthError(f"Mailbox unavailable: {e.detail}") from e
+            if not signed_in:
+                raise CLIAuthError("The mailbox could not be signed in")
+            self._email_service = EmailService(provider, db=self.db)
+        return self._email_service
+
+    @property
+    def stored_emails(self) -> EmailService:
+        """Email service for database reads; its provider is never called."""
+        return self._email_service or EmailService(None, db=self.db)
+
+    @property
+    def task_service(self) -> TaskService:
+        """Task service over the context's store."""
+        if self._task_service is None:
+            self._task_service = TaskService(db=self.db)
+        return self._task_service
+
+    @property
+    def ai_service(self):
+        """The configured AI service.
+
+        Raises:
+            CLIAuthError: If it cannot be initialized
+        """
+        if self._ai_service is None:
+            try:
+                self._ai_service = get_ai_service()
+            except HTTPException as e:
+                raise CLIAuthError(f"AI service unavailable: {e.detail}") from e
+        return self._ai_service
+
+    def print(self, text: str = "") -> None:
+        """Write a line of output."""
+        print(text, file=self.out)
+
+    def error(self, text: str) -> None:
+        """Write a line to the error stream."""
+        print(f"error: {text}", file=self.err)
+
+    def print_json(self, data: Any) -> None:
+        """Write data as indented JSON."""
+        self.print(json.dumps(data, indent=2, default=str))
+
+
+def format_table(headers: Sequence[str], rows: Sequence[Sequence[Any]]) -> str:
+    """Rows as left-aligned columns under a header line (None prints empty)."""
+    cells = [["" if value is None else str(value) for value in row] for row in rows]
+    widths = [max([len(header)] + [len(row[i]) for row in cells]) for i, header in enumerate(headers)]
+    lines = [headers, ["-" * width for width in widths], *cells]
+    return "\n".join("  ".join(cell.ljust(width) for cell, width in zip(line, widths)).rstrip() for line in lines)
+
+
+async def cmd_classify(args: argparse.Namespace, ctx: CLIContext) -> int:
+    """Classify the newest emails of a folder and store the results."""
+    check_writable("classify")
+    user = resolve_user(ctx.db, args.user)
+    email_service = ctx.email_service
+    emails = await email_service.get_emails(args.folder, count=args.limit)
+
+    processed = set()
+    if not args.all:
+        processed = await email_service.get_processed_email_ids([email["id"] for email in emails], user.id)
+        emails = [email for email in emails if email["id"] not in processed]
+    muted = await email_service.get_muted_conversations(
+        user.id, (email.get("conversation_id") for email in emails)
+    )
+    outcome = await classify_batch(
+        emails,
+        ctx.ai_service,
+        email_service,
+        user.id,
+        thread_mode=settings.thread_classification,
+        digest_messages=settings.thread_digest_messages,
+        task_service=ctx.task_service,
+        auto_create_tasks=settings.auto_create_tasks,
+        muted_conversations=muted,
+        strategy=settings.classification_strategy,
+        triage_threshold=settings.triage_confidence_threshold
+    )
+
+    subjects = {email["id"]: email.get("subject") for email in emails}
+    classified = [result for result in outcome.results if result.email_id not in outcome.failures]
+    if args.json:
+        ctx.print_json({
+            "folder": args.folder,
+            "fetched": len(emails) + len(processed),
+            "already_processed": len(processed),
+            "classified": [result.model_dump(include={"email_id", "category", "confidence"}) for result in classified],
+            "failed": outcome.failures,
+            "skipped": outcome.skipped,
+        })
+    else:
+        if classified:
+            ctx.print(format_table(
+                ["EMAIL", "CATEGORY", "CONFIDENCE", "SUBJECT"],
+                [(result.email_id, result.category, f"{result.confidence:.2f}", subjects.get(result.email_id))
+                 for result in classified]
+            ))
+            ctx.print()
+        for email_id, error in outcome.failures.items():
+            ctx.error(f"{email_id}: {error}")
+        ctx.print(
+            f"Classified {len(classified)} of {len(emails)} emails in {args.folder} "
+            f"({len(processed)} already processed, {len(outcome.skipped)} skipped, "
+            f"{len(outcome.failures)} failed)"
+        )
+    return EXIT_FAILURE if outcome.failures else EXIT_OK
+
+
+async def cmd_sync(args: argparse.Namespace, ctx: CLIContext) -> int:
+    """Store a folder's new emails and copy Outlook's changes to stored ones."""
+    check_writable("sync")
+    user = resolve_user(ctx.db, args.user)
+    email_service = ctx.email_service
+    emails = await email_service.get_emails(args.folder, count=args.limit)
+    new = 0
+    for email in emails:
+        new += await email_service.store_email({"folder": args.folder, **email}, user.id)
+    report = await email_service.reconcile_folder(user.id, args.folder, limit=args.limit, apply=True)
+
+    result = {
+        "folder": args.folder,
+        "fetched": len(emails),
+        "new": new,
+        "checked": report["checked"],
+        "removed": report["removed"],
+        "updated": report["updated"],
+    }
+    if args.json:
+        ctx.print_json(result)
+    else:
+        ctx.print(format_table(["FOLDER", "FETCHED", "NEW", "CHECKED", "REMOVED", "UPDATED"], [result.values()]))
+    return EXIT_OK
+
+
+async def cmd_tasks_list(args: argparse.Namespace, ctx: CLIContext) -> int:
+    """List the user's tasks, newest first."""
+    user = resolve_user(ctx.db, args.user)
+    page = await ctx.task_service.get_tasks_paginated(
+        user.id, limit=args.limit, status=args.status, overdue=args.overdue
+    )
+    if args.json:
+        ctx.print_json({
+            "tasks": [task.model_dump(mode="json") for task in page.tasks],
+            "total": page.total_count,
+        })
+        return EXIT_OK
+
+    if page.tasks:
+        ctx.print(format_table(
+            ["ID", "STATUS", "PRIORITY", "DUE", "TITLE"],
+            [(task.id, task.status.value, task.priority.value,
+              task.due_date.strftime("%Y-%m-%d %H:%M") if task.due_date else None, task.title)
+             for task in page.tasks]
+        ))
+        ctx.print()
+    ctx.print(f"{len(page.tasks)} of {page.total_count} {'overdue ' if args.overdue else ''}tasks")
+    return EXIT_OK
+
+
+async def cmd_stats(args: argparse.Namespace, ctx: CLIContext) -> int:
+    """Show stored email counts and task due counts."""
+    user = resolve_user(ctx.db, args.user)
+    stored = ctx.stored_emails
+    counters = await stored.get_counters(user.id)
+    due = await ctx.task_service.get_due_counts(user.id)
+    blocking = await stored.count_blocking_items(user.id)
+
+    if args.json:
+        ctx.print_json({"emails": counters.model_dump(), "tasks": due, "blocking_others": blocking})
+        return EXIT_OK
+
+    for heading, group in (("CATEGORY", counters.by_category), ("FOLDER", counters.by_folder)):
+        ctx.print(format_table(
+            [heading, "TOTAL", "UNREAD"],
+            [(name, counter.total, counter.unread)
+             for name, counter in sorted(group.items(), key=lambda item: (-item[1].total, item[0]))]
+        ))
+        ctx.print()
+    ctx.print(format_table(["", "COUNT"], [
+        ("conversations", counters.conversation_count),
+        ("needs review", counters.needs_review),
+        ("flagged", counters.flagged),
+        ("awaiting reply", counters.awaiting_reply),
+        ("waiting on you", blocking),
+        ("tasks overdue", due["overdue"]),
+        ("tasks due today", due["due_today"]),
+    ]))
+    return EXIT_OK
+
+
+async def cmd_backup(args: argparse.Namespace, ctx: CLIContext) -> int:
+    """Copy the database and check the copy's integrity."""
+    loop = asyncio.get_event_loop()
+    path = await loop.run_in_executor(None, ctx.db.backup, args.output)
+    problem = await loop.run_in_executor(None, check_integrity, path)
+    result = {"path": path, "size_bytes": os.path.getsize(path), "intact": problem is None}
+    if args.json:
+        ctx.print_json({**result, "problem": problem})
+    else:
+        ctx.print(f"Backed up {ctx.db.db_path} to {path} ({result['size_bytes']} bytes)")
+    if problem is not None:
+        ctx.error(f"The backup failed its integrity check: {problem}")
+        return EXIT_FAILURE
+    return EXIT_OK
+
+
+def build_parser() -> argparse.ArgumentParser:
+    """Parser for every subcommand, each with its own flags plus --user and --json."""
+    common = argparse.ArgumentParser(add_help=False)
+    common.add_argument("--user", help="Username to act as (defaults to cli_username)")
+    common.add_argument("--json", action="store_true", help="Print JSON instead of a table")
+
+    parser = argparse.ArgumentParser(prog="python -m backend.cli", description="Email Helper command line")
+    commands = parser.add_subparsers(dest="command", metavar="COMMAND", required=True)
+
+    classify = commands.add_parser("classify", parents=[common], help="Classify a folder's newest emails")
+    classify.add_argument("folder")
+    classify.add_argument("--limit", type=_positive_int, default=50, help="Newest emails to fetch (default 50)")
+    classify.add_argument("--all", action="store_true", help="Also reclassify emails done with the current prompt")
+    classify.set_defaults(handler=cmd_classify)
+
+    sync = commands.add_parser("sync", parents=[common], help="Store a folder's new emails and reconcile stored ones")
+    sync.add_argument("folder")
+    sync.add_argument("--limit", type=_positive_int, default=500, help="Newest emails to sync (default 500)")
+    sync.set_defaults(handler=cmd_sync)
+
+    tasks = commands.add_parser("tasks", help="Task commands")
+    task_commands = tasks.add_subparsers(dest="task_command", metavar="COMMAND", required=True)
+    task_list = task_commands.add_parser("list", parents=[common], help="List tasks, newest first")
+    task_list.add_argument("--overdue", action="store_true", help="Only open tasks past their due date")
+    task_list.add_argument("--status", choices=[status.value for status in TaskStatus])
+    task_list.add_argument("--limit", type=_positive_int, default=50, help="Most tasks to list (default 50)")
+    task_list.set_defaults(handler=cmd_tasks_list)
+
+    stats = commands.add_parser("stats", parents=[common], help="Show email and task counts")
+    stats.set_defaults(handler=cmd_stats)
+
+    backup = commands.add_parser("backup", parents=[common], help="Copy the database")
+    backup.add_argument("--output", help="Backup file (defaults to <db>.backup_<timestamp>)")
+    backup.set_defaults(handler=cmd_backup)
+    return parser
+
+
+def _positive_int(value: str) -> int:
+    number = int(value)
+    if number < 1:
+        raise argparse.ArgumentTypeError(f"must be positive: {value}")
+    return number
+
+
+def main(argv: Optional[List[str]] = None, context: Optional[CLIContext] = None) -> int:
+    """Run one command.
+
+    Args:
+        argv: Arguments without the program name (defaults to sys.argv[1:])
+        context: Services and streams to run with (defaults to the configured ones)
+
+    Returns:
+        EXIT_OK, EXIT_FAILURE, EXIT_USAGE, or EXIT_AUTH
+    """
+    try:
+        args = build_parser().parse_args(argv)
+    except SystemExit as e:
+        return EXIT_USAGE if e.code else EXIT_OK
+
+    context = context or CLIContext()
+    try:
+        warnings = settings.validate_config()
+    except ConfigValidationError as e:
+        context.error(str(e))
+        return EXIT_FAILURE
+    for warning in warnings:
+        print(f"warning: {warning}", file=context.err)
+
+    try:
+        return asyncio.run(args.handler(args, context))
+    except CLIAuthError as e:
+        context.error(str(e))
+        return EXIT_AUTH
+    except Exception as e:
+        context.error(str(e))
+        return EXIT_FAILURE
+
+
+if __name__ == "__main__":
+    sys.exit(main())
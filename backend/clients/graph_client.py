@@ -17,6 +17,10 @@ from urllib3.util.retry import Retry
 
 from backend.core.config import settings
 
+# PR_MESSAGE_CLASS, which Graph only returns as an extended property (IPM.Note.rpmsg for IRM)
+MESSAGE_CLASS_PROPERTY = "String 0x001A"
+MESSAGE_CLASS_EXPAND = f"singleValueExtendedProperties($filter=id eq '{MESSAGE_CLASS_PROPERTY}')"
+
 
 class GraphAPIError(Exception):
     """Custom exception for Graph API errors."""
@@ -260,7 +264,8 @@ class GraphClient:
             "$top": min(count, 100),
             "$skip": offset,
             "$orderby": "receivedDateTime desc",
-            "$select": "id,subject,from,toRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,bodyPreview"
+            "$select": "id,subject,from,toRecipients,ccRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,bodyPreview",
+            "$expand": MESSAGE_CLASS_EXPAND
         }
         
         endpoint = f"/me/mailFolders/{folder_id}/messages"
@@ -272,7 +277,8 @@ class GraphClient:
         """Get full message content by ID."""
         endpoint = f"/me/messages/{message_id}"
         params = {
-            "$select": "id,subject,from,toRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,body,bodyPreview"
+            "$select": "id,subject,from,toRecipients,ccRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,body,bodyPreview",
+            "$expand": MESSAGE_CLASS_EXPAND
         }
         
         return self._make_graph_request("GET", endpoint, params=params)
@@ -316,7 +322,8 @@ class GraphClient:
         params = {
             "$filter": f"conversationId eq '{conversation_id}'",
             "$orderby": "receivedDateTime asc",
-            "$select": "id,subject,from,toRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,bodyPreview"
+            "$select": "id,subject,from,toRecipients,ccRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,bodyPreview",
+            "$expand": MESSAGE_CLASS_EXPAND
         }
         
         result = self._make_graph_request("GET", "/me/messages", params=params)
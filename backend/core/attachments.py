@@ -0,0 +1,54 @@
+"""Email attachment metadata for FastAPI Email Helper API.
+
+Providers list each email's attachments under the attachments key as
+{"name", "size"} dicts (size in bytes), with the Content-ID as content_id
+for inline attachments an HTML body embeds by cid: URL. Only the metadata
+is kept: it is stored as a JSON array in the attachments column with the
+attachment_count and attachment_bytes totals, which GET
+/api/emails/storage-stats adds up per sender and folder. The bytes of an
+inline attachment are read from the provider when GET
+/api/emails/{id}/inline/{cid} asks for them.
+"""
+
+import json
+from typing import Any, Dict, List, Optional, Tuple
+
+
+def email_attachments(email: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Name, size, and any content_id of each attachment of a provider email."""
+    attachments = []
+    for attachment in email.get("attachments") or []:
+        if not isinstance(attachment, dict):
+            continue
+        try:
+            size = max(0, int(attachment.get("size") or 0))
+        except (TypeError, ValueError):
+            size = 0
+        entry = {"name": str(attachment.get("name") or ""), "size": size}
+        if isinstance(attachment.get("content_id"), str) and attachment["content_id"]:
+            entry["content_id"] = attachment["content_id"]
+        attachments.append(entry)
+    return attachments
+
+
+def attachment_columns(email: Dict[str, Any]) -> Tuple[Optional[str], int, int]:
+    """attachments, attachment_count, and attachment_bytes values for storing an email.
+
+    Emails whose provider gave no attachment list store NULL metadata with
+    zero totals.
+    """
+    if email.get("attachments") is None:
+        return None, 0, 0
+    attachments = email_attachments(email)
+    return json.dumps(attachments), len(attachments), sum(a["size"] for a in attachments)
+
+
+def decode_attachments(value: Optional[str]) -> List[Dict[str, Any]]:
+    """Attachments stored in an attachments column."""
+    if not value:
+        return []
+    try:
+        attachments = json.loads(value)
+    except ValueError:
+        return []
+    return attachments if isinstance(attachments, list) else []
@@ -0,0 +1,47 @@
+"""Business-day arithmetic for FastAPI Email Helper API.
+
+Weekends (Saturday and Sunday) and the dates in settings.task_holidays are
+not business days. Used to turn the due_in_business_days of task_defaults
+into a due date.
+"""
+
+from datetime import date, datetime, timedelta
+from typing import Iterable, Set, Union
+
+
+def parse_holidays(values: Iterable[Union[str, date]]) -> Set[date]:
+    """Holiday dates from YYYY-MM-DD strings or dates.
+
+    Raises:
+        ValueError: If a string is not a YYYY-MM-DD date
+    """
+    return {value if isinstance(value, date) else date.fromisoformat(str(value).strip()) for value in values}
+
+
+def is_business_day(day: date, holidays: Iterable[date] = ()) -> bool:
+    """Whether a date is a weekday that is not a holiday."""
+    return day.weekday() < 5 and day not in set(holidays)
+
+
+def add_business_days(start: Union[date, datetime], days: int, holidays: Iterable[date] = ()) -> date:
+    """The date days business days after start.
+
+    Zero days gives start itself, moved forward to the next business day
+    when it falls on a weekend or holiday.
+
+    Args:
+        start: Date (or datetime, whose date is used) to count from
+        days: Business days to add (not negative)
+        holidays: Dates to skip besides weekends
+    """
+    if days < 0:
+        raise ValueError("days cannot be negative")
+    skipped = set(holidays)
+    day = start.date() if isinstance(start, datetime) else start
+    while days:
+        day += timedelta(days=1)
+        if is_business_day(day, skipped):
+            days -= 1
+    while not is_business_day(day, skipped):
+        day += timedelta(days=1)
+    return day
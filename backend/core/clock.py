@@ -0,0 +1,97 @@
+"""Time zone aware clock for FastAPI Email Helper API.
+
+"Today", "overdue", and end-of-day due dates depend on where the user is,
+not on where the API runs (often a UTC container). A Clock gives the
+current time and day boundaries in one IANA time zone: the user's
+user_settings.timezone, else settings.timezone. A Clock without a zone
+keeps the server's local naive times, as the API used before zones were
+configurable.
+
+With a zone, times are timezone-aware and SQL comparisons get them with
+their offset, which SQLite's datetime() converts to UTC. Naive datetimes
+(stored without an offset, or passed as now) are read as UTC the same way.
+Day boundaries are computed on the calendar, so a day across a DST change
+is 23 or 25 hours long.
+"""
+
+from datetime import date, datetime, time, timedelta, timezone, tzinfo
+from typing import Optional, Union
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+
+
+def parse_timezone(name: str) -> ZoneInfo:
+    """The IANA time zone with this name.
+
+    Raises:
+        ValueError: If no time zone has this name
+    """
+    try:
+        return ZoneInfo(str(name).strip())
+    except (ZoneInfoNotFoundError, ValueError):
+        raise ValueError(f"Unknown time zone {name!r}; use an IANA name like America/New_York")
+
+
+class Clock:
+    """Current time and day boundaries in one time zone."""
+
+    def __init__(self, tz: Optional[tzinfo] = None, now: Optional[datetime] = None):
+        """Initialize the clock.
+
+        Args:
+            tz: Time zone; None keeps the server's local naive times
+            now: Fixed current time (naive means UTC when tz is set)
+        """
+        self.tz = tz
+        self._now = self.local(now) if now is not None else None
+
+    @property
+    def timezone_name(self) -> Optional[str]:
+        """IANA name of the zone, or None for the server's local time."""
+        return getattr(self.tz, "key", None) or (str(self.tz) if self.tz else None)
+
+    def local(self, value: datetime) -> datetime:
+        """A datetime in the clock's zone; naive values are read as UTC."""
+        if self.tz is None:
+            return value
+        if value.tzinfo is None:
+            value = value.replace(tzinfo=timezone.utc)
+        return value.astimezone(self.tz)
+
+    def localize(self, value: datetime) -> datetime:
+        """A naive wall-clock time (e.g. a parsed deadline) as that time in the clock's zone."""
+        if self.tz is None or value.tzinfo is not None:
+            return value
+        return value.replace(tzinfo=self.tz)
+
+    def now(self) -> datetime:
+        """Current time in the clock's zone."""
+        return self._now or datetime.now(self.tz)
+
+    def today(self) -> date:
+        """Current date in the clock's zone."""
+        return self.now().date()
+
+    def at(self, day: date, at_time: time) -> datetime:
+        """Wall-clock time on a date in the clock's zone."""
+        return datetime.combine(day, at_time, tzinfo=self.tz)
+
+    def start_of_day(self, day: Optional[Union[date, datetime]] = None) -> datetime:
+        """Midnight starting a date (default today) in the clock's zone."""
+        return self.at(self._date(day), time.min)
+
+    def end_of_day(self, day: Optional[Union[date, datetime]] = None) -> datetime:
+        """Midnight ending a date (default today): the start of the next day."""
+        return self.start_of_day(self._date(day) + timedelta(days=1))
+
+    def _date(self, day: Optional[Union[date, datetime]]) -> date:
+        if day is None:
+            return self.today()
+        return self.local(day).date() if isinstance(day, datetime) else day
+
+
+def clock_for(name: Optional[str], now: Optional[datetime] = None) -> Clock:
+    """Clock for an IANA time zone name; None or an unknown name gives server local time."""
+    try:
+        return Clock(parse_timezone(name) if name else None, now)
+    except ValueError:
+        return Clock(None, now)
@@ -4,13 +4,20 @@ This module extends the existing configuration patterns to support FastAPI-speci
 settings while maintaining compatibility with the existing Email Helper infrastructure.
 """
 
+import json
+import logging
 import os
+import re
 import sys
-from typing import Optional
+import typing
+from datetime import date
+from typing import Any, Dict, List, Optional, Tuple, Type
 from pydantic import Field
-from pydantic_settings import BaseSettings
+from pydantic_settings import BaseSettings, PydanticBaseSettingsSource
 from pathlib import Path
 
+from backend.core.clock import parse_timezone
+
 # Add src to Python path to import existing config
 sys.path.insert(0, str(Path(__file__).parent.parent.parent / "src"))
 
@@ -20,6 +27,214 @@ except ImportError:
     # Fallback if src modules can't be imported
     core_config = None
 
+logger = logging.getLogger(__name__)
+
+# Settings whose values must never be logged or returned by the API
+SECRET_FIELDS = {
+    "secret_key",
+    "admin_api_key",
+    "websocket_api_key",
+    "azure_openai_api_key",
+    "graph_client_secret",
+    "ado_personal_access_token",
+}
+
+DEFAULT_SECRET_KEY = "your-secret-key-change-in-production"
+
+# Values accepted by classification_strategy
+CLASSIFICATION_STRATEGIES = ("single", "two_stage")
+
+# Values accepted by auto_create_tasks
+AUTO_CREATE_TASK_POLICIES = ("off", "required_personal_action", "all_actionable")
+
+# Task priorities task_defaults may name
+TASK_PRIORITIES = ("low", "medium", "high", "urgent")
+
+# Keys of each category's entry in task_defaults
+TASK_DEFAULT_FIELDS = ("priority", "due_in_business_days")
+
+# Model parameters ai_parameters may override per operation, with their allowed (inclusive) range
+AI_PARAMETER_RANGES = {"temperature": (0.0, 2.0), "max_tokens": (1, 32768)}
+
+# Values accepted by provider_read_policy
+PROVIDER_READ_POLICIES = ("strict", "fallback")
+
+# Outlook category colors, in OlCategoryColor order (the index is the COM value)
+OUTLOOK_CATEGORY_COLORS = (
+    "none", "red", "orange", "peach", "yellow", "green", "teal", "olive", "blue",
+    "purple", "maroon", "steel", "dark_steel", "gray", "dark_gray", "black",
+    "dark_red", "dark_orange", "dark_peach", "dark_yellow", "dark_green",
+    "dark_teal", "dark_olive", "dark_blue", "dark_purple", "dark_maroon",
+)
+
+# Default color of the Outlook category created for each AI category
+DEFAULT_CATEGORY_COLORS = {
+    "required_personal_action": "red",
+    "team_action": "orange",
+    "optional_action": "yellow",
+    "work_relevant": "teal",
+    "fyi": "blue",
+    "newsletter": "gray",
+    "spam_to_delete": "purple",
+    "job_listing": "green",
+    "optional_event": "blue",
+}
+
+PROJECT_ROOT = Path(__file__).parent.parent.parent
+
+# Optional settings file; EMAIL_HELPER_CONFIG overrides the default locations
+CONFIG_FILE_ENV = "EMAIL_HELPER_CONFIG"
+DEFAULT_CONFIG_FILES = (PROJECT_ROOT / "config.yaml", PROJECT_ROOT / "config.json")
+
+
+class ConfigValidationError(ValueError):
+    """Raised when settings contain problems the API cannot start with."""
+    
+    def __init__(self, problems: List[str]):
+        self.problems = problems
+        super().__init__("Invalid configuration: " + "; ".join(problems))
+
+
+class ConfigFileError(ValueError):
+    """Raised when the settings file is malformed or contains a bad key."""
+    
+    def __init__(self, path: Path, message: str, key: Optional[str] = None):
+        self.path = path
+        self.key = key
+        super().__init__(f"{path}: {message}")
+
+
+def find_config_file() -> Optional[Path]:
+    """Locate the settings file, if any.
+    
+    Returns:
+        Path from EMAIL_HELPER_CONFIG, else the first default location
+        that exists, else None
+        
+    Raises:
+        ConfigFileError: If EMAIL_HELPER_CONFIG names a missing file
+    """
+    configured = os.environ.get(CONFIG_FILE_ENV)
+    if configured:
+        path = Path(configured)
+        if not path.is_file():
+            raise ConfigFileError(path, f"file named by {CONFIG_FILE_ENV} does not exist")
+        return path
+    
+    for path in DEFAULT_CONFIG_FILES:
+        if path.is_file():
+            return path
+    return None
+
+
+def _parse_config_file(path: Path) -> Any:
+    """Parse a YAML or JSON settings file."""
+    text = path.read_text(encoding="utf-8")
+    
+    if path.suffix.lower() == ".json":
+        try:
+            return json.loads(text) if text.strip() else {}
+        except json.JSONDecodeError as e:
+            raise ConfigFileError(path, f"invalid JSON at line {e.lineno}: {e.msg}")
+    
+    try:
+        import yaml
+    except ImportError:
+        raise ConfigFileError(path, "PyYAML is required to read YAML settings files")
+    
+    try:
+        return yaml.safe_load(text) or {}
+    except yaml.YAMLError as e:
+        mark = getattr(e, "problem_mark", None)
+        where = f" at line {mark.line + 1}" if mark else ""
+        raise ConfigFileError(path, f"invalid YAML{where}: {getattr(e, 'problem', e)}")
+
+
+def _check_value_type(path: Path, key: str, value: Any, annotation: Any) -> None:
+    """Check a file value against a simple settings field annotation."""
+    expected = annotation
+    if typing.get_origin(expected) is typing.Union:
+        if value is None:
+            return
+        expected = next(arg for arg in typing.get_args(expected) if arg is not type(None))
+    if typing.get_origin(expected) is not None:
+        expected = typing.get_origin(expected)
+    
+    if expected is bool:
+        valid = isinstance(value, bool)
+    elif expected is int:
+        valid = isinstance(value, int) and not isinstance(value, bool)
+    elif expected is float:
+        valid = isinstance(value, (int, float)) and not isinstance(value, bool)
+    elif expected in (str, list, dict):
+        valid = isinstance(value, expected)
+    else:
+        valid = True
+    
+    if not valid:
+        raise ConfigFileError(
+            path,
+            f"'{key}' must be {expected.__name__}, got {type(value).__name__} {value!r}",
+            key
+        )
+
+
+def load_config_file(settings_cls: Type[BaseSettings], path: Optional[Path] = None) -> Dict[str, Any]:
+    """Load and check settings values from the settings file.
+    
+    Keys are matched case-insensitively against ``settings_cls`` fields.
+    Secrets are rejected: they may only come from the environment or the
+    encrypted settings store.
+    
+    Args:
+        settings_cls: Settings class whose fields define valid keys
+        path: Settings file to read (defaults to ``find_config_file()``)
+        
+    Returns:
+        Mapping of field name to value; empty if there is no file
+        
+    Raises:
+        ConfigFileError: If the file is malformed or has a bad key or value
+    """
+    path = path or find_config_file()
+    if path is None:
+        return {}
+    
+    data = _parse_config_file(path)
+    if not isinstance(data, dict):
+        raise ConfigFileError(path, "top level must be a mapping of setting names to values")
+    
+    fields = settings_cls.model_fields
+    values = {}
+    for raw_key, value in data.items():
+        key = str(raw_key).lower()
+        if key not in fields:
+            raise ConfigFileError(path, f"unknown setting '{raw_key}'", str(raw_key))
+        if key in SECRET_FIELDS:
+            raise ConfigFileError(
+                path,
+                f"'{raw_key}' is a secret and may only be set via environment variables",
+                str(raw_key)
+            )
+        _check_value_type(path, key, value, fields[key].annotation)
+        values[key] = value
+    
+    return values
+
+
+class ConfigFileSettingsSource(PydanticBaseSettingsSource):
+    """Settings source backed by the optional YAML/JSON settings file."""
+    
+    def __init__(self, settings_cls: Type[BaseSettings]):
+        super().__init__(settings_cls)
+        self._values = load_config_file(settings_cls)
+    
+    def get_field_value(self, field, field_name: str) -> Tuple[Any, str, bool]:
+        return self._values.get(field_name), field_name, False
+    
+    def __call__(self) -> Dict[str, Any]:
+        return dict(self._values)
+
 
 class Settings(BaseSettings):
     """FastAPI application settings."""
@@ -28,16 +243,18 @@ class Settings(BaseSettings):
     app_name: str = "Email Helper API"
     app_version: str = "1.0.0"
     debug: bool = False
+    read_only: bool = False  # Refuse every change: mutating routes answer 403 read_only and mailbox changes are blocked (for demos on a real mailbox)
     
     # Server settings
     host: str = "0.0.0.0"
     port: int = 8000
     
     # Security settings
-    secret_key: str = "your-secret-key-change-in-production"
+    secret_key: str = DEFAULT_SECRET_KEY
     algorithm: str = "HS256"
     access_token_expire_minutes: int = 30
     refresh_token_expire_days: int = 30
+    admin_api_key: Optional[str] = None  # Key sent as X-API-Key to the data export and wipe admin endpoints (unset disables them)
     
     # CORS settings
     cors_origins: list = Field(default=["*"])
@@ -45,14 +262,132 @@ class Settings(BaseSettings):
     cors_allow_methods: list = Field(default=["*"])
     cors_allow_headers: list = Field(default=["*"])
     
+    # WebSocket settings
+    websocket_api_key: Optional[str] = None  # Key WebSocket clients send as the api_key query parameter or an api-key.<key> subprotocol (unset requires none; origins are checked against cors_origins)
+    websocket_max_connections_per_ip: int = 10  # Open WebSocket connections allowed per client IP (0 for no limit)
+    websocket_ping_interval_seconds: int = 30  # Seconds without a client message before the server sends a ping
+    websocket_idle_timeout_seconds: int = 120  # Seconds without a client message before the connection is closed (0 disables)
+    
     # Database settings
     database_url: Optional[str] = None
+    database_auto_restore: bool = False  # On a failed startup integrity check, restore the newest intact <db>.backup_* copy instead of starting empty
+    database_maintenance_interval_seconds: int = 604800  # Seconds between database maintenance passes (VACUUM, ANALYZE, PRAGMA optimize; 0 disables)
+    database_vacuum_threshold: float = 0.2  # Share of free pages (freelist_count / page_count) at which maintenance runs a full VACUUM
     
     # Azure OpenAI settings (from existing config)
     azure_openai_endpoint: Optional[str] = None
     azure_openai_api_key: Optional[str] = None
     azure_openai_deployment: str = "gpt-4o"
     azure_openai_api_version: str = "2024-02-01"
+    ai_rate_limit_per_minute: int = 30  # Per-user AI endpoint calls per minute (0 disables)
+    ai_max_in_flight: int = 4  # AI requests sent to one deployment at once; further requests wait in a queue
+    thread_classification: bool = False  # Batch processing classifies each conversation once
+    classification_strategy: str = "single"  # Batch classification: single (full prompt for every email) or two_stage (triage first)
+    triage_confidence_threshold: float = 0.8  # two_stage sends emails triaged below this confidence to the full classifier
+    triage_deployment: Optional[str] = None  # Azure OpenAI deployment for the triage pass, e.g. gpt-4o-mini (defaults to azure_openai_deployment)
+    thread_digest_messages: int = 5  # Earlier thread messages included as classification context
+    summary_backfill_concurrency: int = 4  # Summaries generated at once during backfill
+    thread_summary_max_tokens: int = 3000  # Prompt tokens a thread summary's messages may use
+    auto_create_tasks: str = "off"  # Create tasks after classification: off, required_personal_action, all_actionable
+    ai_task_titles: bool = False  # Rewrite extracted task titles as short imperative phrases with the AI (task_title.prompty)
+    pipeline_max_tokens_budget: int = 0  # AI tokens one processing run may use before remaining jobs are skipped (0 disables)
+    
+    # Follow-up detection
+    user_email: Optional[str] = None  # Your mailbox address (defaults to the account email)
+    awaiting_reply_days: int = 2  # Days a question must go unanswered to need a follow-up
+    awaiting_reply_ai_check: bool = False  # Confirm heuristic matches with the AI detector
+    to_me_recipient_limit: int = 4  # to_me matches emails to user_email with fewer To and Cc recipients than this
+    
+    # Shared mailboxes
+    shared_mailboxes: List[str] = []  # Shared mailboxes (name or address) merged into /api/emails/unified
+    
+    # Provider outages
+    provider_read_policy: str = "strict"  # strict, or fallback to serve reads from the database when the provider is down
+    
+    # Outlook folder listing
+    folder_cache_seconds: int = 30  # Seconds folder counts are cached (0 disables)
+    excluded_folders: List[str] = []  # Folder path prefixes (e.g. Personal, Inbox/Payroll) never read, processed, or moved into
+    
+    # Tray summary
+    summary_cache_seconds: int = 10  # Seconds GET /api/summary is cached per user (0 disables)
+    
+    # Spam quarantine
+    spam_quarantine_days: int = 0  # Days applied spam waits in the Quarantine folder before deletion (0 disables)
+    quarantine_purge_interval_seconds: int = 3600  # Seconds between purges of expired quarantined emails
+    
+    # Aged email archiving
+    archive_folder: str = "Archive"  # Folder POST /api/emails/archive-aged moves aged inbox emails into
+    archive_aged_categories: List[str] = ["fyi", "newsletter"]  # Categories the scheduled archive_aged job archives
+    archive_aged_days: int = 14  # Days after receipt the scheduled archive_aged job archives an inbox email
+    archive_aged_interval_seconds: int = 0  # Seconds between archive_aged runs over every user's emails (0 disables)
+    
+    # Category folder hygiene
+    folder_retention_days: Dict[str, int] = {}  # Days emails are kept in a category folder before folder hygiene purges them, e.g. {"Job Listings": 90}
+    folder_retention_default_days: int = 0  # Retention of category folders missing from folder_retention_days (0 keeps their emails forever)
+    folder_hygiene_destination: str = "Deleted Items"  # Folder POST /api/emails/folder-hygiene/purge moves purged emails into
+    
+    # Classification snapshots
+    snapshot_max_emails: int = 50000  # Most emails one classification snapshot may hold; larger mailboxes are refused
+    snapshot_max_per_user: int = 20  # Classification snapshots a user may keep before deleting one
+    
+    # Command line (python -m backend.cli)
+    cli_username: Optional[str] = None  # User the CLI acts as without --user (unset uses the only active user, if there is one)
+    
+    # Outlook activity log
+    activity_retention_days: int = 30  # Days Outlook activity log entries are kept (0 keeps them forever)
+    activity_prune_interval_seconds: int = 3600  # Seconds between prunes of expired activity log entries
+    
+    # Outbox (mailbox changes queued while Outlook is unavailable)
+    outbox_enabled: bool = True  # Queue marks, moves, and categories that fail while Outlook is unavailable instead of failing them
+    outbox_max_attempts: int = 5  # Failed retries after which a queued change is dead-lettered
+    outbox_retry_seconds: int = 60  # Seconds before retrying a queued change that failed; doubles with each attempt
+    outbox_drain_interval_seconds: int = 30  # Seconds between attempts to apply queued changes (0 disables)
+    
+    # AI debug traces
+    ai_debug_traces: bool = False  # Record every AI prompt and raw response per email (or send X-AI-Debug-Trace: 1 per request)
+    ai_trace_retention_days: int = 7  # Days AI debug traces are kept (0 keeps them forever)
+    ai_trace_prune_interval_seconds: int = 3600  # Seconds between prunes of expired AI debug traces
+    
+    # Secret redaction (credentials masked in email text sent to the AI)
+    redact_secrets: bool = True  # Mask tokens, keys, SAS signatures, and passwords in email text before AI calls
+    redaction_patterns: List[str] = []  # Extra regular expressions masked as [REDACTED:custom] before AI calls
+    
+    # Rights-managed email (see core.protection)
+    allow_protected_ai: bool = False  # Send rights-managed (IRM) and encrypted emails to the AI anyway, bodies as read
+    
+    # Task reminders
+    task_reminder_lead_minutes: int = 15  # Minutes before a task's due date its reminder notification fires (0 disables)
+    task_reminder_interval_seconds: int = 60  # Seconds between checks for task reminders to fire
+    
+    # Sender boilerplate (signatures and disclaimers learned per sender)
+    boilerplate_learn_interval_seconds: int = 21600  # Seconds between passes learning senders' repeated trailing blocks (0 disables)
+    
+    # Inbox progress (daily inbox-zero snapshots behind GET /api/emails/progress)
+    progress_snapshot_interval_seconds: int = 900  # Seconds between snapshots of every user's unprocessed inbox count (0 disables)
+    
+    # Contacts (relationship hints from Sent Items for classification)
+    contact_scan_interval_seconds: int = 3600  # Seconds between scans of Sent Items for newly sent mail (0 disables)
+    contact_scan_max_emails: int = 2000  # Most sent emails read per scan; a first scan counts only the newest this many
+    contact_frequent_threshold: int = 10  # Sent emails to a contact for relationship hints to call them a frequent correspondent
+    
+    # Email diffs (GET /api/emails/{id}/diff/{other_id})
+    email_diff_max_kb: int = 64  # KB of each email's plain text compared; longer bodies are cut with a notice
+    
+    # Task defaults
+    task_defaults: Dict[str, Dict[str, Any]] = {}  # Per email category, priority and due_in_business_days for created tasks, e.g. {"required_personal_action": {"priority": "high", "due_in_business_days": 2}}
+    task_holidays: List[str] = []  # Dates (YYYY-MM-DD) skipped like weekends when counting business days
+    timezone: Optional[str] = None  # IANA time zone (e.g. America/New_York) for "today", overdue, and due dates of users without their own (defaults to the server's local time)
+    
+    # Reply drafting
+    user_name: Optional[str] = None  # Name replies are drafted as (defaults to user_specific_data/username.txt)
+    job_context: Optional[str] = None  # Your role, used when drafting replies (defaults to the job summary)
+    
+    # Sender reputation scoring
+    reputation_weight_spam: float = 0.4  # Weight of the not-spam rate
+    reputation_weight_tasks: float = 0.3  # Weight of the completed-task rate
+    reputation_weight_engagement: float = 0.2  # Weight of the read rate
+    reputation_weight_volume: float = 0.1  # Weight of the mail volume
+    reputation_cache_ttl_seconds: int = 300  # Seconds a computed reputation is cached
     
     # Microsoft Graph API settings
     graph_client_id: Optional[str] = None
@@ -64,11 +399,324 @@ class Settings(BaseSettings):
     use_com_backend: bool = False  # Enable COM email provider and AI service
     com_connection_timeout: int = 30  # Seconds to wait for COM connection
     com_retry_attempts: int = 3  # Number of retry attempts for COM operations
+    outlook_store_name: Optional[str] = None  # Display name of the Outlook store (mailbox or data file) to use (defaults to the profile's default store)
+    suppress_read_receipts: bool = False  # Never send requested read receipts when marking emails read
+    outlook_category_colors: Dict[str, str] = DEFAULT_CATEGORY_COLORS  # Outlook category color for each AI category
+    
+    # Prompt templates (defaults to the repository prompts/ directory)
+    prompts_dir: Optional[str] = None
+    user_prompts_dir: Optional[str] = None  # Directory of .prompty files overriding bundled templates of the same name (untouched by upgrades)
+    ai_parameters: Dict[str, Dict[str, Any]] = {}  # Per operation (template name without .prompty), temperature and max_tokens overriding the template's, e.g. {"email_classifier_with_explanation": {"temperature": 0.1}}
+    
+    # Azure DevOps integration settings
+    ado_organization: Optional[str] = None
+    ado_project: Optional[str] = None
+    ado_personal_access_token: Optional[str] = None
     
     model_config = {
-        "env_file": ".env",
+        # Later files win: a .env in the working directory overrides the project one
+        "env_file": (str(PROJECT_ROOT / ".env"), ".env"),
         "case_sensitive": False
     }
+    
+    @classmethod
+    def settings_customise_sources(
+        cls,
+        settings_cls,
+        init_settings,
+        env_settings,
+        dotenv_settings,
+        file_secret_settings
+    ):
+        """Order settings sources: environment > .env > settings file > defaults."""
+        return (
+            init_settings,
+            env_settings,
+            dotenv_settings,
+            ConfigFileSettingsSource(settings_cls),
+            file_secret_settings,
+        )
+    
+    def get_prompts_dir(self) -> Path:
+        """Get the directory containing .prompty templates."""
+        if self.prompts_dir:
+            return Path(self.prompts_dir)
+        return PROJECT_ROOT / "prompts"
+    
+    @property
+    def ai_configured(self) -> bool:
+        """Whether Azure OpenAI has enough settings to make requests."""
+        return bool(self.azure_openai_endpoint and self.azure_openai_deployment)
+    
+    @property
+    def graph_configured(self) -> bool:
+        """Whether Microsoft Graph credentials are fully configured."""
+        return bool(self.graph_client_id and self.graph_client_secret and self.graph_tenant_id)
+    
+    @property
+    def ado_configured(self) -> bool:
+        """Whether Azure DevOps integration is configured."""
+        return bool(self.ado_organization and self.ado_personal_access_token)
+    
+    @property
+    def provider_type(self) -> str:
+        """Email provider type selected by these settings."""
+        if self.use_com_backend:
+            return "com"
+        if self.graph_configured:
+            return "graph"
+        return "mock"
+    
+    def validate_config(self) -> List[str]:
+        """Validate settings, failing fast on problems the API cannot run with.
+        
+        Returns:
+            List of non-fatal warnings
+            
+        Raises:
+            ConfigValidationError: If any fatal problem is found
+        """
+        problems = []
+        warnings = []
+        
+        if not 1 <= self.port <= 65535:
+            problems.append(f"port must be between 1 and 65535, got {self.port}")
+        
+        if self.access_token_expire_minutes <= 0:
+            problems.append("access_token_expire_minutes must be positive")
+        
+        if self.com_connection_timeout <= 0:
+            problems.append("com_connection_timeout must be positive")
+        
+        if self.com_retry_attempts < 0:
+            problems.append("com_retry_attempts cannot be negative")
+        
+        if self.ai_rate_limit_per_minute < 0:
+            problems.append("ai_rate_limit_per_minute cannot be negative")
+        
+        if self.thread_digest_messages < 0:
+            problems.append("thread_digest_messages cannot be negative")
+        
+        if self.awaiting_reply_days < 0:
+            problems.append("awaiting_reply_days cannot be negative")
+        
+        if self.to_me_recipient_limit < 2:
+            problems.append("to_me_recipient_limit must be at least 2 (the user plus one other recipient)")
+        
+        if self.pipeline_max_tokens_budget < 0:
+            problems.append("pipeline_max_tokens_budget cannot be negative")
+        
+        if self.summary_backfill_concurrency < 1:
+            problems.append("summary_backfill_concurrency must be positive")
+        if self.ai_max_in_flight < 1:
+            problems.append("ai_max_in_flight must be positive")
+        
+        if self.thread_summary_max_tokens < 1:
+            problems.append("thread_summary_max_tokens must be positive")
+        
+        if self.classification_strategy not in CLASSIFICATION_STRATEGIES:
+            problems.append(
+                f"classification_strategy must be one of {', '.join(CLASSIFICATION_STRATEGIES)}, "
+                f"got {self.classification_strategy!r}"
+            )
+        
+        if not 0 <= self.triage_confidence_threshold <= 1:
+            problems.append("triage_confidence_threshold must be between 0 and 1")
+        
+        if self.auto_create_tasks not in AUTO_CREATE_TASK_POLICIES:
+            problems.append(
+                f"auto_create_tasks must be one of {', '.join(AUTO_CREATE_TASK_POLICIES)}, "
+                f"got {self.auto_create_tasks!r}"
+            )
+        
+        reputation_weights = {
+            name: getattr(self, name) for name in (
+                "reputation_weight_spam", "reputation_weight_tasks",
+                "reputation_weight_engagement", "reputation_weight_volume"
+            )
+        }
+        negative = [name for name, weight in reputation_weights.items() if weight < 0]
+        if negative:
+            problems.append(f"{', '.join(negative)} cannot be negative")
+        elif not any(reputation_weights.values()):
+            problems.append("At least one reputation_weight_* setting must be positive")
+        
+        if self.reputation_cache_ttl_seconds < 0:
+            problems.append("reputation_cache_ttl_seconds cannot be negative")
+        if self.folder_cache_seconds < 0:
+            problems.append("folder_cache_seconds cannot be negative")
+        if self.provider_read_policy not in PROVIDER_READ_POLICIES:
+            problems.append(
+                f"provider_read_policy must be one of {', '.join(PROVIDER_READ_POLICIES)}, "
+                f"got {self.provider_read_policy!r}"
+            )
+        if self.summary_cache_seconds < 0:
+            problems.append("summary_cache_seconds cannot be negative")
+        if self.spam_quarantine_days < 0:
+            problems.append("spam_quarantine_days cannot be negative")
+        if self.quarantine_purge_interval_seconds <= 0:
+            problems.append("quarantine_purge_interval_seconds must be positive")
+        if not self.archive_folder.strip():
+            problems.append("archive_folder cannot be empty")
+        if self.archive_aged_days <= 0:
+            problems.append("archive_aged_days must be positive")
+        if self.archive_aged_interval_seconds < 0:
+            problems.append("archive_aged_interval_seconds cannot be negative")
+        negative_retention = [folder for folder, days in self.folder_retention_days.items() if days < 0]
+        if negative_retention:
+            problems.append(f"folder_retention_days cannot be negative, got: {', '.join(negative_retention)}")
+        if self.folder_retention_default_days < 0:
+            problems.append("folder_retention_default_days cannot be negative")
+        if self.snapshot_max_emails < 1:
+            problems.append("snapshot_max_emails must be positive")
+        if self.snapshot_max_per_user < 1:
+            problems.append("snapshot_max_per_user must be positive")
+        if self.activity_retention_days < 0:
+            problems.append("activity_retention_days cannot be negative")
+        if self.activity_prune_interval_seconds <= 0:
+            problems.append("activity_prune_interval_seconds must be positive")
+        if self.outbox_max_attempts <= 0:
+            problems.append("outbox_max_attempts must be positive")
+        if self.outbox_retry_seconds < 0:
+            problems.append("outbox_retry_seconds cannot be negative")
+        if self.outbox_drain_interval_seconds < 0:
+            problems.append("outbox_drain_interval_seconds cannot be negative")
+        if self.ai_trace_retention_days < 0:
+            problems.append("ai_trace_retention_days cannot be negative")
+        if self.ai_trace_prune_interval_seconds <= 0:
+            problems.append("ai_trace_prune_interval_seconds must be positive")
+        for pattern in self.redaction_patterns:
+            try:
+                re.compile(pattern)
+            except re.error as e:
+                problems.append(f"redaction_patterns has an invalid regular expression {pattern!r}: {e}")
+        if self.task_reminder_lead_minutes < 0:
+            problems.append("task_reminder_lead_minutes cannot be negative")
+        if self.task_reminder_interval_seconds <= 0:
+            problems.append("task_reminder_interval_seconds must be positive")
+        if self.boilerplate_learn_interval_seconds < 0:
+            problems.append("boilerplate_learn_interval_seconds cannot be negative")
+        if self.progress_snapshot_interval_seconds < 0:
+            problems.append("progress_snapshot_interval_seconds cannot be negative")
+        if self.contact_scan_interval_seconds < 0:
+            problems.append("contact_scan_interval_seconds cannot be negative")
+        if self.contact_scan_max_emails <= 0:
+            problems.append("contact_scan_max_emails must be positive")
+        if self.contact_frequent_threshold < 2:
+            problems.append("contact_frequent_threshold must be at least 2")
+        if self.database_maintenance_interval_seconds < 0:
+            problems.append("database_maintenance_interval_seconds cannot be negative")
+        if not 0 < self.database_vacuum_threshold <= 1:
+            problems.append("database_vacuum_threshold must be greater than 0 and at most 1")
+        if self.email_diff_max_kb <= 0:
+            problems.append("email_diff_max_kb must be positive")
+        if self.websocket_max_connections_per_ip < 0:
+            problems.append("websocket_max_connections_per_ip cannot be negative")
+        if self.websocket_ping_interval_seconds <= 0:
+            problems.append("websocket_ping_interval_seconds must be positive")
+        if self.websocket_idle_timeout_seconds < 0:
+            problems.append("websocket_idle_timeout_seconds cannot be negative")
+        for category, defaults in self.task_defaults.items():
+            unknown_fields = sorted(set(defaults) - set(TASK_DEFAULT_FIELDS))
+            if unknown_fields:
+                problems.append(
+                    f"task_defaults.{category} has unknown fields: {', '.join(unknown_fields)} "
+                    f"(valid fields: {', '.join(TASK_DEFAULT_FIELDS)})"
+                )
+            if "priority" in defaults and defaults["priority"] not in TASK_PRIORITIES:
+                problems.append(
+                    f"task_defaults.{category}.priority must be one of {', '.join(TASK_PRIORITIES)}, "
+                    f"got {defaults['priority']!r}"
+                )
+            offset = defaults.get("due_in_business_days")
+            if offset is not None and (not isinstance(offset, int) or isinstance(offset, bool) or offset < 0):
+                problems.append(f"task_defaults.{category}.due_in_business_days must be a non-negative integer")
+        for operation, parameters in self.ai_parameters.items():
+            unknown_fields = sorted(set(parameters) - set(AI_PARAMETER_RANGES))
+            if unknown_fields:
+                problems.append(
+                    f"ai_parameters.{operation} has unknown fields: {', '.join(unknown_fields)} "
+                    f"(valid fields: {', '.join(AI_PARAMETER_RANGES)})"
+                )
+            for name, (low, high) in AI_PARAMETER_RANGES.items():
+                value = parameters.get(name)
+                if value is None:
+                    continue
+                kinds, kind = ((int, float), "a number") if isinstance(low, float) else ((int,), "an integer")
+                if not isinstance(value, kinds) or isinstance(value, bool) or not low <= value <= high:
+                    problems.append(
+                        f"ai_parameters.{operation}.{name} must be {kind} from {low} to {high}, got {value!r}"
+                    )
+        bad_holidays = []
+        for holiday in self.task_holidays:
+            try:
+                date.fromisoformat(str(holiday))
+            except ValueError:
+                bad_holidays.append(str(holiday))
+        if bad_holidays:
+            problems.append(f"task_holidays must be YYYY-MM-DD dates, got: {', '.join(bad_holidays)}")
+        if self.timezone:
+            try:
+                parse_timezone(self.timezone)
+            except ValueError as e:
+                problems.append(f"timezone: {e}")
+        unknown_colors = sorted(
+            f"{category}={color}" for category, color in self.outlook_category_colors.items()
+            if color not in OUTLOOK_CATEGORY_COLORS
+        )
+        if unknown_colors:
+            problems.append(
+                f"outlook_category_colors has unknown colors: {', '.join(unknown_colors)} "
+                f"(valid colors: {', '.join(OUTLOOK_CATEGORY_COLORS)})"
+            )
+        
+        prompts_dir = self.get_prompts_dir()
+        if not prompts_dir.is_dir():
+            if self.prompts_dir:
+                problems.append(f"prompts_dir does not exist: {prompts_dir}")
+            else:
+                warnings.append(f"Default prompts directory not found: {prompts_dir}")
+        
+        if self.user_prompts_dir and not Path(self.user_prompts_dir).is_dir():
+            problems.append(f"user_prompts_dir does not exist: {self.user_prompts_dir}")
+        
+        if self.azure_openai_endpoint and not self.azure_openai_deployment:
+            problems.append("azure_openai_deployment is required when azure_openai_endpoint is set")
+        
+        if self.azure_openai_api_key and not self.azure_openai_endpoint:
+            problems.append("azure_openai_endpoint is required when azure_openai_api_key is set")
+        
+        graph_fields = [self.graph_client_id, self.graph_client_secret, self.graph_tenant_id]
+        if any(graph_fields) and not all(graph_fields):
+            problems.append(
+                "graph_client_id, graph_client_secret and graph_tenant_id must be set together"
+            )
+        
+        if self.ado_personal_access_token and not self.ado_organization:
+            problems.append("ado_organization is required when ado_personal_access_token is set")
+        
+        if self.secret_key == DEFAULT_SECRET_KEY and not self.debug:
+            warnings.append("secret_key is the built-in default; set SECRET_KEY in production")
+        
+        if problems:
+            raise ConfigValidationError(problems)
+        
+        return warnings
+    
+    def masked_values(self) -> Dict[str, Any]:
+        """Get effective settings with secret values masked."""
+        values = self.model_dump()
+        for field in SECRET_FIELDS:
+            if values.get(field):
+                values[field] = "********"
+        return values
+    
+    def log_effective_config(self) -> None:
+        """Log effective settings (secrets masked) and validation warnings."""
+        for key, value in sorted(self.masked_values().items()):
+            logger.info(f"config {key}={value}")
+        for warning in self.validate_config():
+            logger.warning(f"Configuration warning: {warning}")
 
 
 def get_settings() -> Settings:
@@ -83,8 +731,7 @@ def get_database_path() -> str:
         return core_config.get_storage_path("email_helper_history.db")
     else:
         # Fallback path
-        project_root = Path(__file__).parent.parent.parent
-        runtime_data_dir = project_root / "runtime_data"
+        runtime_data_dir = PROJECT_ROOT / "runtime_data"
         runtime_data_dir.mkdir(exist_ok=True)
         return str(runtime_data_dir / "email_helper_history.db")
 
@@ -13,7 +13,7 @@ The module handles:
 
 import logging
 from typing import Optional
-from fastapi import HTTPException, status
+from fastapi import Depends, HTTPException, status
 
 from backend.core.config import settings
 
@@ -66,6 +66,9 @@ def get_com_email_provider():
             
             logger.info("COM email provider initialized successfully")
             
+        except HTTPException:
+            _com_email_provider = None
+            raise
         except ImportError as e:
             logger.error(f"COM email provider not available: {e}")
             raise HTTPException(
@@ -166,6 +169,9 @@ def get_email_provider():
                 _email_provider = get_com_email_provider()
                 return _email_provider
             except HTTPException as e:
+                # A missing configured store must not silently fall back to another mailbox
+                if settings.outlook_store_name and e.status_code == status.HTTP_404_NOT_FOUND:
+                    raise
                 logger.warning(f"COM provider unavailable, falling back: {e.detail}")
         
         # Fall back to existing provider selection logic
@@ -232,6 +238,21 @@ def get_ai_service():
     return _ai_service
 
 
+def get_email_service(provider=Depends(get_email_provider)):
+    """FastAPI dependency for the email service layer.
+    
+    Wraps the selected email provider in an EmailService so endpoints
+    await provider calls off the event loop and can be cancelled when
+    the client goes away.
+    
+    Returns:
+        EmailService: Service bound to the selected email provider
+    """
+    from backend.services.email_service import EmailService
+    
+    return EmailService(provider)
+
+
 def reset_dependencies():
     """Reset all singleton instances for testing purposes.
     
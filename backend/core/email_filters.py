@@ -0,0 +1,261 @@
+"""Stored-email filters for FastAPI Email Helper API.
+
+GET /api/emails and saved views (/api/views) share one filter vocabulary.
+Filters are validated against a fixed schema and turned into SQL only
+through the clauses below, so neither a query string nor a stored view can
+reach the database with an unknown parameter or raw SQL.
+
+The fields parameter of GET /api/emails projects list results onto an
+allowlist of email keys; stored-email searches select only the projected
+columns, so list views can leave the body out of the query entirely.
+
+group_by=conversation lists one entry per stored conversation instead of
+one per email (see EmailService.get_emails_grouped_by_conversation).
+"""
+
+import json
+import re
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional, Tuple
+
+from backend.core.errors import InputValidationError
+
+# Filter name -> expected Python type
+EMAIL_FILTERS = {
+    "folder": str,
+    "category": str,
+    "sender": str,
+    "unread": bool,
+    "flagged": bool,
+    "awaiting_reply": bool,
+    "to_me": bool,
+    "received_after": str,
+    "received_before": str,
+}
+
+# Sort name -> ORDER BY clause
+EMAIL_SORTS = {
+    "received_desc": "received_date DESC, id",
+    "received_asc": "received_date ASC, id",
+    "sender": "sender COLLATE NOCASE, received_date DESC",
+    "subject": "subject COLLATE NOCASE, received_date DESC",
+    # Unscored emails sort after every scored one
+    "importance": "importance_score IS NULL, importance_score DESC, received_date DESC",
+}
+
+DEFAULT_SORT = "received_desc"
+
+# Ways GET /api/emails can group stored emails
+EMAIL_GROUPINGS = ("conversation",)
+
+# Stored email columns a list may include (raw_content and user_id are never listed)
+STORED_EMAIL_FIELDS = (
+    "id", "subject", "sender", "recipient", "content", "received_date", "category",
+    "confidence", "processed_at", "folder", "conversation_id", "is_read", "is_flagged",
+    "needs_review", "ai_reasoning", "one_line_summary", "inherited_from", "awaiting_reply",
+    "awaiting_reply_detected_at", "original_conversation_id", "ai_processed_at",
+    "ai_prompt_version", "ai_model", "ai_category", "ai_confidence", "alternative_categories",
+    "importance_score", "importance_justification",
+    "quarantined_until", "version", "preview", "to_recipients", "cc_recipients", "recipient_count",
+    "attachments", "attachment_count", "attachment_bytes", "is_protected",
+)
+
+# Keys only provider (Outlook or Graph) emails carry
+PROVIDER_EMAIL_FIELDS = ("body", "received_time", "categories", "requests_read_receipt", "to", "cc")
+
+# Keys computed from other tables for stored and provider emails alike
+COMPUTED_EMAIL_FIELDS = ("note_count",)
+
+EMAIL_FIELDS = STORED_EMAIL_FIELDS + PROVIDER_EMAIL_FIELDS + COMPUTED_EMAIL_FIELDS
+
+MAX_FILTER_LENGTH = 200
+
+# Relative bounds such as "7d" or "12h" stay meaningful in saved views
+_RELATIVE_BOUND = re.compile(r"^(\d{1,4})([dhw])$")
+_RELATIVE_UNITS = {"h": "hours", "d": "days", "w": "weeks"}
+
+
+def parse_received_bound(value: str, now: Optional[datetime] = None) -> datetime:
+    """Parse a received_after/received_before value.
+
+    Args:
+        value: ISO date or datetime, or a relative age such as "7d", "12h", "2w"
+        now: Reference time for relative ages (defaults to now)
+
+    Raises:
+        InputValidationError: If the value is neither form
+    """
+    match = _RELATIVE_BOUND.match(value.strip().lower())
+    if match:
+        amount, unit = match.groups()
+        return (now or datetime.now()) - timedelta(**{_RELATIVE_UNITS[unit]: int(amount)})
+    try:
+        return datetime.fromisoformat(value.strip().replace("Z", "+00:00"))
+    except ValueError:
+        raise InputValidationError(f"'{value}' is not an ISO date or a relative age like 7d")
+
+
+def received_date_clause(operator: str, bound: datetime) -> Tuple[str, str]:
+    """WHERE fragment comparing received_date with a bound, and its parameter.
+
+    Stored received_date values are ISO strings ("2024-01-02T09:00:00",
+    sometimes with Z), while sqlite3 binds a datetime with a space, so a
+    plain comparison goes wrong inside the bound's day. julianday reads
+    both forms.
+
+    Args:
+        operator: SQL comparison, e.g. ">=" or "<"
+        bound: Time to compare with
+    """
+    return f"julianday(received_date) {operator} julianday(?)", bound.isoformat()
+
+
+def validate_email_filters(filters: Dict[str, Any]) -> Dict[str, Any]:
+    """Check filters against the schema and drop unset ones.
+
+    Args:
+        filters: Filter name to value
+
+    Returns:
+        The filters that are set
+
+    Raises:
+        InputValidationError: For unknown names, wrongly typed values, or bad dates
+    """
+    if not isinstance(filters, dict):
+        raise InputValidationError("Filters must be an object")
+    unknown = sorted(set(filters) - set(EMAIL_FILTERS))
+    if unknown:
+        raise InputValidationError(f"Unknown filters: {', '.join(unknown)}")
+
+    cleaned = {}
+    for name, value in filters.items():
+        if value is None:
+            continue
+        expected = EMAIL_FILTERS[name]
+        if type(value) is not expected:
+            raise InputValidationError(f"Filter '{name}' must be a {expected.__name__}")
+        if expected is str:
+            value = value.strip()
+            if not value or len(value) > MAX_FILTER_LENGTH:
+                raise InputValidationError(f"Filter '{name}' must be 1-{MAX_FILTER_LENGTH} characters")
+            if name.startswith("received_"):
+                parse_received_bound(value)
+        cleaned[name] = value
+    return cleaned
+
+
+def validate_sort(sort: str) -> str:
+    """Check a sort name.
+
+    Raises:
+        InputValidationError: If the sort is not one of EMAIL_SORTS
+    """
+    if sort not in EMAIL_SORTS:
+        raise InputValidationError(f"Unknown sort '{sort}' (expected {', '.join(EMAIL_SORTS)})")
+    return sort
+
+
+def validate_group_by(group_by: Optional[str]) -> Optional[str]:
+    """Check a grouping name (None lists emails ungrouped).
+
+    Raises:
+        InputValidationError: If the grouping is not one of EMAIL_GROUPINGS
+    """
+    if group_by is not None and group_by not in EMAIL_GROUPINGS:
+        raise InputValidationError(f"Unknown group_by '{group_by}' (expected {', '.join(EMAIL_GROUPINGS)})")
+    return group_by
+
+
+def validate_fields(fields: Optional[str]) -> Optional[List[str]]:
+    """Parse a comma-separated fields projection.
+
+    The id is always kept so projected emails stay addressable.
+
+    Returns:
+        Field names in request order, or None to keep every field
+
+    Raises:
+        InputValidationError: If a name is not one of EMAIL_FIELDS
+    """
+    if fields is None:
+        return None
+    names = list(dict.fromkeys(name.strip() for name in fields.split(",") if name.strip()))
+    if not names:
+        return None
+    unknown = [name for name in names if name not in EMAIL_FIELDS]
+    if unknown:
+        raise InputValidationError(
+            f"Unknown fields: {', '.join(unknown)} (valid fields: {', '.join(EMAIL_FIELDS)})"
+        )
+    return names if "id" in names else ["id", *names]
+
+
+def stored_columns(fields: Optional[List[str]]) -> Optional[List[str]]:
+    """Stored email columns and computed fields to select for a projection (None selects all)."""
+    if fields is None:
+        return None
+    return [name for name in fields if name in STORED_EMAIL_FIELDS or name in COMPUTED_EMAIL_FIELDS]
+
+
+def project_email(email: Dict[str, Any], fields: Optional[List[str]]) -> Dict[str, Any]:
+    """Keep only the projected keys of an email (all keys when fields is None)."""
+    if fields is None:
+        return email
+    return {name: email[name] for name in fields if name in email}
+
+
+def like_pattern(text: str) -> str:
+    """LIKE pattern (with ESCAPE '\\') matching values that contain text."""
+    escaped = re.sub(r"([\\%_])", r"\\\1", text)
+    return f"%{escaped}%"
+
+
+def build_filter_clause(
+    filters: Dict[str, Any],
+    now: Optional[datetime] = None,
+    my_address: Optional[str] = None,
+    to_me_recipient_limit: int = 0
+) -> Tuple[str, List[Any]]:
+    """Turn validated filters into a WHERE fragment over the emails table.
+
+    Args:
+        filters: Filters checked by validate_email_filters
+        now: Reference time for relative received bounds
+        my_address: The user's address, which to_me looks for in To
+        to_me_recipient_limit: to_me only matches emails with fewer
+            recipients (To and Cc) than this
+
+    Returns:
+        SQL to AND onto a WHERE clause ("" when no filters) and its parameters
+    """
+    clauses: List[str] = []
+    params: List[Any] = []
+
+    if "folder" in filters:
+        clauses.append("COALESCE(folder, 'Inbox') = ?")
+        params.append(filters["folder"])
+    if "category" in filters:
+        clauses.append("COALESCE(category, 'uncategorized') = ?")
+        params.append(filters["category"])
+    if "sender" in filters:
+        clauses.append("sender LIKE ? ESCAPE '\\'")
+        params.append(like_pattern(filters["sender"]))
+    for name, column in (("unread", "is_read"), ("flagged", "is_flagged"), ("awaiting_reply", "awaiting_reply")):
+        if name in filters:
+            wanted = not filters[name] if name == "unread" else filters[name]
+            clauses.append(f"COALESCE({column}, 0) = ?")
+            params.append(int(wanted))
+    for name, operator in (("received_after", ">="), ("received_before", "<")):
+        if name in filters:
+            clause, bound = received_date_clause(operator, parse_received_bound(filters[name], now))
+            clauses.append(clause)
+            params.append(bound)
+    if filters.get("to_me"):
+        if not my_address:
+            raise InputValidationError("Filter 'to_me' needs the user's email address")
+        # Addresses are stored JSON-quoted, so the quotes keep the match to whole addresses
+        clauses.append("LOWER(to_recipients) LIKE ? ESCAPE '\\' AND recipient_count < ?")
+        params += [like_pattern(json.dumps(my_address.strip().lower())), to_me_recipient_limit]
+
+    return "".join(f" AND {clause}" for clause in clauses), params
@@ -0,0 +1,374 @@
+"""Error types and response envelope for FastAPI Email Helper API.
+
+Services and the database layer raise the typed errors defined here instead
+of HTTPException; endpoints translate them with ``to_http_exception`` so the
+same failure always maps to the same status code. Every error response,
+including unhandled exceptions, uses one body shape:
+
+    {"error": {"code": "not_found", "message": "...", "request_id": "..."}}
+
+Conflicts caused by a stale version also carry the record's current state
+under "current", so clients can show it or retry against it. Request
+validation failures (422 validation_error from the request models' field
+constraints and validators) list every problem under "fields":
+
+    {"field": "email_ids", "location": "body", "message": "...", "type": "too_short"}
+
+Batch endpoints report failures per item instead. ``batch_status_code``
+gives every batch the same overall status: 200 when all items succeeded,
+207 Multi-Status when only some did, and the dominant item error's status
+when none did. Each item error carries its own status code and an
+is_retryable flag so clients know which items to resend.
+"""
+
+import logging
+import uuid
+from collections import Counter
+from contextvars import ContextVar
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+from fastapi import FastAPI, HTTPException, Request, status
+from fastapi.exceptions import RequestValidationError
+from fastapi.responses import JSONResponse
+from starlette.exceptions import HTTPException as StarletteHTTPException
+
+logger = logging.getLogger(__name__)
+
+REQUEST_ID_HEADER = "X-Request-ID"
+
+# Where a request value comes from, the first part of a validation error's location
+_REQUEST_LOCATIONS = ("body", "query", "path", "header", "cookie")
+
+# Prefix Pydantic puts before the message of a ValueError raised by a validator
+_VALUE_ERROR_PREFIX = "Value error, "
+
+# ID of the request being handled, for code that has no Request (e.g. services)
+_current_request_id: ContextVar[Optional[str]] = ContextVar("current_request_id", default=None)
+
+# Default error codes for statuses raised directly as HTTPException
+_STATUS_CODES = {
+    400: "bad_request",
+    401: "unauthorized",
+    403: "forbidden",
+    404: "not_found",
+    409: "conflict",
+    422: "validation_error",
+    429: "rate_limited",
+    500: "internal_error",
+    502: "upstream_error",
+    503: "service_unavailable",
+    504: "timeout",
+}
+
+
+class ServiceError(Exception):
+    """Base class for errors raised by services and the database layer."""
+
+    status_code = status.HTTP_500_INTERNAL_SERVER_ERROR
+    code = "internal_error"
+
+    def __init__(self, message: str = "Internal server error"):
+        super().__init__(message)
+        self.message = message
+
+
+class NotFoundError(ServiceError):
+    """Requested record does not exist or is not visible to the user."""
+
+    status_code = status.HTTP_404_NOT_FOUND
+    code = "not_found"
+
+
+class ConflictError(ServiceError):
+    """Operation conflicts with the current state of a record.
+
+    Args:
+        message: Human-readable description of the conflict
+        current: Current state of the record, returned to the client
+    """
+
+    status_code = status.HTTP_409_CONFLICT
+    code = "conflict"
+
+    def __init__(self, message: str = "Conflict", current: Optional[Dict[str, Any]] = None):
+        super().__init__(message)
+        self.current = current
+
+
+class InputValidationError(ServiceError, ValueError):
+    """Input was well-formed but failed service-level validation.
+
+    Also a ValueError, so argument checks can raise it where callers catch
+    ValueError.
+    """
+
+    status_code = status.HTTP_422_UNPROCESSABLE_ENTITY
+    code = "validation_error"
+
+
+class ExcludedFolderError(ServiceError):
+    """Operation touches a folder matched by settings.excluded_folders."""
+
+    status_code = status.HTTP_403_FORBIDDEN
+    code = "folder_excluded"
+
+
+class ReadOnlyError(ServiceError):
+    """Change refused because the server runs with settings.read_only."""
+
+    status_code = status.HTTP_403_FORBIDDEN
+    code = "read_only"
+
+
+class ProtectedContentError(ServiceError):
+    """Operation would send a rights-managed email's content to the AI."""
+
+    status_code = status.HTTP_422_UNPROCESSABLE_ENTITY
+    code = "protected_content"
+
+
+class UpstreamOutlookError(ServiceError):
+    """Outlook (COM or Graph) failed while handling the request."""
+
+    status_code = status.HTTP_502_BAD_GATEWAY
+    code = "upstream_outlook"
+
+
+class UpstreamAIError(ServiceError):
+    """Azure OpenAI failed while handling the request."""
+
+    status_code = status.HTTP_502_BAD_GATEWAY
+    code = "upstream_ai"
+
+
+AI_AUTH_MESSAGE = "Azure credential expired — run az login or set an API key"
+
+
+class AIAuthError(ServiceError):
+    """Azure OpenAI could not get a token or rejected the credential (e.g. an expired az login)."""
+
+    status_code = status.HTTP_503_SERVICE_UNAVAILABLE
+    code = "ai_auth"
+
+    def __init__(self, message: str = AI_AUTH_MESSAGE):
+        super().__init__(message)
+
+
+class APIError(HTTPException):
+    """HTTPException carrying a machine-readable error code."""
+
+    def __init__(
+        self,
+        status_code: int,
+        detail: str,
+        code: Optional[str] = None,
+        current: Optional[Dict[str, Any]] = None
+    ):
+        super().__init__(status_code=status_code, detail=detail)
+        self.code = code or error_code_for_status(status_code)
+        self.current = current
+
+
+def error_code_for_status(status_code: int) -> str:
+    """Return the default error code for an HTTP status."""
+    if status_code in _STATUS_CODES:
+        return _STATUS_CODES[status_code]
+    return "internal_error" if status_code >= 500 else "bad_request"
+
+
+def to_http_exception(exc: Exception, message: str) -> HTTPException:
+    """Map an exception raised inside an endpoint to an HTTPException.
+
+    HTTPExceptions pass through unchanged, typed service errors keep their
+    status and code, and anything else becomes a 500 with ``message`` as
+    context. Only InputValidationError is a validation failure: a bare
+    ValueError (e.g. json.JSONDecodeError or UnicodeDecodeError from
+    decoding provider or AI output) is an internal error.
+
+    Args:
+        exc: Exception caught by the endpoint
+        message: Human-readable summary of the failed operation
+
+    Returns:
+        HTTPException to raise from the endpoint
+
+    Example:
+        >>> try:
+        >>>     return await service.get_task(task_id, user_id)
+        >>> except Exception as e:
+        >>>     raise to_http_exception(e, "Failed to retrieve task")
+    """
+    if isinstance(exc, HTTPException):
+        return exc
+    if isinstance(exc, ServiceError):
+        return APIError(exc.status_code, exc.message, exc.code, getattr(exc, "current", None))
+    logger.error(f"{message}: {exc}")
+    return APIError(
+        status.HTTP_500_INTERNAL_SERVER_ERROR,
+        f"{message}: {str(exc)}",
+        "internal_error"
+    )
+
+
+# Item statuses worth retrying unchanged: timeouts, rate limits, and
+# server or upstream failures
+RETRYABLE_STATUS_CODES = frozenset({408, 429, 500, 502, 503, 504})
+
+
+def item_status(status_code: int) -> Dict[str, Any]:
+    """Status fields (status_code, is_retryable) for one batch item error."""
+    return {"status_code": status_code, "is_retryable": status_code in RETRYABLE_STATUS_CODES}
+
+
+def batch_item_failure(exc: Exception, message: str) -> Tuple[int, str]:
+    """Map an exception raised for one batch item to its status and message.
+
+    Uses the same mapping as ``to_http_exception``.
+    """
+    http_exc = to_http_exception(exc, message)
+    return http_exc.status_code, str(http_exc.detail)
+
+
+def batch_status_code(item_count: int, failure_statuses: Iterable[int]) -> int:
+    """Overall HTTP status for a batch of items.
+
+    Args:
+        item_count: Items in the batch
+        failure_statuses: Status code of each failed item
+
+    Returns:
+        200 if nothing failed, 207 if some items failed, otherwise the
+        most common failure status (the first seen on a tie)
+    """
+    failures = list(failure_statuses)
+    if not failures:
+        return status.HTTP_200_OK
+    if len(failures) < item_count:
+        return status.HTTP_207_MULTI_STATUS
+    return Counter(failures).most_common(1)[0][0]
+
+
+def get_request_id(request: Request) -> str:
+    """Return the request ID assigned by the request ID middleware."""
+    request_id = getattr(request.state, "request_id", None)
+    if request_id is None:
+        request_id = request.headers.get(REQUEST_ID_HEADER) or uuid.uuid4().hex
+        request.state.request_id = request_id
+    return request_id
+
+
+def current_request_id() -> Optional[str]:
+    """Return the ID of the request being handled, or None outside a request."""
+    return _current_request_id.get()
+
+
+def error_response(
+    request: Request,
+    status_code: int,
+    code: str,
+    message: str,
+    current: Optional[Dict[str, Any]] = None,
+    fields: Optional[List[Dict[str, Any]]] = None
+) -> JSONResponse:
+    """Build a JSON error response in the standard envelope."""
+    request_id = get_request_id(request)
+    error = {
+        "code": code,
+        "message": message,
+        "request_id": request_id
+    }
+    if current is not None:
+        error["current"] = current
+    if fields is not None:
+        error["fields"] = fields
+    return JSONResponse(
+        status_code=status_code,
+        content={"error": error},
+        headers={REQUEST_ID_HEADER: request_id}
+    )
+
+
+async def http_exception_handler(request: Request, exc: StarletteHTTPException) -> JSONResponse:
+    """Format HTTPExceptions in the standard envelope."""
+    code = getattr(exc, "code", None) or error_code_for_status(exc.status_code)
+    response = error_response(request, exc.status_code, code, str(exc.detail), getattr(exc, "current", None))
+    if exc.headers:
+        response.headers.update(exc.headers)
+    return response
+
+
+async def service_error_handler(request: Request, exc: ServiceError) -> JSONResponse:
+    """Format typed service errors that escaped an endpoint."""
+    return error_response(request, exc.status_code, exc.code, exc.message, getattr(exc, "current", None))
+
+
+def validation_fields(errors: Iterable[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    """Translate Pydantic validation errors into the envelope's per-field entries.
+
+    The field is the dotted path below the request location, e.g.
+    "emails.0.subject", or None for a model-level check such as "Provide
+    either email_ids or criteria".
+    """
+    fields = []
+    for error in errors:
+        loc = [str(part) for part in error.get("loc", ())]
+        location = loc.pop(0) if loc and loc[0] in _REQUEST_LOCATIONS else None
+        message = str(error.get("msg", "invalid value"))
+        if message.startswith(_VALUE_ERROR_PREFIX):
+            message = message[len(_VALUE_ERROR_PREFIX):]
+        fields.append({
+            "field": ".".join(loc) or None,
+            "location": location,
+            "message": message,
+            "type": error.get("type"),
+        })
+    return fields
+
+
+async def validation_exception_handler(request: Request, exc: RequestValidationError) -> JSONResponse:
+    """Format request validation failures in the standard envelope with every problem under fields."""
+    fields = validation_fields(exc.errors())
+    if fields:
+        first = fields[0]
+        location = ".".join(part for part in (first["location"], first["field"]) if part)
+        message = f"{location}: {first['message']}" if location else first["message"]
+    else:
+        message = "Request validation failed"
+    return error_response(
+        request, status.HTTP_422_UNPROCESSABLE_ENTITY, "validation_error", message, fields=fields
+    )
+
+
+async def unhandled_exception_handler(request: Request, exc: Exception) -> JSONResponse:
+    """Recover from unhandled exceptions with a generic 500 envelope."""
+    logger.exception(f"Unhandled error on {request.method} {request.url.path}: {exc}")
+    return error_response(
+        request,
+        status.HTTP_500_INTERNAL_SERVER_ERROR,
+        "internal_error",
+        "Internal server error"
+    )
+
+
+def register_error_handlers(app: FastAPI) -> None:
+    """Install request ID middleware and error handlers on an application.
+
+    Args:
+        app: FastAPI application to configure
+    """
+
+    @app.middleware("http")
+    async def request_id_middleware(request: Request, call_next):
+        request_id = get_request_id(request)
+        _current_request_id.set(request_id)
+        try:
+            response = await call_next(request)
+        except Exception as exc:
+            response = await unhandled_exception_handler(request, exc)
+        response.headers[REQUEST_ID_HEADER] = request_id
+        return response
+
+    app.add_exception_handler(StarletteHTTPException, http_exception_handler)
+    app.add_exception_handler(ServiceError, service_error_handler)
+    app.add_exception_handler(RequestValidationError, validation_exception_handler)
+    app.add_exception_handler(Exception, unhandled_exception_handler)
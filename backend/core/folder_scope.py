@@ -0,0 +1,60 @@
+"""Folder scope exclusions for FastAPI Email Helper API.
+
+settings.excluded_folders lists folder path prefixes, such as "Personal" or
+"Inbox/Payroll", that the API must not touch. A prefix matches the folder
+itself and every folder below it, compared case-insensitively with either
+slash as separator, so "personal" excludes "Personal/Family" but not
+"Personal Projects".
+
+Exclusions are enforced by reads of a folder (GET /api/emails), the
+pipeline's email selection, moves into a folder, and reconciliation with
+the provider; folder listings mark excluded folders instead of hiding them.
+"""
+
+from typing import Iterable, List, Optional
+
+from backend.core.config import settings
+from backend.core.errors import ExcludedFolderError
+
+
+def _segments(path: str) -> List[str]:
+    return [part.strip().casefold() for part in str(path).replace("\\", "/").split("/") if part.strip()]
+
+
+def excluded_prefix(path: Optional[str], prefixes: Optional[Iterable[str]] = None) -> Optional[str]:
+    """The excluded prefix matching a folder path, or None if the folder is in scope.
+
+    Args:
+        path: Folder path, e.g. "Inbox/Payroll/2024"
+        prefixes: Excluded prefixes (defaults to settings.excluded_folders)
+    """
+    if not path:
+        return None
+    segments = _segments(path)
+    for prefix in settings.excluded_folders if prefixes is None else prefixes:
+        prefix_segments = _segments(prefix)
+        if prefix_segments and segments[:len(prefix_segments)] == prefix_segments:
+            return prefix
+    return None
+
+
+def is_excluded_folder(path: Optional[str], prefixes: Optional[Iterable[str]] = None) -> bool:
+    """Whether a folder path is excluded by settings.excluded_folders (or prefixes)."""
+    return excluded_prefix(path, prefixes) is not None
+
+
+def check_folder_in_scope(path: Optional[str], action: str, hint: str = ""):
+    """Reject an operation on an excluded folder.
+
+    Args:
+        path: Folder the operation touches
+        action: What was refused, e.g. "Cannot move emails into"
+        hint: Sentence appended to the message, e.g. how to override
+
+    Raises:
+        ExcludedFolderError: If the folder is excluded
+    """
+    prefix = excluded_prefix(path)
+    if prefix is not None:
+        message = f"{action} folder '{path}': it is excluded by excluded_folders ('{prefix}')"
+        raise ExcludedFolderError(f"{message}. {hint}" if hint else message)
@@ -0,0 +1,131 @@
+"""iCalendar (RFC 5545) rendering for FastAPI Email Helper API.
+
+Builds VCALENDAR documents from plain event dicts. Output depends only on
+the events passed in, so the same events always give byte-identical
+calendars and the text can be hashed for an ETag.
+
+Times are written as floating local times (no time zone), matching how
+due dates are stored. Events whose time is midnight or 23:59:59 are
+date-only deadlines and become all-day events.
+"""
+
+from datetime import date, datetime, time, timedelta, timezone
+from typing import Any, Dict, Iterable, List
+
+PRODID = "-//Email Helper//Task Feed//EN"
+
+# Lines are folded at 75 octets (RFC 5545 section 3.1)
+MAX_LINE_OCTETS = 75
+
+_ALL_DAY_TIMES = (time(0, 0), time(23, 59, 59))
+
+
+def escape_text(value: str) -> str:
+    """Escape a TEXT property value."""
+    return (
+        value.replace("\\", "\\\\")
+        .replace(";", "\\;")
+        .replace(",", "\\,")
+        .replace("\r\n", "\\n")
+        .replace("\n", "\\n")
+    )
+
+
+def fold_line(line: str) -> str:
+    """Fold a content line into 75-octet chunks, never splitting a character."""
+    chunks: List[str] = []
+    current, size = "", 0
+    for char in line:
+        width = len(char.encode("utf-8"))
+        # Continuation lines start with a space, which counts toward the limit
+        limit = MAX_LINE_OCTETS if not chunks else MAX_LINE_OCTETS - 1
+        if size + width > limit:
+            chunks.append(current)
+            current, size = "", 0
+        current += char
+        size += width
+    chunks.append(current)
+    return "\r\n ".join(chunks)
+
+
+def format_datetime(value: datetime) -> str:
+    """Floating DATE-TIME value; aware times are converted to local time first."""
+    if value.tzinfo is not None:
+        value = value.astimezone().replace(tzinfo=None)
+    return value.strftime("%Y%m%dT%H%M%S")
+
+
+def format_utc(value: datetime) -> str:
+    """UTC DATE-TIME value, as DTSTAMP requires; naive times are local."""
+    return value.astimezone(timezone.utc).strftime("%Y%m%dT%H%M%SZ")
+
+
+def format_date(value: date) -> str:
+    """DATE value."""
+    return value.strftime("%Y%m%d")
+
+
+def is_all_day(value: datetime) -> bool:
+    """Whether a due date is a date-only deadline."""
+    return value.time().replace(microsecond=0) in _ALL_DAY_TIMES
+
+
+def render_event(event: Dict[str, Any]) -> List[str]:
+    """Content lines of one VEVENT.
+
+    Args:
+        event: uid, start (datetime), summary, stamp (datetime), and
+            optionally sequence, description, categories, priority, url
+
+    Returns:
+        Unfolded content lines from BEGIN to END
+    """
+    start: datetime = event["start"]
+    lines = [
+        "BEGIN:VEVENT",
+        f"UID:{event['uid']}",
+        f"DTSTAMP:{format_utc(event['stamp'])}",
+        f"SEQUENCE:{int(event.get('sequence') or 0)}",
+    ]
+    if is_all_day(start):
+        lines += [
+            f"DTSTART;VALUE=DATE:{format_date(start.date())}",
+            f"DTEND;VALUE=DATE:{format_date(start.date() + timedelta(days=1))}",
+        ]
+    else:
+        lines += [f"DTSTART:{format_datetime(start)}", f"DTEND:{format_datetime(start)}"]
+    lines.append(f"SUMMARY:{escape_text(event['summary'])}")
+    if event.get("description"):
+        lines.append(f"DESCRIPTION:{escape_text(event['description'])}")
+    if event.get("categories"):
+        lines.append("CATEGORIES:" + ",".join(escape_text(c) for c in event["categories"]))
+    if event.get("priority"):
+        lines.append(f"PRIORITY:{int(event['priority'])}")
+    if event.get("url"):
+        lines.append(f"URL:{event['url']}")
+    lines += ["TRANSP:TRANSPARENT", "END:VEVENT"]
+    return lines
+
+
+def render_calendar(events: Iterable[Dict[str, Any]], name: str) -> str:
+    """Render a VCALENDAR with CRLF line endings.
+
+    Args:
+        events: Event dicts as accepted by render_event, in output order
+        name: Calendar name shown by subscribing clients
+
+    Returns:
+        The iCalendar text
+    """
+    lines = [
+        "BEGIN:VCALENDAR",
+        "VERSION:2.0",
+        f"PRODID:{PRODID}",
+        "CALSCALE:GREGORIAN",
+        "METHOD:PUBLISH",
+        f"X-WR-CALNAME:{escape_text(name)}",
+    ]
+    for event in events:
+        lines += render_event(event)
+    lines.append("END:VCALENDAR")
+    return "".join(fold_line(line) + "\r\n" for line in lines)
@@ -0,0 +1,173 @@
+"""Link extraction for FastAPI Email Helper API.
+
+Pulls every http(s) URL out of an email body, whether it appears as an
+HTML anchor or as bare text, unwraps redirector links such as Outlook Safe
+Links, deduplicates them, and classifies each one by what it points at so
+tasks and work items can carry the links from their source email.
+"""
+
+import re
+from html.parser import HTMLParser
+from typing import Dict, List, Optional, Tuple
+from urllib.parse import urlsplit
+
+from backend.core.sanitize import looks_like_html, unwrap_link
+
+PULL_REQUEST = "pull_request"
+ADO_WORK_ITEM = "ado_work_item"
+SHAREPOINT_DOC = "sharepoint_doc"
+MEETING = "meeting"
+OTHER = "other"
+
+# (type, host pattern, path pattern), checked in order
+LINK_PATTERNS = (
+    (PULL_REQUEST, r"(^|\.)github\.com$", r"^/[^/]+/[^/]+/pull/\d+"),
+    (PULL_REQUEST, r"^dev\.azure\.com$", r"/_git/[^/]+/pullrequest/\d+"),
+    (PULL_REQUEST, r"\.visualstudio\.com$", r"/_git/[^/]+/pullrequest/\d+"),
+    (ADO_WORK_ITEM, r"^dev\.azure\.com$", r"/_workitems/edit/\d+"),
+    (ADO_WORK_ITEM, r"\.visualstudio\.com$", r"/_workitems/edit/\d+"),
+    (SHAREPOINT_DOC, r"\.sharepoint\.com$", r""),
+    (MEETING, r"^teams\.microsoft\.com$", r"^/l/meetup-join/"),
+    (MEETING, r"(^|\.)zoom\.us$", r"^/(j|my|w)/"),
+    (MEETING, r"^meet\.google\.com$", r"^/[a-z]+-[a-z]+-[a-z]+"),
+    (MEETING, r"\.webex\.com$", r""),
+)
+
+_COMPILED_PATTERNS = tuple(
+    (link_type, re.compile(host, re.IGNORECASE), re.compile(path, re.IGNORECASE))
+    for link_type, host, path in LINK_PATTERNS
+)
+
+# Bare URLs end at whitespace, quotes, or angle brackets
+_BARE_URL = re.compile(r"https?://[^\s<>\"'`]+", re.IGNORECASE)
+_TRAILING_PUNCTUATION = ".,;:!?'\"*"
+_CLOSING_BRACKETS = {")": "(", "]": "[", "}": "{"}
+
+
+def classify_link(url: str) -> str:
+    """Classify a URL as a pull request, ADO work item, SharePoint doc, meeting, or other."""
+    try:
+        parts = urlsplit(url)
+    except ValueError:
+        return OTHER
+    host = (parts.hostname or "").lower()
+    for link_type, host_pattern, path_pattern in _COMPILED_PATTERNS:
+        if host_pattern.search(host) and path_pattern.search(parts.path):
+            return link_type
+    return OTHER
+
+
+def trim_url(url: str) -> str:
+    """Strip sentence punctuation and unbalanced closing brackets from a bare URL."""
+    while url:
+        last = url[-1]
+        if last in _TRAILING_PUNCTUATION:
+            url = url[:-1]
+        elif last in _CLOSING_BRACKETS and url.count(last) > url.count(_CLOSING_BRACKETS[last]):
+            url = url[:-1]
+        else:
+            break
+    return url
+
+
+def _is_web_url(url: str) -> bool:
+    try:
+        parts = urlsplit(url)
+    except ValueError:
+        return False
+    return parts.scheme.lower() in ("http", "https") and bool(parts.hostname)
+
+
+def _text_urls(text: str) -> List[str]:
+    return [trim_url(match.group(0)) for match in _BARE_URL.finditer(text or "")]
+
+
+class _AnchorCollector(HTMLParser):
+    """Collect (url, anchor text) pairs from anchors and bare URLs in text."""
+
+    def __init__(self):
+        super().__init__(convert_charrefs=True)
+        self.found: List[Tuple[str, Optional[str]]] = []
+        self._href: Optional[str] = None
+        self._text: List[str] = []
+        self._skip_depth = 0
+
+    def handle_starttag(self, tag, attrs):
+        if tag in ("script", "style"):
+            self._skip_depth += 1
+        elif tag == "a":
+            self._close_anchor()
+            self._href = (dict(attrs).get("href") or "").strip()
+            self._text = []
+
+    def handle_endtag(self, tag):
+        if tag in ("script", "style"):
+            self._skip_depth = max(0, self._skip_depth - 1)
+        elif tag == "a":
+            self._close_anchor()
+
+    def handle_data(self, data):
+        if self._skip_depth:
+            return
+        if self._href is not None:
+            self._text.append(data)
+        else:
+            self.found.extend((url, None) for url in _text_urls(data))
+
+    def _close_anchor(self):
+        if self._href is None:
+            return
+        text = " ".join("".join(self._text).split()) or None
+        if self._href:
+            self.found.append((self._href, text))
+        elif text:
+            self.found.extend((url, None) for url in _text_urls(text))
+        self._href = None
+        self._text = []
+
+    def close(self):
+        super().close()
+        self._close_anchor()
+
+
+def extract_links(body: Optional[str]) -> List[Dict[str, Optional[str]]]:
+    """Extract, unwrap, deduplicate, and classify the links in an email body.
+
+    Args:
+        body: Plain-text or HTML email body
+
+    Returns:
+        Links in order of first appearance, each with url, type, and
+        anchor_text (the first non-empty anchor text seen for the URL)
+    """
+    if not body:
+        return []
+
+    if looks_like_html(body):
+        collector = _AnchorCollector()
+        collector.feed(body)
+        collector.close()
+        found = collector.found
+    else:
+        found = [(url, None) for url in _text_urls(body)]
+
+    links: Dict[str, Dict[str, Optional[str]]] = {}
+    for raw_url, anchor_text in found:
+        url = unwrap_link(raw_url)
+        if not _is_web_url(url):
+            continue
+        link = links.get(url)
+        if link is None:
+            links[url] = {"url": url, "type": classify_link(url), "anchor_text": anchor_text}
+        elif not link["anchor_text"] and anchor_text:
+            link["anchor_text"] = anchor_text
+    return list(links.values())
+
+
+def format_links_for_description(links: List[Dict[str, Optional[str]]]) -> str:
+    """Render links as a block to append to a task description."""
+    lines = ["Links:"]
+    for link in links:
+        label = link.get("anchor_text")
+        lines.append(f"- [{link['type']}] {label}: {link['url']}" if label else f"- [{link['type']}] {link['url']}")
+    return "\n".join(lines)
@@ -0,0 +1,67 @@
+"""Plain-text email previews for FastAPI Email Helper API.
+
+List views show the start of what the sender wrote: email_preview strips
+HTML, leaves out quoted replies and signatures, collapses whitespace, and
+keeps the first PREVIEW_LENGTH characters. Previews are stored with each
+email as it is saved, so they exist before any AI summary does.
+"""
+
+import re
+import unicodedata
+from typing import Optional
+
+from backend.core.sanitize import text_lines
+
+PREVIEW_LENGTH = 140
+
+# Header lines starting the quoted message being replied to or forwarded
+_QUOTE_HEADER = (
+    r"on .+ wrote:|-+ ?original message ?-+|-+ ?forwarded message ?-+|"
+    r"from: .+ sent: .+"
+)
+QUOTE_HEADER = re.compile(f"^(?:{_QUOTE_HEADER})$", re.IGNORECASE)
+
+# Lines where the sender's own text ends: the rest is a signature or the
+# quoted message being replied to
+_END_OF_MESSAGE = re.compile(
+    r"^(?:--|__+|"
+    r"sent from my \w+.*|get outlook for \w+.*|"
+    f"{_QUOTE_HEADER})$",
+    re.IGNORECASE
+)
+
+# HTML elements holding quoted text
+QUOTE_TAGS = frozenset({"blockquote"})
+
+
+def truncate_text(text: str, length: int) -> str:
+    """Cut text to at most length characters without splitting a character.
+
+    Combining marks stay with the character they modify, so a cut never
+    leaves a bare accent or separates one from its letter.
+    """
+    if len(text) <= length:
+        return text
+    end = length
+    while end > 0 and unicodedata.combining(text[end]):
+        end -= 1
+    return text[:end].rstrip()
+
+
+def email_preview(body: Optional[str], length: int = PREVIEW_LENGTH) -> str:
+    """Plain-text preview of the sender's own text in an email body.
+
+    Args:
+        body: Plain text or HTML body
+        length: Most characters to keep
+
+    Returns:
+        The preview, empty when the body has no text of its own
+    """
+    lines = []
+    for line in text_lines(body, drop_tags=QUOTE_TAGS):
+        if _END_OF_MESSAGE.match(line):
+            break
+        if not line.startswith(">"):
+            lines.append(line)
+    return truncate_text(" ".join(" ".join(lines).split()), length)
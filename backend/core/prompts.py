@@ -0,0 +1,211 @@
+"""Layered prompt templates for FastAPI Email Helper API.
+
+Templates come from two directories. The bundled layer is the install's
+prompts directory (settings.prompts_dir), which upgrades overwrite; the
+user layer is settings.user_prompts_dir, which nothing else writes to. A
+user template with the same file name as a bundled one replaces it, and one
+with a new name adds a template. A user copy that fails to parse is skipped
+with a warning and the bundled template stays in use, so a typo in an
+override cannot break every prompt of its kind.
+
+Both layers are read on first use and kept until reload(), which reads
+both again (POST /api/ai/templates/reload), so edited overrides take effect
+without a restart.
+
+A template's model parameters (temperature, max_tokens) come from its
+frontmatter; settings.ai_parameters overrides them per operation (the
+template name without .prompty) without editing the file. parameters()
+gives the effective values the AI processor runs a template with.
+"""
+
+import logging
+import re
+import threading
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Any, Dict, Optional, Tuple
+
+from backend.core.config import settings
+
+logger = logging.getLogger(__name__)
+
+# Layer a template is loaded from
+BUNDLED_LAYER = "bundled"
+USER_LAYER = "user"
+
+TEMPLATE_GLOB = "*.prompty"
+
+
+@dataclass
+class PromptTemplate:
+    """A .prompty file active for its name."""
+    name: str  # File name, e.g. "draft_reply.prompty"
+    path: Path
+    layer: str  # BUNDLED_LAYER or USER_LAYER
+    description: Optional[str] = None
+    version: Optional[str] = None
+    parameters: Dict[str, Any] = field(default_factory=dict)  # model.parameters of the frontmatter
+
+
+def _load_frontmatter(text: str) -> Dict:
+    """Parse frontmatter YAML (top-level "key: value" lines without PyYAML)."""
+    try:
+        import yaml
+    except ImportError:
+        return dict(re.findall(r"^(\w+):[ \t]*(.*)$", text, re.MULTILINE))
+    try:
+        frontmatter = yaml.safe_load(text)
+    except yaml.YAMLError as e:
+        raise ValueError(f"invalid frontmatter: {e}")
+    if not isinstance(frontmatter, dict):
+        raise ValueError("frontmatter is not a mapping")
+    return frontmatter
+
+
+def parse_template(path: Path, layer: str) -> PromptTemplate:
+    """Read and check a .prompty file.
+
+    Raises:
+        ValueError: The file has no --- frontmatter, the frontmatter is not
+            a YAML mapping, or no prompt follows it
+        OSError: The file cannot be read
+    """
+    text = path.read_text(encoding="utf-8")
+    parts = text.split("---", 2) if text.startswith("---") else []
+    if len(parts) < 3:
+        raise ValueError("missing --- frontmatter")
+    frontmatter = _load_frontmatter(parts[1])
+    if not parts[2].strip():
+        raise ValueError("no prompt after the frontmatter")
+    description = frontmatter.get("description")
+    # Read from the text: YAML would turn "version: 1.10" into 1.1
+    version = re.search(r"^version:\s*['\"]?([^'\"\s]+)", parts[1], re.MULTILINE)
+    model = frontmatter.get("model")
+    parameters = model.get("parameters") if isinstance(model, dict) else None
+    return PromptTemplate(
+        name=path.name,
+        path=path,
+        layer=layer,
+        description=str(description).strip().strip("\"'") if description else None,
+        version=version.group(1) if version else None,
+        parameters=dict(parameters) if isinstance(parameters, dict) else {}
+    )
+
+
+def parameter_overrides(name: str) -> Dict[str, Any]:
+    """settings.ai_parameters set for a template's operation (unset values left out)."""
+    overrides = settings.ai_parameters.get(Path(name).stem) or {}
+    return {key: value for key, value in overrides.items() if value is not None}
+
+
+def load_prompt_directory(
+    directory: Optional[Path],
+    layer: str
+) -> Tuple[Dict[str, PromptTemplate], Dict[str, str]]:
+    """Read every template of one layer.
+
+    Returns:
+        Templates by file name, and file name -> parse error of those
+        skipped (both empty if the directory does not exist)
+    """
+    templates: Dict[str, PromptTemplate] = {}
+    errors: Dict[str, str] = {}
+    if directory is None or not directory.is_dir():
+        return templates, errors
+    for path in sorted(directory.glob(TEMPLATE_GLOB)):
+        try:
+            templates[path.name] = parse_template(path, layer)
+        except (OSError, ValueError) as e:
+            errors[path.name] = str(e)
+    return templates, errors
+
+
+def load_layered(
+    bundled_dir: Path,
+    user_dir: Optional[Path] = None
+) -> Tuple[Dict[str, PromptTemplate], Dict[str, str]]:
+    """Read the bundled and user layers, user templates overriding bundled ones.
+
+    Returns:
+        Active templates by file name, and file name -> parse error of
+        user templates skipped (the bundled copy, if any, stays active)
+    """
+    templates, _ = load_prompt_directory(bundled_dir, BUNDLED_LAYER)
+    overrides, errors = load_prompt_directory(user_dir, USER_LAYER)
+    for name, error in errors.items():
+        fallback = "using the bundled template" if name in templates else "no bundled template to use instead"
+        logger.warning(f"Ignoring user prompt template {name} ({error}); {fallback}")
+    templates.update(overrides)
+    return dict(sorted(templates.items())), errors
+
+
+class PromptLibrary:
+    """Active prompt templates of both layers, read once until reloaded."""
+
+    def __init__(self, bundled_dir: Optional[Path] = None, user_dir: Optional[Path] = None):
+        """Initialize the library.
+
+        Args:
+            bundled_dir: Bundled layer (defaults to settings.get_prompts_dir()
+                at each load)
+            user_dir: User layer (defaults to settings.user_prompts_dir at
+                each load)
+        """
+        self._bundled_dir = bundled_dir
+        self._user_dir = user_dir
+        self._templates: Optional[Dict[str, PromptTemplate]] = None
+        self._errors: Dict[str, str] = {}
+        self._lock = threading.Lock()
+
+    @property
+    def bundled_dir(self) -> Path:
+        return self._bundled_dir or settings.get_prompts_dir()
+
+    @property
+    def user_dir(self) -> Optional[Path]:
+        if self._user_dir is not None:
+            return self._user_dir
+        return Path(settings.user_prompts_dir) if settings.user_prompts_dir else None
+
+    def _loaded(self) -> Dict[str, PromptTemplate]:
+        with self._lock:
+            if self._templates is None:
+                self._templates, self._errors = load_layered(self.bundled_dir, self.user_dir)
+            return self._templates
+
+    def templates(self) -> Dict[str, PromptTemplate]:
+        """Active templates by file name."""
+        return dict(self._loaded())
+
+    def errors(self) -> Dict[str, str]:
+        """User templates skipped at the last load, by file name, with why."""
+        self._loaded()
+        return dict(self._errors)
+
+    def get(self, name: str) -> Optional[PromptTemplate]:
+        """The active template of a file name."""
+        return self._loaded().get(name)
+
+    def path(self, name: str) -> Path:
+        """File to run for a template name.
+
+        A name without an active template (e.g. a bundled file that failed
+        to parse) resolves into the bundled directory as it always has.
+        """
+        template = self.get(name)
+        return template.path if template else self.bundled_dir / name
+
+    def parameters(self, name: str) -> Dict[str, Any]:
+        """Model parameters a template runs with: its own, with parameter_overrides applied."""
+        template = self.get(name)
+        return {**(template.parameters if template else {}), **parameter_overrides(name)}
+
+    def reload(self) -> Dict[str, PromptTemplate]:
+        """Read both layers again, picking up added, edited, and removed templates."""
+        with self._lock:
+            self._templates = None
+        return self.templates()
+
+
+# Global library used by the AI services
+prompt_library = PromptLibrary()
@@ -0,0 +1,101 @@
+"""Rights-managed email handling for FastAPI Email Helper API.
+
+Emails protected with Information Rights Management ("Do Not Forward" or a
+permission template) or encrypted with S/MIME reach the providers with a
+garbled or empty body, and their sender restricted who may read them, so
+their content must not be sent to Azure OpenAI. The providers pass each
+email through mark_protection, which sets is_protected from the Outlook
+MessageClass and Permission properties (or an unopened message.rpmsg
+attachment) and replaces the body of a protected email with PROTECTED_BODY.
+Stored emails keep is_protected, so paths reading the database see it too.
+
+Every AI path checks ai_allowed before sending an email to the model:
+batch classification and the processing pipeline report protected emails
+as skipped with PROTECTED_REASON rather than failed, and single-email AI
+endpoints refuse them with ProtectedContentError. settings.allow_protected_ai
+lets them through.
+"""
+
+from typing import Any, Dict, Iterable, Optional
+
+from backend.core.config import settings
+from backend.core.errors import ProtectedContentError
+
+PROTECTED_REASON = "protected content"
+
+PROTECTED_BODY = "[Protected message: the sender restricted this email with rights management]"
+
+# OlPermission values of MailItem.Permission; anything else restricts the email
+OL_UNRESTRICTED = 0
+
+# MessageClass of encrypted mail ("IPM.Note.SMIME.MultipartSigned" is signed only)
+PROTECTED_MESSAGE_CLASSES = frozenset({"ipm.note.smime", "ipm.note.secure"})
+PROTECTED_MESSAGE_CLASS_PREFIXES = ("ipm.note.rpmsg",)
+
+# Attachment carrying the encrypted body of an IRM email Outlook has not opened
+RPMSG_ATTACHMENT = "message.rpmsg"
+
+
+def is_protected_item(
+    message_class: Optional[str] = None,
+    permission: Optional[int] = None,
+    attachments: Iterable[Any] = ()
+) -> bool:
+    """Whether Outlook properties mark an email as rights-managed or encrypted.
+
+    Values of the wrong type (properties Outlook could not read) are ignored.
+
+    Args:
+        message_class: MailItem.MessageClass, e.g. "IPM.Note.rpmsg.Microsoft.Voicemail.UM"
+        permission: MailItem.Permission (OlPermission)
+        attachments: {"name"} dicts or attachment names
+    """
+    kind = message_class.strip().lower() if isinstance(message_class, str) else ""
+    if kind in PROTECTED_MESSAGE_CLASSES or kind.startswith(PROTECTED_MESSAGE_CLASS_PREFIXES):
+        return True
+    if isinstance(permission, int) and permission != OL_UNRESTRICTED:
+        return True
+    for attachment in attachments if isinstance(attachments, (list, tuple)) else ():
+        name = attachment.get("name") if isinstance(attachment, dict) else attachment
+        if str(name or "").strip().lower() == RPMSG_ATTACHMENT:
+            return True
+    return False
+
+
+def mark_protection(email: Optional[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+    """Set is_protected on a provider email and hide a protected email's body.
+
+    Reads the message_class, permission, and attachments keys the providers
+    fill in. While settings.allow_protected_ai is on, the body is kept as
+    the provider read it. The email is changed in place and returned.
+    """
+    if not email:
+        return email
+    email["is_protected"] = bool(email.get("is_protected")) or is_protected_item(
+        email.get("message_class"), email.get("permission"), email.get("attachments")
+    )
+    if email["is_protected"] and not settings.allow_protected_ai:
+        for field in ("body", "content"):
+            if field in email:
+                email[field] = PROTECTED_BODY
+        if email.get("html_body"):
+            email["html_body"] = ""
+    return email
+
+
+def ai_allowed(email: Optional[Dict[str, Any]]) -> bool:
+    """Whether an email's content may be sent to the AI."""
+    return not (email or {}).get("is_protected") or settings.allow_protected_ai
+
+
+def check_ai_allowed(email: Optional[Dict[str, Any]], email_id: Optional[str] = None):
+    """Refuse to send a protected email's content to the AI.
+
+    Raises:
+        ProtectedContentError: If the email is protected and settings.allow_protected_ai is off
+    """
+    if not ai_allowed(email):
+        raise ProtectedContentError(
+            f"Email {email_id or email.get('id')} has protected content and is not sent to the AI "
+            "(see allow_protected_ai)"
+        )
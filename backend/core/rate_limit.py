@@ -0,0 +1,110 @@
+"""In-memory request rate limiting for FastAPI Email Helper API.
+
+AI endpoints each cost an Azure OpenAI call, so they are limited per user
+with a sliding window. Endpoints that make one call per email (such as the
+summary backfill) charge the window per email with ``acquire`` instead of
+once per request. The limiter lives in process memory, which matches
+the single-process localhost deployment the API targets.
+"""
+
+import threading
+import time
+from collections import defaultdict, deque
+from typing import Deque, Dict, Optional
+
+from fastapi import status
+
+from backend.core.errors import APIError
+
+
+class RateLimiter:
+    """Sliding-window rate limiter keyed by caller.
+
+    Attributes:
+        max_requests (int): Requests allowed per window
+        window_seconds (float): Length of the window in seconds
+    """
+
+    def __init__(self, max_requests: int, window_seconds: float = 60.0):
+        self.max_requests = max_requests
+        self.window_seconds = window_seconds
+        self._hits: Dict[str, Deque[float]] = defaultdict(deque)
+        self._lock = threading.Lock()
+
+    def retry_after(self, key: str, now: Optional[float] = None) -> float:
+        """Record a request for ``key`` and return seconds to wait, or 0 if allowed."""
+        now = time.monotonic() if now is None else now
+
+        with self._lock:
+            hits = self._hits[key]
+            while hits and now - hits[0] >= self.window_seconds:
+                hits.popleft()
+
+            if len(hits) >= self.max_requests:
+                return self.window_seconds - (now - hits[0])
+
+            hits.append(now)
+            return 0.0
+
+    def remaining(self, key: str, now: Optional[float] = None) -> Optional[int]:
+        """Requests ``key`` can still make in the window, or None without a limit."""
+        if self.max_requests <= 0:
+            return None
+        now = time.monotonic() if now is None else now
+
+        with self._lock:
+            hits = self._hits[key]
+            while hits and now - hits[0] >= self.window_seconds:
+                hits.popleft()
+            return self.max_requests - len(hits)
+
+    def check(self, key: str) -> None:
+        """Record a request for ``key``, raising 429 if over the limit.
+
+        Raises:
+            APIError: With status 429 and a Retry-After header
+        """
+        if self.max_requests <= 0:
+            return
+
+        wait = self.retry_after(key)
+        if wait > 0:
+            raise self._limit_error(wait)
+
+    def acquire(self, key: str, count: int, now: Optional[float] = None) -> int:
+        """Record up to ``count`` requests for ``key`` and return how many fit in the window.
+
+        Raises:
+            APIError: With status 429 and a Retry-After header if count is
+                positive and none fit
+        """
+        if self.max_requests <= 0 or count <= 0:
+            return max(count, 0)
+        now = time.monotonic() if now is None else now
+
+        with self._lock:
+            hits = self._hits[key]
+            while hits and now - hits[0] >= self.window_seconds:
+                hits.popleft()
+
+            granted = min(count, self.max_requests - len(hits))
+            if granted <= 0:
+                raise self._limit_error(self.window_seconds - (now - hits[0]))
+            hits.extend([now] * granted)
+            return granted
+
+    def _limit_error(self, wait: float) -> APIError:
+        """429 for a caller over the limit, retryable after ``wait`` seconds."""
+        error = APIError(
+            status.HTTP_429_TOO_MANY_REQUESTS,
+            f"Rate limit exceeded: {self.max_requests} requests per "
+            f"{int(self.window_seconds)} seconds",
+            "rate_limited"
+        )
+        error.headers = {"Retry-After": str(max(1, int(wait + 0.999)))}
+        return error
+
+    def reset(self) -> None:
+        """Forget all recorded requests."""
+        with self._lock:
+            self._hits.clear()
@@ -0,0 +1,64 @@
+"""Read-only mode for FastAPI Email Helper API.
+
+With settings.read_only, nothing the API is asked to do may change the
+mailbox or the stored data, e.g. while demoing the tool on a real mailbox:
+
+- every POST, PUT, PATCH, or DELETE request is answered with 403 and the
+  read_only error code before it reaches its route, except the auth routes
+  (logging in does not change mail data) and READ_ONLY_EXEMPT_PATHS;
+- EmailService refuses provider changes and JobQueue refuses to start
+  pipelines with ReadOnlyError, so background jobs and code paths that
+  bypass the routes cannot make changes either;
+- WebSocket messages that act on pipelines are answered with an error.
+
+GET /api/config reports read_only so the UI can disable its actions.
+"""
+
+import logging
+import re
+
+from fastapi import FastAPI, Request, status
+
+from backend.core.config import settings
+from backend.core.errors import ReadOnlyError, error_response
+
+logger = logging.getLogger(__name__)
+
+MUTATING_METHODS = frozenset({"POST", "PUT", "PATCH", "DELETE"})
+
+# Paths whose mutating requests are allowed in read-only mode
+READ_ONLY_EXEMPT_PATHS = re.compile(r"^/(auth|health)(/|$)")
+
+READ_ONLY_MESSAGE = "The server is in read-only mode; changes are disabled"
+
+
+def is_read_only_blocked(method: str, path: str) -> bool:
+    """Whether read-only mode refuses a request (regardless of whether it is on)."""
+    return method.upper() in MUTATING_METHODS and not READ_ONLY_EXEMPT_PATHS.search(path)
+
+
+def check_writable(operation: str) -> None:
+    """Refuse a change while the server is in read-only mode.
+
+    Args:
+        operation: Name of the change, for the log and the error message
+
+    Raises:
+        ReadOnlyError: If settings.read_only is set
+    """
+    if settings.read_only:
+        logger.warning(f"Refused {operation} in read-only mode")
+        raise ReadOnlyError(f"{READ_ONLY_MESSAGE} ({operation})")
+
+
+def register_read_only_middleware(app: FastAPI) -> None:
+    """Install the middleware refusing mutating requests in read-only mode.
+
+    settings.read_only is read on every request, so the mode can be
+    switched without rebuilding the application.
+    """
+    @app.middleware("http")
+    async def read_only_middleware(request: Request, call_next):
+        if settings.read_only and is_read_only_blocked(request.method, request.url.path):
+            return error_response(request, status.HTTP_403_FORBIDDEN, ReadOnlyError.code, READ_ONLY_MESSAGE)
+        return await call_next(request)
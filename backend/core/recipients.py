@@ -0,0 +1,62 @@
+"""Email recipients for FastAPI Email Helper API.
+
+Providers list each email's To and Cc addresses under the to and cc keys
+(emails without them fall back to the single recipient address). They are
+stored as JSON arrays in the to_recipients and cc_recipients columns with
+their combined recipient_count, which the to_me filter of GET /api/emails
+uses to find mail sent to the user rather than to a crowd.
+"""
+
+import json
+from typing import Any, Dict, List, Optional, Tuple
+
+# Addresses named per line of a recipient summary before the rest are counted
+SUMMARY_ADDRESSES = 5
+
+
+def email_recipients(email: Dict[str, Any]) -> Tuple[List[str], List[str]]:
+    """To and Cc addresses of a provider email."""
+    to = email.get("to")
+    if to is None:
+        to = [email["recipient"]] if email.get("recipient") else []
+    return list(to), list(email.get("cc") or [])
+
+
+def recipient_columns(email: Dict[str, Any]) -> Tuple[str, str, int]:
+    """to_recipients, cc_recipients, and recipient_count values for storing an email."""
+    to, cc = email_recipients(email)
+    return json.dumps(to), json.dumps(cc), len(to) + len(cc)
+
+
+def decode_recipients(value: Optional[str]) -> List[str]:
+    """Addresses stored in a to_recipients or cc_recipients column."""
+    if not value:
+        return []
+    try:
+        addresses = json.loads(value)
+    except ValueError:
+        return []
+    return addresses if isinstance(addresses, list) else []
+
+
+def _summary_line(label: str, addresses: List[str]) -> str:
+    line = f"{label}: {', '.join(addresses[:SUMMARY_ADDRESSES])}"
+    if len(addresses) > SUMMARY_ADDRESSES:
+        line += f" (+{len(addresses) - SUMMARY_ADDRESSES} more)"
+    return line
+
+
+def recipient_summary(email: Dict[str, Any]) -> str:
+    """Recipient lines for a classification prompt, e.g. "To: a@x.com\\nRecipients: 1".
+
+    Returns:
+        The summary, empty when the email names no recipients
+    """
+    to, cc = email_recipients(email)
+    if not to and not cc:
+        return ""
+    lines = [_summary_line("To", to)] if to else []
+    if cc:
+        lines.append(_summary_line("Cc", cc))
+    lines.append(f"Recipients: {len(to) + len(cc)}")
+    return "\n".join(lines)
@@ -0,0 +1,125 @@
+"""Secret redaction for FastAPI Email Helper API.
+
+Emails sometimes carry credentials: access tokens, cloud keys, shared
+access signatures, passwords. Before email text goes to the AI model it is
+passed through redact_secrets, which replaces each secret-looking token
+with a typed placeholder such as [REDACTED:jwt], so the model still sees
+that something was there. Redaction runs on the copy sent to the model,
+after boilerplate is stripped and before the prompt is fitted to its token
+budget; stored emails are never changed.
+
+settings.redact_secrets turns redaction off, and settings.redaction_patterns
+adds regular expressions whose matches are masked as [REDACTED:custom].
+"""
+
+import logging
+import re
+from functools import lru_cache
+from typing import Any, Dict, List, Optional, Pattern, Sequence, Tuple
+
+from backend.core.config import settings
+
+logger = logging.getLogger(__name__)
+
+# Email fields that reach prompts
+REDACTED_FIELDS = ("subject", "body", "content")
+
+CUSTOM_TYPE = "custom"
+
+# Secret type -> pattern, most specific first; group 1, when present, is kept
+REDACTION_PATTERNS: Tuple[Tuple[str, Pattern], ...] = (
+    ("private_key", re.compile(
+        r"()-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----"
+    )),
+    ("jwt", re.compile(r"()\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+")),
+    ("aws_access_key", re.compile(r"()\b(?:AKIA|ASIA)[0-9A-Z]{16}\b")),
+    ("aws_secret_key", re.compile(r"(?i)(aws_secret_access_key[\"']?\s*[:=]\s*[\"']?)[A-Za-z0-9/+=]{40}")),
+    ("sas_signature", re.compile(r"(?i)(\bsig=)[A-Za-z0-9%+/=]{16,}")),
+    ("github_token", re.compile(r"()\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})")),
+    # Azure DevOps personal access tokens: 52 lowercase base32 characters
+    ("azure_devops_pat", re.compile(r"()\b(?=[a-z2-7]*[2-7])[a-z2-7]{52}\b")),
+    # password=anything; "password: x" only when x has a digit or symbol, so prose is left alone
+    ("password", re.compile(r"(?i)(\b(?:password|passwd|pwd|passphrase)\s*=\s*[\"']?)[^\s\"',;]+")),
+    ("password", re.compile(
+        r"(?i)(\b(?:password|passwd|pwd|passphrase)\s*:\s*[\"']?)"
+        r"(?=[^\s\"',;]*[0-9!@#$%^&*_+=~|\\/<>{}\[\]-])[^\s\"',;]{4,}"
+    )),
+    ("credential", re.compile(
+        r"(?i)(\b(?:api[_-]?key|client[_-]?secret|access[_-]?token|secret|token)[\"']?\s*[:=]\s*[\"']?)"
+        r"(?=[^\s\"',;]*\d)[^\s\"',;]{8,}"
+    )),
+    # Hex and base64 blobs need letters and digits, so long numbers and words are kept
+    ("hex", re.compile(r"()\b(?=[0-9a-fA-F]*[a-fA-F])(?=[0-9a-fA-F]*[0-9])[0-9a-fA-F]{32,}\b")),
+    ("base64", re.compile(
+        r"()(?<![A-Za-z0-9+/=_.:\-])(?=[A-Za-z0-9+/]*[0-9])(?=[A-Za-z0-9+/]*[a-z])(?=[A-Za-z0-9+/]*[A-Z])"
+        r"[A-Za-z0-9+/]{40,}={0,2}(?![A-Za-z0-9+/=_\-])"
+    )),
+)
+
+
+def placeholder(secret_type: str) -> str:
+    """Text a redacted secret is replaced with."""
+    return f"[REDACTED:{secret_type}]"
+
+
+@lru_cache(maxsize=8)
+def _custom_patterns(expressions: Tuple[str, ...]) -> Tuple[Pattern, ...]:
+    patterns = []
+    for expression in expressions:
+        try:
+            patterns.append(re.compile(expression))
+        except re.error as e:
+            logger.warning(f"Ignoring invalid redaction pattern {expression!r}: {e}")
+    return tuple(patterns)
+
+
+def redact_secrets(
+    text: Optional[str],
+    extra_patterns: Optional[Sequence[str]] = None,
+    force: bool = False
+) -> Tuple[Optional[str], int]:
+    """Mask secret-looking tokens in text bound for the AI model.
+
+    Args:
+        text: Text to redact
+        extra_patterns: Regular expressions masked as [REDACTED:custom]
+            (defaults to settings.redaction_patterns)
+        force: Mask even while settings.redact_secrets is off (for text
+            leaving the machine, such as training exports)
+
+    Returns:
+        The redacted text and the number of secrets masked (the text
+        unchanged and 0 while settings.redact_secrets is off, unless forced)
+    """
+    if not text or not (force or settings.redact_secrets):
+        return text, 0
+    total = 0
+    for secret_type, pattern in REDACTION_PATTERNS:
+        text, count = pattern.subn(lambda match: match.group(1) + placeholder(secret_type), text)
+        total += count
+    expressions = settings.redaction_patterns if extra_patterns is None else extra_patterns
+    for pattern in _custom_patterns(tuple(expressions)):
+        text, count = pattern.subn(placeholder(CUSTOM_TYPE), text)
+        total += count
+    return text, total
+
+
+def redact_email(email: Dict[str, Any]) -> Tuple[Dict[str, Any], int]:
+    """Copy of an email with secrets masked in its subject and body.
+
+    Returns:
+        The copy (the original is left as is) and the number of secrets masked
+    """
+    redacted = dict(email)
+    total = 0
+    for field in REDACTED_FIELDS:
+        if isinstance(redacted.get(field), str):
+            redacted[field], count = redact_secrets(redacted[field])
+            total += count
+    return redacted, total
+
+
+def redact_emails(emails: Sequence[Dict[str, Any]]) -> Tuple[List[Dict[str, Any]], int]:
+    """Redacted copies of emails, in order, and the total secrets masked."""
+    redacted = [redact_email(email) for email in emails]
+    return [email for email, _ in redacted], sum(count for _, count in redacted)
@@ -0,0 +1,437 @@
+"""HTML email body sanitization for FastAPI Email Helper API.
+
+Synced HTML bodies are rendered by the frontend, so they are reduced to an
+allowlist of formatting tags and attributes before they are stored and
+again before they are returned. Everything else is dropped: scripts,
+styles, event handlers, forms, and non-http(s) URLs. Tracking pixels (1x1
+images and images from known tracker domains) are removed, and links
+wrapped by common redirectors such as Outlook Safe Links are unwrapped to
+their real destination.
+
+Embedded images are referenced from the HTML by cid: URLs naming an
+inline attachment's Content-ID. With inline_email_id given, the sanitizer
+points them at GET /api/emails/{id}/inline/{cid} (inline_image_url)
+instead, so the frontend can load them; those relative URLs are the only
+ones it keeps.
+
+external_image_domains reports, before sanitizing, which remote hosts an
+HTML body would load images from, so a suspicious email can be judged
+before it is opened.
+"""
+
+import html
+import re
+from html.parser import HTMLParser
+from typing import Dict, List, Optional, Tuple
+from urllib.parse import parse_qs, quote, unquote, urlsplit
+
+ALLOWED_TAGS = frozenset({
+    "a", "abbr", "b", "blockquote", "br", "caption", "code", "col", "colgroup",
+    "div", "em", "font", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i", "img",
+    "li", "ol", "p", "pre", "s", "small", "span", "strong", "sub", "sup",
+    "table", "tbody", "td", "tfoot", "th", "thead", "tr", "u", "ul",
+})
+
+VOID_TAGS = frozenset({"br", "col", "hr", "img"})
+
+# Removed together with everything inside them
+DROP_CONTENT_TAGS = frozenset({
+    "applet", "embed", "frame", "frameset", "head", "iframe", "math", "noscript",
+    "object", "script", "style", "svg", "template", "title",
+})
+
+# Tags that start a new line of visible text
+BLOCK_TAGS = frozenset({
+    "blockquote", "br", "div", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "li", "p", "pre", "tr",
+})
+
+GLOBAL_ATTRS = frozenset({"dir", "lang", "title"})
+
+TAG_ATTRS = {
+    "a": frozenset({"href"}),
+    "col": frozenset({"span", "width"}),
+    "font": frozenset({"color"}),
+    "img": frozenset({"alt", "height", "src", "width"}),
+    "ol": frozenset({"start"}),
+    "table": frozenset({"border", "cellpadding", "cellspacing", "width"}),
+    "td": frozenset({"align", "colspan", "rowspan", "valign", "width"}),
+    "th": frozenset({"align", "colspan", "rowspan", "valign", "width"}),
+}
+
+# URL schemes allowed per URL attribute
+SAFE_SCHEMES = {
+    "href": frozenset({"http", "https", "mailto"}),
+    "src": frozenset({"http", "https", "cid"}),
+}
+
+# Hosts (and their subdomains) that serve open-tracking images
+TRACKER_DOMAINS = (
+    "mailtrack.io",
+    "list-manage.com",  # Mailchimp open tracking
+    "mandrillapp.com",
+    "sendgrid.net",
+    "track.hubspot.com",
+    "t.yesware.com",
+    "app.bananatag.com",
+    "mixmax.com",
+    "pixel.wp.com",
+    "open.convertkit-mail.com",
+    "trk.klclick.com",
+)
+
+# Link redirectors as (host suffix, path prefix, query parameter holding the target)
+REDIRECTORS = (
+    ("safelinks.protection.outlook.com", "", "url"),
+    ("google.com", "/url", "q"),
+    ("l.facebook.com", "/l.php", "u"),
+)
+
+# Redirectors can wrap each other; stop after this many layers
+MAX_UNWRAP_DEPTH = 3
+
+SAFE_LINK_REL = "noopener noreferrer nofollow"
+
+_HTML_HINT = re.compile(
+    r"<\s*/?\s*(html|body|head|div|p|br|table|span|img|a|font|style|script|iframe)\b",
+    re.IGNORECASE
+)
+_URL_NOISE = re.compile(r"[\x00-\x20\x7f]")
+_STYLE_DIMENSION = re.compile(r"(?:^|;)\s*(width|height)\s*:\s*(\d+(?:\.\d+)?)\s*(?:px)?\s*(?=;|$)", re.I)
+_STYLE_HIDDEN = re.compile(r"display\s*:\s*none|visibility\s*:\s*hidden", re.IGNORECASE)
+_CSS_URL = re.compile(r"url\(\s*['\"]?([^'\")\s]+)", re.IGNORECASE)
+# What inline_image_url produces
+_INLINE_URL = re.compile(r"^/api/emails/[^/?#]+/inline/[^/?#]+$")
+
+
+def looks_like_html(body: Optional[str]) -> bool:
+    """Heuristically decide whether a body is HTML rather than plain text."""
+    return isinstance(body, str) and bool(_HTML_HINT.search(body))
+
+
+def _host(url: str) -> str:
+    try:
+        return (urlsplit(url).hostname or "").lower()
+    except ValueError:
+        return ""
+
+
+def _host_matches(host: str, domain: str) -> bool:
+    return host == domain or host.endswith("." + domain)
+
+
+def unwrap_link(url: str) -> str:
+    """Return the real destination of a redirector-wrapped link.
+
+    Links that are not wrapped, or whose wrapped target is not http(s),
+    are returned unchanged.
+    """
+    for _ in range(MAX_UNWRAP_DEPTH):
+        try:
+            parts = urlsplit(url)
+        except ValueError:
+            return url
+        host = (parts.hostname or "").lower()
+
+        target = None
+        for domain, path_prefix, param in REDIRECTORS:
+            if _host_matches(host, domain) and parts.path.startswith(path_prefix):
+                values = parse_qs(parts.query).get(param)
+                target = values[0] if values else None
+                break
+
+        if not target or urlsplit(target).scheme.lower() not in ("http", "https"):
+            return url
+        url = target
+    return url
+
+
+def content_id(value: Optional[str]) -> str:
+    """Normalize a Content-ID or cid: URL for matching: "cid:Logo%4001" and "<Logo@01>" give "Logo@01"."""
+    value = (value or "").strip()
+    if value[:4].lower() == "cid:":
+        value = unquote(value[4:])
+    return value.strip().strip("<>").strip()
+
+
+def inline_image_url(email_id: str, cid: str) -> str:
+    """URL of the endpoint serving an email's inline attachment with this Content-ID."""
+    return f"/api/emails/{quote(email_id, safe='')}/inline/{quote(content_id(cid), safe='')}"
+
+
+def _safe_url(value: str, attr: str) -> Optional[str]:
+    """Return the URL if its scheme is allowed for the attribute, else None."""
+    url = _URL_NOISE.sub("", value or "")
+    if attr == "src" and _INLINE_URL.match(url):
+        return url
+    try:
+        scheme = urlsplit(url).scheme.lower()
+    except ValueError:
+        return None
+    if scheme not in SAFE_SCHEMES[attr]:
+        return None
+    return unwrap_link(url) if attr == "href" else url
+
+
+def _dimension(value: Optional[str]) -> Optional[float]:
+    if value is None:
+        return None
+    match = re.match(r"\s*(\d+(?:\.\d+)?)", value)
+    return float(match.group(1)) if match else None
+
+
+def is_tracking_pixel(attrs: Dict[str, Optional[str]]) -> bool:
+    """Whether an <img> is a tracking pixel.
+
+    Args:
+        attrs: The image's attributes (lowercase names)
+
+    Returns:
+        True for hidden images, images no larger than 1x1, and images
+        served from a known tracker domain
+    """
+    style = attrs.get("style") or ""
+    if _STYLE_HIDDEN.search(style):
+        return True
+
+    dimensions = {
+        "width": _dimension(attrs.get("width")),
+        "height": _dimension(attrs.get("height")),
+    }
+    for name, size in _STYLE_DIMENSION.findall(style):
+        dimensions[name.lower()] = float(size)
+    if all(size is not None and size <= 1 for size in dimensions.values()):
+        return True
+
+    host = _host(_URL_NOISE.sub("", attrs.get("src") or ""))
+    return any(_host_matches(host, domain) for domain in TRACKER_DOMAINS)
+
+
+class _Sanitizer(HTMLParser):
+    """Re-emit only allowlisted markup from an HTML document.
+
+    Args:
+        inline_email_id: Email whose inline attachment URLs replace cid: image sources
+    """
+
+    def __init__(self, inline_email_id: Optional[str] = None):
+        super().__init__(convert_charrefs=True)
+        self.inline_email_id = inline_email_id
+        self.out: List[str] = []
+        self.open_tags: List[str] = []
+        self.skip_depth = 0
+
+    def handle_starttag(self, tag: str, attrs: List[Tuple[str, Optional[str]]]):
+        self._start(tag, attrs, self_closing=False)
+
+    def handle_startendtag(self, tag: str, attrs: List[Tuple[str, Optional[str]]]):
+        self._start(tag, attrs, self_closing=True)
+
+    def _start(self, tag: str, attrs, self_closing: bool):
+        if self.skip_depth:
+            if tag in DROP_CONTENT_TAGS and not self_closing:
+                self.skip_depth += 1
+            return
+        if tag in DROP_CONTENT_TAGS:
+            if not self_closing:
+                self.skip_depth = 1
+            return
+        if tag not in ALLOWED_TAGS:
+            return
+
+        attr_map = {name.lower(): value for name, value in attrs}
+        if tag == "img" and (is_tracking_pixel(attr_map) or not attr_map.get("src")):
+            return
+
+        allowed = GLOBAL_ATTRS | TAG_ATTRS.get(tag, frozenset())
+        kept = []
+        for name, value in attr_map.items():
+            if name not in allowed:
+                continue
+            if name in SAFE_SCHEMES:
+                value = _safe_url(value, name)
+                if value is None:
+                    continue
+                if name == "src" and self.inline_email_id and value[:4].lower() == "cid:":
+                    if not content_id(value):
+                        continue
+                    value = inline_image_url(self.inline_email_id, value)
+            kept.append((name, value or ""))
+
+        if tag == "img" and not any(name == "src" for name, _ in kept):
+            return
+        if tag == "a" and any(name == "href" for name, _ in kept):
+            kept.append(("rel", SAFE_LINK_REL))
+
+        rendered = "".join(f' {name}="{html.escape(value, quote=True)}"' for name, value in kept)
+        self.out.append(f"<{tag}{rendered}>")
+        if tag not in VOID_TAGS:
+            self.open_tags.append(tag)
+
+    def handle_endtag(self, tag: str):
+        if self.skip_depth:
+            if tag in DROP_CONTENT_TAGS:
+                self.skip_depth -= 1
+            return
+        if tag not in self.open_tags:
+            return
+        # Close anything left open inside this element
+        while self.open_tags:
+            open_tag = self.open_tags.pop()
+            self.out.append(f"</{open_tag}>")
+            if open_tag == tag:
+                break
+
+    def handle_data(self, data: str):
+        if not self.skip_depth:
+            self.out.append(html.escape(data, quote=False))
+
+    def close(self) -> str:
+        super().close()
+        while self.open_tags:
+            self.out.append(f"</{self.open_tags.pop()}>")
+        return "".join(self.out)
+
+
+def sanitize_html(body: Optional[str], inline_email_id: Optional[str] = None) -> str:
+    """Reduce an HTML body to allowlisted, tracker-free markup.
+
+    Sanitizing is idempotent, so already-sanitized bodies can safely be
+    sanitized again on the read path.
+
+    Args:
+        body: Untrusted HTML
+        inline_email_id: Email the body belongs to; its cid: images are
+            rewritten to inline_image_url (None keeps them as cid:)
+
+    Returns:
+        Sanitized HTML
+    """
+    if not body:
+        return ""
+    parser = _Sanitizer(inline_email_id)
+    parser.feed(body)
+    return parser.close()
+
+
+def sanitize_body(body: Optional[str], inline_email_id: Optional[str] = None) -> Optional[str]:
+    """Sanitize a body if it is HTML; plain text is returned unchanged."""
+    return sanitize_html(body, inline_email_id) if looks_like_html(body) else body
+
+
+def rewrite_cid_references(body: Optional[str], email_id: str) -> Optional[str]:
+    """Sanitize a body, pointing its cid: images at the email's inline attachment endpoint."""
+    return sanitize_body(body, inline_email_id=email_id)
+
+
+class _TextExtractor(HTMLParser):
+    """Collect the visible text of an HTML document, one line per block.
+
+    Args:
+        drop_tags: Tags whose content is left out besides DROP_CONTENT_TAGS
+    """
+
+    def __init__(self, drop_tags: frozenset = frozenset()):
+        super().__init__(convert_charrefs=True)
+        self.parts: List[str] = []
+        self._drop_tags = DROP_CONTENT_TAGS | drop_tags
+        self._skip_depth = 0
+
+    def handle_starttag(self, tag: str, attrs: List[Tuple[str, Optional[str]]]):
+        if tag in self._drop_tags and tag not in VOID_TAGS:
+            self._skip_depth += 1
+        self.parts.append("\n" if tag in BLOCK_TAGS else " ")
+
+    def handle_endtag(self, tag: str):
+        if tag in self._drop_tags and self._skip_depth:
+            self._skip_depth -= 1
+        self.parts.append("\n" if tag in BLOCK_TAGS else " ")
+
+    def handle_data(self, data: str):
+        if not self._skip_depth:
+            self.parts.append(data)
+
+
+def text_lines(body: Optional[str], drop_tags: frozenset = frozenset()) -> List[str]:
+    """Visible text of a body as lines with whitespace collapsed; blank lines are kept.
+
+    Args:
+        body: Plain text or HTML body
+        drop_tags: HTML tags whose content is left out, e.g. blockquote
+    """
+    if not body:
+        return []
+    if looks_like_html(body):
+        extractor = _TextExtractor(drop_tags)
+        extractor.feed(body)
+        extractor.close()
+        body = "".join(extractor.parts)
+    return [" ".join(line.split()) for line in body.splitlines()]
+
+
+def plain_text(body: Optional[str]) -> str:
+    """Visible text of a body with whitespace collapsed; HTML tags are dropped."""
+    return " ".join(" ".join(text_lines(body)).split())
+
+
+class _ImageSourceCollector(HTMLParser):
+    """Collect every URL an HTML document would load as an image."""
+
+    def __init__(self):
+        super().__init__(convert_charrefs=True)
+        self.urls: List[str] = []
+        self._in_style = False
+
+    def handle_starttag(self, tag: str, attrs: List[Tuple[str, Optional[str]]]):
+        attr_map = {name.lower(): value or "" for name, value in attrs}
+        if tag == "style":
+            self._in_style = True
+        if tag == "img":
+            self.urls.append(attr_map.get("src", ""))
+            # srcset is "url descriptor, url descriptor"
+            self.urls.extend(
+                candidate.split()[0]
+                for candidate in attr_map.get("srcset", "").split(",")
+                if candidate.strip()
+            )
+        if "background" in attr_map:
+            self.urls.append(attr_map["background"])
+        self.urls.extend(_CSS_URL.findall(attr_map.get("style", "")))
+
+    def handle_endtag(self, tag: str):
+        if tag == "style":
+            self._in_style = False
+
+    def handle_data(self, data: str):
+        if self._in_style:
+            self.urls.extend(_CSS_URL.findall(data))
+
+
+def external_image_domains(body: Optional[str]) -> List[str]:
+    """List the remote hosts an HTML body loads images from.
+
+    Covers <img> src and srcset, background attributes, and CSS url()
+    references. Embedded (cid:) and inline (data:) images are not external.
+
+    Args:
+        body: Untrusted, unsanitized email body
+
+    Returns:
+        Sorted, deduplicated lowercase host names; empty for plain text
+    """
+    if not looks_like_html(body):
+        return []
+    collector = _ImageSourceCollector()
+    collector.feed(body)
+    collector.close()
+
+    domains = set()
+    for url in collector.urls:
+        url = _URL_NOISE.sub("", url)
+        try:
+            scheme = urlsplit(url).scheme.lower()
+        except ValueError:
+            continue
+        host = _host(url)
+        if scheme in ("http", "https") and host:
+            domains.add(host)
+    return sorted(domains)
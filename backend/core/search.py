@@ -0,0 +1,124 @@
+"""Free-text search over stored emails for FastAPI Email Helper API.
+
+GET /api/emails/search matches a query against the subject, content, and
+sender of stored emails, and the text of their notes, with LIKE (there is
+no full-text index). Each result carries search_meta explaining the match:
+
+    {"matched_fields": ["subject", "content"],
+     "snippet": "…the <em>budget</em> review is on Friday…"}
+
+Snippets are cut from the plain text of the best matching field, HTML
+escaped, and only then wrapped in highlight markers, so the markers are the
+only markup a snippet can contain.
+"""
+
+import html
+import re
+from typing import Any, Dict, List, Optional, Tuple
+
+from backend.core.email_filters import MAX_FILTER_LENGTH, like_pattern
+from backend.core.errors import InputValidationError
+from backend.core.sanitize import plain_text
+
+# Fields a query is matched against, in the order they are reported
+SEARCH_FIELDS = ("subject", "content", "sender", "notes")
+
+# Stored columns among SEARCH_FIELDS; notes are matched in the email_notes table
+_SEARCH_COLUMNS = ("subject", "content", "sender")
+_NOTES_MATCH = (
+    "EXISTS (SELECT 1 FROM email_notes WHERE email_notes.email_id = emails.id "
+    "AND email_notes.user_id = emails.user_id AND email_notes.text LIKE ? ESCAPE '\\')"
+)
+
+# Field a snippet is cut from, best first: the body gives the most context
+SNIPPET_FIELDS = ("content", "subject", "notes", "sender")
+
+HIGHLIGHT_START = "<em>"
+HIGHLIGHT_END = "</em>"
+ELLIPSIS = "…"
+
+# Characters of context kept on each side of the first match
+SNIPPET_CONTEXT = 60
+
+
+def validate_query(query: Optional[str]) -> str:
+    """Check a search query.
+
+    Raises:
+        InputValidationError: If the query is empty or too long
+    """
+    query = (query or "").strip()
+    if not query or len(query) > MAX_FILTER_LENGTH:
+        raise InputValidationError(f"Search query must be 1-{MAX_FILTER_LENGTH} characters")
+    return query
+
+
+def build_search_clause(query: str) -> Tuple[str, List[Any]]:
+    """WHERE fragment matching emails whose subject, content, sender, or a note contains query."""
+    matches = [f"{field} LIKE ? ESCAPE '\\'" for field in _SEARCH_COLUMNS] + [_NOTES_MATCH]
+    return f" AND ({' OR '.join(matches)})", [like_pattern(query)] * len(matches)
+
+
+def highlight_snippet(text: str, query: str, context: int = SNIPPET_CONTEXT) -> str:
+    """Cut an escaped excerpt around the first match with every match highlighted.
+
+    Args:
+        text: Plain text to excerpt
+        query: Text to highlight, matched case-insensitively
+        context: Characters kept on each side of the first match
+
+    Returns:
+        HTML-escaped excerpt, with ELLIPSIS where text was cut off
+    """
+    matches = list(re.finditer(re.escape(query), text, re.IGNORECASE))
+    if not matches:
+        start, end = 0, min(len(text), 2 * context)
+    else:
+        first = matches[0]
+        start = max(0, first.start() - context)
+        end = min(len(text), first.end() + context)
+        # Trim partial words at the cut edges
+        if start > 0:
+            space = text.find(" ", start, first.start())
+            start = space + 1 if space != -1 else start
+        if end < len(text):
+            space = text.rfind(" ", first.end(), end)
+            end = space if space != -1 else end
+
+    pieces = [ELLIPSIS] if start > 0 else []
+    position = start
+    for match in matches:
+        if match.start() < start or match.end() > end:
+            continue
+        pieces.append(html.escape(text[position:match.start()]))
+        pieces.append(HIGHLIGHT_START + html.escape(match.group()) + HIGHLIGHT_END)
+        position = match.end()
+    pieces.append(html.escape(text[position:end]))
+    if end < len(text):
+        pieces.append(ELLIPSIS)
+    return "".join(pieces)
+
+
+def search_meta(email: Dict[str, Any], query: str) -> Dict[str, Any]:
+    """Describe why a stored email matched a query.
+
+    Args:
+        email: Stored email row (before sanitizing for display), with the
+            text of its notes as notes
+        query: Query the email matched
+
+    Returns:
+        matched_fields, in SEARCH_FIELDS order, and the highlighted snippet
+    """
+    pattern = re.compile(re.escape(query), re.IGNORECASE)
+    matched_fields = [field for field in SEARCH_FIELDS if pattern.search(email.get(field) or "")]
+
+    texts = {field: plain_text(email.get(field)) for field in SNIPPET_FIELDS}
+    snippet_field = next(
+        (field for field in SNIPPET_FIELDS if pattern.search(texts[field])),
+        matched_fields[0] if matched_fields else "subject"
+    )
+    return {
+        "matched_fields": matched_fields,
+        "snippet": highlight_snippet(texts[snippet_field], query),
+    }
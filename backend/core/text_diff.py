@@ -0,0 +1,120 @@
+"""Word-level text diffs for FastAPI Email Helper API.
+
+An announcement re-sent "with corrections" is mostly the same text, so
+diff_words compares two plain-text bodies word by word and reports each
+inserted or deleted run of words with its character position in both
+texts, plus how similar the texts are. Texts longer than the byte limit
+are cut first, which bounds the memory and time one diff can take.
+"""
+
+import re
+from dataclasses import dataclass, field
+from difflib import SequenceMatcher
+from typing import List, Tuple
+
+INSERT = "insert"
+DELETE = "delete"
+
+DEFAULT_MAX_BYTES = 64 * 1024
+
+_WORD = re.compile(r"\S+")
+
+
+@dataclass
+class WordChange:
+    """A run of words deleted from the old text or inserted into the new one.
+
+    old_position and new_position are character offsets where the change
+    sits in each text; for a deletion new_position is where the words
+    would have been, and for an insertion old_position is where they go.
+    """
+    op: str
+    text: str
+    old_position: int
+    new_position: int
+
+
+@dataclass
+class TextDiff:
+    """Changes turning the old text into the new one."""
+    old_text: str
+    new_text: str
+    similarity: float  # Share of words the texts have in common, 0 to 1
+    changes: List[WordChange] = field(default_factory=list)
+    truncated: bool = False  # Either text was cut to max_bytes before diffing
+
+    @property
+    def insertions(self) -> int:
+        """Number of inserted words."""
+        return sum(len(change.text.split()) for change in self.changes if change.op == INSERT)
+
+    @property
+    def deletions(self) -> int:
+        """Number of deleted words."""
+        return sum(len(change.text.split()) for change in self.changes if change.op == DELETE)
+
+
+def truncate_bytes(text: str, max_bytes: int) -> Tuple[str, bool]:
+    """Cut text to at most max_bytes of UTF-8 without splitting a character.
+
+    Returns:
+        The text and whether it was cut
+    """
+    encoded = text.encode("utf-8")
+    if len(encoded) <= max_bytes:
+        return text, False
+    return encoded[:max_bytes].decode("utf-8", errors="ignore"), True
+
+
+def _words(text: str) -> List[Tuple[str, int, int]]:
+    """Each word of text with its start and end offsets."""
+    return [(match.group(), match.start(), match.end()) for match in _WORD.finditer(text)]
+
+
+def _span(text: str, words: List[Tuple[str, int, int]], start: int, end: int) -> str:
+    """Text from the start of words[start] to the end of words[end - 1]."""
+    return text[words[start][1]:words[end - 1][2]]
+
+
+def _position(text: str, words: List[Tuple[str, int, int]], index: int) -> int:
+    """Offset of words[index], or the end of text past the last word."""
+    return words[index][1] if index < len(words) else len(text)
+
+
+def diff_words(old: str, new: str, max_bytes: int = DEFAULT_MAX_BYTES) -> TextDiff:
+    """Diff two texts word by word.
+
+    Words are runs of non-whitespace, so changes in spacing alone are not
+    reported. A replaced run of words is reported as a deletion followed
+    by an insertion at the same positions.
+
+    Args:
+        old: Text before the changes
+        new: Text after the changes
+        max_bytes: Most UTF-8 bytes of each text to compare
+
+    Returns:
+        The compared (possibly cut) texts, their changes in order, and the
+        similarity (1.0 for identical texts, including two empty ones)
+    """
+    old, old_cut = truncate_bytes(old, max_bytes)
+    new, new_cut = truncate_bytes(new, max_bytes)
+    old_words, new_words = _words(old), _words(new)
+
+    # Without autojunk, common words like "the" still anchor the alignment in long texts
+    matcher = SequenceMatcher(None, [w[0] for w in old_words], [w[0] for w in new_words], autojunk=False)
+    changes = []
+    for tag, old_start, old_end, new_start, new_end in matcher.get_opcodes():
+        if tag in ("delete", "replace"):
+            changes.append(WordChange(
+                DELETE, _span(old, old_words, old_start, old_end),
+                _position(old, old_words, old_start), _position(new, new_words, new_start)
+            ))
+        if tag in ("insert", "replace"):
+            changes.append(WordChange(
+                INSERT, _span(new, new_words, new_start, new_end),
+                _position(old, old_words, old_start), _position(new, new_words, new_start)
+            ))
+
+    similarity = matcher.ratio() if old_words or new_words else 1.0
+    return TextDiff(old, new, round(similarity, 4), changes, truncated=old_cut or new_cut)
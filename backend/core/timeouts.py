@@ -0,0 +1,97 @@
+"""Per-route request time limits for FastAPI Email Helper API.
+
+Every HTTP request gets a time budget from ROUTE_TIMEOUTS, matched on its
+path (the first matching pattern wins, anything unmatched gets
+DEFAULT_TIMEOUT_SECONDS). A request still running when its budget is spent
+is answered with 504 in the standard error envelope and its handler is
+cancelled, so a stuck AI or Outlook call cannot hold a worker forever.
+
+The budget is also stored as a deadline on request.state, which
+cancel_on_disconnect watches: service calls made through it are cancelled
+at the deadline even where the server keeps the handler running after the
+504 is sent.
+
+WebSocket routes never pass through HTTP middleware, and a response whose
+headers are sent within budget is never cut off, so streams are exempt;
+patterns mapped to None exempt HTTP routes as well.
+"""
+
+import asyncio
+import logging
+import re
+from typing import Optional, Pattern, Sequence, Tuple
+
+from fastapi import FastAPI, Request, status
+
+from backend.core.errors import error_response
+
+logger = logging.getLogger(__name__)
+
+# (path pattern, seconds or None for no limit); the first match wins
+ROUTE_TIMEOUTS: Tuple[Tuple[str, Optional[float]], ...] = (
+    # Pipeline progress streams stay open while a pipeline runs
+    (r"^/api/processing/ws(/|$)", None),
+    # Batch work over many emails
+    (r"^/api/emails/(batch-process|reconcile|previews/backfill)$", 300),
+    (r"^/api/ai/(summarize/batch|summaries/backfill)$", 300),
+    # AI calls
+    (r"^/api/ai/", 120),
+    (r"^/api/emails/[^/]+/folder-suggestions$", 120),
+    # Outlook operations
+    (r"^/api/(emails|folders|conversations)(/|$)", 45),
+    (r"^/api/views/[^/]+/emails$", 45),
+)
+
+DEFAULT_TIMEOUT_SECONDS = 15
+
+
+def compile_timeouts(
+    timeouts: Sequence[Tuple[str, Optional[float]]]
+) -> Tuple[Tuple[Pattern, Optional[float]], ...]:
+    """Compile the path patterns of a timeout table."""
+    return tuple((re.compile(pattern), seconds) for pattern, seconds in timeouts)
+
+
+def route_timeout(
+    path: str,
+    timeouts: Sequence[Tuple[Pattern, Optional[float]]],
+    default_seconds: Optional[float] = DEFAULT_TIMEOUT_SECONDS
+) -> Optional[float]:
+    """Time budget of a request path, or None if it has no limit."""
+    for pattern, seconds in timeouts:
+        if pattern.search(path):
+            return seconds
+    return default_seconds
+
+
+def register_timeout_middleware(
+    app: FastAPI,
+    timeouts: Sequence[Tuple[str, Optional[float]]] = ROUTE_TIMEOUTS,
+    default_seconds: Optional[float] = DEFAULT_TIMEOUT_SECONDS
+) -> None:
+    """Install the request time limit middleware on an application.
+
+    Args:
+        app: FastAPI application to configure
+        timeouts: (path pattern, seconds) table, first match wins
+        default_seconds: Budget of paths no pattern matches (None for no limit)
+    """
+    compiled = compile_timeouts(timeouts)
+
+    @app.middleware("http")
+    async def timeout_middleware(request: Request, call_next):
+        budget = route_timeout(request.url.path, compiled, default_seconds)
+        if budget is None:
+            return await call_next(request)
+
+        request.state.deadline = asyncio.get_running_loop().time() + budget
+        try:
+            return await asyncio.wait_for(call_next(request), budget)
+        except asyncio.TimeoutError:
+            logger.warning(f"{request.method} {request.url.path} exceeded its {budget:g}s time limit")
+            return error_response(
+                request,
+                status.HTTP_504_GATEWAY_TIMEOUT,
+                "timeout",
+                f"Request did not finish within {budget:g} seconds"
+            )
@@ -4,16 +4,20 @@ This module provides database connection management using existing database
 patterns while integrating with FastAPI's dependency injection system.
 """
 
+import os
 import sqlite3
 import sys
 from contextlib import contextmanager
+from datetime import datetime
 from pathlib import Path
-from typing import Generator
+from typing import Generator, Optional
 
 # Add src to Python path to import existing database utilities
 sys.path.insert(0, str(Path(__file__).parent.parent.parent / "src"))
 
-from backend.core.config import get_database_path
+from backend.core.config import get_database_path, settings
+from backend.database.recovery import DatabaseRecovery, record_incident, recover_database
+from classification_categories import BUILT_IN_CATEGORIES
 
 try:
     from database.migrations import DatabaseMigrations
@@ -26,31 +30,180 @@ except ImportError:
             return True
 
 
+# Columns added to the emails table after its first release. They are
+# applied with ALTER TABLE on startup so existing databases keep working.
+EMAIL_COLUMNS = {
+    "folder": "TEXT DEFAULT 'Inbox'",
+    "conversation_id": "TEXT",
+    "is_read": "INTEGER DEFAULT 0",
+    "is_flagged": "INTEGER DEFAULT 0",
+    "needs_review": "INTEGER DEFAULT 0",
+    "ai_reasoning": "TEXT",
+    "one_line_summary": "TEXT",
+    # ID of the thread message whose classification this row copied
+    "inherited_from": "TEXT",
+    "awaiting_reply": "INTEGER DEFAULT 0",
+    "awaiting_reply_detected_at": "TIMESTAMP",
+    # Unsanitized HTML body, kept for forensics; content holds the sanitized body
+    "raw_content": "TEXT",
+    # Provider conversation_id of a row merged into another conversation
+    # ('' when the provider gave none); NULL for rows that were never merged
+    "original_conversation_id": "TEXT",
+    # When the AI last classified the row, and with which prompt and model
+    # (user corrections leave these alone)
+    "ai_processed_at": "TIMESTAMP",
+    "ai_prompt_version": "TEXT",
+    "ai_model": "TEXT",
+    # The AI's category and confidence, kept when a user corrects the row;
+    # the calibration report (GET /api/ai/calibration) compares them with category
+    "ai_category": "TEXT",
+    "ai_confidence": "REAL",
+    # The classifier's runner-up categories as a JSON array, best first (see
+    # services.category_suggestions); NULL when it gave none
+    "alternative_categories": "TEXT",
+    # Classifier's 1-5 importance prediction and its reason
+    "importance_score": "INTEGER",
+    "importance_justification": "TEXT",
+    # When a quarantined spam email becomes eligible for purging (NULL if not quarantined)
+    "quarantined_until": "TIMESTAMP",
+    # Bumped on every classification write; clients send it back with
+    # corrections so a change made since they read the row is not overwritten
+    "version": "INTEGER NOT NULL DEFAULT 0",
+    # Plain-text start of the sender's own text for list views (see core.preview);
+    # NULL until computed, backfilled by POST /api/emails/previews/backfill
+    "preview": "TEXT",
+    # To and Cc addresses as JSON arrays and their combined count (see core.recipients);
+    # NULL for emails stored before recipients were kept
+    "to_recipients": "TEXT",
+    "cc_recipients": "TEXT",
+    "recipient_count": "INTEGER",
+    # Attachment names and sizes as a JSON array with their totals (see core.attachments);
+    # NULL/0 for emails stored before attachment metadata was kept
+    "attachments": "TEXT",
+    "attachment_count": "INTEGER DEFAULT 0",
+    "attachment_bytes": "INTEGER DEFAULT 0",
+    # Rights-managed or encrypted (see core.protection); content holds the placeholder body
+    "is_protected": "INTEGER DEFAULT 0",
+}
+
+TASK_COLUMNS = {
+    # How the task was created; "auto" marks tasks from auto_create_tasks
+    "source": "TEXT",
+    # Bumped whenever the due date changes; the calendar feed's SEQUENCE
+    "due_date_sequence": "INTEGER NOT NULL DEFAULT 0",
+    # When the due-date reminder notification fired; cleared when the due date changes
+    "reminded_at": "TIMESTAMP",
+}
+
+AI_TRACE_COLUMNS = {
+    # JSON model parameters the call ran with (template values, settings.ai_parameters applied)
+    "parameters": "TEXT",
+}
+
+MOVE_BATCH_COLUMNS = {
+    # JSON task ID -> {"from": status, "to": status} of tasks the batch closed
+    "task_changes": "TEXT NOT NULL DEFAULT '{}'",
+}
+
+USER_SETTINGS_COLUMNS = {
+    # IANA time zone for "today" and due dates; NULL falls back to settings.timezone
+    "timezone": "TEXT",
+}
+
+EMAIL_INDEXES = {
+    # Covering index for the grouped sidebar counter query
+    "idx_emails_counters": "emails (user_id, category, folder, is_read, needs_review, is_flagged, awaiting_reply)",
+    "idx_emails_user_received": "emails (user_id, received_date)",
+    # Category listings read a page in received order without sorting the category
+    "idx_emails_user_category_received": "emails (user_id, category, received_date)",
+    "idx_emails_user_sender": "emails (user_id, sender)",
+    "idx_emails_user_conversation": "emails (user_id, conversation_id)",
+    "idx_classification_history_email": "classification_history (email_id, created_at)",
+    "idx_emails_user_ai_processed": "emails (user_id, ai_processed_at)",
+    "idx_outlook_activity_created": "outlook_activity (created_at)",
+    "idx_ai_traces_email": "ai_traces (email_id, created_at)",
+    "idx_ai_traces_created": "ai_traces (created_at)",
+    "idx_tasks_user_created": "tasks (user_id, created_at)",
+    "idx_tasks_user_due": "tasks (user_id, due_date)",
+    # Aging report's check for a completed task made from an email
+    "idx_tasks_email_status": "tasks (email_id, status)",
+    "idx_email_notes_email": "email_notes (user_id, email_id, created_at)",
+}
+
+
 class DatabaseManager:
-    """Database connection manager for FastAPI application."""
+    """Database store for the FastAPI application.
+    
+    Each instance owns its own SQLite database, so several stores can be
+    open in one process (e.g. a profile database alongside a backup being
+    verified). Pass ``":memory:"`` to get an isolated in-memory store, which
+    is what tests should use instead of touching the shared default.
+    
+    An existing database file that fails its integrity check is moved
+    aside before it is opened (see backend.database.recovery); recovery
+    then describes what happened, and degraded is true if the store
+    started empty instead of from a backup.
+    """
     
-    def __init__(self):
-        self.db_path = get_database_path()
+    MEMORY_PATH = ":memory:"
+    
+    def __init__(self, db_path: Optional[str] = None):
+        self.db_path = db_path or get_database_path()
+        self._memory_conn: Optional[sqlite3.Connection] = None
+        self.recovery: Optional[DatabaseRecovery] = None
+        
+        if self.is_memory:
+            # An in-memory database only lives as long as its connection,
+            # so keep a single shared connection for the store's lifetime
+            self._memory_conn = sqlite3.connect(self.MEMORY_PATH, check_same_thread=False)
+            self._memory_conn.row_factory = sqlite3.Row
+        
         self._ensure_database_exists()
     
+    @property
+    def is_memory(self) -> bool:
+        """Whether this store is backed by an in-memory database."""
+        return self.db_path == self.MEMORY_PATH
+    
+    @property
+    def degraded(self) -> bool:
+        """Whether a corrupted database was replaced by an empty one at startup."""
+        return self.recovery is not None and self.recovery.degraded
+    
     def _ensure_database_exists(self):
         """Ensure database exists and is properly initialized."""
-        db_dir = Path(self.db_path).parent
-        db_dir.mkdir(parents=True, exist_ok=True)
-        
-        # Apply migrations if available
-        try:
-            migrations = DatabaseMigrations(self.db_path)
-            migrations.apply_migrations()
-        except Exception as e:
-            print(f"Warning: Could not apply migrations: {e}")
+        if not self.is_memory:
+            db_dir = Path(self.db_path).parent
+            db_dir.mkdir(parents=True, exist_ok=True)
+            self.recovery = recover_database(self.db_path, settings.database_auto_restore)
+            
+            # Apply migrations if available
+            try:
+                migrations = DatabaseMigrations(self.db_path)
+                migrations.apply_migrations()
+            except Exception as e:
+                print(f"Warning: Could not apply migrations: {e}")
         
         # Always ensure our API tables exist
         self._create_basic_structure()
+        
+        if self.recovery is not None:
+            with self.get_connection() as conn:
+                record_incident(conn, self.recovery)
+                conn.commit()
+    
+    def _connect(self) -> sqlite3.Connection:
+        """Open a connection to this store's database."""
+        if self._memory_conn is not None:
+            return self._memory_conn
+        
+        conn = sqlite3.connect(self.db_path, check_same_thread=False)
+        conn.row_factory = sqlite3.Row  # Enable column access by name
+        return conn
     
     def _create_basic_structure(self):
         """Create basic database structure if migrations are not available."""
-        with sqlite3.connect(self.db_path, check_same_thread=False) as conn:
+        with self.get_connection() as conn:
             conn.execute('''
                 CREATE TABLE IF NOT EXISTS users (
                     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -95,36 +248,441 @@ class DatabaseManager:
                 )
             ''')
             
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS folder_profiles (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id TEXT NOT NULL,
+                    folder_path TEXT NOT NULL COLLATE NOCASE,
+                    default_stages TEXT NOT NULL,
+                    default_deployment TEXT,
+                    auto_apply_to_outlook INTEGER DEFAULT 0,
+                    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    UNIQUE (user_id, folder_path)
+                )
+            ''')
+            
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS saved_views (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id INTEGER NOT NULL,
+                    name TEXT NOT NULL,
+                    filters TEXT NOT NULL,
+                    sort TEXT NOT NULL,
+                    icon TEXT,
+                    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+            
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS classification_history (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    email_id TEXT NOT NULL,
+                    user_id INTEGER,
+                    previous_category TEXT,
+                    category TEXT NOT NULL,
+                    source TEXT NOT NULL,
+                    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    FOREIGN KEY (email_id) REFERENCES emails (id)
+                )
+            ''')
+            
+            # One row per mailbox change made through EmailService
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS outlook_activity (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    operation TEXT NOT NULL,
+                    email_id TEXT,
+                    parameters TEXT NOT NULL,
+                    result TEXT NOT NULL,
+                    error TEXT,
+                    duration_ms REAL NOT NULL,
+                    request_id TEXT,
+                    created_at TIMESTAMP NOT NULL
+                )
+            ''')
+            
+            # One row per prompt run while AI debug tracing was on (see services.ai_traces)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS ai_traces (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    email_id TEXT NOT NULL,
+                    user_id TEXT,
+                    operation TEXT NOT NULL,
+                    prompt_version TEXT NOT NULL,
+                    model TEXT,
+                    inputs TEXT NOT NULL,
+                    rendered_prompt TEXT,
+                    raw_response TEXT,
+                    error TEXT,
+                    duration_ms REAL NOT NULL,
+                    request_id TEXT,
+                    created_at TIMESTAMP NOT NULL
+                )
+            ''')
+            
+            # One row per applied POST /api/admin/categories/migrate
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS category_migrations (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id INTEGER NOT NULL,
+                    from_category TEXT NOT NULL,
+                    to_category TEXT NOT NULL,
+                    affected TEXT NOT NULL,
+                    update_outlook INTEGER DEFAULT 0,
+                    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+            
+            # Per-user settings; the calendar feed token is stored hashed
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS user_settings (
+                    user_id INTEGER PRIMARY KEY,
+                    feed_token_hash TEXT UNIQUE,
+                    feed_token_created_at TIMESTAMP,
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+            
+            # Classification categories rendered into the classifier prompt;
+            # built-in rows are seeded below and can be edited but not deleted
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS categories (
+                    name TEXT PRIMARY KEY,
+                    description TEXT NOT NULL,
+                    priority TEXT NOT NULL DEFAULT 'low',
+                    folder TEXT,
+                    built_in INTEGER DEFAULT 0,
+                    sort_order INTEGER DEFAULT 0,
+                    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                )
+            ''')
+            conn.executemany(
+                """
+                INSERT OR IGNORE INTO categories (name, description, priority, folder, built_in, sort_order)
+                VALUES (?, ?, ?, ?, 1, ?)
+                """,
+                [
+                    (category["name"], category["description"], category["priority"], category["folder"], order)
+                    for order, category in enumerate(BUILT_IN_CATEGORIES)
+                ]
+            )
+
+            # Signatures and disclaimers learned per sender (backend.services.boilerplate);
+            # dismissed blocks are kept so they are not learned again
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS sender_boilerplate (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id INTEGER NOT NULL,
+                    sender TEXT NOT NULL,
+                    block_hash TEXT NOT NULL,
+                    block TEXT NOT NULL,
+                    line_count INTEGER NOT NULL,
+                    email_count INTEGER NOT NULL,
+                    dismissed INTEGER DEFAULT 0,
+                    learned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    UNIQUE (user_id, sender, block_hash),
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+
+            # Conversations the user muted; their emails are classified without the AI
+            # and left out of listings and counters (see EmailService.mute_conversation)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS muted_conversations (
+                    user_id INTEGER NOT NULL,
+                    conversation_id TEXT NOT NULL,
+                    muted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    PRIMARY KEY (user_id, conversation_id),
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+
+            # One row per user and local day (YYYY-MM-DD), updated whenever inbox
+            # progress is computed, so streaks survive restarts (see EmailService.get_inbox_progress)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS inbox_progress (
+                    user_id INTEGER NOT NULL,
+                    day TEXT NOT NULL,
+                    classified_count INTEGER NOT NULL DEFAULT 0,
+                    applied_count INTEGER NOT NULL DEFAULT 0,
+                    tasks_completed INTEGER NOT NULL DEFAULT 0,
+                    unprocessed_count INTEGER NOT NULL DEFAULT 0,
+                    reached_zero INTEGER NOT NULL DEFAULT 0,
+                    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                    PRIMARY KEY (user_id, day),
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+
+            # Mailbox changes queued while Outlook was unavailable (see services.outbox)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS outbox (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    operation TEXT NOT NULL,
+                    email_id TEXT NOT NULL,
+                    parameters TEXT NOT NULL,
+                    status TEXT NOT NULL DEFAULT 'pending',
+                    attempts INTEGER NOT NULL DEFAULT 0,
+                    next_retry_at TIMESTAMP NOT NULL,
+                    last_error TEXT,
+                    created_at TIMESTAMP NOT NULL,
+                    updated_at TIMESTAMP NOT NULL
+                )
+            ''')
+
+            # What the mailbox owner has sent each recipient, counted from Sent Items (see services.contacts)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS contacts (
+                    address TEXT PRIMARY KEY,
+                    display_name TEXT,
+                    sent_count INTEGER NOT NULL DEFAULT 0,
+                    first_contact_at TIMESTAMP,
+                    last_contact_at TIMESTAMP,
+                    updated_at TIMESTAMP NOT NULL
+                )
+            ''')
+
+            # Position reached by each incremental provider scan, e.g. the newest sent email counted
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS sync_watermarks (
+                    source TEXT PRIMARY KEY,
+                    watermark TIMESTAMP,
+                    updated_at TIMESTAMP NOT NULL
+                )
+            ''')
+
+            # Emails moved together, with the folder each came from, so the
+            # move can be reverted (see services.move_batches)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS move_batches (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id INTEGER NOT NULL,
+                    operation TEXT NOT NULL,
+                    destination_folder TEXT NOT NULL,
+                    moves TEXT NOT NULL,
+                    created_at TIMESTAMP NOT NULL,
+                    reverted_at TIMESTAMP,
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+
+            # Holistic inbox analysis runs, kept so their cleanups can be applied
+            # later (see services.holistic_runs); email_ids and analysis are JSON
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS holistic_analyses (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id INTEGER NOT NULL,
+                    email_ids TEXT NOT NULL,
+                    analysis TEXT NOT NULL,
+                    created_at TIMESTAMP NOT NULL,
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+
+            # Concrete deadlines of the actions holistic analysis found, one per
+            # canonical email; sequence counts deadline changes (see services.holistic_runs)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS holistic_deadlines (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id INTEGER NOT NULL,
+                    email_id TEXT NOT NULL,
+                    topic TEXT,
+                    priority TEXT,
+                    deadline TEXT NOT NULL,
+                    sequence INTEGER NOT NULL DEFAULT 0,
+                    analysis_id INTEGER NOT NULL,
+                    updated_at TIMESTAMP NOT NULL,
+                    UNIQUE (user_id, email_id),
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+
+            # Users' free-text notes on their stored emails (see services.email_notes)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS email_notes (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    email_id TEXT NOT NULL,
+                    user_id INTEGER NOT NULL,
+                    text TEXT NOT NULL,
+                    created_at TIMESTAMP NOT NULL,
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+
+            # Actions holistic analysis flagged as blocking others, one per
+            # canonical email (see services.blocking_items); related_email_ids is JSON
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS blocking_items (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id INTEGER NOT NULL,
+                    email_id TEXT NOT NULL,
+                    topic TEXT,
+                    action_type TEXT,
+                    priority TEXT,
+                    deadline TEXT,
+                    why_relevant TEXT,
+                    related_email_ids TEXT NOT NULL DEFAULT '[]',
+                    first_seen_at TIMESTAMP NOT NULL,
+                    last_seen_at TIMESTAMP NOT NULL,
+                    resolved_at TIMESTAMP,
+                    dismissed_at TIMESTAMP,
+                    dismiss_reason TEXT,
+                    dismiss_note TEXT,
+                    UNIQUE (user_id, email_id),
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+
+            # Named copies of a user's classifications to compare against
+            # later (see services.classification_snapshots)
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS classification_snapshots (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    user_id INTEGER NOT NULL,
+                    name TEXT NOT NULL,
+                    email_count INTEGER NOT NULL,
+                    created_at TIMESTAMP NOT NULL,
+                    UNIQUE (user_id, name),
+                    FOREIGN KEY (user_id) REFERENCES users (id)
+                )
+            ''')
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS classification_snapshot_rows (
+                    snapshot_id INTEGER NOT NULL,
+                    email_id TEXT NOT NULL,
+                    ai_category TEXT,
+                    category TEXT,
+                    confidence REAL,
+                    folder TEXT,
+                    PRIMARY KEY (snapshot_id, email_id),
+                    FOREIGN KEY (snapshot_id) REFERENCES classification_snapshots (id)
+                )
+            ''')
+
+            # Operational events worth keeping, e.g. a corrupted database
+            # replaced at startup (see database.recovery); details is JSON
+            conn.execute('''
+                CREATE TABLE IF NOT EXISTS incidents (
+                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    kind TEXT NOT NULL,
+                    message TEXT NOT NULL,
+                    details TEXT,
+                    created_at TIMESTAMP NOT NULL
+                )
+            ''')
+
+            self._add_missing_columns(conn, "emails", EMAIL_COLUMNS)
+            self._add_missing_columns(conn, "tasks", TASK_COLUMNS)
+            self._add_missing_columns(conn, "user_settings", USER_SETTINGS_COLUMNS)
+            self._add_missing_columns(conn, "ai_traces", AI_TRACE_COLUMNS)
+            self._add_missing_columns(conn, "move_batches", MOVE_BATCH_COLUMNS)
+            for name, target in EMAIL_INDEXES.items():
+                conn.execute(f"CREATE INDEX IF NOT EXISTS {name} ON {target}")
+            
             conn.commit()
             print("📋 Basic database structure created")
     
+    def _add_missing_columns(self, conn: sqlite3.Connection, table: str, columns: dict):
+        """Add any columns missing from an existing table."""
+        existing = {row["name"] for row in conn.execute(f"PRAGMA table_info({table})")}
+        for name, definition in columns.items():
+            if name not in existing:
+                conn.execute(f"ALTER TABLE {table} ADD COLUMN {name} {definition}")
+    
     @contextmanager
     def get_connection(self) -> Generator[sqlite3.Connection, None, None]:
         """Get database connection context manager."""
-        conn = sqlite3.connect(self.db_path, check_same_thread=False)
-        conn.row_factory = sqlite3.Row  # Enable column access by name
+        conn = self._connect()
         try:
             yield conn
         finally:
-            conn.close()
+            if conn is not self._memory_conn:
+                conn.close()
     
     def get_connection_sync(self) -> sqlite3.Connection:
         """Get synchronous database connection."""
-        conn = sqlite3.connect(self.db_path, check_same_thread=False)
-        conn.row_factory = sqlite3.Row
-        return conn
+        return self._connect()
+    
+    def file_size(self) -> int:
+        """Size of the database file in bytes (0 for in-memory or not yet created stores)."""
+        if self.is_memory:
+            return 0
+        try:
+            return os.stat(self.db_path).st_size
+        except OSError:
+            return 0
+    
+    def backup(self, path: Optional[str] = None) -> str:
+        """Copy the database with SQLite's online backup.
+        
+        Args:
+            path: Where to write the copy (defaults to
+                ``<db>.backup_<timestamp>``, the name the migrations use, so
+                settings.database_auto_restore can restore it)
+        
+        Returns:
+            Path of the copy
+        
+        Raises:
+            ValueError: If no path is given for an in-memory store
+        """
+        if path is None:
+            if self.is_memory:
+                raise ValueError("An in-memory database needs a backup path")
+            path = f"{self.db_path}.backup_{datetime.now().strftime('%Y%m%d_%H%M%S')}"
+        target = sqlite3.connect(path)
+        try:
+            with self.get_connection() as conn:
+                conn.backup(target)
+        finally:
+            target.close()
+        return path
+    
+    def close(self):
+        """Release the store's shared connection, if it holds one."""
+        if self._memory_conn is not None:
+            self._memory_conn.close()
+            self._memory_conn = None
+
+
+# Default store used by the package-level helpers below. It is created
+# lazily so importing this module never touches the filesystem.
+_default_manager: Optional[DatabaseManager] = None
+
+
+def get_default_manager() -> DatabaseManager:
+    """Get the process-wide default database store, creating it on first use."""
+    global _default_manager
+    
+    if _default_manager is None:
+        _default_manager = DatabaseManager()
+    return _default_manager
+
+
+def set_default_manager(manager: Optional[DatabaseManager]) -> None:
+    """Replace the default database store (``None`` recreates it lazily)."""
+    global _default_manager
+    _default_manager = manager
 
 
-# Global database manager instance
-db_manager = DatabaseManager()
+def __getattr__(name: str):
+    """Keep ``db_manager`` importable while callers move to explicit stores."""
+    if name == "db_manager":
+        return get_default_manager()
+    raise AttributeError(f"module {__name__!r} has no attribute {name!r}")
 
 
 def get_database() -> Generator[sqlite3.Connection, None, None]:
     """FastAPI dependency for database connection."""
-    with db_manager.get_connection() as conn:
+    with get_default_manager().get_connection() as conn:
         yield conn
 
 
 def get_database_manager() -> DatabaseManager:
     """FastAPI dependency for database manager."""
-    return db_manager
\ No newline at end of file
+    return get_default_manager()
@@ -0,0 +1,152 @@
+"""Startup recovery of a corrupted SQLite database for FastAPI Email Helper API.
+
+DatabaseManager runs ``PRAGMA integrity_check`` on an existing database
+file before opening it. When the check fails, or the file is not a
+database at all, the file and its -wal/-shm sidecars are renamed aside to
+``<db>.corrupt_<timestamp>`` and the server starts anyway:
+
+- with settings.database_auto_restore, the newest backup the migrations
+  wrote (``<db>.backup_<timestamp>``) that passes the check is copied in;
+- otherwise, or when no backup is intact, an empty schema is created and
+  the store runs degraded: /health and GET /api/config report the
+  recovery and where the damaged file was kept so nothing is lost.
+
+Every step is logged, and the recovery is recorded in the incidents table
+of the database the server ends up using.
+"""
+
+import glob
+import json
+import logging
+import os
+import shutil
+import sqlite3
+from dataclasses import asdict, dataclass
+from datetime import datetime
+from pathlib import Path
+from typing import Any, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+INCIDENT_DATABASE_CORRUPTED = "database_corrupted"
+
+# Files SQLite keeps next to a database in WAL mode
+_SIDECAR_SUFFIXES = ("-wal", "-shm")
+
+
+@dataclass
+class DatabaseRecovery:
+    """What happened when a corrupted database was replaced at startup."""
+    detected_at: str
+    problem: str  # First line of the integrity check, or the error opening the file
+    corrupt_path: str  # Where the damaged file was moved
+    restored_from: Optional[str] = None  # Backup copied in, or None for a fresh schema
+
+    @property
+    def degraded(self) -> bool:
+        """Whether the store started empty instead of from a backup."""
+        return self.restored_from is None
+
+    @property
+    def banner(self) -> str:
+        """Message for the UI about the recovery."""
+        if self.degraded:
+            return (
+                f"The database was corrupted and the app started with an empty one. "
+                f"The damaged file was kept at {self.corrupt_path}."
+            )
+        return (
+            f"The database was corrupted and was restored from the backup {self.restored_from}. "
+            f"The damaged file was kept at {self.corrupt_path}."
+        )
+
+    def to_dict(self) -> Dict[str, Any]:
+        """The recovery as reported by /health and GET /api/config."""
+        return {**asdict(self), "degraded": self.degraded}
+
+
+def check_integrity(db_path: str) -> Optional[str]:
+    """Run PRAGMA integrity_check on a database file.
+
+    The file is opened read-only, so SQLite does not touch it or its
+    sidecars while checking.
+
+    Returns:
+        None if the database is intact, else the first problem reported
+    """
+    try:
+        conn = sqlite3.connect(f"{Path(db_path).resolve().as_uri()}?mode=ro", uri=True)
+        try:
+            rows = conn.execute("PRAGMA integrity_check").fetchall()
+        finally:
+            conn.close()
+    except sqlite3.DatabaseError as e:
+        return str(e)
+    if rows and rows[0][0] == "ok":
+        return None
+    return rows[0][0] if rows else "integrity check returned nothing"
+
+
+def latest_backup(db_path: str) -> Optional[str]:
+    """Newest <db>.backup_<timestamp> file that passes the integrity check, or None."""
+    # The timestamps (YYYYmmdd_HHMMSS) sort in time order
+    for candidate in sorted(glob.glob(f"{glob.escape(db_path)}.backup_*"), reverse=True):
+        problem = check_integrity(candidate)
+        if problem is None:
+            return candidate
+        logger.warning(f"Skipping damaged database backup {candidate}: {problem}")
+    return None
+
+
+def recover_database(
+    db_path: str,
+    restore_from_backup: bool = False,
+    now: Optional[datetime] = None
+) -> Optional[DatabaseRecovery]:
+    """Move a corrupted database aside, restoring a backup in its place if asked.
+
+    Does nothing to a missing or intact file.
+
+    Args:
+        db_path: Database file to check
+        restore_from_backup: Copy in the newest intact backup (settings.database_auto_restore)
+        now: Time of the recovery (defaults to now)
+
+    Returns:
+        The recovery, or None if the database was intact
+    """
+    if not os.path.exists(db_path):
+        return None
+    problem = check_integrity(db_path)
+    if problem is None:
+        return None
+
+    now = now or datetime.now()
+    logger.error(f"Database {db_path} failed its integrity check: {problem}")
+    corrupt_path = f"{db_path}.corrupt_{now.strftime('%Y%m%d_%H%M%S')}"
+    os.replace(db_path, corrupt_path)
+    for suffix in _SIDECAR_SUFFIXES:
+        if os.path.exists(db_path + suffix):
+            os.replace(db_path + suffix, corrupt_path + suffix)
+    logger.warning(f"Moved the corrupted database to {corrupt_path}")
+
+    recovery = DatabaseRecovery(detected_at=now.isoformat(), problem=problem, corrupt_path=corrupt_path)
+    if restore_from_backup:
+        backup = latest_backup(db_path)
+        if backup is not None:
+            shutil.copyfile(backup, db_path)
+            recovery.restored_from = backup
+            logger.warning(f"Restored the database from {backup}")
+        else:
+            logger.error(f"No intact backup of {db_path} to restore")
+    if recovery.degraded:
+        logger.error("Starting with an empty database in degraded mode")
+    return recovery
+
+
+def record_incident(conn: sqlite3.Connection, recovery: DatabaseRecovery) -> None:
+    """Add a recovery to the incidents table; the caller commits."""
+    conn.execute(
+        "INSERT INTO incidents (kind, message, details, created_at) VALUES (?, ?, ?, ?)",
+        (INCIDENT_DATABASE_CORRUPTED, recovery.banner, json.dumps(recovery.to_dict()), recovery.detected_at)
+    )
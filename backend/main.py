@@ -10,13 +10,21 @@ from contextlib import asynccontextmanager
 
 from fastapi import FastAPI, HTTPException
 from fastapi.middleware.cors import CORSMiddleware
-from fastapi.responses import JSONResponse
 
 # Add src to Python path for existing service imports
 sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
 
 from backend.core.config import settings
-from backend.database.connection import db_manager
+from backend.core.errors import register_error_handlers
+from backend.core.read_only import register_read_only_middleware
+from backend.core.timeouts import register_timeout_middleware
+from backend.database.connection import get_default_manager
+from backend.services.ai_health import ai_health
+from backend.services.ai_queue import ai_request_queue
+from backend.services.ai_traces import register_ai_trace_middleware
+from backend.services.outbox import Outbox
+from backend.services.provider_fallback import provider_degraded
+from backend.services.scheduler import ScheduledJob, get_job_scheduler
 from backend.api import auth
 
 
@@ -24,8 +32,16 @@ from backend.api import auth
 async def lifespan(app: FastAPI):
     """Application lifespan management."""
     # Startup
+    # Fail fast on invalid configuration before touching Outlook or the database
+    settings.log_effective_config()
+    
+    db_manager = get_default_manager()
     print("🚀 Starting Email Helper API...")
     print(f"📊 Database path: {db_manager.db_path}")
+    if settings.read_only:
+        print("🔒 Read-only mode: changes are disabled")
+    if db_manager.recovery is not None:
+        print(f"⚠️ {db_manager.recovery.banner}")
     
     # Ensure database is ready
     try:
@@ -36,9 +52,139 @@ async def lifespan(app: FastAPI):
     except Exception as e:
         print(f"⚠️ Database initialization warning: {e}")
     
+    # A configured Outlook store that is missing stops startup instead of showing up as empty folders
+    if settings.use_com_backend and settings.outlook_store_name:
+        from backend.core.dependencies import get_com_email_provider
+        
+        try:
+            get_com_email_provider()
+            print(f"📬 Using Outlook store: {settings.outlook_store_name}")
+        except HTTPException as e:
+            if e.status_code == 404:
+                raise RuntimeError(e.detail) from e
+            print(f"⚠️ Outlook not connected at startup: {e.detail}")
+    
+    # Periodic jobs run only while the API is running (see GET /api/admin/jobs)
+    scheduler = get_job_scheduler()
+    
+    if settings.spam_quarantine_days > 0:
+        from backend.core.dependencies import get_email_provider
+        from backend.services.email_service import EmailService
+        from backend.services.quarantine import run_purge
+        
+        try:
+            email_service = EmailService(get_email_provider())
+            scheduler.add(ScheduledJob(
+                "quarantine_purge", lambda: run_purge(email_service),
+                settings.quarantine_purge_interval_seconds, "Delete quarantined spam past its quarantine"
+            ))
+            print(f"🗑️ Purging quarantined spam after {settings.spam_quarantine_days} days")
+        except Exception as e:
+            print(f"⚠️ Quarantine purge not started: {e}")
+    
+    if settings.activity_retention_days > 0:
+        from backend.services.activity_log import ActivityLog, run_prune
+        
+        activity_log = ActivityLog(db_manager)
+        scheduler.add(ScheduledJob(
+            "activity_prune", lambda: run_prune(activity_log, settings.activity_retention_days),
+            settings.activity_prune_interval_seconds, "Delete expired Outlook activity log entries"
+        ))
+    
+    if settings.ai_trace_retention_days > 0:
+        from backend.services.ai_traces import AITraceStore, run_trace_prune
+        
+        trace_store = AITraceStore(db_manager)
+        scheduler.add(ScheduledJob(
+            "ai_trace_prune", lambda: run_trace_prune(trace_store, settings.ai_trace_retention_days),
+            settings.ai_trace_prune_interval_seconds, "Delete expired AI debug traces"
+        ))
+    
+    if settings.task_reminder_lead_minutes > 0:
+        from backend.services.task_reminders import run_reminders
+        
+        scheduler.add(ScheduledJob(
+            "task_reminders", lambda: run_reminders(db_manager, settings.task_reminder_lead_minutes),
+            settings.task_reminder_interval_seconds, "Notify about tasks falling due soon"
+        ))
+    
+    if settings.boilerplate_learn_interval_seconds > 0:
+        from backend.services.boilerplate import BoilerplateStore, run_learning
+        
+        boilerplate_store = BoilerplateStore(db_manager)
+        scheduler.add(ScheduledJob(
+            "boilerplate_learn", lambda: run_learning(boilerplate_store),
+            settings.boilerplate_learn_interval_seconds, "Learn senders' repeated signatures and disclaimers"
+        ))
+    
+    if settings.progress_snapshot_interval_seconds > 0:
+        from backend.core.dependencies import get_email_provider
+        from backend.services.email_service import EmailService, run_progress_snapshots
+        
+        try:
+            progress_service = EmailService(get_email_provider(), db=db_manager)
+            scheduler.add(ScheduledJob(
+                "inbox_progress_snapshot", lambda: run_progress_snapshots(progress_service),
+                settings.progress_snapshot_interval_seconds, "Record each user's inbox-zero progress for the day"
+            ))
+        except Exception as e:
+            print(f"⚠️ Inbox progress snapshots not started: {e}")
+    
+    if settings.archive_aged_interval_seconds > 0:
+        from backend.core.dependencies import get_email_provider
+        from backend.services.email_service import EmailService, run_archive_aged
+        
+        try:
+            archive_service = EmailService(get_email_provider(), db=db_manager)
+            scheduler.add(ScheduledJob(
+                "archive_aged", lambda: run_archive_aged(archive_service),
+                settings.archive_aged_interval_seconds, "Move aged inbox emails to the archive folder"
+            ))
+        except Exception as e:
+            print(f"⚠️ Aged email archiving not started: {e}")
+    
+    if settings.outbox_enabled and settings.outbox_drain_interval_seconds > 0:
+        from backend.core.dependencies import get_email_provider
+        from backend.services.email_service import EmailService, run_outbox_drain
+        
+        try:
+            outbox_service = EmailService(get_email_provider(), db=db_manager)
+            scheduler.add(ScheduledJob(
+                "outbox_drain", lambda: run_outbox_drain(outbox_service),
+                settings.outbox_drain_interval_seconds, "Apply mailbox changes queued while Outlook was unavailable"
+            ))
+        except Exception as e:
+            print(f"⚠️ Outbox drain not started: {e}")
+    
+    if settings.contact_scan_interval_seconds > 0:
+        from backend.core.dependencies import get_email_provider
+        from backend.services.contacts import ContactStore, run_contact_scan
+        
+        try:
+            contact_provider = get_email_provider()
+            contact_store = ContactStore(db_manager)
+            scheduler.add(ScheduledJob(
+                "contact_scan", lambda: run_contact_scan(contact_store, contact_provider),
+                settings.contact_scan_interval_seconds, "Count new Sent Items into contact profiles"
+            ))
+        except Exception as e:
+            print(f"⚠️ Contact scan not started: {e}")
+    
+    if settings.database_maintenance_interval_seconds > 0:
+        from backend.services.database_maintenance import DatabaseMaintenance, run_maintenance
+        
+        maintenance = DatabaseMaintenance(db_manager)
+        scheduler.add(ScheduledJob(
+            "database_maintenance", lambda: run_maintenance(maintenance),
+            settings.database_maintenance_interval_seconds, "Vacuum and analyze the database"
+        ))
+    
+    scheduler.start()
+    
     yield
     
     # Shutdown
+    await scheduler.stop()
     print("🛑 Shutting down Email Helper API...")
 
 
@@ -62,51 +208,50 @@ app.add_middleware(
 )
 
 
-# Exception handlers
-@app.exception_handler(HTTPException)
-async def http_exception_handler(request, exc):
-    """Handle HTTP exceptions with consistent response format."""
-    return JSONResponse(
-        status_code=exc.status_code,
-        content={
-            "error": True,
-            "message": exc.detail,
-            "status_code": exc.status_code
-        }
-    )
-
-
-@app.exception_handler(Exception)
-async def general_exception_handler(request, exc):
-    """Handle general exceptions."""
-    return JSONResponse(
-        status_code=500,
-        content={
-            "error": True,
-            "message": "Internal server error",
-            "status_code": 500
-        }
-    )
+# Request IDs and the standard error envelope
+register_error_handlers(app)
+
+# Per-route time limits (504 when exceeded)
+register_timeout_middleware(app)
+
+# 403 read_only for mutating requests while settings.read_only is set
+register_read_only_middleware(app)
+
+# Per-request AI debug tracing (X-AI-Debug-Trace header)
+register_ai_trace_middleware(app)
 
 
 # Health check endpoint
 @app.get("/health")
 async def health_check():
-    """Health check endpoint."""
+    """Health check endpoint.
+    
+    The status is degraded while the server runs on an empty database that
+    replaced a corrupted one at startup; database_recovery then says where
+    the damaged file was kept. ai.queue shows, per deployment, the AI calls
+    in flight and waiting in the AI request queue and how long calls waited.
+    """
+    db_manager = get_default_manager()
     try:
         # Test database connection
         with db_manager.get_connection() as conn:
             conn.execute("SELECT 1")
         
-        db_status = "healthy"
+        db_status = "degraded" if db_manager.degraded else "healthy"
+        outbox = Outbox(db_manager).counts()
     except Exception as e:
         db_status = f"unhealthy: {str(e)}"
+        outbox = None
     
     return {
-        "status": "healthy",
+        "status": "degraded" if db_manager.degraded else "healthy",
         "service": "email-helper-api",
         "version": settings.app_version,
         "database": db_status,
+        "database_recovery": db_manager.recovery.to_dict() if db_manager.recovery else None,
+        "provider": provider_degraded.snapshot(),
+        "outbox": outbox,
+        "ai": {**ai_health.snapshot(), "queue": ai_request_queue.snapshot()},
         "debug": settings.debug
     }
 
@@ -142,6 +287,46 @@ app.include_router(tasks.router, prefix="/api", tags=["tasks"])
 from backend.api import processing
 app.include_router(processing.router, prefix="/api", tags=["processing"])
 
+# Import and include saved views router
+from backend.api import views
+app.include_router(views.router, prefix="/api", tags=["views"])
+
+# Import and include tray summary router
+from backend.api import summary
+app.include_router(summary.router, prefix="/api", tags=["summary"])
+
+# Import and include Outlook activity log router
+from backend.api import activity
+app.include_router(activity.router, prefix="/api", tags=["activity"])
+
+# Import and include classification categories router
+from backend.api import categories
+app.include_router(categories.router, prefix="/api", tags=["categories"])
+
+# Import and include Outlook rule import router
+from backend.api import rules
+app.include_router(rules.router, prefix="/api", tags=["rules"])
+
+# Import and include calendar feed router
+from backend.api import feeds
+app.include_router(feeds.router, prefix="/api", tags=["feeds"])
+
+# Import and include per-user settings router
+from backend.api import user_settings
+app.include_router(user_settings.router, prefix="/api", tags=["user settings"])
+
+# Import and include admin router
+from backend.api import admin
+app.include_router(admin.router, prefix="/api", tags=["admin"])
+
+# Import and include config introspection router
+from backend.api import config as config_api
+app.include_router(config_api.router, prefix="/api", tags=["config"])
+
+# Import and include contact profile router
+from backend.api import contacts
+app.include_router(contacts.router, prefix="/api", tags=["contacts"])
+
 
 # Service factory integration for existing services
 def get_service_factory():
@@ -10,7 +10,7 @@ from .graph_email import (
 )
 from .ai_models import (
     EmailClassificationRequest, EmailClassificationResponse,
-    ActionItemRequest, ActionItemResponse,
+    ActionItem, ActionItemRequest, ActionItemResponse,
     SummaryRequest, SummaryResponse,
     AIErrorResponse, AvailableTemplatesResponse
 )
@@ -24,7 +24,7 @@ __all__ = [
     "GraphEmailBody", "EmailNormalizer", "GraphAuthResponse", "GraphAuthRequest",
     "GraphErrorResponse",
     "EmailClassificationRequest", "EmailClassificationResponse",
-    "ActionItemRequest", "ActionItemResponse",
+    "ActionItem", "ActionItemRequest", "ActionItemResponse",
     "SummaryRequest", "SummaryResponse",
     "AIErrorResponse", "AvailableTemplatesResponse"
 ]
@@ -0,0 +1,27 @@
+"""Outlook activity log models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from pydantic import BaseModel, Field
+
+
+class ActivityEntry(BaseModel):
+    """One mailbox change made through the email service."""
+    id: int
+    operation: str
+    email_id: Optional[str] = None
+    parameters: Dict[str, Any] = {}
+    result: str = Field(..., description="success, failed (the provider declined), error, or cancelled")
+    error: Optional[str] = None
+    duration_ms: float
+    request_id: Optional[str] = None
+    created_at: datetime
+
+
+class ActivityListResponse(BaseModel):
+    """A page of activity log entries, newest first."""
+    entries: List[ActivityEntry]
+    total: int
+    offset: int
+    limit: int
+    has_more: bool
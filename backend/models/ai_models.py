@@ -2,8 +2,40 @@
 
 from datetime import datetime
 from typing import Optional, List, Dict, Any
-from pydantic import BaseModel, Field
+from pydantic import BaseModel, Field, model_validator
 
+# Built-in categories of the email classifier (mirrors AIProcessor.get_available_categories);
+# the categories table adds user-defined ones (see backend.services.category_service)
+EMAIL_CATEGORIES = [
+    "required_personal_action",
+    "team_action",
+    "optional_action",
+    "work_relevant",
+    "fyi",
+    "newsletter",
+    "spam_to_delete",
+    "job_listing",
+    "optional_event",
+]
+
+# Importance scores run from 1 (ignorable) to 5 (urgent); classifier output
+# without a usable score is treated as average
+MIN_IMPORTANCE_SCORE = 1
+MAX_IMPORTANCE_SCORE = 5
+DEFAULT_IMPORTANCE_SCORE = 3
+
+
+def clamp_importance_score(value: Any) -> int:
+    """Coerce a classifier importance score into 1-5.
+    
+    Missing or non-numeric values become DEFAULT_IMPORTANCE_SCORE and
+    out-of-range values are clamped to the nearest bound.
+    """
+    try:
+        score = round(float(value))
+    except (TypeError, ValueError, OverflowError):
+        return DEFAULT_IMPORTANCE_SCORE
+    return max(MIN_IMPORTANCE_SCORE, min(MAX_IMPORTANCE_SCORE, score))
 
 class EmailClassificationRequest(BaseModel):
     """Request model for email classification."""
@@ -18,19 +50,43 @@ class EmailClassificationResponse(BaseModel):
     category: str = Field(..., description="Classified email category")
     confidence: float = Field(..., ge=0.0, le=1.0, description="Classification confidence score")
     reasoning: str = Field(..., description="Explanation for the classification")
+    importance_score: int = Field(
+        default=DEFAULT_IMPORTANCE_SCORE, ge=MIN_IMPORTANCE_SCORE, le=MAX_IMPORTANCE_SCORE,
+        description="Predicted importance from 1 (ignorable) to 5 (urgent)"
+    )
+    importance_justification: Optional[str] = Field(None, description="Why the email got its importance score")
     alternative_categories: List[str] = Field(default=[], description="Alternative category suggestions")
     processing_time: float = Field(..., description="Processing time in seconds")
+    queue_wait_time: float = Field(0.0, description="Seconds the AI calls spent waiting in the AI request queue")
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
+
+
+# Who an extracted action item falls to
+ACTION_ITEM_OWNERS = ["me", "team", "other"]
+DEFAULT_ACTION_ITEM_OWNER = "me"
+
+
+class ActionItem(BaseModel):
+    """One action item extracted from an email."""
+    description: str = Field(..., description="What needs to be done")
+    owner: str = Field(DEFAULT_ACTION_ITEM_OWNER, description="Who must act: me, team, or other")
+    deadline: Optional[datetime] = Field(None, description="When the item is due, if the email gives a date")
+    source_quote: Optional[str] = Field(None, description="Text of the email the item was taken from")
+    links: List[str] = Field(default=[], description="Links needed to complete the item")
 
 
 class ActionItemRequest(BaseModel):
     """Request model for action item extraction."""
-    email_content: str = Field(..., description="Full email content for analysis")
+    email_content: str = Field(..., min_length=1, description="Full email content for analysis")
     context: Optional[str] = Field(None, description="Additional context for extraction")
 
 
 class ActionItemResponse(BaseModel):
     """Response model for action item extraction."""
-    action_items: List[str] = Field(default=[], description="Extracted action items")
+    items: List[ActionItem] = Field(default=[], description="Extracted action items")
+    action_items: List[str] = Field(
+        default=[], description="Deprecated: descriptions of items, kept for older clients"
+    )
     urgency: str = Field(..., description="Urgency level of action items")
     deadline: Optional[str] = Field(None, description="Deadline if detected")
     confidence: float = Field(..., ge=0.0, le=1.0, description="Extraction confidence score")
@@ -39,12 +95,20 @@ class ActionItemResponse(BaseModel):
     explanation: Optional[str] = Field(None, description="Explanation of action requirements")
     relevance: Optional[str] = Field(None, description="Relevance to user context")
     links: List[str] = Field(default=[], description="Relevant links from email")
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
 
 
 class SummaryRequest(BaseModel):
-    """Request model for email summarization."""
-    email_content: str = Field(..., description="Email content to summarize")
-    summary_type: str = Field(default="brief", description="Type of summary: brief or detailed")
+    """Request model for email summarization.
+    
+    Thread summaries take a conversation_id; every other type takes
+    email_content.
+    """
+    email_content: Optional[str] = Field(None, description="Email content to summarize")
+    conversation_id: Optional[str] = Field(None, description="Conversation to digest (thread summaries only)")
+    summary_type: str = Field(
+        default="brief", description="Type of summary: brief, detailed, action_focused, or thread"
+    )
 
 
 class SummaryResponse(BaseModel):
@@ -53,6 +117,211 @@ class SummaryResponse(BaseModel):
     key_points: List[str] = Field(default=[], description="Key points extracted from email")
     confidence: float = Field(..., ge=0.0, le=1.0, description="Summary quality confidence")
     processing_time: float = Field(..., description="Processing time in seconds")
+    queue_wait_time: float = Field(0.0, description="Seconds the AI calls spent waiting in the AI request queue")
+    message_count: Optional[int] = Field(None, description="Messages in the thread (thread summaries only)")
+    messages_omitted: Optional[int] = Field(
+        None, description="Thread messages left out to fit the token budget (thread summaries only)"
+    )
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
+
+
+class SummarySelector(BaseModel):
+    """Selects stored emails to summarize instead of listing their IDs."""
+    category: Optional[str] = Field(None, description="Only emails in this AI category")
+    unread_only: bool = Field(default=False, description="Only unread emails")
+    since: Optional[str] = Field(None, description="Received on or after this ISO date or relative age like 7d")
+    limit: int = Field(default=50, ge=1, le=100, description="Maximum emails to summarize, newest first")
+
+
+class BatchSummaryRequest(BaseModel):
+    """Request model for summarizing several stored emails.
+    
+    Give either email_ids or a selector, not both.
+    """
+    email_ids: Optional[List[str]] = Field(None, min_length=1, max_length=100, description="Stored emails to summarize")
+    selector: Optional[SummarySelector] = Field(None, description="Resolve the emails to summarize from the database")
+    summary_type: str = Field(default="brief", description="Type of summary: brief, detailed, or action_focused")
+    
+    @model_validator(mode="after")
+    def _one_input_form(self):
+        if (self.email_ids is None) == (self.selector is None):
+            raise ValueError("Provide either email_ids or selector")
+        return self
+
+
+class BatchSummaryItem(BaseModel):
+    """Summary of one email in a batch, or why it could not be summarized."""
+    summary: Optional[str] = Field(None, description="Generated email summary")
+    key_points: List[str] = Field(default=[], description="Key points extracted from email")
+    error: Optional[str] = Field(None, description="Why the email was not summarized")
+    skipped: Optional[str] = Field(None, description="Why the email was not sent to the AI, e.g. protected content")
+    status_code: int = Field(200, description="HTTP status of this item")
+    is_retryable: bool = Field(False, description="Whether resending this item unchanged may succeed")
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
+
+
+class BatchSummaryResponse(BaseModel):
+    """Response model for a batch summary request."""
+    selector: Optional[SummarySelector] = Field(None, description="Selector used, if any")
+    resolved_count: int = Field(..., description="Emails the request resolved to")
+    results: Dict[str, BatchSummaryItem] = Field(default={}, description="Results keyed by email ID")
+    success_count: int = Field(..., description="Emails summarized")
+    failure_count: int = Field(..., description="Emails that could not be summarized")
+    skipped_count: int = Field(0, description="Emails skipped rather than summarized")
+    processing_time: float = Field(..., description="Processing time in seconds")
+    queue_wait_time: float = Field(
+        0.0, description="Seconds the AI calls spent waiting in the AI request queue, added up"
+    )
+
+
+class ExplainClassificationRequest(BaseModel):
+    """Request model for a classification explanation."""
+    email_id: str = Field(..., description="ID of a stored, classified email")
+    target_category: str = Field(..., description="Alternative category to compare against")
+
+
+class ExplainClassificationResponse(BaseModel):
+    """Response model comparing the current category with an alternative."""
+    email_id: str = Field(..., description="Email identifier")
+    current_category: str = Field(..., description="Category the email was classified as")
+    target_category: str = Field(..., description="Alternative category compared against")
+    supports_current: List[str] = Field(default=[], description="Evidence for the current category")
+    supports_alternative: List[str] = Field(default=[], description="Evidence for the alternative category")
+    verdict: str = Field(..., description="keep_current, prefer_alternative, or ambiguous")
+    processing_time: float = Field(..., description="Processing time in seconds")
+    queue_wait_time: float = Field(0.0, description="Seconds the AI calls spent waiting in the AI request queue")
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
+
+
+class DraftReplyRequest(BaseModel):
+    """Request model for drafting a reply to an email."""
+    email_id: str = Field(..., description="Email to reply to")
+    tone: str = Field(default="brief", description="brief, formal, or friendly")
+    bullet_points: List[str] = Field(default=[], description="Points the reply should cover")
+    save_as_draft: bool = Field(default=False, description="Save the reply to Outlook Drafts (never sent)")
+
+
+class DraftReplyResponse(BaseModel):
+    """Response model for a drafted reply."""
+    email_id: str = Field(..., description="Email replied to")
+    subject: str = Field(..., description="Reply subject")
+    body: str = Field(..., description="Reply body")
+    tone: str = Field(..., description="Tone used")
+    saved_as_draft: bool = Field(default=False, description="Whether the reply was saved to Outlook Drafts")
+    draft_id: Optional[str] = Field(None, description="EntryID of the saved draft")
+    processing_time: float = Field(..., description="Processing time in seconds")
+    queue_wait_time: float = Field(0.0, description="Seconds the AI calls spent waiting in the AI request queue")
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
+
+
+class SearchQueryRequest(BaseModel):
+    """Request model for turning a natural language request into search filters."""
+    request: str = Field(
+        ..., min_length=1, max_length=200,
+        description="What to find, e.g. \"mails from dana about the reorg last month\""
+    )
+    execute: bool = Field(default=False, description="Also run the search and return the matching emails")
+    limit: int = Field(default=50, ge=1, le=100, description="Most emails to return when executing")
+
+
+class SearchQueryFilters(BaseModel):
+    """Parameters for GET /api/emails/search; unset ones are left out of the search."""
+    q: Optional[str] = Field(None, description="Text to find in the subject, body, or sender")
+    sender: Optional[str] = Field(None, description="Sender contains this text")
+    category: Optional[str] = Field(None, description="AI category")
+    received_after: Optional[str] = Field(None, description="ISO date or relative age such as 7d")
+    received_before: Optional[str] = Field(None, description="ISO date or relative age such as 7d")
+
+
+class SearchQueryResponse(BaseModel):
+    """Response model for a generated search query."""
+    filters: SearchQueryFilters
+    fallback: bool = Field(
+        default=False, description="True if the AI output was unusable and the request is searched as plain text"
+    )
+    dropped: List[str] = Field(default=[], description="Filters the AI returned that failed validation")
+    emails: Optional[List[Dict[str, Any]]] = Field(None, description="Matching stored emails, when executed")
+    total: Optional[int] = Field(None, description="Stored emails matching in all, when executed")
+    processing_time: float = Field(..., description="Processing time in seconds")
+    queue_wait_time: float = Field(0.0, description="Seconds the AI calls spent waiting in the AI request queue")
+
+
+class HolisticAnalysisRequest(BaseModel):
+    """Request model for analyzing the inbox as a whole."""
+    limit: int = Field(default=50, ge=1, le=200, description="Newest stored inbox emails to analyze")
+
+
+class HolisticAction(BaseModel):
+    """An action still needed, with the email that best represents its topic."""
+    topic: str = Field(..., description="What the action is about")
+    action_type: str = Field(..., description="required_personal_action, team_action, or optional_action")
+    priority: str = Field(..., description="high, medium, or low")
+    canonical_email_id: str = Field(..., description="Email that best represents the topic")
+    related_email_ids: List[str] = Field(default=[], description="Other analyzed emails on the topic")
+    deadline: Optional[str] = Field(None, description="Deadline, as the email states it")
+    why_relevant: str = Field("", description="Why the action matters to the user")
+    blocking_others: bool = Field(default=False, description="Whether other people are waiting on the user")
+
+
+class HolisticAnalysisResponse(BaseModel):
+    """Response model for a holistic inbox analysis."""
+    analysis_id: int = Field(..., description="Stored run POST /api/emails/analyses/{analysis_id}/apply acts on")
+    analyzed: int = Field(..., description="Emails sent to the AI")
+    skipped_protected: int = Field(0, description="Protected emails left out of the analysis")
+    truly_relevant_actions: List[HolisticAction] = Field(default=[], description="Actions still needed")
+    superseded_actions: List[Dict[str, Any]] = Field(default=[], description="Actions made moot by later emails")
+    duplicate_groups: List[Dict[str, Any]] = Field(default=[], description="Emails covering the same topic")
+    expired_items: List[Dict[str, Any]] = Field(default=[], description="Items past their deadline or event")
+    blocking_count: int = Field(..., description="Open blocking items after the analysis")
+    processing_time: float = Field(..., description="Processing time in seconds")
+    queue_wait_time: float = Field(0.0, description="Seconds the AI calls spent waiting in the AI request queue")
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
+
+
+class SummaryBackfillRequest(BaseModel):
+    """Request model for backfilling missing one-line summaries."""
+    category: Optional[str] = Field(None, description="Only backfill emails in this category")
+    limit: int = Field(default=200, ge=1, le=1000, description="Maximum emails to summarize")
+    dry_run: bool = Field(default=False, description="Only count matching emails")
+
+
+class SummaryBackfillResponse(BaseModel):
+    """Response model for a summary backfill request."""
+    missing: int = Field(..., description="Stored emails without a summary matching the filter")
+    scheduled: int = Field(..., description="Emails queued for summarization (at most the AI rate limit left)")
+    dry_run: bool = Field(..., description="True if nothing was scheduled")
+
+
+class CalibrationBucket(BaseModel):
+    """Predictions whose confidence falls in one decile, and how often they were corrected."""
+    min_confidence: float = Field(..., description="Inclusive lower bound")
+    max_confidence: float = Field(..., description="Exclusive upper bound (1.0 is included in the top bucket)")
+    predictions: int = 0
+    corrections: int = Field(0, description="Predictions whose category a user changed")
+    correction_rate: Optional[float] = Field(None, description="corrections / predictions; None for an empty bucket")
+    mean_confidence: Optional[float] = None
+
+
+class CategoryCalibration(BaseModel):
+    """Calibration of the predictions of one category (or of all of them)."""
+    category: str
+    predictions: int = 0
+    corrections: int = 0
+    buckets: List[CalibrationBucket]
+    suggested_threshold: Optional[float] = Field(
+        None, description="Lowest decile bound whose predictions at or above it meet the target precision"
+    )
+    threshold_precision: Optional[float] = Field(None, description="Precision of predictions at or above the threshold")
+    threshold_coverage: Optional[float] = Field(None, description="Share of predictions at or above the threshold")
+
+
+class CalibrationReport(BaseModel):
+    """How well classifier confidence predicts that a category is kept."""
+    since: datetime = Field(..., description="Start of the window of AI classifications covered")
+    days: int
+    target_precision: float
+    overall: CategoryCalibration
+    categories: List[CategoryCalibration] = Field(..., description="Predicted categories, most predictions first")
 
 
 class AIErrorResponse(BaseModel):
@@ -66,4 +335,9 @@ class AIErrorResponse(BaseModel):
 class AvailableTemplatesResponse(BaseModel):
     """Response model for available prompt templates."""
     templates: List[str] = Field(..., description="List of available template names")
-    descriptions: Dict[str, str] = Field(default={}, description="Template descriptions")
\ No newline at end of file
+    descriptions: Dict[str, str] = Field(default={}, description="Template descriptions")
+    layers: Dict[str, str] = Field(default={}, description="Layer each active template came from: bundled or user")
+    parameters: Dict[str, Dict[str, Any]] = Field(
+        default={}, description="Model parameters each template runs with, settings.ai_parameters applied"
+    )
+    errors: Dict[str, str] = Field(default={}, description="User templates that failed to parse, with why")
\ No newline at end of file
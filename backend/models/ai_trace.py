@@ -0,0 +1,28 @@
+"""AI debug trace models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from pydantic import BaseModel, Field
+
+
+class AITrace(BaseModel):
+    """One prompt run recorded while AI debug tracing was on."""
+    id: int
+    email_id: str
+    operation: str = Field(..., description="Prompt template the call ran, e.g. email_classifier_with_explanation")
+    prompt_version: str
+    model: Optional[str] = None
+    inputs: Dict[str, Any] = {}
+    parameters: Dict[str, Any] = Field({}, description="Model parameters the call ran with, e.g. temperature")
+    rendered_prompt: Optional[str] = None
+    raw_response: Optional[str] = None
+    error: Optional[str] = Field(None, description="Failure, or why the response is a fallback")
+    duration_ms: float
+    request_id: Optional[str] = None
+    created_at: datetime
+
+
+class AITraceListResponse(BaseModel):
+    """An email's AI traces, oldest first."""
+    email_id: str
+    traces: List[AITrace]
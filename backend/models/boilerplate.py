@@ -0,0 +1,21 @@
+"""Learned boilerplate models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import List, Optional
+from pydantic import BaseModel, Field
+
+
+class LearnedBoilerplate(BaseModel):
+    """A trailing block, such as a signature or disclaimer, learned for a sender."""
+    id: int
+    sender: str = Field(..., description="Sender the block was learned for, lowercased")
+    block: str = Field(..., description="The block's lines as first seen")
+    line_count: int
+    email_count: int = Field(..., description="Stored emails from the sender ending with the block when learned")
+    learned_at: Optional[datetime] = None
+
+
+class BoilerplateListResponse(BaseModel):
+    """Learned boilerplate blocks."""
+    blocks: List[LearnedBoilerplate]
+    total: int
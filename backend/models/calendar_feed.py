@@ -0,0 +1,18 @@
+"""Calendar feed models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import Optional
+from pydantic import BaseModel, Field
+
+
+class FeedToken(BaseModel):
+    """A newly generated feed token and the feed URL that carries it."""
+    token: str = Field(..., description="Shown once; only a hash is stored")
+    url: str = Field(..., description="Subscription URL for calendar clients")
+    created_at: datetime
+
+
+class FeedTokenStatus(BaseModel):
+    """Whether the user has a feed token."""
+    active: bool
+    created_at: Optional[datetime] = None
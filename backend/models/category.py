@@ -0,0 +1,47 @@
+"""Classification category models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import List, Literal, Optional
+from pydantic import BaseModel, Field
+
+# Shape of a category name: what the classifier returns and Outlook shows
+CATEGORY_NAME_PATTERN = r"^[a-z][a-z0-9_]{0,49}$"
+
+CategoryPriority = Literal["high", "medium", "low"]
+
+
+class CategoryBase(BaseModel):
+    """How the classifier should recognize a category."""
+    description: str = Field(
+        ..., min_length=1, max_length=2000,
+        description="Classification rules, one per line; {{username}} is replaced with the user's name"
+    )
+    priority: CategoryPriority = Field(default="low", description="Prompt section the rules are listed under")
+    folder: Optional[str] = Field(None, max_length=255, description="Outlook folder the category is filed into")
+
+
+class CategoryCreate(CategoryBase):
+    """Category creation model."""
+    name: str = Field(..., pattern=CATEGORY_NAME_PATTERN, description="Lowercase snake_case name")
+
+
+class CategoryUpdate(BaseModel):
+    """Category update model; an empty folder clears it."""
+    description: Optional[str] = Field(None, min_length=1, max_length=2000)
+    priority: Optional[CategoryPriority] = None
+    folder: Optional[str] = Field(None, max_length=255)
+
+
+class Category(CategoryBase):
+    """Category model for API responses."""
+    name: str
+    built_in: bool = False
+    sort_order: int = 0
+    created_at: datetime
+    updated_at: datetime
+
+
+class CategoryListResponse(BaseModel):
+    """Categories in prompt order."""
+    categories: List[Category]
+    total: int
@@ -0,0 +1,35 @@
+"""Category migration models for FastAPI Email Helper API."""
+
+from typing import Dict, List, Optional
+from pydantic import AliasChoices, BaseModel, ConfigDict, Field
+
+from backend.models.email import BatchItemError
+
+
+class CategoryMigrationRequest(BaseModel):
+    """Rename a category everywhere it is stored."""
+    model_config = ConfigDict(populate_by_name=True)
+    
+    from_category: str = Field(
+        ..., min_length=1, max_length=50, validation_alias=AliasChoices("from", "from_category"),
+        description="Category to migrate away from"
+    )
+    to_category: str = Field(
+        ..., min_length=1, max_length=50, validation_alias=AliasChoices("to", "to_category"),
+        description="Category to migrate to"
+    )
+    update_outlook: bool = Field(default=False, description="Re-apply the new category to migrated emails in Outlook")
+    dry_run: bool = Field(default=False, description="Only count the rows that would change")
+    allow_custom: bool = Field(default=False, description="Allow a target outside the registered categories")
+
+
+class CategoryMigrationResult(BaseModel):
+    """Rows a category migration changed (or would change, for a dry run)."""
+    from_category: str
+    to_category: str
+    dry_run: bool
+    affected: Dict[str, int] = Field(default_factory=dict, description="Changed rows by table")
+    migration_id: Optional[int] = Field(None, description="Audit row of the migration; None for dry runs")
+    outlook_applied: int = 0
+    errors: List[BatchItemError] = []  # Emails whose Outlook category could not be re-applied
+
@@ -0,0 +1,25 @@
+"""Contact profile models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import List, Optional
+from pydantic import BaseModel, Field
+
+
+class ContactProfile(BaseModel):
+    """Someone the mailbox owner has sent mail to, aggregated from Sent Items."""
+    address: str = Field(..., description="Recipient address, lowercased")
+    display_name: Optional[str] = None
+    sent_count: int = Field(..., description="Sent emails addressing the contact on To or Cc")
+    first_contact_at: Optional[datetime] = None
+    last_contact_at: Optional[datetime] = None
+    relationship: str = Field(..., description="One-line hint given to the classification prompt")
+
+
+class ContactListResponse(BaseModel):
+    """A page of contacts, most emailed first."""
+    contacts: List[ContactProfile]
+    total: int
+    offset: int
+    limit: int
+    has_more: bool
+    last_scan_at: Optional[datetime] = Field(None, description="When Sent Items was last scanned; None if never")
@@ -0,0 +1,20 @@
+"""Data wipe models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import Dict, Optional
+from pydantic import BaseModel, Field
+
+
+class WipeRequest(BaseModel):
+    """Wipe the stored mail data, or ask for the token confirming it."""
+    confirm: Optional[str] = Field(
+        None, description="Confirmation token from a previous call; without it nothing is deleted"
+    )
+
+
+class WipeResponse(BaseModel):
+    """Rows a wipe deleted, or would delete when not yet confirmed."""
+    wiped: bool
+    rows: Dict[str, int] = Field(default_factory=dict, description="Deleted (or deletable) rows by table")
+    confirm: Optional[str] = Field(None, description="Token to send back as confirm to wipe; only when not wiped")
+    confirm_expires_at: Optional[datetime] = None
@@ -0,0 +1,11 @@
+"""Dry run models for FastAPI Email Helper API."""
+
+from typing import Any, Dict, List
+from pydantic import BaseModel, Field
+
+
+class ChangePlanResult(BaseModel):
+    """Writes a dry run would have made (see backend.services.dry_run)."""
+    summary: Dict[str, int] = Field(..., description="Number of changes per action")
+    description: str = Field(..., description='e.g. "would move 12 emails to Quarantine, create 5 tasks"')
+    changes: List[Dict[str, Any]] = Field(default_factory=list, description="Each change, in order")
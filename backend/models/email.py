@@ -1,8 +1,20 @@
 """Email models for FastAPI Email Helper API."""
 
-from datetime import datetime
-from typing import Optional, List, Dict, Any
-from pydantic import BaseModel, Field
+from datetime import date, datetime
+from typing import Optional, List, Literal, Dict, Any
+from pydantic import AliasChoices, BaseModel, Field, field_validator, model_validator
+
+from backend.core.config import CLASSIFICATION_STRATEGIES
+from backend.models.dry_run import ChangePlanResult
+
+# Most emails POST /emails/batch-process classifies in one call
+MAX_BATCH_EMAILS = 100
+
+# Most corrections accepted by PUT /emails/classifications in one call
+MAX_CLASSIFICATION_CORRECTIONS = 200
+
+# Longest note POST /emails/{id}/notes accepts, in characters
+MAX_NOTE_LENGTH = 4000
 
 
 class EmailBase(BaseModel):
@@ -32,6 +44,7 @@ class EmailInDB(EmailBase):
     confidence: Optional[float] = None
     processed_at: datetime
     user_id: Optional[int] = None
+    is_protected: bool = False
 
     model_config = {"from_attributes": True}
 
@@ -42,23 +55,128 @@ class Email(EmailBase):
     category: Optional[str] = None
     confidence: Optional[float] = None
     processed_at: datetime
+    version: int = 0  # Classification version, sent back with corrections
+    is_protected: bool = False  # Rights-managed; the content is a placeholder and the AI skips it
 
     model_config = {"from_attributes": True}
 
 
 class EmailClassification(BaseModel):
     """Email classification result."""
+    email_id: Optional[str] = None
     category: str
     confidence: float = Field(ge=0.0, le=1.0)
     reasoning: Optional[str] = None
+    summary: Optional[str] = None
     action_items: List[str] = []
+    alternative_categories: List[str] = Field([], description="Runner-up categories, best first")
     priority: Optional[str] = None
+    importance_score: Optional[int] = Field(None, ge=1, le=5, description="Predicted importance from 1 to 5")
+    importance_justification: Optional[str] = None
+    inherited_from: Optional[str] = Field(
+        None, description="Thread message this classification was copied from"
+    )
+    tasks_created: int = Field(0, description="Tasks created automatically for this email")
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
+    classification_stage: Optional[str] = Field(
+        None, description="Pass that decided a two_stage classification: triage or detailed"
+    )
+
+
+class EmailBatchItem(BaseModel):
+    """One email to classify: a provider email ID or the raw message.
+    
+    Raw items are classified without a provider lookup and identified in
+    results by a hash of their content. Items with an email_id are looked
+    up even if they also carry raw fields.
+    """
+    email_id: Optional[str] = Field(
+        None, min_length=1, validation_alias=AliasChoices("email_id", "id"),
+        description="Provider email to look up"
+    )
+    subject: Optional[str] = None
+    sender: Optional[str] = None
+    content: Optional[str] = None
+
+    @model_validator(mode="after")
+    def _one_input_form(self):
+        raw = (self.subject, self.sender, self.content)
+        if self.email_id is None and any(field is None for field in raw):
+            raise ValueError("Provide either email_id or subject, sender, and content")
+        return self
 
 
 class EmailBatch(BaseModel):
     """Batch email processing request."""
-    emails: List[Dict[str, Any]]
+    emails: List[EmailBatchItem] = Field(..., min_length=1, max_length=MAX_BATCH_EMAILS)
     context: Optional[str] = None
+    thread_mode: Optional[bool] = Field(
+        None, description="Classify once per conversation (defaults to settings.thread_classification)"
+    )
+    classification_strategy: Optional[str] = Field(
+        None, description="single or two_stage (defaults to settings.classification_strategy)"
+    )
+    skip_already_processed: bool = Field(
+        True, description="Skip emails already classified with the current prompt version"
+    )
+    save_to_database: bool = Field(
+        False, description="Store raw items' classifications under their content hash"
+    )
+    dry_run: bool = Field(
+        False, description="Classify without changing the mailbox or database; the result has the change plan"
+    )
+    simulate_ai: bool = Field(False, description="Use canned AI responses instead of AI calls (dry runs only)")
+
+    @field_validator("classification_strategy")
+    @classmethod
+    def _check_strategy(cls, value: Optional[str]) -> Optional[str]:
+        if value is not None and value not in CLASSIFICATION_STRATEGIES:
+            raise ValueError(f"classification_strategy must be one of {', '.join(CLASSIFICATION_STRATEGIES)}")
+        return value
+
+    @model_validator(mode="after")
+    def _simulate_only_dry_runs(self):
+        if self.simulate_ai and not self.dry_run:
+            raise ValueError("simulate_ai requires dry_run")
+        return self
+
+
+class BatchItemError(BaseModel):
+    """Why one item of a batch operation failed."""
+    email_id: str
+    error: str
+    status_code: int = Field(..., description="HTTP status of this item")
+    is_retryable: bool = Field(False, description="Whether resending this item unchanged may succeed")
+
+
+class BatchItemSkip(BaseModel):
+    """Why one item of a batch operation was skipped rather than processed."""
+    email_id: str
+    reason: str
+
+
+class BatchItemResult(BaseModel):
+    """Outcome of one email of a batch operation that reports every email.
+    
+    Endpoints extend it with what they made from the email; error or
+    skipped says why they made nothing.
+    """
+    email_id: str
+    error: Optional[str] = Field(None, description="Why the email could not be processed")
+    skipped: Optional[str] = Field(None, description="Why the email was not sent to the AI, e.g. protected content")
+    status_code: int = Field(200, description="HTTP status of this item")
+    is_retryable: bool = Field(False, description="Whether resending this item unchanged may succeed")
+
+
+class TriageStats(BaseModel):
+    """Triage accounting of a two_stage batch; token counts are estimates."""
+    triaged: int = 0
+    detailed: int = Field(0, description="Triaged emails sent on to the full classifier")
+    estimated_tokens: int = 0
+    estimated_single_stage_tokens: int = Field(
+        0, description="Tokens the batch would have used with every email sent to the full classifier"
+    )
+    estimated_tokens_saved: int = 0
 
 
 class EmailBatchResult(BaseModel):
@@ -67,4 +185,601 @@ class EmailBatchResult(BaseModel):
     successful_count: int
     failed_count: int
     results: List[EmailClassification]
-    errors: List[str] = []
\ No newline at end of file
+    errors: List[BatchItemError] = []
+    ai_calls: int = 0
+    ai_calls_saved: int = 0
+    skipped_count: int = 0
+    muted_count: int = 0  # Emails of muted conversations tagged without the AI
+    skipped: List[BatchItemSkip] = []  # Emails the AI must not see, e.g. rights-managed ones
+    classification_strategy: str = "single"
+    triage: Optional[TriageStats] = None  # two_stage batches only
+    change_plan: Optional[ChangePlanResult] = None  # Dry runs only
+
+
+class ClassificationUpdate(BaseModel):
+    """A user-chosen category for an email.
+
+    With version, the change is only applied if the stored classification
+    still has that version.
+    """
+    category: str = Field(..., min_length=1)
+    version: Optional[int] = Field(None, ge=0)
+
+
+class ClassificationCorrection(ClassificationUpdate):
+    """A user-chosen category for one email in a batch."""
+    email_id: str = Field(..., min_length=1)
+
+
+class ClassificationState(BaseModel):
+    """Stored classification of an email."""
+    email_id: str
+    category: Optional[str] = None
+    confidence: Optional[float] = None
+    needs_review: bool = False
+    version: int = 0
+
+
+class ClassificationUpdateResponse(ClassificationState):
+    """Stored classification after a correction, and whether Outlook has it too."""
+    outlook_applied: bool = False
+    warnings: List[str] = []  # Parts of the correction that did not go through, e.g. Outlook failures
+
+
+class ClassificationCorrectionBatch(BaseModel):
+    """Category corrections applied together."""
+    classifications: List[ClassificationCorrection] = Field(
+        ..., min_length=1, max_length=MAX_CLASSIFICATION_CORRECTIONS
+    )
+    apply_to_outlook: bool = False
+
+    @field_validator("classifications")
+    @classmethod
+    def _one_per_email(cls, value: List[ClassificationCorrection]) -> List[ClassificationCorrection]:
+        email_ids = [correction.email_id for correction in value]
+        if len(set(email_ids)) != len(email_ids):
+            raise ValueError("Each email can only appear once")
+        return value
+
+
+class BatchOperationResponse(BaseModel):
+    """Result of an operation over several emails."""
+    success_count: int
+    failure_count: int
+    errors: List[BatchItemError] = []
+
+
+class ArchiveAgedRequest(BaseModel):
+    """Which aged inbox emails to archive."""
+    categories: Optional[List[str]] = Field(
+        None, description="Categories to archive (defaults to settings.archive_aged_categories)"
+    )
+    older_than_days: Optional[int] = Field(
+        None, ge=1, description="Days since receipt (defaults to settings.archive_aged_days)"
+    )
+    dry_run: bool = Field(False, description="Only list the candidates")
+
+
+class ArchiveCandidate(BaseModel):
+    """An inbox email old enough to archive."""
+    id: str
+    subject: Optional[str] = None
+    sender: Optional[str] = None
+    category: str
+    received_date: str
+    folder: str  # Inbox folder it is archived from
+
+
+class ArchiveAgedResponse(BatchOperationResponse):
+    """Result of archiving aged inbox emails."""
+    dry_run: bool = False
+    archive_folder: str
+    candidates: List[ArchiveCandidate] = []
+    batch_id: Optional[int] = Field(
+        None, description="Move batch POST /api/emails/move-batches/{batch_id}/revert undoes"
+    )
+
+
+class FolderHygienePurgeRequest(BaseModel):
+    """Which category folders to purge of emails past their retention."""
+    folders: Optional[List[str]] = Field(
+        None, min_length=1, description="Category folders to purge (defaults to every one not excluded)"
+    )
+    dry_run: bool = Field(False, description="Only list the candidates")
+
+
+class FolderHygieneCandidate(BaseModel):
+    """A category folder email older than the folder's retention."""
+    id: str
+    subject: Optional[str] = None
+    sender: Optional[str] = None
+    category: Optional[str] = None
+    received_date: str
+    folder: str  # Category folder it is purged from
+
+
+class FolderHygienePurgeResponse(BatchOperationResponse):
+    """Result of purging category folders."""
+    dry_run: bool = False
+    destination_folder: str
+    candidates: List[FolderHygieneCandidate] = []
+    batch_id: Optional[int] = Field(
+        None, description="Move batch POST /api/emails/move-batches/{batch_id}/revert undoes"
+    )
+
+
+class HolisticApplyRequest(BaseModel):
+    """Which cleanups of a stored holistic analysis run to apply."""
+    archive_superseded: bool = Field(True, description="Archive emails superseded by later ones")
+    merge_duplicates: bool = Field(True, description="Archive the emails of duplicate groups other than the one kept")
+    complete_expired_tasks: bool = Field(True, description="Close the open tasks made from expired items")
+    expired_task_status: Literal["completed", "cancelled"] = Field(
+        "completed", description="Status given to the tasks of expired items"
+    )
+    dry_run: bool = Field(False, description="Only list the intended changes")
+
+
+class HolisticArchiveCandidate(BaseModel):
+    """A stored email a holistic run would archive."""
+    id: str
+    subject: Optional[str] = None
+    sender: Optional[str] = None
+    folder: str  # Folder it is archived from
+    reason: Literal["superseded", "duplicate"]
+
+
+class HolisticTaskCandidate(BaseModel):
+    """An open task of an email a holistic run found expired."""
+    task_id: int
+    email_id: str
+    title: str
+    status: str  # Status before the apply
+
+
+class HolisticApplyResponse(BatchOperationResponse):
+    """Result of applying a holistic analysis run's cleanups."""
+    analysis_id: int
+    dry_run: bool = False
+    archive_folder: str
+    archive_candidates: List[HolisticArchiveCandidate] = []
+    task_candidates: List[HolisticTaskCandidate] = []
+    expired_task_status: str
+    tasks_closed: List[int] = []
+    batch_id: Optional[int] = Field(
+        None, description="Move batch POST /api/emails/move-batches/{batch_id}/revert undoes"
+    )
+
+
+class MoveBatchRevertResponse(BatchOperationResponse):
+    """Result of moving a batch's emails back to their folders."""
+    batch_id: int
+    tasks_restored: List[int] = Field(default=[], description="Tasks the batch closed, reopened")
+    tasks_changed_since: List[int] = Field(
+        default=[], description="Tasks the batch closed that were deleted or given another status since"
+    )
+
+
+class SenderDomainStats(BaseModel):
+    """Stored email totals of one sender domain, subdomains included."""
+    domain: str
+    total: int = 0
+    unread: int = 0
+    unclassified: int = Field(0, description="Emails without a category, which apply-category would classify")
+    dominant_category: Optional[str] = Field(None, description="Most common AI category of the domain's emails")
+    spam_ratio: float = Field(0.0, description="Share of the domain's emails categorized spam_to_delete")
+
+
+class SenderDomainReport(BaseModel):
+    """Sender domains by stored email count, largest first."""
+    domains: List[SenderDomainStats] = []
+    total: int = Field(0, description="Domains with stored emails, including those past the limit")
+
+
+class DomainCategoryRequest(BaseModel):
+    """Category to give a sender domain's unclassified emails."""
+    category: str
+    apply_to_outlook: bool = Field(
+        False, description="Also file the emails in the mail client, as a revertible move batch when the "
+        "category has a folder"
+    )
+    create_rule: bool = Field(False, description="Add a classification rule so future mail from the domain follows")
+    dry_run: bool = Field(False, description="Only list the emails that would be classified")
+
+
+class DomainCategoryResponse(BatchOperationResponse):
+    """Result of classifying a sender domain's unclassified emails."""
+    domain: str
+    category: str
+    dry_run: bool = False
+    candidates: List[str] = Field(default=[], description="Unclassified emails from the domain, newest first")
+    destination_folder: Optional[str] = Field(None, description="Category folder the emails were moved into")
+    batch_id: Optional[int] = Field(
+        None, description="Move batch POST /api/emails/move-batches/{batch_id}/revert undoes"
+    )
+    rule: Optional[str] = Field(None, description="Classification rule line for the domain, when asked for")
+    rule_created: bool = Field(False, description="False if the category already had the rule")
+
+
+class ClassificationCorrectionResponse(BatchOperationResponse):
+    """Result of a batch of classification corrections."""
+    # Current classification of emails whose version was stale (409 in errors)
+    conflicts: List[ClassificationState] = []
+
+
+class UnreadCounter(BaseModel):
+    """Total and unread email counts for a sidebar badge."""
+    total: int = 0
+    unread: int = 0
+
+
+class EmailCounters(BaseModel):
+    """Email counts grouped by AI category and folder."""
+    by_category: Dict[str, UnreadCounter] = {}
+    by_folder: Dict[str, UnreadCounter] = {}
+    needs_review: int = 0
+    flagged: int = 0
+    awaiting_reply: int = 0
+    conversation_count: int = 0  # Distinct threads; merged conversations count once
+
+
+class AttachmentUsage(BaseModel):
+    """Attachment totals of one sender or folder."""
+    name: str
+    email_count: int = 0  # Emails with at least one attachment
+    attachment_count: int = 0
+    attachment_bytes: int = 0
+
+
+class LargestEmail(BaseModel):
+    """A stored email ranked by size, linked to its detail endpoint."""
+    id: str
+    subject: str
+    sender: str
+    folder: str
+    received_date: Optional[str] = None
+    size_bytes: int  # Body plus attachments
+    attachment_bytes: int = 0
+    url: str  # GET /api/emails/{id}
+
+
+class StorageStats(BaseModel):
+    """Synced mailbox size, for deciding what to purge."""
+    email_count: int = 0
+    database_bytes: int = 0  # Size of the database file; 0 for in-memory stores
+    attachment_count: int = 0
+    attachment_bytes: int = 0
+    by_sender: List[AttachmentUsage] = []  # Largest attachment totals first
+    by_folder: List[AttachmentUsage] = []
+    largest_emails: List[LargestEmail] = []
+
+
+class AgingBucket(BaseModel):
+    """Stale actionable emails received within an age range."""
+    label: str = Field(..., description="0-2d, 3-7d, 8-14d, or 15+d")
+    min_days: int
+    max_days: Optional[int] = None  # None for the open-ended oldest bucket
+    count: int = 0
+
+
+class AgingEmail(BaseModel):
+    """An actionable email still waiting, with the task made from it if any."""
+    id: str
+    subject: str
+    sender: str
+    received_date: str
+    age_days: int
+    task_id: Optional[int] = None  # Latest task linked to the email
+    task_status: Optional[str] = None
+
+
+class EmailAgingReport(BaseModel):
+    """Actionable emails not yet handled, bucketed by age."""
+    categories: List[str]  # AI categories counted as actionable
+    buckets: List[AgingBucket]
+    total: int = 0
+    oldest: List[AgingEmail] = []  # Oldest first
+    as_of: datetime
+
+
+class FolderHygieneFolder(BaseModel):
+    """How old the emails in one category folder are."""
+    folder: str
+    excluded: bool = False  # Covered by settings.excluded_folders, so its emails are not read
+    item_count: Optional[int] = None  # In the mail client, if its folder list counts them
+    stored_count: int = 0
+    buckets: List[AgingBucket] = []  # Stored emails by days since receipt
+    undated_count: int = 0  # Stored emails without a received date
+    retention_days: int = 0  # 0 keeps the folder's emails forever
+    suggested_purge_count: int = 0  # Stored emails past the retention, flagged ones left out
+
+
+class FolderHygieneReport(BaseModel):
+    """Age report of the folders categories file email into."""
+    folders: List[FolderHygieneFolder]
+    destination_folder: str  # Where POST /api/emails/folder-hygiene/purge moves purged emails
+    as_of: datetime
+
+
+class ProgressDay(BaseModel):
+    """One day's inbox progress snapshot."""
+    date: date
+    unprocessed_count: int  # Last count recorded that day
+    reached_zero: bool = False  # Whether the unprocessed count was zero at any snapshot that day
+
+
+class InboxProgress(BaseModel):
+    """Today's triage progress toward an empty inbox, in the user's time zone."""
+    date: date
+    timezone: Optional[str] = None  # None means the server's local time
+    classified_count: int = 0  # Inbox emails classified today
+    applied_count: int = 0  # Stored emails categorized or moved in Outlook today
+    tasks_completed: int = 0
+    unprocessed_count: int = 0  # Inbox emails without a category right now
+    streak_days: int = Field(0, description="Consecutive days, at most 7, the unprocessed count hit zero")
+    history: List[ProgressDay] = []  # Last 7 days with a snapshot, oldest first
+    as_of: datetime
+
+
+class SenderReputation(BaseModel):
+    """Reputation score for a sender with its component breakdown."""
+    sender: str
+    score: Optional[float] = Field(None, description="0-100, or None without history")
+    email_count: int = 0
+    components: Dict[str, float] = Field(default_factory=dict)
+
+
+class FolderSuggestion(BaseModel):
+    """A suggested destination folder for an email."""
+    folder: str
+    source: str = Field(..., description="category, history, or ai")
+    confidence: float = Field(..., ge=0.0, le=1.0)
+
+
+class FolderSuggestionsResponse(BaseModel):
+    """Suggested destination folders for an email, best first."""
+    email_id: str
+    suggestions: List[FolderSuggestion] = Field(default_factory=list)
+
+
+class CategorySuggestion(BaseModel):
+    """A likely category for an email being classified by hand."""
+    category: str
+    source: str = Field(..., description="rule, sender_override, sender_history, or ai_alternative")
+    score: float = Field(..., ge=0.0, le=1.0)
+
+
+class CategorySuggestionsResponse(BaseModel):
+    """Likely categories for an email, best first."""
+    email_id: str
+    current_category: Optional[str] = None
+    suggestions: List[CategorySuggestion] = Field(default_factory=list)
+
+
+class EmailLink(BaseModel):
+    """A link found in an email body."""
+    url: str
+    type: str = Field(..., description="pull_request, ado_work_item, sharepoint_doc, meeting, or other")
+    anchor_text: Optional[str] = None
+
+
+class EmailLinksResponse(BaseModel):
+    """Links extracted from an email."""
+    email_id: str
+    links: List[EmailLink] = []
+    total: int = 0
+
+
+class EmailNoteCreate(BaseModel):
+    """A note to attach to a stored email."""
+    text: str = Field(..., min_length=1, max_length=MAX_NOTE_LENGTH)
+
+    @field_validator("text")
+    @classmethod
+    def _check_text(cls, value: str) -> str:
+        if not value.strip():
+            raise ValueError("text cannot be blank")
+        return value
+
+
+class EmailNote(BaseModel):
+    """A user's note on a stored email."""
+    id: int
+    email_id: str
+    text: str
+    created_at: datetime
+
+
+class EmailNoteListResponse(BaseModel):
+    """An email's notes, newest first."""
+    email_id: str
+    notes: List[EmailNote] = []
+    total: int = 0
+
+
+class BlockingItem(BaseModel):
+    """An action holistic analysis found other people waiting on, with its email."""
+    id: int
+    email_id: str = Field(..., description="Canonical email of the action")
+    topic: Optional[str] = None
+    action_type: Optional[str] = None
+    priority: Optional[str] = None
+    deadline: Optional[str] = None
+    why_relevant: Optional[str] = None
+    related_email_ids: List[str] = []
+    subject: Optional[str] = None
+    sender: Optional[str] = None
+    received_date: Optional[datetime] = None
+    folder: Optional[str] = None
+    task_id: Optional[int] = Field(None, description="Latest task made from the email")
+    task_status: Optional[str] = None
+    age_days: int = Field(0, description="Days since the email was received")
+    first_seen_at: datetime
+    last_seen_at: datetime
+
+
+class BlockingItemListResponse(BaseModel):
+    """Open blocking items, oldest first."""
+    items: List[BlockingItem] = []
+    total: int = 0
+
+
+class BlockingDismissRequest(BaseModel):
+    """Why a blocking item is dismissed; later holistic runs are told."""
+    reason: Literal["completed_elsewhere", "not_blocking"]
+    note: Optional[str] = Field(None, max_length=MAX_NOTE_LENGTH)
+
+
+class EmailDiffChange(BaseModel):
+    """A run of words deleted from the first email's text or inserted into the second's."""
+    op: str = Field(..., description="insert or delete")
+    text: str
+    old_position: int = Field(..., description="Character offset in email_text")
+    new_position: int = Field(..., description="Character offset in other_text")
+
+
+class EmailDiffResponse(BaseModel):
+    """Word-level diff of two emails' plain-text bodies."""
+    email_id: str
+    other_email_id: str
+    similarity: float = Field(..., description="Share of words the bodies have in common, 0 to 1")
+    insertions: int = 0  # Words
+    deletions: int = 0  # Words
+    changes: List[EmailDiffChange] = []
+    email_text: str = ""
+    other_text: str = ""
+    truncated: bool = False
+    notice: Optional[str] = None
+
+
+class OutlookCategoriesResponse(BaseModel):
+    """Outlook master categories created for the AI categories."""
+    categories: Dict[str, str] = Field(..., description="Category name -> its color in Outlook")
+
+
+class OutlookStore(BaseModel):
+    """A store (mailbox or data file) in the Outlook profile."""
+    id: str
+    name: str = Field(..., description="Display name, used to select the store")
+    is_default: bool = False
+    selected: bool = False  # Folder and item operations use this store
+
+
+class OutlookStoreListResponse(BaseModel):
+    """Stores in the Outlook profile."""
+    stores: List[OutlookStore]
+
+
+class OutlookStoreSelection(BaseModel):
+    """Store to switch folder and item operations to."""
+    name: str = Field(..., min_length=1, description="Display name of the store")
+
+
+class ConversationMergeRequest(BaseModel):
+    """Conversations to merge, given directly or through their emails."""
+    conversation_ids: List[str] = Field(default_factory=list)
+    email_ids: List[str] = Field(default_factory=list)
+
+
+class ConversationMergeResponse(BaseModel):
+    """Result of merging conversations."""
+    conversation_id: str = Field(..., description="Canonical conversation the group now shares")
+    merged_conversation_ids: List[str]
+    emails_updated: int
+
+
+class ConversationUnmergeResponse(BaseModel):
+    """Result of splitting a merged conversation."""
+    conversation_id: str
+    restored_conversation_ids: List[str]
+    emails_updated: int
+
+
+class ConversationMuteResponse(BaseModel):
+    """Result of muting or unmuting a conversation."""
+    conversation_id: str
+    muted: bool
+    tasks_downgraded: int = 0  # Open tasks lowered to low priority by muting
+
+
+class ThreadParticipant(BaseModel):
+    """Someone who sent or received messages in a conversation."""
+    address: str = Field(..., description="Email address, lowercased")
+    message_count: int = Field(..., description="Messages the participant sent or received")
+    sent_count: int = 0
+    received_count: int = Field(0, description="Messages the participant was on To or Cc of")
+    first_activity: Optional[datetime] = None
+    last_activity: Optional[datetime] = None
+    internal: Optional[bool] = Field(None, description="Same domain as the user's address; None when it is unknown")
+
+
+class ThreadParticipantsResponse(BaseModel):
+    """Participants of a conversation, most active first."""
+    conversation_id: str
+    participants: List[ThreadParticipant]
+    message_count: int
+    provider_only_count: int = Field(0, description="Messages read from the provider because they are not stored yet")
+
+
+class FieldDifference(BaseModel):
+    """A field whose stored value disagrees with the provider."""
+    field: str = Field(..., description="is_read, folder, or categories")
+    database: Any = None
+    outlook: Any = None
+
+
+class EmailStateMismatch(BaseModel):
+    """Stored email whose state differs from the provider's copy."""
+    email_id: str
+    differences: List[FieldDifference]
+
+
+class ReconcileReport(BaseModel):
+    """Differences between stored emails and the provider for one folder."""
+    folder: str
+    checked: int
+    has_more: bool = Field(False, description="More stored emails exist beyond the limit")
+    missing_in_outlook: List[str] = []
+    state_mismatches: List[EmailStateMismatch] = []
+    applied: bool = False
+    removed: int = Field(0, description="Stored emails deleted because the provider no longer has them")
+    updated: int = Field(0, description="Stored emails updated from the provider")
+
+
+class PreviewBackfillResult(BaseModel):
+    """Result of computing previews for stored emails."""
+    updated: int = Field(0, description="Stored emails given a preview")
+
+
+class OutboxEntry(BaseModel):
+    """A mailbox change queued while Outlook was unavailable."""
+    id: int
+    operation: str
+    email_id: str
+    parameters: Dict[str, Any] = {}
+    status: str = Field(..., description="pending, or dead once it failed outbox_max_attempts times")
+    attempts: int = Field(0, description="Failed attempts to apply the change after it was queued")
+    next_retry_at: datetime
+    last_error: Optional[str] = None
+    created_at: datetime
+    updated_at: datetime
+
+
+class OutboxListResponse(BaseModel):
+    """Queued mailbox changes, oldest first."""
+    entries: List[OutboxEntry]
+    pending: int
+    dead: int
+
+
+class MailboxWarning(BaseModel):
+    """A mailbox left out of a unified listing because it failed."""
+    mailbox: str
+    error: str
+
+
+class UnifiedEmailListResponse(BaseModel):
+    """One page of emails merged across mailboxes."""
+    emails: List[Dict[str, Any]]
+    next_cursor: Optional[str] = Field(None, description="Cursor for the next page; None on the last page")
+    has_more: bool = False
+    warnings: List[MailboxWarning] = []
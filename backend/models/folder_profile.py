@@ -0,0 +1,84 @@
+"""Folder profile models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import List, Optional
+from pydantic import BaseModel, Field, field_validator
+
+# Pipeline stages a profile can select, in processing order
+PIPELINE_STAGES = ["email_analysis", "task_extraction", "categorization"]
+
+
+def normalize_folder_path(path: str) -> str:
+    """Normalize a folder path to slash-separated form without outer slashes."""
+    return "/".join(part.strip() for part in path.replace("\\", "/").split("/") if part.strip())
+
+
+def validate_stages(stages: Optional[List[str]]) -> Optional[List[str]]:
+    """Reject unknown stages and return the rest in processing order."""
+    if stages is None:
+        return None
+    unknown = sorted(set(stages) - set(PIPELINE_STAGES))
+    if unknown:
+        raise ValueError(
+            f"Unknown stages: {', '.join(unknown)} (expected {', '.join(PIPELINE_STAGES)})"
+        )
+    if not stages:
+        raise ValueError("At least one stage is required")
+    return [stage for stage in PIPELINE_STAGES if stage in stages]
+
+
+class FolderProfileBase(BaseModel):
+    """Processing defaults for one mail folder."""
+    folder_path: str = Field(..., min_length=1, max_length=500, description="e.g. Inbox/Recruiters")
+    default_stages: List[str] = Field(default_factory=lambda: list(PIPELINE_STAGES))
+    default_deployment: Optional[str] = Field(None, description="Azure OpenAI deployment")
+    auto_apply_to_outlook: bool = False
+
+    @field_validator("folder_path")
+    @classmethod
+    def _normalize_folder(cls, value: str) -> str:
+        normalized = normalize_folder_path(value)
+        if not normalized:
+            raise ValueError("folder_path cannot be empty")
+        return normalized
+
+    @field_validator("default_stages")
+    @classmethod
+    def _check_stages(cls, value: List[str]) -> List[str]:
+        return validate_stages(value)
+
+
+class FolderProfileCreate(FolderProfileBase):
+    """Folder profile creation model."""
+
+
+class FolderProfileUpdate(BaseModel):
+    """Folder profile update model."""
+    folder_path: Optional[str] = Field(None, min_length=1, max_length=500)
+    default_stages: Optional[List[str]] = None
+    default_deployment: Optional[str] = None
+    auto_apply_to_outlook: Optional[bool] = None
+
+    @field_validator("folder_path")
+    @classmethod
+    def _normalize_folder(cls, value: Optional[str]) -> Optional[str]:
+        if value is None:
+            return None
+        normalized = normalize_folder_path(value)
+        if not normalized:
+            raise ValueError("folder_path cannot be empty")
+        return normalized
+
+    @field_validator("default_stages")
+    @classmethod
+    def _check_stages(cls, value: Optional[List[str]]) -> Optional[List[str]]:
+        return validate_stages(value)
+
+
+class FolderProfile(FolderProfileBase):
+    """Folder profile model for API responses."""
+    id: int
+    created_at: datetime
+    updated_at: datetime
+
+    model_config = {"from_attributes": True}
@@ -28,6 +28,7 @@ class GraphMessage(BaseModel):
     subject: Optional[str] = None
     from_: Optional[GraphRecipient] = Field(None, alias="from")
     toRecipients: List[GraphRecipient] = []
+    ccRecipients: List[GraphRecipient] = []
     receivedDateTime: datetime
     hasAttachments: bool = False
     isRead: bool = False
@@ -77,6 +78,11 @@ class EmailNormalizer:
         for recipient in to_recipients:
             if "emailAddress" in recipient:
                 recipients.append(recipient["emailAddress"].get("address", ""))
+        cc = [
+            recipient["emailAddress"].get("address", "")
+            for recipient in graph_message.get("ccRecipients", [])
+            if "emailAddress" in recipient
+        ]
         
         # Parse body content
         body_content = ""
@@ -106,6 +112,9 @@ class EmailNormalizer:
             "sender_name": sender_name,
             "recipient": recipients[0] if recipients else "",
             "recipients": recipients,
+            "to": recipients,
+            "cc": cc,
+            "recipient_count": len(recipients) + len(cc),
             "body": body_content,
             "received_time": received_time,
             "received_datetime": received_datetime,
@@ -114,9 +123,18 @@ class EmailNormalizer:
             "importance": graph_message.get("importance", "normal"),
             "is_read": graph_message.get("isRead", False),
             "has_attachments": graph_message.get("hasAttachments", False),
+            "message_class": EmailNormalizer.message_class(graph_message),
             "folder": "Inbox"  # Default folder, can be overridden
         }
     
+    @staticmethod
+    def message_class(graph_message: Dict[str, Any]) -> str:
+        """Read the MessageClass from the expanded singleValueExtendedProperties, or ""."""
+        for prop in graph_message.get("singleValueExtendedProperties") or []:
+            if str(prop.get("id", "")).lower() in ("string 0x1a", "string 0x001a"):
+                return prop.get("value") or ""
+        return ""
+    
     @staticmethod
     def normalize_graph_folder(graph_folder: Dict[str, Any]) -> Dict[str, str]:
         """Convert Graph API folder to internal folder format.
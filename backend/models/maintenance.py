@@ -0,0 +1,17 @@
+"""Database maintenance models for FastAPI Email Helper API."""
+
+from typing import Optional
+from pydantic import BaseModel, Field
+
+
+class MaintenanceReport(BaseModel):
+    """What a database maintenance pass did."""
+    vacuum: str = Field(..., description="full, incremental, or none")
+    fragmentation: float = Field(..., description="Share of free pages before the pass (freelist_count / page_count)")
+    freelist_pages_before: int = 0
+    freelist_pages_after: int = 0
+    size_before_bytes: int = 0
+    size_after_bytes: int = 0
+    reclaimed_bytes: int = 0
+    duration_ms: float = 0.0
+    auto_vacuum: Optional[str] = Field(None, description="The database's auto_vacuum mode after the pass")
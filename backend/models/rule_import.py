@@ -0,0 +1,40 @@
+"""Outlook rule import models for FastAPI Email Helper API."""
+
+from typing import List
+from pydantic import BaseModel, Field
+
+
+class RuleImportCandidate(BaseModel):
+    """An Outlook rule that can become a classification rule of a category."""
+    outlook_rule: str = Field(..., description="Name of the Outlook rule")
+    enabled: bool = True
+    category: str = Field(..., description="Category whose folder the Outlook rule moves mail into")
+    folder: str
+    senders: List[str] = []
+    subject_contains: List[str] = []
+    rule: str = Field(..., description="Line added to the category's classification rules")
+
+
+class UnmappableRule(BaseModel):
+    """An Outlook rule that cannot be imported, and why."""
+    outlook_rule: str
+    reasons: List[str]
+
+
+class RuleImportCandidatesResponse(BaseModel):
+    """The mail client's rules, split into importable and not."""
+    candidates: List[RuleImportCandidate]
+    unmappable: List[UnmappableRule]
+
+
+class RuleImportRequest(BaseModel):
+    """Outlook rules to import, by name."""
+    rules: List[str] = Field(..., min_length=1, description="Names of import candidates")
+
+
+class RuleImportResponse(BaseModel):
+    """Result of importing Outlook rules."""
+    imported: List[RuleImportCandidate]
+    skipped: List[UnmappableRule] = Field(
+        default=[], description="Requested rules that were not imported: unknown, unmappable, or already present"
+    )
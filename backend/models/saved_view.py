@@ -0,0 +1,58 @@
+"""Saved view models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import Any, Dict, Optional
+from pydantic import BaseModel, Field, field_validator
+
+from backend.core.email_filters import DEFAULT_SORT, validate_email_filters, validate_sort
+
+
+class SavedViewBase(BaseModel):
+    """A named email filter combination."""
+    name: str = Field(..., min_length=1, max_length=100)
+    filters: Dict[str, Any] = Field(
+        default_factory=dict, description="GET /api/emails filters, e.g. {\"unread\": true}"
+    )
+    sort: str = DEFAULT_SORT
+    icon: Optional[str] = Field(None, max_length=50)
+
+    @field_validator("filters")
+    @classmethod
+    def _check_filters(cls, value: Dict[str, Any]) -> Dict[str, Any]:
+        return validate_email_filters(value)
+
+    @field_validator("sort")
+    @classmethod
+    def _check_sort(cls, value: str) -> str:
+        return validate_sort(value)
+
+
+class SavedViewCreate(SavedViewBase):
+    """Saved view creation model."""
+
+
+class SavedViewUpdate(BaseModel):
+    """Saved view update model."""
+    name: Optional[str] = Field(None, min_length=1, max_length=100)
+    filters: Optional[Dict[str, Any]] = None
+    sort: Optional[str] = None
+    icon: Optional[str] = Field(None, max_length=50)
+
+    @field_validator("filters")
+    @classmethod
+    def _check_filters(cls, value: Optional[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+        return None if value is None else validate_email_filters(value)
+
+    @field_validator("sort")
+    @classmethod
+    def _check_sort(cls, value: Optional[str]) -> Optional[str]:
+        return None if value is None else validate_sort(value)
+
+
+class SavedView(SavedViewBase):
+    """Saved view model for API responses."""
+    id: int
+    created_at: datetime
+    updated_at: datetime
+
+    model_config = {"from_attributes": True}
@@ -0,0 +1,26 @@
+"""Scheduled background job models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import List, Optional
+from pydantic import BaseModel, Field
+
+
+class ScheduledJobStatus(BaseModel):
+    """State of one periodic background job."""
+    name: str
+    description: str = ""
+    interval_seconds: float
+    running: bool = False
+    run_count: int = Field(0, description="Passes finished, successful or not")
+    failure_count: int = 0
+    skipped_count: int = Field(0, description="Passes skipped because the previous one was still running")
+    last_started_at: Optional[datetime] = None
+    last_finished_at: Optional[datetime] = None
+    last_duration_ms: Optional[float] = None
+    last_error: Optional[str] = Field(None, description="Error of the latest pass; None when it succeeded")
+    next_run_at: Optional[datetime] = Field(None, description="When the next scheduled pass starts; None when stopped")
+
+
+class ScheduledJobListResponse(BaseModel):
+    """Every registered background job."""
+    jobs: List[ScheduledJobStatus]
@@ -0,0 +1,80 @@
+"""Classification snapshot models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import List, Optional
+from pydantic import BaseModel, Field, field_validator
+
+
+class SnapshotCreate(BaseModel):
+    """Name of a new classification snapshot."""
+    name: str = Field(..., min_length=1, max_length=100, description="Unique per user, e.g. before-new-prompt")
+
+    @field_validator("name")
+    @classmethod
+    def _check_name(cls, value: str) -> str:
+        if not value.strip():
+            raise ValueError("name cannot be blank")
+        return value.strip()
+
+
+class Snapshot(BaseModel):
+    """A stored copy of a user's classifications."""
+    id: int
+    name: str
+    email_count: int = Field(..., description="Stored emails the snapshot holds")
+    created_at: datetime
+
+
+class SnapshotListResponse(BaseModel):
+    """A user's classification snapshots, newest first."""
+    snapshots: List[Snapshot] = []
+    total: int = 0
+
+
+class CategoryChurn(BaseModel):
+    """How a category's emails moved between a snapshot and now."""
+    category: str
+    before: int = Field(0, description="Emails in the category in the snapshot")
+    after: int = Field(0, description="Stored emails in the category now")
+    left: int = Field(0, description="Snapshot emails of the category now in another one")
+    joined: int = Field(0, description="Snapshot emails of another category now in this one")
+    churn_rate: float = Field(0.0, description="left / before")
+
+
+class SnapshotChange(BaseModel):
+    """A snapshot email whose category, AI category, or folder differs now."""
+    email_id: str
+    subject: Optional[str] = None
+    before_category: Optional[str] = None
+    after_category: Optional[str] = None
+    before_ai_category: Optional[str] = None
+    after_ai_category: Optional[str] = None
+    before_folder: Optional[str] = None
+    after_folder: Optional[str] = None
+
+
+class SnapshotComparison(BaseModel):
+    """A snapshot diffed against the current classifications."""
+    snapshot: Snapshot
+    compared: int = Field(0, description="Snapshot emails still stored")
+    removed: int = Field(0, description="Snapshot emails no longer stored")
+    added: int = Field(0, description="Stored emails the snapshot does not hold")
+    changed: int = Field(0, description="Compared emails with any difference")
+    recategorized: int = Field(0, description="Compared emails whose category differs")
+    churn_rate: float = Field(0.0, description="recategorized / compared")
+    categories: List[CategoryChurn] = []
+    changes: List[SnapshotChange] = []
+    changes_truncated: bool = Field(False, description="True if changes lists only the first limit changes")
+
+
+class SnapshotRestoreRequest(BaseModel):
+    """Options for writing a snapshot's categories back."""
+    dry_run: bool = Field(default=False, description="Only list the emails whose category would change")
+
+
+class SnapshotRestoreResponse(BaseModel):
+    """Emails whose stored category was set back to the snapshot's."""
+    snapshot_id: int
+    dry_run: bool
+    restored: List[str] = Field(default=[], description="Emails set back (or that would be, for a dry run)")
+    missing: List[str] = Field(default=[], description="Snapshot emails no longer stored")
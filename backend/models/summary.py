@@ -0,0 +1,44 @@
+"""Summary models for FastAPI Email Helper API."""
+
+from datetime import datetime
+from typing import Optional
+from pydantic import BaseModel, Field
+
+
+class EmailSummary(BaseModel):
+    """Email counts that need the user's attention."""
+    unread_required_personal_action: int = 0
+    needs_review: int = 0
+    blocking_others: int = 0  # Open blocking items (see GET /api/emails/blocking)
+    as_of: datetime
+
+
+class TaskSummary(BaseModel):
+    """Open tasks that are late or due today."""
+    overdue: int = 0
+    due_today: int = 0
+    as_of: datetime
+
+
+class PipelineSummary(BaseModel):
+    """The user's most recent processing pipeline."""
+    pipeline_id: Optional[str] = None
+    status: Optional[str] = Field(None, description="running, completed, failed, or cancelled; None if never run")
+    last_run_at: Optional[str] = Field(None, description="When the run finished, or started if still running")
+    as_of: datetime
+
+
+class AIHealthSummary(BaseModel):
+    """Whether AI processing is available."""
+    status: str = Field(..., description="healthy, unhealthy, or auth_error")
+    error: Optional[str] = None
+    as_of: datetime
+
+
+class SystemSummary(BaseModel):
+    """Everything a tray icon needs in one poll."""
+    emails: EmailSummary
+    tasks: TaskSummary
+    pipeline: PipelineSummary
+    ai: AIHealthSummary
+    generated_at: datetime
@@ -1,10 +1,12 @@
 """Task models for FastAPI Email Helper API."""
 
 from datetime import datetime
-from typing import Optional
-from pydantic import BaseModel, Field
+from typing import List, Optional
+from pydantic import BaseModel, Field, field_validator
 from enum import Enum
 
+from backend.models.email import BatchItemResult
+
 
 class TaskStatus(str, Enum):
     """Task status enumeration."""
@@ -34,6 +36,14 @@ class TaskBase(BaseModel):
 class TaskCreate(TaskBase):
     """Task creation model."""
     email_id: Optional[str] = None
+    source: Optional[str] = None
+
+    @field_validator("title")
+    @classmethod
+    def _check_title(cls, value: str) -> str:
+        if not value.strip():
+            raise ValueError("title cannot be blank")
+        return value
 
 
 class TaskUpdate(BaseModel):
@@ -45,6 +55,13 @@ class TaskUpdate(BaseModel):
     due_date: Optional[datetime] = None
     email_id: Optional[str] = None
 
+    @field_validator("title")
+    @classmethod
+    def _check_title(cls, value: Optional[str]) -> Optional[str]:
+        if value is not None and not value.strip():
+            raise ValueError("title cannot be blank")
+        return value
+
 
 class TaskListResponse(BaseModel):
     """Response model for paginated task lists."""
@@ -73,15 +90,89 @@ class TaskInDB(TaskBase):
     updated_at: datetime
     email_id: Optional[str] = None
     user_id: Optional[int] = None
+    source: Optional[str] = None
 
     model_config = {"from_attributes": True}
 
 
+class TaskEmail(BaseModel):
+    """The stored email a task was created from, embedded with GET /tasks?include=email."""
+    id: str
+    subject: Optional[str] = None
+    sender: Optional[str] = None
+    received_time: Optional[datetime] = None
+    one_line_summary: Optional[str] = None
+
+
 class Task(TaskBase):
     """Task model for API responses."""
     id: int
     created_at: datetime
     updated_at: datetime
     email_id: Optional[str] = None
+    source: Optional[str] = None
+    # Only set (and serialized) when the email is requested; None when it is not stored
+    email: Optional[TaskEmail] = None
+
+    model_config = {"from_attributes": True}
 
-    model_config = {"from_attributes": True}
\ No newline at end of file
+class TaskBatchItemError(BaseModel):
+    """Why one task of a bulk operation failed."""
+    task_id: int
+    error: str
+    status_code: int = Field(..., description="HTTP status of this item")
+    is_retryable: bool = Field(False, description="Whether resending this item unchanged may succeed")
+
+
+class BulkTaskUpdateResponse(BaseModel):
+    """Result of a bulk task update."""
+    tasks: list[Task]
+    success_count: int
+    failure_count: int
+    errors: list[TaskBatchItemError] = []
+
+
+class BulkTaskDeleteResponse(BaseModel):
+    """Result of a bulk task deletion."""
+    message: str
+    deleted_count: int
+    failure_count: int
+    errors: list[TaskBatchItemError] = []
+
+
+class TaskExtractionRequest(BaseModel):
+    """Stored emails to extract tasks from."""
+    email_ids: List[str] = Field(..., min_length=1, max_length=50, description="Stored emails, results keep this order")
+    context: Optional[str] = Field(None, description="Additional context for extraction")
+
+
+class TaskExtractionResult(BatchItemResult):
+    """Tasks made from one email of a batch extraction."""
+    tasks: List[Task] = Field(default=[], description="Tasks created from the email's action items")
+    duplicates_skipped: List[str] = Field(
+        default=[], description="Action items the email already had a task for, or that repeat another item"
+    )
+    confidence: Optional[float] = Field(None, ge=0.0, le=1.0, description="Action item extraction confidence")
+    redactions_applied: int = Field(0, description="Secrets masked in the email text sent to the AI")
+
+
+class TaskExtractionSummary(BaseModel):
+    """Totals of a batch extraction."""
+    email_count: int = 0
+    tasks_created: int = 0
+    duplicates_skipped: int = 0
+    failure_count: int = 0
+    skipped_count: int = 0
+
+
+class TaskExtractionResponse(BaseModel):
+    """Per-email results of a batch task extraction."""
+    results: List[TaskExtractionResult] = []
+    summary: TaskExtractionSummary
+    tasks: List[Task] = Field(
+        default=[], description="Deprecated: every created task in one list, kept for older clients; read results"
+    )
+    processing_time: float = Field(..., description="Processing time in seconds")
+    queue_wait_time: float = Field(
+        0.0, description="Seconds the AI calls spent waiting in the AI request queue, added up"
+    )
@@ -0,0 +1,44 @@
+"""Training data export and offline evaluation models for FastAPI Email Helper API."""
+
+from typing import List, Optional
+from pydantic import BaseModel, Field
+
+MAX_EVALUATION_SAMPLE = 200
+
+
+class EvaluationRequest(BaseModel):
+    """Run the current classifier over a sample of human-labeled emails."""
+    sample_size: int = Field(50, ge=1, le=MAX_EVALUATION_SAMPLE, description="Labeled emails to classify")
+    seed: Optional[int] = Field(None, description="Seed for the sample; the same seed picks the same emails")
+
+
+class CategoryAgreement(BaseModel):
+    """How often the classifier agreed with the human label of one category."""
+    category: str
+    emails: int = Field(..., description="Sampled emails with this human label")
+    agreed: int
+    agreement: Optional[float] = Field(None, description="agreed / emails; None without classified emails")
+
+
+class EvaluationDisagreement(BaseModel):
+    """A sampled email the classifier labeled differently from the user."""
+    email_id: str
+    category: str = Field(..., description="Human-confirmed category")
+    predicted: str = Field(..., description="Category the current classifier gave")
+    confidence: float
+
+
+class EvaluationReport(BaseModel):
+    """Agreement of the current classifier with human labels on a sample."""
+    prompt_version: str = Field(..., description="Classifier prompt the sample was classified with")
+    labeled: int = Field(..., description="Human-labeled emails the sample was drawn from")
+    sampled: int
+    evaluated: int = Field(..., description="Sampled emails the classifier returned a category for")
+    failed: int = Field(0, description="Sampled emails the classifier failed on")
+    agreed: int
+    agreement: Optional[float] = Field(None, description="agreed / evaluated")
+    stored_agreement: Optional[float] = Field(
+        None, description="How often the stored AI categories of the same emails agreed, for comparison"
+    )
+    categories: List[CategoryAgreement] = []
+    disagreements: List[EvaluationDisagreement] = []
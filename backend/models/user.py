@@ -2,7 +2,9 @@
 
 from datetime import datetime
 from typing import Optional
-from pydantic import BaseModel, EmailStr, Field
+from pydantic import BaseModel, EmailStr, Field, field_validator
+
+from backend.core.clock import parse_timezone
 
 
 class UserBase(BaseModel):
@@ -60,4 +62,21 @@ class Token(BaseModel):
 class TokenData(BaseModel):
     """Token payload data."""
     username: Optional[str] = None
-    user_id: Optional[int] = None
\ No newline at end of file
+    user_id: Optional[int] = None
+
+class UserSettings(BaseModel):
+    """Per-user settings."""
+    timezone: Optional[str] = Field(None, description="IANA time zone; null uses settings.timezone")
+    effective_timezone: Optional[str] = Field(
+        None, description="Zone today and due dates are computed in; null is the server's local time"
+    )
+
+
+class UserSettingsUpdate(BaseModel):
+    """Per-user settings update model."""
+    timezone: Optional[str] = Field(None, description="IANA time zone, e.g. Europe/Berlin; null clears it")
+
+    @field_validator("timezone")
+    @classmethod
+    def _check_timezone(cls, value: Optional[str]) -> Optional[str]:
+        return parse_timezone(value).key if value and value.strip() else None
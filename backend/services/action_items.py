@@ -0,0 +1,157 @@
+"""Action item parsing for FastAPI Email Helper API.
+
+summerize_action_item.prompty returns an action_items array of structured
+items (description, owner, deadline, source_quote, links) alongside the
+single-action fields older callers read. parse_action_items normalizes
+whatever the model returned into that item shape, falling back to the
+single action_required field for outputs without the array, and
+task_fields_for_item turns an item into the priority and due date of the
+task created from it, filling in the defaults settings.task_defaults gives
+the email's category. Settings are read on every call, so changed
+defaults apply to the next task created. Due dates are computed in the
+user's time zone when a Clock is passed (see backend.core.clock).
+"""
+
+import re
+from datetime import date, datetime, time
+from typing import Any, Dict, List, Optional
+
+from backend.core.business_days import add_business_days, parse_holidays
+from backend.core.clock import Clock, clock_for
+from backend.core.config import settings
+from backend.models.ai_models import ACTION_ITEM_OWNERS, DEFAULT_ACTION_ITEM_OWNER
+from backend.models.task import TaskPriority
+
+# Task priority for the person an action item falls to
+OWNER_PRIORITY = {
+    "me": TaskPriority.HIGH,
+    "team": TaskPriority.MEDIUM,
+    "other": TaskPriority.LOW,
+}
+
+# Other words the model uses for each owner
+_OWNER_ALIASES = {
+    "user": "me", "you": "me", "self": "me", "personal": "me",
+    "us": "team", "we": "team", "group": "team",
+}
+
+_ISO_DATE = re.compile(r"^\d{4}-\d{2}-\d{2}")
+
+
+def normalize_owner(value: Any) -> str:
+    """Map a model-supplied owner to me, team, or other (default me)."""
+    owner = str(value or "").strip().lower()
+    if not owner:
+        return DEFAULT_ACTION_ITEM_OWNER
+    owner = _OWNER_ALIASES.get(owner, owner)
+    return owner if owner in ACTION_ITEM_OWNERS else "other"
+
+
+def parse_deadline(value: Any) -> Optional[datetime]:
+    """Parse an ISO 8601 deadline; phrases like "End of week" give None.
+
+    Date-only deadlines mean the end of that day.
+    """
+    if isinstance(value, datetime):
+        return value
+    if isinstance(value, date):
+        return datetime.combine(value, time(23, 59, 59))
+    if not isinstance(value, str) or not _ISO_DATE.match(value.strip()):
+        return None
+    text = value.strip()
+    try:
+        if len(text) == 10:
+            return datetime.combine(date.fromisoformat(text), time(23, 59, 59))
+        parsed = datetime.fromisoformat(text.replace("Z", "+00:00"))
+    except ValueError:
+        return None
+    return parsed.replace(tzinfo=None) if parsed.tzinfo else parsed
+
+
+def normalize_action_item(item: Any) -> Optional[Dict[str, Any]]:
+    """Normalize one extracted item, or None if it has no description.
+
+    Plain strings are accepted as items owned by the user.
+    """
+    if isinstance(item, str):
+        item = {"description": item}
+    if not isinstance(item, dict):
+        return None
+    description = " ".join(str(item.get("description") or "").split())
+    if not description:
+        return None
+    links = item.get("links")
+    return {
+        "description": description,
+        "owner": normalize_owner(item.get("owner")),
+        "deadline": parse_deadline(item.get("deadline")),
+        "source_quote": (item.get("source_quote") or "").strip() or None,
+        "links": [link for link in links if isinstance(link, str)] if isinstance(links, list) else [],
+    }
+
+
+def parse_action_items(result: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Extract normalized action items from action item prompt output.
+
+    Outputs without an action_items array (older prompt versions and the
+    AIProcessor fallbacks) yield a single item built from action_required,
+    due_date, and links.
+    """
+    items = result.get("action_items")
+    if not isinstance(items, list):
+        if not result.get("action_required"):
+            return []
+        items = [{
+            "description": result["action_required"],
+            "deadline": result.get("due_date"),
+            "links": result.get("links"),
+        }]
+    return [normalized for normalized in map(normalize_action_item, items) if normalized]
+
+
+def category_task_fields(
+    category: Optional[str],
+    now: Optional[datetime] = None,
+    clock: Optional[Clock] = None
+) -> Dict[str, Any]:
+    """Default fields settings.task_defaults gives tasks from a category's emails.
+
+    Business days are counted from today in the clock's zone (default
+    settings.timezone) and the due date is the end of that day there.
+
+    Returns:
+        priority and (end of day) due_date, each only if the category's
+        entry sets it; empty for categories without one
+    """
+    clock = clock or clock_for(settings.timezone, now)
+    defaults = (settings.task_defaults.get(category) or {}) if category else {}
+    fields: Dict[str, Any] = {}
+    if defaults.get("priority"):
+        fields["priority"] = TaskPriority(defaults["priority"])
+    if defaults.get("due_in_business_days") is not None:
+        due = add_business_days(
+            clock.today(), defaults["due_in_business_days"], parse_holidays(settings.task_holidays)
+        )
+        fields["due_date"] = clock.at(due, time(23, 59, 59))
+    return fields
+
+
+def task_fields_for_item(
+    item: Dict[str, Any],
+    category: Optional[str] = None,
+    now: Optional[datetime] = None,
+    clock: Optional[Clock] = None
+) -> Dict[str, Any]:
+    """Priority and due date for the task created from a normalized item.
+
+    The item's deadline is the due date, read as a wall-clock time in the
+    clock's zone, and its owner sets the priority. The email category's
+    task defaults (see category_task_fields) replace the owner's priority
+    and give items without a deadline their due date.
+    """
+    clock = clock or clock_for(settings.timezone, now)
+    defaults = category_task_fields(category, clock=clock)
+    return {
+        "priority": defaults.get("priority", OWNER_PRIORITY[item["owner"]]),
+        "due_date": clock.localize(item["deadline"]) if item["deadline"] else defaults.get("due_date"),
+    }
@@ -0,0 +1,191 @@
+"""Outlook activity log for FastAPI Email Helper API.
+
+Every mailbox change EmailService makes (moving, categorizing, marking
+read, deleting, drafting replies, creating categories, applying a holistic
+analysis run's cleanups) is recorded in the outlook_activity table with its
+parameters, result, duration, and the ID of the request that caused it, so
+what the tool did to a mailbox can be reconstructed afterwards. The log is mailbox-wide: providers act on the one
+signed-in mailbox, not on a user's stored emails.
+
+Recording never fails the change it describes; a failed write is logged
+and dropped. Entries older than settings.activity_retention_days are
+pruned periodically while the API runs.
+"""
+
+import asyncio
+import json
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional, Tuple
+
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.activity import ActivityEntry
+
+logger = logging.getLogger(__name__)
+
+# Mailbox changes recorded by EmailService, by operation name
+ACTIVITY_OPERATIONS = (
+    "mark_as_read", "move_email", "move_emails", "categorize_email", "ensure_categories", "create_draft_reply",
+    "delete_email", "apply_holistic_analysis"
+)
+
+# Results of a recorded operation
+RESULT_SUCCESS = "success"
+RESULT_FAILED = "failed"  # The provider declined without raising (returned False or None)
+RESULT_ERROR = "error"
+RESULT_CANCELLED = "cancelled"
+
+
+class ActivityLog:
+    """Store for Outlook activity log entries."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the activity log.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def record(
+        self,
+        operation: str,
+        email_id: Optional[str],
+        parameters: Dict[str, Any],
+        result: str,
+        duration_ms: float,
+        request_id: Optional[str] = None,
+        error: Optional[str] = None
+    ) -> None:
+        """Record one mailbox change, logging instead of raising on failure.
+
+        Blocking; EmailService calls it from the thread pool.
+
+        Args:
+            operation: One of ACTIVITY_OPERATIONS
+            email_id: Email the change applied to, if any
+            parameters: Arguments of the change (JSON serializable)
+            result: RESULT_SUCCESS, RESULT_FAILED, RESULT_ERROR, or RESULT_CANCELLED
+            duration_ms: Time the provider call took
+            request_id: ID of the API request that made the change
+            error: Error message when result is RESULT_ERROR
+        """
+        try:
+            with self.db.get_connection() as conn:
+                conn.execute(
+                    """
+                    INSERT INTO outlook_activity
+                        (operation, email_id, parameters, result, error, duration_ms, request_id, created_at)
+                    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+                    """,
+                    (
+                        operation,
+                        email_id,
+                        json.dumps(parameters, sort_keys=True, default=str),
+                        result,
+                        error,
+                        round(duration_ms, 3),
+                        request_id,
+                        datetime.utcnow(),
+                    )
+                )
+                conn.commit()
+        except Exception as e:
+            logger.error(f"Failed to record {operation} of {email_id} in the activity log: {e}")
+
+    def _filter(
+        self,
+        operation: Optional[str],
+        since: Optional[datetime],
+        until: Optional[datetime]
+    ) -> Tuple[str, List[Any]]:
+        """WHERE clause and parameters for list filters."""
+        where, params = "1 = 1", []
+        if operation:
+            where += " AND operation = ?"
+            params.append(operation)
+        if since is not None:
+            where += " AND created_at >= ?"
+            params.append(since)
+        if until is not None:
+            where += " AND created_at < ?"
+            params.append(until)
+        return where, params
+
+    async def list_activity(
+        self,
+        operation: Optional[str] = None,
+        since: Optional[datetime] = None,
+        until: Optional[datetime] = None,
+        limit: int = 50,
+        offset: int = 0
+    ) -> Tuple[List[ActivityEntry], int]:
+        """List activity log entries, newest first.
+
+        Args:
+            operation: Only entries for this operation
+            since: Only entries recorded at or after this time (UTC)
+            until: Only entries recorded before this time (UTC)
+            limit: Most entries to return
+            offset: Entries to skip
+
+        Returns:
+            The page of entries and the total matching the filters
+        """
+        loop = asyncio.get_event_loop()
+        where, params = self._filter(operation, since, until)
+
+        def _list_activity_sync():
+            with self.db.get_connection() as conn:
+                total = conn.execute(f"SELECT COUNT(*) FROM outlook_activity WHERE {where}", params).fetchone()[0]
+                rows = conn.execute(
+                    f"""
+                    SELECT * FROM outlook_activity WHERE {where}
+                    ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?
+                    """,
+                    [*params, limit, offset]
+                ).fetchall()
+            return [self._row_to_entry(row) for row in rows], total
+
+        return await loop.run_in_executor(None, _list_activity_sync)
+
+    async def prune(self, retention_days: int, now: Optional[datetime] = None) -> int:
+        """Delete entries older than the retention period.
+
+        Args:
+            retention_days: Days entries are kept (0 keeps them forever)
+            now: Reference time (defaults to now, UTC)
+
+        Returns:
+            Number of entries deleted
+        """
+        if retention_days <= 0:
+            return 0
+        loop = asyncio.get_event_loop()
+        cutoff = (now or datetime.utcnow()) - timedelta(days=retention_days)
+
+        def _prune_sync():
+            with self.db.get_connection() as conn:
+                deleted = conn.execute("DELETE FROM outlook_activity WHERE created_at < ?", (cutoff,)).rowcount
+                conn.commit()
+            return deleted
+
+        return await loop.run_in_executor(None, _prune_sync)
+
+    def _row_to_entry(self, row) -> ActivityEntry:
+        """Convert a database row to an ActivityEntry."""
+        return ActivityEntry(**{**dict(row), "parameters": json.loads(row["parameters"])})
+
+
+async def run_prune(activity_log: ActivityLog, retention_days: int) -> int:
+    """Prune expired activity log entries once (the activity_prune background job)."""
+    pruned = await activity_log.prune(retention_days)
+    if pruned:
+        logger.info(f"Pruned {pruned} activity log entries")
+    return pruned
+
+
+# Dependency for FastAPI
+def get_activity_log() -> ActivityLog:
+    """FastAPI dependency for the activity log."""
+    return ActivityLog()
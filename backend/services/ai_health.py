@@ -0,0 +1,77 @@
+"""AI service health tracking.
+
+The AI health check and AI calls report whether the AI service worked;
+when the status changes (healthy, unhealthy, or auth_error when Azure
+rejected the credential) an ai_health_changed notification goes to every
+connected user, and /health reports the last known status.
+"""
+
+from datetime import datetime
+from typing import Any, Dict, Optional
+
+from backend.services.event_bus import notify
+
+
+class AIHealthMonitor:
+    """Last known AI health state."""
+
+    def __init__(self):
+        self.reset()
+
+    def reset(self) -> None:
+        """Forget the last known state."""
+        self.healthy: Optional[bool] = None
+        self.status: Optional[str] = None
+        self.since: Optional[datetime] = None
+        self.last_error: Optional[str] = None
+
+    async def record(self, healthy: bool, error: Optional[str] = None, auth_error: bool = False) -> bool:
+        """Record an observed AI state.
+
+        The first observation only notifies when it is unhealthy, so a
+        normal startup stays quiet.
+
+        Args:
+            healthy: Whether the AI call worked
+            error: Why it failed
+            auth_error: It failed because Azure rejected the credential
+
+        Returns:
+            Whether a notification was published
+        """
+        previous = self.status
+        status = "healthy" if healthy else ("auth_error" if auth_error else "unhealthy")
+        self.last_error = None if healthy else error
+        if previous == status:
+            return False
+        self.healthy = healthy
+        self.status = status
+        self.since = datetime.utcnow()
+        if previous is None and healthy:
+            return False
+
+        titles = {
+            "healthy": "AI service recovered",
+            "unhealthy": "AI service unavailable",
+            "auth_error": "AI credential expired",
+        }
+        await notify(
+            "ai_health_changed",
+            titles[status],
+            "AI processing is working again" if healthy else (error or "AI processing is failing"),
+            status=status,
+            error=self.last_error
+        )
+        return True
+
+    def snapshot(self) -> Dict[str, Any]:
+        """Last known state for health reporting."""
+        return {
+            "status": self.status or "unknown",
+            "since": self.since.isoformat() if self.since else None,
+            "last_error": self.last_error,
+        }
+
+
+# Global AI health monitor
+ai_health = AIHealthMonitor()
@@ -0,0 +1,174 @@
+"""Azure OpenAI request queue for FastAPI Email Helper API.
+
+Every AI call (AIService and COMAIService run them all through their
+_run_in_executor) takes a slot on the deployment it is sent to before it
+runs. At most settings.ai_max_in_flight calls per deployment are in
+flight at once; the others wait in first-come order, so a burst from
+batch processing, summary backfill, and the AI endpoints together is
+smoothed at the client whichever caller started it. A caller cancelled
+while waiting leaves the queue without ever taking a slot.
+
+How long calls waited is added to the processing time metadata of the AI
+endpoints (queue_wait_time, see track_queue_wait), and /health reports
+each deployment's queue under ai.queue.
+"""
+
+import asyncio
+import time
+from collections import deque
+from contextlib import asynccontextmanager, contextmanager
+from contextvars import ContextVar
+from dataclasses import dataclass
+from typing import Any, AsyncIterator, Deque, Dict, Iterator, Optional
+
+from backend.core.config import settings
+
+
+@dataclass
+class QueueWait:
+    """Seconds AI calls made inside track_queue_wait spent in the queue."""
+    seconds: float = 0.0
+
+
+_queue_wait: ContextVar[Optional[QueueWait]] = ContextVar("ai_queue_wait", default=None)
+
+
+@contextmanager
+def track_queue_wait() -> Iterator[QueueWait]:
+    """Add up the queue wait of the AI calls made inside the block.
+
+    Tasks started inside the block (e.g. a batch's concurrent summaries)
+    add to the same total.
+    """
+    wait = QueueWait()
+    token = _queue_wait.set(wait)
+    try:
+        yield wait
+    finally:
+        _queue_wait.reset(token)
+
+
+class DeploymentQueue:
+    """In-flight calls, waiting callers, and wait statistics of one deployment."""
+
+    def __init__(self):
+        self.in_flight = 0
+        self.waiters: Deque[asyncio.Future] = deque()
+        self.requests = 0
+        self.peak_in_flight = 0
+        self.total_wait = 0.0
+        self.max_wait = 0.0
+
+    def snapshot(self) -> Dict[str, Any]:
+        """Queue state for metrics."""
+        return {
+            "in_flight": self.in_flight,
+            "queued": sum(1 for waiter in self.waiters if not waiter.done()),
+            "peak_in_flight": self.peak_in_flight,
+            "requests": self.requests,
+            "average_wait_seconds": round(self.total_wait / self.requests, 3) if self.requests else 0.0,
+            "max_wait_seconds": round(self.max_wait, 3),
+        }
+
+
+class AIRequestQueue:
+    """Per-deployment limit on concurrent AI calls."""
+
+    def __init__(self, max_in_flight: Optional[int] = None):
+        """Initialize the queue.
+
+        Args:
+            max_in_flight: Calls per deployment at once (defaults to
+                settings.ai_max_in_flight, read on every call)
+        """
+        self._max_in_flight = max_in_flight
+        self._queues: Dict[str, DeploymentQueue] = {}
+
+    @property
+    def max_in_flight(self) -> int:
+        """Calls per deployment allowed in flight at once."""
+        return max(1, self._max_in_flight or settings.ai_max_in_flight)
+
+    def _queue(self, deployment: Optional[str]) -> DeploymentQueue:
+        name = deployment or settings.azure_openai_deployment
+        if name not in self._queues:
+            self._queues[name] = DeploymentQueue()
+        return self._queues[name]
+
+    def _grant(self, queue: DeploymentQueue) -> None:
+        """Hand free slots to the callers that have waited longest."""
+        while queue.waiters and queue.in_flight < self.max_in_flight:
+            waiter = queue.waiters.popleft()
+            if waiter.done():
+                continue
+            queue.in_flight += 1
+            queue.peak_in_flight = max(queue.peak_in_flight, queue.in_flight)
+            waiter.set_result(None)
+
+    def _release(self, queue: DeploymentQueue) -> None:
+        queue.in_flight -= 1
+        self._grant(queue)
+
+    async def acquire(self, deployment: Optional[str] = None) -> float:
+        """Wait for a slot on a deployment.
+
+        Args:
+            deployment: Deployment the call goes to (None for azure_openai_deployment)
+
+        Returns:
+            Seconds spent waiting
+        """
+        queue = self._queue(deployment)
+        started = time.monotonic()
+        if queue.in_flight < self.max_in_flight and not queue.waiters:
+            queue.in_flight += 1
+            queue.peak_in_flight = max(queue.peak_in_flight, queue.in_flight)
+        else:
+            waiter = asyncio.get_running_loop().create_future()
+            queue.waiters.append(waiter)
+            try:
+                await waiter
+            except asyncio.CancelledError:
+                if waiter.done() and not waiter.cancelled():
+                    # Granted a slot just as the caller was cancelled; pass it on
+                    self._release(queue)
+                elif waiter in queue.waiters:
+                    queue.waiters.remove(waiter)
+                raise
+
+        waited = time.monotonic() - started
+        queue.requests += 1
+        queue.total_wait += waited
+        queue.max_wait = max(queue.max_wait, waited)
+        wait = _queue_wait.get()
+        if wait is not None:
+            wait.seconds += waited
+        return waited
+
+    def release(self, deployment: Optional[str] = None) -> None:
+        """Give back a slot taken with acquire."""
+        self._release(self._queue(deployment))
+
+    @asynccontextmanager
+    async def slot(self, deployment: Optional[str] = None) -> AsyncIterator[float]:
+        """Hold a slot on a deployment for the block; yields the seconds waited."""
+        waited = await self.acquire(deployment)
+        try:
+            yield waited
+        finally:
+            self.release(deployment)
+
+    def snapshot(self) -> Dict[str, Any]:
+        """Deployment -> queue state, for health reporting."""
+        return {
+            "max_in_flight": self.max_in_flight,
+            "deployments": {name: queue.snapshot() for name, queue in sorted(self._queues.items())},
+        }
+
+    def reset(self) -> None:
+        """Forget every deployment's statistics (for tests)."""
+        self._queues.clear()
+
+
+# Global AI request queue shared by the AI services
+ai_request_queue = AIRequestQueue()
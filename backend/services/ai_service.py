@@ -8,19 +8,45 @@ import asyncio
 import os
 import sys
 import json
+from contextvars import copy_context
+from datetime import date
 from pathlib import Path
-from typing import Dict, Any, List, Optional
+from typing import Any, Callable, Dict, List, Optional
 
 # Add src to Python path for existing service imports
 sys.path.insert(0, str(Path(__file__).parent.parent.parent / "src"))
 
 try:
     from ai_processor import AIProcessor
-    from azure_config import get_azure_config
+    from azure_config import get_azure_config, is_auth_error
 except ImportError as e:
     print(f"Warning: Could not import AI dependencies: {e}")
     AIProcessor = None
     get_azure_config = None
+    is_auth_error = None
+
+from backend.services.action_items import parse_action_items
+from backend.services.ai_health import ai_health
+from backend.services.ai_queue import ai_request_queue
+from backend.services.ai_traces import make_trace_hook
+from backend.services.category_service import load_categories
+from backend.services.classification_explainer import (
+    EXPLAINER_TEMPLATE, build_explainer_inputs, parse_explanation
+)
+from backend.core.config import settings
+from backend.core.errors import AIAuthError
+from backend.core.prompts import prompt_library
+from backend.models.ai_models import DEFAULT_IMPORTANCE_SCORE, clamp_importance_score
+from backend.services.follow_up_detector import AWAITING_REPLY_TEMPLATE, parse_ai_verdict
+from backend.services.folder_suggestions import (
+    FOLDER_RANKER_TEMPLATE, build_ranking_inputs, parse_folder_ranking
+)
+from backend.services.reply_drafter import DRAFT_TEMPLATE, build_draft_inputs, parse_draft
+from backend.services.holistic_analysis import HOLISTIC_TEMPLATE, build_holistic_inputs, parse_holistic_analysis
+from backend.services.search_query import SEARCH_QUERY_TEMPLATE, build_search_query_inputs, parse_search_query
+from backend.services.summary_modes import THREAD_TEMPLATE, build_thread_inputs, split_summary, summary_template
+from backend.services.task_service import TASK_TITLE_TEMPLATE, parse_task_title
+from backend.services.triage import TRIAGE_TEMPLATE, build_triage_inputs, parse_triage, template_deployment
 
 
 class AIService:
@@ -40,17 +66,50 @@ class AIService:
             
             try:
                 self.ai_processor = AIProcessor()
+                self.ai_processor.prompt_resolver = prompt_library.path
+                self.ai_processor.parameter_resolver = prompt_library.parameters
+                self.ai_processor.deployment_resolver = template_deployment
+                self.ai_processor.add_trace_hook(make_trace_hook(self.ai_processor))
+                self.ai_processor.raise_auth_errors = True
                 self.azure_config = get_azure_config()
                 self._initialized = True
             except Exception as e:
                 raise RuntimeError(f"Failed to initialize AI components: {e}")
     
+    async def _run_in_executor(self, func: Callable[..., Any], *args, deployment: Optional[str] = None) -> Any:
+        """Run a blocking AI call in the thread pool with the caller's context.
+        
+        The context carries the AI trace scope (see backend.services.ai_traces)
+        to the thread where AIProcessor runs its trace hooks. The call first
+        waits for a slot on its deployment (see backend.services.ai_queue).
+        
+        Args:
+            func: Blocking function making the AI call
+            *args: Arguments for func
+            deployment: Deployment the call goes to (None for azure_openai_deployment)
+        
+        Raises:
+            AIAuthError: Azure could not get a token or rejected the credential
+        """
+        loop = asyncio.get_event_loop()
+        context = copy_context()
+        try:
+            async with ai_request_queue.slot(deployment):
+                return await loop.run_in_executor(None, lambda: context.run(func, *args))
+        except Exception as e:
+            if is_auth_error is None or not is_auth_error(e):
+                raise
+            await ai_health.record(False, str(e), auth_error=True)
+            raise AIAuthError() from e
+    
     async def classify_email_async(
         self, 
         subject: str, 
         content: str, 
         sender: str, 
-        context: Optional[str] = None
+        context: Optional[str] = None,
+        recipients: Optional[str] = None,
+        relationship: Optional[str] = None
     ) -> Dict[str, Any]:
         """Async wrapper for email classification.
         
@@ -59,27 +118,38 @@ class AIService:
             content: Email body content
             sender: Email sender address
             context: Additional context for classification
+            recipients: Recipient summary from recipient_summary, placed
+                with the headers so the model sees who else was addressed
+            relationship: How the owner knows the sender, from
+                ContactStore.relationship_hints, placed with the headers
             
         Returns:
-            Dict containing classification results with category, confidence, and reasoning
+            Dict containing classification results with category, confidence, reasoning,
+            and importance_score (1-5) with its justification
         """
         self._ensure_initialized()
         
         # Prepare email content in expected format
-        email_text = f"Subject: {subject}\nFrom: {sender}\n\n{content}"
+        headers = f"Subject: {subject}\nFrom: {sender}"
+        if recipients:
+            headers += f"\n{recipients}"
+        if relationship:
+            headers += f"\nRelationship: {relationship}"
+        email_text = f"{headers}\n\n{content}"
         
         # Run CPU-bound AI processing in thread pool to avoid blocking event loop
-        loop = asyncio.get_event_loop()
-        
         try:
-            result = await loop.run_in_executor(
-                None,
+            result = await self._run_in_executor(
                 self._classify_email_sync,
                 email_text,
                 context or ""
             )
+            await ai_health.record(True)
             return result
+        except AIAuthError:
+            raise
         except Exception as e:
+            await ai_health.record(False, str(e))
             return {
                 "category": "work_relevant",
                 "confidence": 0.5,
@@ -94,7 +164,8 @@ class AIService:
             # Use the enhanced classification method with explanation
             result = self.ai_processor.classify_email_with_explanation(
                 email_content, 
-                learning_data=[]  # Empty learning data for now
+                learning_data=[],  # Empty learning data for now
+                categories=load_categories()  # Includes user-defined categories
             )
             
             # Ensure result is in expected format
@@ -103,6 +174,8 @@ class AIService:
                     "category": result.get("category", "work_relevant"),
                     "confidence": result.get("confidence", 0.8),
                     "reasoning": result.get("explanation", "Classification completed"),
+                    "importance_score": clamp_importance_score(result.get("importance_score")),
+                    "importance_justification": result.get("importance_justification"),
                     "alternatives": result.get("alternatives", [])
                 }
             else:
@@ -111,11 +184,46 @@ class AIService:
                     "category": str(result) if result else "work_relevant",
                     "confidence": 0.8,
                     "reasoning": "Email classified successfully",
+                    "importance_score": DEFAULT_IMPORTANCE_SCORE,
+                    "importance_justification": None,
                     "alternatives": []
                 }
         except Exception as e:
             raise RuntimeError(f"Email classification failed: {e}")
     
+    async def triage_email_async(self, subject: str, content: str, sender: str) -> Dict[str, Any]:
+        """Quick first-pass classification for two-stage batch classification.
+        
+        Args:
+            subject: Email subject line
+            content: Email body content (only its opening lines are sent)
+            sender: Email sender address
+            
+        Returns:
+            Dict containing category and confidence, or error on failure
+        """
+        self._ensure_initialized()
+        
+        names = [category["name"] for category in load_categories()]
+        inputs = build_triage_inputs(subject, sender, content, names)
+        
+        try:
+            return await self._run_in_executor(
+                self._triage_email_sync,
+                inputs,
+                names,
+                deployment=template_deployment(TRIAGE_TEMPLATE)
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _triage_email_sync(self, inputs: Dict[str, Any], categories: List[str]) -> Dict[str, Any]:
+        """Synchronous email triage for thread pool execution."""
+        result = self.ai_processor.execute_prompty(TRIAGE_TEMPLATE, inputs)
+        return parse_triage(result, categories)
+    
     async def extract_action_items(
         self, 
         email_content: str, 
@@ -132,16 +240,15 @@ class AIService:
         """
         self._ensure_initialized()
         
-        loop = asyncio.get_event_loop()
-        
         try:
-            result = await loop.run_in_executor(
-                None,
+            result = await self._run_in_executor(
                 self._extract_action_items_sync,
                 email_content,
                 context or ""
             )
             return result
+        except AIAuthError:
+            raise
         except Exception as e:
             return {
                 "action_items": [],
@@ -205,7 +312,7 @@ class AIService:
             
             # Convert to expected API format
             return {
-                "action_items": [parsed_result.get("action_required", "Review email")] if parsed_result.get("action_required") else [],
+                "action_items": parse_action_items(parsed_result),
                 "urgency": "medium",  # Default urgency
                 "deadline": parsed_result.get("due_date"),
                 "confidence": 0.8,
@@ -228,23 +335,22 @@ class AIService:
         
         Args:
             email_content: Email content to summarize
-            summary_type: Type of summary (brief or detailed)
+            summary_type: Type of summary (brief, detailed, or action_focused)
             
         Returns:
             Dict containing summary, key points, and confidence
         """
         self._ensure_initialized()
         
-        loop = asyncio.get_event_loop()
-        
         try:
-            result = await loop.run_in_executor(
-                None,
+            result = await self._run_in_executor(
                 self._generate_summary_sync,
                 email_content,
                 summary_type
             )
             return result
+        except AIAuthError:
+            raise
         except Exception as e:
             return {
                 "summary": f"Unable to generate summary: {str(e)}",
@@ -272,7 +378,7 @@ class AIService:
                     body = '\n'.join(lines[lines.index(line)+1:])
                     break
             
-            # Use the email_one_line_summary prompty template
+            template = summary_template(summary_type)
             inputs = {
                 'context': f'Summary type: {summary_type}',
                 'username': 'User',  # Default username
@@ -282,14 +388,16 @@ class AIService:
                 'body': body
             }
             
-            result = self.ai_processor.execute_prompty('email_one_line_summary.prompty', inputs)
+            result = self.ai_processor.execute_prompty(template, inputs)
             
             # Process result
             summary_text = str(result).strip() if result else "Unable to generate summary"
             
             # Generate key points (simple extraction)
             key_points = []
-            if len(summary_text) > 20:  # If we have a meaningful summary
+            if summary_type == "action_focused":
+                summary_text, key_points = split_summary(summary_text)
+            elif len(summary_text) > 20:  # If we have a meaningful summary
                 # Extract potential key points from the summary
                 sentences = summary_text.split('.')
                 key_points = [s.strip() for s in sentences if len(s.strip()) > 10][:3]
@@ -303,47 +411,349 @@ class AIService:
         except Exception as e:
             raise RuntimeError(f"Summary generation failed: {e}")
     
-    async def get_available_templates(self) -> Dict[str, Any]:
-        """Get list of available prompt templates.
+    async def summarize_thread(
+        self,
+        emails: List[Dict[str, Any]],
+        max_tokens: int
+    ) -> Dict[str, Any]:
+        """Digest a whole conversation thread, oldest message first.
         
+        Args:
+            emails: The conversation's emails
+            max_tokens: Token budget for the thread's messages
+            
         Returns:
-            Dict containing template names and descriptions
+            Dict containing summary, key points, confidence, message_count
+            and messages_omitted, or error on failure
         """
-        templates_dir = Path(__file__).parent.parent.parent / "prompts"
+        self._ensure_initialized()
+        
+        inputs, omitted = build_thread_inputs(
+            emails, max_tokens, username=settings.user_name or self.ai_processor.get_username()
+        )
         
-        if not templates_dir.exists():
+        try:
+            result = await self._run_in_executor(self._summarize_thread_sync, inputs)
+        except AIAuthError:
+            raise
+        except Exception as e:
             return {
-                "templates": [],
-                "descriptions": {}
+                "summary": f"Unable to generate summary: {str(e)}",
+                "key_points": [],
+                "confidence": 0.0,
+                "error": str(e)
             }
+        return {**result, "message_count": len(emails), "messages_omitted": omitted}
+    
+    def _summarize_thread_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous thread summary for thread pool execution."""
+        result = self.ai_processor.execute_prompty(THREAD_TEMPLATE, inputs)
+        summary_text, key_points = split_summary(str(result).strip() if result else "")
+        return {
+            "summary": summary_text or "Unable to generate summary",
+            "key_points": key_points,
+            "confidence": 0.8 if summary_text else 0.5
+        }
+    
+    async def explain_classification(
+        self,
+        subject: str,
+        sender: str,
+        content: str,
+        current_category: str,
+        current_reasoning: Optional[str],
+        alternative_category: str,
+        received_date: Optional[str] = None,
+        categories: Optional[List[str]] = None
+    ) -> Dict[str, Any]:
+        """Explain why an email was classified one way rather than another.
+        
+        Args:
+            subject: Email subject line
+            sender: Email sender
+            content: Email body content
+            current_category: Category the email was classified as
+            current_reasoning: Stored reasoning for the current category
+            alternative_category: Category the user is asking about
+            received_date: When the email was received
+            categories: Valid category names (defaults to the built-in ones)
+            
+        Returns:
+            Dict containing supports_current, supports_alternative, and verdict
+        """
+        self._ensure_initialized()
         
-        templates = []
-        descriptions = {}
+        inputs = build_explainer_inputs(
+            subject=subject,
+            sender=sender,
+            content=content,
+            current_category=current_category,
+            current_reasoning=current_reasoning,
+            alternative_category=alternative_category,
+            received_date=received_date,
+            categories=categories
+        )
         
-        for template_file in templates_dir.glob("*.prompty"):
-            template_name = template_file.name
-            templates.append(template_name)
+        try:
+            return await self._run_in_executor(
+                self._explain_classification_sync,
+                inputs
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _explain_classification_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous classification explanation for thread pool execution."""
+        result = self.ai_processor.execute_prompty(EXPLAINER_TEMPLATE, inputs)
+        return parse_explanation(result)
+    
+    async def check_awaiting_reply(
+        self,
+        subject: str,
+        sender: str,
+        content: str,
+        received_date: Optional[str] = None
+    ) -> Dict[str, Any]:
+        """Ask the AI whether an email is waiting for the user's reply.
+        
+        Args:
+            subject: Email subject line
+            sender: Email sender
+            content: New text of the message, without quoted history
+            received_date: When the email was received
             
-            # Try to read description from template
-            try:
-                with open(template_file, 'r', encoding='utf-8') as f:
-                    content = f.read()
-                    # Extract description from YAML frontmatter
-                    if content.startswith('---'):
-                        parts = content.split('---', 2)
-                        if len(parts) >= 2:
-                            yaml_content = parts[1]
-                            for line in yaml_content.split('\n'):
-                                if line.strip().startswith('description:'):
-                                    desc = line.split('description:', 1)[1].strip()
-                                    descriptions[template_name] = desc.strip('"\'')
-                                    break
-            except Exception:
-                descriptions[template_name] = "No description available"
+        Returns:
+            Dict containing awaiting_reply, or error on failure
+        """
+        self._ensure_initialized()
         
+        inputs = {
+            "context": "",
+            "username": "User",
+            "subject": subject,
+            "sender": sender,
+            "date": received_date or "Unknown",
+            "body": content
+        }
+        
+        try:
+            return await self._run_in_executor(
+                self._check_awaiting_reply_sync,
+                inputs
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _check_awaiting_reply_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous awaiting-reply check for thread pool execution."""
+        result = self.ai_processor.execute_prompty(AWAITING_REPLY_TEMPLATE, inputs)
+        return {"awaiting_reply": parse_ai_verdict(result)}
+    
+    async def rank_folders(self, subject: str, sender: str, folders: List[str]) -> Dict[str, Any]:
+        """Ask the AI which existing folders an email belongs in.
+        
+        Args:
+            subject: Email subject line
+            sender: Email sender
+            folders: Candidate folder names
+            
+        Returns:
+            Dict containing folders as (folder, confidence) pairs, best
+            first, or error on failure
+        """
+        self._ensure_initialized()
+        
+        inputs = build_ranking_inputs(subject, sender, folders)
+        
+        try:
+            return await self._run_in_executor(
+                self._rank_folders_sync,
+                inputs,
+                folders
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _rank_folders_sync(self, inputs: Dict[str, Any], folders: List[str]) -> Dict[str, Any]:
+        """Synchronous folder ranking for thread pool execution."""
+        result = self.ai_processor.execute_prompty(FOLDER_RANKER_TEMPLATE, inputs)
+        return {"folders": parse_folder_ranking(result, folders)}
+    
+    async def generate_search_query(self, request: str, categories: List[str], today: date) -> Dict[str, Any]:
+        """Ask the AI for search filters matching a natural language request.
+        
+        Args:
+            request: What the user is looking for
+            categories: Category names the category filter may take
+            today: The user's current date, for relative dates
+            
+        Returns:
+            Dict containing filters as returned by the model (unchecked; see
+            search_query.validate_search_filters), or error on failure
+        """
+        self._ensure_initialized()
+        
+        inputs = build_search_query_inputs(request, categories, today)
+        
+        try:
+            return await self._run_in_executor(
+                self._generate_search_query_sync,
+                inputs
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _generate_search_query_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous search query generation for thread pool execution."""
+        result = self.ai_processor.execute_prompty(SEARCH_QUERY_TEMPLATE, inputs)
+        return {"filters": parse_search_query(result)}
+    
+    async def rewrite_task_title(self, item: str) -> Dict[str, Any]:
+        """Ask the AI for an imperative task title for an action item.
+        
+        Args:
+            item: Action item text, already cleaned by clean_task_title
+            
+        Returns:
+            Dict containing title, or error on failure
+        """
+        self._ensure_initialized()
+        
+        try:
+            return await self._run_in_executor(
+                self._rewrite_task_title_sync,
+                {"item": item}
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _rewrite_task_title_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous task title rewrite for thread pool execution."""
+        result = self.ai_processor.execute_prompty(TASK_TITLE_TEMPLATE, inputs)
+        return {"title": parse_task_title(result)}
+    
+    async def draft_reply(
+        self,
+        subject: str,
+        sender: str,
+        content: str,
+        tone: str = "brief",
+        bullet_points: Optional[List[str]] = None,
+        received_date: Optional[str] = None
+    ) -> Dict[str, Any]:
+        """Draft a reply to an email in the user's voice.
+        
+        Args:
+            subject: Email subject line
+            sender: Email sender
+            content: Email body content
+            tone: One of reply_drafter.DRAFT_TONES
+            bullet_points: Points the reply must make
+            received_date: When the email was received
+            
+        Returns:
+            Dict containing subject and body, or error on failure
+        """
+        self._ensure_initialized()
+        
+        inputs = build_draft_inputs(
+            subject=subject,
+            sender=sender,
+            content=content,
+            tone=tone,
+            bullet_points=bullet_points,
+            username=settings.user_name or self.ai_processor.get_username(),
+            job_context=settings.job_context or self.ai_processor.get_job_context(),
+            received_date=received_date
+        )
+        
+        try:
+            return await self._run_in_executor(
+                self._draft_reply_sync,
+                inputs,
+                subject
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _draft_reply_sync(self, inputs: Dict[str, Any], subject: str) -> Dict[str, Any]:
+        """Synchronous reply drafting for thread pool execution."""
+        result = self.ai_processor.execute_prompty(DRAFT_TEMPLATE, inputs)
+        return parse_draft(result, subject)
+    
+    async def analyze_inbox(
+        self,
+        emails: List[Dict[str, Any]],
+        today: date,
+        corrections: Optional[List[str]] = None
+    ) -> Dict[str, Any]:
+        """Analyze a set of inbox emails together.
+        
+        Args:
+            emails: Emails with id, subject, sender, received_date, and content
+            today: The user's current date, for deadlines
+            corrections: Dismissed blocking items of earlier runs
+            
+        Returns:
+            Dict containing the parsed analysis (see
+            holistic_analysis.parse_holistic_analysis), or error on failure
+        """
+        self._ensure_initialized()
+        
+        inputs = build_holistic_inputs(
+            emails,
+            username=settings.user_name or self.ai_processor.get_username(),
+            job_context=settings.job_context or self.ai_processor.get_job_context(),
+            today=today,
+            corrections=corrections or []
+        )
+        
+        try:
+            return await self._run_in_executor(
+                self._analyze_inbox_sync,
+                inputs,
+                [str(email["id"]) for email in emails]
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _analyze_inbox_sync(self, inputs: Dict[str, Any], email_ids: List[str]) -> Dict[str, Any]:
+        """Synchronous holistic analysis for thread pool execution."""
+        result = self.ai_processor.execute_prompty(HOLISTIC_TEMPLATE, inputs)
+        return parse_holistic_analysis(result, email_ids)
+    
+    async def get_available_templates(self) -> Dict[str, Any]:
+        """Get list of available prompt templates.
+        
+        Returns:
+            Dict containing the active template names, their descriptions,
+            the layer (bundled or user) each was loaded from, the model
+            parameters each runs with, and why any user templates were
+            skipped (see backend.core.prompts)
+        """
+        templates = prompt_library.templates()
         return {
-            "templates": sorted(templates),
-            "descriptions": descriptions
+            "templates": list(templates),
+            "descriptions": {
+                name: template.description or "No description available" for name, template in templates.items()
+            },
+            "layers": {name: template.layer for name, template in templates.items()},
+            "parameters": {name: prompt_library.parameters(name) for name in templates},
+            "errors": prompt_library.errors()
         }
 
 
@@ -0,0 +1,293 @@
+"""AI debug traces for FastAPI Email Helper API.
+
+With tracing on, every prompt AIProcessor runs for an email is recorded
+in the ai_traces table: the template (the operation) and its version,
+the inputs and rendered prompt text, the model and the parameters it ran
+with (settings.ai_parameters applied), the raw response, how long the
+call took, and any error. Secrets are scrubbed before anything
+is stored. GET /api/ai/traces/{email_id} returns an email's traces, so a
+misclassification can be traced back to what the model saw and said.
+
+Tracing is off by default. settings.ai_debug_traces turns it on for every
+call; the X-AI-Debug-Trace: 1 request header turns it on for the calls
+made while handling that request. Only calls made inside trace_email,
+which names the email, are recorded. Traces older than
+settings.ai_trace_retention_days are pruned while the API runs.
+
+AIProcessor calls its trace hooks on the worker thread running the
+prompt; the AI services run their blocking calls with the caller's
+context, so the scope set by trace_email and the header reach the hook.
+"""
+
+import asyncio
+import json
+import logging
+import re
+from contextlib import contextmanager
+from contextvars import ContextVar
+from dataclasses import dataclass
+from datetime import datetime, timedelta
+from pathlib import Path
+from typing import Any, Callable, Dict, Iterator, List, Optional
+
+from fastapi import FastAPI, Request
+
+from backend.core.config import SECRET_FIELDS, settings
+from backend.core.errors import current_request_id
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.ai_trace import AITrace
+from backend.services.classification_store import prompt_version
+
+logger = logging.getLogger(__name__)
+
+TRACE_HEADER = "X-AI-Debug-Trace"
+
+REDACTED = "[REDACTED]"
+
+# Secret-looking text in prompts and responses; group 1, when present, is kept
+SECRET_PATTERNS = (
+    re.compile(r"(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*"),
+    re.compile(r"(?i)((?:api[_-]?key|password|passwd|secret|token)[\"']?\s*[:=]\s*[\"']?)[^\s\"',;]+"),
+    re.compile(r"()\bsk-[A-Za-z0-9_\-]{16,}"),
+    re.compile(r"()\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+"),  # JWTs
+)
+
+
+@dataclass(frozen=True)
+class TraceScope:
+    """The email, and its owner, that AI calls are currently made for."""
+    email_id: str
+    user_id: Optional[str] = None
+
+
+_trace_scope: ContextVar[Optional[TraceScope]] = ContextVar("ai_trace_scope", default=None)
+
+# Set for the length of a request carrying TRACE_HEADER
+_header_tracing: ContextVar[bool] = ContextVar("ai_header_tracing", default=False)
+
+
+def tracing_enabled() -> bool:
+    """Whether AI calls made now should be traced."""
+    return settings.ai_debug_traces or _header_tracing.get()
+
+
+@contextmanager
+def trace_email(email_id: Optional[str], user_id: Any = None) -> Iterator[None]:
+    """Attribute AI calls made inside the block to an email (no-op without an ID)."""
+    if not email_id:
+        yield
+        return
+    token = _trace_scope.set(TraceScope(str(email_id), None if user_id is None else str(user_id)))
+    try:
+        yield
+    finally:
+        _trace_scope.reset(token)
+
+
+def scrub_secrets(text: Optional[str]) -> Optional[str]:
+    """Replace configured secrets and secret-looking tokens with REDACTED."""
+    if not text:
+        return text
+    for name in SECRET_FIELDS:
+        value = getattr(settings, name, None)
+        if value and len(str(value)) >= 8:
+            text = text.replace(str(value), REDACTED)
+    for pattern in SECRET_PATTERNS:
+        text = pattern.sub(lambda match: match.group(1) + REDACTED, text)
+    return text
+
+
+def _as_text(value: Any) -> Optional[str]:
+    """Response or input value as stored text."""
+    if value is None or isinstance(value, str):
+        return value
+    try:
+        return json.dumps(value, default=str)
+    except (TypeError, ValueError):
+        return str(value)
+
+
+class AITraceStore:
+    """Store for AI debug traces."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the trace store.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def record(
+        self,
+        scope: TraceScope,
+        template: str,
+        inputs: Dict[str, Any],
+        rendered_prompt: Optional[str],
+        response: Any,
+        duration_ms: float,
+        error: Optional[str] = None,
+        request_id: Optional[str] = None,
+        parameters: Optional[Dict[str, Any]] = None
+    ) -> None:
+        """Record one prompt run, scrubbing secrets and logging instead of raising on failure.
+
+        Blocking; trace hooks call it from the thread pool.
+
+        Args:
+            scope: Email the call was made for
+            template: Prompt template file that ran
+            inputs: Template variables
+            rendered_prompt: Prompt text sent to the model
+            response: Raw model response (or the fallback returned instead)
+            duration_ms: Time the call took
+            error: Failure, or why the response is a fallback
+            request_id: ID of the API request that made the call
+            parameters: Model parameters the call ran with
+        """
+        try:
+            scrubbed_inputs = {key: scrub_secrets(_as_text(value)) for key, value in inputs.items()}
+            with self.db.get_connection() as conn:
+                conn.execute(
+                    """
+                    INSERT INTO ai_traces
+                        (email_id, user_id, operation, prompt_version, model, parameters, inputs, rendered_prompt,
+                         raw_response, error, duration_ms, request_id, created_at)
+                    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+                    """,
+                    (
+                        scope.email_id,
+                        scope.user_id,
+                        Path(template).stem,
+                        prompt_version(template),
+                        settings.azure_openai_deployment,
+                        json.dumps(parameters or {}, sort_keys=True),
+                        json.dumps(scrubbed_inputs, sort_keys=True),
+                        scrub_secrets(rendered_prompt),
+                        scrub_secrets(_as_text(response)),
+                        scrub_secrets(error),
+                        round(duration_ms, 3),
+                        request_id,
+                        datetime.utcnow(),
+                    )
+                )
+                conn.commit()
+        except Exception as e:
+            logger.error(f"Failed to record AI trace of {template} for {scope.email_id}: {e}")
+
+    async def get_traces(self, email_id: str, user_id: Any, operation: Optional[str] = None) -> List[AITrace]:
+        """Get a user's traces for an email, oldest first.
+
+        Args:
+            email_id: Email the calls were made for
+            user_id: Owner of the email
+            operation: Only traces of this prompt template (e.g. email_classifier_with_explanation)
+        """
+        loop = asyncio.get_event_loop()
+        where, params = "email_id = ? AND user_id = ?", [email_id, str(user_id)]
+        if operation:
+            where += " AND operation = ?"
+            params.append(operation)
+
+        def _get_traces_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"SELECT * FROM ai_traces WHERE {where} ORDER BY created_at, id",
+                    params
+                ).fetchall()
+            return [
+                AITrace(**{
+                    **dict(row),
+                    "inputs": json.loads(row["inputs"]),
+                    "parameters": json.loads(row["parameters"] or "{}"),
+                })
+                for row in rows
+            ]
+
+        return await loop.run_in_executor(None, _get_traces_sync)
+
+    async def prune(self, retention_days: int, now: Optional[datetime] = None) -> int:
+        """Delete traces older than the retention period.
+
+        Args:
+            retention_days: Days traces are kept (0 keeps them forever)
+            now: Reference time (defaults to now, UTC)
+
+        Returns:
+            Number of traces deleted
+        """
+        if retention_days <= 0:
+            return 0
+        loop = asyncio.get_event_loop()
+        cutoff = (now or datetime.utcnow()) - timedelta(days=retention_days)
+
+        def _prune_sync():
+            with self.db.get_connection() as conn:
+                deleted = conn.execute("DELETE FROM ai_traces WHERE created_at < ?", (cutoff,)).rowcount
+                conn.commit()
+            return deleted
+
+        return await loop.run_in_executor(None, _prune_sync)
+
+
+def make_trace_hook(processor: Any, store: Optional[AITraceStore] = None) -> Callable[[Dict[str, Any]], None]:
+    """Build an AIProcessor trace hook recording traced calls.
+
+    Args:
+        processor: AIProcessor the hook is added to, used to render prompts
+        store: Trace store (defaults to one over the process-wide store)
+    """
+    def hook(event: Dict[str, Any]) -> None:
+        scope = _trace_scope.get()
+        if scope is None or not tracing_enabled():
+            return
+        try:
+            rendered = processor.render_prompty(event["template"], event["inputs"])
+        except Exception as e:
+            rendered = None
+            logger.warning(f"Could not render {event['template']} for its trace: {e}")
+        (store or AITraceStore()).record(
+            scope,
+            event["template"],
+            event["inputs"],
+            rendered,
+            event["response"],
+            event["duration_ms"],
+            error=event["error"],
+            request_id=current_request_id(),
+            parameters=event.get("parameters")
+        )
+
+    return hook
+
+
+def register_ai_trace_middleware(app: FastAPI) -> None:
+    """Install the middleware turning on tracing for requests with TRACE_HEADER.
+
+    Args:
+        app: FastAPI application to configure
+    """
+
+    @app.middleware("http")
+    async def ai_trace_middleware(request: Request, call_next):
+        if request.headers.get(TRACE_HEADER, "").strip().lower() not in ("1", "true", "yes"):
+            return await call_next(request)
+        token = _header_tracing.set(True)
+        try:
+            return await call_next(request)
+        finally:
+            _header_tracing.reset(token)
+
+
+async def run_trace_prune(store: AITraceStore, retention_days: int) -> int:
+    """Prune expired AI traces once (the ai_trace_prune background job)."""
+    pruned = await store.prune(retention_days)
+    if pruned:
+        logger.info(f"Pruned {pruned} AI traces")
+    return pruned
+
+
+# Dependency for FastAPI
+def get_ai_trace_store() -> AITraceStore:
+    """FastAPI dependency for the AI trace store."""
+    return AITraceStore()
@@ -0,0 +1,106 @@
+"""Automatic task creation for FastAPI Email Helper API.
+
+When the auto_create_tasks setting is enabled, emails classified into a
+qualifying category have their action items extracted and turned into tasks
+through TaskService.create_tasks_from_action_items, so re-processing an
+email never duplicates its tasks. Each task's priority follows who the
+item falls to and its due date is the item's deadline. Tasks created this way are marked
+source="auto" so they can be filtered or bulk-deleted, and their
+descriptions list the pull requests, work items, documents, and meetings
+linked from the email.
+"""
+
+import logging
+from typing import Any, Dict, Optional
+
+from backend.core.links import OTHER, extract_links, format_links_for_description
+from backend.core.protection import ai_allowed
+from backend.core.redaction import redact_secrets
+
+logger = logging.getLogger(__name__)
+
+AUTO_TASK_SOURCE = "auto"
+
+# Categories that qualify for automatic tasks under each policy
+POLICY_CATEGORIES = {
+    "off": frozenset(),
+    "required_personal_action": frozenset({"required_personal_action"}),
+    "all_actionable": frozenset({"required_personal_action", "team_action", "optional_action"}),
+}
+
+
+def should_create_tasks(policy: str, category: Optional[str]) -> bool:
+    """Whether an email in this category gets automatic tasks under the policy."""
+    return category in POLICY_CATEGORIES.get(policy, frozenset())
+
+
+def task_links_description(email: Dict[str, Any]) -> Optional[str]:
+    """Describe the email's relevant (non-"other") links for its tasks."""
+    links = [
+        link for link in extract_links(email.get("body") or email.get("content"))
+        if link["type"] != OTHER
+    ]
+    return format_links_for_description(links) if links else None
+
+
+def format_email_content(email: Dict[str, Any]) -> str:
+    """Format an email the way extract_action_items expects its content."""
+    body = email.get("body") or email.get("content") or ""
+    return (
+        f"Subject: {email.get('subject') or 'No subject'}\n"
+        f"From: {email.get('sender') or 'Unknown sender'}\n"
+        f"\n{body}"
+    )
+
+
+async def create_auto_tasks(
+    email: Dict[str, Any],
+    category: Optional[str],
+    ai_service,
+    task_service,
+    user_id,
+    policy: str,
+    context: Optional[str] = None
+) -> int:
+    """Extract action items and create tasks for a qualifying email.
+
+    Failures are logged and count as zero tasks; automatic task creation
+    never fails the classification it follows. Rights-managed emails get
+    no tasks.
+
+    Args:
+        email: Email dict with id, subject, sender, and body or content
+        category: Category the email was classified into
+        ai_service: AI service providing extract_action_items (and
+            rewrite_task_title, see normalize_task_title)
+        task_service: TaskService used to create the tasks
+        user_id: Owner of the tasks
+        policy: Value of the auto_create_tasks setting
+        context: Additional context for extraction
+
+    Returns:
+        Number of tasks created
+    """
+    email_id = email.get("id")
+    if not email_id or not ai_allowed(email) or not should_create_tasks(policy, category):
+        return 0
+
+    try:
+        result = await ai_service.extract_action_items(
+            email_content=redact_secrets(format_email_content(email))[0],
+            context=context
+        )
+        action_items = result.get("action_items") or []
+        if "error" in result and not action_items:
+            logger.warning(f"Action item extraction failed for {email_id}: {result['error']}")
+            return 0
+
+        created = await task_service.create_tasks_from_action_items(
+            email_id, action_items, user_id, source=AUTO_TASK_SOURCE,
+            description=task_links_description(email), category=category, ai_service=ai_service
+        )
+    except Exception as e:
+        logger.warning(f"Automatic task creation failed for {email_id}: {e}")
+        return 0
+
+    return len(created)
@@ -0,0 +1,205 @@
+"""Blocking-others escalation list for FastAPI Email Helper API.
+
+Holistic inbox analysis (POST /api/ai/holistic-analysis) marks the actions
+other people are waiting on with blocking_others. Those are kept here, one
+item per canonical email: a later run that still flags an email refreshes
+its item, and one that analyzed the email without flagging it resolves it.
+GET /api/emails/blocking lists the open items, oldest first, with their
+email and the latest task made from it; an item whose task is completed
+or cancelled is no longer listed.
+
+POST /api/emails/blocking/{item_id}/dismiss closes an item for good with
+a DISMISS_REASONS reason. Dismissals go back to later holistic runs as
+corrections so the same email is not flagged again.
+"""
+
+import json
+from datetime import datetime
+from typing import Any, Dict, Iterable, List, Optional
+
+from backend.core.errors import InputValidationError
+from backend.database.connection import DatabaseManager, get_default_manager
+
+DISMISS_REASONS = ("completed_elsewhere", "not_blocking")
+
+_REASON_TEXT = {
+    "completed_elsewhere": "already completed outside the inbox",
+    "not_blocking": "not actually blocking anyone",
+}
+
+# Open items of a user whose canonical email is still stored and whose
+# latest task, if any, is still open
+_OPEN_ITEMS_SQL = """
+    FROM blocking_items b
+    JOIN emails e ON e.id = b.email_id AND e.user_id = b.user_id
+    LEFT JOIN tasks t ON t.id = (
+        SELECT MAX(id) FROM tasks WHERE tasks.email_id = b.email_id AND tasks.user_id = b.user_id
+    )
+    WHERE b.user_id = ? AND b.dismissed_at IS NULL AND b.resolved_at IS NULL
+      AND (t.status IS NULL OR t.status NOT IN ('completed', 'cancelled'))
+"""
+
+
+class BlockingStore:
+    """Store for blocking items found by holistic analysis."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the store.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def record_analysis(
+        self,
+        user_id: int,
+        actions: Iterable[Dict[str, Any]],
+        analyzed_email_ids: Iterable[str],
+        now: Optional[datetime] = None
+    ) -> int:
+        """Record the blocking actions of a holistic run.
+
+        Blocking; EmailService calls it from the thread pool.
+
+        Args:
+            user_id: Owner of the analyzed emails
+            actions: Parsed truly_relevant_actions (see parse_holistic_analysis)
+            analyzed_email_ids: Emails the run looked at; open items on these
+                that the run no longer flags are resolved
+            now: Time of the run
+
+        Returns:
+            Number of open blocking items after the run
+        """
+        seen_at = (now or datetime.utcnow()).isoformat()
+        flagged = {action["canonical_email_id"]: action for action in actions if action.get("blocking_others")}
+        unflagged = [email_id for email_id in dict.fromkeys(analyzed_email_ids) if email_id not in flagged]
+        with self.db.get_connection() as conn:
+            # Dismissed items stay dismissed; anything else is reopened
+            conn.executemany(
+                """
+                INSERT INTO blocking_items (
+                    user_id, email_id, topic, action_type, priority, deadline, why_relevant,
+                    related_email_ids, first_seen_at, last_seen_at
+                ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+                ON CONFLICT (user_id, email_id) DO UPDATE SET
+                    topic = excluded.topic, action_type = excluded.action_type,
+                    priority = excluded.priority, deadline = excluded.deadline,
+                    why_relevant = excluded.why_relevant, related_email_ids = excluded.related_email_ids,
+                    last_seen_at = excluded.last_seen_at, resolved_at = NULL
+                """,
+                [
+                    (
+                        user_id, email_id, action.get("topic"), action.get("action_type"),
+                        action.get("priority"), action.get("deadline"), action.get("why_relevant"),
+                        json.dumps(action.get("related_email_ids") or []), seen_at, seen_at
+                    )
+                    for email_id, action in flagged.items()
+                ]
+            )
+            conn.executemany(
+                """
+                UPDATE blocking_items SET resolved_at = ?
+                WHERE user_id = ? AND email_id = ? AND resolved_at IS NULL AND dismissed_at IS NULL
+                """,
+                [(seen_at, user_id, email_id) for email_id in unflagged]
+            )
+            conn.commit()
+        return self.open_count(user_id)
+
+    def list_open(self, user_id: int, now: Optional[datetime] = None) -> List[Dict[str, Any]]:
+        """The user's open blocking items, oldest email first.
+
+        Blocking.
+
+        Returns:
+            Items with their email's subject, sender, received_date, and
+            folder, the latest task's task_id and task_status, and age_days
+            since the email was received (or the item first seen)
+        """
+        reference = (now or datetime.utcnow()).isoformat()
+        with self.db.get_connection() as conn:
+            rows = conn.execute(
+                f"""
+                SELECT b.id, b.email_id, b.topic, b.action_type, b.priority, b.deadline,
+                       b.why_relevant, b.related_email_ids, b.first_seen_at, b.last_seen_at,
+                       e.subject, e.sender, e.received_date, COALESCE(NULLIF(e.folder, ''), 'Inbox') AS folder,
+                       t.id AS task_id, t.status AS task_status,
+                       MAX(CAST(julianday(?) - julianday(COALESCE(e.received_date, b.first_seen_at)) AS INTEGER), 0)
+                       AS age_days
+                {_OPEN_ITEMS_SQL}
+                ORDER BY COALESCE(e.received_date, b.first_seen_at), b.id
+                """,
+                (reference, user_id)
+            ).fetchall()
+        items = []
+        for row in rows:
+            item = dict(row)
+            item["related_email_ids"] = json.loads(item["related_email_ids"] or "[]")
+            items.append(item)
+        return items
+
+    def open_count(self, user_id: int) -> int:
+        """Number of items list_open returns.
+
+        Blocking.
+        """
+        with self.db.get_connection() as conn:
+            return conn.execute(f"SELECT COUNT(*) {_OPEN_ITEMS_SQL}", (user_id,)).fetchone()[0]
+
+    def dismiss(
+        self,
+        item_id: int,
+        user_id: int,
+        reason: str,
+        note: Optional[str] = None,
+        now: Optional[datetime] = None
+    ) -> bool:
+        """Dismiss one of the user's blocking items.
+
+        Blocking.
+
+        Returns:
+            False if the user has no such item or it is already dismissed
+        """
+        if reason not in DISMISS_REASONS:
+            raise InputValidationError(f"Unknown dismiss reason: {reason}")
+        with self.db.get_connection() as conn:
+            dismissed = conn.execute(
+                """
+                UPDATE blocking_items SET dismissed_at = ?, dismiss_reason = ?, dismiss_note = ?
+                WHERE id = ? AND user_id = ? AND dismissed_at IS NULL
+                """,
+                ((now or datetime.utcnow()).isoformat(), reason, note, item_id, user_id)
+            ).rowcount
+            conn.commit()
+        return dismissed > 0
+
+    def corrections(self, user_id: int, limit: int = 20) -> List[str]:
+        """The user's latest dismissals as correction lines for the holistic prompt.
+
+        Blocking.
+        """
+        with self.db.get_connection() as conn:
+            rows = conn.execute(
+                """
+                SELECT email_id, topic, dismiss_reason, dismiss_note FROM blocking_items
+                WHERE user_id = ? AND dismissed_at IS NOT NULL
+                ORDER BY dismissed_at DESC, id DESC LIMIT ?
+                """,
+                (user_id, limit)
+            ).fetchall()
+        return [correction_line(dict(row)) for row in rows]
+
+
+def correction_line(item: Dict[str, Any]) -> str:
+    """One dismissed item as a correction line."""
+    line = (
+        f"EMAIL_ID {item['email_id']} ({item.get('topic') or 'untitled'}): "
+        f"{_REASON_TEXT.get(item.get('dismiss_reason'), 'dismissed')}"
+    )
+    if item.get("dismiss_note"):
+        line += f" - {item['dismiss_note']}"
+    return line
+
@@ -0,0 +1,379 @@
+"""Per-sender signature and boilerplate stripping for FastAPI Email Helper API.
+
+Most senders end every email the same way: a signature, a legal
+disclaimer, an unsubscribe footer. A block of trailing lines that at least
+MIN_REPEATS of a sender's stored emails end with is learned as that
+sender's boilerplate and kept in the sender_boilerplate table; trailing
+blocks are compared by hashing each suffix of an email's lines, with
+whitespace collapsed and case folded. Quoted replies below the sender's own
+text are ignored, so a signature above a quoted thread still counts.
+
+Learned blocks are stripped before stored emails are sent to the AI
+(classification, summaries, task extraction, reply drafts) and before
+previews are computed. Stripping never removes a whole email: a block is
+only learned from emails with text of their own above it, and only
+stripped from an email that has text left once it is gone.
+
+Learning runs as the boilerplate_learn background job and on demand with
+POST /api/admin/boilerplate/learn. Deleting a block with DELETE
+/api/admin/boilerplate/{id} dismisses it, so it is neither stripped nor
+learned again.
+"""
+
+import asyncio
+import hashlib
+import logging
+import sqlite3
+from dataclasses import dataclass
+from typing import Any, Dict, Iterable, List, Optional, Sequence, Tuple
+
+from backend.core.preview import QUOTE_HEADER, email_preview
+from backend.core.sanitize import text_lines
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.boilerplate import LearnedBoilerplate
+
+logger = logging.getLogger(__name__)
+
+# Stored emails from a sender that must end with a block for it to be learned
+MIN_REPEATS = 3
+
+# Longest block learned, in non-blank lines
+MAX_BLOCK_LINES = 15
+
+# Shortest block learned, in characters, so a lone "Thanks" is never boilerplate
+MIN_BLOCK_CHARS = 20
+
+# Most recent stored emails of a sender a learning pass looks at
+LEARN_WINDOW = 50
+
+
+@dataclass
+class BoilerplateBlock:
+    """A trailing block found in a sender's emails."""
+    lines: List[str]
+    email_count: int
+
+    @property
+    def block_hash(self) -> str:
+        return block_hash(self.lines)
+
+
+def sender_key(sender: Optional[str]) -> str:
+    """Sender as boilerplate is stored for it."""
+    return (sender or "").strip().lower()
+
+
+def _normalize(lines: Iterable[str]) -> Tuple[str, ...]:
+    return tuple(" ".join(line.split()).casefold() for line in lines)
+
+
+def block_hash(lines: Sequence[str]) -> str:
+    """Hash of a block's normalized lines."""
+    return hashlib.sha256("\n".join(_normalize(lines)).encode("utf-8")).hexdigest()
+
+
+def _own_text_end(lines: Sequence[str]) -> int:
+    """Index of the first line of quoted history, or len(lines) if there is none."""
+    for index, line in enumerate(lines):
+        if line.startswith(">") or QUOTE_HEADER.match(line):
+            return index
+    return len(lines)
+
+
+def own_lines(body: Optional[str]) -> List[str]:
+    """Non-blank lines the sender wrote, without quoted history."""
+    lines = text_lines(body)
+    return [line for line in lines[:_own_text_end(lines)] if line]
+
+
+def detect_boilerplate(bodies: Iterable[Optional[str]], min_repeats: int = MIN_REPEATS) -> List[BoilerplateBlock]:
+    """Find trailing blocks shared by several of one sender's emails.
+
+    Identical bodies count once, and a suffix is only counted when the email
+    has lines above it. Of nested suffixes ending the same emails only the
+    longest is kept.
+
+    Args:
+        bodies: Plain text or HTML bodies of one sender's emails
+        min_repeats: Distinct emails that must end with a block
+
+    Returns:
+        The blocks, longest first
+    """
+    counts: Dict[str, int] = {}
+    first_seen: Dict[str, List[str]] = {}
+    seen = set()
+
+    for body in bodies:
+        lines = own_lines(body)
+        normalized = _normalize(lines)
+        if len(lines) < 2 or normalized in seen:
+            continue
+        seen.add(normalized)
+        for length in range(1, min(MAX_BLOCK_LINES, len(lines) - 1) + 1):
+            digest = block_hash(lines[-length:])
+            counts[digest] = counts.get(digest, 0) + 1
+            first_seen.setdefault(digest, lines[-length:])
+
+    candidates = sorted(
+        (
+            BoilerplateBlock(first_seen[digest], count) for digest, count in counts.items()
+            if count >= min_repeats and sum(len(line) for line in first_seen[digest]) >= MIN_BLOCK_CHARS
+        ),
+        key=lambda block: len(block.lines), reverse=True
+    )
+    kept: List[BoilerplateBlock] = []
+    for candidate in candidates:
+        suffix = _normalize(candidate.lines)
+        if not any(
+            block.email_count == candidate.email_count and _normalize(block.lines)[-len(suffix):] == suffix
+            for block in kept
+        ):
+            kept.append(candidate)
+    return kept
+
+
+def strip_boilerplate(body: Optional[str], blocks: Sequence[Sequence[str]]) -> Optional[str]:
+    """Remove the longest known block ending the sender's own text.
+
+    Args:
+        body: Plain text or HTML body
+        blocks: Known blocks, as lines
+
+    Returns:
+        The body as plain text without the block (quoted history is kept),
+        or the body unchanged if no block ends it or nothing would be left
+    """
+    if not body or not blocks:
+        return body
+
+    lines = text_lines(body)
+    end = _own_text_end(lines)
+    positions = [index for index in range(end) if lines[index]]
+    normalized = _normalize(lines[index] for index in positions)
+
+    for block in sorted((_normalize(block) for block in blocks), key=len, reverse=True):
+        if 0 < len(block) < len(normalized) and normalized[-len(block):] == block:
+            return "\n".join(lines[:positions[-len(block)]] + lines[end:]).strip()
+    return body
+
+
+def sender_blocks(conn: sqlite3.Connection, user_id: int, sender: Optional[str]) -> List[List[str]]:
+    """Blocks learned for a sender and not dismissed, as lines."""
+    rows = conn.execute(
+        "SELECT block FROM sender_boilerplate WHERE user_id = ? AND sender = ? AND dismissed = 0",
+        (user_id, sender_key(sender))
+    ).fetchall()
+    return [row["block"].split("\n") for row in rows]
+
+
+def _refresh_previews(conn: sqlite3.Connection, user_id: int, sender: str) -> None:
+    """Recompute the stored previews of a sender's emails with its current blocks."""
+    blocks = sender_blocks(conn, user_id, sender)
+    rows = conn.execute(
+        "SELECT id, content, raw_content FROM emails WHERE user_id = ? AND lower(trim(sender)) = ?",
+        (user_id, sender)
+    ).fetchall()
+    conn.executemany(
+        "UPDATE emails SET preview = ? WHERE id = ? AND user_id = ?",
+        [(email_preview(strip_boilerplate(row["raw_content"] or row["content"], blocks)), row["id"], user_id)
+         for row in rows]
+    )
+
+
+class BoilerplateStore:
+    """Learns, lists, and strips per-sender boilerplate."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the boilerplate store.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def _learn_sender_sync(self, conn: sqlite3.Connection, user_id: int, sender: str) -> List[BoilerplateBlock]:
+        """Replace a sender's learned blocks with those its recent emails share."""
+        rows = conn.execute(
+            """
+            SELECT content, raw_content FROM emails
+            WHERE user_id = ? AND lower(trim(sender)) = ?
+            ORDER BY received_date DESC LIMIT ?
+            """,
+            (user_id, sender, LEARN_WINDOW)
+        ).fetchall()
+        blocks = detect_boilerplate(row["raw_content"] or row["content"] for row in rows)
+
+        conn.execute(
+            "DELETE FROM sender_boilerplate WHERE user_id = ? AND sender = ? AND dismissed = 0", (user_id, sender)
+        )
+        conn.executemany(
+            """
+            INSERT OR IGNORE INTO sender_boilerplate
+                (user_id, sender, block_hash, block, line_count, email_count)
+            VALUES (?, ?, ?, ?, ?, ?)
+            """,
+            [
+                (user_id, sender, block.block_hash, "\n".join(block.lines), len(block.lines), block.email_count)
+                for block in blocks
+            ]
+        )
+        _refresh_previews(conn, user_id, sender)
+        return blocks
+
+    async def learn_sender(self, user_id: int, sender: str) -> int:
+        """Learn one sender's boilerplate from their stored emails.
+
+        Returns:
+            Number of blocks learned (dismissed blocks included)
+        """
+        loop = asyncio.get_event_loop()
+
+        def _learn_sync():
+            with self.db.get_connection() as conn:
+                blocks = self._learn_sender_sync(conn, user_id, sender_key(sender))
+                conn.commit()
+            return len(blocks)
+
+        return await loop.run_in_executor(None, _learn_sync)
+
+    async def learn_all(self, user_id: Optional[int] = None) -> int:
+        """Learn the boilerplate of every sender with at least MIN_REPEATS stored emails.
+
+        Args:
+            user_id: Only this user's senders (defaults to every user's)
+
+        Returns:
+            Number of blocks learned
+        """
+        loop = asyncio.get_event_loop()
+
+        def _learn_all_sync():
+            learned = 0
+            with self.db.get_connection() as conn:
+                senders = conn.execute(
+                    """
+                    SELECT user_id, lower(trim(sender)) AS sender FROM emails
+                    WHERE (? IS NULL OR user_id = ?) AND trim(COALESCE(sender, '')) != ''
+                    GROUP BY user_id, lower(trim(sender)) HAVING COUNT(*) >= ?
+                    """,
+                    (user_id, user_id, MIN_REPEATS)
+                ).fetchall()
+                for row in senders:
+                    learned += len(self._learn_sender_sync(conn, row["user_id"], row["sender"]))
+                conn.commit()
+            return learned
+
+        return await loop.run_in_executor(None, _learn_all_sync)
+
+    async def list_boilerplate(self, user_id: int, sender: Optional[str] = None) -> List[LearnedBoilerplate]:
+        """List a user's learned blocks that are not dismissed, by sender.
+
+        Args:
+            user_id: Owner of the blocks
+            sender: Only this sender's blocks
+        """
+        loop = asyncio.get_event_loop()
+
+        def _list_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    """
+                    SELECT id, sender, block, line_count, email_count, learned_at FROM sender_boilerplate
+                    WHERE user_id = ? AND dismissed = 0 AND (? IS NULL OR sender = ?)
+                    ORDER BY sender, line_count DESC, id
+                    """,
+                    (user_id, sender_key(sender) if sender else None, sender_key(sender) if sender else None)
+                ).fetchall()
+            return [LearnedBoilerplate(**dict(row)) for row in rows]
+
+        return await loop.run_in_executor(None, _list_sync)
+
+    async def dismiss(self, user_id: int, block_id: Optional[int] = None, sender: Optional[str] = None) -> int:
+        """Dismiss one learned block, or all of a sender's.
+
+        Dismissed blocks are no longer stripped or learned again. The
+        stored previews of the sender's emails are recomputed.
+
+        Args:
+            user_id: Owner of the blocks
+            block_id: Block to dismiss
+            sender: Sender whose blocks to dismiss, when no block_id is given
+
+        Returns:
+            Number of blocks dismissed
+        """
+        loop = asyncio.get_event_loop()
+        where, params = ("id = ?", [block_id]) if block_id is not None else ("sender = ?", [sender_key(sender)])
+
+        def _dismiss_sync():
+            with self.db.get_connection() as conn:
+                senders = [
+                    row["sender"] for row in conn.execute(
+                        f"SELECT DISTINCT sender FROM sender_boilerplate "
+                        f"WHERE user_id = ? AND dismissed = 0 AND {where}",
+                        [user_id, *params]
+                    )
+                ]
+                dismissed = conn.execute(
+                    f"UPDATE sender_boilerplate SET dismissed = 1 WHERE user_id = ? AND dismissed = 0 AND {where}",
+                    [user_id, *params]
+                ).rowcount
+                for dismissed_sender in senders:
+                    _refresh_previews(conn, user_id, dismissed_sender)
+                conn.commit()
+            return dismissed
+
+        return await loop.run_in_executor(None, _dismiss_sync)
+
+    async def strip_emails(self, user_id: int, emails: Sequence[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Copies of emails with their senders' boilerplate stripped from body and content.
+
+        Args:
+            user_id: Owner of the emails
+            emails: Stored or provider email dicts
+
+        Returns:
+            The copies, in the same order
+        """
+        loop = asyncio.get_event_loop()
+
+        def _strip_sync():
+            with self.db.get_connection() as conn:
+                blocks = {
+                    sender: sender_blocks(conn, user_id, sender)
+                    for sender in {sender_key(email.get("sender")) for email in emails}
+                }
+            stripped = []
+            for email in emails:
+                known = blocks[sender_key(email.get("sender"))]
+                stripped.append({
+                    **email,
+                    **{
+                        field: strip_boilerplate(email[field], known)
+                        for field in ("body", "content") if email.get(field)
+                    }
+                })
+            return stripped
+
+        return await loop.run_in_executor(None, _strip_sync)
+
+
+    async def strip_body(self, user_id: int, sender: Optional[str], body: Optional[str]) -> Optional[str]:
+        """A body with its sender's boilerplate stripped."""
+        stripped, = await self.strip_emails(user_id, [{"sender": sender, "body": body}])
+        return stripped["body"]
+
+
+async def run_learning(store: BoilerplateStore) -> int:
+    """Learn every sender's boilerplate once (the boilerplate_learn background job)."""
+    learned = await store.learn_all()
+    if learned:
+        logger.info(f"Learned {learned} sender boilerplate blocks")
+    return learned
+
+
+# Dependency for FastAPI
+def get_boilerplate_store() -> BoilerplateStore:
+    """FastAPI dependency for the boilerplate store."""
+    return BoilerplateStore()
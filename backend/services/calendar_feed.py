@@ -0,0 +1,216 @@
+"""Task calendar feed for FastAPI Email Helper API.
+
+GET /api/feeds/tasks.ics serves an iCalendar feed that Outlook or Google
+Calendar can subscribe to. It lists each open task with a due date, and
+marks the ones whose due date is a deadline detected in an email (tasks
+created from extracted action items, source="auto") as detected
+deadlines.
+
+It also lists, as all-day detected deadlines, the dated actions the
+latest holistic analysis of each stored email found (holistic_deadlines,
+kept by services.holistic_runs). An email with an open dated task is
+left to the task's event.
+
+Calendar clients cannot send a bearer token, so the feed URL carries a
+per-user feed token instead. Only a hash of the token is stored in
+user_settings; generating a new token invalidates the old URL.
+
+Events keep a stable UID per task or email and their SEQUENCE is the
+number of times the due date changed, so clients update events in place.
+The feed is rendered only from stored rows, which makes it deterministic
+and lets its hash serve as the ETag.
+"""
+
+import asyncio
+import hashlib
+import secrets
+from datetime import datetime, time
+from typing import Any, Dict, List, Optional
+
+from backend.core.ics import render_calendar
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.task import TaskStatus
+from backend.services.auto_tasks import AUTO_TASK_SOURCE
+
+FEED_NAME = "Email Helper tasks"
+DETECTED_DEADLINE_CATEGORY = "Detected deadline"
+
+# Task priority -> iCalendar PRIORITY (1 highest, 9 lowest)
+TASK_PRIORITIES = {"urgent": 1, "high": 3, "medium": 5, "low": 9}
+
+_CLOSED_STATUSES = (TaskStatus.COMPLETED.value, TaskStatus.CANCELLED.value)
+
+
+def hash_feed_token(token: str) -> str:
+    """Stored form of a feed token."""
+    return hashlib.sha256(token.encode("utf-8")).hexdigest()
+
+
+def _as_datetime(value: Any) -> datetime:
+    """Stored timestamp as a datetime."""
+    return value if isinstance(value, datetime) else datetime.fromisoformat(str(value))
+
+
+def task_event(task: Dict[str, Any]) -> Dict[str, Any]:
+    """Calendar event for a stored task row."""
+    detected = task.get("source") == AUTO_TASK_SOURCE
+    return {
+        "uid": f"task-{task['id']}@email-helper",
+        "start": _as_datetime(task["due_date"]),
+        "stamp": _as_datetime(task["updated_at"]),
+        "sequence": task.get("due_date_sequence") or 0,
+        "summary": task["title"],
+        "description": task.get("description"),
+        "categories": [DETECTED_DEADLINE_CATEGORY] if detected else [],
+        "priority": TASK_PRIORITIES.get(task.get("priority") or "", 0),
+    }
+
+
+def deadline_event(deadline: Dict[str, Any]) -> Dict[str, Any]:
+    """All-day calendar event for a stored holistic deadline row (joined with its email's subject)."""
+    email_key = hashlib.sha256(deadline["email_id"].encode("utf-8")).hexdigest()[:16]
+    return {
+        "uid": f"deadline-{email_key}@email-helper",
+        "start": datetime.combine(datetime.fromisoformat(deadline["deadline"]).date(), time()),
+        "stamp": _as_datetime(deadline["updated_at"]),
+        "sequence": deadline["sequence"],
+        "summary": deadline.get("topic") or deadline["subject"],
+        "description": None,
+        "categories": [DETECTED_DEADLINE_CATEGORY],
+        "priority": TASK_PRIORITIES.get(deadline.get("priority") or "", 0),
+    }
+
+
+def render_task_feed(tasks: List[Dict[str, Any]], deadlines: Optional[List[Dict[str, Any]]] = None) -> str:
+    """iCalendar text for task rows and holistic deadline rows (each ordered by date, then ID)."""
+    events = [task_event(task) for task in tasks] + [deadline_event(deadline) for deadline in deadlines or []]
+    # Stable, so events on the same start keep tasks first, each in query order
+    events.sort(key=lambda event: event["start"])
+    return render_calendar(events, FEED_NAME)
+
+
+def feed_etag(body: str) -> str:
+    """Strong ETag of a rendered feed."""
+    return f'"{hashlib.sha256(body.encode("utf-8")).hexdigest()[:32]}"'
+
+
+class CalendarFeedService:
+    """Feed tokens and feed rendering."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the calendar feed service.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def rotate_token(self, user_id: int) -> str:
+        """Create the user's feed token, replacing any earlier one.
+
+        Returns:
+            The new token; only its hash is stored, so it cannot be read back
+        """
+        loop = asyncio.get_event_loop()
+        token = secrets.token_urlsafe(32)
+
+        def _rotate_sync():
+            with self.db.get_connection() as conn:
+                conn.execute(
+                    """
+                    INSERT INTO user_settings (user_id, feed_token_hash, feed_token_created_at)
+                    VALUES (?, ?, ?)
+                    ON CONFLICT(user_id) DO UPDATE SET
+                        feed_token_hash = excluded.feed_token_hash,
+                        feed_token_created_at = excluded.feed_token_created_at
+                    """,
+                    (user_id, hash_feed_token(token), datetime.now())
+                )
+                conn.commit()
+
+        await loop.run_in_executor(None, _rotate_sync)
+        return token
+
+    async def revoke_token(self, user_id: int) -> bool:
+        """Remove the user's feed token; returns whether there was one."""
+        loop = asyncio.get_event_loop()
+
+        def _revoke_sync():
+            with self.db.get_connection() as conn:
+                cursor = conn.execute(
+                    """
+                    UPDATE user_settings SET feed_token_hash = NULL, feed_token_created_at = NULL
+                    WHERE user_id = ? AND feed_token_hash IS NOT NULL
+                    """,
+                    (user_id,)
+                )
+                conn.commit()
+                return cursor.rowcount > 0
+
+        return await loop.run_in_executor(None, _revoke_sync)
+
+    async def token_created_at(self, user_id: int) -> Optional[datetime]:
+        """When the user's current feed token was created, or None without one."""
+        loop = asyncio.get_event_loop()
+
+        def _created_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    "SELECT feed_token_created_at FROM user_settings WHERE user_id = ? AND feed_token_hash IS NOT NULL",
+                    (user_id,)
+                ).fetchone()
+                return _as_datetime(row["feed_token_created_at"]) if row else None
+
+        return await loop.run_in_executor(None, _created_sync)
+
+    async def user_for_token(self, token: str) -> Optional[int]:
+        """User a feed token belongs to, or None for unknown tokens."""
+        loop = asyncio.get_event_loop()
+
+        def _lookup_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    "SELECT user_id FROM user_settings WHERE feed_token_hash = ?",
+                    (hash_feed_token(token),)
+                ).fetchone()
+                return row["user_id"] if row else None
+
+        return await loop.run_in_executor(None, _lookup_sync)
+
+    async def render_feed(self, user_id: int) -> str:
+        """The user's task feed as iCalendar text."""
+        loop = asyncio.get_event_loop()
+
+        def _load_sync():
+            with self.db.get_connection() as conn:
+                tasks = [dict(row) for row in conn.execute(
+                    """
+                    SELECT id, title, description, priority, due_date, updated_at, source, due_date_sequence
+                    FROM tasks
+                    WHERE user_id = ? AND due_date IS NOT NULL AND status NOT IN (?, ?)
+                    ORDER BY datetime(due_date), id
+                    """,
+                    (user_id, *_CLOSED_STATUSES)
+                )]
+                deadlines = [dict(row) for row in conn.execute(
+                    """
+                    SELECT d.email_id, d.topic, d.priority, d.deadline, d.sequence, d.updated_at, e.subject
+                    FROM holistic_deadlines d
+                    JOIN emails e ON e.id = d.email_id AND e.user_id = d.user_id
+                    WHERE d.user_id = ? AND NOT EXISTS (
+                        SELECT 1 FROM tasks t
+                        WHERE t.user_id = d.user_id AND t.email_id = d.email_id
+                            AND t.due_date IS NOT NULL AND t.status NOT IN (?, ?)
+                    )
+                    ORDER BY d.deadline, d.id
+                    """,
+                    (user_id, *_CLOSED_STATUSES)
+                )]
+                return tasks, deadlines
+
+        return render_task_feed(*await loop.run_in_executor(None, _load_sync))
+
+
+def get_calendar_feed_service() -> CalendarFeedService:
+    """FastAPI dependency for calendar feed service."""
+    return CalendarFeedService()
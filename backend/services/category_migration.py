@@ -0,0 +1,136 @@
+"""Category migration service for FastAPI Email Helper API.
+
+POST /api/admin/categories/migrate renames a category everywhere it is
+stored for a user, so history stays consistent when a category concept is
+renamed or split:
+
+- emails: the category of matching rows (their version is bumped, so
+  stale corrections are refused as for any reclassification)
+- classification_history: both the previous and the new category
+- saved_views: views whose category filter names the old category
+
+Every table is rewritten in one transaction together with an audit row in
+category_migrations. A dry run counts the rows instead and changes nothing.
+"""
+
+import asyncio
+import json
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional
+
+from backend.database.connection import DatabaseManager, get_default_manager
+
+
+@dataclass
+class MigrationOutcome:
+    """What a category migration changed."""
+    affected: Dict[str, int] = field(default_factory=dict)
+    email_ids: List[str] = field(default_factory=list)  # Migrated emails, for re-applying in Outlook
+    migration_id: Optional[int] = None
+
+
+class CategoryMigrationService:
+    """Service layer for renaming stored categories."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the category migration service.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def migrate(
+        self,
+        user_id: int,
+        from_category: str,
+        to_category: str,
+        dry_run: bool = False,
+        update_outlook: bool = False
+    ) -> MigrationOutcome:
+        """Rename a category in every table that stores it.
+
+        Args:
+            user_id: Owner of the migrated rows
+            from_category: Category to migrate away from
+            to_category: Category to migrate to
+            dry_run: Only count the rows that would change
+            update_outlook: Recorded in the audit row; the caller re-applies
+                the category in Outlook
+
+        Returns:
+            Changed (or matching, for a dry run) rows by table, the migrated
+            email IDs, and the audit row ID (None for a dry run)
+        """
+        loop = asyncio.get_event_loop()
+
+        def _migrate_sync():
+            outcome = MigrationOutcome()
+            with self.db.get_connection() as conn:
+                try:
+                    outcome.email_ids = [
+                        row["id"] for row in conn.execute(
+                            "SELECT id FROM emails WHERE user_id = ? AND category = ? ORDER BY id",
+                            (user_id, from_category)
+                        )
+                    ]
+                    history_count = conn.execute(
+                        """
+                        SELECT COUNT(*) FROM classification_history
+                        WHERE user_id = ? AND (category = ? OR previous_category = ?)
+                        """,
+                        (user_id, from_category, from_category)
+                    ).fetchone()[0]
+                    views = []
+                    for row in conn.execute("SELECT id, filters FROM saved_views WHERE user_id = ?", (user_id,)):
+                        filters = json.loads(row["filters"])
+                        if filters.get("category") == from_category:
+                            views.append((row["id"], {**filters, "category": to_category}))
+
+                    outcome.affected = {
+                        "emails": len(outcome.email_ids),
+                        "classification_history": history_count,
+                        "saved_views": len(views),
+                    }
+                    if dry_run:
+                        return outcome
+
+                    conn.execute(
+                        """
+                        UPDATE emails
+                        SET category = ?, processed_at = CURRENT_TIMESTAMP, version = version + 1
+                        WHERE user_id = ? AND category = ?
+                        """,
+                        (to_category, user_id, from_category)
+                    )
+                    for column in ("category", "previous_category"):
+                        conn.execute(
+                            f"UPDATE classification_history SET {column} = ? WHERE user_id = ? AND {column} = ?",
+                            (to_category, user_id, from_category)
+                        )
+                    for view_id, filters in views:
+                        conn.execute(
+                            "UPDATE saved_views SET filters = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+                            (json.dumps(filters, sort_keys=True), view_id)
+                        )
+                    cursor = conn.execute(
+                        """
+                        INSERT INTO category_migrations
+                            (user_id, from_category, to_category, affected, update_outlook)
+                        VALUES (?, ?, ?, ?, ?)
+                        """,
+                        (user_id, from_category, to_category, json.dumps(outcome.affected), int(update_outlook))
+                    )
+                    outcome.migration_id = cursor.lastrowid
+                    conn.commit()
+                except Exception:
+                    conn.rollback()
+                    raise
+            return outcome
+
+        return await loop.run_in_executor(None, _migrate_sync)
+
+
+def get_category_migration_service() -> CategoryMigrationService:
+    """FastAPI dependency for category migration service."""
+    return CategoryMigrationService()
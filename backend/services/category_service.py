@@ -0,0 +1,189 @@
+"""Classification category service layer for FastAPI Email Helper API.
+
+The categories table holds the set the classifier chooses from. It is
+seeded with the built-in categories (src/classification_categories.py)
+and users can add their own through /api/categories. Every consumer reads
+the current set from here, so a new category reaches the classifier
+prompt, response and request validation, and folder suggestions as soon
+as it is saved.
+
+Built-in categories can be edited but not deleted; their stored emails
+can be moved elsewhere with POST /api/admin/categories/migrate.
+"""
+
+import asyncio
+import sqlite3
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.category import Category, CategoryCreate, CategoryUpdate
+from classification_categories import category_prompt_inputs
+
+_ORDER = "ORDER BY sort_order, name"
+
+
+def load_categories(db: Optional[DatabaseManager] = None) -> List[Dict[str, Any]]:
+    """Current categories as the dicts classification_categories renders, in prompt order."""
+    with (db or get_default_manager()).get_connection() as conn:
+        rows = conn.execute(f"SELECT name, description, priority, folder FROM categories {_ORDER}").fetchall()
+    return [dict(row) for row in rows]
+
+
+def category_names(db: Optional[DatabaseManager] = None) -> List[str]:
+    """Names of the current categories, in prompt order."""
+    return [category["name"] for category in load_categories(db)]
+
+
+def category_folders(db: Optional[DatabaseManager] = None) -> Dict[str, str]:
+    """Category name -> Outlook folder, for categories that are filed away."""
+    return {category["name"]: category["folder"] for category in load_categories(db) if category["folder"]}
+
+
+def classifier_prompt_inputs(username: str, db: Optional[DatabaseManager] = None) -> Dict[str, str]:
+    """category_rules and category_names inputs for the classifier prompt."""
+    return category_prompt_inputs(load_categories(db), username)
+
+
+class CategoryService:
+    """Service layer for category CRUD."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the category service.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def list_categories(self) -> List[Category]:
+        """List categories in prompt order."""
+        loop = asyncio.get_event_loop()
+
+        def _list_categories_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(f"SELECT * FROM categories {_ORDER}").fetchall()
+                return [self._row_to_category(row) for row in rows]
+
+        return await loop.run_in_executor(None, _list_categories_sync)
+
+    async def get_category(self, name: str) -> Optional[Category]:
+        """Get a category by name."""
+        loop = asyncio.get_event_loop()
+
+        def _get_category_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute("SELECT * FROM categories WHERE name = ?", (name,)).fetchone()
+                return self._row_to_category(row) if row else None
+
+        return await loop.run_in_executor(None, _get_category_sync)
+
+    async def create_category(self, category: CategoryCreate) -> Category:
+        """Add a user-defined category after the existing ones.
+
+        Raises:
+            sqlite3.IntegrityError: If a category with the name exists
+        """
+        loop = asyncio.get_event_loop()
+
+        def _create_category_sync():
+            current_time = datetime.now()
+            with self.db.get_connection() as conn:
+                sort_order = conn.execute("SELECT COALESCE(MAX(sort_order), -1) + 1 FROM categories").fetchone()[0]
+                conn.execute(
+                    """
+                    INSERT INTO categories
+                        (name, description, priority, folder, built_in, sort_order, created_at, updated_at)
+                    VALUES (?, ?, ?, ?, 0, ?, ?, ?)
+                    """,
+                    (
+                        category.name,
+                        category.description,
+                        category.priority,
+                        category.folder or None,
+                        sort_order,
+                        current_time,
+                        current_time
+                    )
+                )
+                conn.commit()
+                row = conn.execute("SELECT * FROM categories WHERE name = ?", (category.name,)).fetchone()
+                return self._row_to_category(row)
+
+        return await loop.run_in_executor(None, _create_category_sync)
+
+    async def update_category(self, name: str, updates: CategoryUpdate) -> Optional[Category]:
+        """Update a category's rules, priority, or folder."""
+        loop = asyncio.get_event_loop()
+
+        def _update_category_sync():
+            update_fields = []
+            update_values = []
+
+            if updates.description is not None:
+                update_fields.append("description = ?")
+                update_values.append(updates.description)
+
+            if updates.priority is not None:
+                update_fields.append("priority = ?")
+                update_values.append(updates.priority)
+
+            if updates.folder is not None:
+                update_fields.append("folder = ?")
+                update_values.append(updates.folder or None)
+
+            with self.db.get_connection() as conn:
+                if update_fields:
+                    update_fields.append("updated_at = ?")
+                    update_values.append(datetime.now())
+                    conn.execute(
+                        f"UPDATE categories SET {', '.join(update_fields)} WHERE name = ?",
+                        update_values + [name]
+                    )
+                    conn.commit()
+
+                row = conn.execute("SELECT * FROM categories WHERE name = ?", (name,)).fetchone()
+                return self._row_to_category(row) if row else None
+
+        return await loop.run_in_executor(None, _update_category_sync)
+
+    async def count_emails(self, name: str) -> int:
+        """Count stored emails (of any user) in a category."""
+        loop = asyncio.get_event_loop()
+
+        def _count_emails_sync():
+            with self.db.get_connection() as conn:
+                return conn.execute("SELECT COUNT(*) FROM emails WHERE category = ?", (name,)).fetchone()[0]
+
+        return await loop.run_in_executor(None, _count_emails_sync)
+
+    async def delete_category(self, name: str) -> bool:
+        """Delete a user-defined category; built-in categories are never deleted."""
+        loop = asyncio.get_event_loop()
+
+        def _delete_category_sync():
+            with self.db.get_connection() as conn:
+                cursor = conn.execute("DELETE FROM categories WHERE name = ? AND built_in = 0", (name,))
+                conn.commit()
+                return cursor.rowcount > 0
+
+        return await loop.run_in_executor(None, _delete_category_sync)
+
+    def _row_to_category(self, row: sqlite3.Row) -> Category:
+        """Convert database row to Category model."""
+        return Category(
+            name=row["name"],
+            description=row["description"],
+            priority=row["priority"],
+            folder=row["folder"],
+            built_in=bool(row["built_in"]),
+            sort_order=row["sort_order"],
+            created_at=row["created_at"],
+            updated_at=row["updated_at"]
+        )
+
+
+# Dependency for FastAPI
+def get_category_service() -> CategoryService:
+    """FastAPI dependency for category service."""
+    return CategoryService()
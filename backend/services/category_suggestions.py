@@ -0,0 +1,207 @@
+"""Category suggestions for manual classification in FastAPI Email Helper API.
+
+When the user overrides a classification, GET /api/emails/{id}/category-suggestions
+proposes likely categories at once, without a model call, from four sources:
+
+- rule: an enabled Outlook rule whose sender or subject conditions match
+  the email files it into a category's folder (see services.rule_import)
+- sender_override: the category the user last corrected the sender's mail to
+- sender_history: the category the user has corrected the sender's mail to
+  most often, from classification_history
+- ai_alternative: the runner-up categories the classifier gave when it
+  classified the email, kept in the alternative_categories column
+
+Ranking is a pure function of those inputs so it can be tested against
+seeded histories; EmailService gathers the corrections and nothing is
+changed.
+"""
+
+import json
+import logging
+from typing import Any, Dict, List, Optional, Sequence
+
+from backend.models.email import CategorySuggestion, CategorySuggestionsResponse
+from backend.services.category_service import category_folders, category_names
+from backend.services.rule_import import plan_rule_import
+
+logger = logging.getLogger(__name__)
+
+MAX_SUGGESTIONS = 5
+
+SOURCE_RULE = "rule"
+SOURCE_SENDER_OVERRIDE = "sender_override"
+SOURCE_SENDER_HISTORY = "sender_history"
+SOURCE_AI_ALTERNATIVE = "ai_alternative"
+
+# Score of a category an Outlook rule files the email into
+RULE_SCORE = 0.9
+
+# Score of the category the sender's mail was last corrected to
+SENDER_OVERRIDE_SCORE = 0.8
+
+# Share of the classifier's doubt given to alternatives when it reported no confidence
+DEFAULT_ALTERNATIVE_SHARE = 0.5
+
+# Ties go to the source the user's own choices back
+_SOURCE_PRIORITY = {SOURCE_RULE: 0, SOURCE_SENDER_OVERRIDE: 1, SOURCE_SENDER_HISTORY: 2, SOURCE_AI_ALTERNATIVE: 3}
+
+
+def alternative_names(raw: Any) -> List[str]:
+    """Category names of classifier alternatives, best first.
+
+    Accepts the names themselves or {"category": ...} objects, as the
+    classifier prompts return either; blanks and repeats are dropped.
+    """
+    names = []
+    for item in raw or []:
+        name = item.get("category") if isinstance(item, dict) else item
+        if isinstance(name, str) and name.strip():
+            names.append(name.strip())
+    return list(dict.fromkeys(names))
+
+
+def encode_alternatives(names: Sequence[str]) -> Optional[str]:
+    """Value of the alternative_categories column (NULL when there are none)."""
+    return json.dumps(list(names)) if names else None
+
+
+def decode_alternatives(value: Optional[str]) -> List[str]:
+    """Categories stored in an alternative_categories column."""
+    if not value:
+        return []
+    try:
+        names = json.loads(value)
+    except ValueError:
+        return []
+    return alternative_names(names) if isinstance(names, list) else []
+
+
+def history_score(count: int, total: int) -> float:
+    """Score of a category the sender's mail was corrected to count of total times.
+
+    One is added to the total so a single past correction does not read as certainty.
+    """
+    return count / (total + 1) if total > 0 else 0.0
+
+
+def alternative_score(rank: int, confidence: Optional[float]) -> float:
+    """Score of the classifier's rank-th alternative (0 is its runner-up).
+
+    Alternatives share the classifier's doubt in its own answer, halving
+    with each rank.
+    """
+    doubt = DEFAULT_ALTERNATIVE_SHARE if confidence is None else 1.0 - confidence
+    return doubt / (2 ** rank)
+
+
+def rule_matches(candidate, sender: str, subject: str) -> bool:
+    """Whether an imported-rule candidate's conditions hold for an email.
+
+    As in Outlook, each condition matches if any of its values is contained
+    in the field (ignoring case) and every condition must match.
+    """
+    sender, subject = sender.lower(), subject.lower()
+    if candidate.senders and not any(value.lower() in sender for value in candidate.senders):
+        return False
+    if candidate.subject_contains and not any(value.lower() in subject for value in candidate.subject_contains):
+        return False
+    return bool(candidate.senders or candidate.subject_contains)
+
+
+def rank_category_suggestions(
+    current_category: Optional[str],
+    valid_categories: Sequence[str],
+    rule_categories: Sequence[str] = (),
+    corrections: Sequence[str] = (),
+    alternatives: Sequence[str] = (),
+    confidence: Optional[float] = None,
+    limit: int = MAX_SUGGESTIONS
+) -> List[CategorySuggestion]:
+    """Combine the suggestion sources into the likeliest few categories.
+
+    A category suggested by several sources keeps its highest-scoring
+    suggestion. The email's current category and categories that no
+    longer exist are never suggested.
+
+    Args:
+        current_category: Category the email has now
+        valid_categories: Names of the current categories
+        rule_categories: Categories of Outlook rules matching the email
+        corrections: Categories the sender's mail was corrected to, newest first
+        alternatives: The classifier's alternatives for the email, best first
+        confidence: The classifier's confidence in its own category
+        limit: Most suggestions to return
+
+    Returns:
+        Suggestions, highest score first
+    """
+    valid = set(valid_categories)
+    candidates: List[CategorySuggestion] = []
+
+    def add(category: str, source: str, score: float):
+        if category == current_category or category not in valid:
+            return
+        candidates.append(CategorySuggestion(
+            category=category, source=source, score=round(max(0.0, min(1.0, score)), 3)
+        ))
+
+    for category in rule_categories:
+        add(category, SOURCE_RULE, RULE_SCORE)
+    if corrections:
+        add(corrections[0], SOURCE_SENDER_OVERRIDE, SENDER_OVERRIDE_SCORE)
+    counts: Dict[str, int] = {}
+    for category in corrections:
+        counts[category] = counts.get(category, 0) + 1
+    for category, count in counts.items():
+        add(category, SOURCE_SENDER_HISTORY, history_score(count, len(corrections)))
+    for rank, category in enumerate(alternatives):
+        add(category, SOURCE_AI_ALTERNATIVE, alternative_score(rank, confidence))
+
+    candidates.sort(key=lambda s: (-s.score, _SOURCE_PRIORITY[s.source], s.category))
+    best: Dict[str, CategorySuggestion] = {}
+    for suggestion in candidates:
+        best.setdefault(suggestion.category, suggestion)
+    return list(best.values())[:limit]
+
+
+async def suggest_categories(email_service, email_id: str, user_id: int) -> Optional[CategorySuggestionsResponse]:
+    """Suggest categories for a stored email without a model call.
+
+    Outlook rules are read from the mail client; if they cannot be read,
+    rule suggestions are only left out.
+
+    Args:
+        email_service: EmailService for the email, rules, and corrections
+        email_id: Email being classified by hand
+        user_id: Owner of the stored emails
+
+    Returns:
+        The email's category and suggestions, highest score first, or None
+        if the email is not stored
+    """
+    email = (await email_service.get_stored_emails([email_id], user_id)).get(email_id)
+    if not email:
+        return None
+
+    sender = email.get("sender") or ""
+    rule_categories: List[str] = []
+    try:
+        plan = plan_rule_import(await email_service.get_rules(), category_folders(email_service.db))
+        rule_categories = [
+            candidate.category for candidate in plan.candidates
+            if candidate.enabled and rule_matches(candidate, sender, email.get("subject") or "")
+        ]
+    except Exception as e:
+        logger.warning(f"Outlook rules unavailable for category suggestions on {email_id}: {e}")
+
+    suggestions = rank_category_suggestions(
+        email.get("category"),
+        category_names(email_service.db),
+        rule_categories=rule_categories,
+        corrections=await email_service.get_sender_corrections(sender, user_id) if sender else [],
+        alternatives=email.get("alternative_categories") or [],
+        confidence=email.get("ai_confidence")
+    )
+    return CategorySuggestionsResponse(
+        email_id=email_id, current_category=email.get("category"), suggestions=suggestions
+    )
@@ -0,0 +1,86 @@
+"""Classification explanation helpers for FastAPI Email Helper API.
+
+Both AI services (standard and COM) answer "why not category X?" with the
+classification_explainer prompty template. This module builds the template
+inputs and normalizes the model output so the two services behave alike.
+"""
+
+import json
+import re
+from typing import Any, Dict, List, Optional
+
+from backend.models.ai_models import EMAIL_CATEGORIES
+
+EXPLAINER_TEMPLATE = "classification_explainer.prompty"
+
+VERDICTS = ("keep_current", "prefer_alternative", "ambiguous")
+
+
+def build_explainer_inputs(
+    subject: str,
+    sender: str,
+    content: str,
+    current_category: str,
+    current_reasoning: Optional[str],
+    alternative_category: str,
+    received_date: Optional[str] = None,
+    context: Optional[str] = None,
+    categories: Optional[List[str]] = None
+) -> Dict[str, Any]:
+    """Build prompty inputs comparing the current and alternative categories.
+
+    categories lists the valid category names (the built-in ones by default).
+    """
+    return {
+        "context": context or "",
+        "username": "User",
+        "subject": subject,
+        "sender": sender,
+        "date": received_date or "Unknown",
+        "body": content,
+        "current_category": current_category,
+        "current_reasoning": current_reasoning or "No reasoning was recorded",
+        "alternative_category": alternative_category,
+        "categories": ", ".join(categories or EMAIL_CATEGORIES),
+    }
+
+
+def _as_list(value: Any) -> List[str]:
+    """Coerce a model field to a list of non-empty strings."""
+    if value is None:
+        return []
+    if isinstance(value, str):
+        return [value] if value.strip() else []
+    return [str(item) for item in value if str(item).strip()]
+
+
+def parse_explanation(raw: Any) -> Dict[str, Any]:
+    """Parse explainer output into {supports_current, supports_alternative, verdict}.
+
+    Accepts a dict or a JSON string, optionally wrapped in a code fence.
+
+    Raises:
+        ValueError: If the output is not a JSON object
+    """
+    if isinstance(raw, str):
+        text = raw.strip()
+        fenced = re.search(r"```(?:json)?\s*(.*?)```", text, re.DOTALL)
+        if fenced:
+            text = fenced.group(1).strip()
+        try:
+            raw = json.loads(text)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Explainer returned invalid JSON: {e}")
+
+    if not isinstance(raw, dict):
+        raise ValueError("Explainer returned a non-object response")
+
+    verdict = str(raw.get("verdict", "")).strip().lower()
+    if verdict not in VERDICTS:
+        verdict = "ambiguous"
+
+    return {
+        "supports_current": _as_list(raw.get("supports_current")),
+        "supports_alternative": _as_list(raw.get("supports_alternative")),
+        "verdict": verdict,
+    }
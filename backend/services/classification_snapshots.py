@@ -0,0 +1,291 @@
+"""Classification snapshots for FastAPI Email Helper API.
+
+Before trying aggressive rules or a new prompt, POST /api/admin/snapshots
+copies every stored email's (email_id, ai_category, category, confidence,
+folder) into a named snapshot. GET /api/admin/snapshots/{id}/compare diffs
+a snapshot against the current rows: how many emails changed, each
+category's churn, and the changed emails. POST
+/api/admin/snapshots/{id}/restore sets the stored categories back to the
+snapshot's as a bulk correction (the database only; Outlook is left
+alone).
+
+Snapshots are capped at settings.snapshot_max_emails emails each and
+settings.snapshot_max_per_user per user. The compare math is a pure
+function of the snapshot and current rows (compare_rows) so it can be
+tested without a database.
+"""
+
+import asyncio
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Tuple
+
+from backend.core.config import settings
+from backend.core.errors import ConflictError, InputValidationError
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.snapshot import CategoryChurn, Snapshot, SnapshotChange, SnapshotComparison
+
+# Label of emails without a category in per-category churn
+UNCLASSIFIED = "unclassified"
+
+# Most changed emails a comparison lists unless asked for fewer
+DEFAULT_CHANGE_LIMIT = 200
+
+_SNAPSHOT_COLUMNS = "ai_category, category, confidence, COALESCE(NULLIF(folder, ''), 'Inbox') AS folder"
+
+_COMPARED_FIELDS = ("category", "ai_category", "folder")
+
+
+def compare_rows(
+    snapshot: Dict[str, Dict[str, Any]],
+    current: Dict[str, Dict[str, Any]],
+    limit: int = DEFAULT_CHANGE_LIMIT
+) -> Dict[str, Any]:
+    """Diff a snapshot's rows against the current ones.
+
+    Args:
+        snapshot: Email ID -> snapshot row (category, ai_category, folder)
+        current: Email ID -> current stored row (same fields, plus subject)
+        limit: Most changes to list
+
+    Returns:
+        SnapshotComparison fields other than snapshot
+    """
+    before: Dict[str, int] = {}
+    after: Dict[str, int] = {}
+    left: Dict[str, int] = {}
+    joined: Dict[str, int] = {}
+    for row in snapshot.values():
+        category = row.get("category") or UNCLASSIFIED
+        before[category] = before.get(category, 0) + 1
+    for row in current.values():
+        category = row.get("category") or UNCLASSIFIED
+        after[category] = after.get(category, 0) + 1
+
+    changes, recategorized, removed = [], 0, 0
+    for email_id in sorted(snapshot):
+        old, new = snapshot[email_id], current.get(email_id)
+        if new is None:
+            removed += 1
+            continue
+        if all(old.get(name) == new.get(name) for name in _COMPARED_FIELDS):
+            continue
+        old_category, new_category = old.get("category") or UNCLASSIFIED, new.get("category") or UNCLASSIFIED
+        if old_category != new_category:
+            recategorized += 1
+            left[old_category] = left.get(old_category, 0) + 1
+            joined[new_category] = joined.get(new_category, 0) + 1
+        changes.append(SnapshotChange(
+            email_id=email_id,
+            subject=new.get("subject"),
+            before_category=old.get("category"),
+            after_category=new.get("category"),
+            before_ai_category=old.get("ai_category"),
+            after_ai_category=new.get("ai_category"),
+            before_folder=old.get("folder"),
+            after_folder=new.get("folder")
+        ))
+
+    compared = len(snapshot) - removed
+    categories = [
+        CategoryChurn(
+            category=category,
+            before=before.get(category, 0),
+            after=after.get(category, 0),
+            left=left.get(category, 0),
+            joined=joined.get(category, 0),
+            churn_rate=round(left.get(category, 0) / before[category], 4) if before.get(category) else 0.0
+        )
+        for category in sorted(set(before) | set(after), key=lambda name: (-before.get(name, 0), name))
+    ]
+    return {
+        "compared": compared,
+        "removed": removed,
+        "added": sum(1 for email_id in current if email_id not in snapshot),
+        "changed": len(changes),
+        "recategorized": recategorized,
+        "churn_rate": round(recategorized / compared, 4) if compared else 0.0,
+        "categories": categories,
+        "changes": changes[:limit],
+        "changes_truncated": len(changes) > limit,
+    }
+
+
+class SnapshotService:
+    """Service layer for classification snapshots."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the snapshot service.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def _run(self, func, *args):
+        loop = asyncio.get_event_loop()
+        return await loop.run_in_executor(None, func, *args)
+
+    async def create(self, user_id: int, name: str, now: Optional[datetime] = None) -> Snapshot:
+        """Copy the user's stored classifications into a named snapshot.
+
+        Raises:
+            ConflictError: If the name is taken or the user already keeps
+                settings.snapshot_max_per_user snapshots
+            InputValidationError: If the user has more than settings.snapshot_max_emails stored emails
+        """
+        created_at = now or datetime.utcnow()
+
+        def _create_sync():
+            with self.db.get_connection() as conn:
+                try:
+                    kept = conn.execute(
+                        "SELECT COUNT(*) FROM classification_snapshots WHERE user_id = ?", (user_id,)
+                    ).fetchone()[0]
+                    if kept >= settings.snapshot_max_per_user:
+                        raise ConflictError(
+                            f"{kept} snapshots are kept already (snapshot_max_per_user); delete one first"
+                        )
+                    if conn.execute(
+                        "SELECT 1 FROM classification_snapshots WHERE user_id = ? AND name = ?", (user_id, name)
+                    ).fetchone():
+                        raise ConflictError(f"A snapshot named '{name}' already exists")
+                    count = conn.execute("SELECT COUNT(*) FROM emails WHERE user_id = ?", (user_id,)).fetchone()[0]
+                    if count > settings.snapshot_max_emails:
+                        raise InputValidationError(
+                            f"{count} stored emails exceed snapshot_max_emails ({settings.snapshot_max_emails})"
+                        )
+                    snapshot_id = conn.execute(
+                        """
+                        INSERT INTO classification_snapshots (user_id, name, email_count, created_at)
+                        VALUES (?, ?, ?, ?)
+                        """,
+                        (user_id, name, count, created_at.isoformat())
+                    ).lastrowid
+                    conn.execute(
+                        f"""
+                        INSERT INTO classification_snapshot_rows
+                            (snapshot_id, email_id, ai_category, category, confidence, folder)
+                        SELECT ?, id, {_SNAPSHOT_COLUMNS} FROM emails WHERE user_id = ?
+                        """,
+                        (snapshot_id, user_id)
+                    )
+                    conn.commit()
+                except Exception:
+                    conn.rollback()
+                    raise
+            return Snapshot(id=snapshot_id, name=name, email_count=count, created_at=created_at)
+
+        return await self._run(_create_sync)
+
+    async def list(self, user_id: int) -> List[Snapshot]:
+        """The user's snapshots, newest first."""
+        def _list_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    """
+                    SELECT id, name, email_count, created_at FROM classification_snapshots
+                    WHERE user_id = ? ORDER BY created_at DESC, id DESC
+                    """,
+                    (user_id,)
+                ).fetchall()
+            return [Snapshot(**dict(row)) for row in rows]
+
+        return await self._run(_list_sync)
+
+    def _load_sync(self, snapshot_id: int, user_id: int) -> Optional[Tuple[Snapshot, Dict[str, Dict[str, Any]]]]:
+        """A snapshot and its rows by email ID, or None if the user has no such snapshot."""
+        with self.db.get_connection() as conn:
+            row = conn.execute(
+                "SELECT id, name, email_count, created_at FROM classification_snapshots WHERE id = ? AND user_id = ?",
+                (snapshot_id, user_id)
+            ).fetchone()
+            if row is None:
+                return None
+            rows = conn.execute(
+                "SELECT email_id, ai_category, category, confidence, folder "
+                "FROM classification_snapshot_rows WHERE snapshot_id = ?",
+                (snapshot_id,)
+            ).fetchall()
+        return Snapshot(**dict(row)), {row["email_id"]: dict(row) for row in rows}
+
+    async def compare(
+        self,
+        snapshot_id: int,
+        user_id: int,
+        limit: int = DEFAULT_CHANGE_LIMIT
+    ) -> Optional[SnapshotComparison]:
+        """Diff a snapshot against the user's current stored classifications.
+
+        Returns:
+            The comparison, or None if the user has no such snapshot
+        """
+        def _compare_sync():
+            loaded = self._load_sync(snapshot_id, user_id)
+            if loaded is None:
+                return None
+            snapshot, rows = loaded
+            with self.db.get_connection() as conn:
+                current = {
+                    row["id"]: dict(row) for row in conn.execute(
+                        f"SELECT id, subject, {_SNAPSHOT_COLUMNS} FROM emails WHERE user_id = ?", (user_id,)
+                    )
+                }
+            return SnapshotComparison(snapshot=snapshot, **compare_rows(rows, current, limit))
+
+        return await self._run(_compare_sync)
+
+    async def restore_changes(
+        self,
+        snapshot_id: int,
+        user_id: int
+    ) -> Optional[Tuple[List[Tuple[str, str]], List[str]]]:
+        """What restoring a snapshot's categories would change.
+
+        Returns:
+            (email_id, snapshot category) pairs for stored emails whose
+            category differs, and the snapshot emails no longer stored; None
+            if the user has no such snapshot
+        """
+        def _restore_changes_sync():
+            loaded = self._load_sync(snapshot_id, user_id)
+            if loaded is None:
+                return None
+            _, rows = loaded
+            with self.db.get_connection() as conn:
+                current = {
+                    row["id"]: row["category"]
+                    for row in conn.execute("SELECT id, category FROM emails WHERE user_id = ?", (user_id,))
+                }
+            changes, missing = [], []
+            for email_id in sorted(rows):
+                category = rows[email_id]["category"]
+                if email_id not in current:
+                    missing.append(email_id)
+                elif category and current[email_id] != category:
+                    changes.append((email_id, category))
+            return changes, missing
+
+        return await self._run(_restore_changes_sync)
+
+    async def delete(self, snapshot_id: int, user_id: int) -> bool:
+        """Delete one of the user's snapshots with its rows.
+
+        Returns:
+            False if the user has no such snapshot
+        """
+        def _delete_sync():
+            with self.db.get_connection() as conn:
+                deleted = conn.execute(
+                    "DELETE FROM classification_snapshots WHERE id = ? AND user_id = ?", (snapshot_id, user_id)
+                ).rowcount
+                if deleted:
+                    conn.execute("DELETE FROM classification_snapshot_rows WHERE snapshot_id = ?", (snapshot_id,))
+                conn.commit()
+            return deleted > 0
+
+        return await self._run(_delete_sync)
+
+
+def get_snapshot_service() -> SnapshotService:
+    """FastAPI dependency for classification snapshot service."""
+    return SnapshotService()
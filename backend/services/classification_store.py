@@ -0,0 +1,554 @@
+"""Stored classifications written by the processing pipeline.
+
+The pipeline worker stores each categorization result here. Saving returns
+the values being replaced so the run can report what it changed. The row
+is written by upsert_classified_email, which EmailService.save_classification
+also uses, so an email classified by the pipeline carries the same body,
+preview, recipients, attachments, folder, and protection flag as one
+classified through the API.
+
+Every AI classification also records when it was made and with which
+classifier prompt version and model, so later runs can skip emails that
+were already classified by the current prompt, and keeps the predicted
+category and confidence in ai_category and ai_confidence. A user
+correction changes only category, so comparing the two gives the
+calibration report: how often predictions at each confidence were wrong.
+"""
+
+import asyncio
+import sqlite3
+from datetime import datetime, timedelta
+from pathlib import Path
+from typing import Any, Dict, Iterable, List, Optional, Set, Tuple
+
+from backend.core.attachments import attachment_columns
+from backend.core.config import settings
+from backend.core.email_filters import DEFAULT_SORT, EMAIL_SORTS, build_filter_clause, validate_email_filters
+from backend.core.preview import email_preview
+from backend.core.prompts import prompt_library
+from backend.core.protection import is_protected_item
+from backend.core.recipients import recipient_columns
+from backend.core.sanitize import looks_like_html, sanitize_body
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.ai_models import CalibrationBucket, CalibrationReport, CategoryCalibration
+from backend.services.boilerplate import sender_blocks, strip_boilerplate
+from backend.services.category_suggestions import encode_alternatives
+
+CLASSIFIER_TEMPLATE = "email_classifier_with_explanation.prompty"
+
+PROMPTS_DIR = Path(__file__).parent.parent.parent / "prompts"
+
+# Processing criteria category matching emails that have no category yet
+UNCLASSIFIED = "unclassified"
+
+# Confidence buckets of the calibration report (deciles)
+CALIBRATION_BUCKETS = 10
+
+
+def prompt_version(template: str = CLASSIFIER_TEMPLATE) -> str:
+    """Identify a prompt template by name and frontmatter version.
+
+    The version is that of the active layer (see backend.core.prompts), so
+    classifications made with a user override are told apart from ones made
+    with the bundled template.
+
+    Returns:
+        e.g. "email_classifier_with_explanation@2.0", or "<name>@unversioned"
+        if the template has no version
+    """
+    active = prompt_library.get(template)
+    return f"{Path(template).stem}@{active.version if active and active.version else 'unversioned'}"
+
+
+def upsert_classified_email(
+    conn: sqlite3.Connection,
+    email: Dict[str, Any],
+    user_id: Any,
+    category: str,
+    confidence: Optional[float],
+    reasoning: Optional[str] = None,
+    summary: Optional[str] = None,
+    importance_score: Optional[int] = None,
+    importance_justification: Optional[str] = None,
+    inherited_from: Optional[str] = None,
+    alternatives: Optional[List[str]] = None,
+    needs_review: Optional[bool] = None,
+    model: Optional[str] = None
+) -> None:
+    """Store an email and its classification, replacing any earlier result.
+
+    Inserts the full row (sanitized content, raw HTML, preview, recipients,
+    attachments, folder, conversation, protection) or, for a stored email,
+    replaces the classification and fills in the email columns it is
+    missing. The row records the current classifier prompt version and
+    deployment so later runs can skip it. The caller commits.
+
+    Args:
+        conn: Open database connection
+        email: Provider email dict (id, subject, sender, body, ...)
+        user_id: Owner of the stored email
+        category: New category
+        confidence: Classifier confidence
+        reasoning: Why the classifier chose the category
+        summary: One-line summary (an existing one is kept when None)
+        importance_score: Predicted importance from 1 to 5
+        importance_justification: Why the email got its importance
+        inherited_from: Thread message the classification was copied from
+        alternatives: The classifier's runner-up categories, best first
+        needs_review: Whether the classification needs a human look (the
+            stored flag is kept when None)
+        model: Deployment that classified the email (defaults to
+            settings.azure_openai_deployment)
+    """
+    # Emails fetched with include_raw carry the unsanitized body
+    body = email.get("raw_body") or email.get("body") or email.get("content")
+    is_protected = bool(email.get("is_protected")) or is_protected_item(
+        email.get("message_class"), email.get("permission"), email.get("attachments")
+    )
+    review_update = "needs_review = excluded.needs_review," if needs_review is not None else ""
+    conn.execute(
+        f"""
+        INSERT INTO emails (id, subject, sender, recipient, to_recipients, cc_recipients,
+                            recipient_count, attachments, attachment_count, attachment_bytes,
+                            content, raw_content, preview, received_date,
+                            folder, conversation_id, category, confidence, needs_review, ai_reasoning,
+                            one_line_summary, importance_score, importance_justification,
+                            inherited_from, processed_at, ai_processed_at,
+                            ai_prompt_version, ai_model, ai_category, ai_confidence,
+                            alternative_categories, is_protected, user_id)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+                CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO UPDATE SET
+            preview = COALESCE(preview, excluded.preview),
+            to_recipients = COALESCE(to_recipients, excluded.to_recipients),
+            cc_recipients = COALESCE(cc_recipients, excluded.cc_recipients),
+            recipient_count = COALESCE(recipient_count, excluded.recipient_count),
+            attachment_count = CASE WHEN attachments IS NULL
+                THEN excluded.attachment_count ELSE attachment_count END,
+            attachment_bytes = CASE WHEN attachments IS NULL
+                THEN excluded.attachment_bytes ELSE attachment_bytes END,
+            attachments = COALESCE(attachments, excluded.attachments),
+            content = COALESCE(content, excluded.content),
+            raw_content = COALESCE(raw_content, excluded.raw_content),
+            category = excluded.category,
+            confidence = excluded.confidence,
+            {review_update}
+            ai_reasoning = excluded.ai_reasoning,
+            one_line_summary = COALESCE(excluded.one_line_summary, one_line_summary),
+            importance_score = excluded.importance_score,
+            importance_justification = excluded.importance_justification,
+            inherited_from = excluded.inherited_from,
+            conversation_id = CASE
+                WHEN original_conversation_id IS NOT NULL THEN conversation_id
+                ELSE COALESCE(excluded.conversation_id, conversation_id)
+            END,
+            processed_at = CURRENT_TIMESTAMP,
+            ai_processed_at = CURRENT_TIMESTAMP,
+            ai_prompt_version = excluded.ai_prompt_version,
+            ai_model = excluded.ai_model,
+            ai_category = excluded.ai_category,
+            ai_confidence = excluded.ai_confidence,
+            alternative_categories = excluded.alternative_categories,
+            is_protected = MAX(is_protected, excluded.is_protected),
+            version = version + 1
+        """,
+        (
+            email["id"],
+            email.get("subject") or "",
+            email.get("sender") or "",
+            email.get("recipient"),
+            *recipient_columns(email),
+            *attachment_columns(email),
+            sanitize_body(body),
+            body if looks_like_html(body) else None,
+            email_preview(strip_boilerplate(body, sender_blocks(conn, user_id, email.get("sender")))),
+            email.get("received_time") or email.get("received_date") or email.get("received_at"),
+            email.get("folder") or "Inbox",
+            email.get("conversation_id"),
+            category,
+            confidence,
+            int(bool(needs_review)),
+            reasoning,
+            summary,
+            importance_score,
+            importance_justification,
+            inherited_from,
+            prompt_version(),
+            model or settings.azure_openai_deployment,
+            category,
+            confidence,
+            encode_alternatives(alternatives or []),
+            int(is_protected),
+            user_id,
+        )
+    )
+
+
+class ClassificationStore:
+    """Reads and replaces stored email classifications."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the classification store.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def save_classification(
+        self,
+        email: Dict[str, Any],
+        user_id: str,
+        category: str,
+        confidence: Optional[float],
+        needs_review: bool,
+        model: Optional[str] = None,
+        alternatives: Optional[List[str]] = None,
+        reasoning: Optional[str] = None,
+        importance_score: Optional[int] = None,
+        importance_justification: Optional[str] = None
+    ) -> Optional[Dict[str, Any]]:
+        """Store an email's classification, returning the one it replaced.
+
+        Args:
+            email: Provider email dict with at least an id
+            user_id: Owner of the stored email
+            category: New category
+            confidence: Classifier confidence
+            needs_review: Whether the classification needs a human look
+            model: Deployment that classified the email (defaults to
+                settings.azure_openai_deployment)
+            alternatives: The classifier's runner-up categories, best first
+            reasoning: Why the classifier chose the category
+            importance_score: Predicted importance from 1 to 5
+            importance_justification: Why the email got its importance
+
+        Returns:
+            The previous category and needs_review flag, or None if the
+            email had no stored classification
+        """
+        loop = asyncio.get_event_loop()
+
+        def _save_classification_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    "SELECT category, needs_review FROM emails WHERE id = ?",
+                    (email["id"],)
+                ).fetchone()
+                upsert_classified_email(
+                    conn,
+                    email,
+                    user_id,
+                    category,
+                    confidence,
+                    reasoning=reasoning,
+                    importance_score=importance_score,
+                    importance_justification=importance_justification,
+                    alternatives=alternatives,
+                    needs_review=needs_review,
+                    model=model
+                )
+                conn.commit()
+            if row is None or row["category"] is None:
+                return None
+            return {"category": row["category"], "needs_review": bool(row["needs_review"])}
+
+        return await loop.run_in_executor(None, _save_classification_sync)
+
+    async def get_classification(self, email_id: str) -> Optional[Dict[str, Any]]:
+        """An email's stored category and needs_review flag, or None if it is unclassified."""
+        loop = asyncio.get_event_loop()
+
+        def _get_classification_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    "SELECT category, needs_review FROM emails WHERE id = ?",
+                    (email_id,)
+                ).fetchone()
+            if row is None or row["category"] is None:
+                return None
+            return {"category": row["category"], "needs_review": bool(row["needs_review"])}
+
+        return await loop.run_in_executor(None, _get_classification_sync)
+
+    async def get_processed_ids(
+        self,
+        email_ids: List[str],
+        user_id: str,
+        version: Optional[str] = None
+    ) -> Set[str]:
+        """Find which emails were already classified with a prompt version.
+
+        Args:
+            email_ids: Emails to check
+            user_id: Owner of the stored emails
+            version: Prompt version to match (defaults to the current one)
+
+        Returns:
+            The IDs of emails classified with that version
+        """
+        if not email_ids:
+            return set()
+        loop = asyncio.get_event_loop()
+        placeholders = ", ".join("?" for _ in email_ids)
+
+        def _get_processed_ids_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id FROM emails
+                    WHERE id IN ({placeholders}) AND user_id = ? AND ai_prompt_version = ?
+                    """,
+                    [*email_ids, user_id, version or prompt_version()]
+                ).fetchall()
+            return {row["id"] for row in rows}
+
+        return await loop.run_in_executor(None, _get_processed_ids_sync)
+
+    async def get_stored_folders(self, email_ids: List[str], user_id: str) -> Dict[str, str]:
+        """Find the folder each stored email is in.
+
+        Args:
+            email_ids: Emails to look up
+            user_id: Owner of the stored emails
+
+        Returns:
+            Folder by email ID, for the emails that are stored
+        """
+        if not email_ids:
+            return {}
+        loop = asyncio.get_event_loop()
+        placeholders = ", ".join("?" for _ in email_ids)
+
+        def _get_stored_folders_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id, COALESCE(folder, 'Inbox') AS folder FROM emails
+                    WHERE id IN ({placeholders}) AND user_id = ?
+                    """,
+                    [*email_ids, user_id]
+                ).fetchall()
+            return {row["id"]: row["folder"] for row in rows}
+
+        return await loop.run_in_executor(None, _get_stored_folders_sync)
+
+    async def find_email_ids(
+        self,
+        user_id: str,
+        folder: str,
+        unread_only: bool = False,
+        since_days: Optional[int] = None,
+        categories: Optional[List[str]] = None,
+        limit: int = 100
+    ) -> Tuple[List[str], int]:
+        """Find stored emails matching processing criteria, newest first.
+
+        Args:
+            user_id: Owner of the stored emails
+            folder: Folder the emails are in
+            unread_only: Only unread emails
+            since_days: Only emails received in the last this many days
+            categories: Only emails in these categories; UNCLASSIFIED matches
+                emails without one
+            limit: Maximum IDs to return
+
+        Returns:
+            The IDs of up to limit matching emails, and how many matched
+        """
+        loop = asyncio.get_event_loop()
+        clause, params = build_filter_clause(validate_email_filters({
+            "folder": folder,
+            "unread": True if unread_only else None,
+            "received_after": f"{since_days}d" if since_days else None,
+        }))
+        if categories:
+            named = [category for category in categories if category != UNCLASSIFIED]
+            matches = [f"category IN ({', '.join('?' for _ in named)})"] if named else []
+            if UNCLASSIFIED in categories:
+                matches.append("category IS NULL")
+            clause += f" AND ({' OR '.join(matches)})"
+            params += named
+
+        def _find_email_ids_sync():
+            with self.db.get_connection() as conn:
+                matched = conn.execute(
+                    f"SELECT COUNT(*) FROM emails WHERE user_id = ?{clause}", [user_id, *params]
+                ).fetchone()[0]
+                rows = conn.execute(
+                    f"SELECT id FROM emails WHERE user_id = ?{clause} ORDER BY {EMAIL_SORTS[DEFAULT_SORT]} LIMIT ?",
+                    [user_id, *params, limit]
+                ).fetchall()
+            return [row["id"] for row in rows], matched
+
+        return await loop.run_in_executor(None, _find_email_ids_sync)
+
+    async def tag_muted(
+        self,
+        email_ids: List[str],
+        user_id: str,
+        category: str,
+        dry_run: bool = False
+    ) -> Set[str]:
+        """Classify stored emails of muted conversations without the AI.
+
+        A conversation merged into a muted one is muted too (see
+        EmailService.mute_conversation). The AI columns are left alone, so
+        the tag does not count as a classification by the current prompt.
+
+        Args:
+            email_ids: Emails to check
+            user_id: Owner of the stored emails
+            category: Category muted emails are given
+            dry_run: Only find the muted emails, without tagging them
+
+        Returns:
+            IDs of the emails that were in muted conversations
+        """
+        if not email_ids:
+            return set()
+        loop = asyncio.get_event_loop()
+        placeholders = ", ".join("?" for _ in email_ids)
+
+        def _tag_muted_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id FROM emails
+                    WHERE id IN ({placeholders}) AND user_id = ?
+                      AND EXISTS (
+                          SELECT 1 FROM muted_conversations AS muted
+                          WHERE muted.user_id = emails.user_id
+                            AND muted.conversation_id IN (emails.conversation_id, emails.original_conversation_id)
+                      )
+                    """,
+                    [*email_ids, user_id]
+                ).fetchall()
+                muted = [row["id"] for row in rows]
+                if dry_run:
+                    return set(muted)
+                conn.executemany(
+                    """
+                    UPDATE emails
+                    SET category = ?, confidence = 1.0, needs_review = 0,
+                        processed_at = CURRENT_TIMESTAMP, version = version + 1
+                    WHERE id = ?
+                    """,
+                    [(category, email_id) for email_id in muted]
+                )
+                conn.commit()
+            return set(muted)
+
+        return await loop.run_in_executor(None, _tag_muted_sync)
+
+    async def get_calibration_report(
+        self,
+        user_id: Any,
+        days: int = 30,
+        target_precision: float = 0.9,
+        now: Optional[datetime] = None
+    ) -> CalibrationReport:
+        """Compare classifier confidence with how often users corrected the category.
+
+        Covers emails the AI classified within the window; an email counts as
+        corrected when its category no longer matches the AI's.
+
+        Args:
+            user_id: Owner of the stored emails
+            days: Days of AI classifications to cover
+            target_precision: Share of kept predictions the suggested thresholds aim for
+            now: End of the window (defaults to now, UTC)
+        """
+        loop = asyncio.get_event_loop()
+        since = (now or datetime.utcnow()) - timedelta(days=days)
+
+        def _get_calibration_sync():
+            with self.db.get_connection() as conn:
+                return conn.execute(
+                    """
+                    SELECT ai_category AS category,
+                           MIN(CAST(ai_confidence * ? AS INTEGER), ? - 1) AS bucket,
+                           COUNT(*) AS predictions,
+                           SUM(category IS NOT ai_category) AS corrections,
+                           AVG(ai_confidence) AS mean_confidence
+                    FROM emails
+                    WHERE user_id = ? AND ai_processed_at >= ?
+                      AND ai_category IS NOT NULL AND ai_confidence IS NOT NULL
+                    GROUP BY ai_category, bucket
+                    """,
+                    (CALIBRATION_BUCKETS, CALIBRATION_BUCKETS, user_id, since)
+                ).fetchall()
+
+        rows = [dict(row) for row in await loop.run_in_executor(None, _get_calibration_sync)]
+        by_category: Dict[str, List[Dict[str, Any]]] = {}
+        for row in rows:
+            by_category.setdefault(row["category"], []).append(row)
+        categories = [
+            calibrate(category, category_rows, target_precision)
+            for category, category_rows in by_category.items()
+        ]
+        return CalibrationReport(
+            since=since,
+            days=days,
+            target_precision=target_precision,
+            overall=calibrate("all", rows, target_precision),
+            categories=sorted(categories, key=lambda item: (-item.predictions, item.category))
+        )
+
+
+def calibrate(category: str, rows: Iterable[Dict[str, Any]], target_precision: float) -> CategoryCalibration:
+    """Fold bucketed prediction counts into one category's calibration.
+
+    Args:
+        category: Category name for the result
+        rows: Dicts with bucket (0-9), predictions, corrections, and
+            mean_confidence; several rows may share a bucket
+        target_precision: Precision the suggested threshold must reach
+
+    Returns:
+        Every decile bucket (empty ones included) and the lowest bound of
+        a non-empty bucket at which the predictions at or above it have at
+        least the target precision, None if no bound does
+    """
+    counts = [[0, 0, 0.0] for _ in range(CALIBRATION_BUCKETS)]  # predictions, corrections, confidence sum
+    for row in rows:
+        bucket = max(0, min(int(row["bucket"]), CALIBRATION_BUCKETS - 1))
+        counts[bucket][0] += row["predictions"]
+        counts[bucket][1] += row["corrections"] or 0
+        counts[bucket][2] += (row["mean_confidence"] or 0.0) * row["predictions"]
+
+    buckets = [
+        CalibrationBucket(
+            min_confidence=round(index / CALIBRATION_BUCKETS, 2),
+            max_confidence=round((index + 1) / CALIBRATION_BUCKETS, 2),
+            predictions=predictions,
+            corrections=corrections,
+            correction_rate=round(corrections / predictions, 4) if predictions else None,
+            mean_confidence=round(confidence_sum / predictions, 4) if predictions else None
+        )
+        for index, (predictions, corrections, confidence_sum) in enumerate(counts)
+    ]
+    total = sum(bucket.predictions for bucket in buckets)
+    result = CategoryCalibration(
+        category=category,
+        predictions=total,
+        corrections=sum(bucket.corrections for bucket in buckets),
+        buckets=buckets
+    )
+
+    # Walk down from the most confident bucket, keeping the lowest non-empty bound that still meets the target
+    kept = corrected = 0
+    for bucket in reversed(buckets):
+        kept += bucket.predictions
+        corrected += bucket.corrections
+        if bucket.predictions and (kept - corrected) / kept >= target_precision:
+            result.suggested_threshold = bucket.min_confidence
+            result.threshold_precision = round((kept - corrected) / kept, 4)
+            result.threshold_coverage = round(kept / total, 4)
+    return result
+
+
+# Dependency for FastAPI
+def get_classification_store() -> ClassificationStore:
+    """FastAPI dependency for the classification store."""
+    return ClassificationStore()
@@ -19,19 +19,45 @@ import asyncio
 import os
 import sys
 import json
+from contextvars import copy_context
+from datetime import date
 from pathlib import Path
-from typing import Dict, Any, List, Optional
+from typing import Any, Callable, Dict, List, Optional
 
 # Add src to Python path for existing service imports
 sys.path.insert(0, str(Path(__file__).parent.parent.parent / "src"))
 
 try:
     from ai_processor import AIProcessor
-    from azure_config import get_azure_config
+    from azure_config import get_azure_config, is_auth_error
 except ImportError as e:
     print(f"Warning: Could not import AI dependencies: {e}")
     AIProcessor = None
     get_azure_config = None
+    is_auth_error = None
+
+from backend.services.action_items import parse_action_items
+from backend.services.ai_health import ai_health
+from backend.services.ai_queue import ai_request_queue
+from backend.services.ai_traces import make_trace_hook
+from backend.services.category_service import load_categories
+from backend.services.classification_explainer import (
+    EXPLAINER_TEMPLATE, build_explainer_inputs, parse_explanation
+)
+from backend.core.config import settings
+from backend.core.errors import AIAuthError
+from backend.core.prompts import prompt_library
+from backend.models.ai_models import DEFAULT_IMPORTANCE_SCORE, clamp_importance_score
+from backend.services.follow_up_detector import AWAITING_REPLY_TEMPLATE, parse_ai_verdict
+from backend.services.folder_suggestions import (
+    FOLDER_RANKER_TEMPLATE, build_ranking_inputs, parse_folder_ranking
+)
+from backend.services.reply_drafter import DRAFT_TEMPLATE, build_draft_inputs, parse_draft
+from backend.services.holistic_analysis import HOLISTIC_TEMPLATE, build_holistic_inputs, parse_holistic_analysis
+from backend.services.search_query import SEARCH_QUERY_TEMPLATE, build_search_query_inputs, parse_search_query
+from backend.services.summary_modes import THREAD_TEMPLATE, build_thread_inputs, split_summary, summary_template
+from backend.services.task_service import TASK_TITLE_TEMPLATE, parse_task_title
+from backend.services.triage import TRIAGE_TEMPLATE, build_triage_inputs, parse_triage, template_deployment
 
 
 class COMAIService:
@@ -76,11 +102,42 @@ class COMAIService:
             
             try:
                 self.ai_processor = AIProcessor()
+                self.ai_processor.prompt_resolver = prompt_library.path
+                self.ai_processor.parameter_resolver = prompt_library.parameters
+                self.ai_processor.deployment_resolver = template_deployment
+                self.ai_processor.add_trace_hook(make_trace_hook(self.ai_processor))
+                self.ai_processor.raise_auth_errors = True
                 self.azure_config = get_azure_config()
                 self._initialized = True
             except Exception as e:
                 raise RuntimeError(f"Failed to initialize AI components: {e}")
     
+    async def _run_in_executor(self, func: Callable[..., Any], *args, deployment: Optional[str] = None) -> Any:
+        """Run a blocking AI call in the thread pool with the caller's context.
+        
+        The context carries the AI trace scope (see backend.services.ai_traces)
+        to the thread where AIProcessor runs its trace hooks. The call first
+        waits for a slot on its deployment (see backend.services.ai_queue).
+        
+        Args:
+            func: Blocking function making the AI call
+            *args: Arguments for func
+            deployment: Deployment the call goes to (None for azure_openai_deployment)
+        
+        Raises:
+            AIAuthError: Azure could not get a token or rejected the credential
+        """
+        loop = asyncio.get_event_loop()
+        context = copy_context()
+        try:
+            async with ai_request_queue.slot(deployment):
+                return await loop.run_in_executor(None, lambda: context.run(func, *args))
+        except Exception as e:
+            if is_auth_error is None or not is_auth_error(e):
+                raise
+            await ai_health.record(False, str(e), auth_error=True)
+            raise AIAuthError() from e
+    
     async def classify_email(
         self, 
         email_content: str,
@@ -100,6 +157,8 @@ class COMAIService:
             - category (str): Primary classification category
             - confidence (float): Confidence score (0.0 to 1.0)
             - reasoning (str): Explanation of classification decision
+            - importance_score (int): Predicted importance, clamped to 1-5
+            - importance_justification (str): Why the email got that score
             - alternatives (List): Alternative category suggestions
             - requires_review (bool): Whether manual review is needed
             - error (str, optional): Error message if classification failed
@@ -112,17 +171,18 @@ class COMAIService:
         self._ensure_initialized()
         
         # Run CPU-bound AI processing in thread pool to avoid blocking event loop
-        loop = asyncio.get_event_loop()
-        
         try:
-            result = await loop.run_in_executor(
-                None,
+            result = await self._run_in_executor(
                 self._classify_email_sync,
                 email_content,
                 context or ""
             )
+            await ai_health.record(True)
             return result
+        except AIAuthError:
+            raise
         except Exception as e:
+            await ai_health.record(False, str(e))
             return {
                 "category": "work_relevant",
                 "confidence": 0.5,
@@ -146,7 +206,8 @@ class COMAIService:
             # Use the enhanced classification method with explanation
             result = self.ai_processor.classify_email_with_explanation(
                 email_content=email_content,
-                learning_data=[]  # Empty learning data for now
+                learning_data=[],  # Empty learning data for now
+                categories=load_categories()  # Includes user-defined categories
             )
             
             # Ensure result is in expected format
@@ -161,6 +222,8 @@ class COMAIService:
                     'category': category,
                     'confidence': confidence,
                     'reasoning': result.get('explanation', 'Email classified'),
+                    'importance_score': clamp_importance_score(result.get('importance_score')),
+                    'importance_justification': result.get('importance_justification'),
                     'alternatives': result.get('alternatives', []),
                     'requires_review': requires_review
                 }
@@ -171,6 +234,8 @@ class COMAIService:
                     'category': category,
                     'confidence': 0.8,
                     'reasoning': 'Email classified successfully',
+                    'importance_score': DEFAULT_IMPORTANCE_SCORE,
+                    'importance_justification': None,
                     'alternatives': [],
                     'requires_review': True  # Default to requiring review
                 }
@@ -196,6 +261,56 @@ class COMAIService:
         threshold = self.ai_processor.CONFIDENCE_THRESHOLDS.get(category, 1.0)
         return confidence < threshold
     
+    async def triage_email_async(self, subject: str, content: str, sender: str) -> Dict[str, Any]:
+        """Quick first-pass classification for two-stage batch classification.
+        
+        Uses the email_triage.prompty template on settings.triage_deployment,
+        sending only the headers and the opening lines of the body.
+        
+        Args:
+            subject: Email subject line
+            content: Email body content
+            sender: Email sender address
+            
+        Returns:
+            Dictionary with the triage:
+            - category (str): One of the configured category names
+            - confidence (float): Confidence score 0.0-1.0
+            - error (str, optional): Error message if the triage failed
+        """
+        self._ensure_initialized()
+        
+        names = [category["name"] for category in load_categories()]
+        inputs = build_triage_inputs(subject, sender, content, names)
+        
+        try:
+            return await self._run_in_executor(
+                self._triage_email_sync,
+                inputs,
+                names,
+                deployment=template_deployment(TRIAGE_TEMPLATE)
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _triage_email_sync(self, inputs: Dict[str, Any], categories: List[str]) -> Dict[str, Any]:
+        """Synchronous email triage for thread pool execution.
+        
+        Args:
+            inputs: Prompty inputs
+            categories: Category names the triage may return
+            
+        Returns:
+            Triage result dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            TRIAGE_TEMPLATE,
+            inputs=inputs
+        )
+        return parse_triage(result, categories)
+    
     async def extract_action_items(
         self,
         email_content: str,
@@ -212,7 +327,8 @@ class COMAIService:
             
         Returns:
             Dictionary with action item details:
-            - action_items (List): List of extracted action items
+            - action_items (List): Items with description, owner, deadline,
+              source_quote, and links
             - action_required (str): Primary action description
             - due_date (str): Action deadline if available
             - explanation (str): Why this is an action item
@@ -228,16 +344,15 @@ class COMAIService:
         """
         self._ensure_initialized()
         
-        loop = asyncio.get_event_loop()
-        
         try:
-            result = await loop.run_in_executor(
-                None,
+            result = await self._run_in_executor(
                 self._extract_action_items_sync,
                 email_content,
                 context or ""
             )
             return result
+        except AIAuthError:
+            raise
         except Exception as e:
             return {
                 "action_items": [],
@@ -301,7 +416,7 @@ class COMAIService:
             
             # Format response with all expected fields
             return {
-                "action_items": [result] if result.get("action_required") else [],
+                "action_items": parse_action_items(result),
                 "action_required": result.get("action_required", "No action required"),
                 "due_date": result.get("due_date", ""),
                 "explanation": result.get("explanation", ""),
@@ -320,11 +435,13 @@ class COMAIService:
     ) -> Dict[str, Any]:
         """Generate a summary of email content.
         
-        Uses the email_one_line_summary.prompty template for brief summaries.
+        Uses the email_one_line_summary.prompty template for brief and
+        detailed summaries and email_action_summary.prompty for
+        action_focused ones.
         
         Args:
             email_content: Full email text
-            summary_type: Type of summary ("brief", "detailed", etc.)
+            summary_type: Type of summary ("brief", "detailed", or "action_focused")
             
         Returns:
             Dictionary with summary details:
@@ -340,16 +457,15 @@ class COMAIService:
         """
         self._ensure_initialized()
         
-        loop = asyncio.get_event_loop()
-        
         try:
-            result = await loop.run_in_executor(
-                None,
+            result = await self._run_in_executor(
                 self._generate_summary_sync,
                 email_content,
                 summary_type
             )
             return result
+        except AIAuthError:
+            raise
         except Exception as e:
             return {
                 "summary": f"Unable to generate summary: {str(e)}",
@@ -374,9 +490,8 @@ class COMAIService:
                 "email_content": email_content
             }
             
-            # Execute the email_one_line_summary prompty for brief summaries
             result = self.ai_processor.execute_prompty(
-                "email_one_line_summary.prompty",
+                summary_template(summary_type),
                 inputs=inputs
             )
             
@@ -388,7 +503,9 @@ class COMAIService:
             
             # Extract key points from summary
             key_points = []
-            if summary_text:
+            if summary_type == "action_focused":
+                summary_text, key_points = split_summary(summary_text)
+            elif summary_text:
                 # Simple key point extraction (split by sentence)
                 sentences = [s.strip() for s in summary_text.split('.') if s.strip()]
                 key_points = sentences[:3]  # Take up to 3 key points
@@ -425,15 +542,14 @@ class COMAIService:
         """
         self._ensure_initialized()
         
-        loop = asyncio.get_event_loop()
-        
         try:
-            result = await loop.run_in_executor(
-                None,
+            result = await self._run_in_executor(
                 self._detect_duplicates_sync,
                 emails
             )
             return result
+        except AIAuthError:
+            raise
         except Exception as e:
             print(f"Error detecting duplicates: {e}")
             return []  # Return empty list on error
@@ -490,53 +606,484 @@ class COMAIService:
             print(f"Error in duplicate detection: {e}")
             return []
     
-    async def get_available_templates(self) -> Dict[str, Any]:
-        """Get list of available prompty templates.
+    async def summarize_thread(
+        self,
+        emails: List[Dict[str, Any]],
+        max_tokens: int
+    ) -> Dict[str, Any]:
+        """Digest a whole conversation thread, oldest message first.
         
-        Returns:
-            Dictionary with template information:
-            - templates (List[str]): List of template filenames
-            - descriptions (Dict[str, str]): Template descriptions
+        Uses the email_thread_summary.prompty template. Messages are fitted
+        into the token budget by summary_modes.build_thread_inputs.
         
-        Example:
-            >>> result = await service.get_available_templates()
-            >>> print(result['templates'])
-            ['email_classifier.prompty', 'action_item.prompty', ...]
+        Args:
+            emails: The conversation's emails
+            max_tokens: Token budget for the thread's messages
+            
+        Returns:
+            Dictionary with thread summary details:
+            - summary (str): Where the conversation stands
+            - key_points (List[str]): Developments in order
+            - confidence (float): Confidence score
+            - message_count (int): Messages in the thread
+            - messages_omitted (int): Messages left out to fit the budget
+            - error (str, optional): Error message if generation failed
         """
-        # Get prompts directory from ai_processor
-        prompts_dir = Path(__file__).parent.parent.parent / "prompts"
+        self._ensure_initialized()
+        
+        inputs, omitted = build_thread_inputs(
+            emails, max_tokens, username=settings.user_name or self.ai_processor.get_username()
+        )
         
-        if not prompts_dir.exists():
+        try:
+            result = await self._run_in_executor(self._summarize_thread_sync, inputs)
+        except AIAuthError:
+            raise
+        except Exception as e:
             return {
-                "templates": [],
-                "descriptions": {}
+                "summary": f"Unable to generate summary: {str(e)}",
+                "key_points": [],
+                "confidence": 0.0,
+                "error": str(e)
             }
+        return {**result, "message_count": len(emails), "messages_omitted": omitted}
+    
+    def _summarize_thread_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous thread summary for thread pool execution.
         
-        # List all .prompty files
-        templates = []
-        descriptions = {}
+        Args:
+            inputs: Prompty inputs from build_thread_inputs
+            
+        Returns:
+            Summary result dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            THREAD_TEMPLATE,
+            inputs=inputs
+        )
+        summary_text, key_points = split_summary(result.strip() if isinstance(result, str) else str(result or ""))
+        return {
+            "summary": summary_text or "Unable to generate summary",
+            "key_points": key_points,
+            "confidence": 0.8 if summary_text else 0.5
+        }
+    
+    async def explain_classification(
+        self,
+        subject: str,
+        sender: str,
+        content: str,
+        current_category: str,
+        current_reasoning: Optional[str],
+        alternative_category: str,
+        received_date: Optional[str] = None,
+        categories: Optional[List[str]] = None
+    ) -> Dict[str, Any]:
+        """Compare an email's current category against an alternative.
         
-        for prompty_file in prompts_dir.glob("*.prompty"):
-            templates.append(prompty_file.name)
+        Uses the classification_explainer.prompty template to list the evidence
+        for each category and pick a verdict.
+        
+        Args:
+            subject: Email subject line
+            sender: Email sender
+            content: Email body content
+            current_category: Category the email was classified as
+            current_reasoning: Stored reasoning for the current category
+            alternative_category: Category the user is asking about
+            received_date: When the email was received
+            categories: Valid category names (defaults to the built-in ones)
             
-            # Try to extract description from file
-            try:
-                with open(prompty_file, 'r', encoding='utf-8') as f:
-                    content = f.read()
-                    # Look for description in YAML frontmatter
-                    if content.startswith('---'):
-                        parts = content.split('---', 2)
-                        if len(parts) >= 2:
-                            import re
-                            desc_match = re.search(r'description:\s*(.+)', parts[1])
-                            if desc_match:
-                                descriptions[prompty_file.name] = desc_match.group(1).strip()
-            except Exception:
-                pass  # Skip description if parsing fails
+        Returns:
+            Dictionary with explanation details:
+            - supports_current (List[str]): Evidence for the current category
+            - supports_alternative (List[str]): Evidence for the alternative
+            - verdict (str): keep_current, prefer_alternative, or ambiguous
+            - error (str, optional): Error message if explanation failed
         
+        Example:
+            >>> result = await service.explain_classification(
+            ...     "Lunch?", "bob@example.com", "Want to grab lunch?",
+            ...     "fyi", "Social note", "optional_event"
+            ... )
+            >>> print(result['verdict'])
+            'prefer_alternative'
+        """
+        self._ensure_initialized()
+        
+        inputs = build_explainer_inputs(
+            subject=subject,
+            sender=sender,
+            content=content,
+            current_category=current_category,
+            current_reasoning=current_reasoning,
+            alternative_category=alternative_category,
+            received_date=received_date,
+            categories=categories
+        )
+        
+        try:
+            return await self._run_in_executor(
+                self._explain_classification_sync,
+                inputs
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _explain_classification_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous classification explanation for thread pool execution.
+        
+        Args:
+            inputs: Prompty inputs from build_explainer_inputs
+            
+        Returns:
+            Parsed explanation dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            EXPLAINER_TEMPLATE,
+            inputs=inputs
+        )
+        return parse_explanation(result)
+    
+    async def check_awaiting_reply(
+        self,
+        subject: str,
+        sender: str,
+        content: str,
+        received_date: Optional[str] = None
+    ) -> Dict[str, Any]:
+        """Ask the AI whether an email is waiting for the user's reply.
+        
+        Uses the awaiting_reply_detector.prompty template.
+        
+        Args:
+            subject: Email subject line
+            sender: Email sender
+            content: New text of the message, without quoted history
+            received_date: When the email was received
+            
+        Returns:
+            Dictionary with check details:
+            - awaiting_reply (bool): True if the user owes a reply
+            - error (str, optional): Error message if the check failed
+        """
+        self._ensure_initialized()
+        
+        inputs = {
+            "context": "",
+            "username": "User",
+            "subject": subject,
+            "sender": sender,
+            "date": received_date or "Unknown",
+            "body": content
+        }
+        
+        try:
+            return await self._run_in_executor(
+                self._check_awaiting_reply_sync,
+                inputs
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _check_awaiting_reply_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous awaiting-reply check for thread pool execution.
+        
+        Args:
+            inputs: Prompty inputs
+            
+        Returns:
+            Check result dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            AWAITING_REPLY_TEMPLATE,
+            inputs=inputs
+        )
+        return {"awaiting_reply": parse_ai_verdict(result)}
+    
+    async def rank_folders(self, subject: str, sender: str, folders: List[str]) -> Dict[str, Any]:
+        """Ask the AI which existing folders an email belongs in.
+        
+        Uses the folder_ranker.prompty template.
+        
+        Args:
+            subject: Email subject line
+            sender: Email sender
+            folders: Candidate folder names
+            
+        Returns:
+            Dictionary with ranking details:
+            - folders (List[Tuple[str, float]]): (folder, confidence) pairs, best first
+            - error (str, optional): Error message if ranking failed
+        """
+        self._ensure_initialized()
+        
+        inputs = build_ranking_inputs(subject, sender, folders)
+        
+        try:
+            return await self._run_in_executor(
+                self._rank_folders_sync,
+                inputs,
+                folders
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _rank_folders_sync(self, inputs: Dict[str, Any], folders: List[str]) -> Dict[str, Any]:
+        """Synchronous folder ranking for thread pool execution.
+        
+        Args:
+            inputs: Prompty inputs from build_ranking_inputs
+            folders: Candidate folder names the output is checked against
+            
+        Returns:
+            Ranking result dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            FOLDER_RANKER_TEMPLATE,
+            inputs=inputs
+        )
+        return {"folders": parse_folder_ranking(result, folders)}
+    
+    async def generate_search_query(self, request: str, categories: List[str], today: date) -> Dict[str, Any]:
+        """Ask the AI for search filters matching a natural language request.
+        
+        Uses the search_query.prompty template.
+        
+        Args:
+            request: What the user is looking for
+            categories: Category names the category filter may take
+            today: The user's current date, for relative dates
+            
+        Returns:
+            Dictionary with the generated query:
+            - filters (Dict[str, Any]): Filters as returned by the model,
+              unchecked (see search_query.validate_search_filters)
+            - error (str, optional): Error message if generation failed
+        """
+        self._ensure_initialized()
+        
+        inputs = build_search_query_inputs(request, categories, today)
+        
+        try:
+            return await self._run_in_executor(
+                self._generate_search_query_sync,
+                inputs
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _generate_search_query_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous search query generation for thread pool execution.
+        
+        Args:
+            inputs: Prompty inputs from build_search_query_inputs
+            
+        Returns:
+            Generation result dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            SEARCH_QUERY_TEMPLATE,
+            inputs=inputs
+        )
+        return {"filters": parse_search_query(result)}
+    
+    async def rewrite_task_title(self, item: str) -> Dict[str, Any]:
+        """Ask the AI for an imperative task title for an action item.
+        
+        Uses the task_title.prompty template.
+        
+        Args:
+            item: Action item text, already cleaned by clean_task_title
+            
+        Returns:
+            Dictionary with the rewrite:
+            - title (str): Task title, cleaned again by clean_task_title
+            - error (str, optional): Error message if the rewrite failed
+        """
+        self._ensure_initialized()
+        
+        try:
+            return await self._run_in_executor(
+                self._rewrite_task_title_sync,
+                {"item": item}
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _rewrite_task_title_sync(self, inputs: Dict[str, Any]) -> Dict[str, Any]:
+        """Synchronous task title rewrite for thread pool execution.
+        
+        Args:
+            inputs: Prompty inputs
+            
+        Returns:
+            Rewrite result dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            TASK_TITLE_TEMPLATE,
+            inputs=inputs
+        )
+        return {"title": parse_task_title(result)}
+    
+    async def draft_reply(
+        self,
+        subject: str,
+        sender: str,
+        content: str,
+        tone: str = "brief",
+        bullet_points: Optional[List[str]] = None,
+        received_date: Optional[str] = None
+    ) -> Dict[str, Any]:
+        """Draft a reply to an email in the user's voice.
+        
+        Uses the draft_reply.prompty template with the user's name and job
+        context from settings (falling back to the user_specific_data files).
+        The draft is only returned; it is never sent.
+        
+        Args:
+            subject: Email subject line
+            sender: Email sender
+            content: Email body content
+            tone: brief, formal, or friendly
+            bullet_points: Points the reply must make
+            received_date: When the email was received
+            
+        Returns:
+            Dictionary with draft details:
+            - subject (str): Reply subject
+            - body (str): Reply body
+            - error (str, optional): Error message if drafting failed
+        """
+        self._ensure_initialized()
+        
+        inputs = build_draft_inputs(
+            subject=subject,
+            sender=sender,
+            content=content,
+            tone=tone,
+            bullet_points=bullet_points,
+            username=settings.user_name or self.ai_processor.get_username(),
+            job_context=settings.job_context or self.ai_processor.get_job_context(),
+            received_date=received_date
+        )
+        
+        try:
+            return await self._run_in_executor(
+                self._draft_reply_sync,
+                inputs,
+                subject
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _draft_reply_sync(self, inputs: Dict[str, Any], subject: str) -> Dict[str, Any]:
+        """Synchronous reply drafting for thread pool execution.
+        
+        Args:
+            inputs: Prompty inputs from build_draft_inputs
+            subject: Original subject, used when the model omits one
+            
+        Returns:
+            Parsed draft dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            DRAFT_TEMPLATE,
+            inputs=inputs
+        )
+        return parse_draft(result, subject)
+    
+    async def analyze_inbox(
+        self,
+        emails: List[Dict[str, Any]],
+        today: date,
+        corrections: Optional[List[str]] = None
+    ) -> Dict[str, Any]:
+        """Analyze a set of inbox emails together.
+        
+        Uses the holistic_inbox_analyzer.prompty template with the user's
+        name and job context from settings (falling back to the
+        user_specific_data files).
+        
+        Args:
+            emails: Emails with id, subject, sender, received_date, and content
+            today: The user's current date, for deadlines
+            corrections: Dismissed blocking items of earlier runs (see
+                blocking_items.BlockingStore.corrections)
+            
+        Returns:
+            Dictionary with the analysis:
+            - truly_relevant_actions, superseded_actions, duplicate_groups,
+              expired_items (List[Dict]): See parse_holistic_analysis
+            - error (str, optional): Error message if the analysis failed
+        """
+        self._ensure_initialized()
+        
+        inputs = build_holistic_inputs(
+            emails,
+            username=settings.user_name or self.ai_processor.get_username(),
+            job_context=settings.job_context or self.ai_processor.get_job_context(),
+            today=today,
+            corrections=corrections or []
+        )
+        
+        try:
+            return await self._run_in_executor(
+                self._analyze_inbox_sync,
+                inputs,
+                [str(email["id"]) for email in emails]
+            )
+        except AIAuthError:
+            raise
+        except Exception as e:
+            return {"error": str(e)}
+    
+    def _analyze_inbox_sync(self, inputs: Dict[str, Any], email_ids: List[str]) -> Dict[str, Any]:
+        """Synchronous holistic analysis for thread pool execution.
+        
+        Args:
+            inputs: Prompty inputs from build_holistic_inputs
+            email_ids: IDs of the analyzed emails
+            
+        Returns:
+            Parsed analysis dictionary
+        """
+        result = self.ai_processor.execute_prompty(
+            HOLISTIC_TEMPLATE,
+            inputs=inputs
+        )
+        return parse_holistic_analysis(result, email_ids)
+    
+    async def get_available_templates(self) -> Dict[str, Any]:
+        """Get list of available prompt templates.
+        
+        Returns:
+            Dict containing the active template names, their descriptions,
+            the layer (bundled or user) each was loaded from, the model
+            parameters each runs with, and why any user templates were
+            skipped (see backend.core.prompts)
+        """
+        templates = prompt_library.templates()
         return {
-            "templates": sorted(templates),
-            "descriptions": descriptions
+            "templates": list(templates),
+            "descriptions": {
+                name: template.description or "No description available" for name, template in templates.items()
+            },
+            "layers": {name: template.layer for name, template in templates.items()},
+            "parameters": {name: prompt_library.parameters(name) for name in templates},
+            "errors": prompt_library.errors()
         }
 
 
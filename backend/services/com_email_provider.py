@@ -18,6 +18,7 @@ Thread Safety:
 
 import sys
 import logging
+import time
 from pathlib import Path
 from typing import List, Dict, Any, Optional
 
@@ -26,6 +27,8 @@ from fastapi import HTTPException
 # Add src to Python path for adapter imports
 sys.path.insert(0, str(Path(__file__).parent.parent.parent / "src"))
 
+from backend.core.config import OUTLOOK_CATEGORY_COLORS, settings
+from backend.core.protection import mark_protection
 from backend.services.email_provider import EmailProvider
 
 # Import OutlookEmailAdapter - only available on Windows
@@ -37,6 +40,13 @@ except ImportError:
     OutlookEmailAdapter = None
 
 
+def _color_name(value: int) -> str:
+    """Name an OlCategoryColor value, or "none" if it is out of range."""
+    if 0 <= value < len(OUTLOOK_CATEGORY_COLORS):
+        return OUTLOOK_CATEGORY_COLORS[value]
+    return "none"
+
+
 class COMEmailProvider(EmailProvider):
     """COM-based EmailProvider implementation wrapping OutlookEmailAdapter.
     
@@ -80,6 +90,10 @@ class COMEmailProvider(EmailProvider):
         self.adapter = OutlookEmailAdapter()
         self.authenticated = False
         self.logger = logging.getLogger(__name__)
+        # (monotonic time listed, folders) from the last folder walk
+        self._folder_cache: Optional[tuple] = None
+        # (requested colors, effective colors) from the last category check
+        self._category_colors: Optional[tuple] = None
     
     def authenticate(self, credentials: Dict[str, str]) -> bool:
         """Authenticate with Outlook COM interface.
@@ -100,7 +114,10 @@ class COMEmailProvider(EmailProvider):
         try:
             self.logger.info("Attempting to connect to Outlook COM interface")
             
-            success = self.adapter.connect()
+            if settings.outlook_store_name:
+                success = self.adapter.connect(store_name=settings.outlook_store_name)
+            else:
+                success = self.adapter.connect()
             
             if success:
                 self.authenticated = True
@@ -114,6 +131,10 @@ class COMEmailProvider(EmailProvider):
                     detail="Could not connect to Outlook. Ensure Outlook is running."
                 )
                 
+        except LookupError as e:
+            self.authenticated = False
+            self.logger.error(f"Outlook store unavailable: {e}")
+            raise HTTPException(status_code=404, detail=str(e))
         except Exception as e:
             self.authenticated = False
             self.logger.error(f"Outlook connection error: {e}")
@@ -122,6 +143,78 @@ class COMEmailProvider(EmailProvider):
                 detail=f"Outlook connection failed: {str(e)}"
             )
     
+    def list_stores(self) -> List[Dict[str, Any]]:
+        """List the stores (mailboxes and data files) in the Outlook profile.
+        
+        Returns:
+            Store dictionaries with name, id, is_default, and selected
+        
+        Raises:
+            HTTPException: If not authenticated or listing fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        try:
+            return self.adapter.list_stores()
+            
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error listing Outlook stores: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to list Outlook stores: {str(e)}"
+            )
+    
+    def select_store(self, name: str) -> Dict[str, Any]:
+        """Switch folder and item operations to another Outlook store.
+        
+        The folder and category caches belong to the previous store and are
+        dropped, so the next listing re-reads the selected one.
+        
+        Args:
+            name: Display name of the store
+        
+        Returns:
+            The selected store's dictionary
+        
+        Raises:
+            HTTPException: 404 if the profile has no such store, or if not
+                authenticated or switching fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        try:
+            store = self.adapter.select_store(name)
+            
+        except LookupError as e:
+            raise HTTPException(status_code=404, detail=str(e))
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error selecting Outlook store {name}: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to select Outlook store {name}: {str(e)}"
+            )
+        
+        self.invalidate_folder_cache()
+        self._category_colors = None
+        self.logger.info(f"Selected Outlook store {store['name']}")
+        return store
+    
     def get_emails(
         self, 
         folder_name: str = "Inbox", 
@@ -141,11 +234,15 @@ class COMEmailProvider(EmailProvider):
             - subject: Email subject line
             - sender: Sender email address
             - recipient: Primary recipient address
+            - to: To recipient addresses
+            - cc: Cc recipient addresses
+            - recipient_count: Number of To and Cc recipients
             - body: Email body text preview
             - received_time: ISO format timestamp
             - is_read: Read status boolean
             - categories: List of assigned categories
             - conversation_id: Thread identifier
+            - is_protected: Whether the email is rights-managed (see core.protection)
         
         Raises:
             HTTPException: If not authenticated or retrieval fails
@@ -168,7 +265,7 @@ class COMEmailProvider(EmailProvider):
             )
             
             self.logger.info(f"Retrieved {len(emails)} emails from {folder_name}")
-            return emails
+            return [mark_protection(email) for email in emails]
             
         except RuntimeError as e:
             # Adapter raises RuntimeError when not connected
@@ -182,10 +279,59 @@ class COMEmailProvider(EmailProvider):
                 detail=f"Failed to retrieve emails: {str(e)}"
             )
     
+    def get_mailbox_emails(
+        self,
+        mailbox: str,
+        folder_name: str = "Inbox",
+        count: int = 50,
+        offset: int = 0
+    ) -> List[Dict[str, Any]]:
+        """Retrieve emails from a shared mailbox, newest first.
+        
+        Args:
+            mailbox: Display name or address of the shared mailbox
+            folder_name: Folder of the shared mailbox (default: Inbox)
+            count: Maximum number of emails to retrieve
+            offset: Number of emails to skip for pagination
+        
+        Returns:
+            List of email dictionaries in the get_emails format
+        
+        Raises:
+            HTTPException: If not authenticated, the mailbox is unknown, or retrieval fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        try:
+            emails = self.adapter.get_shared_mailbox_emails(
+                mailbox, folder_name=folder_name, count=count, offset=offset
+            )
+            self.logger.info(f"Retrieved {len(emails)} emails from {mailbox}/{folder_name}")
+            return [mark_protection(email) for email in emails]
+            
+        except ValueError as e:
+            raise HTTPException(status_code=404, detail=str(e))
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error retrieving emails from {mailbox}: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to retrieve emails from {mailbox}: {str(e)}"
+            )
+    
     def get_email_content(self, email_id: str) -> Dict[str, Any]:
         """Get full email content by ID.
         
-        Retrieves complete email details including full body content.
+        Retrieves complete email details including full body content, whether
+        the sender requested a read receipt, and the HTML body (used to find
+        external images even when the plain-text body is returned).
         
         Args:
             email_id: Email EntryID from Outlook
@@ -212,10 +358,16 @@ class COMEmailProvider(EmailProvider):
                 # Return email with full body
                 # Note: This is a simplified version. In a real implementation,
                 # you might want to retrieve all email fields again
-                return {
+                properties = self.adapter.get_privacy_properties(email_id)
+                return mark_protection({
                     'id': email_id,
-                    'body': body
-                }
+                    'body': body,
+                    'html_body': properties.get('html_body') or '',
+                    'requests_read_receipt': bool(properties.get('requests_read_receipt')),
+                    'message_class': properties.get('message_class') or '',
+                    'permission': properties.get('permission') or 0,
+                    'attachments': properties.get('attachments') or []
+                })
             
             return None
             
@@ -231,10 +383,15 @@ class COMEmailProvider(EmailProvider):
             )
     
     def get_folders(self) -> List[Dict[str, str]]:
-        """List available email folders.
+        """List available email folders with their unread and total counts.
+        
+        The folder tree is walked once and cached for
+        settings.folder_cache_seconds; moving or categorizing an email
+        clears the cache.
         
         Returns:
-            List of folder dictionaries with id, name, and type
+            List of folder dictionaries with id, name, path, type,
+            unread_count, and total_count
         
         Raises:
             HTTPException: If not authenticated or listing fails
@@ -245,12 +402,19 @@ class COMEmailProvider(EmailProvider):
                 detail="Not authenticated. Call authenticate() first."
             )
         
+        if self.folder_cache_age() is not None:
+            return list(self._folder_cache[1])
+        
         try:
             self.logger.debug("Retrieving folder list")
             
+            started = time.monotonic()
             folders = self.adapter.get_folders()
+            elapsed = time.monotonic() - started
             
-            self.logger.info(f"Retrieved {len(folders)} folders")
+            self.logger.info(f"Retrieved {len(folders)} folders in {elapsed:.2f}s")
+            if settings.folder_cache_seconds > 0:
+                self._folder_cache = (time.monotonic(), list(folders))
             return folders
             
         except RuntimeError as e:
@@ -264,11 +428,26 @@ class COMEmailProvider(EmailProvider):
                 detail=f"Failed to retrieve folders: {str(e)}"
             )
     
-    def mark_as_read(self, email_id: str) -> bool:
+    def folder_cache_age(self) -> Optional[float]:
+        """Seconds since the cached folder list was read, or None if there is no fresh cache."""
+        if self._folder_cache is None:
+            return None
+        age = time.monotonic() - self._folder_cache[0]
+        if age >= settings.folder_cache_seconds:
+            self._folder_cache = None
+            return None
+        return age
+    
+    def invalidate_folder_cache(self) -> None:
+        """Drop the cached folder list so the next listing re-reads Outlook."""
+        self._folder_cache = None
+    
+    def mark_as_read(self, email_id: str, suppress_read_receipt: bool = False) -> bool:
         """Mark an email as read.
         
         Args:
             email_id: Email EntryID from Outlook
+            suppress_read_receipt: Prevent Outlook from sending a requested read receipt
         
         Returns:
             True if successful, False otherwise
@@ -285,9 +464,12 @@ class COMEmailProvider(EmailProvider):
         try:
             self.logger.debug(f"Marking email as read: {email_id}")
             
-            success = self.adapter.mark_as_read(email_id)
+            success = self.adapter.mark_as_read(
+                email_id, suppress_read_receipt=suppress_read_receipt
+            )
             
             if success:
+                self.invalidate_folder_cache()
                 self.logger.info(f"Marked email as read: {email_id}")
             else:
                 self.logger.warning(f"Failed to mark email as read: {email_id}")
@@ -330,6 +512,7 @@ class COMEmailProvider(EmailProvider):
             success = self.adapter.move_email(email_id, destination_folder)
             
             if success:
+                self.invalidate_folder_cache()
                 self.logger.info(f"Moved email to {destination_folder}")
             else:
                 self.logger.warning(f"Failed to move email to {destination_folder}")
@@ -347,6 +530,283 @@ class COMEmailProvider(EmailProvider):
                 detail=f"Failed to move email: {str(e)}"
             )
     
+    def delete_email(self, email_id: str) -> bool:
+        """Delete an email in Outlook (it goes to Deleted Items).
+        
+        Args:
+            email_id: Email EntryID from Outlook
+        
+        Returns:
+            True if deleted, False otherwise
+        
+        Raises:
+            HTTPException: If not authenticated or operation fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        try:
+            self.logger.debug(f"Deleting email {email_id}")
+            
+            success = self.adapter.delete_email(email_id)
+            
+            if success:
+                self.invalidate_folder_cache()
+                self.logger.info(f"Deleted email {email_id}")
+            else:
+                self.logger.warning(f"Failed to delete email {email_id}")
+            
+            return success
+            
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error deleting email: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to delete email: {str(e)}"
+            )
+    
+    def get_emails_by_ids(self, email_ids: List[str]) -> Dict[str, Dict[str, Any]]:
+        """Look up several emails, including read state, folder, and categories.
+        
+        Args:
+            email_ids: Email EntryIDs from Outlook
+        
+        Returns:
+            Email dictionaries keyed by EntryID; deleted emails are left out
+        
+        Raises:
+            HTTPException: If not authenticated or retrieval fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        try:
+            emails = self.adapter.get_emails_by_ids(email_ids)
+            return {email_id: mark_protection(email) for email_id, email in emails.items()}
+            
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error looking up emails: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to look up emails: {str(e)}"
+            )
+    
+    def create_draft_reply(self, email_id: str, subject: str, body: str) -> Optional[str]:
+        """Save a reply to an email in the Outlook Drafts folder.
+        
+        The reply is saved, never sent.
+        
+        Args:
+            email_id: Email EntryID from Outlook
+            subject: Reply subject
+            body: Reply text, placed above the quoted original
+        
+        Returns:
+            EntryID of the saved draft, or None if the email was not found
+        
+        Raises:
+            HTTPException: If not authenticated or operation fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        try:
+            self.logger.debug(f"Saving draft reply to {email_id}")
+            
+            draft_id = self.adapter.create_draft_reply(email_id, subject, body)
+            
+            if draft_id:
+                self.logger.info(f"Saved draft reply to {email_id}")
+            else:
+                self.logger.warning(f"Failed to save draft reply to {email_id}")
+            
+            return draft_id
+            
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error saving draft reply: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to save draft reply: {str(e)}"
+            )
+    
+    def get_inline_attachment(self, email_id: str, content_id: str) -> Optional[Dict[str, Any]]:
+        """Read the inline attachment with this Content-ID from an Outlook email.
+        
+        Args:
+            email_id: Email EntryID from Outlook
+            content_id: Content-ID the HTML body references as cid:content_id
+        
+        Returns:
+            Dict with name, content_type, and data (bytes), or None if the
+            email has no such attachment
+        
+        Raises:
+            HTTPException: If not authenticated or reading fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        try:
+            return self.adapter.get_inline_attachment(email_id, content_id)
+            
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error reading inline attachment: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to read inline attachment: {str(e)}"
+            )
+    
+    def categorize_email(self, email_id: str, category: str) -> bool:
+        """Apply a category to an email in Outlook.
+        
+        Args:
+            email_id: Email EntryID from Outlook
+            category: Email Helper category to apply
+        
+        Returns:
+            True if successful, False otherwise
+        
+        Raises:
+            HTTPException: If not authenticated or operation fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        if self._category_colors is None:
+            try:
+                self.ensure_categories(settings.outlook_category_colors)
+            except HTTPException as e:
+                self.logger.warning(f"Could not create Outlook categories: {e.detail}")
+        
+        try:
+            self.logger.debug(f"Categorizing email {email_id} as {category}")
+            
+            success = self.adapter.categorize_email(email_id, category)
+            
+            if success:
+                self.invalidate_folder_cache()
+            else:
+                self.logger.warning(f"Failed to categorize email {email_id} as {category}")
+            
+            return success
+            
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error categorizing email: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to categorize email: {str(e)}"
+            )
+    
+    def ensure_categories(self, colors: Dict[str, str], force_colors: bool = False) -> Dict[str, str]:
+        """Create missing Outlook master categories with their colors.
+        
+        The result is cached, so Outlook's category list is only read again
+        when a different palette is requested or force_colors is set.
+        
+        Args:
+            colors: Category name -> color name from OUTLOOK_CATEGORY_COLORS
+            force_colors: Recolor categories the user already colored differently
+        
+        Returns:
+            Category name -> the color it has in Outlook
+        
+        Raises:
+            HTTPException: If not authenticated or operation fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        if not force_colors and self._category_colors and self._category_colors[0] == colors:
+            return dict(self._category_colors[1])
+        
+        try:
+            applied = self.adapter.ensure_categories(
+                {name: OUTLOOK_CATEGORY_COLORS.index(color) for name, color in colors.items()},
+                force_colors
+            )
+            effective = {name: _color_name(value) for name, value in applied.items()}
+            
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error creating Outlook categories: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to create Outlook categories: {str(e)}"
+            )
+        
+        self._category_colors = (dict(colors), effective)
+        return dict(effective)
+    
+    def get_rules(self) -> List[Dict[str, Any]]:
+        """List the selected store's Outlook rules, in the order they run.
+        
+        Returns:
+            Rule dictionaries in the EmailProvider.get_rules format
+        
+        Raises:
+            HTTPException: If not authenticated or listing fails
+        """
+        if not self.authenticated:
+            raise HTTPException(
+                status_code=401,
+                detail="Not authenticated. Call authenticate() first."
+            )
+        
+        try:
+            return self.adapter.get_rules()
+            
+        except RuntimeError as e:
+            self.logger.error(f"Connection error: {e}")
+            self.authenticated = False
+            raise HTTPException(status_code=401, detail=str(e))
+        except Exception as e:
+            self.logger.error(f"Error listing Outlook rules: {e}")
+            raise HTTPException(
+                status_code=500,
+                detail=f"Failed to list Outlook rules: {str(e)}"
+            )
+    
     def get_conversation_thread(self, conversation_id: str) -> List[Dict[str, Any]]:
         """Get all emails in a conversation thread.
         
@@ -378,7 +838,7 @@ class COMEmailProvider(EmailProvider):
             all_emails = self.adapter.get_emails(folder_name="Inbox", count=100)
             
             thread_emails = [
-                email for email in all_emails
+                mark_protection(email) for email in all_emails
                 if email.get('conversation_id') == conversation_id
             ]
             
@@ -0,0 +1,302 @@
+"""Contact profiles from sent mail for FastAPI Email Helper API.
+
+Who the mailbox owner writes to says a lot about who matters: a sender
+emailed every week is a colleague, one never written to is more likely a
+newsletter. The contact_scan background job reads the provider's Sent
+Items and counts, per To and Cc address, how many sent emails addressed it
+and when the first and latest were sent, in the contacts table. Each
+contact's counts become a one-line relationship hint ("frequent
+correspondent, 42 exchanges, last reply 3 days ago") that batch
+classification places with the email headers.
+
+Scans are incremental. The sent time of the newest email counted is kept
+as the contact_scan watermark in the sync_watermarks table, and the next
+scan reads Sent Items newest first only until it reaches an email sent at
+or before it. At most settings.contact_scan_max_emails emails are read per
+scan, so a first scan of a large Sent Items counts only the newest ones.
+Sent Items is never read while settings.excluded_folders covers it.
+Like the activity log, contacts are mailbox-wide: the provider reads the
+one signed-in mailbox.
+"""
+
+import asyncio
+import logging
+from datetime import datetime
+from email.utils import parseaddr
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+from backend.core.config import settings
+from backend.core.folder_scope import is_excluded_folder
+from backend.core.recipients import email_recipients
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.contact import ContactProfile
+from backend.services.follow_up_detector import parse_received
+from backend.services.thread_participants import normalize_address
+
+logger = logging.getLogger(__name__)
+
+SENT_FOLDER = "Sent Items"
+
+# sync_watermarks row of the Sent Items scan
+CONTACT_SCAN_WATERMARK = "contact_scan"
+
+# Sent emails requested from the provider per page while scanning
+CONTACT_SCAN_PAGE_SIZE = 100
+
+# Hint for a sender the mailbox owner has never written to
+UNKNOWN_CONTACT_HINT = "not someone you have emailed"
+
+
+def _sent_at(email: Dict[str, Any]) -> Optional[datetime]:
+    """When a sent email was sent (providers list it as received_time in Sent Items)."""
+    return parse_received(email.get("sent_time") or email.get("received_time") or email.get("received_date"))
+
+
+def _days_ago(then: datetime, now: datetime) -> str:
+    days = (now.date() - then.date()).days
+    if days <= 0:
+        return "today"
+    if days == 1:
+        return "yesterday"
+    return f"{days} days ago"
+
+
+def relationship_hint(profile: Optional[Dict[str, Any]], now: Optional[datetime] = None) -> str:
+    """One-line description of how often and how recently the owner wrote to a contact.
+
+    Args:
+        profile: Contact row (sent_count, last_contact_at), or None for
+            someone never written to
+        now: Reference time (defaults to now, UTC)
+    """
+    if not profile or not profile.get("sent_count"):
+        return UNKNOWN_CONTACT_HINT
+    count = profile["sent_count"]
+    last = parse_received(profile.get("last_contact_at"))
+    if count == 1:
+        parts = ["emailed once"]
+    else:
+        kind = "frequent" if count >= settings.contact_frequent_threshold else "occasional"
+        parts = [f"{kind} correspondent", f"{count} exchanges"]
+    if last is not None:
+        parts.append(f"last reply {_days_ago(last, now or datetime.utcnow())}")
+    return ", ".join(parts)
+
+
+def aggregate_sent(
+    emails: Iterable[Dict[str, Any]],
+    own_address: Optional[str] = None
+) -> Dict[str, Dict[str, Any]]:
+    """Count sent emails per To and Cc address.
+
+    An address named twice on one email counts once; the owner's own
+    address is skipped.
+
+    Returns:
+        Address -> display_name, sent_count, first_contact_at, last_contact_at
+    """
+    own = normalize_address(own_address)
+    contacts: Dict[str, Dict[str, Any]] = {}
+    for email in emails:
+        sent_at = _sent_at(email)
+        to, cc = email_recipients(email)
+        seen = set()
+        for value in to + cc:
+            address = normalize_address(value)
+            if not address or address == own or address in seen:
+                continue
+            seen.add(address)
+            contact = contacts.setdefault(address, {
+                "display_name": None, "sent_count": 0, "first_contact_at": None, "last_contact_at": None
+            })
+            contact["display_name"] = contact["display_name"] or parseaddr(value)[0] or None
+            contact["sent_count"] += 1
+            if sent_at is not None:
+                if contact["first_contact_at"] is None or sent_at < contact["first_contact_at"]:
+                    contact["first_contact_at"] = sent_at
+                if contact["last_contact_at"] is None or sent_at > contact["last_contact_at"]:
+                    contact["last_contact_at"] = sent_at
+    return contacts
+
+
+class ContactStore:
+    """Store for contact profiles and the Sent Items scan."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the contact store.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def _watermark_sync(self) -> Tuple[Optional[datetime], Optional[datetime]]:
+        """Sent time of the newest email counted, and when the last scan ran."""
+        with self.db.get_connection() as conn:
+            row = conn.execute(
+                "SELECT watermark, updated_at FROM sync_watermarks WHERE source = ?", (CONTACT_SCAN_WATERMARK,)
+            ).fetchone()
+        if row is None:
+            return None, None
+        return parse_received(row["watermark"]), parse_received(row["updated_at"])
+
+    def _read_new_sent(self, provider, watermark: Optional[datetime]) -> List[Dict[str, Any]]:
+        """Sent emails newer than the watermark, read newest first. Blocking."""
+        limit = settings.contact_scan_max_emails
+        emails: List[Dict[str, Any]] = []
+        offset = 0
+        while len(emails) < limit:
+            page = provider.get_emails(SENT_FOLDER, count=CONTACT_SCAN_PAGE_SIZE, offset=offset)
+            for email in page:
+                sent_at = _sent_at(email)
+                if watermark is not None and sent_at is not None and sent_at <= watermark:
+                    return emails[:limit]
+                emails.append(email)
+            if len(page) < CONTACT_SCAN_PAGE_SIZE:
+                break
+            offset += len(page)
+        return emails[:limit]
+
+    async def scan(self, provider, now: Optional[datetime] = None) -> int:
+        """Count the sent emails added since the last scan.
+
+        Args:
+            provider: Email provider whose Sent Items is read
+            now: Time recorded as the scan time (defaults to now, UTC)
+
+        Returns:
+            Number of sent emails counted (0 while settings.excluded_folders covers Sent Items)
+        """
+        if is_excluded_folder(SENT_FOLDER):
+            logger.info(f"Not scanning {SENT_FOLDER}: it is excluded by excluded_folders")
+            return 0
+        loop = asyncio.get_event_loop()
+        now = now or datetime.utcnow()
+
+        def _scan_sync():
+            watermark, _ = self._watermark_sync()
+            emails = self._read_new_sent(provider, watermark)
+            sent_times = [sent_at for sent_at in map(_sent_at, emails) if sent_at is not None]
+            newest = max(sent_times + ([watermark] if watermark else []), default=None)
+
+            with self.db.get_connection() as conn:
+                for address, contact in aggregate_sent(emails, settings.user_email).items():
+                    conn.execute(
+                        """
+                        INSERT INTO contacts
+                            (address, display_name, sent_count, first_contact_at, last_contact_at, updated_at)
+                        VALUES (?, ?, ?, ?, ?, ?)
+                        ON CONFLICT(address) DO UPDATE SET
+                            display_name = COALESCE(contacts.display_name, excluded.display_name),
+                            sent_count = contacts.sent_count + excluded.sent_count,
+                            first_contact_at = MIN(COALESCE(contacts.first_contact_at, excluded.first_contact_at),
+                                                   COALESCE(excluded.first_contact_at, contacts.first_contact_at)),
+                            last_contact_at = MAX(COALESCE(contacts.last_contact_at, excluded.last_contact_at),
+                                                  COALESCE(excluded.last_contact_at, contacts.last_contact_at)),
+                            updated_at = excluded.updated_at
+                        """,
+                        (address, contact["display_name"], contact["sent_count"], contact["first_contact_at"],
+                         contact["last_contact_at"], now)
+                    )
+                conn.execute(
+                    """
+                    INSERT INTO sync_watermarks (source, watermark, updated_at) VALUES (?, ?, ?)
+                    ON CONFLICT(source) DO UPDATE SET watermark = excluded.watermark, updated_at = excluded.updated_at
+                    """,
+                    (CONTACT_SCAN_WATERMARK, newest, now)
+                )
+                conn.commit()
+            return len(emails)
+
+        return await loop.run_in_executor(None, _scan_sync)
+
+    def _profile(self, row, now: Optional[datetime] = None) -> ContactProfile:
+        """Convert a database row to a ContactProfile."""
+        return ContactProfile(**dict(row), relationship=relationship_hint(dict(row), now))
+
+    async def list_contacts(
+        self,
+        limit: int = 50,
+        offset: int = 0
+    ) -> Tuple[List[ContactProfile], int, Optional[datetime]]:
+        """List contacts, most emailed first.
+
+        Returns:
+            The page of contacts, the total, and when Sent Items was last scanned
+        """
+        loop = asyncio.get_event_loop()
+
+        def _list_sync():
+            with self.db.get_connection() as conn:
+                total = conn.execute("SELECT COUNT(*) FROM contacts").fetchone()[0]
+                rows = conn.execute(
+                    """
+                    SELECT address, display_name, sent_count, first_contact_at, last_contact_at FROM contacts
+                    ORDER BY sent_count DESC, last_contact_at DESC, address LIMIT ? OFFSET ?
+                    """,
+                    (limit, offset)
+                ).fetchall()
+            return [self._profile(row) for row in rows], total, self._watermark_sync()[1]
+
+        return await loop.run_in_executor(None, _list_sync)
+
+    async def get_contact(self, address: str) -> Optional[ContactProfile]:
+        """Get one contact by address (any case, with or without a display name)."""
+        loop = asyncio.get_event_loop()
+
+        def _get_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    """
+                    SELECT address, display_name, sent_count, first_contact_at, last_contact_at FROM contacts
+                    WHERE address = ?
+                    """,
+                    (normalize_address(address),)
+                ).fetchone()
+            return self._profile(row) if row else None
+
+        return await loop.run_in_executor(None, _get_sync)
+
+    async def relationship_hints(
+        self,
+        senders: Iterable[Optional[str]],
+        now: Optional[datetime] = None
+    ) -> Dict[str, str]:
+        """Relationship hint of each sender, for classification.
+
+        Returns:
+            Normalized sender address -> hint; empty until Sent Items has
+            been scanned, since no hint is better than calling everyone a
+            stranger
+        """
+        loop = asyncio.get_event_loop()
+        addresses = {address for address in map(normalize_address, senders) if address}
+
+        def _hints_sync():
+            if not addresses or self._watermark_sync()[1] is None:
+                return {}
+            placeholders = ", ".join("?" * len(addresses))
+            with self.db.get_connection() as conn:
+                rows = {
+                    row["address"]: dict(row) for row in conn.execute(
+                        f"SELECT address, sent_count, last_contact_at FROM contacts WHERE address IN ({placeholders})",
+                        sorted(addresses)
+                    )
+                }
+            return {address: relationship_hint(rows.get(address), now) for address in addresses}
+
+        return await loop.run_in_executor(None, _hints_sync)
+
+
+async def run_contact_scan(store: ContactStore, provider) -> int:
+    """Scan Sent Items for new sent mail once (the contact_scan background job)."""
+    counted = await store.scan(provider)
+    if counted:
+        logger.info(f"Counted {counted} sent emails into contact profiles")
+    return counted
+
+
+# Dependency for FastAPI
+def get_contact_store() -> ContactStore:
+    """FastAPI dependency for the contact store."""
+    return ContactStore()
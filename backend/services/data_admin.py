@@ -0,0 +1,374 @@
+"""Anonymized export and data wipe for FastAPI Email Helper API.
+
+POST /api/admin/export-anonymized returns a copy of the database that can
+be attached to a bug report. Mail content is replaced in the copy by fakes
+derived with HMAC (keyed per export), so equal values still get equal
+fakes and joins like tasks.email_id = emails.id keep working, while
+categories, confidences, timestamps, flags, and task structure are kept
+as they are. Senders and recipients stay address-shaped with a faked
+domain, so per-domain grouping survives. AI debug traces hold whole
+prompts and learned sender boilerplate holds signatures, so both are
+dropped. The copy is vacuumed, so no original text is
+left in free pages.
+
+POST /api/admin/wipe deletes the stored mail data (WIPE_TABLES) and keeps
+users, categories, saved views, folder profiles, user settings, and muted
+conversations. It only deletes when sent a confirmation token, which a
+call without one returns along with the row counts; tokens are signed
+with settings.secret_key and expire after WIPE_CONFIRMATION_MINUTES.
+"""
+
+import asyncio
+import hashlib
+import hmac
+import json
+import logging
+import re
+import secrets
+import sqlite3
+from datetime import datetime, timedelta, timezone
+from pathlib import PurePosixPath
+from typing import Callable, Dict, Optional, Tuple
+
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager, get_default_manager
+from classification_categories import BUILT_IN_CATEGORIES
+
+logger = logging.getLogger(__name__)
+
+# Tables POST /api/admin/wipe empties, children first
+WIPE_TABLES = (
+    "tasks", "classification_history", "outlook_activity", "outbox", "ai_traces", "category_migrations",
+    "sender_boilerplate", "contacts", "sync_watermarks", "move_batches", "email_notes",
+    "blocking_items", "holistic_analyses", "holistic_deadlines",
+    "classification_snapshot_rows", "classification_snapshots", "emails"
+)
+
+WIPE_CONFIRMATION_MINUTES = 5
+
+# Folder names kept in exports: they say nothing about the mailbox owner
+STANDARD_FOLDERS = {"inbox", "sent items", "deleted items", "drafts", "junk email", "archive", "outbox"}
+
+_ADDRESS = re.compile(r"[\w.+'-]+@([\w-]+(?:\.[\w-]+)+)")
+
+# Table -> column -> SQL expression replacing it in an export; fake(kind, value)
+# and the other functions are registered by Anonymizer. Columns not listed
+# are copied as they are.
+ANONYMIZED_COLUMNS = {
+    "users": {
+        "username": "fake('user', username)",
+        "email": "fake_address(email)",
+        "hashed_password": "''",
+    },
+    "emails": {
+        "id": "fake('email', id)",
+        "subject": "fake('subject', subject)",
+        "sender": "fake_address(sender)",
+        "recipient": "fake_address(recipient)",
+        "content": "fake('body', content)",
+        "raw_content": "fake('body', raw_content)",
+        "preview": "fake('preview', preview)",
+        "ai_reasoning": "fake('reasoning', ai_reasoning)",
+        "one_line_summary": "fake('summary', one_line_summary)",
+        "importance_justification": "fake('reasoning', importance_justification)",
+        "to_recipients": "fake_addresses(to_recipients)",
+        "cc_recipients": "fake_addresses(cc_recipients)",
+        "attachments": "fake_attachments(attachments)",
+        "folder": "fake_folder(folder)",
+        "conversation_id": "fake('conversation', conversation_id)",
+        "original_conversation_id": "fake('conversation', original_conversation_id)",
+        "inherited_from": "fake('email', inherited_from)",
+    },
+    "tasks": {
+        "title": "fake('task', title)",
+        "description": "fake('description', description)",
+        "email_id": "fake('email', email_id)",
+    },
+    "classification_history": {
+        "email_id": "fake('email', email_id)",
+    },
+    "outlook_activity": {
+        "email_id": "fake('email', email_id)",
+        "parameters": "'{}'",
+        "error": "fake('error', error)",
+    },
+    "outbox": {
+        "email_id": "fake('email', email_id)",
+        "parameters": "'{}'",
+        "last_error": "fake('error', last_error)",
+    },
+    "contacts": {
+        "address": "fake_address(address)",
+        "display_name": "fake('name', display_name)",
+    },
+    "saved_views": {
+        "name": "fake('view', name)",
+        "filters": "'{}'",
+    },
+    "folder_profiles": {
+        "folder_path": "fake_folder(folder_path)",
+    },
+    "categories": {
+        "description": "fake_rules(name, description)",
+    },
+    "user_settings": {
+        "feed_token_hash": "NULL",
+    },
+    "muted_conversations": {
+        "conversation_id": "fake('conversation', conversation_id)",
+    },
+    "email_notes": {
+        "email_id": "fake('email', email_id)",
+        "text": "fake('note', text)",
+    },
+    "move_batches": {
+        "destination_folder": "fake_folder(destination_folder)",
+        "moves": "'[]'",
+    },
+    "blocking_items": {
+        "email_id": "fake('email', email_id)",
+        "topic": "fake('topic', topic)",
+        "why_relevant": "fake('reasoning', why_relevant)",
+        "related_email_ids": "'[]'",
+        "dismiss_note": "fake('note', dismiss_note)",
+    },
+    "holistic_analyses": {
+        "email_ids": "'[]'",
+        "analysis": "'{}'",
+    },
+    "holistic_deadlines": {
+        "email_id": "fake('email', email_id)",
+        "topic": "fake('topic', topic)",
+    },
+    "classification_snapshots": {
+        "name": "fake('snapshot', name)",
+    },
+    "classification_snapshot_rows": {
+        "email_id": "fake('email', email_id)",
+        "folder": "fake_folder(folder)",
+    },
+    # Recovery messages and details name local file paths
+    "incidents": {
+        "message": "kind",
+        "details": "NULL",
+    },
+}
+
+# Tables left empty in an export
+DROPPED_TABLES = ("ai_traces", "sender_boilerplate")
+
+
+class Anonymizer:
+    """Deterministic HMAC fakes of mail content for one export."""
+
+    def __init__(self, key: Optional[bytes] = None, kept_folders: Tuple[str, ...] = ()):
+        """Initialize the anonymizer.
+
+        Args:
+            key: HMAC key (random by default, so fakes differ between exports)
+            kept_folders: Folder names kept besides STANDARD_FOLDERS, e.g. category folders
+        """
+        self.key = key or secrets.token_bytes(32)
+        self.kept_folders = STANDARD_FOLDERS | {folder.strip().lower() for folder in kept_folders}
+        self.built_in_rules = {category["name"]: category["description"] for category in BUILT_IN_CATEGORIES}
+
+    def digest(self, kind: str, value: str) -> str:
+        return hmac.new(self.key, f"{kind}:{value}".encode("utf-8"), hashlib.sha256).hexdigest()[:16]
+
+    def fake(self, kind: str, value: Optional[str]) -> Optional[str]:
+        """Fake for a text value; NULL and empty values are kept."""
+        if value is None or value == "":
+            return value
+        return f"{kind}-{self.digest(kind, str(value))}"
+
+    def fake_address(self, value: Optional[str]) -> Optional[str]:
+        """Fake email address; the same domain always gets the same fake domain."""
+        if not value:
+            return value
+        match = _ADDRESS.search(str(value))
+        if not match:
+            return self.fake("sender", value)
+        address, domain = match.group(0).lower(), match.group(1).lower()
+        return f"{self.fake('user', address)}@{self.fake('domain', domain)}.invalid"
+
+    def fake_addresses_in_text(self, value: Optional[str]) -> Optional[str]:
+        """Replace every address in free text with its fake."""
+        if not value:
+            return value
+        return _ADDRESS.sub(lambda match: self.fake_address(match.group(0)), str(value))
+
+    def fake_addresses(self, value: Optional[str]) -> Optional[str]:
+        """Fake a JSON array of addresses."""
+        if not value:
+            return value
+        try:
+            addresses = json.loads(value)
+        except ValueError:
+            return "[]"
+        return json.dumps([self.fake_address(str(address)) for address in addresses if address])
+
+    def fake_attachments(self, value: Optional[str]) -> Optional[str]:
+        """Fake attachment names, keeping their extensions and sizes."""
+        if not value:
+            return value
+        try:
+            attachments = json.loads(value)
+        except ValueError:
+            return "[]"
+        faked = []
+        for attachment in attachments:
+            if isinstance(attachment, dict):
+                name = str(attachment.get("name") or "")
+                faked.append({
+                    "name": f"{self.fake('attachment', name)}{PurePosixPath(name).suffix}",
+                    "size": attachment.get("size", 0),
+                })
+        return json.dumps(faked)
+
+    def fake_folder(self, value: Optional[str]) -> Optional[str]:
+        """Fake each folder path segment that is not a standard or kept folder."""
+        if not value:
+            return value
+        return "/".join(
+            segment if segment.strip().lower() in self.kept_folders else self.fake("folder", segment)
+            for segment in str(value).replace("\\", "/").split("/")
+        )
+
+    def fake_rules(self, name: str, value: Optional[str]) -> Optional[str]:
+        """Fake a category's rules unless they are the built-in ones."""
+        if value == self.built_in_rules.get(name):
+            return value
+        return self.fake("rules", value)
+
+    def register(self, conn: sqlite3.Connection):
+        """Make the fake functions callable from SQL on a connection."""
+        functions: Dict[str, Tuple[int, Callable]] = {
+            "fake": (2, self.fake),
+            "fake_address": (1, self.fake_address),
+            "fake_addresses": (1, self.fake_addresses),
+            "fake_attachments": (1, self.fake_attachments),
+            "fake_folder": (1, self.fake_folder),
+            "fake_rules": (2, self.fake_rules),
+        }
+        for name, (arity, function) in functions.items():
+            conn.create_function(name, arity, function, deterministic=True)
+
+
+def anonymize_connection(conn: sqlite3.Connection, anonymizer: Anonymizer) -> Dict[str, int]:
+    """Replace mail content in a database (a copy, never the live one).
+
+    Returns:
+        Rows rewritten by table
+    """
+    anonymizer.register(conn)
+    conn.execute("PRAGMA secure_delete = ON")
+    tables = {row[0] for row in conn.execute("SELECT name FROM sqlite_master WHERE type = 'table'")}
+    rewritten = {}
+    for table, columns in ANONYMIZED_COLUMNS.items():
+        if table not in tables:
+            continue
+        existing = {row[1] for row in conn.execute(f"PRAGMA table_info({table})")}
+        assignments = ", ".join(
+            f"{column} = {expression}" for column, expression in columns.items() if column in existing
+        )
+        rewritten[table] = conn.execute(f"UPDATE {table} SET {assignments}").rowcount
+    for table in DROPPED_TABLES:
+        if table in tables:
+            rewritten[table] = conn.execute(f"DELETE FROM {table}").rowcount
+    conn.commit()
+    # Rewrite every page so no replaced text survives in free pages
+    conn.execute("VACUUM")
+    return rewritten
+
+
+def wipe_confirmation(now: Optional[datetime] = None) -> Tuple[str, datetime]:
+    """New wipe confirmation token and when it expires."""
+    expires_at = (now or datetime.now(timezone.utc)) + timedelta(minutes=WIPE_CONFIRMATION_MINUTES)
+    expires = str(int(expires_at.timestamp()))
+    return f"{expires}.{_sign(expires)}", expires_at
+
+
+def check_wipe_confirmation(token: str, now: Optional[datetime] = None) -> bool:
+    """Whether a wipe confirmation token is genuine and not expired."""
+    expires, _, signature = (token or "").partition(".")
+    if not expires.isdigit() or not hmac.compare_digest(signature, _sign(expires)):
+        return False
+    return int(expires) >= (now or datetime.now(timezone.utc)).timestamp()
+
+
+def _sign(expires: str) -> str:
+    key = settings.secret_key.encode("utf-8")
+    return hmac.new(key, f"wipe:{expires}".encode("utf-8"), hashlib.sha256).hexdigest()
+
+
+class DataAdminService:
+    """Anonymized exports and wipes of the stored data."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the data admin service.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def export_anonymized(self, target_path: str, anonymizer: Optional[Anonymizer] = None) -> Dict[str, int]:
+        """Write an anonymized copy of the database to target_path.
+
+        Returns:
+            Rows rewritten by table
+        """
+        loop = asyncio.get_event_loop()
+
+        def _export_sync():
+            with self.db.get_connection() as conn:
+                kept_folders = tuple(
+                    segment for (folder,) in conn.execute("SELECT folder FROM categories WHERE folder IS NOT NULL")
+                    for segment in folder.replace("\\", "/").split("/")
+                )
+                target = sqlite3.connect(target_path)
+                try:
+                    conn.backup(target)
+                    rewritten = anonymize_connection(target, anonymizer or Anonymizer(kept_folders=kept_folders))
+                finally:
+                    target.close()
+            logger.info(f"Exported anonymized database copy ({sum(rewritten.values())} rows rewritten)")
+            return rewritten
+
+        return await loop.run_in_executor(None, _export_sync)
+
+    async def wipe_counts(self) -> Dict[str, int]:
+        """Rows a wipe would delete, by table."""
+        loop = asyncio.get_event_loop()
+
+        def _count_sync():
+            with self.db.get_connection() as conn:
+                return {table: conn.execute(f"SELECT COUNT(*) FROM {table}").fetchone()[0] for table in WIPE_TABLES}
+
+        return await loop.run_in_executor(None, _count_sync)
+
+    async def wipe(self) -> Dict[str, int]:
+        """Delete every row of WIPE_TABLES in one transaction.
+
+        Returns:
+            Deleted rows by table
+        """
+        loop = asyncio.get_event_loop()
+
+        def _wipe_sync():
+            with self.db.get_connection() as conn:
+                try:
+                    deleted = {table: conn.execute(f"DELETE FROM {table}").rowcount for table in WIPE_TABLES}
+                    conn.commit()
+                except Exception:
+                    conn.rollback()
+                    raise
+            logger.warning(f"Wiped stored data: {deleted}")
+            return deleted
+
+        return await loop.run_in_executor(None, _wipe_sync)
+
+
+def get_data_admin_service() -> DataAdminService:
+    """FastAPI dependency for data admin service."""
+    return DataAdminService()
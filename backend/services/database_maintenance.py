@@ -0,0 +1,192 @@
+"""SQLite maintenance for FastAPI Email Helper API.
+
+Deleting rows (wipes, purges, pruning) leaves their pages on the
+database's freelist: the file never shrinks and the query planner's
+statistics go stale. A maintenance pass reclaims the free pages and
+refreshes the statistics with ANALYZE and PRAGMA optimize.
+
+How free pages are reclaimed depends on fragmentation, the share of the
+database's pages on the freelist (freelist_count / page_count):
+
+- at or above settings.database_vacuum_threshold, a full VACUUM rewrites
+  the file. It also switches a database without auto_vacuum to
+  incremental auto_vacuum, so later passes can reclaim pages without a
+  rewrite;
+- below it, a database in incremental auto_vacuum mode gets PRAGMA
+  incremental_vacuum, which releases the free pages at the end of the
+  file; otherwise the free pages are left for new rows to reuse.
+
+A full VACUUM holds the database's write lock while it copies the file.
+Writes in that window wait for SQLite's busy timeout (5 seconds) and,
+if the VACUUM takes longer, fail with "database is locked"; clients
+should retry them. Only one pass runs at a time, and a VACUUM that
+cannot get the lock because of a long-running write is a 409.
+
+POST /api/admin/maintenance runs a pass now; the database_maintenance
+background job runs one every settings.database_maintenance_interval_seconds.
+"""
+
+import asyncio
+import logging
+import sqlite3
+import threading
+import time
+from typing import Optional
+
+from backend.core.config import settings
+from backend.core.errors import ConflictError
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.maintenance import MaintenanceReport
+
+logger = logging.getLogger(__name__)
+
+VACUUM_FULL = "full"
+VACUUM_INCREMENTAL = "incremental"
+VACUUM_NONE = "none"
+
+# PRAGMA auto_vacuum values by name
+AUTO_VACUUM_MODES = {0: "none", 1: "full", 2: "incremental"}
+AUTO_VACUUM_NONE = 0
+AUTO_VACUUM_INCREMENTAL = 2
+
+# Held while a pass runs, across stores and threads
+_maintenance_lock = threading.Lock()
+
+
+def fragmentation(freelist_count: int, page_count: int) -> float:
+    """Share of a database's pages that are free (0 for an empty database)."""
+    if page_count <= 0:
+        return 0.0
+    return freelist_count / page_count
+
+
+def choose_vacuum(
+    freelist_count: int,
+    page_count: int,
+    auto_vacuum: int,
+    threshold: float,
+    full: Optional[bool] = None
+) -> str:
+    """Pick how a maintenance pass reclaims free pages.
+
+    Args:
+        freelist_count: Free pages (PRAGMA freelist_count)
+        page_count: Pages in the database (PRAGMA page_count)
+        auto_vacuum: PRAGMA auto_vacuum value
+        threshold: Fragmentation at which a full VACUUM pays off
+        full: True for a full VACUUM regardless of fragmentation, False to
+            never run one, None to decide by the threshold
+
+    Returns:
+        VACUUM_FULL, VACUUM_INCREMENTAL, or VACUUM_NONE
+    """
+    if full is None:
+        full = freelist_count > 0 and fragmentation(freelist_count, page_count) >= threshold
+    if full:
+        return VACUUM_FULL
+    if freelist_count > 0 and auto_vacuum == AUTO_VACUUM_INCREMENTAL:
+        return VACUUM_INCREMENTAL
+    return VACUUM_NONE
+
+
+class DatabaseMaintenance:
+    """Vacuums and analyzes the SQLite database."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize database maintenance.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def run(self, full: Optional[bool] = None) -> MaintenanceReport:
+        """Run a maintenance pass.
+
+        Args:
+            full: True to force a full VACUUM, False to never run one, None
+                to decide by settings.database_vacuum_threshold
+
+        Returns:
+            The vacuum run, fragmentation, and database size before and after
+
+        Raises:
+            ConflictError: If a pass is already running or the database is locked
+        """
+        loop = asyncio.get_event_loop()
+        return await loop.run_in_executor(None, self._run_sync, full)
+
+    def _run_sync(self, full: Optional[bool]) -> MaintenanceReport:
+        if not _maintenance_lock.acquire(blocking=False):
+            raise ConflictError("Database maintenance is already running")
+        try:
+            started = time.monotonic()
+            with self.db.get_connection() as conn:
+                conn.commit()
+                freelist_before, page_count, auto_vacuum = self._page_stats(conn)
+                size_before = self._size(conn)
+                vacuum = choose_vacuum(
+                    freelist_before, page_count, auto_vacuum, settings.database_vacuum_threshold, full
+                )
+                try:
+                    # Statistics first, so the vacuum also reclaims the pages ANALYZE frees
+                    conn.execute("ANALYZE")
+                    conn.execute("PRAGMA optimize")
+                    conn.commit()
+                    if vacuum == VACUUM_FULL:
+                        if auto_vacuum == AUTO_VACUUM_NONE:
+                            conn.execute("PRAGMA auto_vacuum = INCREMENTAL")
+                        conn.execute("VACUUM")
+                    elif vacuum == VACUUM_INCREMENTAL:
+                        # executescript steps the pragma to completion; execute frees one page
+                        conn.executescript("PRAGMA incremental_vacuum")
+                except sqlite3.OperationalError as e:
+                    if "locked" not in str(e):
+                        raise
+                    raise ConflictError("The database is busy; retry maintenance later") from e
+                freelist_after, _, auto_vacuum = self._page_stats(conn)
+                size_after = self._size(conn)
+            report = MaintenanceReport(
+                vacuum=vacuum,
+                fragmentation=round(fragmentation(freelist_before, page_count), 4),
+                freelist_pages_before=freelist_before,
+                freelist_pages_after=freelist_after,
+                size_before_bytes=size_before,
+                size_after_bytes=size_after,
+                reclaimed_bytes=max(size_before - size_after, 0),
+                duration_ms=round((time.monotonic() - started) * 1000, 1),
+                auto_vacuum=AUTO_VACUUM_MODES.get(auto_vacuum)
+            )
+        finally:
+            _maintenance_lock.release()
+        logger.info(
+            f"Database maintenance: {vacuum} vacuum, {report.reclaimed_bytes} bytes reclaimed "
+            f"in {report.duration_ms} ms"
+        )
+        return report
+
+    @staticmethod
+    def _page_stats(conn: sqlite3.Connection):
+        """Free pages, total pages, and auto_vacuum mode."""
+        return (
+            conn.execute("PRAGMA freelist_count").fetchone()[0],
+            conn.execute("PRAGMA page_count").fetchone()[0],
+            conn.execute("PRAGMA auto_vacuum").fetchone()[0],
+        )
+
+    def _size(self, conn: sqlite3.Connection) -> int:
+        """Size of the database file, or of the pages of an in-memory store."""
+        if not self.db.is_memory:
+            return self.db.file_size()
+        return conn.execute("PRAGMA page_count").fetchone()[0] * conn.execute("PRAGMA page_size").fetchone()[0]
+
+
+async def run_maintenance(maintenance: DatabaseMaintenance) -> MaintenanceReport:
+    """Run a maintenance pass once (the database_maintenance background job)."""
+    return await maintenance.run()
+
+
+# Dependency for FastAPI
+def get_database_maintenance() -> DatabaseMaintenance:
+    """FastAPI dependency for database maintenance."""
+    return DatabaseMaintenance()
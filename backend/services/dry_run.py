@@ -0,0 +1,248 @@
+"""Dry runs of the processing pipeline and batch processing.
+
+A dry run does everything a normal run does except change the mailbox
+or the database. The services it uses are wrapped in WriteInterceptor,
+which records each write into a ChangePlan instead of running it, so the
+processing code itself has no dry-run branches. Reads go to the real
+services, so the plan reflects the current mailbox and database.
+
+With simulate_ai the AI service is replaced by SimulatedAIService, whose
+canned responses cost no tokens; it is only allowed in dry runs, since
+its results should never be stored.
+"""
+
+import inspect
+from collections import Counter
+from dataclasses import dataclass, field
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from backend.core.config import settings
+from backend.services.quarantine import QUARANTINE_FOLDER, SPAM_CATEGORY
+
+# A write handler gets the real service and the call's arguments, and
+# returns the change to record (None records nothing) plus the value the
+# caller receives in place of the real result
+WriteHandler = Callable[..., Awaitable[Tuple[Optional[Dict[str, Any]], Any]]]
+
+
+@dataclass
+class ChangePlan:
+    """Writes a dry run would have made, in the order they were attempted."""
+    changes: List[Dict[str, Any]] = field(default_factory=list)
+
+    def record(self, change: Dict[str, Any]) -> None:
+        """Add a change; every change has an action naming the write."""
+        self.changes.append(change)
+
+    def summary(self) -> Dict[str, int]:
+        """Number of changes per action."""
+        return dict(Counter(change["action"] for change in self.changes))
+
+    def describe(self) -> str:
+        """One-line description, e.g. "would move 12 emails to Quarantine, create 5 tasks"."""
+        groups: Counter = Counter()
+        for change in self.changes:
+            groups[_phrase_key(change)] += 1
+        if not groups:
+            return "would make no changes"
+        return "would " + ", ".join(_phrase(key, count) for key, count in groups.items())
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Plan as returned in run results."""
+        return {"summary": self.summary(), "description": self.describe(), "changes": list(self.changes)}
+
+
+def _phrase_key(change: Dict[str, Any]) -> Tuple[str, Optional[str]]:
+    """What a change is grouped by when described."""
+    action = change["action"]
+    if action in ("classify", "categorize_email"):
+        return action, change.get("category")
+    if action == "move_email":
+        return action, change.get("destination_folder")
+    return action, None
+
+
+def _plural(count: int, noun: str) -> str:
+    """'1 email' or '3 emails'."""
+    return f"{count} {noun}" if count == 1 else f"{count} {noun}s"
+
+
+def _phrase(key: Tuple[str, Optional[str]], count: int) -> str:
+    """Describe a group of changes."""
+    action, detail = key
+    if action == "classify":
+        return f"classify {_plural(count, 'email')} as {detail}"
+    if action == "categorize_email":
+        return f"apply category {detail} to {_plural(count, 'email')}"
+    if action == "move_email":
+        return f"move {_plural(count, 'email')} to {detail}"
+    if action == "create_task":
+        return f"create {_plural(count, 'task')}"
+    return f"{action.replace('_', ' ')} ({count})"
+
+
+class WriteInterceptor:
+    """Proxy for a service that records its writes into a change plan.
+
+    Methods named in writes are replaced by their handlers. Every other
+    method of the service's class runs with the interceptor as self, so
+    writes it makes internally (create_tasks_from_action_items calling
+    create_task) are intercepted too; other attributes come from the
+    service itself.
+    """
+
+    def __init__(self, target: Any, plan: ChangePlan, writes: Dict[str, WriteHandler]):
+        self._target = target
+        self._plan = plan
+        self._writes = writes
+
+    def __getattr__(self, name: str) -> Any:
+        if name in self._writes:
+            handler = self._writes[name]
+
+            async def intercepted(*args, **kwargs):
+                change, result = await handler(self._target, *args, **kwargs)
+                if change is not None:
+                    self._plan.record(change)
+                return result
+
+            return intercepted
+        # getattr_static leaves staticmethods and classmethods unwrapped, so only plain methods are rebound
+        method = inspect.getattr_static(type(self._target), name, None)
+        if inspect.isfunction(method):
+            return method.__get__(self)
+        return getattr(self._target, name)
+
+
+def _task_fields(task_data: Any) -> Dict[str, Any]:
+    """Task fields from a TaskCreate or a plain dict."""
+    if hasattr(task_data, "model_dump"):
+        return task_data.model_dump(mode="json", exclude_none=True)
+    return dict(task_data)
+
+
+async def _mutate(target, operation, email_id, parameters, func, *args, **kwargs):
+    """Provider change (EmailService._mutate runs every one)."""
+    return {"action": operation, "email_id": email_id, **parameters}, True
+
+
+async def _save_email_classification(target, email, classification, user_id):
+    """EmailService.save_classification."""
+    return {"action": "classify", "email_id": email.get("id"), "category": classification.category}, None
+
+
+async def _apply_category(target, email_id, category, user_id):
+    """EmailService.apply_category, which quarantines spam instead of categorizing it."""
+    if category == SPAM_CATEGORY and settings.spam_quarantine_days > 0:
+        return {"action": "move_email", "email_id": email_id, "destination_folder": QUARANTINE_FOLDER}, True
+    return {"action": "categorize_email", "email_id": email_id, "category": category}, True
+
+
+async def _update_email_category(target, email_id, category_result):
+    """Worker email service category update."""
+    return {"action": "categorize_email", "email_id": email_id, "category": category_result.get("category")}, True
+
+
+def _record_only(action: str, result: Any = True) -> WriteHandler:
+    """Handler recording the call as action and returning result."""
+    async def handler(target, *args, **kwargs):
+        return {"action": action}, result
+    return handler
+
+
+EMAIL_WRITES: Dict[str, WriteHandler] = {
+    "_mutate": _mutate,
+    "save_classification": _save_email_classification,
+    "apply_category": _apply_category,
+    "update_email_category": _update_email_category,
+    "store_email": _record_only("store_email"),
+    "update_email_summary": _record_only("update_email_summary"),
+    "update_classifications": _record_only("update_classifications", None),
+    "set_awaiting_reply": _record_only("set_awaiting_reply", None),
+}
+
+
+async def _create_task(target, task_data, user_id=None):
+    """TaskService.create_task; the caller gets the task fields back."""
+    fields = _task_fields(task_data)
+    return {"action": "create_task", **{k: fields.get(k) for k in ("title", "email_id", "priority", "due_date")}}, fields
+
+
+async def _update_task(target, task_id, updates, user_id):
+    """TaskService.update_task; the caller gets the unchanged task."""
+    return {"action": "update_task", "task_id": task_id}, await target.get_task(task_id, user_id)
+
+
+async def _delete_task(target, task_id, user_id):
+    """TaskService.delete_task."""
+    return {"action": "delete_task", "task_id": task_id}, True
+
+
+async def _delete_tasks(target, task_ids, user_id):
+    """TaskService.delete_tasks."""
+    return {"action": "delete_tasks", "task_ids": list(task_ids)}, list(task_ids)
+
+
+TASK_WRITES: Dict[str, WriteHandler] = {
+    "create_task": _create_task,
+    "update_task": _update_task,
+    "delete_task": _delete_task,
+    "delete_tasks": _delete_tasks,
+}
+
+
+async def _save_stored_classification(
+    target, email, user_id, category, confidence, needs_review, model=None, alternatives=None
+):
+    """ClassificationStore.save_classification; returns the stored classification it would replace."""
+    previous = await target.get_classification(email["id"])
+    change = {"action": "classify", "email_id": email["id"], "category": category, "needs_review": needs_review}
+    return change, previous
+
+
+CLASSIFICATION_WRITES: Dict[str, WriteHandler] = {
+    "save_classification": _save_stored_classification,
+}
+
+
+class SimulatedAIService:
+    """Canned AI responses for dry runs with simulate_ai.
+
+    Every email is classified as fyi with no action items, and no tokens
+    are used, so a dry run can exercise the pipeline without AI calls.
+    """
+
+    tokens_used = 0
+
+    CLASSIFICATION = {
+        "category": "fyi",
+        "confidence": 0.5,
+        "reasoning": "Simulated classification (simulate_ai)",
+        "alternatives": [],
+        "importance_score": 3,
+        "importance_justification": "Simulated",
+    }
+
+    async def classify_email_async(self, subject, content, sender, context=None, recipients=None, relationship=None):
+        """Canned classification."""
+        return dict(self.CLASSIFICATION)
+
+    async def triage_email_async(self, subject, content, sender):
+        """Canned triage, unsure enough to go on to the canned classification."""
+        return {"category": self.CLASSIFICATION["category"], "confidence": self.CLASSIFICATION["confidence"]}
+
+    async def extract_action_items(self, email_content, context=None):
+        """No action items."""
+        return {"action_items": [], "urgency": "low"}
+
+    async def analyze_email(self, email_data):
+        """Canned analysis."""
+        return {"priority": "medium", "simulated": True}
+
+    async def extract_tasks(self, email_data):
+        """No tasks."""
+        return []
+
+    async def categorize_email(self, email_data):
+        """Canned categorization."""
+        return dict(self.CLASSIFICATION)
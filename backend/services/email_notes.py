@@ -0,0 +1,116 @@
+"""Free-text notes on stored emails for FastAPI Email Helper API.
+
+Users attach notes to their stored emails with POST /api/emails/{id}/notes
+("called back, waiting on the invoice"). Email lists carry each email's
+note_count, GET /api/emails/{id} carries the latest note, and
+GET /api/emails/search matches note text alongside the subject, content,
+and sender. Notes go when their email's row does (quarantine purge,
+reconcile, and POST /api/admin/wipe).
+"""
+
+from datetime import datetime
+from typing import Any, Dict, Iterable, List, Optional
+
+from backend.database.connection import DatabaseManager, get_default_manager
+
+# Correlated subqueries over a row of the emails table
+NOTE_COUNT_SQL = (
+    "(SELECT COUNT(*) FROM email_notes "
+    "WHERE email_notes.email_id = emails.id AND email_notes.user_id = emails.user_id)"
+)
+NOTES_TEXT_SQL = (
+    "(SELECT group_concat(text, char(10)) FROM email_notes "
+    "WHERE email_notes.email_id = emails.id AND email_notes.user_id = emails.user_id)"
+)
+
+
+class NoteStore:
+    """Store for notes on stored emails."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the store.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def add(
+        self,
+        email_id: str,
+        user_id: int,
+        text: str,
+        now: Optional[datetime] = None
+    ) -> Optional[Dict[str, Any]]:
+        """Add a note to one of the user's stored emails.
+
+        Blocking; EmailService calls it from the thread pool.
+
+        Returns:
+            The note, or None if the user has no such stored email
+        """
+        created_at = (now or datetime.utcnow()).isoformat()
+        with self.db.get_connection() as conn:
+            cursor = conn.execute(
+                """
+                INSERT INTO email_notes (email_id, user_id, text, created_at)
+                SELECT ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM emails WHERE id = ? AND user_id = ?)
+                """,
+                (email_id, user_id, text, created_at, email_id, user_id)
+            )
+            conn.commit()
+        if cursor.rowcount == 0:
+            return None
+        return {"id": cursor.lastrowid, "email_id": email_id, "text": text, "created_at": created_at}
+
+    def list(self, email_id: str, user_id: int) -> List[Dict[str, Any]]:
+        """An email's notes, newest first.
+
+        Blocking.
+        """
+        with self.db.get_connection() as conn:
+            rows = conn.execute(
+                """
+                SELECT id, email_id, text, created_at FROM email_notes
+                WHERE email_id = ? AND user_id = ?
+                ORDER BY created_at DESC, id DESC
+                """,
+                (email_id, user_id)
+            ).fetchall()
+        return [dict(row) for row in rows]
+
+    def delete(self, note_id: int, email_id: str, user_id: int) -> bool:
+        """Delete one of an email's notes.
+
+        Blocking.
+
+        Returns:
+            False if the user has no such note on the email
+        """
+        with self.db.get_connection() as conn:
+            deleted = conn.execute(
+                "DELETE FROM email_notes WHERE id = ? AND email_id = ? AND user_id = ?",
+                (note_id, email_id, user_id)
+            ).rowcount
+            conn.commit()
+        return deleted > 0
+
+    def counts(self, user_id: int, email_ids: Iterable[str]) -> Dict[str, int]:
+        """Email ID -> number of notes, for the emails that have any.
+
+        Blocking.
+        """
+        email_ids = list(dict.fromkeys(email_ids))
+        if not email_ids:
+            return {}
+        placeholders = ", ".join("?" for _ in email_ids)
+        with self.db.get_connection() as conn:
+            rows = conn.execute(
+                f"""
+                SELECT email_id, COUNT(*) AS note_count FROM email_notes
+                WHERE user_id = ? AND email_id IN ({placeholders})
+                GROUP BY email_id
+                """,
+                [user_id, *email_ids]
+            ).fetchall()
+        return {row["email_id"]: row["note_count"] for row in rows}
@@ -6,6 +6,7 @@ maintaining compatibility with the existing EmailProvider interface
 from src/core/interfaces.py.
 """
 
+import logging
 import sys
 from abc import ABC, abstractmethod
 from pathlib import Path
@@ -17,6 +18,8 @@ sys.path.insert(0, str(Path(__file__).parent.parent.parent / "src"))
 
 from backend.core.config import settings
 
+logger = logging.getLogger(__name__)
+
 try:
     from core.interfaces import EmailProvider as CoreEmailProvider
 except ImportError:
@@ -54,8 +57,12 @@ class EmailProvider(CoreEmailProvider):
         pass
     
     @abstractmethod
-    def mark_as_read(self, email_id: str) -> bool:
-        """Mark email as read."""
+    def mark_as_read(self, email_id: str, suppress_read_receipt: bool = False) -> bool:
+        """Mark email as read.
+        
+        suppress_read_receipt asks the provider not to send a read receipt the
+        sender requested; providers that never send receipts ignore it.
+        """
         pass
     
     @abstractmethod
@@ -68,6 +75,129 @@ class EmailProvider(CoreEmailProvider):
         """Get all emails in a conversation thread."""
         pass
 
+    def folder_cache_age(self) -> Optional[float]:
+        """Seconds since the cached get_folders result was read from the mail client.
+        
+        Providers that do not cache folders always return None.
+        """
+        return None
+
+    def get_emails_by_ids(self, email_ids: List[str]) -> Dict[str, Dict[str, Any]]:
+        """Look up several emails at once.
+        
+        Emails that no longer exist are left out of the result. This default
+        fetches them one by one; providers with a bulk lookup override it.
+        """
+        found = {}
+        for email_id in email_ids:
+            try:
+                email = self.get_email_content(email_id)
+            except HTTPException as e:
+                if e.status_code == 404:
+                    continue
+                raise
+            if email:
+                found[email_id] = email
+        return found
+
+    def move_emails(self, email_ids: List[str], destination_folder: str) -> Dict[str, bool]:
+        """Move several emails to one folder.
+        
+        Returns whether each email was moved; an email whose move raises
+        counts as not moved. This default moves them one by one; providers
+        with a bulk move override it.
+        """
+        moved = {}
+        for email_id in email_ids:
+            try:
+                moved[email_id] = bool(self.move_email(email_id, destination_folder))
+            except Exception as e:
+                logger.warning(f"Could not move {email_id} to {destination_folder}: {e}")
+                moved[email_id] = False
+        return moved
+
+    def categorize_email(self, email_id: str, category: str) -> bool:
+        """Apply a category to an email in the mail client.
+        
+        Providers without client-side categories raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support categories")
+
+    def ensure_categories(self, colors: Dict[str, str], force_colors: bool = False) -> Dict[str, str]:
+        """Create missing master categories in the mail client with their colors.
+        
+        Categories that already exist keep the user's color unless
+        force_colors is set. Returns each category's effective color.
+        Providers without client-side categories raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support categories")
+
+    def create_draft_reply(self, email_id: str, subject: str, body: str) -> Optional[str]:
+        """Save a reply to an email as a draft without sending it.
+        
+        Returns the draft's ID, or None if the email was not found. Providers
+        that cannot save drafts raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support drafts")
+
+    def get_inline_attachment(self, email_id: str, content_id: str) -> Optional[Dict[str, Any]]:
+        """Read the attachment an email's HTML body embeds as cid:content_id.
+        
+        Returns name, content_type (empty when unknown), and data (bytes),
+        or None if the email has no attachment with this Content-ID.
+        Providers that cannot read attachments raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support inline attachments")
+
+    def get_mailbox_emails(
+        self,
+        mailbox: str,
+        folder_name: str = "Inbox",
+        count: int = 50,
+        offset: int = 0
+    ) -> List[Dict[str, Any]]:
+        """Retrieve emails from a folder of a shared mailbox, newest first.
+        
+        Providers without shared mailbox access raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support shared mailboxes")
+
+    def delete_email(self, email_id: str) -> bool:
+        """Delete an email from the mailbox.
+        
+        Returns False if the email was not found. Providers that cannot
+        delete mail raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support deleting emails")
+
+    def list_stores(self) -> List[Dict[str, Any]]:
+        """List the mail client's stores (mailboxes and data files).
+        
+        Each store has name, id, is_default, and selected. Providers bound
+        to a single mailbox raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support store selection")
+
+    def select_store(self, name: str) -> Dict[str, Any]:
+        """Bind folder and item operations to the store with this display name.
+        
+        Returns the selected store. Unknown names raise HTTPException (404);
+        providers bound to a single mailbox raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support store selection")
+
+    def get_rules(self) -> List[Dict[str, Any]]:
+        """List the mail client's rules, in the order they run.
+        
+        Each rule has name, enabled, incoming (False for rules on sent
+        mail), senders and subject_contains (the values of its sender and
+        subject conditions), move_to_folder (destination folder path, or
+        None), other_conditions and other_actions (names of the conditions
+        and actions beyond those). Providers without client-side rules
+        raise NotImplementedError.
+        """
+        raise NotImplementedError(f"{type(self).__name__} does not support mail rules")
+
     def get_email_body(self, email_id: str) -> str:
         """Get email body text (compatibility method)."""
         email = self.get_email_content(email_id)
@@ -79,6 +209,11 @@ class MockEmailProvider(EmailProvider):
     
     def __init__(self):
         self.authenticated = False
+        self.drafts: List[Dict[str, Any]] = []
+        # Shared mailbox name -> its emails
+        self.shared_mailboxes: Dict[str, List[Dict[str, Any]]] = {}
+        # Master category name -> color
+        self.master_categories: Dict[str, str] = {}
         self.mock_emails = [
             {
                 'id': 'mock-email-1',
@@ -110,6 +245,14 @@ class MockEmailProvider(EmailProvider):
             {'id': 'sent', 'name': 'Sent Items', 'type': 'sent'},
             {'id': 'drafts', 'name': 'Drafts', 'type': 'drafts'},
         ]
+        self.mock_stores = [
+            {'id': 'store-primary', 'name': 'Mailbox - Test User', 'is_default': True},
+            {'id': 'store-archive', 'name': 'Archive', 'is_default': False},
+        ]
+        self.selected_store_id = 'store-primary'
+        self.mock_rules: List[Dict[str, Any]] = []
+        # (email ID, Content-ID) -> inline attachment
+        self.inline_attachments: Dict[tuple, Dict[str, Any]] = {}
     
     def authenticate(self, credentials: Dict[str, str]) -> bool:
         """Mock authentication."""
@@ -124,6 +267,22 @@ class MockEmailProvider(EmailProvider):
         folder_emails = [email for email in self.mock_emails if email['folder'] == folder_name]
         return folder_emails[offset:offset + count]
     
+    def get_mailbox_emails(
+        self,
+        mailbox: str,
+        folder_name: str = "Inbox",
+        count: int = 50,
+        offset: int = 0
+    ) -> List[Dict[str, Any]]:
+        """Get mock emails from a shared mailbox."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        if mailbox not in self.shared_mailboxes:
+            raise HTTPException(status_code=404, detail=f"Mailbox {mailbox} not found")
+        
+        folder_emails = [email for email in self.shared_mailboxes[mailbox] if email['folder'] == folder_name]
+        return folder_emails[offset:offset + count]
+    
     def get_email_content(self, email_id: str) -> Dict[str, Any]:
         """Get mock email content."""
         if not self.authenticated:
@@ -141,7 +300,7 @@ class MockEmailProvider(EmailProvider):
         
         return self.mock_folders
     
-    def mark_as_read(self, email_id: str) -> bool:
+    def mark_as_read(self, email_id: str, suppress_read_receipt: bool = False) -> bool:
         """Mark mock email as read."""
         if not self.authenticated:
             raise HTTPException(status_code=401, detail="Not authenticated")
@@ -163,6 +322,80 @@ class MockEmailProvider(EmailProvider):
                 return True
         return False
     
+    def create_draft_reply(self, email_id: str, subject: str, body: str) -> Optional[str]:
+        """Save a mock reply draft."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        
+        if not any(email['id'] == email_id for email in self.mock_emails):
+            return None
+        draft_id = f"draft-{len(self.drafts) + 1}"
+        self.drafts.append({'id': draft_id, 'in_reply_to': email_id, 'subject': subject, 'body': body})
+        return draft_id
+    
+    def get_inline_attachment(self, email_id: str, content_id: str) -> Optional[Dict[str, Any]]:
+        """Get a mock inline attachment by Content-ID."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        
+        return self.inline_attachments.get((email_id, content_id))
+    
+    def delete_email(self, email_id: str) -> bool:
+        """Remove a mock email."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        
+        remaining = [email for email in self.mock_emails if email['id'] != email_id]
+        deleted = len(remaining) < len(self.mock_emails)
+        self.mock_emails = remaining
+        return deleted
+    
+    def categorize_email(self, email_id: str, category: str) -> bool:
+        """Set the category of a mock email."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        
+        for email in self.mock_emails:
+            if email['id'] == email_id:
+                email['categories'] = [category]
+                return True
+        return False
+    
+    def ensure_categories(self, colors: Dict[str, str], force_colors: bool = False) -> Dict[str, str]:
+        """Add missing mock master categories and return their colors."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        
+        for name, color in colors.items():
+            if name not in self.master_categories or force_colors:
+                self.master_categories[name] = color
+        return {name: self.master_categories[name] for name in colors}
+    
+    def list_stores(self) -> List[Dict[str, Any]]:
+        """List mock stores, marking the selected one."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        
+        return [{**store, 'selected': store['id'] == self.selected_store_id} for store in self.mock_stores]
+    
+    def select_store(self, name: str) -> Dict[str, Any]:
+        """Select a mock store by display name."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        
+        for store in self.mock_stores:
+            if store['name'].lower() == name.strip().lower():
+                self.selected_store_id = store['id']
+                return {**store, 'selected': True}
+        raise HTTPException(status_code=404, detail=f"Store {name} not found")
+    
+    def get_rules(self) -> List[Dict[str, Any]]:
+        """List the mock mail rules."""
+        if not self.authenticated:
+            raise HTTPException(status_code=401, detail="Not authenticated")
+        
+        return [dict(rule) for rule in self.mock_rules]
+    
     def get_conversation_thread(self, conversation_id: str) -> List[Dict[str, Any]]:
         """Get mock conversation thread."""
         if not self.authenticated:
@@ -0,0 +1,3381 @@
+"""Email service layer for FastAPI Email Helper API.
+
+This module provides the EmailService that sits between the email API
+endpoints and the configured EmailProvider. Provider calls (Outlook COM,
+Graph API, mock) are synchronous, so the service runs them in the thread
+pool; awaiting a service method is therefore cancellable, and a request
+that is abandoned by its client stops waiting on Outlook instead of
+holding the event loop hostage.
+"""
+
+import asyncio
+import json
+import logging
+import time
+from dataclasses import dataclass, field
+from datetime import datetime, timedelta
+from typing import Any, Awaitable, Callable, Dict, Iterable, List, Optional, Set, Tuple, TypeVar
+from urllib.parse import quote
+
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.core.attachments import attachment_columns, decode_attachments
+from backend.core.config import settings
+from backend.core.errors import InputValidationError, current_request_id
+from backend.core.read_only import check_writable
+from backend.core.email_filters import DEFAULT_SORT, EMAIL_SORTS, build_filter_clause, received_date_clause
+from backend.core.folder_scope import check_folder_in_scope, is_excluded_folder
+from backend.core.preview import email_preview
+from backend.core.recipients import decode_recipients, recipient_columns
+from backend.core.sanitize import external_image_domains, looks_like_html, sanitize_body
+from backend.core.search import build_search_clause, search_meta
+from backend.models.email import (
+    AgingBucket, AgingEmail, AttachmentUsage, EmailAgingReport, EmailClassification, EmailCounters,
+    FolderHygieneFolder, FolderHygieneReport, InboxProgress, LargestEmail, OutboxEntry, ProgressDay,
+    SenderDomainReport, SenderReputation, StorageStats, ThreadParticipantsResponse, UnreadCounter
+)
+from backend.services.activity_log import (
+    RESULT_CANCELLED, RESULT_ERROR, RESULT_FAILED, RESULT_SUCCESS, ActivityLog
+)
+from backend.services.blocking_items import BlockingStore
+from backend.services.boilerplate import sender_blocks, strip_boilerplate
+from backend.services.category_service import category_folders, category_names
+from backend.services.category_suggestions import decode_alternatives
+from backend.services.classification_store import prompt_version, upsert_classified_email
+from backend.services.email_notes import NOTE_COUNT_SQL, NOTES_TEXT_SQL, NoteStore
+from backend.services.email_provider import EmailProvider
+from backend.services.folder_hygiene import (
+    AGE_BUCKETS, bucket_counts, folder_retention, hygiene_folders, purge_candidates
+)
+from backend.services.holistic_runs import HolisticRunStore, cleanup_plan
+from backend.services.move_batches import (
+    OPERATION_ARCHIVE_AGED, OPERATION_DOMAIN_CATEGORY, OPERATION_FOLDER_HYGIENE, OPERATION_HOLISTIC_CLEANUP,
+    MoveBatchStore
+)
+from backend.services.outbox import Outbox
+from backend.services.provider_fallback import is_transient_provider_error, provider_degraded
+from backend.services.quarantine import (
+    QUARANTINE_FOLDER, SPAM_CATEGORY, is_purge_eligible, is_quarantined, quarantine_deadline
+)
+from backend.services.sender_domains import from_domain, summarize_domains
+from backend.services.sender_reputation import (
+    ReputationWeights, SenderHistory, TTLCache, score_sender
+)
+from backend.services.thread_participants import normalize_address, summarize_participants
+from backend.services.user_settings_service import user_clock
+
+logger = logging.getLogger(__name__)
+
+T = TypeVar("T")
+
+# Stored emails looked up in one provider call by reconcile_folder
+RECONCILE_BATCH_SIZE = 50
+
+# Stored rows given a preview per transaction by backfill_previews
+PREVIEW_BACKFILL_BATCH_SIZE = 200
+
+# Most emails one archive_aged_emails call selects; the job archives the rest on later runs
+ARCHIVE_AGED_LIMIT = 500
+
+# Most emails one purge_folder_hygiene call moves; later purges move the rest
+FOLDER_HYGIENE_LIMIT = 500
+
+# Emails listed in the largest_emails part of storage stats
+STORAGE_STATS_LARGEST = 20
+
+# Categories the aging report tracks, and its buckets as (label, min days, max days)
+AGING_CATEGORIES = ("required_personal_action", "team_action")
+AGING_BUCKETS = (("0-2d", 0, 2), ("3-7d", 3, 7), ("8-14d", 8, 14), ("15+d", 15, None))
+
+# Emails listed in the oldest part of the aging report
+AGING_OLDEST = 10
+
+# Categories that make a conversation actionable in grouped lists
+ACTIONABLE_CATEGORIES = ("required_personal_action", "team_action", "optional_action")
+
+# Conversation a stored email is grouped under; emails without one are groups of their own
+THREAD_KEY_SQL = "COALESCE(NULLIF(conversation_id, ''), id)"
+
+# Stored columns describing an email's classification, as returned in conflicts
+CLASSIFICATION_STATE_COLUMNS = ("category", "confidence", "needs_review", "version")
+
+# Where get_email_by_id looks an email up; auto tries the database first
+EMAIL_SOURCES = ("auto", "outlook", "database")
+
+# How emails of muted conversations are classified, without the AI
+MUTED_CATEGORY = "fyi"
+MUTED_REASONING = "Conversation is muted"
+
+# Open tasks of a conversation drop to this priority when it is muted
+MUTED_TASK_PRIORITY = "low"
+
+# Days of inbox progress history returned, which also caps the streak
+PROGRESS_STREAK_DAYS = 7
+
+# Activity log operations that count as applying a classification to Outlook
+PROGRESS_APPLIED_OPERATIONS = ("categorize_email", "move_email")
+
+# How a stored email's category was confirmed by a person, NULL if it was not.
+# corrected: the latest history entry is the user's and the category is still
+# theirs; confirmed: the user set the AI's own category again, which marks it
+# certain while ai_confidence keeps the AI's doubt.
+HUMAN_LABEL_SOURCE_SQL = """
+    CASE
+        WHEN EXISTS (
+            SELECT 1 FROM classification_history AS history
+            WHERE history.email_id = emails.id AND history.user_id = emails.user_id
+              AND history.source = 'user' AND history.category = emails.category
+              AND history.id = (
+                  SELECT MAX(latest.id) FROM classification_history AS latest
+                  WHERE latest.email_id = emails.id AND latest.user_id = emails.user_id
+              )
+        ) THEN 'corrected'
+        WHEN category = ai_category AND confidence = 1.0 AND ai_confidence < 1.0
+             AND inherited_from IS NULL THEN 'confirmed'
+    END
+"""
+
+# Sender reputations keyed by (user_id, sender); scores change slowly
+reputation_cache: TTLCache[SenderReputation] = TTLCache(settings.reputation_cache_ttl_seconds)
+
+
+def present_stored_email(row: Dict[str, Any], include_raw: bool = False) -> Dict[str, Any]:
+    """Prepare a stored email row for display.
+    
+    The content is sanitized again (defense in depth for rows stored before
+    sanitization existed), raw_content is only kept when requested, the
+    recipient, attachment, and alternative category columns are decoded
+    into lists, and is_protected into a bool.
+    """
+    email = dict(row)
+    raw = email.pop("raw_content", None)
+    if "content" in email:
+        email["content"] = sanitize_body(email["content"])
+    for column in ("to_recipients", "cc_recipients"):
+        if column in email:
+            email[column] = decode_recipients(email[column])
+    if "attachments" in email:
+        email["attachments"] = decode_attachments(email["attachments"])
+    if "alternative_categories" in email:
+        email["alternative_categories"] = decode_alternatives(email["alternative_categories"])
+    if "is_protected" in email:
+        email["is_protected"] = bool(email["is_protected"])
+    if include_raw:
+        email["raw_content"] = raw
+    return email
+
+
+def stored_email_columns(columns: Optional[List[str]] = None) -> str:
+    """SELECT list over the emails table for STORED_EMAIL_FIELDS and note_count (default all)."""
+    if not columns:
+        return f"*, {NOTE_COUNT_SQL} AS note_count"
+    return ", ".join(f"{NOTE_COUNT_SQL} AS note_count" if name == "note_count" else name for name in columns)
+
+
+def classification_state(email_id: str, row: Any) -> Dict[str, Any]:
+    """Classification fields of a stored email row, keyed as in responses."""
+    state = {"email_id": email_id, **{column: row[column] for column in CLASSIFICATION_STATE_COLUMNS}}
+    state["needs_review"] = bool(state["needs_review"])
+    return state
+
+
+@dataclass
+class ClassificationUpdateResult:
+    """Outcome of EmailService.update_classifications."""
+    missing: List[str] = field(default_factory=list)
+    # Current classification of emails not updated because their version was stale
+    conflicts: Dict[str, Dict[str, Any]] = field(default_factory=dict)
+
+
+@dataclass
+class ClassificationChangeResult:
+    """Outcome of EmailService.update_classification."""
+    db_updated: bool = False
+    # The email is neither stored nor found in the mail client
+    missing: bool = False
+    # Current classification, if the expected version was stale
+    conflict: Optional[Dict[str, Any]] = None
+    outlook_applied: bool = False
+
+
+@dataclass
+class ArchiveOutcome:
+    """Outcome of EmailService.archive_aged_emails."""
+    candidates: List[Dict[str, Any]] = field(default_factory=list)  # In the order they were selected
+    archived: List[str] = field(default_factory=list)
+    failed: List[str] = field(default_factory=list)  # The mail client did not move them
+    batch_id: Optional[int] = None  # Move batch recorded for the archived emails
+
+
+@dataclass
+class FolderHygieneOutcome:
+    """Outcome of EmailService.purge_folder_hygiene."""
+    destination_folder: str
+    candidates: List[Dict[str, Any]] = field(default_factory=list)  # Oldest first within each folder
+    purged: List[str] = field(default_factory=list)
+    failed: List[str] = field(default_factory=list)  # The mail client did not move them
+    batch_id: Optional[int] = None  # Move batch recorded for the purged emails
+
+
+@dataclass
+class DomainCategoryOutcome:
+    """Outcome of EmailService.classify_domain."""
+    candidates: List[str] = field(default_factory=list)  # Newest first
+    classified: List[str] = field(default_factory=list)
+    failed: List[str] = field(default_factory=list)  # The mail client did not file them
+    destination_folder: Optional[str] = None  # Category folder the emails were moved into
+    batch_id: Optional[int] = None  # Move batch recorded for the moved emails
+
+
+@dataclass
+class RevertOutcome:
+    """Outcome of EmailService.revert_move_batch."""
+    restored: List[str] = field(default_factory=list)
+    missing: List[str] = field(default_factory=list)  # No longer stored
+    moved_since: List[str] = field(default_factory=list)  # No longer in the batch's destination folder
+    failed: List[str] = field(default_factory=list)  # The mail client did not move them back
+    tasks_restored: List[int] = field(default_factory=list)  # Reopened with the status they had
+    tasks_changed_since: List[int] = field(default_factory=list)  # Deleted or given another status since
+    # Why the category could not be applied in the mail client, when asked to
+    outlook_error: Optional[str] = None
+
+
+@dataclass
+class HolisticApplyOutcome:
+    """Outcome of EmailService.apply_holistic_analysis."""
+    task_status: str  # Status the closed tasks were given
+    # Stored emails to archive, with the reason (superseded or duplicate), in the run's order
+    archive_candidates: List[Dict[str, Any]] = field(default_factory=list)
+    task_candidates: List[Dict[str, Any]] = field(default_factory=list)  # Open tasks of expired items
+    archived: List[str] = field(default_factory=list)
+    failed: List[str] = field(default_factory=list)  # The mail client did not move them
+    missing: List[str] = field(default_factory=list)  # Named by the run but no longer stored
+    tasks_closed: List[int] = field(default_factory=list)
+    batch_id: Optional[int] = None  # Move batch recorded for the archived emails and closed tasks
+
+
+def present_provider_email(
+    email: Optional[Dict[str, Any]],
+    include_raw: bool = False
+) -> Optional[Dict[str, Any]]:
+    """Prepare a provider email for display, sanitizing an HTML body."""
+    if not email or "body" not in email:
+        return email
+    presented = {**email, "body": sanitize_body(email["body"])}
+    if include_raw:
+        presented["raw_body"] = email.get("body")
+    return presented
+
+
+def _category_label(name: str) -> str:
+    """Normalize a category name for comparison with Outlook category labels."""
+    return name.strip().lower().replace("_", " ")
+
+
+def _compare_with_provider(row: Dict[str, Any], email: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """List field differences between a stored email and the provider's copy.
+    
+    Only fields the provider returned are compared. Categories differ when
+    Outlook has categories and none of them is the stored category.
+    """
+    differences = []
+    if "is_read" in email and bool(row["is_read"]) != bool(email["is_read"]):
+        differences.append(
+            {"field": "is_read", "database": bool(row["is_read"]), "outlook": bool(email["is_read"])}
+        )
+    if email.get("folder") and (row["folder"] or "Inbox") != email["folder"]:
+        differences.append({"field": "folder", "database": row["folder"] or "Inbox", "outlook": email["folder"]})
+    categories = email.get("categories") or []
+    if categories and row["category"] and _category_label(row["category"]) not in {
+        _category_label(category) for category in categories
+    }:
+        differences.append({"field": "categories", "database": row["category"], "outlook": list(categories)})
+    return differences
+
+
+class EmailService:
+    """Async service wrapping an EmailProvider and the database store.
+
+    Attributes:
+        provider (EmailProvider): Provider used for mailbox operations
+        db (DatabaseManager): Database store for locally synced data
+        activity (ActivityLog): Log of the mailbox changes made through _mutate
+        outbox (Outbox): Mailbox changes queued while the provider is unavailable
+        degraded (bool): Whether a read was served from the database because
+            the provider failed
+        queued (bool): Whether a change was queued in the outbox because the
+            provider failed
+    """
+
+    def __init__(self, provider: EmailProvider, db: Optional[DatabaseManager] = None):
+        """Initialize the email service.
+
+        Args:
+            provider: Email provider instance
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.provider = provider
+        self.db = db or get_default_manager()
+        self.activity = ActivityLog(self.db)
+        self.outbox = Outbox(self.db)
+        self.move_batches = MoveBatchStore(self.db)
+        self.notes = NoteStore(self.db)
+        self.blocking = BlockingStore(self.db)
+        self.holistic_runs = HolisticRunStore(self.db)
+        # Set once a read has been answered from the database (see _read)
+        self.degraded = False
+        # Set once a change has been queued instead of applied (see _queueable_mutate)
+        self.queued = False
+
+    async def _run(self, func: Callable[..., T], *args, **kwargs) -> T:
+        """Run a blocking provider or database call in the thread pool.
+
+        The awaiting coroutine is cancelled as soon as its task is cancelled;
+        the worker thread finishes in the background and its result is dropped.
+        """
+        loop = asyncio.get_event_loop()
+        return await loop.run_in_executor(None, lambda: func(*args, **kwargs))
+
+    async def _mutate(
+        self,
+        operation: str,
+        email_id: Optional[str],
+        parameters: Dict[str, Any],
+        func: Callable[..., T],
+        *args,
+        **kwargs
+    ) -> T:
+        """Run a provider call that changes the mailbox and record it in the activity log.
+        
+        Every provider change goes through here, so each one gets exactly
+        one activity row, whether it succeeds, is declined, raises, or is
+        cancelled. Recording failures are logged by ActivityLog and never
+        reach the caller. In read-only mode the change is refused before
+        the provider is called, and nothing is recorded.
+        
+        Args:
+            operation: Name recorded for the change (see ACTIVITY_OPERATIONS)
+            email_id: Email the change applies to, if any
+            parameters: Arguments recorded with the change
+            func: Provider method to run
+        
+        Raises:
+            ReadOnlyError: If settings.read_only is set
+        """
+        check_writable(operation)
+        request_id = current_request_id()
+        started = time.perf_counter()
+        result, error = RESULT_CANCELLED, None
+        try:
+            value = await self._run(func, *args, **kwargs)
+            result = RESULT_FAILED if value is None or value is False else RESULT_SUCCESS
+            return value
+        except Exception as e:
+            result, error = RESULT_ERROR, str(e)
+            raise
+        finally:
+            duration_ms = (time.perf_counter() - started) * 1000
+            await self._run(
+                self.activity.record, operation, email_id, parameters, result, duration_ms, request_id, error
+            )
+
+    async def _queueable_mutate(
+        self,
+        operation: str,
+        email_id: str,
+        parameters: Dict[str, Any],
+        func: Callable[..., bool],
+        *args,
+        **kwargs
+    ) -> bool:
+        """Make a mailbox change through _mutate, queueing it if the provider is down.
+        
+        With settings.outbox_enabled, a change failing with a transient or
+        disconnected provider error is queued in the outbox for the
+        outbox_drain job and reported as accepted, with queued set. A change
+        applied directly drops the queued change of the same kind to the
+        email, which it has overtaken.
+        
+        Args:
+            operation: One of OUTBOX_OPERATIONS
+            email_id: Email the change applies to
+            parameters: Arguments recorded with the change and replayed from the outbox
+            func: Provider method to run
+        """
+        try:
+            applied = await self._mutate(operation, email_id, parameters, func, *args, **kwargs)
+        except Exception as e:
+            if not settings.outbox_enabled or not is_transient_provider_error(e):
+                raise
+            logger.warning(f"Provider {operation} of {email_id} failed, queued in the outbox: {e}")
+            error = getattr(e, "detail", None) or str(e)
+            await self._run(self.outbox.enqueue, operation, email_id, parameters, error)
+            self.queued = True
+            return True
+        if applied:
+            await self._run(self.outbox.supersede, operation, email_id)
+        return applied
+
+    async def _read(
+        self,
+        operation: str,
+        provider_read: Callable[[], Awaitable[T]],
+        stored_read: Optional[Callable[[], Awaitable[T]]] = None
+    ) -> T:
+        """Run a provider read, answering from the database if the provider is down.
+        
+        The stored read is used only under the fallback provider_read_policy,
+        for transient or disconnected provider errors, and when the caller
+        supplied one (it needs the user whose stored emails to read).
+        
+        Args:
+            operation: Name of the read, for logs and the gauge
+            provider_read: Coroutine function reading from the provider
+            stored_read: Coroutine function reading the same data from the database
+        """
+        try:
+            result = await provider_read()
+        except Exception as e:
+            if (stored_read is None or settings.provider_read_policy != "fallback"
+                    or not is_transient_provider_error(e)):
+                raise
+            logger.warning(f"Provider {operation} failed, serving stored emails: {e}")
+            provider_degraded.record_fallback(operation, e)
+            self.degraded = True
+            return await stored_read()
+        provider_degraded.record_success()
+        return result
+
+    async def get_emails(
+        self,
+        folder_name: str = "Inbox",
+        count: int = 50,
+        offset: int = 0,
+        user_id: Optional[int] = None
+    ) -> List[Dict[str, Any]]:
+        """Get a page of emails from a folder, with HTML bodies sanitized.
+        
+        With a user_id, the user's stored emails in the folder can stand in
+        for an unreachable provider (see _read).
+        """
+        async def _provider_read():
+            emails = await self._run(
+                self.provider.get_emails,
+                folder_name=folder_name,
+                count=count,
+                offset=offset
+            )
+            return [present_provider_email(email) for email in emails]
+        
+        async def _stored_read():
+            emails, _ = await self.search_stored_emails(
+                user_id, {"folder": folder_name}, limit=count, offset=offset
+            )
+            return emails
+        
+        return await self._read("get_emails", _provider_read, _stored_read if user_id is not None else None)
+
+    async def get_mailbox_emails(
+        self,
+        mailbox: str,
+        folder_name: str = "Inbox",
+        count: int = 50,
+        offset: int = 0
+    ) -> List[Dict[str, Any]]:
+        """Get a page of emails from a shared mailbox, with HTML bodies sanitized."""
+        emails = await self._run(
+            self.provider.get_mailbox_emails,
+            mailbox,
+            folder_name=folder_name,
+            count=count,
+            offset=offset
+        )
+        return [present_provider_email(email) for email in emails]
+
+    async def get_email_by_id(
+        self,
+        email_id: str,
+        include_raw: bool = False,
+        user_id: Optional[int] = None,
+        source: str = "outlook",
+        persist: bool = False
+    ) -> Optional[Dict[str, Any]]:
+        """Get full email content by ID, or None if not found.
+
+        With source="outlook" the provider is asked (its stored copy stands
+        in when the provider is unreachable), with "database" only the
+        stored copy is read, and with "auto" the stored copy is tried first
+        and the provider only when the email is not stored. Every email
+        reports the store it came from as source ("outlook" or "database").
+
+        Provider emails with a body also report requests_read_receipt and
+        external_image_domains (hosts the unsanitized HTML loads images
+        from), so suspicious mail can be judged before it is opened.
+
+        Args:
+            email_id: Email identifier
+            include_raw: Also return the unsanitized body as raw_body
+            user_id: Owner of the stored copy (required unless source is "outlook")
+            source: One of EMAIL_SOURCES
+            persist: With source="auto", store an email found only in the provider
+
+        Raises:
+            InputValidationError: If source is unknown, or needs a user_id that was not given
+        """
+        if source not in EMAIL_SOURCES:
+            raise InputValidationError(f"Unknown source '{source}'. Valid sources: {', '.join(EMAIL_SOURCES)}")
+        if source != "outlook" and user_id is None:
+            raise InputValidationError(f"source={source} needs the user whose stored emails to read")
+
+        async def _stored_copy():
+            stored = await self.get_stored_email(email_id, user_id, include_raw=include_raw)
+            return {**stored, "source": "database"} if stored else None
+
+        if source != "outlook":
+            stored = await _stored_copy()
+            if stored or source == "database":
+                return stored
+
+        email = await self._read(
+            "get_email_by_id",
+            lambda: self._run(self.provider.get_email_content, email_id),
+            _stored_copy if user_id is not None else None
+        )
+        # Stored rows carry content rather than body and are returned as they are
+        if not email or "source" in email:
+            return email
+        if "body" not in email:
+            return {**email, "source": "outlook"}
+        if source == "auto" and persist:
+            await self.store_email(email, user_id)
+        email = dict(email)
+        html_body = email.pop("html_body", None) or email["body"]
+        presented = present_provider_email(email, include_raw)
+        presented["requests_read_receipt"] = bool(email.get("requests_read_receipt"))
+        presented["external_image_domains"] = external_image_domains(html_body)
+        presented["source"] = "outlook"
+        return presented
+
+    async def store_email(self, email: Dict[str, Any], user_id: int) -> bool:
+        """Store a provider email without classifying it.
+
+        Args:
+            email: Provider email dict (id, subject, sender, body, ...)
+            user_id: Owner of the stored email
+
+        Returns:
+            True if the email was stored, False if it was already stored
+        """
+        body = email.get("html_body") or email.get("body")
+
+        def _store_email_sync():
+            with self.db.get_connection() as conn:
+                cursor = conn.execute(
+                    """
+                    INSERT INTO emails (id, subject, sender, recipient, to_recipients, cc_recipients,
+                                        recipient_count, attachments, attachment_count, attachment_bytes,
+                                        content, raw_content, preview, received_date,
+                                        folder, conversation_id, is_read, is_protected, user_id)
+                    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+                    ON CONFLICT(id) DO NOTHING
+                    """,
+                    (
+                        email["id"],
+                        email.get("subject") or "",
+                        email.get("sender") or "",
+                        email.get("recipient"),
+                        *recipient_columns(email),
+                        *attachment_columns(email),
+                        sanitize_body(body),
+                        body if looks_like_html(body) else None,
+                        email_preview(strip_boilerplate(body, sender_blocks(conn, user_id, email.get("sender")))),
+                        email.get("received_time") or email.get("received_date"),
+                        email.get("folder") or "Inbox",
+                        email.get("conversation_id"),
+                        int(bool(email.get("is_read"))),
+                        int(bool(email.get("is_protected"))),
+                        user_id,
+                    )
+                )
+                conn.commit()
+            return cursor.rowcount > 0
+
+        return await self._run(_store_email_sync)
+
+    async def get_folders(self, user_id: Optional[int] = None) -> List[Dict[str, Any]]:
+        """List available email folders.
+        
+        With a user_id, folders and counts of the user's stored emails stand
+        in for an unreachable provider. Each folder's excluded flag says
+        whether settings.excluded_folders covers it.
+        """
+        def _stored_folders_sync():
+            where, params = self._visible_filter(user_id)
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT COALESCE(folder, 'Inbox') AS name,
+                           COUNT(*) AS total_count,
+                           COUNT(*) - TOTAL(is_read) AS unread_count
+                    FROM emails
+                    WHERE {where}
+                    GROUP BY COALESCE(folder, 'Inbox')
+                    ORDER BY name
+                    """,
+                    params
+                ).fetchall()
+            return [
+                {
+                    "id": row["name"],
+                    "name": row["name"],
+                    "path": row["name"],
+                    "type": "mail",
+                    "unread_count": int(row["unread_count"]),
+                    "total_count": row["total_count"],
+                }
+                for row in rows
+            ]
+        
+        folders = await self._read(
+            "get_folders",
+            lambda: self._run(self.provider.get_folders),
+            (lambda: self._run(_stored_folders_sync)) if user_id is not None else None
+        )
+        return [
+            {**folder, "excluded": is_excluded_folder(folder.get("path") or folder.get("name"))}
+            for folder in folders
+        ]
+    
+    def folder_cache_age(self) -> Optional[float]:
+        """Seconds since the provider's cached folder list was read, or None if not cached."""
+        return self.provider.folder_cache_age()
+
+    async def get_conversation(
+        self,
+        conversation_id: str,
+        user_id: Optional[int] = None
+    ) -> List[Dict[str, Any]]:
+        """Get all emails in a conversation thread.
+
+        With a user_id, a conversation merged with others (see
+        merge_conversations) returns the emails of the whole merged group,
+        oldest first; any member's ID finds the group.
+
+        Args:
+            conversation_id: Conversation identifier
+            user_id: Owner whose merged conversations apply
+        """
+        if user_id is None:
+            return await self._run(self.provider.get_conversation_thread, conversation_id)
+
+        canonical, members = await self.get_conversation_group(conversation_id, user_id)
+        emails: Dict[str, Dict[str, Any]] = {}
+        
+        async def _provider_read():
+            for member in members:
+                for email in await self._run(self.provider.get_conversation_thread, member):
+                    emails.setdefault(email["id"], email)
+        
+        # Merged members all carry the canonical ID once stored
+        def _stored_thread_sync():
+            where, params = self._visible_filter(user_id)
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"SELECT * FROM emails WHERE conversation_id = ? AND {where}",
+                    [canonical, *params]
+                ).fetchall()
+            emails.clear()
+            emails.update((row["id"], present_stored_email(row)) for row in rows)
+        
+        await self._read("get_conversation", _provider_read, lambda: self._run(_stored_thread_sync))
+
+        # Merged rows the provider cannot find by conversation (e.g. it gave them none)
+        def _get_unlisted_sync():
+            where, params = self._visible_filter(user_id)
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT * FROM emails
+                    WHERE conversation_id = ? AND original_conversation_id IS NOT NULL AND {where}
+                    """,
+                    [canonical, *params]
+                ).fetchall()
+            return [present_stored_email(row) for row in rows if row["id"] not in emails]
+
+        for row in await self._run(_get_unlisted_sync):
+            emails[row["id"]] = row
+
+        return sorted(
+            emails.values(),
+            key=lambda email: str(email.get("received_time") or email.get("received_date") or "")
+        )
+
+    async def get_conversation_participants(
+        self,
+        conversation_id: str,
+        user_id: int,
+        my_address: Optional[str] = None
+    ) -> ThreadParticipantsResponse:
+        """Get everyone who sent or received a message in a conversation.
+
+        The stored thread (of the whole merged group) comes first; messages
+        the provider has that are not stored yet are added to it. When the
+        provider cannot be reached the stored thread is used alone.
+
+        Args:
+            conversation_id: Conversation identifier (any member of a merged group)
+            user_id: Owner of the stored emails
+            my_address: The user's address, whose domain makes a participant internal
+
+        Returns:
+            The participants; message_count is 0 when neither the database
+            nor the provider has the conversation
+        """
+        canonical, members = await self.get_conversation_group(conversation_id, user_id)
+
+        def _stored_thread_sync():
+            where, params = self._visible_filter(user_id)
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"SELECT * FROM emails WHERE conversation_id = ? AND {where}",
+                    [canonical, *params]
+                ).fetchall()
+            return {row["id"]: present_stored_email(row) for row in rows}
+
+        emails = await self._run(_stored_thread_sync)
+        stored = len(emails)
+        try:
+            for member in members:
+                for email in await self._run(self.provider.get_conversation_thread, member):
+                    emails.setdefault(email["id"], email)
+        except Exception as e:
+            logger.warning(f"Provider thread of {conversation_id} unavailable, using stored emails: {e}")
+
+        return ThreadParticipantsResponse(
+            conversation_id=canonical,
+            participants=summarize_participants(emails.values(), my_address),
+            message_count=len(emails),
+            provider_only_count=len(emails) - stored
+        )
+
+    async def mark_as_read(self, email_id: str, suppress_read_receipt: bool = False) -> bool:
+        """Mark an email as read, optionally without sending a requested read receipt.
+        
+        Queued in the outbox while the provider is unavailable (see _queueable_mutate).
+        """
+        return await self._queueable_mutate(
+            "mark_as_read", email_id, {"suppress_read_receipt": suppress_read_receipt},
+            self.provider.mark_as_read, email_id, suppress_read_receipt=suppress_read_receipt
+        )
+
+    async def move_email(self, email_id: str, destination_folder: str) -> bool:
+        """Move an email to another folder.
+        
+        Queued in the outbox while the provider is unavailable (see _queueable_mutate).
+        
+        Raises:
+            ExcludedFolderError: If settings.excluded_folders covers the destination
+        """
+        check_folder_in_scope(destination_folder, "Cannot move emails into")
+        return await self._queueable_mutate(
+            "move_email", email_id, {"destination_folder": destination_folder},
+            self.provider.move_email, email_id, destination_folder
+        )
+
+    async def move_emails(self, email_ids: List[str], destination_folder: str) -> Dict[str, bool]:
+        """Move several emails to one folder with the provider's bulk move.
+        
+        Recorded as one activity entry and never queued in the outbox; the
+        result says which emails were moved.
+        
+        Raises:
+            ExcludedFolderError: If settings.excluded_folders covers the destination
+        """
+        check_folder_in_scope(destination_folder, "Cannot move emails into")
+        email_ids = list(email_ids)
+        return await self._mutate(
+            "move_emails", None, {"destination_folder": destination_folder, "email_ids": email_ids},
+            self.provider.move_emails, email_ids, destination_folder
+        )
+    
+    async def create_draft_reply(self, email_id: str, subject: str, body: str) -> Optional[str]:
+        """Save a reply as a draft in the mailbox without sending it.
+        
+        The activity log records the subject and body length, not the body.
+        """
+        return await self._mutate(
+            "create_draft_reply", email_id, {"subject": subject, "body_length": len(body or "")},
+            self.provider.create_draft_reply, email_id, subject, body
+        )
+
+    async def categorize_email(self, email_id: str, category: str) -> bool:
+        """Apply a category to an email in the mail client.
+        
+        Queued in the outbox while the provider is unavailable (see _queueable_mutate).
+        """
+        return await self._queueable_mutate(
+            "categorize_email", email_id, {"category": category},
+            self.provider.categorize_email, email_id, category
+        )
+
+    async def delete_email(self, email_id: str) -> bool:
+        """Delete an email in the mail client."""
+        return await self._mutate("delete_email", email_id, {}, self.provider.delete_email, email_id)
+
+    async def ensure_categories(self, force_colors: bool = False) -> Dict[str, str]:
+        """Create the mail client categories in settings.outlook_category_colors.
+
+        Returns each category's effective color; categories the user already
+        colored keep their color unless force_colors is set.
+        """
+        colors = settings.outlook_category_colors
+        return await self._mutate(
+            "ensure_categories", None, {"colors": colors, "force_colors": force_colors},
+            self.provider.ensure_categories, colors, force_colors
+        )
+
+    async def list_stores(self) -> List[Dict[str, Any]]:
+        """List the mail client's stores, marking the selected one."""
+        return await self._run(self.provider.list_stores)
+
+    async def select_store(self, name: str) -> Dict[str, Any]:
+        """Switch folder and item operations to the store with this display name."""
+        return await self._run(self.provider.select_store, name)
+
+    async def get_rules(self) -> List[Dict[str, Any]]:
+        """List the mail client's rules, in the order they run."""
+        return await self._run(self.provider.get_rules)
+
+    async def get_inline_attachment(self, email_id: str, content_id: str) -> Optional[Dict[str, Any]]:
+        """Read the attachment an email's HTML body embeds as cid:content_id from the mail client."""
+        return await self._run(self.provider.get_inline_attachment, email_id, content_id)
+
+    async def apply_category(self, email_id: str, category: str, user_id: int) -> bool:
+        """Apply a stored category to an email in the mail client.
+        
+        With settings.spam_quarantine_days set, spam_to_delete moves the
+        email to the Quarantine folder and stamps quarantined_until on its
+        row instead of applying the category.
+        
+        Returns:
+            True if the mail client accepted the change
+        """
+        if category != SPAM_CATEGORY or settings.spam_quarantine_days <= 0:
+            return await self.categorize_email(email_id, category)
+        
+        if not await self.move_email(email_id, QUARANTINE_FOLDER):
+            return False
+        until = quarantine_deadline(settings.spam_quarantine_days)
+        
+        def _quarantine_sync():
+            with self.db.get_connection() as conn:
+                conn.execute(
+                    "UPDATE emails SET folder = ?, quarantined_until = ? WHERE id = ? AND user_id = ?",
+                    (QUARANTINE_FOLDER, until, email_id, user_id)
+                )
+                conn.commit()
+        
+        await self._run(_quarantine_sync)
+        return True
+    
+    async def probe_provider(self) -> bool:
+        """Whether the provider answers a folder listing, reconnecting it first if it lost its connection."""
+        def _probe_sync():
+            if getattr(self.provider, "authenticated", True) is False and not self.provider.authenticate({}):
+                return False
+            self.provider.get_folders()
+            return True
+        
+        try:
+            return await self._run(_probe_sync)
+        except Exception as e:
+            logger.info(f"Provider probe failed: {e}")
+            return False
+    
+    def _replay(self, entry: OutboxEntry) -> Callable[[], bool]:
+        """Provider call applying a queued change."""
+        parameters = entry.parameters
+        if entry.operation == "mark_as_read":
+            return lambda: self.provider.mark_as_read(
+                entry.email_id, suppress_read_receipt=parameters.get("suppress_read_receipt", False)
+            )
+        if entry.operation == "move_email":
+            return lambda: self.provider.move_email(entry.email_id, parameters["destination_folder"])
+        if entry.operation == "categorize_email":
+            return lambda: self.provider.categorize_email(entry.email_id, parameters["category"])
+        raise ValueError(f"Unknown outbox operation '{entry.operation}'")
+    
+    async def drain_outbox(self, now: Optional[datetime] = None) -> int:
+        """Apply the outbox's due changes once the provider answers again.
+        
+        Nothing is attempted, or counted against a change, until
+        probe_provider succeeds, or while the server is read-only. Each change goes through _mutate, so it is
+        recorded in the activity log, and is removed once the provider
+        accepts it. A change that raises or is declined is retried later
+        and dead-lettered after settings.outbox_max_attempts; a transient
+        error stops the pass, leaving the rest for the next one.
+        
+        Args:
+            now: Reference time (defaults to now, UTC)
+        
+        Returns:
+            Number of changes applied
+        """
+        if settings.read_only:
+            return 0
+        entries = await self._run(self.outbox.due, now)
+        if not entries or not await self.probe_provider():
+            return 0
+        
+        applied = 0
+        for entry in entries:
+            try:
+                accepted = await self._mutate(entry.operation, entry.email_id, entry.parameters, self._replay(entry))
+            except Exception as e:
+                error = getattr(e, "detail", None) or str(e)
+                await self._run(
+                    self.outbox.fail, entry, error, settings.outbox_max_attempts, settings.outbox_retry_seconds, now
+                )
+                if is_transient_provider_error(e):
+                    break
+                continue
+            if accepted:
+                await self._run(self.outbox.complete, entry)
+                applied += 1
+            else:
+                await self._run(
+                    self.outbox.fail, entry, "The mail client declined the change",
+                    settings.outbox_max_attempts, settings.outbox_retry_seconds, now
+                )
+        return applied
+
+    
+    def _visible_filter(
+        self,
+        user_id: int,
+        since: Optional[datetime] = None,
+        include_muted: bool = True
+    ) -> Tuple[str, List[Any]]:
+        """Build the WHERE clause selecting a user's visible stored emails.
+        
+        Every query over stored emails should start from this filter so
+        exclusions apply everywhere at once. With include_muted false,
+        emails of muted conversations (see mute_conversation) are left out;
+        a conversation muted before it was merged still matches through
+        original_conversation_id.
+        """
+        clauses = ["user_id = ?"]
+        params: List[Any] = [user_id]
+        
+        if since is not None:
+            clause, bound = received_date_clause(">=", since)
+            clauses.append(clause)
+            params.append(bound)
+        if not include_muted:
+            clauses.append(
+                "NOT EXISTS (SELECT 1 FROM muted_conversations AS muted "
+                "WHERE muted.user_id = emails.user_id "
+                "AND muted.conversation_id IN (emails.conversation_id, emails.original_conversation_id))"
+            )
+        
+        return " AND ".join(clauses), params
+    
+    async def get_stored_email(
+        self,
+        email_id: str,
+        user_id: int,
+        include_raw: bool = False
+    ) -> Optional[Dict[str, Any]]:
+        """Get a locally stored email, including its cached classification.
+        
+        Args:
+            email_id: Email identifier
+            user_id: Owner of the stored email
+            include_raw: Also return the unsanitized HTML as raw_content
+            
+        Returns:
+            Stored email row as a dict, or None if not stored or not visible
+        """
+        where, params = self._visible_filter(user_id)
+        
+        def _get_stored_email_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    f"SELECT * FROM emails WHERE id = ? AND {where}",
+                    [email_id, *params]
+                ).fetchone()
+            return present_stored_email(row, include_raw) if row else None
+        
+        return await self._run(_get_stored_email_sync)
+    
+    async def get_stored_emails(self, email_ids: List[str], user_id: int) -> Dict[str, Dict[str, Any]]:
+        """Get several locally stored emails in one query.
+        
+        Returns:
+            Email ID to stored row for the IDs that are stored and visible
+        """
+        if not email_ids:
+            return {}
+        where, params = self._visible_filter(user_id)
+        placeholders = ", ".join("?" for _ in email_ids)
+        
+        def _get_stored_emails_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"SELECT * FROM emails WHERE id IN ({placeholders}) AND {where}",
+                    [*email_ids, *params]
+                ).fetchall()
+            return {row["id"]: present_stored_email(row) for row in rows}
+        
+        return await self._run(_get_stored_emails_sync)
+    
+    def _missing_summary_filter(self, user_id: int, category: Optional[str]) -> Tuple[str, List[Any]]:
+        """Build the WHERE clause selecting visible emails without a one-line summary."""
+        where, params = self._visible_filter(user_id)
+        where += " AND (one_line_summary IS NULL OR TRIM(one_line_summary) = '')"
+        if category:
+            where += " AND category = ?"
+            params.append(category)
+        return where, params
+    
+    async def count_missing_summaries(self, user_id: int, category: Optional[str] = None) -> int:
+        """Count stored emails that have no one-line summary yet."""
+        where, params = self._missing_summary_filter(user_id, category)
+        
+        def _count_sync():
+            with self.db.get_connection() as conn:
+                return conn.execute(f"SELECT COUNT(*) FROM emails WHERE {where}", params).fetchone()[0]
+        
+        return await self._run(_count_sync)
+    
+    async def get_emails_missing_summary(
+        self,
+        user_id: int,
+        category: Optional[str] = None,
+        limit: int = 200
+    ) -> List[Dict[str, Any]]:
+        """Get stored emails without a one-line summary, newest first.
+        
+        Args:
+            user_id: Owner of the stored emails
+            category: Only include emails in this AI category
+            limit: Maximum number of emails to return
+            
+        Returns:
+            Stored email rows as dicts
+        """
+        where, params = self._missing_summary_filter(user_id, category)
+        
+        def _get_missing_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id, subject, sender, content, category, is_protected
+                    FROM emails WHERE {where}
+                    ORDER BY received_date DESC LIMIT ?
+                    """,
+                    [*params, limit]
+                ).fetchall()
+            return [dict(row) for row in rows]
+        
+        return await self._run(_get_missing_sync)
+    
+    async def update_email_summary(self, email_id: str, user_id: int, summary: str) -> bool:
+        """Store a one-line summary for an email that does not have one yet.
+        
+        Existing summaries are never overwritten, so concurrent or repeated
+        backfills cannot clobber each other.
+        
+        Returns:
+            True if the summary was written
+        """
+        where, params = self._missing_summary_filter(user_id, None)
+        
+        def _update_sync():
+            with self.db.get_connection() as conn:
+                cursor = conn.execute(
+                    f"UPDATE emails SET one_line_summary = ? WHERE id = ? AND {where}",
+                    [summary, email_id, *params]
+                )
+                conn.commit()
+                return cursor.rowcount > 0
+        
+        return await self._run(_update_sync)
+    
+    async def save_classification(
+        self,
+        email: Dict[str, Any],
+        classification: EmailClassification,
+        user_id: int
+    ) -> None:
+        """Store an email and its classification, replacing any earlier result.
+        
+        The row is written by upsert_classified_email, shared with the
+        pipeline's ClassificationStore, and records the current classifier
+        prompt version and deployment so later batches can skip it.
+        
+        Args:
+            email: Provider email dict (id, subject, sender, body, ...)
+            classification: Classification to store
+            user_id: Owner of the stored email
+        """
+        def _save_classification_sync():
+            with self.db.get_connection() as conn:
+                upsert_classified_email(
+                    conn,
+                    email,
+                    user_id,
+                    classification.category,
+                    classification.confidence,
+                    reasoning=classification.reasoning,
+                    summary=classification.summary,
+                    importance_score=classification.importance_score,
+                    importance_justification=classification.importance_justification,
+                    inherited_from=classification.inherited_from,
+                    alternatives=classification.alternative_categories
+                )
+                conn.commit()
+        
+        await self._run(_save_classification_sync)
+    
+    async def backfill_previews(self, user_id: int) -> int:
+        """Compute the preview of stored emails saved before previews existed.
+        
+        Previews are computed from the unsanitized body when it was kept.
+        Rows are updated PREVIEW_BACKFILL_BATCH_SIZE at a time and only
+        while their preview is NULL, so an interrupted backfill resumes
+        where it stopped.
+        
+        Args:
+            user_id: Owner of the stored emails
+            
+        Returns:
+            Number of emails given a preview
+        """
+        def _backfill_sync():
+            updated = 0
+            with self.db.get_connection() as conn:
+                while True:
+                    rows = conn.execute(
+                        "SELECT id, sender, content, raw_content FROM emails "
+                        "WHERE user_id = ? AND preview IS NULL LIMIT ?",
+                        (user_id, PREVIEW_BACKFILL_BATCH_SIZE)
+                    ).fetchall()
+                    if not rows:
+                        return updated
+                    conn.executemany(
+                        "UPDATE emails SET preview = ? WHERE id = ? AND user_id = ?",
+                        [(email_preview(strip_boilerplate(
+                            row["raw_content"] or row["content"], sender_blocks(conn, user_id, row["sender"])
+                        )), row["id"], user_id) for row in rows]
+                    )
+                    conn.commit()
+                    updated += len(rows)
+        
+        return await self._run(_backfill_sync)
+    
+    async def get_processed_email_ids(
+        self,
+        email_ids: List[str],
+        user_id: int,
+        version: Optional[str] = None
+    ) -> Set[str]:
+        """Find which emails were already classified with a prompt version.
+        
+        Args:
+            email_ids: Emails to check
+            user_id: Owner of the stored emails
+            version: Prompt version to match (defaults to the current one)
+            
+        Returns:
+            The IDs of emails classified with that version
+        """
+        if not email_ids:
+            return set()
+        where, params = self._visible_filter(user_id)
+        placeholders = ", ".join("?" for _ in email_ids)
+        
+        def _get_processed_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id FROM emails
+                    WHERE id IN ({placeholders}) AND ai_prompt_version = ? AND {where}
+                    """,
+                    [*email_ids, version or prompt_version(), *params]
+                ).fetchall()
+            return {row["id"] for row in rows}
+        
+        return await self._run(_get_processed_sync)
+    
+    async def update_classifications(
+        self,
+        user_id: int,
+        changes: List[Tuple[str, str]],
+        source: str = "user",
+        expected_versions: Optional[Dict[str, int]] = None
+    ) -> ClassificationUpdateResult:
+        """Set the category of several stored emails in one transaction.
+        
+        Each email whose category changes gets a classification_history row.
+        Corrected emails are marked certain, no longer inherit from their
+        thread, and get their version bumped. An email with an expected
+        version is only updated if its stored version still matches; the
+        check is part of the UPDATE, so a write that lands between reading
+        and updating the row is never overwritten.
+        
+        Args:
+            user_id: Owner of the stored emails
+            changes: (email_id, category) pairs
+            source: Who made the change, recorded in the history
+            expected_versions: Version each email was read at, by email ID
+            
+        Returns:
+            IDs from changes that are not stored for the user, and the
+            current classification of emails whose version was stale
+        """
+        expected_versions = expected_versions or {}
+        
+        def _update_classifications_sync():
+            result = ClassificationUpdateResult()
+            with self.db.get_connection() as conn:
+                try:
+                    for email_id, category in changes:
+                        row = conn.execute(
+                            "SELECT category FROM emails WHERE id = ? AND user_id = ?",
+                            (email_id, user_id)
+                        ).fetchone()
+                        if row is None:
+                            result.missing.append(email_id)
+                            continue
+                        query = """
+                            UPDATE emails
+                            SET category = ?, confidence = 1.0, needs_review = 0,
+                                inherited_from = NULL, processed_at = CURRENT_TIMESTAMP,
+                                version = version + 1
+                            WHERE id = ? AND user_id = ?
+                        """
+                        params = [category, email_id, user_id]
+                        if email_id in expected_versions:
+                            query += " AND version = ?"
+                            params.append(expected_versions[email_id])
+                        if conn.execute(query, params).rowcount == 0:
+                            current = conn.execute(
+                                f"SELECT {', '.join(CLASSIFICATION_STATE_COLUMNS)} FROM emails "
+                                "WHERE id = ? AND user_id = ?",
+                                (email_id, user_id)
+                            ).fetchone()
+                            result.conflicts[email_id] = classification_state(email_id, current)
+                            continue
+                        if row["category"] != category:
+                            conn.execute(
+                                """
+                                INSERT INTO classification_history
+                                    (email_id, user_id, previous_category, category, source)
+                                VALUES (?, ?, ?, ?, ?)
+                                """,
+                                (email_id, user_id, row["category"], category, source)
+                            )
+                    conn.commit()
+                except Exception:
+                    conn.rollback()
+                    raise
+            return result
+        
+        return await self._run(_update_classifications_sync)
+    
+    async def ensure_email_stored(self, email_id: str, user_id: int) -> bool:
+        """Make sure an email is stored, storing it from the provider if it is not.
+        
+        Args:
+            email_id: Email to look for
+            user_id: Owner of the stored email
+            
+        Returns:
+            True if the email is stored, False if it is not and the provider
+            does not have it or cannot be reached
+        """
+        if await self.get_classification_state(email_id, user_id) is not None:
+            return True
+        try:
+            email = await self._run(self.provider.get_email_content, email_id)
+        except Exception as e:
+            logger.warning(f"Could not fetch email {email_id} to store it: {getattr(e, 'detail', e)}")
+            return False
+        if not email:
+            return False
+        await self.store_email(email, user_id)
+        return True
+    
+    async def update_classification(
+        self,
+        user_id: int,
+        email_id: str,
+        category: str,
+        expected_version: Optional[int] = None,
+        apply_to_outlook: bool = False
+    ) -> ClassificationChangeResult:
+        """Set one email's category, storing the email first if only the provider has it.
+        
+        The stored change is made by update_classifications. With
+        apply_to_outlook, the category is then applied in the mail client;
+        a failure there does not undo the stored change and is reported in
+        outlook_error instead of raised.
+        
+        Args:
+            user_id: Owner of the stored email
+            email_id: Email to reclassify
+            category: New category
+            expected_version: Version the email was read at
+            apply_to_outlook: Also apply the category in the mail client
+            
+        Returns:
+            Whether the stored category and the mail client were updated
+        """
+        result = ClassificationChangeResult()
+        if not await self.ensure_email_stored(email_id, user_id):
+            result.missing = True
+            return result
+        
+        expected = {email_id: expected_version} if expected_version is not None else None
+        update = await self.update_classifications(user_id, [(email_id, category)], expected_versions=expected)
+        if update.missing:
+            result.missing = True
+            return result
+        if email_id in update.conflicts:
+            result.conflict = update.conflicts[email_id]
+            return result
+        result.db_updated = True
+        
+        if apply_to_outlook:
+            try:
+                result.outlook_applied = await self.apply_category(email_id, category, user_id)
+                if not result.outlook_applied:
+                    result.outlook_error = "The mail client did not apply the category"
+            except Exception as e:
+                result.outlook_error = str(getattr(e, "detail", None) or e)
+                logger.warning(f"Could not apply {category} to email {email_id}: {result.outlook_error}")
+        return result
+    
+    async def get_classification_state(self, email_id: str, user_id: int) -> Optional[Dict[str, Any]]:
+        """Read an email's stored classification and version.
+        
+        Args:
+            email_id: Stored email
+            user_id: Owner of the stored email
+            
+        Returns:
+            The email's classification state, or None if it is not stored
+        """
+        def _get_state_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    f"SELECT {', '.join(CLASSIFICATION_STATE_COLUMNS)} FROM emails "
+                    "WHERE id = ? AND user_id = ?",
+                    (email_id, user_id)
+                ).fetchone()
+            return classification_state(email_id, row) if row is not None else None
+        
+        return await self._run(_get_state_sync)
+    
+    async def reconcile_folder(
+        self,
+        user_id: int,
+        folder: str = "Inbox",
+        limit: int = 500,
+        apply: bool = False
+    ) -> Dict[str, Any]:
+        """Compare a folder's stored emails with the provider.
+        
+        Looks the newest ``limit`` stored emails up in batches of
+        RECONCILE_BATCH_SIZE. With apply, emails gone from the provider are
+        deleted from the database and read state and folder are copied from
+        the provider; a category is copied only when exactly one Outlook
+        category is a classifier category.
+        
+        Args:
+            user_id: Owner of the stored emails
+            folder: Stored folder to check
+            limit: Most stored emails to check
+            apply: Fix the database side
+            
+        Returns:
+            Report with missing_in_outlook, state_mismatches, and counts
+        
+        Raises:
+            ExcludedFolderError: If settings.excluded_folders covers the folder
+        """
+        check_folder_in_scope(folder, "Cannot reconcile")
+        where, params = self._visible_filter(user_id)
+        
+        def _load_rows_sync():
+            with self.db.get_connection() as conn:
+                return [dict(row) for row in conn.execute(
+                    f"""
+                    SELECT id, is_read, folder, category FROM emails
+                    WHERE {where} AND COALESCE(folder, 'Inbox') = ?
+                    ORDER BY received_date DESC, id
+                    LIMIT ?
+                    """,
+                    [*params, folder, limit + 1]
+                ).fetchall()]
+        
+        rows = await self._run(_load_rows_sync)
+        has_more = len(rows) > limit
+        rows = rows[:limit]
+        
+        missing: List[str] = []
+        mismatches: List[Dict[str, Any]] = []
+        for start in range(0, len(rows), RECONCILE_BATCH_SIZE):
+            batch = rows[start:start + RECONCILE_BATCH_SIZE]
+            found = await self._run(self.provider.get_emails_by_ids, [row["id"] for row in batch])
+            for row in batch:
+                email = found.get(row["id"])
+                if email is None:
+                    missing.append(row["id"])
+                    continue
+                differences = _compare_with_provider(row, email)
+                if differences:
+                    mismatches.append({"email_id": row["id"], "differences": differences})
+        
+        removed = updated = 0
+        if apply and (missing or mismatches):
+            known = {_category_label(category): category for category in await self._run(category_names, self.db)}
+            
+            def _apply_sync():
+                changed = 0
+                with self.db.get_connection() as conn:
+                    gone = [(email_id, user_id) for email_id in missing]
+                    conn.executemany(
+                        "DELETE FROM classification_history WHERE email_id = ? AND user_id = ?", gone
+                    )
+                    conn.executemany("DELETE FROM email_notes WHERE email_id = ? AND user_id = ?", gone)
+                    conn.executemany("DELETE FROM emails WHERE id = ? AND user_id = ?", gone)
+                    for mismatch in mismatches:
+                        updates = {}
+                        for difference in mismatch["differences"]:
+                            if difference["field"] == "is_read":
+                                updates["is_read"] = int(difference["outlook"])
+                            elif difference["field"] == "folder":
+                                updates["folder"] = difference["outlook"]
+                            else:
+                                matches = {known[label] for label in map(_category_label, difference["outlook"])
+                                           if label in known}
+                                if len(matches) == 1:
+                                    updates["category"] = matches.pop()
+                        if updates:
+                            assignments = [f"{column} = ?" for column in updates]
+                            if "category" in updates:
+                                assignments.append("version = version + 1")
+                            conn.execute(
+                                f"UPDATE emails SET {', '.join(assignments)} WHERE id = ? AND user_id = ?",
+                                [*updates.values(), mismatch["email_id"], user_id]
+                            )
+                            changed += 1
+                    conn.commit()
+                return changed
+            
+            updated = await self._run(_apply_sync)
+            removed = len(missing)
+        
+        return {
+            "folder": folder,
+            "checked": len(rows),
+            "has_more": has_more,
+            "missing_in_outlook": missing,
+            "state_mismatches": mismatches,
+            "applied": apply,
+            "removed": removed,
+            "updated": updated,
+        }
+    
+    async def get_quarantined_emails(
+        self,
+        user_id: int,
+        limit: int = 50,
+        offset: int = 0
+    ) -> Tuple[List[Dict[str, Any]], int]:
+        """Get stored emails waiting in quarantine, soonest purge first.
+        
+        Returns:
+            The page of emails and the total number quarantined
+        """
+        where, params = self._visible_filter(user_id)
+        where += " AND quarantined_until IS NOT NULL AND folder = ?"
+        params.append(QUARANTINE_FOLDER)
+        
+        def _get_quarantined_sync():
+            with self.db.get_connection() as conn:
+                total = conn.execute(f"SELECT COUNT(*) FROM emails WHERE {where}", params).fetchone()[0]
+                rows = conn.execute(
+                    f"SELECT * FROM emails WHERE {where} ORDER BY quarantined_until, id LIMIT ? OFFSET ?",
+                    [*params, limit, offset]
+                ).fetchall()
+            return [present_stored_email(row) for row in rows], total
+        
+        return await self._run(_get_quarantined_sync)
+    
+    async def rescue_email(self, email_id: str, user_id: int, category: str) -> bool:
+        """Move a quarantined email back to the inbox and record the correction.
+        
+        The new category is stored as a user correction, so the rescue
+        shows up in the classification history like any other fix.
+        
+        Args:
+            email_id: Quarantined email
+            user_id: Owner of the stored email
+            category: Category the email should have had
+            
+        Returns:
+            False if the mail client could not move the email back
+            
+        Raises:
+            LookupError: If the email is not stored for the user
+            InputValidationError: If the email is not in quarantine
+        """
+        row = await self.get_stored_email(email_id, user_id)
+        if row is None:
+            raise LookupError(f"Email {email_id} not found")
+        if not is_quarantined(row):
+            raise InputValidationError(f"Email {email_id} is not quarantined")
+        
+        if not await self.move_email(email_id, "Inbox"):
+            return False
+        
+        def _release_sync():
+            with self.db.get_connection() as conn:
+                conn.execute(
+                    "UPDATE emails SET folder = 'Inbox', quarantined_until = NULL WHERE id = ? AND user_id = ?",
+                    (email_id, user_id)
+                )
+                conn.commit()
+        
+        await self._run(_release_sync)
+        await self.update_classifications(user_id, [(email_id, category)])
+        return True
+    
+    async def purge_quarantine(self, now: Optional[datetime] = None) -> List[str]:
+        """Delete quarantined emails whose quarantine has expired, for every user.
+        
+        Each eligible email is deleted in the mail client first; its row is
+        removed only once that succeeds, so a failed delete is retried on
+        the next purge.
+        
+        Args:
+            now: Reference time (defaults to now, UTC)
+            
+        Returns:
+            IDs of the purged emails
+        """
+        def _load_quarantined_sync():
+            with self.db.get_connection() as conn:
+                return [dict(row) for row in conn.execute(
+                    """
+                    SELECT id, user_id, folder, category, quarantined_until FROM emails
+                    WHERE quarantined_until IS NOT NULL
+                    """
+                ).fetchall()]
+        
+        rows = [row for row in await self._run(_load_quarantined_sync) if is_purge_eligible(row, now)]
+        
+        purged = []
+        for row in rows:
+            try:
+                deleted = await self.delete_email(row["id"])
+            except Exception as e:
+                logger.warning(f"Could not delete quarantined email {row['id']}: {e}")
+                continue
+            if deleted:
+                purged.append((row["id"], row["user_id"]))
+            else:
+                logger.warning(f"Could not delete quarantined email {row['id']}")
+        
+        def _remove_rows_sync():
+            with self.db.get_connection() as conn:
+                conn.executemany(
+                    "DELETE FROM classification_history WHERE email_id = ? AND user_id = ?", purged
+                )
+                conn.executemany("DELETE FROM email_notes WHERE email_id = ? AND user_id = ?", purged)
+                conn.executemany("DELETE FROM emails WHERE id = ? AND user_id = ?", purged)
+                conn.commit()
+        
+        if purged:
+            await self._run(_remove_rows_sync)
+        return [email_id for email_id, _ in purged]
+    
+    async def get_aged_emails(
+        self,
+        user_id: int,
+        categories: List[str],
+        older_than_days: int,
+        limit: int = ARCHIVE_AGED_LIMIT,
+        now: Optional[datetime] = None
+    ) -> List[Dict[str, Any]]:
+        """Stored emails in the inbox received at least older_than_days ago, oldest first.
+        
+        Emails without a folder count as in the inbox; emails without a
+        received date are left out.
+        
+        Args:
+            user_id: Owner of the stored emails
+            categories: Categories to select
+            older_than_days: Minimum age in days
+            limit: Most emails returned
+            now: Reference time ages are measured from (defaults to now in
+                the user's time zone)
+            
+        Returns:
+            id, subject, sender, category, received_date, and folder of each email
+        """
+        if not categories:
+            return []
+        where, params = self._visible_filter(user_id)
+        placeholders = ", ".join("?" for _ in categories)
+        
+        def _get_aged_sync():
+            as_of = user_clock(self.db, user_id, now).now()
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id, subject, sender, category, received_date,
+                           COALESCE(NULLIF(folder, ''), 'Inbox') AS folder
+                    FROM emails
+                    WHERE {where} AND category IN ({placeholders}) AND received_date IS NOT NULL
+                      AND LOWER(COALESCE(NULLIF(folder, ''), 'Inbox')) = 'inbox'
+                      AND julianday(?) - julianday(received_date) >= ?
+                    ORDER BY received_date, id
+                    LIMIT ?
+                    """,
+                    [*params, *categories, as_of, older_than_days, limit]
+                ).fetchall()
+            return [dict(row) for row in rows]
+        
+        return await self._run(_get_aged_sync)
+    
+    async def archive_aged_emails(
+        self,
+        user_id: int,
+        categories: List[str],
+        older_than_days: int,
+        dry_run: bool = False,
+        now: Optional[datetime] = None
+    ) -> ArchiveOutcome:
+        """Move aged inbox emails of some categories to settings.archive_folder.
+        
+        Selects with get_aged_emails and moves the candidates with
+        move_emails. Archived emails get the archive folder on their row
+        and are recorded as one move batch that revert_move_batch can undo;
+        emails the mail client did not move keep their row as it was.
+        
+        Args:
+            user_id: Owner of the stored emails
+            categories: Categories to archive
+            older_than_days: Minimum age in days
+            dry_run: Only select the candidates
+            now: Reference time (defaults to now in the user's time zone)
+            
+        Raises:
+            ExcludedFolderError: If settings.excluded_folders covers the archive folder
+        """
+        outcome = ArchiveOutcome(candidates=await self.get_aged_emails(user_id, categories, older_than_days, now=now))
+        if dry_run or not outcome.candidates:
+            return outcome
+        
+        folder = settings.archive_folder
+        moved = await self.move_emails([email["id"] for email in outcome.candidates], folder)
+        for email in outcome.candidates:
+            (outcome.archived if moved.get(email["id"]) else outcome.failed).append(email["id"])
+        if not outcome.archived:
+            return outcome
+        
+        origins = {email["id"]: email["folder"] for email in outcome.candidates if moved.get(email["id"])}
+        
+        def _archive_sync():
+            with self.db.get_connection() as conn:
+                conn.executemany(
+                    "UPDATE emails SET folder = ? WHERE id = ? AND user_id = ?",
+                    [(folder, email_id, user_id) for email_id in outcome.archived]
+                )
+                conn.commit()
+            return self.move_batches.record(user_id, OPERATION_ARCHIVE_AGED, folder, origins)
+        
+        outcome.batch_id = await self._run(_archive_sync)
+        return outcome
+    
+    async def archive_aged_for_all_users(self, categories: List[str], older_than_days: int) -> int:
+        """Run archive_aged_emails for every user with stored emails.
+        
+        Returns:
+            Number of emails archived
+        """
+        def _user_ids_sync():
+            with self.db.get_connection() as conn:
+                return [
+                    row["user_id"] for row in
+                    conn.execute("SELECT DISTINCT user_id FROM emails WHERE user_id IS NOT NULL")
+                ]
+        
+        archived = 0
+        for user_id in await self._run(_user_ids_sync):
+            outcome = await self.archive_aged_emails(user_id, categories, older_than_days)
+            archived += len(outcome.archived)
+            if outcome.failed:
+                logger.warning(f"Could not archive {len(outcome.failed)} aged emails of user {user_id}")
+        return archived
+    
+    async def revert_move_batch(self, batch_id: int, user_id: int) -> RevertOutcome:
+        """Move the emails of a move batch back to the folders they came from.
+        
+        Emails no longer stored, or moved out of the batch's destination
+        folder since, are left where they are. Tasks the batch closed get
+        their earlier status back unless their status changed since. A
+        batch is reverted once, even if some of its emails could not be
+        moved back.
+        
+        Raises:
+            LookupError: If the user has no such batch
+            InputValidationError: If the batch was already reverted
+        """
+        batch = await self._run(self.move_batches.get, batch_id, user_id)
+        if batch is None:
+            raise LookupError(f"Move batch {batch_id} not found")
+        if not await self._run(self.move_batches.mark_reverted, batch_id):
+            raise InputValidationError(f"Move batch {batch_id} was already reverted")
+        
+        moves = batch["moves"]
+        stored = await self.get_stored_emails(list(moves), user_id)
+        outcome = RevertOutcome()
+        origins: Dict[str, List[str]] = {}
+        for email_id, origin in moves.items():
+            if email_id not in stored:
+                outcome.missing.append(email_id)
+            elif (stored[email_id].get("folder") or "Inbox") != batch["destination_folder"]:
+                outcome.moved_since.append(email_id)
+            else:
+                origins.setdefault(origin, []).append(email_id)
+        
+        restored = []
+        for origin, email_ids in origins.items():
+            moved = await self.move_emails(email_ids, origin)
+            for email_id in email_ids:
+                if moved.get(email_id):
+                    restored.append((origin, email_id))
+                else:
+                    outcome.failed.append(email_id)
+        
+        def _restore_sync():
+            with self.db.get_connection() as conn:
+                conn.executemany(
+                    "UPDATE emails SET folder = ? WHERE id = ? AND user_id = ?",
+                    [(origin, email_id, user_id) for origin, email_id in restored]
+                )
+                for task_id, change in batch["task_changes"].items():
+                    reopened = conn.execute(
+                        """
+                        UPDATE tasks SET status = ?, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = ? AND user_id = ? AND status = ?
+                        """,
+                        (change["from"], task_id, user_id, change["to"])
+                    ).rowcount
+                    (outcome.tasks_restored if reopened else outcome.tasks_changed_since).append(task_id)
+                conn.commit()
+        
+        if restored or batch["task_changes"]:
+            await self._run(_restore_sync)
+        outcome.restored = [email_id for _, email_id in restored]
+        return outcome
+    
+    async def _get_folder_emails(self, user_id: int, folders: List[str]) -> Dict[str, List[Dict[str, Any]]]:
+        """Lowercased folder name -> the user's stored emails in it."""
+        if not folders:
+            return {}
+        where, params = self._visible_filter(user_id)
+        placeholders = ", ".join("?" for _ in folders)
+        
+        def _get_folder_emails_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id, subject, sender, category, received_date, folder, is_flagged
+                    FROM emails
+                    WHERE {where} AND LOWER(folder) IN ({placeholders})
+                    """,
+                    [*params, *(folder.lower() for folder in folders)]
+                ).fetchall()
+            by_folder: Dict[str, List[Dict[str, Any]]] = {}
+            for row in rows:
+                by_folder.setdefault(row["folder"].lower(), []).append(dict(row))
+            return by_folder
+        
+        return await self._run(_get_folder_emails_sync)
+    
+    async def get_folder_hygiene(self, user_id: int, now: Optional[datetime] = None) -> FolderHygieneReport:
+        """Report how old the emails in each category folder are.
+        
+        Covers the folders categories file email into, other than the inbox
+        (see backend.services.folder_hygiene). Item counts come from the
+        mail client's folder list; ages, and the emails past the folder's
+        retention, from the stored emails. Excluded folders are listed
+        without reading their emails.
+        
+        Args:
+            user_id: Owner of the stored emails
+            now: Reference time ages are measured from (defaults to now in
+                the user's time zone)
+            
+        Returns:
+            Each folder's item count, stored emails per AGE_BUCKETS bucket,
+            retention, and emails past it, by folder name
+        """
+        folders = await self._run(hygiene_folders, self.db)
+        item_counts: Dict[str, Optional[int]] = {}
+        for listed in await self.get_folders(user_id=user_id):
+            for name in (listed.get("path"), listed.get("name")):
+                if name:
+                    item_counts.setdefault(name.lower(), listed.get("total_count"))
+        in_scope = [folder for folder in folders if not is_excluded_folder(folder)]
+        emails = await self._get_folder_emails(user_id, in_scope)
+        as_of = user_clock(self.db, user_id, now).now()
+        
+        report = []
+        for folder in folders:
+            entry = FolderHygieneFolder(
+                folder=folder,
+                excluded=folder not in in_scope,
+                item_count=item_counts.get(folder.lower()),
+                retention_days=folder_retention(folder)
+            )
+            if not entry.excluded:
+                rows = emails.get(folder.lower(), [])
+                counts, entry.undated_count = bucket_counts(rows, as_of)
+                entry.stored_count = len(rows)
+                entry.buckets = [
+                    AgingBucket(label=label, min_days=min_days, max_days=max_days, count=counts[label])
+                    for label, min_days, max_days in AGE_BUCKETS
+                ]
+                entry.suggested_purge_count = len(purge_candidates(rows, entry.retention_days, as_of))
+            report.append(entry)
+        return FolderHygieneReport(
+            folders=report, destination_folder=settings.folder_hygiene_destination, as_of=as_of
+        )
+    
+    async def purge_folder_hygiene(
+        self,
+        user_id: int,
+        folders: Optional[List[str]] = None,
+        dry_run: bool = False,
+        now: Optional[datetime] = None
+    ) -> FolderHygieneOutcome:
+        """Move category folder emails past their retention to settings.folder_hygiene_destination.
+        
+        Candidates are selected with purge_candidates from the stored
+        emails of each folder and moved with move_emails. Purged emails get
+        the destination folder on their row and are recorded as one move
+        batch that revert_move_batch can undo; emails the mail client did
+        not move keep their row as it was.
+        
+        Args:
+            user_id: Owner of the stored emails
+            folders: Category folders to purge (defaults to every category
+                folder not covered by settings.excluded_folders)
+            dry_run: Only select the candidates
+            now: Reference time (defaults to now in the user's time zone)
+            
+        Raises:
+            InputValidationError: If a requested folder is not a category folder
+            ExcludedFolderError: If settings.excluded_folders covers a
+                requested folder or the destination folder
+        """
+        mapped = await self._run(hygiene_folders, self.db)
+        if folders is None:
+            selected = [folder for folder in mapped if not is_excluded_folder(folder)]
+        else:
+            by_name = {folder.lower(): folder for folder in mapped}
+            unknown = [folder for folder in folders if folder.lower() not in by_name]
+            if unknown:
+                raise InputValidationError(f"Not category folders: {', '.join(unknown)}")
+            selected = list(dict.fromkeys(by_name[folder.lower()] for folder in folders))
+            for folder in selected:
+                check_folder_in_scope(folder, "Cannot purge")
+        destination = settings.folder_hygiene_destination
+        check_folder_in_scope(destination, "Cannot move emails into")
+        
+        emails = await self._get_folder_emails(user_id, selected)
+        as_of = user_clock(self.db, user_id, now).now()
+        outcome = FolderHygieneOutcome(destination_folder=destination)
+        for folder in selected:
+            outcome.candidates.extend(
+                purge_candidates(emails.get(folder.lower(), []), folder_retention(folder), as_of)
+            )
+        outcome.candidates = outcome.candidates[:FOLDER_HYGIENE_LIMIT]
+        if dry_run or not outcome.candidates:
+            return outcome
+        
+        moved = await self.move_emails([email["id"] for email in outcome.candidates], destination)
+        for email in outcome.candidates:
+            (outcome.purged if moved.get(email["id"]) else outcome.failed).append(email["id"])
+        if not outcome.purged:
+            return outcome
+        
+        origins = {email["id"]: email["folder"] for email in outcome.candidates if moved.get(email["id"])}
+        
+        def _purge_sync():
+            with self.db.get_connection() as conn:
+                conn.executemany(
+                    "UPDATE emails SET folder = ? WHERE id = ? AND user_id = ?",
+                    [(destination, email_id, user_id) for email_id in outcome.purged]
+                )
+                conn.commit()
+            return self.move_batches.record(user_id, OPERATION_FOLDER_HYGIENE, destination, origins)
+        
+        outcome.batch_id = await self._run(_purge_sync)
+        return outcome
+    
+    async def get_sender_domains(self, user_id: int, limit: int = 50) -> SenderDomainReport:
+        """Per sender domain totals of the user's stored emails (see summarize_domains).
+        
+        Args:
+            user_id: Owner of the stored emails
+            limit: Most domains returned, largest first
+        """
+        where, params = self._visible_filter(user_id)
+        
+        def _get_rows_sync():
+            with self.db.get_connection() as conn:
+                return [
+                    dict(row) for row in conn.execute(
+                        f"SELECT sender, is_read, category, ai_category FROM emails WHERE {where}", params
+                    )
+                ]
+        
+        domains = summarize_domains(await self._run(_get_rows_sync))
+        return SenderDomainReport(domains=domains[:limit], total=len(domains))
+    
+    async def classify_domain(
+        self,
+        user_id: int,
+        domain: str,
+        category: str,
+        apply_to_outlook: bool = False,
+        dry_run: bool = False
+    ) -> DomainCategoryOutcome:
+        """Give every unclassified stored email from a sender domain a category.
+        
+        Emails from the domain or its subdomains without a category are
+        corrected with update_classifications, recorded in the history
+        under the source domain. With apply_to_outlook they are also filed
+        in the mail client: moved into the category's folder as one move
+        batch that revert_move_batch can undo, or, for a category without a
+        folder (or filed in the inbox), given the category with
+        apply_category. Emails the mail client refused keep their row's
+        folder; their stored category stays corrected.
+        
+        Args:
+            user_id: Owner of the stored emails
+            domain: Sender domain, e.g. contoso.com
+            category: Category to give the emails
+            apply_to_outlook: Also file the emails in the mail client
+            dry_run: Only select the candidates
+            
+        Raises:
+            ExcludedFolderError: If settings.excluded_folders covers the category's folder
+        """
+        where, params = self._visible_filter(user_id)
+        
+        def _get_candidates_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id, sender, COALESCE(NULLIF(folder, ''), 'Inbox') AS folder FROM emails
+                    WHERE {where} AND COALESCE(category, '') = ''
+                    ORDER BY received_date DESC, id
+                    """,
+                    params
+                ).fetchall()
+            return [dict(row) for row in rows if from_domain(row["sender"], domain)]
+        
+        emails = await self._run(_get_candidates_sync)
+        outcome = DomainCategoryOutcome(candidates=[email["id"] for email in emails])
+        folder = None
+        if apply_to_outlook:
+            folder = (await self._run(category_folders, self.db)).get(category)
+            if folder and folder.strip().lower() != "inbox":
+                check_folder_in_scope(folder, "Cannot move emails into")
+            else:
+                folder = None
+        if dry_run or not emails:
+            return outcome
+        
+        result = await self.update_classifications(
+            user_id, [(email["id"], category) for email in emails], source="domain"
+        )
+        emails = [email for email in emails if email["id"] not in result.missing]
+        outcome.classified = [email["id"] for email in emails]
+        if not apply_to_outlook or not emails:
+            return outcome
+        
+        if folder is None:
+            for email in emails:
+                if not await self.apply_category(email["id"], category, user_id):
+                    outcome.failed.append(email["id"])
+            return outcome
+        
+        to_move = [email for email in emails if email["folder"].lower() != folder.lower()]
+        if not to_move:
+            return outcome
+        moved = await self.move_emails([email["id"] for email in to_move], folder)
+        outcome.failed = [email["id"] for email in to_move if not moved.get(email["id"])]
+        origins = {email["id"]: email["folder"] for email in to_move if moved.get(email["id"])}
+        if not origins:
+            return outcome
+        
+        def _file_sync():
+            with self.db.get_connection() as conn:
+                conn.executemany(
+                    "UPDATE emails SET folder = ? WHERE id = ? AND user_id = ?",
+                    [(folder, email_id, user_id) for email_id in origins]
+                )
+                conn.commit()
+            return self.move_batches.record(user_id, OPERATION_DOMAIN_CATEGORY, folder, origins)
+        
+        outcome.destination_folder = folder
+        outcome.batch_id = await self._run(_file_sync)
+        return outcome
+    
+    async def add_note(self, email_id: str, user_id: int, text: str) -> Dict[str, Any]:
+        """Add a note to a stored email.
+        
+        Raises:
+            LookupError: If the user has no such stored email
+        """
+        note = await self._run(self.notes.add, email_id, user_id, text)
+        if note is None:
+            raise LookupError(f"Email {email_id} not found")
+        return note
+    
+    async def get_notes(self, email_id: str, user_id: int) -> List[Dict[str, Any]]:
+        """Get an email's notes, newest first."""
+        return await self._run(self.notes.list, email_id, user_id)
+    
+    async def delete_note(self, email_id: str, note_id: int, user_id: int) -> None:
+        """Delete a note from an email.
+        
+        Raises:
+            LookupError: If the email has no such note of the user's
+        """
+        if not await self._run(self.notes.delete, note_id, email_id, user_id):
+            raise LookupError(f"Note {note_id} of email {email_id} not found")
+    
+    async def get_note_counts(self, user_id: int, email_ids: Iterable[str]) -> Dict[str, int]:
+        """Count the notes on several emails; emails without notes are left out."""
+        return await self._run(self.notes.counts, user_id, list(email_ids))
+    
+    async def get_holistic_emails(self, user_id: int, limit: int = 50) -> List[Dict[str, Any]]:
+        """Get the user's newest stored inbox emails for holistic analysis.
+        
+        Emails of muted conversations are left out.
+        
+        Returns:
+            id, subject, sender, received_date, content, and is_protected of each email
+        """
+        where, params = self._visible_filter(user_id, include_muted=False)
+        
+        def _get_holistic_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id, subject, sender, received_date, content, is_protected
+                    FROM emails
+                    WHERE {where} AND LOWER(COALESCE(NULLIF(folder, ''), 'Inbox')) = 'inbox'
+                    ORDER BY received_date DESC LIMIT ?
+                    """,
+                    [*params, limit]
+                ).fetchall()
+            return [dict(row) for row in rows]
+        
+        return await self._run(_get_holistic_sync)
+    
+    async def record_blocking_items(
+        self,
+        user_id: int,
+        actions: List[Dict[str, Any]],
+        analyzed_email_ids: List[str]
+    ) -> int:
+        """Keep the blocking actions of a holistic run (see backend.services.blocking_items).
+        
+        Returns:
+            Number of open blocking items after the run
+        """
+        return await self._run(self.blocking.record_analysis, user_id, actions, analyzed_email_ids)
+    
+    async def get_blocking_items(self, user_id: int, now: Optional[datetime] = None) -> List[Dict[str, Any]]:
+        """Get the user's open blocking items, oldest first, with their email and latest task."""
+        as_of = user_clock(self.db, user_id, now).now()
+        return await self._run(self.blocking.list_open, user_id, as_of)
+    
+    async def count_blocking_items(self, user_id: int) -> int:
+        """Count the user's open blocking items."""
+        return await self._run(self.blocking.open_count, user_id)
+    
+    async def dismiss_blocking_item(
+        self,
+        item_id: int,
+        user_id: int,
+        reason: str,
+        note: Optional[str] = None
+    ) -> None:
+        """Dismiss a blocking item; later holistic runs get the reason as a correction.
+        
+        Raises:
+            LookupError: If the user has no such open item
+        """
+        if not await self._run(self.blocking.dismiss, item_id, user_id, reason, note):
+            raise LookupError(f"Blocking item {item_id} not found")
+    
+    async def get_blocking_corrections(self, user_id: int) -> List[str]:
+        """Get the user's latest blocking item dismissals as holistic analysis corrections."""
+        return await self._run(self.blocking.corrections, user_id)
+    
+    async def record_holistic_analysis(
+        self,
+        user_id: int,
+        analysis: Dict[str, List[Dict[str, Any]]],
+        analyzed_email_ids: List[str]
+    ) -> int:
+        """Keep a holistic run so its cleanups can be applied (see backend.services.holistic_runs).
+        
+        Returns:
+            ID of the stored run
+        """
+        return await self._run(self.holistic_runs.record, user_id, analysis, analyzed_email_ids)
+    
+    async def apply_holistic_analysis(
+        self,
+        analysis_id: int,
+        user_id: int,
+        archive_superseded: bool = True,
+        merge_duplicates: bool = True,
+        complete_expired_tasks: bool = True,
+        task_status: str = "completed",
+        dry_run: bool = False
+    ) -> HolisticApplyOutcome:
+        """Apply the selected cleanups of a stored holistic run.
+        
+        Superseded emails and the non-canonical emails of duplicate groups
+        still stored outside settings.archive_folder are moved there with
+        move_emails; open tasks made from expired items get task_status.
+        Both are recorded as one move batch that revert_move_batch undoes,
+        and the whole apply as one apply_holistic_analysis activity entry.
+        Emails the mail client did not move keep their row as it was.
+        
+        Args:
+            analysis_id: Stored run, from record_holistic_analysis
+            user_id: Owner of the run
+            archive_superseded: Archive emails superseded by later ones
+            merge_duplicates: Archive duplicates other than the one kept
+            complete_expired_tasks: Close the open tasks of expired items
+            task_status: completed or cancelled
+            dry_run: Only select the candidates
+        
+        Raises:
+            LookupError: If the user has no such run
+            InputValidationError: If task_status is not completed or cancelled
+            ExcludedFolderError: If settings.excluded_folders covers the archive folder
+        """
+        if task_status not in ("completed", "cancelled"):
+            raise InputValidationError(f"Expired item tasks can be completed or cancelled, not {task_status}")
+        run = await self._run(self.holistic_runs.get, analysis_id, user_id)
+        if run is None:
+            raise LookupError(f"Holistic analysis {analysis_id} not found")
+        plan = cleanup_plan(run, archive_superseded, merge_duplicates, complete_expired_tasks)
+        
+        folder = settings.archive_folder
+        outcome = HolisticApplyOutcome(task_status=task_status)
+        stored = await self.get_stored_emails(list(plan.archive), user_id)
+        for email_id, reason in plan.archive.items():
+            email = stored.get(email_id)
+            if email is None:
+                outcome.missing.append(email_id)
+            elif (email.get("folder") or "Inbox").lower() != folder.lower():
+                outcome.archive_candidates.append({
+                    "id": email_id, "subject": email.get("subject"), "sender": email.get("sender"),
+                    "folder": email.get("folder") or "Inbox", "reason": reason
+                })
+        
+        def _get_tasks_sync():
+            if not plan.expired:
+                return []
+            placeholders = ", ".join("?" for _ in plan.expired)
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id AS task_id, email_id, title, status FROM tasks
+                    WHERE user_id = ? AND email_id IN ({placeholders}) AND status IN ('pending', 'in_progress')
+                    ORDER BY id
+                    """,
+                    [user_id, *plan.expired]
+                ).fetchall()
+            return [dict(row) for row in rows]
+        
+        outcome.task_candidates = await self._run(_get_tasks_sync)
+        if dry_run or not (outcome.archive_candidates or outcome.task_candidates):
+            return outcome
+        
+        started = time.perf_counter()
+        moved: Dict[str, bool] = {}
+        if outcome.archive_candidates:
+            moved = await self.move_emails([email["id"] for email in outcome.archive_candidates], folder)
+        for email in outcome.archive_candidates:
+            (outcome.archived if moved.get(email["id"]) else outcome.failed).append(email["id"])
+        origins = {email["id"]: email["folder"] for email in outcome.archive_candidates if moved.get(email["id"])}
+        
+        def _apply_sync():
+            task_changes = {}
+            with self.db.get_connection() as conn:
+                conn.executemany(
+                    "UPDATE emails SET folder = ? WHERE id = ? AND user_id = ?",
+                    [(folder, email_id, user_id) for email_id in outcome.archived]
+                )
+                for task in outcome.task_candidates:
+                    closed = conn.execute(
+                        """
+                        UPDATE tasks SET status = ?, updated_at = CURRENT_TIMESTAMP
+                        WHERE id = ? AND user_id = ? AND status = ?
+                        """,
+                        (task_status, task["task_id"], user_id, task["status"])
+                    ).rowcount
+                    if closed:
+                        task_changes[task["task_id"]] = {"from": task["status"], "to": task_status}
+                conn.commit()
+            outcome.tasks_closed = list(task_changes)
+            if not (origins or task_changes):
+                return None
+            return self.move_batches.record(user_id, OPERATION_HOLISTIC_CLEANUP, folder, origins, task_changes)
+        
+        outcome.batch_id = await self._run(_apply_sync)
+        await self._run(
+            self.activity.record,
+            "apply_holistic_analysis",
+            None,
+            {
+                "analysis_id": analysis_id, "batch_id": outcome.batch_id, "archived": outcome.archived,
+                "failed": outcome.failed, "tasks_closed": outcome.tasks_closed, "task_status": task_status
+            },
+            RESULT_FAILED if outcome.failed else RESULT_SUCCESS,
+            (time.perf_counter() - started) * 1000,
+            current_request_id()
+        )
+        return outcome
+    
+    async def get_classification_history(self, email_id: str, user_id: int) -> List[Dict[str, Any]]:
+        """Get an email's category changes, oldest first."""
+        def _get_history_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    """
+                    SELECT previous_category, category, source, created_at
+                    FROM classification_history
+                    WHERE email_id = ? AND user_id = ?
+                    ORDER BY created_at, id
+                    """,
+                    (email_id, user_id)
+                ).fetchall()
+            return [dict(row) for row in rows]
+        
+        return await self._run(_get_history_sync)
+    
+    async def get_sender_history(self, sender: str, user_id: int) -> SenderHistory:
+        """Count a sender's stored emails and the outcomes they led to."""
+        where, params = self._visible_filter(user_id)
+        
+        def _get_history_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    f"""
+                    SELECT COUNT(*) AS total,
+                           TOTAL(category = 'spam_to_delete') AS spam,
+                           TOTAL(is_read) AS read,
+                           TOTAL(EXISTS (
+                               SELECT 1 FROM tasks
+                               WHERE tasks.email_id = emails.id AND tasks.status = 'completed'
+                           )) AS completed_tasks
+                    FROM emails
+                    WHERE sender = ? AND {where}
+                    """,
+                    [sender, *params]
+                ).fetchone()
+            return SenderHistory(
+                total=row["total"],
+                spam=int(row["spam"]),
+                read=int(row["read"]),
+                completed_tasks=int(row["completed_tasks"])
+            )
+        
+        return await self._run(_get_history_sync)
+    
+    async def get_sender_move_history(self, sender: str, user_id: int) -> Dict[str, int]:
+        """Count where the user has moved a sender's stored emails.
+        
+        Successful move_email entries of the activity log are matched to
+        the user's stored emails from the sender.
+        
+        Returns:
+            Destination folder -> number of moves there
+        """
+        where, params = self._visible_filter(user_id)
+        
+        def _get_move_history_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT parameters FROM outlook_activity
+                    WHERE operation = 'move_email' AND result = ?
+                      AND email_id IN (SELECT id FROM emails WHERE sender = ? AND {where})
+                    """,
+                    [RESULT_SUCCESS, sender, *params]
+                ).fetchall()
+            history: Dict[str, int] = {}
+            for row in rows:
+                folder = json.loads(row["parameters"]).get("destination_folder")
+                if folder:
+                    history[folder] = history.get(folder, 0) + 1
+            return history
+        
+        return await self._run(_get_move_history_sync)
+    
+    async def get_sender_corrections(self, sender: str, user_id: int) -> List[str]:
+        """List the categories the user has corrected a sender's stored emails to.
+        
+        Returns:
+            One category per classification_history entry, newest first
+        """
+        where, params = self._visible_filter(user_id)
+        
+        def _get_corrections_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT category FROM classification_history
+                    WHERE user_id = ?
+                      AND email_id IN (SELECT id FROM emails WHERE sender = ? AND {where})
+                    ORDER BY created_at DESC, id DESC
+                    """,
+                    [user_id, sender, *params]
+                ).fetchall()
+            return [row["category"] for row in rows]
+        
+        return await self._run(_get_corrections_sync)
+    
+    async def get_human_labels(self, user_id: int) -> Dict[str, str]:
+        """Map each stored email a user corrected or confirmed to its category.
+        
+        See HUMAN_LABEL_SOURCE_SQL for what counts as corrected or confirmed.
+        """
+        where, params = self._visible_filter(user_id)
+        
+        def _get_labels_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"SELECT id, category FROM emails WHERE {where} AND ({HUMAN_LABEL_SOURCE_SQL}) IS NOT NULL",
+                    params
+                ).fetchall()
+            return {row["id"]: row["category"] for row in rows}
+        
+        return await self._run(_get_labels_sync)
+    
+    async def get_labeled_emails(
+        self,
+        user_id: int,
+        after_id: Optional[str] = None,
+        limit: int = 200
+    ) -> List[Dict[str, Any]]:
+        """Read a page of the stored emails a user corrected or confirmed, in ID order.
+        
+        Args:
+            user_id: Owner of the stored emails
+            after_id: Last ID of the previous page
+            limit: Most emails to return
+            
+        Returns:
+            Rows with id, subject, sender, content, category, ai_category,
+            ai_confidence, ai_prompt_version, and label_source ("corrected"
+            or "confirmed")
+        """
+        where, params = self._visible_filter(user_id)
+        if after_id is not None:
+            where += " AND id > ?"
+            params.append(after_id)
+        
+        def _get_labeled_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT * FROM (
+                        SELECT id, subject, sender, content, category, ai_category, ai_confidence,
+                               ai_prompt_version, {HUMAN_LABEL_SOURCE_SQL} AS label_source
+                        FROM emails WHERE {where}
+                    )
+                    WHERE label_source IS NOT NULL
+                    ORDER BY id
+                    LIMIT ?
+                    """,
+                    [*params, limit]
+                ).fetchall()
+            return [dict(row) for row in rows]
+        
+        return await self._run(_get_labeled_sync)
+    
+    async def get_sender_reputation(self, sender: str, user_id: int) -> SenderReputation:
+        """Get a sender's reputation score, cached for reputation_cache_ttl_seconds.
+        
+        Args:
+            sender: Sender address as stored on emails
+            user_id: Owner of the stored emails
+            
+        Returns:
+            Score and component breakdown
+        """
+        key = (user_id, sender)
+        cached = reputation_cache.get(key)
+        if cached is not None:
+            return cached
+        
+        history = await self.get_sender_history(sender, user_id)
+        reputation = score_sender(sender, history, ReputationWeights.from_settings(settings))
+        reputation_cache.set(key, reputation)
+        return reputation
+    
+    async def get_thread_messages(self, user_id: int) -> List[Dict[str, Any]]:
+        """Get the fields of a user's stored emails needed for thread analysis."""
+        where, params = self._visible_filter(user_id)
+        
+        def _get_thread_messages_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT id, subject, sender, recipient, content, received_date, conversation_id, is_protected
+                    FROM emails WHERE {where}
+                    """,
+                    params
+                ).fetchall()
+            return [dict(row) for row in rows]
+        
+        return await self._run(_get_thread_messages_sync)
+    
+    def _canonical_conversation(self, conn, conversation_id: str, user_id: int) -> Optional[str]:
+        """Find the conversation a stored conversation was merged into (itself if never merged)."""
+        row = conn.execute(
+            """
+            SELECT conversation_id FROM emails
+            WHERE user_id = ? AND (original_conversation_id = ? OR conversation_id = ?)
+            ORDER BY original_conversation_id = ? DESC
+            LIMIT 1
+            """,
+            (user_id, conversation_id, conversation_id, conversation_id)
+        ).fetchone()
+        return row["conversation_id"] if row else None
+
+    def _merged_members(self, conn, canonical: str, user_id: int) -> List[str]:
+        """List the provider conversation IDs merged into a canonical conversation."""
+        rows = conn.execute(
+            """
+            SELECT DISTINCT original_conversation_id FROM emails
+            WHERE user_id = ? AND conversation_id = ? AND original_conversation_id != ''
+            ORDER BY original_conversation_id
+            """,
+            (user_id, canonical)
+        ).fetchall()
+        return [row[0] for row in rows]
+
+    async def get_conversation_group(self, conversation_id: str, user_id: int) -> Tuple[str, List[str]]:
+        """Resolve a conversation to its merged group.
+
+        Returns:
+            The group's canonical conversation ID and its member conversation
+            IDs, canonical first; ([conversation_id], ...) when not merged
+        """
+        def _get_group_sync():
+            with self.db.get_connection() as conn:
+                canonical = self._canonical_conversation(conn, conversation_id, user_id) or conversation_id
+                members = self._merged_members(conn, canonical, user_id)
+            others = [member for member in members if member != canonical]
+            return canonical, [canonical, *others]
+
+        return await self._run(_get_group_sync)
+
+    async def merge_conversations(
+        self,
+        user_id: int,
+        conversation_ids: Optional[List[str]] = None,
+        email_ids: Optional[List[str]] = None
+    ) -> Dict[str, Any]:
+        """Merge stored conversations that the provider threaded separately.
+
+        Every row of the affected conversations gets the canonical
+        conversation_id (that of the oldest email) and keeps its provider
+        value in original_conversation_id. Conversations already merged are
+        folded in whole.
+
+        Args:
+            user_id: Owner of the stored emails
+            conversation_ids: Conversations to merge
+            email_ids: Emails whose conversations to merge
+
+        Returns:
+            Dict with conversation_id (canonical), merged_conversation_ids,
+            and emails_updated
+
+        Raises:
+            LookupError: If an email or conversation is not stored
+            InputValidationError: If fewer than two distinct conversations are given
+        """
+        def _merge_sync():
+            with self.db.get_connection() as conn:
+                groups: List[str] = []
+                loose_emails: List[str] = []
+                for email_id in email_ids or []:
+                    row = conn.execute(
+                        "SELECT conversation_id FROM emails WHERE id = ? AND user_id = ?",
+                        (email_id, user_id)
+                    ).fetchone()
+                    if row is None:
+                        raise LookupError(f"Email '{email_id}' not found")
+                    if row["conversation_id"]:
+                        groups.append(row["conversation_id"])
+                    else:
+                        loose_emails.append(email_id)
+                for conversation_id in conversation_ids or []:
+                    canonical = self._canonical_conversation(conn, conversation_id, user_id)
+                    if canonical is None:
+                        raise LookupError(f"Conversation '{conversation_id}' has no stored emails")
+                    groups.append(canonical)
+
+                groups = list(dict.fromkeys(groups))
+                loose_emails = list(dict.fromkeys(loose_emails))
+                if len(groups) + len(loose_emails) < 2:
+                    raise InputValidationError("At least two different conversations are required to merge")
+
+                group_marks = ", ".join("?" for _ in groups) or "NULL"
+                email_marks = ", ".join("?" for _ in loose_emails) or "NULL"
+                selection = (
+                    f"user_id = ? AND (conversation_id IN ({group_marks}) OR id IN ({email_marks}))"
+                )
+                selection_params = [user_id, *groups, *loose_emails]
+
+                oldest = conn.execute(
+                    f"""
+                    SELECT id, conversation_id FROM emails WHERE {selection}
+                    ORDER BY received_date IS NULL, received_date, id LIMIT 1
+                    """,
+                    selection_params
+                ).fetchone()
+                canonical = oldest["conversation_id"] or oldest["id"]
+
+                cursor = conn.execute(
+                    f"""
+                    UPDATE emails
+                    SET original_conversation_id = COALESCE(original_conversation_id, conversation_id, ''),
+                        conversation_id = ?
+                    WHERE {selection}
+                    """,
+                    [canonical, *selection_params]
+                )
+                members = self._merged_members(conn, canonical, user_id)
+                conn.commit()
+            return {
+                "conversation_id": canonical,
+                "merged_conversation_ids": members,
+                "emails_updated": cursor.rowcount,
+            }
+
+        return await self._run(_merge_sync)
+
+    async def unmerge_conversation(self, conversation_id: str, user_id: int) -> Dict[str, Any]:
+        """Split a merged conversation back into its original conversations.
+
+        Args:
+            conversation_id: The merged conversation, or any of its members
+            user_id: Owner of the stored emails
+
+        Returns:
+            Dict with conversation_id (the former canonical ID),
+            restored_conversation_ids, and emails_updated (0 if not merged)
+        """
+        def _unmerge_sync():
+            with self.db.get_connection() as conn:
+                canonical = self._canonical_conversation(conn, conversation_id, user_id) or conversation_id
+                restored = self._merged_members(conn, canonical, user_id)
+                cursor = conn.execute(
+                    """
+                    UPDATE emails
+                    SET conversation_id = NULLIF(original_conversation_id, ''),
+                        original_conversation_id = NULL
+                    WHERE user_id = ? AND conversation_id = ? AND original_conversation_id IS NOT NULL
+                    """,
+                    (user_id, canonical)
+                )
+                conn.commit()
+            return {
+                "conversation_id": canonical,
+                "restored_conversation_ids": restored,
+                "emails_updated": cursor.rowcount,
+            }
+
+        return await self._run(_unmerge_sync)
+
+    async def mute_conversation(self, conversation_id: str, user_id: int) -> Dict[str, Any]:
+        """Mute a conversation and lower the priority of its open tasks.
+        
+        Emails of a muted conversation are classified MUTED_CATEGORY without
+        the AI and left out of listings and counters. A conversation merged
+        into another is muted as the conversation it was merged into. Open
+        tasks made from the conversation's stored emails drop to
+        MUTED_TASK_PRIORITY with a note saying why.
+        
+        Args:
+            conversation_id: Conversation to mute (any merged member's ID works)
+            user_id: Owner of the conversation
+            
+        Returns:
+            Dict with conversation_id (as muted), muted (True), and
+            tasks_downgraded
+        """
+        def _mute_sync():
+            with self.db.get_connection() as conn:
+                canonical = self._canonical_conversation(conn, conversation_id, user_id) or conversation_id
+                conn.execute(
+                    "INSERT OR IGNORE INTO muted_conversations (user_id, conversation_id) VALUES (?, ?)",
+                    (user_id, canonical)
+                )
+                cursor = conn.execute(
+                    """
+                    UPDATE tasks
+                    SET description = COALESCE(NULLIF(description, '') || char(10) || char(10), '') ||
+                                      'Priority lowered from ' || COALESCE(priority, 'medium') ||
+                                      ' because the conversation was muted.',
+                        priority = ?,
+                        updated_at = CURRENT_TIMESTAMP
+                    WHERE user_id = ? AND status IN ('pending', 'in_progress')
+                      AND COALESCE(priority, 'medium') != ?
+                      AND email_id IN (
+                          SELECT id FROM emails
+                          WHERE user_id = ? AND (conversation_id = ? OR original_conversation_id = ?)
+                      )
+                    """,
+                    (MUTED_TASK_PRIORITY, user_id, MUTED_TASK_PRIORITY, user_id, canonical, canonical)
+                )
+                conn.commit()
+            return {"conversation_id": canonical, "muted": True, "tasks_downgraded": cursor.rowcount}
+        
+        return await self._run(_mute_sync)
+    
+    async def unmute_conversation(self, conversation_id: str, user_id: int) -> bool:
+        """Unmute a conversation; lowered task priorities are left as they are.
+        
+        Args:
+            conversation_id: Muted conversation (any merged member's ID works)
+            user_id: Owner of the conversation
+            
+        Returns:
+            True if the conversation was muted
+        """
+        def _unmute_sync():
+            with self.db.get_connection() as conn:
+                canonical = self._canonical_conversation(conn, conversation_id, user_id) or conversation_id
+                cursor = conn.execute(
+                    "DELETE FROM muted_conversations WHERE user_id = ? AND conversation_id IN (?, ?)",
+                    (user_id, conversation_id, canonical)
+                )
+                conn.commit()
+            return cursor.rowcount > 0
+        
+        return await self._run(_unmute_sync)
+    
+    async def get_muted_conversations(self, user_id: int, conversation_ids: Iterable[str]) -> Set[str]:
+        """Find which of the given conversations are muted.
+        
+        A conversation counts as muted when it was muted itself or was
+        merged into a muted conversation, so provider emails still carrying
+        their original conversation ID match.
+        
+        Args:
+            user_id: Owner of the conversations
+            conversation_ids: Provider or stored conversation IDs
+            
+        Returns:
+            The muted ones among conversation_ids
+        """
+        wanted = sorted({conversation_id for conversation_id in conversation_ids if conversation_id})
+        if not wanted:
+            return set()
+        placeholders = ", ".join("?" for _ in wanted)
+        
+        def _get_muted_sync():
+            with self.db.get_connection() as conn:
+                muted = {
+                    row["conversation_id"] for row in conn.execute(
+                        "SELECT conversation_id FROM muted_conversations WHERE user_id = ?", (user_id,)
+                    )
+                }
+                merged = conn.execute(
+                    f"""
+                    SELECT DISTINCT original_conversation_id, conversation_id FROM emails
+                    WHERE user_id = ? AND original_conversation_id IN ({placeholders})
+                    """,
+                    [user_id, *wanted]
+                ).fetchall()
+            canonical = {row["original_conversation_id"]: row["conversation_id"] for row in merged}
+            return {
+                conversation_id for conversation_id in wanted
+                if conversation_id in muted or canonical.get(conversation_id) in muted
+            }
+        
+        return await self._run(_get_muted_sync)
+    
+    async def set_awaiting_reply(self, user_id: int, email_ids: List[str]) -> None:
+        """Flag exactly these emails as awaiting a reply and clear the rest.
+        
+        Emails that stay flagged keep their original awaiting_reply_detected_at.
+        """
+        def _set_awaiting_reply_sync():
+            placeholders = ", ".join("?" for _ in email_ids)
+            # "id NOT IN (NULL)" never matches, so an empty list needs no filter
+            keep = f"AND id NOT IN ({placeholders})" if email_ids else ""
+            with self.db.get_connection() as conn:
+                conn.execute(
+                    f"""
+                    UPDATE emails SET awaiting_reply = 0, awaiting_reply_detected_at = NULL
+                    WHERE user_id = ? AND awaiting_reply = 1 {keep}
+                    """,
+                    [user_id, *email_ids]
+                )
+                if email_ids:
+                    conn.execute(
+                        f"""
+                        UPDATE emails
+                        SET awaiting_reply = 1,
+                            awaiting_reply_detected_at = COALESCE(awaiting_reply_detected_at, ?)
+                        WHERE user_id = ? AND id IN ({placeholders})
+                        """,
+                        [datetime.utcnow(), user_id, *email_ids]
+                    )
+                conn.commit()
+        
+        await self._run(_set_awaiting_reply_sync)
+    
+    async def get_awaiting_reply(
+        self,
+        user_id: int,
+        limit: int = 50,
+        offset: int = 0
+    ) -> Tuple[List[Dict[str, Any]], int]:
+        """Get stored emails flagged as awaiting a reply, oldest first.
+        
+        Returns:
+            The page of emails and the total number flagged
+        """
+        return await self.search_stored_emails(
+            user_id, {"awaiting_reply": True}, sort="received_asc", limit=limit, offset=offset
+        )
+    
+    async def search_stored_emails(
+        self,
+        user_id: int,
+        filters: Dict[str, Any],
+        sort: str = DEFAULT_SORT,
+        limit: int = 50,
+        offset: int = 0,
+        columns: Optional[List[str]] = None,
+        query: Optional[str] = None,
+        my_address: Optional[str] = None,
+        include_muted: bool = True
+    ) -> Tuple[List[Dict[str, Any]], int]:
+        """Search a user's stored emails.
+        
+        With a query, only emails whose subject, content, sender, or one of
+        whose notes contain it are returned, each with search_meta
+        describing the match. Every email carries its note_count unless
+        columns leaves it out.
+        
+        Args:
+            user_id: Owner of the stored emails
+            filters: Filters already checked by validate_email_filters
+            sort: One of EMAIL_SORTS
+            limit: Page size
+            offset: Emails to skip
+            columns: STORED_EMAIL_FIELDS and note_count to select (default
+                all), so list views can skip the body columns
+            query: Free text already checked by validate_query
+            my_address: The user's address, needed by the to_me filter
+            include_muted: Also return emails of muted conversations
+            
+        Returns:
+            The page of emails and the total number matching
+        """
+        where, params = self._visible_filter(user_id, include_muted=include_muted)
+        clause, filter_params = build_filter_clause(
+            filters, my_address=my_address, to_me_recipient_limit=settings.to_me_recipient_limit
+        )
+        where += clause
+        params += filter_params
+        if query:
+            clause, query_params = build_search_clause(query)
+            where += clause
+            params += query_params
+        order_by = EMAIL_SORTS[sort]
+        selected = stored_email_columns(columns)
+        if query:
+            selected += f", {NOTES_TEXT_SQL} AS notes"
+        
+        def _search_sync():
+            with self.db.get_connection() as conn:
+                total = conn.execute(f"SELECT COUNT(*) FROM emails WHERE {where}", params).fetchone()[0]
+                rows = conn.execute(
+                    f"SELECT {selected} FROM emails WHERE {where} ORDER BY {order_by} LIMIT ? OFFSET ?",
+                    [*params, limit, offset]
+                ).fetchall()
+            if not query:
+                return [present_stored_email(row) for row in rows], total
+            results = []
+            for row in rows:
+                email = present_stored_email(row)
+                email.pop("notes")
+                results.append({**email, "search_meta": search_meta(dict(row), query)})
+            return results, total
+        
+        return await self._run(_search_sync)
+    
+    async def get_emails_grouped_by_conversation(
+        self,
+        user_id: int,
+        filters: Dict[str, Any],
+        limit: int = 50,
+        offset: int = 0,
+        columns: Optional[List[str]] = None,
+        my_address: Optional[str] = None,
+        include_muted: bool = True
+    ) -> Tuple[List[Dict[str, Any]], int]:
+        """List a user's stored conversations, latest activity first.
+        
+        Filters select emails first, so each conversation is summarized
+        from its matching emails only. Every entry carries the fields of
+        the conversation's latest email plus:
+        
+        - conversation_count: matching emails in the conversation
+        - unread_in_thread: how many of them are unread
+        - participants: addresses that sent or received them, in order of appearance
+        - has_actionable: whether any of them is in ACTIONABLE_CATEGORIES
+        - note_count: notes on the latest email, unless columns leaves it out
+        
+        Emails without a conversation_id are conversations of one.
+        
+        Args:
+            user_id: Owner of the stored emails
+            filters: Filters already checked by validate_email_filters
+            limit: Conversations per page
+            offset: Conversations to skip
+            columns: STORED_EMAIL_FIELDS and note_count of the latest email
+                to select (default all); the conversation fields are always included
+            my_address: The user's address, needed by the to_me filter
+            include_muted: Also return muted conversations
+            
+        Returns:
+            The page of conversations and the total number of conversations
+        """
+        where, params = self._visible_filter(user_id, include_muted=include_muted)
+        clause, filter_params = build_filter_clause(
+            filters, my_address=my_address, to_me_recipient_limit=settings.to_me_recipient_limit
+        )
+        where += clause
+        params += filter_params
+        group_columns = "thread_key, conversation_count, unread_in_thread, has_actionable"
+        selected = f"{', '.join(columns)}, {group_columns}" if columns else "*"
+        actionable = ", ".join("?" for _ in ACTIONABLE_CATEGORIES)
+        
+        def _grouped_sync():
+            with self.db.get_connection() as conn:
+                total = conn.execute(
+                    f"SELECT COUNT(DISTINCT {THREAD_KEY_SQL}) FROM emails WHERE {where}", params
+                ).fetchone()[0]
+                rows = conn.execute(
+                    f"""
+                    WITH threaded AS (
+                        SELECT {stored_email_columns()}, {THREAD_KEY_SQL} AS thread_key,
+                               ROW_NUMBER() OVER (thread ORDER BY received_date DESC, id) AS thread_rank,
+                               COUNT(*) OVER thread AS conversation_count,
+                               COUNT(*) OVER thread - TOTAL(is_read) OVER thread AS unread_in_thread,
+                               MAX(category IN ({actionable})) OVER thread AS has_actionable
+                        FROM emails
+                        WHERE {where}
+                        WINDOW thread AS (PARTITION BY {THREAD_KEY_SQL})
+                    )
+                    SELECT {selected} FROM threaded
+                    WHERE thread_rank = 1
+                    ORDER BY received_date DESC, id
+                    LIMIT ? OFFSET ?
+                    """,
+                    [*ACTIONABLE_CATEGORIES, *params, limit, offset]
+                ).fetchall()
+                keys = [row["thread_key"] for row in rows]
+                members = conn.execute(
+                    f"""
+                    SELECT {THREAD_KEY_SQL} AS thread_key, sender, to_recipients, cc_recipients FROM emails
+                    WHERE {where} AND {THREAD_KEY_SQL} IN ({", ".join("?" for _ in keys)})
+                    ORDER BY received_date, id
+                    """,
+                    [*params, *keys]
+                ).fetchall() if keys else []
+            
+            participants: Dict[str, Dict[str, None]] = {key: {} for key in keys}
+            for member in members:
+                to, cc = decode_recipients(member["to_recipients"]), decode_recipients(member["cc_recipients"])
+                for address in (member["sender"], *to, *cc):
+                    address = normalize_address(address)
+                    if address:
+                        participants[member["thread_key"]].setdefault(address)
+            
+            groups = []
+            for row in rows:
+                group = present_stored_email(row)
+                thread_key = group.pop("thread_key")
+                group.pop("thread_rank", None)
+                group["conversation_count"] = row["conversation_count"]
+                group["unread_in_thread"] = int(row["unread_in_thread"])
+                group["participants"] = list(participants[thread_key])
+                group["has_actionable"] = bool(row["has_actionable"])
+                groups.append(group)
+            return groups, total
+        
+        return await self._run(_grouped_sync)
+    
+    async def get_counters(self, user_id: int, since: Optional[datetime] = None) -> EmailCounters:
+        """Get total/unread counts per AI category and folder in one query.
+        
+        Emails of muted conversations are not counted.
+        
+        Args:
+            user_id: Owner of the stored emails
+            since: Only count emails received at or after this time
+            
+        Returns:
+            Counts for sidebar badges
+        """
+        where, params = self._visible_filter(user_id, since, include_muted=False)
+        
+        def _get_counters_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    f"""
+                    SELECT category, folder,
+                           COUNT(*) AS total,
+                           COUNT(*) - TOTAL(is_read) AS unread,
+                           TOTAL(needs_review) AS needs_review,
+                           TOTAL(is_flagged) AS flagged,
+                           TOTAL(awaiting_reply) AS awaiting_reply
+                    FROM emails
+                    WHERE {where}
+                    GROUP BY category, folder
+                    """,
+                    params
+                ).fetchall()
+                conversation_count = conn.execute(
+                    f"SELECT COUNT(DISTINCT COALESCE(conversation_id, id)) FROM emails WHERE {where}",
+                    params
+                ).fetchone()[0]
+            
+            # Grouping on the raw columns lets SQLite answer from the covering
+            # index; NULL category/folder are folded into defaults here instead
+            counters = EmailCounters(conversation_count=conversation_count)
+            for row in rows:
+                category = row["category"] or "uncategorized"
+                folder = row["folder"] or "Inbox"
+                for key, group in ((category, counters.by_category),
+                                   (folder, counters.by_folder)):
+                    counter = group.setdefault(key, UnreadCounter())
+                    counter.total += row["total"]
+                    counter.unread += int(row["unread"])
+                counters.needs_review += int(row["needs_review"])
+                counters.flagged += int(row["flagged"])
+                counters.awaiting_reply += int(row["awaiting_reply"])
+            
+            return counters
+        
+        return await self._run(_get_counters_sync)
+
+    
+    async def get_storage_stats(self, user_id: int) -> StorageStats:
+        """Get synced email counts, database size, and attachment usage.
+        
+        Attachment totals come from the stored attachment metadata, so
+        emails synced before it was kept count as having none.
+        
+        Args:
+            user_id: Owner of the stored emails
+            
+        Returns:
+            Totals, attachment usage by sender and folder (largest first),
+            and the STORAGE_STATS_LARGEST largest emails
+        """
+        where, params = self._visible_filter(user_id)
+        
+        def _usage(rows) -> List[AttachmentUsage]:
+            return [
+                AttachmentUsage(
+                    name=row["name"],
+                    email_count=row["email_count"],
+                    attachment_count=int(row["attachment_count"]),
+                    attachment_bytes=int(row["attachment_bytes"])
+                )
+                for row in rows
+            ]
+        
+        def _get_storage_stats_sync():
+            with self.db.get_connection() as conn:
+                totals = conn.execute(
+                    f"""
+                    SELECT COUNT(*) AS email_count,
+                           TOTAL(attachment_count) AS attachment_count,
+                           TOTAL(attachment_bytes) AS attachment_bytes
+                    FROM emails
+                    WHERE {where}
+                    """,
+                    params
+                ).fetchone()
+                grouped = {}
+                for key, column in (("by_sender", "sender"), ("by_folder", "COALESCE(folder, 'Inbox')")):
+                    grouped[key] = conn.execute(
+                        f"""
+                        SELECT {column} AS name,
+                               COUNT(*) AS email_count,
+                               TOTAL(attachment_count) AS attachment_count,
+                               TOTAL(attachment_bytes) AS attachment_bytes
+                        FROM emails
+                        WHERE {where} AND attachment_count > 0
+                        GROUP BY {column}
+                        ORDER BY attachment_bytes DESC, name
+                        """,
+                        params
+                    ).fetchall()
+                largest = conn.execute(
+                    f"""
+                    SELECT id, subject, sender, COALESCE(folder, 'Inbox') AS folder, received_date,
+                           COALESCE(attachment_bytes, 0) AS attachment_bytes,
+                           LENGTH(CAST(COALESCE(content, '') AS BLOB))
+                               + LENGTH(CAST(COALESCE(raw_content, '') AS BLOB))
+                               + COALESCE(attachment_bytes, 0) AS size_bytes
+                    FROM emails
+                    WHERE {where}
+                    ORDER BY size_bytes DESC, id
+                    LIMIT ?
+                    """,
+                    [*params, STORAGE_STATS_LARGEST]
+                ).fetchall()
+            
+            return StorageStats(
+                email_count=totals["email_count"],
+                database_bytes=self.db.file_size(),
+                attachment_count=int(totals["attachment_count"]),
+                attachment_bytes=int(totals["attachment_bytes"]),
+                by_sender=_usage(grouped["by_sender"]),
+                by_folder=_usage(grouped["by_folder"]),
+                largest_emails=[
+                    LargestEmail(
+                        id=row["id"],
+                        subject=row["subject"],
+                        sender=row["sender"],
+                        folder=row["folder"],
+                        received_date=str(row["received_date"]) if row["received_date"] is not None else None,
+                        size_bytes=row["size_bytes"],
+                        attachment_bytes=row["attachment_bytes"],
+                        url=f"/api/emails/{quote(row['id'], safe='')}"
+                    )
+                    for row in largest
+                ]
+            )
+        
+        return await self._run(_get_storage_stats_sync)
+
+    async def get_aging_report(self, user_id: int, now: Optional[datetime] = None) -> EmailAgingReport:
+        """Bucket stored actionable emails by days since they were received.
+        
+        Emails in AGING_CATEGORIES count until a task made from them is
+        completed; emails without a received date are left out.
+        
+        Args:
+            user_id: Owner of the stored emails
+            now: Reference time ages are measured from (defaults to now in
+                the user's time zone)
+            
+        Returns:
+            Counts per AGING_BUCKETS bucket and the AGING_OLDEST oldest
+            emails with their latest linked task's status
+        """
+        where, params = self._visible_filter(user_id)
+        placeholders = ", ".join("?" for _ in AGING_CATEGORIES)
+        bucket_cases = " ".join(
+            f"WHEN age_days <= {max_days} THEN '{label}'" for label, _, max_days in AGING_BUCKETS if max_days is not None
+        )
+        stale = f"""
+            WITH stale AS (
+                SELECT id, subject, sender, received_date,
+                       MAX(CAST(julianday(?) - julianday(received_date) AS INTEGER), 0) AS age_days
+                FROM emails
+                WHERE {where} AND category IN ({placeholders}) AND received_date IS NOT NULL
+                  AND NOT EXISTS (
+                      SELECT 1 FROM tasks
+                      WHERE tasks.email_id = emails.id AND tasks.status = 'completed'
+                  )
+            )
+        """
+        
+        def _get_aging_report_sync():
+            as_of = user_clock(self.db, user_id, now).now()
+            stale_params = [as_of, *params, *AGING_CATEGORIES]
+            with self.db.get_connection() as conn:
+                counts = {
+                    row["bucket"]: row["email_count"]
+                    for row in conn.execute(
+                        f"""
+                        {stale}
+                        SELECT CASE {bucket_cases} ELSE '{AGING_BUCKETS[-1][0]}' END AS bucket,
+                               COUNT(*) AS email_count
+                        FROM stale
+                        GROUP BY bucket
+                        """,
+                        stale_params
+                    )
+                }
+                oldest = conn.execute(
+                    f"""
+                    {stale}
+                    SELECT stale.*, tasks.id AS task_id, tasks.status AS task_status
+                    FROM stale
+                    LEFT JOIN tasks ON tasks.id = (
+                        SELECT MAX(id) FROM tasks WHERE tasks.email_id = stale.id
+                    )
+                    ORDER BY stale.received_date, stale.id
+                    LIMIT ?
+                    """,
+                    [*stale_params, AGING_OLDEST]
+                ).fetchall()
+            
+            return EmailAgingReport(
+                categories=list(AGING_CATEGORIES),
+                buckets=[
+                    AgingBucket(label=label, min_days=min_days, max_days=max_days, count=counts.get(label, 0))
+                    for label, min_days, max_days in AGING_BUCKETS
+                ],
+                total=sum(counts.values()),
+                oldest=[
+                    AgingEmail(
+                        id=row["id"],
+                        subject=row["subject"],
+                        sender=row["sender"],
+                        received_date=str(row["received_date"]),
+                        age_days=row["age_days"],
+                        task_id=row["task_id"],
+                        task_status=row["task_status"]
+                    )
+                    for row in oldest
+                ],
+                as_of=as_of
+            )
+        
+        return await self._run(_get_aging_report_sync)
+    
+    def _inbox_progress_sync(self, conn, user_id: int, now: Optional[datetime]) -> InboxProgress:
+        """Compute today's inbox progress and record it as the user's snapshot for today."""
+        clock = user_clock(self.db, user_id, now)
+        as_of, today = clock.now(), clock.today()
+        start, end = clock.start_of_day().isoformat(), clock.end_of_day().isoformat()
+        where, params = self._visible_filter(user_id, include_muted=False)
+        operations = ", ".join("?" for _ in PROGRESS_APPLIED_OPERATIONS)
+        
+        row = conn.execute(
+            f"""
+            SELECT TOTAL(datetime(processed_at) >= datetime(?) AND datetime(processed_at) < datetime(?))
+                       AS classified_count,
+                   TOTAL(category IS NULL) AS unprocessed_count
+            FROM emails
+            WHERE {where} AND COALESCE(folder, 'Inbox') = 'Inbox' COLLATE NOCASE
+            """,
+            [start, end, *params]
+        ).fetchone()
+        classified_count, unprocessed_count = int(row["classified_count"]), int(row["unprocessed_count"])
+        applied_count = conn.execute(
+            f"""
+            SELECT COUNT(DISTINCT email_id) FROM outlook_activity
+            WHERE operation IN ({operations}) AND result = ?
+              AND datetime(created_at) >= datetime(?) AND datetime(created_at) < datetime(?)
+              AND email_id IN (SELECT id FROM emails WHERE user_id = ?)
+            """,
+            [*PROGRESS_APPLIED_OPERATIONS, RESULT_SUCCESS, start, end, user_id]
+        ).fetchone()[0]
+        # A completed task's updated_at is when it last changed, normally when it was completed
+        tasks_completed = conn.execute(
+            """
+            SELECT COUNT(*) FROM tasks
+            WHERE user_id = ? AND status = 'completed'
+              AND datetime(updated_at) >= datetime(?) AND datetime(updated_at) < datetime(?)
+            """,
+            (user_id, start, end)
+        ).fetchone()[0]
+        
+        conn.execute(
+            """
+            INSERT INTO inbox_progress (user_id, day, classified_count, applied_count, tasks_completed,
+                                        unprocessed_count, reached_zero, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+            ON CONFLICT(user_id, day) DO UPDATE SET
+                classified_count = excluded.classified_count,
+                applied_count = excluded.applied_count,
+                tasks_completed = excluded.tasks_completed,
+                unprocessed_count = excluded.unprocessed_count,
+                reached_zero = MAX(inbox_progress.reached_zero, excluded.reached_zero),
+                updated_at = CURRENT_TIMESTAMP
+            """,
+            (user_id, today.isoformat(), classified_count, applied_count, tasks_completed,
+             unprocessed_count, int(unprocessed_count == 0))
+        )
+        first_day = today - timedelta(days=PROGRESS_STREAK_DAYS - 1)
+        history = [
+            ProgressDay(
+                date=row["day"], unprocessed_count=row["unprocessed_count"], reached_zero=bool(row["reached_zero"])
+            )
+            for row in conn.execute(
+                "SELECT day, unprocessed_count, reached_zero FROM inbox_progress "
+                "WHERE user_id = ? AND day >= ? AND day <= ? ORDER BY day",
+                (user_id, first_day.isoformat(), today.isoformat())
+            )
+        ]
+        
+        # Today can still reach zero, so a streak not yet extended today counts from yesterday
+        zero_days = {day.date for day in history if day.reached_zero}
+        day = today if today in zero_days else today - timedelta(days=1)
+        streak_days = 0
+        while day in zero_days:
+            streak_days += 1
+            day -= timedelta(days=1)
+        
+        return InboxProgress(
+            date=today,
+            timezone=clock.timezone_name,
+            classified_count=classified_count,
+            applied_count=applied_count,
+            tasks_completed=tasks_completed,
+            unprocessed_count=unprocessed_count,
+            streak_days=streak_days,
+            history=history,
+            as_of=as_of
+        )
+    
+    async def get_inbox_progress(self, user_id: int, now: Optional[datetime] = None) -> InboxProgress:
+        """Get today's triage progress and inbox-zero streak.
+        
+        Today is the current day in the user's time zone (see user_clock).
+        Inbox emails without a category are unprocessed; emails of muted
+        conversations are not counted. Each call records today's counts in
+        the user's inbox_progress snapshot, and a day counts toward the
+        streak if the unprocessed count was zero at any snapshot that day.
+        
+        Args:
+            user_id: Owner of the stored emails
+            now: Reference time (defaults to now)
+        
+        Returns:
+            Today's counts, the streak, and the snapshots of the last
+            PROGRESS_STREAK_DAYS days
+        """
+        def _get_inbox_progress_sync():
+            with self.db.get_connection() as conn:
+                progress = self._inbox_progress_sync(conn, user_id, now)
+                conn.commit()
+            return progress
+        
+        return await self._run(_get_inbox_progress_sync)
+    
+    async def snapshot_inbox_progress(self, now: Optional[datetime] = None) -> int:
+        """Record today's inbox progress for every user with stored emails.
+        
+        Args:
+            now: Reference time (defaults to now)
+        
+        Returns:
+            Number of users snapshotted
+        """
+        def _snapshot_sync():
+            with self.db.get_connection() as conn:
+                user_ids = [
+                    row["user_id"] for row in
+                    conn.execute("SELECT DISTINCT user_id FROM emails WHERE user_id IS NOT NULL")
+                ]
+                for user_id in user_ids:
+                    self._inbox_progress_sync(conn, user_id, now)
+                conn.commit()
+            return len(user_ids)
+        
+        return await self._run(_snapshot_sync)
+
+
+async def run_progress_snapshots(email_service: EmailService) -> int:
+    """Snapshot every user's inbox progress once (the inbox_progress_snapshot background job)."""
+    return await email_service.snapshot_inbox_progress()
+
+
+async def run_archive_aged(email_service: EmailService) -> int:
+    """Archive every user's aged emails once (the archive_aged background job)."""
+    archived = await email_service.archive_aged_for_all_users(
+        settings.archive_aged_categories, settings.archive_aged_days
+    )
+    if archived:
+        logger.info(f"Archived {archived} aged emails")
+    return archived
+
+
+async def run_outbox_drain(email_service: EmailService) -> int:
+    """Apply due outbox changes once (the outbox_drain background job)."""
+    applied = await email_service.drain_outbox()
+    if applied:
+        logger.info(f"Applied {applied} queued mailbox changes from the outbox")
+    return applied
+
+
+async def cancel_on_disconnect(
+    request,
+    awaitable: Awaitable[T],
+    poll_interval: float = 0.1
+) -> T:
+    """Await ``awaitable`` but cancel it if the HTTP client disconnects.
+
+    FastAPI keeps running a handler after its client goes away; this helper
+    watches ``request.is_disconnected()`` while the work runs and cancels the
+    work when the client is gone, raising ``asyncio.CancelledError``. Work
+    still running at the request's deadline (set by the time limit
+    middleware, see backend.core.timeouts) is cancelled the same way.
+
+    Args:
+        request: Incoming Starlette/FastAPI request
+        awaitable: Service call to run
+        poll_interval: Seconds between disconnect checks
+
+    Returns:
+        The result of ``awaitable``
+    """
+    work = asyncio.ensure_future(awaitable)
+    deadline = getattr(getattr(request, "state", None), "deadline", None)
+
+    try:
+        while True:
+            done, _ = await asyncio.wait({work}, timeout=poll_interval)
+            if done:
+                return work.result()
+            if await request.is_disconnected():
+                logger.info(f"Client disconnected from {request.url.path}, cancelling work")
+                work.cancel()
+                raise asyncio.CancelledError()
+            if deadline is not None and asyncio.get_running_loop().time() >= deadline:
+                logger.info(f"{request.url.path} ran past its time limit, cancelling work")
+                work.cancel()
+                raise asyncio.CancelledError()
+    finally:
+        if not work.done():
+            work.cancel()
@@ -0,0 +1,121 @@
+"""In-process notification event bus.
+
+Services publish notifications here and the general WebSocket delivers
+them to the desktop app (see WebSocketManager). Events are not stored:
+a notification published while nobody is connected is dropped, and a
+reconnecting client starts from the server's hello message.
+
+Every notification has the same shape:
+
+    {
+        "type": "notification",
+        "id": "<uuid>",
+        "event_type": "pipeline_completed",
+        "user_id": "1",          # null for events every user receives
+        "title": "Processing finished",
+        "message": "12 emails processed",
+        "data": {...},           # event-specific fields
+        "timestamp": "2026-10-14T09:30:00"
+    }
+"""
+
+import logging
+import uuid
+from dataclasses import asdict, dataclass, field
+from datetime import datetime
+from typing import Any, Awaitable, Callable, Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# Event types a client can subscribe to
+NOTIFICATION_TYPES = (
+    "pipeline_completed",
+    "action_required_email",
+    "task_reminder",
+    "ai_health_changed",
+    "budget_warning",
+)
+
+
+@dataclass
+class Notification:
+    """One notification event."""
+    event_type: str
+    title: str
+    message: str
+    user_id: Optional[str] = None
+    data: Dict[str, Any] = field(default_factory=dict)
+    id: str = field(default_factory=lambda: str(uuid.uuid4()))
+    timestamp: str = field(default_factory=lambda: datetime.utcnow().isoformat())
+
+    def to_message(self) -> Dict[str, Any]:
+        """WebSocket message for this notification."""
+        return {"type": "notification", **asdict(self)}
+
+
+Subscriber = Callable[[Notification], Awaitable[None]]
+
+
+class EventBus:
+    """Fan notifications out to subscribers in the order they subscribed."""
+
+    def __init__(self):
+        self._subscribers: List[Subscriber] = []
+
+    def subscribe(self, subscriber: Subscriber) -> Callable[[], None]:
+        """Register a subscriber; returns a function that unsubscribes it."""
+        self._subscribers.append(subscriber)
+
+        def unsubscribe():
+            if subscriber in self._subscribers:
+                self._subscribers.remove(subscriber)
+
+        return unsubscribe
+
+    async def publish(self, notification: Notification) -> None:
+        """Deliver a notification to every subscriber.
+
+        A failing subscriber is logged and does not stop delivery to the
+        others or fail the publisher.
+        """
+        if notification.event_type not in NOTIFICATION_TYPES:
+            raise ValueError(f"Unknown notification type: {notification.event_type}")
+        for subscriber in list(self._subscribers):
+            try:
+                await subscriber(notification)
+            except Exception as e:
+                logger.error(f"Notification subscriber failed for {notification.event_type}: {e}")
+
+
+async def notify(
+    event_type: str,
+    title: str,
+    message: str,
+    user_id: Optional[Any] = None,
+    **data: Any
+) -> Notification:
+    """Publish a notification on the global bus.
+
+    Args:
+        event_type: One of NOTIFICATION_TYPES
+        title: Short heading for the desktop notification
+        message: Notification body
+        user_id: User to notify, or None for every connected user
+        **data: Event-specific fields
+
+    Returns:
+        The published notification
+    """
+    notification = Notification(
+        event_type=event_type,
+        title=title,
+        message=message,
+        user_id=str(user_id) if user_id is not None else None,
+        data=data
+    )
+    await event_bus.publish(notification)
+    return notification
+
+
+# Global event bus instance
+event_bus = EventBus()
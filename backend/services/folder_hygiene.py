@@ -0,0 +1,111 @@
+"""Category folder hygiene for FastAPI Email Helper API.
+
+Folders that categories file email into (Job Listings, Optional Events,
+...) grow for months without anyone revisiting them.
+GET /api/emails/folder-hygiene reports, for every mapped folder other than
+the inbox, the mail client's item count, how old the stored emails in it
+are (AGE_BUCKETS), and how many are past the folder's retention.
+POST /api/emails/folder-hygiene/purge moves those emails to
+settings.folder_hygiene_destination as one move batch, which
+POST /api/emails/move-batches/{batch_id}/revert moves back.
+
+A folder's retention is settings.folder_retention_days[folder], else
+settings.folder_retention_default_days; zero keeps its emails forever.
+Flagged emails are never purged, and folders covered by
+settings.excluded_folders are reported as excluded but neither read nor
+purged. Bucketing and candidate selection are pure functions of the
+stored rows so they can be tested without a mailbox.
+"""
+
+from datetime import datetime
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager
+from backend.services.category_service import category_folders
+
+# (label, minimum days, maximum days or None), in whole days since receipt
+AGE_BUCKETS: Tuple[Tuple[str, int, Optional[int]], ...] = (
+    ("0-30d", 0, 30), ("31-90d", 31, 90), ("91-365d", 91, 365), ("366+d", 366, None)
+)
+
+
+def hygiene_folders(db: Optional[DatabaseManager] = None) -> List[str]:
+    """Folders categories file email into, other than the inbox, by name."""
+    folders = {folder for folder in category_folders(db).values() if folder.strip().lower() != "inbox"}
+    return sorted(folders, key=str.lower)
+
+
+def folder_retention(folder: str) -> int:
+    """Days a folder's emails are kept before they are purge candidates (0 keeps them forever)."""
+    for name, days in settings.folder_retention_days.items():
+        if name.strip().lower() == folder.strip().lower():
+            return days
+    return settings.folder_retention_default_days
+
+
+def email_age_days(received_date: Any, now: datetime) -> Optional[int]:
+    """Whole days since an email was received, or None if its date is missing or unreadable."""
+    if not received_date:
+        return None
+    if isinstance(received_date, str):
+        try:
+            received_date = datetime.fromisoformat(received_date.replace("Z", "+00:00"))
+        except ValueError:
+            return None
+    if (received_date.tzinfo is None) != (now.tzinfo is None):
+        # Naive dates are wall-clock times; compare both as such
+        received_date, now = received_date.replace(tzinfo=None), now.replace(tzinfo=None)
+    return max(0, (now - received_date).days)
+
+
+def age_bucket(age_days: int) -> str:
+    """Label of the AGE_BUCKETS bucket an age falls into."""
+    for label, _, max_days in AGE_BUCKETS:
+        if max_days is None or age_days <= max_days:
+            return label
+    return AGE_BUCKETS[-1][0]
+
+
+def bucket_counts(rows: Iterable[Dict[str, Any]], now: datetime) -> Tuple[Dict[str, int], int]:
+    """Count stored emails per AGE_BUCKETS bucket.
+
+    Returns:
+        Bucket label -> emails (every bucket present), and the number of
+        emails left out for having no readable received date
+    """
+    counts = {label: 0 for label, _, _ in AGE_BUCKETS}
+    undated = 0
+    for row in rows:
+        age = email_age_days(row.get("received_date"), now)
+        if age is None:
+            undated += 1
+        else:
+            counts[age_bucket(age)] += 1
+    return counts, undated
+
+
+def purge_candidates(
+    rows: Iterable[Dict[str, Any]],
+    retention_days: int,
+    now: datetime
+) -> List[Dict[str, Any]]:
+    """Stored emails of a folder past its retention, oldest first.
+
+    Flagged and undated emails are kept, as is everything when the
+    retention is zero.
+
+    Args:
+        rows: Stored emails of one folder with id, received_date, and is_flagged
+        retention_days: The folder's retention (see folder_retention)
+        now: Reference time ages are measured from
+    """
+    if retention_days <= 0:
+        return []
+    candidates = []
+    for row in rows:
+        age = email_age_days(row.get("received_date"), now)
+        if age is not None and age >= retention_days and not row.get("is_flagged"):
+            candidates.append((age, row))
+    candidates.sort(key=lambda candidate: (-candidate[0], str(candidate[1]["received_date"]), candidate[1]["id"]))
+    return [row for _, row in candidates]
@@ -0,0 +1,197 @@
+"""Folder profile service layer for Email Helper API.
+
+A folder profile stores the processing defaults for one mail folder (which
+pipeline stages run, which deployment to use, and whether results are
+applied back to Outlook). StartProcessing fills in anything a request
+omits from the profile of the folder being processed.
+"""
+
+import asyncio
+import json
+import sqlite3
+from datetime import datetime
+from typing import List, Optional
+
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.folder_profile import (
+    FolderProfile, FolderProfileCreate, FolderProfileUpdate, normalize_folder_path
+)
+
+
+class FolderProfileService:
+    """Service layer for folder profile CRUD."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the folder profile service.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def create_profile(self, profile: FolderProfileCreate, user_id: str) -> FolderProfile:
+        """Create a folder profile.
+
+        Raises:
+            sqlite3.IntegrityError: If the user already has a profile for the folder
+        """
+        loop = asyncio.get_event_loop()
+
+        def _create_profile_sync():
+            current_time = datetime.now()
+            with self.db.get_connection() as conn:
+                cursor = conn.execute(
+                    """
+                    INSERT INTO folder_profiles (user_id, folder_path, default_stages,
+                                                 default_deployment, auto_apply_to_outlook,
+                                                 created_at, updated_at)
+                    VALUES (?, ?, ?, ?, ?, ?, ?)
+                    """,
+                    (
+                        user_id,
+                        profile.folder_path,
+                        json.dumps(profile.default_stages),
+                        profile.default_deployment,
+                        int(profile.auto_apply_to_outlook),
+                        current_time,
+                        current_time
+                    )
+                )
+                conn.commit()
+                row = conn.execute(
+                    "SELECT * FROM folder_profiles WHERE id = ?",
+                    (cursor.lastrowid,)
+                ).fetchone()
+                return self._row_to_profile(row)
+
+        return await loop.run_in_executor(None, _create_profile_sync)
+
+    async def list_profiles(self, user_id: str) -> List[FolderProfile]:
+        """List a user's folder profiles ordered by folder path."""
+        loop = asyncio.get_event_loop()
+
+        def _list_profiles_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    "SELECT * FROM folder_profiles WHERE user_id = ? ORDER BY folder_path",
+                    (user_id,)
+                ).fetchall()
+                return [self._row_to_profile(row) for row in rows]
+
+        return await loop.run_in_executor(None, _list_profiles_sync)
+
+    async def get_profile(self, profile_id: int, user_id: str) -> Optional[FolderProfile]:
+        """Get a folder profile by ID."""
+        loop = asyncio.get_event_loop()
+
+        def _get_profile_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    "SELECT * FROM folder_profiles WHERE id = ? AND user_id = ?",
+                    (profile_id, user_id)
+                ).fetchone()
+                return self._row_to_profile(row) if row else None
+
+        return await loop.run_in_executor(None, _get_profile_sync)
+
+    async def get_profile_for_folder(self, folder_path: str, user_id: str) -> Optional[FolderProfile]:
+        """Get the profile for a folder path (case-insensitive), if any."""
+        loop = asyncio.get_event_loop()
+
+        def _get_profile_for_folder_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    "SELECT * FROM folder_profiles WHERE folder_path = ? AND user_id = ?",
+                    (normalize_folder_path(folder_path), user_id)
+                ).fetchone()
+                return self._row_to_profile(row) if row else None
+
+        return await loop.run_in_executor(None, _get_profile_for_folder_sync)
+
+    async def update_profile(
+        self,
+        profile_id: int,
+        updates: FolderProfileUpdate,
+        user_id: str
+    ) -> Optional[FolderProfile]:
+        """Update a folder profile.
+
+        Raises:
+            sqlite3.IntegrityError: If the new folder path already has a profile
+        """
+        loop = asyncio.get_event_loop()
+
+        def _update_profile_sync():
+            update_fields = []
+            update_values = []
+
+            if updates.folder_path is not None:
+                update_fields.append("folder_path = ?")
+                update_values.append(updates.folder_path)
+
+            if updates.default_stages is not None:
+                update_fields.append("default_stages = ?")
+                update_values.append(json.dumps(updates.default_stages))
+
+            if updates.default_deployment is not None:
+                update_fields.append("default_deployment = ?")
+                update_values.append(updates.default_deployment or None)
+
+            if updates.auto_apply_to_outlook is not None:
+                update_fields.append("auto_apply_to_outlook = ?")
+                update_values.append(int(updates.auto_apply_to_outlook))
+
+            with self.db.get_connection() as conn:
+                if update_fields:
+                    update_fields.append("updated_at = ?")
+                    update_values.append(datetime.now())
+                    conn.execute(
+                        f"""
+                        UPDATE folder_profiles
+                        SET {', '.join(update_fields)}
+                        WHERE id = ? AND user_id = ?
+                        """,
+                        update_values + [profile_id, user_id]
+                    )
+                    conn.commit()
+
+                row = conn.execute(
+                    "SELECT * FROM folder_profiles WHERE id = ? AND user_id = ?",
+                    (profile_id, user_id)
+                ).fetchone()
+                return self._row_to_profile(row) if row else None
+
+        return await loop.run_in_executor(None, _update_profile_sync)
+
+    async def delete_profile(self, profile_id: int, user_id: str) -> bool:
+        """Delete a folder profile."""
+        loop = asyncio.get_event_loop()
+
+        def _delete_profile_sync():
+            with self.db.get_connection() as conn:
+                cursor = conn.execute(
+                    "DELETE FROM folder_profiles WHERE id = ? AND user_id = ?",
+                    (profile_id, user_id)
+                )
+                conn.commit()
+                return cursor.rowcount > 0
+
+        return await loop.run_in_executor(None, _delete_profile_sync)
+
+    def _row_to_profile(self, row: sqlite3.Row) -> FolderProfile:
+        """Convert database row to FolderProfile model."""
+        return FolderProfile(
+            id=row["id"],
+            folder_path=row["folder_path"],
+            default_stages=json.loads(row["default_stages"]),
+            default_deployment=row["default_deployment"],
+            auto_apply_to_outlook=bool(row["auto_apply_to_outlook"]),
+            created_at=row["created_at"],
+            updated_at=row["updated_at"]
+        )
+
+
+# Dependency for FastAPI
+def get_folder_profile_service() -> FolderProfileService:
+    """FastAPI dependency for folder profile service."""
+    return FolderProfileService()
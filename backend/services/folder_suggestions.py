@@ -0,0 +1,241 @@
+"""Destination folder suggestions for FastAPI Email Helper API.
+
+When the user files an email by hand, GET /api/emails/{id}/folder-suggestions
+proposes where it should go from up to three sources:
+
+- category: the folder OutlookManager files the email's AI category into
+- history: where the user has moved the sender's mail before, from the
+  move_email entries of the activity log
+- ai: an optional model ranking of the mailbox's existing folders against
+  the email's subject
+
+Ranking is a pure function of those inputs so it can be tested against
+seeded histories; EmailService gathers the history and nothing is changed
+in the mailbox.
+"""
+
+import json
+import logging
+import re
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from backend.core.protection import ai_allowed
+from backend.models.email import FolderSuggestion
+from backend.services.category_service import category_folders as registered_folders
+from backend.services.quarantine import QUARANTINE_FOLDER
+
+logger = logging.getLogger(__name__)
+
+FOLDER_RANKER_TEMPLATE = "folder_ranker.prompty"
+
+MAX_SUGGESTIONS = 3
+
+SOURCE_CATEGORY = "category"
+SOURCE_HISTORY = "history"
+SOURCE_AI = "ai"
+
+# Folder each built-in AI category is filed into (mirrors OutlookManager's
+# INBOX_CATEGORIES and NON_INBOX_CATEGORIES); suggest_folders overlays the
+# folders set in the categories table
+CATEGORY_FOLDERS = {
+    "required_personal_action": "Required Actions (Me)",
+    "optional_action": "Optional Actions",
+    "job_listing": "Job Listings",
+    "work_relevant": "Work Relevant",
+    "team_action": "Team Actions",
+    "optional_event": "Optional Events",
+    "fyi": "FYI",
+    "newsletter": "Newsletters",
+    "general_information": "Summarized",
+    "spam_to_delete": "ai_deleted",
+}
+
+# Confidence of a category mapping when the classification has none
+DEFAULT_CATEGORY_CONFIDENCE = 0.5
+
+# Folders never suggested as a destination
+EXCLUDED_FOLDERS = frozenset(name.lower() for name in (
+    QUARANTINE_FOLDER, "Drafts", "Outbox", "Sent Items", "Deleted Items", "Junk Email"
+))
+
+# Ties go to the source the user's own behavior backs
+_SOURCE_PRIORITY = {SOURCE_HISTORY: 0, SOURCE_CATEGORY: 1, SOURCE_AI: 2}
+
+
+def history_confidence(count: int, total: int) -> float:
+    """Confidence that the sender's next email goes where count of their total moves went.
+
+    One is added to the total so a single past move does not read as certainty.
+    """
+    return count / (total + 1) if total > 0 else 0.0
+
+
+def rank_folder_suggestions(
+    current_folder: Optional[str],
+    category: Optional[str] = None,
+    category_confidence: Optional[float] = None,
+    history: Optional[Dict[str, int]] = None,
+    ai_ranking: Sequence[Tuple[str, float]] = (),
+    folders: Optional[Sequence[str]] = None,
+    limit: int = MAX_SUGGESTIONS,
+    category_folders: Optional[Dict[str, str]] = None
+) -> List[FolderSuggestion]:
+    """Combine the suggestion sources into the best few destinations.
+
+    A folder suggested by several sources keeps its most confident
+    suggestion. The email's current folder and EXCLUDED_FOLDERS are never
+    suggested, and with a folder list, category and AI suggestions for
+    folders the mailbox does not have are dropped.
+
+    Args:
+        current_folder: Folder the email is in now
+        category: The email's AI category, if classified
+        category_confidence: Confidence of that classification
+        history: Destination folder -> times the sender's mail was moved there
+        ai_ranking: (folder, confidence) pairs from parse_folder_ranking
+        folders: Names of the mailbox's folders, when known
+        limit: Most suggestions to return
+        category_folders: Category -> folder it is filed into (defaults to
+            CATEGORY_FOLDERS)
+
+    Returns:
+        Suggestions, most confident first
+    """
+    existing = {name.lower(): name for name in folders} if folders else None
+    skipped = EXCLUDED_FOLDERS | {(current_folder or "").lower()}
+    candidates: List[FolderSuggestion] = []
+
+    def add(folder: str, source: str, confidence: float, must_exist: bool):
+        key = folder.lower()
+        if key in skipped:
+            return
+        if existing is not None:
+            if key in existing:
+                folder = existing[key]
+            elif must_exist:
+                return
+        candidates.append(FolderSuggestion(
+            folder=folder, source=source, confidence=round(max(0.0, min(1.0, confidence)), 3)
+        ))
+
+    mapped = (CATEGORY_FOLDERS if category_folders is None else category_folders).get(category or "")
+    if mapped:
+        confidence = DEFAULT_CATEGORY_CONFIDENCE if category_confidence is None else category_confidence
+        add(mapped, SOURCE_CATEGORY, confidence, must_exist=True)
+    total_moves = sum((history or {}).values())
+    for folder, count in (history or {}).items():
+        add(folder, SOURCE_HISTORY, history_confidence(count, total_moves), must_exist=False)
+    for folder, confidence in ai_ranking:
+        add(folder, SOURCE_AI, confidence, must_exist=True)
+
+    candidates.sort(key=lambda s: (-s.confidence, _SOURCE_PRIORITY[s.source], s.folder.lower()))
+    best: Dict[str, FolderSuggestion] = {}
+    for suggestion in candidates:
+        best.setdefault(suggestion.folder.lower(), suggestion)
+    return list(best.values())[:limit]
+
+
+def ranking_candidates(folders: Sequence[str], current_folder: Optional[str]) -> List[str]:
+    """Folders worth asking the model about."""
+    skipped = EXCLUDED_FOLDERS | {(current_folder or "").lower()}
+    return [name for name in dict.fromkeys(folders) if name.lower() not in skipped]
+
+
+def build_ranking_inputs(subject: str, sender: str, folders: Sequence[str]) -> Dict[str, Any]:
+    """Build folder_ranker prompty inputs."""
+    return {
+        "subject": subject or "(no subject)",
+        "sender": sender or "Unknown",
+        "folders": "\n".join(f"- {name}" for name in folders),
+    }
+
+
+def parse_folder_ranking(raw: Any, folders: Sequence[str]) -> List[Tuple[str, float]]:
+    """Parse folder_ranker output into (folder, confidence) pairs.
+
+    Accepts a dict or a JSON string, optionally wrapped in a code fence.
+    Folders the model made up are dropped and confidences are clamped to
+    0-1 (unparseable ones count as 0.5).
+
+    Raises:
+        ValueError: If the output is not a JSON object
+    """
+    if isinstance(raw, str):
+        text = raw.strip()
+        fenced = re.search(r"```(?:json)?\s*(.*?)```", text, re.DOTALL)
+        if fenced:
+            text = fenced.group(1).strip()
+        try:
+            raw = json.loads(text)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Folder ranker returned invalid JSON: {e}")
+
+    if not isinstance(raw, dict):
+        raise ValueError("Folder ranker returned a non-object response")
+
+    known = {name.lower(): name for name in folders}
+    ranking = []
+    for item in raw.get("folders") or []:
+        if not isinstance(item, dict):
+            continue
+        folder = known.get(str(item.get("folder", "")).strip().lower())
+        if folder is None:
+            continue
+        try:
+            confidence = float(item.get("confidence", 0.5))
+        except (TypeError, ValueError):
+            confidence = 0.5
+        ranking.append((folder, max(0.0, min(1.0, confidence))))
+    return ranking
+
+
+async def suggest_folders(
+    email_service,
+    email_id: str,
+    user_id: int,
+    ai_service=None
+) -> Optional[List[FolderSuggestion]]:
+    """Suggest destination folders for an email without changing anything.
+
+    Args:
+        email_service: EmailService for the email, folders, and move history
+        email_id: Email being filed
+        user_id: Owner of the stored emails
+        ai_service: If given, also ranks the mailbox's folders with the AI
+            (never for rights-managed emails); a failed ranking only leaves
+            its suggestions out
+
+    Returns:
+        Suggestions, most confident first, or None if the email is not found
+    """
+    email = await email_service.get_email_by_id(email_id, user_id=user_id, source="auto")
+    if not email:
+        return None
+
+    current_folder = email.get("folder") or "Inbox"
+    folders = [folder["name"] for folder in await email_service.get_folders(user_id=user_id) if folder.get("name")]
+    history = await email_service.get_sender_move_history(email["sender"], user_id) if email.get("sender") else {}
+
+    ai_ranking: List[Tuple[str, float]] = []
+    candidates = ranking_candidates(folders, current_folder)
+    if ai_service is not None and candidates and ai_allowed(email):
+        try:
+            result = await ai_service.rank_folders(
+                subject=email.get("subject") or "", sender=email.get("sender") or "", folders=candidates
+            )
+            if "error" in result:
+                logger.warning(f"Folder ranking failed for {email_id}: {result['error']}")
+            else:
+                ai_ranking = result.get("folders", [])
+        except Exception as e:
+            logger.warning(f"Folder ranking failed for {email_id}: {e}")
+
+    return rank_folder_suggestions(
+        current_folder,
+        category=email.get("category"),
+        category_confidence=email.get("confidence"),
+        history=history,
+        ai_ranking=ai_ranking,
+        folders=folders,
+        category_folders={**CATEGORY_FOLDERS, **registered_folders(email_service.db)}
+    )
@@ -0,0 +1,192 @@
+"""Follow-up detection for FastAPI Email Helper API.
+
+Finds stored threads where the latest message came from someone else, is
+addressed to the user, asks a question, and has gone unanswered for a
+configured number of days. Heuristics decide first; an optional AI check
+(awaiting_reply_detector.prompty) confirms the heuristic matches.
+"""
+
+import json
+import logging
+import re
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional
+
+from backend.core.protection import ai_allowed
+from backend.core.redaction import redact_email
+from backend.services.boilerplate import BoilerplateStore
+
+logger = logging.getLogger(__name__)
+
+AWAITING_REPLY_TEMPLATE = "awaiting_reply_detector.prompty"
+
+# Requests that expect an answer even without a question mark
+QUESTION_PHRASES = (
+    "can you", "could you", "would you", "will you", "do you", "are you",
+    "please let me know", "let me know", "please confirm", "please advise",
+    "any update", "any thoughts", "what do you think", "your thoughts",
+    "get back to me", "when can", "are we able",
+)
+
+# Lines where quoted history starts in a reply
+_QUOTE_MARKERS = re.compile(
+    r"^(-{2,}\s*original message\s*-{2,}|on .+ wrote:|from:\s.+|_{5,})\s*$",
+    re.IGNORECASE
+)
+
+
+def strip_quoted_text(body: str) -> str:
+    """Return only the new text of a message, without quoted history."""
+    lines = []
+    for line in (body or "").splitlines():
+        if _QUOTE_MARKERS.match(line.strip()):
+            break
+        if line.lstrip().startswith(">"):
+            continue
+        lines.append(line)
+    return "\n".join(lines)
+
+
+def looks_like_question(body: str) -> bool:
+    """Heuristically decide whether a message asks for a response."""
+    text = strip_quoted_text(body).lower()
+    if "?" in text:
+        return True
+    return any(phrase in text for phrase in QUESTION_PHRASES)
+
+
+def _contains_address(field: Optional[str], address: str) -> bool:
+    return bool(field) and address.lower() in field.lower()
+
+
+def parse_received(value: Any) -> Optional[datetime]:
+    """Naive datetime of a received time given as a datetime or ISO string (None if unparseable)."""
+    if isinstance(value, datetime):
+        return value.replace(tzinfo=None)
+    if not value:
+        return None
+    try:
+        return datetime.fromisoformat(str(value).replace("Z", "+00:00")).replace(tzinfo=None)
+    except ValueError:
+        return None
+
+
+def is_awaiting_reply(
+    thread: List[Dict[str, Any]],
+    my_address: Optional[str],
+    min_age_days: int,
+    now: Optional[datetime] = None
+) -> bool:
+    """Decide with heuristics whether a thread awaits the user's reply.
+
+    Args:
+        thread: Thread messages, oldest first
+        my_address: The user's email address, used to spot self-sent messages
+        min_age_days: Days the latest message must have gone unanswered
+        now: Current time (for tests)
+
+    Returns:
+        True if the latest message is from someone else, addressed to the
+        user, asks a question, and is at least min_age_days old
+    """
+    if not thread:
+        return False
+
+    latest = thread[-1]
+    if my_address:
+        if _contains_address(latest.get("sender"), my_address):
+            return False
+        recipient = latest.get("recipient")
+        if recipient and not _contains_address(recipient, my_address):
+            return False
+
+    received = parse_received(latest.get("received_date"))
+    if received is None:
+        return False
+    if (now or datetime.utcnow()) - received < timedelta(days=min_age_days):
+        return False
+
+    return looks_like_question(latest.get("content") or "")
+
+
+def parse_ai_verdict(raw: Any) -> bool:
+    """Parse awaiting_reply_detector output; unparseable output counts as True.
+
+    The AI check only runs on heuristic matches, so when it cannot answer
+    the heuristic result stands.
+    """
+    if isinstance(raw, str):
+        try:
+            raw = json.loads(raw.strip().strip("`").removeprefix("json"))
+        except json.JSONDecodeError:
+            return True
+    if isinstance(raw, dict):
+        return bool(raw.get("awaiting_reply", True))
+    return True
+
+
+def group_threads(emails: List[Dict[str, Any]]) -> List[List[Dict[str, Any]]]:
+    """Group stored emails into threads, oldest message first."""
+    threads: Dict[str, List[Dict[str, Any]]] = {}
+    for email in emails:
+        key = email.get("conversation_id") or f"single:{email['id']}"
+        threads.setdefault(key, []).append(email)
+    return [
+        sorted(thread, key=lambda e: parse_received(e.get("received_date")) or datetime.min)
+        for thread in threads.values()
+    ]
+
+
+async def detect_awaiting_reply(
+    email_service,
+    user_id: int,
+    my_address: Optional[str],
+    min_age_days: int,
+    ai_service=None,
+    now: Optional[datetime] = None
+) -> int:
+    """Refresh the awaiting_reply flag on a user's stored threads.
+
+    Args:
+        email_service: EmailService over the database store
+        user_id: Owner of the stored emails
+        my_address: The user's email address
+        min_age_days: Days the latest message must have gone unanswered
+        ai_service: If given, confirms heuristic matches with the AI check
+            (rights-managed emails are judged by the heuristic alone)
+        now: Current time (for tests)
+
+    Returns:
+        Number of threads awaiting a reply
+    """
+    emails = await email_service.get_thread_messages(user_id)
+    awaiting_ids = []
+
+    for thread in group_threads(emails):
+        if not is_awaiting_reply(thread, my_address, min_age_days, now):
+            continue
+
+        latest = thread[-1]
+        if ai_service is not None and ai_allowed(latest):
+            try:
+                redacted, _ = redact_email({
+                    "subject": latest.get("subject") or "",
+                    "content": strip_quoted_text(await BoilerplateStore(email_service.db).strip_body(
+                        user_id, latest.get("sender"), latest.get("content") or ""
+                    ))
+                })
+                result = await ai_service.check_awaiting_reply(
+                    subject=redacted["subject"],
+                    sender=latest.get("sender") or "",
+                    content=redacted["content"],
+                    received_date=str(latest.get("received_date") or "")
+                )
+                if "error" not in result and not result.get("awaiting_reply", True):
+                    continue
+            except Exception as e:
+                logger.warning(f"Awaiting-reply AI check failed for {latest['id']}: {e}")
+
+        awaiting_ids.append(latest["id"])
+
+    await email_service.set_awaiting_reply(user_id, awaiting_ids)
+    return len(awaiting_ids)
@@ -9,6 +9,7 @@ from typing import List, Dict, Any, Optional
 from fastapi import HTTPException
 
 from backend.clients.graph_client import GraphClient, GraphAPIError
+from backend.core.protection import mark_protection
 from backend.models.graph_email import EmailNormalizer
 from backend.services.email_provider import EmailProvider
 from backend.core.config import settings
@@ -119,7 +120,7 @@ class GraphEmailProvider(EmailProvider):
             )
             
             self.logger.info(f"Retrieved {len(normalized_emails)} emails from {folder_name}")
-            return normalized_emails
+            return [mark_protection(email) for email in normalized_emails]
             
         except GraphAPIError as e:
             self.logger.error(f"Failed to get emails from {folder_name}: {e}")
@@ -158,7 +159,7 @@ class GraphEmailProvider(EmailProvider):
             normalized_email = EmailNormalizer.normalize_graph_message(graph_message)
             
             self.logger.debug(f"Retrieved email content for {email_id}")
-            return normalized_email
+            return mark_protection(normalized_email)
             
         except GraphAPIError as e:
             self.logger.error(f"Failed to get email content for {email_id}: {e}")
@@ -214,11 +215,13 @@ class GraphEmailProvider(EmailProvider):
             self.logger.error(f"Unexpected error getting folders: {e}")
             raise HTTPException(status_code=500, detail=f"Unexpected error: {e}")
     
-    def mark_as_read(self, email_id: str) -> bool:
+    def mark_as_read(self, email_id: str, suppress_read_receipt: bool = False) -> bool:
         """Mark email as read.
         
         Args:
             email_id: Email ID from Graph API
+            suppress_read_receipt: Ignored; Graph does not send read receipts
+                when isRead is patched
             
         Returns:
             True if successful, False otherwise
@@ -300,7 +303,7 @@ class GraphEmailProvider(EmailProvider):
             normalized_emails = EmailNormalizer.batch_normalize_messages(graph_messages)
             
             self.logger.debug(f"Retrieved {len(normalized_emails)} messages in conversation {conversation_id}")
-            return normalized_emails
+            return [mark_protection(email) for email in normalized_emails]
             
         except GraphAPIError as e:
             self.logger.error(f"Failed to get conversation {conversation_id}: {e}")
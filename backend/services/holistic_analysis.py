@@ -0,0 +1,128 @@
+"""Holistic inbox analysis for FastAPI Email Helper API.
+
+POST /api/ai/holistic-analysis sends a summary of the user's stored inbox
+emails to the holistic_inbox_analyzer prompty template in one call, which
+picks out the actions still needed (with a canonical email per topic),
+actions superseded by later emails, duplicate groups, and expired items.
+Both AI services (standard and COM) run it; this module builds its inputs
+and checks what comes back.
+
+Actions flagged blocking_others are kept apart as blocking items (see
+backend.services.blocking_items); what the user said when dismissing one
+goes back to later runs as corrections.
+"""
+
+import json
+import re
+from datetime import date
+from typing import Any, Dict, Iterable, List, Optional, Sequence
+
+HOLISTIC_TEMPLATE = "holistic_inbox_analyzer.prompty"
+
+# Characters of each email's body previewed in the inbox summary
+PREVIEW_CHARS = 300
+
+ACTION_TYPES = ("required_personal_action", "team_action", "optional_action")
+PRIORITIES = ("high", "medium", "low")
+
+# Top-level lists of the analysis, each of dicts
+ANALYSIS_KEYS = ("truly_relevant_actions", "superseded_actions", "duplicate_groups", "expired_items")
+
+
+def build_inbox_summary(emails: Iterable[Dict[str, Any]]) -> str:
+    """Summarize emails (id, subject, sender, received_date, content) for the prompt."""
+    parts = []
+    for email in emails:
+        body = (email.get("content") or "").strip()
+        preview = body[:PREVIEW_CHARS] + ("..." if len(body) > PREVIEW_CHARS else "")
+        parts.append(
+            f"EMAIL_ID: {email['id']}\n"
+            f"Subject: {email.get('subject') or 'Unknown Subject'}\n"
+            f"From: {email.get('sender') or 'Unknown Sender'}\n"
+            f"Date: {email.get('received_date') or 'Unknown Date'}\n"
+            f"Preview: {preview}\n"
+        )
+    return "\n---\n".join(parts)
+
+
+def build_holistic_inputs(
+    emails: Sequence[Dict[str, Any]],
+    username: str,
+    job_context: str,
+    today: date,
+    corrections: Sequence[str] = ()
+) -> Dict[str, Any]:
+    """Build holistic_inbox_analyzer prompty inputs.
+
+    Args:
+        emails: Emails to analyze, see build_inbox_summary
+        username: Mailbox owner's name
+        job_context: Owner's role, for judging relevance
+        today: Current date in the owner's time zone
+        corrections: One line per earlier blocking item the owner dismissed
+    """
+    return {
+        "context": job_context,
+        "job_role_context": job_context,
+        "username": username,
+        "inbox_summary": build_inbox_summary(emails),
+        "current_date": today.isoformat(),
+        "corrections": "\n".join(f"- {line}" for line in corrections) or "None",
+    }
+
+
+def parse_holistic_analysis(raw: Any, email_ids: Iterable[str]) -> Dict[str, List[Dict[str, Any]]]:
+    """Parse holistic_inbox_analyzer output, keeping what refers to the analyzed emails.
+
+    Accepts a dict or a JSON string, optionally wrapped in a code fence.
+    Actions whose canonical email was not analyzed are dropped, unknown
+    related IDs are removed, and action_type and priority outside their
+    enumerations fall back to optional_action and medium.
+
+    Raises:
+        ValueError: If the output is not a JSON object
+    """
+    if isinstance(raw, str):
+        text = raw.strip()
+        fenced = re.search(r"```(?:json)?\s*(.*?)```", text, re.DOTALL)
+        if fenced:
+            text = fenced.group(1).strip()
+        try:
+            raw = json.loads(text)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Holistic analyzer returned invalid JSON: {e}")
+    if not isinstance(raw, dict):
+        raise ValueError("Holistic analyzer returned a non-object response")
+
+    known = set(email_ids)
+    analysis = {
+        key: [item for item in raw.get(key) or [] if isinstance(item, dict)] for key in ANALYSIS_KEYS
+    }
+
+    actions = []
+    for action in analysis["truly_relevant_actions"]:
+        email_id = str(action.get("canonical_email_id") or "")
+        if email_id not in known:
+            continue
+        action_type, priority = action.get("action_type"), action.get("priority")
+        actions.append({
+            "action_type": action_type if action_type in ACTION_TYPES else "optional_action",
+            "priority": priority if priority in PRIORITIES else "medium",
+            "topic": str(action.get("topic") or ""),
+            "canonical_email_id": email_id,
+            "related_email_ids": [
+                str(related) for related in action.get("related_email_ids") or []
+                if str(related) in known and str(related) != email_id
+            ],
+            "deadline": _optional_text(action.get("deadline")),
+            "why_relevant": str(action.get("why_relevant") or ""),
+            "blocking_others": action.get("blocking_others") is True,
+        })
+    analysis["truly_relevant_actions"] = actions
+    return analysis
+
+
+def _optional_text(value: Any) -> Optional[str]:
+    if value is None:
+        return None
+    return str(value).strip() or None
@@ -0,0 +1,176 @@
+"""Stored holistic analysis runs for FastAPI Email Helper API.
+
+Every POST /api/ai/holistic-analysis run is kept in the holistic_analyses
+table with the emails it analyzed and its parsed result (see
+backend.services.holistic_analysis), and its ID is returned with the
+response. POST /api/emails/analyses/{analysis_id}/apply acts on a stored
+run: it archives the superseded emails and the non-canonical emails of
+duplicate groups, and closes the open tasks made from expired items.
+
+cleanup_plan works out what a run asks for, limited to the emails the
+run analyzed; an email the run still treats as the one to act on or keep
+is never archived.
+
+Actions with a concrete deadline (YYYY-MM-DD) are also kept in the
+holistic_deadlines table, one per canonical email, for the calendar feed
+(see backend.services.calendar_feed). A later run that still reports an
+email's deadline refreshes it, bumping its sequence when the date moved,
+and one that analyzed the email without reporting a date removes it.
+"""
+
+import json
+import re
+from dataclasses import dataclass, field
+from datetime import date, datetime
+from typing import Any, Dict, Iterable, List, Optional
+
+from backend.database.connection import DatabaseManager, get_default_manager
+
+# Why an email is archived by an applied run
+ARCHIVE_SUPERSEDED = "superseded"
+ARCHIVE_DUPLICATE = "duplicate"
+
+_ISO_DATE = re.compile(r"\d{4}-\d{2}-\d{2}")
+
+
+@dataclass
+class CleanupPlan:
+    """What applying a holistic run would change."""
+    archive: Dict[str, str] = field(default_factory=dict)  # Email ID -> ARCHIVE_SUPERSEDED or ARCHIVE_DUPLICATE
+    expired: List[str] = field(default_factory=list)  # Emails whose open tasks are closed
+
+
+class HolisticRunStore:
+    """Store for holistic analysis runs."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the store.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def record(
+        self,
+        user_id: int,
+        analysis: Dict[str, List[Dict[str, Any]]],
+        email_ids: Iterable[str],
+        now: Optional[datetime] = None
+    ) -> int:
+        """Record a holistic run and the concrete deadlines of its actions.
+
+        Blocking; EmailService calls it from the thread pool.
+
+        Args:
+            user_id: Owner of the analyzed emails
+            analysis: Parsed result (see parse_holistic_analysis)
+            email_ids: Emails the run looked at; deadlines of these that the
+                run no longer reports are removed
+
+        Returns:
+            ID of the run
+        """
+        analyzed = list(dict.fromkeys(email_ids))
+        seen_at = now or datetime.utcnow()
+        deadlines = {}
+        for action in analysis.get("truly_relevant_actions", []):
+            deadline = action_deadline(action)
+            if deadline is not None:
+                deadlines.setdefault(action["canonical_email_id"], (action, deadline))
+        with self.db.get_connection() as conn:
+            run_id = conn.execute(
+                "INSERT INTO holistic_analyses (user_id, email_ids, analysis, created_at) VALUES (?, ?, ?, ?)",
+                (user_id, json.dumps(analyzed), json.dumps(analysis), seen_at)
+            ).lastrowid
+            # SET expressions see the old row, so sequence and updated_at only move with the date
+            conn.executemany(
+                """
+                INSERT INTO holistic_deadlines (user_id, email_id, topic, priority, deadline, analysis_id, updated_at)
+                VALUES (?, ?, ?, ?, ?, ?, ?)
+                ON CONFLICT (user_id, email_id) DO UPDATE SET
+                    sequence = sequence + (deadline IS NOT excluded.deadline),
+                    updated_at = CASE WHEN deadline IS NOT excluded.deadline
+                        THEN excluded.updated_at ELSE updated_at END,
+                    topic = excluded.topic, priority = excluded.priority, deadline = excluded.deadline,
+                    analysis_id = excluded.analysis_id
+                """,
+                [
+                    (user_id, email_id, action.get("topic"), action.get("priority"), deadline.isoformat(),
+                     run_id, seen_at)
+                    for email_id, (action, deadline) in deadlines.items()
+                ]
+            )
+            conn.executemany(
+                "DELETE FROM holistic_deadlines WHERE user_id = ? AND email_id = ?",
+                [(user_id, email_id) for email_id in analyzed if email_id not in deadlines]
+            )
+            conn.commit()
+        return run_id
+
+    def get(self, run_id: int, user_id: int) -> Optional[Dict[str, Any]]:
+        """A user's run with email_ids and analysis decoded, or None if there is none.
+
+        Blocking.
+        """
+        with self.db.get_connection() as conn:
+            row = conn.execute(
+                "SELECT * FROM holistic_analyses WHERE id = ? AND user_id = ?", (run_id, user_id)
+            ).fetchone()
+        if row is None:
+            return None
+        run = dict(row)
+        run["email_ids"] = json.loads(run["email_ids"])
+        run["analysis"] = json.loads(run["analysis"])
+        return run
+
+
+def action_deadline(action: Dict[str, Any]) -> Optional[date]:
+    """An action's deadline as a date, or None unless it is a YYYY-MM-DD date."""
+    value = (action.get("deadline") or "").strip()
+    if not _ISO_DATE.fullmatch(value):
+        return None
+    try:
+        return date.fromisoformat(value)
+    except ValueError:
+        return None
+
+
+def cleanup_plan(
+    run: Dict[str, Any],
+    archive_superseded: bool = True,
+    merge_duplicates: bool = True,
+    complete_expired_tasks: bool = True
+) -> CleanupPlan:
+    """Work out the cleanups of a stored run that were selected.
+
+    Superseded emails are the original_email_id of superseded_actions.
+    Duplicates are a group's archive_email_ids, or its email_ids other than
+    keep_email_id when the model left archive_email_ids out. Emails the
+    run did not analyze, canonical emails of its actions, and kept
+    duplicates are left alone.
+    """
+    analysis, analyzed = run["analysis"], set(run["email_ids"])
+    kept = {action.get("canonical_email_id") for action in analysis.get("truly_relevant_actions", [])}
+    kept.update(group.get("keep_email_id") for group in analysis.get("duplicate_groups", []))
+
+    plan = CleanupPlan()
+    if archive_superseded:
+        for item in analysis.get("superseded_actions", []):
+            plan.archive.setdefault(str(item.get("original_email_id") or ""), ARCHIVE_SUPERSEDED)
+    if merge_duplicates:
+        for group in analysis.get("duplicate_groups", []):
+            archived = group.get("archive_email_ids")
+            if archived is None:
+                archived = [
+                    email_id for email_id in group.get("email_ids") or [] if email_id != group.get("keep_email_id")
+                ]
+            for email_id in archived:
+                plan.archive.setdefault(str(email_id), ARCHIVE_DUPLICATE)
+    plan.archive = {
+        email_id: reason for email_id, reason in plan.archive.items() if email_id in analyzed and email_id not in kept
+    }
+    if complete_expired_tasks:
+        expired = (str(item.get("email_id") or "") for item in analysis.get("expired_items", []))
+        plan.expired = [email_id for email_id in dict.fromkeys(expired) if email_id in analyzed]
+    return plan
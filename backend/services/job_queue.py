@@ -11,15 +11,22 @@ import logging
 from datetime import datetime, timedelta
 from typing import Dict, Any, List, Optional, Union
 from enum import Enum
-from dataclasses import dataclass, asdict
+from dataclasses import dataclass, asdict, field
 import asyncio
 
+from backend.core.read_only import check_writable
+from backend.services.dry_run import ChangePlan
+from backend.services.event_bus import notify
+
 # For now, using a simple in-memory implementation
 # In production, would use: from celery import Celery
 # from redis import Redis
 
 logger = logging.getLogger(__name__)
 
+# Share of a run's token budget at which a budget_warning notification is sent
+BUDGET_WARNING_RATIO = 0.8
+
 
 class JobStatus(Enum):
     """Job execution status."""
@@ -29,6 +36,8 @@ class JobStatus(Enum):
     FAILED = "failed"
     CANCELLED = "cancelled"
     RETRYING = "retrying"
+    SKIPPED_BUDGET = "skipped_budget"  # Not run because the pipeline's token budget ran out
+    SKIPPED = "skipped"  # Not run because its email must not reach the AI (see core.protection)
 
 
 class JobType(Enum):
@@ -80,6 +89,56 @@ class ProcessingJob:
             self.created_at = datetime.utcnow().isoformat()
 
 
+@dataclass
+class RunDiff:
+    """What a pipeline run changed compared with the stored values it replaced."""
+    newly_classified: List[Dict[str, Any]] = field(default_factory=list)
+    category_changed: List[Dict[str, Any]] = field(default_factory=list)
+    tasks_created: List[Dict[str, Any]] = field(default_factory=list)
+    became_needs_review: List[Dict[str, Any]] = field(default_factory=list)
+    
+    def record_classification(
+        self,
+        email_id: str,
+        previous: Optional[Dict[str, Any]],
+        category: Optional[str],
+        needs_review: bool
+    ):
+        """Record a classification against the stored row it overwrote.
+        
+        Args:
+            email_id: Classified email
+            previous: Stored category and needs_review before the run (None if unclassified)
+            category: New category
+            needs_review: Whether the new classification needs review
+        """
+        previous_category = previous.get("category") if previous else None
+        if previous_category is None:
+            self.newly_classified.append({"email_id": email_id, "category": category})
+        elif previous_category != category:
+            self.category_changed.append({
+                "email_id": email_id,
+                "previous_category": previous_category,
+                "category": category
+            })
+        if needs_review and not (previous and previous.get("needs_review")):
+            self.became_needs_review.append({"email_id": email_id, "category": category})
+    
+    def record_tasks(self, email_id: str, count: int):
+        """Record tasks created for an email."""
+        if count:
+            self.tasks_created.append({"email_id": email_id, "count": count})
+    
+    def summary(self) -> Dict[str, int]:
+        """Counts for each kind of change."""
+        return {
+            "newly_classified": len(self.newly_classified),
+            "category_changed": len(self.category_changed),
+            "tasks_created": sum(entry["count"] for entry in self.tasks_created),
+            "became_needs_review": len(self.became_needs_review)
+        }
+
+
 @dataclass
 class ProcessingPipeline:
     """Processing pipeline definition."""
@@ -88,14 +147,33 @@ class ProcessingPipeline:
     user_id: str
     jobs: List[ProcessingJob]
     overall_progress: int
-    status: str  # 'running', 'completed', 'failed', 'paused'
+    status: str  # 'running', 'completed', 'failed', 'budget_exhausted', 'paused'
     created_at: str = None
     started_at: Optional[str] = None
     completed_at: Optional[str] = None
+    folder: Optional[str] = None
+    stages: List[str] = field(default_factory=list)
+    deployment: Optional[str] = None
+    auto_apply_to_outlook: bool = False
+    profile_id: Optional[int] = None  # Folder profile that supplied defaults, if any
+    diff: RunDiff = field(default_factory=RunDiff)
+    max_tokens_budget: int = 0  # AI tokens the run may use (0 means unlimited)
+    tokens_consumed: int = 0
+    dry_run: bool = False  # Writes go to plan instead of the mailbox and database
+    simulate_ai: bool = False  # Dry run with canned AI responses
+    plan: Optional[ChangePlan] = None  # What a dry run would have changed
+    criteria: Optional[Dict[str, Any]] = None  # Criteria the emails were resolved from, if any
+    resolved_email_ids: List[str] = field(default_factory=list)  # Criteria matches before skipping, for reruns
+    matched_count: int = 0  # Emails the criteria matched, including those past its limit
     
     def __post_init__(self):
         if self.created_at is None:
             self.created_at = datetime.utcnow().isoformat()
+    
+    @property
+    def budget_exhausted(self) -> bool:
+        """Whether the run has used up its token budget."""
+        return self.max_tokens_budget > 0 and self.tokens_consumed >= self.max_tokens_budget
 
 
 class JobQueue:
@@ -121,9 +199,50 @@ class JobQueue:
         
         self.logger.info("JobQueue initialized with in-memory storage")
     
-    async def create_pipeline(self, email_ids: List[str], user_id: str) -> str:
-        """Create a new processing pipeline for multiple emails."""
+    async def create_pipeline(
+        self,
+        email_ids: List[str],
+        user_id: str,
+        stages: Optional[List[str]] = None,
+        folder: Optional[str] = None,
+        deployment: Optional[str] = None,
+        auto_apply_to_outlook: bool = False,
+        profile_id: Optional[int] = None,
+        max_tokens_budget: int = 0,
+        dry_run: bool = False,
+        simulate_ai: bool = False,
+        criteria: Optional[Dict[str, Any]] = None,
+        resolved_email_ids: Optional[List[str]] = None,
+        matched_count: int = 0
+    ) -> str:
+        """Create a new processing pipeline for multiple emails.
+        
+        Args:
+            email_ids: Emails to process
+            user_id: Owner of the pipeline
+            stages: Job types to run for each email (defaults to all)
+            folder: Folder the emails came from
+            deployment: Azure OpenAI deployment to use
+            auto_apply_to_outlook: Whether results are applied back to Outlook
+            profile_id: Folder profile that supplied the configuration
+            max_tokens_budget: AI tokens the run may use before its remaining
+                jobs are skipped (0 means unlimited)
+            dry_run: Record writes in the pipeline's change plan instead of
+                making them (see backend.services.dry_run)
+            simulate_ai: Use canned AI responses; only valid with dry_run
+            criteria: Processing criteria email_ids were resolved from
+            resolved_email_ids: Every email the criteria resolved to, before
+                processed, muted, and excluded ones were left out
+            matched_count: Emails the criteria matched before its limit
+        
+        Raises:
+            ReadOnlyError: If settings.read_only is set
+        """
+        check_writable("start_pipeline")
         pipeline_id = f"pipeline_{uuid.uuid4().hex[:8]}"
+        stages = stages or [
+            JobType.EMAIL_ANALYSIS.value, JobType.TASK_EXTRACTION.value, JobType.CATEGORIZATION.value
+        ]
         
         jobs = []
         for email_id in email_ids:
@@ -170,7 +289,7 @@ class JobQueue:
                 )
             )
             
-            jobs.extend([analysis_job, task_job, category_job])
+            jobs.extend(job for job in (analysis_job, task_job, category_job) if job.type.value in stages)
         
         pipeline = ProcessingPipeline(
             id=pipeline_id,
@@ -178,7 +297,19 @@ class JobQueue:
             user_id=user_id,
             jobs=jobs,
             overall_progress=0,
-            status="running"
+            status="running",
+            folder=folder,
+            stages=list(stages),
+            deployment=deployment,
+            auto_apply_to_outlook=auto_apply_to_outlook,
+            profile_id=profile_id,
+            max_tokens_budget=max_tokens_budget,
+            dry_run=dry_run,
+            simulate_ai=simulate_ai,
+            plan=ChangePlan() if dry_run else None,
+            criteria=criteria,
+            resolved_email_ids=list(resolved_email_ids or []),
+            matched_count=matched_count
         )
         
         # Store pipeline and jobs
@@ -195,10 +326,20 @@ class JobQueue:
         """Get pipeline by ID."""
         return self._pipelines.get(pipeline_id)
     
+    async def get_latest_pipeline(self, user_id: str) -> Optional[ProcessingPipeline]:
+        """Get a user's most recently created pipeline."""
+        pipelines = [p for p in self._pipelines.values() if p.user_id == user_id]
+        return max(pipelines, key=lambda p: p.created_at, default=None)
+    
     async def get_job(self, job_id: str) -> Optional[ProcessingJob]:
         """Get job by ID."""
         return self._jobs.get(job_id)
     
+    async def get_pipeline_for_job(self, job_id: str) -> Optional[ProcessingPipeline]:
+        """Get the pipeline containing a job."""
+        return next((p for p in self._pipelines.values()
+                     if any(j.id == job_id for j in p.jobs)), None)
+    
     async def update_job_progress(self, job_id: str, progress: JobProgress) -> bool:
         """Update job progress."""
         if job_id not in self._jobs:
@@ -245,6 +386,28 @@ class JobQueue:
         self.logger.info(f"Completed job {job_id}")
         return True
     
+    async def skip_job(self, job_id: str, reason: str) -> bool:
+        """Mark job as skipped: it is finished without a result and is not retried."""
+        if job_id not in self._jobs:
+            return False
+        
+        job = self._jobs[job_id]
+        job.status = JobStatus.SKIPPED
+        job.completed_at = datetime.utcnow().isoformat()
+        job.progress.percentage = 100
+        job.progress.message = f"Skipped: {reason}"
+        job.progress.updated_at = datetime.utcnow().isoformat()
+        
+        pipeline = next((p for p in self._pipelines.values() 
+                        if any(j.id == job_id for j in p.jobs)), None)
+        if pipeline:
+            await self._update_pipeline_progress(pipeline.id)
+        
+        await self._trigger_callbacks(job_id, "skipped")
+        
+        self.logger.info(f"Skipped job {job_id}: {reason}")
+        return True
+    
     async def fail_job(self, job_id: str, error: str) -> bool:
         """Mark job as failed with error."""
         if job_id not in self._jobs:
@@ -284,6 +447,42 @@ class JobQueue:
         
         return True
     
+    async def record_token_usage(self, job_id: str, tokens: int) -> bool:
+        """Add the AI tokens a job used to its pipeline.
+        
+        Once the pipeline's budget is used up, its queued jobs are skipped.
+        """
+        pipeline = await self.get_pipeline_for_job(job_id)
+        if not pipeline:
+            return False
+        
+        was_exhausted = pipeline.budget_exhausted
+        before = pipeline.tokens_consumed
+        pipeline.tokens_consumed += tokens
+        if pipeline.budget_exhausted:
+            if not was_exhausted:
+                await self._notify_budget(pipeline, "exhausted")
+            await self._skip_for_budget(pipeline)
+        elif pipeline.max_tokens_budget > 0:
+            threshold = pipeline.max_tokens_budget * BUDGET_WARNING_RATIO
+            if before < threshold <= pipeline.tokens_consumed:
+                await self._notify_budget(pipeline, "warning")
+        return True
+    
+    async def _notify_budget(self, pipeline: ProcessingPipeline, level: str):
+        """Publish a budget_warning notification when a run nears or reaches its token budget."""
+        percent = round(100 * pipeline.tokens_consumed / pipeline.max_tokens_budget)
+        await notify(
+            "budget_warning",
+            "Token budget used up" if level == "exhausted" else "Token budget running low",
+            f"Processing run used {pipeline.tokens_consumed} of {pipeline.max_tokens_budget} tokens ({percent}%)",
+            user_id=pipeline.user_id,
+            pipeline_id=pipeline.id,
+            level=level,
+            tokens_consumed=pipeline.tokens_consumed,
+            max_tokens_budget=pipeline.max_tokens_budget
+        )
+    
     async def get_next_job(self) -> Optional[ProcessingJob]:
         """Get next job from queue based on priority."""
         for priority in [JobPriority.URGENT, JobPriority.HIGH, JobPriority.MEDIUM, JobPriority.LOW]:
@@ -293,6 +492,11 @@ class JobQueue:
                 if job_id in self._jobs:
                     job = self._jobs[job_id]
                     if job.status == JobStatus.QUEUED:
+                        # Retries re-queued after the budget ran out are skipped too
+                        pipeline = await self.get_pipeline_for_job(job_id)
+                        if pipeline and pipeline.budget_exhausted:
+                            await self._skip_for_budget(pipeline)
+                            continue
                         job.status = JobStatus.PROCESSING
                         job.started_at = datetime.utcnow().isoformat()
                         return job
@@ -321,6 +525,24 @@ class JobQueue:
         await self._trigger_callbacks(pipeline_id, "pipeline_cancelled")
         return True
     
+    async def _skip_for_budget(self, pipeline: ProcessingPipeline):
+        """Mark a pipeline's queued jobs as skipped because its budget ran out."""
+        skipped_count = 0
+        for job in pipeline.jobs:
+            if job.status == JobStatus.QUEUED:
+                job.status = JobStatus.SKIPPED_BUDGET
+                job.completed_at = datetime.utcnow().isoformat()
+                job.progress.message = "Skipped: token budget exhausted"
+                job.progress.updated_at = datetime.utcnow().isoformat()
+                skipped_count += 1
+        
+        if skipped_count:
+            self.logger.warning(
+                f"Pipeline {pipeline.id} used {pipeline.tokens_consumed} of {pipeline.max_tokens_budget} "
+                f"tokens; skipped {skipped_count} jobs"
+            )
+        await self._update_pipeline_progress(pipeline.id)
+    
     async def register_callback(self, identifier: str, callback: callable):
         """Register callback for job or pipeline events."""
         if identifier not in self._job_callbacks:
@@ -337,8 +559,10 @@ class JobQueue:
         if total_jobs == 0:
             return
         
-        completed_jobs = sum(1 for job in pipeline.jobs if job.status == JobStatus.COMPLETED)
+        # Jobs skipped for their email are done as far as the pipeline is concerned
+        completed_jobs = sum(1 for job in pipeline.jobs if job.status in (JobStatus.COMPLETED, JobStatus.SKIPPED))
         failed_jobs = sum(1 for job in pipeline.jobs if job.status == JobStatus.FAILED)
+        skipped_jobs = sum(1 for job in pipeline.jobs if job.status == JobStatus.SKIPPED_BUDGET)
         
         # Calculate overall progress
         total_progress = sum(job.progress.percentage for job in pipeline.jobs)
@@ -351,6 +575,9 @@ class JobQueue:
         elif failed_jobs > 0 and (completed_jobs + failed_jobs) == total_jobs:
             pipeline.status = "failed"
             pipeline.completed_at = datetime.utcnow().isoformat()
+        elif skipped_jobs > 0 and (completed_jobs + failed_jobs + skipped_jobs) == total_jobs:
+            pipeline.status = "budget_exhausted"
+            pipeline.completed_at = datetime.utcnow().isoformat()
         
         await self._trigger_callbacks(pipeline_id, "pipeline_progress")
     
@@ -0,0 +1,106 @@
+"""Revertible batches of email moves for FastAPI Email Helper API.
+
+Operations that move many emails at once (POST /api/emails/archive-aged,
+the archive_aged background job, POST /api/emails/folder-hygiene/purge,
+POST /api/emails/domains/{domain}/apply-category, and POST
+/api/emails/analyses/{analysis_id}/apply) record the emails they moved and
+the folder each came from as one batch in the move_batches table. A batch
+can also hold the tasks the operation closed, with the status each had.
+POST /api/emails/move-batches/{batch_id}/revert moves every email of a
+batch back where it came from and reopens its tasks; a batch is reverted
+at most once.
+"""
+
+import json
+from datetime import datetime
+from typing import Any, Dict, Optional
+
+from backend.database.connection import DatabaseManager, get_default_manager
+
+# Operations recorded as move batches
+OPERATION_ARCHIVE_AGED = "archive_aged"
+OPERATION_FOLDER_HYGIENE = "folder_hygiene"
+OPERATION_DOMAIN_CATEGORY = "domain_category"
+OPERATION_HOLISTIC_CLEANUP = "holistic_cleanup"
+
+
+class MoveBatchStore:
+    """Store for revertible batches of email moves."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the store.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def record(
+        self,
+        user_id: int,
+        operation: str,
+        destination_folder: str,
+        moves: Dict[str, str],
+        task_changes: Optional[Dict[int, Dict[str, str]]] = None,
+        now: Optional[datetime] = None
+    ) -> int:
+        """Record emails moved together.
+
+        Blocking; EmailService calls it from the thread pool.
+
+        Args:
+            user_id: Owner of the moved emails
+            operation: What moved them, e.g. OPERATION_ARCHIVE_AGED
+            destination_folder: Folder the emails were moved into
+            moves: Email ID -> folder the email was moved out of
+            task_changes: Task ID -> {"from": status, "to": status} of tasks
+                the operation closed
+
+        Returns:
+            ID of the batch
+        """
+        with self.db.get_connection() as conn:
+            batch_id = conn.execute(
+                """
+                INSERT INTO move_batches (user_id, operation, destination_folder, moves, task_changes, created_at)
+                VALUES (?, ?, ?, ?, ?, ?)
+                """,
+                (
+                    user_id, operation, destination_folder, json.dumps(moves),
+                    json.dumps(task_changes or {}), now or datetime.utcnow()
+                )
+            ).lastrowid
+            conn.commit()
+        return batch_id
+
+    def get(self, batch_id: int, user_id: int) -> Optional[Dict[str, Any]]:
+        """A user's batch with its moves and task changes decoded, or None if there is none.
+
+        Blocking.
+        """
+        with self.db.get_connection() as conn:
+            row = conn.execute(
+                "SELECT * FROM move_batches WHERE id = ? AND user_id = ?", (batch_id, user_id)
+            ).fetchone()
+        if row is None:
+            return None
+        batch = dict(row)
+        batch["moves"] = json.loads(batch["moves"])
+        batch["task_changes"] = {int(task_id): change for task_id, change in json.loads(batch["task_changes"]).items()}
+        return batch
+
+    def mark_reverted(self, batch_id: int, now: Optional[datetime] = None) -> bool:
+        """Mark a batch reverted, unless it already was.
+
+        Blocking.
+
+        Returns:
+            False if the batch was already reverted
+        """
+        with self.db.get_connection() as conn:
+            updated = conn.execute(
+                "UPDATE move_batches SET reverted_at = ? WHERE id = ? AND reverted_at IS NULL",
+                (now or datetime.utcnow(), batch_id)
+            ).rowcount
+            conn.commit()
+        return updated > 0
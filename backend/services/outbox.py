@@ -0,0 +1,222 @@
+"""Outbox of mailbox changes for FastAPI Email Helper API.
+
+When Outlook is unavailable (COM not connected, or a transient provider
+error), EmailService queues marks, moves, and categorizations in the
+outbox table instead of failing them, and the outbox_drain background job
+applies them once the provider answers again. Only changes that set a
+state are queued, so applying one twice leaves the mailbox as applying it
+once; a newer change of the same kind to the same email replaces the
+queued one rather than queueing behind it.
+
+A change that keeps failing is retried with doubling delays and, after
+settings.outbox_max_attempts, dead-lettered: it stays listed by
+GET /api/emails/outbox until deleted, but is no longer applied.
+"""
+
+import asyncio
+import json
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional, Tuple
+
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.email import OutboxEntry
+
+logger = logging.getLogger(__name__)
+
+# Mailbox changes queued while the provider is unavailable, by operation name
+OUTBOX_OPERATIONS = ("mark_as_read", "move_email", "categorize_email")
+
+# States of a queued change
+STATUS_PENDING = "pending"
+STATUS_DEAD = "dead"  # Failed outbox_max_attempts times; kept for inspection, never applied
+
+
+class Outbox:
+    """Store for queued mailbox changes."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the outbox.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def enqueue(
+        self,
+        operation: str,
+        email_id: str,
+        parameters: Dict[str, Any],
+        error: str,
+        now: Optional[datetime] = None
+    ) -> int:
+        """Queue a change, replacing a pending change of the same kind to the same email.
+
+        Blocking; EmailService calls it from the thread pool. The change is
+        due immediately, so the next drain after the provider recovers
+        applies it.
+
+        Args:
+            operation: One of OUTBOX_OPERATIONS
+            email_id: Email the change applies to
+            parameters: Arguments of the change (JSON serializable)
+            error: Provider error that deferred the change
+            now: Reference time (defaults to now, UTC)
+
+        Returns:
+            ID of the queued entry
+        """
+        now = now or datetime.utcnow()
+        payload = json.dumps(parameters, sort_keys=True, default=str)
+        with self.db.get_connection() as conn:
+            row = conn.execute(
+                "SELECT id FROM outbox WHERE operation = ? AND email_id = ? AND status = ?",
+                (operation, email_id, STATUS_PENDING)
+            ).fetchone()
+            if row:
+                entry_id = row["id"]
+                conn.execute(
+                    """
+                    UPDATE outbox SET parameters = ?, attempts = 0, next_retry_at = ?, last_error = ?, updated_at = ?
+                    WHERE id = ?
+                    """,
+                    (payload, now, error, now, entry_id)
+                )
+            else:
+                entry_id = conn.execute(
+                    """
+                    INSERT INTO outbox
+                        (operation, email_id, parameters, status, attempts, next_retry_at, last_error,
+                         created_at, updated_at)
+                    VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?)
+                    """,
+                    (operation, email_id, payload, STATUS_PENDING, now, error, now, now)
+                ).lastrowid
+            conn.commit()
+        return entry_id
+
+    def supersede(self, operation: str, email_id: str) -> None:
+        """Drop pending changes that a change applied directly has overtaken.
+
+        Blocking.
+        """
+        with self.db.get_connection() as conn:
+            conn.execute(
+                "DELETE FROM outbox WHERE operation = ? AND email_id = ? AND status = ?",
+                (operation, email_id, STATUS_PENDING)
+            )
+            conn.commit()
+
+    def due(self, now: Optional[datetime] = None) -> List[OutboxEntry]:
+        """Pending changes whose retry time has come, oldest first.
+
+        Blocking.
+        """
+        with self.db.get_connection() as conn:
+            rows = conn.execute(
+                "SELECT * FROM outbox WHERE status = ? AND next_retry_at <= ? ORDER BY id",
+                (STATUS_PENDING, now or datetime.utcnow())
+            ).fetchall()
+        return [self._row_to_entry(row) for row in rows]
+
+    def complete(self, entry: OutboxEntry) -> None:
+        """Remove a change once it has been applied.
+
+        Blocking. A change re-queued with other parameters while this one
+        was being applied is kept, so the newer change is applied too.
+        """
+        with self.db.get_connection() as conn:
+            conn.execute(
+                "DELETE FROM outbox WHERE id = ? AND parameters = ?",
+                (entry.id, json.dumps(entry.parameters, sort_keys=True, default=str))
+            )
+            conn.commit()
+
+    def fail(
+        self,
+        entry: OutboxEntry,
+        error: str,
+        max_attempts: int,
+        retry_seconds: int,
+        now: Optional[datetime] = None
+    ) -> str:
+        """Record a failed attempt, scheduling a retry or dead-lettering the change.
+
+        Blocking. The delay before the next retry doubles with each attempt.
+
+        Args:
+            entry: Change that failed
+            error: Why it failed
+            max_attempts: Attempts after which the change is dead-lettered
+            retry_seconds: Delay before the first retry
+            now: Reference time (defaults to now, UTC)
+
+        Returns:
+            The change's new status
+        """
+        now = now or datetime.utcnow()
+        attempts = entry.attempts + 1
+        status = STATUS_DEAD if attempts >= max_attempts else STATUS_PENDING
+        next_retry_at = now + timedelta(seconds=retry_seconds * 2 ** (attempts - 1))
+        with self.db.get_connection() as conn:
+            conn.execute(
+                """
+                UPDATE outbox SET status = ?, attempts = ?, next_retry_at = ?, last_error = ?, updated_at = ?
+                WHERE id = ?
+                """,
+                (status, attempts, next_retry_at, error, now, entry.id)
+            )
+            conn.commit()
+        if status == STATUS_DEAD:
+            logger.warning(f"Dead-lettered {entry.operation} of {entry.email_id} after {attempts} attempts: {error}")
+        return status
+
+    def counts(self) -> Dict[str, int]:
+        """Number of pending and dead-lettered changes, for health reporting. Blocking."""
+        with self.db.get_connection() as conn:
+            rows = conn.execute("SELECT status, COUNT(*) FROM outbox GROUP BY status").fetchall()
+        counts = {STATUS_PENDING: 0, STATUS_DEAD: 0}
+        counts.update({row[0]: row[1] for row in rows})
+        return counts
+
+    async def list_entries(self, status: Optional[str] = None) -> Tuple[List[OutboxEntry], Dict[str, int]]:
+        """List queued changes, oldest first.
+
+        Args:
+            status: Only changes in this state (STATUS_PENDING or STATUS_DEAD)
+
+        Returns:
+            The changes and the counts of every state (see counts)
+        """
+        loop = asyncio.get_event_loop()
+
+        def _list_sync():
+            with self.db.get_connection() as conn:
+                if status:
+                    rows = conn.execute("SELECT * FROM outbox WHERE status = ? ORDER BY id", (status,)).fetchall()
+                else:
+                    rows = conn.execute("SELECT * FROM outbox ORDER BY id").fetchall()
+            return [self._row_to_entry(row) for row in rows], self.counts()
+
+        return await loop.run_in_executor(None, _list_sync)
+
+    async def delete(self, entry_id: int) -> bool:
+        """Discard a queued or dead-lettered change without applying it.
+
+        Returns:
+            False if there is no such entry
+        """
+        loop = asyncio.get_event_loop()
+
+        def _delete_sync():
+            with self.db.get_connection() as conn:
+                deleted = conn.execute("DELETE FROM outbox WHERE id = ?", (entry_id,)).rowcount
+                conn.commit()
+            return deleted > 0
+
+        return await loop.run_in_executor(None, _delete_sync)
+
+    def _row_to_entry(self, row) -> OutboxEntry:
+        """Convert a database row to an OutboxEntry."""
+        return OutboxEntry(**{**dict(row), "parameters": json.loads(row["parameters"])})
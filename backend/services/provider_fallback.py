@@ -0,0 +1,74 @@
+"""Database fallback for provider reads in FastAPI Email Helper API.
+
+With settings.provider_read_policy set to "fallback", read-only EmailService
+calls (email lists, single emails, conversations, folders) that fail with a
+transient or disconnected provider error are answered from stored emails
+instead, and the response is marked degraded. Writes never fall back. The
+provider_degraded gauge records whether the last provider read failed so
+/health can report it.
+"""
+
+import threading
+from datetime import datetime
+from typing import Any, Dict, Optional
+
+from fastapi import HTTPException
+
+# Provider HTTP errors that mean Outlook or Graph is unreachable or
+# disconnected rather than that the request was wrong
+TRANSIENT_STATUS_CODES = frozenset({401, 500, 502, 503, 504})
+
+
+def is_transient_provider_error(error: BaseException) -> bool:
+    """Whether a provider error is worth answering from the database."""
+    if isinstance(error, HTTPException):
+        return error.status_code in TRANSIENT_STATUS_CODES
+    # pywintypes.com_error cannot be imported off Windows
+    return isinstance(error, (ConnectionError, TimeoutError, RuntimeError)) or type(error).__name__ == "com_error"
+
+
+class ProviderDegradedGauge:
+    """Thread-safe record of whether provider reads are currently failing."""
+
+    def __init__(self):
+        self._lock = threading.Lock()
+        self.reset()
+
+    def reset(self) -> None:
+        """Mark the provider healthy and forget past fallbacks."""
+        with self._lock:
+            self.degraded = False
+            self.degraded_since: Optional[datetime] = None
+            self.last_error: Optional[str] = None
+            self.last_operation: Optional[str] = None
+            self.fallback_count = 0
+
+    def record_fallback(self, operation: str, error: BaseException) -> None:
+        """Record a read served from the database after a provider error."""
+        with self._lock:
+            if not self.degraded:
+                self.degraded = True
+                self.degraded_since = datetime.utcnow()
+            self.last_error = getattr(error, "detail", None) or str(error)
+            self.last_operation = operation
+            self.fallback_count += 1
+
+    def record_success(self) -> None:
+        """Record a provider read that succeeded."""
+        with self._lock:
+            self.degraded = False
+            self.degraded_since = None
+
+    def snapshot(self) -> Dict[str, Any]:
+        """Current gauge values for health reporting."""
+        with self._lock:
+            return {
+                "provider_degraded": int(self.degraded),
+                "degraded_since": self.degraded_since.isoformat() if self.degraded_since else None,
+                "last_error": self.last_error,
+                "last_operation": self.last_operation,
+                "fallback_count": self.fallback_count,
+            }
+
+
+provider_degraded = ProviderDegradedGauge()
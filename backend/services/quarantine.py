@@ -0,0 +1,57 @@
+"""Spam quarantine for FastAPI Email Helper API.
+
+With settings.spam_quarantine_days above zero, applying spam_to_delete moves
+the email to the Quarantine folder instead of filing it as spam, and stamps
+quarantined_until on its row. Emails rescued before then go back to the
+inbox; the rest are deleted by a periodic purge. Eligibility is a pure
+function of the stored row so it can be tested without a mailbox.
+"""
+
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+QUARANTINE_FOLDER = "Quarantine"
+SPAM_CATEGORY = "spam_to_delete"
+
+
+def quarantine_deadline(days: int, now: Optional[datetime] = None) -> datetime:
+    """When an email quarantined now becomes eligible for purging."""
+    return (now or datetime.utcnow()) + timedelta(days=days)
+
+
+def is_quarantined(row: Dict[str, Any]) -> bool:
+    """Whether a stored email is waiting in quarantine."""
+    return bool(row.get("quarantined_until")) and (row.get("folder") or "Inbox") == QUARANTINE_FOLDER
+
+
+def is_purge_eligible(row: Dict[str, Any], now: Optional[datetime] = None) -> bool:
+    """Whether a stored email has aged out of quarantine without being rescued.
+
+    Emails that were moved out of the Quarantine folder or reclassified as
+    something other than spam count as rescued and are never purged.
+
+    Args:
+        row: Stored email with quarantined_until, folder, and category
+        now: Reference time (defaults to now, UTC)
+    """
+    if not is_quarantined(row) or row.get("category") != SPAM_CATEGORY:
+        return False
+    until = row["quarantined_until"]
+    if isinstance(until, str):
+        try:
+            until = datetime.fromisoformat(until)
+        except ValueError:
+            logger.warning(f"Ignoring unreadable quarantined_until {until!r} on {row.get('id')}")
+            return False
+    return until <= (now or datetime.utcnow())
+
+
+async def run_purge(email_service) -> int:
+    """Purge expired quarantined emails once (the quarantine_purge background job)."""
+    purged = await email_service.purge_quarantine()
+    if purged:
+        logger.info(f"Purged {len(purged)} quarantined emails")
+    return len(purged)
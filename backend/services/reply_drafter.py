@@ -0,0 +1,89 @@
+"""Reply drafting helpers for FastAPI Email Helper API.
+
+Both AI services (standard and COM) draft replies with the draft_reply
+prompty template. This module builds the template inputs and normalizes the
+model output so the two services behave alike. Drafts are only ever
+returned or saved as Outlook drafts; nothing here sends mail.
+"""
+
+import json
+import re
+from typing import Any, Dict, List, Optional
+
+from backend.core.errors import InputValidationError
+
+DRAFT_TEMPLATE = "draft_reply.prompty"
+
+DRAFT_TONES = {
+    "brief": "Brief: a few short sentences, no filler",
+    "formal": "Formal: polite and professional, complete sentences",
+    "friendly": "Friendly: warm and conversational, still concise",
+}
+
+
+def reply_subject(subject: str) -> str:
+    """Prefix a subject with Re: unless it already has one."""
+    subject = (subject or "").strip()
+    if re.match(r"^re:", subject, re.IGNORECASE):
+        return subject
+    return f"Re: {subject}" if subject else "Re:"
+
+
+def build_draft_inputs(
+    subject: str,
+    sender: str,
+    content: str,
+    tone: str,
+    bullet_points: Optional[List[str]] = None,
+    username: Optional[str] = None,
+    job_context: Optional[str] = None,
+    received_date: Optional[str] = None
+) -> Dict[str, Any]:
+    """Build prompty inputs for a reply to one email.
+
+    Raises:
+        InputValidationError: If the tone is not one of DRAFT_TONES
+    """
+    if tone not in DRAFT_TONES:
+        raise InputValidationError(f"Unknown tone '{tone}' (expected {', '.join(DRAFT_TONES)})")
+    points = [point.strip() for point in bullet_points or [] if point.strip()]
+    return {
+        "context": job_context or "",
+        "username": username or "User",
+        "subject": subject,
+        "sender": sender,
+        "date": received_date or "Unknown",
+        "body": content,
+        "tone": DRAFT_TONES[tone],
+        "bullet_points": "\n".join(f"- {point}" for point in points) or "None given; reply to what the email asks",
+    }
+
+
+def parse_draft(raw: Any, original_subject: str) -> Dict[str, str]:
+    """Parse drafter output into {subject, body}.
+
+    Accepts a dict or a JSON string, optionally wrapped in a code fence. A
+    missing subject falls back to "Re: <original subject>".
+
+    Raises:
+        ValueError: If the output has no body
+    """
+    if isinstance(raw, str):
+        text = raw.strip()
+        fenced = re.search(r"```(?:json)?\s*(.*?)```", text, re.DOTALL)
+        if fenced:
+            text = fenced.group(1).strip()
+        try:
+            raw = json.loads(text)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Drafter returned invalid JSON: {e}")
+
+    if not isinstance(raw, dict):
+        raise ValueError("Drafter returned a non-object response")
+
+    body = str(raw.get("body") or "").strip()
+    if not body:
+        raise ValueError("Drafter returned an empty body")
+
+    subject = str(raw.get("subject") or "").strip() or reply_subject(original_subject)
+    return {"subject": subject, "body": body}
@@ -0,0 +1,184 @@
+"""Outlook rule import for FastAPI Email Helper API.
+
+Years of Outlook rules ("move mail from news@contoso.com to Newsletters")
+already say how the user files mail. GET /api/rules/import-candidates
+reads them from the mail client and maps each rule whose destination
+folder is a category's folder (the inverse of the categories table's
+folder mapping) to a line of that category's classification rules.
+POST /api/rules/import appends the lines of the selected rules to their
+categories, so the classifier applies them from the next email on.
+
+Only receive rules with sender or subject conditions and a move-to-folder
+action map; the others are listed with the reasons they do not.
+"""
+
+import logging
+from typing import Any, Dict, List, Optional, Sequence, Union
+
+from backend.models.category import CategoryUpdate
+from backend.models.rule_import import (
+    RuleImportCandidate, RuleImportCandidatesResponse, RuleImportResponse, UnmappableRule
+)
+from backend.services.category_service import CategoryService
+
+logger = logging.getLogger(__name__)
+
+# Longest category description CategoryUpdate accepts
+MAX_DESCRIPTION_LENGTH = 2000
+
+
+def folder_categories(category_folders: Dict[str, str]) -> Dict[str, List[str]]:
+    """Folder (lowercased) -> the categories filed into it, inverting category -> folder."""
+    index: Dict[str, List[str]] = {}
+    for category, folder in category_folders.items():
+        index.setdefault(folder.strip().lower(), []).append(category)
+    return index
+
+
+def _folder_lookup(folder: str, index: Dict[str, List[str]]) -> List[str]:
+    """Categories filed into a folder path, matched in full or by its last segment."""
+    key = folder.strip().strip("/").lower()
+    return index.get(key) or index.get(key.rsplit("/", 1)[-1], [])
+
+
+def _quoted(values: Sequence[str]) -> str:
+    return " or ".join(f'"{value}"' for value in values)
+
+
+def rule_text(name: str, senders: Sequence[str], subject_contains: Sequence[str]) -> str:
+    """Classification rule line equivalent to an Outlook rule's conditions."""
+    parts = []
+    if senders:
+        parts.append(f"from a sender address containing {_quoted(senders)}")
+    if subject_contains:
+        parts.append(f"with a subject containing {_quoted(subject_contains)}")
+    return f"Emails {' and '.join(parts)} (Outlook rule \"{name}\")"
+
+
+def map_outlook_rule(
+    rule: Dict[str, Any],
+    index: Dict[str, List[str]]
+) -> Union[RuleImportCandidate, UnmappableRule]:
+    """Map one rule from EmailProvider.get_rules to a category's classification rule.
+
+    Args:
+        rule: Rule dictionary from the provider
+        index: Folder -> categories, from folder_categories
+
+    Returns:
+        The import candidate, or the reasons the rule cannot be imported
+    """
+    name = rule.get("name") or "(unnamed rule)"
+    senders = [sender.strip() for sender in rule.get("senders") or [] if sender.strip()]
+    subjects = [text.strip() for text in rule.get("subject_contains") or [] if text.strip()]
+    folder = rule.get("move_to_folder")
+    reasons = []
+
+    if not rule.get("incoming", True):
+        reasons.append("runs on sent mail")
+    if not senders and not subjects:
+        reasons.append("has no sender or subject condition")
+    if rule.get("other_conditions"):
+        reasons.append(
+            f"also checks {', '.join(rule['other_conditions'])}, which a classification rule cannot express"
+        )
+
+    categories: List[str] = []
+    if not folder:
+        reasons.append("does not move mail to a folder")
+    else:
+        categories = _folder_lookup(folder, index)
+        if not categories:
+            reasons.append(f"moves mail to {folder}, which no category is filed into")
+        elif len(categories) > 1:
+            reasons.append(
+                f"moves mail to {folder}, which several categories are filed into: {', '.join(sorted(categories))}"
+            )
+
+    if reasons:
+        return UnmappableRule(outlook_rule=name, reasons=reasons)
+    return RuleImportCandidate(
+        outlook_rule=name,
+        enabled=bool(rule.get("enabled", True)),
+        category=categories[0],
+        folder=folder,
+        senders=senders,
+        subject_contains=subjects,
+        rule=rule_text(name, senders, subjects)
+    )
+
+
+def plan_rule_import(
+    rules: Sequence[Dict[str, Any]],
+    category_folders: Dict[str, str]
+) -> RuleImportCandidatesResponse:
+    """Split the mail client's rules into import candidates and unmappable rules.
+
+    Args:
+        rules: Rules from EmailProvider.get_rules, in the order they run
+        category_folders: Category -> folder it is filed into
+
+    Returns:
+        Candidates and unmappable rules, each in rule order
+    """
+    index = folder_categories(category_folders)
+    candidates: List[RuleImportCandidate] = []
+    unmappable: List[UnmappableRule] = []
+    for rule in rules:
+        mapped = map_outlook_rule(rule, index)
+        (candidates if isinstance(mapped, RuleImportCandidate) else unmappable).append(mapped)
+    return RuleImportCandidatesResponse(candidates=candidates, unmappable=unmappable)
+
+
+async def import_rules(
+    names: Sequence[str],
+    plan: RuleImportCandidatesResponse,
+    category_service: CategoryService
+) -> RuleImportResponse:
+    """Append the selected candidates' rule lines to their categories' descriptions.
+
+    A rule whose line a category already has is skipped, so importing twice
+    changes nothing, as is one that would push the description past
+    MAX_DESCRIPTION_LENGTH.
+
+    Args:
+        names: Names of the Outlook rules to import
+        plan: Current candidates, from plan_rule_import
+        category_service: Category service whose descriptions are updated
+    """
+    candidates = {}
+    for candidate in plan.candidates:
+        candidates.setdefault(candidate.outlook_rule, candidate)
+    unmappable = {rule.outlook_rule: rule for rule in plan.unmappable}
+    descriptions: Dict[str, Optional[str]] = {}
+    imported: List[RuleImportCandidate] = []
+    skipped: List[UnmappableRule] = []
+
+    for name in dict.fromkeys(names):
+        candidate = candidates.get(name)
+        if candidate is None:
+            skipped.append(
+                unmappable.get(name) or UnmappableRule(outlook_rule=name, reasons=["no Outlook rule has this name"])
+            )
+            continue
+
+        if candidate.category not in descriptions:
+            category = await category_service.get_category(candidate.category)
+            descriptions[candidate.category] = category.description if category else None
+        description = descriptions[candidate.category]
+        if description is None:
+            reasons = [f"category {candidate.category} no longer exists"]
+        elif candidate.rule in (line.strip() for line in description.splitlines()):
+            reasons = [f"already in the rules of {candidate.category}"]
+        elif len(f"{description.rstrip()}\n{candidate.rule}") > MAX_DESCRIPTION_LENGTH:
+            reasons = [f"the rules of {candidate.category} would exceed {MAX_DESCRIPTION_LENGTH} characters"]
+        else:
+            descriptions[candidate.category] = f"{description.rstrip()}\n{candidate.rule}"
+            imported.append(candidate)
+            continue
+        skipped.append(UnmappableRule(outlook_rule=name, reasons=reasons))
+
+    for category in dict.fromkeys(candidate.category for candidate in imported):
+        await category_service.update_category(category, CategoryUpdate(description=descriptions[category]))
+        logger.info(f"Imported Outlook rules into the rules of category {category}")
+    return RuleImportResponse(imported=imported, skipped=skipped)
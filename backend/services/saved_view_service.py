@@ -0,0 +1,170 @@
+"""Saved view service layer for FastAPI Email Helper API.
+
+A saved view stores a named combination of GET /api/emails filters and a
+sort so it can be re-run with one request. Filters are validated against
+backend.core.email_filters when saved and again when executed.
+"""
+
+import asyncio
+import json
+import sqlite3
+from datetime import datetime
+from typing import List, Optional
+
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.saved_view import SavedView, SavedViewCreate, SavedViewUpdate
+
+
+class SavedViewService:
+    """Service layer for saved view CRUD."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the saved view service.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    async def create_view(self, view: SavedViewCreate, user_id: int) -> SavedView:
+        """Create a saved view."""
+        loop = asyncio.get_event_loop()
+
+        def _create_view_sync():
+            current_time = datetime.now()
+            with self.db.get_connection() as conn:
+                cursor = conn.execute(
+                    """
+                    INSERT INTO saved_views (user_id, name, filters, sort, icon, created_at, updated_at)
+                    VALUES (?, ?, ?, ?, ?, ?, ?)
+                    """,
+                    (
+                        user_id,
+                        view.name,
+                        json.dumps(view.filters, sort_keys=True),
+                        view.sort,
+                        view.icon,
+                        current_time,
+                        current_time
+                    )
+                )
+                conn.commit()
+                row = conn.execute(
+                    "SELECT * FROM saved_views WHERE id = ?",
+                    (cursor.lastrowid,)
+                ).fetchone()
+                return self._row_to_view(row)
+
+        return await loop.run_in_executor(None, _create_view_sync)
+
+    async def list_views(self, user_id: int) -> List[SavedView]:
+        """List a user's saved views ordered by name."""
+        loop = asyncio.get_event_loop()
+
+        def _list_views_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    "SELECT * FROM saved_views WHERE user_id = ? ORDER BY name COLLATE NOCASE, id",
+                    (user_id,)
+                ).fetchall()
+                return [self._row_to_view(row) for row in rows]
+
+        return await loop.run_in_executor(None, _list_views_sync)
+
+    async def get_view(self, view_id: int, user_id: int) -> Optional[SavedView]:
+        """Get a saved view by ID."""
+        loop = asyncio.get_event_loop()
+
+        def _get_view_sync():
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    "SELECT * FROM saved_views WHERE id = ? AND user_id = ?",
+                    (view_id, user_id)
+                ).fetchone()
+                return self._row_to_view(row) if row else None
+
+        return await loop.run_in_executor(None, _get_view_sync)
+
+    async def update_view(
+        self,
+        view_id: int,
+        updates: SavedViewUpdate,
+        user_id: int
+    ) -> Optional[SavedView]:
+        """Update a saved view; filters, when given, replace the stored ones."""
+        loop = asyncio.get_event_loop()
+
+        def _update_view_sync():
+            update_fields = []
+            update_values = []
+
+            if updates.name is not None:
+                update_fields.append("name = ?")
+                update_values.append(updates.name)
+
+            if updates.filters is not None:
+                update_fields.append("filters = ?")
+                update_values.append(json.dumps(updates.filters, sort_keys=True))
+
+            if updates.sort is not None:
+                update_fields.append("sort = ?")
+                update_values.append(updates.sort)
+
+            if updates.icon is not None:
+                update_fields.append("icon = ?")
+                update_values.append(updates.icon or None)
+
+            with self.db.get_connection() as conn:
+                if update_fields:
+                    update_fields.append("updated_at = ?")
+                    update_values.append(datetime.now())
+                    conn.execute(
+                        f"""
+                        UPDATE saved_views
+                        SET {', '.join(update_fields)}
+                        WHERE id = ? AND user_id = ?
+                        """,
+                        update_values + [view_id, user_id]
+                    )
+                    conn.commit()
+
+                row = conn.execute(
+                    "SELECT * FROM saved_views WHERE id = ? AND user_id = ?",
+                    (view_id, user_id)
+                ).fetchone()
+                return self._row_to_view(row) if row else None
+
+        return await loop.run_in_executor(None, _update_view_sync)
+
+    async def delete_view(self, view_id: int, user_id: int) -> bool:
+        """Delete a saved view."""
+        loop = asyncio.get_event_loop()
+
+        def _delete_view_sync():
+            with self.db.get_connection() as conn:
+                cursor = conn.execute(
+                    "DELETE FROM saved_views WHERE id = ? AND user_id = ?",
+                    (view_id, user_id)
+                )
+                conn.commit()
+                return cursor.rowcount > 0
+
+        return await loop.run_in_executor(None, _delete_view_sync)
+
+    def _row_to_view(self, row: sqlite3.Row) -> SavedView:
+        """Convert database row to SavedView model."""
+        return SavedView(
+            id=row["id"],
+            name=row["name"],
+            filters=json.loads(row["filters"]),
+            sort=row["sort"],
+            icon=row["icon"],
+            created_at=row["created_at"],
+            updated_at=row["updated_at"]
+        )
+
+
+# Dependency for FastAPI
+def get_saved_view_service() -> SavedViewService:
+    """FastAPI dependency for saved view service."""
+    return SavedViewService()
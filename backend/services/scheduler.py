@@ -0,0 +1,224 @@
+"""Periodic background jobs for FastAPI Email Helper API.
+
+Work repeated while the API runs (quarantine purges, activity log and AI
+trace pruning, task reminders) is registered with the JobScheduler as a
+ScheduledJob: a name, a coroutine function doing one pass, and the
+seconds between passes. Once started, the scheduler runs each job's first
+pass right away and then one pass per interval, recording when the job
+last ran, how long it took, and any error.
+
+A pass that raises is logged and recorded as the job's last error; the
+job runs again next interval and the other jobs are unaffected. A job
+never runs twice at once: a scheduled pass that comes due while another
+is still running is skipped, and a manual run is refused. On shutdown the
+scheduler stops scheduling and gives running passes a grace period to
+finish before cancelling them.
+
+GET /api/admin/jobs lists the jobs; POST /api/admin/jobs/{name}/run
+starts a pass now.
+"""
+
+import asyncio
+import logging
+import time
+from dataclasses import dataclass
+from datetime import datetime, timedelta
+from typing import Any, Awaitable, Callable, Dict, List, Optional
+
+from backend.core.errors import ConflictError, NotFoundError
+from backend.models.scheduled_job import ScheduledJobStatus
+
+logger = logging.getLogger(__name__)
+
+# Seconds running passes get to finish on shutdown before they are cancelled
+SHUTDOWN_GRACE_SECONDS = 10.0
+
+
+@dataclass
+class ScheduledJob:
+    """Periodic work run by the JobScheduler."""
+    name: str
+    run: Callable[[], Awaitable[Any]]
+    interval_seconds: float
+    description: str = ""
+
+
+class _JobState:
+    """A registered job and what its passes did."""
+
+    def __init__(self, job: ScheduledJob):
+        self.job = job
+        self.loop: Optional[asyncio.Task] = None
+        self.current: Optional[asyncio.Task] = None
+        self.run_count = 0
+        self.failure_count = 0
+        self.skipped_count = 0
+        self.last_started_at: Optional[datetime] = None
+        self.last_finished_at: Optional[datetime] = None
+        self.last_duration_ms: Optional[float] = None
+        self.last_error: Optional[str] = None
+        self.next_run_at: Optional[datetime] = None
+
+    @property
+    def running(self) -> bool:
+        return self.current is not None and not self.current.done()
+
+    def status(self) -> ScheduledJobStatus:
+        return ScheduledJobStatus(
+            name=self.job.name,
+            description=self.job.description,
+            interval_seconds=self.job.interval_seconds,
+            running=self.running,
+            run_count=self.run_count,
+            failure_count=self.failure_count,
+            skipped_count=self.skipped_count,
+            last_started_at=self.last_started_at,
+            last_finished_at=self.last_finished_at,
+            last_duration_ms=self.last_duration_ms,
+            last_error=self.last_error,
+            next_run_at=self.next_run_at
+        )
+
+
+class JobScheduler:
+    """Runs registered jobs periodically and tracks their status."""
+
+    def __init__(self):
+        self._jobs: Dict[str, _JobState] = {}
+        self._started = False
+
+    def add(self, job: ScheduledJob) -> None:
+        """Register a job, scheduling it right away if the scheduler is running.
+
+        Raises:
+            ValueError: If a job with the same name is registered or the interval is not positive
+        """
+        if job.name in self._jobs:
+            raise ValueError(f"Job {job.name} is already registered")
+        if job.interval_seconds <= 0:
+            raise ValueError(f"Job {job.name} needs a positive interval")
+        state = self._jobs[job.name] = _JobState(job)
+        if self._started:
+            self._schedule(state)
+
+    def start(self) -> None:
+        """Start scheduling the registered jobs (needs a running event loop)."""
+        self._started = True
+        for state in self._jobs.values():
+            if state.loop is None:
+                self._schedule(state)
+
+    async def stop(self, grace_seconds: float = SHUTDOWN_GRACE_SECONDS) -> None:
+        """Stop scheduling, wait for running passes, and forget the jobs.
+
+        Args:
+            grace_seconds: Time running passes get to finish before they are cancelled
+        """
+        self._started = False
+        states = list(self._jobs.values())
+        for state in states:
+            if state.loop is not None:
+                state.loop.cancel()
+        running = [state.current for state in states if state.running]
+        if running:
+            _, pending = await asyncio.wait(running, timeout=grace_seconds)
+            for task in pending:
+                logger.warning(f"Cancelling background job still running at shutdown: {task.get_name()}")
+                task.cancel()
+            await asyncio.gather(*pending, return_exceptions=True)
+        await asyncio.gather(*(state.loop for state in states if state.loop is not None), return_exceptions=True)
+        self._jobs.clear()
+
+    def statuses(self) -> List[ScheduledJobStatus]:
+        """Status of every registered job, by name."""
+        return [self._jobs[name].status() for name in sorted(self._jobs)]
+
+    def status(self, name: str) -> ScheduledJobStatus:
+        """Status of one job.
+
+        Raises:
+            NotFoundError: If no job has that name
+        """
+        return self._state(name).status()
+
+    def trigger(self, name: str) -> ScheduledJobStatus:
+        """Start a pass of a job now, in the background.
+
+        Returns:
+            The job's status, now running
+
+        Raises:
+            NotFoundError: If no job has that name
+            ConflictError: If the job is already running
+        """
+        state = self._state(name)
+        if state.running:
+            raise ConflictError(
+                f"Background job '{name}' is already running", current=state.status().model_dump(mode="json")
+            )
+        self._spawn(state)
+        return state.status()
+
+    async def run_job(self, name: str) -> bool:
+        """Run a pass of a job and wait for it, unless the job is already running.
+
+        Returns:
+            False if the pass was skipped because another was still running
+        """
+        state = self._state(name)
+        if state.running:
+            state.skipped_count += 1
+            logger.info(f"Skipping background job {name}: the previous pass is still running")
+            return False
+        # Shielded so cancelling the caller (the schedule on shutdown) leaves the pass to finish
+        await asyncio.shield(self._spawn(state))
+        return True
+
+    def _state(self, name: str) -> _JobState:
+        state = self._jobs.get(name)
+        if state is None:
+            raise NotFoundError(f"Background job '{name}' not found")
+        return state
+
+    def _schedule(self, state: _JobState) -> None:
+        state.next_run_at = datetime.utcnow()
+        state.loop = asyncio.create_task(self._run_schedule(state), name=f"schedule:{state.job.name}")
+
+    def _spawn(self, state: _JobState) -> asyncio.Task:
+        state.current = asyncio.create_task(self._execute(state), name=state.job.name)
+        return state.current
+
+    async def _run_schedule(self, state: _JobState) -> None:
+        interval = state.job.interval_seconds
+        try:
+            while True:
+                await self.run_job(state.job.name)
+                state.next_run_at = datetime.utcnow() + timedelta(seconds=interval)
+                await asyncio.sleep(interval)
+        finally:
+            state.next_run_at = None
+
+    async def _execute(self, state: _JobState) -> None:
+        state.last_started_at = datetime.utcnow()
+        started = time.perf_counter()
+        try:
+            await state.job.run()
+            state.last_error = None
+        except Exception as e:
+            state.failure_count += 1
+            state.last_error = f"{type(e).__name__}: {e}"
+            logger.exception(f"Background job {state.job.name} failed: {e}")
+        finally:
+            state.run_count += 1
+            state.last_finished_at = datetime.utcnow()
+            state.last_duration_ms = round((time.perf_counter() - started) * 1000, 3)
+
+
+# Scheduler for the jobs the API runs (see backend.main)
+job_scheduler = JobScheduler()
+
+
+# Dependency for FastAPI
+def get_job_scheduler() -> JobScheduler:
+    """FastAPI dependency for the background job scheduler."""
+    return job_scheduler
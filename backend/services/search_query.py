@@ -0,0 +1,159 @@
+"""Natural language email search for FastAPI Email Helper API.
+
+POST /api/ai/search-query turns a request such as "mails from dana about
+the reorg last month" into the parameters GET /api/emails/search takes
+(q, sender, category, received_after, received_before). Both AI services
+(standard and COM) ask the search_query prompty template for them; this
+module builds its inputs and checks what comes back.
+
+The model's output is never trusted: every value is checked against the
+filter schema (see backend.core.email_filters), unknown names, made-up
+categories, and unparseable dates are dropped, and a reversed date range
+is dropped entirely. If the model fails, returns something that is not
+JSON, or leaves no usable filter, the request itself becomes a plain
+free-text query, so a search is always possible.
+"""
+
+import json
+import logging
+import re
+from datetime import date, datetime
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from backend.core.email_filters import MAX_FILTER_LENGTH, parse_received_bound
+from backend.models.ai_models import SearchQueryFilters
+
+logger = logging.getLogger(__name__)
+
+SEARCH_QUERY_TEMPLATE = "search_query.prompty"
+
+# Parameters of GET /api/emails/search the model may fill in
+SEARCH_QUERY_FIELDS = ("q", "sender", "category", "received_after", "received_before")
+
+def build_search_query_inputs(request: str, categories: Sequence[str], today: date) -> Dict[str, Any]:
+    """Build search_query prompty inputs."""
+    return {
+        "request": request,
+        "today": f"{today.isoformat()} ({today.strftime('%A')})",
+        "categories": "\n".join(f"- {name}" for name in categories),
+    }
+
+
+def parse_search_query(raw: Any) -> Dict[str, Any]:
+    """Parse search_query output into a dict of raw filter values.
+
+    Accepts a dict or a JSON string, optionally wrapped in a code fence.
+    Values are not checked here; see validate_search_filters.
+
+    Raises:
+        ValueError: If the output is not a JSON object
+    """
+    if isinstance(raw, str):
+        text = raw.strip()
+        fenced = re.search(r"```(?:json)?\s*(.*?)```", text, re.DOTALL)
+        if fenced:
+            text = fenced.group(1).strip()
+        try:
+            raw = json.loads(text)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Search query builder returned invalid JSON: {e}")
+
+    if not isinstance(raw, dict):
+        raise ValueError("Search query builder returned a non-object response")
+    return raw
+
+
+def validate_search_filters(
+    raw: Dict[str, Any],
+    categories: Sequence[str]
+) -> Tuple[Dict[str, str], List[str]]:
+    """Keep the model's filters that fit the search schema.
+
+    Args:
+        raw: Output of parse_search_query
+        categories: Category names the category filter may take
+
+    Returns:
+        The valid filters, and the names of those dropped
+    """
+    known_categories = {name.lower(): name for name in categories}
+    filters: Dict[str, str] = {}
+    dropped = sorted(name for name, value in raw.items() if name not in SEARCH_QUERY_FIELDS and value is not None)
+
+    for name in SEARCH_QUERY_FIELDS:
+        value = raw.get(name)
+        if value is None:
+            continue
+        value = value.strip() if isinstance(value, str) else None
+        if value == "":
+            continue
+        if value is None or len(value) > MAX_FILTER_LENGTH:
+            dropped.append(name)
+            continue
+        if name == "category":
+            value = known_categories.get(value.lower())
+        elif name.startswith("received_"):
+            value = _received_bound(value)
+        if value is None:
+            dropped.append(name)
+            continue
+        filters[name] = value
+
+    after, before = filters.get("received_after"), filters.get("received_before")
+    if after and before and _naive(parse_received_bound(after)) >= _naive(parse_received_bound(before)):
+        del filters["received_after"], filters["received_before"]
+        dropped += ["received_after", "received_before"]
+    return filters, dropped
+
+
+def _received_bound(value: str) -> Optional[str]:
+    """The value if search accepts it as a received bound, else None."""
+    try:
+        parse_received_bound(value)
+    except ValueError:
+        return None
+    return value
+
+
+def _naive(value: datetime) -> datetime:
+    return value.replace(tzinfo=None)
+
+
+def fallback_filters(request: str) -> Dict[str, str]:
+    """Filters searching for the request text itself."""
+    return {"q": " ".join(request.split())[:MAX_FILTER_LENGTH]}
+
+
+async def build_search_query(
+    ai_service,
+    request: str,
+    categories: Sequence[str],
+    today: date
+) -> Tuple[SearchQueryFilters, bool, List[str]]:
+    """Turn a natural language request into search filters.
+
+    Args:
+        ai_service: AI service with generate_search_query
+        request: What the user is looking for
+        categories: Category names the category filter may take
+        today: The user's current date, for relative dates
+
+    Returns:
+        The filters, whether they fell back to a free-text query of the
+        request, and the names of filters dropped from the model's output
+    """
+    filters: Dict[str, str] = {}
+    dropped: List[str] = []
+    try:
+        result = await ai_service.generate_search_query(request=request, categories=list(categories), today=today)
+        raw = result.get("filters")
+        if "error" in result:
+            logger.warning(f"Search query generation failed: {result['error']}")
+        elif isinstance(raw, dict):
+            filters, dropped = validate_search_filters(raw, categories)
+    except Exception as e:
+        logger.warning(f"Search query generation failed: {e}")
+
+    if not filters:
+        return SearchQueryFilters(**fallback_filters(request)), True, dropped
+    return SearchQueryFilters(**filters), False, dropped
@@ -0,0 +1,132 @@
+"""Sender domain analytics for FastAPI Email Helper API.
+
+Most noise comes from a handful of domains. GET /api/emails/domains
+reports, per sender domain, the stored email total, how many are unread
+or unclassified, the domain's most common AI category, and the share
+categorized spam_to_delete. POST /api/emails/domains/{domain}/apply-category
+gives every unclassified email from a domain a category as one bulk
+correction, can file them in the mail client as a revertible move batch,
+and can add a line to the category's classification rules so future mail
+from the domain follows.
+
+Senders are grouped by registrable domain: mail.news.contoso.com counts
+as contoso.com, and a two-level suffix such as co.uk keeps its third
+label. The domain is taken from the bare address, so plus-addressed
+senders (alice+news@contoso.com) and display names do not matter.
+Grouping is a pure function of the stored rows so it can be tested
+without a database.
+"""
+
+from collections import Counter
+from email.utils import parseaddr
+from typing import Any, Dict, Iterable, List, Optional
+
+from backend.core.errors import InputValidationError
+from backend.models.category import CategoryUpdate
+from backend.models.email import SenderDomainStats
+from backend.services.category_service import CategoryService
+from backend.services.quarantine import SPAM_CATEGORY
+from backend.services.rule_import import MAX_DESCRIPTION_LENGTH
+
+# Public suffixes under which registrable domains have three labels
+TWO_LEVEL_SUFFIXES = frozenset({
+    "co.uk", "org.uk", "ac.uk", "gov.uk", "com.au", "net.au", "org.au", "co.nz", "co.jp", "co.in",
+    "co.za", "com.br", "com.mx", "com.cn", "com.sg",
+})
+
+
+def normalize_domain(domain: Optional[str]) -> Optional[str]:
+    """Lowercased domain without a leading "@" or surrounding dots (None if empty)."""
+    domain = (domain or "").strip().lstrip("@").strip(".").lower()
+    return domain or None
+
+
+def address_host(sender: Optional[str]) -> Optional[str]:
+    """Full domain of a sender ("Name <a+tag@mail.x.com>" gives mail.x.com)."""
+    address = parseaddr(sender or "")[1]
+    if "@" not in address:
+        return None
+    return normalize_domain(address.rsplit("@", 1)[1])
+
+
+def registrable_domain(host: Optional[str]) -> Optional[str]:
+    """Domain a host belongs to, without its subdomains."""
+    host = normalize_domain(host)
+    if host is None:
+        return None
+    labels = host.split(".")
+    keep = 3 if ".".join(labels[-2:]) in TWO_LEVEL_SUFFIXES else 2
+    return ".".join(labels[-keep:])
+
+
+def sender_domain(sender: Optional[str]) -> Optional[str]:
+    """Registrable domain of a sender, or None if it has no address."""
+    return registrable_domain(address_host(sender))
+
+
+def from_domain(sender: Optional[str], domain: str) -> bool:
+    """Whether a sender's address is at domain or one of its subdomains."""
+    host = address_host(sender)
+    domain = normalize_domain(domain)
+    return host is not None and domain is not None and (host == domain or host.endswith(f".{domain}"))
+
+
+def summarize_domains(rows: Iterable[Dict[str, Any]]) -> List[SenderDomainStats]:
+    """Per-domain totals of stored email rows, largest domain first.
+
+    Args:
+        rows: Rows with sender, is_read, category, and ai_category; rows
+            without a sender address are left out
+
+    Returns:
+        One entry per domain, by total (then domain name)
+    """
+    grouped: Dict[str, List[Dict[str, Any]]] = {}
+    for row in rows:
+        domain = sender_domain(row.get("sender"))
+        if domain is not None:
+            grouped.setdefault(domain, []).append(row)
+
+    report = []
+    for domain, emails in grouped.items():
+        ai_categories = Counter(email["ai_category"] for email in emails if email.get("ai_category"))
+        report.append(SenderDomainStats(
+            domain=domain,
+            total=len(emails),
+            unread=sum(1 for email in emails if not email.get("is_read")),
+            unclassified=sum(1 for email in emails if not email.get("category")),
+            # most_common keeps first-seen order on ties
+            dominant_category=ai_categories.most_common(1)[0][0] if ai_categories else None,
+            spam_ratio=round(sum(1 for email in emails if email.get("category") == SPAM_CATEGORY) / len(emails), 4)
+        ))
+    report.sort(key=lambda entry: (-entry.total, entry.domain))
+    return report
+
+
+def domain_rule_text(domain: str) -> str:
+    """Classification rule line filing a domain's mail into a category."""
+    return f'Emails from a sender address at "{domain}" or any of its subdomains (domain rule)'
+
+
+async def add_domain_rule(category_service: CategoryService, category: str, domain: str) -> bool:
+    """Append the domain's rule line to a category's classification rules.
+
+    Returns:
+        False if the category already has the line
+
+    Raises:
+        LookupError: If the category does not exist
+        InputValidationError: If the line would push the rules past MAX_DESCRIPTION_LENGTH
+    """
+    existing = await category_service.get_category(category)
+    if existing is None:
+        raise LookupError(f"Category {category} not found")
+    rule = domain_rule_text(domain)
+    description = existing.description or ""
+    if rule in (line.strip() for line in description.splitlines()):
+        return False
+    updated = f"{description.rstrip()}\n{rule}" if description.strip() else rule
+    if len(updated) > MAX_DESCRIPTION_LENGTH:
+        raise InputValidationError(f"The rules of {category} would exceed {MAX_DESCRIPTION_LENGTH} characters")
+    await category_service.update_category(category, CategoryUpdate(description=updated))
+    return True
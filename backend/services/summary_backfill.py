@@ -0,0 +1,143 @@
+"""One-line summary backfill for FastAPI Email Helper API.
+
+Emails synced before AI was configured have no one-line summary. The
+backfill selects those rows, summarizes them with bounded concurrency and
+writes each summary back as soon as it is ready. Rows that already have a
+summary are never selected or overwritten, so an interrupted backfill is
+resumed simply by running it again.
+
+summarize_emails runs the same bounded-concurrency loop for the batch
+summary endpoint, returning results instead of storing them. The backfill
+strips each sender's learned boilerplate before summarizing (see
+backend.services.boilerplate); the batch endpoint strips it before calling
+summarize_emails. Both mask secrets in the text sent to the model (see
+backend.core.redaction) and skip rights-managed emails (see
+backend.core.protection).
+"""
+
+import asyncio
+import logging
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Tuple
+
+from backend.core.protection import PROTECTED_REASON, ai_allowed
+from backend.core.redaction import redact_secrets
+from backend.services.ai_traces import trace_email
+from backend.services.boilerplate import BoilerplateStore
+from backend.services.email_service import EmailService
+
+logger = logging.getLogger(__name__)
+
+ProgressCallback = Callable[[Dict[str, Any]], Awaitable[None]]
+
+
+def _summary_input(email: Dict[str, Any]) -> Tuple[str, int]:
+    """Format a stored email the way generate_summary expects, with secrets masked.
+
+    Returns:
+        The text and the number of secrets masked
+    """
+    return redact_secrets(
+        f"Subject: {email.get('subject') or ''}\n"
+        f"From: {email.get('sender') or ''}\n\n"
+        f"{email.get('content') or ''}"
+    )
+
+
+async def backfill_summaries(
+    emails: List[Dict[str, Any]],
+    ai_service,
+    email_service: EmailService,
+    user_id: int,
+    concurrency: int = 4,
+    on_progress: Optional[ProgressCallback] = None
+) -> Dict[str, int]:
+    """Summarize emails and store the results.
+
+    Args:
+        emails: Stored email rows from get_emails_missing_summary
+        ai_service: AI service providing generate_summary
+        email_service: Email service used to write summaries
+        user_id: Owner of the stored emails
+        concurrency: Maximum summaries generated at once
+        on_progress: Awaited after each email with running totals
+
+    Returns:
+        Totals: total, processed, updated, failed, skipped
+    """
+    totals = {"total": len(emails), "processed": 0, "updated": 0, "failed": 0, "skipped": 0}
+    semaphore = asyncio.Semaphore(max(1, concurrency))
+
+    async def _summarize(email: Dict[str, Any]):
+        async with semaphore:
+            try:
+                with trace_email(email["id"], user_id):
+                    result = await ai_service.generate_summary(
+                        email_content=_summary_input(email)[0],
+                        summary_type="brief"
+                    )
+                summary = (result.get("summary") or "").strip()
+                if "error" in result or not summary:
+                    raise RuntimeError(result.get("error", "empty summary"))
+
+                if await email_service.update_email_summary(email["id"], user_id, summary):
+                    totals["updated"] += 1
+            except Exception as e:
+                logger.warning(f"Summary backfill failed for {email['id']}: {e}")
+                totals["failed"] += 1
+
+            totals["processed"] += 1
+            if on_progress:
+                await on_progress({**totals, "email_id": email["id"]})
+
+    allowed = [email for email in emails if ai_allowed(email)]
+    totals["skipped"] = totals["processed"] = len(emails) - len(allowed)
+    stripped = await BoilerplateStore(email_service.db).strip_emails(user_id, allowed)
+    await asyncio.gather(*(_summarize(email) for email in stripped))
+    return totals
+
+
+async def summarize_emails(
+    emails: List[Dict[str, Any]],
+    ai_service,
+    summary_type: str = "brief",
+    concurrency: int = 4
+) -> Dict[str, Dict[str, Any]]:
+    """Summarize stored emails without writing anything back.
+
+    Args:
+        emails: Stored email rows
+        ai_service: AI service providing generate_summary
+        summary_type: brief, detailed, or action_focused
+        concurrency: Maximum summaries generated at once
+
+    Returns:
+        Email ID to {summary, key_points, redactions_applied}, {error} if
+        summarizing failed, or {skipped} with the reason it was not sent
+    """
+    results: Dict[str, Dict[str, Any]] = {}
+    semaphore = asyncio.Semaphore(max(1, concurrency))
+
+    async def _summarize(email: Dict[str, Any]):
+        if not ai_allowed(email):
+            results[email["id"]] = {"skipped": PROTECTED_REASON}
+            return
+        async with semaphore:
+            try:
+                email_content, redactions = _summary_input(email)
+                result = await ai_service.generate_summary(
+                    email_content=email_content,
+                    summary_type=summary_type
+                )
+                summary = (result.get("summary") or "").strip()
+                if "error" in result or not summary:
+                    raise RuntimeError(result.get("error", "empty summary"))
+
+                results[email["id"]] = {
+                    "summary": summary, "key_points": result.get("key_points", []), "redactions_applied": redactions
+                }
+            except Exception as e:
+                logger.warning(f"Batch summary failed for {email['id']}: {e}")
+                results[email["id"]] = {"error": str(e)}
+
+    await asyncio.gather(*(_summarize(email) for email in emails))
+    return {email["id"]: results[email["id"]] for email in emails}
@@ -0,0 +1,207 @@
+"""Summary modes for FastAPI Email Helper API.
+
+POST /api/ai/summarize supports four summary_type values:
+
+- brief / detailed: the email_one_line_summary template
+- action_focused: what the user has to do, by when, and who is waiting on
+  it, from the email_action_summary template
+- thread: a digest of a whole conversation, given by conversation_id
+
+Thread summaries load the conversation's emails, order them oldest first,
+and fit them into a token budget before they go to the
+email_thread_summary template. The opening message and the newest replies
+are kept whole as long as possible; when the thread is too long, message
+bodies are shortened (longest first) and, if even that is not enough, the
+oldest replies after the opening message are left out. Each sender's
+learned boilerplate is stripped first (see backend.services.boilerplate),
+then secrets are masked (see backend.core.redaction). Rights-managed
+messages (see backend.core.protection) are left out of the thread; a
+thread with nothing else is refused.
+
+Both AI services (standard and COM) use the helpers here so the modes
+behave alike.
+"""
+
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from backend.core.errors import InputValidationError, ProtectedContentError
+from backend.core.protection import ai_allowed
+from backend.core.redaction import redact_emails
+from backend.services.boilerplate import BoilerplateStore
+
+ONE_LINE_TEMPLATE = "email_one_line_summary.prompty"
+ACTION_TEMPLATE = "email_action_summary.prompty"
+THREAD_TEMPLATE = "email_thread_summary.prompty"
+
+SUMMARY_TEMPLATES = {
+    "brief": ONE_LINE_TEMPLATE,
+    "detailed": ONE_LINE_TEMPLATE,
+    "action_focused": ACTION_TEMPLATE,
+}
+
+# Every summary_type POST /api/ai/summarize accepts
+SUMMARY_TYPES = [*SUMMARY_TEMPLATES, "thread"]
+
+# Rough prompt size estimate used for the thread budget
+CHARS_PER_TOKEN = 4
+
+# Bodies are never shortened below this; messages are dropped instead
+MIN_BODY_CHARS = 200
+
+
+def summary_template(summary_type: str) -> str:
+    """Prompty template for a single-email summary type.
+
+    Raises:
+        InputValidationError: If the type is unknown or needs a conversation (thread)
+    """
+    if summary_type not in SUMMARY_TEMPLATES:
+        raise InputValidationError(
+            f"Unknown summary type '{summary_type}' for one email "
+            f"(expected {', '.join(SUMMARY_TEMPLATES)})"
+        )
+    return SUMMARY_TEMPLATES[summary_type]
+
+
+def split_summary(text: str) -> Tuple[str, List[str]]:
+    """Split templated summary output into the summary and its "- " bullet lines."""
+    summary_lines, key_points = [], []
+    for line in (text or "").splitlines():
+        line = line.strip()
+        if line.startswith(("- ", "* ")):
+            point = line[2:].strip()
+            if point:
+                key_points.append(point)
+        elif line:
+            summary_lines.append(line)
+    return " ".join(summary_lines), key_points
+
+
+def _received(email: Dict[str, Any]) -> str:
+    """Sort key for message order within a thread (ISO timestamps sort as text)."""
+    return str(email.get("received_time") or email.get("received_date") or "")
+
+
+def _body(email: Dict[str, Any]) -> str:
+    return " ".join(str(email.get("body") or email.get("content") or "").split())
+
+
+def _header(email: Dict[str, Any]) -> str:
+    return (
+        f"[{_received(email) or 'Unknown date'}] From: {email.get('sender') or 'Unknown'}"
+        f" | Subject: {email.get('subject') or '(no subject)'}"
+    )
+
+
+def _fit_bodies(lengths: Sequence[int], budget: int) -> List[int]:
+    """Cap body lengths to total at most budget, shortening the longest first."""
+    caps = list(lengths)
+    if sum(caps) <= budget:
+        return caps
+
+    remaining = max(0, budget)
+    order = sorted(range(len(caps)), key=lambda i: caps[i])
+    for position, index in enumerate(order):
+        caps[index] = min(caps[index], remaining // (len(order) - position))
+        remaining -= caps[index]
+    return caps
+
+
+def build_thread_transcript(
+    emails: Sequence[Dict[str, Any]],
+    max_tokens: int
+) -> Tuple[str, int]:
+    """Lay a conversation out oldest first within a token budget.
+
+    Args:
+        emails: The conversation's emails, in any order
+        max_tokens: Estimated prompt tokens the transcript may use
+
+    Returns:
+        The transcript and how many messages were left out of it
+    """
+    messages = sorted(emails, key=_received)
+    budget = max_tokens * CHARS_PER_TOKEN
+
+    def fixed_cost(kept: Sequence[Dict[str, Any]]) -> int:
+        # Header line plus the blank line between messages
+        return sum(len(_header(email)) + 2 for email in kept)
+
+    # Keep the opening message and drop the oldest replies until every
+    # remaining body can have at least MIN_BODY_CHARS
+    kept = list(messages)
+    while len(kept) > 2 and fixed_cost(kept) + MIN_BODY_CHARS * len(kept) > budget:
+        del kept[1]
+    omitted = len(messages) - len(kept)
+
+    bodies = [_body(email) for email in kept]
+    caps = _fit_bodies([len(body) for body in bodies], budget - fixed_cost(kept))
+
+    blocks = []
+    for index, (email, body, cap) in enumerate(zip(kept, bodies, caps)):
+        if len(body) > cap:
+            body = body[:cap].rstrip() + "..."
+        blocks.append(f"{_header(email)}\n{body}")
+        if index == 0 and omitted:
+            blocks.append(f"({omitted} earlier {'reply' if omitted == 1 else 'replies'} omitted)")
+
+    return "\n\n".join(blocks), omitted
+
+
+def build_thread_inputs(
+    emails: Sequence[Dict[str, Any]],
+    max_tokens: int,
+    username: Optional[str] = None
+) -> Tuple[Dict[str, Any], int]:
+    """Build email_thread_summary prompty inputs for a conversation.
+
+    Returns:
+        The inputs and how many messages were left out to fit the budget
+    """
+    ordered = sorted(emails, key=_received)
+    transcript, omitted = build_thread_transcript(ordered, max_tokens)
+    participants = list(dict.fromkeys(email.get("sender") or "Unknown" for email in ordered))
+    return {
+        "username": username or "User",
+        "subject": (ordered[0].get("subject") if ordered else None) or "(no subject)",
+        "participants": ", ".join(participants),
+        "message_count": str(len(ordered)),
+        "messages": transcript,
+    }, omitted
+
+
+async def summarize_conversation(
+    email_service,
+    ai_service,
+    conversation_id: str,
+    user_id: int,
+    max_tokens: int
+) -> Optional[Dict[str, Any]]:
+    """Summarize a whole conversation thread.
+
+    Args:
+        email_service: EmailService the conversation is loaded from
+        ai_service: AI service providing summarize_thread
+        conversation_id: Conversation to summarize (any merged member's ID works)
+        user_id: Owner of the conversation
+        max_tokens: Token budget for the thread's messages
+
+    Returns:
+        summarize_thread's result with redactions_applied, or None if the
+        conversation has no emails
+
+    Raises:
+        ProtectedContentError: If every message of the conversation is rights-managed
+    """
+    emails = await email_service.get_conversation(conversation_id, user_id=user_id)
+    if not emails:
+        return None
+    allowed = [email for email in emails if ai_allowed(email)]
+    if not allowed:
+        raise ProtectedContentError(f"Every message of conversation {conversation_id} has protected content")
+    emails = allowed
+    emails = await BoilerplateStore(email_service.db).strip_emails(user_id, emails)
+    # Before build_thread_inputs shortens bodies, so a secret is never cut in half and half sent
+    emails, redactions = redact_emails(emails)
+    result = await ai_service.summarize_thread(emails, max_tokens=max_tokens)
+    return {**result, "redactions_applied": redactions}
@@ -0,0 +1,80 @@
+"""Batch task extraction for FastAPI Email Helper API.
+
+POST /api/ai/extract-tasks extracts the action items of several stored
+emails and turns them into tasks through
+TaskService.create_action_item_tasks, so an email that already has a task
+for an item does not get it again. Results are per email in request
+order, each with the tasks created, the items skipped as duplicates, and
+the extraction confidence, or the error or skip reason when nothing was
+made, so the UI can show "3 tasks from this email, 0 from that one".
+
+The response's summary totals and flat tasks list are what clients of a
+single task list read; the flat list is deprecated in favor of results.
+"""
+
+from typing import Any, Dict, List, Optional
+
+from backend.core.errors import UpstreamAIError
+from backend.core.protection import PROTECTED_REASON, ai_allowed
+from backend.core.redaction import redact_secrets
+from backend.models.task import TaskExtractionResult, TaskExtractionSummary
+from backend.services.auto_tasks import format_email_content, task_links_description
+
+EXTRACTED_TASK_SOURCE = "extracted"
+
+
+async def extract_email_tasks(
+    email: Dict[str, Any],
+    ai_service,
+    task_service,
+    user_id: int,
+    context: Optional[str] = None
+) -> TaskExtractionResult:
+    """Extract one stored email's action items and create their tasks.
+
+    Args:
+        email: Stored email row
+        ai_service: AI service providing extract_action_items
+        task_service: TaskService used to create the tasks
+        user_id: Owner of the tasks
+        context: Additional context for extraction
+
+    Returns:
+        The tasks created and the items skipped as duplicates, or the skip
+        reason for a protected email
+
+    Raises:
+        UpstreamAIError: If the AI returned no action items and an error
+    """
+    email_id = email["id"]
+    if not ai_allowed(email):
+        return TaskExtractionResult(email_id=email_id, skipped=PROTECTED_REASON)
+
+    email_content, redactions = redact_secrets(format_email_content(email))
+    result = await ai_service.extract_action_items(email_content=email_content, context=context)
+    action_items = result.get("action_items") or []
+    if "error" in result and not action_items:
+        raise UpstreamAIError(f"Action item extraction failed: {result['error']}")
+
+    outcome = await task_service.create_action_item_tasks(
+        email_id, action_items, user_id, source=EXTRACTED_TASK_SOURCE,
+        description=task_links_description(email), category=email.get("category"), ai_service=ai_service
+    )
+    return TaskExtractionResult(
+        email_id=email_id,
+        tasks=outcome.created,
+        duplicates_skipped=outcome.duplicates,
+        confidence=result.get("confidence"),
+        redactions_applied=redactions
+    )
+
+
+def summarize_results(results: List[TaskExtractionResult]) -> TaskExtractionSummary:
+    """Totals over the per-email results."""
+    return TaskExtractionSummary(
+        email_count=len(results),
+        tasks_created=sum(len(result.tasks) for result in results),
+        duplicates_skipped=sum(len(result.duplicates_skipped) for result in results),
+        failure_count=sum(1 for result in results if result.error),
+        skipped_count=sum(1 for result in results if result.skipped)
+    )
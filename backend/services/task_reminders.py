@@ -0,0 +1,81 @@
+"""Task due-date reminders for FastAPI Email Helper API.
+
+While the API is running, a background job checks for open tasks that
+fall due within task_reminder_lead_minutes and publishes a task_reminder
+notification for each. A task is reminded once per due date: the
+reminder is recorded in tasks.reminded_at, which is cleared when the due
+date changes. Tasks already past due when the check runs (e.g. created
+overdue, or due while the API was stopped) are not reminded. The due
+time in the notification is shown in the task owner's time zone.
+"""
+
+import asyncio
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional
+
+from backend.core.clock import clock_for
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager
+from backend.models.task import TaskStatus
+from backend.services.event_bus import notify
+from backend.services.user_settings_service import user_clock
+
+logger = logging.getLogger(__name__)
+
+_CLOSED_STATUSES = (TaskStatus.COMPLETED.value, TaskStatus.CANCELLED.value)
+
+
+def claim_due_reminders(db: DatabaseManager, now: datetime, lead_minutes: int) -> List[Dict[str, Any]]:
+    """Mark and return the open tasks whose reminder should fire now."""
+    with db.get_connection() as conn:
+        rows = [dict(row) for row in conn.execute(
+            """
+            SELECT id, user_id, title, priority, due_date FROM tasks
+            WHERE due_date IS NOT NULL AND reminded_at IS NULL AND status NOT IN (?, ?)
+              AND datetime(due_date) BETWEEN datetime(?) AND datetime(?)
+            ORDER BY datetime(due_date), id
+            """,
+            (*_CLOSED_STATUSES, now.isoformat(), (now + timedelta(minutes=lead_minutes)).isoformat())
+        )]
+        conn.executemany(
+            "UPDATE tasks SET reminded_at = ? WHERE id = ?",
+            [(now, row["id"]) for row in rows]
+        )
+        conn.commit()
+    return rows
+
+
+async def fire_task_reminders(
+    db: DatabaseManager, lead_minutes: int, now: Optional[datetime] = None
+) -> List[Dict[str, Any]]:
+    """Publish task_reminder notifications for tasks falling due soon.
+
+    Returns:
+        The reminded task rows
+    """
+    loop = asyncio.get_event_loop()
+    now = now or clock_for(settings.timezone).now()
+    tasks = await loop.run_in_executor(None, claim_due_reminders, db, now, lead_minutes)
+    for task in tasks:
+        due = task["due_date"] if isinstance(task["due_date"], datetime) else datetime.fromisoformat(str(task["due_date"]))
+        clock = await loop.run_in_executor(None, user_clock, db, task["user_id"])
+        due = clock.local(due)
+        await notify(
+            "task_reminder",
+            "Task due soon",
+            f"{task['title']} is due at {due.strftime('%H:%M')}",
+            user_id=task["user_id"],
+            task_id=task["id"],
+            due_date=due.isoformat(),
+            priority=task["priority"]
+        )
+    return tasks
+
+
+async def run_reminders(db: DatabaseManager, lead_minutes: int) -> int:
+    """Fire due task reminders once (the task_reminders background job)."""
+    reminded = await fire_task_reminders(db, lead_minutes)
+    if reminded:
+        logger.info(f"Sent {len(reminded)} task reminders")
+    return len(reminded)
@@ -3,17 +3,139 @@
 This module provides the business logic layer for task management operations,
 serving as an async wrapper around the existing TaskPersistence system while
 providing additional database integration for user-scoped operations.
+
+Tasks made from extracted action items get their titles from
+normalize_task_title: clean_task_title always drops reply and forward
+prefixes, leading bracketed tags, and extra whitespace, and with
+settings.ai_task_titles the AI then rewrites the cleaned text as an
+imperative title (task_title.prompty), falling back to the cleaned text
+when it fails. A task whose title differs from its item keeps the item's
+text at the start of its description.
 """
 
 import asyncio
+import json
+import logging
+import re
 import sqlite3
 from datetime import datetime
 from typing import List, Optional, Dict, Any
 
-from backend.database.connection import db_manager
-from backend.models.task import Task, TaskCreate, TaskUpdate, TaskInDB, TaskStatus, TaskPriority
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.task import Task, TaskCreate, TaskEmail, TaskUpdate, TaskInDB, TaskStatus, TaskPriority
+from backend.services.action_items import normalize_action_item, task_fields_for_item
+from backend.services.user_settings_service import user_clock
 from src.task_persistence import TaskPersistence
 
+# Stored email columns joined into a task page for include_email
+_TASK_EMAIL_COLUMNS = """,
+                       emails.id AS linked_email_id, emails.subject AS linked_email_subject,
+                       emails.sender AS linked_email_sender, emails.received_date AS linked_email_received,
+                       emails.one_line_summary AS linked_email_summary"""
+_TASK_EMAIL_JOIN = """
+                LEFT JOIN emails ON emails.id = tasks.email_id AND emails.user_id = tasks.user_id"""
+
+logger = logging.getLogger(__name__)
+
+TASK_TITLE_TEMPLATE = "task_title.prompty"
+
+# Longest task title TaskCreate accepts
+MAX_TASK_TITLE = 200
+
+# Start of the description of a task whose title was rewritten from its action item
+ITEM_TEXT_PREFIX = "Action item: "
+
+# Reply and forward markers, also as Outlook writes them in German, Nordic, French, and Spanish
+_REPLY_PREFIX = re.compile(r"^(?:(?:re|fw|fwd|aw|wg|sv|vs|tr|rv)\s*(?:\[\d+\])?\s*:\s*)+", re.IGNORECASE)
+
+# Bracketed tags before the text such as [EXTERNAL] or (Action Required)
+_LEADING_TAG = re.compile(r"^(?:\[[^\]]*\]|\([^)]*\))\s*")
+
+# Pairs a whole title may be wrapped in
+_ENCLOSING = {("[", "]"), ("(", ")"), ('"', '"'), ("'", "'")}
+
+
+def _normalize_title(title: str) -> str:
+    """Normalize a task title for duplicate detection."""
+    return " ".join(title.split()).casefold()
+
+
+def clean_task_title(text: str) -> str:
+    """Tidy action item text into a task title without the AI.
+    
+    Reply and forward prefixes and bracketed tags in front of the text are
+    removed (also when they alternate, as in "RE: [EXT] FW: budget"), as
+    are brackets or quotes around the whole title, and whitespace is
+    collapsed. Text consisting only of prefixes and tags is kept as it was.
+    
+    Returns:
+        The title, at most MAX_TASK_TITLE characters
+    """
+    original = " ".join(str(text or "").split())
+    title = original
+    while True:
+        stripped = _REPLY_PREFIX.sub("", title).strip()
+        if (stripped[:1], stripped[-1:]) in _ENCLOSING and not set(stripped[0] + stripped[-1]) & set(stripped[1:-1]):
+            stripped = stripped[1:-1].strip()
+        else:
+            stripped = _LEADING_TAG.sub("", stripped).strip()
+        if stripped == title:
+            break
+        title = stripped
+    return (title.strip(" -:") or original)[:MAX_TASK_TITLE]
+
+
+def parse_task_title(raw: Any) -> str:
+    """Parse task_title output into a cleaned title.
+    
+    Accepts a dict or a JSON string, optionally wrapped in a code fence.
+    
+    Raises:
+        ValueError: If the output has no usable title
+    """
+    if isinstance(raw, str):
+        text = raw.strip().strip("`").removeprefix("json")
+        try:
+            raw = json.loads(text)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Task title writer returned invalid JSON: {e}")
+    title = raw.get("title") if isinstance(raw, dict) else None
+    if not isinstance(title, str) or not title.strip():
+        raise ValueError("Task title writer returned no title")
+    return clean_task_title(title.strip().rstrip("."))
+
+
+async def normalize_task_title(text: str, ai_service=None) -> str:
+    """Title for a task made from an action item.
+    
+    The text is always cleaned by clean_task_title. With
+    settings.ai_task_titles on and an ai_service given, the AI rewrites the
+    cleaned text as an imperative title; if it fails, the cleaned text is
+    used.
+    
+    Args:
+        text: Action item description
+        ai_service: AI service providing rewrite_task_title
+    """
+    title = clean_task_title(text)
+    if ai_service is None or not settings.ai_task_titles:
+        return title
+    try:
+        result = await ai_service.rewrite_task_title(title)
+    except Exception as e:
+        logger.debug(f"Task title rewrite failed, keeping the cleaned title: {e}")
+        return title
+    return result.get("title") or title
+
+
+class ActionItemTasks:
+    """Tasks created from an email's action items, and the items skipped as duplicates."""
+    
+    def __init__(self, created: List[Task], duplicates: List[str]):
+        self.created = created
+        self.duplicates = duplicates  # Descriptions of the items that were not made into tasks
+
 
 class TaskListResponse:
     """Response model for paginated task lists."""
@@ -36,8 +158,13 @@ class TaskListResponse:
 class TaskService:
     """Service layer for task management operations."""
     
-    def __init__(self):
-        """Initialize the task service."""
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the task service.
+        
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
         self.task_persistence = TaskPersistence()
     
     async def create_task(self, task_data: TaskCreate, user_id: int) -> Task:
@@ -46,12 +173,12 @@ class TaskService:
         
         def _create_task_sync():
             current_time = datetime.now()
-            with db_manager.get_connection() as conn:
+            with self.db.get_connection() as conn:
                 cursor = conn.execute(
                     """
                     INSERT INTO tasks (title, description, status, priority, due_date, 
-                                     created_at, updated_at, email_id, user_id)
-                    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+                                     created_at, updated_at, email_id, user_id, source)
+                    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
                     """,
                     (
                         task_data.title,
@@ -62,7 +189,8 @@ class TaskService:
                         current_time,
                         current_time,
                         task_data.email_id,
-                        user_id
+                        user_id,
+                        task_data.source
                     )
                 )
                 
@@ -88,7 +216,7 @@ class TaskService:
         loop = asyncio.get_event_loop()
         
         def _get_task_sync():
-            with db_manager.get_connection() as conn:
+            with self.db.get_connection() as conn:
                 cursor = conn.execute(
                     "SELECT * FROM tasks WHERE id = ? AND user_id = ?",
                     (task_id, user_id)
@@ -128,6 +256,11 @@ class TaskService:
                 update_values.append(updates.priority.value)
             
             if updates.due_date is not None:
+                # SET expressions see the old row, so this compares against the stored due date
+                update_fields.append("due_date_sequence = due_date_sequence + (due_date IS NOT ?)")
+                update_values.append(updates.due_date)
+                update_fields.append("reminded_at = CASE WHEN due_date IS NOT ? THEN NULL ELSE reminded_at END")
+                update_values.append(updates.due_date)
                 update_fields.append("due_date = ?")
                 update_values.append(updates.due_date)
             
@@ -137,7 +270,7 @@ class TaskService:
             
             if not update_fields:
                 # No updates provided, return current task
-                with db_manager.get_connection() as conn:
+                with self.db.get_connection() as conn:
                     cursor = conn.execute(
                         "SELECT * FROM tasks WHERE id = ? AND user_id = ?",
                         (task_id, user_id)
@@ -158,7 +291,7 @@ class TaskService:
             WHERE id = ? AND user_id = ?
             """
             
-            with db_manager.get_connection() as conn:
+            with self.db.get_connection() as conn:
                 cursor = conn.execute(query, update_values)
                 conn.commit()
                 
@@ -180,7 +313,7 @@ class TaskService:
         loop = asyncio.get_event_loop()
         
         def _delete_task_sync():
-            with db_manager.get_connection() as conn:
+            with self.db.get_connection() as conn:
                 cursor = conn.execute(
                     "DELETE FROM tasks WHERE id = ? AND user_id = ?",
                     (task_id, user_id)
@@ -197,26 +330,51 @@ class TaskService:
         limit: int = 20,
         status: Optional[str] = None,
         priority: Optional[str] = None,
-        search: Optional[str] = None
+        search: Optional[str] = None,
+        source: Optional[str] = None,
+        include_email: bool = False,
+        overdue: bool = False,
+        now: Optional[datetime] = None
     ) -> TaskListResponse:
-        """Get paginated list of tasks with filtering."""
+        """Get paginated list of tasks with filtering.
+        
+        With include_email, each task's email is set to the stored email it
+        was created from (None when it has none or the email is not
+        stored), joined into the page query rather than looked up per task.
+        With overdue, only the open tasks get_due_counts counts as overdue
+        are listed.
+        """
         loop = asyncio.get_event_loop()
         
         def _get_tasks_sync():
-            # Build WHERE clause
-            where_conditions = ["user_id = ?"]
+            # Build WHERE clause; columns are qualified for the email join
+            where_conditions = ["tasks.user_id = ?"]
             where_values = [user_id]
             
+            if overdue:
+                where_conditions.append(
+                    "tasks.due_date IS NOT NULL AND datetime(tasks.due_date) < datetime(?) "
+                    "AND tasks.status NOT IN (?, ?)"
+                )
+                where_values.extend([
+                    user_clock(self.db, user_id, now).now().isoformat(),
+                    TaskStatus.COMPLETED.value, TaskStatus.CANCELLED.value
+                ])
+            
             if status:
-                where_conditions.append("status = ?")
+                where_conditions.append("tasks.status = ?")
                 where_values.append(status)
             
             if priority:
-                where_conditions.append("priority = ?")
+                where_conditions.append("tasks.priority = ?")
                 where_values.append(priority)
             
+            if source:
+                where_conditions.append("tasks.source = ?")
+                where_values.append(source)
+            
             if search:
-                where_conditions.append("(title LIKE ? OR description LIKE ?)")
+                where_conditions.append("(tasks.title LIKE ? OR tasks.description LIKE ?)")
                 search_term = f"%{search}%"
                 where_values.extend([search_term, search_term])
             
@@ -225,17 +383,19 @@ class TaskService:
             # Calculate offset
             offset = (page - 1) * limit
             
-            with db_manager.get_connection() as conn:
+            with self.db.get_connection() as conn:
                 # Get total count
                 count_query = f"SELECT COUNT(*) FROM tasks WHERE {where_clause}"
                 cursor = conn.execute(count_query, where_values)
                 total_count = cursor.fetchone()[0]
                 
                 # Get paginated results
+                email_columns = _TASK_EMAIL_COLUMNS if include_email else ""
+                email_join = _TASK_EMAIL_JOIN if include_email else ""
                 tasks_query = f"""
-                SELECT * FROM tasks 
+                SELECT tasks.*{email_columns} FROM tasks{email_join}
                 WHERE {where_clause}
-                ORDER BY created_at DESC
+                ORDER BY tasks.created_at DESC
                 LIMIT ? OFFSET ?
                 """
                 
@@ -246,6 +406,9 @@ class TaskService:
                 
                 rows = cursor.fetchall()
                 tasks = [self._row_to_task(row) for row in rows]
+                if include_email:
+                    for task, row in zip(tasks, rows):
+                        task.email = self._row_to_task_email(row)
                 
                 # Calculate if there are more pages
                 has_next = (page * limit) < total_count
@@ -260,6 +423,39 @@ class TaskService:
         
         return await loop.run_in_executor(None, _get_tasks_sync)
     
+    async def get_due_counts(self, user_id: int, now: Optional[datetime] = None) -> Dict[str, int]:
+        """Count open tasks that are overdue or due later today.
+        
+        Today ends at midnight in the user's time zone (see user_clock).
+        
+        Args:
+            user_id: Owner of the tasks
+            now: Reference time (defaults to now)
+            
+        Returns:
+            Dict with overdue and due_today counts
+        """
+        loop = asyncio.get_event_loop()
+        
+        def _get_due_counts_sync():
+            clock = user_clock(self.db, user_id, now)
+            current, end_of_day = clock.now(), clock.end_of_day()
+            with self.db.get_connection() as conn:
+                row = conn.execute(
+                    """
+                    SELECT TOTAL(datetime(due_date) < datetime(?)) AS overdue,
+                           TOTAL(datetime(due_date) >= datetime(?)
+                                 AND datetime(due_date) < datetime(?)) AS due_today
+                    FROM tasks
+                    WHERE user_id = ? AND due_date IS NOT NULL AND status NOT IN (?, ?)
+                    """,
+                    (current.isoformat(), current.isoformat(), end_of_day.isoformat(), user_id,
+                     TaskStatus.COMPLETED.value, TaskStatus.CANCELLED.value)
+                ).fetchone()
+            return {"overdue": int(row["overdue"]), "due_today": int(row["due_today"])}
+        
+        return await loop.run_in_executor(None, _get_due_counts_sync)
+    
     async def bulk_update_tasks(
         self, 
         task_ids: List[int], 
@@ -276,21 +472,126 @@ class TaskService:
     
     async def bulk_delete_tasks(self, task_ids: List[int], user_id: int) -> int:
         """Delete multiple tasks at once."""
+        return len(await self.delete_tasks(task_ids, user_id))
+    
+    async def delete_tasks(self, task_ids: List[int], user_id: int) -> List[int]:
+        """Delete several of a user's tasks and return the IDs that were deleted."""
         loop = asyncio.get_event_loop()
         
-        def _bulk_delete_sync():
+        def _delete_sync():
             if not task_ids:
-                return 0
+                return []
             
             placeholders = ", ".join("?" for _ in task_ids)
-            query = f"DELETE FROM tasks WHERE id IN ({placeholders}) AND user_id = ?"
+            where = f"id IN ({placeholders}) AND user_id = ?"
             
-            with db_manager.get_connection() as conn:
-                cursor = conn.execute(query, task_ids + [user_id])
+            with self.db.get_connection() as conn:
+                deleted = [
+                    row["id"] for row in
+                    conn.execute(f"SELECT id FROM tasks WHERE {where}", task_ids + [user_id])
+                ]
+                conn.execute(f"DELETE FROM tasks WHERE {where}", task_ids + [user_id])
                 conn.commit()
-                return cursor.rowcount
+                return deleted
+        
+        return await loop.run_in_executor(None, _delete_sync)
+    
+    async def create_tasks_from_action_items(
+        self,
+        email_id: str,
+        action_items: List[Any],
+        user_id: int,
+        source: Optional[str] = None,
+        description: Optional[str] = None,
+        category: Optional[str] = None,
+        ai_service=None
+    ) -> List[Task]:
+        """Create one task per extracted action item, skipping duplicates.
         
-        return await loop.run_in_executor(None, _bulk_delete_sync)
+        See create_action_item_tasks, which also reports the duplicates.
+        
+        Returns:
+            The tasks that were created
+        """
+        outcome = await self.create_action_item_tasks(
+            email_id, action_items, user_id, source=source, description=description,
+            category=category, ai_service=ai_service
+        )
+        return outcome.created
+    
+    async def create_action_item_tasks(
+        self,
+        email_id: str,
+        action_items: List[Any],
+        user_id: int,
+        source: Optional[str] = None,
+        description: Optional[str] = None,
+        category: Optional[str] = None,
+        ai_service=None
+    ) -> ActionItemTasks:
+        """Create one task per extracted action item, skipping duplicates.
+        
+        Titles come from normalize_task_title; when the title differs from
+        the item's text, the text starts the description. An item is a
+        duplicate if the email already has a task with the same title or
+        item text (ignoring case and whitespace) or it repeats an earlier
+        item, so re-running extraction on an email does not create the same
+        tasks twice, however the AI words the title. Duplicates are found
+        before any AI rewrite. Each task's priority follows the item's owner
+        and its due date is the item's deadline, unless
+        settings.task_defaults says otherwise for the email's category (see
+        task_fields_for_item), both in the user's time zone.
+        
+        Args:
+            email_id: Email the action items were extracted from
+            action_items: Extracted action items, or plain descriptions
+            user_id: Owner of the tasks
+            source: Origin marker stored on each created task
+            description: Description given to each created task
+            category: Category the email was classified into
+            ai_service: AI service for rewriting titles (see normalize_task_title)
+        
+        Returns:
+            The created tasks and the duplicate items' descriptions, in item order
+        """
+        loop = asyncio.get_event_loop()
+        
+        def _existing_titles_sync():
+            with self.db.get_connection() as conn:
+                rows = conn.execute(
+                    "SELECT title, description FROM tasks WHERE email_id = ? AND user_id = ?",
+                    (email_id, user_id)
+                ).fetchall()
+            seen = set()
+            for row in rows:
+                seen.add(_normalize_title(row["title"]))
+                first_line = (row["description"] or "").split("\n", 1)[0]
+                if first_line.startswith(ITEM_TEXT_PREFIX):
+                    seen.add(_normalize_title(clean_task_title(first_line[len(ITEM_TEXT_PREFIX):])))
+            return seen
+        
+        seen = await loop.run_in_executor(None, _existing_titles_sync)
+        clock = await loop.run_in_executor(None, user_clock, self.db, user_id)
+        created, duplicates = [], []
+        for item in filter(None, map(normalize_action_item, action_items)):
+            key = _normalize_title(clean_task_title(item["description"]))
+            if key in seen:
+                duplicates.append(item["description"])
+                continue
+            seen.add(key)
+            title = await normalize_task_title(item["description"], ai_service)
+            seen.add(_normalize_title(title))
+            task_description = description
+            if title != item["description"]:
+                task_description = "\n\n".join(filter(None, [ITEM_TEXT_PREFIX + item["description"], description]))
+            created.append(await self.create_task(
+                TaskCreate(
+                    title=title, description=task_description, email_id=email_id, source=source,
+                    **task_fields_for_item(item, category, clock=clock)
+                ),
+                user_id
+            ))
+        return ActionItemTasks(created, duplicates)
     
     async def link_email_to_task(self, task_id: int, email_id: str, user_id: int) -> Optional[Task]:
         """Link an email to a task."""
@@ -308,7 +609,20 @@ class TaskService:
             due_date=row["due_date"],
             created_at=row["created_at"],
             updated_at=row["updated_at"],
-            email_id=row["email_id"]
+            email_id=row["email_id"],
+            source=row["source"]
+        )
+
+    def _row_to_task_email(self, row) -> Optional[TaskEmail]:
+        """Embedded email from the _TASK_EMAIL_COLUMNS of a joined task row."""
+        if row["linked_email_id"] is None:
+            return None
+        return TaskEmail(
+            id=row["linked_email_id"],
+            subject=row["linked_email_subject"],
+            sender=row["linked_email_sender"],
+            received_time=row["linked_email_received"],
+            one_line_summary=row["linked_email_summary"]
         )
 
 
@@ -0,0 +1,355 @@
+"""Thread-aware batch classification for FastAPI Email Helper API.
+
+Batch processing classifies each requested email with the AI service. In
+thread mode, emails that share a conversation_id are classified once: only
+the latest message goes to the model (with a short digest of the earlier
+messages as context) and the other messages inherit its result, which keeps
+categories consistent within a conversation and saves AI calls.
+
+With an auto_create_tasks policy, tasks are extracted from each classified
+message in a qualifying category; inherited messages get no tasks of their
+own.
+
+Emails of muted conversations (see EmailService.mute_conversation) never
+reach the model: they are stored as MUTED_CATEGORY and get no tasks.
+Rights-managed emails (see backend.core.protection) are left out of the
+batch altogether, before threads are grouped, and reported in skipped.
+
+With the "two_stage" strategy each email (or thread's latest message) is
+triaged first and only sent to the full classifier when the triage cannot
+settle it (see backend.services.triage).
+
+The model sees each email with its sender's learned boilerplate stripped
+(see backend.services.boilerplate) and then its secrets masked (see
+backend.core.redaction); stored emails keep their full content.
+Once Sent Items has been scanned, each email is also given a relationship
+hint saying how often the owner has written to its sender (see
+backend.services.contacts).
+
+Raw emails (subject, sender, and content with no provider ID) are
+identified by raw_email_id, a hash of their content, so resubmitting the
+same message yields the same ID.
+"""
+
+import hashlib
+import json
+import logging
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from backend.core.config import settings
+from backend.core.protection import PROTECTED_REASON, ai_allowed
+from backend.core.recipients import recipient_summary
+from backend.core.redaction import redact_email
+from backend.models.email import EmailClassification
+from backend.services.ai_traces import trace_email
+from backend.services.auto_tasks import create_auto_tasks
+from backend.services.boilerplate import BoilerplateStore
+from backend.services.category_suggestions import alternative_names
+from backend.services.contacts import ContactStore
+from backend.services.email_service import MUTED_CATEGORY, MUTED_REASONING, EmailService
+from backend.services.thread_participants import normalize_address
+from backend.services.triage import (
+    CLASSIFIER_TEMPLATE, TRIAGE_REASONING, TRIAGE_TEMPLATE, build_triage_inputs, estimate_tokens,
+    needs_detailed_pass
+)
+
+logger = logging.getLogger(__name__)
+
+DIGEST_SNIPPET_CHARS = 200
+
+RAW_EMAIL_ID_PREFIX = "raw-"
+
+
+@dataclass
+class BatchClassification:
+    """Outcome of classifying a batch of emails."""
+    results: List[EmailClassification] = field(default_factory=list)
+    ai_calls: int = 0
+    ai_calls_saved: int = 0
+    muted: int = 0  # Emails tagged MUTED_CATEGORY without the AI
+    # Email ID -> why its classification failed (inherited messages included)
+    failures: Dict[str, str] = field(default_factory=dict)
+    # Email ID -> why it was left out without a result, e.g. PROTECTED_REASON
+    skipped: Dict[str, str] = field(default_factory=dict)
+    # two_stage only: emails triaged, those sent on to the full classifier, and
+    # estimated tokens used against those single-stage classification would use
+    triaged: int = 0
+    detailed: int = 0
+    estimated_tokens: int = 0
+    estimated_single_stage_tokens: int = 0
+
+
+def raw_email_id(subject: str, sender: str, content: str) -> str:
+    """Deterministic ID for an email submitted as raw content."""
+    payload = json.dumps([subject, sender, content], ensure_ascii=False)
+    return RAW_EMAIL_ID_PREFIX + hashlib.sha256(payload.encode("utf-8")).hexdigest()
+
+
+def _received(email: Dict[str, Any]) -> str:
+    """Sort key for message order within a thread (ISO timestamps sort as text)."""
+    return str(email.get("received_time") or email.get("received_date") or "")
+
+
+def group_by_conversation(emails: List[Dict[str, Any]]) -> List[List[Dict[str, Any]]]:
+    """Group emails into threads, oldest message first.
+
+    Emails without a conversation_id are singleton threads. Threads are
+    returned in the order their first email appears in the input.
+    """
+    groups: Dict[Any, List[Dict[str, Any]]] = {}
+    for index, email in enumerate(emails):
+        key = email.get("conversation_id") or ("single", index)
+        groups.setdefault(key, []).append(email)
+
+    return [sorted(group, key=_received) for group in groups.values()]
+
+
+def build_thread_digest(earlier: List[Dict[str, Any]], max_messages: int = 5) -> str:
+    """Summarize earlier thread messages as classification context.
+
+    Args:
+        earlier: Earlier messages in the thread, oldest first
+        max_messages: Most recent earlier messages to include (0 disables)
+
+    Returns:
+        Digest text, or an empty string if there is nothing to include
+    """
+    if max_messages <= 0 or not earlier:
+        return ""
+
+    lines = [f"Earlier messages in this thread ({len(earlier)} total):"]
+    for email in earlier[-max_messages:]:
+        body = " ".join(str(email.get("body") or email.get("content") or "").split())
+        if len(body) > DIGEST_SNIPPET_CHARS:
+            body = body[:DIGEST_SNIPPET_CHARS].rstrip() + "..."
+        lines.append(f"- {email.get('sender', 'Unknown')}: {email.get('subject', '')} | {body}")
+
+    return "\n".join(lines)
+
+
+async def _classify(
+    ai_service,
+    email: Dict[str, Any],
+    context: Optional[str],
+    relationship: Optional[str] = None
+) -> Tuple[EmailClassification, bool]:
+    """Classify one email, degrading to an unclassified result on AI failure.
+
+    Returns:
+        The classification and whether the AI call succeeded
+    """
+    try:
+        result = await ai_service.classify_email_async(
+            subject=email.get("subject") or "",
+            content=email.get("body") or email.get("content") or "",
+            sender=email.get("sender") or "",
+            context=context,
+            recipients=recipient_summary(email),
+            relationship=relationship
+        )
+    except Exception as e:
+        result = {"error": str(e)}
+
+    if "error" in result:
+        logger.warning(f"Classification failed for {email.get('id')}: {result['error']}")
+        return EmailClassification(
+            email_id=email.get("id"),
+            category="unclassified",
+            confidence=0.0,
+            reasoning=f"AI classification unavailable: {result['error']}",
+            priority="normal"
+        ), False
+
+    return EmailClassification(
+        email_id=email.get("id"),
+        category=result.get("category", "work_relevant"),
+        confidence=result.get("confidence", 0.5),
+        reasoning=result.get("reasoning"),
+        summary=result.get("summary"),
+        alternative_categories=alternative_names(result.get("alternatives")),
+        priority="normal",
+        importance_score=result.get("importance_score"),
+        importance_justification=result.get("importance_justification")
+    ), True
+
+
+async def _classify_two_stage(
+    ai_service,
+    email: Dict[str, Any],
+    context: Optional[str],
+    relationship: Optional[str],
+    threshold: float,
+    outcome: BatchClassification
+) -> Tuple[EmailClassification, bool]:
+    """Triage one email, classifying it in full only if the triage cannot settle it.
+
+    AI calls, triage counts, and token estimates are added to outcome.
+
+    Returns:
+        The classification and whether the deciding AI call succeeded
+    """
+    subject = email.get("subject") or ""
+    content = email.get("body") or email.get("content") or ""
+    sender = email.get("sender") or ""
+    headers = (subject, sender, recipient_summary(email), relationship, context)
+    full_text = "\n".join(part for part in (*headers, content) if part)
+    single_stage = estimate_tokens(CLASSIFIER_TEMPLATE, full_text)
+    outcome.estimated_single_stage_tokens += single_stage
+
+    try:
+        triage = await ai_service.triage_email_async(subject=subject, content=content, sender=sender)
+    except Exception as e:
+        triage = {"error": str(e)}
+    outcome.ai_calls += 1
+    outcome.triaged += 1
+    triage_text = "\n".join(build_triage_inputs(subject, sender, content, ()).values())
+    outcome.estimated_tokens += estimate_tokens(TRIAGE_TEMPLATE, triage_text)
+    if "error" in triage:
+        logger.warning(f"Triage failed for {email.get('id')}: {triage['error']}")
+
+    if not needs_detailed_pass(triage, threshold):
+        return EmailClassification(
+            email_id=email.get("id"),
+            category=triage["category"],
+            confidence=triage["confidence"],
+            reasoning=TRIAGE_REASONING,
+            priority="normal",
+            classification_stage="triage"
+        ), True
+
+    classification, succeeded = await _classify(ai_service, email, context, relationship)
+    outcome.ai_calls += 1
+    outcome.detailed += 1
+    outcome.estimated_tokens += single_stage
+    return classification.model_copy(update={"classification_stage": "detailed"}), succeeded
+
+
+async def classify_batch(
+    emails: List[Dict[str, Any]],
+    ai_service,
+    email_service: EmailService,
+    user_id: int,
+    thread_mode: bool = False,
+    context: Optional[str] = None,
+    digest_messages: int = 5,
+    task_service=None,
+    auto_create_tasks: str = "off",
+    unsaved_ids: Optional[Set[str]] = None,
+    muted_conversations: Optional[Set[str]] = None,
+    strategy: str = "single",
+    triage_threshold: Optional[float] = None
+) -> BatchClassification:
+    """Classify a batch of emails and store the results.
+
+    Args:
+        emails: Provider email dicts to classify
+        ai_service: AI service providing classify_email_async
+        email_service: Email service used to store classifications
+        user_id: Owner of the stored emails
+        thread_mode: Classify only the latest message of each conversation
+        context: Additional context passed to every classification
+        digest_messages: Earlier messages included in the thread digest
+        task_service: TaskService used for automatic tasks
+        auto_create_tasks: Policy deciding which categories get automatic tasks
+        unsaved_ids: Emails to classify without storing results or creating tasks
+        muted_conversations: Conversation IDs whose emails are tagged
+            MUTED_CATEGORY instead of classified
+        strategy: "single" sends every email to the full classifier;
+            "two_stage" triages first (see backend.services.triage)
+        triage_threshold: Lowest triage confidence kept without the full
+            classifier (defaults to settings.triage_confidence_threshold)
+
+    Returns:
+        Results in input order, skipped emails left out, plus AI call accounting
+    """
+    outcome = BatchClassification()
+    for email in emails:
+        if not ai_allowed(email):
+            outcome.skipped[email.get("id")] = PROTECTED_REASON
+    if outcome.skipped:
+        logger.info(f"Skipping {len(outcome.skipped)} protected emails")
+        emails = [email for email in emails if ai_allowed(email)]
+    unsaved_ids = unsaved_ids or set()
+    muted_conversations = muted_conversations or set()
+    if triage_threshold is None:
+        triage_threshold = settings.triage_confidence_threshold
+    by_id: Dict[int, EmailClassification] = {}
+
+    groups = group_by_conversation(emails) if thread_mode else [[email] for email in emails]
+    stripped = await BoilerplateStore(email_service.db).strip_emails(user_id, emails)
+    # What the model sees of each email, by identity as in by_id, and the secrets masked in it
+    redacted = [redact_email(copy) for copy in stripped]
+    ai_view = {id(email): copy for email, (copy, _) in zip(emails, redacted)}
+    redactions = {id(email): count for email, (_, count) in zip(emails, redacted)}
+    hints = await ContactStore(email_service.db).relationship_hints(email.get("sender") for email in emails)
+
+    for group in groups:
+        latest, earlier = group[-1], group[:-1]
+
+        if latest.get("conversation_id") in muted_conversations:
+            for email in group:
+                by_id[id(email)] = EmailClassification(
+                    email_id=email.get("id"),
+                    category=MUTED_CATEGORY,
+                    confidence=1.0,
+                    reasoning=MUTED_REASONING,
+                    priority="low"
+                )
+                if email.get("id") and email["id"] not in unsaved_ids:
+                    await email_service.save_classification(email, by_id[id(email)], user_id)
+            outcome.muted += len(group)
+            continue
+
+        digest = build_thread_digest([ai_view[id(email)] for email in earlier], digest_messages)
+        thread_context = "\n\n".join(part for part in (context, digest) if part) or None
+
+        relationship = hints.get(normalize_address(latest.get("sender")))
+        with trace_email(latest.get("id"), user_id):
+            if strategy == "two_stage":
+                classification, succeeded = await _classify_two_stage(
+                    ai_service, ai_view[id(latest)], thread_context, relationship, triage_threshold, outcome
+                )
+            else:
+                classification, succeeded = await _classify(
+                    ai_service, ai_view[id(latest)], thread_context, relationship
+                )
+                outcome.ai_calls += 1
+        # Secrets masked in the latest message and in the earlier ones its digest quotes
+        sent = [latest, *(earlier[-digest_messages:] if digest_messages > 0 else [])]
+        classification = classification.model_copy(update={
+            "redactions_applied": sum(redactions[id(email)] for email in sent)
+        })
+        by_id[id(latest)] = classification
+
+        for email in earlier:
+            by_id[id(email)] = classification.model_copy(update={
+                "email_id": email.get("id"),
+                "inherited_from": latest.get("id")
+            })
+        outcome.ai_calls_saved += len(earlier)
+
+        # Failed classifications are reported but never stored
+        if not succeeded:
+            for email in group:
+                if email.get("id"):
+                    outcome.failures[email["id"]] = classification.reasoning
+            continue
+        for email in group:
+            if email.get("id") and email["id"] not in unsaved_ids:
+                await email_service.save_classification(email, by_id[id(email)], user_id)
+
+        if task_service is not None and latest.get("id") not in unsaved_ids:
+            with trace_email(latest.get("id"), user_id):
+                tasks_created = await create_auto_tasks(
+                    ai_view[id(latest)], classification.category, ai_service, task_service,
+                    user_id, auto_create_tasks, context=context
+                )
+            by_id[id(latest)] = classification.model_copy(update={"tasks_created": tasks_created})
+
+    if outcome.triaged:
+        logger.info(
+            f"Two-stage classification: {outcome.detailed} of {outcome.triaged} emails needed the full classifier"
+        )
+    outcome.results = [by_id[id(email)] for email in emails]
+    return outcome
@@ -0,0 +1,102 @@
+"""Thread participants for FastAPI Email Helper API.
+
+Lists everyone who sent or received a message in a conversation: how many
+messages they sent and were on To or Cc of, and when they first and last
+appeared. Participants whose address is in the same domain as the user's
+are internal; everyone else is external.
+"""
+
+from datetime import datetime
+from email.utils import parseaddr
+from typing import Any, Dict, Iterable, List, Optional, Set, Tuple
+
+from backend.core.recipients import email_recipients
+from backend.models.email import ThreadParticipant
+from backend.services.follow_up_detector import parse_received
+
+
+def normalize_address(value: Optional[str]) -> Optional[str]:
+    """Bare lowercased address of "Name <a@x.com>" or "a@x.com" (None without one)."""
+    address = parseaddr(value or "")[1].strip().lower()
+    return address or None
+
+
+def address_domain(address: Optional[str]) -> Optional[str]:
+    """Domain part of an address, lowercased."""
+    address = normalize_address(address)
+    if not address or "@" not in address:
+        return None
+    return address.rsplit("@", 1)[1]
+
+
+def message_recipients(email: Dict[str, Any]) -> Tuple[List[str], List[str]]:
+    """To and Cc addresses of a stored or provider email."""
+    if "to_recipients" not in email:
+        return email_recipients(email)
+    to = email.get("to_recipients") or ([email["recipient"]] if email.get("recipient") else [])
+    return list(to), list(email.get("cc_recipients") or [])
+
+
+class _Activity:
+    """Running totals for one participant."""
+
+    def __init__(self, address: str):
+        self.address = address
+        self.messages: Set[str] = set()
+        self.sent = 0
+        self.received = 0
+        self.first: Optional[datetime] = None
+        self.last: Optional[datetime] = None
+
+    def seen(self, message_id: str, when: Optional[datetime]) -> None:
+        self.messages.add(message_id)
+        if when is not None:
+            self.first = when if self.first is None else min(self.first, when)
+            self.last = when if self.last is None else max(self.last, when)
+
+
+def summarize_participants(
+    thread: Iterable[Dict[str, Any]],
+    my_address: Optional[str] = None
+) -> List[ThreadParticipant]:
+    """Participants of a thread, most messages first.
+
+    Args:
+        thread: Thread messages, stored or from the provider
+        my_address: The user's address, whose domain makes a participant internal
+
+    Returns:
+        One participant per distinct address, ties broken by address
+    """
+    my_domain = address_domain(my_address)
+    activity: Dict[str, _Activity] = {}
+
+    def participant(address: str) -> _Activity:
+        return activity.setdefault(address, _Activity(address))
+
+    for index, email in enumerate(thread):
+        message_id = str(email.get("id") or index)
+        when = parse_received(email.get("received_time") or email.get("received_date"))
+        sender = normalize_address(email.get("sender"))
+        if sender:
+            participant(sender).sent += 1
+            participant(sender).seen(message_id, when)
+        to, cc = message_recipients(email)
+        # Counted once per message, even when listed on both To and Cc
+        for address in sorted({normalize_address(value) for value in to + cc} - {None}):
+            participant(address).received += 1
+            participant(address).seen(message_id, when)
+
+    participants = [
+        ThreadParticipant(
+            address=item.address,
+            message_count=len(item.messages),
+            sent_count=item.sent,
+            received_count=item.received,
+            first_activity=item.first,
+            last_activity=item.last,
+            internal=None if my_domain is None else address_domain(item.address) == my_domain
+        )
+        for item in activity.values()
+    ]
+    return sorted(participants, key=lambda p: (-p.message_count, p.address))
@@ -0,0 +1,195 @@
+"""Training data export and offline evaluation for FastAPI Email Helper API.
+
+GET /api/admin/training-export streams the emails whose category a person
+corrected or confirmed (see EmailService.get_labeled_emails) as JSON
+Lines, one training_record per email: a subject, sender, and body excerpt,
+the human label, and what the AI had said. Secrets are always masked (see
+backend.core.redaction), even while settings.redact_secrets is off; at
+the anonymized level the sender, the email ID, and addresses written in
+the text are also replaced by fakes (see services.data_admin.Anonymizer,
+keyed per export). Output stops at the last whole line within max_bytes.
+
+POST /api/admin/evaluate draws a seeded sample of the same emails
+(sample_labeled), classifies them with the current classifier prompt
+and model through classify_batch without storing anything, and reports
+how often the result agrees with the human label, beside how often the
+stored AI categories of the sample did.
+"""
+
+import json
+import logging
+import random
+from typing import Any, AsyncIterator, Dict, List, Optional, Sequence
+
+from backend.core.preview import email_preview
+from backend.core.redaction import redact_secrets
+from backend.models.training import CategoryAgreement, EvaluationDisagreement, EvaluationReport
+from backend.services.classification_store import prompt_version
+from backend.services.data_admin import Anonymizer
+from backend.services.thread_classifier import classify_batch
+
+logger = logging.getLogger(__name__)
+
+REDACTION_SECRETS = "secrets"
+REDACTION_ANONYMIZED = "anonymized"
+REDACTION_LEVELS = (REDACTION_SECRETS, REDACTION_ANONYMIZED)
+
+# Characters of the sender's own text kept per email
+EXCERPT_CHARS = 1000
+
+DEFAULT_EXPORT_BYTES = 10 * 1024 * 1024
+MAX_EXPORT_BYTES = 100 * 1024 * 1024
+
+# Labeled emails read per query while streaming
+EXPORT_PAGE_SIZE = 200
+
+# Disagreements listed in an evaluation report
+MAX_DISAGREEMENTS = 50
+
+
+def training_record(row: Dict[str, Any], anonymizer: Optional[Anonymizer] = None) -> Dict[str, Any]:
+    """One export line for a labeled email row.
+
+    Args:
+        row: Row from EmailService.get_labeled_emails
+        anonymizer: Fakes for the sender, ID, and addresses in the text
+            (None keeps them; secrets are masked either way)
+
+    Returns:
+        The record, with excerpt as the start of the sender's own plain text
+    """
+    subject = redact_secrets(row.get("subject") or "", force=True)[0]
+    excerpt = redact_secrets(email_preview(row.get("content"), EXCERPT_CHARS), force=True)[0]
+    email_id, sender = row["id"], row.get("sender") or ""
+    if anonymizer is not None:
+        email_id = anonymizer.fake("email", email_id)
+        sender = anonymizer.fake_address(sender)
+        subject = anonymizer.fake_addresses_in_text(subject)
+        excerpt = anonymizer.fake_addresses_in_text(excerpt)
+    return {
+        "email_id": email_id,
+        "subject": subject,
+        "sender": sender,
+        "excerpt": excerpt,
+        "category": row["category"],
+        "label_source": row["label_source"],
+        "ai_category": row.get("ai_category"),
+        "ai_confidence": row.get("ai_confidence"),
+        "prompt_version": row.get("ai_prompt_version"),
+    }
+
+
+async def training_export_lines(
+    email_service,
+    user_id: int,
+    redaction: str = REDACTION_SECRETS,
+    max_bytes: int = DEFAULT_EXPORT_BYTES
+) -> AsyncIterator[bytes]:
+    """Yield the labeled emails as JSON Lines, a page of emails at a time.
+
+    Args:
+        email_service: EmailService the labeled emails are read through
+        user_id: Owner of the stored emails
+        redaction: REDACTION_SECRETS or REDACTION_ANONYMIZED
+        max_bytes: Stop before the line that would take the output past this size
+    """
+    anonymizer = Anonymizer() if redaction == REDACTION_ANONYMIZED else None
+    written = exported = 0
+    after_id = None
+    while True:
+        rows = await email_service.get_labeled_emails(user_id, after_id=after_id, limit=EXPORT_PAGE_SIZE)
+        for row in rows:
+            line = (json.dumps(training_record(row, anonymizer), ensure_ascii=False) + "\n").encode("utf-8")
+            if written + len(line) > max_bytes:
+                logger.info(f"Training export stopped at {max_bytes} bytes after {exported} emails")
+                return
+            written += len(line)
+            exported += 1
+            yield line
+        if len(rows) < EXPORT_PAGE_SIZE:
+            logger.info(f"Exported {exported} labeled emails ({written} bytes)")
+            return
+        after_id = rows[-1]["id"]
+
+
+def sample_labeled(email_ids: Sequence[str], size: int, seed: Optional[int] = None) -> List[str]:
+    """Pick up to size email IDs at random.
+
+    The IDs are sorted first, so a seed picks the same sample whatever
+    order they were read in.
+    """
+    ordered = sorted(set(email_ids))
+    return random.Random(seed).sample(ordered, min(size, len(ordered)))
+
+
+def _rate(agreed: int, total: int) -> Optional[float]:
+    return round(agreed / total, 4) if total else None
+
+
+async def evaluate_classifier(
+    ai_service,
+    email_service,
+    user_id: int,
+    sample_size: int,
+    seed: Optional[int] = None
+) -> EvaluationReport:
+    """Classify a sample of labeled emails with the current classifier and compare with the labels.
+
+    Nothing is stored: the sample is classified as unsaved, so the stored
+    categories and AI metadata stay as they are.
+
+    Args:
+        ai_service: AI service providing classify_email_async
+        email_service: EmailService the labeled emails are read through
+        user_id: Owner of the stored emails
+        sample_size: Labeled emails to classify
+        seed: Seed for the sample
+
+    Returns:
+        Overall and per-category agreement, and the sampled emails the
+        classifier disagreed on
+    """
+    labels = await email_service.get_human_labels(user_id)
+    sample = sample_labeled(list(labels), sample_size, seed)
+    stored = await email_service.get_stored_emails(sample, user_id)
+    emails = [stored[email_id] for email_id in sample if email_id in stored]
+    outcome = await classify_batch(
+        emails, ai_service, email_service, user_id, unsaved_ids={email["id"] for email in emails}
+    )
+    # Protected emails never reach the classifier, so they are not part of the sample
+    emails = [email for email in emails if email["id"] not in outcome.skipped]
+
+    per_category: Dict[str, List[int]] = {}  # emails, agreed
+    disagreements = []
+    agreed = stored_agreed = 0
+    for email, result in zip(emails, outcome.results):
+        label = labels[email["id"]]
+        stored_agreed += email.get("ai_category") == label
+        if email["id"] in outcome.failures:
+            continue
+        counts = per_category.setdefault(label, [0, 0])
+        counts[0] += 1
+        if result.category == label:
+            counts[1] += 1
+            agreed += 1
+        elif len(disagreements) < MAX_DISAGREEMENTS:
+            disagreements.append(EvaluationDisagreement(
+                email_id=email["id"], category=label, predicted=result.category, confidence=result.confidence
+            ))
+
+    evaluated = len(emails) - len(outcome.failures)
+    return EvaluationReport(
+        prompt_version=prompt_version(),
+        labeled=len(labels),
+        sampled=len(emails),
+        evaluated=evaluated,
+        failed=len(outcome.failures),
+        agreed=agreed,
+        agreement=_rate(agreed, evaluated),
+        stored_agreement=_rate(stored_agreed, len(emails)),
+        categories=[
+            CategoryAgreement(category=category, emails=total, agreed=matched, agreement=_rate(matched, total))
+            for category, (total, matched) in sorted(per_category.items(), key=lambda item: (-item[1][0], item[0]))
+        ],
+        disagreements=disagreements
+    )
@@ -0,0 +1,103 @@
+"""Two-stage batch classification for FastAPI Email Helper API.
+
+With classification_strategy "two_stage", batch classification (see
+backend.services.thread_classifier) first triages each email with the
+email_triage prompty template: a short prompt on the sender, subject, and
+opening lines, run on settings.triage_deployment (a small model such as
+gpt-4o-mini). Only emails the triage cannot settle go on to the full
+email_classifier_with_explanation prompt: a confidence below
+settings.triage_confidence_threshold, an action category (those need the
+full prompt's ownership rules), or a failed triage. The rest keep the
+triage's category.
+
+Neither AI service counts real tokens, so a run's savings are estimated:
+a prompt is its template's size plus the email text sent, at
+CHARS_PER_TOKEN characters per token, and a completion is the template's
+max_tokens.
+"""
+
+import json
+from typing import Any, Dict, Optional, Sequence
+
+from backend.core.config import settings
+from backend.core.prompts import prompt_library
+from backend.services.email_service import ACTIONABLE_CATEGORIES
+from backend.services.summary_modes import CHARS_PER_TOKEN
+
+TRIAGE_TEMPLATE = "email_triage.prompty"
+CLASSIFIER_TEMPLATE = "email_classifier_with_explanation.prompty"
+
+# Reasoning of classifications the triage settled
+TRIAGE_REASONING = "Sorted by the quick triage pass"
+
+# Opening characters of the body the triage sees
+TRIAGE_CONTENT_CHARS = 500
+
+
+def build_triage_inputs(subject: str, sender: str, content: str, categories: Sequence[str]) -> Dict[str, Any]:
+    """Build email_triage prompty inputs."""
+    snippet = " ".join(content.split())
+    if len(snippet) > TRIAGE_CONTENT_CHARS:
+        snippet = snippet[:TRIAGE_CONTENT_CHARS].rstrip() + "..."
+    return {
+        "subject": subject,
+        "sender": sender,
+        "snippet": snippet,
+        "category_names": ", ".join(categories),
+    }
+
+
+def parse_triage(raw: Any, categories: Sequence[str]) -> Dict[str, Any]:
+    """Parse email_triage output into category and confidence.
+
+    Accepts a dict or a JSON string, optionally wrapped in a code fence.
+    The confidence is clamped to [0, 1].
+
+    Raises:
+        ValueError: If the output is not JSON, names no known category, or
+            has no numeric confidence
+    """
+    if isinstance(raw, str):
+        text = raw.strip().strip("`").removeprefix("json")
+        try:
+            raw = json.loads(text)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Triage returned invalid JSON: {e}")
+    if not isinstance(raw, dict):
+        raise ValueError("Triage returned no JSON object")
+    category, confidence = raw.get("category"), raw.get("confidence")
+    if category not in categories:
+        raise ValueError(f"Triage returned unknown category {category!r}")
+    if isinstance(confidence, bool) or not isinstance(confidence, (int, float)):
+        raise ValueError("Triage returned no confidence")
+    return {"category": category, "confidence": min(max(float(confidence), 0.0), 1.0)}
+
+
+def needs_detailed_pass(triage: Dict[str, Any], threshold: float) -> bool:
+    """Whether a triaged email goes on to the full classifier.
+
+    Args:
+        triage: Result of triage_email_async (failed ones carry error)
+        threshold: Lowest confidence a triage result is kept at
+    """
+    if "error" in triage:
+        return True
+    return triage["category"] in ACTIONABLE_CATEGORIES or triage["confidence"] < threshold
+
+
+def template_deployment(prompty_file: str) -> Optional[str]:
+    """Deployment a template runs on, or None for azure_openai_deployment (see AIProcessor.deployment_resolver)."""
+    if prompty_file == TRIAGE_TEMPLATE:
+        return settings.triage_deployment or None
+    return None
+
+
+def estimate_tokens(prompty_file: str, text: str) -> int:
+    """Estimated prompt and completion tokens of running a template on text."""
+    template = prompt_library.get(prompty_file)
+    try:
+        prompt_chars = template.path.stat().st_size if template else 0
+    except OSError:
+        prompt_chars = 0
+    completion = prompt_library.parameters(prompty_file).get("max_tokens") or 0
+    return (prompt_chars + len(text)) // CHARS_PER_TOKEN + int(completion)
@@ -0,0 +1,151 @@
+"""Unified inbox for FastAPI Email Helper API.
+
+Merges emails from the primary mailbox and settings.shared_mailboxes into one
+stream, newest first. Every mailbox is read concurrently; a mailbox that
+fails is reported as a warning and left out instead of failing the request.
+Pages are addressed by a composite cursor holding each mailbox's offset, so
+the next page resumes every mailbox exactly where the last one stopped.
+"""
+
+import asyncio
+import base64
+import binascii
+import json
+import logging
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from fastapi import Depends
+
+from backend.core.config import settings
+from backend.core.dependencies import get_email_provider
+from backend.core.errors import InputValidationError
+from backend.services.email_service import EmailService
+
+logger = logging.getLogger(__name__)
+
+PRIMARY_MAILBOX = "primary"
+
+
+@dataclass
+class MailboxSource:
+    """A mailbox the unified inbox reads from."""
+    name: str
+    service: EmailService
+    shared: bool = False
+
+    async def fetch(self, folder: str, count: int, offset: int) -> List[Dict[str, Any]]:
+        """Read a page of this mailbox, newest first."""
+        if self.shared:
+            return await self.service.get_mailbox_emails(self.name, folder_name=folder, count=count, offset=offset)
+        return await self.service.get_emails(folder_name=folder, count=count, offset=offset)
+
+
+def get_mailbox_sources(provider=Depends(get_email_provider)) -> List[MailboxSource]:
+    """FastAPI dependency for the primary mailbox and configured shared mailboxes."""
+    service = EmailService(provider)
+    sources = [MailboxSource(settings.user_email or PRIMARY_MAILBOX, service)]
+    sources.extend(MailboxSource(mailbox, service, shared=True) for mailbox in settings.shared_mailboxes)
+    return sources
+
+
+def encode_cursor(offsets: Dict[str, int]) -> str:
+    """Encode per-mailbox offsets as an opaque cursor."""
+    return base64.urlsafe_b64encode(json.dumps(offsets, sort_keys=True).encode()).decode()
+
+
+def decode_cursor(cursor: Optional[str]) -> Dict[str, int]:
+    """Decode a cursor from encode_cursor.
+
+    Raises:
+        InputValidationError: If the cursor is malformed
+    """
+    if not cursor:
+        return {}
+    try:
+        offsets = json.loads(base64.urlsafe_b64decode(cursor.encode()))
+    except (binascii.Error, UnicodeDecodeError, ValueError):
+        raise InputValidationError("Invalid cursor")
+    if not isinstance(offsets, dict) or not all(
+        isinstance(offset, int) and not isinstance(offset, bool) and offset >= 0
+        for offset in offsets.values()
+    ):
+        raise InputValidationError("Invalid cursor")
+    return offsets
+
+
+def received_sort_key(email: Dict[str, Any]) -> datetime:
+    """Naive UTC received time of a provider email; unreadable times sort last."""
+    value = email.get("received_time")
+    if isinstance(value, str):
+        try:
+            value = datetime.fromisoformat(value.replace("Z", "+00:00"))
+        except ValueError:
+            return datetime.min
+    if not isinstance(value, datetime):
+        return datetime.min
+    if value.tzinfo is not None:
+        value = value.astimezone(timezone.utc).replace(tzinfo=None)
+    return value
+
+
+async def get_unified_emails(
+    sources: List[MailboxSource],
+    folder: str = "Inbox",
+    limit: int = 50,
+    cursor: Optional[str] = None
+) -> Dict[str, Any]:
+    """Get one page of the merged mailbox stream.
+
+    Each mailbox is read from its cursor offset for limit + 1 emails so the
+    page and has_more are exact. Emails are tagged with their mailbox and
+    sorted newest first, ties broken by mailbox then id so pages are stable.
+
+    Args:
+        sources: Mailboxes to merge
+        folder: Folder to read from every mailbox
+        limit: Emails per page
+        cursor: Cursor from a previous page, or None for the first page
+
+    Returns:
+        Dict with emails, next_cursor, has_more, and warnings ({mailbox, error})
+
+    Raises:
+        InputValidationError: If the cursor is malformed
+    """
+    offsets = decode_cursor(cursor)
+    results = await asyncio.gather(
+        *(source.fetch(folder, limit + 1, offsets.get(source.name, 0)) for source in sources),
+        return_exceptions=True
+    )
+
+    warnings = []
+    candidates = []
+    fetched = {}
+    for source, result in zip(sources, results):
+        if isinstance(result, BaseException):
+            logger.warning(f"Unified inbox skipped {source.name}: {result}")
+            warnings.append({"mailbox": source.name, "error": getattr(result, "detail", None) or str(result)})
+            continue
+        fetched[source.name] = len(result)
+        candidates.extend({**email, "mailbox": source.name} for email in result)
+
+    candidates.sort(key=lambda email: (email["mailbox"], str(email.get("id", ""))))
+    candidates.sort(key=received_sort_key, reverse=True)
+    page = candidates[:limit]
+
+    next_offsets = dict(offsets)
+    taken = {name: 0 for name in fetched}
+    for email in page:
+        taken[email["mailbox"]] += 1
+    for name, count in taken.items():
+        next_offsets[name] = offsets.get(name, 0) + count
+    has_more = any(fetched[name] > taken[name] for name in fetched)
+
+    return {
+        "emails": page,
+        "next_cursor": encode_cursor(next_offsets) if has_more else None,
+        "has_more": has_more,
+        "warnings": warnings,
+    }
@@ -0,0 +1,77 @@
+"""Per-user settings for FastAPI Email Helper API.
+
+Settings live in user_settings, one row per user, next to the calendar
+feed token. The time zone a user sets there decides what "today",
+"overdue", and end-of-day due dates mean for them (see backend.core.clock);
+users without one get settings.timezone.
+"""
+
+import asyncio
+from datetime import datetime
+from typing import Optional
+
+from backend.core.clock import Clock, clock_for
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager, get_default_manager
+from backend.models.user import UserSettings, UserSettingsUpdate
+
+
+def user_timezone(db: DatabaseManager, user_id: int) -> Optional[str]:
+    """Time zone the user has set, or None."""
+    with db.get_connection() as conn:
+        row = conn.execute("SELECT timezone FROM user_settings WHERE user_id = ?", (user_id,)).fetchone()
+    return row["timezone"] if row else None
+
+
+def user_clock(db: DatabaseManager, user_id: int, now: Optional[datetime] = None) -> Clock:
+    """Clock in the user's time zone, else settings.timezone, else server local time."""
+    return clock_for(user_timezone(db, user_id) or settings.timezone, now)
+
+
+class UserSettingsService:
+    """Reading and updating per-user settings."""
+
+    def __init__(self, db: Optional[DatabaseManager] = None):
+        """Initialize the user settings service.
+
+        Args:
+            db: Database store to use (defaults to the process-wide store)
+        """
+        self.db = db or get_default_manager()
+
+    def _settings_sync(self, user_id: int) -> UserSettings:
+        timezone = user_timezone(self.db, user_id)
+        return UserSettings(
+            timezone=timezone,
+            effective_timezone=clock_for(timezone or settings.timezone).timezone_name
+        )
+
+    async def get_settings(self, user_id: int) -> UserSettings:
+        """The user's settings."""
+        loop = asyncio.get_event_loop()
+        return await loop.run_in_executor(None, self._settings_sync, user_id)
+
+    async def update_settings(self, user_id: int, update: UserSettingsUpdate) -> UserSettings:
+        """Apply the fields set in update and return the user's settings."""
+        loop = asyncio.get_event_loop()
+        fields = update.model_dump(exclude_unset=True)
+
+        def _update_sync():
+            if "timezone" in fields:
+                with self.db.get_connection() as conn:
+                    conn.execute(
+                        """
+                        INSERT INTO user_settings (user_id, timezone) VALUES (?, ?)
+                        ON CONFLICT(user_id) DO UPDATE SET timezone = excluded.timezone
+                        """,
+                        (user_id, fields["timezone"])
+                    )
+                    conn.commit()
+            return self._settings_sync(user_id)
+
+        return await loop.run_in_executor(None, _update_sync)
+
+
+def get_user_settings_service() -> UserSettingsService:
+    """FastAPI dependency for user settings service."""
+    return UserSettingsService()
@@ -4,16 +4,71 @@ This module provides WebSocket connection management and real-time broadcasting
 of processing status updates, job progress, and pipeline events.
 """
 
+import hmac
 import json
 import logging
 import asyncio
-from typing import Dict, Set, Any, Optional, List
+from typing import Dict, Set, Any, Optional, List, Tuple
 from datetime import datetime
 from fastapi import WebSocket, WebSocketDisconnect
 from dataclasses import asdict
 
+from backend.core.config import settings
+from backend.core.errors import ReadOnlyError
+from backend.core.read_only import READ_ONLY_MESSAGE
+from backend.services.event_bus import NOTIFICATION_TYPES, Notification, event_bus
+
 logger = logging.getLogger(__name__)
 
+# Browsers cannot set headers on a WebSocket, so the API key may instead be
+# offered as the Sec-WebSocket-Protocol entry "api-key.<key>"
+API_KEY_SUBPROTOCOL_PREFIX = "api-key."
+
+# Close codes for connections refused before or dropped after the upgrade
+CLOSE_INVALID_API_KEY = 4001
+CLOSE_ORIGIN_NOT_ALLOWED = 4003
+CLOSE_IDLE_TIMEOUT = 4008
+CLOSE_TOO_MANY_CONNECTIONS = 4029
+
+# Client messages that act on pipelines, refused in read-only mode
+PIPELINE_ACTION_MESSAGES = ("cancel_pipeline", "retry_failed_jobs")
+
+
+def api_key_subprotocol(websocket: WebSocket) -> Optional[str]:
+    """The client's api-key.<key> subprotocol, if it offered one."""
+    for subprotocol in websocket.scope.get("subprotocols") or ():
+        if subprotocol.startswith(API_KEY_SUBPROTOCOL_PREFIX):
+            return subprotocol
+    return None
+
+
+def handshake_rejection(websocket: WebSocket) -> Optional[Tuple[int, str]]:
+    """Check a WebSocket handshake's origin and API key.
+
+    An Origin header must be one of settings.cors_origins unless those
+    include "*"; clients that send none (non-browser clients) pass. While
+    settings.websocket_api_key is set, the key must be given as the api_key
+    query parameter or the api-key.<key> subprotocol.
+
+    Returns:
+        The close code and reason to refuse the connection with, or None
+    """
+    origin = websocket.headers.get("origin")
+    allowed_origins = {str(allowed).rstrip("/") for allowed in settings.cors_origins}
+    if origin is not None and "*" not in allowed_origins and origin.rstrip("/") not in allowed_origins:
+        return CLOSE_ORIGIN_NOT_ALLOWED, "Origin not allowed"
+
+    if settings.websocket_api_key:
+        api_key = websocket.query_params.get("api_key")
+        subprotocol = api_key_subprotocol(websocket)
+        if api_key is None and subprotocol is not None:
+            api_key = subprotocol[len(API_KEY_SUBPROTOCOL_PREFIX):]
+        if not api_key or not hmac.compare_digest(
+            api_key.encode("utf-8"), settings.websocket_api_key.encode("utf-8")
+        ):
+            return CLOSE_INVALID_API_KEY, "Invalid or missing API key"
+    return None
+
 
 class ConnectionManager:
     """Manages WebSocket connections for real-time updates."""
@@ -26,17 +81,37 @@ class ConnectionManager:
         self.pipeline_subscriptions: Dict[str, Set[str]] = {}
         # User to pipeline mapping for cleanup
         self.user_pipelines: Dict[str, Set[str]] = {}
+        # Notification types each connection receives (None receives all)
+        self.notification_filters: Dict[WebSocket, Optional[Set[str]]] = {}
+        # Open connections per client IP, and each connection's IP
+        self.connections_by_ip: Dict[str, int] = {}
+        self.connection_ips: Dict[WebSocket, str] = {}
         
         self.logger.info("WebSocket ConnectionManager initialized")
     
-    async def connect(self, websocket: WebSocket, user_id: str, pipeline_id: Optional[str] = None):
-        """Accept a new WebSocket connection."""
-        await websocket.accept()
+    async def connect(self, websocket: WebSocket, user_id: str, pipeline_id: Optional[str] = None) -> bool:
+        """Accept a new WebSocket connection.
+        
+        Refuses it (returning False) while its IP already has
+        settings.websocket_max_connections_per_ip connections open.
+        """
+        client_ip = websocket.client.host if websocket.client else "unknown"
+        limit = settings.websocket_max_connections_per_ip
+        if limit and self.connections_by_ip.get(client_ip, 0) >= limit:
+            self.logger.warning(f"Refusing WebSocket for user {user_id}: {client_ip} has {limit} connections open")
+            await websocket.close(code=CLOSE_TOO_MANY_CONNECTIONS, reason="Too many connections")
+            return False
+        # Counted before the first await so concurrent handshakes cannot both pass the limit
+        self.connections_by_ip[client_ip] = self.connections_by_ip.get(client_ip, 0) + 1
+        self.connection_ips[websocket] = client_ip
+        
+        await websocket.accept(subprotocol=api_key_subprotocol(websocket))
         
         # Add to active connections
         if user_id not in self.active_connections:
             self.active_connections[user_id] = set()
         self.active_connections[user_id].add(websocket)
+        self.notification_filters[websocket] = None
         
         # Subscribe to pipeline if specified
         if pipeline_id:
@@ -51,9 +126,25 @@ class ConnectionManager:
             "pipeline_id": pipeline_id,
             "timestamp": datetime.utcnow().isoformat()
         })
+        
+        # Nothing is buffered between connections, so a reconnecting client
+        # uses the hello to resynchronize (e.g. refetch state) instead
+        await websocket.send_text(json.dumps({
+            "type": "hello",
+            "server_time": datetime.utcnow().isoformat(),
+            "version": settings.app_version,
+            "notification_types": list(NOTIFICATION_TYPES)
+        }))
+        return True
     
     async def disconnect(self, websocket: WebSocket, user_id: str):
         """Remove a WebSocket connection."""
+        self.notification_filters.pop(websocket, None)
+        client_ip = self.connection_ips.pop(websocket, None)
+        if client_ip is not None:
+            self.connections_by_ip[client_ip] -= 1
+            if not self.connections_by_ip[client_ip]:
+                del self.connections_by_ip[client_ip]
         if user_id in self.active_connections:
             self.active_connections[user_id].discard(websocket)
             
@@ -117,6 +208,34 @@ class ConnectionManager:
         for websocket in disconnected_sockets:
             await self.disconnect(websocket, user_id)
     
+    def set_notification_filter(self, websocket: WebSocket, event_types: Optional[Set[str]]):
+        """Limit a connection's notifications to event_types (None for all)."""
+        if websocket in self.notification_filters:
+            self.notification_filters[websocket] = event_types
+    
+    async def send_notification(self, notification: Notification):
+        """Send a notification to its user's connections (or everyone's) that accept its type."""
+        if notification.user_id is None:
+            user_ids = list(self.active_connections)
+        else:
+            user_ids = [notification.user_id] if notification.user_id in self.active_connections else []
+        
+        message_str = json.dumps(notification.to_message())
+        for user_id in user_ids:
+            disconnected_sockets = []
+            for websocket in self.active_connections.get(user_id, set()).copy():
+                accepted = self.notification_filters.get(websocket)
+                if accepted is not None and notification.event_type not in accepted:
+                    continue
+                try:
+                    await websocket.send_text(message_str)
+                except Exception as e:
+                    self.logger.warning(f"Failed to send notification to user {user_id}: {e}")
+                    disconnected_sockets.append(websocket)
+            
+            for websocket in disconnected_sockets:
+                await self.disconnect(websocket, user_id)
+    
     async def broadcast_to_pipeline(self, pipeline_id: str, message: Dict[str, Any]):
         """Broadcast message to all users subscribed to a pipeline."""
         if pipeline_id not in self.pipeline_subscriptions:
@@ -147,15 +266,50 @@ class WebSocketManager:
     def __init__(self):
         self.connection_manager = ConnectionManager()
         self.logger = logging.getLogger(__name__)
+        self.unsubscribe_notifications = event_bus.subscribe(self.connection_manager.send_notification)
+    
+    async def authorize(self, websocket: WebSocket) -> bool:
+        """Refuse the handshake (closing before the upgrade, which clients see as a 403) unless it is allowed."""
+        rejection = handshake_rejection(websocket)
+        if rejection is None:
+            return True
+        code, reason = rejection
+        client_ip = websocket.client.host if websocket.client else "unknown"
+        self.logger.warning(f"Refusing WebSocket from {client_ip}: {reason}")
+        await websocket.close(code=code, reason=reason)
+        return False
     
     async def handle_connection(self, websocket: WebSocket, user_id: str, pipeline_id: Optional[str] = None):
-        """Handle WebSocket connection lifecycle."""
-        await self.connection_manager.connect(websocket, user_id, pipeline_id)
+        """Handle WebSocket connection lifecycle.
+        
+        Sends {"type": "ping"} after every settings.websocket_ping_interval_seconds
+        without a client message, and closes the connection once none has
+        arrived for settings.websocket_idle_timeout_seconds.
+        """
+        if not await self.connection_manager.connect(websocket, user_id, pipeline_id):
+            return
         
+        loop = asyncio.get_running_loop()
+        last_message_at = loop.time()
         try:
             while True:
                 # Listen for client messages
-                data = await websocket.receive_text()
+                try:
+                    data = await asyncio.wait_for(
+                        websocket.receive_text(), timeout=settings.websocket_ping_interval_seconds
+                    )
+                except asyncio.TimeoutError:
+                    idle_timeout = settings.websocket_idle_timeout_seconds
+                    if idle_timeout and loop.time() - last_message_at >= idle_timeout:
+                        self.logger.info(f"Closing idle WebSocket for user {user_id}")
+                        await websocket.close(code=CLOSE_IDLE_TIMEOUT, reason="Idle timeout")
+                        break
+                    await websocket.send_text(json.dumps({
+                        "type": "ping",
+                        "timestamp": datetime.utcnow().isoformat()
+                    }))
+                    continue
+                last_message_at = loop.time()
                 await self.handle_client_message(websocket, user_id, data)
                 
         except WebSocketDisconnect:
@@ -171,6 +325,15 @@ class WebSocketManager:
             data = json.loads(message)
             message_type = data.get("type")
             
+            if settings.read_only and message_type in PIPELINE_ACTION_MESSAGES:
+                await websocket.send_text(json.dumps({
+                    "type": "error",
+                    "code": ReadOnlyError.code,
+                    "message": READ_ONLY_MESSAGE,
+                    "timestamp": datetime.utcnow().isoformat()
+                }))
+                return
+            
             if message_type == "subscribe_pipeline":
                 pipeline_id = data.get("pipeline_id")
                 if pipeline_id:
@@ -191,6 +354,29 @@ class WebSocketManager:
                         "timestamp": datetime.utcnow().isoformat()
                     }))
             
+            elif message_type == "subscribe_notifications":
+                # An absent or null list restores every notification type
+                event_types = data.get("event_types")
+                if event_types is not None and not isinstance(event_types, list):
+                    problem = "event_types must be a list"
+                else:
+                    unknown = sorted(set(map(str, event_types or ())) - set(NOTIFICATION_TYPES))
+                    problem = f"Unknown notification types: {', '.join(unknown)}" if unknown else None
+                if problem:
+                    await websocket.send_text(json.dumps({
+                        "type": "error",
+                        "message": problem,
+                        "timestamp": datetime.utcnow().isoformat()
+                    }))
+                    return
+                accepted = None if event_types is None else set(event_types)
+                self.connection_manager.set_notification_filter(websocket, accepted)
+                await websocket.send_text(json.dumps({
+                    "type": "notification_subscription_confirmed",
+                    "event_types": sorted(accepted) if accepted is not None else list(NOTIFICATION_TYPES),
+                    "timestamp": datetime.utcnow().isoformat()
+                }))
+            
             elif message_type == "cancel_pipeline":
                 pipeline_id = data.get("pipeline_id")
                 if pipeline_id:
@@ -219,6 +405,10 @@ class WebSocketManager:
                     "timestamp": datetime.utcnow().isoformat()
                 }))
             
+            elif message_type == "pong":
+                # Reply to a server ping; receiving it already reset the idle timeout
+                pass
+            
             else:
                 self.logger.warning(f"Unknown message type: {message_type}")
                 
@@ -287,6 +477,16 @@ class WebSocketManager:
         
         await self.connection_manager.broadcast_to_pipeline(pipeline_id, message)
     
+    async def send_backfill_progress(self, user_id: str, progress: Dict[str, Any]):
+        """Send summary backfill progress to a user's general connections."""
+        message = {
+            "type": "summary_backfill_progress",
+            **progress,
+            "timestamp": datetime.utcnow().isoformat()
+        }
+        
+        await self.connection_manager.send_to_user(user_id, message)
+    
     async def get_stats(self) -> Dict[str, Any]:
         """Get WebSocket manager statistics."""
         connection_stats = await self.connection_manager.get_connection_stats()
@@ -298,7 +498,8 @@ class WebSocketManager:
                 "real_time_updates": True,
                 "pipeline_subscriptions": True,
                 "job_progress_tracking": True,
-                "error_notifications": True
+                "error_notifications": True,
+                "notifications": list(NOTIFICATION_TYPES)
             }
         }
 
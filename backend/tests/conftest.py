@@ -256,14 +256,18 @@ def sample_action_items_response():
         "confidence": 0.88,
         "action_items": [
             {
-                "task": "Review budget line items",
+                "description": "Review budget line items",
+                "owner": "me",
                 "deadline": "2025-01-20",
-                "priority": "high"
+                "source_quote": None,
+                "links": ["https://company.com/budget/q4"]
             },
             {
-                "task": "Provide approval or feedback",
+                "description": "Provide approval or feedback",
+                "owner": "me",
                 "deadline": "2025-01-20",
-                "priority": "high"
+                "source_quote": None,
+                "links": []
             }
         ],
         "links": [
@@ -119,10 +119,11 @@ def get_action_items_response_single() -> Dict[str, Any]:
         "confidence": 0.88,
         "action_items": [
             {
-                "task": "Complete employee satisfaction survey",
+                "description": "Complete employee satisfaction survey",
+                "owner": "me",
                 "deadline": "2025-01-20",
-                "priority": "medium",
-                "estimated_time": "15 minutes"
+                "source_quote": "Please complete the satisfaction survey by January 20.",
+                "links": ["https://surveys.company.com/employee-satisfaction"]
             }
         ],
         "links": [
@@ -144,22 +145,25 @@ def get_action_items_response_multiple() -> Dict[str, Any]:
         "confidence": 0.93,
         "action_items": [
             {
-                "task": "Review budget line items for accuracy",
+                "description": "Review budget line items for accuracy",
+                "owner": "me",
                 "deadline": "2025-01-19",
-                "priority": "high",
-                "estimated_time": "30 minutes"
+                "source_quote": None,
+                "links": []
             },
             {
-                "task": "Provide approval or feedback",
+                "description": "Provide approval or feedback",
+                "owner": "me",
                 "deadline": "2025-01-20",
-                "priority": "high",
-                "estimated_time": "15 minutes"
+                "source_quote": None,
+                "links": []
             },
             {
-                "task": "Submit signed approval form",
+                "description": "Submit signed approval form",
+                "owner": "me",
                 "deadline": "2025-01-20",
-                "priority": "high",
-                "estimated_time": "5 minutes"
+                "source_quote": None,
+                "links": []
             }
         ],
         "links": [
@@ -182,16 +186,18 @@ def get_action_items_response_urgent() -> Dict[str, Any]:
         "confidence": 0.98,
         "action_items": [
             {
-                "task": "Join emergency conference call",
-                "deadline": "Immediately",
-                "priority": "urgent",
-                "estimated_time": "Unknown"
+                "description": "Join emergency conference call",
+                "owner": "me",
+                "deadline": None,
+                "source_quote": None,
+                "links": []
             },
             {
-                "task": "Investigate database connection failure",
-                "deadline": "Immediately",
-                "priority": "urgent",
-                "estimated_time": "Unknown"
+                "description": "Investigate database connection failure",
+                "owner": "team",
+                "deadline": None,
+                "source_quote": None,
+                "links": []
             }
         ],
         "links": []
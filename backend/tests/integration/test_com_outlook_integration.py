@@ -165,7 +165,7 @@ class TestCOMOutlookIntegration:
         # Mark email as read
         result = com_email_provider.mark_as_read('email-456')
         assert result is True
-        mock_outlook_adapter.mark_as_read.assert_called_once_with('email-456')
+        mock_outlook_adapter.mark_as_read.assert_called_once_with('email-456', suppress_read_receipt=False)
     
     def test_move_email_workflow(self, com_email_provider, mock_outlook_adapter):
         """Test moving emails between folders."""
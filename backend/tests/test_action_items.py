@@ -0,0 +1,170 @@
+"""Tests for parsing structured action items and mapping them to tasks."""
+
+from datetime import datetime
+
+import pytest
+
+from backend.core.config import settings
+from backend.models.task import TaskPriority
+from backend.services.action_items import (
+    category_task_fields, normalize_action_item, normalize_owner, parse_action_items, parse_deadline,
+    task_fields_for_item
+)
+from backend.services.classification_store import PROMPTS_DIR
+
+
+class TestNormalizeOwner:
+    """Tests for mapping model owners to me, team, or other."""
+
+    @pytest.mark.parametrize("raw,expected", [
+        ("me", "me"),
+        ("ME", "me"),
+        ("you", "me"),
+        (None, "me"),
+        ("", "me"),
+        ("team", "team"),
+        ("We", "team"),
+        ("other", "other"),
+        ("finance", "other"),
+    ])
+    def test_owner(self, raw, expected):
+        """Test owners, aliases, and the default."""
+        assert normalize_owner(raw) == expected
+
+
+class TestParseDeadline:
+    """Tests for reading item deadlines."""
+
+    @pytest.mark.parametrize("raw,expected", [
+        ("2024-01-15", datetime(2024, 1, 15, 23, 59, 59)),
+        ("2024-01-15T09:30:00", datetime(2024, 1, 15, 9, 30)),
+        ("2024-01-15T09:30:00Z", datetime(2024, 1, 15, 9, 30)),
+        ("End of week", None),
+        ("No specific deadline", None),
+        ("2024-13-45", None),
+        (None, None),
+        (20240115, None),
+    ])
+    def test_deadline(self, raw, expected):
+        """Test ISO dates and date-times parse and phrases do not."""
+        assert parse_deadline(raw) == expected
+
+
+class TestParseActionItems:
+    """Tests for normalizing action item prompt output."""
+
+    def test_structured_items(self):
+        """Test that a well-formed array is normalized item by item."""
+        items = parse_action_items({
+            "action_required": "Review report",
+            "action_items": [
+                {"description": " Review   report ", "owner": "me", "deadline": "2024-01-15",
+                 "source_quote": " Please review. ", "links": ["https://a.example", 7]},
+                {"description": "Book room", "owner": "team"},
+                {"description": "", "owner": "me"},
+                "Send notes",
+                42,
+            ]
+        })
+
+        assert items == [
+            {"description": "Review report", "owner": "me", "deadline": datetime(2024, 1, 15, 23, 59, 59),
+             "source_quote": "Please review.", "links": ["https://a.example"]},
+            {"description": "Book room", "owner": "team", "deadline": None, "source_quote": None, "links": []},
+            {"description": "Send notes", "owner": "me", "deadline": None, "source_quote": None, "links": []},
+        ]
+
+    def test_legacy_output(self):
+        """Test that outputs without the array yield their single action."""
+        items = parse_action_items({
+            "action_required": "Review email manually",
+            "due_date": "No deadline",
+            "links": ["https://a.example"],
+        })
+
+        assert items == [{
+            "description": "Review email manually", "owner": "me", "deadline": None,
+            "source_quote": None, "links": ["https://a.example"],
+        }]
+
+    def test_no_action(self):
+        """Test that an empty array or missing action yields nothing."""
+        assert parse_action_items({"action_required": "Review", "action_items": []}) == []
+        assert parse_action_items({"action_required": None}) == []
+
+    def test_prompt_requests_items(self):
+        """Test that the template asks for every item field."""
+        text = (PROMPTS_DIR / "summerize_action_item.prompty").read_text(encoding="utf-8")
+
+        for field in ('"action_items"', '"description"', '"owner"', '"deadline"', '"source_quote"'):
+            assert field in text
+
+
+class TestTaskFields:
+    """Tests for the task fields derived from an item."""
+
+    @pytest.mark.parametrize("owner,priority", [
+        ("me", TaskPriority.HIGH),
+        ("team", TaskPriority.MEDIUM),
+        ("other", TaskPriority.LOW),
+    ])
+    def test_priority_follows_owner(self, owner, priority):
+        """Test that items owned by the user get the highest priority."""
+        item = normalize_action_item({"description": "Do it", "owner": owner, "deadline": "2024-01-15"})
+
+        assert task_fields_for_item(item) == {
+            "priority": priority, "due_date": datetime(2024, 1, 15, 23, 59, 59)
+        }
+
+
+class TestCategoryTaskDefaults:
+    """Tests for applying settings.task_defaults to the tasks of a category."""
+
+    NOW = datetime(2024, 1, 11, 9, 30)  # A Thursday
+
+    @pytest.fixture(autouse=True)
+    def task_defaults(self, monkeypatch):
+        """Defaults for required_personal_action and team_action, with Friday a holiday."""
+        monkeypatch.setattr(settings, "task_defaults", {
+            "required_personal_action": {"priority": "high", "due_in_business_days": 2},
+            "team_action": {"due_in_business_days": 0},
+        })
+        monkeypatch.setattr(settings, "task_holidays", ["2024-01-12"])
+
+    def test_defaults_fill_missing_deadline(self):
+        """Test that the category priority replaces the owner's and the offset skips the weekend and holiday."""
+        item = normalize_action_item({"description": "Do it", "owner": "other"})
+
+        assert task_fields_for_item(item, "required_personal_action", now=self.NOW) == {
+            "priority": TaskPriority.HIGH, "due_date": datetime(2024, 1, 16, 23, 59, 59)
+        }
+
+    def test_deadline_wins(self):
+        """Test that a deadline from the AI is kept over the offset."""
+        item = normalize_action_item({"description": "Do it", "owner": "team", "deadline": "2024-02-01"})
+
+        assert task_fields_for_item(item, "required_personal_action", now=self.NOW)["due_date"] == datetime(
+            2024, 2, 1, 23, 59, 59
+        )
+
+    def test_partial_entry(self):
+        """Test that an entry without a priority keeps the owner's priority."""
+        item = normalize_action_item({"description": "Do it", "owner": "team"})
+
+        assert task_fields_for_item(item, "team_action", now=self.NOW) == {
+            "priority": TaskPriority.MEDIUM, "due_date": datetime(2024, 1, 11, 23, 59, 59)
+        }
+
+    def test_category_without_defaults(self):
+        """Test that other categories and no category are unchanged."""
+        item = normalize_action_item({"description": "Do it", "owner": "other"})
+
+        assert task_fields_for_item(item, "fyi", now=self.NOW) == {"priority": TaskPriority.LOW, "due_date": None}
+        assert category_task_fields(None) == {}
+
+    def test_changes_apply_immediately(self, monkeypatch):
+        """Test that the settings are read on every call."""
+        monkeypatch.setattr(settings, "task_defaults", {"fyi": {"priority": "urgent"}})
+
+        assert category_task_fields("fyi") == {"priority": TaskPriority.URGENT}
+        assert category_task_fields("required_personal_action") == {}
@@ -0,0 +1,211 @@
+"""Tests for the Outlook activity log."""
+
+from datetime import datetime, timedelta
+from unittest.mock import patch
+
+import pytest
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from backend.api import activity, emails
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.activity_log import ActivityLog, get_activity_log
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+# (operation, call on the service, recorded parameters)
+MUTATIONS = [
+    ("mark_as_read", lambda s: s.mark_as_read("mock-email-1"), {"suppress_read_receipt": False}),
+    ("move_email", lambda s: s.move_email("mock-email-1", "Archive"), {"destination_folder": "Archive"}),
+    ("categorize_email", lambda s: s.categorize_email("mock-email-1", "fyi"), {"category": "fyi"}),
+    ("categorize_email", lambda s: s.apply_category("mock-email-1", "fyi", USER_ID), {"category": "fyi"}),
+    ("delete_email", lambda s: s.delete_email("mock-email-1"), {}),
+    ("create_draft_reply", lambda s: s.create_draft_reply("mock-email-1", "Re: Hi", "Thanks!"),
+     {"subject": "Re: Hi", "body_length": 7}),
+]
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory database."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock provider."""
+    mock = MockEmailProvider()
+    mock.authenticate({})
+    return mock
+
+
+@pytest.fixture
+def service(provider, store):
+    """Email service over the mock provider and in-memory store."""
+    return EmailService(provider, db=store)
+
+
+def activity_rows(store):
+    """Every recorded activity row, oldest first."""
+    with store.get_connection() as conn:
+        return [dict(row) for row in conn.execute("SELECT * FROM outlook_activity ORDER BY id")]
+
+
+def add_entry(store, operation, created_at):
+    """Insert an activity row recorded at a given time."""
+    with store.get_connection() as conn:
+        conn.execute(
+            """
+            INSERT INTO outlook_activity (operation, parameters, result, duration_ms, created_at)
+            VALUES (?, '{}', 'success', 1.0, ?)
+            """,
+            (operation, created_at)
+        )
+        conn.commit()
+
+
+class TestServiceRecording:
+    """Tests for the activity rows written by EmailService."""
+
+    @pytest.mark.asyncio
+    @pytest.mark.parametrize("operation,call,parameters", MUTATIONS)
+    async def test_one_row_per_mutation(self, service, store, operation, call, parameters):
+        """Test that each mutating method records exactly one row."""
+        await call(service)
+
+        rows = activity_rows(store)
+        assert len(rows) == 1
+        assert (rows[0]["operation"], rows[0]["email_id"], rows[0]["result"]) == (operation, "mock-email-1", "success")
+        assert ActivityLog(store)._row_to_entry(rows[0]).parameters == parameters
+        assert rows[0]["duration_ms"] >= 0
+        assert rows[0]["request_id"] is None
+
+    @pytest.mark.asyncio
+    async def test_ensure_categories(self, service, store):
+        """Test that creating categories is recorded without an email."""
+        await service.ensure_categories(force_colors=True)
+
+        rows = activity_rows(store)
+        assert [(row["operation"], row["email_id"]) for row in rows] == [("ensure_categories", None)]
+        assert '"force_colors": true' in rows[0]["parameters"]
+
+    @pytest.mark.asyncio
+    async def test_declined_and_raised(self, service, provider, store, monkeypatch):
+        """Test that declined calls are failed and raising calls are errors."""
+        monkeypatch.setattr(settings, "outbox_enabled", False)
+        assert await service.move_email("ghost", "Archive") is False
+        provider.authenticated = False
+        with pytest.raises(HTTPException):
+            await service.mark_as_read("mock-email-1")
+
+        rows = activity_rows(store)
+        assert [(row["operation"], row["result"]) for row in rows] == [
+            ("move_email", "failed"), ("mark_as_read", "error")
+        ]
+        assert "Not authenticated" in rows[1]["error"]
+
+    @pytest.mark.asyncio
+    async def test_logging_failure_does_not_block(self, service, store):
+        """Test that the change succeeds and the failure is logged when the log cannot be written."""
+        with store.get_connection() as conn:
+            conn.execute("DROP TABLE outlook_activity")
+            conn.commit()
+
+        with patch("backend.services.activity_log.logger") as logger:
+            assert await service.move_email("mock-email-1", "Archive") is True
+
+        logger.error.assert_called_once()
+        assert "move_email" in logger.error.call_args.args[0]
+
+
+class TestPrune:
+    """Tests for activity log retention."""
+
+    @pytest.mark.asyncio
+    async def test_prunes_expired_entries(self, store):
+        """Test that only entries older than the retention period are deleted."""
+        now = datetime(2024, 6, 30)
+        add_entry(store, "move_email", now - timedelta(days=31))
+        add_entry(store, "mark_as_read", now - timedelta(days=29))
+
+        assert await ActivityLog(store).prune(30, now=now) == 1
+        assert [row["operation"] for row in activity_rows(store)] == ["mark_as_read"]
+
+    @pytest.mark.asyncio
+    async def test_zero_retention_keeps_everything(self, store):
+        """Test that a retention of 0 never prunes."""
+        add_entry(store, "move_email", datetime(2000, 1, 1))
+
+        assert await ActivityLog(store).prune(0) == 0
+        assert len(activity_rows(store)) == 1
+
+
+class TestActivityAPI:
+    """Tests for GET /api/activity and request IDs on recorded changes."""
+
+    @pytest.fixture
+    def client(self, service, store):
+        """Client with auth, the email service, and the activity log overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(emails.router, prefix="/api")
+        app.include_router(activity.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="auditor", email="auditor@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_activity_log] = lambda: ActivityLog(store)
+        return TestClient(app)
+
+    def test_records_request_id(self, client):
+        """Test that changes made by a request carry its request ID."""
+        client.post(
+            "/api/emails/mock-email-1/move", params={"destination_folder": "Archive"},
+            headers={"X-Request-ID": "req-123"}
+        )
+
+        entries = client.get("/api/activity").json()["entries"]
+        assert [(e["operation"], e["request_id"]) for e in entries] == [("move_email", "req-123")]
+        assert entries[0]["parameters"] == {"destination_folder": "Archive"}
+
+    def test_filters(self, client, store):
+        """Test filtering by operation and time range, newest first."""
+        add_entry(store, "move_email", datetime(2024, 1, 1))
+        add_entry(store, "move_email", datetime(2024, 1, 3))
+        add_entry(store, "delete_email", datetime(2024, 1, 2))
+
+        data = client.get("/api/activity", params={"operation": "move_email"}).json()
+        assert data["total"] == 2
+        assert [e["created_at"][:10] for e in data["entries"]] == ["2024-01-03", "2024-01-01"]
+
+        data = client.get("/api/activity", params={
+            "since": "2024-01-02T00:00:00", "until": "2024-01-03T00:00:00+00:00"
+        }).json()
+        assert [e["operation"] for e in data["entries"]] == ["delete_email"]
+
+    def test_pagination(self, client, store):
+        """Test limit, offset, and has_more."""
+        for day in range(1, 4):
+            add_entry(store, "move_email", datetime(2024, 1, day))
+
+        data = client.get("/api/activity", params={"limit": 2}).json()
+        assert (len(data["entries"]), data["total"], data["has_more"]) == (2, 3, True)
+        assert client.get("/api/activity", params={"limit": 2, "offset": 2}).json()["has_more"] is False
+
+    @pytest.mark.parametrize("params", [
+        {"operation": "send_email"},
+        {"since": "2024-01-03T00:00:00", "until": "2024-01-01T00:00:00"},
+    ])
+    def test_invalid_filters(self, client, params):
+        """Test that unknown operations and empty ranges are rejected."""
+        assert client.get("/api/activity", params=params).status_code == 422
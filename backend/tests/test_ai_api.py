@@ -1,5 +1,8 @@
 """Tests for AI processing API endpoints."""
 
+import time
+from datetime import datetime
+
 import pytest
 from unittest.mock import patch, AsyncMock, MagicMock
 from fastapi.testclient import TestClient
@@ -7,7 +10,15 @@ from backend.main import app
 from backend.models.ai_models import (
     EmailClassificationRequest, ActionItemRequest, SummaryRequest
 )
-from backend.core.dependencies import reset_dependencies
+from backend.models.user import UserInDB
+from backend.api.ai import ai_rate_limiter
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_ai_service, get_email_service, reset_dependencies
+from backend.core.errors import APIError
+from backend.core.rate_limit import RateLimiter
+from backend.database.connection import DatabaseManager
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
 
 client = TestClient(app)
 
@@ -17,8 +28,10 @@ client = TestClient(app)
 def reset_deps():
     """Reset dependencies before and after each test."""
     reset_dependencies()
+    ai_rate_limiter.reset()
     yield
     reset_dependencies()
+    ai_rate_limiter.reset()
 
 
 @pytest.fixture
@@ -113,8 +126,9 @@ class TestAIClassification:
             headers=auth_headers
         )
         
-        assert response.status_code == 500
-        assert "AI classification failed" in response.json()["detail"]
+        assert response.status_code == 502
+        assert response.json()["error"]["code"] == "upstream_ai"
+        assert "AI classification failed" in response.json()["error"]["message"]
     
     def test_classify_email_unauthorized(self):
         """Test email classification without authentication."""
@@ -177,7 +191,8 @@ class TestActionItemExtraction:
         
         assert response.status_code == 200
         data = response.json()
-        assert len(data["action_items"]) == 2
+        assert data["action_items"] == ["Review quarterly report", "Submit feedback by Friday"]
+        assert [item["owner"] for item in data["items"]] == ["me", "me"]
         assert data["urgency"] == "high"
         assert data["deadline"] == "Friday"
         assert data["confidence"] == 0.85
@@ -212,6 +227,39 @@ class TestActionItemExtraction:
         data = response.json()
         assert len(data["action_items"]) == 0
         assert data["urgency"] == "none"
+    
+    @patch('backend.services.ai_service.AIService.extract_action_items')
+    def test_extract_action_items_structured(self, mock_extract, auth_headers):
+        """Test that structured items are returned with the legacy string list."""
+        mock_extract.return_value = {
+            "action_items": [
+                {"description": "Review quarterly report", "owner": "me",
+                 "deadline": datetime(2024, 1, 15, 23, 59, 59),
+                 "source_quote": "Please review the quarterly report.", "links": ["https://company.com/q4"]},
+                {"description": "Collect team numbers", "owner": "team", "deadline": None,
+                 "source_quote": "The team should send their numbers.", "links": []}
+            ],
+            "confidence": 0.85,
+            "due_date": "2024-01-15",
+            "action_required": "Review quarterly report",
+            "links": ["https://company.com/q4"]
+        }
+        
+        response = client.post(
+            "/api/ai/action-items",
+            json={"email_content": "Subject: Q4\n\nPlease review the quarterly report."},
+            headers=auth_headers
+        )
+        
+        assert response.status_code == 200
+        data = response.json()
+        assert data["action_items"] == ["Review quarterly report", "Collect team numbers"]
+        assert data["items"] == [
+            {"description": "Review quarterly report", "owner": "me", "deadline": "2024-01-15T23:59:59",
+             "source_quote": "Please review the quarterly report.", "links": ["https://company.com/q4"]},
+            {"description": "Collect team numbers", "owner": "team", "deadline": None,
+             "source_quote": "The team should send their numbers.", "links": []}
+        ]
 
 
 class TestEmailSummarization:
@@ -272,6 +320,473 @@ class TestEmailSummarization:
         assert len(data["key_points"]) >= 3
 
 
+class TestClassificationExplanation:
+    """Tests for the classification explanation endpoint."""
+    
+    @pytest.fixture
+    def stub_ai(self):
+        """Stub AI client returning a fixed explanation."""
+        ai = MagicMock()
+        ai.explain_classification = AsyncMock(return_value={
+            "supports_current": ["Sent to the whole team"],
+            "supports_alternative": ["Asks for an RSVP by Friday"],
+            "verdict": "prefer_alternative"
+        })
+        return ai
+    
+    @pytest.fixture
+    def explain_client(self, stub_ai):
+        """Client with a stored classified email and overridden dependencies."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        with store.get_connection() as conn:
+            conn.execute(
+                """
+                INSERT INTO emails (id, subject, sender, content, received_date,
+                                    category, ai_reasoning, user_id)
+                VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+                """,
+                ("email-1", "Team offsite", "lead@company.com", "RSVP by Friday",
+                 datetime.now(), "fyi", "Team announcement", 1)
+            )
+            conn.execute(
+                "INSERT INTO emails (id, subject, sender, user_id) VALUES (?, ?, ?, ?)",
+                ("unclassified", "Hello", "someone@company.com", 1)
+            )
+            conn.commit()
+        
+        user = UserInDB(id=1, username="explainer", email="explainer@example.com",
+                        hashed_password="x", created_at=datetime.now())
+        app.dependency_overrides[get_current_user] = lambda: user
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        app.dependency_overrides[get_email_service] = lambda: EmailService(MagicMock(), db=store)
+        yield TestClient(app)
+        app.dependency_overrides.clear()
+        store.close()
+    
+    def test_explain_uses_stored_email(self, explain_client, stub_ai):
+        """Test that the explanation reuses the cached email and classification."""
+        response = explain_client.post("/api/ai/explain", json={
+            "email_id": "email-1", "target_category": "optional_event"
+        })
+        
+        assert response.status_code == 200
+        data = response.json()
+        assert data["current_category"] == "fyi"
+        assert data["target_category"] == "optional_event"
+        assert data["supports_current"] == ["Sent to the whole team"]
+        assert data["supports_alternative"] == ["Asks for an RSVP by Friday"]
+        assert data["verdict"] == "prefer_alternative"
+        
+        kwargs = stub_ai.explain_classification.call_args.kwargs
+        assert kwargs["content"] == "RSVP by Friday"
+        assert kwargs["current_reasoning"] == "Team announcement"
+    
+    def test_explain_rejects_unknown_category(self, explain_client, stub_ai):
+        """Test that target categories are validated against the known set."""
+        response = explain_client.post("/api/ai/explain", json={
+            "email_id": "email-1", "target_category": "urgent_stuff"
+        })
+        
+        assert response.status_code == 422
+        assert "urgent_stuff" in response.json()["error"]["message"]
+        stub_ai.explain_classification.assert_not_called()
+    
+    def test_explain_missing_email(self, explain_client):
+        """Test that an email not in the database returns 404."""
+        response = explain_client.post("/api/ai/explain", json={
+            "email_id": "missing", "target_category": "fyi"
+        })
+        
+        assert response.status_code == 404
+    
+    def test_explain_unclassified_email(self, explain_client):
+        """Test that an email without a category cannot be explained."""
+        response = explain_client.post("/api/ai/explain", json={
+            "email_id": "unclassified", "target_category": "fyi"
+        })
+        
+        assert response.status_code == 422
+        assert "not been classified" in response.json()["error"]["message"]
+    
+    def test_explain_ai_failure(self, explain_client, stub_ai):
+        """Test that AI errors surface as upstream failures."""
+        stub_ai.explain_classification.return_value = {"error": "model timeout"}
+        
+        response = explain_client.post("/api/ai/explain", json={
+            "email_id": "email-1", "target_category": "optional_event"
+        })
+        
+        assert response.status_code == 502
+        assert "model timeout" in response.json()["error"]["message"]
+    
+    def test_explain_is_rate_limited(self, explain_client):
+        """Test that the endpoint shares the AI rate limit."""
+        with patch.object(ai_rate_limiter, "max_requests", 2):
+            responses = [
+                explain_client.post("/api/ai/explain", json={
+                    "email_id": "email-1", "target_category": "optional_event"
+                })
+                for _ in range(3)
+            ]
+        
+        assert [r.status_code for r in responses] == [200, 200, 429]
+        assert responses[2].json()["error"]["code"] == "rate_limited"
+        assert int(responses[2].headers["Retry-After"]) >= 1
+
+
+class TestDraftReplyEndpoint:
+    """Tests for the reply drafting endpoint."""
+    
+    @pytest.fixture
+    def stub_ai(self):
+        """Stub AI client returning a fixed draft."""
+        ai = MagicMock()
+        ai.draft_reply = AsyncMock(return_value={
+            "subject": "Re: Budget",
+            "body": "Thanks, I'll review the numbers by Friday."
+        })
+        return ai
+    
+    @pytest.fixture
+    def provider(self):
+        """Authenticated mock mailbox."""
+        provider = MockEmailProvider()
+        provider.authenticate({})
+        return provider
+    
+    @pytest.fixture
+    def draft_client(self, stub_ai, provider):
+        """Client with one stored email and overridden dependencies."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        with store.get_connection() as conn:
+            conn.execute(
+                """
+                INSERT INTO emails (id, subject, sender, content, received_date, user_id)
+                VALUES (?, ?, ?, ?, ?, ?)
+                """,
+                ("mock-email-1", "Budget", "cfo@company.com", "Please review the attached numbers",
+                 datetime(2024, 1, 8, 9, 0), 1)
+            )
+            conn.commit()
+        
+        user = UserInDB(id=1, username="drafter", email="drafter@example.com",
+                        hashed_password="x", created_at=datetime.now())
+        app.dependency_overrides[get_current_user] = lambda: user
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        app.dependency_overrides[get_email_service] = lambda: EmailService(provider, db=store)
+        yield TestClient(app)
+        app.dependency_overrides.clear()
+        store.close()
+    
+    def test_draft_reply_returns_draft(self, draft_client, stub_ai, provider):
+        """Test that a draft is returned without touching the mailbox."""
+        response = draft_client.post("/api/ai/draft-reply", json={
+            "email_id": "mock-email-1", "tone": "friendly", "bullet_points": ["Review by Friday"]
+        })
+        
+        assert response.status_code == 200
+        data = response.json()
+        assert data["subject"] == "Re: Budget"
+        assert data["body"] == "Thanks, I'll review the numbers by Friday."
+        assert data["tone"] == "friendly"
+        assert data["saved_as_draft"] is False
+        assert data["draft_id"] is None
+        assert provider.drafts == []
+        
+        kwargs = stub_ai.draft_reply.call_args.kwargs
+        assert kwargs["content"] == "Please review the attached numbers"
+        assert kwargs["bullet_points"] == ["Review by Friday"]
+        assert kwargs["received_date"].startswith("2024-01-08")
+    
+    def test_draft_reply_saves_draft(self, draft_client, provider):
+        """Test that save_as_draft saves the reply as a draft and never sends it."""
+        response = draft_client.post("/api/ai/draft-reply", json={
+            "email_id": "mock-email-1", "save_as_draft": True
+        })
+        
+        assert response.status_code == 200
+        data = response.json()
+        assert data["saved_as_draft"] is True
+        assert data["draft_id"] == "draft-1"
+        assert provider.drafts == [{
+            "id": "draft-1",
+            "in_reply_to": "mock-email-1",
+            "subject": "Re: Budget",
+            "body": "Thanks, I'll review the numbers by Friday."
+        }]
+    
+    def test_draft_reply_falls_back_to_mailbox(self, draft_client, stub_ai):
+        """Test that an email not yet stored is read from the mailbox."""
+        response = draft_client.post("/api/ai/draft-reply", json={"email_id": "mock-email-2"})
+        
+        assert response.status_code == 200
+        assert stub_ai.draft_reply.call_args.kwargs["subject"] == "Test Email 2"
+    
+    def test_draft_reply_rejects_unknown_tone(self, draft_client, stub_ai):
+        """Test that tones are validated before the model is called."""
+        response = draft_client.post("/api/ai/draft-reply", json={
+            "email_id": "mock-email-1", "tone": "sarcastic"
+        })
+        
+        assert response.status_code == 422
+        assert "sarcastic" in response.json()["error"]["message"]
+        stub_ai.draft_reply.assert_not_called()
+    
+    def test_draft_reply_missing_email(self, draft_client):
+        """Test that an unknown email returns 404."""
+        response = draft_client.post("/api/ai/draft-reply", json={"email_id": "missing"})
+        
+        assert response.status_code == 404
+    
+    def test_draft_reply_ai_failure(self, draft_client, stub_ai, provider):
+        """Test that AI errors surface as upstream failures and nothing is saved."""
+        stub_ai.draft_reply.return_value = {"error": "model timeout"}
+        
+        response = draft_client.post("/api/ai/draft-reply", json={
+            "email_id": "mock-email-1", "save_as_draft": True
+        })
+        
+        assert response.status_code == 502
+        assert provider.drafts == []
+
+
+class TestBatchSummaryEndpoint:
+    """Tests for the batch summary endpoint."""
+    
+    @pytest.fixture
+    def batch_client(self):
+        """Client over a store with read and unread emails in two categories."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        rows = (
+            ("b1", "fyi", 0, datetime(2024, 1, 3)),
+            ("b2", "fyi", 1, datetime(2024, 1, 2)),
+            ("b3", "fyi", 0, datetime(2024, 1, 1)),
+            ("b4", "newsletter", 0, datetime(2024, 1, 4)),
+        )
+        with store.get_connection() as conn:
+            for email_id, category, is_read, received in rows:
+                conn.execute(
+                    """
+                    INSERT INTO emails (id, subject, sender, content, category, is_read,
+                                        received_date, user_id)
+                    VALUES (?, ?, 'sender@example.com', 'Body', ?, ?, ?, 1)
+                    """,
+                    (email_id, email_id, category, is_read, received)
+                )
+            conn.commit()
+        
+        async def generate_summary(email_content, summary_type="brief"):
+            subject = email_content.splitlines()[0].replace("Subject: ", "")
+            if subject == "b3":
+                return {"summary": "Unable to generate summary", "confidence": 0.0, "error": "boom"}
+            return {"summary": f"Summary of {subject}", "key_points": ["Point"], "confidence": 0.8}
+        
+        ai = MagicMock()
+        ai.generate_summary = AsyncMock(side_effect=generate_summary)
+        user = UserInDB(id=1, username="batcher", email="batch@example.com",
+                        hashed_password="x", created_at=datetime.now())
+        app.dependency_overrides[get_current_user] = lambda: user
+        app.dependency_overrides[get_ai_service] = lambda: ai
+        app.dependency_overrides[get_email_service] = lambda: EmailService(MagicMock(), db=store)
+        yield TestClient(app), ai
+        app.dependency_overrides.clear()
+        store.close()
+    
+    def test_selector_resolves_from_database(self, batch_client):
+        """Test that a selector picks unread emails in a category, newest first."""
+        client, ai = batch_client
+        
+        response = client.post("/api/ai/summarize/batch", json={
+            "selector": {"category": "fyi", "unread_only": True, "since": "2024-01-01"}
+        })
+        
+        assert response.status_code == 207
+        data = response.json()
+        assert data["selector"]["category"] == "fyi"
+        assert data["resolved_count"] == 2
+        assert list(data["results"]) == ["b1", "b3"]
+        assert data["results"]["b1"]["summary"] == "Summary of b1"
+        assert data["results"]["b1"]["key_points"] == ["Point"]
+        assert data["results"]["b1"]["status_code"] == 200
+        assert data["results"]["b3"]["error"] == "boom"
+        assert (data["results"]["b3"]["status_code"], data["results"]["b3"]["is_retryable"]) == (502, True)
+        assert (data["success_count"], data["failure_count"]) == (1, 1)
+        assert ai.generate_summary.call_count == 2
+    
+    def test_selector_limit(self, batch_client):
+        """Test that the selector limit caps the resolved emails."""
+        client, _ = batch_client
+        
+        response = client.post("/api/ai/summarize/batch", json={"selector": {"limit": 1}})
+        
+        assert response.json()["resolved_count"] == 1
+        assert list(response.json()["results"]) == ["b4"]
+    
+    def test_explicit_ids(self, batch_client):
+        """Test that listed IDs are summarized and unknown ones get an error entry."""
+        client, ai = batch_client
+        
+        response = client.post("/api/ai/summarize/batch", json={"email_ids": ["b2", "missing", "b2"]})
+        
+        assert response.status_code == 207
+        data = response.json()
+        assert data["selector"] is None
+        assert data["resolved_count"] == 2
+        assert data["results"]["b2"]["summary"] == "Summary of b2"
+        assert data["results"]["missing"]["error"] == "Email not found"
+        assert (data["results"]["missing"]["status_code"], data["results"]["missing"]["is_retryable"]) == (404, False)
+        assert ai.generate_summary.call_count == 1
+    
+    def test_status_follows_items(self, batch_client):
+        """Test 200 when every email is summarized and the item status when none are."""
+        client, _ = batch_client
+        
+        assert client.post("/api/ai/summarize/batch", json={"email_ids": ["b1", "b2"]}).status_code == 200
+        assert client.post("/api/ai/summarize/batch", json={"email_ids": ["b3"]}).status_code == 502
+        assert client.post("/api/ai/summarize/batch", json={"email_ids": ["missing"]}).status_code == 404
+    
+    @pytest.mark.parametrize("body", [
+        {},
+        {"email_ids": ["b1"], "selector": {"category": "fyi"}},
+        {"selector": {"category": "urgent_stuff"}},
+        {"selector": {"since": "last tuesday"}},
+        {"email_ids": ["b1"], "summary_type": "epic"},
+    ])
+    def test_invalid_requests(self, batch_client, body):
+        """Test that both or neither input form, and bad selectors, are rejected."""
+        client, ai = batch_client
+        
+        response = client.post("/api/ai/summarize/batch", json=body)
+        
+        assert response.status_code == 422
+        ai.generate_summary.assert_not_called()
+
+
+class TestSummaryBackfillEndpoint:
+    """Tests for the summary backfill endpoint."""
+    
+    @pytest.fixture
+    def backfill_client(self):
+        """Client over a store with two emails missing summaries."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        with store.get_connection() as conn:
+            for email_id, category, summary in (("s1", "fyi", None), ("s2", "newsletter", ""),
+                                                ("s3", "fyi", "Done")):
+                conn.execute(
+                    """
+                    INSERT INTO emails (id, subject, sender, category, one_line_summary, user_id)
+                    VALUES (?, 'Subject', 'sender@example.com', ?, ?, 1)
+                    """,
+                    (email_id, category, summary)
+                )
+            conn.commit()
+        
+        ai = MagicMock()
+        ai.generate_summary = AsyncMock(return_value={"summary": "Short summary", "confidence": 0.8})
+        user = UserInDB(id=1, username="backfiller", email="backfill@example.com",
+                        hashed_password="x", created_at=datetime.now())
+        app.dependency_overrides[get_current_user] = lambda: user
+        app.dependency_overrides[get_ai_service] = lambda: ai
+        app.dependency_overrides[get_email_service] = lambda: EmailService(MagicMock(), db=store)
+        yield TestClient(app), ai
+        app.dependency_overrides.clear()
+        store.close()
+    
+    def test_dry_run_counts_without_calling_ai(self, backfill_client):
+        """Test that dry-run mode only counts matching emails."""
+        client, ai = backfill_client
+        
+        response = client.post("/api/ai/summaries/backfill", json={"dry_run": True, "limit": 1})
+        
+        assert response.status_code == 202
+        assert response.json() == {"missing": 2, "scheduled": 1, "dry_run": True}
+        ai.generate_summary.assert_not_called()
+    
+    def test_category_filter(self, backfill_client):
+        """Test that the category narrows the backfill."""
+        client, _ = backfill_client
+        
+        response = client.post("/api/ai/summaries/backfill",
+                               json={"category": "newsletter", "dry_run": True})
+        
+        assert response.json()["missing"] == 1
+    
+    def test_unknown_category_rejected(self, backfill_client):
+        """Test that categories are validated."""
+        client, _ = backfill_client
+        
+        response = client.post("/api/ai/summaries/backfill", json={"category": "misc"})
+        
+        assert response.status_code == 422
+    
+    def test_backfill_is_scheduled(self, backfill_client):
+        """Test that a real run schedules the emails missing summaries."""
+        client, _ = backfill_client
+        
+        response = client.post("/api/ai/summaries/backfill", json={})
+        
+        assert response.status_code == 202
+        assert response.json() == {"missing": 2, "scheduled": 2, "dry_run": False}
+    
+    def test_backfill_charges_rate_limit_per_email(self, backfill_client):
+        """Test that a run schedules only the emails the AI rate limit has room for."""
+        client, _ = backfill_client
+        
+        with patch.object(ai_rate_limiter, "max_requests", 1):
+            dry_run = client.post("/api/ai/summaries/backfill", json={"dry_run": True})
+            first = client.post("/api/ai/summaries/backfill", json={})
+            second = client.post("/api/ai/summaries/backfill", json={})
+        
+        assert dry_run.json()["scheduled"] == 1
+        assert first.json() == {"missing": 2, "scheduled": 1, "dry_run": False}
+        assert second.status_code == 429
+        assert "Retry-After" in second.headers
+
+
+class TestRateLimiter:
+    """Tests for the sliding-window rate limiter."""
+    
+    def test_window_slides(self):
+        """Test that requests are allowed again once the window passes."""
+        limiter = RateLimiter(max_requests=2, window_seconds=60)
+        now = time.monotonic()
+        
+        assert limiter.retry_after("user", now) == 0
+        assert limiter.retry_after("user", now + 1) == 0
+        assert limiter.retry_after("user", now + 2) == pytest.approx(58)
+        assert limiter.retry_after("user", now + 60) == 0
+    
+    def test_keys_are_independent(self):
+        """Test that each caller has its own budget."""
+        limiter = RateLimiter(max_requests=1)
+        now = time.monotonic()
+        
+        assert limiter.retry_after("alice", now) == 0
+        assert limiter.retry_after("bob", now) == 0
+        assert limiter.retry_after("alice", now) > 0
+    
+    def test_zero_disables_limit(self):
+        """Test that a limit of zero never rejects requests."""
+        limiter = RateLimiter(max_requests=0)
+        
+        for _ in range(10):
+            limiter.check("user")
+    
+    def test_acquire_grants_what_fits(self):
+        """Test that acquire records only as many requests as the window has room for."""
+        limiter = RateLimiter(max_requests=3, window_seconds=60)
+        now = time.monotonic()
+        
+        assert limiter.acquire("user", 2, now) == 2
+        assert limiter.acquire("user", 5, now + 1) == 1
+        assert limiter.remaining("user", now + 1) == 0
+        with pytest.raises(APIError) as excinfo:
+            limiter.acquire("user", 1, now + 2)
+        assert excinfo.value.status_code == 429
+        assert limiter.acquire("user", 5, now + 61) == 3
+        assert RateLimiter(max_requests=0).acquire("user", 500) == 500
+
+
 class TestAITemplates:
     """Tests for AI templates endpoint."""
     
@@ -375,7 +890,8 @@ class TestAIServiceIntegration:
         # All endpoints should either succeed or fail gracefully
         for response in [classify_response, action_response, summary_response]:
             # Should not return server errors (5xx) for valid input
-            assert response.status_code < 500 or response.status_code == 500
-            if response.status_code == 500:
+            assert response.status_code < 500 or response.status_code in (500, 502)
+            if response.status_code >= 500:
                 # If it fails, should be due to AI service unavailability
-                assert "AI" in response.json().get("detail", "") or "failed" in response.json().get("detail", "")
\ No newline at end of file
+                message = response.json()["error"]["message"]
+                assert "AI" in message or "failed" in message
\ No newline at end of file
@@ -0,0 +1,196 @@
+"""Tests for reporting Azure authentication failures from AI calls."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import AsyncMock, MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import ai as ai_api
+from backend.api.auth import get_current_user
+from backend.api.summary import check_ai_health
+from backend.core.dependencies import get_ai_service
+from backend.core.errors import AI_AUTH_MESSAGE, AIAuthError, register_error_handlers
+from backend.models.user import UserInDB
+from backend.services.ai_health import ai_health
+from backend.services.ai_service import AIService
+from azure_config import is_auth_error  # src/ is on the path once the AI service is imported
+
+
+class FakeResponse:
+    """HTTP response carrying only a status code."""
+
+    def __init__(self, status_code):
+        self.status_code = status_code
+
+
+class AuthenticationError(Exception):
+    """Stands in for openai.AuthenticationError, raised on a 401 from the endpoint."""
+
+    def __init__(self, status_code=401):
+        super().__init__(f"Error code: {status_code} - {{'error': {{'code': 'Unauthorized'}}}}")
+        self.response = FakeResponse(status_code)
+
+
+class CredentialUnavailableError(Exception):
+    """Stands in for azure.identity.CredentialUnavailableError."""
+
+
+class APIStatusError(Exception):
+    """An endpoint error other than authentication."""
+
+    def __init__(self, status_code):
+        super().__init__("Server error")
+        self.response = FakeResponse(status_code)
+
+
+@pytest.fixture(autouse=True)
+def reset_state():
+    """Forget the AI health state and rate limits before and after each test."""
+    ai_health.reset()
+    ai_api.ai_rate_limiter.reset()
+    yield
+    ai_health.reset()
+    ai_api.ai_rate_limiter.reset()
+
+
+@pytest.fixture
+def ai_service():
+    """AI service whose model calls fail as an expired az login does."""
+    service = AIService()
+    service.ai_processor = MagicMock()
+    service.ai_processor.classify_email_with_explanation.side_effect = AuthenticationError()
+    service.ai_processor.execute_prompty.side_effect = AuthenticationError()
+    service._initialized = True
+    return service
+
+
+@pytest.fixture
+def client(ai_service):
+    """Client for the AI endpoints over the failing service."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(ai_api.router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=1, username="user", email="user@example.com", hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_ai_service] = lambda: ai_service
+    return TestClient(app)
+
+
+class TestDetection:
+    """Tests for recognizing authentication failures."""
+
+    @pytest.mark.parametrize("error", [
+        AuthenticationError(),
+        AuthenticationError(403),
+        CredentialUnavailableError("EnvironmentCredential authentication unavailable"),
+        APIStatusError(401),
+        RuntimeError("DefaultAzureCredential failed to retrieve a token from the included credentials."),
+        RuntimeError("WrappedOpenAIError: Error code: 401 - Access denied due to invalid subscription key"),
+    ])
+    def test_auth_errors(self, error):
+        """Test token acquisition failures and 401/403 responses."""
+        assert is_auth_error(error)
+
+    @pytest.mark.parametrize("error", [
+        APIStatusError(500),
+        APIStatusError(429),
+        RuntimeError("Request timed out"),
+        ValueError("content_filter"),
+    ])
+    def test_other_errors(self, error):
+        """Test that other failures are not authentication failures."""
+        assert not is_auth_error(error)
+
+    def test_wrapped_error(self):
+        """Test that an authentication failure re-raised as another error is found."""
+        try:
+            try:
+                raise AuthenticationError()
+            except Exception as e:
+                raise RuntimeError(f"Email classification failed: {e}")
+        except RuntimeError as wrapped:
+            assert is_auth_error(wrapped)
+
+
+class TestService:
+    """Tests for the AI service raising AIAuthError."""
+
+    def test_raises_typed_error(self, ai_service):
+        """Test that a rejected credential is raised with the remediation message, not a fallback."""
+        with pytest.raises(AIAuthError) as raised:
+            asyncio.run(ai_service.classify_email_async(subject="Hi", content="Body", sender="a@example.com"))
+
+        assert raised.value.message == AI_AUTH_MESSAGE
+        assert isinstance(raised.value.__cause__, AuthenticationError)
+        assert ai_health.status == "auth_error"
+
+    def test_other_failures_still_degrade(self, ai_service):
+        """Test that other AI failures still return an error result and mark the AI unhealthy."""
+        ai_service.ai_processor.classify_email_with_explanation.side_effect = APIStatusError(500)
+
+        result = asyncio.run(ai_service.classify_email_async(subject="Hi", content="Body", sender="a@example.com"))
+
+        assert "error" in result
+        assert ai_health.status == "unhealthy"
+
+    def test_processor_raises_auth_errors(self, monkeypatch):
+        """Test that the service asks AIProcessor to raise authentication failures."""
+        processor = MagicMock()
+        monkeypatch.setattr("backend.services.ai_service.AIProcessor", lambda: processor)
+        monkeypatch.setattr("backend.services.ai_service.get_azure_config", MagicMock())
+        service = AIService()
+
+        service._ensure_initialized()
+
+        assert processor.raise_auth_errors is True
+
+
+class TestEndpoints:
+    """Tests for the error envelope and health reporting."""
+
+    def test_classify_maps_to_503(self, client):
+        """Test that an AI endpoint answers 503 ai_auth with the remediation message."""
+        response = client.post("/api/ai/classify", json={
+            "subject": "Hi", "content": "Body", "sender": "a@example.com"
+        })
+
+        assert response.status_code == 503
+        assert response.json()["error"]["code"] == "ai_auth"
+        assert response.json()["error"]["message"] == AI_AUTH_MESSAGE
+
+    def test_health_reports_auth_error(self, client, ai_service):
+        """Test that the AI health check keeps reporting auth_error until a model call succeeds."""
+        ai_service.get_available_templates = AsyncMock(return_value={"templates": ["a"]})
+        client.post("/api/ai/classify", json={"subject": "Hi", "content": "Body", "sender": "a@example.com"})
+
+        response = client.get("/api/ai/health")
+
+        assert response.status_code == 503
+        assert (response.json()["status"], response.json()["message"]) == ("auth_error", AI_AUTH_MESSAGE)
+
+        ai_service.ai_processor.classify_email_with_explanation.side_effect = None
+        ai_service.ai_processor.classify_email_with_explanation.return_value = {"category": "fyi"}
+        client.post("/api/ai/classify", json={"subject": "Hi", "content": "Body", "sender": "a@example.com"})
+
+        assert client.get("/api/ai/health").json()["status"] == "healthy"
+
+    def test_app_health_snapshot(self):
+        """Test that the monitor's snapshot shows the auth_error status for /health."""
+        asyncio.run(ai_health.record(False, "Error code: 401", auth_error=True))
+
+        snapshot = ai_health.snapshot()
+
+        assert (snapshot["status"], snapshot["last_error"]) == ("auth_error", "Error code: 401")
+        assert snapshot["since"]
+
+    def test_tray_summary_reports_auth_error(self, ai_service):
+        """Test that the tray summary's AI status shows the expired credential."""
+        asyncio.run(ai_health.record(False, "Error code: 401", auth_error=True))
+
+        summary = check_ai_health(ai_service)
+
+        assert (summary.status, summary.error) == ("auth_error", AI_AUTH_MESSAGE)
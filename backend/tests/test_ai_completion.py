@@ -0,0 +1,177 @@
+"""Tests for AIProcessor's prompt requests and fallbacks with a fake completer."""
+
+import json
+
+import pytest
+
+from backend.services.ai_service import AIProcessor
+# src/ is on the path once the AI service is imported
+from ai_completion import CompleterUnavailableError
+from ai_responses import content_filter_fallback, execution_error_fallback, repair_json
+
+
+class FakeCompleter:
+    """Completer returning response, or raising error, and keeping the requests it ran."""
+
+    def __init__(self, response="fyi", error=None):
+        self.response = response
+        self.error = error
+        self.requests = []
+
+    def complete(self, request):
+        self.requests.append(request)
+        if self.error:
+            raise self.error
+        return self.response
+
+
+class WrappedOpenAIError(Exception):
+    """Stands in for promptflow's wrapper around a filtered OpenAI call."""
+
+
+class AuthenticationError(Exception):
+    """Stands in for openai.AuthenticationError."""
+
+
+@pytest.fixture
+def processor(tmp_path):
+    """AIProcessor over an empty prompts directory whose completer is swapped per test."""
+    if AIProcessor is None:
+        pytest.skip("AI dependencies not available")
+    processor = AIProcessor.__new__(AIProcessor)
+    processor.prompts_dir = str(tmp_path)
+    processor.trace_hooks = []
+    processor.raise_auth_errors = False
+    return processor
+
+
+def run(processor, completer, prompty_file="email_classifier_with_explanation.prompty", inputs=None):
+    """Execute a template with the completer, returning the response and its trace event."""
+    events = []
+    processor.completer = completer
+    processor.add_trace_hook(events.append)
+    response = processor.execute_prompty(prompty_file, inputs or {"subject": "Quarterly report"})
+    return response, events[0]
+
+
+class TestRequests:
+    """Tests for the requests the completer gets."""
+
+    def test_request_from_resolvers(self, processor, tmp_path):
+        """Test that the request carries the resolved file, parameters, deployment, and inputs."""
+        processor.prompt_resolver = lambda name: tmp_path / "user" / name
+        processor.parameter_resolver = lambda name: {"temperature": 0.1}
+        processor.deployment_resolver = lambda name: "gpt-4o-mini"
+        completer = FakeCompleter()
+
+        response, event = run(processor, completer, "fyi_summary.prompty", {"subject": "Hi"})
+
+        request, = completer.requests
+        assert (response, event["error"]) == ("fyi", None)
+        assert request.template == "fyi_summary.prompty"
+        assert request.path == str(tmp_path / "user" / "fyi_summary.prompty")
+        assert (request.parameters, request.deployment, request.inputs) == (
+            {"temperature": 0.1}, "gpt-4o-mini", {"subject": "Hi"}
+        )
+
+    def test_defaults(self, processor, tmp_path):
+        """Test that without resolvers templates come from prompts_dir on the configured deployment."""
+        completer = FakeCompleter()
+
+        run(processor, completer, "fyi_summary.prompty")
+
+        request, = completer.requests
+        assert request.path == str(tmp_path / "fyi_summary.prompty")
+        assert (request.parameters, request.deployment) == ({}, None)
+
+    def test_injected_completer(self):
+        """Test that a completer passed to the constructor is used instead of the default."""
+        if AIProcessor is None:
+            pytest.skip("AI dependencies not available")
+        completer = FakeCompleter("Summary")
+
+        processor = AIProcessor(completer=completer)
+
+        assert processor.completer is completer
+        assert AIProcessor.completer is not completer
+
+
+class TestFallbacks:
+    """Tests for responses that stand in for failed calls."""
+
+    def test_content_filter(self, processor):
+        """Test that a filtered call gets the template's content filter fallback, traced as an error."""
+        response, event = run(processor, FakeCompleter(error=RuntimeError("Error: content_filter triggered")))
+
+        assert json.loads(response)["explanation"] == "Classification blocked by content filter"
+        assert event["error"].startswith("Content filter: ")
+        assert event["response"] == response
+
+    def test_wrapped_openai_error_is_filtered(self, processor):
+        """Test that promptflow's wrapped OpenAI errors count as content filter rejections."""
+        response, _ = run(processor, FakeCompleter(error=WrappedOpenAIError("Bad request")), "fyi_summary.prompty")
+
+        assert response == "• Summary blocked by content filter - Quarterly report"
+
+    def test_execution_error(self, processor):
+        """Test that other failures get the template's execution error fallback."""
+        response, event = run(
+            processor, FakeCompleter(error=ConnectionError("Connection reset")), "summerize_action_item.prompty"
+        )
+
+        assert json.loads(response)["explanation"] == "AI service unavailable"
+        assert event["error"] == "Connection reset"
+
+    def test_per_template(self):
+        """Test the fallback of each kind of template."""
+        inputs = {"subject": "x" * 100}
+
+        assert content_filter_fallback("email_one_line_summary.prompty", inputs) == (
+            f"Summary blocked by content filter - {'x' * 80}"
+        )
+        assert execution_error_fallback("email_one_line_summary.prompty", {}) == "AI unavailable - Email"
+        assert json.loads(execution_error_fallback("holistic_inbox_analyzer.prompty", {})) == {
+            "truly_relevant_actions": [], "superseded_actions": [], "duplicate_groups": [], "expired_items": []
+        }
+        assert content_filter_fallback("unknown.prompty", {}) == "Content filter blocked - manual review required"
+        assert execution_error_fallback("unknown.prompty", {}) == "AI processing unavailable"
+
+    def test_auth_errors_raise_when_asked(self, processor):
+        """Test that authentication failures are raised instead of falling back when raise_auth_errors is set."""
+        processor.raise_auth_errors = True
+        events = []
+        processor.completer = FakeCompleter(error=AuthenticationError("401 Unauthorized"))
+        processor.add_trace_hook(events.append)
+
+        with pytest.raises(AuthenticationError):
+            processor.execute_prompty("fyi_summary.prompty", {})
+        assert events[0]["error"] == "401 Unauthorized"
+
+    def test_missing_client_library_raises(self, processor):
+        """Test that a missing client library is raised rather than hidden behind a fallback."""
+        processor.completer = FakeCompleter(error=CompleterUnavailableError("Prompty library unavailable"))
+
+        with pytest.raises(CompleterUnavailableError):
+            processor.execute_prompty("fyi_summary.prompty", {})
+
+
+class TestRepairJson:
+    """Tests for repairing malformed JSON responses."""
+
+    def test_valid_json_is_unchanged(self):
+        """Test that valid JSON is returned as-is."""
+        assert repair_json(' {"category": "fyi"} ') == '{"category": "fyi"}'
+
+    def test_truncated_response(self):
+        """Test that an unterminated value and missing closing brackets are fixed."""
+        repaired = repair_json('{"action": {"topic": "Launch plan,\n  "priority": "high"')
+
+        assert json.loads(repaired) == {"action": {"topic": "Launch plan", "priority": "high"}}
+
+    def test_unrepairable(self):
+        """Test that text without JSON gives None and a broken holistic analysis an empty one."""
+        assert repair_json("Sorry, I can't help with that") is None
+        assert repair_json("") is None
+        assert json.loads(repair_json('{"truly_relevant_actions": [{"topic": }')) == {
+            "truly_relevant_actions": [], "superseded_actions": [], "duplicate_groups": [], "expired_items": []
+        }
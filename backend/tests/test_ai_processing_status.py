@@ -0,0 +1,311 @@
+"""Tests for per-email AI processing status and skipping already processed emails."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import AsyncMock, MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import emails as emails_api
+from backend.api import processing as processing_api
+from backend.api.auth import get_current_user
+from backend.api.processing import get_known_folders
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.classification_store import (
+    ClassificationStore, get_classification_store, prompt_version
+)
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.folder_profile_service import FolderProfileService, get_folder_profile_service
+from backend.services.job_queue import job_queue as shared_job_queue
+
+USER_ID = 1
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock mailbox."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return provider
+
+
+@pytest.fixture
+def service(store, provider):
+    """Email service over the in-memory store."""
+    return EmailService(provider, db=store)
+
+
+def ai_status(store, email_id):
+    """Read an email's AI processing columns."""
+    with store.get_connection() as conn:
+        row = conn.execute(
+            "SELECT ai_processed_at, ai_prompt_version, ai_model FROM emails WHERE id = ?",
+            (email_id,)
+        ).fetchone()
+    return dict(row) if row else None
+
+
+def mark_old_version(store, email_id):
+    """Pretend an email was classified by an earlier prompt version."""
+    with store.get_connection() as conn:
+        conn.execute(
+            "UPDATE emails SET ai_prompt_version = 'email_classifier_with_explanation@1.0' WHERE id = ?",
+            (email_id,)
+        )
+        conn.commit()
+
+
+class TestPromptVersion:
+    """Tests for identifying the classifier prompt version."""
+
+    def test_reads_frontmatter_version(self):
+        """Test that the version comes from the template frontmatter."""
+        assert prompt_version() == "email_classifier_with_explanation@2.1"
+        assert prompt_version("draft_reply.prompty") == "draft_reply@1.0"
+
+    def test_missing_template(self):
+        """Test that a missing template is reported as unversioned."""
+        assert prompt_version("missing.prompty") == "missing@unversioned"
+
+
+class TestProcessingStatusColumns:
+    """Tests for writing the AI processing columns."""
+
+    def test_migration_adds_columns(self, store):
+        """Test that the emails table has the status columns."""
+        with store.get_connection() as conn:
+            columns = {row["name"] for row in conn.execute("PRAGMA table_info(emails)")}
+
+        assert {"ai_processed_at", "ai_prompt_version", "ai_model"} <= columns
+
+    @pytest.mark.asyncio
+    async def test_save_classification_records_status(self, service, store):
+        """Test that batch classifications record the prompt version and model."""
+        await service.save_classification(
+            {"id": "e1", "subject": "Hi", "sender": "a@example.com", "body": "Hello"},
+            EmailClassification(category="fyi", confidence=0.9),
+            USER_ID
+        )
+
+        status = ai_status(store, "e1")
+        assert status["ai_prompt_version"] == prompt_version()
+        assert status["ai_model"] == settings.azure_openai_deployment
+        assert status["ai_processed_at"] is not None
+
+    @pytest.mark.asyncio
+    async def test_user_corrections_keep_status(self, service, store):
+        """Test that a user correction does not count as an AI classification."""
+        await service.save_classification(
+            {"id": "e1", "subject": "Hi", "sender": "a@example.com", "body": "Hello"},
+            EmailClassification(category="fyi", confidence=0.9),
+            USER_ID
+        )
+        mark_old_version(store, "e1")
+
+        await service.update_classifications(USER_ID, {"e1": "newsletter"})
+
+        assert ai_status(store, "e1")["ai_prompt_version"] == "email_classifier_with_explanation@1.0"
+
+    @pytest.mark.asyncio
+    async def test_pipeline_store_records_model(self, store):
+        """Test that pipeline classifications record the pipeline's deployment."""
+        classification_store = ClassificationStore(db=store)
+
+        await classification_store.save_classification(
+            {"id": "e1"}, "user_1", "fyi", 0.8, False, model="gpt-4o-mini"
+        )
+        await classification_store.save_classification({"id": "e2"}, "user_1", "fyi", 0.8, False)
+
+        assert ai_status(store, "e1")["ai_model"] == "gpt-4o-mini"
+        assert ai_status(store, "e2")["ai_model"] == settings.azure_openai_deployment
+
+    @pytest.mark.asyncio
+    async def test_pipeline_store_saves_full_row(self, store):
+        """Test that pipeline classifications store the same email columns as the API's."""
+        classification_store = ClassificationStore(db=store)
+        email = {
+            "id": "e1", "subject": "Plan", "sender": "a@example.com",
+            "body": "<p>Quarterly plan attached</p><script>track()</script>",
+            "to": ["me@example.com"], "cc": ["b@example.com"],
+            "folder": "Projects", "conversation_id": "conv-1", "message_class": "IPM.Note.SMIME",
+        }
+
+        await classification_store.save_classification(
+            email, "user_1", "fyi", 0.8, True, reasoning="Informational", importance_score=2
+        )
+
+        with store.get_connection() as conn:
+            row = dict(conn.execute("SELECT * FROM emails WHERE id = 'e1'").fetchone())
+        assert row["preview"] == "Quarterly plan attached"
+        assert "<script>" not in row["content"] and row["raw_content"] == email["body"]
+        assert (row["to_recipients"], row["cc_recipients"], row["recipient_count"]) == (
+            '["me@example.com"]', '["b@example.com"]', 2
+        )
+        assert (row["folder"], row["conversation_id"], row["is_protected"]) == ("Projects", "conv-1", 1)
+        assert (row["needs_review"], row["ai_reasoning"], row["importance_score"]) == (1, "Informational", 2)
+
+    @pytest.mark.asyncio
+    async def test_pipeline_keeps_review_flag_of_api_row(self, store):
+        """Test that an API save keeps the needs_review flag the pipeline set."""
+        await ClassificationStore(db=store).save_classification(
+            {"id": "e1", "subject": "Hi", "sender": "a@example.com"}, USER_ID, "fyi", 0.4, True
+        )
+
+        await EmailService(MockEmailProvider(), db=store).save_classification(
+            {"id": "e1", "subject": "Hi", "sender": "a@example.com", "body": "Hello"},
+            EmailClassification(category="newsletter", confidence=0.9),
+            USER_ID
+        )
+
+        with store.get_connection() as conn:
+            row = conn.execute("SELECT category, needs_review FROM emails WHERE id = 'e1'").fetchone()
+        assert (row["category"], row["needs_review"]) == ("newsletter", 1)
+
+    @pytest.mark.asyncio
+    async def test_processed_ids_match_current_version(self, store):
+        """Test that only emails classified by the current prompt count as processed."""
+        classification_store = ClassificationStore(db=store)
+        for email_id in ("current", "old"):
+            await classification_store.save_classification({"id": email_id}, "user_1", "fyi", 0.8, False)
+        mark_old_version(store, "old")
+
+        processed = await classification_store.get_processed_ids(["current", "old", "new"], "user_1")
+
+        assert processed == {"current"}
+        assert await classification_store.get_processed_ids(["current"], "user_2") == set()
+
+
+class TestBatchProcessSkips:
+    """Tests for skipping already processed emails in batch processing."""
+
+    @pytest.fixture
+    def stub_ai(self):
+        """Stub AI client classifying everything as fyi."""
+        ai = MagicMock()
+        ai.classify_email_async = AsyncMock(return_value={
+            "category": "fyi", "confidence": 0.9, "reasoning": "Informational"
+        })
+        return ai
+
+    @pytest.fixture
+    def client(self, service, stub_ai):
+        """Client with auth, AI, and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(emails_api.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="batcher", email="batcher@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        return TestClient(app)
+
+    def batch(self, client, **options):
+        response = client.post("/api/emails/batch-process", json={
+            "emails": [{"id": "mock-email-1"}, {"id": "mock-email-2"}],
+            "thread_mode": False,
+            **options
+        })
+        assert response.status_code == 200
+        return response.json()
+
+    def test_rerun_skips_processed_emails(self, client, stub_ai):
+        """Test that a second run skips emails classified by the current prompt."""
+        first = self.batch(client)
+        second = self.batch(client)
+
+        assert first["skipped_count"] == 0
+        assert first["successful_count"] == 2
+        assert second["skipped_count"] == 2
+        assert second["successful_count"] == 0
+        assert second["results"] == []
+        assert stub_ai.classify_email_async.call_count == 2
+
+    def test_prompt_change_reprocesses(self, client, store, stub_ai):
+        """Test that emails classified by an older prompt version are reprocessed."""
+        self.batch(client)
+        mark_old_version(store, "mock-email-2")
+
+        data = self.batch(client)
+
+        assert data["skipped_count"] == 1
+        assert data["successful_count"] == 1
+        assert ai_status(store, "mock-email-2")["ai_prompt_version"] == prompt_version()
+
+    def test_force_reprocess(self, client, stub_ai):
+        """Test that skip_already_processed=false reprocesses everything."""
+        self.batch(client)
+
+        data = self.batch(client, skip_already_processed=False)
+
+        assert data["skipped_count"] == 0
+        assert data["successful_count"] == 2
+        assert stub_ai.classify_email_async.call_count == 4
+
+
+class TestStartProcessingSkips:
+    """Tests for skipping already processed emails when starting a pipeline."""
+
+    @pytest.fixture
+    def client(self, store):
+        """Client with auth, the profile store, and the classification store overridden."""
+        classification_store = ClassificationStore(db=store)
+        for email_id in ("done_1", "done_2"):
+            asyncio.run(classification_store.save_classification({"id": email_id}, "user_1", "fyi", 0.8, False))
+
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(processing_api.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: {"user_id": "user_1"}
+        app.dependency_overrides[get_folder_profile_service] = lambda: FolderProfileService(db=store)
+        app.dependency_overrides[get_classification_store] = lambda: classification_store
+        app.dependency_overrides[get_known_folders] = lambda: None
+        return TestClient(app)
+
+    def test_skips_processed_emails(self, client):
+        """Test that only unprocessed emails get jobs and the skip is reported."""
+        data = client.post(
+            "/api/processing/start", json={"email_ids": ["done_1", "new_1", "done_2"]}
+        ).json()
+
+        assert data["email_count"] == 1
+        assert data["skipped_count"] == 2
+        pipeline = asyncio.run(shared_job_queue.get_pipeline(data["pipeline_id"]))
+        assert {job.email_id for job in pipeline.jobs} == {"new_1"}
+        shared_job_queue._pipelines.pop(data["pipeline_id"], None)
+
+    def test_all_processed_starts_nothing(self, client):
+        """Test that a fully processed request does not create a pipeline."""
+        data = client.post("/api/processing/start", json={"email_ids": ["done_1", "done_2"]}).json()
+
+        assert data["status"] == "skipped"
+        assert data["pipeline_id"] is None
+        assert data["skipped_count"] == 2
+
+    def test_force_reprocess(self, client):
+        """Test that skip_already_processed=false keeps every email."""
+        data = client.post(
+            "/api/processing/start",
+            json={"email_ids": ["done_1", "new_1"], "skip_already_processed": False}
+        ).json()
+
+        assert data["email_count"] == 2
+        assert data["skipped_count"] == 0
+        shared_job_queue._pipelines.pop(data["pipeline_id"], None)
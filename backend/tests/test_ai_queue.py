@@ -0,0 +1,153 @@
+"""Tests for the per-deployment AI request queue."""
+
+import asyncio
+import threading
+import time
+
+import pytest
+
+from backend.core.config import settings
+from backend.services.ai_queue import AIRequestQueue, ai_request_queue, track_queue_wait
+from backend.services.ai_service import AIService
+
+
+class FakeCalls:
+    """Blocking fake AI calls that record how many run at once."""
+
+    def __init__(self):
+        self.lock = threading.Lock()
+        self.in_flight = 0
+        self.max_in_flight = 0
+
+    def call(self, seconds=0.05):
+        with self.lock:
+            self.in_flight += 1
+            self.max_in_flight = max(self.max_in_flight, self.in_flight)
+        time.sleep(seconds)
+        with self.lock:
+            self.in_flight -= 1
+        return "ok"
+
+
+@pytest.fixture
+def queue_settings(monkeypatch):
+    """Two AI calls per deployment at once, with the global queue forgotten around the test."""
+    monkeypatch.setattr(settings, "ai_max_in_flight", 2)
+    ai_request_queue.reset()
+    yield
+    ai_request_queue.reset()
+
+
+class TestLimit:
+    """Tests for limiting concurrent calls per deployment."""
+
+    @pytest.mark.asyncio
+    async def test_burst_never_exceeds_limit(self, queue_settings):
+        """Test that a burst of 6 calls through AIService never has more than 2 in flight."""
+        calls = FakeCalls()
+        service = AIService()
+
+        results = await asyncio.gather(*(service._run_in_executor(calls.call) for _ in range(6)))
+
+        assert results == ["ok"] * 6
+        assert calls.max_in_flight == 2
+        snapshot = ai_request_queue.snapshot()["deployments"][settings.azure_openai_deployment]
+        assert (snapshot["requests"], snapshot["in_flight"], snapshot["queued"]) == (6, 0, 0)
+        assert snapshot["peak_in_flight"] == 2
+        assert snapshot["max_wait_seconds"] > 0
+
+    @pytest.mark.asyncio
+    async def test_callers_served_in_order(self):
+        """Test that waiting callers get slots first come, first served."""
+        queue = AIRequestQueue(max_in_flight=1)
+        order = []
+
+        async def call(name):
+            async with queue.slot():
+                order.append(name)
+                await asyncio.sleep(0.01)
+
+        await asyncio.gather(*(call(name) for name in "abcd"))
+
+        assert order == list("abcd")
+
+    @pytest.mark.asyncio
+    async def test_deployments_have_separate_queues(self, queue_settings):
+        """Test that calls to the triage deployment do not wait for the default deployment."""
+        calls = FakeCalls()
+        service = AIService()
+
+        await asyncio.gather(
+            *(service._run_in_executor(calls.call) for _ in range(2)),
+            *(service._run_in_executor(calls.call, deployment="gpt-4o-mini") for _ in range(2))
+        )
+
+        assert calls.max_in_flight == 4
+        assert set(ai_request_queue.snapshot()["deployments"]) == {settings.azure_openai_deployment, "gpt-4o-mini"}
+
+
+class TestCancellation:
+    """Tests for callers cancelled while queued."""
+
+    @pytest.mark.asyncio
+    async def test_cancelled_waiter_leaves_the_queue(self):
+        """Test that a cancelled queued call never runs and frees its place for the next one."""
+        queue = AIRequestQueue(max_in_flight=1)
+        ran = []
+
+        async def call(name):
+            async with queue.slot():
+                ran.append(name)
+                await asyncio.sleep(0.02)
+
+        first = asyncio.create_task(call("first"))
+        cancelled = asyncio.create_task(call("cancelled"))
+        last = asyncio.create_task(call("last"))
+        await asyncio.sleep(0)
+        assert queue.snapshot()["deployments"][settings.azure_openai_deployment]["queued"] == 2
+
+        cancelled.cancel()
+        await asyncio.gather(first, last)
+
+        assert ran == ["first", "last"]
+        assert cancelled.cancelled()
+        snapshot = queue.snapshot()["deployments"][settings.azure_openai_deployment]
+        assert (snapshot["in_flight"], snapshot["queued"], snapshot["requests"]) == (0, 0, 2)
+
+    @pytest.mark.asyncio
+    async def test_slot_granted_while_cancelled_is_passed_on(self):
+        """Test that a slot handed to a caller in the same step it was cancelled goes to the next caller."""
+        queue = AIRequestQueue(max_in_flight=1)
+        await queue.acquire()
+        cancelled = asyncio.create_task(queue.acquire())
+        waiting = asyncio.create_task(queue.acquire())
+        await asyncio.sleep(0)
+
+        queue.release()
+        cancelled.cancel()
+        await asyncio.wait_for(waiting, timeout=1)
+
+        assert cancelled.cancelled()
+        assert queue.snapshot()["deployments"][settings.azure_openai_deployment]["in_flight"] == 1
+
+
+class TestQueueWait:
+    """Tests for reporting queue wait in processing time metadata."""
+
+    @pytest.mark.asyncio
+    async def test_wait_added_up_across_tasks(self):
+        """Test that track_queue_wait totals the waits of calls made in tasks started inside it."""
+        queue = AIRequestQueue(max_in_flight=1)
+
+        async def call():
+            async with queue.slot():
+                await asyncio.sleep(0.02)
+
+        with track_queue_wait() as wait:
+            await asyncio.gather(call(), call(), call())
+        inside = wait.seconds
+        await asyncio.gather(call(), call())
+
+        # The second call waits for one call, the third for two; calls after the block are not counted
+        assert 0.05 <= inside < 0.2
+        assert wait.seconds == inside
@@ -1,7 +1,11 @@
 """Tests for AI service wrapper."""
 
+import json
+from datetime import datetime
+
 import pytest
 from unittest.mock import patch, MagicMock, AsyncMock
+from backend.core.prompts import PromptLibrary
 from backend.services.ai_service import AIService, get_ai_service
 
 
@@ -186,47 +190,26 @@ class TestAIService:
         assert result["confidence"] == 0.0
         assert "error" in result
     
-    @patch('pathlib.Path.exists')
-    @patch('pathlib.Path.glob')
     @pytest.mark.asyncio
-    async def test_get_available_templates(self, mock_glob, mock_exists, ai_service):
+    async def test_get_available_templates(self, ai_service, tmp_path):
         """Test getting available prompt templates."""
-        # Mock templates directory exists
-        mock_exists.return_value = True
+        (tmp_path / "email_classifier.prompty").write_text(
+            "---\ndescription: Enhanced email classifier with explanations\n---\nTemplate content here"
+        )
+        (tmp_path / "email_summary.prompty").write_text("---\nname: Summary\n---\nTemplate content here")
         
-        # Mock template files
-        mock_template_files = [
-            MagicMock(name="email_classifier.prompty"),
-            MagicMock(name="email_summary.prompty")
-        ]
-        mock_template_files[0].name = "email_classifier.prompty"
-        mock_template_files[1].name = "email_summary.prompty"
-        mock_glob.return_value = mock_template_files
-        
-        # Mock file reading
-        template_content = """---
-description: Enhanced email classifier with explanations
----
-Template content here"""
-        
-        with patch('builtins.open', create=True) as mock_open:
-            mock_open.return_value.__enter__.return_value.read.return_value = template_content
-            
+        with patch('backend.services.ai_service.prompt_library', PromptLibrary(tmp_path)):
             result = await ai_service.get_available_templates()
         
-        assert len(result["templates"]) == 2
-        assert "email_classifier.prompty" in result["templates"]
-        assert "email_summary.prompty" in result["templates"]
-        assert len(result["descriptions"]) >= 0
+        assert result["templates"] == ["email_classifier.prompty", "email_summary.prompty"]
+        assert result["descriptions"]["email_classifier.prompty"] == "Enhanced email classifier with explanations"
+        assert result["layers"] == {"email_classifier.prompty": "bundled", "email_summary.prompty": "bundled"}
     
-    @patch('pathlib.Path.exists')
     @pytest.mark.asyncio
-    async def test_get_available_templates_no_directory(self, mock_exists, ai_service):
+    async def test_get_available_templates_no_directory(self, ai_service, tmp_path):
         """Test getting templates when directory doesn't exist."""
-        # Mock templates directory doesn't exist
-        mock_exists.return_value = False
-        
-        result = await ai_service.get_available_templates()
+        with patch('backend.services.ai_service.prompt_library', PromptLibrary(tmp_path / "missing")):
+            result = await ai_service.get_available_templates()
         
         assert len(result["templates"]) == 0
         assert len(result["descriptions"]) == 0
@@ -268,6 +251,43 @@ class TestAIServiceEdgeCases:
         assert result["category"] == "required_personal_action"
         assert result["confidence"] == 0.8
         assert "Email classified successfully" in result["reasoning"]
+        assert result["importance_score"] == 3
+    
+    @pytest.mark.parametrize("raw_score,expected", [
+        (4, 4),
+        ("2", 2),
+        (4.6, 5),
+        (9, 5),
+        (0, 1),
+        (None, 3),
+        ("high", 3),
+    ])
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_classify_email_importance_score(
+        self, mock_config, mock_processor, ai_service, raw_score, expected
+    ):
+        """Test that importance scores are clamped to 1-5 and default to 3."""
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_config.return_value = MagicMock()
+        
+        mock_ai_instance.classify_email_with_explanation.return_value = {
+            "category": "team_action",
+            "explanation": "Review requested from our team",
+            "importance_score": raw_score,
+            "importance_justification": "Blocks a rollout"
+        }
+        
+        result = await ai_service.classify_email_async(
+            subject="PR review",
+            content="Please review",
+            sender="dev@example.com"
+        )
+        
+        assert result["importance_score"] == expected
+        assert result["importance_justification"] == "Blocks a rollout"
     
     @patch('backend.services.ai_service.AIProcessor')
     @patch('backend.services.ai_service.get_azure_config')
@@ -291,6 +311,39 @@ class TestAIServiceEdgeCases:
         assert result["due_date"] == "2024-01-15"
         assert result["confidence"] == 0.8
     
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_extract_action_items_structured(self, mock_config, mock_processor, ai_service):
+        """Test that the action_items array is returned as structured items."""
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_config.return_value = MagicMock()
+        mock_ai_instance.execute_prompty.return_value = json.dumps({
+            "due_date": "2024-01-15",
+            "action_required": "Review report",
+            "explanation": "Manager request",
+            "relevance": "Work task",
+            "links": [],
+            "action_items": [
+                {"description": "Review report", "owner": "me", "deadline": "2024-01-15",
+                 "source_quote": "Please review the report by Monday.", "links": ["https://company.com/r"]},
+                {"description": "Book the review room", "owner": "Team", "deadline": "next week",
+                 "source_quote": "Someone on the team should book a room.", "links": []}
+            ]
+        })
+        
+        result = await ai_service.extract_action_items(
+            email_content="Subject: Report\nFrom: manager@company.com\n\nReview this."
+        )
+        
+        assert result["action_items"] == [
+            {"description": "Review report", "owner": "me", "deadline": datetime(2024, 1, 15, 23, 59, 59),
+             "source_quote": "Please review the report by Monday.", "links": ["https://company.com/r"]},
+            {"description": "Book the review room", "owner": "team", "deadline": None,
+             "source_quote": "Someone on the team should book a room.", "links": []}
+        ]
+    
     @patch('backend.services.ai_service.AIProcessor')
     @patch('backend.services.ai_service.get_azure_config')
     @pytest.mark.asyncio
@@ -332,4 +385,158 @@ class TestAIServiceEdgeCases:
         
         assert result["summary"] == "Unable to generate summary"
         assert result["confidence"] == 0.5
-        assert len(result["key_points"]) == 0
\ No newline at end of file
+        assert len(result["key_points"]) == 0
+
+class TestExplainClassification:
+    """Tests for classification explanations."""
+    
+    @pytest.fixture
+    def ai_service(self):
+        """Create AI service instance for testing."""
+        return AIService()
+    
+    async def _explain(self, ai_service):
+        return await ai_service.explain_classification(
+            subject="Team offsite",
+            sender="lead@company.com",
+            content="Optional offsite next Friday, RSVP if you can make it.",
+            current_category="fyi",
+            current_reasoning="Informational team announcement",
+            alternative_category="optional_event"
+        )
+    
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_explain_classification_success(self, mock_config, mock_processor, ai_service):
+        """Test that the explainer prompt is called with both categories."""
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_config.return_value = MagicMock()
+        
+        mock_ai_instance.execute_prompty.return_value = {
+            "supports_current": ["Announcement to the whole team"],
+            "supports_alternative": ["Asks for an RSVP", "Has a date"],
+            "verdict": "prefer_alternative"
+        }
+        
+        result = await self._explain(ai_service)
+        
+        assert result["verdict"] == "prefer_alternative"
+        assert result["supports_alternative"] == ["Asks for an RSVP", "Has a date"]
+        template, inputs = mock_ai_instance.execute_prompty.call_args[0]
+        assert template == "classification_explainer.prompty"
+        assert inputs["current_category"] == "fyi"
+        assert inputs["alternative_category"] == "optional_event"
+        assert inputs["current_reasoning"] == "Informational team announcement"
+    
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_explain_classification_fenced_json(self, mock_config, mock_processor, ai_service):
+        """Test that fenced JSON output is parsed and unknown verdicts become ambiguous."""
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_config.return_value = MagicMock()
+        
+        mock_ai_instance.execute_prompty.return_value = (
+            '```json\n{"supports_current": "Team-wide", "verdict": "maybe"}\n```'
+        )
+        
+        result = await self._explain(ai_service)
+        
+        assert result == {
+            "supports_current": ["Team-wide"],
+            "supports_alternative": [],
+            "verdict": "ambiguous"
+        }
+    
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_explain_classification_invalid_output(self, mock_config, mock_processor, ai_service):
+        """Test that unparseable output is reported as an error."""
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_config.return_value = MagicMock()
+        
+        mock_ai_instance.execute_prompty.return_value = "I think it is fine"
+        
+        result = await self._explain(ai_service)
+        
+        assert "invalid JSON" in result["error"]
+
+
+class TestDraftReply:
+    """Tests for reply drafting."""
+    
+    @pytest.fixture
+    def ai_service(self):
+        """Create AI service instance for testing."""
+        return AIService()
+    
+    def _processor(self, mock_config, mock_processor, output):
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_config.return_value = MagicMock()
+        mock_ai_instance.get_username.return_value = "Sam"
+        mock_ai_instance.get_job_context.return_value = "Release manager for the payments team"
+        mock_ai_instance.execute_prompty.return_value = output
+        return mock_ai_instance
+    
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_draft_reply_success(self, mock_config, mock_processor, ai_service):
+        """Test that the drafter prompt gets the email, tone, points, and user context."""
+        mock_ai_instance = self._processor(mock_config, mock_processor, {
+            "subject": "Re: Release date",
+            "body": "Hi Dana, we ship on the 14th."
+        })
+        
+        result = await ai_service.draft_reply(
+            subject="Release date",
+            sender="dana@company.com",
+            content="When does 2.3 ship?",
+            tone="formal",
+            bullet_points=["Ships on the 14th"]
+        )
+        
+        assert result == {"subject": "Re: Release date", "body": "Hi Dana, we ship on the 14th."}
+        template, inputs = mock_ai_instance.execute_prompty.call_args[0]
+        assert template == "draft_reply.prompty"
+        assert inputs["body"] == "When does 2.3 ship?"
+        assert inputs["username"] == "Sam"
+        assert inputs["context"] == "Release manager for the payments team"
+        assert inputs["tone"].startswith("Formal")
+        assert inputs["bullet_points"] == "- Ships on the 14th"
+    
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_draft_reply_settings_override_user_files(self, mock_config, mock_processor, ai_service):
+        """Test that configured name and job context win over the user files."""
+        mock_ai_instance = self._processor(mock_config, mock_processor, {"body": "Thanks!"})
+        
+        with patch('backend.services.ai_service.settings') as mock_settings:
+            mock_settings.user_name = "Samira"
+            mock_settings.job_context = "Configured context"
+            result = await ai_service.draft_reply(
+                subject="Lunch?", sender="a@company.com", content="Lunch Friday?"
+            )
+        
+        assert result == {"subject": "Re: Lunch?", "body": "Thanks!"}
+        inputs = mock_ai_instance.execute_prompty.call_args[0][1]
+        assert inputs["username"] == "Samira"
+        assert inputs["context"] == "Configured context"
+    
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_draft_reply_empty_body(self, mock_config, mock_processor, ai_service):
+        """Test that a draft without a body is reported as an error."""
+        self._processor(mock_config, mock_processor, '{"subject": "Re: Hi", "body": ""}')
+        
+        result = await ai_service.draft_reply(subject="Hi", sender="a@company.com", content="Hi")
+        
+        assert "empty body" in result["error"]
@@ -0,0 +1,267 @@
+"""Tests for AI debug traces."""
+
+import asyncio
+import json
+from datetime import datetime, timedelta
+from unittest.mock import MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.ai import ai_rate_limiter, router
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import REQUEST_ID_HEADER, register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.ai_service import AIProcessor, AIService
+from backend.services.ai_traces import (
+    REDACTED, TRACE_HEADER, AITraceStore, TraceScope, get_ai_trace_store, make_trace_hook,
+    register_ai_trace_middleware, scrub_secrets, trace_email
+)
+from backend.services.classification_explainer import EXPLAINER_TEMPLATE
+from backend.services.classification_store import PROMPTS_DIR, prompt_version
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+EXPLANATION = json.dumps({
+    "supports_current": ["Sent to the whole team"],
+    "supports_alternative": ["Asks for an RSVP"],
+    "verdict": "prefer_current"
+})
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store with one classified email."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        conn.execute(
+            """
+            INSERT INTO emails (id, subject, sender, content, received_date, category, ai_reasoning, user_id)
+            VALUES ('email-1', 'Team offsite', 'lead@example.com', 'RSVP by Friday', ?, 'fyi', 'Announcement', ?)
+            """,
+            (datetime.now(), USER_ID)
+        )
+        conn.commit()
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def traces(store):
+    """Trace store over the in-memory store."""
+    return AITraceStore(db=store)
+
+
+def stub_processor(traces, response=EXPLANATION, error=None):
+    """AIProcessor over the real prompts whose model call returns response (or raises error)."""
+    if AIProcessor is None:
+        pytest.skip("AI dependencies not available")
+    processor = AIProcessor.__new__(AIProcessor)
+    processor.prompts_dir = str(PROMPTS_DIR)
+    processor.trace_hooks = []
+
+    def run(prompty_file, inputs, event):
+        if error:
+            raise RuntimeError(error)
+        return response
+
+    processor._execute_prompty = run
+    processor.add_trace_hook(make_trace_hook(processor, traces))
+    return processor
+
+
+def explain(service):
+    """Run a classification explanation through an AI service."""
+    return service.explain_classification(
+        subject="Team offsite", sender="lead@example.com", content="RSVP by Friday",
+        current_category="fyi", current_reasoning=None, alternative_category="optional_event"
+    )
+
+
+class TestScrubSecrets:
+    """Tests for removing secrets from stored text."""
+
+    def test_secret_looking_tokens(self):
+        """Test that keys, bearer tokens, and passwords are redacted."""
+        text = scrub_secrets(
+            "Authorization: Bearer abc.def-ghi\napi_key=12345678abcdef password: hunter22 "
+            "key sk-ABCDEFGHIJKLMNOPQRSTUV"
+        )
+
+        assert "abc.def-ghi" not in text and "hunter22" not in text and "sk-ABC" not in text
+        assert "12345678abcdef" not in text
+        assert text.count(REDACTED) == 4
+        assert "Authorization: Bearer " in text
+
+    def test_configured_secrets(self, monkeypatch):
+        """Test that the values of secret settings are redacted wherever they appear."""
+        monkeypatch.setattr(settings, "azure_openai_api_key", "0f3c9a1b7d")
+
+        assert scrub_secrets("The key is 0f3c9a1b7d.") == f"The key is {REDACTED}."
+
+    def test_ordinary_text_unchanged(self):
+        """Test that text without secrets is stored as is."""
+        assert scrub_secrets("Please send the tokens report by Friday") == "Please send the tokens report by Friday"
+
+
+class TestTraceCapture:
+    """Tests for recording prompt runs through AIProcessor trace hooks."""
+
+    @pytest.fixture
+    def service(self, traces):
+        """AI service running the stub processor."""
+        service = AIService()
+        service.ai_processor = stub_processor(traces)
+        service._initialized = True
+        return service
+
+    def test_off_by_default(self, service, traces):
+        """Test that nothing is recorded unless tracing is turned on."""
+        async def scenario():
+            with trace_email("email-1", USER_ID):
+                await explain(service)
+            return await traces.get_traces("email-1", USER_ID)
+
+        assert asyncio.run(scenario()) == []
+
+    def test_records_traced_email(self, service, traces, monkeypatch):
+        """Test that a traced call is recorded with its template, prompt, response, and timing."""
+        monkeypatch.setattr(settings, "ai_debug_traces", True)
+
+        async def scenario():
+            await explain(service)  # Outside trace_email: not attributed to an email
+            with trace_email("email-1", USER_ID):
+                result = await explain(service)
+            return result, await traces.get_traces("email-1", USER_ID)
+
+        result, recorded = asyncio.run(scenario())
+
+        assert result["verdict"] == "prefer_current"
+        assert len(recorded) == 1
+        trace = recorded[0]
+        assert trace.operation == "classification_explainer"
+        assert trace.prompt_version == prompt_version(EXPLAINER_TEMPLATE)
+        assert trace.raw_response == EXPLANATION
+        assert trace.inputs["content"] == "RSVP by Friday"
+        assert "RSVP by Friday" in trace.rendered_prompt
+        assert trace.error is None
+        assert trace.duration_ms >= 0
+
+    def test_records_failures(self, traces, monkeypatch):
+        """Test that a failing call is recorded with its error, scrubbed."""
+        monkeypatch.setattr(settings, "ai_debug_traces", True)
+        service = AIService()
+        service.ai_processor = stub_processor(traces, error="401 for api_key=supersecret")
+        service._initialized = True
+
+        async def scenario():
+            with trace_email("email-1", USER_ID):
+                result = await explain(service)
+            return result, await traces.get_traces("email-1", USER_ID)
+
+        result, recorded = asyncio.run(scenario())
+
+        assert "error" in result
+        assert recorded[0].error == f"401 for api_key={REDACTED}"
+        assert recorded[0].raw_response is None
+
+    def test_records_effective_parameters(self, service, traces, monkeypatch):
+        """Test that a trace records the model parameters the call ran with."""
+        monkeypatch.setattr(settings, "ai_debug_traces", True)
+        service.ai_processor.parameter_resolver = lambda name: {"temperature": 0.1, "max_tokens": 300}
+
+        async def scenario():
+            with trace_email("email-1", USER_ID):
+                await explain(service)
+            return await traces.get_traces("email-1", USER_ID)
+
+        assert asyncio.run(scenario())[0].parameters == {"temperature": 0.1, "max_tokens": 300}
+
+    def test_other_users_traces_hidden(self, service, traces, monkeypatch):
+        """Test that traces are only returned to the email's owner."""
+        monkeypatch.setattr(settings, "ai_debug_traces", True)
+
+        async def scenario():
+            with trace_email("email-1", USER_ID):
+                await explain(service)
+            return await traces.get_traces("email-1", 2)
+
+        assert asyncio.run(scenario()) == []
+
+    def test_prune(self, traces):
+        """Test that traces older than the retention period are deleted."""
+        scope = TraceScope("email-1", str(USER_ID))
+        traces.record(scope, EXPLAINER_TEMPLATE, {}, None, "{}", 1.0)
+
+        async def scenario():
+            kept = await traces.prune(7)
+            pruned = await traces.prune(7, now=datetime.utcnow() + timedelta(days=8))
+            return kept, pruned
+
+        assert asyncio.run(scenario()) == (0, 1)
+
+
+class TestTracesAPI:
+    """Tests for the X-AI-Debug-Trace header and GET /api/ai/traces/{email_id}."""
+
+    @pytest.fixture
+    def client(self, store, traces):
+        """Client running explanations through the stub processor."""
+        service = AIService()
+        service.ai_processor = stub_processor(traces)
+        service._initialized = True
+        app = FastAPI()
+        register_error_handlers(app)
+        register_ai_trace_middleware(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="debugger", email="debugger@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_ai_service] = lambda: service
+        app.dependency_overrides[get_email_service] = lambda: EmailService(MagicMock(), db=store)
+        app.dependency_overrides[get_ai_trace_store] = lambda: traces
+        ai_rate_limiter.reset()
+        yield TestClient(app)
+        ai_rate_limiter.reset()
+
+    def explain(self, client, headers=None):
+        """Ask why email-1 is not an optional_event."""
+        return client.post(
+            "/api/ai/explain", json={"email_id": "email-1", "target_category": "optional_event"}, headers=headers
+        )
+
+    def test_header_turns_tracing_on(self, client):
+        """Test that only the request with the header is traced."""
+        self.explain(client)
+        traced = self.explain(client, headers={TRACE_HEADER: "1"})
+
+        response = client.get("/api/ai/traces/email-1")
+
+        assert traced.status_code == 200
+        assert response.status_code == 200
+        recorded = response.json()["traces"]
+        assert len(recorded) == 1
+        assert recorded[0]["operation"] == "classification_explainer"
+        assert recorded[0]["request_id"] == traced.headers[REQUEST_ID_HEADER]
+
+    def test_operation_filter(self, client):
+        """Test filtering an email's traces by operation."""
+        self.explain(client, headers={TRACE_HEADER: "1"})
+
+        assert len(client.get("/api/ai/traces/email-1?operation=classification_explainer").json()["traces"]) == 1
+        assert client.get("/api/ai/traces/email-1?operation=email_one_line_summary").status_code == 404
+
+    def test_no_traces_is_404(self, client):
+        """Test that an email without traces returns 404."""
+        self.explain(client)
+
+        response = client.get("/api/ai/traces/email-1")
+
+        assert response.status_code == 404
+        assert response.json()["error"]["code"] == "not_found"
@@ -198,7 +198,7 @@ class TestEmailEndpointsWithCOM:
                 assert data["success"] is True
                 assert "message" in data
                 
-                mock_com_email_provider.mark_as_read.assert_called_once_with("email-1")
+                mock_com_email_provider.mark_as_read.assert_called_once_with("email-1", suppress_read_receipt=False)
     
     def test_move_email_to_folder(self, auth_headers, mock_com_email_provider):
         """Test POST /api/emails/{id}/move - Move to folder."""
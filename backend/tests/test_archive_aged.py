@@ -0,0 +1,198 @@
+"""Tests for archiving aged inbox emails and reverting move batches."""
+
+from datetime import datetime, timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService, run_archive_aged
+
+USER_ID = 1
+NOW = datetime.utcnow().replace(microsecond=0)
+
+# ID -> (category, age in days, folder)
+EMAILS = {
+    "old-fyi": ("fyi", 30, "Inbox"),
+    "old-newsletter": ("newsletter", 15, "Inbox"),
+    "fresh-fyi": ("fyi", 3, "Inbox"),
+    "old-action": ("required_personal_action", 30, "Inbox"),
+    "filed-fyi": ("fyi", 30, "Projects"),
+}
+
+
+def mock_email(email_id, age_days, folder):
+    """A mock mailbox email received age_days before NOW."""
+    return {
+        "id": email_id,
+        "subject": email_id.replace("-", " ").title(),
+        "sender": "news@example.com",
+        "body": "Some text.",
+        "received_time": (NOW - timedelta(days=age_days)).isoformat(),
+        "conversation_id": email_id,
+        "categories": [],
+        "folder": folder,
+        "is_read": True,
+    }
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock mailbox holding EMAILS."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    provider.mock_emails = [mock_email(email_id, age, folder) for email_id, (_, age, folder) in EMAILS.items()]
+    return provider
+
+
+@pytest.fixture
+async def service(provider):
+    """Email service over an isolated store with EMAILS classified."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    for email in provider.mock_emails:
+        category = EMAILS[email["id"]][0]
+        await email_service.save_classification(
+            email, EmailClassification(category=category, confidence=0.9), USER_ID
+        )
+    yield email_service
+    store.close()
+
+
+def mailbox_folder(provider, email_id):
+    """Folder an email is in in the mock mailbox."""
+    return next(email["folder"] for email in provider.mock_emails if email["id"] == email_id)
+
+
+class TestArchiveAged:
+    """Tests for selecting and archiving aged emails through EmailService."""
+
+    @pytest.mark.asyncio
+    async def test_selects_old_inbox_emails_of_categories(self, service):
+        """Test that only inbox emails of the categories past the age are selected, oldest first."""
+        emails = await service.get_aged_emails(USER_ID, ["fyi", "newsletter"], 14, now=NOW)
+
+        assert [email["id"] for email in emails] == ["old-fyi", "old-newsletter"]
+        assert emails[0]["folder"] == "Inbox"
+
+    @pytest.mark.asyncio
+    async def test_dry_run_moves_nothing(self, service, provider):
+        """Test that a dry run lists the candidates without moving or recording them."""
+        outcome = await service.archive_aged_emails(USER_ID, ["fyi", "newsletter"], 14, dry_run=True, now=NOW)
+
+        assert len(outcome.candidates) == 2
+        assert (outcome.archived, outcome.batch_id) == ([], None)
+        assert mailbox_folder(provider, "old-fyi") == "Inbox"
+
+    @pytest.mark.asyncio
+    async def test_archive_and_revert(self, service, provider):
+        """Test that archived emails move with their rows and a revert moves them back."""
+        outcome = await service.archive_aged_emails(USER_ID, ["fyi", "newsletter"], 14, now=NOW)
+
+        assert outcome.archived == ["old-fyi", "old-newsletter"]
+        assert mailbox_folder(provider, "old-fyi") == settings.archive_folder
+        assert (await service.get_stored_email("old-fyi", USER_ID))["folder"] == settings.archive_folder
+        assert await service.get_aged_emails(USER_ID, ["fyi", "newsletter"], 14, now=NOW) == []
+
+        reverted = await service.revert_move_batch(outcome.batch_id, USER_ID)
+
+        assert reverted.restored == ["old-fyi", "old-newsletter"]
+        assert mailbox_folder(provider, "old-newsletter") == "Inbox"
+        assert (await service.get_stored_email("old-newsletter", USER_ID))["folder"] == "Inbox"
+
+    @pytest.mark.asyncio
+    async def test_revert_skips_emails_moved_since(self, service, provider):
+        """Test that emails moved again after the batch are left where they are."""
+        outcome = await service.archive_aged_emails(USER_ID, ["fyi"], 14, now=NOW)
+        with service.db.get_connection() as conn:
+            conn.execute("UPDATE emails SET folder = 'Projects' WHERE id = 'old-fyi'")
+            conn.commit()
+
+        reverted = await service.revert_move_batch(outcome.batch_id, USER_ID)
+
+        assert (reverted.restored, reverted.moved_since) == ([], ["old-fyi"])
+        assert mailbox_folder(provider, "old-fyi") == settings.archive_folder
+
+    @pytest.mark.asyncio
+    async def test_revert_errors(self, service):
+        """Test that unknown, other users', and reverted batches cannot be reverted."""
+        outcome = await service.archive_aged_emails(USER_ID, ["fyi"], 14, now=NOW)
+
+        with pytest.raises(LookupError):
+            await service.revert_move_batch(outcome.batch_id, USER_ID + 1)
+        await service.revert_move_batch(outcome.batch_id, USER_ID)
+        with pytest.raises(ValueError):
+            await service.revert_move_batch(outcome.batch_id, USER_ID)
+
+    @pytest.mark.asyncio
+    async def test_background_job(self, service, provider, monkeypatch):
+        """Test that the archive_aged job archives with the configured categories and age."""
+        monkeypatch.setattr(settings, "archive_aged_categories", ["newsletter"])
+
+        assert await run_archive_aged(service) == 1
+        assert mailbox_folder(provider, "old-newsletter") == settings.archive_folder
+        assert mailbox_folder(provider, "old-fyi") == "Inbox"
+
+
+class TestArchiveAgedAPI:
+    """Tests for POST /emails/archive-aged and POST /emails/move-batches/{id}/revert."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="archiver", email="archiver@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        return TestClient(app)
+
+    def test_dry_run_uses_settings(self, client):
+        """Test that without categories or an age the configured ones are used."""
+        response = client.post("/api/emails/archive-aged", json={"dry_run": True})
+
+        assert response.status_code == 200
+        data = response.json()
+        assert [candidate["id"] for candidate in data["candidates"]] == ["old-fyi", "old-newsletter"]
+        assert (data["success_count"], data["batch_id"]) == (0, None)
+
+    def test_archive_then_revert(self, client, provider):
+        """Test that the returned batch moves the archived emails back once."""
+        data = client.post("/api/emails/archive-aged", json={"categories": ["fyi"], "older_than_days": 7}).json()
+
+        assert (data["success_count"], data["archive_folder"]) == (1, settings.archive_folder)
+        revert = client.post(f"/api/emails/move-batches/{data['batch_id']}/revert")
+        assert (revert.status_code, revert.json()["success_count"]) == (200, 1)
+        assert mailbox_folder(provider, "old-fyi") == "Inbox"
+
+        assert client.post(f"/api/emails/move-batches/{data['batch_id']}/revert").status_code == 409
+        assert client.post("/api/emails/move-batches/999/revert").status_code == 404
+
+    def test_partial_failure(self, client, provider):
+        """Test that emails the mail client cannot move are 502 items in a 207."""
+        provider.mock_emails = [email for email in provider.mock_emails if email["id"] != "old-newsletter"]
+
+        response = client.post("/api/emails/archive-aged", json={})
+
+        assert response.status_code == 207
+        data = response.json()
+        assert (data["success_count"], data["failure_count"]) == (1, 1)
+        assert data["errors"][0]["email_id"] == "old-newsletter"
+        assert data["errors"][0]["status_code"] == 502
+
+    @pytest.mark.parametrize("body", [{"categories": []}, {"categories": ["made_up"]}, {"older_than_days": 0}])
+    def test_invalid_request(self, client, body):
+        """Test that empty or unknown categories and non-positive ages are rejected."""
+        assert client.post("/api/emails/archive-aged", json=body).status_code == 422
@@ -43,7 +43,8 @@ def test_duplicate_user_registration(test_user):
     assert response.status_code == 400
     # Check that error message contains "already registered"
     response_data = response.json()
-    assert "already registered" in response_data.get("message", response_data.get("detail", ""))
+    assert response_data["error"]["code"] == "bad_request"
+    assert "already registered" in response_data["error"]["message"]
 
 
 def test_user_login(test_user):
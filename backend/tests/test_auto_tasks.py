@@ -0,0 +1,239 @@
+"""Tests for automatic task creation after classification."""
+
+from datetime import datetime
+
+import pytest
+from unittest.mock import AsyncMock, MagicMock
+
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager
+from backend.models.ai_models import EMAIL_CATEGORIES
+from backend.models.task import TaskPriority
+from backend.services.auto_tasks import AUTO_TASK_SOURCE, create_auto_tasks, should_create_tasks
+from backend.services.email_service import EmailService
+from backend.services.task_service import TaskService
+from backend.services.thread_classifier import classify_batch
+
+ACTIONABLE = {"required_personal_action", "team_action", "optional_action"}
+DUE = datetime(2024, 1, 5, 23, 59, 59)
+
+
+def make_email(email_id, conversation_id=None, minute=0):
+    """Build a provider-style email dict."""
+    return {
+        "id": email_id,
+        "subject": f"Subject {email_id}",
+        "sender": "alice@example.com",
+        "body": "Please review the design doc and send feedback.",
+        "received_time": f"2024-01-01T10:{minute:02d}:00Z",
+        "conversation_id": conversation_id,
+    }
+
+
+@pytest.fixture
+def stub_ai():
+    """Stub AI client that always finds the same two action items."""
+    ai = MagicMock()
+    ai.extract_action_items = AsyncMock(return_value={
+        "action_items": [
+            {"description": "Review the design doc", "owner": "me", "deadline": DUE,
+             "source_quote": "Please review the design doc", "links": []},
+            {"description": "Send feedback", "owner": "other", "deadline": None,
+             "source_quote": "send feedback", "links": []},
+        ],
+    })
+    ai.classify_email_async = AsyncMock(return_value={
+        "category": "required_personal_action",
+        "confidence": 0.9,
+    })
+    return ai
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def task_service(store):
+    """Task service over the in-memory store."""
+    return TaskService(db=store)
+
+
+class TestPolicyGating:
+    """Tests for which categories qualify under each policy."""
+
+    @pytest.mark.parametrize("category", EMAIL_CATEGORIES)
+    def test_off_never_qualifies(self, category):
+        """Test that the off policy creates no tasks for any category."""
+        assert should_create_tasks("off", category) is False
+
+    @pytest.mark.parametrize("category", EMAIL_CATEGORIES)
+    def test_required_personal_action(self, category):
+        """Test that only required_personal_action qualifies."""
+        expected = category == "required_personal_action"
+
+        assert should_create_tasks("required_personal_action", category) is expected
+
+    @pytest.mark.parametrize("category", EMAIL_CATEGORIES)
+    def test_all_actionable(self, category):
+        """Test that every action category qualifies and nothing else does."""
+        assert should_create_tasks("all_actionable", category) is (category in ACTIONABLE)
+
+    def test_unknown_policy_or_category(self):
+        """Test that unknown values never qualify."""
+        assert should_create_tasks("sometimes", "required_personal_action") is False
+        assert should_create_tasks("all_actionable", None) is False
+
+
+class TestCreateAutoTasks:
+    """Tests for extracting and storing automatic tasks."""
+
+    @pytest.mark.asyncio
+    async def test_creates_marked_tasks(self, stub_ai, task_service):
+        """Test that qualifying emails get tasks marked source=auto."""
+        count = await create_auto_tasks(
+            make_email("e1"), "required_personal_action", stub_ai, task_service, 1,
+            "required_personal_action"
+        )
+
+        assert count == 2
+        content = stub_ai.extract_action_items.call_args.kwargs["email_content"]
+        assert content.startswith("Subject: Subject e1\nFrom: alice@example.com\n")
+
+        result = await task_service.get_tasks_paginated(user_id=1, source=AUTO_TASK_SOURCE)
+        assert result.total_count == 2
+        assert {task.email_id for task in result.tasks} == {"e1"}
+        assert all(task.source == AUTO_TASK_SOURCE for task in result.tasks)
+
+    @pytest.mark.asyncio
+    async def test_owner_sets_priority_and_deadline_sets_due_date(self, stub_ai, task_service):
+        """Test that tasks take their priority from the owner and due date from the deadline."""
+        await create_auto_tasks(
+            make_email("e1"), "required_personal_action", stub_ai, task_service, 1,
+            "required_personal_action"
+        )
+
+        tasks = {task.title: task for task in (await task_service.get_tasks_paginated(user_id=1)).tasks}
+        assert tasks["Review the design doc"].priority == TaskPriority.HIGH
+        assert tasks["Review the design doc"].due_date == DUE
+        assert tasks["Send feedback"].priority == TaskPriority.LOW
+        assert tasks["Send feedback"].due_date is None
+
+    @pytest.mark.asyncio
+    async def test_category_task_defaults(self, stub_ai, task_service, monkeypatch):
+        """Test that the category's task defaults give undated items a due date and set the priority."""
+        monkeypatch.setattr(settings, "task_defaults", {
+            "required_personal_action": {"priority": "urgent", "due_in_business_days": 3}
+        })
+        await create_auto_tasks(
+            make_email("e1"), "required_personal_action", stub_ai, task_service, 1,
+            "required_personal_action"
+        )
+
+        tasks = {task.title: task for task in (await task_service.get_tasks_paginated(user_id=1)).tasks}
+        assert tasks["Review the design doc"].priority == TaskPriority.URGENT
+        assert tasks["Review the design doc"].due_date == DUE
+        assert tasks["Send feedback"].priority == TaskPriority.URGENT
+        assert tasks["Send feedback"].due_date is not None
+        assert tasks["Send feedback"].due_date.weekday() < 5
+
+    @pytest.mark.asyncio
+    async def test_gated_category_skips_extraction(self, stub_ai, task_service):
+        """Test that non-qualifying categories never call the AI."""
+        count = await create_auto_tasks(
+            make_email("e1"), "team_action", stub_ai, task_service, 1, "required_personal_action"
+        )
+
+        assert count == 0
+        stub_ai.extract_action_items.assert_not_called()
+
+    @pytest.mark.asyncio
+    async def test_rerun_does_not_duplicate(self, stub_ai, task_service):
+        """Test that re-processing an email skips tasks it already has."""
+        await task_service.create_tasks_from_action_items("e1", ["review the  design doc"], 1)
+
+        count = await create_auto_tasks(
+            make_email("e1"), "team_action", stub_ai, task_service, 1, "all_actionable"
+        )
+        again = await create_auto_tasks(
+            make_email("e1"), "team_action", stub_ai, task_service, 1, "all_actionable"
+        )
+
+        assert count == 1
+        assert again == 0
+        result = await task_service.get_tasks_paginated(user_id=1)
+        assert sorted(task.title for task in result.tasks) == [
+            "Send feedback", "review the design doc"
+        ]
+
+    @pytest.mark.asyncio
+    async def test_descriptions_list_relevant_links(self, stub_ai, task_service):
+        """Test that tasks carry the email's PR and meeting links but not other links."""
+        email = make_email("e1")
+        email["body"] = (
+            "Review https://github.com/org/repo/pull/7 before "
+            "https://teams.microsoft.com/l/meetup-join/abc (agenda: https://example.com/agenda)."
+        )
+
+        await create_auto_tasks(email, "team_action", stub_ai, task_service, 1, "all_actionable")
+
+        tasks = (await task_service.get_tasks_paginated(user_id=1)).tasks
+        assert tasks[0].description == (
+            "Links:\n"
+            "- [pull_request] https://github.com/org/repo/pull/7\n"
+            "- [meeting] https://teams.microsoft.com/l/meetup-join/abc"
+        )
+
+    @pytest.mark.asyncio
+    async def test_extraction_failure_creates_nothing(self, stub_ai, task_service):
+        """Test that an AI error results in zero tasks instead of an exception."""
+        stub_ai.extract_action_items.return_value = {"action_items": [], "error": "offline"}
+
+        count = await create_auto_tasks(
+            make_email("e1"), "required_personal_action", stub_ai, task_service, 1, "all_actionable"
+        )
+
+        assert count == 0
+        assert (await task_service.get_tasks_paginated(user_id=1)).total_count == 0
+
+
+class TestBatchAutoTasks:
+    """Tests for automatic tasks during batch classification."""
+
+    @pytest.mark.asyncio
+    async def test_batch_records_tasks_created(self, stub_ai, store, task_service):
+        """Test that batch results report tasks per email under the policy."""
+        outcome = await classify_batch(
+            [make_email("e1"), make_email("e2")], stub_ai, EmailService(MagicMock(), db=store), 1,
+            task_service=task_service, auto_create_tasks="required_personal_action"
+        )
+
+        assert [result.tasks_created for result in outcome.results] == [2, 2]
+
+    @pytest.mark.asyncio
+    async def test_batch_off_by_default(self, stub_ai, store, task_service):
+        """Test that batches create no tasks when the policy is off."""
+        outcome = await classify_batch(
+            [make_email("e1")], stub_ai, EmailService(MagicMock(), db=store), 1,
+            task_service=task_service
+        )
+
+        assert outcome.results[0].tasks_created == 0
+        stub_ai.extract_action_items.assert_not_called()
+
+    @pytest.mark.asyncio
+    async def test_thread_mode_extracts_once(self, stub_ai, store, task_service):
+        """Test that inherited thread messages get no tasks of their own."""
+        emails = [make_email("t1", "conv", minute=1), make_email("t2", "conv", minute=2)]
+
+        outcome = await classify_batch(
+            emails, stub_ai, EmailService(MagicMock(), db=store), 1, thread_mode=True,
+            task_service=task_service, auto_create_tasks="all_actionable"
+        )
+
+        assert [result.tasks_created for result in outcome.results] == [0, 2]
+        stub_ai.extract_action_items.assert_called_once()
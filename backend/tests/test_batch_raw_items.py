@@ -0,0 +1,147 @@
+"""Tests for classifying raw email payloads in batch processing."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import AsyncMock, MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import emails as emails_api
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.thread_classifier import raw_email_id
+
+USER_ID = 1
+
+RAW = {"subject": "Lunch?", "sender": "friend@example.com", "content": "Free at noon?"}
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def service(store):
+    """Email service over the mock mailbox and the in-memory store."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return EmailService(provider, db=store)
+
+
+@pytest.fixture
+def stub_ai():
+    """Stub AI client classifying everything as fyi."""
+    ai = MagicMock()
+    ai.classify_email_async = AsyncMock(return_value={
+        "category": "fyi", "confidence": 0.9, "reasoning": "Informational"
+    })
+    return ai
+
+
+@pytest.fixture
+def client(service, stub_ai):
+    """Client with auth, AI, and the email service overridden."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(emails_api.router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="batcher", email="batcher@example.com",
+        hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: service
+    app.dependency_overrides[get_ai_service] = lambda: stub_ai
+    return TestClient(app)
+
+
+def batch(client, emails, **options):
+    """Post a batch and return the response."""
+    return client.post("/api/emails/batch-process", json={
+        "emails": emails, "thread_mode": False, "skip_already_processed": False, **options
+    })
+
+
+class TestRawEmailId:
+    """Tests for the content hash identifying raw items."""
+
+    def test_deterministic(self):
+        """Test that the same content always hashes to the same ID."""
+        email_id = raw_email_id("Lunch?", "friend@example.com", "Free at noon?")
+
+        assert email_id == raw_email_id("Lunch?", "friend@example.com", "Free at noon?")
+        assert email_id.startswith("raw-")
+        assert email_id != raw_email_id("Lunch?", "friend@example.com", "Free at one?")
+
+    def test_fields_do_not_run_together(self):
+        """Test that moving text between fields changes the ID."""
+        assert raw_email_id("ab", "c", "") != raw_email_id("a", "bc", "")
+
+
+class TestMixedBatches:
+    """Tests for batches mixing provider IDs and raw payloads."""
+
+    def test_mixed_batch(self, client, stub_ai, service):
+        """Test that raw items skip the lookup and are reported under their hash."""
+        response = batch(client, [{"email_id": "mock-email-1"}, RAW, {"id": "mock-email-2"}])
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["successful_count"] == 3
+        assert [result["email_id"] for result in data["results"]] == [
+            "mock-email-1", raw_email_id(**RAW), "mock-email-2"
+        ]
+        raw_call = stub_ai.classify_email_async.call_args_list[1].kwargs
+        assert (raw_call["subject"], raw_call["sender"], raw_call["content"]) == (
+            "Lunch?", "friend@example.com", "Free at noon?"
+        )
+
+    def test_raw_items_not_saved_by_default(self, client, service):
+        """Test that raw items are classified without being stored."""
+        batch(client, [{"email_id": "mock-email-1"}, RAW])
+
+        assert asyncio.run(service.get_stored_email("mock-email-1", USER_ID)) is not None
+        assert asyncio.run(service.get_stored_email(raw_email_id(**RAW), USER_ID)) is None
+
+    def test_save_to_database(self, client, service):
+        """Test that save_to_database stores raw items under their hash."""
+        batch(client, [RAW], save_to_database=True)
+
+        stored = asyncio.run(service.get_stored_email(raw_email_id(**RAW), USER_ID))
+        assert stored["subject"] == "Lunch?"
+        assert stored["category"] == "fyi"
+
+    def test_missing_email_reported(self, client):
+        """Test that an unknown provider ID is an error and raw items still classify."""
+        response = batch(client, [{"email_id": "missing"}, RAW])
+
+        assert response.status_code == 207
+        data = response.json()
+        assert data["failed_count"] == 1
+        assert data["errors"] == [
+            {"email_id": "missing", "error": "Email not found", "status_code": 404, "is_retryable": False}
+        ]
+        assert [result["email_id"] for result in data["results"]] == [raw_email_id(**RAW)]
+
+    @pytest.mark.parametrize("item", [
+        {},
+        {"subject": "Lunch?", "sender": "friend@example.com"},
+        {"content": "Free at noon?"},
+        {"email_id": ""},
+        {"invalid": "data"},
+    ])
+    def test_rejects_items_without_either_form(self, client, stub_ai, item):
+        """Test that items with neither an ID nor a full raw payload are rejected."""
+        response = batch(client, [{"email_id": "mock-email-1"}, item])
+
+        assert response.status_code == 422
+        stub_ai.classify_email_async.assert_not_called()
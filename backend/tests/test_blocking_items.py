@@ -0,0 +1,284 @@
+"""Tests for holistic analysis parsing and the blocking-others escalation list."""
+
+import re
+from datetime import date, datetime
+from pathlib import Path
+from unittest.mock import AsyncMock
+
+import pytest
+import yaml
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import ai as ai_api
+from backend.api import emails as emails_api
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.blocking_items import BlockingStore
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.holistic_analysis import HOLISTIC_TEMPLATE, build_holistic_inputs, parse_holistic_analysis
+
+USER_ID = 1
+NOW = datetime(2024, 6, 10, 12, 0, 0)
+PROMPT_PATH = Path(__file__).parent.parent.parent / "prompts" / HOLISTIC_TEMPLATE
+
+# ID -> (subject, received)
+EMAILS = {
+    "budget": ("Budget sign-off needed", datetime(2024, 6, 1, 9, 0)),
+    "review": ("Please review the design doc", datetime(2024, 6, 5, 9, 0)),
+    "access": ("Grant repo access", datetime(2024, 6, 8, 9, 0)),
+    "newsletter": ("Weekly digest", datetime(2024, 6, 9, 9, 0)),
+}
+
+
+def action(email_id, blocking=True, **values):
+    """A parsed truly_relevant_actions entry for an email."""
+    return {
+        "action_type": "required_personal_action", "priority": "high", "topic": EMAILS[email_id][0],
+        "canonical_email_id": email_id, "related_email_ids": [], "deadline": None,
+        "why_relevant": "Others are waiting", "blocking_others": blocking, **values
+    }
+
+
+@pytest.fixture
+def db():
+    """Isolated store holding EMAILS for USER_ID."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with store.get_connection() as conn:
+        conn.executemany(
+            "INSERT INTO emails (id, subject, sender, content, category, received_date, user_id) "
+            "VALUES (?, ?, 'dana@example.com', 'Body', 'required_personal_action', ?, ?)",
+            [(email_id, subject, received, USER_ID) for email_id, (subject, received) in EMAILS.items()]
+        )
+        conn.commit()
+    yield store
+    store.close()
+
+
+@pytest.fixture
+def store(db):
+    """Blocking store over the isolated store."""
+    return BlockingStore(db)
+
+
+def add_task(db, email_id, status):
+    """Store a task made from an email."""
+    with db.get_connection() as conn:
+        conn.execute(
+            "INSERT INTO tasks (title, status, email_id, user_id) VALUES (?, ?, ?, ?)",
+            (f"Task for {email_id}", status, email_id, USER_ID)
+        )
+        conn.commit()
+
+
+class TestHolisticPrompt:
+    """Tests for the holistic_inbox_analyzer template inputs and output parsing."""
+
+    def test_inputs_match_template(self):
+        """Test that the built inputs are the declared ones and cover every placeholder."""
+        _, frontmatter, body = PROMPT_PATH.read_text(encoding="utf-8").split("---", 2)
+        declared = set(yaml.safe_load(frontmatter)["inputs"])
+        placeholders = set(re.findall(r"\{\{\s*(\w+)\s*\}\}", body))
+
+        inputs = build_holistic_inputs(
+            [{"id": "budget", "subject": "Budget", "content": "x" * 400}], "Sam", "Engineer", date(2024, 6, 10),
+            ["EMAIL_ID budget (Budget): not actually blocking anyone"]
+        )
+
+        assert placeholders <= declared == set(inputs)
+        assert "EMAIL_ID: budget" in inputs["inbox_summary"]
+        assert inputs["inbox_summary"].count("x") == 300
+        assert inputs["corrections"] == "- EMAIL_ID budget (Budget): not actually blocking anyone"
+        assert build_holistic_inputs([], "Sam", "Engineer", date(2024, 6, 10))["corrections"] == "None"
+
+    def test_actions_kept_for_analyzed_emails(self):
+        """Test that only actions on analyzed emails are kept and odd values are normalized."""
+        raw = """```json
+        {"truly_relevant_actions": [
+            {"canonical_email_id": "budget", "topic": "Budget", "action_type": "urgent", "priority": "asap",
+             "related_email_ids": ["review", "unknown", "budget"], "blocking_others": true},
+            {"canonical_email_id": "invented", "topic": "Not analyzed", "blocking_others": true},
+            {"canonical_email_id": "review", "topic": "Review", "blocking_others": "yes"}
+        ], "expired_items": ["not a dict"]}
+        ```"""
+
+        analysis = parse_holistic_analysis(raw, ["budget", "review"])
+        actions = analysis["truly_relevant_actions"]
+
+        assert [entry["canonical_email_id"] for entry in actions] == ["budget", "review"]
+        assert (actions[0]["action_type"], actions[0]["priority"]) == ("optional_action", "medium")
+        assert actions[0]["related_email_ids"] == ["review"]
+        assert [entry["blocking_others"] for entry in actions] == [True, False]
+        assert (analysis["expired_items"], analysis["duplicate_groups"]) == ([], [])
+
+    @pytest.mark.parametrize("raw", ["Here is my analysis.", "[1, 2]", 42])
+    def test_garbage_is_rejected(self, raw):
+        """Test that output that is not a JSON object raises."""
+        with pytest.raises(ValueError):
+            parse_holistic_analysis(raw, ["budget"])
+
+
+class TestBlockingStore:
+    """Tests for recording, listing, and dismissing blocking items."""
+
+    def test_list_joins_emails_and_tasks_oldest_first(self, db, store):
+        """Test that open items carry their email and latest task and are ordered by email age."""
+        add_task(db, "review", "completed")
+        add_task(db, "review", "in_progress")
+
+        count = store.record_analysis(
+            USER_ID, [action("access"), action("budget"), action("review"), action("newsletter", blocking=False)],
+            list(EMAILS), NOW
+        )
+        items = store.list_open(USER_ID, NOW)
+
+        assert count == 3
+        assert [item["email_id"] for item in items] == ["budget", "review", "access"]
+        budget, review = items[0], items[1]
+        assert (budget["subject"], budget["sender"], budget["folder"]) == (
+            "Budget sign-off needed", "dana@example.com", "Inbox"
+        )
+        assert (budget["task_id"], budget["age_days"]) == (None, 9)
+        assert review["task_status"] == "in_progress"
+
+    def test_items_with_closed_tasks_or_gone_emails_are_left_out(self, db, store):
+        """Test that a completed latest task or a deleted email takes an item off the list."""
+        store.record_analysis(USER_ID, [action("budget"), action("review")], list(EMAILS), NOW)
+        add_task(db, "budget", "completed")
+        with db.get_connection() as conn:
+            conn.execute("DELETE FROM emails WHERE id = 'review'")
+            conn.commit()
+
+        assert store.list_open(USER_ID, NOW) == []
+        assert store.open_count(USER_ID) == 0
+
+    def test_rerun_resolves_only_analyzed_emails(self, store):
+        """Test that a later run resolves items it analyzed without flagging and keeps the rest."""
+        store.record_analysis(USER_ID, [action("budget"), action("review")], list(EMAILS), NOW)
+
+        store.record_analysis(USER_ID, [action("budget", topic="Budget, again")], ["budget", "access"], NOW)
+        assert [item["email_id"] for item in store.list_open(USER_ID, NOW)] == ["budget", "review"]
+        assert store.list_open(USER_ID, NOW)[0]["topic"] == "Budget, again"
+
+        store.record_analysis(USER_ID, [], ["review"], NOW)
+        assert [item["email_id"] for item in store.list_open(USER_ID, NOW)] == ["budget"]
+
+        store.record_analysis(USER_ID, [action("review")], ["review"], NOW)
+        assert [item["email_id"] for item in store.list_open(USER_ID, NOW)] == ["budget", "review"]
+
+    def test_dismissed_items_stay_dismissed_and_become_corrections(self, store):
+        """Test that dismissal survives later runs and is reported back as a correction."""
+        store.record_analysis(USER_ID, [action("budget"), action("review")], list(EMAILS), NOW)
+        budget = store.list_open(USER_ID, NOW)[0]
+
+        assert store.dismiss(budget["id"], USER_ID, "completed_elsewhere", "Signed on paper", NOW)
+        assert not store.dismiss(budget["id"], USER_ID, "not_blocking")
+        assert not store.dismiss(budget["id"] + 100, USER_ID, "not_blocking")
+        store.record_analysis(USER_ID, [action("budget"), action("review")], list(EMAILS), NOW)
+
+        assert [item["email_id"] for item in store.list_open(USER_ID, NOW)] == ["review"]
+        assert store.corrections(USER_ID) == [
+            "EMAIL_ID budget (Budget sign-off needed): already completed outside the inbox - Signed on paper"
+        ]
+
+    def test_other_users_items(self, store):
+        """Test that users neither see nor dismiss each other's items."""
+        store.record_analysis(USER_ID, [action("budget")], list(EMAILS), NOW)
+        item = store.list_open(USER_ID, NOW)[0]
+
+        assert store.list_open(USER_ID + 1, NOW) == []
+        assert not store.dismiss(item["id"], USER_ID + 1, "not_blocking")
+
+    def test_unknown_reason_is_rejected(self, store):
+        """Test that only DISMISS_REASONS are accepted."""
+        with pytest.raises(ValueError):
+            store.dismiss(1, USER_ID, "bored")
+
+
+class TestBlockingService:
+    """Tests for the EmailService blocking item methods."""
+
+    @pytest.mark.asyncio
+    async def test_dismiss_and_count(self, db):
+        """Test that counts follow dismissals and unknown items raise LookupError."""
+        service = EmailService(MockEmailProvider(), db=db)
+        assert await service.record_blocking_items(USER_ID, [action("budget"), action("access")], list(EMAILS)) == 2
+        items = await service.get_blocking_items(USER_ID, now=NOW)
+
+        await service.dismiss_blocking_item(items[0]["id"], USER_ID, "not_blocking")
+
+        assert await service.count_blocking_items(USER_ID) == 1
+        assert await service.get_blocking_corrections(USER_ID) == [
+            "EMAIL_ID budget (Budget sign-off needed): not actually blocking anyone"
+        ]
+        with pytest.raises(LookupError):
+            await service.dismiss_blocking_item(items[0]["id"], USER_ID, "not_blocking")
+
+
+class TestBlockingAPI:
+    """Tests for POST /api/ai/holistic-analysis and the /emails/blocking endpoints."""
+
+    @pytest.fixture
+    def ai(self):
+        """AI service stub flagging budget and access as blocking others."""
+        ai = AsyncMock()
+        ai.analyze_inbox.return_value = {
+            "truly_relevant_actions": [action("budget"), action("access"), action("review", blocking=False)],
+            "superseded_actions": [], "duplicate_groups": [], "expired_items": [],
+        }
+        return ai
+
+    @pytest.fixture
+    def client(self, db, ai):
+        """Client for the AI and email endpoints with auth, AI, and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(ai_api.router, prefix="/api")
+        app.include_router(emails_api.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="sam", email="sam@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: EmailService(MockEmailProvider(), db=db)
+        app.dependency_overrides[get_ai_service] = lambda: ai
+        ai_api.ai_rate_limiter.reset()
+        yield TestClient(app)
+        ai_api.ai_rate_limiter.reset()
+
+    def test_analysis_lists_and_dismisses(self, client, ai):
+        """Test that an analysis fills the list, dismissal empties it, and the next run is told."""
+        data = client.post("/api/ai/holistic-analysis", json={}).json()
+        assert (data["analyzed"], data["blocking_count"]) == (4, 2)
+        assert [entry["blocking_others"] for entry in data["truly_relevant_actions"]] == [True, True, False]
+
+        listed = client.get("/api/emails/blocking").json()
+        assert [item["email_id"] for item in listed["items"]] == ["budget", "access"]
+        assert listed["items"][0]["subject"] == "Budget sign-off needed"
+
+        item_id = listed["items"][0]["id"]
+        response = client.post(f"/api/emails/blocking/{item_id}/dismiss", json={"reason": "completed_elsewhere"})
+        assert response.status_code == 200
+        assert client.get("/api/emails/blocking").json()["total"] == 1
+
+        data = client.post("/api/ai/holistic-analysis", json={}).json()
+        assert data["blocking_count"] == 1
+        corrections = ai.analyze_inbox.call_args.args[2]
+        assert corrections == ["EMAIL_ID budget (Budget sign-off needed): already completed outside the inbox"]
+
+    def test_dismiss_errors(self, client):
+        """Test that unknown items are 404 and unknown reasons 422."""
+        client.post("/api/ai/holistic-analysis", json={})
+        item_id = client.get("/api/emails/blocking").json()["items"][0]["id"]
+
+        assert client.post("/api/emails/blocking/999/dismiss", json={"reason": "not_blocking"}).status_code == 404
+        assert client.post(f"/api/emails/blocking/{item_id}/dismiss", json={"reason": "bored"}).status_code == 422
+
+    def test_ai_failure_is_upstream_error(self, client, ai):
+        """Test that a failed analysis records nothing."""
+        ai.analyze_inbox.return_value = {"error": "Holistic analyzer returned invalid JSON"}
+
+        assert client.post("/api/ai/holistic-analysis", json={}).status_code == 502
+        assert client.get("/api/emails/blocking").json()["total"] == 0
@@ -0,0 +1,271 @@
+"""Tests for learning and stripping per-sender boilerplate."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import admin as admin_api
+from backend.api.auth import get_current_user
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.boilerplate import (
+    MIN_REPEATS, BoilerplateStore, detect_boilerplate, get_boilerplate_store, strip_boilerplate
+)
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+DANA = "dana@contoso.com"
+
+SIGNATURE = [
+    "Dana Whitfield",
+    "Program Manager | Contoso Ltd.",
+    "+1 555 0100 | dana@contoso.com",
+]
+DISCLAIMER = [
+    "CONFIDENTIALITY NOTICE: This email and any attachments are for the sole use of the intended recipient.",
+]
+MESSAGES = [
+    "Hi team,\nThe reorg review moved to Thursday.\nPlease update your slides.",
+    "Quick question: did the budget numbers come back yet?",
+    "Thanks for the notes.\nI'll merge them into the plan tonight.",
+    "Can you send me the Q3 forecast?",
+]
+
+
+def signed(message: str, trailer=SIGNATURE + DISCLAIMER) -> str:
+    """A message followed by a blank line and the trailing block."""
+    return message + "\n\n" + "\n".join(trailer)
+
+
+class TestDetection:
+    """Tests for detect_boilerplate on synthetic corpora."""
+
+    def test_repeated_signature_detected(self):
+        """Test that the signature and disclaimer every email ends with are one block."""
+        blocks = detect_boilerplate(signed(message) for message in MESSAGES)
+
+        assert [block.lines for block in blocks] == [SIGNATURE + DISCLAIMER]
+        assert blocks[0].email_count == len(MESSAGES)
+
+    def test_needs_min_repeats(self):
+        """Test that a block in fewer than MIN_REPEATS emails is not learned."""
+        assert detect_boilerplate(signed(message) for message in MESSAGES[:MIN_REPEATS - 1]) == []
+
+    def test_real_content_not_learned(self):
+        """Test that differing last lines above the signature stay out of the block."""
+        blocks = detect_boilerplate(signed(message, SIGNATURE) for message in MESSAGES)
+
+        assert [block.lines for block in blocks] == [SIGNATURE]
+
+    def test_shared_closing_in_too_few_emails(self):
+        """Test that a closing line two emails share is not folded into the block."""
+        bodies = [signed(message + "\nCheers,", SIGNATURE) for message in MESSAGES[:2]]
+        bodies += [signed(message, SIGNATURE) for message in MESSAGES[2:]]
+
+        assert [block.lines for block in detect_boilerplate(bodies)] == [SIGNATURE]
+
+    def test_identical_emails_count_once(self):
+        """Test that the same email stored repeatedly teaches nothing."""
+        assert detect_boilerplate([signed(MESSAGES[0])] * 5) == []
+
+    def test_quoted_history_ignored(self):
+        """Test that a signature above a quoted reply still counts."""
+        bodies = [
+            signed(message) + f"\n\nOn Mon, Jun 3, 2024 Sam wrote:\n> earlier message {index}"
+            for index, message in enumerate(MESSAGES)
+        ]
+
+        assert [block.lines for block in detect_boilerplate(bodies)] == [SIGNATURE + DISCLAIMER]
+
+    def test_whitespace_and_case_insensitive(self):
+        """Test that blocks differing only in spacing and case count as the same."""
+        bodies = [signed(message) for message in MESSAGES[:2]]
+        bodies.append(signed(MESSAGES[2], [line.upper().replace(" ", "  ") for line in SIGNATURE + DISCLAIMER]))
+
+        assert len(detect_boilerplate(bodies)) == 1
+
+    def test_short_trailers_ignored(self):
+        """Test that a lone short sign-off is not boilerplate."""
+        assert detect_boilerplate(signed(message, ["Thanks"]) for message in MESSAGES) == []
+
+    def test_html_bodies(self):
+        """Test that HTML bodies are compared by their text."""
+        bodies = [
+            f"<p>{message}</p><div>{'<br>'.join(SIGNATURE)}</div>" for message in MESSAGES
+        ]
+
+        assert [block.lines for block in detect_boilerplate(bodies)] == [SIGNATURE]
+
+
+class TestStripping:
+    """Tests for strip_boilerplate."""
+
+    def test_block_stripped(self):
+        """Test that the block goes and the sender's text stays."""
+        assert strip_boilerplate(signed(MESSAGES[0]), [SIGNATURE + DISCLAIMER]) == MESSAGES[0]
+
+    def test_quoted_history_kept(self):
+        """Test that text quoted below the signature is kept."""
+        body = signed(MESSAGES[1]) + "\n\nOn Mon, Jun 3, 2024 Sam wrote:\n> Budget?"
+
+        assert strip_boilerplate(body, [SIGNATURE + DISCLAIMER]) == (
+            MESSAGES[1] + "\n\nOn Mon, Jun 3, 2024 Sam wrote:\n> Budget?"
+        )
+
+    def test_unmatched_body_unchanged(self):
+        """Test that a body not ending with a known block is returned as it is."""
+        body = "<p>New signature</p>"
+
+        assert strip_boilerplate(body, [SIGNATURE]) == body
+
+    def test_block_in_the_middle_kept(self):
+        """Test that a block followed by more of the sender's text is not stripped."""
+        body = "\n".join(["Forwarding Dana's details:", *SIGNATURE, "Call her tomorrow."])
+
+        assert strip_boilerplate(body, [SIGNATURE]) == body
+
+    def test_email_that_is_only_the_block_kept(self):
+        """Test that stripping never leaves an email empty."""
+        body = "\n".join(SIGNATURE)
+
+        assert strip_boilerplate(body, [SIGNATURE]) == body
+
+
+@pytest.fixture
+def store():
+    """In-memory store with Dana's signed emails and one unsigned email from Lee."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    service = EmailService(MockEmailProvider(), db=db)
+    for index, message in enumerate(MESSAGES):
+        asyncio.run(service.store_email({
+            "id": f"dana-{index}", "subject": f"Update {index}", "sender": DANA if index else DANA.upper(),
+            "body": signed(message, SIGNATURE), "received_time": datetime(2024, 6, index + 1),
+        }, USER_ID))
+    asyncio.run(service.store_email({
+        "id": "lee-0", "subject": "Hello", "sender": "lee@example.com", "body": signed(MESSAGES[0], SIGNATURE),
+    }, USER_ID))
+    yield db
+    db.close()
+
+
+def preview(db, email_id):
+    with db.get_connection() as conn:
+        return conn.execute("SELECT preview FROM emails WHERE id = ?", (email_id,)).fetchone()[0]
+
+
+class TestStore:
+    """Tests for BoilerplateStore over stored emails."""
+
+    def test_learn_all(self, store):
+        """Test that senders with enough emails are learned, case-insensitively."""
+        boilerplate = BoilerplateStore(store)
+
+        assert asyncio.run(boilerplate.learn_all()) == 1
+        [block] = asyncio.run(boilerplate.list_boilerplate(USER_ID))
+        assert (block.sender, block.block, block.email_count) == (DANA, "\n".join(SIGNATURE), len(MESSAGES))
+
+    def test_learning_refreshes_previews(self, store):
+        """Test that previews of the sender's stored emails lose the signature."""
+        assert "Program Manager" in preview(store, "dana-1")
+
+        asyncio.run(BoilerplateStore(store).learn_sender(USER_ID, DANA))
+
+        assert preview(store, "dana-1") == MESSAGES[1]
+        assert "Program Manager" in preview(store, "lee-0")
+
+    def test_new_emails_previewed_without_block(self, store):
+        """Test that emails stored after learning get previews without it."""
+        asyncio.run(BoilerplateStore(store).learn_sender(USER_ID, DANA))
+
+        asyncio.run(EmailService(MockEmailProvider(), db=store).store_email(
+            {"id": "dana-new", "subject": "New", "sender": DANA, "body": signed("See you at 3pm.", SIGNATURE)},
+            USER_ID
+        ))
+
+        assert preview(store, "dana-new") == "See you at 3pm."
+
+    def test_strip_emails(self, store):
+        """Test that copies for the AI are stripped and the stored content is not."""
+        boilerplate = BoilerplateStore(store)
+        asyncio.run(boilerplate.learn_sender(USER_ID, DANA))
+        emails = [
+            {"id": "a", "sender": DANA, "content": signed(MESSAGES[2], SIGNATURE)},
+            {"id": "b", "sender": "lee@example.com", "body": signed(MESSAGES[2], SIGNATURE)},
+        ]
+
+        stripped = asyncio.run(boilerplate.strip_emails(USER_ID, emails))
+
+        assert stripped[0]["content"] == MESSAGES[2]
+        assert stripped[1] == emails[1]
+        assert emails[0]["content"] == signed(MESSAGES[2], SIGNATURE)
+
+    def test_dismissed_blocks_not_relearned(self, store):
+        """Test that a dismissed block stays dismissed and previews get it back."""
+        boilerplate = BoilerplateStore(store)
+        asyncio.run(boilerplate.learn_sender(USER_ID, DANA))
+        [block] = asyncio.run(boilerplate.list_boilerplate(USER_ID))
+
+        assert asyncio.run(boilerplate.dismiss(USER_ID, block_id=block.id)) == 1
+        asyncio.run(boilerplate.learn_all())
+
+        assert asyncio.run(boilerplate.list_boilerplate(USER_ID)) == []
+        assert "Program Manager" in preview(store, "dana-1")
+
+    def test_other_users_unaffected(self, store):
+        """Test that one user's learned blocks are not another's."""
+        boilerplate = BoilerplateStore(store)
+        asyncio.run(boilerplate.learn_sender(USER_ID, DANA))
+
+        assert asyncio.run(boilerplate.list_boilerplate(USER_ID + 1)) == []
+        assert asyncio.run(boilerplate.dismiss(USER_ID + 1, sender=DANA)) == 0
+
+
+@pytest.fixture
+def client(store):
+    """Client for the admin endpoints over the store."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(admin_api.router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="sam", email="sam@example.com", hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_boilerplate_store] = lambda: BoilerplateStore(store)
+    return TestClient(app)
+
+
+class TestAdminAPI:
+    """Tests for the /api/admin/boilerplate endpoints."""
+
+    def test_learn_and_list(self, client):
+        """Test that learning returns the blocks and listing filters by sender."""
+        learned = client.post("/api/admin/boilerplate/learn").json()
+
+        assert learned["total"] == 1 and learned["blocks"][0]["sender"] == DANA
+        assert client.get("/api/admin/boilerplate", params={"sender": "DANA@contoso.com"}).json()["total"] == 1
+        assert client.get("/api/admin/boilerplate", params={"sender": "lee@example.com"}).json()["total"] == 0
+
+    def test_learn_one_sender(self, client):
+        """Test that a sender can be learned on its own."""
+        assert client.post("/api/admin/boilerplate/learn", params={"sender": DANA}).json()["total"] == 1
+
+    def test_delete_block(self, client):
+        """Test that deleting a block removes it from the list."""
+        [block] = client.post("/api/admin/boilerplate/learn").json()["blocks"]
+
+        assert client.delete(f"/api/admin/boilerplate/{block['id']}").status_code == 200
+        assert client.get("/api/admin/boilerplate").json()["total"] == 0
+        assert client.delete(f"/api/admin/boilerplate/{block['id']}").status_code == 404
+
+    def test_delete_sender(self, client):
+        """Test that a sender's blocks are deleted together."""
+        client.post("/api/admin/boilerplate/learn")
+
+        response = client.delete("/api/admin/boilerplate", params={"sender": DANA})
+
+        assert response.json()["dismissed"] == 1
+        assert client.delete("/api/admin/boilerplate", params={"sender": DANA}).status_code == 404
@@ -0,0 +1,67 @@
+"""Tests for business-day arithmetic."""
+
+from datetime import date, datetime
+
+import pytest
+
+from backend.core.business_days import add_business_days, is_business_day, parse_holidays
+
+MONDAY = date(2024, 1, 8)
+FRIDAY = date(2024, 1, 12)
+
+
+class TestAddBusinessDays:
+    """Tests for add_business_days."""
+
+    @pytest.mark.parametrize("start,days,expected", [
+        (MONDAY, 1, date(2024, 1, 9)),
+        (MONDAY, 4, FRIDAY),
+        (FRIDAY, 1, date(2024, 1, 15)),
+        (FRIDAY, 6, date(2024, 1, 22)),
+        (date(2024, 1, 13), 1, date(2024, 1, 15)),  # Saturday
+    ])
+    def test_weekends_skipped(self, start, days, expected):
+        """Test that Saturdays and Sundays are not counted."""
+        assert add_business_days(start, days) == expected
+
+    def test_holidays_skipped(self):
+        """Test that holidays are skipped like weekends."""
+        holidays = {date(2024, 1, 15), date(2024, 1, 16)}
+
+        assert add_business_days(FRIDAY, 1, holidays) == date(2024, 1, 17)
+
+    def test_zero_days(self):
+        """Test that zero days keeps a business day and moves a weekend or holiday forward."""
+        assert add_business_days(MONDAY, 0) == MONDAY
+        assert add_business_days(date(2024, 1, 14), 0) == date(2024, 1, 15)
+        assert add_business_days(MONDAY, 0, {MONDAY}) == date(2024, 1, 9)
+
+    def test_datetime_start(self):
+        """Test that a datetime counts from its date."""
+        assert add_business_days(datetime(2024, 1, 12, 17, 45), 1) == date(2024, 1, 15)
+
+    def test_negative_days_rejected(self):
+        """Test that counting backwards is refused."""
+        with pytest.raises(ValueError):
+            add_business_days(MONDAY, -1)
+
+
+class TestHolidays:
+    """Tests for holiday parsing and business-day checks."""
+
+    def test_parse_holidays(self):
+        """Test that YYYY-MM-DD strings and dates are accepted."""
+        assert parse_holidays(["2024-12-25", " 2024-12-26 ", date(2025, 1, 1)]) == {
+            date(2024, 12, 25), date(2024, 12, 26), date(2025, 1, 1)
+        }
+
+    def test_invalid_holiday(self):
+        """Test that other date formats are rejected."""
+        with pytest.raises(ValueError):
+            parse_holidays(["12/25/2024"])
+
+    def test_is_business_day(self):
+        """Test weekdays, weekends, and holidays."""
+        assert is_business_day(MONDAY)
+        assert not is_business_day(date(2024, 1, 13))
+        assert not is_business_day(MONDAY, [MONDAY])
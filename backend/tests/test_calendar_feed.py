@@ -0,0 +1,289 @@
+"""Tests for the iCalendar task feed."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.feeds import router
+from backend.core.errors import register_error_handlers
+from backend.core.ics import fold_line
+from backend.database.connection import DatabaseManager
+from backend.models.task import TaskCreate, TaskPriority, TaskStatus, TaskUpdate
+from backend.models.user import UserInDB
+from backend.services.auto_tasks import AUTO_TASK_SOURCE
+from backend.services.calendar_feed import CalendarFeedService, get_calendar_feed_service
+from backend.services.holistic_runs import HolisticRunStore
+from backend.services.task_service import TaskService
+from backend.tests.utils import parse_ics
+
+USER_ID = 1
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield store
+    store.close()
+
+
+@pytest.fixture
+def feeds(store):
+    """Calendar feed service over the store."""
+    return CalendarFeedService(db=store)
+
+
+@pytest.fixture
+def tasks(store):
+    """Task service over the store."""
+    return TaskService(db=store)
+
+
+def seed(tasks):
+    """Create tasks with and without due dates, open and closed, for two users."""
+    async def setup():
+        created = {}
+        for key, task, user_id in (
+            ("review", TaskCreate(title="Review PR, then merge", description="Line one\nLine two",
+                                  priority=TaskPriority.HIGH, due_date=datetime(2026, 10, 20, 15, 30)), USER_ID),
+            ("report", TaskCreate(title="Send report", due_date=datetime(2026, 10, 18, 23, 59, 59),
+                                  source=AUTO_TASK_SOURCE), USER_ID),
+            ("someday", TaskCreate(title="No due date"), USER_ID),
+            ("done", TaskCreate(title="Done already", status=TaskStatus.COMPLETED,
+                                due_date=datetime(2026, 10, 15, 9, 0)), USER_ID),
+            ("other", TaskCreate(title="Someone else's", due_date=datetime(2026, 10, 16, 9, 0)), USER_ID + 1),
+        ):
+            created[key] = await tasks.create_task(task, user_id)
+        return created
+
+    return asyncio.run(setup())
+
+
+def events_by_summary(feed_text):
+    """Parse a feed and key its events by summary."""
+    return {event["SUMMARY"]: event for event in parse_ics(feed_text)["events"]}
+
+
+class TestFeedRendering:
+    """Tests for the rendered iCalendar text."""
+
+    def test_open_tasks_with_due_dates(self, feeds, tasks):
+        """Test that only the user's open, dated tasks are listed, soonest first."""
+        created = seed(tasks)
+
+        parsed = parse_ics(asyncio.run(feeds.render_feed(USER_ID)))
+
+        assert parsed["calendar"]["VERSION"] == "2.0"
+        assert [event["UID"] for event in parsed["events"]] == [
+            f"task-{created['report'].id}@email-helper", f"task-{created['review'].id}@email-helper"
+        ]
+
+    def test_event_properties(self, feeds, tasks):
+        """Test timed and all-day events, escaping, priority, and detected deadlines."""
+        seed(tasks)
+
+        events = events_by_summary(asyncio.run(feeds.render_feed(USER_ID)))
+        review = events["Review PR\\, then merge"]
+        report = events["Send report"]
+
+        assert review["DTSTART"] == "20261020T153000"
+        assert review["DESCRIPTION"] == "Line one\\nLine two"
+        assert review["PRIORITY"] == "3"
+        assert "CATEGORIES" not in review
+        assert report["DTSTART;VALUE=DATE"] == "20261018"
+        assert report["DTEND;VALUE=DATE"] == "20261019"
+        assert report["CATEGORIES"] == "Detected deadline"
+        assert review["DTSTAMP"].endswith("Z")
+
+    def test_deterministic(self, feeds, tasks):
+        """Test that rendering the same rows twice gives identical text."""
+        seed(tasks)
+
+        assert asyncio.run(feeds.render_feed(USER_ID)) == asyncio.run(feeds.render_feed(USER_ID))
+
+    def test_sequence_follows_due_date_changes(self, feeds, tasks):
+        """Test that SEQUENCE only increases when the due date actually changes."""
+        created = seed(tasks)
+        task_id = created["review"].id
+
+        def sequence():
+            return events_by_summary(asyncio.run(feeds.render_feed(USER_ID)))["Review PR\\, then merge"]["SEQUENCE"]
+
+        before = sequence()
+        asyncio.run(tasks.update_task(task_id, TaskUpdate(title="Review PR, then merge", priority=TaskPriority.LOW), USER_ID))
+        unchanged = sequence()
+        asyncio.run(tasks.update_task(task_id, TaskUpdate(due_date=datetime(2026, 10, 21, 10, 0)), USER_ID))
+        moved = sequence()
+        asyncio.run(tasks.update_task(task_id, TaskUpdate(due_date=datetime(2026, 10, 21, 10, 0)), USER_ID))
+
+        assert (before, unchanged, moved, sequence()) == ("0", "0", "1", "1")
+
+    def test_long_lines_are_folded(self):
+        """Test that folding keeps lines within 75 octets without splitting characters."""
+        line = "SUMMARY:" + "é" * 100
+
+        folded = fold_line(line)
+
+        assert all(len(part.encode("utf-8")) <= 75 for part in folded.split("\r\n"))
+        assert folded.replace("\r\n ", "") == line
+
+
+def holistic_run(store, deadlines, email_ids=("plan", "digest")):
+    """Record a holistic run with one action per email_id -> deadline."""
+    actions = [
+        {"action_type": "required_personal_action", "priority": "urgent", "topic": f"Sign off {email_id}",
+         "canonical_email_id": email_id, "related_email_ids": [], "deadline": deadline}
+        for email_id, deadline in deadlines.items()
+    ]
+    return HolisticRunStore(db=store).record(USER_ID, {"truly_relevant_actions": actions}, list(email_ids))
+
+
+class TestHolisticDeadlines:
+    """Tests for deadlines found by holistic analysis in the feed."""
+
+    @pytest.fixture(autouse=True)
+    def emails(self, store):
+        """Store the analyzed emails, plus one of another user."""
+        with store.get_connection() as conn:
+            conn.executemany(
+                "INSERT INTO emails (id, subject, sender, user_id) VALUES (?, ?, ?, ?)",
+                [("plan", "Launch plan", "pat@example.com", USER_ID),
+                 ("digest", "Weekly digest", "news@example.com", USER_ID),
+                 ("theirs", "Their plan", "pat@example.com", USER_ID + 1)]
+            )
+            conn.commit()
+
+    def test_iso_deadline_is_listed(self, store, feeds):
+        """Test that a dated action is an all-day detected deadline and text deadlines are left out."""
+        holistic_run(store, {"plan": "2026-10-22", "digest": "EOW"})
+
+        events = events_by_summary(asyncio.run(feeds.render_feed(USER_ID)))
+
+        assert list(events) == ["Sign off plan"]
+        event = events["Sign off plan"]
+        assert event["DTSTART;VALUE=DATE"] == "20261022"
+        assert event["CATEGORIES"] == "Detected deadline"
+        assert event["PRIORITY"] == "1"
+        assert event["UID"].startswith("deadline-")
+
+    def test_later_runs_update_in_place(self, store, feeds):
+        """Test that SEQUENCE follows date changes under one UID and a run without a date removes it."""
+        def plan_event():
+            return events_by_summary(asyncio.run(feeds.render_feed(USER_ID))).get("Sign off plan")
+
+        holistic_run(store, {"plan": "2026-10-22"})
+        first = plan_event()
+        holistic_run(store, {"plan": "2026-10-22"})
+        unchanged = plan_event()
+        holistic_run(store, {"plan": "2026-10-24"})
+        moved = plan_event()
+
+        assert (first["SEQUENCE"], unchanged["SEQUENCE"], moved["SEQUENCE"]) == ("0", "0", "1")
+        assert first["UID"] == moved["UID"]
+        assert moved["DTSTART;VALUE=DATE"] == "20261024"
+        holistic_run(store, {}, email_ids=["digest"])
+        assert plan_event() is not None
+        holistic_run(store, {})
+        assert plan_event() is None
+
+    def test_scoped_and_covered_by_tasks(self, store, feeds, tasks):
+        """Test that other users' deadlines are not listed and an email's open dated task replaces it."""
+        HolisticRunStore(db=store).record(USER_ID + 1, {"truly_relevant_actions": [
+            {"topic": "Their sign-off", "canonical_email_id": "theirs", "deadline": "2026-10-22"}
+        ]}, ["theirs"])
+        holistic_run(store, {"plan": "2026-10-22"})
+        asyncio.run(tasks.create_task(
+            TaskCreate(title="Sign off", email_id="plan", due_date=datetime(2026, 10, 21, 9, 0)), USER_ID
+        ))
+
+        assert list(events_by_summary(asyncio.run(feeds.render_feed(USER_ID)))) == ["Sign off"]
+
+
+class TestFeedTokens:
+    """Tests for feed token storage."""
+
+    def test_rotation_invalidates_old_token(self, feeds):
+        """Test that a new token replaces the old one."""
+        first = asyncio.run(feeds.rotate_token(USER_ID))
+        second = asyncio.run(feeds.rotate_token(USER_ID))
+
+        assert asyncio.run(feeds.user_for_token(first)) is None
+        assert asyncio.run(feeds.user_for_token(second)) == USER_ID
+
+    def test_only_hash_is_stored(self, store, feeds):
+        """Test that the plain token is not written to the database."""
+        token = asyncio.run(feeds.rotate_token(USER_ID))
+
+        with store.get_connection() as conn:
+            stored = conn.execute("SELECT feed_token_hash FROM user_settings").fetchone()[0]
+
+        assert stored != token and len(stored) == 64
+
+    def test_revoke(self, feeds):
+        """Test that a revoked token stops resolving."""
+        token = asyncio.run(feeds.rotate_token(USER_ID))
+
+        assert asyncio.run(feeds.revoke_token(USER_ID)) is True
+        assert asyncio.run(feeds.user_for_token(token)) is None
+        assert asyncio.run(feeds.revoke_token(USER_ID)) is False
+
+
+class TestFeedAPI:
+    """Tests for /api/feeds."""
+
+    @pytest.fixture
+    def client(self, feeds):
+        """Client with auth and the feed service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="planner", email="planner@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_calendar_feed_service] = lambda: feeds
+        return TestClient(app)
+
+    def test_subscribe_with_generated_url(self, client, tasks):
+        """Test that the generated URL serves the feed without a bearer token."""
+        seed(tasks)
+        generated = client.post("/api/feeds/token")
+
+        assert generated.status_code == 201
+        response = client.get(generated.json()["url"])
+
+        assert response.status_code == 200
+        assert response.headers["content-type"].startswith("text/calendar")
+        assert len(parse_ics(response.text)["events"]) == 2
+        assert client.get("/api/feeds/token").json()["active"] is True
+
+    def test_etag(self, client, tasks):
+        """Test that an unchanged feed answers If-None-Match with 304 and a change gives a new ETag."""
+        created = seed(tasks)
+        token = client.post("/api/feeds/token").json()["token"]
+
+        first = client.get("/api/feeds/tasks.ics", params={"token": token})
+        cached = client.get("/api/feeds/tasks.ics", params={"token": token},
+                            headers={"If-None-Match": first.headers["etag"]})
+        asyncio.run(tasks.update_task(created["review"].id, TaskUpdate(due_date=datetime(2026, 11, 1, 9, 0)), USER_ID))
+        changed = client.get("/api/feeds/tasks.ics", params={"token": token},
+                             headers={"If-None-Match": first.headers["etag"]})
+
+        assert cached.status_code == 304
+        assert cached.content == b""
+        assert changed.status_code == 200
+        assert changed.headers["etag"] != first.headers["etag"]
+
+    def test_unknown_and_revoked_tokens(self, client):
+        """Test that bad or revoked tokens get 404."""
+        token = client.post("/api/feeds/token").json()["token"]
+        client.delete("/api/feeds/token")
+
+        assert client.get("/api/feeds/tasks.ics", params={"token": token}).status_code == 404
+        assert client.get("/api/feeds/tasks.ics", params={"token": "nope"}).status_code == 404
+        assert client.delete("/api/feeds/token").status_code == 404
+        assert client.get("/api/feeds/token").json() == {"active": False, "created_at": None}
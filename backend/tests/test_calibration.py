@@ -0,0 +1,186 @@
+"""Tests for the classifier confidence calibration report."""
+
+import asyncio
+from datetime import datetime, timedelta
+from itertools import count
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.ai import router
+from backend.api.auth import get_current_user
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.classification_store import ClassificationStore, calibrate, get_classification_store
+
+USER_ID = 1
+NOW = datetime(2024, 3, 1, 12, 0)
+
+
+@pytest.fixture
+def store():
+    """Classification store on an in-memory database."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield ClassificationStore(db=db)
+    db.close()
+
+
+_ids = count()
+
+
+def seed(store, category, confidence, predictions, corrections, at=NOW - timedelta(days=1), user_id=USER_ID):
+    """Store AI predictions of category at confidence; the first corrections were changed by the user."""
+    with store.db.get_connection() as conn:
+        for index in range(predictions):
+            corrected = index < corrections
+            conn.execute(
+                """
+                INSERT INTO emails (id, subject, sender, category, confidence, ai_category,
+                                    ai_confidence, ai_processed_at, user_id)
+                VALUES (?, 'Subject', 'sender@example.com', ?, ?, ?, ?, ?, ?)
+                """,
+                (f"email-{next(_ids)}", "team_action" if corrected else category,
+                 1.0 if corrected else confidence, category, confidence, at, user_id)
+            )
+        conn.commit()
+
+
+def report(store, days=30, target_precision=0.9):
+    """Calibration report for USER_ID as of NOW."""
+    return asyncio.run(store.get_calibration_report(USER_ID, days=days, target_precision=target_precision, now=NOW))
+
+
+class TestCalibrationReport:
+    """Tests for ClassificationStore.get_calibration_report."""
+
+    def test_bucket_correction_rates(self, store):
+        """Test that each decile counts its predictions and corrections."""
+        seed(store, "required_personal_action", 0.95, 10, 0)
+        seed(store, "required_personal_action", 0.75, 10, 2)
+        seed(store, "required_personal_action", 0.55, 10, 6)
+
+        category = report(store).categories[0]
+
+        assert (category.category, category.predictions, category.corrections) == ("required_personal_action", 30, 8)
+        assert len(category.buckets) == 10
+        rates = {bucket.min_confidence: bucket.correction_rate for bucket in category.buckets if bucket.predictions}
+        assert rates == {0.5: 0.6, 0.7: 0.2, 0.9: 0.0}
+        assert category.buckets[7].mean_confidence == 0.75
+        assert category.buckets[3].correction_rate is None
+
+    def test_suggested_threshold(self, store):
+        """Test that the threshold is the lowest bound whose predictions above it meet the target."""
+        seed(store, "required_personal_action", 0.95, 10, 0)
+        seed(store, "required_personal_action", 0.75, 10, 2)
+        seed(store, "required_personal_action", 0.55, 10, 6)
+
+        category = report(store).categories[0]
+
+        # 18 of the 20 predictions at 0.7 and above were kept; adding 0.5 drops to 22 of 30
+        assert category.suggested_threshold == 0.7
+        assert category.threshold_precision == 0.9
+        assert category.threshold_coverage == 0.6667
+        assert report(store, target_precision=0.7).categories[0].suggested_threshold == 0.5
+
+    def test_no_threshold_meets_target(self, store):
+        """Test that no threshold is suggested when even the top bucket misses the target."""
+        seed(store, "fyi", 0.95, 4, 2)
+
+        category = report(store).categories[0]
+
+        assert category.suggested_threshold is None
+        assert category.threshold_precision is None
+
+    def test_full_confidence_in_top_bucket(self, store):
+        """Test that a confidence of exactly 1.0 counts in the 0.9-1.0 bucket."""
+        seed(store, "fyi", 1.0, 3, 0)
+
+        assert report(store).categories[0].buckets[9].predictions == 3
+
+    def test_categories_and_overall(self, store):
+        """Test that categories are reported separately and together, most predictions first."""
+        seed(store, "fyi", 0.85, 4, 1)
+        seed(store, "newsletter", 0.85, 6, 0)
+
+        calibration = report(store)
+
+        assert [category.category for category in calibration.categories] == ["newsletter", "fyi"]
+        overall = calibration.overall.buckets[8]
+        assert (calibration.overall.category, overall.predictions, overall.corrections) == ("all", 10, 1)
+        assert overall.correction_rate == 0.1
+
+    def test_window_and_user(self, store):
+        """Test that older classifications and other users' emails are left out."""
+        seed(store, "fyi", 0.85, 2, 0)
+        seed(store, "fyi", 0.85, 5, 5, at=NOW - timedelta(days=10))
+        seed(store, "fyi", 0.85, 5, 5, user_id=2)
+
+        calibration = report(store, days=7)
+
+        assert calibration.overall.predictions == 2
+        assert calibration.since == NOW - timedelta(days=7)
+        assert report(store, days=30).overall.predictions == 7
+
+    def test_unclassified_emails_ignored(self, store):
+        """Test that emails the AI never classified are not predictions."""
+        with store.db.get_connection() as conn:
+            conn.execute(
+                "INSERT INTO emails (id, subject, sender, category, user_id) VALUES ('manual', 'S', 'a@b.com', 'fyi', ?)",
+                (USER_ID,)
+            )
+            conn.commit()
+
+        calibration = report(store)
+
+        assert calibration.overall.predictions == 0
+        assert calibration.categories == []
+
+
+class TestCalibrate:
+    """Tests for folding bucket rows into a calibration."""
+
+    def test_rows_sharing_a_bucket_are_merged(self):
+        """Test that counts and mean confidence of rows in one bucket combine."""
+        calibration = calibrate("fyi", [
+            {"bucket": 6, "predictions": 2, "corrections": 1, "mean_confidence": 0.6},
+            {"bucket": 6, "predictions": 2, "corrections": 0, "mean_confidence": 0.68},
+        ], 0.9)
+
+        bucket = calibration.buckets[6]
+        assert (bucket.predictions, bucket.corrections, bucket.correction_rate) == (4, 1, 0.25)
+        assert bucket.mean_confidence == 0.64
+
+
+class TestCalibrationAPI:
+    """Tests for GET /api/ai/calibration."""
+
+    @pytest.fixture
+    def client(self, store):
+        """Client with auth and the classification store overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="calibrator", email="calibrator@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_classification_store] = lambda: store
+        return TestClient(app)
+
+    def test_get_calibration(self, client, store):
+        """Test that the report covers recent classifications."""
+        seed(store, "fyi", 0.95, 5, 0, at=datetime.utcnow())
+
+        response = client.get("/api/ai/calibration", params={"days": 7, "target_precision": 0.8})
+
+        assert response.status_code == 200
+        data = response.json()
+        assert (data["days"], data["target_precision"]) == (7, 0.8)
+        assert data["categories"][0]["suggested_threshold"] == 0.9
+
+    @pytest.mark.parametrize("params", [{"days": 0}, {"days": 400}, {"target_precision": 1.5}, {"target_precision": 0}])
+    def test_invalid_parameters(self, client, params):
+        """Test that out-of-range windows and targets are rejected."""
+        assert client.get("/api/ai/calibration", params=params).status_code == 422
@@ -0,0 +1,289 @@
+"""Tests for user-defined classification categories."""
+
+import asyncio
+import json
+from datetime import datetime
+from unittest.mock import MagicMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import ai, emails
+from backend.api.auth import get_current_user
+from backend.api.categories import router
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager, set_default_manager
+from backend.models.category import CategoryCreate, CategoryUpdate
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.ai_service import AIService
+from backend.services.category_service import (
+    CategoryService, category_names, classifier_prompt_inputs, get_category_service, load_categories
+)
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.folder_suggestions import rank_folder_suggestions
+from classification_categories import BUILT_IN_CATEGORIES
+
+USER_ID = 1
+
+INVOICE = CategoryCreate(
+    name="vendor_invoice",
+    description="Invoices and payment reminders from vendors\nBilling questions addressed to {{username}}",
+    priority="medium",
+    folder="Invoices"
+)
+
+
+@pytest.fixture
+def store():
+    """In-memory store installed as the process default for one test."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    set_default_manager(store)
+    yield store
+    set_default_manager(None)
+    store.close()
+
+
+@pytest.fixture
+def categories(store):
+    """Category service over the store."""
+    return CategoryService(db=store)
+
+
+@pytest.fixture
+def service(store):
+    """Email service over an authenticated mock provider and the store."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return EmailService(provider, db=store)
+
+
+def add_invoice(categories):
+    """Register the vendor_invoice category."""
+    return asyncio.run(categories.create_category(INVOICE))
+
+
+class TestCategoryService:
+    """Tests for the categories table."""
+
+    def test_built_ins_are_seeded(self, categories):
+        """Test that every built-in category is stored, flagged, in prompt order."""
+        stored = asyncio.run(categories.list_categories())
+
+        assert [c.name for c in stored] == [c["name"] for c in BUILT_IN_CATEGORIES]
+        assert all(c.built_in for c in stored)
+        assert stored[0].folder == "Required Actions (Me)"
+
+    def test_seeding_keeps_edits(self, store, categories):
+        """Test that re-running the schema setup does not reset edited built-ins."""
+        asyncio.run(categories.update_category("fyi", CategoryUpdate(description="Only announcements")))
+
+        store._create_basic_structure()
+
+        assert asyncio.run(categories.get_category("fyi")).description == "Only announcements"
+
+    def test_created_categories_come_last(self, store, categories):
+        """Test that new categories are user-defined and appended to the prompt order."""
+        created = add_invoice(categories)
+
+        assert created.built_in is False
+        assert category_names(store)[-1] == "vendor_invoice"
+
+    def test_update_clears_folder(self, categories):
+        """Test that an empty folder unsets the category's folder."""
+        add_invoice(categories)
+
+        updated = asyncio.run(categories.update_category("vendor_invoice", CategoryUpdate(folder="")))
+
+        assert updated.folder is None
+        assert asyncio.run(categories.update_category("missing", CategoryUpdate(priority="high"))) is None
+
+    def test_built_ins_are_never_deleted(self, categories):
+        """Test that the service refuses to delete built-in rows."""
+        assert asyncio.run(categories.delete_category("fyi")) is False
+        assert asyncio.run(categories.get_category("fyi")) is not None
+
+
+class TestPromptRendering:
+    """Tests for rendering the stored categories into the classifier prompt."""
+
+    def test_new_category_is_rendered(self, store, categories):
+        """Test that a new category's rules and name reach the prompt inputs immediately."""
+        before = classifier_prompt_inputs("Amelia", store)
+        add_invoice(categories)
+        after = classifier_prompt_inputs("Amelia", store)
+
+        assert "vendor_invoice" not in before["category_rules"]
+        assert after["category_names"].endswith(", vendor_invoice")
+        medium = after["category_rules"].split("### Medium Priority")[1].split("### Low Priority")[0]
+        assert "**vendor_invoice**\n- Invoices and payment reminders from vendors" in medium
+        assert "- Billing questions addressed to Amelia" in medium
+
+    def test_edited_description_is_rendered(self, store, categories):
+        """Test that editing a built-in category changes its rendered rules."""
+        asyncio.run(categories.update_category("newsletter", CategoryUpdate(description="Weekly digests only")))
+
+        rules = classifier_prompt_inputs("Amelia", store)["category_rules"]
+
+        assert "**newsletter**\n- Weekly digests only" in rules
+        assert "Mass-distributed" not in rules
+
+    def test_priority_moves_section(self, store, categories):
+        """Test that a category is listed under its priority's heading."""
+        asyncio.run(categories.update_category("fyi", CategoryUpdate(priority="high")))
+
+        rules = classifier_prompt_inputs("Amelia", store)["category_rules"]
+
+        assert rules.index("**fyi**") < rules.index("### Medium Priority")
+
+    @patch('backend.services.ai_service.AIProcessor')
+    @patch('backend.services.ai_service.get_azure_config')
+    def test_classifier_receives_new_category(self, mock_config, mock_processor, categories):
+        """Test that AIService classifies into the stored categories, new ones included."""
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_ai_instance.classify_email_with_explanation.return_value = {
+            "category": "vendor_invoice", "confidence": 0.9, "explanation": "Vendor invoice"
+        }
+        add_invoice(categories)
+
+        result = asyncio.run(AIService().classify_email("Subject: Invoice 42\n\nPlease pay by Friday"))
+
+        assert result["category"] == "vendor_invoice"
+        passed = mock_ai_instance.classify_email_with_explanation.call_args.kwargs["categories"]
+        assert [c["name"] for c in passed][-1] == "vendor_invoice"
+
+    def test_processor_accepts_only_known_categories(self, store, categories):
+        """Test that AIProcessor falls back to fyi for categories outside the given set."""
+        ai_processor = pytest.importorskip("ai_processor")
+        processor = ai_processor.AIProcessor.__new__(ai_processor.AIProcessor)
+        processor.get_few_shot_examples = lambda email, learning: []
+        processor.get_username = lambda: "Amelia"
+        processor.get_job_role_context = lambda: ""
+        processor.generate_explanation = lambda email, category: f"Fallback for {category}"
+        processor.execute_prompty = MagicMock(
+            return_value=json.dumps({"category": "vendor_invoice", "explanation": "An invoice from a vendor"})
+        )
+        email = {"subject": "Invoice 42", "sender": "billing@vendor.com", "body": "Please pay"}
+
+        unknown = processor.classify_email_with_explanation(email, [], categories=load_categories(store))
+        add_invoice(categories)
+        known = processor.classify_email_with_explanation(email, [], categories=load_categories(store))
+
+        assert unknown["category"] == "fyi"
+        assert known["category"] == "vendor_invoice"
+        inputs = processor.execute_prompty.call_args[0][1]
+        assert "**vendor_invoice**" in inputs["category_rules"]
+
+    def test_folder_for_new_category(self, store, categories):
+        """Test that a new category's folder is suggested like a built-in mapping."""
+        add_invoice(categories)
+
+        suggestions = rank_folder_suggestions(
+            "Inbox", category="vendor_invoice", category_confidence=0.8,
+            folders=["Inbox", "Invoices"], category_folders={"vendor_invoice": "Invoices"}
+        )
+
+        assert [(s.folder, s.source) for s in suggestions] == [("Invoices", "category")]
+
+
+class TestCategoriesAPI:
+    """Tests for /api/categories."""
+
+    @pytest.fixture
+    def client(self, categories, service):
+        """Client with auth and services overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.include_router(emails.router, prefix="/api")
+        app.include_router(ai.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="sorter", email="sorter@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_category_service] = lambda: categories
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: MagicMock()
+        return TestClient(app)
+
+    def test_list(self, client):
+        """Test that the seeded categories are listed."""
+        data = client.get("/api/categories").json()
+
+        assert data["total"] == len(BUILT_IN_CATEGORIES)
+        assert data["categories"][0]["built_in"] is True
+
+    def test_create_get_update(self, client):
+        """Test the create, read, and update round trip."""
+        created = client.post("/api/categories", json=INVOICE.model_dump())
+        duplicate = client.post("/api/categories", json=INVOICE.model_dump())
+        updated = client.put("/api/categories/vendor_invoice", json={"priority": "high"})
+
+        assert created.status_code == 201
+        assert duplicate.status_code == 409
+        assert updated.json()["priority"] == "high"
+        assert client.get("/api/categories/vendor_invoice").json()["priority"] == "high"
+        assert client.get("/api/categories/nope").status_code == 404
+
+    @pytest.mark.parametrize("payload", [
+        {"name": "Vendor Invoice", "description": "x"},
+        {"name": "vendor_invoice", "description": "x", "priority": "urgent"},
+        {"name": "vendor_invoice", "description": ""},
+    ])
+    def test_create_validation(self, client, payload):
+        """Test that bad names, priorities, and empty rules are refused."""
+        assert client.post("/api/categories", json=payload).status_code == 422
+
+    def test_built_in_cannot_be_deleted(self, client):
+        """Test that deleting a built-in category is a conflict."""
+        response = client.delete("/api/categories/team_action")
+
+        assert response.status_code == 409
+        assert "migrate" in response.json()["error"]["message"]
+
+    def test_delete_custom(self, client, service):
+        """Test that a custom category can be deleted once no email is in it."""
+        client.post("/api/categories", json=INVOICE.model_dump())
+        email = service.provider.mock_emails[0]
+        asyncio.run(service.save_classification(
+            email, EmailClassification(category="vendor_invoice", confidence=0.9), USER_ID
+        ))
+
+        in_use = client.delete("/api/categories/vendor_invoice")
+        asyncio.run(service.update_classifications(USER_ID, [(email["id"], "fyi")]))
+        deleted = client.delete("/api/categories/vendor_invoice")
+
+        assert in_use.status_code == 409
+        assert deleted.status_code == 200
+        assert client.get("/api/categories/vendor_invoice").status_code == 404
+
+    def test_validation_accepts_new_category(self, client, service):
+        """Test that reclassifying into a category is refused until it is registered."""
+        email = service.provider.mock_emails[0]
+        asyncio.run(service.save_classification(
+            email, EmailClassification(category="fyi", confidence=0.9), USER_ID
+        ))
+        url = f"/api/emails/{email['id']}/classification"
+
+        refused = client.put(url, json={"category": "vendor_invoice"})
+        client.post("/api/categories", json=INVOICE.model_dump())
+        accepted = client.put(url, json={"category": "vendor_invoice"})
+
+        assert refused.status_code == 422
+        assert "vendor_invoice" in refused.json()["error"]["message"]
+        assert accepted.status_code == 200
+
+    def test_backfill_filter_accepts_new_category(self, client):
+        """Test that AI endpoints validate category filters against the stored set."""
+        payload = {"category": "vendor_invoice", "dry_run": True}
+
+        refused = client.post("/api/ai/summaries/backfill", json=payload)
+        client.post("/api/categories", json=INVOICE.model_dump())
+        accepted = client.post("/api/ai/summaries/backfill", json=payload)
+
+        assert refused.status_code == 422
+        assert accepted.status_code == 202
@@ -0,0 +1,172 @@
+"""Tests for migrating stored data from one category to another."""
+
+import asyncio
+import json
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.admin import router
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.category_migration import CategoryMigrationService, get_category_migration_service
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+
+@pytest.fixture
+def service():
+    """Email service over an authenticated mock provider and an in-memory store."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield EmailService(provider, db=store)
+    store.close()
+
+
+@pytest.fixture
+def migrations(service):
+    """Migration service over the same store."""
+    return CategoryMigrationService(db=service.db)
+
+
+def seed(service):
+    """Classify the mock emails as team_action, correct one, and save views on both categories."""
+    async def setup():
+        for email in service.provider.mock_emails:
+            await service.save_classification(
+                email, EmailClassification(category="team_action", confidence=0.8), USER_ID
+            )
+        await service.update_classifications(USER_ID, [("mock-email-1", "fyi")])
+        await service.update_classifications(USER_ID, [("mock-email-1", "team_action")])
+        await service.save_classification(
+            {"id": "other-user", "subject": "x", "sender": "s@example.com", "body": "Hi"},
+            EmailClassification(category="team_action", confidence=0.8), USER_ID + 1
+        )
+
+    asyncio.run(setup())
+    with service.db.get_connection() as conn:
+        for name, filters in (("Team", {"category": "team_action", "unread": True}), ("FYI", {"category": "fyi"})):
+            conn.execute(
+                "INSERT INTO saved_views (user_id, name, filters, sort) VALUES (?, ?, ?, 'newest')",
+                (USER_ID, name, json.dumps(filters, sort_keys=True))
+            )
+        conn.commit()
+
+
+def categories(service, user_id=USER_ID):
+    """Stored category of each of a user's emails."""
+    with service.db.get_connection() as conn:
+        rows = conn.execute("SELECT id, category FROM emails WHERE user_id = ?", (user_id,)).fetchall()
+    return {row["id"]: row["category"] for row in rows}
+
+
+class TestCategoryMigrationService:
+    """Tests for CategoryMigrationService.migrate."""
+
+    def test_dry_run_counts_without_changing(self, service, migrations):
+        """Test that a dry run reports affected rows per table and writes nothing."""
+        seed(service)
+        before = categories(service)
+
+        outcome = asyncio.run(migrations.migrate(USER_ID, "team_action", "work_relevant", dry_run=True))
+
+        assert outcome.affected == {
+            "emails": len(before), "classification_history": 2, "saved_views": 1
+        }
+        assert outcome.migration_id is None
+        assert categories(service) == before
+        with service.db.get_connection() as conn:
+            assert conn.execute("SELECT COUNT(*) FROM category_migrations").fetchone()[0] == 0
+
+    def test_migration_rewrites_every_table(self, service, migrations):
+        """Test that emails, history, and saved views move to the new category and are audited."""
+        seed(service)
+
+        outcome = asyncio.run(migrations.migrate(USER_ID, "team_action", "work_relevant"))
+
+        assert set(categories(service).values()) == {"work_relevant"}
+        assert categories(service, USER_ID + 1) == {"other-user": "team_action"}
+        history = asyncio.run(service.get_classification_history("mock-email-1", USER_ID))
+        assert {(h["previous_category"], h["category"]) for h in history} == {
+            ("work_relevant", "fyi"), ("fyi", "work_relevant")
+        }
+        with service.db.get_connection() as conn:
+            views = {row["name"]: json.loads(row["filters"]) for row in conn.execute("SELECT * FROM saved_views")}
+            audit = conn.execute("SELECT * FROM category_migrations").fetchone()
+        assert views == {"Team": {"category": "work_relevant", "unread": True}, "FYI": {"category": "fyi"}}
+        assert audit["id"] == outcome.migration_id
+        assert (audit["from_category"], audit["to_category"]) == ("team_action", "work_relevant")
+        assert json.loads(audit["affected"]) == outcome.affected
+
+    def test_migration_bumps_versions(self, service, migrations):
+        """Test that migrated emails get a new version so stale corrections are refused."""
+        seed(service)
+        before = asyncio.run(service.get_classification_state("mock-email-2", USER_ID))["version"]
+
+        asyncio.run(migrations.migrate(USER_ID, "team_action", "work_relevant"))
+
+        assert asyncio.run(service.get_classification_state("mock-email-2", USER_ID))["version"] == before + 1
+
+
+class TestMigrateAPI:
+    """Tests for POST /api/admin/categories/migrate."""
+
+    @pytest.fixture
+    def client(self, service, migrations):
+        """Client with auth and both services overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="admin", email="admin@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_category_migration_service] = lambda: migrations
+        return TestClient(app)
+
+    def test_custom_target_requires_allow_custom(self, client, service):
+        """Test that unknown targets are refused unless allow_custom is set."""
+        seed(service)
+
+        refused = client.post("/api/admin/categories/migrate", json={"from": "team_action", "to": "delegate_action"})
+        allowed = client.post("/api/admin/categories/migrate", json={
+            "from": "team_action", "to": "delegate_action", "allow_custom": True, "dry_run": True
+        })
+
+        assert refused.status_code == 422
+        assert allowed.status_code == 200
+        assert allowed.json()["dry_run"] is True
+
+    @pytest.mark.parametrize("payload", [
+        {"from": "fyi", "to": "fyi"},
+        {"from": "fyi", "to": "Not A Name", "allow_custom": True},
+    ])
+    def test_invalid_requests(self, client, payload):
+        """Test that same-category and badly named custom targets are refused."""
+        assert client.post("/api/admin/categories/migrate", json=payload).status_code == 422
+
+    def test_update_outlook_reapplies_category(self, client, service):
+        """Test that update_outlook applies the new category to each migrated email."""
+        seed(service)
+        migrated = sorted(categories(service))
+        service.provider.mock_emails.pop()
+
+        response = client.post("/api/admin/categories/migrate", json={
+            "from": "team_action", "to": "work_relevant", "update_outlook": True
+        })
+
+        assert response.status_code == 207
+        data = response.json()
+        assert data["outlook_applied"] == len(migrated) - 1
+        assert [error["status_code"] for error in data["errors"]] == [502]
+        assert service.provider.mock_emails[0]["categories"] == ["work_relevant"]
@@ -0,0 +1,192 @@
+"""Tests for category suggestions while classifying by hand."""
+
+import asyncio
+from datetime import datetime
+from types import SimpleNamespace
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.category_suggestions import (
+    alternative_names, decode_alternatives, encode_alternatives, rank_category_suggestions, rule_matches
+)
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+CATEGORIES = ["required_personal_action", "job_listing", "fyi", "newsletter", "work_relevant", "optional_action"]
+
+
+def ranked(suggestions):
+    """(category, source, score) of each suggestion."""
+    return [(s.category, s.source, s.score) for s in suggestions]
+
+
+class TestRanking:
+    """Tests for combining the suggestion sources."""
+
+    def test_sources_are_ranked_by_score(self):
+        """Test that rule, sender, and AI suggestions interleave by score, each category once."""
+        suggestions = rank_category_suggestions(
+            "fyi",
+            CATEGORIES,
+            rule_categories=["job_listing"],
+            corrections=["newsletter", "optional_action", "newsletter"],
+            alternatives=["newsletter", "work_relevant"],
+            confidence=0.6
+        )
+
+        assert ranked(suggestions) == [
+            ("job_listing", "rule", 0.9),
+            ("newsletter", "sender_override", 0.8),
+            ("optional_action", "sender_history", 0.25),
+            ("work_relevant", "ai_alternative", 0.2),
+        ]
+
+    def test_history_grows_with_corrections(self):
+        """Test that one correction is not certain and a long habit scores higher than the override."""
+        once = rank_category_suggestions(None, CATEGORIES, corrections=["fyi"])
+        habit = rank_category_suggestions(None, CATEGORIES, corrections=["fyi"] * 9)
+
+        assert ranked(once) == [("fyi", "sender_override", 0.8)]
+        assert ranked(habit) == [("fyi", "sender_history", 0.9)]
+
+    def test_alternatives_share_the_doubt(self):
+        """Test that alternatives halve by rank and default to half the doubt without a confidence."""
+        confident = rank_category_suggestions("fyi", CATEGORIES, alternatives=["newsletter", "work_relevant"],
+                                              confidence=0.9)
+        unknown = rank_category_suggestions("fyi", CATEGORIES, alternatives=["newsletter"])
+
+        assert [s.score for s in confident] == [0.1, 0.05]
+        assert [s.score for s in unknown] == [0.5]
+
+    def test_current_and_unknown_categories_skipped(self):
+        """Test that the current category and deleted categories are never suggested, and limit applies."""
+        suggestions = rank_category_suggestions(
+            "fyi", CATEGORIES, rule_categories=["fyi", "retired_category"], alternatives=CATEGORIES, limit=2
+        )
+
+        assert [s.category for s in suggestions] == ["required_personal_action", "job_listing"]
+        assert rank_category_suggestions("fyi", CATEGORIES) == []
+
+    def test_rule_matching(self):
+        """Test that every condition must match, any value of a condition will do."""
+        rule = SimpleNamespace(senders=["@contoso.com", "jobs@"], subject_contains=["Opening"])
+
+        assert rule_matches(rule, "Jobs@Fabrikam.com", "New OPENING in Redmond")
+        assert not rule_matches(rule, "jobs@fabrikam.com", "Lunch")
+        assert not rule_matches(SimpleNamespace(senders=[], subject_contains=[]), "a@b.com", "Hi")
+
+    def test_alternative_storage(self):
+        """Test that alternatives are read from names or objects and survive a round trip."""
+        names = alternative_names(["newsletter", {"category": "fyi"}, " ", "newsletter", {"reason": "x"}])
+
+        assert names == ["newsletter", "fyi"]
+        assert decode_alternatives(encode_alternatives(names)) == names
+        assert encode_alternatives([]) is None
+        assert decode_alternatives("not json") == []
+
+
+class TestCategorySuggestionsAPI:
+    """Tests for GET /api/emails/{id}/category-suggestions."""
+
+    @pytest.fixture
+    def provider(self):
+        """Authenticated mock provider with a rule filing the first sender's mail as job listings."""
+        mock = MockEmailProvider()
+        mock.authenticate({})
+        mock.mock_rules = [{
+            "name": "Recruiters", "enabled": True, "incoming": True, "senders": ["test1@"],
+            "subject_contains": [], "move_to_folder": "Job Listings", "other_conditions": [], "other_actions": []
+        }]
+        return mock
+
+    @pytest.fixture
+    def service(self, provider):
+        """Email service over the mock provider and an in-memory store."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        yield EmailService(provider, db=store)
+        store.close()
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="filer", email="filer@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        return TestClient(app)
+
+    def seed(self, service):
+        """Classify the first email with alternatives and correct a second email from its sender twice."""
+        async def setup():
+            for email in service.provider.mock_emails:
+                await service.store_email(email, USER_ID)
+            await service.save_classification(
+                service.provider.mock_emails[0],
+                EmailClassification(
+                    category="fyi", confidence=0.6, alternative_categories=["newsletter", "work_relevant"]
+                ),
+                USER_ID
+            )
+            with service.db.get_connection() as conn:
+                conn.execute("UPDATE emails SET sender = 'test1@example.com' WHERE id = 'mock-email-2'")
+                conn.commit()
+            await service.update_classifications(USER_ID, [("mock-email-2", "optional_action")])
+            await service.update_classifications(USER_ID, [("mock-email-2", "newsletter")])
+
+        asyncio.run(setup())
+
+    def test_suggestions_combine_sources(self, client, service):
+        """Test that rules, corrections, and stored alternatives are ranked without changing anything."""
+        self.seed(service)
+        with service.db.get_connection() as conn:
+            version_before = conn.execute("SELECT version FROM emails WHERE id = 'mock-email-1'").fetchone()[0]
+
+        data = client.get("/api/emails/mock-email-1/category-suggestions").json()
+
+        assert data == {"email_id": "mock-email-1", "current_category": "fyi", "suggestions": [
+            {"category": "job_listing", "source": "rule", "score": 0.9},
+            {"category": "newsletter", "source": "sender_override", "score": 0.8},
+            {"category": "optional_action", "source": "sender_history", "score": 0.333},
+            {"category": "work_relevant", "source": "ai_alternative", "score": 0.2},
+        ]}
+        with service.db.get_connection() as conn:
+            assert conn.execute("SELECT version FROM emails WHERE id = 'mock-email-1'").fetchone()[0] == version_before
+
+    def test_alternatives_are_stored(self, service):
+        """Test that a classification's alternatives are kept on the row and replaced by the next one."""
+        self.seed(service)
+        stored = asyncio.run(service.get_stored_emails(["mock-email-1"], USER_ID))["mock-email-1"]
+        assert stored["alternative_categories"] == ["newsletter", "work_relevant"]
+
+        asyncio.run(service.save_classification(
+            service.provider.mock_emails[0], EmailClassification(category="fyi", confidence=0.9), USER_ID
+        ))
+        stored = asyncio.run(service.get_stored_emails(["mock-email-1"], USER_ID))["mock-email-1"]
+        assert stored["alternative_categories"] == []
+
+    def test_unreadable_rules_are_skipped(self, client, service, provider):
+        """Test that suggestions still come from the other sources when rules cannot be read."""
+        self.seed(service)
+        provider.get_rules = lambda: (_ for _ in ()).throw(NotImplementedError("no rules"))
+
+        data = client.get("/api/emails/mock-email-1/category-suggestions").json()
+
+        assert [s["source"] for s in data["suggestions"]] == ["sender_override", "sender_history", "ai_alternative"]
+
+    def test_missing_email(self, client):
+        """Test that unknown emails are 404."""
+        assert client.get("/api/emails/ghost/category-suggestions").status_code == 404
@@ -0,0 +1,191 @@
+"""Tests for bulk classification corrections."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import MAX_CLASSIFICATION_CORRECTIONS, EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+
+class CategoryProvider(MockEmailProvider):
+    """Mock provider that fails to categorize mock-email-2."""
+
+    def categorize_email(self, email_id, category):
+        if email_id == "mock-email-2":
+            return False
+        return super().categorize_email(email_id, category)
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock provider."""
+    mock = CategoryProvider()
+    mock.authenticate({})
+    return mock
+
+
+@pytest.fixture
+async def service(provider):
+    """Email service with the provider's emails stored as fyi."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    for email in provider.mock_emails:
+        await email_service.save_classification(
+            email, EmailClassification(category="fyi", confidence=0.6), USER_ID
+        )
+    yield email_service
+    store.close()
+
+
+def stored_category(service, email_id):
+    """Read an email's stored category and confidence."""
+    with service.db.get_connection() as conn:
+        row = conn.execute("SELECT category, confidence FROM emails WHERE id = ?", (email_id,)).fetchone()
+    return row["category"], row["confidence"]
+
+
+class TestUpdateClassifications:
+    """Tests for EmailService.update_classifications."""
+
+    @pytest.mark.asyncio
+    async def test_mixed_known_and_unknown(self, service):
+        """Test that known emails update, unknown ones are reported, and changes are recorded."""
+        result = await service.update_classifications(
+            USER_ID, [("mock-email-1", "newsletter"), ("nope", "newsletter"), ("mock-email-2", "fyi")]
+        )
+
+        assert result.missing == ["nope"]
+        assert stored_category(service, "mock-email-1") == ("newsletter", 1.0)
+        history = await service.get_classification_history("mock-email-1", USER_ID)
+        assert [(h["previous_category"], h["category"], h["source"]) for h in history] == [
+            ("fyi", "newsletter", "user")
+        ]
+        # Unchanged categories are not history
+        assert await service.get_classification_history("mock-email-2", USER_ID) == []
+
+    @pytest.mark.asyncio
+    async def test_other_users_emails_are_unknown(self, service):
+        """Test that corrections only touch the caller's emails."""
+        result = await service.update_classifications(2, [("mock-email-1", "newsletter")])
+        assert result.missing == ["mock-email-1"]
+        assert stored_category(service, "mock-email-1") == ("fyi", 0.6)
+
+    @pytest.mark.asyncio
+    async def test_failure_rolls_back(self, service):
+        """Test that an error part-way leaves every email unchanged."""
+        with service.db.get_connection() as conn:
+            conn.execute("DROP TABLE classification_history")
+            conn.commit()
+
+        with pytest.raises(Exception):
+            await service.update_classifications(USER_ID, [("mock-email-1", "newsletter"), ("mock-email-2", "spam_to_delete")])
+
+        assert stored_category(service, "mock-email-1") == ("fyi", 0.6)
+
+
+class TestClassificationCorrectionAPI:
+    """Tests for PUT /emails/classifications."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="fixer", email="fixer@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        return TestClient(app)
+
+    def test_mixed_known_and_unknown(self, client, service, provider):
+        """Test per-item errors and that Outlook is left alone by default."""
+        response = client.put("/api/emails/classifications", json={"classifications": [
+            {"email_id": "mock-email-1", "category": "newsletter"},
+            {"email_id": "ghost", "category": "newsletter"},
+        ]})
+
+        assert response.status_code == 207
+        assert response.json() == {
+            "success_count": 1,
+            "failure_count": 1,
+            "errors": [{"email_id": "ghost", "error": "Email not found", "status_code": 404, "is_retryable": False}],
+            "conflicts": [],
+        }
+        assert stored_category(service, "mock-email-1")[0] == "newsletter"
+        assert provider.mock_emails[0]["categories"] == ["Test"]
+
+    def test_apply_to_outlook(self, client, provider):
+        """Test that stored corrections are applied through the provider."""
+        response = client.put("/api/emails/classifications", json={
+            "classifications": [
+                {"email_id": "mock-email-1", "category": "newsletter"},
+                {"email_id": "mock-email-2", "category": "newsletter"},
+            ],
+            "apply_to_outlook": True,
+        })
+
+        assert response.status_code == 207
+        data = response.json()
+        assert data["success_count"] == 1
+        assert data["errors"] == [{
+            "email_id": "mock-email-2", "error": "Failed to apply category in Outlook",
+            "status_code": 502, "is_retryable": True,
+        }]
+        assert provider.mock_emails[0]["categories"] == ["newsletter"]
+
+    def test_status_follows_items(self, client):
+        """Test 200 when every item succeeds and the item status when all fail."""
+        ok = client.put("/api/emails/classifications", json={"classifications": [
+            {"email_id": "mock-email-1", "category": "newsletter"},
+        ]})
+        missing = client.put("/api/emails/classifications", json={"classifications": [
+            {"email_id": "ghost", "category": "newsletter"},
+            {"email_id": "phantom", "category": "fyi"},
+        ]})
+
+        assert ok.status_code == 200
+        assert ok.json()["errors"] == []
+        assert missing.status_code == 404
+        assert missing.json()["failure_count"] == 2
+
+    @pytest.mark.parametrize("classifications", [
+        [{"email_id": "mock-email-1", "category": "junk"}],
+        [{"email_id": "mock-email-1", "category": ""}],
+    ])
+    def test_validation(self, client, service, classifications):
+        """Test that unknown and empty categories are rejected."""
+        response = client.put("/api/emails/classifications", json={"classifications": classifications})
+
+        assert response.status_code == 422
+        assert stored_category(service, "mock-email-1")[0] == "fyi"
+
+    @pytest.mark.parametrize("classifications,message", [
+        ([], "at least 1 item"),
+        (
+            [{"email_id": "mock-email-1", "category": "fyi"}, {"email_id": "mock-email-1", "category": "newsletter"}],
+            "Each email can only appear once"
+        ),
+        ([{"email_id": f"e{i}", "category": "fyi"} for i in range(MAX_CLASSIFICATION_CORRECTIONS + 1)], "at most"),
+    ])
+    def test_batch_shape_errors(self, client, classifications, message):
+        """Test that empty, duplicate, and oversized batches name the classifications field."""
+        response = client.put("/api/emails/classifications", json={"classifications": classifications})
+
+        assert response.status_code == 422
+        fields = response.json()["error"]["fields"]
+        assert [field["field"] for field in fields] == ["classifications"]
+        assert message in fields[0]["message"]
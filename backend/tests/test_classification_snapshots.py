@@ -0,0 +1,218 @@
+"""Tests for classification snapshots, their comparison, and restore."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.admin import router
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import ConflictError, register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.classification_snapshots import (
+    UNCLASSIFIED, SnapshotService, compare_rows, get_snapshot_service
+)
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+# ID -> category at snapshot time
+CATEGORIES = {"a": "fyi", "b": "fyi", "c": "team_action", "d": "newsletter"}
+
+
+def row(category, ai_category=None, folder="Inbox", subject=None):
+    """A snapshot or current row."""
+    return {"category": category, "ai_category": ai_category or category, "folder": folder, "subject": subject}
+
+
+@pytest.fixture
+def service():
+    """Email service over an in-memory store with CATEGORIES classified for USER_ID."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(MockEmailProvider(), db=store)
+
+    async def setup():
+        for email_id, category in CATEGORIES.items():
+            await email_service.save_classification(
+                {"id": email_id, "subject": f"Email {email_id}", "sender": "s@example.com", "body": "Hi"},
+                EmailClassification(category=category, confidence=0.8), USER_ID
+            )
+        await email_service.save_classification(
+            {"id": "other-user", "subject": "x", "sender": "s@example.com", "body": "Hi"},
+            EmailClassification(category="fyi", confidence=0.8), USER_ID + 1
+        )
+
+    asyncio.run(setup())
+    yield email_service
+    store.close()
+
+
+@pytest.fixture
+def snapshots(service):
+    """Snapshot service over the same store."""
+    return SnapshotService(db=service.db)
+
+
+class TestCompareRows:
+    """Tests for the snapshot comparison math."""
+
+    def test_counts_and_churn(self):
+        """Test compared, removed, added, and per-category churn."""
+        snapshot = {"a": row("fyi"), "b": row("fyi"), "c": row("team_action"), "d": row("newsletter")}
+        current = {
+            "a": row("team_action"), "b": row("fyi"), "c": row("team_action"),
+            "e": row("fyi"),
+        }
+
+        result = compare_rows(snapshot, current)
+
+        assert (result["compared"], result["removed"], result["added"]) == (3, 1, 1)
+        assert (result["changed"], result["recategorized"], result["churn_rate"]) == (1, 1, 0.3333)
+        churn = {entry.category: entry for entry in result["categories"]}
+        assert (churn["fyi"].before, churn["fyi"].after, churn["fyi"].left, churn["fyi"].joined) == (2, 2, 1, 0)
+        assert churn["fyi"].churn_rate == 0.5
+        assert (churn["team_action"].before, churn["team_action"].after, churn["team_action"].joined) == (1, 2, 1)
+        assert churn["team_action"].churn_rate == 0.0
+        assert (churn["newsletter"].before, churn["newsletter"].after) == (1, 0)
+        assert [entry.category for entry in result["categories"]] == ["fyi", "newsletter", "team_action"]
+
+    def test_changes_list_every_difference(self):
+        """Test that AI category and folder differences are changes without counting as churn."""
+        snapshot = {"a": row("fyi"), "b": row("fyi"), "c": row("fyi")}
+        current = {
+            "a": row("fyi", ai_category="newsletter", subject="A"),
+            "b": row("fyi", folder="Archive"),
+            "c": row("fyi"),
+        }
+
+        result = compare_rows(snapshot, current)
+
+        relabeled, moved = result["changes"]
+        assert [change.email_id for change in result["changes"]] == ["a", "b"]
+        assert (relabeled.before_ai_category, relabeled.after_ai_category, relabeled.subject) == ("fyi", "newsletter", "A")
+        assert (moved.before_folder, moved.after_folder) == ("Inbox", "Archive")
+        assert (result["changed"], result["recategorized"], result["churn_rate"]) == (2, 0, 0.0)
+
+    def test_unclassified_and_limit(self):
+        """Test that missing categories are counted as unclassified and changes are truncated."""
+        snapshot = {email_id: row(None) for email_id in "abc"}
+        current = {email_id: row("fyi") for email_id in "abc"}
+
+        result = compare_rows(snapshot, current, limit=2)
+
+        churn = {entry.category: entry for entry in result["categories"]}
+        assert (churn[UNCLASSIFIED].before, churn[UNCLASSIFIED].left, churn[UNCLASSIFIED].churn_rate) == (3, 3, 1.0)
+        assert [change.email_id for change in result["changes"]] == ["a", "b"]
+        assert result["changes_truncated"]
+
+    def test_empty(self):
+        """Test that an empty snapshot of an empty store has no churn."""
+        result = compare_rows({}, {})
+
+        assert (result["compared"], result["churn_rate"], result["categories"]) == (0, 0.0, [])
+
+
+class TestSnapshotService:
+    """Tests for creating, comparing, restoring, and deleting snapshots."""
+
+    @pytest.mark.asyncio
+    async def test_create_and_compare(self, service, snapshots):
+        """Test that a snapshot holds the user's rows and sees later corrections."""
+        snapshot = await snapshots.create(USER_ID, "before")
+        await service.update_classifications(USER_ID, [("a", "team_action")])
+
+        comparison = await snapshots.compare(snapshot.id, USER_ID)
+
+        assert snapshot.email_count == 4
+        assert (comparison.compared, comparison.recategorized, comparison.added) == (4, 1, 0)
+        assert [(change.email_id, change.before_category, change.after_category) for change in comparison.changes] == [
+            ("a", "fyi", "team_action")
+        ]
+        assert await snapshots.compare(snapshot.id, USER_ID + 1) is None
+
+    @pytest.mark.asyncio
+    async def test_limits(self, snapshots, monkeypatch):
+        """Test that names are unique and size and count limits are enforced."""
+        await snapshots.create(USER_ID, "first")
+        with pytest.raises(ConflictError):
+            await snapshots.create(USER_ID, "first")
+
+        monkeypatch.setattr(settings, "snapshot_max_per_user", 1)
+        with pytest.raises(ConflictError):
+            await snapshots.create(USER_ID, "second")
+
+        monkeypatch.setattr(settings, "snapshot_max_per_user", 5)
+        monkeypatch.setattr(settings, "snapshot_max_emails", 3)
+        with pytest.raises(ValueError):
+            await snapshots.create(USER_ID, "second")
+        assert [snapshot.name for snapshot in await snapshots.list(USER_ID)] == ["first"]
+
+    @pytest.mark.asyncio
+    async def test_restore_changes_and_delete(self, service, snapshots):
+        """Test that restore lists the emails to set back and gone ones, and delete removes the rows."""
+        snapshot = await snapshots.create(USER_ID, "before")
+        await service.update_classifications(USER_ID, [("a", "team_action"), ("c", "fyi")])
+        with service.db.get_connection() as conn:
+            conn.execute("DELETE FROM emails WHERE id = 'd'")
+            conn.commit()
+
+        changes, missing = await snapshots.restore_changes(snapshot.id, USER_ID)
+
+        assert changes == [("a", "fyi"), ("c", "team_action")]
+        assert missing == ["d"]
+        assert await snapshots.delete(snapshot.id, USER_ID)
+        assert not await snapshots.delete(snapshot.id, USER_ID)
+        with service.db.get_connection() as conn:
+            assert conn.execute("SELECT COUNT(*) FROM classification_snapshot_rows").fetchone()[0] == 0
+
+
+class TestSnapshotAPI:
+    """Tests for the /admin/snapshots endpoints."""
+
+    @pytest.fixture
+    def client(self, service, snapshots):
+        """Client with auth and both services overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="admin", email="admin@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_snapshot_service] = lambda: snapshots
+        return TestClient(app)
+
+    def test_snapshot_compare_restore(self, client, service):
+        """Test the create, list, compare, and restore flow."""
+        created = client.post("/api/admin/snapshots", json={"name": "before"})
+        assert created.status_code == 201
+        snapshot_id = created.json()["id"]
+        assert client.post("/api/admin/snapshots", json={"name": "before"}).status_code == 409
+        assert client.get("/api/admin/snapshots").json()["total"] == 1
+
+        asyncio.run(service.update_classifications(USER_ID, [("a", "newsletter")]))
+        comparison = client.get(f"/api/admin/snapshots/{snapshot_id}/compare").json()
+        assert comparison["recategorized"] == 1
+        assert comparison["changes"][0]["email_id"] == "a"
+
+        dry = client.post(f"/api/admin/snapshots/{snapshot_id}/restore", json={"dry_run": True}).json()
+        assert dry["restored"] == ["a"]
+        assert client.get(f"/api/admin/snapshots/{snapshot_id}/compare").json()["recategorized"] == 1
+
+        restored = client.post(f"/api/admin/snapshots/{snapshot_id}/restore", json={}).json()
+        assert (restored["restored"], restored["dry_run"]) == (["a"], False)
+        assert client.get(f"/api/admin/snapshots/{snapshot_id}/compare").json()["recategorized"] == 0
+
+    def test_unknown_snapshot(self, client):
+        """Test that unknown snapshots are 404."""
+        assert client.get("/api/admin/snapshots/99/compare").status_code == 404
+        assert client.post("/api/admin/snapshots/99/restore", json={}).status_code == 404
+        assert client.delete("/api/admin/snapshots/99").status_code == 404
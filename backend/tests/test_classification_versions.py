@@ -0,0 +1,269 @@
+"""Tests for version checks on classification corrections."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+
+@pytest.fixture
+async def service():
+    """Email service with the mock provider's emails stored as fyi."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    for email in provider.mock_emails:
+        await email_service.save_classification(
+            email, EmailClassification(category="fyi", confidence=0.6), USER_ID
+        )
+    yield email_service
+    store.close()
+
+
+@pytest.fixture
+def client(service):
+    """Client with auth and the email service overridden."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="triager", email="triager@example.com",
+        hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: service
+    return TestClient(app)
+
+
+async def reclassify_by_ai(service, email_id, category):
+    """Store a new AI classification, as the processing pipeline would."""
+    email = next(e for e in service.provider.mock_emails if e["id"] == email_id)
+    await service.save_classification(email, EmailClassification(category=category, confidence=0.8), USER_ID)
+
+
+def stored_category(service, email_id):
+    """Read an email's stored category."""
+    with service.db.get_connection() as conn:
+        return conn.execute("SELECT category FROM emails WHERE id = ?", (email_id,)).fetchone()["category"]
+
+
+class TestUpdateClassifications:
+    """Tests for expected_versions in EmailService.update_classifications."""
+
+    @pytest.mark.asyncio
+    async def test_writes_bump_version(self, service):
+        """Test that AI and user classifications each bump the version."""
+        before = (await service.get_classification_state("mock-email-1", USER_ID))["version"]
+
+        await reclassify_by_ai(service, "mock-email-1", "newsletter")
+        await service.update_classifications(USER_ID, [("mock-email-1", "team_action")])
+
+        state = await service.get_classification_state("mock-email-1", USER_ID)
+        assert state["version"] == before + 2
+        assert (state["category"], state["needs_review"]) == ("team_action", False)
+
+    @pytest.mark.asyncio
+    async def test_stale_version_is_not_applied(self, service):
+        """Test that a write made after reading wins over a stale correction."""
+        read = await service.get_classification_state("mock-email-1", USER_ID)
+        await reclassify_by_ai(service, "mock-email-1", "newsletter")
+
+        result = await service.update_classifications(
+            USER_ID, [("mock-email-1", "team_action"), ("mock-email-2", "team_action")],
+            expected_versions={"mock-email-1": read["version"], "mock-email-2": read["version"]}
+        )
+
+        assert result.missing == []
+        assert result.conflicts == {"mock-email-1": {
+            "email_id": "mock-email-1", "category": "newsletter", "confidence": 0.8,
+            "needs_review": False, "version": read["version"] + 1,
+        }}
+        assert stored_category(service, "mock-email-1") == "newsletter"
+        assert stored_category(service, "mock-email-2") == "team_action"
+        assert await service.get_classification_history("mock-email-1", USER_ID) == []
+
+
+class TestClassificationEndpoint:
+    """Tests for GET and PUT /emails/{email_id}/classification."""
+
+    def test_update_with_current_version(self, client):
+        """Test that a correction against the current version applies and bumps it."""
+        read = client.get("/api/emails/mock-email-1/classification").json()
+
+        response = client.put("/api/emails/mock-email-1/classification", json={
+            "category": "newsletter", "version": read["version"]
+        })
+
+        assert response.status_code == 200
+        assert response.json() == {
+            "email_id": "mock-email-1", "category": "newsletter", "confidence": 1.0,
+            "needs_review": False, "version": read["version"] + 1,
+            "outlook_applied": False, "warnings": [],
+        }
+
+    def test_conflict_returns_current_state(self, client, service):
+        """Test that a change made between read and write gives 409 with the current state."""
+        read = client.get("/api/emails/mock-email-1/classification").json()
+        asyncio.run(reclassify_by_ai(service, "mock-email-1", "newsletter"))
+
+        response = client.put("/api/emails/mock-email-1/classification", json={
+            "category": "team_action", "version": read["version"]
+        })
+
+        assert response.status_code == 409
+        error = response.json()["error"]
+        assert error["code"] == "conflict"
+        assert error["current"]["category"] == "newsletter"
+        assert error["current"]["version"] == read["version"] + 1
+        assert stored_category(service, "mock-email-1") == "newsletter"
+
+    def test_without_version_last_write_wins(self, client, service):
+        """Test that corrections without a version are applied unconditionally."""
+        asyncio.run(reclassify_by_ai(service, "mock-email-1", "newsletter"))
+
+        response = client.put("/api/emails/mock-email-1/classification", json={"category": "team_action"})
+
+        assert response.status_code == 200
+        assert stored_category(service, "mock-email-1") == "team_action"
+
+    def test_unknown_email_and_category(self, client):
+        """Test 404 for unknown emails and 422 for unknown categories."""
+        assert client.get("/api/emails/ghost/classification").status_code == 404
+        assert client.put("/api/emails/ghost/classification", json={"category": "fyi"}).status_code == 404
+        assert client.put("/api/emails/mock-email-1/classification", json={"category": "bogus"}).status_code == 422
+
+    def test_stored_list_reports_version(self, client):
+        """Test that stored email responses carry the version."""
+        emails = client.get("/api/emails/search", params={"q": "Test Email"}).json()["emails"]
+
+        assert emails and all(isinstance(email["version"], int) for email in emails)
+
+
+class TestSingleCorrection:
+    """Tests for storing unknown emails and applying to Outlook in update_classification."""
+
+    @pytest.fixture
+    def unstored(self, service):
+        """An email the provider has that was never stored."""
+        email = {**service.provider.mock_emails[0], "id": "mock-email-new", "subject": "Not synced yet"}
+        service.provider.mock_emails.append(email)
+        return email
+
+    @pytest.mark.asyncio
+    async def test_missing_email_stored_from_provider(self, service, unstored):
+        """Test that an email only the provider has is stored and then reclassified."""
+        result = await service.update_classification(USER_ID, "mock-email-new", "team_action")
+
+        assert (result.db_updated, result.missing) == (True, False)
+        assert stored_category(service, "mock-email-new") == "team_action"
+        history = await service.get_classification_history("mock-email-new", USER_ID)
+        assert [(entry["previous_category"], entry["category"]) for entry in history] == [(None, "team_action")]
+
+    @pytest.mark.asyncio
+    async def test_missing_email_provider_unavailable(self, service, unstored, monkeypatch):
+        """Test that an unstored email is missing when the provider cannot be reached."""
+        def unavailable(email_id):
+            raise HTTPException(status_code=503, detail="Outlook not running")
+        monkeypatch.setattr(service.provider, "get_email_content", unavailable)
+
+        result = await service.update_classification(USER_ID, "mock-email-new", "team_action")
+
+        assert (result.db_updated, result.missing) == (False, True)
+        assert await service.get_classification_state("mock-email-new", USER_ID) is None
+
+    @pytest.mark.asyncio
+    async def test_outlook_applied(self, service):
+        """Test that apply_to_outlook applies the category in the mail client."""
+        result = await service.update_classification(
+            USER_ID, "mock-email-1", "team_action", apply_to_outlook=True
+        )
+
+        assert (result.db_updated, result.outlook_applied, result.outlook_error) == (True, True, None)
+        email = next(e for e in service.provider.mock_emails if e["id"] == "mock-email-1")
+        assert email["categories"] == ["team_action"]
+
+    @pytest.mark.asyncio
+    async def test_outlook_failure_reported(self, service, monkeypatch):
+        """Test that an Outlook failure keeps the stored change and is reported, not raised."""
+        def unavailable(email_id, category):
+            raise HTTPException(status_code=502, detail="COM busy")
+        monkeypatch.setattr(service.provider, "categorize_email", unavailable)
+
+        result = await service.update_classification(
+            USER_ID, "mock-email-1", "team_action", apply_to_outlook=True
+        )
+
+        assert (result.db_updated, result.outlook_applied, result.outlook_error) == (True, False, "COM busy")
+        assert stored_category(service, "mock-email-1") == "team_action"
+
+    @pytest.mark.asyncio
+    async def test_outlook_declined_reported(self, service, monkeypatch):
+        """Test that a category the mail client declines is reported."""
+        monkeypatch.setattr(service.provider, "categorize_email", lambda email_id, category: False)
+
+        result = await service.update_classification(
+            USER_ID, "mock-email-1", "team_action", apply_to_outlook=True
+        )
+
+        assert result.outlook_applied is False
+        assert result.outlook_error == "The mail client did not apply the category"
+
+    def test_endpoint_warns_on_outlook_failure(self, client, service, monkeypatch):
+        """Test that the handler returns 200 with a warning when Outlook failed."""
+        monkeypatch.setattr(service.provider, "categorize_email", lambda email_id, category: False)
+
+        response = client.put(
+            "/api/emails/mock-email-1/classification?apply_to_outlook=true", json={"category": "team_action"}
+        )
+
+        assert response.status_code == 200
+        data = response.json()
+        assert (data["category"], data["outlook_applied"]) == ("team_action", False)
+        assert data["warnings"] == [
+            "Category stored but not applied in Outlook: The mail client did not apply the category"
+        ]
+
+    def test_endpoint_outlook_applied(self, client):
+        """Test that a successful Outlook apply has no warnings."""
+        response = client.put(
+            "/api/emails/mock-email-1/classification?apply_to_outlook=true", json={"category": "team_action"}
+        )
+
+        assert response.json()["outlook_applied"] is True
+        assert response.json()["warnings"] == []
+
+
+class TestBatchCorrectionVersions:
+    """Tests for versions in PUT /emails/classifications."""
+
+    def test_stale_items_conflict(self, client, service):
+        """Test that stale items fail with 409 and the rest are applied."""
+        read = client.get("/api/emails/mock-email-1/classification").json()
+        asyncio.run(reclassify_by_ai(service, "mock-email-1", "newsletter"))
+
+        response = client.put("/api/emails/classifications", json={"classifications": [
+            {"email_id": "mock-email-1", "category": "team_action", "version": read["version"]},
+            {"email_id": "mock-email-2", "category": "team_action", "version": read["version"]},
+        ]})
+
+        assert response.status_code == 207
+        data = response.json()
+        assert (data["success_count"], data["failure_count"]) == (1, 1)
+        assert data["errors"][0]["email_id"] == "mock-email-1"
+        assert (data["errors"][0]["status_code"], data["errors"][0]["is_retryable"]) == (409, False)
+        assert [(c["email_id"], c["category"]) for c in data["conflicts"]] == [("mock-email-1", "newsletter")]
+        assert stored_category(service, "mock-email-2") == "team_action"
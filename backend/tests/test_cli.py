@@ -0,0 +1,213 @@
+"""Tests for the command line interface."""
+
+import io
+import json
+from datetime import datetime, timedelta
+from unittest.mock import AsyncMock, MagicMock
+
+import pytest
+
+import backend.cli
+from backend.cli import (
+    EXIT_AUTH, EXIT_FAILURE, EXIT_OK, EXIT_USAGE, CLIContext, build_parser, cmd_tasks_list, format_table, main
+)
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager
+from backend.database.recovery import check_integrity
+from backend.models.task import TaskCreate
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.task_service import TaskService
+
+
+def add_user(store, username):
+    """Insert an active user and return its ID."""
+    with store.get_connection() as conn:
+        user_id = conn.execute(
+            "INSERT INTO users (username, email, hashed_password, is_active) VALUES (?, ?, ?, ?)",
+            (username, f"{username}@example.com", "x", True)
+        ).lastrowid
+        conn.commit()
+    return user_id
+
+
+@pytest.fixture
+def store():
+    """In-memory store with one user."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    add_user(store, "alice")
+    yield store
+    store.close()
+
+
+@pytest.fixture
+def stub_ai():
+    """Stub AI service classifying everything as fyi."""
+    ai = MagicMock()
+    ai.classify_email_async = AsyncMock(return_value={"category": "fyi", "confidence": 0.9, "reasoning": "Stub"})
+    return ai
+
+
+@pytest.fixture
+def context(store, stub_ai):
+    """Context over the store, the mock mailbox, and the stub AI, capturing output."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return CLIContext(
+        db=store,
+        email_service=EmailService(provider, db=store),
+        task_service=TaskService(db=store),
+        ai_service=stub_ai,
+        out=io.StringIO(),
+        err=io.StringIO()
+    )
+
+
+def run(context, *argv):
+    """Run a command and return its exit code and output."""
+    code = main(list(argv), context)
+    return code, context.out.getvalue()
+
+
+class TestParser:
+    """Tests for argument parsing and output formatting."""
+
+    def test_subcommand_flags(self):
+        """Test that each subcommand parses its own flags."""
+        args = build_parser().parse_args(["classify", "Inbox", "--limit", "5", "--json"])
+        assert (args.folder, args.limit, args.json, args.all) == ("Inbox", 5, True, False)
+
+        args = build_parser().parse_args(["tasks", "list", "--overdue", "--user", "bob"])
+        assert (args.overdue, args.user, args.status) == (True, "bob", None)
+
+    def test_usage_errors(self, context):
+        """Test that unknown commands and bad flags exit with EXIT_USAGE."""
+        assert main(["purge"], context) == EXIT_USAGE
+        assert main(["classify", "Inbox", "--limit", "0"], context) == EXIT_USAGE
+        assert main(["stats", "--overdue"], context) == EXIT_USAGE
+
+    def test_format_table(self):
+        """Test that columns are padded to their widest cell."""
+        assert format_table(["ID", "TITLE"], [(1, "Report"), (22, None)]).splitlines() == [
+            "ID  TITLE", "--  ------", "1   Report", "22",
+        ]
+
+
+class TestCommands:
+    """Tests for the subcommands over in-memory stores and stub services."""
+
+    def test_classify_stores_results(self, context, stub_ai):
+        """Test that classify stores each email's category and skips them on a second run."""
+        code, output = run(context, "classify", "Inbox", "--json")
+
+        result = json.loads(output)
+        assert code == EXIT_OK
+        assert [item["category"] for item in result["classified"]] == ["fyi", "fyi"]
+        assert stub_ai.classify_email_async.await_count == 2
+        with context.db.get_connection() as conn:
+            assert conn.execute("SELECT COUNT(*) FROM emails WHERE category = 'fyi'").fetchone()[0] == 2
+
+        context.out = io.StringIO()
+        code, output = run(context, "classify", "Inbox")
+        assert code == EXIT_OK
+        assert "Classified 0 of 0 emails in Inbox (2 already processed" in output
+
+    def test_classify_failure_exit_code(self, context, stub_ai):
+        """Test that an AI failure is reported and exits with EXIT_FAILURE."""
+        stub_ai.classify_email_async = AsyncMock(return_value={"error": "quota exceeded"})
+
+        code, _ = run(context, "classify", "Inbox")
+
+        assert code == EXIT_FAILURE
+        assert "quota exceeded" in context.err.getvalue()
+
+    def test_read_only_refuses_changes(self, context, stub_ai, monkeypatch):
+        """Test that classify and sync are refused in read-only mode."""
+        monkeypatch.setattr(settings, "read_only", True)
+
+        assert run(context, "classify", "Inbox")[0] == EXIT_FAILURE
+        assert run(context, "sync", "Inbox")[0] == EXIT_FAILURE
+        assert "read-only" in context.err.getvalue()
+        stub_ai.classify_email_async.assert_not_awaited()
+
+    def test_sync(self, context):
+        """Test that sync stores new emails once."""
+        code, output = run(context, "sync", "Inbox", "--json")
+        assert code == EXIT_OK
+        assert (json.loads(output)["fetched"], json.loads(output)["new"]) == (2, 2)
+
+        context.out = io.StringIO()
+        _, output = run(context, "sync", "Inbox", "--json")
+        assert (json.loads(output)["new"], json.loads(output)["removed"]) == (0, 0)
+
+    @pytest.mark.asyncio
+    async def test_tasks_list_overdue(self, context, store):
+        """Test that --overdue lists only open tasks past their due date."""
+        user_id = 1
+        now = datetime.now()
+        await context.task_service.create_task(TaskCreate(title="Late", due_date=now - timedelta(days=2)), user_id)
+        await context.task_service.create_task(TaskCreate(title="Later", due_date=now + timedelta(days=2)), user_id)
+        await context.task_service.create_task(TaskCreate(title="Undated"), user_id)
+
+        args = build_parser().parse_args(["tasks", "list", "--overdue"])
+        assert await cmd_tasks_list(args, context) == EXIT_OK
+
+        lines = context.out.getvalue().splitlines()
+        assert [line.split()[-1] for line in lines[2:-2]] == ["Late"]
+        assert lines[-1] == "1 of 1 overdue tasks"
+
+    def test_stats_json(self, context):
+        """Test that stats reports stored counts without the mailbox."""
+        run(context, "sync", "Inbox")
+        context.out = io.StringIO()
+        context._email_service = None
+
+        code, output = run(context, "stats", "--json")
+
+        result = json.loads(output)
+        assert code == EXIT_OK
+        assert result["emails"]["by_folder"]["Inbox"] == {"total": 2, "unread": 1}
+        assert result["tasks"] == {"overdue": 0, "due_today": 0}
+        assert result["blocking_others"] == 0
+
+    def test_backup(self, context, tmp_path):
+        """Test that backup writes an intact copy."""
+        target = tmp_path / "copy.db"
+
+        code, output = run(context, "backup", "--output", str(target), "--json")
+
+        assert code == EXIT_OK
+        assert json.loads(output)["intact"]
+        assert check_integrity(str(target)) is None
+
+    def test_backup_needs_path_for_memory_store(self, context):
+        """Test that an in-memory store without --output fails."""
+        assert run(context, "backup")[0] == EXIT_FAILURE
+
+
+class TestUsers:
+    """Tests for choosing the user without prompting."""
+
+    def test_unknown_user(self, context):
+        """Test that an unknown --user exits with EXIT_AUTH."""
+        assert run(context, "stats", "--user", "mallory")[0] == EXIT_AUTH
+        assert "mallory" in context.err.getvalue()
+
+    def test_ambiguous_user(self, context, store, monkeypatch):
+        """Test that several users need --user or cli_username."""
+        add_user(store, "bob")
+        monkeypatch.setattr(settings, "cli_username", None)
+        assert run(context, "stats")[0] == EXIT_AUTH
+
+        monkeypatch.setattr(settings, "cli_username", "bob")
+        assert run(context, "stats")[0] == EXIT_OK
+
+    def test_mailbox_sign_in_failure(self, store, monkeypatch):
+        """Test that a mailbox that cannot sign in exits with EXIT_AUTH."""
+        provider = MagicMock(authenticated=False)
+        provider.authenticate.return_value = False
+        monkeypatch.setattr(backend.cli, "get_email_provider", lambda: provider)
+        context = CLIContext(db=store, out=io.StringIO(), err=io.StringIO())
+
+        assert run(context, "sync", "Inbox")[0] == EXIT_AUTH
+        assert "signed in" in context.err.getvalue()
@@ -8,6 +8,8 @@ email processing.
 import pytest
 from unittest.mock import patch, MagicMock, AsyncMock, Mock
 import json
+from datetime import datetime
+from backend.core.prompts import PromptLibrary
 from backend.services.com_ai_service import COMAIService, get_com_ai_service
 
 
@@ -125,6 +127,31 @@ class TestCOMAIService:
         assert result["confidence"] == 0.8
         assert "Email classified successfully" in result["reasoning"]
         assert result["requires_review"] is True
+        assert result["importance_score"] == 3
+    
+    @patch('backend.services.com_ai_service.AIProcessor')
+    @patch('backend.services.com_ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_classify_email_importance_score(self, mock_config, mock_processor, com_ai_service):
+        """Test that out-of-range importance scores are clamped."""
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_config.return_value = MagicMock()
+        mock_ai_instance.CONFIDENCE_THRESHOLDS = {}
+        
+        mock_ai_instance.classify_email_with_explanation.return_value = {
+            "category": "required_personal_action",
+            "explanation": "Manager asked for the report",
+            "importance_score": 7,
+            "importance_justification": "Manager request due today"
+        }
+        
+        result = await com_ai_service.classify_email(
+            email_content="Subject: Report\n\nNeed it today"
+        )
+        
+        assert result["importance_score"] == 5
+        assert result["importance_justification"] == "Manager request due today"
     
     @patch('backend.services.com_ai_service.AIProcessor')
     @patch('backend.services.com_ai_service.get_azure_config')
@@ -362,48 +389,29 @@ class TestCOMAIService:
         assert len(result) == 0
     
     @pytest.mark.asyncio
-    async def test_get_available_templates(self, com_ai_service):
+    async def test_get_available_templates(self, com_ai_service, tmp_path):
         """Test getting available prompty templates."""
-        # Mock the prompts directory
-        with patch('pathlib.Path.exists') as mock_exists, \
-             patch('pathlib.Path.glob') as mock_glob:
-            
-            mock_exists.return_value = True
-            
-            # Mock template files
-            mock_template_files = [
-                Mock(name="email_classifier.prompty"),
-                Mock(name="action_item.prompty")
-            ]
-            mock_template_files[0].name = "email_classifier.prompty"
-            mock_template_files[1].name = "action_item.prompty"
-            mock_glob.return_value = mock_template_files
-            
-            # Mock file reading
-            template_content = """---
-description: Email classification template
----
-Template content here"""
-            
-            with patch('builtins.open', create=True) as mock_open:
-                mock_open.return_value.__enter__.return_value.read.return_value = template_content
-                
-                result = await com_ai_service.get_available_templates()
-            
-            assert len(result["templates"]) == 2
-            assert "email_classifier.prompty" in result["templates"]
-            assert "action_item.prompty" in result["templates"]
+        (tmp_path / "email_classifier.prompty").write_text(
+            "---\ndescription: Email classification template\n---\nTemplate content here"
+        )
+        (tmp_path / "action_item.prompty").write_text("---\nname: Action items\n---\nTemplate content here")
+        
+        with patch('backend.services.com_ai_service.prompt_library', PromptLibrary(tmp_path)):
+            result = await com_ai_service.get_available_templates()
+        
+        assert len(result["templates"]) == 2
+        assert "email_classifier.prompty" in result["templates"]
+        assert "action_item.prompty" in result["templates"]
+        assert result["descriptions"]["email_classifier.prompty"] == "Email classification template"
     
     @pytest.mark.asyncio
-    async def test_get_available_templates_no_directory(self, com_ai_service):
+    async def test_get_available_templates_no_directory(self, com_ai_service, tmp_path):
         """Test getting templates when directory doesn't exist."""
-        with patch('pathlib.Path.exists') as mock_exists:
-            mock_exists.return_value = False
-            
+        with patch('backend.services.com_ai_service.prompt_library', PromptLibrary(tmp_path / "missing")):
             result = await com_ai_service.get_available_templates()
-            
-            assert len(result["templates"]) == 0
-            assert len(result["descriptions"]) == 0
+        
+        assert len(result["templates"]) == 0
+        assert len(result["descriptions"]) == 0
     
     def test_get_com_ai_service_dependency(self):
         """Test FastAPI dependency function."""
@@ -483,6 +491,41 @@ class TestCOMAIServiceEdgeCases:
         assert result["action_required"] == "Submit proposal"
         assert result["due_date"] == "2024-02-01"
         assert len(result["action_items"]) == 1
+        assert result["action_items"][0]["description"] == "Submit proposal"
+        assert result["action_items"][0]["owner"] == "me"
+        assert result["action_items"][0]["links"] == ["http://project.com"]
+    
+    @patch('backend.services.com_ai_service.AIProcessor')
+    @patch('backend.services.com_ai_service.get_azure_config')
+    @pytest.mark.asyncio
+    async def test_extract_action_items_structured(self, mock_config, mock_processor, com_ai_service):
+        """Test that the action_items array is returned as structured items."""
+        mock_ai_instance = MagicMock()
+        mock_processor.return_value = mock_ai_instance
+        mock_config.return_value = MagicMock()
+        mock_ai_instance.execute_prompty.return_value = {
+            "due_date": "2024-02-01",
+            "action_required": "Submit proposal",
+            "explanation": "Proposal due date approaching",
+            "relevance": "Project requirement",
+            "links": [],
+            "action_items": [
+                {"description": "Submit proposal", "owner": "me", "deadline": "2024-02-01T17:00:00",
+                 "source_quote": "Submit by Feb 1", "links": []},
+                {"description": "Approve budget", "owner": "finance", "deadline": None,
+                 "source_quote": "Finance will approve the budget", "links": []},
+                {"description": "  ", "owner": "me"}
+            ]
+        }
+        
+        result = await com_ai_service.extract_action_items(
+            email_content="Subject: Proposal\n\nSubmit by Feb 1"
+        )
+        
+        assert [(item["description"], item["owner"], item["deadline"]) for item in result["action_items"]] == [
+            ("Submit proposal", "me", datetime(2024, 2, 1, 17, 0)),
+            ("Approve budget", "other", None),
+        ]
     
     @patch('backend.services.com_ai_service.AIProcessor')
     @patch('backend.services.com_ai_service.get_azure_config')
@@ -162,6 +162,10 @@ class TestCOMEmailProviderOperations:
         ])
         
         adapter_instance.get_email_body = Mock(return_value="Full email body content")
+        adapter_instance.get_privacy_properties = Mock(return_value={
+            'requests_read_receipt': True,
+            'html_body': '<p>Full email body content</p>'
+        })
         adapter_instance.get_folders = Mock(return_value=[
             {'id': 'inbox', 'name': 'Inbox', 'type': 'mail'},
             {'id': 'sent', 'name': 'Sent Items', 'type': 'mail'}
@@ -229,6 +233,8 @@ class TestCOMEmailProviderOperations:
         assert content is not None
         assert content['id'] == 'email1'
         assert 'body' in content
+        assert content['requests_read_receipt'] is True
+        assert content['html_body'] == '<p>Full email body content</p>'
         mock_adapter.get_email_body.assert_called_once_with("email1")
     
     def test_get_folders(self, authenticated_provider):
@@ -249,7 +255,15 @@ class TestCOMEmailProviderOperations:
         result = provider.mark_as_read("email1")
         
         assert result is True
-        mock_adapter.mark_as_read.assert_called_once_with("email1")
+        mock_adapter.mark_as_read.assert_called_once_with("email1", suppress_read_receipt=False)
+    
+    def test_mark_as_read_suppressing_receipt(self, authenticated_provider):
+        """Test that read receipt suppression is passed to the adapter."""
+        provider, mock_adapter = authenticated_provider
+        
+        provider.mark_as_read("email1", suppress_read_receipt=True)
+        
+        mock_adapter.mark_as_read.assert_called_once_with("email1", suppress_read_receipt=True)
     
     def test_mark_as_read_failure(self, authenticated_provider):
         """Test marking email as read failure."""
@@ -278,6 +292,36 @@ class TestCOMEmailProviderOperations:
         
         assert result is False
     
+    def test_get_emails_by_ids(self, authenticated_provider):
+        """Test bulk lookup goes through the adapter in one call."""
+        provider, mock_adapter = authenticated_provider
+        mock_adapter.get_emails_by_ids.return_value = {"email1": {"id": "email1", "is_read": True}}
+        
+        found = provider.get_emails_by_ids(["email1", "gone"])
+        
+        assert found == {"email1": {"id": "email1", "is_read": True, "is_protected": False}}
+        mock_adapter.get_emails_by_ids.assert_called_once_with(["email1", "gone"])
+    
+    def test_create_draft_reply(self, authenticated_provider):
+        """Test saving a draft reply through the adapter."""
+        provider, mock_adapter = authenticated_provider
+        mock_adapter.create_draft_reply.return_value = "draft1"
+        
+        draft_id = provider.create_draft_reply("email1", "Re: Budget", "Confirmed.")
+        
+        assert draft_id == "draft1"
+        mock_adapter.create_draft_reply.assert_called_once_with("email1", "Re: Budget", "Confirmed.")
+    
+    def test_categorize_email(self, authenticated_provider):
+        """Test applying a category through the adapter."""
+        provider, mock_adapter = authenticated_provider
+        mock_adapter.categorize_email.return_value = True
+        
+        result = provider.categorize_email("email1", "newsletter")
+        
+        assert result is True
+        mock_adapter.categorize_email.assert_called_once_with("email1", "newsletter")
+    
     def test_get_conversation_thread(self, authenticated_provider):
         """Test retrieving conversation thread."""
         provider, mock_adapter = authenticated_provider
@@ -289,6 +333,100 @@ class TestCOMEmailProviderOperations:
         assert thread[0]['id'] == 'email1'
 
 
+class TestCOMEmailProviderFolderCache:
+    """Test caching and invalidation of the folder list."""
+    
+    @pytest.fixture
+    def provider(self):
+        """Authenticated COM provider whose adapter lists one folder."""
+        adapter_instance = Mock()
+        adapter_instance.connect = Mock(return_value=True)
+        adapter_instance.get_folders = Mock(return_value=[
+            {'id': 'inbox', 'name': 'Inbox', 'path': 'Inbox', 'type': 'mail',
+             'unread_count': 3, 'total_count': 10}
+        ])
+        adapter_instance.move_email = Mock(return_value=True)
+        adapter_instance.categorize_email = Mock(return_value=True)
+        adapter_instance.mark_as_read = Mock(return_value=True)
+        adapter_class = Mock(return_value=adapter_instance)
+        
+        with patch('backend.services.com_email_provider.OutlookEmailAdapter', adapter_class):
+            with patch('backend.services.com_email_provider.COM_AVAILABLE', True):
+                from backend.services.com_email_provider import COMEmailProvider
+                provider = COMEmailProvider()
+                provider.adapter = adapter_instance
+                provider.authenticate({})
+                return provider
+    
+    def test_second_listing_is_cached(self, provider):
+        """Test that a repeat listing within the TTL does not walk Outlook again."""
+        first = provider.get_folders()
+        second = provider.get_folders()
+        
+        assert first == second
+        assert first[0]['unread_count'] == 3
+        provider.adapter.get_folders.assert_called_once()
+        assert 0 <= provider.folder_cache_age() < 30
+    
+    def test_cache_expires(self, provider):
+        """Test that the folder list is re-read once the TTL has passed."""
+        with patch('backend.services.com_email_provider.time.monotonic', return_value=1000.0):
+            provider.get_folders()
+        
+        with patch('backend.services.com_email_provider.time.monotonic', return_value=1029.0):
+            assert provider.folder_cache_age() == 29.0
+            provider.get_folders()
+        assert provider.adapter.get_folders.call_count == 1
+        
+        with patch('backend.services.com_email_provider.time.monotonic', return_value=1030.0):
+            assert provider.folder_cache_age() is None
+            provider.get_folders()
+        assert provider.adapter.get_folders.call_count == 2
+    
+    @pytest.mark.parametrize("operation", [
+        lambda p: p.move_email("email1", "Archive"),
+        lambda p: p.categorize_email("email1", "newsletter"),
+        lambda p: p.mark_as_read("email1"),
+    ])
+    def test_changes_invalidate_cache(self, provider, operation):
+        """Test that moving, categorizing, or reading an email clears the cache."""
+        provider.get_folders()
+        
+        operation(provider)
+        
+        assert provider.folder_cache_age() is None
+        provider.get_folders()
+        assert provider.adapter.get_folders.call_count == 2
+    
+    def test_failed_move_keeps_cache(self, provider):
+        """Test that a move that did not happen leaves the cache alone."""
+        provider.adapter.move_email.return_value = False
+        provider.get_folders()
+        
+        provider.move_email("email1", "Archive")
+        
+        assert provider.folder_cache_age() is not None
+    
+    def test_cache_disabled(self, provider):
+        """Test that folder_cache_seconds=0 reads Outlook every time."""
+        with patch('backend.services.com_email_provider.settings') as mock_settings:
+            mock_settings.folder_cache_seconds = 0
+            provider.get_folders()
+            provider.get_folders()
+            assert provider.folder_cache_age() is None
+        
+        assert provider.adapter.get_folders.call_count == 2
+    
+    def test_errors_are_not_cached(self, provider):
+        """Test that a failed listing is retried on the next call."""
+        provider.adapter.get_folders.side_effect = [Exception("COM busy"), []]
+        
+        with pytest.raises(HTTPException):
+            provider.get_folders()
+        
+        assert provider.get_folders() == []
+
+
 class TestCOMEmailProviderErrorHandling:
     """Test error handling and edge cases."""
     
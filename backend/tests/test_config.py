@@ -0,0 +1,359 @@
+"""Tests for settings validation and the config introspection endpoint."""
+
+import logging
+from pathlib import Path
+
+import pytest
+from fastapi.testclient import TestClient
+
+from backend.core.config import (
+    CONFIG_FILE_ENV, ConfigFileError, ConfigValidationError, Settings,
+    get_settings, load_config_file
+)
+from backend.main import app
+
+
+@pytest.fixture
+def prompts_dir(tmp_path):
+    """Create an empty prompts directory."""
+    path = tmp_path / "prompts"
+    path.mkdir()
+    return path
+
+
+def make_settings(prompts_dir, **overrides):
+    """Build settings that pass validation unless overridden."""
+    values = {"prompts_dir": str(prompts_dir), "secret_key": "test-secret"}
+    values.update(overrides)
+    return Settings(**values)
+
+
+class TestSettingsValidation:
+    """Tests for Settings.validate_config rules."""
+
+    def test_valid_settings(self, prompts_dir):
+        """Test that default settings with a prompts directory validate cleanly."""
+        assert make_settings(prompts_dir).validate_config() == []
+
+    @pytest.mark.parametrize("port", [0, -1, 65536, 99999])
+    def test_port_out_of_range(self, prompts_dir, port):
+        """Test that ports outside 1-65535 are rejected."""
+        with pytest.raises(ConfigValidationError, match="port"):
+            make_settings(prompts_dir, port=port).validate_config()
+
+    def test_missing_prompts_dir(self, tmp_path):
+        """Test that an explicit prompts directory must exist."""
+        settings = make_settings(tmp_path / "missing")
+
+        with pytest.raises(ConfigValidationError, match="prompts_dir"):
+            settings.validate_config()
+
+    def test_endpoint_requires_deployment(self, prompts_dir):
+        """Test that an Azure endpoint without a deployment is rejected."""
+        settings = make_settings(
+            prompts_dir,
+            azure_openai_endpoint="https://example.openai.azure.com",
+            azure_openai_deployment=""
+        )
+
+        with pytest.raises(ConfigValidationError, match="azure_openai_deployment"):
+            settings.validate_config()
+
+    def test_api_key_requires_endpoint(self, prompts_dir):
+        """Test that an Azure API key without an endpoint is rejected."""
+        settings = make_settings(prompts_dir, azure_openai_api_key="key")
+
+        with pytest.raises(ConfigValidationError, match="azure_openai_endpoint"):
+            settings.validate_config()
+
+    def test_partial_graph_credentials(self, prompts_dir):
+        """Test that Graph credentials must be configured together."""
+        settings = make_settings(prompts_dir, graph_client_id="client")
+
+        with pytest.raises(ConfigValidationError, match="graph_client_id"):
+            settings.validate_config()
+
+    def test_ado_token_requires_organization(self, prompts_dir):
+        """Test that an ADO token without an organization is rejected."""
+        settings = make_settings(prompts_dir, ado_personal_access_token="pat")
+
+        with pytest.raises(ConfigValidationError, match="ado_organization"):
+            settings.validate_config()
+
+    def test_non_positive_timeouts(self, prompts_dir):
+        """Test that timeouts and token lifetimes must be positive."""
+        settings = make_settings(
+            prompts_dir,
+            com_connection_timeout=0,
+            access_token_expire_minutes=0,
+            com_retry_attempts=-1
+        )
+
+        with pytest.raises(ConfigValidationError) as exc_info:
+            settings.validate_config()
+
+        assert len(exc_info.value.problems) == 3
+
+    def test_reputation_weights(self, prompts_dir):
+        """Test that reputation weights must be non-negative and not all zero."""
+        with pytest.raises(ConfigValidationError, match="reputation_weight_spam"):
+            make_settings(prompts_dir, reputation_weight_spam=-1).validate_config()
+        
+        with pytest.raises(ConfigValidationError, match="reputation_weight"):
+            make_settings(
+                prompts_dir,
+                reputation_weight_spam=0, reputation_weight_tasks=0,
+                reputation_weight_engagement=0, reputation_weight_volume=0
+            ).validate_config()
+
+    def test_classification_strategy(self, prompts_dir):
+        """Test that the strategy must be known and the triage threshold a probability."""
+        assert make_settings(prompts_dir, classification_strategy="two_stage").validate_config() == []
+
+        with pytest.raises(ConfigValidationError) as exc_info:
+            make_settings(
+                prompts_dir, classification_strategy="triage", triage_confidence_threshold=1.5
+            ).validate_config()
+
+        problems = "\n".join(exc_info.value.problems)
+        assert "classification_strategy must be one of single, two_stage" in problems
+        assert "triage_confidence_threshold" in problems
+
+    def test_archive_aged(self, prompts_dir):
+        """Test that aged email archiving needs a folder, a positive age, and a non-negative interval."""
+        with pytest.raises(ConfigValidationError) as exc_info:
+            make_settings(
+                prompts_dir, archive_folder=" ", archive_aged_days=0, archive_aged_interval_seconds=-1
+            ).validate_config()
+
+        problems = "\n".join(exc_info.value.problems)
+        assert "archive_folder" in problems
+        assert "archive_aged_days" in problems
+        assert "archive_aged_interval_seconds" in problems
+
+    def test_task_defaults(self, prompts_dir):
+        """Test that task defaults need known fields, priorities, offsets, and holiday dates."""
+        valid = {"required_personal_action": {"priority": "high", "due_in_business_days": 2}}
+        assert make_settings(prompts_dir, task_defaults=valid, task_holidays=["2024-12-25"]).validate_config() == []
+
+        settings = make_settings(
+            prompts_dir,
+            task_defaults={
+                "team_action": {"priority": "asap", "due_in_business_days": -1},
+                "fyi": {"due_days": 3},
+            },
+            task_holidays=["25/12/2024"]
+        )
+
+        with pytest.raises(ConfigValidationError) as exc_info:
+            settings.validate_config()
+
+        problems = "\n".join(exc_info.value.problems)
+        assert "task_defaults.team_action.priority" in problems
+        assert "task_defaults.team_action.due_in_business_days" in problems
+        assert "task_defaults.fyi has unknown fields: due_days" in problems
+        assert "25/12/2024" in problems
+    
+    def test_ai_parameters(self, prompts_dir):
+        """Test that AI parameter overrides need known fields within their ranges."""
+        valid = {
+            "email_classifier_with_explanation": {"temperature": 0.1},
+            "draft_reply": {"temperature": 1, "max_tokens": 800},
+            "email_one_line_summary": {"max_tokens": None},
+        }
+        assert make_settings(prompts_dir, ai_parameters=valid).validate_config() == []
+
+        settings = make_settings(
+            prompts_dir,
+            ai_parameters={
+                "email_classifier_with_explanation": {"temperature": 2.5, "max_tokens": 0},
+                "draft_reply": {"max_tokens": 400.5, "top_p": 0.9},
+                "fyi_summary": {"temperature": True},
+            }
+        )
+
+        with pytest.raises(ConfigValidationError) as exc_info:
+            settings.validate_config()
+
+        problems = "\n".join(exc_info.value.problems)
+        assert "ai_parameters.email_classifier_with_explanation.temperature must be a number from 0.0" in problems
+        assert "ai_parameters.email_classifier_with_explanation.max_tokens must be an integer" in problems
+        assert "ai_parameters.draft_reply.max_tokens" in problems
+        assert "ai_parameters.draft_reply has unknown fields: top_p" in problems
+        assert "ai_parameters.fyi_summary.temperature" in problems
+    
+    def test_default_secret_key_warns(self, prompts_dir):
+        """Test that the built-in secret key is a warning, not an error."""
+        settings = Settings(prompts_dir=str(prompts_dir))
+
+        warnings = settings.validate_config()
+
+        assert any("secret_key" in warning for warning in warnings)
+
+    def test_masked_values_hide_secrets(self, prompts_dir):
+        """Test that secrets are masked in effective values."""
+        settings = make_settings(
+            prompts_dir,
+            azure_openai_endpoint="https://example.openai.azure.com",
+            azure_openai_api_key="super-secret"
+        )
+
+        values = settings.masked_values()
+
+        assert values["azure_openai_api_key"] == "********"
+        assert values["secret_key"] == "********"
+        assert values["azure_openai_endpoint"] == "https://example.openai.azure.com"
+
+    def test_log_effective_config_masks_secrets(self, prompts_dir, caplog):
+        """Test that startup logging never includes secret values."""
+        settings = make_settings(prompts_dir, graph_client_secret=None)
+
+        with caplog.at_level(logging.INFO, logger="backend.core.config"):
+            settings.log_effective_config()
+
+        assert "test-secret" not in caplog.text
+        assert "config port=8000" in caplog.text
+
+
+class TestConfigFileLoading:
+    """Tests for settings file loading and source precedence."""
+
+    @pytest.fixture
+    def config_file(self, tmp_path, monkeypatch):
+        """Point EMAIL_HELPER_CONFIG at a file in a clean environment."""
+        path = tmp_path / "config.yaml"
+        monkeypatch.setenv(CONFIG_FILE_ENV, str(path))
+        for name in ("PORT", "DEBUG", "APP_NAME"):
+            monkeypatch.delenv(name, raising=False)
+        monkeypatch.chdir(tmp_path)
+        return path
+
+    def test_file_overrides_defaults(self, config_file):
+        """Test that file values replace built-in defaults."""
+        config_file.write_text("port: 9001\ndebug: true\n")
+
+        settings = Settings()
+
+        assert settings.port == 9001
+        assert settings.debug is True
+
+    def test_env_overrides_file(self, config_file, monkeypatch):
+        """Test that environment variables win over the file."""
+        config_file.write_text("port: 9001\napp_name: From File\n")
+        monkeypatch.setenv("PORT", "9002")
+
+        settings = Settings()
+
+        assert settings.port == 9002
+        assert settings.app_name == "From File"
+
+    def test_dotenv_overrides_file(self, config_file, tmp_path):
+        """Test that a local .env file wins over the settings file."""
+        config_file.write_text("port: 9001\n")
+        (tmp_path / ".env").write_text("PORT=9003\n")
+
+        assert Settings().port == 9003
+
+    def test_json_file(self, tmp_path, monkeypatch):
+        """Test that JSON settings files are supported."""
+        path = tmp_path / "config.json"
+        path.write_text('{"port": 9004}')
+        monkeypatch.setenv(CONFIG_FILE_ENV, str(path))
+        monkeypatch.delenv("PORT", raising=False)
+
+        assert Settings().port == 9004
+
+    def test_keys_are_case_insensitive(self, config_file):
+        """Test that file keys match fields regardless of case."""
+        config_file.write_text("PORT: 9005\n")
+
+        assert load_config_file(Settings, config_file) == {"port": 9005}
+
+    def test_unknown_key_is_named(self, config_file):
+        """Test that unknown keys produce an error naming the key."""
+        config_file.write_text("prot: 9001\n")
+
+        with pytest.raises(ConfigFileError, match="unknown setting 'prot'") as exc_info:
+            load_config_file(Settings, config_file)
+
+        assert exc_info.value.key == "prot"
+
+    def test_secret_key_rejected(self, config_file):
+        """Test that secrets cannot be set in the settings file."""
+        config_file.write_text("azure_openai_api_key: abc\n")
+
+        with pytest.raises(ConfigFileError, match="azure_openai_api_key") as exc_info:
+            load_config_file(Settings, config_file)
+
+        assert "environment" in str(exc_info.value)
+
+    def test_wrong_type_is_named(self, config_file):
+        """Test that type mismatches name the key and expected type."""
+        config_file.write_text("port: eighty\n")
+
+        with pytest.raises(ConfigFileError, match="'port' must be int"):
+            load_config_file(Settings, config_file)
+
+    def test_malformed_yaml_reports_line(self, config_file):
+        """Test that YAML syntax errors report the line number."""
+        config_file.write_text("port: 9001\ndebug: [true\n")
+
+        with pytest.raises(ConfigFileError, match="line"):
+            load_config_file(Settings, config_file)
+
+    def test_non_mapping_rejected(self, config_file):
+        """Test that a top-level list is rejected."""
+        config_file.write_text("- port\n")
+
+        with pytest.raises(ConfigFileError, match="mapping"):
+            load_config_file(Settings, config_file)
+
+    def test_missing_configured_file(self, tmp_path, monkeypatch):
+        """Test that a missing EMAIL_HELPER_CONFIG file is an error."""
+        monkeypatch.setenv(CONFIG_FILE_ENV, str(tmp_path / "missing.yaml"))
+
+        with pytest.raises(ConfigFileError, match=CONFIG_FILE_ENV):
+            Settings()
+
+    def test_generated_sample_is_loadable(self):
+        """Test that the generated sample file passes file validation."""
+        sample = Path(__file__).parent.parent.parent / "config.sample.yaml"
+
+        values = load_config_file(Settings, sample)
+
+        assert values["port"] == 8000
+        assert "secret_key" not in values
+
+
+class TestConfigEndpoint:
+    """Tests for GET /api/config."""
+
+    @pytest.fixture
+    def client(self, prompts_dir):
+        """Create a client with overridden settings."""
+        app.dependency_overrides[get_settings] = lambda: make_settings(
+            prompts_dir,
+            use_com_backend=True,
+            azure_openai_endpoint="https://example.openai.azure.com",
+            azure_openai_api_key="super-secret"
+        )
+        yield TestClient(app)
+        app.dependency_overrides.clear()
+
+    def test_get_config(self, client):
+        """Test that feature flags reflect settings."""
+        response = client.get("/api/config")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["provider"] == "com"
+        assert data["com_backend"] is True
+        assert data["ai_configured"] is True
+        assert data["ado_configured"] is False
+
+    def test_get_config_excludes_secrets(self, client):
+        """Test that no secret values are exposed."""
+        response = client.get("/api/config")
+
+        assert "super-secret" not in response.text
+        assert "test-secret" not in response.text
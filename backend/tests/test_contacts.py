@@ -0,0 +1,203 @@
+"""Tests for contact profiles built from sent mail."""
+
+import asyncio
+from datetime import datetime, timedelta
+from unittest.mock import AsyncMock, MagicMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.contacts import router
+from backend.core.config import settings
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.ai_service import AIService
+from backend.services.contacts import (
+    SENT_FOLDER, UNKNOWN_CONTACT_HINT, ContactStore, aggregate_sent, get_contact_store,
+    relationship_hint
+)
+from backend.services.email_service import EmailService
+from backend.services.thread_classifier import classify_batch
+
+NOW = datetime(2024, 3, 10, 12, 0)
+
+
+def sent(email_id, days_ago, to, cc=None):
+    """Build a Sent Items email sent days_ago days before NOW."""
+    return {
+        "id": email_id,
+        "subject": f"Sent {email_id}",
+        "to": to,
+        "cc": cc or [],
+        "received_time": (NOW - timedelta(days=days_ago)).isoformat(),
+    }
+
+
+class SentProvider:
+    """Provider whose Sent Items holds the given emails, newest first."""
+
+    def __init__(self, emails):
+        self.sent = list(emails)
+        self.reads = 0
+
+    def get_emails(self, folder_name="Inbox", count=50, offset=0):
+        assert folder_name == SENT_FOLDER
+        self.reads += 1
+        return self.sent[offset:offset + count]
+
+
+@pytest.fixture
+def store():
+    """Contact store over an isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield ContactStore(db)
+    db.close()
+
+
+class TestAggregation:
+    """Tests for counting sent emails per recipient."""
+
+    def test_counts_each_recipient_once_per_email(self):
+        """Test that To and Cc are counted, duplicates once, and the owner skipped."""
+        emails = [
+            sent("s1", 5, ["Alice Smith <Alice@Example.com>", "bob@example.com"],
+                 cc=["alice@example.com", "me@example.com"]),
+            sent("s2", 1, ["alice@example.com"]),
+        ]
+
+        contacts = aggregate_sent(emails, "Me@example.com")
+
+        assert set(contacts) == {"alice@example.com", "bob@example.com"}
+        alice = contacts["alice@example.com"]
+        assert alice["sent_count"] == 2
+        assert alice["display_name"] == "Alice Smith"
+        assert alice["first_contact_at"] == NOW - timedelta(days=5)
+        assert alice["last_contact_at"] == NOW - timedelta(days=1)
+        assert contacts["bob@example.com"]["sent_count"] == 1
+
+    def test_relationship_hints(self, monkeypatch):
+        """Test the hint wording for one-off, occasional, frequent, and unknown contacts."""
+        monkeypatch.setattr(settings, "contact_frequent_threshold", 10)
+        last = NOW - timedelta(days=3)
+
+        assert relationship_hint({"sent_count": 1, "last_contact_at": NOW}, NOW) == "emailed once, last reply today"
+        assert relationship_hint({"sent_count": 4, "last_contact_at": last}, NOW) == (
+            "occasional correspondent, 4 exchanges, last reply 3 days ago"
+        )
+        assert relationship_hint({"sent_count": 42, "last_contact_at": last}, NOW) == (
+            "frequent correspondent, 42 exchanges, last reply 3 days ago"
+        )
+        assert relationship_hint(None, NOW) == UNKNOWN_CONTACT_HINT
+
+
+class TestScan:
+    """Tests for the incremental Sent Items scan."""
+
+    def test_scan_is_incremental(self, store, monkeypatch):
+        """Test that a second scan counts only emails sent after the watermark."""
+        monkeypatch.setattr(settings, "user_email", "me@example.com")
+        provider = SentProvider([
+            sent("s2", 2, ["alice@example.com"]),
+            sent("s1", 9, ["alice@example.com", "bob@example.com"]),
+        ])
+
+        assert asyncio.run(store.scan(provider, now=NOW)) == 2
+        provider.sent.insert(0, sent("s3", 1, ["alice@example.com"]))
+        assert asyncio.run(store.scan(provider, now=NOW)) == 1
+        assert asyncio.run(store.scan(provider, now=NOW)) == 0
+
+        alice = asyncio.run(store.get_contact("ALICE@example.com"))
+        assert alice.sent_count == 3
+        assert alice.first_contact_at == NOW - timedelta(days=9)
+        assert alice.last_contact_at == NOW - timedelta(days=1)
+        contacts, total, last_scan_at = asyncio.run(store.list_contacts())
+        assert [contact.address for contact in contacts] == ["alice@example.com", "bob@example.com"]
+        assert total == 2
+        assert last_scan_at == NOW
+
+    def test_scan_stops_at_the_cap(self, store, monkeypatch):
+        """Test that at most contact_scan_max_emails emails are read per scan."""
+        monkeypatch.setattr(settings, "contact_scan_max_emails", 3)
+        provider = SentProvider([sent(f"s{i}", i, ["alice@example.com"]) for i in range(250)])
+
+        assert asyncio.run(store.scan(provider, now=NOW)) == 3
+        assert asyncio.run(store.get_contact("alice@example.com")).sent_count == 3
+
+    def test_excluded_sent_items_is_never_read(self, store, monkeypatch):
+        """Test that Sent Items is not scanned while excluded_folders covers it."""
+        monkeypatch.setattr(settings, "excluded_folders", ["Sent Items"])
+        provider = SentProvider([sent("s1", 1, ["alice@example.com"])])
+
+        assert asyncio.run(store.scan(provider, now=NOW)) == 0
+        assert provider.reads == 0
+        assert asyncio.run(store.relationship_hints(["alice@example.com"], NOW)) == {}
+
+
+class TestClassificationPrompt:
+    """Tests for relationship hints in classification."""
+
+    def test_relationship_follows_headers(self):
+        """Test that the relationship hint is placed with the email headers."""
+        service = AIService()
+        service._initialized = True
+        with patch.object(service, "_classify_email_sync", return_value={"category": "fyi"}) as classify:
+            asyncio.run(service.classify_email_async(
+                "Hi", "Body", "s@example.com", relationship="emailed once, last reply today"
+            ))
+
+        assert classify.call_args.args[0] == (
+            "Subject: Hi\nFrom: s@example.com\nRelationship: emailed once, last reply today\n\nBody"
+        )
+
+    def test_batch_passes_sender_hints(self, store, monkeypatch):
+        """Test that batch classification gives each email its sender's hint once Sent Items is scanned."""
+        monkeypatch.setattr(settings, "user_email", "me@example.com")
+        ai = MagicMock()
+        ai.classify_email_async = AsyncMock(return_value={"category": "fyi", "confidence": 0.9})
+        service = EmailService(MagicMock(), db=store.db)
+        emails = [
+            {"id": "e1", "subject": "Hi", "sender": "Alice <alice@example.com>", "body": "Hello"},
+            {"id": "e2", "subject": "Deal", "sender": "promo@example.com", "body": "Buy"},
+        ]
+
+        asyncio.run(classify_batch(emails, ai, service, 1))
+        assert [call.kwargs["relationship"] for call in ai.classify_email_async.call_args_list] == [None, None]
+
+        just_sent = {"id": "s1", "to": ["alice@example.com"], "received_time": datetime.utcnow().isoformat()}
+        asyncio.run(store.scan(SentProvider([just_sent])))
+        ai.classify_email_async.reset_mock()
+        asyncio.run(classify_batch(emails, ai, service, 1))
+        assert [call.kwargs["relationship"] for call in ai.classify_email_async.call_args_list] == [
+            "emailed once, last reply today", UNKNOWN_CONTACT_HINT
+        ]
+
+
+class TestContactEndpoints:
+    """Tests for the contact endpoints."""
+
+    def test_list_and_get(self, store):
+        """Test listing contacts and reading one, 404 for an unknown address."""
+        provider = SentProvider([sent("s1", 2, ["alice@example.com"]), sent("s0", 3, ["bob@example.com"])])
+        asyncio.run(store.scan(provider))
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="reader", email="me@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_contact_store] = lambda: store
+        client = TestClient(app)
+
+        page = client.get("/api/contacts", params={"limit": 1}).json()
+        assert page["total"] == 2
+        assert page["has_more"] is True
+        assert page["last_scan_at"] is not None
+        assert len(page["contacts"]) == 1
+
+        alice = client.get("/api/contacts/Alice@Example.com").json()
+        assert alice["sent_count"] == 1
+        assert alice["relationship"].startswith("emailed once")
+        assert client.get("/api/contacts/nobody@example.com").status_code == 404
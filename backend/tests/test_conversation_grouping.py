@@ -0,0 +1,134 @@
+"""Tests for listing stored emails grouped by conversation."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+# Three threads and a single email, interleaved in time:
+# conv-a: a1 (1st), a2 (4th), a3 (6th); conv-b: b1 (2nd), b2 (5th); single: s1 (3rd)
+EMAILS = [
+    ("a1", "conv-a", 1, "alice@contoso.com", ["me@contoso.com"], [], True, "fyi"),
+    ("b1", "conv-b", 2, "bob@fabrikam.com", ["me@contoso.com"], ["carol@fabrikam.com"], True, "newsletter"),
+    ("s1", None, 3, "Dana <dana@contoso.com>", ["me@contoso.com"], [], False, "team_action"),
+    ("a2", "conv-a", 4, "me@contoso.com", ["Alice@contoso.com"], ["erin@contoso.com"], False, "fyi"),
+    ("b2", "conv-b", 5, "carol@fabrikam.com", ["bob@fabrikam.com"], [], True, "newsletter"),
+    ("a3", "conv-a", 6, "alice@contoso.com", ["me@contoso.com"], [], False, "required_personal_action"),
+]
+
+
+@pytest.fixture
+def service():
+    """Email service over a store holding the interleaved threads."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(MagicMock(), db=db)
+    for email_id, conversation_id, day, sender, to, cc, is_read, category in EMAILS:
+        asyncio.run(email_service.store_email({
+            "id": email_id, "subject": f"Subject {email_id}", "sender": sender, "to": to, "cc": cc,
+            "body": "Hello", "received_time": f"2024-01-0{day}T10:00:00", "conversation_id": conversation_id,
+            "is_read": is_read,
+        }, USER_ID))
+    with db.get_connection() as conn:
+        conn.executemany(
+            "UPDATE emails SET category = ? WHERE id = ?", [(email[7], email[0]) for email in EMAILS]
+        )
+        conn.commit()
+    yield email_service
+    db.close()
+
+
+@pytest.fixture
+def client(service):
+    """Client for the email endpoints over the store."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="me", email="me@contoso.com", hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: service
+    app.dependency_overrides[get_ai_service] = lambda: MagicMock()
+    return TestClient(app)
+
+
+def grouped(client, **params):
+    """List conversations and return the response body."""
+    response = client.get("/api/emails", params={"group_by": "conversation", **params})
+    assert response.status_code == 200
+    return response.json()
+
+
+class TestGroupedList:
+    """Tests for GET /api/emails?group_by=conversation."""
+
+    def test_one_entry_per_conversation(self, client):
+        """Test that each conversation is listed once as its latest email, latest activity first."""
+        data = grouped(client)
+
+        assert [email["id"] for email in data["emails"]] == ["a3", "b2", "s1"]
+        assert (data["total"], data["has_more"]) == (3, False)
+        assert data["emails"][0]["subject"] == "Subject a3"
+        assert "thread_key" not in data["emails"][0] and "thread_rank" not in data["emails"][0]
+
+    def test_conversation_summary(self, client):
+        """Test the counts, participants, and actionable flag of each conversation."""
+        conversations = {email["id"]: email for email in grouped(client)["emails"]}
+
+        assert conversations["a3"]["conversation_count"] == 3
+        assert conversations["a3"]["unread_in_thread"] == 2
+        assert conversations["a3"]["participants"] == ["alice@contoso.com", "me@contoso.com", "erin@contoso.com"]
+        assert conversations["a3"]["has_actionable"] is True
+        assert (conversations["b2"]["unread_in_thread"], conversations["b2"]["has_actionable"]) == (0, False)
+        assert conversations["b2"]["participants"] == ["bob@fabrikam.com", "me@contoso.com", "carol@fabrikam.com"]
+
+    def test_email_without_conversation_is_its_own_group(self, client):
+        """Test that an email without a conversation_id is a conversation of one."""
+        single = next(email for email in grouped(client)["emails"] if email["id"] == "s1")
+
+        assert (single["conversation_count"], single["unread_in_thread"], single["has_actionable"]) == (1, 1, True)
+        assert single["participants"] == ["dana@contoso.com", "me@contoso.com"]
+
+    def test_pages_over_conversations(self, client):
+        """Test that limit and offset count conversations rather than emails."""
+        first = grouped(client, limit=2)
+        second = grouped(client, limit=2, offset=2)
+
+        assert [email["id"] for email in first["emails"]] == ["a3", "b2"]
+        assert (first["total"], first["has_more"]) == (3, True)
+        assert [email["id"] for email in second["emails"]] == ["s1"]
+        assert second["has_more"] is False
+
+    def test_filters_apply_before_grouping(self, client):
+        """Test that a filter narrows the emails each conversation is summarized from."""
+        data = grouped(client, unread=True)
+
+        assert [email["id"] for email in data["emails"]] == ["a3", "s1"]
+        assert (data["emails"][0]["conversation_count"], data["emails"][0]["unread_in_thread"]) == (2, 2)
+
+    def test_fields_keep_conversation_keys(self, client):
+        """Test that a fields projection still returns the conversation keys."""
+        email = grouped(client, fields="subject", limit=1)["emails"][0]
+
+        assert set(email) == {
+            "id", "subject", "conversation_count", "unread_in_thread", "participants", "has_actionable"
+        }
+
+    def test_invalid_grouping(self, client):
+        """Test that an unknown grouping, or a sort with a grouping, is a validation error."""
+        assert client.get("/api/emails", params={"group_by": "sender"}).status_code == 422
+        assert client.get(
+            "/api/emails", params={"group_by": "conversation", "sort": "subject"}
+        ).status_code == 422
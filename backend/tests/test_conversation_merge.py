@@ -0,0 +1,229 @@
+"""Tests for merging conversations with broken threading."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+
+class ThreadedProvider(MockEmailProvider):
+    """Mock provider with one logical thread split across three conversations."""
+
+    def __init__(self):
+        super().__init__()
+        self.mock_emails = [
+            make_email("orig", "conv-a", "2024-01-01T09:00:00"),
+            make_email("reply", "conv-a", "2024-01-01T10:00:00"),
+            make_email("forward", "conv-b", "2024-01-02T09:00:00"),
+            make_email("late", "conv-c", "2024-01-03T09:00:00"),
+            make_email("unrelated", "conv-z", "2024-01-04T09:00:00"),
+        ]
+        self.authenticate({})
+
+
+def make_email(email_id, conversation_id, received_time):
+    """Build a provider email dict."""
+    return {
+        "id": email_id,
+        "subject": f"Re: Budget ({email_id})",
+        "sender": "alice@example.com",
+        "body": "Numbers attached",
+        "received_time": received_time,
+        "conversation_id": conversation_id,
+    }
+
+
+async def store_all(service, provider):
+    """Store every provider email as the sync pipeline would."""
+    for email in provider.mock_emails:
+        await service.save_classification(
+            email, EmailClassification(category="fyi", confidence=0.9), USER_ID
+        )
+
+
+def stored_conversations(service):
+    """Map stored email IDs to (conversation_id, original_conversation_id)."""
+    with service.db.get_connection() as conn:
+        rows = conn.execute("SELECT id, conversation_id, original_conversation_id FROM emails").fetchall()
+    return {row["id"]: (row["conversation_id"], row["original_conversation_id"]) for row in rows}
+
+
+@pytest.fixture
+def provider():
+    """Provider with a split thread."""
+    return ThreadedProvider()
+
+
+@pytest.fixture
+async def service(provider):
+    """Email service over an isolated in-memory store with the thread stored."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    await store_all(email_service, provider)
+    yield email_service
+    store.close()
+
+
+class TestMergeRoundTrip:
+    """Tests for merge, query, and unmerge."""
+
+    @pytest.mark.asyncio
+    async def test_merge_query_unmerge(self, service):
+        """Test a full round trip restores the provider's conversation IDs."""
+        before = stored_conversations(service)
+
+        result = await service.merge_conversations(USER_ID, conversation_ids=["conv-b", "conv-a"])
+
+        assert result == {
+            "conversation_id": "conv-a",
+            "merged_conversation_ids": ["conv-a", "conv-b"],
+            "emails_updated": 3,
+        }
+        conversations = stored_conversations(service)
+        assert conversations["forward"] == ("conv-a", "conv-b")
+        assert conversations["orig"] == ("conv-a", "conv-a")
+        assert conversations["unrelated"] == ("conv-z", None)
+
+        thread = await service.get_conversation("conv-b", USER_ID)
+        assert [email["id"] for email in thread] == ["orig", "reply", "forward"]
+
+        undone = await service.unmerge_conversation("conv-a", USER_ID)
+
+        assert undone["restored_conversation_ids"] == ["conv-a", "conv-b"]
+        assert undone["emails_updated"] == 3
+        assert stored_conversations(service) == before
+        assert [email["id"] for email in await service.get_conversation("conv-b", USER_ID)] == ["forward"]
+
+    @pytest.mark.asyncio
+    async def test_merge_by_email_ids(self, service):
+        """Test that email IDs select their conversations."""
+        result = await service.merge_conversations(USER_ID, email_ids=["late", "reply"])
+
+        assert result["conversation_id"] == "conv-a"
+        assert result["merged_conversation_ids"] == ["conv-a", "conv-c"]
+        assert [email["id"] for email in await service.get_conversation("conv-c", USER_ID)] == [
+            "orig", "reply", "late"
+        ]
+
+    @pytest.mark.asyncio
+    async def test_merge_into_existing_group(self, service):
+        """Test that merging a merged group folds it in whole and unmerge splits all of it."""
+        await service.merge_conversations(USER_ID, conversation_ids=["conv-b", "conv-c"])
+
+        result = await service.merge_conversations(USER_ID, conversation_ids=["conv-c", "conv-a"])
+
+        assert result["conversation_id"] == "conv-a"
+        assert result["merged_conversation_ids"] == ["conv-a", "conv-b", "conv-c"]
+        assert stored_conversations(service)["late"] == ("conv-a", "conv-c")
+
+        await service.unmerge_conversation("conv-c", USER_ID)
+
+        assert stored_conversations(service)["late"] == ("conv-c", None)
+        assert stored_conversations(service)["forward"] == ("conv-b", None)
+
+    @pytest.mark.asyncio
+    async def test_resync_keeps_merge(self, service, provider):
+        """Test that re-storing a merged email keeps its merged conversation."""
+        await service.merge_conversations(USER_ID, conversation_ids=["conv-a", "conv-b"])
+
+        await store_all(service, provider)
+
+        assert stored_conversations(service)["forward"] == ("conv-a", "conv-b")
+
+    @pytest.mark.asyncio
+    async def test_email_without_conversation(self, service):
+        """Test that an email with no provider conversation joins and leaves cleanly."""
+        await service.save_classification(
+            make_email("loose", None, "2024-01-05T09:00:00"),
+            EmailClassification(category="fyi", confidence=0.9),
+            USER_ID
+        )
+
+        await service.merge_conversations(USER_ID, conversation_ids=["conv-a"], email_ids=["loose"])
+
+        assert stored_conversations(service)["loose"] == ("conv-a", "")
+        thread = await service.get_conversation("conv-a", USER_ID)
+        assert [email["id"] for email in thread][-1] == "loose"
+
+        await service.unmerge_conversation("conv-a", USER_ID)
+
+        assert stored_conversations(service)["loose"] == (None, None)
+
+    @pytest.mark.asyncio
+    async def test_conversation_count_reflects_merge(self, service):
+        """Test that merged conversations are counted once."""
+        assert (await service.get_counters(USER_ID)).conversation_count == 4
+
+        await service.merge_conversations(USER_ID, conversation_ids=["conv-a", "conv-b", "conv-c"])
+
+        assert (await service.get_counters(USER_ID)).conversation_count == 2
+
+    @pytest.mark.asyncio
+    async def test_merge_validation(self, service):
+        """Test that merges need two stored, distinct conversations."""
+        with pytest.raises(ValueError):
+            await service.merge_conversations(USER_ID, conversation_ids=["conv-a"], email_ids=["orig"])
+        with pytest.raises(LookupError):
+            await service.merge_conversations(USER_ID, conversation_ids=["conv-a", "missing"])
+        with pytest.raises(LookupError):
+            await service.merge_conversations(USER_ID, email_ids=["orig", "missing"])
+
+    @pytest.mark.asyncio
+    async def test_unmerge_without_merge(self, service):
+        """Test that unmerging an unmerged conversation changes nothing."""
+        result = await service.unmerge_conversation("conv-a", USER_ID)
+
+        assert result["emails_updated"] == 0
+
+
+class TestConversationMergeAPI:
+    """Tests for the merge and unmerge endpoints."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="merger", email="merger@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        return TestClient(app)
+
+    def test_merge_get_unmerge(self, client):
+        """Test the endpoints round trip through GET /conversations."""
+        response = client.post("/api/conversations/merge", json={"conversation_ids": ["conv-a", "conv-b"]})
+
+        assert response.status_code == 200
+        assert response.json()["conversation_id"] == "conv-a"
+
+        data = client.get("/api/conversations/conv-b").json()
+        assert data["total"] == 3
+
+        response = client.post("/api/conversations/conv-b/unmerge")
+        assert response.status_code == 200
+        assert response.json()["restored_conversation_ids"] == ["conv-a", "conv-b"]
+        assert client.get("/api/conversations/conv-b").json()["total"] == 1
+
+    def test_errors(self, client):
+        """Test validation, missing, and not-merged responses."""
+        assert client.post("/api/conversations/merge", json={"conversation_ids": ["conv-a"]}).status_code == 422
+        assert client.post(
+            "/api/conversations/merge", json={"email_ids": ["orig", "missing"]}
+        ).status_code == 404
+        assert client.post("/api/conversations/conv-a/unmerge").status_code == 404
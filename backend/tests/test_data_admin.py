@@ -0,0 +1,237 @@
+"""Tests for the anonymized database export and the data wipe."""
+
+import asyncio
+import json
+import sqlite3
+from datetime import timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.admin import router
+from backend.core.config import settings
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.services.data_admin import (
+    WIPE_TABLES, Anonymizer, DataAdminService, check_wipe_confirmation, get_data_admin_service,
+    wipe_confirmation
+)
+
+# Planted in every text column; no form of it may survive anonymization
+CANARY = "Canary7f3aZq"
+API_KEY = "test-admin-key"
+
+
+@pytest.fixture
+def store():
+    """In-memory store with a user, two emails, a task, and history, all carrying CANARY."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        conn.execute(
+            "INSERT INTO users (id, username, email, hashed_password) VALUES (1, ?, ?, 'hash')",
+            (f"{CANARY}user", f"{CANARY}@contoso.com")
+        )
+        conn.execute(
+            """
+            INSERT INTO emails (id, subject, sender, content, raw_content, one_line_summary, ai_reasoning,
+                                category, confidence, ai_category, ai_confidence, received_date, user_id,
+                                folder, conversation_id, to_recipients, attachments)
+            VALUES (?, ?, ?, ?, ?, ?, ?, 'team_action', 0.72, 'fyi', 0.72, '2024-05-01 09:30:00', 1, ?, ?, ?, ?)
+            """,
+            (
+                f"outlook-{CANARY}", f"Re: {CANARY} launch", f"{CANARY} <boss.{CANARY}@contoso.com>",
+                f"Body about {CANARY}", f"<p>{CANARY}</p>", f"{CANARY} summary", f"Mentions {CANARY}",
+                f"Inbox/{CANARY}", f"conversation-{CANARY}", json.dumps([f"team.{CANARY}@contoso.com"]),
+                json.dumps([{"name": f"{CANARY}-plan.pdf", "size": 2048}]),
+            )
+        )
+        conn.execute(
+            """
+            INSERT INTO emails (id, subject, sender, category, confidence, user_id, folder)
+            VALUES ('other', 'Lunch', 'peer@contoso.com', 'fyi', 0.95, 1, 'Inbox')
+            """
+        )
+        conn.execute(
+            "INSERT INTO tasks (title, description, email_id, user_id, status, priority) "
+            "VALUES (?, ?, ?, 1, 'pending', 'high')",
+            (f"Reply to {CANARY}", f"Details {CANARY}", f"outlook-{CANARY}")
+        )
+        conn.execute(
+            "INSERT INTO classification_history (email_id, user_id, previous_category, category, source) "
+            "VALUES (?, 1, 'fyi', 'team_action', 'user')",
+            (f"outlook-{CANARY}",)
+        )
+        conn.execute(
+            "INSERT INTO ai_traces (email_id, operation, prompt_version, inputs, duration_ms, created_at) "
+            "VALUES (?, 'classify', 'v1', ?, 5, '2024-05-01')",
+            (f"outlook-{CANARY}", f"prompt with {CANARY}")
+        )
+        conn.execute(
+            "INSERT INTO saved_views (user_id, name, filters, sort) VALUES (1, ?, ?, 'received_desc')",
+            (f"{CANARY} view", json.dumps({"search": CANARY}))
+        )
+        conn.execute("UPDATE categories SET description = description || ? WHERE name = 'fyi'", (f"\nFrom {CANARY}",))
+        conn.commit()
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def exported(store, tmp_path):
+    """Path of an anonymized export of the store."""
+    path = tmp_path / "anonymized.db"
+    asyncio.run(DataAdminService(db=store).export_anonymized(str(path)))
+    return path
+
+
+def rows(path, sql):
+    """Rows of a query against an exported database."""
+    conn = sqlite3.connect(path)
+    conn.row_factory = sqlite3.Row
+    try:
+        return [dict(row) for row in conn.execute(sql)]
+    finally:
+        conn.close()
+
+
+class TestAnonymizedExport:
+    """Tests for DataAdminService.export_anonymized."""
+
+    def test_no_canary_survives(self, exported):
+        """Test that the canary is in no table and nowhere in the file's bytes."""
+        data = exported.read_bytes().lower()
+
+        assert CANARY.lower().encode() not in data
+
+    def test_joins_still_work(self, exported):
+        """Test that email IDs are faked the same everywhere."""
+        joined = rows(exported, """
+            SELECT tasks.priority, emails.category FROM tasks
+            JOIN emails ON emails.id = tasks.email_id
+            JOIN classification_history ON classification_history.email_id = emails.id
+        """)
+
+        assert joined == [{"priority": "high", "category": "team_action"}]
+
+    def test_classification_fields_kept(self, exported):
+        """Test that categories, confidences, and timestamps are unchanged."""
+        email = rows(exported, "SELECT * FROM emails WHERE category = 'team_action'")[0]
+
+        assert (email["ai_category"], email["confidence"], email["ai_confidence"]) == ("fyi", 0.72, 0.72)
+        assert email["received_date"] == "2024-05-01 09:30:00"
+        assert email["folder"].startswith("Inbox/folder-")
+
+    def test_addresses_keep_their_shape(self, exported):
+        """Test that senders stay addresses, with one fake domain per real domain."""
+        senders = [row["sender"] for row in rows(exported, "SELECT sender FROM emails ORDER BY category")]
+
+        assert all(sender.endswith(".invalid") and "@" in sender for sender in senders)
+        assert len({sender.split("@")[1] for sender in senders}) == 1
+
+    def test_attachments_keep_extension_and_size(self, exported):
+        """Test that attachment names are faked but still useful for stats."""
+        email = rows(exported, "SELECT attachments FROM emails WHERE attachments IS NOT NULL")[0]
+
+        attachment = json.loads(email["attachments"])[0]
+        assert attachment["name"].endswith(".pdf") and attachment["size"] == 2048
+
+    def test_traces_dropped_and_built_in_rules_kept(self, exported):
+        """Test that AI traces are left out and unedited built-in rules are copied."""
+        assert rows(exported, "SELECT COUNT(*) AS n FROM ai_traces") == [{"n": 0}]
+        descriptions = {row["name"]: row["description"] for row in rows(exported, "SELECT * FROM categories")}
+        assert descriptions["fyi"].startswith("rules-")
+        assert not descriptions["newsletter"].startswith("rules-")
+
+    def test_live_database_untouched(self, store, exported):
+        """Test that only the copy is rewritten."""
+        with store.get_connection() as conn:
+            subject = conn.execute("SELECT subject FROM emails WHERE id = ?", (f"outlook-{CANARY}",)).fetchone()[0]
+
+        assert CANARY in subject
+
+    def test_fakes_are_deterministic_per_key(self):
+        """Test that a key gives the same fake for the same value, and keys differ."""
+        first, second = Anonymizer(key=b"k"), Anonymizer(key=b"k")
+
+        assert first.fake("subject", "Budget") == second.fake("subject", "Budget")
+        assert Anonymizer().fake("subject", "Budget") != Anonymizer().fake("subject", "Budget")
+
+
+class TestWipe:
+    """Tests for wiping the stored data."""
+
+    def test_wipe_keeps_settings(self, store):
+        """Test that mail data is deleted and users, categories, and views are kept."""
+        service = DataAdminService(db=store)
+
+        deleted = asyncio.run(service.wipe())
+
+        assert (deleted["emails"], deleted["tasks"], deleted["ai_traces"]) == (2, 1, 1)
+        assert set(asyncio.run(service.wipe_counts()).values()) == {0}
+        with store.get_connection() as conn:
+            kept = [conn.execute(f"SELECT COUNT(*) FROM {table}").fetchone()[0]
+                    for table in ("users", "categories", "saved_views")]
+        assert kept[0] == 1 and kept[1] > 0 and kept[2] == 1
+
+    def test_confirmation_tokens(self):
+        """Test that tokens are accepted until they expire and cannot be forged."""
+        token, expires_at = wipe_confirmation()
+
+        assert check_wipe_confirmation(token)
+        assert not check_wipe_confirmation(token, now=expires_at + timedelta(seconds=1))
+        assert not check_wipe_confirmation(f"{int(expires_at.timestamp()) + 3600}.forged")
+
+
+class TestDataAdminAPI:
+    """Tests for POST /api/admin/export-anonymized and POST /api/admin/wipe."""
+
+    @pytest.fixture
+    def client(self, store, monkeypatch):
+        """Client with an admin API key set and the data admin service overridden."""
+        monkeypatch.setattr(settings, "admin_api_key", API_KEY)
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_data_admin_service] = lambda: DataAdminService(db=store)
+        return TestClient(app)
+
+    def test_export(self, client, tmp_path):
+        """Test that the export downloads a SQLite file without the canary."""
+        response = client.post("/api/admin/export-anonymized", headers={"X-API-Key": API_KEY})
+
+        assert response.status_code == 200
+        assert response.content.startswith(b"SQLite format 3")
+        assert CANARY.lower().encode() not in response.content.lower()
+
+    def test_wipe_needs_confirmation(self, client, store):
+        """Test that the first call only counts and the confirmed call deletes."""
+        headers = {"X-API-Key": API_KEY}
+        pending = client.post("/api/admin/wipe", json={}, headers=headers).json()
+
+        assert pending["wiped"] is False
+        assert pending["rows"]["emails"] == 2 and set(pending["rows"]) == set(WIPE_TABLES)
+
+        wiped = client.post("/api/admin/wipe", json={"confirm": pending["confirm"]}, headers=headers).json()
+
+        assert wiped["wiped"] is True and wiped["confirm"] is None
+        assert asyncio.run(DataAdminService(db=store).wipe_counts())["emails"] == 0
+
+    def test_bad_confirmation(self, client):
+        """Test that a wrong token deletes nothing."""
+        response = client.post("/api/admin/wipe", json={"confirm": "0.nope"}, headers={"X-API-Key": API_KEY})
+
+        assert response.status_code == 422
+        assert client.post("/api/admin/wipe", json={}, headers={"X-API-Key": API_KEY}).json()["rows"]["emails"] == 2
+
+    @pytest.mark.parametrize("path", ["/api/admin/export-anonymized", "/api/admin/wipe"])
+    def test_api_key_required(self, client, path):
+        """Test that a missing or wrong API key is a 401."""
+        assert client.post(path, json={}).status_code == 401
+        assert client.post(path, json={}, headers={"X-API-Key": "wrong"}).status_code == 401
+
+    def test_disabled_without_key(self, client, monkeypatch):
+        """Test that the endpoints are off until an admin API key is set."""
+        monkeypatch.setattr(settings, "admin_api_key", None)
+
+        assert client.post("/api/admin/wipe", json={}, headers={"X-API-Key": API_KEY}).status_code == 403
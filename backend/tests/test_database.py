@@ -1,33 +1,50 @@
 """Tests for database connection and management."""
 
 import pytest
-import tempfile
 import os
-from backend.database.connection import DatabaseManager, get_database
+from backend.database import connection
+from backend.database.connection import (
+    DatabaseManager, get_database, get_database_manager,
+    get_default_manager, set_default_manager
+)
 
 
-def test_database_manager_initialization():
+@pytest.fixture
+def memory_store():
+    """Create an isolated in-memory database store."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield store
+    store.close()
+
+
+@pytest.fixture
+def default_store(memory_store):
+    """Install an in-memory store as the process default for one test."""
+    set_default_manager(memory_store)
+    yield memory_store
+    set_default_manager(None)
+
+
+def test_database_manager_initialization(tmp_path):
     """Test database manager initialization."""
-    manager = DatabaseManager()
-    assert manager.db_path is not None
-    assert os.path.exists(manager.db_path) or os.path.exists(os.path.dirname(manager.db_path))
+    db_path = str(tmp_path / "nested" / "email_helper.db")
+    manager = DatabaseManager(db_path)
+    assert manager.db_path == db_path
+    assert not manager.is_memory
+    assert os.path.exists(manager.db_path)
 
 
-def test_database_connection():
+def test_database_connection(memory_store):
     """Test database connection context manager."""
-    manager = DatabaseManager()
-    
-    with manager.get_connection() as conn:
+    with memory_store.get_connection() as conn:
         cursor = conn.execute("SELECT 1")
         result = cursor.fetchone()
         assert result[0] == 1
 
 
-def test_database_structure():
+def test_database_structure(memory_store):
     """Test that required database tables exist."""
-    manager = DatabaseManager()
-    
-    with manager.get_connection() as conn:
+    with memory_store.get_connection() as conn:
         # Check if users table exists
         cursor = conn.execute(
             "SELECT name FROM sqlite_master WHERE type='table' AND name='users'"
@@ -47,21 +64,15 @@ def test_database_structure():
         assert cursor.fetchone() is not None
 
 
-def test_database_user_operations():
+def test_database_user_operations(memory_store):
     """Test basic user database operations."""
-    import time
-    timestamp = str(int(time.time()))
-    
-    manager = DatabaseManager()
-    
-    with manager.get_connection() as conn:
-        # Insert test user with unique email
+    with memory_store.get_connection() as conn:
         cursor = conn.execute(
             """
             INSERT INTO users (username, email, hashed_password, is_active)
             VALUES (?, ?, ?, ?)
             """,
-            (f"testuser_{timestamp}", f"test_{timestamp}@example.com", "hashed_password", True)
+            ("testuser", "test@example.com", "hashed_password", True)
         )
         conn.commit()
         user_id = cursor.lastrowid
@@ -71,16 +82,51 @@ def test_database_user_operations():
         user = cursor.fetchone()
         
         assert user is not None
-        assert user["username"] == f"testuser_{timestamp}"
-        assert user["email"] == f"test_{timestamp}@example.com"
+        assert user["username"] == "testuser"
+        assert user["email"] == "test@example.com"
         assert user["is_active"] == 1  # SQLite stores boolean as integer
-        
-        # Clean up
-        conn.execute("DELETE FROM users WHERE id = ?", (user_id,))
+
+
+def test_memory_store_persists_across_connections(memory_store):
+    """Test that an in-memory store keeps data between get_connection calls."""
+    with memory_store.get_connection() as conn:
+        conn.execute(
+            "INSERT INTO users (username, email, hashed_password) VALUES (?, ?, ?)",
+            ("persisted", "persisted@example.com", "hash")
+        )
         conn.commit()
+    
+    with memory_store.get_connection() as conn:
+        row = conn.execute("SELECT COUNT(*) FROM users").fetchone()
+        assert row[0] == 1
+
+
+def test_stores_are_isolated(tmp_path):
+    """Test that two stores in one process do not share data."""
+    first = DatabaseManager(str(tmp_path / "first.db"))
+    second = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    
+    with first.get_connection() as conn:
+        conn.execute(
+            "INSERT INTO users (username, email, hashed_password) VALUES (?, ?, ?)",
+            ("only_in_first", "first@example.com", "hash")
+        )
+        conn.commit()
+    
+    with second.get_connection() as conn:
+        assert conn.execute("SELECT COUNT(*) FROM users").fetchone()[0] == 0
+    
+    second.close()
+
+
+def test_default_manager_delegates(default_store):
+    """Test that package-level helpers delegate to the default store."""
+    assert get_default_manager() is default_store
+    assert get_database_manager() is default_store
+    assert connection.db_manager is default_store
 
 
-def test_get_database_dependency():
+def test_get_database_dependency(default_store):
     """Test the FastAPI database dependency."""
     db_gen = get_database()
     conn = next(db_gen)
@@ -94,4 +140,4 @@ def test_get_database_dependency():
     try:
         next(db_gen)
     except StopIteration:
-        pass  # Expected behavior
\ No newline at end of file
+        pass  # Expected behavior
@@ -0,0 +1,209 @@
+"""Tests for SQLite vacuum and analyze maintenance."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+import backend.services.database_maintenance as database_maintenance
+from backend.api.admin import router
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.errors import ConflictError, register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.task import TaskCreate
+from backend.models.user import UserInDB
+from backend.services.database_maintenance import (
+    AUTO_VACUUM_INCREMENTAL, AUTO_VACUUM_NONE, VACUUM_FULL, VACUUM_INCREMENTAL, VACUUM_NONE, DatabaseMaintenance,
+    choose_vacuum, fragmentation, get_database_maintenance
+)
+from backend.services.task_service import TaskService
+
+BODY = "x" * 4000
+
+
+def add_emails(store, count, start=0):
+    """Insert emails large enough to fill a page each."""
+    with store.get_connection() as conn:
+        conn.executemany(
+            "INSERT INTO emails (id, subject, sender, content, user_id) VALUES (?, ?, 'a@example.com', ?, 1)",
+            [(f"m{i}", f"Subject {i}", BODY) for i in range(start, start + count)]
+        )
+        conn.commit()
+
+
+def delete_emails(store, count):
+    """Delete the first count emails, leaving their pages free."""
+    with store.get_connection() as conn:
+        conn.execute("DELETE FROM emails WHERE rowid IN (SELECT rowid FROM emails ORDER BY rowid LIMIT ?)", (count,))
+        conn.commit()
+
+
+@pytest.fixture
+def store(tmp_path):
+    """File-backed store, so the file size can shrink."""
+    db = DatabaseManager(str(tmp_path / "email_helper.db"))
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def maintenance(store, monkeypatch):
+    """Maintenance over the store with a 20% full VACUUM threshold."""
+    monkeypatch.setattr(settings, "database_vacuum_threshold", 0.2)
+    return DatabaseMaintenance(db=store)
+
+
+class TestFragmentationHeuristic:
+    """Tests for choosing between a full, incremental, or no VACUUM."""
+
+    def test_fragmentation(self):
+        """Test the free page share, with an empty database counted as unfragmented."""
+        assert fragmentation(25, 100) == 0.25
+        assert fragmentation(0, 0) == 0.0
+
+    @pytest.mark.parametrize("freelist, pages, auto_vacuum, expected", [
+        (20, 100, AUTO_VACUUM_NONE, VACUUM_FULL),
+        (19, 100, AUTO_VACUUM_NONE, VACUUM_NONE),
+        (19, 100, AUTO_VACUUM_INCREMENTAL, VACUUM_INCREMENTAL),
+        (0, 100, AUTO_VACUUM_INCREMENTAL, VACUUM_NONE),
+        (0, 0, AUTO_VACUUM_NONE, VACUUM_NONE),
+    ])
+    def test_threshold(self, freelist, pages, auto_vacuum, expected):
+        """Test that a full VACUUM runs only at or above the threshold."""
+        assert choose_vacuum(freelist, pages, auto_vacuum, 0.2) == expected
+
+    def test_forced(self):
+        """Test that full=True and full=False override the threshold."""
+        assert choose_vacuum(0, 100, AUTO_VACUUM_NONE, 0.2, full=True) == VACUUM_FULL
+        assert choose_vacuum(90, 100, AUTO_VACUUM_INCREMENTAL, 0.2, full=False) == VACUUM_INCREMENTAL
+        assert choose_vacuum(90, 100, AUTO_VACUUM_NONE, 0.2, full=False) == VACUUM_NONE
+
+
+class TestMaintenancePass:
+    """Tests for maintenance passes over a file-backed store."""
+
+    @pytest.mark.asyncio
+    async def test_full_vacuum_shrinks_file(self, store, maintenance):
+        """Test that a fragmented database is rewritten smaller and switched to incremental auto_vacuum."""
+        add_emails(store, 200)
+        delete_emails(store, 150)
+
+        report = await maintenance.run()
+
+        assert report.vacuum == VACUUM_FULL
+        assert report.fragmentation >= 0.2
+        assert report.freelist_pages_before > 0 and report.freelist_pages_after == 0
+        assert report.size_after_bytes < report.size_before_bytes
+        assert report.reclaimed_bytes == report.size_before_bytes - report.size_after_bytes
+        assert report.size_after_bytes == store.file_size()
+        assert report.auto_vacuum == "incremental"
+        assert report.duration_ms >= 0
+
+    @pytest.mark.asyncio
+    async def test_incremental_below_threshold(self, store, maintenance):
+        """Test that after the switch, light fragmentation is reclaimed without a rewrite."""
+        add_emails(store, 200)
+        await maintenance.run(full=True)
+        add_emails(store, 10, start=200)
+        delete_emails(store, 10)
+
+        report = await maintenance.run()
+
+        assert report.vacuum == VACUUM_INCREMENTAL
+        assert report.fragmentation < 0.2
+        assert report.freelist_pages_after == 0
+
+    @pytest.mark.asyncio
+    async def test_nothing_to_reclaim(self, maintenance):
+        """Test that an unfragmented database is only analyzed."""
+        report = await maintenance.run()
+
+        assert (report.vacuum, report.reclaimed_bytes, report.freelist_pages_before) == (VACUUM_NONE, 0, 0)
+
+    @pytest.mark.asyncio
+    async def test_queries_succeed_afterwards(self, store, maintenance):
+        """Test that reads and writes work normally after a full VACUUM."""
+        add_emails(store, 50)
+        delete_emails(store, 40)
+        task_service = TaskService(db=store)
+        task = await task_service.create_task(TaskCreate(title="Before"), 1)
+
+        await maintenance.run(full=True)
+
+        assert (await task_service.get_task(task.id, 1)).title == "Before"
+        await task_service.create_task(TaskCreate(title="After"), 1)
+        with store.get_connection() as conn:
+            assert conn.execute("SELECT COUNT(*) FROM emails").fetchone()[0] == 10
+            assert conn.execute("SELECT COUNT(*) FROM tasks").fetchone()[0] == 2
+            assert conn.execute("PRAGMA integrity_check").fetchone()[0] == "ok"
+
+    @pytest.mark.asyncio
+    async def test_one_pass_at_a_time(self, maintenance):
+        """Test that a pass started while another runs is a conflict."""
+        assert database_maintenance._maintenance_lock.acquire(blocking=False)
+        try:
+            with pytest.raises(ConflictError):
+                await maintenance.run()
+        finally:
+            database_maintenance._maintenance_lock.release()
+
+        assert (await maintenance.run()).vacuum == VACUUM_NONE
+
+    @pytest.mark.asyncio
+    async def test_in_memory_store(self):
+        """Test that an in-memory store reports its page size."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        try:
+            report = await DatabaseMaintenance(db=store).run(full=True)
+        finally:
+            store.close()
+
+        assert report.vacuum == VACUUM_FULL
+        assert report.size_after_bytes > 0
+
+
+class TestMaintenanceAPI:
+    """Tests for POST /api/admin/maintenance."""
+
+    @pytest.fixture
+    def client(self, maintenance):
+        """Client with auth and the maintenance service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="admin", email="admin@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_database_maintenance] = lambda: maintenance
+        return TestClient(app)
+
+    def test_reports_pass(self, client, store):
+        """Test that the endpoint runs a pass and reports the sizes."""
+        add_emails(store, 100)
+        delete_emails(store, 80)
+
+        response = client.post("/api/admin/maintenance")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["vacuum"] == "full"
+        assert data["size_after_bytes"] < data["size_before_bytes"]
+
+    def test_full_false_skips_rewrite(self, client, store):
+        """Test that full=false never runs a full VACUUM."""
+        add_emails(store, 100)
+        delete_emails(store, 80)
+
+        response = client.post("/api/admin/maintenance", params={"full": "false"})
+
+        assert response.json()["vacuum"] == "none"
+
+    def test_concurrent_pass_conflicts(self, client):
+        """Test that a pass already running is a 409."""
+        database_maintenance._maintenance_lock.acquire()
+        try:
+            assert client.post("/api/admin/maintenance").status_code == 409
+        finally:
+            database_maintenance._maintenance_lock.release()
@@ -0,0 +1,180 @@
+"""Tests for starting on a corrupted database file."""
+
+import json
+import sqlite3
+from datetime import datetime
+
+import pytest
+from fastapi.testclient import TestClient
+
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager, get_database_manager
+from backend.database.recovery import (
+    INCIDENT_DATABASE_CORRUPTED, check_integrity, latest_backup, recover_database
+)
+
+NOW = datetime(2024, 5, 1, 9, 30, 0)
+GARBAGE = b"this is not an SQLite database" * 100
+
+
+@pytest.fixture
+def db_path(tmp_path):
+    """Path of a database file in an empty directory."""
+    return str(tmp_path / "email_helper.db")
+
+
+def write_database(path, username):
+    """Write a small database holding one user."""
+    conn = sqlite3.connect(path)
+    conn.execute("CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT)")
+    conn.execute("INSERT INTO users (username) VALUES (?)", (username,))
+    conn.commit()
+    conn.close()
+
+
+def damage_pages(path):
+    """Overwrite part of a database's second page, past its intact header."""
+    conn = sqlite3.connect(path)
+    conn.execute("CREATE TABLE filler (text TEXT)")
+    conn.executemany("INSERT INTO filler VALUES (?)", [("x" * 200,)] * 200)
+    conn.commit()
+    page_size = conn.execute("PRAGMA page_size").fetchone()[0]
+    conn.close()
+    with open(path, "r+b") as f:
+        f.seek(page_size)
+        f.write(b"\xff" * 200)
+
+
+def usernames(store):
+    """Usernames stored in a database store."""
+    with store.get_connection() as conn:
+        return [row["username"] for row in conn.execute("SELECT username FROM users")]
+
+
+def incidents(store):
+    """(kind, details) of every recorded incident."""
+    with store.get_connection() as conn:
+        rows = conn.execute("SELECT kind, details FROM incidents ORDER BY id").fetchall()
+    return [(row["kind"], json.loads(row["details"])) for row in rows]
+
+
+class TestRecovery:
+    """Tests for detecting corruption and moving the damaged file aside."""
+
+    def test_intact_database_is_kept(self, db_path):
+        """Test that an intact file passes the check and keeps its data."""
+        write_database(db_path, "alice")
+
+        assert check_integrity(db_path) is None
+        assert recover_database(db_path, now=NOW) is None
+
+        store = DatabaseManager(db_path)
+        assert (store.recovery, store.degraded) == (None, False)
+        assert usernames(store) == ["alice"]
+        assert incidents(store) == []
+
+    def test_damaged_pages_fail_the_check(self, db_path):
+        """Test that a database with a valid header but damaged pages is detected."""
+        write_database(db_path, "alice")
+        damage_pages(db_path)
+
+        assert check_integrity(db_path) is not None
+        assert DatabaseManager(db_path).degraded
+
+    def test_missing_database_is_not_a_recovery(self, db_path):
+        """Test that a first start creates the database without an incident."""
+        assert recover_database(db_path) is None
+
+    def test_corrupted_database_is_moved_aside(self, db_path):
+        """Test that a damaged file is renamed with its sidecars and the store starts empty."""
+        with open(db_path, "wb") as f:
+            f.write(GARBAGE)
+        with open(db_path + "-wal", "wb") as f:
+            f.write(b"wal")
+
+        recovery = recover_database(db_path, now=NOW)
+
+        assert recovery.corrupt_path == db_path + ".corrupt_20240501_093000"
+        assert recovery.degraded
+        with open(recovery.corrupt_path, "rb") as f:
+            assert f.read() == GARBAGE
+        with open(recovery.corrupt_path + "-wal", "rb") as f:
+            assert f.read() == b"wal"
+        assert recovery.corrupt_path in recovery.banner
+
+    def test_store_starts_degraded_and_records_incident(self, db_path):
+        """Test that DatabaseManager recovers on open, creates the schema, and logs the incident."""
+        with open(db_path, "wb") as f:
+            f.write(GARBAGE)
+
+        store = DatabaseManager(db_path)
+
+        assert store.degraded
+        assert usernames(store) == []
+        [(kind, details)] = incidents(store)
+        assert kind == INCIDENT_DATABASE_CORRUPTED
+        assert details["corrupt_path"] == store.recovery.corrupt_path
+        assert details["degraded"] is True
+        assert check_integrity(db_path) is None
+
+    def test_auto_restore_uses_newest_intact_backup(self, db_path, monkeypatch):
+        """Test that with database_auto_restore the newest backup that passes the check is copied in."""
+        write_database(f"{db_path}.backup_20240101_000000", "older")
+        write_database(f"{db_path}.backup_20240301_000000", "newer")
+        with open(f"{db_path}.backup_20240401_000000", "wb") as f:
+            f.write(GARBAGE)
+        with open(db_path, "wb") as f:
+            f.write(GARBAGE)
+        monkeypatch.setattr(settings, "database_auto_restore", True)
+
+        store = DatabaseManager(db_path)
+
+        assert store.recovery.restored_from == f"{db_path}.backup_20240301_000000"
+        assert not store.degraded
+        assert usernames(store) == ["newer"]
+        assert incidents(store)[0][1]["restored_from"] == store.recovery.restored_from
+
+    def test_auto_restore_without_backup_starts_empty(self, db_path):
+        """Test that a restore with no intact backup falls back to degraded mode."""
+        with open(db_path, "wb") as f:
+            f.write(GARBAGE)
+
+        recovery = recover_database(db_path, restore_from_backup=True, now=NOW)
+
+        assert recovery.degraded
+        assert latest_backup(db_path) is None
+
+
+class TestDegradedReporting:
+    """Tests for /health and GET /api/config on a recovered store."""
+
+    @pytest.fixture
+    def store(self, db_path):
+        """Store that replaced a corrupted database with an empty one."""
+        with open(db_path, "wb") as f:
+            f.write(GARBAGE)
+        return DatabaseManager(db_path)
+
+    def test_health_reports_degraded(self, store, monkeypatch):
+        """Test that /health is degraded and says where the damaged file went."""
+        from backend import main
+
+        monkeypatch.setattr(main, "get_default_manager", lambda: store)
+
+        data = TestClient(main.app).get("/health").json()
+
+        assert (data["status"], data["database"]) == ("degraded", "degraded")
+        assert data["database_recovery"]["corrupt_path"] == store.recovery.corrupt_path
+
+    def test_config_banner(self, store):
+        """Test that GET /api/config carries a banner naming the damaged file."""
+        from backend.main import app
+
+        app.dependency_overrides[get_database_manager] = lambda: store
+        try:
+            data = TestClient(app).get("/api/config").json()
+        finally:
+            app.dependency_overrides.clear()
+
+        assert store.recovery.corrupt_path in data["banner"]
+        assert data["database_recovery"]["degraded"] is True
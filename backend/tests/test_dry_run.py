@@ -0,0 +1,291 @@
+"""Tests for dry runs of batch processing and the processing pipeline."""
+
+import asyncio
+import copy
+from datetime import datetime
+from unittest.mock import AsyncMock, MagicMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import emails as emails_api
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.task import TaskCreate
+from backend.models.user import UserInDB
+from backend.services.classification_store import ClassificationStore
+from backend.services.dry_run import TASK_WRITES, ChangePlan, WriteInterceptor
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.job_queue import JobQueue
+from backend.services.task_service import TaskService, get_task_service
+from backend.workers.email_processor import EmailProcessorWorker, MockEmailService, MockTaskService
+
+USER_ID = 1
+
+EMAIL_IDS = ["mock-email-1", "mock-email-2"]
+
+# Tables a run writes to
+WRITTEN_TABLES = ("emails", "tasks", "classification_history", "outlook_activity")
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+def row_counts(store):
+    """Row count of every table a run writes to."""
+    with store.get_connection() as conn:
+        return {table: conn.execute(f"SELECT COUNT(*) FROM {table}").fetchone()[0] for table in WRITTEN_TABLES}
+
+
+@pytest.fixture
+def stub_ai():
+    """Stub AI client flagging everything as actionable with two action items."""
+    ai = MagicMock()
+    ai.classify_email_async = AsyncMock(return_value={
+        "category": "required_personal_action", "confidence": 0.9, "reasoning": "Asks for a reply"
+    })
+    ai.extract_action_items = AsyncMock(return_value={
+        "action_items": ["Reply to the thread", "Book the room"]
+    })
+    return ai
+
+
+class TestChangePlan:
+    """Tests for describing a change plan."""
+
+    def test_describe(self):
+        """Test that changes are grouped and counted in the description."""
+        plan = ChangePlan()
+        for email_id in ("a", "b"):
+            plan.record({"action": "move_email", "email_id": email_id, "destination_folder": "Quarantine"})
+        plan.record({"action": "classify", "email_id": "c", "category": "fyi"})
+        plan.record({"action": "create_task", "title": "Reply"})
+
+        assert plan.describe() == "would move 2 emails to Quarantine, classify 1 email as fyi, create 1 task"
+        assert plan.summary() == {"move_email": 2, "classify": 1, "create_task": 1}
+        assert ChangePlan().describe() == "would make no changes"
+
+    def test_internal_writes_are_intercepted(self, store):
+        """Test that writes a service makes through its own methods are recorded, not run."""
+        plan = ChangePlan()
+        tasks = WriteInterceptor(TaskService(db=store), plan, TASK_WRITES)
+
+        created = asyncio.run(tasks.create_tasks_from_action_items("mock-email-1", ["Reply", "reply "], USER_ID))
+
+        assert len(created) == 1
+        assert plan.summary() == {"create_task": 1}
+        assert row_counts(store)["tasks"] == 0
+
+
+class TestBatchDryRun:
+    """Tests for dry_run on POST /api/emails/batch-process."""
+
+    @pytest.fixture
+    def provider(self):
+        """Authenticated mock mailbox."""
+        provider = MockEmailProvider()
+        provider.authenticate({})
+        return provider
+
+    @pytest.fixture
+    def client(self, store, provider, stub_ai, monkeypatch):
+        """Client over the in-memory store with automatic tasks on."""
+        monkeypatch.setattr(settings, "auto_create_tasks", "required_personal_action")
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(emails_api.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="rehearser", email="rehearser@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: EmailService(provider, db=store)
+        app.dependency_overrides[get_task_service] = lambda: TaskService(db=store)
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        return TestClient(app)
+
+    def batch(self, client, **options):
+        """Post a batch of EMAIL_IDS."""
+        return client.post("/api/emails/batch-process", json={
+            "emails": [{"email_id": email_id} for email_id in EMAIL_IDS],
+            "thread_mode": False, "skip_already_processed": False, **options
+        })
+
+    def test_no_mutations(self, client, store, provider, stub_ai):
+        """Test that a dry run calls the AI but changes neither the database nor the mailbox."""
+        mailbox = copy.deepcopy(provider.mock_emails)
+
+        response = self.batch(client, dry_run=True)
+
+        assert response.status_code == 200
+        assert row_counts(store) == dict.fromkeys(WRITTEN_TABLES, 0)
+        assert provider.mock_emails == mailbox
+        assert stub_ai.classify_email_async.await_count == 2
+        plan = response.json()["change_plan"]
+        assert plan["summary"] == {"classify": 2, "create_task": 4}
+        assert plan["description"] == "would classify 2 emails as required_personal_action, create 4 tasks"
+        assert response.json()["results"][0]["category"] == "required_personal_action"
+
+    def test_normal_run_has_no_plan(self, client, store):
+        """Test that without dry_run the same batch is stored and no plan is returned."""
+        response = self.batch(client)
+
+        assert response.json()["change_plan"] is None
+        assert row_counts(store)["emails"] == 2
+        assert row_counts(store)["tasks"] == 4
+
+    def test_simulate_ai(self, client, store, stub_ai):
+        """Test that simulate_ai uses canned responses instead of the AI service."""
+        response = self.batch(client, dry_run=True, simulate_ai=True)
+
+        stub_ai.classify_email_async.assert_not_called()
+        assert response.json()["change_plan"]["summary"] == {"classify": 2}
+        assert row_counts(store)["emails"] == 0
+
+    def test_simulate_ai_requires_dry_run(self, client, stub_ai):
+        """Test that canned responses are never stored."""
+        response = self.batch(client, simulate_ai=True)
+
+        assert response.status_code == 422
+        stub_ai.classify_email_async.assert_not_called()
+
+
+class ActionableAIService:
+    """Mock worker AI client flagging every email as actionable."""
+
+    async def analyze_email(self, email_data):
+        return {"priority": "medium"}
+
+    async def extract_tasks(self, email_data):
+        return [{"title": "Follow up", "priority": "medium"}]
+
+    async def categorize_email(self, email_data):
+        return {"category": "required_personal_action", "confidence": 0.9}
+
+    async def extract_action_items(self, email_content, context=None):
+        return {"action_items": ["Send the numbers"]}
+
+
+class TestPipelineDryRun:
+    """Tests for dry runs of the processing pipeline."""
+
+    @pytest.fixture
+    def queue(self):
+        """Isolated job queue with WebSocket sends recorded."""
+        job_queue = JobQueue()
+        with patch("backend.workers.email_processor.job_queue", job_queue), \
+                patch("backend.workers.email_processor.websocket_manager") as manager:
+            manager.broadcast_job_status = AsyncMock()
+            manager.send_processing_complete = AsyncMock()
+            yield job_queue, manager
+
+    @pytest.fixture
+    def worker(self, store, monkeypatch):
+        """Worker over the in-memory store with automatic tasks on."""
+        monkeypatch.setattr(settings, "auto_create_tasks", "required_personal_action")
+        worker = EmailProcessorWorker()
+        worker.ai_service = ActionableAIService()
+        worker.email_service = MockEmailService()
+        worker.task_service = MockTaskService()
+        worker.task_service.create_task = AsyncMock()
+        worker.email_service.update_email_category = AsyncMock()
+        worker._classification_store = ClassificationStore(db=store)
+        worker._auto_task_service = TaskService(db=store)
+        return worker
+
+    def run(self, queue, worker, **options):
+        """Run a pipeline over EMAIL_IDS to the end and return it."""
+        job_queue, _ = queue
+
+        async def scenario():
+            pipeline_id = await job_queue.create_pipeline(EMAIL_IDS, "user_1", **options)
+            while (job := await job_queue.get_next_job()) is not None:
+                await worker._process_job(job)
+            return await job_queue.get_pipeline(pipeline_id)
+
+        return asyncio.run(scenario())
+
+    def test_no_mutations(self, queue, worker, store):
+        """Test that a dry run stores nothing and reports the writes in its plan."""
+        asyncio.run(TaskService(db=store).create_task(TaskCreate(title="Existing"), USER_ID))
+        before = row_counts(store)
+
+        pipeline = self.run(queue, worker, dry_run=True)
+
+        assert row_counts(store) == before
+        worker.task_service.create_task.assert_not_called()
+        worker.email_service.update_email_category.assert_not_called()
+        assert pipeline.status == "completed"
+        assert pipeline.plan.summary() == {"create_task": 4, "categorize_email": 2, "classify": 2}
+        assert pipeline.diff.summary()["newly_classified"] == 2
+
+    def test_completion_event_carries_plan(self, queue, worker):
+        """Test that the completion event reports the change plan."""
+        self.run(queue, worker, dry_run=True)
+
+        results = queue[1].send_processing_complete.await_args.args[1]
+        assert results["change_plan"]["description"] == (
+            "would create 4 tasks, apply category required_personal_action to 2 emails, "
+            "classify 2 emails as required_personal_action"
+        )
+
+    def test_normal_run_writes(self, queue, worker, store):
+        """Test that the same pipeline without dry_run stores its results."""
+        pipeline = self.run(queue, worker)
+
+        assert pipeline.plan is None
+        assert row_counts(store)["emails"] == 2
+        assert worker.task_service.create_task.await_count == 2
+
+
+class TestProcessingAPI:
+    """Tests for dry_run on POST /api/processing/start."""
+
+    @pytest.fixture
+    def client(self, queue_only):
+        """Client with auth overridden and the worker not started."""
+        from backend.api.processing import router
+
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: {"user_id": "user_1"}
+        with patch("backend.api.processing.email_processor_worker") as background_worker:
+            background_worker.start = AsyncMock()
+            yield TestClient(app)
+
+    @pytest.fixture
+    def queue_only(self):
+        """Isolated job queue for the API."""
+        job_queue = JobQueue()
+        with patch("backend.api.processing.job_queue", job_queue):
+            yield job_queue
+
+    def start(self, client, **options):
+        """Start a run over EMAIL_IDS."""
+        return client.post("/api/processing/start", json={
+            "email_ids": EMAIL_IDS, "skip_already_processed": False, **options
+        })
+
+    def test_status_reports_plan(self, client):
+        """Test that a dry run's status carries its (initially empty) change plan."""
+        started = self.start(client, dry_run=True, simulate_ai=True).json()
+
+        status = client.get(f"/api/processing/{started['pipeline_id']}/status").json()
+
+        assert started["dry_run"] is True
+        assert status["dry_run"] is True
+        assert status["change_plan"] == {"summary": {}, "description": "would make no changes", "changes": []}
+
+    def test_simulate_ai_requires_dry_run(self, client):
+        """Test that simulate_ai is rejected outside dry runs."""
+        assert self.start(client, simulate_ai=True).status_code == 422
@@ -0,0 +1,165 @@
+"""Tests for the aging report of stale actionable emails."""
+
+import asyncio
+from datetime import datetime, timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import AGING_OLDEST, EmailService
+
+USER_ID = 1
+
+NOW = datetime(2026, 3, 20, 12, 0)
+
+
+@pytest.fixture
+def store():
+    """In-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def service(store):
+    """Email service over an authenticated mock provider and the store."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return EmailService(provider, db=store)
+
+
+def add_email(store, email_id, days_old, category="required_personal_action", user_id=USER_ID):
+    """Store a classified email received days_old days before NOW."""
+    with store.get_connection() as conn:
+        conn.execute(
+            """
+            INSERT INTO emails (id, subject, sender, content, received_date, category, user_id)
+            VALUES (?, ?, 'boss@example.com', 'Please look', ?, ?, ?)
+            """,
+            (email_id, f"Subject {email_id}", NOW - timedelta(days=days_old, hours=1), category, user_id)
+        )
+        conn.commit()
+
+
+def add_task(store, email_id, status, user_id=USER_ID):
+    """Store a task made from an email."""
+    with store.get_connection() as conn:
+        cursor = conn.execute(
+            "INSERT INTO tasks (title, status, email_id, user_id) VALUES (?, ?, ?, ?)",
+            (f"Follow up {email_id}", status, email_id, user_id)
+        )
+        conn.commit()
+        return cursor.lastrowid
+
+
+def report(service):
+    """Aging report as of NOW."""
+    return asyncio.run(service.get_aging_report(USER_ID, now=NOW))
+
+
+class TestAgingReport:
+    """Tests for EmailService.get_aging_report."""
+
+    def test_buckets(self, store, service):
+        """Test that ages fall into the 0-2d, 3-7d, 8-14d, and 15+d buckets at their edges."""
+        for email_id, days_old in (("a", 0), ("b", 2), ("c", 3), ("d", 7), ("e", 8), ("f", 14), ("g", 15), ("h", 90)):
+            add_email(store, email_id, days_old, category="team_action" if days_old % 2 else "required_personal_action")
+
+        result = report(service)
+
+        assert [(bucket.label, bucket.count) for bucket in result.buckets] == [
+            ("0-2d", 2), ("3-7d", 2), ("8-14d", 2), ("15+d", 2)
+        ]
+        assert result.buckets[-1].max_days is None
+        assert result.total == 8
+
+    def test_only_actionable_categories(self, store, service):
+        """Test that other categories and other users' emails are not counted."""
+        add_email(store, "action", 1)
+        add_email(store, "fyi", 1, category="fyi")
+        add_email(store, "unclassified", 1, category=None)
+        add_email(store, "theirs", 1, user_id=USER_ID + 1)
+
+        result = report(service)
+
+        assert result.total == 1
+        assert [email.id for email in result.oldest] == ["action"]
+
+    def test_completed_tasks_excluded(self, store, service):
+        """Test that emails with a completed task drop out and open tasks are reported."""
+        add_email(store, "done", 20)
+        add_task(store, "done", "pending")
+        add_task(store, "done", "completed")
+        add_email(store, "working", 10)
+        add_task(store, "working", "pending")
+        task_id = add_task(store, "working", "in_progress")
+        add_email(store, "cancelled", 5)
+        add_task(store, "cancelled", "cancelled")
+        add_email(store, "untracked", 1)
+
+        result = report(service)
+
+        assert result.total == 3
+        assert [(email.id, email.task_status) for email in result.oldest] == [
+            ("working", "in_progress"), ("cancelled", "cancelled"), ("untracked", None)
+        ]
+        assert result.oldest[0].task_id == task_id
+        assert result.oldest[0].age_days == 10
+
+    def test_oldest_ten(self, store, service):
+        """Test that only the oldest AGING_OLDEST emails are listed, oldest first."""
+        for days_old in range(AGING_OLDEST + 5):
+            add_email(store, f"e{days_old}", days_old)
+
+        result = report(service)
+
+        assert result.total == AGING_OLDEST + 5
+        assert len(result.oldest) == AGING_OLDEST
+        assert result.oldest[0].id == f"e{AGING_OLDEST + 4}"
+        assert [email.age_days for email in result.oldest] == sorted(
+            (email.age_days for email in result.oldest), reverse=True
+        )
+
+    def test_empty(self, service):
+        """Test that a user without actionable emails gets empty buckets."""
+        result = report(service)
+
+        assert result.total == 0
+        assert [bucket.count for bucket in result.buckets] == [0, 0, 0, 0]
+        assert result.oldest == []
+
+
+class TestEndpoint:
+    """Tests for GET /api/emails/aging."""
+
+    def test_get_aging(self, store, service):
+        """Test that the report is returned for the current user."""
+        add_email(store, "stale", 30)
+        add_task(store, "stale", "pending")
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="ager", email="ager@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+
+        response = TestClient(app).get("/api/emails/aging")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["categories"] == ["required_personal_action", "team_action"]
+        assert data["buckets"][-1] == {"label": "15+d", "min_days": 15, "max_days": None, "count": 1}
+        assert data["oldest"][0]["subject"] == "Subject stale"
+        assert data["oldest"][0]["task_status"] == "pending"
+        assert data["oldest"][0]["age_days"] >= 30
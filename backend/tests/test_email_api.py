@@ -2,10 +2,10 @@
 
 import pytest
 from fastapi.testclient import TestClient
-from unittest.mock import Mock, patch
+from unittest.mock import AsyncMock, Mock, patch
 from backend.main import app
 from backend.services.email_provider import MockEmailProvider
-from backend.core.dependencies import reset_dependencies
+from backend.core.dependencies import get_ai_service, reset_dependencies
 
 client = TestClient(app)
 
@@ -109,7 +109,7 @@ class TestEmailAPI:
         with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
             mock_get_provider.return_value = mock_provider
             
-            response = client.get("/api/emails/mock-email-1", headers=auth_headers)
+            response = client.get("/api/emails/mock-email-1?source=outlook", headers=auth_headers)
             
             assert response.status_code == 200
             data = response.json()
@@ -119,6 +119,87 @@ class TestEmailAPI:
             assert data["sender"] == "test1@example.com"
             assert "body" in data
     
+    def test_get_emails_awaiting_reply(self, auth_headers, mock_provider):
+        """Test that awaiting_reply lists stored threads instead of the provider folder."""
+        with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
+            mock_get_provider.return_value = mock_provider
+            
+            response = client.get("/api/emails?awaiting_reply=true", headers=auth_headers)
+            
+            assert response.status_code == 200
+            data = response.json()
+            assert data["total"] == len(data["emails"])
+            assert all(email["awaiting_reply"] == 1 for email in data["emails"])
+    
+    def test_get_email_counters(self, auth_headers, mock_provider):
+        """Test sidebar counters endpoint shape and caching header."""
+        with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
+            mock_get_provider.return_value = mock_provider
+            
+            response = client.get("/api/emails/counters", headers=auth_headers)
+            
+            assert response.status_code == 200
+            data = response.json()
+            assert "by_category" in data
+            assert "by_folder" in data
+            assert "needs_review" in data
+            assert "flagged" in data
+            assert "awaiting_reply" in data
+            assert "conversation_count" in data
+            assert response.headers["cache-control"] == "private, max-age=15"
+    
+    def test_get_email_with_reputation(self, auth_headers, mock_provider):
+        """Test that include_reputation adds the sender score and breakdown."""
+        with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
+            mock_get_provider.return_value = mock_provider
+            
+            plain = client.get("/api/emails/mock-email-1", headers=auth_headers).json()
+            response = client.get("/api/emails/mock-email-1?include_reputation=true",
+                                  headers=auth_headers)
+            
+            assert response.status_code == 200
+            data = response.json()
+            assert "sender_score" not in plain
+            assert "sender_score" in data
+            assert data["sender_reputation"]["sender"] == "test1@example.com"
+    
+    def test_get_email_include_raw(self, auth_headers, mock_provider):
+        """Test that include_raw adds the unsanitized body for forensics."""
+        with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
+            mock_get_provider.return_value = mock_provider
+            
+            plain = client.get("/api/emails/mock-email-1?source=outlook", headers=auth_headers).json()
+            response = client.get("/api/emails/mock-email-1?source=outlook&include_raw=true", headers=auth_headers)
+            
+            assert response.status_code == 200
+            assert "raw_body" not in plain
+            assert response.json()["raw_body"] == plain["body"]
+    
+    def test_get_email_links(self, auth_headers, mock_provider):
+        """Test link extraction for an email and a missing email."""
+        with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
+            mock_get_provider.return_value = mock_provider
+            
+            response = client.get("/api/emails/mock-email-1/links", headers=auth_headers)
+            missing = client.get("/api/emails/non-existing/links", headers=auth_headers)
+            
+            assert response.status_code == 200
+            data = response.json()
+            assert data["email_id"] == "mock-email-1"
+            assert data["total"] == len(data["links"])
+            assert missing.status_code == 404
+    
+    def test_get_sender_reputation(self, auth_headers):
+        """Test the sender reputation endpoint for a sender without history."""
+        response = client.get("/api/emails/senders/nobody@example.com/reputation",
+                              headers=auth_headers)
+        
+        assert response.status_code == 200
+        data = response.json()
+        assert data["sender"] == "nobody@example.com"
+        assert data["score"] is None
+        assert data["email_count"] == 0
+    
     def test_get_email_by_id_not_found(self, auth_headers, mock_provider):
         """Test email retrieval for non-existing ID."""
         with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
@@ -128,7 +209,8 @@ class TestEmailAPI:
             
             assert response.status_code == 404
             data = response.json()
-            assert "not found" in data["message"].lower()
+            assert data["error"]["code"] == "not_found"
+            assert "not found" in data["error"]["message"].lower()
     
     def test_mark_email_as_read_success(self, auth_headers, mock_provider):
         """Test successful marking email as read."""
@@ -144,6 +226,18 @@ class TestEmailAPI:
             assert data["email_id"] == "mock-email-1"
             assert "successfully" in data["message"].lower()
     
+    def test_mark_email_as_read_suppresses_receipts(self, auth_headers, mock_provider):
+        """Test that the suppress_read_receipts setting reaches the provider."""
+        with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider, \
+                patch('backend.api.emails.settings.suppress_read_receipts', True), \
+                patch.object(mock_provider, 'mark_as_read', return_value=True) as mark_as_read:
+            mock_get_provider.return_value = mock_provider
+            
+            response = client.post("/api/emails/mock-email-1/mark-read", headers=auth_headers)
+            
+            assert response.status_code == 200
+            mark_as_read.assert_called_once_with("mock-email-1", suppress_read_receipt=True)
+    
     def test_mark_email_as_read_failure(self, auth_headers, mock_provider):
         """Test failed marking email as read."""
         with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
@@ -199,6 +293,7 @@ class TestEmailAPI:
             
             assert len(data["folders"]) == 3
             assert data["total"] == 3
+            assert data["cache_age_seconds"] is None
             
             # Check folder structure
             folder = data["folders"][0]
@@ -238,7 +333,18 @@ class TestEmailAPI:
             assert len(data["emails"]) == 0
             assert data["total"] == 0
     
-    def test_batch_process_emails_success(self, auth_headers, mock_provider):
+    @pytest.fixture
+    def stub_ai(self):
+        """Stub AI client classifying everything as fyi."""
+        ai = Mock()
+        ai.classify_email_async = AsyncMock(return_value={
+            "category": "fyi", "confidence": 0.9, "reasoning": "Informational"
+        })
+        app.dependency_overrides[get_ai_service] = lambda: ai
+        yield ai
+        app.dependency_overrides.pop(get_ai_service, None)
+    
+    def test_batch_process_emails_success(self, auth_headers, mock_provider, stub_ai):
         """Test successful batch email processing."""
         with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
             mock_get_provider.return_value = mock_provider
@@ -248,7 +354,8 @@ class TestEmailAPI:
                     {"id": "mock-email-1"},
                     {"id": "mock-email-2"}
                 ],
-                "context": "test batch processing"
+                "context": "test batch processing",
+                "skip_already_processed": False
             }
             
             response = client.post("/api/emails/batch-process", json=batch_request, headers=auth_headers)
@@ -270,7 +377,7 @@ class TestEmailAPI:
             assert "action_items" in result
             assert "priority" in result
     
-    def test_batch_process_emails_with_failures(self, auth_headers, mock_provider):
+    def test_batch_process_emails_with_failures(self, auth_headers, mock_provider, stub_ai):
         """Test batch email processing with some failures."""
         with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
             mock_get_provider.return_value = mock_provider
@@ -278,21 +385,75 @@ class TestEmailAPI:
             batch_request = {
                 "emails": [
                     {"id": "mock-email-1"},
-                    {"id": "non-existing"},
-                    {"invalid": "data"}
-                ]
+                    {"id": "non-existing"}
+                ],
+                "skip_already_processed": False
             }
             
             response = client.post("/api/emails/batch-process", json=batch_request, headers=auth_headers)
             
-            assert response.status_code == 200
+            assert response.status_code == 207
             data = response.json()
             
-            assert data["processed_count"] == 3
+            assert data["processed_count"] == 2
             assert data["successful_count"] == 1  # Only mock-email-1 succeeds
-            assert data["failed_count"] > 0
+            assert data["failed_count"] == 1
             assert len(data["results"]) == 1
-            assert len(data["errors"]) > 0
+            assert data["errors"] == [{
+                "email_id": "non-existing", "error": "Email not found", "status_code": 404, "is_retryable": False
+            }]
+    
+    def test_batch_process_ai_unavailable(self, auth_headers, mock_provider, stub_ai):
+        """Test that AI failures are retryable item errors and fail the whole batch."""
+        stub_ai.classify_email_async.side_effect = RuntimeError("model unavailable")
+        with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
+            mock_get_provider.return_value = mock_provider
+            
+            response = client.post("/api/emails/batch-process", json={
+                "emails": [{"id": "mock-email-1"}, {"id": "mock-email-2"}],
+                "thread_mode": False,
+                "skip_already_processed": False
+            }, headers=auth_headers)
+        
+        assert response.status_code == 502
+        data = response.json()
+        assert data["successful_count"] == 0
+        assert [(e["email_id"], e["status_code"], e["is_retryable"]) for e in data["errors"]] == [
+            ("mock-email-1", 502, True), ("mock-email-2", 502, True)
+        ]
+        assert data["results"][0]["category"] == "unclassified"
+    
+    def test_batch_process_thread_mode(self, auth_headers, mock_provider):
+        """Test that thread mode classifies each conversation once."""
+        mock_provider.mock_emails.append({
+            **mock_provider.mock_emails[0],
+            'id': 'mock-email-1-reply',
+            'received_time': '2024-01-01T12:00:00Z'
+        })
+        stub_ai = Mock()
+        stub_ai.classify_email_async = AsyncMock(return_value={
+            "category": "team_action", "confidence": 0.9, "reasoning": "Thread needs a reply"
+        })
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        
+        try:
+            with patch('backend.services.email_provider.get_email_provider_instance') as mock_get_provider:
+                mock_get_provider.return_value = mock_provider
+                
+                response = client.post("/api/emails/batch-process", json={
+                    "emails": [{"id": "mock-email-1"}, {"id": "mock-email-1-reply"}, {"id": "mock-email-2"}],
+                    "thread_mode": True,
+                    "skip_already_processed": False
+                }, headers=auth_headers)
+        finally:
+            app.dependency_overrides.pop(get_ai_service, None)
+        
+        assert response.status_code == 200
+        data = response.json()
+        assert data["ai_calls"] == 2
+        assert data["ai_calls_saved"] == 1
+        assert data["results"][0]["inherited_from"] == "mock-email-1-reply"
+        assert data["results"][2]["inherited_from"] is None
     
     def test_api_parameter_validation(self, auth_headers):
         """Test API parameter validation."""
@@ -319,4 +480,6 @@ class TestEmailAPI:
             
             assert response.status_code == 500
             data = response.json()
-            assert "Failed to retrieve emails" in data["message"]
\ No newline at end of file
+            assert data["error"]["code"] == "internal_error"
+            assert "Failed to retrieve emails" in data["error"]["message"]
+            assert data["error"]["request_id"]
\ No newline at end of file
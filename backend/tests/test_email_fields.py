@@ -0,0 +1,191 @@
+"""Tests for the fields projection on email list responses."""
+
+import asyncio
+import json
+from datetime import datetime, timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.email_filters import EMAIL_FIELDS, project_email, stored_columns, validate_fields
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+LIST_FIELDS = "id,subject,sender,received_date,category,one_line_summary"
+
+
+@pytest.fixture
+def service():
+    """Email service over the mock mailbox and an in-memory store with two emails."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    service = EmailService(provider, db=db)
+    for email_id in ("stored-1", "stored-2"):
+        asyncio.run(service.save_classification(
+            {"id": email_id, "subject": f"Subject {email_id}", "sender": "a@example.com",
+             "body": "A long body", "received_time": "2024-01-02T09:00:00"},
+            EmailClassification(category="fyi", confidence=0.9, summary="Short summary"),
+            USER_ID
+        ))
+    yield service
+    db.close()
+
+
+@pytest.fixture
+def client(service):
+    """Client with auth and the email service overridden."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="lister", email="lister@example.com",
+        hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: service
+    app.dependency_overrides[get_ai_service] = lambda: None
+    return TestClient(app)
+
+
+class TestValidateFields:
+    """Tests for parsing the fields parameter."""
+
+    def test_parses_and_keeps_id(self):
+        """Test that names are trimmed, deduplicated, and always include id."""
+        assert validate_fields(" subject, sender ,subject") == ["id", "subject", "sender"]
+        assert validate_fields("sender,id") == ["sender", "id"]
+
+    @pytest.mark.parametrize("fields", [None, "", " , "])
+    def test_empty_keeps_everything(self, fields):
+        """Test that a missing or empty parameter means no projection."""
+        assert validate_fields(fields) is None
+
+    def test_unknown_lists_valid_names(self):
+        """Test that unknown names are rejected with the allowlist."""
+        with pytest.raises(ValueError) as excinfo:
+            validate_fields("subject,password,raw_content")
+
+        message = str(excinfo.value)
+        assert "password, raw_content" in message
+        assert all(name in message for name in EMAIL_FIELDS)
+
+    def test_stored_columns(self):
+        """Test that provider-only keys are not selected from the database."""
+        assert stored_columns(["id", "body", "received_time", "content"]) == ["id", "content"]
+        assert stored_columns(None) is None
+
+    def test_project_email(self):
+        """Test that missing keys are skipped rather than added."""
+        email = {"id": "1", "subject": "Hi", "body": "Hello"}
+
+        assert project_email(email, ["id", "subject", "category"]) == {"id": "1", "subject": "Hi"}
+        assert project_email(email, None) is email
+
+
+class TestStoredProjection:
+    """Tests for selecting only projected columns from stored emails."""
+
+    @pytest.mark.asyncio
+    async def test_body_columns_not_selected(self, service):
+        """Test that stored rows carry only the requested columns."""
+        emails, total = await service.search_stored_emails(
+            USER_ID, {"category": "fyi"}, columns=["id", "subject", "one_line_summary"]
+        )
+
+        assert total == 2
+        assert emails[0] == {"id": emails[0]["id"], "subject": emails[0]["subject"],
+                             "one_line_summary": "Short summary"}
+
+    @pytest.mark.asyncio
+    async def test_content_still_sanitized(self, service):
+        """Test that a projected content column is sanitized as usual."""
+        with service.db.get_connection() as conn:
+            conn.execute("UPDATE emails SET content = '<script>alert(1)</script>Hi' WHERE id = 'stored-1'")
+            conn.commit()
+
+        emails, _ = await service.search_stored_emails(
+            USER_ID, {"sender": "a@example.com"}, columns=["id", "content"]
+        )
+
+        assert all(set(email) == {"id", "content"} for email in emails)
+        assert all("<script>" not in email["content"] for email in emails)
+
+
+class TestListEndpoint:
+    """Tests for GET /api/emails?fields=..."""
+
+    def test_provider_list_excludes_body(self, client):
+        """Test that provider emails drop the body when it is not requested."""
+        response = client.get("/api/emails?fields=id,subject,received_time")
+
+        assert response.status_code == 200
+        emails = response.json()["emails"]
+        assert [set(email) for email in emails] == [{"id", "subject", "received_time"}] * 2
+
+    def test_stored_search_excludes_content(self, client):
+        """Test that filtered searches return only the requested keys."""
+        response = client.get(f"/api/emails?category=fyi&fields={LIST_FIELDS}")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["total"] == 2
+        for email in data["emails"]:
+            assert "content" not in email
+            assert set(email) == set(LIST_FIELDS.split(","))
+
+    def test_content_when_requested(self, client):
+        """Test that the body comes back when it is listed."""
+        emails = client.get("/api/emails?category=fyi&fields=content").json()["emails"]
+
+        assert all(set(email) == {"id", "content"} for email in emails)
+
+    def test_without_fields_unchanged(self, client):
+        """Test that omitting fields returns full emails."""
+        emails = client.get("/api/emails").json()["emails"]
+
+        assert "body" in emails[0]
+
+    def test_unknown_field_is_400(self, client):
+        """Test that unknown names return 400 listing the valid ones."""
+        response = client.get("/api/emails?fields=id,secret")
+
+        assert response.status_code == 400
+        error = response.json()["error"]
+        assert error["code"] == "bad_request"
+        assert "secret" in error["message"]
+        assert "one_line_summary" in error["message"]
+
+    @pytest.mark.slow
+    def test_payload_size_benchmark(self, client, service):
+        """Benchmark list payload size with and without a projection."""
+        now = datetime.now()
+        body = "Quarterly planning notes. " * 200
+        with service.db.get_connection() as conn:
+            conn.executemany(
+                """
+                INSERT INTO emails (id, subject, sender, content, raw_content, received_date,
+                                    category, one_line_summary, user_id)
+                VALUES (?, 'Planning', 'boss@example.com', ?, ?, ?, 'fyi', 'Planning notes', 1)
+                """,
+                [(f"bench-{i}", body, body, now - timedelta(minutes=i)) for i in range(100)]
+            )
+            conn.commit()
+
+        full = client.get("/api/emails?category=fyi&limit=100").json()
+        slim = client.get(f"/api/emails?category=fyi&limit=100&fields={LIST_FIELDS}").json()
+
+        full_bytes = len(json.dumps(full))
+        slim_bytes = len(json.dumps(slim))
+        print(f"100 emails: {full_bytes} bytes full, {slim_bytes} bytes with fields")
+        assert len(slim["emails"]) == len(full["emails"]) == 100
+        assert slim_bytes * 10 < full_bytes
@@ -0,0 +1,240 @@
+"""Tests for notes on stored emails."""
+
+from datetime import datetime, timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.quarantine import QUARANTINE_FOLDER
+
+USER_ID = 1
+NOW = datetime(2024, 3, 10, 12, 0, 0)
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock mailbox."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return provider
+
+
+@pytest.fixture
+async def service(provider):
+    """Email service over an isolated store with both mock emails classified."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    for email in provider.mock_emails:
+        await email_service.save_classification(
+            email, EmailClassification(category="fyi", confidence=0.9), USER_ID
+        )
+    yield email_service
+    store.close()
+
+
+def note_rows(service):
+    """(email_id, text) of every stored note."""
+    with service.db.get_connection() as conn:
+        rows = conn.execute("SELECT email_id, text FROM email_notes ORDER BY id").fetchall()
+    return [tuple(row) for row in rows]
+
+
+class TestNoteStore:
+    """Tests for adding, listing, and deleting notes through EmailService."""
+
+    @pytest.mark.asyncio
+    async def test_add_and_list_newest_first(self, service):
+        """Test that notes are listed newest first and only for their email."""
+        await service.add_note("mock-email-1", USER_ID, "Called back")
+        second = await service.add_note("mock-email-1", USER_ID, "Waiting on the invoice")
+        await service.add_note("mock-email-2", USER_ID, "Other email")
+
+        notes = await service.get_notes("mock-email-1", USER_ID)
+
+        assert [note["text"] for note in notes] == ["Waiting on the invoice", "Called back"]
+        assert notes[0]["id"] == second["id"]
+        assert await service.get_note_counts(USER_ID, ["mock-email-1", "mock-email-2", "other"]) == {
+            "mock-email-1": 2, "mock-email-2": 1
+        }
+
+    @pytest.mark.asyncio
+    async def test_notes_need_a_stored_email_of_the_user(self, service):
+        """Test that unknown and other users' emails cannot get notes."""
+        with pytest.raises(LookupError):
+            await service.add_note("not-stored", USER_ID, "Hi")
+        with pytest.raises(LookupError):
+            await service.add_note("mock-email-1", USER_ID + 1, "Hi")
+        assert note_rows(service) == []
+
+    @pytest.mark.asyncio
+    async def test_delete(self, service):
+        """Test that a note is deleted once, and only from its own email."""
+        note = await service.add_note("mock-email-1", USER_ID, "Called back")
+
+        with pytest.raises(LookupError):
+            await service.delete_note("mock-email-2", note["id"], USER_ID)
+        await service.delete_note("mock-email-1", note["id"], USER_ID)
+        with pytest.raises(LookupError):
+            await service.delete_note("mock-email-1", note["id"], USER_ID)
+        assert note_rows(service) == []
+
+
+class TestNotesInListsAndSearch:
+    """Tests for note_count in stored lists and matching notes in searches."""
+
+    @pytest.mark.asyncio
+    async def test_note_count_in_stored_lists(self, service):
+        """Test that searches and conversation groups carry note_count unless projected out."""
+        await service.add_note("mock-email-1", USER_ID, "Called back")
+
+        emails, _ = await service.search_stored_emails(USER_ID, {"category": "fyi"})
+        groups, _ = await service.get_emails_grouped_by_conversation(USER_ID, {})
+        projected, _ = await service.search_stored_emails(
+            USER_ID, {"category": "fyi"}, columns=["id", "note_count"]
+        )
+
+        assert {email["id"]: email["note_count"] for email in emails} == {"mock-email-1": 1, "mock-email-2": 0}
+        assert {group["id"]: group["note_count"] for group in groups} == {"mock-email-1": 1, "mock-email-2": 0}
+        assert sorted(projected, key=lambda email: email["id"]) == [
+            {"id": "mock-email-1", "note_count": 1}, {"id": "mock-email-2", "note_count": 0}
+        ]
+
+    @pytest.mark.asyncio
+    async def test_search_matches_note_text(self, service):
+        """Test that a query found only in a note matches, with notes in matched_fields."""
+        await service.add_note("mock-email-2", USER_ID, "Follow up about the invoice with Dana")
+
+        emails, total = await service.search_stored_emails(USER_ID, {}, query="invoice")
+
+        assert total == 1
+        assert emails[0]["id"] == "mock-email-2"
+        assert "notes" not in emails[0]
+        assert emails[0]["search_meta"]["matched_fields"] == ["notes"]
+        assert "<em>invoice</em>" in emails[0]["search_meta"]["snippet"]
+
+    @pytest.mark.asyncio
+    async def test_other_users_notes_do_not_match(self, service):
+        """Test that a note only makes its owner's email match."""
+        with service.db.get_connection() as conn:
+            conn.execute(
+                "INSERT INTO email_notes (email_id, user_id, text, created_at) VALUES (?, ?, ?, ?)",
+                ("mock-email-1", USER_ID + 1, "invoice", NOW)
+            )
+            conn.commit()
+
+        assert (await service.search_stored_emails(USER_ID, {}, query="invoice"))[1] == 0
+
+
+class TestCascade:
+    """Tests for deleting notes along with their email's row."""
+
+    @pytest.mark.asyncio
+    async def test_purge_deletes_notes(self, service, monkeypatch):
+        """Test that purging a quarantined email deletes its notes and keeps the others'."""
+        monkeypatch.setattr(settings, "spam_quarantine_days", 7)
+        await service.add_note("mock-email-1", USER_ID, "Spam, ignore")
+        await service.add_note("mock-email-2", USER_ID, "Keep")
+        with service.db.get_connection() as conn:
+            conn.execute(
+                "UPDATE emails SET folder = ?, category = 'spam_to_delete', quarantined_until = ? WHERE id = ?",
+                (QUARANTINE_FOLDER, str(NOW - timedelta(days=1)), "mock-email-1")
+            )
+            conn.commit()
+
+        assert await service.purge_quarantine(now=NOW) == ["mock-email-1"]
+        assert note_rows(service) == [("mock-email-2", "Keep")]
+
+    @pytest.mark.asyncio
+    async def test_reconcile_deletes_notes(self, service, provider):
+        """Test that removing a row gone from the mailbox deletes its notes."""
+        await service.add_note("mock-email-1", USER_ID, "Gone soon")
+        provider.mock_emails = [email for email in provider.mock_emails if email["id"] != "mock-email-1"]
+
+        await service.reconcile_folder(USER_ID, "Inbox", apply=True)
+
+        assert note_rows(service) == []
+
+
+class TestNotesAPI:
+    """Tests for the /emails/{id}/notes endpoints and notes in other responses."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="annotator", email="annotator@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: None
+        return TestClient(app)
+
+    def test_add_list_delete(self, client):
+        """Test a note's round trip through the endpoints."""
+        response = client.post("/api/emails/mock-email-1/notes", json={"text": "Called back"})
+
+        assert response.status_code == 201
+        note = response.json()
+        assert (note["email_id"], note["text"]) == ("mock-email-1", "Called back")
+
+        data = client.get("/api/emails/mock-email-1/notes").json()
+        assert (data["total"], data["notes"][0]["id"]) == (1, note["id"])
+
+        assert client.delete(f"/api/emails/mock-email-1/notes/{note['id']}").status_code == 200
+        assert client.get("/api/emails/mock-email-1/notes").json()["total"] == 0
+        assert client.delete(f"/api/emails/mock-email-1/notes/{note['id']}").status_code == 404
+
+    def test_unknown_email(self, client):
+        """Test that notes of emails that are not stored are 404."""
+        assert client.post("/api/emails/not-stored/notes", json={"text": "Hi"}).status_code == 404
+        assert client.get("/api/emails/not-stored/notes").status_code == 404
+
+    @pytest.mark.parametrize("body", [{}, {"text": ""}, {"text": "   "}, {"text": "x" * 4001}])
+    def test_invalid_note(self, client, body):
+        """Test that missing, blank, and overlong notes are rejected."""
+        assert client.post("/api/emails/mock-email-1/notes", json=body).status_code == 422
+
+    def test_detail_has_latest_note(self, client):
+        """Test that GET /emails/{id} carries the note count and the newest note's text."""
+        assert client.get("/api/emails/mock-email-1").json()["latest_note"] is None
+        client.post("/api/emails/mock-email-1/notes", json={"text": "First"})
+        client.post("/api/emails/mock-email-1/notes", json={"text": "Second"})
+
+        data = client.get("/api/emails/mock-email-1").json()
+
+        assert (data["note_count"], data["latest_note"]) == (2, "Second")
+
+    def test_lists_have_note_count(self, client):
+        """Test that provider and stored lists count each email's notes."""
+        client.post("/api/emails/mock-email-2/notes", json={"text": "Re-read"})
+
+        provider_list = client.get("/api/emails").json()["emails"]
+        stored_list = client.get("/api/emails?category=fyi").json()["emails"]
+        projected = client.get("/api/emails?fields=id,subject").json()["emails"]
+
+        assert {email["id"]: email["note_count"] for email in provider_list} == {"mock-email-1": 0, "mock-email-2": 1}
+        assert {email["id"]: email["note_count"] for email in stored_list} == {"mock-email-1": 0, "mock-email-2": 1}
+        assert all("note_count" not in email for email in projected)
+
+    def test_search_endpoint_finds_notes(self, client):
+        """Test that GET /emails/search matches note text."""
+        client.post("/api/emails/mock-email-1/notes", json={"text": "Ask Priya about the rollout"})
+
+        data = client.get("/api/emails/search", params={"q": "rollout"}).json()
+
+        assert [email["id"] for email in data["emails"]] == ["mock-email-1"]
+        assert data["emails"][0]["search_meta"]["matched_fields"] == ["notes"]
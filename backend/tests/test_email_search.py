@@ -0,0 +1,189 @@
+"""Tests for free-text email search with match metadata."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.core.search import highlight_snippet, search_meta, validate_query
+from backend.core.sanitize import plain_text
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+LONG_BODY = (
+    "Thanks for joining the planning call yesterday. As discussed we will move the "
+    "quarterly budget review to Friday afternoon so finance can attend. Please send "
+    "your slides before Thursday noon and keep them short."
+)
+
+# (id, subject, sender, content, category)
+EMAILS = [
+    ("subject-hit", "Budget approved", "cfo@example.com", "See you at the offsite.", "fyi"),
+    ("body-hit", "Planning call notes", "pm@example.com", LONG_BODY, "team_action"),
+    ("html-hit", "Newsletter", "news@example.com",
+     "<p>Our <b>budget</b> tips &amp; tricks <script>budget()</script></p>", "newsletter"),
+    ("miss", "Lunch", "friend@example.com", "Free at noon?", "fyi"),
+]
+
+
+@pytest.fixture
+def service():
+    """Email service over an in-memory store with the EMAILS rows."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        conn.executemany(
+            """
+            INSERT INTO emails (id, subject, sender, content, category, received_date, user_id)
+            VALUES (?, ?, ?, ?, ?, ?, ?)
+            """,
+            [(*email, datetime(2024, 1, 1 + i), USER_ID) for i, email in enumerate(EMAILS)]
+        )
+        conn.commit()
+    yield EmailService(MockEmailProvider(), db=db)
+    db.close()
+
+
+@pytest.fixture
+def client(service):
+    """Client with auth and the email service overridden."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="seeker", email="seeker@example.com",
+        hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: service
+    app.dependency_overrides[get_ai_service] = lambda: None
+    return TestClient(app)
+
+
+def search(client, q, **params):
+    """Search and return the emails keyed by id."""
+    response = client.get("/api/emails/search", params={"q": q, **params})
+    assert response.status_code == 200
+    return {email["id"]: email for email in response.json()["emails"]}
+
+
+class TestHighlightSnippet:
+    """Tests for cutting highlighted excerpts."""
+
+    def test_short_text_highlighted_in_place(self):
+        """Test that every match is wrapped, keeping its original case."""
+        assert highlight_snippet("Budget and more budget", "budget") == (
+            "<em>Budget</em> and more <em>budget</em>"
+        )
+
+    def test_long_text_is_excerpted(self):
+        """Test that long text is cut around the first match at word boundaries."""
+        snippet = highlight_snippet(LONG_BODY, "budget", context=30)
+
+        assert snippet.startswith("…")
+        assert snippet.endswith("…")
+        assert "<em>budget</em>" in snippet
+        excerpt = snippet.strip("…").replace("<em>", "").replace("</em>", "")
+        assert excerpt in LONG_BODY
+        assert LONG_BODY[LONG_BODY.index(excerpt) - 1] == " "
+
+    def test_html_is_escaped(self):
+        """Test that markup in the text cannot survive into the snippet."""
+        snippet = highlight_snippet('<img src=x onerror="alert(1)"> & <b>', "<b>")
+
+        assert snippet == "&lt;img src=x onerror=&quot;alert(1)&quot;&gt; &amp; <em>&lt;b&gt;</em>"
+
+    def test_no_match(self):
+        """Test that text without the query is excerpted from the start."""
+        assert highlight_snippet("Nothing here", "budget") == "Nothing here"
+
+
+class TestSearchMeta:
+    """Tests for describing a match."""
+
+    def test_subject_only(self):
+        """Test that a subject match is snipped from the subject."""
+        meta = search_meta({"subject": "Budget approved", "content": "See you", "sender": "a@b.c"}, "budget")
+
+        assert meta == {"matched_fields": ["subject"], "snippet": "<em>Budget</em> approved"}
+
+    def test_body_preferred(self):
+        """Test that the body is snipped when both subject and body match."""
+        meta = search_meta({"subject": "Budget", "content": "The budget is fine", "sender": "x"}, "budget")
+
+        assert meta["matched_fields"] == ["subject", "content"]
+        assert meta["snippet"] == "The <em>budget</em> is fine"
+
+    def test_html_body_uses_visible_text(self):
+        """Test that tags and script contents are not part of the snippet."""
+        meta = search_meta({"content": EMAILS[2][3]}, "budget")
+
+        assert meta["snippet"] == "Our <em>budget</em> tips &amp; tricks"
+
+    def test_plain_text(self):
+        """Test converting bodies to collapsed visible text."""
+        assert plain_text("<div>Hello<br>there</div><style>p{}</style>") == "Hello there"
+        assert plain_text("  plain\n text ") == "plain text"
+        assert plain_text(None) == ""
+
+
+class TestSearchEndpoint:
+    """Tests for GET /api/emails/search."""
+
+    def test_matches_subject_body_and_html(self, client):
+        """Test that every field is searched and non-matches are left out."""
+        results = search(client, "budget")
+
+        assert set(results) == {"subject-hit", "body-hit", "html-hit"}
+        assert results["subject-hit"]["search_meta"] == {
+            "matched_fields": ["subject"], "snippet": "<em>Budget</em> approved"
+        }
+        body_meta = results["body-hit"]["search_meta"]
+        assert body_meta["matched_fields"] == ["content"]
+        assert "quarterly <em>budget</em> review" in body_meta["snippet"]
+
+    def test_sender_match(self, client):
+        """Test that sender matches are reported and snipped from the sender."""
+        results = search(client, "cfo@")
+
+        assert results["subject-hit"]["search_meta"] == {
+            "matched_fields": ["sender"], "snippet": "<em>cfo@</em>example.com"
+        }
+
+    def test_snippets_escape_html(self, client):
+        """Test that HTML in stored content is escaped in the snippet."""
+        snippet = search(client, "tricks")["html-hit"]["search_meta"]["snippet"]
+
+        assert "<b>" not in snippet and "<script>" not in snippet
+        assert snippet == "Our budget tips &amp; <em>tricks</em>"
+
+    def test_like_wildcards_are_literal(self, client):
+        """Test that % and _ in the query match only themselves."""
+        assert search(client, "%") == {}
+        assert search(client, "_") == {}
+
+    def test_filters(self, client):
+        """Test that category narrows the search."""
+        assert set(search(client, "budget", category="fyi")) == {"subject-hit"}
+
+    def test_list_endpoint_has_no_search_meta(self, client):
+        """Test that search_meta only appears on the search endpoint."""
+        emails = client.get("/api/emails?category=fyi").json()["emails"]
+
+        assert emails and all("search_meta" not in email for email in emails)
+
+    @pytest.mark.parametrize("q", ["", "   ", "x" * 201])
+    def test_invalid_query(self, client, q):
+        """Test that empty and overlong queries are rejected."""
+        assert client.get("/api/emails/search", params={"q": q}).status_code == 422
+
+    def test_validate_query_trims(self):
+        """Test that queries are trimmed."""
+        assert validate_query("  budget ") == "budget"
@@ -0,0 +1,327 @@
+"""Tests for the email service layer."""
+
+import asyncio
+import threading
+import time
+from datetime import datetime, timedelta
+
+import pytest
+from unittest.mock import MagicMock
+
+from backend.database.connection import DatabaseManager
+from backend.services.email_service import EmailService, cancel_on_disconnect
+
+
+class SlowProvider:
+    """Provider stub whose calls block until released or time out."""
+
+    def __init__(self, delay: float = 2.0):
+        self.delay = delay
+        self.started = threading.Event()
+
+    def get_emails(self, folder_name="Inbox", count=50, offset=0):
+        self.started.set()
+        time.sleep(self.delay)
+        return [{"id": "slow-1"}]
+
+    def get_email_content(self, email_id):
+        self.started.set()
+        time.sleep(self.delay)
+        return {"id": email_id}
+
+
+class FakeRequest:
+    """Minimal request stub exposing is_disconnected()."""
+
+    def __init__(self, disconnect_after: float):
+        self.url = MagicMock(path="/api/emails")
+        self._deadline = time.monotonic() + disconnect_after
+
+    async def is_disconnected(self):
+        return time.monotonic() >= self._deadline
+
+
+class TestEmailService:
+    """Tests for EmailService provider delegation and cancellation."""
+
+    @pytest.fixture
+    def db(self):
+        """Create an isolated in-memory database store."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        yield store
+        store.close()
+
+    @pytest.mark.asyncio
+    async def test_get_emails_delegates_to_provider(self, db):
+        """Test that get_emails forwards arguments to the provider."""
+        provider = MagicMock()
+        provider.get_emails.return_value = [{"id": "1"}]
+        service = EmailService(provider, db=db)
+
+        emails = await service.get_emails(folder_name="Archive", count=10, offset=5)
+
+        assert emails == [{"id": "1"}]
+        provider.get_emails.assert_called_once_with(folder_name="Archive", count=10, offset=5)
+
+    @pytest.mark.asyncio
+    async def test_get_email_by_id_returns_none_when_missing(self, db):
+        """Test that a missing email is returned as None."""
+        provider = MagicMock()
+        provider.get_email_content.return_value = None
+        service = EmailService(provider, db=db)
+
+        assert await service.get_email_by_id("missing") is None
+
+    @pytest.mark.asyncio
+    async def test_get_email_by_id_reports_privacy_signals(self, db):
+        """Test that details report the read receipt request and external image hosts."""
+        provider = MagicMock()
+        provider.get_email_content.return_value = {
+            "id": "abc",
+            "body": "Plain text version",
+            "html_body": '<p>Hi</p><img src="https://images.example.com/a.png">',
+            "requests_read_receipt": True,
+        }
+        service = EmailService(provider, db=db)
+
+        email = await service.get_email_by_id("abc")
+
+        assert email["requests_read_receipt"] is True
+        assert email["external_image_domains"] == ["images.example.com"]
+        assert "html_body" not in email
+
+    @pytest.mark.asyncio
+    async def test_cancelled_call_returns_promptly(self, db):
+        """Test that cancelling a slow provider call raises CancelledError quickly."""
+        provider = SlowProvider(delay=2.0)
+        service = EmailService(provider, db=db)
+
+        task = asyncio.ensure_future(service.get_emails())
+        await asyncio.get_event_loop().run_in_executor(None, provider.started.wait, 1.0)
+
+        start = time.monotonic()
+        task.cancel()
+        with pytest.raises(asyncio.CancelledError):
+            await task
+
+        assert time.monotonic() - start < 0.5
+
+    @pytest.mark.asyncio
+    async def test_cancel_on_disconnect_stops_work(self, db):
+        """Test that a client disconnect cancels the in-flight service call."""
+        provider = SlowProvider(delay=2.0)
+        service = EmailService(provider, db=db)
+        request = FakeRequest(disconnect_after=0.1)
+
+        start = time.monotonic()
+        with pytest.raises(asyncio.CancelledError):
+            await cancel_on_disconnect(
+                request, service.get_email_by_id("abc"), poll_interval=0.05
+            )
+
+        assert time.monotonic() - start < 1.0
+
+    @pytest.mark.asyncio
+    async def test_cancel_on_disconnect_returns_result(self, db):
+        """Test that a connected client receives the service result."""
+        provider = MagicMock()
+        provider.get_email_content.return_value = {"id": "abc"}
+        service = EmailService(provider, db=db)
+        request = FakeRequest(disconnect_after=60)
+
+        result = await cancel_on_disconnect(request, service.get_email_by_id("abc"))
+
+        assert result == {"id": "abc", "source": "outlook"}
+
+
+def insert_email(db, email_id, user_id=1, category="fyi", folder="Inbox", is_read=0,
+                 is_flagged=0, needs_review=0, received_date=None):
+    """Insert a stored email row for counter tests."""
+    with db.get_connection() as conn:
+        conn.execute(
+            """
+            INSERT INTO emails (id, subject, sender, received_date, category, folder,
+                                is_read, is_flagged, needs_review, user_id)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+            """,
+            (email_id, "Subject", "sender@example.com", received_date or datetime.now(),
+             category, folder, is_read, is_flagged, needs_review, user_id)
+        )
+        conn.commit()
+
+
+class TestGetEmailSource:
+    """Tests for choosing where get_email_by_id reads from."""
+
+    @pytest.fixture
+    def service(self):
+        """Create a service over a mock provider and an isolated in-memory store."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        provider = MagicMock()
+        provider.get_email_content.return_value = None
+        yield EmailService(provider, db=store)
+        store.close()
+
+    @pytest.mark.asyncio
+    async def test_auto_database_hit(self, service):
+        """Test that a stored email is served without asking the provider."""
+        insert_email(service.db, "stored")
+
+        email = await service.get_email_by_id("stored", user_id=1, source="auto")
+
+        assert (email["id"], email["source"]) == ("stored", "database")
+        service.provider.get_email_content.assert_not_called()
+
+    @pytest.mark.asyncio
+    async def test_auto_outlook_hit(self, service):
+        """Test that an email only the provider has is enriched and persisted on request."""
+        service.provider.get_email_content.return_value = {
+            "id": "remote", "subject": "Hi", "sender": "a@example.com",
+            "body": '<p>Hi</p><img src="https://img.example.com/x.png">', "is_read": True,
+        }
+
+        email = await service.get_email_by_id("remote", user_id=1, source="auto", persist=True)
+
+        assert email["source"] == "outlook"
+        assert email["external_image_domains"] == ["img.example.com"]
+        stored = await service.get_stored_email("remote", 1, include_raw=True)
+        assert (stored["subject"], stored["is_read"], stored["category"]) == ("Hi", 1, None)
+        assert "img.example.com" in stored["raw_content"]
+
+    @pytest.mark.asyncio
+    async def test_auto_outlook_hit_not_persisted_by_default(self, service):
+        """Test that provider emails are only stored with persist."""
+        service.provider.get_email_content.return_value = {"id": "remote", "body": "Hi"}
+
+        assert (await service.get_email_by_id("remote", user_id=1, source="auto"))["source"] == "outlook"
+        assert await service.get_stored_email("remote", 1) is None
+
+    @pytest.mark.asyncio
+    async def test_auto_both_miss(self, service):
+        """Test that an email in neither store is None."""
+        assert await service.get_email_by_id("ghost", user_id=1, source="auto") is None
+        service.provider.get_email_content.assert_called_once_with("ghost")
+
+    @pytest.mark.asyncio
+    async def test_explicit_sources(self, service):
+        """Test that outlook and database read only their own store."""
+        insert_email(service.db, "stored")
+
+        assert await service.get_email_by_id("stored", user_id=1, source="outlook") is None
+        service.provider.get_email_content.return_value = {"id": "remote", "body": "Hi"}
+        assert await service.get_email_by_id("remote", user_id=1, source="database") is None
+
+    @pytest.mark.asyncio
+    async def test_invalid_source(self, service):
+        """Test that unknown sources and database reads without a user are rejected."""
+        with pytest.raises(ValueError):
+            await service.get_email_by_id("x", source="everywhere")
+        with pytest.raises(ValueError):
+            await service.get_email_by_id("x", source="auto")
+
+
+class TestEmailCounters:
+    """Tests for grouped email counters."""
+
+    @pytest.fixture
+    def service(self):
+        """Create a service over an isolated in-memory store."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        yield EmailService(MagicMock(), db=store)
+        store.close()
+
+    @pytest.mark.asyncio
+    async def test_counts_by_category_and_folder(self, service):
+        """Test totals and unread counts per category and folder."""
+        insert_email(service.db, "1", category="fyi", is_read=0)
+        insert_email(service.db, "2", category="fyi", is_read=1)
+        insert_email(service.db, "3", category="required_personal_action", folder="Projects",
+                     is_flagged=1, needs_review=1)
+        insert_email(service.db, "4", category=None, folder="Projects", is_read=1)
+
+        counters = await service.get_counters(user_id=1)
+
+        assert counters.by_category["fyi"].total == 2
+        assert counters.by_category["fyi"].unread == 1
+        assert counters.by_category["required_personal_action"].unread == 1
+        assert counters.by_category["uncategorized"].total == 1
+        assert counters.by_folder["Inbox"].total == 2
+        assert counters.by_folder["Projects"].total == 2
+        assert counters.by_folder["Projects"].unread == 1
+        assert counters.needs_review == 1
+        assert counters.flagged == 1
+
+    @pytest.mark.asyncio
+    async def test_counts_are_user_scoped(self, service):
+        """Test that other users' emails are not counted."""
+        insert_email(service.db, "1", user_id=1)
+        insert_email(service.db, "2", user_id=2)
+
+        counters = await service.get_counters(user_id=1)
+
+        assert counters.by_folder["Inbox"].total == 1
+
+    @pytest.mark.asyncio
+    async def test_since_limits_to_recent_mail(self, service):
+        """Test that since excludes older emails."""
+        now = datetime.now()
+        insert_email(service.db, "old", received_date=now - timedelta(days=10))
+        insert_email(service.db, "new", received_date=now)
+
+        counters = await service.get_counters(user_id=1, since=now - timedelta(days=1))
+
+        assert counters.by_category["fyi"].total == 1
+
+    @pytest.mark.asyncio
+    async def test_since_inside_a_day(self, service):
+        """Test that since compares times, not strings, against ISO received dates."""
+        insert_email(service.db, "morning", received_date="2024-01-02T09:00:00")
+        insert_email(service.db, "afternoon", received_date="2024-01-02T15:00:00")
+        insert_email(service.db, "utc", received_date="2024-01-02T13:00:00Z")
+
+        counters = await service.get_counters(user_id=1, since=datetime(2024, 1, 2, 12))
+
+        assert counters.by_category["fyi"].total == 2
+
+    @pytest.mark.asyncio
+    async def test_empty_store(self, service):
+        """Test counters for a user with no stored emails."""
+        counters = await service.get_counters(user_id=1)
+
+        assert counters.by_category == {}
+        assert counters.needs_review == 0
+
+    @pytest.mark.slow
+    @pytest.mark.asyncio
+    async def test_counters_benchmark_50k_rows(self, service):
+        """Benchmark the grouped counter query on 50k rows."""
+        categories = ["fyi", "newsletter", "team_action", "required_personal_action",
+                      "optional_action", "job_listing", "spam_to_delete"]
+        folders = ["Inbox", "Projects", "Archive", "Newsletters"]
+        now = datetime.now()
+        with service.db.get_connection() as conn:
+            conn.executemany(
+                """
+                INSERT INTO emails (id, subject, sender, received_date, category, folder,
+                                    is_read, user_id)
+                VALUES (?, 'Subject', 'sender@example.com', ?, ?, ?, ?, 1)
+                """,
+                [
+                    (f"bench-{i}", now - timedelta(minutes=i), categories[i % 7],
+                     folders[i % 4], i % 3 == 0)
+                    for i in range(50000)
+                ]
+            )
+            conn.commit()
+
+        await service.get_counters(user_id=1)  # warm up
+        runs = 5
+        start = time.perf_counter()
+        for _ in range(runs):
+            counters = await service.get_counters(user_id=1)
+        elapsed_ms = (time.perf_counter() - start) * 1000 / runs
+
+        assert sum(c.total for c in counters.by_category.values()) == 50000
+        print(f"get_counters over 50k rows: {elapsed_ms:.1f} ms")
+        # Generous bound so slow CI machines don't flake; target is <20ms
+        assert elapsed_ms < 200
@@ -0,0 +1,216 @@
+"""Tests for error mapping and the standard error envelope."""
+
+import json
+from typing import List
+
+import pytest
+from fastapi import FastAPI, HTTPException, Query
+from fastapi.testclient import TestClient
+from pydantic import BaseModel, Field, model_validator
+
+from backend.core.errors import (
+    ConflictError, InputValidationError, NotFoundError, UpstreamAIError,
+    UpstreamOutlookError, batch_item_failure, batch_status_code, item_status,
+    register_error_handlers, to_http_exception
+)
+
+
+class Order(BaseModel):
+    """Request body with field constraints and a model-level check."""
+    items: List[str] = Field(..., min_length=1)
+    quantity: int = Field(1, ge=1)
+    gift: bool = False
+    note: str = ""
+
+    @model_validator(mode="after")
+    def _gift_needs_note(self):
+        if self.gift and not self.note:
+            raise ValueError("Gifts need a note")
+        return self
+
+
+@pytest.fixture
+def client():
+    """Create a test client for an app exercising each error path."""
+    app = FastAPI()
+    register_error_handlers(app)
+
+    @app.get("/service-error/{kind}")
+    async def service_error(kind: str):
+        errors = {
+            "not_found": NotFoundError("Email not found"),
+            "conflict": ConflictError("Already processed"),
+            "validation": InputValidationError("Bad folder name"),
+            "outlook": UpstreamOutlookError("Outlook is not responding"),
+            "ai": UpstreamAIError("Azure OpenAI timed out"),
+        }
+        try:
+            raise errors[kind]
+        except Exception as e:
+            raise to_http_exception(e, "Failed to handle request")
+
+    @app.get("/http-error")
+    async def http_error():
+        raise HTTPException(status_code=403, detail="Access denied")
+
+    @app.get("/crash")
+    async def crash():
+        raise RuntimeError("boom")
+
+    @app.get("/typed")
+    async def typed(limit: int = Query(..., ge=1)):
+        return {"limit": limit}
+
+    @app.post("/orders")
+    async def orders(order: Order):
+        return order
+
+    return TestClient(app, raise_server_exceptions=False)
+
+
+class TestErrorMapping:
+    """Tests for mapping exceptions to HTTP errors."""
+
+    @pytest.mark.parametrize("kind,status_code,code", [
+        ("not_found", 404, "not_found"),
+        ("conflict", 409, "conflict"),
+        ("validation", 422, "validation_error"),
+        ("outlook", 502, "upstream_outlook"),
+        ("ai", 502, "upstream_ai"),
+    ])
+    def test_service_errors_map_to_status(self, client, kind, status_code, code):
+        """Test that each typed error maps to its status and code."""
+        response = client.get(f"/service-error/{kind}")
+
+        assert response.status_code == status_code
+        assert response.json()["error"]["code"] == code
+
+    def test_http_exception_passes_through(self):
+        """Test that HTTPExceptions are returned unchanged."""
+        exc = HTTPException(status_code=403, detail="Access denied")
+
+        assert to_http_exception(exc, "Failed") is exc
+
+    def test_input_validation_error_is_validation_error(self):
+        """Test that InputValidationError maps to a 422 and is still a ValueError."""
+        exc = InputValidationError("bad priority")
+        mapped = to_http_exception(exc, "Failed to create task")
+
+        assert isinstance(exc, ValueError)
+        assert mapped.status_code == 422
+        assert mapped.detail == "bad priority"
+
+    @pytest.mark.parametrize("exc", [
+        ValueError("bad priority"),
+        json.JSONDecodeError("Expecting value", "{", 1),
+        UnicodeDecodeError("utf-8", b"\xff", 0, 1, "invalid start byte"),
+    ])
+    def test_bare_value_error_is_internal(self, exc):
+        """Test that ValueErrors from decoding or internal checks map to a 500, not a 422."""
+        mapped = to_http_exception(exc, "Failed to classify email")
+
+        assert mapped.status_code == 500
+        assert mapped.code == "internal_error"
+
+    def test_unknown_error_is_internal(self):
+        """Test that unexpected errors map to a 500 with context."""
+        mapped = to_http_exception(KeyError("id"), "Failed to retrieve email")
+
+        assert mapped.status_code == 500
+        assert mapped.detail.startswith("Failed to retrieve email")
+
+
+class TestErrorEnvelope:
+    """Tests for the error response body shape."""
+
+    def test_envelope_shape(self, client):
+        """Test that errors use the {error: {code, message, request_id}} shape."""
+        response = client.get("/http-error")
+
+        assert response.status_code == 403
+        body = response.json()
+        assert set(body.keys()) == {"error"}
+        assert set(body["error"].keys()) == {"code", "message", "request_id"}
+        assert body["error"]["code"] == "forbidden"
+        assert body["error"]["message"] == "Access denied"
+
+    def test_request_id_echoed_from_header(self, client):
+        """Test that a caller-supplied request ID is reused."""
+        response = client.get("/http-error", headers={"X-Request-ID": "req-123"})
+
+        assert response.json()["error"]["request_id"] == "req-123"
+        assert response.headers["X-Request-ID"] == "req-123"
+
+    def test_unhandled_exception_is_recovered(self, client):
+        """Test that crashes are formatted like any other error."""
+        response = client.get("/crash")
+
+        assert response.status_code == 500
+        body = response.json()
+        assert body["error"]["code"] == "internal_error"
+        assert body["error"]["message"] == "Internal server error"
+        assert body["error"]["request_id"] == response.headers["X-Request-ID"]
+
+    def test_request_validation_uses_envelope(self, client):
+        """Test that FastAPI validation failures use the envelope."""
+        response = client.get("/typed?limit=0")
+
+        assert response.status_code == 422
+        assert response.json()["error"]["code"] == "validation_error"
+        assert "limit" in response.json()["error"]["message"]
+        assert response.json()["error"]["fields"][0]["location"] == "query"
+
+    def test_request_validation_lists_every_field(self, client):
+        """Test that each failed body field gets its own entry."""
+        response = client.post("/orders", json={"items": [], "quantity": 0})
+
+        error = response.json()["error"]
+        assert error["message"] == "body.items: List should have at least 1 item after validation, not 0"
+        assert [(field["field"], field["location"], field["type"]) for field in error["fields"]] == [
+            ("items", "body", "too_short"), ("quantity", "body", "greater_than_equal")
+        ]
+
+    def test_model_check_has_no_field(self, client):
+        """Test that a model-level check is reported without a field or Pydantic's prefix."""
+        response = client.post("/orders", json={"items": ["book"], "gift": True})
+
+        assert response.json()["error"]["fields"] == [
+            {"field": None, "location": "body", "message": "Gifts need a note", "type": "value_error"}
+        ]
+        assert response.json()["error"]["message"] == "body: Gifts need a note"
+
+    def test_unknown_route_uses_envelope(self, client):
+        """Test that router 404s use the envelope."""
+        response = client.get("/does-not-exist")
+
+        assert response.status_code == 404
+        assert response.json()["error"]["code"] == "not_found"
+
+
+class TestBatchStatus:
+    """Tests for the overall status of batch responses."""
+
+    @pytest.mark.parametrize("item_count,failures,expected", [
+        (3, [], 200),
+        (0, [], 200),
+        (3, [404], 207),
+        (3, [502, 404], 207),
+        (2, [404, 404], 404),
+        (3, [502, 404, 502], 502),
+        (2, [404, 502], 404),
+    ])
+    def test_status(self, item_count, failures, expected):
+        """Test full, partial, and total failure, with ties going to the first status."""
+        assert batch_status_code(item_count, failures) == expected
+
+    @pytest.mark.parametrize("status_code,retryable", [
+        (404, False), (409, False), (422, False), (429, True), (500, True), (502, True), (503, True),
+    ])
+    def test_item_status(self, status_code, retryable):
+        """Test that only transient failures are marked retryable."""
+        assert item_status(status_code) == {"status_code": status_code, "is_retryable": retryable}
+
+    def test_item_failure_uses_error_mapping(self):
+        """Test that item exceptions map like endpoint exceptions."""
+        assert batch_item_failure(NotFoundError("Email not found"), "Failed") == (404, "Email not found")
+        assert batch_item_failure(UpstreamAIError("timeout"), "Failed") == (502, "timeout")
@@ -0,0 +1,239 @@
+"""Tests for settings.excluded_folders across listing, moves, processing, and reconciliation."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import emails as emails_api
+from backend.api import processing as processing_api
+from backend.api.auth import get_current_user
+from backend.api.processing import get_known_folders
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import ExcludedFolderError, register_error_handlers
+from backend.core.folder_scope import check_folder_in_scope, excluded_prefix, is_excluded_folder
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.classification_store import ClassificationStore, get_classification_store
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.folder_profile_service import FolderProfileService, get_folder_profile_service
+from backend.services.job_queue import job_queue as shared_job_queue
+
+EXCLUDED = ["Personal", "Inbox/Payroll"]
+
+
+@pytest.fixture(autouse=True)
+def excluded_folders(monkeypatch):
+    """Exclude Personal and Inbox/Payroll, with their subfolders."""
+    monkeypatch.setattr(settings, "excluded_folders", EXCLUDED)
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+def error(response):
+    """Error code and message of an error response."""
+    body = response.json()["error"]
+    return body["code"], body["message"]
+
+
+class TestPrefixMatching:
+    """Tests for excluded_prefix and is_excluded_folder."""
+
+    @pytest.mark.parametrize("path, prefix", [
+        ("Personal", "Personal"),
+        ("personal", "Personal"),
+        ("PERSONAL/Family/Photos", "Personal"),
+        ("/Personal/", "Personal"),
+        ("Inbox/Payroll", "Inbox/Payroll"),
+        ("inbox\\payroll\\2024", "Inbox/Payroll"),
+        ("Inbox/ Payroll /2024", "Inbox/Payroll"),
+    ])
+    def test_excluded(self, path, prefix):
+        """Test that prefixes match the folder and its subfolders in any case and either slash."""
+        assert excluded_prefix(path) == prefix
+
+    @pytest.mark.parametrize("path", [
+        "Inbox", "Personal Projects", "Inbox/Payroll Archive", "Archive/Personal", "", None
+    ])
+    def test_in_scope(self, path):
+        """Test that only whole leading path segments match."""
+        assert not is_excluded_folder(path)
+
+    def test_explicit_prefixes_and_empty_entries(self):
+        """Test that prefixes can be passed in and blank entries match nothing."""
+        assert is_excluded_folder("Clients/Acme", ["clients"])
+        assert not is_excluded_folder("Inbox", ["", "/"])
+
+    def test_check_names_the_prefix(self):
+        """Test that the refusal says which prefix matched and how to override."""
+        with pytest.raises(ExcludedFolderError, match=r"'personal/family'.*\('Personal'\)\. Try override"):
+            check_folder_in_scope("personal/family", "Cannot list", "Try override")
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock mailbox with Personal and Inbox/Payroll folders."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    provider.mock_folders = [
+        {"id": "inbox", "name": "Inbox", "path": "Inbox", "type": "mail"},
+        {"id": "payroll", "name": "Payroll", "path": "Inbox/Payroll", "type": "mail"},
+        {"id": "payroll-2024", "name": "2024", "path": "Inbox/Payroll/2024", "type": "mail"},
+        {"id": "personal", "name": "personal", "path": "personal", "type": "mail"},
+        {"id": "projects", "name": "Personal Projects", "path": "Personal Projects", "type": "mail"},
+    ]
+    return provider
+
+
+@pytest.fixture
+def email_client(store, provider):
+    """Client for the email endpoints over the mock mailbox."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(emails_api.router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=1, username="owner", email="owner@example.com", hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: EmailService(provider, db=store)
+    return TestClient(app)
+
+
+class TestListing:
+    """Tests for GET /api/emails on excluded folders."""
+
+    @pytest.mark.parametrize("folder", ["Personal", "personal/Family", "INBOX/PAYROLL/2024"])
+    def test_excluded_folder_is_forbidden(self, email_client, folder):
+        """Test that listing an excluded folder or subfolder is a 403 explaining the override."""
+        response = email_client.get("/api/emails", params={"folder": folder})
+
+        assert response.status_code == 403
+        code, message = error(response)
+        assert code == "folder_excluded" and "override=true" in message
+
+    def test_stored_search_is_forbidden(self, email_client):
+        """Test that stored-email searches of an excluded folder are refused too."""
+        response = email_client.get("/api/emails", params={"folder": "Inbox/Payroll", "category": "fyi"})
+
+        assert response.status_code == 403
+
+    def test_override(self, email_client):
+        """Test that override=true lists the folder anyway."""
+        response = email_client.get("/api/emails", params={"folder": "Personal", "override": "true"})
+
+        assert response.status_code == 200
+
+    def test_other_folders_unaffected(self, email_client):
+        """Test that sibling folders and the default inbox list normally."""
+        assert email_client.get("/api/emails", params={"folder": "Personal Projects"}).status_code == 200
+        assert email_client.get("/api/emails").status_code == 200
+
+
+class TestMove:
+    """Tests for moves into excluded folders."""
+
+    def test_move_into_excluded_folder_is_forbidden(self, email_client, provider):
+        """Test that a nested excluded destination is refused before Outlook is touched."""
+        response = email_client.post(
+            "/api/emails/mock-email-1/move", params={"destination_folder": "inbox\\payroll\\2024"}
+        )
+
+        assert response.status_code == 403
+        assert provider.mock_emails[0]["folder"] != "inbox\\payroll\\2024"
+
+    def test_move_elsewhere(self, email_client, provider):
+        """Test that other destinations still move."""
+        response = email_client.post("/api/emails/mock-email-1/move", params={"destination_folder": "Archive"})
+
+        assert response.json()["success"] is True
+        assert provider.mock_emails[0]["folder"] == "Archive"
+
+
+class TestFolderListing:
+    """Tests for the excluded flag in GET /api/folders."""
+
+    def test_excluded_folders_are_marked(self, email_client):
+        """Test that excluded folders and subfolders are flagged, not hidden."""
+        folders = email_client.get("/api/folders").json()["folders"]
+
+        assert {folder["path"]: folder["excluded"] for folder in folders} == {
+            "Inbox": False,
+            "Inbox/Payroll": True,
+            "Inbox/Payroll/2024": True,
+            "personal": True,
+            "Personal Projects": False,
+        }
+
+
+class TestReconcile:
+    """Tests for reconciling excluded folders with the provider."""
+
+    def test_reconcile_excluded_folder_is_forbidden(self, email_client):
+        """Test that an excluded folder is not synced."""
+        response = email_client.post("/api/emails/reconcile", params={"folder": "Personal/Family"})
+
+        assert response.status_code == 403
+
+    def test_service_refuses(self, store, provider):
+        """Test that the service refuses without calling the provider."""
+        with pytest.raises(ExcludedFolderError):
+            asyncio.run(EmailService(provider, db=store).reconcile_folder(1, "inbox/payroll"))
+
+
+class TestProcessing:
+    """Tests for the pipeline's email selection."""
+
+    @pytest.fixture
+    def client(self, store):
+        """Client for the processing endpoints with emails stored in Inbox, Payroll, and Personal."""
+        with store.get_connection() as conn:
+            conn.executemany(
+                "INSERT INTO emails (id, subject, sender, user_id, folder) "
+                "VALUES (?, 'Hi', 'a@example.com', 'user_1', ?)",
+                [("in_inbox", "Inbox"), ("in_payroll", "Inbox/Payroll/2024"), ("in_personal", "PERSONAL")]
+            )
+            conn.commit()
+
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(processing_api.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: {"user_id": "user_1"}
+        app.dependency_overrides[get_folder_profile_service] = lambda: FolderProfileService(db=store)
+        app.dependency_overrides[get_classification_store] = lambda: ClassificationStore(db=store)
+        app.dependency_overrides[get_known_folders] = lambda: None
+        return TestClient(app)
+
+    def test_excluded_emails_left_out(self, client):
+        """Test that stored emails in excluded folders get no jobs; unknown emails still do."""
+        data = client.post(
+            "/api/processing/start", json={"email_ids": ["in_inbox", "in_payroll", "in_personal", "unstored"]}
+        ).json()
+
+        assert (data["email_count"], data["excluded_count"], data["skipped_count"]) == (2, 2, 0)
+        pipeline = asyncio.run(shared_job_queue.get_pipeline(data["pipeline_id"]))
+        assert {job.email_id for job in pipeline.jobs} == {"in_inbox", "unstored"}
+        shared_job_queue._pipelines.pop(data["pipeline_id"], None)
+
+    def test_only_excluded_emails_starts_nothing(self, client):
+        """Test that a request of only excluded emails creates no pipeline."""
+        data = client.post("/api/processing/start", json={"email_ids": ["in_payroll"]}).json()
+
+        assert (data["status"], data["pipeline_id"], data["excluded_count"]) == ("skipped", None, 1)
+
+    def test_excluded_source_folder_is_forbidden(self, client):
+        """Test that a run for an excluded folder is a 403."""
+        response = client.post(
+            "/api/processing/start", json={"email_ids": ["in_inbox"], "folder": "inbox/payroll"}
+        )
+
+        assert response.status_code == 403
+        assert error(response)[0] == "folder_excluded"
@@ -0,0 +1,285 @@
+"""Tests for the category folder hygiene report and purge."""
+
+from datetime import datetime, timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import ExcludedFolderError, register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.folder_hygiene import (
+    AGE_BUCKETS, age_bucket, bucket_counts, email_age_days, folder_retention, hygiene_folders, purge_candidates
+)
+
+USER_ID = 1
+NOW = datetime(2024, 6, 1, 12, 0, 0)
+
+# ID -> (category, age in days, folder, flagged)
+EMAILS = {
+    "old-job": ("job_listing", 200, "Job Listings", False),
+    "older-job": ("job_listing", 400, "Job Listings", False),
+    "flagged-job": ("job_listing", 300, "Job Listings", True),
+    "fresh-job": ("job_listing", 10, "Job Listings", False),
+    "old-event": ("optional_event", 120, "Optional Events", False),
+    "old-inbox": ("job_listing", 300, "Inbox", False),
+}
+
+
+def row(email_id, age_days, **values):
+    """A stored email row received age_days before NOW."""
+    received = None if age_days is None else (NOW - timedelta(days=age_days)).isoformat()
+    return {"id": email_id, "received_date": received, "is_flagged": 0, **values}
+
+
+@pytest.fixture
+def retention(monkeypatch):
+    """Job Listings keep 90 days, other category folders forever."""
+    monkeypatch.setattr(settings, "folder_retention_days", {"job listings": 90})
+    monkeypatch.setattr(settings, "folder_retention_default_days", 0)
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock mailbox holding EMAILS."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    provider.mock_emails = [
+        {
+            "id": email_id,
+            "subject": email_id.replace("-", " ").title(),
+            "sender": "jobs@example.com",
+            "body": "Some text.",
+            "received_time": (NOW - timedelta(days=age)).isoformat(),
+            "conversation_id": email_id,
+            "categories": [],
+            "folder": folder,
+            "is_read": True,
+        }
+        for email_id, (_, age, folder, _) in EMAILS.items()
+    ]
+    provider.mock_folders = provider.mock_folders + [
+        {"id": "jobs", "name": "Job Listings", "type": "mail", "total_count": 1200}
+    ]
+    return provider
+
+
+@pytest.fixture
+async def service(provider):
+    """Email service over an isolated store with EMAILS classified and filed."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    for email in provider.mock_emails:
+        await email_service.save_classification(
+            email, EmailClassification(category=EMAILS[email["id"]][0], confidence=0.9), USER_ID
+        )
+    with store.get_connection() as conn:
+        conn.executemany(
+            "UPDATE emails SET folder = ?, is_flagged = ? WHERE id = ?",
+            [(folder, int(flagged), email_id) for email_id, (_, _, folder, flagged) in EMAILS.items()]
+        )
+        conn.commit()
+    yield email_service
+    store.close()
+
+
+def stored_folder(service, email_id):
+    """Folder on an email's stored row."""
+    with service.db.get_connection() as conn:
+        return conn.execute("SELECT folder FROM emails WHERE id = ?", (email_id,)).fetchone()["folder"]
+
+
+class TestAgeBuckets:
+    """Tests for bucketing stored emails by age."""
+
+    @pytest.mark.parametrize("age_days, label", [
+        (0, "0-30d"), (30, "0-30d"), (31, "31-90d"), (90, "31-90d"),
+        (91, "91-365d"), (365, "91-365d"), (366, "366+d"), (2000, "366+d"),
+    ])
+    def test_bucket_edges(self, age_days, label):
+        """Test that each bucket's maximum is inclusive."""
+        assert age_bucket(age_days) == label
+
+    def test_ages_in_whole_days(self):
+        """Test that ages count whole days and dates with an offset are compared as wall-clock times."""
+        assert email_age_days((NOW - timedelta(days=3, hours=23)).isoformat(), NOW) == 3
+        assert email_age_days("2024-05-31T12:00:00+02:00", NOW) == 1
+        assert email_age_days((NOW + timedelta(days=1)).isoformat(), NOW) == 0
+        assert email_age_days(None, NOW) is None
+        assert email_age_days("not a date", NOW) is None
+
+    def test_counts_every_bucket(self):
+        """Test that every bucket is present and undated emails are counted apart."""
+        rows = [row("a", 5), row("b", 45), row("c", 60), row("d", 500), row("e", None)]
+
+        counts, undated = bucket_counts(rows, NOW)
+
+        assert list(counts) == [label for label, _, _ in AGE_BUCKETS]
+        assert counts == {"0-30d": 1, "31-90d": 2, "91-365d": 0, "366+d": 1}
+        assert undated == 1
+
+
+class TestPurgeCandidates:
+    """Tests for selecting emails past a folder's retention."""
+
+    def test_past_retention_oldest_first(self):
+        """Test that emails at or past the retention are selected, oldest first."""
+        rows = [row("fresh", 89), row("edge", 90), row("oldest", 400), row("old", 200)]
+
+        assert [email["id"] for email in purge_candidates(rows, 90, NOW)] == ["oldest", "old", "edge"]
+
+    def test_flagged_and_undated_are_kept(self):
+        """Test that flagged emails and emails without a date are never candidates."""
+        rows = [row("flagged", 400, is_flagged=1), row("undated", None), row("old", 400)]
+
+        assert [email["id"] for email in purge_candidates(rows, 30, NOW)] == ["old"]
+
+    def test_zero_retention_keeps_everything(self):
+        """Test that a folder without a retention has no candidates."""
+        assert purge_candidates([row("ancient", 5000)], 0, NOW) == []
+
+    def test_retention_lookup(self, retention, monkeypatch):
+        """Test that folder retention is matched case-insensitively and falls back to the default."""
+        assert folder_retention("Job Listings") == 90
+        assert folder_retention("Optional Events") == 0
+        monkeypatch.setattr(settings, "folder_retention_default_days", 365)
+        assert folder_retention("Optional Events") == 365
+
+    def test_category_folders_leave_out_the_inbox(self):
+        """Test that the built-in category folders are covered and the inbox is not."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        folders = hygiene_folders(store)
+        store.close()
+
+        assert {"Job Listings", "Optional Events"} <= set(folders)
+        assert "inbox" not in {folder.lower() for folder in folders}
+
+
+class TestReport:
+    """Tests for EmailService.get_folder_hygiene."""
+
+    @pytest.mark.asyncio
+    async def test_report(self, service, retention):
+        """Test that a folder reports its item count, age buckets, and suggested purges."""
+        report = await service.get_folder_hygiene(USER_ID, now=NOW)
+        folders = {entry.folder: entry for entry in report.folders}
+
+        jobs = folders["Job Listings"]
+        assert (jobs.item_count, jobs.stored_count, jobs.retention_days) == (1200, 4, 90)
+        assert {bucket.label: bucket.count for bucket in jobs.buckets} == {
+            "0-30d": 1, "31-90d": 0, "91-365d": 2, "366+d": 1
+        }
+        assert jobs.suggested_purge_count == 2
+        events = folders["Optional Events"]
+        assert (events.item_count, events.stored_count, events.suggested_purge_count) == (None, 1, 0)
+        assert "Inbox" not in folders
+        assert report.destination_folder == "Deleted Items"
+
+    @pytest.mark.asyncio
+    async def test_excluded_folder_not_read(self, service, retention, monkeypatch):
+        """Test that an excluded category folder is listed without buckets or suggested purges."""
+        monkeypatch.setattr(settings, "excluded_folders", ["Job Listings"])
+
+        report = await service.get_folder_hygiene(USER_ID, now=NOW)
+        jobs = next(entry for entry in report.folders if entry.folder == "Job Listings")
+
+        assert jobs.excluded
+        assert (jobs.stored_count, jobs.buckets, jobs.suggested_purge_count) == (0, [], 0)
+
+
+class TestPurge:
+    """Tests for EmailService.purge_folder_hygiene."""
+
+    @pytest.mark.asyncio
+    async def test_dry_run_moves_nothing(self, service, provider, retention):
+        """Test that a dry run lists the candidates and leaves the mailbox alone."""
+        outcome = await service.purge_folder_hygiene(USER_ID, dry_run=True, now=NOW)
+
+        assert [email["id"] for email in outcome.candidates] == ["older-job", "old-job"]
+        assert (outcome.purged, outcome.batch_id) == ([], None)
+        assert stored_folder(service, "old-job") == "Job Listings"
+
+    @pytest.mark.asyncio
+    async def test_purge_and_revert(self, service, provider, retention):
+        """Test that purged emails move to Deleted Items as one batch that reverts to their folder."""
+        outcome = await service.purge_folder_hygiene(USER_ID, now=NOW)
+
+        assert sorted(outcome.purged) == ["old-job", "older-job"]
+        assert stored_folder(service, "old-job") == "Deleted Items"
+        assert stored_folder(service, "flagged-job") == "Job Listings"
+        assert stored_folder(service, "old-inbox") == "Inbox"
+
+        reverted = await service.revert_move_batch(outcome.batch_id, USER_ID)
+
+        assert sorted(reverted.restored) == ["old-job", "older-job"]
+        assert stored_folder(service, "older-job") == "Job Listings"
+
+    @pytest.mark.asyncio
+    async def test_selected_folders(self, service, retention, monkeypatch):
+        """Test that only the requested folders are purged, and unknown ones are rejected."""
+        monkeypatch.setattr(settings, "folder_retention_default_days", 30)
+
+        outcome = await service.purge_folder_hygiene(USER_ID, ["optional events"], dry_run=True, now=NOW)
+
+        assert [email["id"] for email in outcome.candidates] == ["old-event"]
+        with pytest.raises(ValueError):
+            await service.purge_folder_hygiene(USER_ID, ["Projects"], dry_run=True, now=NOW)
+
+    @pytest.mark.asyncio
+    async def test_excluded_folders_respected(self, service, retention, monkeypatch):
+        """Test that excluded folders are skipped by default and refused when requested."""
+        monkeypatch.setattr(settings, "excluded_folders", ["Job Listings"])
+
+        assert (await service.purge_folder_hygiene(USER_ID, now=NOW)).candidates == []
+        with pytest.raises(ExcludedFolderError):
+            await service.purge_folder_hygiene(USER_ID, ["Job Listings"], now=NOW)
+
+        monkeypatch.setattr(settings, "excluded_folders", ["Deleted Items"])
+        with pytest.raises(ExcludedFolderError):
+            await service.purge_folder_hygiene(USER_ID, dry_run=True, now=NOW)
+
+
+class TestFolderHygieneAPI:
+    """Tests for the /emails/folder-hygiene endpoints."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="tidy", email="tidy@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        return TestClient(app)
+
+    def test_report_endpoint(self, client, retention):
+        """Test that GET /emails/folder-hygiene lists the category folders."""
+        data = client.get("/api/emails/folder-hygiene").json()
+
+        jobs = next(entry for entry in data["folders"] if entry["folder"] == "Job Listings")
+        assert jobs["retention_days"] == 90
+        assert [bucket["label"] for bucket in jobs["buckets"]] == [label for label, _, _ in AGE_BUCKETS]
+
+    def test_purge_endpoint(self, client, retention):
+        """Test that POST /emails/folder-hygiene/purge dry-runs, purges, and rejects unknown folders."""
+        dry = client.post("/api/emails/folder-hygiene/purge", json={"dry_run": True}).json()
+        assert dry["success_count"] == 0
+        assert {email["id"] for email in dry["candidates"]} >= {"old-job", "older-job"}
+
+        data = client.post("/api/emails/folder-hygiene/purge", json={}).json()
+        assert data["success_count"] == len(dry["candidates"])
+        assert data["batch_id"] is not None
+
+        response = client.post("/api/emails/folder-hygiene/purge", json={"folders": ["Projects"]})
+        assert response.status_code == 422
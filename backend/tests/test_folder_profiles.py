@@ -0,0 +1,272 @@
+"""Tests for per-folder processing profiles."""
+
+import sqlite3
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.processing import get_known_folders, router
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.folder_profile import (
+    PIPELINE_STAGES, FolderProfileCreate, FolderProfileUpdate, normalize_folder_path, validate_stages
+)
+from backend.services.folder_profile_service import FolderProfileService, get_folder_profile_service
+from backend.services.job_queue import JobQueue, job_queue as shared_job_queue
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def profile_service(store):
+    """Folder profile service over the in-memory store."""
+    return FolderProfileService(db=store)
+
+
+class TestFolderProfileModels:
+    """Tests for folder path normalization and stage validation."""
+
+    @pytest.mark.parametrize("raw,expected", [
+        ("Inbox", "Inbox"),
+        ("Inbox/Recruiters", "Inbox/Recruiters"),
+        ("Inbox\\Recruiters", "Inbox/Recruiters"),
+        ("/Inbox/Recruiters/", "Inbox/Recruiters"),
+        (" Inbox / Recruiters ", "Inbox/Recruiters"),
+        ("//", ""),
+    ])
+    def test_normalize_folder_path(self, raw, expected):
+        """Test that paths are slash-separated without outer slashes."""
+        assert normalize_folder_path(raw) == expected
+
+    def test_validate_stages_orders_stages(self):
+        """Test that stages come back in processing order."""
+        assert validate_stages(["categorization", "email_analysis"]) == ["email_analysis", "categorization"]
+        assert validate_stages(None) is None
+
+    @pytest.mark.parametrize("stages", [["summarize"], []])
+    def test_validate_stages_rejects(self, stages):
+        """Test that unknown and empty stage lists are rejected."""
+        with pytest.raises(ValueError):
+            validate_stages(stages)
+
+    def test_create_defaults(self):
+        """Test that a profile defaults to every stage with no auto-apply."""
+        profile = FolderProfileCreate(folder_path="Inbox\\Recruiters")
+
+        assert profile.folder_path == "Inbox/Recruiters"
+        assert profile.default_stages == PIPELINE_STAGES
+        assert profile.default_deployment is None
+        assert profile.auto_apply_to_outlook is False
+
+
+class TestFolderProfileService:
+    """Tests for folder profile CRUD."""
+
+    @pytest.mark.asyncio
+    async def test_create_and_get(self, profile_service):
+        """Test creating a profile and reading it back."""
+        created = await profile_service.create_profile(
+            FolderProfileCreate(
+                folder_path="Inbox/Recruiters",
+                default_stages=["categorization"],
+                default_deployment="gpt-4o-mini",
+                auto_apply_to_outlook=True
+            ),
+            "user_1"
+        )
+
+        fetched = await profile_service.get_profile(created.id, "user_1")
+        assert fetched.folder_path == "Inbox/Recruiters"
+        assert fetched.default_stages == ["categorization"]
+        assert fetched.default_deployment == "gpt-4o-mini"
+        assert fetched.auto_apply_to_outlook is True
+        assert await profile_service.get_profile(created.id, "user_2") is None
+
+    @pytest.mark.asyncio
+    async def test_duplicate_folder_rejected(self, profile_service):
+        """Test that a second profile for the same folder is an integrity error."""
+        await profile_service.create_profile(FolderProfileCreate(folder_path="Inbox/Newsletters"), "user_1")
+
+        with pytest.raises(sqlite3.IntegrityError):
+            await profile_service.create_profile(FolderProfileCreate(folder_path="inbox/newsletters"), "user_1")
+        # Other users can have their own profile for the folder
+        await profile_service.create_profile(FolderProfileCreate(folder_path="Inbox/Newsletters"), "user_2")
+
+    @pytest.mark.asyncio
+    async def test_get_profile_for_folder_is_case_insensitive(self, profile_service):
+        """Test folder lookup ignores case and separator style."""
+        created = await profile_service.create_profile(FolderProfileCreate(folder_path="Inbox/Recruiters"), "user_1")
+
+        found = await profile_service.get_profile_for_folder("inbox\\recruiters", "user_1")
+        assert found.id == created.id
+        assert await profile_service.get_profile_for_folder("Inbox", "user_1") is None
+
+    @pytest.mark.asyncio
+    async def test_update_list_and_delete(self, profile_service):
+        """Test updating, listing, and deleting profiles."""
+        created = await profile_service.create_profile(FolderProfileCreate(folder_path="Inbox/B"), "user_1")
+        await profile_service.create_profile(FolderProfileCreate(folder_path="Inbox/A"), "user_1")
+
+        updated = await profile_service.update_profile(
+            created.id, FolderProfileUpdate(default_stages=["email_analysis"]), "user_1"
+        )
+        assert updated.default_stages == ["email_analysis"]
+        assert updated.folder_path == "Inbox/B"
+
+        profiles = await profile_service.list_profiles("user_1")
+        assert [p.folder_path for p in profiles] == ["Inbox/A", "Inbox/B"]
+
+        assert await profile_service.delete_profile(created.id, "user_1") is True
+        assert await profile_service.delete_profile(created.id, "user_1") is False
+        assert await profile_service.update_profile(created.id, FolderProfileUpdate(), "user_1") is None
+
+
+class TestPipelineStages:
+    """Tests for pipelines created with a stage subset."""
+
+    @pytest.mark.asyncio
+    async def test_create_pipeline_with_stages(self):
+        """Test that only the selected stages get jobs and the profile is recorded."""
+        queue = JobQueue()
+
+        pipeline_id = await queue.create_pipeline(
+            ["email_1", "email_2"], "user_1",
+            stages=["categorization"], folder="Inbox/Recruiters", profile_id=7
+        )
+
+        pipeline = await queue.get_pipeline(pipeline_id)
+        assert [job.type.value for job in pipeline.jobs] == ["categorization", "categorization"]
+        assert pipeline.stages == ["categorization"]
+        assert pipeline.folder == "Inbox/Recruiters"
+        assert pipeline.profile_id == 7
+
+    @pytest.mark.asyncio
+    async def test_create_pipeline_defaults_to_all_stages(self):
+        """Test that omitting stages keeps the three-job pipeline."""
+        queue = JobQueue()
+
+        pipeline = await queue.get_pipeline(await queue.create_pipeline(["email_1"], "user_1"))
+
+        assert pipeline.stages == PIPELINE_STAGES
+        assert len(pipeline.jobs) == 3
+        assert pipeline.profile_id is None
+
+
+class TestFolderProfileAPI:
+    """Tests for the folder profile endpoints and profile defaults."""
+
+    @pytest.fixture
+    def app(self, profile_service):
+        """App with auth, the profile store, and Outlook folders overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: {"user_id": "user_1"}
+        app.dependency_overrides[get_folder_profile_service] = lambda: profile_service
+        app.dependency_overrides[get_known_folders] = lambda: None
+        return app
+
+    @pytest.fixture
+    def client(self, app):
+        """Test client."""
+        return TestClient(app)
+
+    def test_crud(self, client):
+        """Test the create, read, update, and delete endpoints."""
+        response = client.post(
+            "/api/processing/folder-profiles",
+            json={"folder_path": "Inbox/Recruiters", "default_stages": ["categorization"]}
+        )
+        assert response.status_code == 201
+        profile_id = response.json()["id"]
+
+        assert client.get(f"/api/processing/folder-profiles/{profile_id}").json()["folder_path"] == "Inbox/Recruiters"
+        assert len(client.get("/api/processing/folder-profiles").json()) == 1
+
+        response = client.put(
+            f"/api/processing/folder-profiles/{profile_id}", json={"auto_apply_to_outlook": True}
+        )
+        assert response.status_code == 200
+        assert response.json()["auto_apply_to_outlook"] is True
+
+        assert client.delete(f"/api/processing/folder-profiles/{profile_id}").status_code == 200
+        assert client.get(f"/api/processing/folder-profiles/{profile_id}").status_code == 404
+
+    def test_duplicate_is_conflict(self, client):
+        """Test that a second profile for a folder returns 409."""
+        client.post("/api/processing/folder-profiles", json={"folder_path": "Inbox/Recruiters"})
+
+        response = client.post("/api/processing/folder-profiles", json={"folder_path": "Inbox/Recruiters"})
+
+        assert response.status_code == 409
+
+    def test_unknown_folder_rejected_when_outlook_available(self, app, client):
+        """Test that profiles for folders missing from Outlook are rejected."""
+        app.dependency_overrides[get_known_folders] = lambda: ["Inbox", "Archive"]
+
+        assert client.post(
+            "/api/processing/folder-profiles", json={"folder_path": "Nowhere"}
+        ).status_code == 422
+        assert client.post(
+            "/api/processing/folder-profiles", json={"folder_path": "inbox/Recruiters"}
+        ).status_code == 201
+
+    def test_start_processing_uses_profile_defaults(self, client):
+        """Test that omitted settings come from the folder's profile."""
+        profile_id = client.post(
+            "/api/processing/folder-profiles",
+            json={
+                "folder_path": "Inbox/Recruiters",
+                "default_stages": ["categorization"],
+                "default_deployment": "gpt-4o-mini"
+            }
+        ).json()["id"]
+
+        data = client.post(
+            "/api/processing/start",
+            json={"email_ids": ["email_1"], "folder": "inbox/recruiters"}
+        ).json()
+
+        assert data["stages"] == ["categorization"]
+        assert data["profile_id"] == profile_id
+        status = client.get(f"/api/processing/{data['pipeline_id']}/status").json()
+        assert status["deployment"] == "gpt-4o-mini"
+        assert status["folder"] == "inbox/recruiters"
+        shared_job_queue._pipelines.pop(data["pipeline_id"], None)
+
+    def test_start_processing_explicit_settings_override_profile(self, client):
+        """Test that a fully specified request does not use the profile."""
+        client.post(
+            "/api/processing/folder-profiles",
+            json={"folder_path": "Inbox", "default_stages": ["categorization"]}
+        )
+
+        data = client.post(
+            "/api/processing/start",
+            json={
+                "email_ids": ["email_1"],
+                "stages": ["email_analysis"],
+                "deployment": "gpt-4o",
+                "auto_apply_to_outlook": False
+            }
+        ).json()
+
+        assert data["stages"] == ["email_analysis"]
+        assert data["profile_id"] is None
+        shared_job_queue._pipelines.pop(data["pipeline_id"], None)
+
+    def test_start_processing_rejects_unknown_stage(self, client):
+        """Test that unknown stages are a validation error."""
+        response = client.post(
+            "/api/processing/start", json={"email_ids": ["email_1"], "stages": ["summarize"]}
+        )
+
+        assert response.status_code == 422
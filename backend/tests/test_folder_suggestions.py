@@ -0,0 +1,190 @@
+"""Tests for destination folder suggestions."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import AsyncMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.folder_suggestions import parse_folder_ranking, rank_folder_suggestions
+
+USER_ID = 1
+FOLDERS = ["Inbox", "Projects", "Receipts", "FYI", "Drafts"]
+
+
+def ranked(suggestions):
+    """(folder, source, confidence) of each suggestion."""
+    return [(s.folder, s.source, s.confidence) for s in suggestions]
+
+
+class TestRanking:
+    """Tests for combining the suggestion sources."""
+
+    def test_sources_are_ranked_by_confidence(self):
+        """Test that history, category, and AI suggestions interleave by confidence, top three kept."""
+        suggestions = rank_folder_suggestions(
+            "Inbox",
+            category="fyi", category_confidence=0.7,
+            history={"Projects": 3, "Receipts": 1},
+            ai_ranking=[("Receipts", 0.4), ("Inbox", 0.9)],
+            folders=FOLDERS
+        )
+
+        assert ranked(suggestions) == [
+            ("FYI", "category", 0.7), ("Projects", "history", 0.6), ("Receipts", "ai", 0.4)
+        ]
+
+    def test_one_move_is_not_certain(self):
+        """Test that history confidence grows with the number of matching moves."""
+        once = rank_folder_suggestions("Inbox", history={"Projects": 1})
+        often = rank_folder_suggestions("Inbox", history={"Projects": 9})
+
+        assert 0 < once[0].confidence < often[0].confidence < 1
+
+    def test_excluded_and_missing_folders(self):
+        """Test that the current folder, special folders, and unknown category folders are skipped."""
+        suggestions = rank_folder_suggestions(
+            "Projects",
+            category="newsletter",
+            history={"Projects": 2, "Drafts": 2, "Archive 2019": 1},
+            folders=FOLDERS
+        )
+
+        # Moved-to folders are kept even when the folder list no longer has them
+        assert [s.folder for s in suggestions] == ["Archive 2019"]
+
+    def test_ties_prefer_history(self):
+        """Test that a folder two sources agree on keeps the history suggestion on a tie."""
+        suggestions = rank_folder_suggestions(
+            "Inbox", history={"projects": 1}, ai_ranking=[("Projects", 0.5)], folders=FOLDERS
+        )
+
+        assert ranked(suggestions) == [("Projects", "history", 0.5)]
+
+    def test_unclassified_without_history(self):
+        """Test that an email with nothing to go on gets no suggestions."""
+        assert rank_folder_suggestions("Inbox", folders=FOLDERS) == []
+
+
+class TestParseRanking:
+    """Tests for parsing folder_ranker output."""
+
+    def test_fenced_json(self):
+        """Test that fenced output is parsed and names are matched case-insensitively."""
+        raw = '```json\n{"folders": [{"folder": "projects", "confidence": 0.8}, {"folder": "Receipts"}]}\n```'
+
+        assert parse_folder_ranking(raw, FOLDERS) == [("Projects", 0.8), ("Receipts", 0.5)]
+
+    def test_invented_folders_and_bad_confidences(self):
+        """Test that unknown folders are dropped and confidences clamped."""
+        raw = {"folders": [{"folder": "Made Up", "confidence": 0.9}, {"folder": "FYI", "confidence": 7}, "x"]}
+
+        assert parse_folder_ranking(raw, FOLDERS) == [("FYI", 1.0)]
+
+    def test_invalid_output(self):
+        """Test that non-JSON output is rejected."""
+        with pytest.raises(ValueError):
+            parse_folder_ranking("Projects, probably", FOLDERS)
+
+
+class TestFolderSuggestionsAPI:
+    """Tests for GET /api/emails/{id}/folder-suggestions."""
+
+    @pytest.fixture
+    def provider(self):
+        """Authenticated mock provider with a few extra folders."""
+        mock = MockEmailProvider()
+        mock.authenticate({})
+        mock.mock_folders += [{"id": name, "name": name, "type": "mail"} for name in ("Projects", "FYI")]
+        return mock
+
+    @pytest.fixture
+    def service(self, provider):
+        """Email service over the mock provider and an in-memory store."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        yield EmailService(provider, db=store)
+        store.close()
+
+    @pytest.fixture
+    def stub_ai(self):
+        """AI service whose folder ranking is stubbed."""
+        ai = AsyncMock()
+        ai.rank_folders = AsyncMock(return_value={"folders": [("Projects", 0.3)]})
+        return ai
+
+    @pytest.fixture
+    def client(self, service, stub_ai):
+        """Client with auth, the email service, and the AI service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="filer", email="filer@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        return TestClient(app)
+
+    def seed(self, service):
+        """Store the mock emails, classify the first, and file a second email from its sender in Projects."""
+        async def setup():
+            for email in service.provider.mock_emails:
+                await service.store_email(email, USER_ID)
+            await service.save_classification(
+                service.provider.mock_emails[0], EmailClassification(category="fyi", confidence=0.9), USER_ID
+            )
+            await service.move_email("mock-email-2", "Projects")
+            with service.db.get_connection() as conn:
+                conn.execute("UPDATE emails SET sender = 'test1@example.com' WHERE id = 'mock-email-2'")
+                conn.commit()
+
+        asyncio.run(setup())
+
+    def test_suggestions_without_ai(self, client, service, stub_ai):
+        """Test that category and sender history are combined and the AI is not called by default."""
+        self.seed(service)
+        with service.db.get_connection() as conn:
+            activity_before = conn.execute("SELECT COUNT(*) FROM outlook_activity").fetchone()[0]
+
+        data = client.get("/api/emails/mock-email-1/folder-suggestions").json()
+
+        assert data == {"email_id": "mock-email-1", "suggestions": [
+            {"folder": "FYI", "source": "category", "confidence": 0.9},
+            {"folder": "Projects", "source": "history", "confidence": 0.5},
+        ]}
+        stub_ai.rank_folders.assert_not_called()
+        with service.db.get_connection() as conn:
+            assert conn.execute("SELECT COUNT(*) FROM outlook_activity").fetchone()[0] == activity_before
+
+    def test_ai_ranking(self, client, service, stub_ai):
+        """Test that ai=true asks the AI about existing folders other than the current one."""
+        data = client.get("/api/emails/mock-email-1/folder-suggestions", params={"ai": True}).json()
+
+        assert data["suggestions"] == [{"folder": "Projects", "source": "ai", "confidence": 0.3}]
+        assert stub_ai.rank_folders.call_args.kwargs["folders"] == ["Projects", "FYI"]
+
+    def test_ai_failure_is_ignored(self, client, service, stub_ai):
+        """Test that a failed ranking leaves the other suggestions intact."""
+        self.seed(service)
+        stub_ai.rank_folders.return_value = {"error": "model unavailable"}
+
+        response = client.get("/api/emails/mock-email-1/folder-suggestions", params={"ai": True})
+
+        assert response.status_code == 200
+        assert [s["source"] for s in response.json()["suggestions"]] == ["category", "history"]
+
+    def test_missing_email(self, client):
+        """Test that unknown emails are 404."""
+        assert client.get("/api/emails/ghost/folder-suggestions").status_code == 404
@@ -0,0 +1,194 @@
+"""Tests for follow-up (awaiting reply) detection."""
+
+from datetime import datetime, timedelta
+
+import pytest
+from unittest.mock import AsyncMock, MagicMock
+
+from backend.database.connection import DatabaseManager
+from backend.services.email_service import EmailService
+from backend.services.follow_up_detector import (
+    detect_awaiting_reply, is_awaiting_reply, looks_like_question, parse_ai_verdict,
+    strip_quoted_text
+)
+
+ME = "me@example.com"
+NOW = datetime(2024, 3, 10, 12, 0)
+
+
+def message(email_id, conversation_id, sender, days_ago, content, recipient=ME):
+    """Build a stored email row."""
+    return {
+        "id": email_id,
+        "subject": f"Thread {conversation_id}",
+        "sender": sender,
+        "recipient": recipient,
+        "content": content,
+        "received_date": NOW - timedelta(days=days_ago),
+        "conversation_id": conversation_id,
+    }
+
+
+@pytest.fixture
+def conversations():
+    """Fixture threads covering replied, unreplied, and self-sent cases."""
+    return [
+        # Unreplied: question from Alice, 3 days old
+        message("u1", "unreplied", "alice@example.com", 5, "FYI, kickoff is Monday."),
+        message("u2", "unreplied", "alice@example.com", 3, "Can you send the deck by Friday?"),
+        # Replied: I answered Bob's question
+        message("r1", "replied", "bob@example.com", 6, "Is the build green?"),
+        message("r2", "replied", f"Me <{ME}>", 5, "Yes, all green.", recipient="bob@example.com"),
+        # Self-sent: only my own message in the thread
+        message("s1", "self", ME, 4, "Did you get a chance to review?", recipient="carol@example.com"),
+        # Too recent to need a follow-up
+        message("n1", "recent", "dave@example.com", 0, "Are you free tomorrow?"),
+        # Old but no question
+        message("q1", "statement", "erin@example.com", 7, "Thanks, that's all I needed."),
+        # Question addressed to someone else
+        message("o1", "other", "frank@example.com", 4, "Can you review?", recipient="gina@example.com"),
+    ]
+
+
+@pytest.fixture
+def service(conversations):
+    """Email service over a store seeded with the fixture threads."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with store.get_connection() as conn:
+        for row in conversations:
+            conn.execute(
+                """
+                INSERT INTO emails (id, subject, sender, recipient, content, received_date,
+                                    conversation_id, user_id)
+                VALUES (:id, :subject, :sender, :recipient, :content, :received_date,
+                        :conversation_id, 1)
+                """,
+                row
+            )
+        conn.commit()
+    yield EmailService(MagicMock(), db=store)
+    store.close()
+
+
+def flagged(service):
+    """Map flagged email IDs to their detected_at value."""
+    with service.db.get_connection() as conn:
+        rows = conn.execute(
+            "SELECT id, awaiting_reply_detected_at FROM emails WHERE awaiting_reply = 1"
+        ).fetchall()
+    return {row["id"]: row["awaiting_reply_detected_at"] for row in rows}
+
+
+class TestHeuristics:
+    """Tests for the question and thread heuristics."""
+
+    @pytest.mark.parametrize("body,expected", [
+        ("Can we meet at 3?", True),
+        ("Please let me know your availability", True),
+        ("Could you take a look", True),
+        ("Thanks for the update.", False),
+        ("Sounds good\n\nOn Mon, Alice wrote:\n> Can you join?", False),
+        ("Sounds good\n> Can you join?", False),
+    ])
+    def test_looks_like_question(self, body, expected):
+        """Test question detection ignores quoted history."""
+        assert looks_like_question(body) is expected
+
+    def test_strip_quoted_text(self):
+        """Test that original-message blocks are removed."""
+        body = "New text\n-----Original Message-----\nFrom: a@example.com\nOld text"
+
+        assert strip_quoted_text(body) == "New text"
+
+    def test_thread_cases(self, conversations):
+        """Test the thread decision for each fixture conversation."""
+        threads = {}
+        for row in conversations:
+            threads.setdefault(row["conversation_id"], []).append(row)
+
+        decisions = {
+            key: is_awaiting_reply(thread, ME, min_age_days=2, now=NOW)
+            for key, thread in threads.items()
+        }
+
+        assert decisions == {
+            "unreplied": True,
+            "replied": False,
+            "self": False,
+            "recent": False,
+            "statement": False,
+            "other": False,
+        }
+
+    def test_parse_ai_verdict(self):
+        """Test AI output parsing, defaulting to the heuristic result."""
+        assert parse_ai_verdict({"awaiting_reply": False}) is False
+        assert parse_ai_verdict('```json\n{"awaiting_reply": true}\n```') is True
+        assert parse_ai_verdict("not json") is True
+
+
+class TestDetectAwaitingReply:
+    """Tests for persisting awaiting_reply flags."""
+
+    @pytest.mark.asyncio
+    async def test_flags_latest_unreplied_message(self, service):
+        """Test that only the latest message of the unreplied thread is flagged."""
+        count = await detect_awaiting_reply(service, 1, ME, min_age_days=2, now=NOW)
+
+        assert count == 1
+        assert list(flagged(service)) == ["u2"]
+
+        emails, total = await service.get_awaiting_reply(1)
+        assert total == 1
+        assert emails[0]["id"] == "u2"
+
+    @pytest.mark.asyncio
+    async def test_detected_at_kept_and_flags_cleared(self, service):
+        """Test that repeat runs keep detected_at and clear answered threads."""
+        await detect_awaiting_reply(service, 1, ME, min_age_days=2, now=NOW)
+        first_detected = flagged(service)["u2"]
+
+        await detect_awaiting_reply(service, 1, ME, min_age_days=2, now=NOW)
+        assert flagged(service)["u2"] == first_detected
+
+        with service.db.get_connection() as conn:
+            conn.execute(
+                """
+                INSERT INTO emails (id, subject, sender, recipient, content, received_date,
+                                    conversation_id, user_id)
+                VALUES ('u3', 'Re', ?, 'alice@example.com', 'Sent!', ?, 'unreplied', 1)
+                """,
+                (ME, NOW - timedelta(days=1))
+            )
+            conn.commit()
+
+        assert await detect_awaiting_reply(service, 1, ME, min_age_days=2, now=NOW) == 0
+        assert flagged(service) == {}
+
+    @pytest.mark.asyncio
+    async def test_ai_check_can_reject(self, service):
+        """Test that the optional AI check filters heuristic matches."""
+        ai = MagicMock()
+        ai.check_awaiting_reply = AsyncMock(return_value={"awaiting_reply": False})
+
+        count = await detect_awaiting_reply(service, 1, ME, min_age_days=2, ai_service=ai, now=NOW)
+
+        assert count == 0
+        ai.check_awaiting_reply.assert_called_once()
+
+    @pytest.mark.asyncio
+    async def test_ai_failure_keeps_heuristic_result(self, service):
+        """Test that an AI error does not drop a heuristic match."""
+        ai = MagicMock()
+        ai.check_awaiting_reply = AsyncMock(return_value={"error": "offline"})
+
+        assert await detect_awaiting_reply(service, 1, ME, 2, ai_service=ai, now=NOW) == 1
+
+    @pytest.mark.asyncio
+    async def test_counters_include_awaiting_reply(self, service):
+        """Test that the sidebar counters report awaiting-reply threads."""
+        await detect_awaiting_reply(service, 1, ME, min_age_days=2, now=NOW)
+
+        counters = await service.get_counters(user_id=1)
+
+        assert counters.awaiting_reply == 1
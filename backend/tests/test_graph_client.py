@@ -262,7 +262,7 @@ class TestGraphClient:
                     "$top": 2,
                     "$skip": 0,
                     "$orderby": "receivedDateTime desc",
-                    "$select": "id,subject,from,toRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,bodyPreview"
+                    "$select": "id,subject,from,toRecipients,ccRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,bodyPreview"
                 }
             )
     
@@ -278,7 +278,7 @@ class TestGraphClient:
             mock_request.assert_called_once_with(
                 "GET", 
                 "/me/messages/msg1",
-                params={"$select": "id,subject,from,toRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,body,bodyPreview"}
+                params={"$select": "id,subject,from,toRecipients,ccRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,body,bodyPreview"}
             )
     
     def test_get_folders(self, graph_client):
@@ -353,6 +353,6 @@ class TestGraphClient:
                 params={
                     "$filter": "conversationId eq 'conv1'",
                     "$orderby": "receivedDateTime asc",
-                    "$select": "id,subject,from,toRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,bodyPreview"
+                    "$select": "id,subject,from,toRecipients,ccRecipients,receivedDateTime,hasAttachments,isRead,conversationId,categories,importance,bodyPreview"
                 }
             )
\ No newline at end of file
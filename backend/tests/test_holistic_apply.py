@@ -0,0 +1,257 @@
+"""Tests for storing holistic analysis runs and applying their cleanups."""
+
+from datetime import datetime
+from unittest.mock import AsyncMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import ai as ai_api
+from backend.api import emails as emails_api
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import ExcludedFolderError, register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.holistic_runs import ARCHIVE_DUPLICATE, ARCHIVE_SUPERSEDED, cleanup_plan
+
+USER_ID = 1
+
+# Stored and in the mock mailbox; "gone" is analyzed but no longer stored
+EMAILS = ["old-plan", "new-plan", "dup-1", "dup-2", "dup-3", "dup-4", "invite"]
+ANALYZED = EMAILS + ["gone"]
+
+ANALYSIS = {
+    "truly_relevant_actions": [{
+        "action_type": "required_personal_action", "priority": "high", "topic": "Launch plan",
+        "canonical_email_id": "new-plan", "related_email_ids": ["old-plan"], "deadline": "2024-06-20",
+        "why_relevant": "Sign-off", "blocking_others": False,
+    }],
+    "superseded_actions": [
+        {"original_email_id": "old-plan", "superseded_by_email_id": "new-plan", "reason": "Revised"},
+        {"original_email_id": "gone", "superseded_by_email_id": "new-plan", "reason": "Revised"},
+        {"original_email_id": "unanalyzed", "superseded_by_email_id": "new-plan", "reason": "Revised"},
+        {"original_email_id": "new-plan", "superseded_by_email_id": "old-plan", "reason": "Contradiction"},
+    ],
+    "duplicate_groups": [
+        {"topic": "Digest", "email_ids": ["dup-1", "dup-2", "dup-3"], "keep_email_id": "dup-1",
+         "archive_email_ids": ["dup-2", "dup-3"]},
+        {"topic": "Reminder", "email_ids": ["dup-1", "dup-4"], "keep_email_id": "dup-1"},
+    ],
+    "expired_items": [{"email_id": "invite", "reason": "The event was last week"}],
+}
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock mailbox holding EMAILS in the inbox."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    provider.mock_emails = [
+        {"id": email_id, "subject": email_id, "sender": "pat@example.com", "body": "Text.", "folder": "Inbox",
+         "categories": []}
+        for email_id in EMAILS
+    ]
+    return provider
+
+
+@pytest.fixture
+async def service(provider):
+    """Email service over an isolated store with EMAILS, two tasks of the invite, and ANALYSIS stored."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    for email in provider.mock_emails:
+        await email_service.save_classification(email, EmailClassification(category="fyi", confidence=0.9), USER_ID)
+    with store.get_connection() as conn:
+        conn.executemany(
+            "INSERT INTO tasks (title, status, email_id, user_id) VALUES (?, ?, ?, ?)",
+            [("RSVP", "pending", "invite", USER_ID), ("Book room", "completed", "invite", USER_ID)]
+        )
+        conn.commit()
+    email_service.analysis_id = await email_service.record_holistic_analysis(USER_ID, ANALYSIS, ANALYZED)
+    yield email_service
+    store.close()
+
+
+def mailbox_folder(provider, email_id):
+    """Folder an email is in in the mock mailbox."""
+    return next(email["folder"] for email in provider.mock_emails if email["id"] == email_id)
+
+
+def task_statuses(service):
+    """Task title -> status."""
+    with service.db.get_connection() as conn:
+        return {row["title"]: row["status"] for row in conn.execute("SELECT title, status FROM tasks")}
+
+
+class TestCleanupPlan:
+    """Tests for working out a stored run's cleanups."""
+
+    def test_plan(self):
+        """Test that only analyzed emails the run does not keep are archived, duplicates without a list included."""
+        plan = cleanup_plan({"email_ids": ANALYZED, "analysis": ANALYSIS})
+
+        assert plan.archive == {
+            "old-plan": ARCHIVE_SUPERSEDED, "gone": ARCHIVE_SUPERSEDED,
+            "dup-2": ARCHIVE_DUPLICATE, "dup-3": ARCHIVE_DUPLICATE, "dup-4": ARCHIVE_DUPLICATE,
+        }
+        assert plan.expired == ["invite"]
+
+    def test_selection(self):
+        """Test that unselected cleanups are left out."""
+        run = {"email_ids": ANALYZED, "analysis": ANALYSIS}
+
+        assert list(cleanup_plan(run, merge_duplicates=False, complete_expired_tasks=False).archive) == [
+            "old-plan", "gone"
+        ]
+        plan = cleanup_plan(run, archive_superseded=False, merge_duplicates=False)
+        assert (plan.archive, plan.expired) == ({}, ["invite"])
+
+
+class TestApplyHolisticAnalysis:
+    """Tests for EmailService.apply_holistic_analysis."""
+
+    @pytest.mark.asyncio
+    async def test_dry_run_changes_nothing(self, service, provider):
+        """Test that a dry run lists the emails and tasks without changing them."""
+        outcome = await service.apply_holistic_analysis(service.analysis_id, USER_ID, dry_run=True)
+
+        assert [(email["id"], email["reason"]) for email in outcome.archive_candidates] == [
+            ("old-plan", "superseded"), ("dup-2", "duplicate"), ("dup-3", "duplicate"), ("dup-4", "duplicate")
+        ]
+        assert [task["title"] for task in outcome.task_candidates] == ["RSVP"]
+        assert (outcome.missing, outcome.archived, outcome.batch_id) == (["gone"], [], None)
+        assert mailbox_folder(provider, "old-plan") == "Inbox"
+        assert task_statuses(service)["RSVP"] == "pending"
+
+    @pytest.mark.asyncio
+    async def test_apply_and_revert(self, service, provider):
+        """Test that applying archives and closes as one batch, logs it, and reverts."""
+        outcome = await service.apply_holistic_analysis(service.analysis_id, USER_ID)
+
+        assert outcome.archived == ["old-plan", "dup-2", "dup-3", "dup-4"]
+        assert len(outcome.tasks_closed) == 1
+        assert mailbox_folder(provider, "dup-2") == settings.archive_folder
+        assert (await service.get_stored_email("dup-2", USER_ID))["folder"] == settings.archive_folder
+        assert mailbox_folder(provider, "new-plan") == "Inbox"
+        assert task_statuses(service) == {"RSVP": "completed", "Book room": "completed"}
+        entries, total = await service.activity.list_activity(operation="apply_holistic_analysis")
+        assert total == 1
+        assert entries[0].parameters["batch_id"] == outcome.batch_id
+
+        reverted = await service.revert_move_batch(outcome.batch_id, USER_ID)
+
+        assert sorted(reverted.restored) == ["dup-2", "dup-3", "dup-4", "old-plan"]
+        assert reverted.tasks_restored == outcome.tasks_closed
+        assert mailbox_folder(provider, "dup-2") == "Inbox"
+        assert task_statuses(service) == {"RSVP": "pending", "Book room": "completed"}
+
+    @pytest.mark.asyncio
+    async def test_apply_again_changes_nothing(self, service):
+        """Test that a second apply finds nothing left to do."""
+        await service.apply_holistic_analysis(service.analysis_id, USER_ID)
+
+        outcome = await service.apply_holistic_analysis(service.analysis_id, USER_ID)
+
+        assert (outcome.archive_candidates, outcome.task_candidates, outcome.batch_id) == ([], [], None)
+
+    @pytest.mark.asyncio
+    async def test_cancel_and_revert_skips_changed_task(self, service):
+        """Test that tasks can be cancelled and one changed since is not reopened."""
+        outcome = await service.apply_holistic_analysis(
+            service.analysis_id, USER_ID, archive_superseded=False, merge_duplicates=False, task_status="cancelled"
+        )
+        assert (outcome.archived, task_statuses(service)["RSVP"]) == ([], "cancelled")
+        with service.db.get_connection() as conn:
+            conn.execute("UPDATE tasks SET status = 'completed' WHERE title = 'RSVP'")
+            conn.commit()
+
+        reverted = await service.revert_move_batch(outcome.batch_id, USER_ID)
+
+        assert (reverted.tasks_restored, reverted.tasks_changed_since) == ([], outcome.tasks_closed)
+        assert task_statuses(service)["RSVP"] == "completed"
+
+    @pytest.mark.asyncio
+    async def test_errors(self, service, monkeypatch):
+        """Test unknown runs, other users' runs, bad statuses, and an excluded archive folder."""
+        with pytest.raises(LookupError):
+            await service.apply_holistic_analysis(service.analysis_id, USER_ID + 1)
+        with pytest.raises(ValueError):
+            await service.apply_holistic_analysis(service.analysis_id, USER_ID, task_status="pending")
+        monkeypatch.setattr(settings, "excluded_folders", [settings.archive_folder])
+        with pytest.raises(ExcludedFolderError):
+            await service.apply_holistic_analysis(service.analysis_id, USER_ID)
+        assert task_statuses(service)["RSVP"] == "pending"
+
+
+class TestHolisticApplyAPI:
+    """Tests for POST /api/ai/holistic-analysis runs and POST /api/emails/analyses/{id}/apply."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client for the AI and email endpoints with auth, AI, and the email service overridden."""
+        ai = AsyncMock()
+        ai.analyze_inbox.return_value = ANALYSIS
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(ai_api.router, prefix="/api")
+        app.include_router(emails_api.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="pat", email="pat@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: ai
+        ai_api.ai_rate_limiter.reset()
+        yield TestClient(app)
+        ai_api.ai_rate_limiter.reset()
+
+    def test_analysis_run_is_stored(self, client, service):
+        """Test that the analysis returns the ID of a stored run the apply endpoint accepts."""
+        data = client.post("/api/ai/holistic-analysis", json={}).json()
+
+        run = service.holistic_runs.get(data["analysis_id"], USER_ID)
+        assert sorted(run["email_ids"]) == sorted(EMAILS)
+        assert run["analysis"]["expired_items"] == ANALYSIS["expired_items"]
+
+        response = client.post(f"/api/emails/analyses/{data['analysis_id']}/apply", json={"dry_run": True})
+        assert response.status_code == 200
+        assert len(response.json()["archive_candidates"]) == 4
+
+    def test_partial_failure(self, client, service, provider):
+        """Test that missing and unmoved emails are 404 and 502 items in a 207."""
+        provider.mock_emails = [email for email in provider.mock_emails if email["id"] != "dup-3"]
+
+        response = client.post(f"/api/emails/analyses/{service.analysis_id}/apply", json={})
+
+        assert response.status_code == 207
+        data = response.json()
+        assert (data["success_count"], data["failure_count"]) == (4, 2)
+        assert {(error["email_id"], error["status_code"]) for error in data["errors"]} == {
+            ("gone", 404), ("dup-3", 502)
+        }
+        assert data["batch_id"] is not None
+        assert data["expired_task_status"] == "completed"
+
+    def test_tasks_only_then_revert(self, client, service):
+        """Test that closing only the expired tasks succeeds and the batch reopens them."""
+        data = client.post(
+            f"/api/emails/analyses/{service.analysis_id}/apply",
+            json={"archive_superseded": False, "merge_duplicates": False}
+        ).json()
+        assert (data["success_count"], data["failure_count"]) == (1, 0)
+
+        revert = client.post(f"/api/emails/move-batches/{data['batch_id']}/revert").json()
+        assert revert["tasks_restored"] == data["tasks_closed"]
+        assert task_statuses(service)["RSVP"] == "pending"
+
+    def test_invalid_requests(self, client, service):
+        """Test that unknown runs are 404 and unknown task statuses 422."""
+        assert client.post("/api/emails/analyses/999/apply", json={}).status_code == 404
+        assert client.post(
+            f"/api/emails/analyses/{service.analysis_id}/apply", json={"expired_task_status": "pending"}
+        ).status_code == 422
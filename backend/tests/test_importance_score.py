@@ -0,0 +1,140 @@
+"""Tests for classifier importance scores and sorting stored emails by importance."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.ai_models import EmailClassificationResponse, clamp_importance_score
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.classification_store import CLASSIFIER_TEMPLATE, PROMPTS_DIR
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+
+def make_email(email_id, received_time):
+    """Build a provider email dict."""
+    return {
+        "id": email_id,
+        "subject": f"Subject {email_id}",
+        "sender": "alice@example.com",
+        "body": "Hello",
+        "received_time": received_time,
+    }
+
+
+@pytest.fixture
+async def service():
+    """Email service over an isolated store with scored and unscored emails."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    email_service = EmailService(provider, db=store)
+    for email_id, received_time, score in [
+        ("low", "2024-01-04T09:00:00", 1),
+        ("unscored", "2024-01-05T09:00:00", None),
+        ("urgent", "2024-01-01T09:00:00", 5),
+        ("normal_old", "2024-01-02T09:00:00", 3),
+        ("normal_new", "2024-01-03T09:00:00", 3),
+    ]:
+        await email_service.save_classification(
+            make_email(email_id, received_time),
+            EmailClassification(
+                category="fyi", confidence=0.9, importance_score=score,
+                importance_justification=f"Scored {score}" if score else None
+            ),
+            USER_ID
+        )
+    yield email_service
+    store.close()
+
+
+class TestClampImportanceScore:
+    """Tests for normalizing classifier importance scores."""
+
+    @pytest.mark.parametrize("raw,expected", [
+        (1, 1),
+        (5, 5),
+        ("4", 4),
+        (2.4, 2),
+        (-3, 1),
+        (12, 5),
+        (None, 3),
+        ("", 3),
+        ("urgent", 3),
+        (float("nan"), 3),
+    ])
+    def test_clamp(self, raw, expected):
+        """Test that scores are clamped to 1-5 and default to 3."""
+        assert clamp_importance_score(raw) == expected
+
+    def test_response_defaults_to_average(self):
+        """Test that a classification response without a score reports 3."""
+        response = EmailClassificationResponse(
+            category="fyi", confidence=0.9, reasoning="Informational", processing_time=0.1
+        )
+
+        assert response.importance_score == 3
+        assert response.importance_justification is None
+
+    def test_prompt_requests_score(self):
+        """Test that the classifier template asks for the score and justification."""
+        text = (PROMPTS_DIR / CLASSIFIER_TEMPLATE).read_text(encoding="utf-8")
+
+        assert '"importance_score"' in text
+        assert '"importance_justification"' in text
+
+
+class TestImportanceStorage:
+    """Tests for storing importance scores and sorting by them."""
+
+    @pytest.mark.asyncio
+    async def test_sort_by_importance(self, service):
+        """Test that higher scores come first, then newer emails, with unscored last."""
+        emails, total = await service.search_stored_emails(USER_ID, {"category": "fyi"}, sort="importance")
+
+        assert total == 5
+        assert [email["id"] for email in emails] == ["urgent", "normal_new", "normal_old", "low", "unscored"]
+        assert emails[0]["importance_score"] == 5
+        assert emails[0]["importance_justification"] == "Scored 5"
+
+    @pytest.mark.asyncio
+    async def test_reclassification_replaces_score(self, service):
+        """Test that a new classification overwrites the stored score."""
+        await service.save_classification(
+            make_email("urgent", "2024-01-01T09:00:00"),
+            EmailClassification(category="fyi", confidence=0.9, importance_score=2),
+            USER_ID
+        )
+
+        row = await service.get_stored_email("urgent", USER_ID)
+        assert row["importance_score"] == 2
+        assert row["importance_justification"] is None
+
+    def test_list_endpoint_sorts_by_importance(self, service):
+        """Test that GET /emails returns scores and accepts sort=importance."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="sorter", email="sorter@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        client = TestClient(app)
+
+        response = client.get("/api/emails?category=fyi&sort=importance&limit=2")
+
+        assert response.status_code == 200
+        emails = response.json()["emails"]
+        assert [email["id"] for email in emails] == ["urgent", "normal_new"]
+        assert [email["importance_score"] for email in emails] == [5, 3]
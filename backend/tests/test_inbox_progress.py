@@ -0,0 +1,228 @@
+"""Tests for the daily inbox-zero progress metric."""
+
+import asyncio
+from datetime import date, datetime, timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import PROGRESS_STREAK_DAYS, EmailService
+
+USER_ID = 1
+
+# 23:30 on June 2 in Los Angeles (UTC-7); midnight there is 07:00 UTC
+BEFORE_MIDNIGHT = datetime(2024, 6, 3, 6, 30)
+AFTER_MIDNIGHT = datetime(2024, 6, 3, 7, 30)
+
+
+@pytest.fixture
+def store():
+    """In-memory store with the user in Los Angeles."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        conn.execute("INSERT INTO user_settings (user_id, timezone) VALUES (?, 'America/Los_Angeles')", (USER_ID,))
+        conn.commit()
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def service(store):
+    """Email service over an authenticated mock provider and the store."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return EmailService(provider, db=store)
+
+
+def add_email(store, email_id, category=None, processed_at=None, folder="Inbox", user_id=USER_ID,
+              conversation_id=None):
+    """Store an email, classified at processed_at (UTC) if a category is given."""
+    with store.get_connection() as conn:
+        conn.execute(
+            """
+            INSERT INTO emails (id, subject, sender, folder, category, processed_at, user_id, conversation_id)
+            VALUES (?, 'Hi', 'a@example.com', ?, ?, ?, ?, ?)
+            """,
+            (email_id, folder, category, processed_at, user_id, conversation_id)
+        )
+        conn.commit()
+
+
+def classify(store, email_id, processed_at):
+    """Classify a stored email at processed_at (UTC)."""
+    with store.get_connection() as conn:
+        conn.execute("UPDATE emails SET category = 'fyi', processed_at = ? WHERE id = ?", (processed_at, email_id))
+        conn.commit()
+
+
+def progress(service, now):
+    """Inbox progress as of now (UTC)."""
+    return asyncio.run(service.get_inbox_progress(USER_ID, now=now))
+
+
+class TestCounts:
+    """Tests for today's counts."""
+
+    def test_classified_today_in_user_timezone(self, store, service):
+        """Test that 'today' ends at the user's midnight, not UTC's."""
+        add_email(store, "late", "fyi", processed_at=datetime(2024, 6, 3, 6, 0))  # 23:00 June 2 local
+        add_email(store, "early", "fyi", processed_at=datetime(2024, 6, 2, 6, 0))  # 23:00 June 1 local
+        add_email(store, "archived", "fyi", processed_at=datetime(2024, 6, 3, 6, 0), folder="Archive")
+
+        before = progress(service, BEFORE_MIDNIGHT)
+        after = progress(service, AFTER_MIDNIGHT)
+
+        assert (before.date, before.timezone, before.classified_count) == (date(2024, 6, 2), "America/Los_Angeles", 1)
+        assert (after.date, after.classified_count) == (date(2024, 6, 3), 0)
+
+    def test_applied_counts_successful_outlook_writes(self, store, service):
+        """Test that emails successfully categorized or moved today count once each."""
+        add_email(store, "mine", "fyi")
+        add_email(store, "theirs", "fyi", user_id=2)
+        with store.get_connection() as conn:
+            conn.executemany(
+                """
+                INSERT INTO outlook_activity (operation, email_id, parameters, result, duration_ms, created_at)
+                VALUES (?, ?, '{}', ?, 1.0, ?)
+                """,
+                [
+                    ("categorize_email", "mine", "success", datetime(2024, 6, 3, 5, 0)),
+                    ("move_email", "mine", "success", datetime(2024, 6, 3, 5, 1)),
+                    ("move_email", "theirs", "success", datetime(2024, 6, 3, 5, 2)),
+                    ("mark_as_read", "mine", "success", datetime(2024, 6, 3, 5, 3)),
+                ]
+            )
+            conn.execute(
+                "INSERT INTO outlook_activity (operation, email_id, parameters, result, duration_ms, created_at) "
+                "VALUES ('move_email', 'mine', '{}', 'error', 1.0, ?)",
+                (datetime(2024, 6, 3, 5, 4),)
+            )
+            conn.commit()
+
+        assert progress(service, BEFORE_MIDNIGHT).applied_count == 1
+        assert progress(service, AFTER_MIDNIGHT).applied_count == 0
+
+    def test_tasks_completed_today(self, store, service):
+        """Test that only tasks completed today count."""
+        with store.get_connection() as conn:
+            conn.executemany(
+                "INSERT INTO tasks (title, status, updated_at, user_id) VALUES ('Task', ?, ?, ?)",
+                [
+                    ("completed", datetime(2024, 6, 3, 6, 0), USER_ID),
+                    ("completed", datetime(2024, 6, 2, 6, 0), USER_ID),
+                    ("pending", datetime(2024, 6, 3, 6, 0), USER_ID),
+                    ("completed", datetime(2024, 6, 3, 6, 0), 2),
+                ]
+            )
+            conn.commit()
+
+        assert progress(service, BEFORE_MIDNIGHT).tasks_completed == 1
+
+    def test_unprocessed_inbox_count(self, store, service):
+        """Test that uncategorized inbox emails count, except in other folders and muted conversations."""
+        add_email(store, "waiting")
+        add_email(store, "done", "fyi", processed_at=datetime(2024, 6, 3, 6, 0))
+        add_email(store, "elsewhere", folder="Archive")
+        add_email(store, "muted", conversation_id="conv-muted")
+        asyncio.run(service.mute_conversation("conv-muted", USER_ID))
+
+        assert progress(service, BEFORE_MIDNIGHT).unprocessed_count == 1
+
+
+class TestStreak:
+    """Tests for the inbox-zero streak across days."""
+
+    def test_streak_walks_across_midnight(self, store, service):
+        """Test that each day the inbox hits zero extends the streak, and today counts once it does."""
+        for day in range(3):
+            email_id = f"day-{day}"
+            add_email(store, email_id)
+            morning = datetime(2024, 6, 1 + day, 16, 0)  # 09:00 local
+            assert progress(service, morning).unprocessed_count == 1
+            classify(store, email_id, morning + timedelta(hours=1))
+            assert progress(service, morning + timedelta(hours=2)).streak_days == day + 1
+
+        add_email(store, "next-morning")
+        next_morning = progress(service, datetime(2024, 6, 4, 16, 0))
+
+        assert (next_morning.unprocessed_count, next_morning.streak_days) == (1, 3)
+        assert [(day.date.day, day.reached_zero) for day in next_morning.history] == [
+            (1, True), (2, True), (3, True), (4, False)
+        ]
+
+    def test_zero_at_any_snapshot_counts(self, store, service):
+        """Test that mail arriving after the inbox hit zero keeps the day in the streak."""
+        progress(service, BEFORE_MIDNIGHT)
+        add_email(store, "late-arrival")
+
+        late = progress(service, BEFORE_MIDNIGHT + timedelta(minutes=10))
+
+        assert (late.unprocessed_count, late.streak_days) == (1, 1)
+        assert late.history[-1].reached_zero is True
+
+    def test_missed_day_breaks_streak(self, store, service):
+        """Test that a day without zero, or without a snapshot, ends the streak."""
+        progress(service, datetime(2024, 6, 1, 16, 0))
+        progress(service, datetime(2024, 6, 3, 16, 0))
+
+        assert progress(service, datetime(2024, 6, 3, 17, 0)).streak_days == 1
+
+    def test_streak_capped(self, store, service):
+        """Test that the streak counts at most PROGRESS_STREAK_DAYS days."""
+        for day in range(10):
+            latest = progress(service, datetime(2024, 6, 1 + day, 16, 0))
+
+        assert latest.streak_days == PROGRESS_STREAK_DAYS
+        assert len(latest.history) == PROGRESS_STREAK_DAYS
+
+    def test_snapshots_survive_restart(self, store, service):
+        """Test that a new service over the same database keeps the streak."""
+        progress(service, datetime(2024, 6, 1, 16, 0))
+        progress(service, datetime(2024, 6, 2, 16, 0))
+
+        restarted = EmailService(MockEmailProvider(), db=store)
+
+        assert progress(restarted, datetime(2024, 6, 3, 1, 0)).streak_days == 2  # 18:00 June 2 local
+
+    def test_snapshot_every_user(self, store, service):
+        """Test that the background snapshot records a day for each user with stored emails."""
+        add_email(store, "mine")
+        add_email(store, "theirs", user_id=2)
+
+        assert asyncio.run(service.snapshot_inbox_progress(now=BEFORE_MIDNIGHT)) == 2
+        with store.get_connection() as conn:
+            rows = conn.execute(
+                "SELECT user_id, day, unprocessed_count FROM inbox_progress ORDER BY user_id"
+            ).fetchall()
+        assert [tuple(row) for row in rows] == [(1, "2024-06-02", 1), (2, "2024-06-03", 1)]
+
+
+class TestEndpoint:
+    """Tests for GET /api/emails/progress."""
+
+    def test_get_progress(self, store, service):
+        """Test that today's progress is returned for the current user."""
+        add_email(store, "waiting")
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="triager", email="triager@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+
+        response = TestClient(app).get("/api/emails/progress")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert (data["unprocessed_count"], data["streak_days"], data["timezone"]) == (1, 0, "America/Los_Angeles")
+        assert data["history"] == [{"date": data["date"], "unprocessed_count": 1, "reached_zero": False}]
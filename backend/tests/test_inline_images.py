@@ -0,0 +1,169 @@
+"""Tests for serving inline images and pointing cid: references at them."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.attachments import email_attachments
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.core.sanitize import content_id, inline_image_url, rewrite_cid_references, sanitize_html
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import EmailProvider, MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+PNG = b"\x89PNG\r\n\x1a\n"
+
+
+class TestRewrite:
+    """Tests for rewriting cid: image sources while sanitizing."""
+
+    @pytest.mark.parametrize("value,expected", [
+        ("cid:logo@01", "logo@01"),
+        ("CID:Logo%4001", "Logo@01"),
+        ("<logo@01>", "logo@01"),
+        ("  logo@01 ", "logo@01"),
+        ("cid:", ""),
+    ])
+    def test_content_id(self, value, expected):
+        """Test that cid: URLs and bracketed Content-IDs normalize to the bare ID."""
+        assert content_id(value) == expected
+
+    def test_cid_image_points_at_endpoint(self):
+        """Test that a cid: image loads from the email's inline endpoint."""
+        body = '<p>Hi</p><img src="cid:logo@01" alt="Logo" width="120">'
+
+        assert rewrite_cid_references(body, "mock-email-1") == (
+            '<p>Hi</p><img src="/api/emails/mock-email-1/inline/logo%4001" alt="Logo" width="120">'
+        )
+
+    def test_ids_are_escaped(self):
+        """Test that slashes and other reserved characters in IDs stay inside their path segment."""
+        assert inline_image_url("AAMk/x=", "<a/b?c>") == "/api/emails/AAMk%2Fx%3D/inline/a%2Fb%3Fc"
+
+    def test_rewritten_urls_survive_sanitizing(self):
+        """Test that sanitizing a rewritten body again keeps its inline URLs."""
+        rewritten = rewrite_cid_references('<img src="cid:logo@01">', "e1")
+
+        assert sanitize_html(rewritten) == rewritten
+        assert rewrite_cid_references(rewritten, "e1") == rewritten
+
+    def test_only_cid_images_rewritten(self):
+        """Test that remote images, links, and other relative URLs are handled as before."""
+        body = (
+            '<img src="https://cdn.example.com/a.png" width="50" height="50">'
+            '<a href="cid:logo@01">logo</a><img src="/api/users/me"><img src="cid:">'
+        )
+
+        assert rewrite_cid_references(body, "e1") == (
+            '<img src="https://cdn.example.com/a.png" width="50" height="50"><a>logo</a>'
+        )
+
+    def test_without_rewrite_cid_is_kept(self):
+        """Test that plain sanitizing keeps cid: sources and plain text is left alone."""
+        assert sanitize_html('<img src="cid:logo@01">') == '<img src="cid:logo@01">'
+        assert rewrite_cid_references("See cid:logo@01", "e1") == "See cid:logo@01"
+
+    def test_content_ids_are_recorded(self):
+        """Test that attachment metadata keeps the Content-ID of inline attachments."""
+        email = {"attachments": [
+            {"name": "logo.png", "size": 8, "content_id": "logo@01"}, {"name": "deck.pptx", "size": 2048}
+        ]}
+
+        assert email_attachments(email) == [
+            {"name": "logo.png", "size": 8, "content_id": "logo@01"}, {"name": "deck.pptx", "size": 2048}
+        ]
+
+
+@pytest.fixture
+def provider():
+    """Mock provider whose first email embeds a PNG and an SVG."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    provider.inline_attachments = {
+        ("mock-email-1", "logo@01"): {"name": "logo.png", "content_type": "image/png", "data": PNG},
+        ("mock-email-1", "chart@01"): {"name": "chart.svg", "content_type": "", "data": b"<svg/>"},
+    }
+    return provider
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def client(provider, store):
+    """Client for the email endpoints over the mock provider."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="viewer", email="viewer@example.com", hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: EmailService(provider, db=store)
+    return TestClient(app)
+
+
+class TestInlineEndpoint:
+    """Tests for GET /api/emails/{id}/inline/{cid}."""
+
+    @pytest.mark.parametrize("cid", ["logo@01", "logo%4001", "%3Clogo@01%3E"])
+    def test_image_served(self, client, cid):
+        """Test that the attachment is found by Content-ID however it is written."""
+        response = client.get(f"/api/emails/mock-email-1/inline/{cid}")
+
+        assert response.status_code == 200
+        assert response.content == PNG
+        assert response.headers["content-type"] == "image/png"
+        assert response.headers["x-content-type-options"] == "nosniff"
+
+    def test_non_image_is_a_download(self, client):
+        """Test that types a browser could run, like SVG, are not served as images."""
+        response = client.get("/api/emails/mock-email-1/inline/chart@01")
+
+        assert response.headers["content-type"] == "application/octet-stream"
+        assert response.headers["content-disposition"] == "attachment"
+
+    @pytest.mark.parametrize("path", ["mock-email-1/inline/missing@01", "unknown/inline/logo@01"])
+    def test_missing_cid_is_404(self, client, path):
+        """Test that an unknown Content-ID or email is a 404."""
+        assert client.get(f"/api/emails/{path}").status_code == 404
+
+    def test_unsupported_provider_is_501(self, store):
+        """Test that providers that cannot read attachments answer 501."""
+        provider = MagicMock(spec=EmailProvider)
+        provider.get_inline_attachment.side_effect = NotImplementedError("no attachments")
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="viewer", email="viewer@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: EmailService(provider, db=store)
+
+        assert TestClient(app).get("/api/emails/e1/inline/logo@01").status_code == 501
+
+    def test_rewritten_body_links_resolve(self, client, provider, store):
+        """Test that GET /emails/{id}?rewrite_cid=true gives image URLs the inline endpoint serves."""
+        provider.mock_emails[0]["body"] = '<p>Logo:</p><img src="cid:logo@01">'
+        asyncio.run(EmailService(provider, db=store).store_email(provider.mock_emails[0], USER_ID))
+
+        plain = client.get("/api/emails/mock-email-1").json()
+        rewritten = client.get("/api/emails/mock-email-1", params={"rewrite_cid": True}).json()
+
+        assert 'src="cid:logo@01"' in plain["content"]
+        url = "/api/emails/mock-email-1/inline/logo%4001"
+        assert f'src="{url}"' in rewritten["content"]
+        assert client.get(url).content == PNG
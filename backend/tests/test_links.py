@@ -0,0 +1,168 @@
+"""Tests for email link extraction."""
+
+import pytest
+
+from backend.core.links import (
+    ADO_WORK_ITEM, MEETING, OTHER, PULL_REQUEST, SHAREPOINT_DOC,
+    classify_link, extract_links, format_links_for_description, trim_url
+)
+
+
+def urls(body):
+    """Extract just the URLs from a body."""
+    return [link["url"] for link in extract_links(body)]
+
+
+class TestTrimUrl:
+    """Tests for trimming bare URLs out of prose."""
+
+    @pytest.mark.parametrize("raw,expected", [
+        ("https://example.com/a.", "https://example.com/a"),
+        ("https://example.com/a,", "https://example.com/a"),
+        ("https://example.com/a?!", "https://example.com/a"),
+        ("https://example.com/a).", "https://example.com/a"),
+        ("https://en.wikipedia.org/wiki/Foo_(bar)", "https://en.wikipedia.org/wiki/Foo_(bar)"),
+        ("https://en.wikipedia.org/wiki/Foo_(bar))", "https://en.wikipedia.org/wiki/Foo_(bar)"),
+        ("https://example.com/a]", "https://example.com/a"),
+        ("https://example.com/a?q=1", "https://example.com/a?q=1"),
+        ("https://example.com/", "https://example.com/"),
+    ])
+    def test_trim(self, raw, expected):
+        """Test that trailing punctuation is stripped but balanced brackets stay."""
+        assert trim_url(raw) == expected
+
+
+class TestPlainTextExtraction:
+    """Tests for URLs in plain-text bodies."""
+
+    @pytest.mark.parametrize("body,expected", [
+        ("See https://example.com/doc.", ["https://example.com/doc"]),
+        ("Link: <https://example.com/doc>", ["https://example.com/doc"]),
+        ("(see https://example.com/doc)", ["https://example.com/doc"]),
+        ('"https://example.com/doc"', ["https://example.com/doc"]),
+        ("https://example.com/search?q=a%20b&lang=en;", ["https://example.com/search?q=a%20b&lang=en"]),
+        ("Two: https://a.example.com and https://b.example.com/x",
+         ["https://a.example.com", "https://b.example.com/x"]),
+        ("Dup https://example.com/x then https://example.com/x.", ["https://example.com/x"]),
+        ("ftp://example.com/file and mailto:me@example.com", []),
+        ("No links here", []),
+        ("", []),
+    ])
+    def test_extract(self, body, expected):
+        """Test bare URL extraction, trimming, and deduplication."""
+        assert urls(body) == expected
+
+    def test_safelinks_unwrapped_and_deduplicated(self):
+        """Test that a Safe Link and its plain target count as one link."""
+        body = (
+            "Doc: https://nam12.safelinks.protection.outlook.com/?url=https%3A%2F%2Fexample.com%2Fdoc"
+            "%3Fa%3D1%26b%3D2&data=05%7C01 and again https://example.com/doc?a=1&b=2"
+        )
+
+        assert urls(body) == ["https://example.com/doc?a=1&b=2"]
+
+
+class TestHtmlExtraction:
+    """Tests for anchors and bare URLs in HTML bodies."""
+
+    def test_anchor_text_and_bare_urls(self):
+        """Test that anchors keep their text and bare URLs in text are found."""
+        body = (
+            '<p>Please review <a href="https://github.com/org/repo/pull/42">my <b>PR</b></a>'
+            " and https://example.com/notes.</p>"
+        )
+
+        assert extract_links(body) == [
+            {"url": "https://github.com/org/repo/pull/42", "type": PULL_REQUEST, "anchor_text": "my PR"},
+            {"url": "https://example.com/notes", "type": OTHER, "anchor_text": None},
+        ]
+
+    def test_entities_decoded_in_href(self):
+        """Test that &amp; in an href is decoded to a plain ampersand."""
+        body = '<a href="https://example.com/?a=1&amp;b=2">Query</a>'
+
+        assert urls(body) == ["https://example.com/?a=1&b=2"]
+
+    def test_first_anchor_text_wins(self):
+        """Test that duplicates keep the first non-empty anchor text."""
+        body = (
+            "<div>https://example.com/x</div>"
+            '<a href="https://example.com/x">First</a>'
+            '<a href="https://example.com/x">Second</a>'
+        )
+
+        assert extract_links(body) == [
+            {"url": "https://example.com/x", "type": OTHER, "anchor_text": "First"}
+        ]
+
+    def test_unsafe_and_relative_hrefs_ignored(self):
+        """Test that javascript:, mailto:, and relative hrefs are not links."""
+        body = (
+            '<a href="javascript:alert(1)">x</a><a href="mailto:a@example.com">mail</a>'
+            '<a href="/relative">rel</a><a href="#top">top</a>'
+        )
+
+        assert urls(body) == []
+
+    def test_script_and_style_ignored(self):
+        """Test that URLs inside scripts and styles are not extracted."""
+        body = (
+            "<style>.x { background: url(https://cdn.example.com/bg.png) }</style>"
+            "<script>fetch('https://evil.example.com')</script><p>Hi</p>"
+        )
+
+        assert urls(body) == []
+
+    def test_safelinks_anchor_unwrapped(self):
+        """Test that Safe Links hrefs are unwrapped to their target."""
+        body = (
+            '<a href="https://eur01.safelinks.protection.outlook.com/?url='
+            'https%3A%2F%2Fcontoso.sharepoint.com%2Fsites%2Fteam%2FDoc.docx&amp;data=1">Spec</a>'
+        )
+
+        assert extract_links(body) == [{
+            "url": "https://contoso.sharepoint.com/sites/team/Doc.docx",
+            "type": SHAREPOINT_DOC,
+            "anchor_text": "Spec",
+        }]
+
+
+class TestClassifyLink:
+    """Tests for link type classification."""
+
+    @pytest.mark.parametrize("url,expected", [
+        ("https://github.com/org/repo/pull/42", PULL_REQUEST),
+        ("https://github.com/org/repo/pull/42/files", PULL_REQUEST),
+        ("https://github.com/org/repo/issues/42", OTHER),
+        ("https://dev.azure.com/org/project/_git/repo/pullrequest/1234", PULL_REQUEST),
+        ("https://org.visualstudio.com/project/_git/repo/pullrequest/1234?_a=files", PULL_REQUEST),
+        ("https://dev.azure.com/org/project/_workitems/edit/98765", ADO_WORK_ITEM),
+        ("https://org.visualstudio.com/project/_workitems/edit/98765/", ADO_WORK_ITEM),
+        ("https://dev.azure.com/org/project/_boards", OTHER),
+        ("https://contoso.sharepoint.com/:w:/r/sites/team/Doc.docx?d=abc", SHAREPOINT_DOC),
+        ("https://contoso-my.sharepoint.com/personal/me/Documents/a.xlsx", SHAREPOINT_DOC),
+        ("https://sharepoint.com.evil.example.com/doc", OTHER),
+        ("https://teams.microsoft.com/l/meetup-join/19%3ameeting_abc%40thread.v2/0?context=x", MEETING),
+        ("https://teams.microsoft.com/l/channel/19%3aabc", OTHER),
+        ("https://us02web.zoom.us/j/123456789?pwd=abc", MEETING),
+        ("https://meet.google.com/abc-defg-hij", MEETING),
+        ("https://contoso.webex.com/meet/someone", MEETING),
+        ("https://example.com/pull/42", OTHER),
+    ])
+    def test_classify(self, url, expected):
+        """Test pattern-based classification."""
+        assert classify_link(url) == expected
+
+
+def test_format_links_for_description():
+    """Test the task description block."""
+    links = [
+        {"url": "https://github.com/org/repo/pull/42", "type": PULL_REQUEST, "anchor_text": "My PR"},
+        {"url": "https://meet.google.com/abc-defg-hij", "type": MEETING, "anchor_text": None},
+    ]
+
+    assert format_links_for_description(links) == (
+        "Links:\n"
+        "- [pull_request] My PR: https://github.com/org/repo/pull/42\n"
+        "- [meeting] https://meet.google.com/abc-defg-hij"
+    )
@@ -0,0 +1,252 @@
+"""Tests for muting conversations."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import AsyncMock, MagicMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import emails as emails_api
+from backend.api import processing as processing_api
+from backend.api.auth import get_current_user
+from backend.api.processing import get_known_folders
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.classification_store import ClassificationStore, get_classification_store
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import MUTED_CATEGORY, MUTED_REASONING, EmailService
+from backend.services.folder_profile_service import FolderProfileService, get_folder_profile_service
+from backend.services.thread_classifier import classify_batch
+
+USER_ID = 1
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def service(store):
+    """Email service over the mock mailbox with both mock emails and a third message in conv-1 stored."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    service = EmailService(provider, db=store)
+    for email in provider.mock_emails:
+        asyncio.run(service.store_email(email, USER_ID))
+    asyncio.run(service.store_email({
+        "id": "reply-1", "subject": "Re: Test Email 1", "sender": "test3@example.com", "body": "Me too.",
+        "received_time": "2024-01-02T10:00:00Z", "conversation_id": "conv-1", "folder": "Inbox",
+    }, USER_ID))
+    return service
+
+
+@pytest.fixture
+def stub_ai():
+    """Stub AI client classifying everything as team_action."""
+    ai = MagicMock()
+    ai.classify_email_async = AsyncMock(return_value={
+        "category": "team_action", "confidence": 0.9, "reasoning": "Needs a reply"
+    })
+    return ai
+
+
+@pytest.fixture
+def client(service, stub_ai):
+    """Client for the email endpoints over the service."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(emails_api.router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="muter", email="user@example.com", hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: service
+    app.dependency_overrides[get_ai_service] = lambda: stub_ai
+    return TestClient(app)
+
+
+def add_task(store, email_id, priority="high", status="pending", description="Reply to the thread"):
+    """Insert a task made from a stored email and return its ID."""
+    with store.get_connection() as conn:
+        cursor = conn.execute(
+            "INSERT INTO tasks (title, description, status, priority, email_id, user_id) VALUES (?, ?, ?, ?, ?, ?)",
+            ("Follow up", description, status, priority, email_id, USER_ID)
+        )
+        conn.commit()
+    return cursor.lastrowid
+
+
+def task(store, task_id):
+    """Read a task's priority and description."""
+    with store.get_connection() as conn:
+        row = conn.execute("SELECT priority, description FROM tasks WHERE id = ?", (task_id,)).fetchone()
+    return row["priority"], row["description"]
+
+
+def category(store, email_id):
+    """Read a stored email's category."""
+    with store.get_connection() as conn:
+        return conn.execute("SELECT category FROM emails WHERE id = ?", (email_id,)).fetchone()["category"]
+
+
+class TestMuting:
+    """Tests for the mute and unmute endpoints."""
+
+    def test_mute_downgrades_open_tasks(self, client, store):
+        """Test that open tasks drop to low with a note and finished ones are left alone."""
+        open_task = add_task(store, "reply-1")
+        done_task = add_task(store, "mock-email-1", status="completed")
+        other_task = add_task(store, "mock-email-2")
+
+        data = client.post("/api/conversations/conv-1/mute").json()
+
+        assert data == {"conversation_id": "conv-1", "muted": True, "tasks_downgraded": 1}
+        priority, description = task(store, open_task)
+        assert priority == "low"
+        assert description.startswith("Reply to the thread\n\n")
+        assert "lowered from high because the conversation was muted" in description
+        assert task(store, done_task) == ("high", "Reply to the thread")
+        assert task(store, other_task) == ("high", "Reply to the thread")
+
+    def test_mute_twice(self, client, store):
+        """Test that muting again changes nothing."""
+        add_task(store, "reply-1")
+        client.post("/api/conversations/conv-1/mute")
+
+        assert client.post("/api/conversations/conv-1/mute").json()["tasks_downgraded"] == 0
+
+    def test_unmute(self, client):
+        """Test that a muted conversation can be unmuted once."""
+        client.post("/api/conversations/conv-1/mute")
+
+        assert client.post("/api/conversations/conv-1/unmute").json()["muted"] is False
+        assert client.post("/api/conversations/conv-1/unmute").status_code == 404
+
+    def test_merged_conversation_muted_as_canonical(self, client, service):
+        """Test that muting a conversation merged into another mutes the merged group."""
+        asyncio.run(service.merge_conversations(USER_ID, conversation_ids=["conv-1", "conv-2"]))
+
+        data = client.post("/api/conversations/conv-2/mute").json()
+
+        assert data["conversation_id"] == "conv-1"
+        assert asyncio.run(service.get_muted_conversations(USER_ID, ["conv-1", "conv-2", "conv-3"])) == {
+            "conv-1", "conv-2"
+        }
+
+
+class TestListing:
+    """Tests for leaving muted conversations out of listings and counters."""
+
+    def test_provider_listing_excludes_muted(self, client):
+        """Test that GET /emails leaves muted emails out unless include_muted=true."""
+        client.post("/api/conversations/conv-1/mute")
+
+        assert [email["id"] for email in client.get("/api/emails").json()["emails"]] == ["mock-email-2"]
+        listed = client.get("/api/emails", params={"include_muted": True}).json()["emails"]
+        assert [email["id"] for email in listed] == ["mock-email-1", "mock-email-2"]
+
+    def test_stored_search_excludes_muted(self, client):
+        """Test that stored searches leave muted emails out and count without them."""
+        client.post("/api/conversations/conv-1/mute")
+
+        data = client.get("/api/emails", params={"sender": "example.com"}).json()
+        assert ([email["id"] for email in data["emails"]], data["total"]) == (["mock-email-2"], 1)
+        assert client.get("/api/emails", params={"sender": "example.com", "include_muted": True}).json()["total"] == 3
+
+    def test_merged_member_excluded(self, client, service):
+        """Test that a conversation muted before a merge still hides its emails."""
+        client.post("/api/conversations/conv-2/mute")
+        asyncio.run(service.merge_conversations(USER_ID, conversation_ids=["conv-1", "conv-2"]))
+
+        data = client.get("/api/emails", params={"sender": "example.com"}).json()
+
+        assert {email["id"] for email in data["emails"]} == {"mock-email-1", "reply-1"}
+
+    def test_counters_exclude_muted(self, service):
+        """Test that muted emails are not counted."""
+        assert asyncio.run(service.get_counters(USER_ID)).conversation_count == 2
+        asyncio.run(service.mute_conversation("conv-1", USER_ID))
+
+        counters = asyncio.run(service.get_counters(USER_ID))
+
+        assert counters.conversation_count == 1
+        assert (counters.by_folder["Inbox"].total, counters.by_folder["Inbox"].unread) == (1, 0)
+
+
+class TestClassification:
+    """Tests for skipping the AI for muted conversations."""
+
+    @pytest.mark.parametrize("thread_mode", [False, True])
+    def test_classify_batch_tags_muted(self, service, stub_ai, thread_mode):
+        """Test that muted emails are stored as fyi without an AI call."""
+        emails = [dict(email) for email in service.provider.mock_emails]
+
+        outcome = asyncio.run(classify_batch(
+            emails, stub_ai, service, USER_ID, thread_mode=thread_mode, muted_conversations={"conv-1"}
+        ))
+
+        assert stub_ai.classify_email_async.await_count == 1
+        assert outcome.muted == 1
+        assert (outcome.results[0].category, outcome.results[0].reasoning) == (MUTED_CATEGORY, MUTED_REASONING)
+        assert outcome.results[1].category == "team_action"
+        assert (category(service.db, "mock-email-1"), category(service.db, "mock-email-2")) == (
+            MUTED_CATEGORY, "team_action"
+        )
+
+    def test_batch_process_skips_muted(self, client, store, stub_ai):
+        """Test that the batch endpoint reports muted emails and only classifies the rest."""
+        client.post("/api/conversations/conv-1/mute")
+
+        data = client.post("/api/emails/batch-process", json={
+            "emails": [{"email_id": "mock-email-1"}, {"email_id": "mock-email-2"}],
+            "skip_already_processed": False
+        }).json()
+
+        assert (data["muted_count"], data["ai_calls"], data["successful_count"]) == (1, 1, 2)
+        assert category(store, "mock-email-1") == MUTED_CATEGORY
+
+    @pytest.fixture
+    def pipeline_client(self, store):
+        """Client for the processing endpoints with two stored emails, one in a muted conversation."""
+        with store.get_connection() as conn:
+            conn.executemany(
+                "INSERT INTO emails (id, subject, sender, user_id, conversation_id) "
+                "VALUES (?, 'Hi', 'a@example.com', 'user_1', ?)",
+                [("quiet", "conv-quiet"), ("loud", "conv-loud")]
+            )
+            conn.execute("INSERT INTO muted_conversations (user_id, conversation_id) VALUES ('user_1', 'conv-quiet')")
+            conn.commit()
+
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(processing_api.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: {"user_id": "user_1"}
+        app.dependency_overrides[get_folder_profile_service] = lambda: FolderProfileService(db=store)
+        app.dependency_overrides[get_classification_store] = lambda: ClassificationStore(db=store)
+        app.dependency_overrides[get_known_folders] = lambda: None
+        return TestClient(app)
+
+    def test_pipeline_tags_muted(self, pipeline_client, store):
+        """Test that the pipeline tags muted emails instead of queueing them."""
+        with patch("backend.api.processing.email_processor_worker") as background_worker:
+            background_worker.start = AsyncMock()
+            data = pipeline_client.post("/api/processing/start", json={"email_ids": ["quiet", "loud"]}).json()
+
+        assert (data["email_count"], data["muted_count"], data["skipped_count"]) == (1, 1, 0)
+        assert category(store, "quiet") == MUTED_CATEGORY
+        assert category(store, "loud") is None
+        processing_api.job_queue._pipelines.pop(data["pipeline_id"], None)
+
+    def test_pipeline_dry_run_leaves_muted_untagged(self, pipeline_client, store):
+        """Test that a dry run leaves muted emails out without tagging them."""
+        data = pipeline_client.post("/api/processing/start", json={"email_ids": ["quiet"], "dry_run": True}).json()
+
+        assert (data["status"], data["muted_count"]) == ("skipped", 1)
+        assert category(store, "quiet") is None
@@ -0,0 +1,287 @@
+"""Tests for the notification event bus and its WebSocket stream."""
+
+import asyncio
+from datetime import datetime, timedelta
+from functools import partial
+from unittest.mock import AsyncMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.processing import router
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager
+from backend.models.task import TaskCreate, TaskStatus, TaskUpdate
+from backend.services.ai_health import AIHealthMonitor
+from backend.services.classification_store import ClassificationStore
+from backend.services.event_bus import EventBus, Notification, event_bus, notify
+from backend.services.job_queue import JobQueue
+from backend.services.task_reminders import fire_task_reminders
+from backend.services.task_service import TaskService
+from backend.workers.email_processor import EmailProcessorWorker, MockEmailService, MockTaskService
+
+USER_ID = "1"
+
+
+@pytest.fixture
+def published():
+    """Notifications published on the global bus during the test."""
+    received = []
+
+    async def record(notification):
+        received.append(notification)
+
+    unsubscribe = event_bus.subscribe(record)
+    yield received
+    unsubscribe()
+
+
+class TestEventBus:
+    """Tests for publishing to subscribers."""
+
+    def test_delivers_to_every_subscriber(self):
+        """Test that a failing subscriber does not stop delivery to the others."""
+        bus = EventBus()
+        received = []
+
+        async def broken(notification):
+            raise RuntimeError("boom")
+
+        async def record(notification):
+            received.append(notification.event_type)
+
+        bus.subscribe(broken)
+        unsubscribe = bus.subscribe(record)
+        asyncio.run(bus.publish(Notification("task_reminder", "Due", "Soon")))
+        unsubscribe()
+        asyncio.run(bus.publish(Notification("task_reminder", "Due", "Soon")))
+
+        assert received == ["task_reminder"]
+
+    def test_unknown_type_rejected(self):
+        """Test that only declared notification types can be published."""
+        with pytest.raises(ValueError):
+            asyncio.run(EventBus().publish(Notification("made_up", "x", "y")))
+
+    def test_message_shape(self, published):
+        """Test the WebSocket message built from a notification."""
+        asyncio.run(notify("budget_warning", "Low", "80% used", user_id=1, level="warning"))
+
+        message = published[0].to_message()
+        assert message["type"] == "notification"
+        assert (message["event_type"], message["user_id"], message["data"]) == (
+            "budget_warning", "1", {"level": "warning"}
+        )
+        assert message["id"] and message["timestamp"]
+
+
+class TestNotificationStream:
+    """Tests for notifications over /api/processing/ws."""
+
+    @pytest.fixture
+    def client(self):
+        """Client with the processing router."""
+        app = FastAPI()
+        app.include_router(router, prefix="/api")
+        with TestClient(app) as client:
+            yield client
+
+    def connect(self, client, user_id=USER_ID):
+        """Open the general WebSocket."""
+        return client.websocket_connect(f"/api/processing/ws?user_id={user_id}")
+
+    def hello(self, ws):
+        """Read the connect messages and return the hello."""
+        assert ws.receive_json()["type"] == "connection_established"
+        return ws.receive_json()
+
+    def publish(self, client, event_type, user_id=USER_ID, **data):
+        """Publish on the app's event loop."""
+        client.portal.call(partial(notify, event_type, "Title", "Message", user_id=user_id, **data))
+
+    def test_hello(self, client):
+        """Test that every connection, including reconnects, starts with a hello."""
+        for _ in range(2):
+            with self.connect(client) as ws:
+                hello = self.hello(ws)
+
+            assert hello["type"] == "hello"
+            assert hello["version"] == settings.app_version
+            assert datetime.fromisoformat(hello["server_time"])
+            assert "task_reminder" in hello["notification_types"]
+
+    def test_only_the_users_notifications(self, client):
+        """Test that user notifications reach only that user while broadcasts reach everyone."""
+        with self.connect(client) as ws:
+            self.hello(ws)
+            self.publish(client, "task_reminder", user_id="2", task_id=7)
+            self.publish(client, "ai_health_changed", user_id=None, status="unhealthy")
+            self.publish(client, "task_reminder", task_id=8)
+
+            assert ws.receive_json()["event_type"] == "ai_health_changed"
+            assert ws.receive_json()["data"] == {"task_id": 8}
+
+    def test_subscription_filters_types(self, client):
+        """Test that a connection receives only the types it subscribed to."""
+        with self.connect(client) as ws:
+            self.hello(ws)
+            ws.send_json({"type": "subscribe_notifications", "event_types": ["task_reminder"]})
+            confirmed = ws.receive_json()
+            self.publish(client, "budget_warning", level="warning")
+            self.publish(client, "task_reminder", task_id=3)
+            message = ws.receive_json()
+
+        assert (confirmed["type"], confirmed["event_types"]) == ("notification_subscription_confirmed", ["task_reminder"])
+        assert (message["event_type"], message["data"]) == ("task_reminder", {"task_id": 3})
+
+    def test_filters_are_per_connection(self, client):
+        """Test that one connection's subscription does not affect another's."""
+        with self.connect(client) as filtered, self.connect(client) as unfiltered:
+            self.hello(filtered)
+            # The second connect's connection_established also reaches the first socket
+            filtered.receive_json()
+            self.hello(unfiltered)
+            filtered.send_json({"type": "subscribe_notifications", "event_types": ["task_reminder"]})
+            filtered.receive_json()
+            self.publish(client, "budget_warning", level="warning")
+            self.publish(client, "task_reminder", task_id=4)
+
+            assert unfiltered.receive_json()["event_type"] == "budget_warning"
+            assert filtered.receive_json()["event_type"] == "task_reminder"
+
+    def test_unknown_subscription_type(self, client):
+        """Test that subscribing to an unknown type is reported and changes nothing."""
+        with self.connect(client) as ws:
+            self.hello(ws)
+            ws.send_json({"type": "subscribe_notifications", "event_types": ["task_reminder", "nope"]})
+            error = ws.receive_json()
+            self.publish(client, "budget_warning", level="warning")
+            message = ws.receive_json()
+
+        assert (error["type"], error["message"]) == ("error", "Unknown notification types: nope")
+        assert message["event_type"] == "budget_warning"
+
+
+class TestPublishers:
+    """Tests for the services that publish notifications."""
+
+    @pytest.fixture
+    def store(self):
+        """Isolated in-memory store."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        yield store
+        store.close()
+
+    def test_task_reminders_fire_once(self, store, published):
+        """Test that tasks due within the lead time are reminded once per due date."""
+        tasks = TaskService(db=store)
+        now = datetime(2026, 10, 14, 9, 0)
+
+        async def scenario():
+            soon = await tasks.create_task(TaskCreate(title="Call back", due_date=now + timedelta(minutes=10)), 1)
+            await tasks.create_task(TaskCreate(title="Later", due_date=now + timedelta(hours=2)), 1)
+            await tasks.create_task(TaskCreate(title="Overdue", due_date=now - timedelta(minutes=5)), 1)
+            await tasks.create_task(TaskCreate(title="Done", status=TaskStatus.COMPLETED,
+                                               due_date=now + timedelta(minutes=5)), 1)
+            first = await fire_task_reminders(store, 15, now=now)
+            repeat = await fire_task_reminders(store, 15, now=now)
+            await tasks.update_task(soon.id, TaskUpdate(due_date=now + timedelta(minutes=12)), 1)
+            moved = await fire_task_reminders(store, 15, now=now)
+            return soon, first, repeat, moved
+
+        soon, first, repeat, moved = asyncio.run(scenario())
+
+        assert [task["id"] for task in first] == [soon.id]
+        assert repeat == []
+        assert [task["id"] for task in moved] == [soon.id]
+        assert [(n.event_type, n.user_id, n.data["task_id"]) for n in published] == [
+            ("task_reminder", "1", soon.id)
+        ] * 2
+
+    def test_ai_health_transitions(self, published):
+        """Test that only changes of AI state are published, and a healthy start is quiet."""
+        monitor = AIHealthMonitor()
+
+        async def scenario():
+            return [
+                await monitor.record(True),
+                await monitor.record(True),
+                await monitor.record(False, "timeout"),
+                await monitor.record(False, "timeout"),
+                await monitor.record(True),
+            ]
+
+        assert asyncio.run(scenario()) == [False, False, True, False, True]
+        assert [n.data["status"] for n in published] == ["unhealthy", "healthy"]
+        assert published[0].user_id is None
+        assert published[0].data["error"] == "timeout"
+
+    @pytest.fixture
+    def worker(self, store):
+        """Worker with an isolated queue and WebSocket sends mocked."""
+        queue = JobQueue()
+        worker = EmailProcessorWorker()
+        worker.ai_service = BudgetedAIService()
+        worker.email_service = MockEmailService()
+        worker.task_service = MockTaskService()
+        worker._classification_store = ClassificationStore(db=store)
+        with patch("backend.workers.email_processor.job_queue", queue), \
+                patch("backend.workers.email_processor.websocket_manager") as manager:
+            manager.broadcast_job_status = AsyncMock()
+            manager.send_processing_complete = AsyncMock()
+            yield worker, queue
+
+    def test_pipeline_notifications(self, worker, published):
+        """Test budget warning, exhaustion, action-required and completion notifications."""
+        worker, queue = worker
+
+        async def scenario():
+            await queue.create_pipeline(["first", "second"], USER_ID, max_tokens_budget=250)
+            while (job := await queue.get_next_job()) is not None:
+                await worker._process_job(job)
+
+        asyncio.run(scenario())
+
+        assert [(n.event_type, n.data.get("level")) for n in published] == [
+            ("budget_warning", "warning"),
+            ("action_required_email", None),
+            ("budget_warning", "exhausted"),
+            ("pipeline_completed", None),
+        ]
+        assert all(n.user_id == USER_ID for n in published)
+        assert published[1].data["email_id"] == "first"
+        assert published[-1].data["status"] == "budget_exhausted"
+
+    def test_reclassified_action_email_not_renotified(self, worker, published):
+        """Test that an email already stored as action-required is not announced again."""
+        worker, queue = worker
+
+        async def scenario():
+            for _ in range(2):
+                await queue.create_pipeline(["first"], USER_ID)
+                while (job := await queue.get_next_job()) is not None:
+                    await worker._process_job(job)
+
+        asyncio.run(scenario())
+
+        assert [n.event_type for n in published].count("action_required_email") == 1
+
+
+class BudgetedAIService:
+    """Mock AI client using 100 tokens per call and flagging every email as actionable."""
+
+    def __init__(self):
+        self.tokens_used = 0
+
+    async def analyze_email(self, email_data):
+        self.tokens_used += 100
+        return {"priority": "medium"}
+
+    async def extract_tasks(self, email_data):
+        self.tokens_used += 100
+        return []
+
+    async def categorize_email(self, email_data):
+        self.tokens_used += 100
+        return {"category": "required_personal_action", "confidence": 0.9}
@@ -0,0 +1,326 @@
+"""Tests for queueing mailbox changes while Outlook is unavailable."""
+
+import asyncio
+from datetime import datetime, timedelta
+
+import pytest
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.outbox import STATUS_DEAD, STATUS_PENDING
+
+# Drains run an hour ahead of the clock, so changes queued during a test are already due
+NOW = datetime.utcnow() + timedelta(hours=1)
+
+
+class OutageProvider(MockEmailProvider):
+    """Mock mailbox that fails every call with the configured error until it recovers."""
+
+    def __init__(self):
+        super().__init__()
+        self.authenticate({})
+        self.error = None
+        self.calls = []
+
+    def _call(self, name, *args):
+        if self.error is not None:
+            raise self.error
+        self.calls.append((name, *args))
+
+    def get_folders(self):
+        self._call("get_folders")
+        return super().get_folders()
+
+    def mark_as_read(self, email_id, suppress_read_receipt=False):
+        self._call("mark_as_read", email_id)
+        return super().mark_as_read(email_id, suppress_read_receipt)
+
+    def move_email(self, email_id, destination_folder):
+        self._call("move_email", email_id, destination_folder)
+        return super().move_email(email_id, destination_folder)
+
+    def categorize_email(self, email_id, category):
+        self._call("categorize_email", email_id, category)
+        return super().categorize_email(email_id, category)
+
+    def go_down(self):
+        self.error = HTTPException(status_code=401, detail="Not connected to Outlook")
+
+    def recover(self):
+        self.error = None
+
+
+@pytest.fixture
+def store():
+    """In-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def provider():
+    """Mailbox that starts out connected."""
+    return OutageProvider()
+
+
+@pytest.fixture
+def service(provider, store):
+    """Email service over the mailbox and store."""
+    return EmailService(provider, db=store)
+
+
+def email(provider, email_id):
+    """The mock mailbox's copy of an email."""
+    return next(e for e in provider.mock_emails if e["id"] == email_id)
+
+
+def outbox(service):
+    """Every outbox entry, oldest first."""
+    return asyncio.run(service.outbox.list_entries())[0]
+
+
+def drain(service, now=NOW):
+    """Run one outbox drain."""
+    return asyncio.run(service.drain_outbox(now=now))
+
+
+class TestQueueing:
+    """Tests for queueing changes that fail while the provider is down."""
+
+    def test_outage_queues_then_recovery_applies(self, service, provider):
+        """Test that changes made during an outage are applied, in order, once Outlook is back."""
+        provider.go_down()
+
+        assert asyncio.run(service.mark_as_read("mock-email-1")) is True
+        assert asyncio.run(service.move_email("mock-email-1", "Archive")) is True
+        assert service.queued is True
+        assert [(e.operation, e.status, e.last_error) for e in outbox(service)] == [
+            ("mark_as_read", STATUS_PENDING, "Not connected to Outlook"),
+            ("move_email", STATUS_PENDING, "Not connected to Outlook"),
+        ]
+
+        assert drain(service) == 0
+        assert len(outbox(service)) == 2
+
+        provider.recover()
+        assert drain(service) == 2
+
+        assert outbox(service) == []
+        assert (email(provider, "mock-email-1")["is_read"], email(provider, "mock-email-1")["folder"]) == (
+            True, "Archive"
+        )
+        assert provider.calls == [
+            ("get_folders",), ("mark_as_read", "mock-email-1"), ("move_email", "mock-email-1", "Archive")
+        ]
+
+    def test_other_errors_raise(self, service, provider):
+        """Test that a provider error that is not an outage still raises and queues nothing."""
+        provider.error = HTTPException(status_code=404, detail="Email not found")
+
+        with pytest.raises(HTTPException):
+            asyncio.run(service.move_email("mock-email-1", "Archive"))
+
+        assert outbox(service) == []
+
+    def test_disabled(self, service, provider, monkeypatch):
+        """Test that writes fail as before with outbox_enabled off."""
+        monkeypatch.setattr(settings, "outbox_enabled", False)
+        provider.go_down()
+
+        with pytest.raises(HTTPException):
+            asyncio.run(service.categorize_email("mock-email-1", "fyi"))
+
+        assert outbox(service) == []
+
+    def test_activity_log_records_both_attempts(self, service, provider, store):
+        """Test that the failed call and its replay each get an activity row."""
+        provider.go_down()
+        asyncio.run(service.categorize_email("mock-email-1", "fyi"))
+        provider.recover()
+        drain(service)
+
+        with store.get_connection() as conn:
+            rows = conn.execute("SELECT operation, result FROM outlook_activity ORDER BY id").fetchall()
+        assert [tuple(row) for row in rows] == [("categorize_email", "error"), ("categorize_email", "success")]
+
+
+class TestIdempotency:
+    """Tests for applying each queued change once."""
+
+    def test_newer_change_replaces_queued_one(self, service, provider):
+        """Test that moving a queued email again replaces the queued move."""
+        provider.go_down()
+        asyncio.run(service.move_email("mock-email-1", "Archive"))
+        asyncio.run(service.move_email("mock-email-1", "Projects"))
+
+        entries = outbox(service)
+        assert [(e.operation, e.parameters) for e in entries] == [("move_email", {"destination_folder": "Projects"})]
+
+        provider.recover()
+        drain(service)
+
+        assert provider.calls == [("get_folders",), ("move_email", "mock-email-1", "Projects")]
+
+    def test_direct_change_drops_queued_one(self, service, provider):
+        """Test that a change applied directly is not undone by the queued change it overtook."""
+        provider.go_down()
+        asyncio.run(service.move_email("mock-email-1", "Archive"))
+        provider.recover()
+        asyncio.run(service.move_email("mock-email-1", "Projects"))
+
+        assert outbox(service) == []
+        assert drain(service) == 0
+        assert email(provider, "mock-email-1")["folder"] == "Projects"
+
+    def test_second_drain_applies_nothing(self, service, provider):
+        """Test that an applied change is removed, so the next drain does not repeat it."""
+        provider.go_down()
+        asyncio.run(service.mark_as_read("mock-email-1"))
+        provider.recover()
+
+        assert drain(service) == 1
+        assert drain(service) == 0
+        assert provider.calls.count(("mark_as_read", "mock-email-1")) == 1
+
+    def test_change_requeued_during_drain_kept(self, service, provider):
+        """Test that a change re-queued with new parameters while the old one applied is kept."""
+        provider.go_down()
+        asyncio.run(service.move_email("mock-email-1", "Archive"))
+        entry = outbox(service)[0]
+        asyncio.run(service.move_email("mock-email-1", "Projects"))
+
+        service.outbox.complete(entry)
+
+        assert [e.parameters for e in outbox(service)] == [{"destination_folder": "Projects"}]
+
+
+class TestRetries:
+    """Tests for backoff and dead-lettering."""
+
+    def test_declined_change_backs_off_then_dead_letters(self, service, provider, monkeypatch):
+        """Test that a change the provider keeps declining is retried later, then dead-lettered."""
+        monkeypatch.setattr(settings, "outbox_max_attempts", 3)
+        monkeypatch.setattr(settings, "outbox_retry_seconds", 60)
+        provider.go_down()
+        asyncio.run(service.move_email("ghost", "Archive"))
+        provider.recover()
+
+        drain(service, NOW)
+        entry = outbox(service)[0]
+        assert (entry.status, entry.attempts, entry.next_retry_at) == (STATUS_PENDING, 1, NOW + timedelta(seconds=60))
+
+        drain(service, NOW + timedelta(seconds=30))
+        assert outbox(service)[0].attempts == 1
+
+        drain(service, NOW + timedelta(seconds=60))
+        entry = outbox(service)[0]
+        assert (entry.attempts, entry.next_retry_at) == (2, NOW + timedelta(seconds=180))
+
+        drain(service, NOW + timedelta(seconds=180))
+        entry = outbox(service)[0]
+        assert (entry.status, entry.attempts, entry.last_error) == (
+            STATUS_DEAD, 3, "The mail client declined the change"
+        )
+
+        drain(service, NOW + timedelta(days=1))
+        assert outbox(service)[0].attempts == 3
+
+    def test_outage_during_drain_stops_pass(self, service, provider, monkeypatch):
+        """Test that the provider going down mid-drain fails one change and leaves the rest untouched."""
+        provider.go_down()
+        asyncio.run(service.mark_as_read("mock-email-1"))
+        asyncio.run(service.mark_as_read("mock-email-2"))
+        provider.recover()
+
+        def failing_mark(email_id, suppress_read_receipt=False):
+            provider.go_down()
+            raise ConnectionError("Outlook closed")
+
+        monkeypatch.setattr(provider, "mark_as_read", failing_mark)
+
+        assert drain(service) == 0
+        assert [(e.email_id, e.attempts, e.last_error) for e in outbox(service)] == [
+            ("mock-email-1", 1, "Outlook closed"), ("mock-email-2", 0, "Not connected to Outlook")
+        ]
+
+    def test_probe_reconnects(self, service, provider):
+        """Test that the drain reconnects a provider that lost its connection."""
+        provider.go_down()
+        asyncio.run(service.mark_as_read("mock-email-1"))
+        provider.recover()
+        provider.authenticated = False
+
+        assert drain(service) == 1
+        assert provider.authenticated is True
+
+
+class TestEndpoints:
+    """Tests for the outbox endpoints and queued responses."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client for the email endpoints over the service."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="user", email="user@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        return TestClient(app)
+
+    def test_queued_response_and_listing(self, client, provider):
+        """Test that a move during an outage is reported as queued and listed in the outbox."""
+        provider.go_down()
+
+        moved = client.post("/api/emails/mock-email-1/move", params={"destination_folder": "Archive"})
+
+        assert moved.status_code == 200
+        assert (moved.json()["success"], moved.json()["queued"]) == (True, True)
+        listing = client.get("/api/emails/outbox").json()
+        assert (listing["pending"], listing["dead"]) == (1, 0)
+        assert listing["entries"][0]["parameters"] == {"destination_folder": "Archive"}
+
+    def test_status_filter(self, client, service, provider):
+        """Test filtering the outbox by status, and refusing unknown statuses."""
+        provider.go_down()
+        asyncio.run(service.mark_as_read("mock-email-1"))
+
+        assert len(client.get("/api/emails/outbox", params={"status": "pending"}).json()["entries"]) == 1
+        assert client.get("/api/emails/outbox", params={"status": "dead"}).json()["entries"] == []
+        assert client.get("/api/emails/outbox", params={"status": "sent"}).status_code == 422
+
+    def test_delete(self, client, service, provider):
+        """Test that a deleted change is never applied, and deleting it again is a 404."""
+        provider.go_down()
+        asyncio.run(service.mark_as_read("mock-email-1"))
+        entry_id = outbox(service)[0].id
+
+        assert client.delete(f"/api/emails/outbox/{entry_id}").status_code == 200
+        assert client.delete(f"/api/emails/outbox/{entry_id}").status_code == 404
+
+        provider.recover()
+        assert drain(service) == 0
+        assert email(provider, "mock-email-1")["is_read"] is False
+
+    def test_health_reports_counts(self, service, provider, monkeypatch):
+        """Test that /health reports the pending and dead-lettered counts."""
+        from backend import main
+
+        provider.go_down()
+        asyncio.run(service.mark_as_read("mock-email-1"))
+        monkeypatch.setattr(main, "get_default_manager", lambda: service.db)
+
+        response = TestClient(main.app).get("/health")
+
+        assert response.json()["outbox"] == {"pending": 1, "dead": 0}
@@ -0,0 +1,161 @@
+"""Tests for creating colored Outlook categories for the AI categories."""
+
+from datetime import datetime
+from unittest.mock import Mock, patch
+
+import pytest
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import (
+    DEFAULT_CATEGORY_COLORS, OUTLOOK_CATEGORY_COLORS, ConfigValidationError, Settings, settings
+)
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.ai_models import EMAIL_CATEGORIES
+from backend.models.user import UserInDB
+from backend.services.email_provider import EmailProvider, MockEmailProvider
+from backend.services.email_service import EmailService
+
+
+@pytest.fixture
+def provider():
+    """Mock mailbox where the user already colored fyi green."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    provider.master_categories["fyi"] = "green"
+    return provider
+
+
+@pytest.fixture
+def client(provider):
+    """Client with auth and the email service overridden."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=1, username="painter", email="painter@example.com",
+        hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: EmailService(provider, db=db)
+    yield TestClient(app)
+    db.close()
+
+
+class TestPaletteSettings:
+    """Tests for the outlook_category_colors setting."""
+
+    def test_default_covers_every_category(self):
+        """Test that every AI category has a valid default color."""
+        assert set(DEFAULT_CATEGORY_COLORS) == set(EMAIL_CATEGORIES)
+        assert set(DEFAULT_CATEGORY_COLORS.values()) <= set(OUTLOOK_CATEGORY_COLORS)
+
+    def test_unknown_color_rejected(self):
+        """Test that colors Outlook does not have fail validation."""
+        config = Settings(outlook_category_colors={"fyi": "blue", "newsletter": "chartreuse"})
+
+        with pytest.raises(ConfigValidationError) as excinfo:
+            config.validate_config()
+
+        assert "newsletter=chartreuse" in str(excinfo.value)
+
+
+class TestEndpoint:
+    """Tests for GET /api/emails/outlook-categories."""
+
+    def test_creates_missing_categories(self, client, provider):
+        """Test that missing categories get their palette color and user colors stay."""
+        response = client.get("/api/emails/outlook-categories")
+
+        assert response.status_code == 200
+        categories = response.json()["categories"]
+        assert set(categories) == set(settings.outlook_category_colors)
+        assert categories["fyi"] == "green"
+        assert categories["required_personal_action"] == "red"
+        assert provider.master_categories["fyi"] == "green"
+
+    def test_force_colors(self, client, provider):
+        """Test that force_colors recolors the user's categories."""
+        response = client.get("/api/emails/outlook-categories?force_colors=true")
+
+        assert response.json()["categories"]["fyi"] == "blue"
+        assert provider.master_categories["fyi"] == "blue"
+
+    def test_custom_palette(self, client, monkeypatch):
+        """Test that only the configured categories are created."""
+        monkeypatch.setattr(settings, "outlook_category_colors", {"newsletter": "dark_gray"})
+
+        categories = client.get("/api/emails/outlook-categories").json()["categories"]
+
+        assert categories == {"newsletter": "dark_gray"}
+
+    def test_provider_without_categories(self, client, provider, monkeypatch):
+        """Test that providers without client-side categories return 501."""
+        monkeypatch.setattr(
+            MockEmailProvider, "ensure_categories", EmailProvider.ensure_categories
+        )
+
+        response = client.get("/api/emails/outlook-categories")
+
+        assert response.status_code == 501
+
+
+class TestCOMProvider:
+    """Tests for the COM provider's category cache."""
+
+    @pytest.fixture
+    def com_provider(self):
+        """Authenticated COM provider whose Outlook already has fyi in green."""
+        adapter_instance = Mock()
+        adapter_instance.connect = Mock(return_value=True)
+        adapter_instance.categorize_email = Mock(return_value=True)
+        adapter_instance.ensure_categories = Mock(side_effect=lambda colors, force: {
+            name: OUTLOOK_CATEGORY_COLORS.index("green") if name == "fyi" and not force else value
+            for name, value in colors.items()
+        })
+        adapter_class = Mock(return_value=adapter_instance)
+
+        with patch('backend.services.com_email_provider.OutlookEmailAdapter', adapter_class):
+            with patch('backend.services.com_email_provider.COM_AVAILABLE', True):
+                from backend.services.com_email_provider import COMEmailProvider
+                provider = COMEmailProvider()
+                provider.adapter = adapter_instance
+                provider.authenticate({})
+                return provider
+
+    def test_colors_by_name(self, com_provider):
+        """Test that palette names are sent as OlCategoryColor values and mapped back."""
+        colors = com_provider.ensure_categories({"fyi": "blue", "newsletter": "gray"})
+
+        assert colors == {"fyi": "green", "newsletter": "gray"}
+        com_provider.adapter.ensure_categories.assert_called_once_with({"fyi": 8, "newsletter": 13}, False)
+
+    def test_result_is_cached(self, com_provider):
+        """Test that Outlook is only read again for a new palette or force_colors."""
+        com_provider.ensure_categories({"fyi": "blue"})
+        com_provider.ensure_categories({"fyi": "blue"})
+        assert com_provider.adapter.ensure_categories.call_count == 1
+
+        assert com_provider.ensure_categories({"fyi": "blue"}, force_colors=True) == {"fyi": "blue"}
+        com_provider.ensure_categories({"fyi": "red"})
+        assert com_provider.adapter.ensure_categories.call_count == 3
+
+    def test_first_apply_creates_categories(self, com_provider):
+        """Test that categorizing creates the configured categories once."""
+        com_provider.categorize_email("email1", "fyi")
+        com_provider.categorize_email("email2", "newsletter")
+
+        com_provider.adapter.ensure_categories.assert_called_once()
+        assert com_provider.adapter.categorize_email.call_count == 2
+
+    def test_failure_does_not_block_apply(self, com_provider):
+        """Test that a failed category check still applies the category."""
+        com_provider.adapter.ensure_categories.side_effect = Exception("COM busy")
+
+        assert com_provider.categorize_email("email1", "fyi") is True
+        with pytest.raises(HTTPException):
+            com_provider.ensure_categories({"fyi": "blue"})
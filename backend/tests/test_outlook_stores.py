@@ -0,0 +1,205 @@
+"""Tests for choosing the Outlook store folder and item operations use."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import Mock, patch
+
+import pytest
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_email_provider, get_email_service, reset_dependencies
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.main import app as main_app, lifespan
+from backend.models.user import UserInDB
+from backend.services.email_provider import EmailProvider, MockEmailProvider
+from backend.services.email_service import EmailService
+
+STORES = [
+    {'id': 'store-primary', 'name': 'Mailbox - Test User', 'is_default': True, 'selected': True},
+    {'id': 'store-archive', 'name': 'Archive', 'is_default': False, 'selected': False},
+]
+
+
+@pytest.fixture
+def provider():
+    """Mock profile with a primary mailbox and an Archive data file."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return provider
+
+
+@pytest.fixture
+def client(provider):
+    """Client with auth and the email service overridden."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=1, username="archivist", email="archivist@example.com",
+        hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: EmailService(provider, db=db)
+    yield TestClient(app)
+    db.close()
+
+
+def com_provider_for(adapter_instance):
+    """COM provider wrapping a mock adapter."""
+    adapter_class = Mock(return_value=adapter_instance)
+    with patch('backend.services.com_email_provider.OutlookEmailAdapter', adapter_class):
+        with patch('backend.services.com_email_provider.COM_AVAILABLE', True):
+            from backend.services.com_email_provider import COMEmailProvider
+            provider = COMEmailProvider()
+            provider.adapter = adapter_instance
+            return provider
+
+
+class TestEndpoints:
+    """Tests for GET /api/emails/stores and POST /api/emails/stores/select."""
+
+    def test_list_stores(self, client):
+        """Test that every store is listed with the default one selected."""
+        response = client.get("/api/emails/stores")
+
+        assert response.status_code == 200
+        assert response.json()["stores"] == STORES
+
+    def test_select_store(self, client, provider):
+        """Test that selecting a store by display name switches to it."""
+        response = client.post("/api/emails/stores/select", json={"name": "archive"})
+
+        assert response.status_code == 200
+        assert response.json()["name"] == "Archive"
+        assert response.json()["selected"] is True
+        assert provider.selected_store_id == "store-archive"
+        stores = client.get("/api/emails/stores").json()["stores"]
+        assert [store["selected"] for store in stores] == [False, True]
+
+    def test_unknown_store(self, client, provider):
+        """Test that an unknown store is a 404 and the selection stays."""
+        response = client.post("/api/emails/stores/select", json={"name": "Archive 2019"})
+
+        assert response.status_code == 404
+        assert provider.selected_store_id == "store-primary"
+
+    def test_empty_name_rejected(self, client):
+        """Test that a blank store name fails validation."""
+        assert client.post("/api/emails/stores/select", json={"name": ""}).status_code == 422
+
+    def test_provider_without_stores(self, client, monkeypatch):
+        """Test that providers bound to one mailbox return 501."""
+        monkeypatch.setattr(MockEmailProvider, "list_stores", EmailProvider.list_stores)
+        monkeypatch.setattr(MockEmailProvider, "select_store", EmailProvider.select_store)
+
+        assert client.get("/api/emails/stores").status_code == 501
+        assert client.post("/api/emails/stores/select", json={"name": "Archive"}).status_code == 501
+
+
+class TestCOMProvider:
+    """Tests for store selection in the COM provider."""
+
+    @pytest.fixture
+    def adapter(self):
+        """Mock adapter with one folder and an Archive store."""
+        adapter_instance = Mock()
+        adapter_instance.connect = Mock(return_value=True)
+        adapter_instance.get_folders = Mock(return_value=[
+            {'id': 'inbox', 'name': 'Inbox', 'path': 'Inbox', 'type': 'mail',
+             'unread_count': 1, 'total_count': 2}
+        ])
+        adapter_instance.ensure_categories = Mock(side_effect=lambda colors, force: dict(colors))
+        adapter_instance.list_stores = Mock(return_value=STORES)
+        adapter_instance.select_store = Mock(return_value={**STORES[1], 'selected': True})
+        return adapter_instance
+
+    def test_connects_to_configured_store(self, adapter, monkeypatch):
+        """Test that outlook_store_name is passed to the adapter on connect."""
+        monkeypatch.setattr(settings, "outlook_store_name", "Archive")
+
+        assert com_provider_for(adapter).authenticate({}) is True
+        adapter.connect.assert_called_once_with(store_name="Archive")
+
+    def test_missing_configured_store(self, adapter, monkeypatch):
+        """Test that a configured store that does not exist fails with 404, naming it."""
+        monkeypatch.setattr(settings, "outlook_store_name", "Archive 2019")
+        adapter.connect.side_effect = LookupError("Outlook store 'Archive 2019' not found")
+        provider = com_provider_for(adapter)
+
+        with pytest.raises(HTTPException) as excinfo:
+            provider.authenticate({})
+
+        assert excinfo.value.status_code == 404
+        assert "Archive 2019" in excinfo.value.detail
+        assert provider.authenticated is False
+
+    def test_select_drops_caches(self, adapter):
+        """Test that switching stores re-reads folders and categories."""
+        provider = com_provider_for(adapter)
+        provider.authenticate({})
+        provider.get_folders()
+        provider.ensure_categories({"fyi": "blue"})
+
+        assert provider.select_store("Archive")["name"] == "Archive"
+
+        assert provider.folder_cache_age() is None
+        provider.get_folders()
+        provider.ensure_categories({"fyi": "blue"})
+        assert adapter.get_folders.call_count == 2
+        assert adapter.ensure_categories.call_count == 2
+
+    def test_select_unknown_store_keeps_caches(self, adapter):
+        """Test that a failed switch is a 404 and leaves the current store's caches."""
+        adapter.select_store.side_effect = LookupError("Outlook store 'Nope' not found")
+        provider = com_provider_for(adapter)
+        provider.authenticate({})
+        provider.get_folders()
+
+        with pytest.raises(HTTPException) as excinfo:
+            provider.select_store("Nope")
+
+        assert excinfo.value.status_code == 404
+        assert provider.folder_cache_age() is not None
+
+
+class TestStartup:
+    """Tests for failing fast on a missing configured store."""
+
+    @pytest.fixture(autouse=True)
+    def com_store(self, monkeypatch):
+        """Configure the COM backend with a store that does not exist."""
+        monkeypatch.setattr(settings, "use_com_backend", True)
+        monkeypatch.setattr(settings, "outlook_store_name", "Archive 2019")
+        reset_dependencies()
+        yield
+        reset_dependencies()
+
+    def missing_store(self):
+        """Patch the COM provider dependency to report the missing store."""
+        return patch(
+            'backend.core.dependencies.get_com_email_provider',
+            side_effect=HTTPException(status_code=404, detail="Outlook store 'Archive 2019' not found")
+        )
+
+    def test_missing_store_stops_startup(self):
+        """Test that the API does not start against the wrong mailbox."""
+        async def scenario():
+            async with lifespan(main_app):
+                pass
+
+        with self.missing_store():
+            with pytest.raises(RuntimeError, match="Archive 2019"):
+                asyncio.run(scenario())
+
+    def test_missing_store_does_not_fall_back(self):
+        """Test that the provider dependency does not silently use another mailbox."""
+        with self.missing_store():
+            with pytest.raises(HTTPException) as excinfo:
+                get_email_provider()
+
+        assert excinfo.value.status_code == 404
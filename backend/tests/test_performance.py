@@ -0,0 +1,163 @@
+"""Benchmarks and performance budgets for the stored-email and task hot paths.
+
+The benchmarks are marked slow and print their timings; run them with
+``npm run bench`` (or ``pytest backend/tests/test_performance.py -m slow -s``).
+The budget test runs with the rest of the suite and only fails on a gross
+regression such as a lost index, so its threshold is deliberately generous.
+"""
+
+import asyncio
+import time
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.core.search import validate_query
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.task_service import TaskService
+from backend.tests.utils import seed_emails, seed_tasks
+
+USER_ID = 1
+
+FIXTURE_SIZE = 50_000
+
+# Generous ceiling for one page of GET /api/emails over FIXTURE_SIZE stored emails
+LIST_BUDGET_SECONDS = 1.0
+
+
+@pytest.fixture(scope="module")
+def store():
+    """In-memory store seeded once with FIXTURE_SIZE emails and tasks."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_ids = seed_emails(db, FIXTURE_SIZE, USER_ID)
+    seed_tasks(db, FIXTURE_SIZE, USER_ID)
+    yield db, email_ids
+    db.close()
+
+
+@pytest.fixture
+def service(store):
+    """Email service over the seeded store."""
+    db, _ = store
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return EmailService(provider, db=db)
+
+
+def best_of(make_call, rounds=5):
+    """Fastest of several runs of an awaitable factory, with its last result."""
+    async def measure():
+        timings = []
+        result = None
+        for _ in range(rounds):
+            started = time.perf_counter()
+            result = await make_call()
+            timings.append(time.perf_counter() - started)
+        return min(timings), result
+
+    return asyncio.run(measure())
+
+
+def report(name, seconds):
+    """Print a benchmark timing."""
+    print(f"{name}: {seconds * 1000:.1f} ms")
+
+
+class TestBenchmarks:
+    """Timings of the hot paths over FIXTURE_SIZE rows."""
+
+    @pytest.mark.slow
+    def test_get_emails_category_50k(self, service):
+        """Benchmark a category page of stored emails."""
+        seconds, (emails, total) = best_of(
+            lambda: service.search_stored_emails(USER_ID, {"category": "fyi"}, limit=50)
+        )
+
+        report("search_stored_emails(category=fyi)", seconds)
+        assert len(emails) == 50
+        assert total == FIXTURE_SIZE // 6 + (FIXTURE_SIZE % 6 > 2)
+
+    @pytest.mark.slow
+    def test_search_emails(self, service):
+        """Benchmark free-text search, which cannot use an index."""
+        query = validate_query("item 4999")
+        seconds, (emails, total) = best_of(
+            lambda: service.search_stored_emails(USER_ID, {}, limit=50, query=query)
+        )
+
+        report("search_stored_emails(q='item 4999')", seconds)
+        assert total == 11  # item 4999 and items 49990-49999
+        assert all("search_meta" in email for email in emails)
+
+    @pytest.mark.slow
+    def test_get_task_stats(self, store):
+        """Benchmark the due-task counters and a filtered task page."""
+        db, _ = store
+        tasks = TaskService(db=db)
+        now = datetime.now()
+
+        counts_seconds, counts = best_of(lambda: tasks.get_due_counts(USER_ID, now=now))
+        page_seconds, page = best_of(lambda: tasks.get_tasks_paginated(USER_ID, status="pending"))
+
+        report("get_due_counts", counts_seconds)
+        report("get_tasks_paginated(status=pending)", page_seconds)
+        assert counts["overdue"] > 0
+        assert page.total_count == FIXTURE_SIZE // 4
+
+    @pytest.mark.slow
+    def test_enrich_emails(self, store, service):
+        """Benchmark looking up stored data for a page of emails, in a single query."""
+        db, email_ids = store
+        page = email_ids[::500]
+        statements = []
+
+        with db.get_connection() as conn:
+            conn.set_trace_callback(statements.append)
+            try:
+                seconds, stored = best_of(lambda: service.get_stored_emails(page, USER_ID), rounds=1)
+            finally:
+                conn.set_trace_callback(None)
+
+        report(f"get_stored_emails({len(page)} ids)", seconds)
+        assert set(stored) == set(page)
+        assert len([sql for sql in statements if sql.lstrip().upper().startswith("SELECT")]) == 1
+
+
+class TestPerformanceBudget:
+    """Regression guard for GET /api/emails over a large store."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client over the seeded store."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="heavy", email="heavy@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: None
+        return TestClient(app)
+
+    def test_category_page_within_budget(self, client):
+        """Test that a category page over 50k stored emails stays under LIST_BUDGET_SECONDS."""
+        client.get("/api/emails?category=fyi&limit=50")
+        timings = []
+        for _ in range(3):
+            started = time.perf_counter()
+            response = client.get("/api/emails?category=fyi&limit=50&offset=5000")
+            timings.append(time.perf_counter() - started)
+
+        assert response.status_code == 200
+        assert len(response.json()["emails"]) == 50
+        assert min(timings) < LIST_BUDGET_SECONDS, f"GET /api/emails took {min(timings):.2f}s"
@@ -0,0 +1,157 @@
+"""Tests for plain-text email previews."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.core.preview import PREVIEW_LENGTH, email_preview, truncate_text
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+
+class TestEmailPreview:
+    """Tests for computing a preview from a body."""
+
+    def test_html_and_entities(self):
+        """Test that tags are dropped, entities decoded, and whitespace collapsed."""
+        body = "<div><p>Fish&nbsp;&amp;&nbsp;chips</p>\n<p>at   &lt;noon&gt;?</p><style>p {}</style></div>"
+
+        assert email_preview(body) == "Fish & chips at <noon>?"
+
+    @pytest.mark.parametrize("signature", [
+        "--\nJane Doe\nDirector",
+        "-- \nJane",
+        "Sent from my iPhone",
+        "Get Outlook for Android",
+    ])
+    def test_signatures_are_dropped(self, signature):
+        """Test that text from the signature delimiter on is left out."""
+        assert email_preview(f"See you at 3.\n\n{signature}") == "See you at 3."
+
+    @pytest.mark.parametrize("quote", [
+        "On Mon, Jan 1, 2024 at 9:00 AM Bob <bob@example.com> wrote:\n> Lunch?",
+        "-----Original Message-----\nFrom: Bob",
+        "________________________________\nFrom: Bob <bob@example.com> Sent: Monday",
+        "> Lunch?\n> Bob",
+    ])
+    def test_quoted_replies_are_dropped(self, quote):
+        """Test that quoted messages are left out of plain-text replies."""
+        assert email_preview(f"Yes, noon works.\n{quote}") == "Yes, noon works."
+
+    def test_html_blockquote_is_dropped(self):
+        """Test that quoted HTML is left out but text after it is kept."""
+        body = "<p>Sounds good.</p><blockquote><p>Lunch?</p></blockquote><p>Thanks</p>"
+
+        assert email_preview(body) == "Sounds good. Thanks"
+
+    def test_length(self):
+        """Test that previews keep the first PREVIEW_LENGTH characters."""
+        assert email_preview("word " * 100) == " ".join(["word"] * (PREVIEW_LENGTH // 5))
+        assert email_preview(None) == ""
+
+    def test_multibyte_truncation(self):
+        """Test that cuts fall between characters, keeping accents with their letters."""
+        assert truncate_text("日本語のテキスト", 3) == "日本語"
+        assert truncate_text("🎉🎉🎉", 2) == "🎉🎉"
+        # "e" followed by a combining acute accent is one visible character
+        assert truncate_text("cafés", 4) == "caf"
+        assert truncate_text("cafés", 5) == "café"
+
+    def test_multibyte_preview_is_valid_utf8(self):
+        """Test that a cut preview of multi-byte text encodes cleanly."""
+        preview = email_preview("ü" * 200)
+
+        assert preview == "ü" * PREVIEW_LENGTH
+        assert preview.encode("utf-8").decode("utf-8") == preview
+
+
+class TestStoredPreviews:
+    """Tests for storing and backfilling previews."""
+
+    @pytest.fixture
+    def service(self):
+        """Email service over an authenticated mock provider and an in-memory store."""
+        provider = MockEmailProvider()
+        provider.authenticate({})
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        yield EmailService(provider, db=store)
+        store.close()
+
+    def preview(self, service, email_id):
+        """Read an email's stored preview."""
+        with service.db.get_connection() as conn:
+            return conn.execute("SELECT preview FROM emails WHERE id = ?", (email_id,)).fetchone()["preview"]
+
+    @pytest.mark.asyncio
+    async def test_saved_with_classification(self, service):
+        """Test that saving a classified email stores its preview."""
+        email = {"id": "e1", "subject": "Hi", "sender": "a@example.com",
+                 "body": "<p>Hello there</p><p>--</p><p>Ann</p>"}
+
+        await service.save_classification(email, EmailClassification(category="fyi", confidence=0.9), USER_ID)
+
+        assert self.preview(service, "e1") == "Hello there"
+
+    @pytest.mark.asyncio
+    async def test_saved_when_persisted(self, service):
+        """Test that emails persisted from the provider get a preview."""
+        await service.get_email_by_id("mock-email-1", user_id=USER_ID, source="auto", persist=True)
+
+        assert self.preview(service, "mock-email-1") == email_preview(service.provider.mock_emails[0]["body"])
+
+    @pytest.mark.asyncio
+    async def test_backfill(self, service):
+        """Test that rows without a preview are filled once, from the raw body when kept."""
+        with service.db.get_connection() as conn:
+            conn.executemany(
+                "INSERT INTO emails (id, subject, sender, content, raw_content, user_id) VALUES (?, ?, ?, ?, ?, ?)",
+                [
+                    ("old-html", "S", "s@example.com", "clean", "<p>Raw &amp; original</p>", USER_ID),
+                    ("old-text", "S", "s@example.com", "Plain\nSent from my iPhone", None, USER_ID),
+                    ("empty", "S", "s@example.com", None, None, USER_ID),
+                    ("other-user", "S", "s@example.com", "Theirs", None, 2),
+                ]
+            )
+            conn.commit()
+
+        assert await service.backfill_previews(USER_ID) == 3
+        assert await service.backfill_previews(USER_ID) == 0
+
+        assert self.preview(service, "old-html") == "Raw & original"
+        assert self.preview(service, "old-text") == "Plain"
+        assert self.preview(service, "empty") == ""
+        assert self.preview(service, "other-user") is None
+
+    def test_list_and_backfill_endpoints(self, service):
+        """Test that stored list responses carry the preview and the backfill endpoint reports counts."""
+        with service.db.get_connection() as conn:
+            conn.execute(
+                "INSERT INTO emails (id, subject, sender, content, category, user_id) VALUES (?, ?, ?, ?, ?, ?)",
+                ("old", "Budget", "s@example.com", "Numbers attached", "fyi", USER_ID)
+            )
+            conn.commit()
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="reader", email="reader@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: None
+        client = TestClient(app)
+
+        assert client.post("/api/emails/previews/backfill").json() == {"updated": 1}
+        emails = client.get("/api/emails", params={"category": "fyi", "fields": "preview"}).json()["emails"]
+        assert emails == [{"id": "old", "preview": "Numbers attached"}]
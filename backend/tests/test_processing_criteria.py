@@ -0,0 +1,127 @@
+"""Tests for starting the processing pipeline from criteria instead of email IDs."""
+
+import asyncio
+from datetime import datetime, timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.processing import get_known_folders, router
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.services.classification_store import ClassificationStore, get_classification_store
+from backend.services.folder_profile_service import FolderProfileService, get_folder_profile_service
+from backend.services.job_queue import job_queue
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store with Inbox and Archive emails of varied age, read state, and category."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    now = datetime.now()
+    with db.get_connection() as conn:
+        conn.executemany(
+            "INSERT INTO emails (id, subject, sender, user_id, folder, received_date, is_read, category) "
+            "VALUES (?, 'Hi', 'a@example.com', 'user_1', ?, ?, ?, ?)",
+            [
+                ("new_unread", "Inbox", now - timedelta(hours=1), 0, None),
+                ("new_read", "Inbox", now - timedelta(hours=2), 1, None),
+                ("new_fyi", "Inbox", now - timedelta(hours=3), 0, "fyi"),
+                ("old_unread", "Inbox", now - timedelta(days=30), 0, None),
+                ("archived", "Archive", now - timedelta(hours=1), 0, None),
+            ]
+        )
+        conn.commit()
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def client(store):
+    """Client for the processing endpoints over the store."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: {"user_id": "user_1"}
+    app.dependency_overrides[get_folder_profile_service] = lambda: FolderProfileService(db=store)
+    app.dependency_overrides[get_classification_store] = lambda: ClassificationStore(db=store)
+    app.dependency_overrides[get_known_folders] = lambda: None
+    yield TestClient(app)
+    job_queue._pipelines.clear()
+
+
+def start(client, **body):
+    """Start a run and return the response JSON."""
+    return client.post("/api/processing/start", json=body).json()
+
+
+class TestCriteria:
+    """Tests for resolving criteria to emails."""
+
+    def test_criteria_resolve_newest_first(self, client):
+        """Test that criteria select matching stored emails and the run keeps what they resolved to."""
+        data = start(client, criteria={"unread_only": True, "since_days": 7, "categories": ["unclassified"]})
+
+        assert (data["status"], data["email_count"]) == ("started", 1)
+        assert (data["matched_count"], data["capped_count"]) == (1, 0)
+        status = client.get(f"/api/processing/{data['pipeline_id']}/status").json()
+        assert status["resolved_email_ids"] == ["new_unread"]
+        assert status["criteria"]["categories"] == ["unclassified"]
+        assert status["folder"] == "Inbox"
+
+    def test_limit_caps_the_matches(self, client):
+        """Test that the status reports emails matched and emails left out by the limit."""
+        data = start(client, criteria={"categories": ["unclassified", "fyi"], "limit": 2})
+
+        status = client.get(f"/api/processing/{data['pipeline_id']}/status").json()
+        assert status["resolved_email_ids"] == ["new_unread", "new_read"]
+        assert (status["matched_count"], status["capped_count"], status["email_count"]) == (4, 2, 2)
+        pipeline = asyncio.run(job_queue.get_pipeline(data["pipeline_id"]))
+        assert pipeline.email_ids == ["new_unread", "new_read"]
+
+    def test_criteria_folder_is_used(self, client):
+        """Test that criteria.folder picks the folder emails come from."""
+        data = start(client, criteria={"folder": "Archive"})
+
+        assert data["email_count"] == 1
+        status = client.get(f"/api/processing/{data['pipeline_id']}/status").json()
+        assert (status["folder"], status["resolved_email_ids"]) == ("Archive", ["archived"])
+
+    def test_no_matches_starts_nothing(self, client):
+        """Test that criteria matching nothing create no pipeline."""
+        data = start(client, criteria={"folder": "Archive", "unread_only": True, "categories": ["fyi"]})
+
+        assert (data["status"], data["pipeline_id"], data["matched_count"]) == ("skipped", None, 0)
+        assert data["message"] == "No emails match the criteria"
+
+    def test_unknown_category(self, client):
+        """Test that an unknown category is a validation error."""
+        response = client.post("/api/processing/start", json={"criteria": {"categories": ["nonsense"]}})
+
+        assert response.status_code == 422
+        assert "Unknown categories: nonsense" in response.json()["error"]["message"]
+
+
+class TestInputForms:
+    """Tests for choosing between email IDs and criteria."""
+
+    def test_email_ids_still_work(self, client):
+        """Test that a run from email IDs reports no criteria counts."""
+        data = start(client, email_ids=["new_unread", "old_unread"])
+
+        assert data["email_count"] == 2
+        assert "matched_count" not in data
+        status = client.get(f"/api/processing/{data['pipeline_id']}/status").json()
+        assert (status["criteria"], status["resolved_email_ids"], status["capped_count"]) == (None, [], 0)
+
+    @pytest.mark.parametrize("body", [
+        {},
+        {"email_ids": ["new_unread"], "criteria": {"unread_only": True}},
+    ])
+    def test_exactly_one_form_required(self, client, body):
+        """Test that neither or both of email_ids and criteria is a validation error."""
+        response = client.post("/api/processing/start", json=body)
+
+        assert response.status_code == 422
@@ -0,0 +1,198 @@
+"""Tests for processing run diffs."""
+
+import asyncio
+from unittest.mock import AsyncMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.processing import router
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.services.classification_store import ClassificationStore
+from backend.services.job_queue import JobQueue, RunDiff
+from backend.workers.email_processor import EmailProcessorWorker, MockEmailService, MockTaskService
+
+USER_ID = "user_1"
+
+# Category and review flag the mock AI client returns for each email
+AI_RESULTS = {
+    "same": ("newsletter", False),
+    "changed": ("required_personal_action", True),
+    "brand_new": ("fyi", False),
+    "reviewed": ("team_action", True),
+}
+
+
+class FakeAIService:
+    """Mock AI client returning fixed categories."""
+
+    async def analyze_email(self, email_data):
+        return {"priority": "medium"}
+
+    async def extract_tasks(self, email_data):
+        if email_data["id"] == "changed":
+            return [{"title": "Reply to boss"}, {"title": "Book room"}]
+        return []
+
+    async def categorize_email(self, email_data):
+        category, needs_review = AI_RESULTS[email_data["id"]]
+        return {"category": category, "confidence": 0.6, "needs_review": needs_review}
+
+
+@pytest.fixture
+def store():
+    """In-memory store seeded with earlier classifications."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        conn.executemany(
+            "INSERT INTO emails (id, subject, sender, category, needs_review, user_id) VALUES (?, ?, ?, ?, ?, ?)",
+            [
+                ("same", "Weekly", "news@list.com", "newsletter", 0, USER_ID),
+                ("changed", "Budget", "boss@corp.com", "fyi", 0, USER_ID),
+                ("reviewed", "Launch", "team@corp.com", "team_action", 1, USER_ID),
+            ]
+        )
+        conn.commit()
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def queue():
+    """Isolated job queue patched in for the worker and API."""
+    job_queue = JobQueue()
+    with patch("backend.workers.email_processor.job_queue", job_queue), \
+            patch("backend.api.processing.job_queue", job_queue):
+        yield job_queue
+
+
+@pytest.fixture
+def websocket():
+    """WebSocket manager with sends recorded."""
+    with patch("backend.workers.email_processor.websocket_manager") as manager:
+        manager.broadcast_job_status = AsyncMock()
+        manager.send_processing_complete = AsyncMock()
+        yield manager
+
+
+@pytest.fixture
+def worker(store):
+    """Worker with mock services over the in-memory store."""
+    worker = EmailProcessorWorker()
+    worker.ai_service = FakeAIService()
+    worker.email_service = MockEmailService()
+    worker.task_service = MockTaskService()
+    worker._classification_store = ClassificationStore(db=store)
+    return worker
+
+
+async def run_pipeline(queue, worker):
+    """Run every queued job, as the processing loop would."""
+    pipeline_id = await queue.create_pipeline(list(AI_RESULTS), USER_ID)
+    while (job := await queue.get_next_job()) is not None:
+        await worker._process_job(job)
+    return pipeline_id
+
+
+def stored(store, email_id):
+    """Read an email's stored category and review flag."""
+    with store.get_connection() as conn:
+        row = conn.execute("SELECT category, needs_review FROM emails WHERE id = ?", (email_id,)).fetchone()
+    return row["category"], row["needs_review"]
+
+
+class TestRunDiff:
+    """Tests for RunDiff bookkeeping."""
+
+    def test_record_classification(self):
+        """Test each kind of classification change."""
+        diff = RunDiff()
+
+        diff.record_classification("a", None, "fyi", False)
+        diff.record_classification("b", {"category": "fyi", "needs_review": False}, "fyi", False)
+        diff.record_classification("c", {"category": "fyi", "needs_review": False}, "newsletter", True)
+        diff.record_classification("d", {"category": "fyi", "needs_review": True}, "fyi", True)
+        diff.record_tasks("c", 0)
+
+        assert diff.newly_classified == [{"email_id": "a", "category": "fyi"}]
+        assert diff.category_changed == [{"email_id": "c", "previous_category": "fyi", "category": "newsletter"}]
+        assert diff.became_needs_review == [{"email_id": "c", "category": "newsletter"}]
+        assert diff.summary() == {
+            "newly_classified": 1, "category_changed": 1, "tasks_created": 0, "became_needs_review": 1
+        }
+
+
+class TestPipelineDiff:
+    """Tests for diffs computed during a pipeline run."""
+
+    @pytest.mark.asyncio
+    async def test_diff_against_prior_classifications(self, store, queue, websocket, worker):
+        """Test that the run diff compares with the values it overwrote."""
+        pipeline_id = await run_pipeline(queue, worker)
+
+        diff = (await queue.get_pipeline(pipeline_id)).diff
+        assert diff.newly_classified == [{"email_id": "brand_new", "category": "fyi"}]
+        assert diff.category_changed == [
+            {"email_id": "changed", "previous_category": "fyi", "category": "required_personal_action"}
+        ]
+        assert diff.tasks_created == [{"email_id": "changed", "count": 2}]
+        # "reviewed" already needed review before the run
+        assert diff.became_needs_review == [{"email_id": "changed", "category": "required_personal_action"}]
+        assert stored(store, "changed") == ("required_personal_action", 1)
+        assert stored(store, "brand_new") == ("fyi", 0)
+
+    @pytest.mark.asyncio
+    async def test_completion_event_has_summary(self, queue, websocket, worker):
+        """Test that one completion event is sent with the diff counts."""
+        pipeline_id = await run_pipeline(queue, worker)
+
+        websocket.send_processing_complete.assert_awaited_once_with(pipeline_id, {
+            "status": "completed",
+            "diff": {"newly_classified": 1, "category_changed": 1, "tasks_created": 2, "became_needs_review": 1},
+            "tokens_consumed": 0,
+            "max_tokens_budget": 0
+        })
+
+    @pytest.mark.asyncio
+    async def test_rerun_has_no_changes(self, queue, websocket, worker):
+        """Test that a second identical run reports nothing new."""
+        await run_pipeline(queue, worker)
+
+        diff = (await queue.get_pipeline(await run_pipeline(queue, worker))).diff
+
+        assert diff.newly_classified == []
+        assert diff.category_changed == []
+        assert diff.became_needs_review == []
+
+
+class TestProcessingDiffAPI:
+    """Tests for GET /processing/{id}/diff."""
+
+    @pytest.fixture
+    def client(self):
+        """Client with auth overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: {"user_id": USER_ID}
+        return TestClient(app)
+
+    def test_get_diff(self, client, queue, websocket, worker):
+        """Test the endpoint returns the stored diff."""
+        pipeline_id = asyncio.run(run_pipeline(queue, worker))
+
+        data = client.get(f"/api/processing/{pipeline_id}/diff").json()
+
+        assert data["status"] == "completed"
+        assert data["summary"]["category_changed"] == 1
+        assert data["category_changed"][0]["email_id"] == "changed"
+
+    def test_get_diff_access(self, client, queue):
+        """Test missing and foreign pipelines."""
+        assert client.get("/api/processing/pipeline_missing/diff").status_code == 404
+
+        other = asyncio.run(queue.create_pipeline(["x"], "someone_else"))
+        assert client.get(f"/api/processing/{other}/diff").status_code == 403
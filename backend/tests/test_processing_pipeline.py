@@ -7,6 +7,7 @@ from fastapi.testclient import TestClient
 from fastapi import FastAPI
 
 from backend.api.processing import router
+from backend.core.errors import register_error_handlers
 from backend.services.job_queue import job_queue, JobStatus, JobType, JobPriority
 from backend.services.websocket_manager import websocket_manager
 from backend.workers.email_processor import email_processor_worker
@@ -16,6 +17,7 @@ from backend.workers.email_processor import email_processor_worker
 def app():
     """Create FastAPI app for testing."""
     app = FastAPI()
+    register_error_handlers(app)
     app.include_router(router, prefix="/api")
     return app
 
@@ -66,8 +68,10 @@ class TestProcessingAPI:
             json={"email_ids": []}
         )
         
-        assert response.status_code == 400
-        assert "No email IDs provided" in response.json()["detail"]
+        assert response.status_code == 422
+        error = response.json()["error"]
+        assert error["code"] == "validation_error"
+        assert [(field["field"], field["type"]) for field in error["fields"]] == [("email_ids", "too_short")]
     
     def test_start_processing_too_many_emails(self, client, auth_headers):
         """Test processing start with too many emails."""
@@ -78,8 +82,10 @@ class TestProcessingAPI:
             json={"email_ids": email_ids}
         )
         
-        assert response.status_code == 400
-        assert "Too many emails" in response.json()["detail"]
+        assert response.status_code == 422
+        assert [(field["field"], field["type"]) for field in response.json()["error"]["fields"]] == [
+            ("email_ids", "too_long")
+        ]
     
     @pytest.mark.asyncio
     async def test_get_processing_status_success(self, client, auth_headers):
@@ -104,7 +110,8 @@ class TestProcessingAPI:
         response = client.get("/api/processing/nonexistent_pipeline/status")
         
         assert response.status_code == 404
-        assert "Pipeline not found" in response.json()["detail"]
+        assert response.json()["error"]["code"] == "not_found"
+        assert "Pipeline not found" in response.json()["error"]["message"]
     
     @pytest.mark.asyncio
     async def test_get_pipeline_jobs_success(self, client, auth_headers):
@@ -150,7 +157,8 @@ class TestProcessingAPI:
         response = client.post("/api/processing/nonexistent_pipeline/cancel")
         
         assert response.status_code == 404
-        assert "Pipeline not found" in response.json()["detail"]
+        assert response.json()["error"]["code"] == "not_found"
+        assert "Pipeline not found" in response.json()["error"]["message"]
     
     def test_get_processing_stats(self, client, auth_headers):
         """Test getting processing statistics."""
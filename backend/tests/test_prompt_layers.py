@@ -0,0 +1,253 @@
+"""Tests for layering user prompt templates over the bundled ones."""
+
+import sys
+from datetime import datetime
+from types import ModuleType, SimpleNamespace
+from unittest.mock import MagicMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.ai import router
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service
+from backend.core.errors import register_error_handlers
+from backend.core.prompts import BUNDLED_LAYER, USER_LAYER, PromptLibrary, load_layered
+from backend.models.user import UserInDB
+from backend.services import classification_store
+from backend.services.ai_service import AIProcessor, AIService
+
+
+def write_template(directory, name, description, version="1.0", body="Prompt text"):
+    """Write a .prompty file with a description and version."""
+    directory.mkdir(parents=True, exist_ok=True)
+    path = directory / name
+    path.write_text(f"---\ndescription: {description}\nversion: {version}\n---\n{body}\n", encoding="utf-8")
+    return path
+
+
+def write_tuned_template(directory, name, temperature=0.7, max_tokens=400):
+    """Write a .prompty file whose frontmatter sets model parameters."""
+    directory.mkdir(parents=True, exist_ok=True)
+    path = directory / name
+    path.write_text(
+        f"---\ndescription: Tuned\nversion: 1.0\nmodel:\n  api: chat\n  parameters:\n"
+        f"    temperature: {temperature}\n    max_tokens: {max_tokens}\n---\nPrompt text\n",
+        encoding="utf-8"
+    )
+    return path
+
+
+@pytest.fixture
+def bundled(tmp_path):
+    """Bundled layer with a classifier and a summarizer."""
+    directory = tmp_path / "bundled"
+    write_template(directory, "classifier.prompty", "Bundled classifier", version="2.0")
+    write_template(directory, "summary.prompty", "Bundled summary")
+    return directory
+
+
+@pytest.fixture
+def user(tmp_path):
+    """Empty user layer."""
+    directory = tmp_path / "user"
+    directory.mkdir()
+    return directory
+
+
+class TestLayering:
+    """Tests for override precedence."""
+
+    def test_user_template_overrides_same_name(self, bundled, user):
+        """Test that a user template replaces the bundled one and a new name is added."""
+        write_template(user, "classifier.prompty", "My classifier", version="2.0-mine")
+        write_template(user, "triage.prompty", "My triage")
+
+        templates, errors = load_layered(bundled, user)
+
+        assert list(templates) == ["classifier.prompty", "summary.prompty", "triage.prompty"]
+        assert templates["classifier.prompty"].layer == USER_LAYER
+        assert templates["classifier.prompty"].path == user / "classifier.prompty"
+        assert templates["classifier.prompty"].description == "My classifier"
+        assert templates["summary.prompty"].layer == BUNDLED_LAYER
+        assert templates["triage.prompty"].layer == USER_LAYER
+        assert errors == {}
+
+    def test_unparseable_user_copy_falls_back(self, bundled, user):
+        """Test that a user copy that fails to parse leaves the bundled template active."""
+        (user / "classifier.prompty").write_text("no frontmatter here", encoding="utf-8")
+        (user / "summary.prompty").write_text("---\ndescription: [unclosed\n---\nPrompt", encoding="utf-8")
+        (user / "empty.prompty").write_text("---\ndescription: Nothing to say\n---\n", encoding="utf-8")
+
+        templates, errors = load_layered(bundled, user)
+
+        assert templates["classifier.prompty"].layer == BUNDLED_LAYER
+        assert templates["summary.prompty"].layer == BUNDLED_LAYER
+        assert "empty.prompty" not in templates
+        assert set(errors) == {"classifier.prompty", "summary.prompty", "empty.prompty"}
+
+    def test_missing_user_directory_uses_bundled(self, bundled, tmp_path):
+        """Test that an unset or missing user directory leaves only the bundled layer."""
+        for user_dir in (None, tmp_path / "missing"):
+            templates, errors = load_layered(bundled, user_dir)
+            assert {template.layer for template in templates.values()} == {BUNDLED_LAYER}
+            assert errors == {}
+
+    def test_resolution_and_version(self, bundled, user):
+        """Test that template paths and prompt versions come from the active layer."""
+        write_template(user, "classifier.prompty", "My classifier", version="2.0-mine")
+        library = PromptLibrary(bundled, user)
+
+        assert library.path("classifier.prompty") == user / "classifier.prompty"
+        assert library.path("summary.prompty") == bundled / "summary.prompty"
+        # Names without an active template still resolve into the bundled directory
+        assert library.path("unknown.prompty") == bundled / "unknown.prompty"
+        with patch.object(classification_store, "prompt_library", library):
+            assert classification_store.prompt_version("classifier.prompty") == "classifier@2.0-mine"
+            assert classification_store.prompt_version("summary.prompty") == "summary@1.0"
+
+    def test_user_directory_from_settings(self, bundled, user, monkeypatch):
+        """Test that the user layer defaults to settings.user_prompts_dir."""
+        write_template(user, "summary.prompty", "My summary")
+        monkeypatch.setattr(settings, "user_prompts_dir", str(user))
+
+        assert PromptLibrary(bundled).get("summary.prompty").layer == USER_LAYER
+
+
+class TestReload:
+    """Tests for re-reading both layers."""
+
+    def test_reload_reevaluates_both_layers(self, bundled, user):
+        """Test that templates are kept until reload, which picks up changes to either layer."""
+        library = PromptLibrary(bundled, user)
+        assert library.get("classifier.prompty").layer == BUNDLED_LAYER
+
+        override = write_template(user, "classifier.prompty", "My classifier")
+        write_template(bundled, "digest.prompty", "Bundled digest")
+        assert library.get("classifier.prompty").layer == BUNDLED_LAYER
+
+        library.reload()
+        assert library.get("classifier.prompty").layer == USER_LAYER
+        assert library.get("digest.prompty").layer == BUNDLED_LAYER
+
+        override.write_text("broken", encoding="utf-8")
+        library.reload()
+        assert library.get("classifier.prompty").layer == BUNDLED_LAYER
+        assert "classifier.prompty" in library.errors()
+
+    def test_templates_endpoints_report_layers(self, bundled, user):
+        """Test that listing reports each template's layer and reload picks up a new override."""
+        library = PromptLibrary(bundled, user)
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="reader", email="reader@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_ai_service] = lambda: AIService()
+        client = TestClient(app)
+
+        with patch("backend.api.ai.prompt_library", library), \
+                patch("backend.services.ai_service.prompt_library", library):
+            listing = client.get("/api/ai/templates").json()
+            assert listing["layers"] == {"classifier.prompty": "bundled", "summary.prompty": "bundled"}
+
+            write_template(user, "summary.prompty", "My summary")
+            (user / "classifier.prompty").write_text("broken", encoding="utf-8")
+            reloaded = client.post("/api/ai/templates/reload").json()
+
+        assert reloaded["layers"] == {"classifier.prompty": "bundled", "summary.prompty": "user"}
+        assert reloaded["descriptions"]["summary.prompty"] == "My summary"
+        assert list(reloaded["errors"]) == ["classifier.prompty"]
+
+
+class TestModelParameters:
+    """Tests for overriding template model parameters with settings.ai_parameters."""
+
+    @pytest.fixture
+    def library(self, bundled, user, monkeypatch):
+        """Library whose classifier sets temperature 0.7 and max_tokens 400, overridden to temperature 0.1."""
+        write_tuned_template(bundled, "classifier.prompty")
+        monkeypatch.setattr(settings, "ai_parameters", {"classifier": {"temperature": 0.1, "max_tokens": None}})
+        return PromptLibrary(bundled, user)
+
+    @pytest.fixture
+    def processor(self, library, bundled):
+        """AIProcessor resolving templates and parameters through the library."""
+        if AIProcessor is None:
+            pytest.skip("AI dependencies not available")
+        processor = AIProcessor.__new__(AIProcessor)
+        processor.prompts_dir = str(bundled)
+        processor.trace_hooks = []
+        processor.raise_auth_errors = False
+        processor.prompt_resolver = library.path
+        processor.parameter_resolver = library.parameters
+        return processor
+
+    def test_override_wins_over_template(self, library):
+        """Test that the override replaces the template's value and unset fields keep the template's."""
+        assert library.get("classifier.prompty").parameters == {"temperature": 0.7, "max_tokens": 400}
+        assert library.parameters("classifier.prompty") == {"temperature": 0.1, "max_tokens": 400}
+        assert library.parameters("summary.prompty") == {}
+
+    def test_prompty_client_runs_with_override(self, processor):
+        """Test that the prompty client applies the override after loading the template's defaults."""
+        loaded = SimpleNamespace(model=SimpleNamespace(
+            parameters={"temperature": 0.7, "max_tokens": 400}, configuration={"api_key": "x"}
+        ))
+        prompty = ModuleType("prompty")
+        prompty.load = MagicMock(return_value=loaded)
+        prompty.run = MagicMock(side_effect=lambda p, inputs: dict(p.model.parameters))
+        azure_config = MagicMock(use_azure_credential=MagicMock(return_value=False))
+        modules = {
+            "promptflow": None, "promptflow.core": None,  # Not installed: the prompty client runs
+            "prompty": prompty, "prompty.azure": ModuleType("prompty.azure"),
+        }
+
+        with patch.dict(sys.modules, modules), patch("ai_completion.get_azure_config", return_value=azure_config):
+            ran_with = processor.execute_prompty("classifier.prompty", {})
+
+        assert ran_with == {"temperature": 0.1, "max_tokens": 400}
+
+    def test_promptflow_client_runs_with_override(self, processor, bundled):
+        """Test that the promptflow client is loaded with the effective parameters."""
+        core = ModuleType("promptflow.core")
+        core.Prompty = MagicMock()
+        core.Prompty.load.return_value = MagicMock(return_value="fyi")
+
+        with patch.dict(sys.modules, {"promptflow": ModuleType("promptflow"), "promptflow.core": core}), \
+                patch("ai_completion.get_azure_config"):
+            processor.execute_prompty("classifier.prompty", {})
+
+        path, = core.Prompty.load.call_args.args
+        assert path == str(bundled / "classifier.prompty")
+        assert core.Prompty.load.call_args.kwargs["model"]["parameters"] == {"temperature": 0.1, "max_tokens": 400}
+
+    def test_trace_hooks_see_parameters(self, processor):
+        """Test that trace hooks are told the parameters a call ran with."""
+        events = []
+        processor._execute_prompty = lambda prompty_file, inputs, event: "fyi"
+        processor.add_trace_hook(events.append)
+
+        processor.execute_prompty("classifier.prompty", {})
+
+        assert events[0]["parameters"] == {"temperature": 0.1, "max_tokens": 400}
+
+    def test_templates_endpoint_reports_parameters(self, library):
+        """Test that the listing shows the parameters each template runs with."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="reader", email="reader@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_ai_service] = lambda: AIService()
+
+        with patch("backend.services.ai_service.prompt_library", library):
+            listing = TestClient(app).get("/api/ai/templates").json()
+
+        assert listing["parameters"] == {
+            "classifier.prompty": {"temperature": 0.1, "max_tokens": 400}, "summary.prompty": {}
+        }
@@ -0,0 +1,249 @@
+"""Tests for keeping rights-managed emails away from the AI."""
+
+import asyncio
+from datetime import datetime
+from types import SimpleNamespace
+from unittest.mock import AsyncMock, MagicMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.ai import ai_rate_limiter, router
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import ProtectedContentError, register_error_handlers
+from backend.core.protection import (
+    PROTECTED_BODY, PROTECTED_REASON, check_ai_allowed, is_protected_item, mark_protection
+)
+from backend.database.connection import DatabaseManager
+from backend.models.graph_email import EmailNormalizer
+from backend.models.user import UserInDB
+from backend.services.email_service import EmailService
+from backend.services.job_queue import JobQueue, JobStatus
+from backend.services.summary_backfill import summarize_emails
+from backend.services.thread_classifier import classify_batch
+from backend.workers.email_processor import EmailProcessorWorker, MockTaskService
+
+USER_ID = 1
+
+
+def make_email(email_id, protected=False, conversation_id=None, minute=0):
+    """Build a provider-style email dict, rights-managed if protected."""
+    return mark_protection({
+        "id": email_id,
+        "subject": f"Subject {email_id}",
+        "sender": "alice@example.com",
+        "body": "Quarterly numbers attached.",
+        "received_time": f"2024-01-01T10:{minute:02d}:00Z",
+        "conversation_id": conversation_id,
+        "message_class": "IPM.Note.rpmsg" if protected else "IPM.Note",
+        "permission": 0,
+    })
+
+
+@pytest.fixture
+def stub_ai():
+    """Stub AI client filing everything as fyi."""
+    ai = MagicMock()
+    ai.classify_email_async = AsyncMock(return_value={"category": "fyi", "confidence": 0.9})
+    ai.generate_summary = AsyncMock(return_value={"summary": "Numbers are in."})
+    ai.draft_reply = AsyncMock(return_value={"subject": "Re: numbers", "body": "Thanks"})
+    return ai
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+class TestDetection:
+    """Tests for recognizing rights-managed emails from Outlook properties."""
+
+    def test_message_classes(self):
+        """Test that IRM and encrypted message classes are protected and signed-only mail is not."""
+        assert is_protected_item("IPM.Note.rpmsg.Microsoft.Voicemail.UM")
+        assert is_protected_item("ipm.note.SMIME")
+        assert not is_protected_item("IPM.Note.SMIME.MultipartSigned")
+        assert not is_protected_item("IPM.Note")
+
+    def test_permission_and_attachment(self):
+        """Test that a restricting permission or an unopened message.rpmsg marks an email."""
+        assert is_protected_item("IPM.Note", permission=1)
+        assert not is_protected_item("IPM.Note", permission=0)
+        assert is_protected_item("IPM.Note", attachments=[{"name": "message.rpmsg", "size": 4096}])
+        assert not is_protected_item("IPM.Note", attachments=["deck.pptx"])
+
+    def test_unreadable_properties_are_ignored(self):
+        """Test that properties of the wrong type do not mark an email."""
+        assert not is_protected_item(MagicMock(), permission=MagicMock(), attachments=MagicMock())
+
+    def test_body_replaced(self):
+        """Test that a protected email's body is replaced by the placeholder."""
+        email = mark_protection({
+            "id": "p1", "body": "garbled", "html_body": "<p>garbled</p>", "message_class": "IPM.Note.rpmsg"
+        })
+
+        assert (email["is_protected"], email["body"], email["html_body"]) == (True, PROTECTED_BODY, "")
+        assert make_email("plain")["is_protected"] is False
+
+    def test_allowed_keeps_body(self, monkeypatch):
+        """Test that allow_protected_ai keeps the body and lets the email through."""
+        monkeypatch.setattr(settings, "allow_protected_ai", True)
+
+        email = mark_protection({"id": "p1", "body": "as read", "permission": 1})
+
+        assert (email["is_protected"], email["body"]) == (True, "as read")
+        check_ai_allowed(email)
+
+    def test_refused(self):
+        """Test that protected emails are refused with a 422."""
+        with pytest.raises(ProtectedContentError) as exc_info:
+            check_ai_allowed(make_email("p1", protected=True))
+
+        assert exc_info.value.status_code == 422
+
+    def test_graph_message_class(self):
+        """Test that Graph messages are read with the expanded MessageClass property."""
+        email = mark_protection(EmailNormalizer.normalize_graph_message({
+            "id": "g1",
+            "body": {"content": "garbled"},
+            "singleValueExtendedProperties": [{"id": "String 0x1a", "value": "IPM.Note.rpmsg"}],
+        }))
+
+        assert (email["message_class"], email["is_protected"], email["body"]) == (
+            "IPM.Note.rpmsg", True, PROTECTED_BODY
+        )
+
+
+class TestBatchSkip:
+    """Tests for leaving protected emails out of batch AI work."""
+
+    @pytest.mark.asyncio
+    async def test_classification_skips_protected(self, stub_ai, store):
+        """Test that protected emails are skipped with a reason and never reach the AI."""
+        emails = [make_email("p1", protected=True), make_email("e1")]
+
+        outcome = await classify_batch(emails, stub_ai, EmailService(MagicMock(), db=store), USER_ID)
+
+        assert outcome.skipped == {"p1": PROTECTED_REASON}
+        assert [result.email_id for result in outcome.results] == ["e1"]
+        assert outcome.failures == {}
+        stub_ai.classify_email_async.assert_awaited_once()
+        assert "Subject e1" in str(stub_ai.classify_email_async.await_args)
+
+    @pytest.mark.asyncio
+    async def test_thread_digest_leaves_out_protected(self, stub_ai, store):
+        """Test that a protected earlier message is not quoted for the latest one of its thread."""
+        emails = [make_email("p1", True, "conv", minute=1), make_email("e1", False, "conv", minute=2)]
+
+        outcome = await classify_batch(
+            emails, stub_ai, EmailService(MagicMock(), db=store), USER_ID, thread_mode=True
+        )
+
+        assert (outcome.skipped, outcome.ai_calls_saved) == ({"p1": PROTECTED_REASON}, 0)
+        assert "Subject p1" not in str(stub_ai.classify_email_async.await_args)
+
+    @pytest.mark.asyncio
+    async def test_allowed_protected_is_classified(self, stub_ai, store, monkeypatch):
+        """Test that allow_protected_ai classifies protected emails like any other."""
+        monkeypatch.setattr(settings, "allow_protected_ai", True)
+
+        outcome = await classify_batch(
+            [make_email("p1", protected=True)], stub_ai, EmailService(MagicMock(), db=store), USER_ID
+        )
+
+        assert (outcome.skipped, [result.email_id for result in outcome.results]) == ({}, ["p1"])
+
+    @pytest.mark.asyncio
+    async def test_batch_summaries_skip_protected(self, stub_ai):
+        """Test that batch summaries mark protected emails skipped without an AI call."""
+        emails = [{"id": "p1", "content": PROTECTED_BODY, "is_protected": 1}, {"id": "e1", "content": "Hi"}]
+
+        results = await summarize_emails(emails, stub_ai)
+
+        assert results["p1"] == {"skipped": PROTECTED_REASON}
+        assert results["e1"]["summary"] == "Numbers are in."
+        stub_ai.generate_summary.assert_awaited_once()
+
+    @pytest.mark.asyncio
+    async def test_flag_is_stored(self, store):
+        """Test that stored emails keep is_protected."""
+        service = EmailService(MagicMock(), db=store)
+        await service.store_email(make_email("p1", protected=True), USER_ID)
+        await service.store_email(make_email("e1"), USER_ID)
+
+        stored = await service.get_stored_emails(["p1", "e1"], USER_ID)
+
+        assert (stored["p1"]["is_protected"], stored["e1"]["is_protected"]) == (True, False)
+        assert stored["p1"]["content"] == PROTECTED_BODY
+
+
+class ProtectedEmailService:
+    """Worker email service whose emails are all rights-managed."""
+
+    async def get_email(self, email_id):
+        return {"id": email_id, "subject": "Restricted", "content": PROTECTED_BODY, "is_protected": True}
+
+    async def update_email_category(self, email_id, category):
+        return True
+
+
+class TestPipelineSkip:
+    """Tests for protected emails in the processing pipeline."""
+
+    @pytest.mark.asyncio
+    async def test_jobs_are_skipped_not_failed(self):
+        """Test that every job of a protected email is skipped and the pipeline still completes."""
+        job_queue = JobQueue()
+        worker = EmailProcessorWorker()
+        worker.ai_service = SimpleNamespace(tokens_used=0)  # Any AI call would fail the job
+        worker.email_service = ProtectedEmailService()
+        worker.task_service = MockTaskService()
+        with patch("backend.workers.email_processor.job_queue", job_queue), \
+                patch("backend.workers.email_processor.websocket_manager") as websocket:
+            websocket.broadcast_job_status = AsyncMock()
+            websocket.send_processing_complete = AsyncMock()
+            pipeline_id = await job_queue.create_pipeline(["p1"], "user_1")
+
+            while (job := await job_queue.get_next_job()) is not None:
+                await worker._process_job(job)
+
+        pipeline = await job_queue.get_pipeline(pipeline_id)
+        assert {job.status for job in pipeline.jobs} == {JobStatus.SKIPPED}
+        assert pipeline.jobs[0].progress.message == f"Skipped: {PROTECTED_REASON}"
+        assert pipeline.status == "completed"
+        websocket.send_processing_complete.assert_awaited_once()
+
+
+class TestSingleEmailRefusal:
+    """Tests for single-email AI endpoints refusing protected emails."""
+
+    @pytest.fixture
+    def client(self, stub_ai, store):
+        """Client over a store holding a protected email."""
+        service = EmailService(MagicMock(), db=store)
+        asyncio.run(service.store_email(make_email("p1", protected=True), USER_ID))
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="reader", email="reader@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        ai_rate_limiter.reset()
+        yield TestClient(app)
+        ai_rate_limiter.reset()
+
+    def test_draft_reply_refused(self, client, stub_ai):
+        """Test that drafting a reply to a protected email is a 422 without an AI call."""
+        response = client.post("/api/ai/draft-reply", json={"email_id": "p1"})
+
+        assert response.status_code == 422
+        assert "protected content" in response.text
+        stub_ai.draft_reply.assert_not_called()
@@ -0,0 +1,259 @@
+"""Tests for answering reads from the database when the provider is down."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.provider_fallback import is_transient_provider_error, provider_degraded
+
+USER_ID = 1
+
+
+class FlakyProvider(MockEmailProvider):
+    """Mock mailbox whose every call fails with the configured error until healed."""
+
+    def __init__(self):
+        super().__init__()
+        self.authenticate({})
+        self.error = HTTPException(status_code=503, detail="Outlook is not responding")
+
+    def _fail(self):
+        if self.error is not None:
+            raise self.error
+
+    def get_emails(self, *args, **kwargs):
+        self._fail()
+        return super().get_emails(*args, **kwargs)
+
+    def get_email_content(self, email_id):
+        self._fail()
+        return super().get_email_content(email_id)
+
+    def get_conversation_thread(self, conversation_id):
+        self._fail()
+        return super().get_conversation_thread(conversation_id)
+
+    def get_folders(self):
+        self._fail()
+        return super().get_folders()
+
+    def mark_as_read(self, email_id, suppress_read_receipt=False):
+        self._fail()
+        return super().mark_as_read(email_id, suppress_read_receipt)
+
+
+@pytest.fixture
+def fallback_policy(monkeypatch):
+    """Serve reads from the database when the provider is down."""
+    monkeypatch.setattr(settings, "provider_read_policy", "fallback")
+
+
+@pytest.fixture(autouse=True)
+def reset_gauge():
+    """Start every test with a healthy provider gauge."""
+    provider_degraded.reset()
+    yield
+    provider_degraded.reset()
+
+
+@pytest.fixture
+def store():
+    """In-memory store with three emails in two folders and one thread."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    seed = EmailService(MockEmailProvider(), db=db)
+    for email_id, folder, received, conversation_id in [
+        ("old", "Inbox", "2024-01-01T09:00:00", "thread-1"),
+        ("new", "Inbox", "2024-01-02T09:00:00", "thread-1"),
+        ("filed", "Archive", "2024-01-03T09:00:00", None),
+    ]:
+        asyncio.run(seed.save_classification(
+            {"id": email_id, "subject": f"Subject {email_id}", "sender": "a@example.com",
+             "body": "Hello", "received_time": received, "folder": folder,
+             "conversation_id": conversation_id},
+            EmailClassification(category="fyi", confidence=0.9),
+            USER_ID
+        ))
+    with db.get_connection() as conn:
+        conn.execute("UPDATE emails SET is_read = 1 WHERE id = 'old'")
+        conn.commit()
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def provider():
+    """Provider that is currently failing."""
+    return FlakyProvider()
+
+
+@pytest.fixture
+def service(provider, store):
+    """Email service over the flaky provider and the seeded store."""
+    return EmailService(provider, db=store)
+
+
+class TestTransientErrors:
+    """Tests for deciding which provider errors fall back."""
+
+    @pytest.mark.parametrize("error,expected", [
+        (HTTPException(status_code=503, detail="down"), True),
+        (HTTPException(status_code=500, detail="COM error"), True),
+        (HTTPException(status_code=401, detail="Not authenticated"), True),
+        (HTTPException(status_code=404, detail="missing"), False),
+        (HTTPException(status_code=400, detail="bad folder"), False),
+        (RuntimeError("Not connected to Outlook"), True),
+        (ConnectionError("reset"), True),
+        (TimeoutError(), True),
+        (type("com_error", (Exception,), {})("RPC server unavailable"), True),
+        (ValueError("bad input"), False),
+    ])
+    def test_classification(self, error, expected):
+        """Test that outages fall back and request errors do not."""
+        assert is_transient_provider_error(error) is expected
+
+
+class TestFallbackReads:
+    """Tests for EmailService reads under each policy."""
+
+    @pytest.mark.asyncio
+    async def test_strict_raises(self, service):
+        """Test that the default policy surfaces the provider error."""
+        assert settings.provider_read_policy == "strict"
+
+        with pytest.raises(HTTPException):
+            await service.get_emails(user_id=USER_ID)
+        assert service.degraded is False
+
+    @pytest.mark.asyncio
+    async def test_get_emails_from_store(self, service, fallback_policy):
+        """Test that the folder is listed from stored emails, newest first."""
+        emails = await service.get_emails("Inbox", count=10, user_id=USER_ID)
+
+        assert [email["id"] for email in emails] == ["new", "old"]
+        assert service.degraded is True
+        gauge = provider_degraded.snapshot()
+        assert gauge["provider_degraded"] == 1
+        assert gauge["last_operation"] == "get_emails"
+        assert gauge["last_error"] == "Outlook is not responding"
+
+    @pytest.mark.asyncio
+    async def test_recovery_clears_gauge(self, service, provider, fallback_policy):
+        """Test that a successful provider read marks the provider healthy again."""
+        await service.get_emails(user_id=USER_ID)
+        provider.error = None
+
+        emails = await EmailService(provider, db=service.db).get_emails(user_id=USER_ID)
+
+        assert {email["id"] for email in emails} == {"mock-email-1", "mock-email-2"}
+        assert provider_degraded.snapshot()["provider_degraded"] == 0
+        assert provider_degraded.snapshot()["fallback_count"] == 1
+
+    @pytest.mark.asyncio
+    async def test_get_email_by_id_from_store(self, service, fallback_policy):
+        """Test that a single email is served from its stored copy."""
+        email = await service.get_email_by_id("filed", user_id=USER_ID)
+
+        assert email["id"] == "filed"
+        assert email["content"] == "Hello"
+        assert await service.get_email_by_id("unknown", user_id=USER_ID) is None
+
+    @pytest.mark.asyncio
+    async def test_get_conversation_from_store(self, service, fallback_policy):
+        """Test that a thread is rebuilt from stored emails, oldest first."""
+        emails = await service.get_conversation("thread-1", USER_ID)
+
+        assert [email["id"] for email in emails] == ["old", "new"]
+        assert service.degraded is True
+
+    @pytest.mark.asyncio
+    async def test_get_folders_from_store(self, service, fallback_policy):
+        """Test that folders and counts come from stored emails."""
+        folders = await service.get_folders(user_id=USER_ID)
+
+        assert [(f["name"], f["total_count"], f["unread_count"]) for f in folders] == [
+            ("Archive", 1, 1), ("Inbox", 2, 1)
+        ]
+
+    @pytest.mark.asyncio
+    async def test_request_errors_do_not_fall_back(self, service, provider, fallback_policy):
+        """Test that non-transient errors still raise."""
+        provider.error = HTTPException(status_code=404, detail="Folder not found")
+
+        with pytest.raises(HTTPException):
+            await service.get_emails("Missing", user_id=USER_ID)
+        assert provider_degraded.snapshot()["provider_degraded"] == 0
+
+    @pytest.mark.asyncio
+    async def test_reads_without_user_raise(self, service, fallback_policy):
+        """Test that reads with no user to read stored emails for still raise."""
+        with pytest.raises(HTTPException):
+            await service.get_emails()
+        with pytest.raises(HTTPException):
+            await service.get_folders()
+
+    @pytest.mark.asyncio
+    async def test_writes_never_fall_back(self, service, provider, fallback_policy, monkeypatch):
+        """Test that a failing write raises even under the fallback policy when the outbox is off."""
+        monkeypatch.setattr(settings, "outbox_enabled", False)
+        provider.error = RuntimeError("Not connected to Outlook")
+
+        with pytest.raises(RuntimeError):
+            await service.mark_as_read("new")
+        assert service.degraded is False
+
+
+class TestFallbackEndpoints:
+    """Tests for the degraded indicator in API responses."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="offline", email="offline@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: EmailService(service.provider, db=service.db)
+        return TestClient(app)
+
+    def test_list_is_degraded(self, client, fallback_policy):
+        """Test that the list envelope reports degraded."""
+        data = client.get("/api/emails?folder=Inbox").json()
+
+        assert data["degraded"] is True
+        assert [email["id"] for email in data["emails"]] == ["new", "old"]
+
+    def test_email_and_folders_are_degraded(self, client, fallback_policy):
+        """Test that single emails and folder lists report degraded."""
+        assert client.get("/api/emails/new?source=outlook").json()["degraded"] is True
+        folders = client.get("/api/folders").json()
+        assert folders["degraded"] is True
+        assert folders["cache_age_seconds"] is None
+
+    def test_healthy_provider_is_not_degraded(self, client, service, fallback_policy):
+        """Test that normal responses report degraded false."""
+        service.provider.error = None
+
+        data = client.get("/api/emails").json()
+
+        assert data["degraded"] is False
+        assert "degraded" not in client.get("/api/emails/mock-email-1").json()
+
+    def test_strict_returns_error(self, client):
+        """Test that the strict policy returns the provider's error."""
+        assert client.get("/api/emails").status_code == 503
@@ -0,0 +1,238 @@
+"""Tests for quarantining applied spam, purging it, and rescuing it."""
+
+from datetime import datetime, timedelta
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.quarantine import QUARANTINE_FOLDER, is_purge_eligible, quarantine_deadline
+
+USER_ID = 1
+NOW = datetime(2024, 3, 10, 12, 0, 0)
+
+
+def quarantined_row(**overrides):
+    """A stored spam email whose quarantine ended an hour ago."""
+    row = {
+        "id": "spam-1",
+        "folder": QUARANTINE_FOLDER,
+        "category": "spam_to_delete",
+        "quarantined_until": str(NOW - timedelta(hours=1)),
+    }
+    row.update(overrides)
+    return row
+
+
+@pytest.fixture
+def quarantine_days(monkeypatch):
+    """Enable a seven day quarantine."""
+    monkeypatch.setattr(settings, "spam_quarantine_days", 7)
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock mailbox."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    return provider
+
+
+@pytest.fixture
+async def service(provider):
+    """Email service over an isolated store with both mock emails classified as spam."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    for email in provider.mock_emails:
+        await email_service.save_classification(
+            email, EmailClassification(category="spam_to_delete", confidence=0.9), USER_ID
+        )
+    yield email_service
+    store.close()
+
+
+def set_quarantined_until(service, email_id, until):
+    """Move a stored email's quarantine deadline."""
+    with service.db.get_connection() as conn:
+        conn.execute("UPDATE emails SET quarantined_until = ? WHERE id = ?", (until, email_id))
+        conn.commit()
+
+
+def history(service, email_id):
+    """Read an email's (previous_category, category, source) history."""
+    with service.db.get_connection() as conn:
+        rows = conn.execute(
+            "SELECT previous_category, category, source FROM classification_history WHERE email_id = ?",
+            (email_id,)
+        ).fetchall()
+    return [tuple(row) for row in rows]
+
+
+class TestPurgeEligibility:
+    """Tests for deciding which quarantined emails may be purged."""
+
+    def test_expired_spam_is_eligible(self):
+        """Test that spam past its quarantine is purged."""
+        assert is_purge_eligible(quarantined_row(), NOW)
+
+    def test_deadline_is_inclusive(self):
+        """Test that an email is eligible exactly at its deadline."""
+        assert is_purge_eligible(quarantined_row(quarantined_until=NOW), NOW)
+
+    @pytest.mark.parametrize("overrides", [
+        {"quarantined_until": str(NOW + timedelta(minutes=1))},
+        {"quarantined_until": None},
+        {"folder": "Inbox"},
+        {"folder": None},
+        {"category": "work_relevant"},
+        {"quarantined_until": "next tuesday"},
+    ])
+    def test_not_eligible(self, overrides):
+        """Test that pending, rescued, reclassified, and unreadable rows are kept."""
+        assert not is_purge_eligible(quarantined_row(**overrides), NOW)
+
+    def test_deadline(self):
+        """Test that the deadline is the configured days after now."""
+        assert quarantine_deadline(7, NOW) == datetime(2024, 3, 17, 12, 0, 0)
+
+
+class TestQuarantineService:
+    """Tests for applying, purging, and rescuing through EmailService."""
+
+    @pytest.mark.asyncio
+    async def test_apply_spam_quarantines(self, service, provider, quarantine_days):
+        """Test that applying spam moves it to Quarantine and stamps the row."""
+        assert await service.apply_category("mock-email-1", "spam_to_delete", USER_ID)
+
+        assert provider.mock_emails[0]["folder"] == QUARANTINE_FOLDER
+        assert provider.mock_emails[0]["categories"] == ["Test"]
+        emails, total = await service.get_quarantined_emails(USER_ID)
+        assert total == 1
+        assert emails[0]["id"] == "mock-email-1"
+        assert emails[0]["quarantined_until"] is not None
+
+    @pytest.mark.asyncio
+    async def test_apply_without_quarantine(self, service, provider):
+        """Test that spam is categorized as before when quarantine is off."""
+        assert settings.spam_quarantine_days == 0
+
+        assert await service.apply_category("mock-email-1", "spam_to_delete", USER_ID)
+
+        assert provider.mock_emails[0]["categories"] == ["spam_to_delete"]
+        assert (await service.get_quarantined_emails(USER_ID))[1] == 0
+
+    @pytest.mark.asyncio
+    async def test_purge_deletes_only_expired(self, service, provider, quarantine_days):
+        """Test that the purge deletes aged-out spam in the mailbox and the database."""
+        for email_id in ("mock-email-1", "mock-email-2"):
+            await service.apply_category(email_id, "spam_to_delete", USER_ID)
+        set_quarantined_until(service, "mock-email-1", datetime.utcnow() - timedelta(days=1))
+
+        purged = await service.purge_quarantine()
+
+        assert purged == ["mock-email-1"]
+        assert [email["id"] for email in provider.mock_emails] == ["mock-email-2"]
+        assert await service.get_stored_email("mock-email-1", USER_ID) is None
+        assert await service.get_stored_email("mock-email-2", USER_ID) is not None
+
+    @pytest.mark.asyncio
+    async def test_failed_delete_is_retried(self, service, provider, quarantine_days):
+        """Test that a row survives when the mailbox delete fails."""
+        await service.apply_category("mock-email-1", "spam_to_delete", USER_ID)
+        set_quarantined_until(service, "mock-email-1", datetime.utcnow() - timedelta(days=1))
+        provider.mock_emails = []
+
+        assert await service.purge_quarantine() == []
+        assert await service.get_stored_email("mock-email-1", USER_ID) is not None
+
+    @pytest.mark.asyncio
+    async def test_rescue_records_correction(self, service, provider, quarantine_days):
+        """Test that a rescue returns the email to the inbox as a user correction."""
+        await service.apply_category("mock-email-1", "spam_to_delete", USER_ID)
+
+        assert await service.rescue_email("mock-email-1", USER_ID, "newsletter")
+
+        assert provider.mock_emails[0]["folder"] == "Inbox"
+        row = await service.get_stored_email("mock-email-1", USER_ID)
+        assert row["folder"] == "Inbox"
+        assert row["quarantined_until"] is None
+        assert row["category"] == "newsletter"
+        assert row["confidence"] == 1.0
+        assert history(service, "mock-email-1") == [("spam_to_delete", "newsletter", "user")]
+
+    @pytest.mark.asyncio
+    async def test_rescued_email_is_not_purged(self, service, quarantine_days):
+        """Test that a rescue stops a later purge."""
+        await service.apply_category("mock-email-1", "spam_to_delete", USER_ID)
+        await service.rescue_email("mock-email-1", USER_ID, "fyi")
+
+        assert await service.purge_quarantine(now=datetime.utcnow() + timedelta(days=30)) == []
+
+    @pytest.mark.asyncio
+    async def test_rescue_errors(self, service, quarantine_days):
+        """Test that only stored, quarantined emails can be rescued."""
+        with pytest.raises(LookupError):
+            await service.rescue_email("missing", USER_ID, "fyi")
+        with pytest.raises(ValueError):
+            await service.rescue_email("mock-email-1", USER_ID, "fyi")
+
+
+class TestQuarantineAPI:
+    """Tests for GET /emails/quarantine and POST /emails/{id}/rescue."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="rescuer", email="rescuer@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        return TestClient(app)
+
+    def test_apply_list_and_rescue(self, client, service, quarantine_days):
+        """Test quarantining through a correction, listing it, and rescuing it."""
+        response = client.put("/api/emails/classifications", json={
+            "classifications": [{"email_id": "mock-email-2", "category": "spam_to_delete"}],
+            "apply_to_outlook": True
+        })
+        assert response.status_code == 200
+
+        listed = client.get("/api/emails/quarantine").json()
+        assert listed["total"] == 1
+        assert listed["emails"][0]["id"] == "mock-email-2"
+
+        response = client.post("/api/emails/mock-email-2/rescue?category=team_action")
+        assert response.status_code == 200
+        assert response.json()["success"] is True
+        assert client.get("/api/emails/quarantine").json()["total"] == 0
+        assert history(service, "mock-email-2")[-1] == ("spam_to_delete", "team_action", "user")
+
+    def test_rescue_defaults_category(self, client, service, quarantine_days):
+        """Test that a rescue without a category records work_relevant."""
+        client.put("/api/emails/classifications", json={
+            "classifications": [{"email_id": "mock-email-1", "category": "spam_to_delete"}],
+            "apply_to_outlook": True
+        })
+
+        assert client.post("/api/emails/mock-email-1/rescue").status_code == 200
+        assert history(service, "mock-email-1")[-1][1] == "work_relevant"
+
+    def test_rescue_errors(self, client):
+        """Test missing, not quarantined, and invalid category responses."""
+        assert client.post("/api/emails/missing/rescue").status_code == 404
+        assert client.post("/api/emails/mock-email-1/rescue").status_code == 409
+        assert client.post("/api/emails/mock-email-1/rescue?category=spam_to_delete").status_code == 422
@@ -0,0 +1,134 @@
+"""Tests for read-only mode."""
+
+import re
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.routing import APIRoute
+from fastapi.testclient import TestClient
+
+from backend.core.config import settings
+from backend.core.errors import ReadOnlyError
+from backend.core.read_only import MUTATING_METHODS, is_read_only_blocked
+from backend.database.connection import DatabaseManager
+from backend.main import app
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.job_queue import JobQueue
+
+NOW = datetime(2024, 5, 1, 9, 0, 0)
+
+
+@pytest.fixture
+def read_only(monkeypatch):
+    """Switch the server to read-only mode."""
+    monkeypatch.setattr(settings, "read_only", True)
+
+
+@pytest.fixture
+def client():
+    """Client for the full application."""
+    return TestClient(app)
+
+
+def mutating_routes():
+    """(method, path) of every registered route read-only mode refuses, path parameters filled in."""
+    routes = []
+    for route in app.routes:
+        if not isinstance(route, APIRoute):
+            continue
+        for method in sorted(route.methods & MUTATING_METHODS):
+            if is_read_only_blocked(method, route.path):
+                routes.append((method, re.sub(r"\{[^}]+\}", "1", route.path)))
+    return routes
+
+
+@pytest.fixture
+def service():
+    """Email service over the mock mailbox and an isolated store."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield EmailService(provider, db=store)
+    store.close()
+
+
+class TestMiddleware:
+    """Tests for refusing mutating requests."""
+
+    def test_every_mutating_route_is_refused(self, client, read_only):
+        """Test that each registered POST/PUT/PATCH/DELETE route answers 403 read_only."""
+        routes = mutating_routes()
+        assert len(routes) > 40
+
+        for method, path in routes:
+            response = client.request(method, path, json={})
+
+            assert response.status_code == 403, (method, path)
+            error = response.json()["error"]
+            assert error["code"] == "read_only", (method, path)
+            assert error["request_id"] == response.headers["X-Request-ID"]
+
+    def test_exempt_and_read_routes_pass(self, client, read_only):
+        """Test that auth, health, and GET requests still reach their routes."""
+        assert not is_read_only_blocked("POST", "/auth/login")
+        assert client.post("/auth/login", json={}).status_code == 422
+        assert client.get("/health").status_code == 200
+        assert client.get("/api/config").json()["read_only"] is True
+
+    def test_off_by_default(self, client):
+        """Test that mutating requests reach their routes when the mode is off."""
+        response = client.post("/api/processing/start", json={})
+
+        assert response.json()["error"]["code"] != "read_only"
+        assert client.get("/api/config").json()["read_only"] is False
+
+
+class TestServiceGuards:
+    """Tests for the service-level guards behind the middleware."""
+
+    @pytest.mark.asyncio
+    async def test_provider_changes_refused(self, service, read_only):
+        """Test that EmailService refuses mailbox changes without calling the provider."""
+        with pytest.raises(ReadOnlyError):
+            await service.move_email("mock-email-1", "Archive")
+        with pytest.raises(ReadOnlyError):
+            await service.delete_email("mock-email-1")
+
+        assert [email["folder"] for email in service.provider.mock_emails] == ["Inbox", "Inbox"]
+        assert (await service.activity.list_activity())[1] == 0
+
+    @pytest.mark.asyncio
+    async def test_outbox_not_drained(self, service, read_only):
+        """Test that queued changes wait, untouched, while the server is read-only."""
+        service.outbox.enqueue("move_email", "mock-email-1", {"destination_folder": "Archive"}, "offline", now=NOW)
+
+        assert await service.drain_outbox() == 0
+        [entry] = service.outbox.due()
+        assert entry.attempts == 0
+
+    @pytest.mark.asyncio
+    async def test_pipeline_start_refused(self, read_only):
+        """Test that no pipeline can be created, whatever route or message asked for it."""
+        with pytest.raises(ReadOnlyError):
+            await JobQueue().create_pipeline(["mock-email-1"], "1")
+
+
+class TestWebSocket:
+    """Tests for pipeline actions sent over the processing WebSocket."""
+
+    def test_pipeline_actions_refused(self, read_only):
+        """Test that retry and cancel messages get a read_only error."""
+        from backend.api.processing import router
+
+        ws_app = FastAPI()
+        ws_app.include_router(router, prefix="/api")
+        with TestClient(ws_app) as ws_client:
+            with ws_client.websocket_connect("/api/processing/ws?user_id=1") as ws:
+                assert ws.receive_json()["type"] == "connection_established"
+                ws.receive_json()
+                for message_type in ("retry_failed_jobs", "cancel_pipeline"):
+                    ws.send_json({"type": message_type, "pipeline_id": "pipeline_1"})
+                    reply = ws.receive_json()
+                    assert (reply["type"], reply["code"]) == ("error", "read_only")
@@ -0,0 +1,131 @@
+"""Tests for stored To/Cc recipients and the to_me filter."""
+
+import asyncio
+from datetime import datetime
+from unittest.mock import patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.core.recipients import recipient_summary
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.graph_email import EmailNormalizer
+from backend.models.user import UserInDB
+from backend.services.ai_service import AIService
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+MY_ADDRESS = "me@example.com"
+
+
+@pytest.fixture
+def service():
+    """Email service over an authenticated mock provider and an in-memory store."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield EmailService(provider, db=store)
+    store.close()
+
+
+async def save(service, email_id, to, cc=()):
+    """Classify and store an email with the given recipients."""
+    email = {"id": email_id, "subject": email_id, "sender": "s@example.com", "body": "Hi", "to": list(to), "cc": list(cc)}
+    await service.save_classification(email, EmailClassification(category="fyi", confidence=0.9), USER_ID)
+
+
+class TestRecipientSummary:
+    """Tests for the recipient lines given to the classifier."""
+
+    def test_to_and_cc(self):
+        """Test that To, Cc, and the total are listed."""
+        summary = recipient_summary({"to": ["me@example.com"], "cc": ["a@example.com", "b@example.com"]})
+
+        assert summary == "To: me@example.com\nCc: a@example.com, b@example.com\nRecipients: 3"
+
+    def test_long_lists_are_counted(self):
+        """Test that addresses past the first few are counted, not listed."""
+        to = [f"user{n}@example.com" for n in range(8)]
+
+        assert recipient_summary({"to": to}).splitlines()[0].endswith("user4@example.com (+3 more)")
+
+    def test_single_recipient_fallback(self):
+        """Test that emails with only a recipient address still summarize."""
+        assert recipient_summary({"recipient": MY_ADDRESS}) == f"To: {MY_ADDRESS}\nRecipients: 1"
+        assert recipient_summary({}) == ""
+
+    def test_graph_messages(self):
+        """Test that Graph messages carry To and Cc lists."""
+        email = EmailNormalizer.normalize_graph_message({
+            "id": "g1",
+            "toRecipients": [{"emailAddress": {"address": MY_ADDRESS}}],
+            "ccRecipients": [{"emailAddress": {"address": "boss@example.com"}}],
+        })
+
+        assert (email["to"], email["cc"], email["recipient_count"]) == ([MY_ADDRESS], ["boss@example.com"], 2)
+
+
+class TestStoredRecipients:
+    """Tests for storing recipients and filtering by them."""
+
+    @pytest.mark.asyncio
+    async def test_stored_as_lists(self, service):
+        """Test that saved emails keep their To and Cc lists and count."""
+        await save(service, "e1", [MY_ADDRESS], ["boss@example.com"])
+
+        emails, _ = await service.search_stored_emails(USER_ID, {"category": "fyi"})
+        assert (emails[0]["to_recipients"], emails[0]["cc_recipients"], emails[0]["recipient_count"]) == (
+            [MY_ADDRESS], ["boss@example.com"], 2
+        )
+
+    def test_to_me_filter(self, service):
+        """Test that to_me matches emails to the user's address with few recipients."""
+        async def setup():
+            await save(service, "direct", ["Me@Example.com"])
+            await save(service, "small-group", ["a@example.com", MY_ADDRESS], ["b@example.com"])
+            await save(service, "crowd", [MY_ADDRESS, "a@example.com", "b@example.com"], ["c@example.com"])
+            await save(service, "cc-only", ["a@example.com"], [MY_ADDRESS])
+            await save(service, "lookalike", ["notme@example.com"])
+
+        asyncio.run(setup())
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="reader", email=MY_ADDRESS,
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_ai_service] = lambda: None
+        client = TestClient(app)
+
+        with patch("backend.api.emails.settings.user_email", None), \
+                patch("backend.services.email_service.settings.to_me_recipient_limit", 4):
+            emails = client.get("/api/emails", params={"to_me": True, "sort": "subject"}).json()["emails"]
+            assert [email["id"] for email in emails] == ["direct", "small-group"]
+            # to_me=false applies no filter, so the provider folder is listed
+            assert client.get("/api/emails", params={"to_me": False}).json()["total"] == 2
+
+        with patch("backend.api.emails.settings.user_email", "other@example.com"):
+            assert client.get("/api/emails", params={"to_me": True}).json()["emails"] == []
+
+
+class TestClassificationPrompt:
+    """Tests for recipients in the classification prompt."""
+
+    @pytest.mark.asyncio
+    async def test_recipients_follow_headers(self):
+        """Test that the recipient summary is placed with the email headers."""
+        service = AIService()
+        service._initialized = True
+        with patch.object(service, "_classify_email_sync", return_value={"category": "fyi"}) as classify:
+            await service.classify_email_async("Hi", "Body", "s@example.com", recipients="To: me@example.com\nRecipients: 1")
+
+        assert classify.call_args.args[0] == "Subject: Hi\nFrom: s@example.com\nTo: me@example.com\nRecipients: 1\n\nBody"
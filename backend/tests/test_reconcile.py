@@ -0,0 +1,155 @@
+"""Tests for reconciling stored emails with the provider."""
+
+from datetime import datetime
+from unittest.mock import patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+
+class CountingProvider(MockEmailProvider):
+    """Mock provider that records bulk lookups."""
+
+    def __init__(self):
+        super().__init__()
+        self.lookups = []
+        self.authenticate({})
+
+    def get_emails_by_ids(self, email_ids):
+        self.lookups.append(list(email_ids))
+        return super().get_emails_by_ids(email_ids)
+
+
+def stored(email_id, received_time, folder="Inbox"):
+    """Build a provider-shaped email to store."""
+    return {
+        "id": email_id,
+        "subject": email_id,
+        "sender": "someone@example.com",
+        "body": "Hello",
+        "received_time": received_time,
+        "folder": folder,
+    }
+
+
+@pytest.fixture
+def provider():
+    """Provider holding mock-email-1 (unread) and mock-email-2 (read)."""
+    return CountingProvider()
+
+
+@pytest.fixture
+async def service(provider):
+    """Email service with present, changed, and deleted emails stored."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    email_service = EmailService(provider, db=store)
+    for email, category in [
+        # Matches the provider, which has it categorized FYI
+        (stored("mock-email-1", "2024-01-01T10:00:00"), "fyi"),
+        # Stored unread in Inbox; the provider has it read in Projects as a newsletter
+        (stored("mock-email-2", "2024-01-01T11:00:00", folder="Inbox"), "work_relevant"),
+        (stored("deleted-1", "2024-01-01T12:00:00"), "newsletter"),
+        (stored("deleted-2", "2024-01-01T13:00:00"), "newsletter"),
+        (stored("elsewhere", "2024-01-01T14:00:00", folder="Archive"), "fyi"),
+    ]:
+        await email_service.save_classification(
+            email, EmailClassification(category=category, confidence=0.9), USER_ID
+        )
+    provider.mock_emails[0]["categories"] = ["FYI"]
+    provider.mock_emails[1]["categories"] = ["Newsletter"]
+    provider.mock_emails[1]["folder"] = "Projects"
+    yield email_service
+    store.close()
+
+
+def stored_row(service, email_id):
+    """Read a stored row, or None."""
+    with service.db.get_connection() as conn:
+        row = conn.execute("SELECT is_read, folder, category FROM emails WHERE id = ?", (email_id,)).fetchone()
+    return dict(row) if row else None
+
+
+class TestReconcileFolder:
+    """Tests for EmailService.reconcile_folder."""
+
+    @pytest.mark.asyncio
+    async def test_report(self, service, provider):
+        """Test missing emails and field-level mismatches are reported without changes."""
+        report = await service.reconcile_folder(USER_ID, "Inbox")
+
+        assert report["checked"] == 4
+        assert report["missing_in_outlook"] == ["deleted-2", "deleted-1"]
+        assert report["state_mismatches"] == [{
+            "email_id": "mock-email-2",
+            "differences": [
+                {"field": "is_read", "database": False, "outlook": True},
+                {"field": "folder", "database": "Inbox", "outlook": "Projects"},
+                {"field": "categories", "database": "work_relevant", "outlook": ["Newsletter"]},
+            ],
+        }]
+        assert report["removed"] == report["updated"] == 0
+        assert stored_row(service, "deleted-1") is not None
+
+    @pytest.mark.asyncio
+    async def test_apply_fixes_database(self, service):
+        """Test that apply deletes vanished rows and copies provider state."""
+        report = await service.reconcile_folder(USER_ID, "Inbox", apply=True)
+
+        assert report["removed"] == 2
+        assert report["updated"] == 1
+        assert stored_row(service, "deleted-1") is None
+        assert stored_row(service, "mock-email-2") == {"is_read": 1, "folder": "Projects", "category": "newsletter"}
+        assert (await service.reconcile_folder(USER_ID, "Inbox"))["checked"] == 1
+
+    @pytest.mark.asyncio
+    async def test_batches_and_limit(self, service, provider):
+        """Test that lookups are batched and the limit bounds the run."""
+        with patch("backend.services.email_service.RECONCILE_BATCH_SIZE", 2):
+            report = await service.reconcile_folder(USER_ID, "Inbox", limit=3)
+
+        assert provider.lookups == [["deleted-2", "deleted-1"], ["mock-email-2"]]
+        assert report["checked"] == 3
+        assert report["has_more"] is True
+
+
+class TestReconcileAPI:
+    """Tests for POST /emails/reconcile."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="sync", email="sync@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        return TestClient(app)
+
+    def test_reconcile(self, client, service):
+        """Test the report and apply mode over HTTP."""
+        data = client.post("/api/emails/reconcile?folder=Inbox").json()
+
+        assert data["missing_in_outlook"] == ["deleted-2", "deleted-1"]
+        assert data["state_mismatches"][0]["differences"][0]["field"] == "is_read"
+        assert data["applied"] is False
+
+        data = client.post("/api/emails/reconcile?apply=true").json()
+
+        assert data["removed"] == 2
+        assert stored_row(service, "deleted-2") is None
@@ -0,0 +1,116 @@
+"""Tests for the reply drafting prompt and its output parsing."""
+
+import re
+from pathlib import Path
+
+import pytest
+import yaml
+
+from backend.services.reply_drafter import (
+    DRAFT_TEMPLATE, DRAFT_TONES, build_draft_inputs, parse_draft, reply_subject
+)
+
+PROMPT_PATH = Path(__file__).parent.parent.parent / "prompts" / DRAFT_TEMPLATE
+
+
+def load_prompt():
+    """Split the prompty file into its frontmatter and template body."""
+    _, frontmatter, body = PROMPT_PATH.read_text(encoding="utf-8").split("---", 2)
+    return yaml.safe_load(frontmatter), body
+
+
+def render(body, inputs):
+    """Substitute {{name}} placeholders as prompty does for plain variables."""
+    return re.sub(r"\{\{\s*(\w+)\s*\}\}", lambda match: str(inputs[match.group(1)]), body)
+
+
+def sample_inputs(**overrides):
+    """Inputs for a reply to a budget email."""
+    values = dict(
+        subject="Q3 budget",
+        sender="cfo@company.com",
+        content="Can you confirm the Q3 numbers by Friday?",
+        tone="formal",
+        bullet_points=["Numbers are final", "  ", "Sending the sheet Thursday"],
+        username="Sam",
+        job_context="Finance lead for the payments team",
+        received_date="2024-01-08 09:00:00",
+    )
+    values.update(overrides)
+    return build_draft_inputs(**values)
+
+
+class TestDraftPrompt:
+    """Tests for rendering the draft_reply template."""
+
+    def test_inputs_match_template(self):
+        """Test that the declared inputs, placeholders, and built inputs agree."""
+        frontmatter, body = load_prompt()
+        placeholders = set(re.findall(r"\{\{\s*(\w+)\s*\}\}", body))
+
+        assert placeholders == set(frontmatter["inputs"])
+        assert set(sample_inputs()) == placeholders
+        assert set(frontmatter["outputs"]) == {"subject", "body"}
+
+    def test_render_includes_email_and_context(self):
+        """Test that the rendered prompt carries the email, tone, points, and user context."""
+        _, body = load_prompt()
+
+        rendered = render(body, sample_inputs())
+
+        assert "Can you confirm the Q3 numbers by Friday?" in rendered
+        assert "From: cfo@company.com" in rendered
+        assert "Date: 2024-01-08 09:00:00" in rendered
+        assert "Finance lead for the payments team" in rendered
+        assert "for Sam." in rendered
+        assert DRAFT_TONES["formal"] in rendered
+        assert "- Numbers are final\n- Sending the sheet Thursday" in rendered
+
+    def test_defaults_without_points_or_user(self):
+        """Test the fallbacks for missing points, user name, and date."""
+        inputs = sample_inputs(bullet_points=None, username=None, job_context=None, received_date=None)
+
+        assert inputs["bullet_points"].startswith("None given")
+        assert inputs["username"] == "User"
+        assert inputs["context"] == ""
+        assert inputs["date"] == "Unknown"
+
+    def test_unknown_tone(self):
+        """Test that unknown tones are rejected."""
+        with pytest.raises(ValueError, match="sarcastic"):
+            sample_inputs(tone="sarcastic")
+
+
+class TestParseDraft:
+    """Tests for normalizing drafter output."""
+
+    def test_dict_output(self):
+        """Test that dict output is trimmed."""
+        assert parse_draft({"subject": " Re: Q3 ", "body": " Confirmed. "}, "Q3") == {
+            "subject": "Re: Q3", "body": "Confirmed."
+        }
+
+    def test_fenced_json_without_subject(self):
+        """Test that fenced JSON parses and a missing subject falls back to Re:."""
+        result = parse_draft('```json\n{"body": "Confirmed."}\n```', "Q3 budget")
+
+        assert result == {"subject": "Re: Q3 budget", "body": "Confirmed."}
+
+    @pytest.mark.parametrize("raw,message", [
+        ("Sure, here is a reply", "invalid JSON"),
+        ('["Confirmed."]', "non-object"),
+        ({"subject": "Re: Q3", "body": "  "}, "empty body"),
+    ])
+    def test_invalid_output(self, raw, message):
+        """Test that unusable output is rejected."""
+        with pytest.raises(ValueError, match=message):
+            parse_draft(raw, "Q3")
+
+    @pytest.mark.parametrize("subject,expected", [
+        ("Q3 budget", "Re: Q3 budget"),
+        ("RE: Q3 budget", "RE: Q3 budget"),
+        ("", "Re:"),
+    ])
+    def test_reply_subject(self, subject, expected):
+        """Test that Re: is added once."""
+        assert reply_subject(subject) == expected
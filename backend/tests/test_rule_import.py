@@ -0,0 +1,277 @@
+"""Tests for importing Outlook rules as category classification rules."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.rules import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.category_service import CategoryService, get_category_service
+from backend.services.email_provider import EmailProvider, MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.rule_import import folder_categories, import_rules, map_outlook_rule, plan_rule_import
+
+CATEGORY_FOLDERS = {
+    "newsletter": "Newsletters",
+    "job_listing": "Job Listings",
+    "fyi": "FYI",
+    "work_relevant": "Inbox/Projects",
+}
+
+
+def outlook_rule(name, **fields):
+    """Rule dictionary as EmailProvider.get_rules describes it."""
+    rule = {
+        "name": name, "enabled": True, "incoming": True, "senders": [], "subject_contains": [],
+        "move_to_folder": None, "other_conditions": [], "other_actions": [],
+    }
+    rule.update(fields)
+    return rule
+
+
+NEWSLETTERS = outlook_rule("Newsletters", senders=["news@contoso.com", "digest@"], move_to_folder="Inbox/Newsletters")
+RECRUITERS = outlook_rule(
+    "Recruiters", senders=["@talent.example.com"], subject_contains=["Opportunity"],
+    move_to_folder="Job Listings", enabled=False
+)
+
+
+def mapped(rule, category_folders=CATEGORY_FOLDERS):
+    """Map a rule against category_folders."""
+    return map_outlook_rule(rule, folder_categories(category_folders))
+
+
+class TestMapOutlookRule:
+    """Tests for mapping one Outlook rule."""
+
+    def test_sender_rule(self):
+        """Test that a sender rule maps to the category filed into its folder."""
+        candidate = mapped(NEWSLETTERS)
+
+        assert (candidate.category, candidate.folder, candidate.enabled) == ("newsletter", "Inbox/Newsletters", True)
+        assert candidate.senders == ["news@contoso.com", "digest@"]
+        assert candidate.rule == (
+            'Emails from a sender address containing "news@contoso.com" or "digest@" (Outlook rule "Newsletters")'
+        )
+
+    def test_sender_and_subject_rule(self):
+        """Test that sender and subject conditions are both required, like Outlook requires them."""
+        candidate = mapped(RECRUITERS)
+
+        assert candidate.category == "job_listing"
+        assert candidate.enabled is False
+        assert candidate.rule == (
+            'Emails from a sender address containing "@talent.example.com" and with a subject '
+            'containing "Opportunity" (Outlook rule "Recruiters")'
+        )
+
+    def test_subject_only_rule(self):
+        """Test that a subject condition alone is enough."""
+        candidate = mapped(outlook_rule("FYI", subject_contains=["[FYI]", " "], move_to_folder="fyi"))
+
+        assert candidate.category == "fyi"
+        assert candidate.subject_contains == ["[FYI]"]
+        assert candidate.rule == 'Emails with a subject containing "[FYI]" (Outlook rule "FYI")'
+
+    @pytest.mark.parametrize("folder,category", [
+        ("Inbox/Projects", "work_relevant"),
+        ("inbox/projects/", "work_relevant"),
+        ("Archive/Newsletters", "newsletter"),
+    ])
+    def test_folder_matching(self, folder, category):
+        """Test that folders match by full path or last segment, ignoring case."""
+        assert mapped(outlook_rule("Rule", senders=["a@b.com"], move_to_folder=folder)).category == category
+
+    @pytest.mark.parametrize("rule,reason", [
+        (outlook_rule("Flag boss", senders=["boss@contoso.com"]), "does not move mail to a folder"),
+        (outlook_rule("Receipts", senders=["shop@"], move_to_folder="Receipts"),
+         "moves mail to Receipts, which no category is filed into"),
+        (outlook_rule("Everything", move_to_folder="Newsletters"), "has no sender or subject condition"),
+        (outlook_rule("Sent", senders=["a@b.com"], move_to_folder="FYI", incoming=False), "runs on sent mail"),
+        (outlook_rule("Attachments", senders=["a@b.com"], move_to_folder="FYI",
+                      other_conditions=["HasAttachment", "Exceptions"]),
+         "also checks HasAttachment, Exceptions, which a classification rule cannot express"),
+    ])
+    def test_unmappable(self, rule, reason):
+        """Test that rules the classifier cannot express are listed with why."""
+        result = mapped(rule)
+
+        assert result.outlook_rule == rule["name"]
+        assert result.reasons == [reason]
+
+    def test_all_reasons_listed(self):
+        """Test that every reason a rule fails is reported."""
+        result = mapped(outlook_rule("Sent copies", incoming=False))
+
+        assert result.reasons == [
+            "runs on sent mail", "has no sender or subject condition", "does not move mail to a folder"
+        ]
+
+    def test_shared_folder_is_ambiguous(self):
+        """Test that a folder several categories are filed into gives no category guess."""
+        result = mapped(NEWSLETTERS, {"newsletter": "Newsletters", "fyi": "newsletters"})
+
+        assert result.reasons == [
+            "moves mail to Inbox/Newsletters, which several categories are filed into: fyi, newsletter"
+        ]
+
+    def test_other_actions_allowed(self):
+        """Test that actions besides the move do not block the import."""
+        rule = {**NEWSLETTERS, "other_actions": ["MarkAsTask", "PlaySound"]}
+
+        assert mapped(rule).category == "newsletter"
+
+    def test_plan_keeps_rule_order(self):
+        """Test that candidates and unmappable rules keep the order the rules run in."""
+        plan = plan_rule_import(
+            [RECRUITERS, outlook_rule("Flag boss", senders=["boss@"]), NEWSLETTERS], CATEGORY_FOLDERS
+        )
+
+        assert [candidate.outlook_rule for candidate in plan.candidates] == ["Recruiters", "Newsletters"]
+        assert [rule.outlook_rule for rule in plan.unmappable] == ["Flag boss"]
+
+
+@pytest.fixture
+def store():
+    """In-memory store with the built-in categories."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield store
+    store.close()
+
+
+@pytest.fixture
+def categories(store):
+    """Category service over the store."""
+    return CategoryService(db=store)
+
+
+def description(categories, name):
+    """A category's stored classification rules."""
+    return asyncio.run(categories.get_category(name)).description
+
+
+class TestImportRules:
+    """Tests for import_rules."""
+
+    def test_appends_rule_lines(self, categories):
+        """Test that selected candidates are added to their categories' rules."""
+        before = description(categories, "newsletter")
+        plan = plan_rule_import([NEWSLETTERS, RECRUITERS], {"newsletter": "Newsletters", "job_listing": "Job Listings"})
+
+        result = asyncio.run(import_rules(["Newsletters"], plan, categories))
+
+        assert [candidate.outlook_rule for candidate in result.imported] == ["Newsletters"]
+        assert description(categories, "newsletter") == f"{before.rstrip()}\n{plan.candidates[0].rule}"
+        assert 'Outlook rule "Recruiters"' not in description(categories, "job_listing")
+
+    def test_import_twice_is_skipped(self, categories):
+        """Test that a rule already imported is not added again."""
+        plan = plan_rule_import([NEWSLETTERS], {"newsletter": "Newsletters"})
+        asyncio.run(import_rules(["Newsletters"], plan, categories))
+        imported_once = description(categories, "newsletter")
+
+        result = asyncio.run(import_rules(["Newsletters"], plan, categories))
+
+        assert result.imported == []
+        assert result.skipped[0].reasons == ["already in the rules of newsletter"]
+        assert description(categories, "newsletter") == imported_once
+
+    def test_unknown_and_unmappable_skipped(self, categories):
+        """Test that names that are not candidates are reported with reasons."""
+        plan = plan_rule_import([outlook_rule("Flag boss", senders=["boss@"])], {"newsletter": "Newsletters"})
+
+        result = asyncio.run(import_rules(["Flag boss", "Missing"], plan, categories))
+
+        assert result.imported == []
+        assert [(rule.outlook_rule, rule.reasons) for rule in result.skipped] == [
+            ("Flag boss", ["does not move mail to a folder"]),
+            ("Missing", ["no Outlook rule has this name"]),
+        ]
+
+    def test_several_rules_into_one_category(self, categories):
+        """Test that rules for the same category are all appended."""
+        other = outlook_rule("Digests", subject_contains=["Digest"], move_to_folder="Newsletters")
+        plan = plan_rule_import([NEWSLETTERS, other], {"newsletter": "Newsletters"})
+
+        result = asyncio.run(import_rules(["Newsletters", "Digests"], plan, categories))
+
+        assert len(result.imported) == 2
+        lines = description(categories, "newsletter").splitlines()
+        assert lines[-2:] == [candidate.rule for candidate in plan.candidates]
+
+    def test_description_limit(self, categories):
+        """Test that a rule that would make the description too long is skipped."""
+        senders = [f"sender{index}@example.com" for index in range(100)]
+        huge = outlook_rule("Huge", senders=senders, move_to_folder="Newsletters")
+        plan = plan_rule_import([huge], {"newsletter": "Newsletters"})
+
+        result = asyncio.run(import_rules(["Huge"], plan, categories))
+
+        assert result.skipped[0].reasons == ["the rules of newsletter would exceed 2000 characters"]
+
+
+class TestRuleImportAPI:
+    """Tests for GET /api/rules/import-candidates and POST /api/rules/import."""
+
+    @pytest.fixture
+    def provider(self):
+        """Authenticated mock provider with Outlook rules."""
+        provider = MockEmailProvider()
+        provider.authenticate({})
+        provider.mock_rules = [NEWSLETTERS, outlook_rule("Flag boss", senders=["boss@contoso.com"])]
+        return provider
+
+    @pytest.fixture
+    def client(self, provider, store, categories):
+        """Client with auth, the email service, and the category service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="filer", email="filer@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        self.email_service = EmailService(provider, db=store)
+        app.dependency_overrides[get_email_service] = lambda: self.email_service
+        app.dependency_overrides[get_category_service] = lambda: categories
+        return TestClient(app)
+
+    def test_import_candidates(self, client):
+        """Test that rules are mapped with the built-in category folders."""
+        response = client.get("/api/rules/import-candidates")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert [(c["outlook_rule"], c["category"]) for c in data["candidates"]] == [("Newsletters", "newsletter")]
+        assert data["unmappable"] == [{"outlook_rule": "Flag boss", "reasons": ["does not move mail to a folder"]}]
+
+    def test_import(self, client, categories):
+        """Test that importing adds the rule to the category."""
+        response = client.post("/api/rules/import", json={"rules": ["Newsletters"]})
+
+        assert response.status_code == 200
+        assert response.json()["imported"][0]["category"] == "newsletter"
+        assert 'Outlook rule "Newsletters"' in description(categories, "newsletter")
+
+    def test_import_requires_rules(self, client):
+        """Test that an empty selection is rejected."""
+        assert client.post("/api/rules/import", json={"rules": []}).status_code == 422
+
+    def test_provider_without_rules(self, client):
+        """Test that a provider without mail rules is a 501."""
+        self.email_service.provider = RulelessProvider()
+
+        assert client.get("/api/rules/import-candidates").status_code == 501
+
+
+class RulelessProvider(MockEmailProvider):
+    """Mock provider whose mail client has no rules."""
+
+    get_rules = EmailProvider.get_rules
@@ -0,0 +1,220 @@
+"""Tests for HTML email body sanitization."""
+
+import pytest
+from unittest.mock import MagicMock
+
+from backend.core.sanitize import (
+    external_image_domains, is_tracking_pixel, looks_like_html, sanitize_body, sanitize_html, unwrap_link
+)
+from backend.database.connection import DatabaseManager
+from backend.services.email_service import EmailService
+from backend.models.email import EmailClassification
+from backend.tests.fixtures.email_fixtures import (
+    get_malicious_html_email, get_tracker_html_email
+)
+
+
+class TestMaliciousContent:
+    """Tests that script injection attempts are removed."""
+
+    @pytest.fixture
+    def sanitized(self):
+        """The malicious fixture body after sanitization."""
+        return sanitize_html(get_malicious_html_email()["body"])
+
+    @pytest.mark.parametrize("fragment", [
+        "<script", "<style", "<iframe", "<form", "<input", "<svg", "<title",
+        "onload", "onclick", "onerror", "style=", "javascript", "JaVaScRiPt",
+        "data:text/html", "evil.example.com", "document.cookie", "Frame fallback",
+    ])
+    def test_dangerous_markup_removed(self, sanitized, fragment):
+        """Test that scripts, handlers, embeds, and unsafe URLs are gone."""
+        assert fragment not in sanitized
+
+    def test_safe_content_kept(self, sanitized):
+        """Test that formatting and text survive sanitization."""
+        assert "<p>Please review the <b>attached</b> invoice.</p>" in sanitized
+        assert "<a>View invoice</a>" in sanitized
+        assert "Sign in" in sanitized
+        assert "Thanks &amp; regards, <span>Billing &lt;team&gt;</span>" in sanitized
+
+    def test_unclosed_tags_are_closed(self, sanitized):
+        """Test that open elements are closed so markup cannot leak."""
+        assert sanitized.endswith("</span>\n</div>")
+
+    def test_idempotent(self, sanitized):
+        """Test that sanitizing twice changes nothing (read path re-sanitizes)."""
+        assert sanitize_html(sanitized) == sanitized
+
+    @pytest.mark.parametrize("dirty,clean", [
+        ('<a href="mailto:me@example.com">Mail</a>',
+         '<a href="mailto:me@example.com" rel="noopener noreferrer nofollow">Mail</a>'),
+        ('<a href="/relative">Rel</a>', "<a>Rel</a>"),
+        ('<img src="cid:logo@01" alt="Logo">', '<img src="cid:logo@01" alt="Logo">'),
+        ('<img alt="no source">', ""),
+        ('<p title="a&quot;b" id="x" class="y">T</p>', '<p title="a&quot;b">T</p>'),
+        ("<b>bold</i></b>", "<b>bold</b>"),
+        ("<table><tr><td colspan=2 onmouseover=x>A</td></tr></table>",
+         '<table><tr><td colspan="2">A</td></tr></table>'),
+    ])
+    def test_allowlist(self, dirty, clean):
+        """Test tag and attribute allowlisting on small fragments."""
+        assert sanitize_html(dirty) == clean
+
+
+class TestTrackers:
+    """Tests for tracking-pixel removal and link unwrapping."""
+
+    @pytest.fixture
+    def sanitized(self):
+        """The tracker fixture body after sanitization."""
+        return sanitize_html(get_tracker_html_email()["body"])
+
+    def test_tracking_pixels_removed(self, sanitized):
+        """Test that 1x1, hidden, and tracker-domain images are dropped."""
+        assert "open.gif" not in sanitized
+        assert "open2.gif" not in sanitized
+        assert "hidden.png" not in sanitized
+        assert "list-manage.com" not in sanitized
+        assert "mailtrack.io" not in sanitized
+
+    def test_content_images_kept(self, sanitized):
+        """Test that real images are kept with their dimensions."""
+        assert '<img src="https://cdn.example.com/banner.png" alt="Banner" width="600" height="200">' in sanitized
+
+    def test_links_unwrapped(self, sanitized):
+        """Test that Safe Links and Google redirects point at their real targets."""
+        assert 'href="https://blog.example.com/posts?id=42&amp;ref=mail"' in sanitized
+        assert 'href="https://docs.example.com/guide"' in sanitized
+        assert "safelinks" not in sanitized
+        assert "google.com" not in sanitized
+
+    @pytest.mark.parametrize("url,expected", [
+        ("https://eur01.safelinks.protection.outlook.com/?url=https%3A%2F%2Fexample.com%2F&data=1",
+         "https://example.com/"),
+        # Nested wrappers are unwrapped layer by layer
+        ("https://nam12.safelinks.protection.outlook.com/?url="
+         "https%3A%2F%2Fwww.google.com%2Furl%3Fq%3Dhttps%3A%2F%2Fexample.com%2Fdeep",
+         "https://example.com/deep"),
+        # Targets that are not http(s) are left wrapped
+        ("https://nam12.safelinks.protection.outlook.com/?url=javascript%3Aalert(1)",
+         "https://nam12.safelinks.protection.outlook.com/?url=javascript%3Aalert(1)"),
+        ("https://www.google.com/search?q=https://example.com", "https://www.google.com/search?q=https://example.com"),
+        ("https://example.com/page", "https://example.com/page"),
+    ])
+    def test_unwrap_link(self, url, expected):
+        """Test redirector unwrapping."""
+        assert unwrap_link(url) == expected
+
+    @pytest.mark.parametrize("attrs,expected", [
+        ({"src": "https://a.example.com/x.gif", "width": "1", "height": "1"}, True),
+        ({"src": "https://a.example.com/x.gif", "width": "0", "height": "0"}, True),
+        ({"src": "https://a.example.com/x.gif", "style": "height: 1px; width: 1px"}, True),
+        ({"src": "https://a.example.com/x.gif", "style": "visibility:hidden"}, True),
+        ({"src": "https://o.sendgrid.net/wf/open?upn=abc"}, True),
+        ({"src": "https://a.example.com/x.gif", "width": "1"}, False),
+        ({"src": "https://a.example.com/logo.png", "width": "120", "height": "40"}, False),
+        ({"src": "https://notsendgrid.net/logo.png"}, False),
+    ])
+    def test_is_tracking_pixel(self, attrs, expected):
+        """Test tracking-pixel detection."""
+        assert is_tracking_pixel(attrs) is expected
+
+
+class TestExternalImages:
+    """Tests for finding the hosts an HTML body loads images from."""
+
+    @pytest.mark.parametrize("body,expected", [
+        ('<img src="https://cdn.example.com/logo.png">', ["cdn.example.com"]),
+        ('<img src="HTTP://CDN.Example.com/a.png"><img src="https://cdn.example.com/b.png">',
+         ["cdn.example.com"]),
+        ('<img src="cid:image001.png@01D9"><img src="data:image/png;base64,AAAA">', []),
+        ('<img srcset="https://a.example.com/1x.png 1x, https://b.example.com/2x.png 2x">',
+         ["a.example.com", "b.example.com"]),
+        ('<table background="https://bg.example.com/tile.gif"><tr><td>x</td></tr></table>',
+         ["bg.example.com"]),
+        ("<div style=\"background-image: url('https://css.example.com/hero.jpg')\">Hi</div>",
+         ["css.example.com"]),
+        ("<style>.x { background: url(https://sheet.example.com/bg.png) }</style><p>Hi</p>",
+         ["sheet.example.com"]),
+        ('<img src="https://mailtrack.io/trace/mail/abc.png" width="1" height="1">', ["mailtrack.io"]),
+        ('<a href="https://link.example.com/">Not an image</a>', []),
+        ("Plain text https://cdn.example.com/logo.png", []),
+        (None, []),
+    ])
+    def test_external_image_domains(self, body, expected):
+        """Test image sources are found in tags, attributes, and CSS."""
+        assert external_image_domains(body) == expected
+
+
+class TestPlainText:
+    """Tests that plain text bodies pass through untouched."""
+
+    @pytest.mark.parametrize("body", [
+        "Plain text with 3 < 5 and a > quote",
+        "Call me at <555-1234> tomorrow",
+        "",
+        None,
+    ])
+    def test_plain_text_unchanged(self, body):
+        """Test that non-HTML bodies are not rewritten."""
+        assert looks_like_html(body) is False
+        assert sanitize_body(body) == body
+
+
+class TestStoredBodies:
+    """Tests for sanitization on the storage and read paths."""
+
+    @pytest.fixture
+    def service(self):
+        """Email service over an isolated in-memory store."""
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        yield EmailService(MagicMock(), db=store)
+        store.close()
+
+    @pytest.mark.asyncio
+    async def test_store_sanitizes_and_keeps_raw(self, service):
+        """Test that stored content is sanitized and the raw HTML is kept aside."""
+        email = get_malicious_html_email()
+        classification = EmailClassification(category="fyi", confidence=0.9)
+
+        await service.save_classification(email, classification, user_id=1)
+
+        with service.db.get_connection() as conn:
+            row = conn.execute(
+                "SELECT content, raw_content FROM emails WHERE id = ?", (email["id"],)
+            ).fetchone()
+        assert "<script" not in row["content"]
+        assert row["raw_content"] == email["body"]
+
+        stored = await service.get_stored_email(email["id"], user_id=1)
+        assert "raw_content" not in stored
+        with_raw = await service.get_stored_email(email["id"], user_id=1, include_raw=True)
+        assert with_raw["raw_content"] == email["body"]
+
+    @pytest.mark.asyncio
+    async def test_read_path_sanitizes_legacy_rows(self, service):
+        """Test that rows stored before sanitization are cleaned when read."""
+        with service.db.get_connection() as conn:
+            conn.execute(
+                "INSERT INTO emails (id, subject, sender, content, user_id) VALUES (?, ?, ?, ?, 1)",
+                ("legacy", "Old", "a@example.com", "<p>Hi<script>alert(1)</script></p>")
+            )
+            conn.commit()
+
+        stored = await service.get_stored_email("legacy", user_id=1)
+
+        assert stored["content"] == "<p>Hi</p>"
+
+    @pytest.mark.asyncio
+    async def test_provider_bodies_sanitized(self, service):
+        """Test that provider bodies are sanitized, with raw_body only on request."""
+        email = get_tracker_html_email()
+        service.provider.get_email_content.return_value = email
+
+        fetched = await service.get_email_by_id(email["id"])
+        forensic = await service.get_email_by_id(email["id"], include_raw=True)
+
+        assert "open.gif" not in fetched["body"]
+        assert "raw_body" not in fetched
+        assert forensic["raw_body"] == email["body"]
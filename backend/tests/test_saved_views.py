@@ -0,0 +1,266 @@
+"""Tests for stored-email filters and saved views."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import emails, views
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_email_service
+from backend.core.email_filters import build_filter_clause, parse_received_bound, validate_email_filters
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.saved_view import SavedViewCreate, SavedViewUpdate
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.saved_view_service import SavedViewService, get_saved_view_service
+
+USER_ID = 1
+
+
+def make_email(email_id, sender, received_time, folder="Inbox"):
+    """Build a provider email dict."""
+    return {
+        "id": email_id,
+        "subject": f"Subject {email_id}",
+        "sender": sender,
+        "body": "Hello",
+        "received_time": received_time,
+        "folder": folder,
+    }
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+async def email_service(store):
+    """Email service with a handful of stored, classified emails."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    service = EmailService(provider, db=store)
+    for email, category in [
+        (make_email("a", "boss@corp.com", "2024-01-01T09:00:00"), "required_personal_action"),
+        (make_email("b", "news@list.com", "2024-01-02T09:00:00"), "newsletter"),
+        (make_email("c", "boss@corp.com", "2024-01-03T09:00:00"), "required_personal_action"),
+        (make_email("d", "100%_off@shop.com", "2024-01-04T09:00:00", "Promotions"), "spam_to_delete"),
+    ]:
+        await service.save_classification(email, EmailClassification(category=category, confidence=0.9), USER_ID)
+    with store.get_connection() as conn:
+        conn.execute("UPDATE emails SET is_read = 1 WHERE id IN ('b', 'c')")
+        conn.execute("UPDATE emails SET is_flagged = 1 WHERE id = 'c'")
+        conn.commit()
+    return service
+
+
+@pytest.fixture
+def view_service(store):
+    """Saved view service over the in-memory store."""
+    with store.get_connection() as conn:
+        conn.execute(
+            "INSERT INTO users (id, username, email, hashed_password) VALUES (?, ?, ?, ?)",
+            (USER_ID, "viewer", "viewer@example.com", "x")
+        )
+        conn.commit()
+    return SavedViewService(db=store)
+
+
+class TestEmailFilters:
+    """Tests for filter validation and SQL generation."""
+
+    @pytest.mark.parametrize("filters", [
+        {"label": "x"},
+        {"category; DROP TABLE emails": "x"},
+        {"unread": "true"},
+        {"category": 5},
+        {"flagged": 1},
+        {"sender": "   "},
+        {"received_after": "last tuesday"},
+        {"sender": "x" * 201},
+    ])
+    def test_rejects(self, filters):
+        """Test that unknown names, wrong types, and bad values are rejected."""
+        with pytest.raises(ValueError):
+            validate_email_filters(filters)
+
+    def test_drops_unset_and_strips(self):
+        """Test that None filters are dropped and strings are stripped."""
+        assert validate_email_filters({"category": " newsletter ", "unread": None}) == {"category": "newsletter"}
+
+    def test_parse_received_bound(self):
+        """Test ISO and relative bounds."""
+        now = datetime(2024, 1, 10, 12, 0)
+
+        assert parse_received_bound("7d", now) == datetime(2024, 1, 3, 12, 0)
+        assert parse_received_bound("2w", now) == datetime(2023, 12, 27, 12, 0)
+        assert parse_received_bound("2024-01-05", now) == datetime(2024, 1, 5)
+
+    def test_values_are_parameters(self):
+        """Test that filter values never become SQL text."""
+        clause, params = build_filter_clause({"category": "x' OR '1'='1", "unread": True})
+
+        assert "OR" not in clause
+        assert params == ["x' OR '1'='1", 0]
+
+    def test_validate_sort(self):
+        """Test that views reject unknown sorts."""
+        with pytest.raises(ValueError):
+            SavedViewCreate(name="Bad", sort="id; DROP TABLE emails")
+
+
+class TestSearchStoredEmails:
+    """Tests for EmailService.search_stored_emails."""
+
+    @pytest.mark.asyncio
+    async def test_filters_and_sort(self, email_service):
+        """Test combining filters and sorting."""
+        emails, total = await email_service.search_stored_emails(
+            USER_ID, {"category": "required_personal_action"}, sort="received_asc"
+        )
+
+        assert total == 2
+        assert [email["id"] for email in emails] == ["a", "c"]
+
+        emails, _ = await email_service.search_stored_emails(USER_ID, {"unread": True})
+        assert [email["id"] for email in emails] == ["d", "a"]
+
+        emails, _ = await email_service.search_stored_emails(USER_ID, {"flagged": True, "unread": False})
+        assert [email["id"] for email in emails] == ["c"]
+
+    @pytest.mark.asyncio
+    async def test_sender_and_dates(self, email_service):
+        """Test sender matching with LIKE wildcards escaped, and date bounds."""
+        emails, _ = await email_service.search_stored_emails(USER_ID, {"sender": "100%_off"})
+        assert [email["id"] for email in emails] == ["d"]
+        _, total = await email_service.search_stored_emails(USER_ID, {"sender": "%"})
+        assert total == 1
+
+        emails, _ = await email_service.search_stored_emails(
+            USER_ID, {"received_after": "2024-01-02", "received_before": "2024-01-04"}
+        )
+        assert [email["id"] for email in emails] == ["c", "b"]
+
+    @pytest.mark.asyncio
+    async def test_bounds_inside_a_day(self, email_service):
+        """Test that bounds with a time compare against ISO received dates as times, not strings."""
+        emails, _ = await email_service.search_stored_emails(USER_ID, {"received_after": "2024-01-02T12:00"})
+        assert [email["id"] for email in emails] == ["d", "c"]
+
+        emails, _ = await email_service.search_stored_emails(
+            USER_ID, {"received_after": "2024-01-02", "received_before": "2024-01-03T12:00"}
+        )
+        assert [email["id"] for email in emails] == ["c", "b"]
+
+    @pytest.mark.asyncio
+    async def test_relative_bound(self, email_service):
+        """Test that a relative bound such as 12h counts back from now to the hour."""
+        clause, params = build_filter_clause({"received_after": "12h"}, now=datetime(2024, 1, 3, 22, 0))
+
+        with email_service.db.get_connection() as conn:
+            rows = conn.execute(
+                f"SELECT id FROM emails WHERE user_id = ?{clause} ORDER BY id", [USER_ID, *params]
+            ).fetchall()
+        assert [row["id"] for row in rows] == ["d"]
+
+    @pytest.mark.asyncio
+    async def test_paging(self, email_service):
+        """Test limit and offset with the total count."""
+        emails, total = await email_service.search_stored_emails(USER_ID, {"folder": "Inbox"}, limit=2, offset=1)
+
+        assert total == 3
+        assert [email["id"] for email in emails] == ["b", "a"]
+
+
+class TestSavedViewService:
+    """Tests for saved view CRUD."""
+
+    @pytest.mark.asyncio
+    async def test_crud(self, view_service):
+        """Test creating, listing, updating, and deleting views."""
+        created = await view_service.create_view(
+            SavedViewCreate(name="Unread action", filters={"unread": True, "category": "required_personal_action"}),
+            USER_ID
+        )
+        await view_service.create_view(SavedViewCreate(name="all mail", icon="inbox"), USER_ID)
+
+        fetched = await view_service.get_view(created.id, USER_ID)
+        assert fetched.filters == {"unread": True, "category": "required_personal_action"}
+        assert fetched.sort == "received_desc"
+        assert [view.name for view in await view_service.list_views(USER_ID)] == ["all mail", "Unread action"]
+        assert await view_service.get_view(created.id, 2) is None
+
+        updated = await view_service.update_view(
+            created.id, SavedViewUpdate(filters={"flagged": True}, sort="sender"), USER_ID
+        )
+        assert updated.filters == {"flagged": True}
+        assert updated.sort == "sender"
+        assert updated.name == "Unread action"
+
+        assert await view_service.delete_view(created.id, USER_ID) is True
+        assert await view_service.delete_view(created.id, USER_ID) is False
+        assert await view_service.update_view(created.id, SavedViewUpdate(), USER_ID) is None
+
+
+class TestSavedViewAPI:
+    """Tests for the /views endpoints."""
+
+    @pytest.fixture
+    def client(self, email_service, view_service):
+        """Client with auth, email service, and view store overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(emails.router, prefix="/api")
+        app.include_router(views.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="viewer", email="viewer@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: email_service
+        app.dependency_overrides[get_saved_view_service] = lambda: view_service
+        return TestClient(app)
+
+    @pytest.mark.parametrize("filters,sort,query", [
+        ({"category": "required_personal_action"}, "received_asc", "category=required_personal_action&sort=received_asc"),
+        ({"unread": True, "folder": "Inbox"}, "received_desc", "unread=true&folder=Inbox"),
+        ({"sender": "boss", "received_after": "2024-01-02"}, "subject", "sender=boss&received_after=2024-01-02&sort=subject"),
+    ])
+    def test_view_matches_raw_endpoint(self, client, filters, sort, query):
+        """Test that running a view returns what GET /emails returns for its params."""
+        view_id = client.post(
+            "/api/views", json={"name": "View", "filters": filters, "sort": sort}
+        ).json()["id"]
+
+        from_view = client.get(f"/api/views/{view_id}/emails?limit=10")
+        raw = client.get(f"/api/emails?{query}&limit=10")
+
+        assert from_view.status_code == 200
+        assert from_view.json() == raw.json()
+        assert from_view.json()["total"] > 0
+
+    def test_crud_and_validation(self, client):
+        """Test the CRUD endpoints and that bad filters are rejected on save."""
+        assert client.post("/api/views", json={"name": "Bad", "filters": {"label": "x"}}).status_code == 422
+        assert client.post("/api/views", json={"name": "Bad", "filters": {"unread": "1 OR 1=1"}}).status_code == 422
+        assert client.post("/api/views", json={"name": "Bad", "sort": "received_date; --"}).status_code == 422
+
+        response = client.post("/api/views", json={"name": "Flagged", "filters": {"flagged": True}, "icon": "flag"})
+        assert response.status_code == 201
+        view_id = response.json()["id"]
+
+        assert client.get(f"/api/views/{view_id}").json()["icon"] == "flag"
+        assert len(client.get("/api/views").json()) == 1
+        assert client.put(f"/api/views/{view_id}", json={"name": "Starred"}).json()["name"] == "Starred"
+        assert client.get(f"/api/views/{view_id}/emails").json()["total"] == 1
+
+        assert client.delete(f"/api/views/{view_id}").status_code == 200
+        assert client.get(f"/api/views/{view_id}").status_code == 404
+        assert client.get(f"/api/views/{view_id}/emails").status_code == 404
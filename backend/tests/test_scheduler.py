@@ -0,0 +1,258 @@
+"""Tests for the periodic background job scheduler."""
+
+import asyncio
+import threading
+import time
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.admin import router
+from backend.api.auth import get_current_user
+from backend.core.errors import ConflictError, NotFoundError, register_error_handlers
+from backend.models.user import UserInDB
+from backend.services.scheduler import JobScheduler, ScheduledJob, get_job_scheduler
+
+
+def counting_job(name, calls, interval=3600.0, error=None):
+    """Job appending to calls on each pass, then raising error if given."""
+    async def run():
+        calls.append(name)
+        if error:
+            raise error
+    return ScheduledJob(name, run, interval)
+
+
+class TestJobScheduler:
+    """Tests for JobScheduler."""
+
+    def test_overlapping_pass_skipped(self):
+        """Test that a pass due while the previous one runs is skipped, not run alongside."""
+        scheduler = JobScheduler()
+        release = asyncio.Event()
+        calls = []
+
+        async def slow():
+            calls.append("slow")
+            await release.wait()
+
+        scheduler.add(ScheduledJob("slow", slow, 3600))
+
+        async def scenario():
+            first = asyncio.create_task(scheduler.run_job("slow"))
+            await asyncio.sleep(0)
+            await asyncio.sleep(0)
+            second = await scheduler.run_job("slow")
+            running = scheduler.status("slow").running
+            release.set()
+            return await first, second, running
+
+        assert asyncio.run(scenario()) == (True, False, True)
+        status = scheduler.status("slow")
+        assert calls == ["slow"]
+        assert (status.run_count, status.skipped_count, status.running) == (1, 1, False)
+
+    def test_manual_run_refused_while_running(self):
+        """Test that triggering a running job is a conflict carrying its status."""
+        scheduler = JobScheduler()
+        release = asyncio.Event()
+
+        async def slow():
+            await release.wait()
+
+        scheduler.add(ScheduledJob("slow", slow, 3600))
+
+        async def scenario():
+            assert scheduler.trigger("slow").running is True
+            with pytest.raises(ConflictError) as excinfo:
+                scheduler.trigger("slow")
+            release.set()
+            await scheduler.stop()
+            return excinfo.value
+
+        error = asyncio.run(scenario())
+        assert error.current["name"] == "slow"
+        assert error.current["running"] is True
+
+    def test_failing_job_isolated(self):
+        """Test that a job that raises keeps its schedule and does not stop the others."""
+        scheduler = JobScheduler()
+        calls = []
+        scheduler.add(counting_job("broken", calls, interval=0.01, error=RuntimeError("boom")))
+        scheduler.add(counting_job("healthy", calls, interval=0.01))
+
+        async def scenario():
+            scheduler.start()
+            await asyncio.sleep(0.1)
+            statuses = {status.name: status for status in scheduler.statuses()}
+            await scheduler.stop()
+            return statuses
+
+        statuses = asyncio.run(scenario())
+        assert statuses["broken"].failure_count >= 2
+        assert statuses["broken"].last_error == "RuntimeError: boom"
+        assert statuses["healthy"].run_count >= 2
+        assert statuses["healthy"].failure_count == 0
+        assert statuses["healthy"].last_error is None
+        assert statuses["healthy"].next_run_at is not None
+
+    def test_success_clears_last_error(self):
+        """Test that last_error describes only the latest pass."""
+        scheduler = JobScheduler()
+        outcomes = [ValueError("bad input"), None]
+
+        async def flaky():
+            error = outcomes.pop(0)
+            if error:
+                raise error
+
+        scheduler.add(ScheduledJob("flaky", flaky, 3600))
+
+        async def scenario():
+            await scheduler.run_job("flaky")
+            failed = scheduler.status("flaky")
+            await scheduler.run_job("flaky")
+            return failed, scheduler.status("flaky")
+
+        failed, recovered = asyncio.run(scenario())
+        assert failed.last_error == "ValueError: bad input"
+        assert recovered.last_error is None
+        assert (recovered.run_count, recovered.failure_count) == (2, 1)
+        assert recovered.last_duration_ms >= 0
+
+    def test_stop_waits_for_running_pass(self):
+        """Test that shutdown lets a running pass finish within the grace period."""
+        scheduler = JobScheduler()
+        finished = []
+
+        async def short():
+            await asyncio.sleep(0.05)
+            finished.append(True)
+
+        scheduler.add(ScheduledJob("short", short, 3600))
+
+        async def scenario():
+            scheduler.start()
+            await asyncio.sleep(0.01)
+            await scheduler.stop(grace_seconds=1)
+
+        asyncio.run(scenario())
+        assert finished == [True]
+        assert scheduler.statuses() == []
+
+    def test_stop_cancels_after_grace(self):
+        """Test that a pass still running after the grace period is cancelled."""
+        scheduler = JobScheduler()
+        cancelled = []
+
+        async def stuck():
+            try:
+                await asyncio.sleep(3600)
+            except asyncio.CancelledError:
+                cancelled.append(True)
+                raise
+
+        scheduler.add(ScheduledJob("stuck", stuck, 3600))
+
+        async def scenario():
+            scheduler.start()
+            await asyncio.sleep(0.01)
+            await scheduler.stop(grace_seconds=0.01)
+
+        asyncio.run(scenario())
+        assert cancelled == [True]
+
+    def test_registration_checks(self):
+        """Test that duplicate names, bad intervals, and unknown jobs are rejected."""
+        scheduler = JobScheduler()
+        scheduler.add(counting_job("once", []))
+
+        with pytest.raises(ValueError):
+            scheduler.add(counting_job("once", []))
+        with pytest.raises(ValueError):
+            scheduler.add(counting_job("never", [], interval=0))
+        with pytest.raises(NotFoundError):
+            scheduler.status("missing")
+
+
+class TestJobsAPI:
+    """Tests for GET /api/admin/jobs and POST /api/admin/jobs/{name}/run."""
+
+    @pytest.fixture
+    def scheduler(self):
+        """Scheduler with one quick job and one held until released."""
+        scheduler = JobScheduler()
+        self.release = threading.Event()
+
+        async def held():
+            while not self.release.is_set():
+                await asyncio.sleep(0.01)
+
+        scheduler.add(counting_job("quick", [], interval=60))
+        scheduler.add(ScheduledJob("held", held, 60, description="Waits to be released"))
+        return scheduler
+
+    @pytest.fixture
+    def client(self, scheduler):
+        """Client with auth and the scheduler overridden, kept on one event loop."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="operator", email="operator@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_job_scheduler] = lambda: scheduler
+        with TestClient(app) as client:
+            yield client
+        self.release.set()
+
+    def job(self, client, name):
+        """A job's status from the listing."""
+        return next(job for job in client.get("/api/admin/jobs").json()["jobs"] if job["name"] == name)
+
+    def wait_for(self, client, name, run_count):
+        """Poll until a job has finished run_count passes."""
+        for _ in range(100):
+            job = self.job(client, name)
+            if job["run_count"] >= run_count:
+                return job
+            time.sleep(0.01)
+        raise AssertionError(f"{name} did not finish")
+
+    def test_list_jobs(self, client):
+        """Test that every job is listed, by name, before it has run."""
+        response = client.get("/api/admin/jobs")
+
+        assert response.status_code == 200
+        jobs = response.json()["jobs"]
+        assert [job["name"] for job in jobs] == ["held", "quick"]
+        assert jobs[0]["description"] == "Waits to be released"
+        assert jobs[1]["run_count"] == 0
+        assert jobs[1]["last_started_at"] is None
+
+    def test_run_job(self, client):
+        """Test that a manual run starts in the background and is recorded."""
+        response = client.post("/api/admin/jobs/quick/run")
+
+        assert response.status_code == 202
+        job = self.wait_for(client, "quick", 1)
+        assert job["last_finished_at"] is not None
+        assert job["last_error"] is None
+
+    def test_run_running_job(self, client):
+        """Test that a job already running is a 409."""
+        assert client.post("/api/admin/jobs/held/run").status_code == 202
+
+        response = client.post("/api/admin/jobs/held/run")
+
+        assert response.status_code == 409
+        assert response.json()["error"]["code"] == "conflict"
+        self.release.set()
+        self.wait_for(client, "held", 1)
+
+    def test_unknown_job(self, client):
+        """Test that running a job that does not exist is a 404."""
+        assert client.post("/api/admin/jobs/backups/run").status_code == 404
@@ -0,0 +1,250 @@
+"""Tests for generating email search filters from natural language."""
+
+import asyncio
+import re
+from datetime import date, datetime
+from pathlib import Path
+from unittest.mock import AsyncMock
+
+import pytest
+import yaml
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import ai as ai_api
+from backend.api import emails as emails_api
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.search_query import (
+    SEARCH_QUERY_FIELDS, SEARCH_QUERY_TEMPLATE, build_search_query, build_search_query_inputs,
+    parse_search_query, validate_search_filters
+)
+
+USER_ID = 1
+CATEGORIES = ["fyi", "team_action", "newsletter"]
+REQUEST = "mails from dana about the reorg last month"
+PROMPT_PATH = Path(__file__).parent.parent.parent / "prompts" / SEARCH_QUERY_TEMPLATE
+
+
+class TestSearchQueryPrompt:
+    """Tests for the search_query template."""
+
+    def test_inputs_and_outputs_match_template(self):
+        """Test that the declared inputs, placeholders, built inputs, and outputs agree."""
+        _, frontmatter, body = PROMPT_PATH.read_text(encoding="utf-8").split("---", 2)
+        frontmatter = yaml.safe_load(frontmatter)
+        placeholders = set(re.findall(r"\{\{\s*(\w+)\s*\}\}", body))
+
+        inputs = build_search_query_inputs(REQUEST, CATEGORIES, date(2024, 6, 12))
+
+        assert placeholders == set(frontmatter["inputs"]) == set(inputs)
+        assert tuple(frontmatter["outputs"]) == SEARCH_QUERY_FIELDS
+        assert inputs["today"] == "2024-06-12 (Wednesday)"
+        assert inputs["categories"] == "- fyi\n- team_action\n- newsletter"
+
+
+class TestParseAndValidate:
+    """Tests for parse_search_query and validate_search_filters."""
+
+    def test_fenced_json(self):
+        """Test that fenced JSON output parses."""
+        assert parse_search_query('```json\n{"q": "reorg", "sender": null}\n```') == {"q": "reorg", "sender": None}
+
+    @pytest.mark.parametrize("raw", ["Sure! Here are your filters.", "[1, 2]", 42, '{"q": "reorg"'])
+    def test_garbage_is_rejected(self, raw):
+        """Test that output that is not a JSON object raises."""
+        with pytest.raises(ValueError):
+            parse_search_query(raw)
+
+    def test_valid_filters_kept(self):
+        """Test that well-formed values pass, with categories matched case-insensitively."""
+        filters, dropped = validate_search_filters({
+            "q": " reorg ", "sender": "dana", "category": "FYI",
+            "received_after": "2024-05-01", "received_before": "2024-06-01",
+        }, CATEGORIES)
+
+        assert filters == {
+            "q": "reorg", "sender": "dana", "category": "fyi",
+            "received_after": "2024-05-01", "received_before": "2024-06-01",
+        }
+        assert dropped == []
+
+    def test_invalid_values_dropped(self):
+        """Test that unknown names, made-up categories, bad dates, and non-strings are dropped."""
+        filters, dropped = validate_search_filters({
+            "q": "reorg", "sender": 12, "category": "reorg_mail", "received_after": "last spring",
+            "received_before": "", "sql": "1=1; DROP TABLE emails", "folder": None,
+        }, CATEGORIES)
+
+        assert filters == {"q": "reorg"}
+        assert sorted(dropped) == ["category", "received_after", "sender", "sql"]
+
+    def test_reversed_range_dropped(self):
+        """Test that a range ending before it starts is dropped whole."""
+        filters, dropped = validate_search_filters(
+            {"sender": "dana", "received_after": "2024-06-01", "received_before": "2024-05-01T00:00:00Z"},
+            CATEGORIES
+        )
+
+        assert filters == {"sender": "dana"}
+        assert dropped == ["received_after", "received_before"]
+
+    def test_overlong_value_dropped(self):
+        """Test that values longer than search accepts are dropped."""
+        assert validate_search_filters({"q": "x" * 201}, CATEGORIES) == ({}, ["q"])
+
+
+def stub_ai(**result):
+    """AI service whose generate_search_query returns result."""
+    ai = AsyncMock()
+    ai.generate_search_query = AsyncMock(return_value=result)
+    return ai
+
+
+class TestBuildSearchQuery:
+    """Tests for build_search_query fallbacks."""
+
+    def build(self, ai):
+        return asyncio.run(build_search_query(ai, REQUEST, CATEGORIES, date(2024, 6, 12)))
+
+    def test_valid_output(self):
+        """Test that valid model filters are returned without a fallback."""
+        filters, fallback, dropped = self.build(stub_ai(filters={"q": "reorg", "sender": "dana"}))
+
+        assert (filters.q, filters.sender, fallback, dropped) == ("reorg", "dana", False, [])
+
+    @pytest.mark.parametrize("ai", [
+        stub_ai(error="Search query builder returned invalid JSON: Expecting value"),
+        stub_ai(filters={"category": "made_up", "received_after": "someday"}),
+        stub_ai(filters={}),
+        stub_ai(filters="not a dict"),
+        AsyncMock(generate_search_query=AsyncMock(side_effect=RuntimeError("AI dependencies not available"))),
+    ])
+    def test_unusable_output_falls_back_to_free_text(self, ai):
+        """Test that errors, garbage, and empty output search the request as plain text."""
+        filters, fallback, _ = self.build(ai)
+
+        assert fallback is True
+        assert filters.model_dump(exclude_none=True) == {"q": REQUEST}
+
+
+@pytest.fixture
+def service():
+    """Email service over an in-memory store with two emails from Dana and one from Lee."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        conn.executemany(
+            "INSERT INTO emails (id, subject, sender, content, category, received_date, user_id) "
+            "VALUES (?, ?, ?, ?, 'fyi', ?, ?)",
+            [
+                ("may-reorg", "Reorg update", "dana@example.com", "New team structure", datetime(2024, 5, 20), USER_ID),
+                ("june-reorg", "Reorg follow-up", "dana@example.com", "Questions", datetime(2024, 6, 3), USER_ID),
+                ("lee-reorg", "Reorg thoughts", "lee@example.com", "My take", datetime(2024, 5, 21), USER_ID),
+            ]
+        )
+        conn.commit()
+    yield EmailService(MockEmailProvider(), db=db)
+    db.close()
+
+
+@pytest.fixture
+def ai():
+    """AI service stub, valid output unless a test changes it."""
+    return stub_ai(filters={
+        "q": "reorg", "sender": "dana", "received_after": "2024-05-01", "received_before": "2024-06-01",
+        "category": None,
+    })
+
+
+@pytest.fixture
+def client(service, ai):
+    """Client for the AI and email endpoints with auth, AI, and the email service overridden."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(ai_api.router, prefix="/api")
+    app.include_router(emails_api.router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="sam", email="sam@example.com", hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: service
+    app.dependency_overrides[get_ai_service] = lambda: ai
+    ai_api.ai_rate_limiter.reset()
+    yield TestClient(app)
+    ai_api.ai_rate_limiter.reset()
+
+
+class TestSearchQueryAPI:
+    """Tests for POST /api/ai/search-query."""
+
+    def test_returns_filters_for_search_endpoint(self, client):
+        """Test that the filters are accepted by GET /api/emails/search as they are."""
+        data = client.post("/api/ai/search-query", json={"request": REQUEST}).json()
+
+        assert data["filters"] == {
+            "q": "reorg", "sender": "dana", "category": None,
+            "received_after": "2024-05-01", "received_before": "2024-06-01",
+        }
+        assert (data["fallback"], data["emails"], data["total"]) == (False, None, None)
+        params = {name: value for name, value in data["filters"].items() if value is not None}
+        found = client.get("/api/emails/search", params=params).json()["emails"]
+        assert [email["id"] for email in found] == ["may-reorg"]
+
+    def test_execute_returns_results(self, client):
+        """Test that execute=true runs the search inline."""
+        data = client.post("/api/ai/search-query", json={"request": REQUEST, "execute": True}).json()
+
+        assert data["total"] == 1
+        assert [email["id"] for email in data["emails"]] == ["may-reorg"]
+
+    def test_prompt_gets_categories_and_today(self, client, ai):
+        """Test that the model is asked with the stored categories and a date."""
+        client.post("/api/ai/search-query", json={"request": REQUEST})
+
+        kwargs = ai.generate_search_query.call_args.kwargs
+        assert kwargs["request"] == REQUEST
+        assert "fyi" in kwargs["categories"]
+        assert isinstance(kwargs["today"], date)
+
+    def test_garbage_output_degrades_to_free_text(self, client, ai):
+        """Test that unparseable output is a plain text search, not an error."""
+        ai.generate_search_query.return_value = {"error": "Search query builder returned invalid JSON"}
+
+        response = client.post("/api/ai/search-query", json={"request": "reorg", "execute": True})
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["fallback"] is True and data["filters"]["q"] == "reorg"
+        assert {email["id"] for email in data["emails"]} == {"may-reorg", "june-reorg", "lee-reorg"}
+
+    def test_invalid_fields_reported(self, client, ai):
+        """Test that dropped filters are listed while the valid ones are used."""
+        ai.generate_search_query.return_value = {"filters": {"sender": "lee", "category": "reorg_news"}}
+
+        data = client.post("/api/ai/search-query", json={"request": "anything lee sent", "execute": True}).json()
+
+        assert (data["fallback"], data["dropped"]) == (False, ["category"])
+        assert [email["id"] for email in data["emails"]] == ["lee-reorg"]
+
+    @pytest.mark.parametrize("request_text", ["", "   ", "x" * 201])
+    def test_invalid_request(self, client, request_text):
+        """Test that blank and overlong requests are rejected."""
+        assert client.post("/api/ai/search-query", json={"request": request_text}).status_code == 422
+
+
+class TestSearchEndpointFilters:
+    """Tests for the filters GET /api/emails/search gained for generated queries."""
+
+    def test_filters_without_text(self, client):
+        """Test that a sender filter alone is a valid search."""
+        found = client.get("/api/emails/search", params={"sender": "lee"}).json()["emails"]
+
+        assert [email["id"] for email in found] == ["lee-reorg"]
+
+    def test_needs_text_or_filter(self, client):
+        """Test that a search without q or any filter is still rejected."""
+        assert client.get("/api/emails/search").status_code == 422
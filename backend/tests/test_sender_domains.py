@@ -0,0 +1,271 @@
+"""Tests for sender domain analytics and bulk domain classification."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import ExcludedFolderError, register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.category_service import CategoryService, get_category_service
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.sender_domains import (
+    add_domain_rule, domain_rule_text, from_domain, normalize_domain, sender_domain, summarize_domains
+)
+
+USER_ID = 1
+
+# ID -> (sender, is_read, category, ai_category, received day in January 2024)
+EMAILS = {
+    "c1": ("Contoso News <news@contoso.com>", 0, None, "newsletter", 5),
+    "c2": ("deals+weekly@mail.contoso.com", 0, None, "newsletter", 6),
+    "c3": ("alerts@eu.mail.contoso.com", 1, "spam_to_delete", "spam_to_delete", 4),
+    "c4": ("Ann <ann@contoso.com>", 1, "fyi", "fyi", 3),
+    "f1": ("bob@fabrikam.co.uk", 0, None, "fyi", 2),
+    "f2": ("carol@news.fabrikam.co.uk", 1, "spam_to_delete", "newsletter", 1),
+    "x1": ("Display Name Only", 0, None, None, 1),
+}
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock mailbox holding EMAILS."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    provider.mock_emails = [
+        {"id": email_id, "subject": email_id, "sender": sender, "folder": "Inbox", "categories": []}
+        for email_id, (sender, *_) in EMAILS.items()
+    ]
+    return provider
+
+
+@pytest.fixture
+def service(provider):
+    """Email service over an isolated store seeded with EMAILS in the inbox."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with store.get_connection() as conn:
+        conn.executemany(
+            """
+            INSERT INTO emails (id, subject, sender, is_read, category, ai_category, received_date, folder, user_id)
+            VALUES (?, ?, ?, ?, ?, ?, ?, 'Inbox', ?)
+            """,
+            [
+                (email_id, email_id, sender, is_read, category, ai_category, datetime(2024, 1, day), USER_ID)
+                for email_id, (sender, is_read, category, ai_category, day) in EMAILS.items()
+            ]
+        )
+        conn.commit()
+    yield EmailService(provider, db=store)
+    store.close()
+
+
+def stored(service, email_id, column):
+    """A column of an email's stored row."""
+    with service.db.get_connection() as conn:
+        return conn.execute(f"SELECT {column} FROM emails WHERE id = ?", (email_id,)).fetchone()[0]
+
+
+class TestDomainExtraction:
+    """Tests for grouping senders by domain."""
+
+    @pytest.mark.parametrize("sender, domain", [
+        ("news@contoso.com", "contoso.com"),
+        ("Contoso <NEWS@Mail.Contoso.COM>", "contoso.com"),
+        ("deals+weekly@eu.mail.contoso.com", "contoso.com"),
+        ("bob@news.fabrikam.co.uk", "fabrikam.co.uk"),
+        ("root@localhost", "localhost"),
+        ("Display Name Only", None),
+        (None, None),
+    ])
+    def test_sender_domain(self, sender, domain):
+        """Test that subdomains, case, display names, and plus-addressing are handled."""
+        assert sender_domain(sender) == domain
+
+    def test_from_domain(self):
+        """Test that a domain matches its subdomains but not lookalikes."""
+        assert from_domain("a+b@mail.contoso.com", "contoso.com")
+        assert from_domain("a@contoso.com", "@Contoso.com")
+        assert from_domain("a@mail.contoso.com", "mail.contoso.com")
+        assert not from_domain("a@contoso.com", "mail.contoso.com")
+        assert not from_domain("a@notcontoso.com", "contoso.com")
+        assert normalize_domain(" @Contoso.com. ") == "contoso.com"
+
+
+class TestDomainAnalytics:
+    """Tests for per-domain totals over seeded rows."""
+
+    def test_summarize_domains(self):
+        """Test totals, unread, unclassified, dominant AI category, and spam ratio."""
+        rows = [
+            {"sender": sender, "is_read": is_read, "category": category, "ai_category": ai_category}
+            for sender, is_read, category, ai_category, _ in EMAILS.values()
+        ]
+
+        contoso, fabrikam = summarize_domains(rows)
+
+        assert (contoso.domain, contoso.total, contoso.unread, contoso.unclassified) == ("contoso.com", 4, 2, 2)
+        assert (contoso.dominant_category, contoso.spam_ratio) == ("newsletter", 0.25)
+        assert (fabrikam.domain, fabrikam.total, fabrikam.unread) == ("fabrikam.co.uk", 2, 1)
+        assert (fabrikam.dominant_category, fabrikam.spam_ratio) == ("fyi", 0.5)
+
+    @pytest.mark.asyncio
+    async def test_report_limit(self, service):
+        """Test that the report is limited but counts every domain."""
+        report = await service.get_sender_domains(USER_ID, limit=1)
+
+        assert [entry.domain for entry in report.domains] == ["contoso.com"]
+        assert report.total == 2
+
+
+class TestClassifyDomain:
+    """Tests for EmailService.classify_domain."""
+
+    @pytest.mark.asyncio
+    async def test_dry_run(self, service):
+        """Test that a dry run lists the unclassified emails and changes nothing."""
+        outcome = await service.classify_domain(USER_ID, "contoso.com", "newsletter", dry_run=True)
+
+        assert outcome.candidates == ["c2", "c1"]
+        assert outcome.classified == []
+        assert stored(service, "c1", "category") is None
+
+    @pytest.mark.asyncio
+    async def test_database_only(self, service):
+        """Test that only unclassified emails are corrected, with history."""
+        outcome = await service.classify_domain(USER_ID, "contoso.com", "newsletter")
+
+        assert outcome.classified == ["c2", "c1"]
+        assert outcome.batch_id is None
+        assert stored(service, "c1", "category") == "newsletter"
+        assert stored(service, "c4", "category") == "fyi"
+        assert stored(service, "f1", "category") is None
+        with service.db.get_connection() as conn:
+            sources = {row[0] for row in conn.execute("SELECT source FROM classification_history")}
+        assert sources == {"domain"}
+
+    @pytest.mark.asyncio
+    async def test_outlook_move_batch_reverts(self, service):
+        """Test that filing in Outlook moves the emails as a batch that reverts to the inbox."""
+        outcome = await service.classify_domain(USER_ID, "contoso.com", "newsletter", apply_to_outlook=True)
+
+        assert outcome.destination_folder == "Newsletters"
+        assert outcome.failed == []
+        assert stored(service, "c2", "folder") == "Newsletters"
+
+        reverted = await service.revert_move_batch(outcome.batch_id, USER_ID)
+
+        assert sorted(reverted.restored) == ["c1", "c2"]
+        assert stored(service, "c2", "folder") == "Inbox"
+
+    @pytest.mark.asyncio
+    async def test_outlook_category_without_folder(self, service, provider):
+        """Test that a category without a folder is applied as a mail client category."""
+        with service.db.get_connection() as conn:
+            conn.execute("UPDATE categories SET folder = NULL WHERE name = 'newsletter'")
+            conn.commit()
+
+        outcome = await service.classify_domain(USER_ID, "fabrikam.co.uk", "newsletter", apply_to_outlook=True)
+
+        assert (outcome.classified, outcome.batch_id) == (["f1"], None)
+        assert next(email for email in provider.mock_emails if email["id"] == "f1")["categories"] == ["newsletter"]
+
+    @pytest.mark.asyncio
+    async def test_excluded_folder_refused(self, service, monkeypatch):
+        """Test that a category filed into an excluded folder is refused before anything changes."""
+        monkeypatch.setattr(settings, "excluded_folders", ["Newsletters"])
+
+        with pytest.raises(ExcludedFolderError):
+            await service.classify_domain(USER_ID, "contoso.com", "newsletter", apply_to_outlook=True)
+        assert stored(service, "c1", "category") is None
+
+
+class TestDomainRule:
+    """Tests for adding the domain's classification rule."""
+
+    @pytest.mark.asyncio
+    async def test_rule_added_once(self, service):
+        """Test that the rule line is appended to the category's rules only once."""
+        categories = CategoryService(db=service.db)
+
+        assert await add_domain_rule(categories, "newsletter", "contoso.com") is True
+        assert await add_domain_rule(categories, "newsletter", "contoso.com") is False
+
+        description = (await categories.get_category("newsletter")).description
+        assert description.splitlines().count(domain_rule_text("contoso.com")) == 1
+
+    @pytest.mark.asyncio
+    async def test_unknown_category(self, service):
+        """Test that a missing category raises LookupError."""
+        with pytest.raises(LookupError):
+            await add_domain_rule(CategoryService(db=service.db), "projects", "contoso.com")
+
+
+class TestDomainAPI:
+    """Tests for the /emails/domains endpoints."""
+
+    @pytest.fixture
+    def client(self, service):
+        """Client with auth and the email and category services overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="domains", email="domains@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        app.dependency_overrides[get_category_service] = lambda: CategoryService(db=service.db)
+        return TestClient(app)
+
+    def test_domains_endpoint(self, client):
+        """Test that GET /emails/domains lists domains largest first."""
+        data = client.get("/api/emails/domains").json()
+
+        assert [entry["domain"] for entry in data["domains"]] == ["contoso.com", "fabrikam.co.uk"]
+        assert data["domains"][0]["spam_ratio"] == 0.25
+
+    def test_apply_category_creates_rule(self, client, service):
+        """Test that create_rule adds the domain's line to the category's rules."""
+        response = client.post(
+            "/api/emails/domains/Contoso.com/apply-category", json={"category": "newsletter", "create_rule": True}
+        )
+
+        assert response.status_code == 200
+        data = response.json()
+        assert (data["domain"], data["success_count"], data["rule_created"]) == ("contoso.com", 2, True)
+        assert data["rule"] == domain_rule_text("contoso.com")
+        with service.db.get_connection() as conn:
+            description = conn.execute("SELECT description FROM categories WHERE name = 'newsletter'").fetchone()[0]
+        assert domain_rule_text("contoso.com") in description
+
+        again = client.post(
+            "/api/emails/domains/contoso.com/apply-category", json={"category": "newsletter", "create_rule": True}
+        ).json()
+        assert (again["candidates"], again["rule_created"]) == ([], False)
+
+    def test_dry_run_adds_no_rule(self, client, service):
+        """Test that a dry run neither classifies nor adds the rule."""
+        data = client.post(
+            "/api/emails/domains/contoso.com/apply-category",
+            json={"category": "newsletter", "create_rule": True, "dry_run": True}
+        ).json()
+
+        assert (data["candidates"], data["rule_created"]) == (["c2", "c1"], False)
+        with service.db.get_connection() as conn:
+            description = conn.execute("SELECT description FROM categories WHERE name = 'newsletter'").fetchone()[0]
+        assert domain_rule_text("contoso.com") not in (description or "")
+
+    @pytest.mark.parametrize("domain, body", [
+        ("contoso.com", {"category": "projects"}),
+        ("localhost", {"category": "newsletter"}),
+    ])
+    def test_invalid_requests(self, client, domain, body):
+        """Test that unknown categories and dotless domains are rejected."""
+        assert client.post(f"/api/emails/domains/{domain}/apply-category", json=body).status_code == 422
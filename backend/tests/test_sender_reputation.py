@@ -0,0 +1,146 @@
+"""Tests for sender reputation scoring."""
+
+import pytest
+from unittest.mock import MagicMock
+
+from backend.database.connection import DatabaseManager
+from backend.services.email_service import EmailService, reputation_cache
+from backend.services.sender_reputation import (
+    ReputationWeights, SenderHistory, TTLCache, score_sender
+)
+
+
+class TestScoreSender:
+    """Tests for the pure scoring function against synthetic histories."""
+
+    def test_no_history_has_no_score(self):
+        """Test that unknown senders are not scored."""
+        reputation = score_sender("new@example.com", SenderHistory())
+
+        assert reputation.score is None
+        assert reputation.email_count == 0
+        assert reputation.components == {}
+
+    def test_all_spam_scores_low(self):
+        """Test that a sender whose mail is all spam scores near zero."""
+        history = SenderHistory(total=20, spam=20, read=0, completed_tasks=0)
+
+        reputation = score_sender("spam@example.com", history)
+
+        assert reputation.components["not_spam"] == 0.0
+        assert reputation.score < 10
+
+    def test_trusted_sender_scores_high(self):
+        """Test that a read, task-producing, high-volume sender scores near 100."""
+        history = SenderHistory(total=50, spam=0, read=50, completed_tasks=50)
+
+        reputation = score_sender("boss@example.com", history)
+
+        assert reputation.score == 100.0
+        assert reputation.components["volume"] == 1.0
+
+    def test_components_are_rates(self):
+        """Test the component breakdown for a mixed history."""
+        history = SenderHistory(total=10, spam=2, read=5, completed_tasks=1)
+
+        components = score_sender("mixed@example.com", history).components
+
+        assert components["not_spam"] == 0.8
+        assert components["task_completion"] == 0.1
+        assert components["engagement"] == 0.5
+        assert 0 < components["volume"] < 1
+
+    def test_weights_change_score(self):
+        """Test that weights select which components matter."""
+        history = SenderHistory(total=10, spam=0, read=0, completed_tasks=0)
+
+        spam_only = score_sender("a@example.com", history, ReputationWeights(1, 0, 0, 0))
+        engagement_only = score_sender("a@example.com", history, ReputationWeights(0, 0, 1, 0))
+
+        assert spam_only.score == 100.0
+        assert engagement_only.score == 0.0
+
+    def test_more_spam_never_scores_higher(self):
+        """Test that the score is monotonic in the spam count."""
+        scores = [
+            score_sender("s@example.com", SenderHistory(total=10, spam=spam, read=5)).score
+            for spam in range(11)
+        ]
+
+        assert scores == sorted(scores, reverse=True)
+
+
+class TestTTLCache:
+    """Tests for the reputation cache."""
+
+    def test_entries_expire(self):
+        """Test that entries are returned until the TTL passes."""
+        cache = TTLCache(ttl_seconds=10)
+        cache.set("key", "value", now=100)
+
+        assert cache.get("key", now=105) == "value"
+        assert cache.get("key", now=110) is None
+
+    def test_zero_ttl_disables_cache(self):
+        """Test that a zero TTL never stores values."""
+        cache = TTLCache(ttl_seconds=0)
+        cache.set("key", "value")
+
+        assert cache.get("key") is None
+
+
+class TestSenderHistory:
+    """Tests for gathering sender history from the database."""
+
+    @pytest.fixture
+    def service(self):
+        """Email service over a store with history for one sender."""
+        reputation_cache.clear()
+        store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        with store.get_connection() as conn:
+            rows = [
+                ("h1", "alice@example.com", "spam_to_delete", 0, 1),
+                ("h2", "alice@example.com", "team_action", 1, 1),
+                ("h3", "alice@example.com", "fyi", 1, 1),
+                ("h4", "alice@example.com", "fyi", 0, 1),
+                ("h5", "bob@example.com", "fyi", 1, 1),
+                ("h6", "alice@example.com", "spam_to_delete", 0, 2),
+            ]
+            conn.executemany(
+                """
+                INSERT INTO emails (id, subject, sender, category, is_read, user_id)
+                VALUES (?, 'Subject', ?, ?, ?, ?)
+                """,
+                rows
+            )
+            conn.executemany(
+                "INSERT INTO tasks (title, status, email_id, user_id) VALUES (?, ?, ?, 1)",
+                [("Done", "completed", "h2"), ("Also done", "completed", "h2"),
+                 ("Open", "pending", "h3")]
+            )
+            conn.commit()
+        yield EmailService(MagicMock(), db=store)
+        store.close()
+        reputation_cache.clear()
+
+    @pytest.mark.asyncio
+    async def test_history_counts(self, service):
+        """Test that history is scoped to the sender and user."""
+        history = await service.get_sender_history("alice@example.com", user_id=1)
+
+        assert history == SenderHistory(total=4, spam=1, read=2, completed_tasks=1)
+
+    @pytest.mark.asyncio
+    async def test_reputation_is_cached(self, service):
+        """Test that repeat lookups are served from the cache."""
+        first = await service.get_sender_reputation("alice@example.com", user_id=1)
+
+        with service.db.get_connection() as conn:
+            conn.execute("UPDATE emails SET category = 'spam_to_delete' WHERE user_id = 1")
+            conn.commit()
+        second = await service.get_sender_reputation("alice@example.com", user_id=1)
+
+        assert second == first
+        reputation_cache.clear()
+        third = await service.get_sender_reputation("alice@example.com", user_id=1)
+        assert third.components["not_spam"] == 0.0
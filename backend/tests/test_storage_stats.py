@@ -0,0 +1,148 @@
+"""Tests for attachment metadata and mailbox storage statistics."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.attachments import attachment_columns, email_attachments
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+USER_ID = 1
+
+
+@pytest.fixture
+def service():
+    """Email service over an authenticated mock provider and an in-memory store."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield EmailService(provider, db=store)
+    store.close()
+
+
+def seed(service):
+    """Store emails from two senders in two folders with varying attachments."""
+    emails = [
+        ("e1", "alice@example.com", "Inbox", [{"name": "deck.pptx", "size": 5000}, {"name": "a.txt", "size": 100}]),
+        ("e2", "alice@example.com", "Projects", [{"name": "plan.pdf", "size": 2000}]),
+        ("e3", "bob@example.com", "Inbox", [{"name": "photo.jpg", "size": 3000}]),
+        ("e4", "bob@example.com", "Inbox", []),
+        ("e5", "carol@example.com", None, None),
+    ]
+
+    async def setup():
+        for email_id, sender, folder, attachments in emails:
+            email = {"id": email_id, "subject": email_id, "sender": sender, "body": "Hi", "folder": folder}
+            if attachments is not None:
+                email["attachments"] = attachments
+            await service.store_email(email, USER_ID)
+        await service.store_email(
+            {"id": "other", "subject": "x", "sender": "alice@example.com", "body": "Hi",
+             "attachments": [{"name": "big.zip", "size": 10 ** 6}]},
+            USER_ID + 1
+        )
+
+    asyncio.run(setup())
+
+
+class TestAttachmentColumns:
+    """Tests for the stored attachment metadata."""
+
+    def test_totals(self):
+        """Test that attachments are counted and sized, bad sizes counting as zero."""
+        email = {"attachments": [{"name": "a.pdf", "size": 10}, {"name": "b", "size": "junk"}, "bad"]}
+
+        metadata, count, size = attachment_columns(email)
+
+        assert email_attachments(email) == [{"name": "a.pdf", "size": 10}, {"name": "b", "size": 0}]
+        assert (count, size) == (2, 10)
+        assert metadata is not None
+
+    def test_provider_without_attachment_list(self):
+        """Test that emails with unknown attachments store NULL metadata."""
+        assert attachment_columns({"id": "x"}) == (None, 0, 0)
+
+    def test_classification_keeps_first_metadata(self, service):
+        """Test that reclassifying an email does not overwrite its attachment totals."""
+        email = {"id": "e1", "subject": "s", "sender": "a@example.com", "body": "Hi",
+                 "attachments": [{"name": "a.pdf", "size": 10}]}
+        classification = EmailClassification(category="fyi", confidence=0.9)
+
+        async def save():
+            await service.save_classification(email, classification, USER_ID)
+            await service.save_classification({**email, "attachments": []}, classification, USER_ID)
+            return await service.get_stored_email("e1", USER_ID)
+
+        stored = asyncio.run(save())
+
+        assert stored["attachments"] == [{"name": "a.pdf", "size": 10}]
+        assert (stored["attachment_count"], stored["attachment_bytes"]) == (1, 10)
+
+
+class TestStorageStats:
+    """Tests for EmailService.get_storage_stats."""
+
+    def test_aggregations(self, service):
+        """Test that totals and per-sender and per-folder usage count only the user's emails."""
+        seed(service)
+
+        stats = asyncio.run(service.get_storage_stats(USER_ID))
+
+        assert (stats.email_count, stats.attachment_count, stats.attachment_bytes) == (5, 4, 10100)
+        assert [(u.name, u.email_count, u.attachment_count, u.attachment_bytes) for u in stats.by_sender] == [
+            ("alice@example.com", 2, 3, 7100), ("bob@example.com", 1, 1, 3000)
+        ]
+        assert [(u.name, u.attachment_bytes) for u in stats.by_folder] == [("Inbox", 8100), ("Projects", 2000)]
+        assert stats.database_bytes == 0
+
+    def test_largest_emails_link_to_detail(self, service):
+        """Test that the largest emails are ordered by size and link to GET /api/emails/{id}."""
+        seed(service)
+
+        largest = asyncio.run(service.get_storage_stats(USER_ID)).largest_emails
+
+        assert [email.id for email in largest] == ["e1", "e3", "e2", "e4", "e5"]
+        assert largest[0].size_bytes > largest[0].attachment_bytes == 5100
+        assert largest[0].url == "/api/emails/e1"
+
+    def test_database_file_size(self, tmp_path):
+        """Test that a file-backed store reports the size of its database file."""
+        store = DatabaseManager(str(tmp_path / "stats.db"))
+        stats = asyncio.run(EmailService(MockEmailProvider(), db=store).get_storage_stats(USER_ID))
+
+        assert stats.database_bytes == (tmp_path / "stats.db").stat().st_size > 0
+
+
+class TestStorageStatsAPI:
+    """Tests for GET /api/emails/storage-stats."""
+
+    def test_endpoint(self, service):
+        """Test that the endpoint returns the typed stats for the current user."""
+        seed(service)
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="purger", email="purger@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+
+        response = TestClient(app).get("/api/emails/storage-stats")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["email_count"] == 5
+        assert data["by_sender"][0]["name"] == "alice@example.com"
+        assert len(data["largest_emails"]) == 5
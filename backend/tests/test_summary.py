@@ -0,0 +1,173 @@
+"""Tests for the tray summary endpoint."""
+
+import asyncio
+from datetime import datetime, timedelta
+from unittest.mock import MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import summary as summary_api
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.email import EmailClassification
+from backend.models.task import TaskCreate, TaskStatus
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.job_queue import job_queue
+from backend.services.task_service import TaskService, get_task_service
+
+USER_ID = 1
+NOW = datetime(2024, 3, 10, 12, 0, 0)
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def email_service(store):
+    """Email service with action, review, and read emails stored."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    service = EmailService(provider, db=store)
+    for email_id, category in [
+        ("action_unread", "required_personal_action"),
+        ("action_read", "required_personal_action"),
+        ("fyi_review", "fyi"),
+        ("newsletter", "newsletter"),
+    ]:
+        asyncio.run(service.save_classification(
+            {"id": email_id, "subject": email_id, "sender": "a@example.com", "body": "Hi"},
+            EmailClassification(category=category, confidence=0.9),
+            USER_ID
+        ))
+    with store.get_connection() as conn:
+        conn.execute("UPDATE emails SET is_read = 1 WHERE id = 'action_read'")
+        conn.execute("UPDATE emails SET needs_review = 1 WHERE id IN ('fyi_review', 'action_unread')")
+        conn.commit()
+    return service
+
+
+@pytest.fixture
+def task_service(store):
+    """Task service with late, due, future, undated, and finished tasks."""
+    service = TaskService(db=store)
+    now = datetime.now()
+    end_of_day = datetime.combine(now.date(), datetime.min.time()) + timedelta(days=1)
+    for title, due_date, status in [
+        ("late", now - timedelta(days=2), TaskStatus.PENDING),
+        ("late in progress", now - timedelta(minutes=5), TaskStatus.IN_PROGRESS),
+        ("due tonight", end_of_day - timedelta(seconds=1), TaskStatus.PENDING),
+        ("next week", now + timedelta(days=7), TaskStatus.PENDING),
+        ("undated", None, TaskStatus.PENDING),
+        ("done late", now - timedelta(days=1), TaskStatus.COMPLETED),
+        ("dropped", now - timedelta(days=1), TaskStatus.CANCELLED),
+    ]:
+        asyncio.run(service.create_task(TaskCreate(title=title, due_date=due_date, status=status), USER_ID))
+    return service
+
+
+@pytest.fixture
+def ai_service():
+    """Stub AI service that initializes."""
+    return MagicMock()
+
+
+@pytest.fixture
+def client(email_service, task_service, ai_service):
+    """Client with auth and every summary dependency overridden."""
+    summary_api.summary_cache.clear()
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(summary_api.router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="tray", email="tray@example.com",
+        hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: email_service
+    app.dependency_overrides[get_task_service] = lambda: task_service
+    app.dependency_overrides[get_ai_service] = lambda: ai_service
+    yield TestClient(app)
+    summary_api.summary_cache.clear()
+
+
+@pytest.fixture
+def pipeline():
+    """A completed pipeline owned by the test user."""
+    pipeline_id = asyncio.run(job_queue.create_pipeline(["action_unread"], str(USER_ID)))
+    pipeline = asyncio.run(job_queue.get_pipeline(pipeline_id))
+    pipeline.status = "completed"
+    pipeline.completed_at = NOW.isoformat()
+    yield pipeline
+    job_queue._pipelines.pop(pipeline_id, None)
+
+
+class TestDueCounts:
+    """Tests for counting late and due tasks."""
+
+    def test_counts_open_tasks(self, task_service):
+        """Test that finished and undated tasks are ignored."""
+        counts = asyncio.run(task_service.get_due_counts(USER_ID))
+
+        assert counts == {"overdue": 2, "due_today": 1}
+
+    def test_other_users(self, task_service):
+        """Test that counts are per user."""
+        assert asyncio.run(task_service.get_due_counts(USER_ID + 1)) == {"overdue": 0, "due_today": 0}
+
+
+class TestSummaryEndpoint:
+    """Tests for GET /api/summary."""
+
+    def test_shape(self, client, pipeline):
+        """Test the exact JSON shape and values."""
+        response = client.get("/api/summary")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert set(data) == {"emails", "tasks", "pipeline", "ai", "generated_at"}
+        as_of = {name: data[name].pop("as_of") for name in ("emails", "tasks", "pipeline", "ai")}
+        assert data["emails"] == {"unread_required_personal_action": 1, "needs_review": 2}
+        assert data["tasks"] == {"overdue": 2, "due_today": 1}
+        assert data["pipeline"] == {
+            "pipeline_id": pipeline.id, "status": "completed", "last_run_at": NOW.isoformat()
+        }
+        assert data["ai"] == {"status": "healthy", "error": None}
+        for value in [*as_of.values(), data["generated_at"]]:
+            datetime.fromisoformat(value)
+
+    def test_never_run_and_ai_down(self, client, ai_service):
+        """Test the empty pipeline and an AI service that cannot start."""
+        ai_service._ensure_initialized.side_effect = RuntimeError("AI dependencies not available")
+
+        data = client.get("/api/summary").json()
+
+        assert data["pipeline"]["pipeline_id"] is None
+        assert data["pipeline"]["status"] is None
+        assert data["pipeline"]["last_run_at"] is None
+        assert data["ai"]["status"] == "unhealthy"
+        assert data["ai"]["error"] == "AI dependencies not available"
+
+    def test_cached(self, client, store):
+        """Test that a repeat poll within the TTL returns the cached summary."""
+        first = client.get("/api/summary").json()
+        with store.get_connection() as conn:
+            conn.execute("UPDATE emails SET is_read = 1")
+            conn.commit()
+
+        second = client.get("/api/summary").json()
+        summary_api.summary_cache.clear()
+        fresh = client.get("/api/summary").json()
+
+        assert second == first
+        assert fresh["emails"]["unread_required_personal_action"] == 0
+        assert fresh["generated_at"] != first["generated_at"]
@@ -0,0 +1,162 @@
+"""Tests for the one-line summary backfill."""
+
+import asyncio
+
+import pytest
+from unittest.mock import MagicMock
+
+from backend.database.connection import DatabaseManager
+from backend.services.email_service import EmailService
+from backend.services.summary_backfill import backfill_summaries, summarize_emails
+
+
+class StubAI:
+    """Mock AI client that records calls and tracks concurrency."""
+
+    def __init__(self, fail_ids=()):
+        self.calls = []
+        self.in_flight = 0
+        self.max_in_flight = 0
+        self.fail_ids = set(fail_ids)
+
+    async def generate_summary(self, email_content, summary_type="brief"):
+        self.calls.append(email_content)
+        self.in_flight += 1
+        self.max_in_flight = max(self.max_in_flight, self.in_flight)
+        await asyncio.sleep(0.01)
+        self.in_flight -= 1
+        subject = email_content.splitlines()[0].replace("Subject: ", "")
+        if subject in self.fail_ids:
+            return {"summary": "Unable to generate summary", "confidence": 0.0, "error": "boom"}
+        return {"summary": f"Summary of {subject}", "key_points": [], "confidence": 0.8}
+
+
+@pytest.fixture
+def service():
+    """Email service over a store with a mix of summarized and empty rows."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    rows = [
+        ("e1", "fyi", None, 1),
+        ("e2", "fyi", "", 1),
+        ("e3", "newsletter", "   ", 1),
+        ("e4", "fyi", "Already summarized", 1),
+        ("e5", "newsletter", None, 1),
+        ("other-user", "fyi", None, 2),
+    ]
+    with store.get_connection() as conn:
+        for email_id, category, summary, user_id in rows:
+            conn.execute(
+                """
+                INSERT INTO emails (id, subject, sender, content, category,
+                                    one_line_summary, user_id)
+                VALUES (?, ?, 'sender@example.com', 'Body', ?, ?, ?)
+                """,
+                (email_id, email_id, category, summary, user_id)
+            )
+        conn.commit()
+    yield EmailService(MagicMock(), db=store)
+    store.close()
+
+
+def summaries(service):
+    """Map email ID to stored summary."""
+    with service.db.get_connection() as conn:
+        rows = conn.execute("SELECT id, one_line_summary FROM emails").fetchall()
+    return {row["id"]: row["one_line_summary"] for row in rows}
+
+
+class TestSummaryBackfill:
+    """Tests for selecting and backfilling empty summaries."""
+
+    @pytest.mark.asyncio
+    async def test_only_empty_rows_are_touched(self, service):
+        """Test that rows with summaries and other users' rows are left alone."""
+        ai = StubAI()
+        emails = await service.get_emails_missing_summary(user_id=1)
+
+        totals = await backfill_summaries(emails, ai, service, user_id=1)
+
+        assert totals == {"total": 4, "processed": 4, "updated": 4, "failed": 0}
+        assert len(ai.calls) == 4
+        stored = summaries(service)
+        assert stored["e1"] == "Summary of e1"
+        assert stored["e3"] == "Summary of e3"
+        assert stored["e4"] == "Already summarized"
+        assert stored["other-user"] is None
+
+    @pytest.mark.asyncio
+    async def test_category_and_limit_filters(self, service):
+        """Test that category and limit restrict the selection."""
+        assert await service.count_missing_summaries(user_id=1) == 4
+        assert await service.count_missing_summaries(user_id=1, category="newsletter") == 2
+
+        emails = await service.get_emails_missing_summary(user_id=1, category="fyi", limit=1)
+
+        assert len(emails) == 1
+        assert emails[0]["category"] == "fyi"
+
+    @pytest.mark.asyncio
+    async def test_rerun_resumes(self, service):
+        """Test that a second run only picks up rows the first run missed."""
+        first = await service.get_emails_missing_summary(user_id=1, limit=2)
+        await backfill_summaries(first, StubAI(), service, user_id=1)
+
+        remaining = await service.get_emails_missing_summary(user_id=1)
+
+        assert len(remaining) == 2
+        assert not {e["id"] for e in first} & {e["id"] for e in remaining}
+
+    @pytest.mark.asyncio
+    async def test_failures_are_not_written(self, service):
+        """Test that failed summaries leave the row empty for a later run."""
+        ai = StubAI(fail_ids={"e2"})
+        emails = await service.get_emails_missing_summary(user_id=1)
+
+        totals = await backfill_summaries(emails, ai, service, user_id=1)
+
+        assert totals["failed"] == 1
+        assert totals["updated"] == 3
+        assert summaries(service)["e2"] == ""
+        assert await service.count_missing_summaries(user_id=1) == 1
+
+    @pytest.mark.asyncio
+    async def test_existing_summary_not_overwritten(self, service):
+        """Test that a summary written meanwhile is kept."""
+        assert await service.update_email_summary("e4", 1, "New summary") is False
+        assert summaries(service)["e4"] == "Already summarized"
+
+    @pytest.mark.asyncio
+    async def test_concurrency_is_bounded_and_progress_reported(self, service):
+        """Test the concurrency cap and per-email progress callbacks."""
+        ai = StubAI()
+        events = []
+
+        async def on_progress(progress):
+            events.append(progress)
+
+        emails = await service.get_emails_missing_summary(user_id=1)
+        await backfill_summaries(emails, ai, service, user_id=1, concurrency=2,
+                                 on_progress=on_progress)
+
+        assert ai.max_in_flight <= 2
+        assert [e["processed"] for e in events] == [1, 2, 3, 4]
+        assert events[-1]["updated"] == 4
+
+
+class TestSummarizeEmails:
+    """Tests for batch summaries that are returned instead of stored."""
+
+    @pytest.mark.asyncio
+    async def test_results_keyed_by_id_and_not_stored(self, service):
+        """Test that results follow the input order and nothing is written."""
+        ai = StubAI(fail_ids={"e2"})
+        stored = await service.get_stored_emails(["e3", "e2", "e4", "other-user"], user_id=1)
+
+        results = await summarize_emails([stored["e4"], stored["e2"], stored["e3"]], ai, concurrency=2)
+
+        assert list(results) == ["e4", "e2", "e3"]
+        assert results["e4"] == {"summary": "Summary of e4", "key_points": [], "redactions_applied": 0}
+        assert results["e2"] == {"error": "boom"}
+        assert "other-user" not in stored
+        assert ai.max_in_flight <= 2
+        assert summaries(service)["e3"] == "   "
@@ -0,0 +1,181 @@
+"""Tests for action-focused and thread summary modes."""
+
+from datetime import datetime
+from unittest.mock import AsyncMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.ai import router
+from backend.api.auth import get_current_user
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.summary_modes import (
+    ACTION_TEMPLATE, ONE_LINE_TEMPLATE, build_thread_inputs, build_thread_transcript,
+    split_summary, summary_template
+)
+
+USER_ID = 1
+
+
+def message(index, body="Short reply.", sender=None):
+    """Thread email received on day index of January 2024."""
+    return {
+        "id": f"m{index}",
+        "subject": "Launch plan",
+        "sender": sender or f"person{index}@example.com",
+        "received_time": f"2024-01-{index:02d}T09:00:00",
+        "body": body,
+    }
+
+
+class TestThreadAssembly:
+    """Tests for laying a conversation out within the token budget."""
+
+    def test_messages_are_ordered_oldest_first(self):
+        """Test that the transcript follows the thread regardless of input order."""
+        transcript, omitted = build_thread_transcript([message(3), message(1), message(2)], max_tokens=1000)
+
+        assert omitted == 0
+        positions = [transcript.index(f"person{i}@example.com") for i in (1, 2, 3)]
+        assert positions == sorted(positions)
+
+    def test_long_bodies_are_shortened_longest_first(self):
+        """Test that an over-budget thread cuts the long body and leaves short ones whole."""
+        emails = [message(1, "Kickoff."), message(2, "x" * 5000), message(3, "Agreed.")]
+
+        transcript, omitted = build_thread_transcript(emails, max_tokens=300)
+
+        assert omitted == 0
+        assert len(transcript) <= 300 * 4 + 3
+        assert "Kickoff." in transcript and "Agreed." in transcript
+        assert "x" * 100 + "..." in transcript
+
+    def test_oldest_replies_are_omitted_when_bodies_cannot_fit(self):
+        """Test that the opening message and newest replies are kept when replies must be dropped."""
+        emails = [message(i, "word " * 200) for i in range(1, 11)]
+
+        transcript, omitted = build_thread_transcript(emails, max_tokens=400)
+
+        assert omitted > 0
+        assert "person1@example.com" in transcript
+        assert "person10@example.com" in transcript
+        assert "person2@example.com" not in transcript
+        assert f"({omitted} earlier replies omitted)" in transcript
+
+    def test_inputs_list_participants_once(self):
+        """Test that the template inputs use the opening subject and each sender once."""
+        emails = [message(2, sender="b@example.com"), message(1, sender="a@example.com"),
+                  message(3, sender="a@example.com")]
+
+        inputs, omitted = build_thread_inputs(emails, max_tokens=1000, username="Dana")
+
+        assert omitted == 0
+        assert inputs["username"] == "Dana"
+        assert inputs["subject"] == "Launch plan"
+        assert inputs["participants"] == "a@example.com, b@example.com"
+        assert inputs["message_count"] == "3"
+
+
+class TestSummaryOutput:
+    """Tests for template choice and output parsing."""
+
+    def test_templates(self):
+        """Test that single-email types map to their templates and thread is rejected."""
+        assert summary_template("brief") == ONE_LINE_TEMPLATE
+        assert summary_template("action_focused") == ACTION_TEMPLATE
+        with pytest.raises(ValueError):
+            summary_template("thread")
+
+    def test_split_summary(self):
+        """Test that bullet lines become key points and the rest the summary."""
+        text = "Send the budget to Finance\n- Deadline: Friday\n\n- Blocking: Sam\n"
+
+        assert split_summary(text) == ("Send the budget to Finance", ["Deadline: Friday", "Blocking: Sam"])
+
+
+class TestSummarizeAPI:
+    """Tests for POST /api/ai/summarize summary types."""
+
+    @pytest.fixture
+    def stub_ai(self):
+        """AI service with stubbed single-email and thread summaries."""
+        ai = AsyncMock()
+        ai.generate_summary = AsyncMock(return_value={
+            "summary": "Send the budget", "key_points": ["Deadline: Friday"], "confidence": 0.8
+        })
+        ai.summarize_thread = AsyncMock(return_value={
+            "summary": "Launch moved to March", "key_points": ["Date agreed"], "confidence": 0.8,
+            "message_count": 2, "messages_omitted": 0
+        })
+        return ai
+
+    @pytest.fixture
+    def stub_emails(self):
+        """Email service with one two-message conversation and no learned boilerplate."""
+        service = AsyncMock()
+        service.db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+
+        async def get_conversation(conversation_id, user_id=None):
+            return [message(1), message(2)] if conversation_id == "conv-1" else []
+
+        service.get_conversation = AsyncMock(side_effect=get_conversation)
+        return service
+
+    @pytest.fixture
+    def client(self, stub_ai, stub_emails):
+        """Client with auth and both services overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="reader", email="reader@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        app.dependency_overrides[get_email_service] = lambda: stub_emails
+        return TestClient(app)
+
+    def test_action_focused(self, client, stub_ai):
+        """Test that action_focused summaries go to the AI service with their type."""
+        response = client.post("/api/ai/summarize", json={
+            "email_content": "Please send the budget by Friday", "summary_type": "action_focused"
+        })
+
+        assert response.status_code == 200
+        assert response.json()["key_points"] == ["Deadline: Friday"]
+        assert stub_ai.generate_summary.call_args.kwargs["summary_type"] == "action_focused"
+
+    def test_thread(self, client, stub_ai, stub_emails):
+        """Test that thread summaries load the conversation and report its size."""
+        response = client.post("/api/ai/summarize", json={"conversation_id": "conv-1", "summary_type": "thread"})
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["summary"] == "Launch moved to March"
+        assert (data["message_count"], data["messages_omitted"]) == (2, 0)
+        stub_emails.get_conversation.assert_awaited_once_with("conv-1", user_id=USER_ID)
+        assert len(stub_ai.summarize_thread.call_args.args[0]) == 2
+        stub_ai.generate_summary.assert_not_called()
+
+    def test_unknown_conversation(self, client):
+        """Test that a conversation with no emails is 404."""
+        response = client.post("/api/ai/summarize", json={"conversation_id": "ghost", "summary_type": "thread"})
+
+        assert response.status_code == 404
+
+    @pytest.mark.parametrize("payload", [
+        {"email_content": "Hi", "summary_type": "haiku"},
+        {"email_content": "Hi", "summary_type": "thread"},
+        {"conversation_id": "conv-1", "summary_type": "brief"},
+    ])
+    def test_type_validation(self, client, stub_ai, payload):
+        """Test that unknown types and missing inputs for a type are rejected."""
+        response = client.post("/api/ai/summarize", json=payload)
+
+        assert response.status_code == 422
+        stub_ai.generate_summary.assert_not_called()
+        stub_ai.summarize_thread.assert_not_called()
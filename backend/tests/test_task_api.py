@@ -97,6 +97,16 @@ class TestTaskAPI:
         
         response = client.post("/api/tasks", json=task_data, headers=auth_headers)
         assert response.status_code == 422  # Validation error
+        fields = {field["field"]: field for field in response.json()["error"]["fields"]}
+        assert set(fields) == {"title", "priority"}
+        assert fields["priority"]["location"] == "body"
+    
+    def test_create_task_blank_title(self, auth_headers):
+        """Test that a whitespace-only title is rejected with a per-field message."""
+        response = client.post("/api/tasks", json={"title": "   "}, headers=auth_headers)
+        
+        assert response.status_code == 422
+        assert response.json()["error"]["fields"][0]["message"] == "title cannot be blank"
     
     def test_get_tasks_paginated(self, auth_headers):
         """Test getting paginated tasks."""
@@ -271,11 +281,29 @@ class TestTaskAPI:
         assert response.status_code == 200
         
         data = response.json()
-        assert len(data) == 3
-        for task in data:
+        assert data["success_count"] == 3
+        assert data["errors"] == []
+        for task in data["tasks"]:
             assert task["status"] == "completed"
             assert task["priority"] == "high"
     
+    def test_bulk_update_partial(self, auth_headers):
+        """Test that unknown tasks are 404 items inside a 207 response."""
+        response = client.post("/api/tasks", json={"title": "Partial Bulk Task"}, headers=auth_headers)
+        task_id = response.json()["id"]
+        
+        response = client.post("/api/tasks/bulk-update", json={
+            "task_ids": [task_id, 999999],
+            "updates": {"status": "completed"}
+        }, headers=auth_headers)
+        
+        assert response.status_code == 207
+        data = response.json()
+        assert [task["id"] for task in data["tasks"]] == [task_id]
+        assert data["errors"] == [
+            {"task_id": 999999, "error": "Task not found", "status_code": 404, "is_retryable": False}
+        ]
+    
     def test_bulk_delete_tasks(self, auth_headers):
         """Test bulk task deletion."""
         # Create multiple tasks
@@ -294,6 +322,12 @@ class TestTaskAPI:
         
         data = response.json()
         assert data["deleted_count"] == 3
+        assert data["failure_count"] == 0
+        
+        # Deleting them again fails for every task
+        response = client.post("/api/tasks/bulk-delete", json=bulk_data, headers=auth_headers)
+        assert response.status_code == 404
+        assert response.json()["failure_count"] == 3
         
         # Verify all tasks are gone
         for task_id in task_ids:
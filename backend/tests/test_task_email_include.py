@@ -0,0 +1,141 @@
+"""Tests for embedding linked emails in task lists (GET /api/tasks?include=email)."""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.tasks import router
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.task import TaskCreate
+from backend.models.user import UserInDB
+from backend.services.task_service import TaskService, get_task_service
+
+USER_ID = 1
+
+
+@pytest.fixture
+def tasks():
+    """Task service over an in-memory store with two stored emails and three tasks."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        conn.executemany(
+            """
+            INSERT INTO emails (id, subject, sender, received_date, one_line_summary, user_id)
+            VALUES (?, ?, ?, ?, ?, ?)
+            """,
+            [
+                ("email-budget", "Q3 budget", "cfo@contoso.com", datetime(2024, 7, 1, 9, 30),
+                 "CFO needs budget numbers by Friday", USER_ID),
+                ("email-other-user", "Private", "someone@contoso.com", datetime(2024, 7, 1, 10, 0), None, 2),
+            ]
+        )
+        conn.commit()
+    service = TaskService(db=db)
+    for title, email_id in [
+        ("Send budget", "email-budget"),
+        ("Water plants", None),
+        ("Reply to someone", "email-other-user"),
+    ]:
+        asyncio.run(service.create_task(TaskCreate(title=title, email_id=email_id), USER_ID))
+    yield service
+    db.close()
+
+
+def task_page(tasks, **kwargs):
+    """Page of USER_ID's tasks and the SQL statements it ran."""
+    statements = []
+    with tasks.db.get_connection() as conn:
+        conn.set_trace_callback(statements.append)
+        try:
+            page = asyncio.run(tasks.get_tasks_paginated(USER_ID, **kwargs))
+        finally:
+            conn.set_trace_callback(None)
+    return {task.title: task for task in page.tasks}, statements
+
+
+class TestIncludeEmail:
+    """Tests for TaskService.get_tasks_paginated(include_email=True)."""
+
+    def test_linked_email_embedded(self, tasks):
+        """Test that a task's stored email is embedded."""
+        page, _ = task_page(tasks, include_email=True)
+
+        email = page["Send budget"].email
+        assert (email.id, email.subject, email.sender) == ("email-budget", "Q3 budget", "cfo@contoso.com")
+        assert email.received_time == datetime(2024, 7, 1, 9, 30)
+        assert email.one_line_summary == "CFO needs budget numbers by Friday"
+
+    def test_unlinked_and_unstored_are_none(self, tasks):
+        """Test that tasks without a stored email of the user's get None."""
+        page, _ = task_page(tasks, include_email=True)
+
+        assert page["Water plants"].email is None
+        assert page["Reply to someone"].email is None
+
+    def test_single_page_query(self, tasks):
+        """Test that embedding adds no queries: one count and one joined page query."""
+        _, plain = task_page(tasks)
+        _, embedded = task_page(tasks, include_email=True)
+
+        selects = [sql for sql in embedded if sql.lstrip().upper().startswith("SELECT")]
+        assert len(selects) == len([sql for sql in plain if sql.lstrip().upper().startswith("SELECT")]) == 2
+        assert len([sql for sql in selects if "emails" in sql]) == 1
+
+    def test_filters_still_apply(self, tasks):
+        """Test that filters and search work with the join."""
+        page, _ = task_page(tasks, include_email=True, search="budget")
+
+        assert list(page) == ["Send budget"]
+
+    def test_not_set_without_include(self, tasks):
+        """Test that email is left unset when not requested."""
+        page, _ = task_page(tasks)
+
+        assert "email" not in page["Send budget"].model_fields_set
+
+
+class TestTasksAPI:
+    """Tests for the include parameter of GET /api/tasks."""
+
+    @pytest.fixture
+    def client(self, tasks):
+        """Client with auth and the task service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="planner", email="planner@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_task_service] = lambda: tasks
+        return TestClient(app)
+
+    def test_include_email(self, client):
+        """Test that every task has an email object or null."""
+        response = client.get("/api/tasks", params={"include": "email"})
+
+        assert response.status_code == 200
+        by_title = {task["title"]: task for task in response.json()["tasks"]}
+        assert by_title["Send budget"]["email"]["subject"] == "Q3 budget"
+        assert by_title["Send budget"]["email"]["received_time"].startswith("2024-07-01T09:30")
+        assert by_title["Water plants"]["email"] is None
+
+    def test_without_include_unchanged(self, client):
+        """Test that tasks have no email key unless it is requested, and nulls are kept."""
+        task = client.get("/api/tasks").json()["tasks"][0]
+
+        assert "email" not in task
+        assert task["due_date"] is None
+        assert "email_id" in task
+
+    def test_unknown_include(self, client):
+        """Test that an include the endpoint does not know is rejected."""
+        response = client.get("/api/tasks", params={"include": "email,attachments"})
+
+        assert response.status_code == 422
+        assert "attachments" in response.json()["error"]["message"]
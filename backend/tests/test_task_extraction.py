@@ -0,0 +1,187 @@
+"""Tests for batch task extraction and its per-email results."""
+
+from datetime import datetime
+
+import pytest
+from unittest.mock import AsyncMock, MagicMock
+from fastapi.testclient import TestClient
+
+from backend.main import app
+from backend.api.ai import ai_rate_limiter
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import UpstreamAIError
+from backend.core.protection import PROTECTED_REASON
+from backend.database.connection import DatabaseManager
+from backend.models.task import TaskExtractionResult
+from backend.models.user import UserInDB
+from backend.services.email_service import EmailService
+from backend.services.task_extraction import EXTRACTED_TASK_SOURCE, extract_email_tasks, summarize_results
+from backend.services.task_service import TaskService, get_task_service
+
+ITEMS = {
+    "Report": [
+        {"description": "Send the report", "owner": "me"},
+        {"description": "Book the room", "owner": "me"},
+    ],
+    "Lunch": [],
+}
+
+
+async def extract_action_items(email_content, context=None):
+    """Stub extraction keyed by the email's subject."""
+    subject = email_content.splitlines()[0].replace("Subject: ", "")
+    if subject not in ITEMS:
+        return {"action_items": [], "confidence": 0.0, "error": "quota exceeded"}
+    return {"action_items": ITEMS[subject], "confidence": 0.8}
+
+
+def make_email(email_id, subject, is_protected=0):
+    """Build a stored email row."""
+    return {
+        "id": email_id,
+        "subject": subject,
+        "sender": "alice@example.com",
+        "content": "Please send the report and book the room.",
+        "is_protected": is_protected,
+    }
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def task_service(store):
+    """Task service over the in-memory store."""
+    return TaskService(db=store)
+
+
+@pytest.fixture
+def stub_ai():
+    """Stub AI finding two items in "Report", none in "Lunch", and failing otherwise."""
+    ai = MagicMock()
+    ai.extract_action_items = AsyncMock(side_effect=extract_action_items)
+    return ai
+
+
+class TestExtractEmailTasks:
+    """Tests for one email's extraction result."""
+
+    @pytest.mark.asyncio
+    async def test_tasks_belong_to_their_email(self, stub_ai, task_service):
+        """Test that the result lists the email's tasks and reruns only report duplicates."""
+        result = await extract_email_tasks(make_email("e1", "Report"), stub_ai, task_service, 1)
+
+        assert result.email_id == "e1"
+        assert [task.title for task in result.tasks] == ["Send the report", "Book the room"]
+        assert {task.email_id for task in result.tasks} == {"e1"}
+        assert {task.source for task in result.tasks} == {EXTRACTED_TASK_SOURCE}
+        assert (result.confidence, result.duplicates_skipped, result.error) == (0.8, [], None)
+
+        again = await extract_email_tasks(make_email("e1", "Report"), stub_ai, task_service, 1)
+
+        assert again.tasks == []
+        assert again.duplicates_skipped == ["Send the report", "Book the room"]
+
+    @pytest.mark.asyncio
+    async def test_email_without_items(self, stub_ai, task_service):
+        """Test that an email with no action items succeeds with no tasks."""
+        result = await extract_email_tasks(make_email("e2", "Lunch"), stub_ai, task_service, 1)
+
+        assert (result.tasks, result.error, result.status_code) == ([], None, 200)
+
+    @pytest.mark.asyncio
+    async def test_ai_failure_raises(self, stub_ai, task_service):
+        """Test that an extraction error with no items raises UpstreamAIError."""
+        with pytest.raises(UpstreamAIError, match="quota exceeded"):
+            await extract_email_tasks(make_email("e3", "Other"), stub_ai, task_service, 1)
+
+    @pytest.mark.asyncio
+    async def test_protected_email_skipped(self, stub_ai, task_service, monkeypatch):
+        """Test that a protected email is skipped without calling the AI."""
+        monkeypatch.setattr(settings, "allow_protected_ai", False)
+
+        result = await extract_email_tasks(make_email("e4", "Report", is_protected=1), stub_ai, task_service, 1)
+
+        assert (result.skipped, result.tasks) == (PROTECTED_REASON, [])
+        stub_ai.extract_action_items.assert_not_awaited()
+
+    def test_summarize_results(self):
+        """Test that the summary counts tasks, duplicates, failures, and skips."""
+        results = [
+            TaskExtractionResult(email_id="e1", duplicates_skipped=["Send the report"]),
+            TaskExtractionResult(email_id="e2", error="Email not found", status_code=404),
+            TaskExtractionResult(email_id="e3", skipped=PROTECTED_REASON),
+        ]
+
+        summary = summarize_results(results)
+
+        assert (summary.email_count, summary.tasks_created, summary.duplicates_skipped) == (3, 0, 1)
+        assert (summary.failure_count, summary.skipped_count) == (1, 1)
+
+
+class TestExtractTasksEndpoint:
+    """Tests for POST /api/ai/extract-tasks."""
+
+    @pytest.fixture
+    def extract_client(self, store, task_service, stub_ai):
+        """Client over a store with a "Report", a "Lunch", and a failing email."""
+        with store.get_connection() as conn:
+            for email_id, subject in (("x1", "Report"), ("x2", "Lunch"), ("x3", "Other")):
+                conn.execute(
+                    "INSERT INTO emails (id, subject, sender, content, user_id) "
+                    "VALUES (?, ?, 'alice@example.com', 'Body', 1)",
+                    (email_id, subject)
+                )
+            conn.commit()
+        user = UserInDB(id=1, username="extractor", email="extract@example.com",
+                        hashed_password="x", created_at=datetime.now())
+        app.dependency_overrides[get_current_user] = lambda: user
+        app.dependency_overrides[get_ai_service] = lambda: stub_ai
+        app.dependency_overrides[get_email_service] = lambda: EmailService(MagicMock(), db=store)
+        app.dependency_overrides[get_task_service] = lambda: task_service
+        ai_rate_limiter.reset()
+        yield TestClient(app)
+        app.dependency_overrides.clear()
+        ai_rate_limiter.reset()
+
+    def test_results_per_email(self, extract_client):
+        """Test that each email reports its own tasks, in request order."""
+        response = extract_client.post("/api/ai/extract-tasks", json={"email_ids": ["x2", "x1", "missing", "x3"]})
+
+        assert response.status_code == 207
+        data = response.json()
+        assert [result["email_id"] for result in data["results"]] == ["x2", "x1", "missing", "x3"]
+        lunch, report, missing, failed = data["results"]
+        assert lunch["tasks"] == []
+        assert [task["title"] for task in report["tasks"]] == ["Send the report", "Book the room"]
+        assert (missing["error"], missing["status_code"]) == ("Email not found", 404)
+        assert (failed["status_code"], failed["is_retryable"]) == (502, True)
+        assert data["summary"] == {
+            "email_count": 4, "tasks_created": 2, "duplicates_skipped": 0, "failure_count": 2, "skipped_count": 0,
+        }
+        assert [task["id"] for task in data["tasks"]] == [task["id"] for task in report["tasks"]]
+
+    def test_rerun_reports_duplicates(self, extract_client):
+        """Test that extracting an email again creates nothing and lists the duplicates."""
+        extract_client.post("/api/ai/extract-tasks", json={"email_ids": ["x1"]})
+
+        response = extract_client.post("/api/ai/extract-tasks", json={"email_ids": ["x1", "x1"]})
+
+        assert response.status_code == 200
+        result, = response.json()["results"]
+        assert result["tasks"] == []
+        assert result["duplicates_skipped"] == ["Send the report", "Book the room"]
+        assert response.json()["summary"]["tasks_created"] == 0
+
+    def test_status_when_every_email_fails(self, extract_client):
+        """Test that the dominant item error becomes the response status."""
+        response = extract_client.post("/api/ai/extract-tasks", json={"email_ids": ["missing", "gone"]})
+
+        assert response.status_code == 404
@@ -123,7 +123,7 @@ def test_complete_task_workflow():
     }
     response = client.post("/api/tasks/bulk-update", json=bulk_update_data, headers=headers)
     assert response.status_code == 200
-    bulk_updated = response.json()
+    bulk_updated = response.json()["tasks"]
     assert len(bulk_updated) == 2
     assert all(task["status"] == "completed" for task in bulk_updated)
     
@@ -6,7 +6,7 @@ from datetime import datetime, timedelta
 
 from backend.services.task_service import TaskService, TaskListResponse
 from backend.models.task import TaskCreate, TaskUpdate, TaskStatus, TaskPriority
-from backend.database.connection import db_manager
+from backend.database.connection import DatabaseManager
 
 
 class TestTaskService:
@@ -14,26 +14,21 @@ class TestTaskService:
     
     @pytest.fixture
     def task_service(self):
-        """Create task service instance."""
-        return TaskService()
+        """Create task service backed by an isolated in-memory store."""
+        db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        yield TaskService(db=db)
+        db.close()
     
     @pytest.fixture
-    def test_user_id(self):
+    def test_user_id(self, task_service: TaskService):
         """Create a test user and return ID."""
-        with db_manager.get_connection() as conn:
+        with task_service.db.get_connection() as conn:
             cursor = conn.execute(
                 "INSERT INTO users (username, email, hashed_password, is_active) VALUES (?, ?, ?, ?)",
                 ("testuser_task", "test_task@example.com", "hashed_password", True)
             )
             conn.commit()
-            user_id = cursor.lastrowid
-            
-            yield user_id
-            
-            # Cleanup - remove test user and their tasks
-            conn.execute("DELETE FROM tasks WHERE user_id = ?", (user_id,))
-            conn.execute("DELETE FROM users WHERE id = ?", (user_id,))
-            conn.commit()
+            return cursor.lastrowid
     
     @pytest.mark.asyncio
     async def test_create_task(self, task_service: TaskService, test_user_id: int):
@@ -244,7 +239,7 @@ class TestTaskService:
     async def test_user_isolation(self, task_service: TaskService):
         """Test that users can only access their own tasks."""
         # Create two test users
-        with db_manager.get_connection() as conn:
+        with task_service.db.get_connection() as conn:
             cursor = conn.execute(
                 "INSERT INTO users (username, email, hashed_password, is_active) VALUES (?, ?, ?, ?)",
                 ("user1", "user1@example.com", "hashed_password", True)
@@ -259,27 +254,19 @@ class TestTaskService:
             conn.commit()
             user2_id = cursor.lastrowid
         
-        try:
-            # User 1 creates a task
-            task_data = TaskCreate(title="User 1 Task")
-            user1_task = await task_service.create_task(task_data, user1_id)
-            
-            # User 2 should not be able to access User 1's task
-            result = await task_service.get_task(user1_task.id, user2_id)
-            assert result is None
-            
-            # User 2 should not be able to update User 1's task
-            updates = TaskUpdate(title="Hacked Task")
-            result = await task_service.update_task(user1_task.id, updates, user2_id)
-            assert result is None
-            
-            # User 2 should not be able to delete User 1's task
-            success = await task_service.delete_task(user1_task.id, user2_id)
-            assert success is False
-            
-        finally:
-            # Cleanup
-            with db_manager.get_connection() as conn:
-                conn.execute("DELETE FROM tasks WHERE user_id IN (?, ?)", (user1_id, user2_id))
-                conn.execute("DELETE FROM users WHERE id IN (?, ?)", (user1_id, user2_id))
-                conn.commit()
\ No newline at end of file
+        # User 1 creates a task
+        task_data = TaskCreate(title="User 1 Task")
+        user1_task = await task_service.create_task(task_data, user1_id)
+        
+        # User 2 should not be able to access User 1's task
+        result = await task_service.get_task(user1_task.id, user2_id)
+        assert result is None
+        
+        # User 2 should not be able to update User 1's task
+        updates = TaskUpdate(title="Hacked Task")
+        result = await task_service.update_task(user1_task.id, updates, user2_id)
+        assert result is None
+        
+        # User 2 should not be able to delete User 1's task
+        success = await task_service.delete_task(user1_task.id, user2_id)
+        assert success is False
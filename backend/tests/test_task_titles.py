@@ -0,0 +1,131 @@
+"""Tests for normalizing the titles of tasks made from action items."""
+
+from unittest.mock import AsyncMock, MagicMock
+
+import pytest
+
+from backend.core.config import settings
+from backend.database.connection import DatabaseManager
+from backend.services.task_service import (
+    ITEM_TEXT_PREFIX, MAX_TASK_TITLE, TaskService, clean_task_title, normalize_task_title, parse_task_title
+)
+
+USER_ID = 1
+
+
+@pytest.fixture
+def rewriter():
+    """Stub AI rewriting every item as the same imperative title."""
+    ai = MagicMock()
+    ai.rewrite_task_title = AsyncMock(return_value={"title": "Review Q4 budget spreadsheet"})
+    return ai
+
+
+@pytest.fixture
+def task_service():
+    """Task service over an isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield TaskService(db=db)
+    db.close()
+
+
+class TestCleanTitle:
+    """Tests for the deterministic cleanup every title gets."""
+
+    @pytest.mark.parametrize("text,expected", [
+        ("RE: RE: FW: please see attached", "please see attached"),
+        ("Fwd:Re:  budget   review", "budget review"),
+        ("AW: WG: Angebot prüfen", "Angebot prüfen"),
+        ("RE[2]: Q4 numbers", "Q4 numbers"),
+        ("[EXTERNAL] RE: [Action Required] sign the NDA", "sign the NDA"),
+        ("(URGENT) FW: renew the certificate -", "renew the certificate"),
+        ('"Review the design doc"', "Review the design doc"),
+        ("[Update the roadmap]", "Update the roadmap"),
+        ('Reply to "Alice" about "Bob"', 'Reply to "Alice" about "Bob"'),
+        ("Review the re: invent notes", "Review the re: invent notes"),
+    ])
+    def test_cleanup(self, text, expected):
+        """Test that prefixes, leading tags, and wrapping brackets go and the rest stays."""
+        assert clean_task_title(text) == expected
+
+    def test_only_prefixes_kept(self):
+        """Test that text with nothing but prefixes and tags is kept as it was."""
+        assert clean_task_title("RE:  FW:") == "RE: FW:"
+
+    def test_length_is_capped(self):
+        """Test that long items are cut to the longest title a task may have."""
+        assert len(clean_task_title("FW: " + "x" * 300)) == MAX_TASK_TITLE
+
+
+class TestAIRewrite:
+    """Tests for the optional AI rewrite on top of the cleanup."""
+
+    @pytest.mark.parametrize("raw", [
+        {"title": "Review Q4 budget spreadsheet."},
+        '```json\n{"title": "RE: Review Q4 budget spreadsheet"}\n```',
+    ])
+    def test_parse(self, raw):
+        """Test that the model's title is read from a dict or fenced JSON and cleaned."""
+        assert parse_task_title(raw) == "Review Q4 budget spreadsheet"
+
+    @pytest.mark.parametrize("raw", ["not json", {"title": "  "}, ["title"]])
+    def test_parse_rejects_missing_title(self, raw):
+        """Test that output without a usable title is an error."""
+        with pytest.raises(ValueError):
+            parse_task_title(raw)
+
+    @pytest.mark.asyncio
+    async def test_off_by_default(self, rewriter):
+        """Test that without ai_task_titles the AI is not asked."""
+        assert await normalize_task_title("RE: please see attached", rewriter) == "please see attached"
+        rewriter.rewrite_task_title.assert_not_called()
+
+    @pytest.mark.asyncio
+    async def test_rewrites_cleaned_text(self, rewriter, monkeypatch):
+        """Test that the AI gets the cleaned text and its title is used."""
+        monkeypatch.setattr(settings, "ai_task_titles", True)
+
+        assert await normalize_task_title("RE: RE: budget sheet", rewriter) == "Review Q4 budget spreadsheet"
+        rewriter.rewrite_task_title.assert_awaited_once_with("budget sheet")
+
+    @pytest.mark.asyncio
+    @pytest.mark.parametrize("outcome", [{"error": "model down"}, RuntimeError("model down")])
+    async def test_failure_falls_back_to_cleaned(self, rewriter, monkeypatch, outcome):
+        """Test that an AI error or failed rewrite quietly keeps the cleaned text."""
+        monkeypatch.setattr(settings, "ai_task_titles", True)
+        rewriter.rewrite_task_title.side_effect = [outcome]
+
+        assert await normalize_task_title("FW: budget sheet", rewriter) == "budget sheet"
+
+
+class TestCreatedTasks:
+    """Tests for titles and descriptions of tasks made from action items."""
+
+    @pytest.mark.asyncio
+    async def test_item_text_kept_in_description(self, task_service):
+        """Test that a task whose title was cleaned starts its description with the item's text."""
+        created = await task_service.create_tasks_from_action_items(
+            "e1", ["RE: FW: please see attached", "Send feedback"], USER_ID, description="Links:\n- x"
+        )
+
+        assert [task.title for task in created] == ["please see attached", "Send feedback"]
+        assert created[0].description == f"{ITEM_TEXT_PREFIX}RE: FW: please see attached\n\nLinks:\n- x"
+        assert created[1].description == "Links:\n- x"
+
+    @pytest.mark.asyncio
+    async def test_rewritten_titles_are_not_duplicated(self, task_service, rewriter, monkeypatch):
+        """Test that re-extracting an item the AI retitled finds the task it made before."""
+        monkeypatch.setattr(settings, "ai_task_titles", True)
+        await task_service.create_tasks_from_action_items("e1", ["FW: budget sheet"], USER_ID, ai_service=rewriter)
+        rewriter.rewrite_task_title.return_value = {"title": "Check the budget sheet"}
+
+        again = await task_service.create_tasks_from_action_items(
+            "e1", ["RE: budget sheet", "Review Q4 budget spreadsheet"], USER_ID, ai_service=rewriter
+        )
+
+        assert again == []
+        rewriter.rewrite_task_title.assert_awaited_once()
+        tasks = (await task_service.get_tasks_paginated(user_id=USER_ID)).tasks
+        assert [(task.title, task.description) for task in tasks] == [
+            ("Review Q4 budget spreadsheet", f"{ITEM_TEXT_PREFIX}FW: budget sheet")
+        ]
@@ -0,0 +1,155 @@
+"""Tests for word-level email diffs."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.config import settings
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.core.text_diff import DELETE, INSERT, diff_words, truncate_bytes
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+
+ANNOUNCEMENT = "The all-hands is on Tuesday at 10am in Building 4. Bring your badge."
+CORRECTED = "The all-hands is on Wednesday at 10am in Building 4. Bring your badge and laptop."
+
+
+def changes(diff):
+    """The diff's changes as (op, text, old_position, new_position) tuples."""
+    return [(c.op, c.text, c.old_position, c.new_position) for c in diff.changes]
+
+
+class TestDiffWords:
+    """Tests for the word-level diff."""
+
+    def test_identical(self):
+        """Test that identical texts have no changes and full similarity."""
+        diff = diff_words(ANNOUNCEMENT, ANNOUNCEMENT)
+
+        assert (diff.changes, diff.similarity, diff.truncated) == ([], 1.0, False)
+
+    def test_corrected_announcement(self):
+        """Test that a replaced word is a deletion then an insertion, and an appended word an insertion."""
+        diff = diff_words(ANNOUNCEMENT, CORRECTED)
+
+        tuesday, wednesday = ANNOUNCEMENT.index("Tuesday"), CORRECTED.index("Wednesday")
+        assert changes(diff) == [
+            (DELETE, "Tuesday", tuesday, wednesday),
+            (INSERT, "Wednesday", tuesday, wednesday),
+            (DELETE, "badge.", ANNOUNCEMENT.index("badge."), CORRECTED.index("badge and")),
+            (INSERT, "badge and laptop.", ANNOUNCEMENT.index("badge."), CORRECTED.index("badge and")),
+        ]
+        assert (diff.insertions, diff.deletions) == (4, 2)
+        assert 0.75 < diff.similarity < 1.0
+
+    def test_positions_index_the_texts(self):
+        """Test that each change's text sits at its position in its own text."""
+        diff = diff_words("one two three four", "zero one three four five")
+
+        for change in diff.changes:
+            text = diff.old_text if change.op == DELETE else diff.new_text
+            position = change.old_position if change.op == DELETE else change.new_position
+            assert text[position:position + len(change.text)] == change.text
+        assert changes(diff) == [
+            (INSERT, "zero", 0, 0),
+            (DELETE, "two", 4, 9),
+            (INSERT, "five", 18, 20),
+        ]
+
+    def test_whitespace_only_changes_ignored(self):
+        """Test that reflowed text is not a change."""
+        diff = diff_words("Please  review\nthe doc", "Please review the\n\ndoc")
+
+        assert (diff.changes, diff.similarity) == ([], 1.0)
+
+    @pytest.mark.parametrize("old,new,expected", [
+        ("", "", 1.0),
+        ("", "new text", 0.0),
+        ("old text", "", 0.0),
+        ("a b c d", "e f g h", 0.0),
+    ])
+    def test_similarity_bounds(self, old, new, expected):
+        """Test similarity for empty and disjoint texts."""
+        assert diff_words(old, new).similarity == expected
+
+    def test_removing_everything(self):
+        """Test that deleting all words reports one run at the end of the new text."""
+        assert changes(diff_words("gone for good", "")) == [(DELETE, "gone for good", 0, 0)]
+
+    def test_long_texts_cut(self):
+        """Test that texts beyond max_bytes are cut and flagged."""
+        diff = diff_words("word " * 1000, "word " * 1000 + "extra", max_bytes=100)
+
+        assert diff.truncated is True
+        assert len(diff.old_text.encode("utf-8")) <= 100
+        assert diff.changes == []
+
+    def test_cut_keeps_whole_characters(self):
+        """Test that a cut never splits a multi-byte character."""
+        assert truncate_bytes("naïve café", 3) == ("na", True)
+        assert truncate_bytes("café", 5) == ("café", False)
+
+
+class TestEndpoint:
+    """Tests for GET /api/emails/{email_id}/diff/{other_email_id}."""
+
+    @pytest.fixture
+    def provider(self):
+        """Mock mailbox holding an announcement and its correction."""
+        provider = MockEmailProvider()
+        provider.authenticate({})
+        provider.mock_emails[0]["body"] = f"<p>{ANNOUNCEMENT}</p>"
+        provider.mock_emails[1]["body"] = CORRECTED
+        return provider
+
+    @pytest.fixture
+    def client(self, provider):
+        """Client for the email endpoints over the mailbox."""
+        db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+        service = EmailService(provider, db=db)
+
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="reader", email="reader@example.com", hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: service
+        yield TestClient(app)
+        db.close()
+
+    def test_diff(self, client):
+        """Test the diff of the HTML announcement against its plain-text correction."""
+        response = client.get("/api/emails/mock-email-1/diff/mock-email-2")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert (data["email_text"], data["other_text"]) == (ANNOUNCEMENT, CORRECTED)
+        assert [change["text"] for change in data["changes"]] == ["Tuesday", "Wednesday", "badge.", "badge and laptop."]
+        assert (data["insertions"], data["deletions"], data["truncated"], data["notice"]) == (4, 2, False, None)
+
+    @pytest.mark.parametrize("email_id,other_email_id", [("missing", "mock-email-2"), ("mock-email-1", "missing")])
+    def test_missing_email(self, client, email_id, other_email_id):
+        """Test that either email being missing is a 404 naming it."""
+        response = client.get(f"/api/emails/{email_id}/diff/{other_email_id}")
+
+        assert response.status_code == 404
+        assert "'missing'" in response.text
+
+    def test_truncation_notice(self, client, provider, monkeypatch):
+        """Test that bodies over email_diff_max_kb are cut with a notice."""
+        monkeypatch.setattr(settings, "email_diff_max_kb", 1)
+        provider.mock_emails[1]["body"] = CORRECTED * 20
+
+        data = client.get("/api/emails/mock-email-1/diff/mock-email-2").json()
+
+        assert data["truncated"] is True
+        assert (data["email_text"], len(data["other_text"])) == (ANNOUNCEMENT, 1024)
+        assert data["notice"] == "Only the first 1 KB of each body was compared"
@@ -0,0 +1,170 @@
+"""Tests for thread-level batch classification."""
+
+import pytest
+from unittest.mock import AsyncMock, MagicMock
+
+from backend.database.connection import DatabaseManager
+from backend.services.email_service import EmailService
+from backend.services.thread_classifier import (
+    build_thread_digest, classify_batch, group_by_conversation
+)
+
+
+def make_email(email_id, conversation_id=None, minute=0, sender="alice@example.com"):
+    """Build a provider-style email dict."""
+    return {
+        "id": email_id,
+        "subject": f"Re: Subject {conversation_id or email_id}",
+        "sender": sender,
+        "body": f"Body of {email_id}",
+        "received_time": f"2024-01-01T10:{minute:02d}:00Z",
+        "conversation_id": conversation_id,
+    }
+
+
+@pytest.fixture
+def conversation_emails():
+    """A 3-message thread, a 2-message thread, and two singletons, interleaved."""
+    return [
+        make_email("a1", "conv-a", minute=1),
+        make_email("solo-1", minute=2),
+        make_email("b2", "conv-b", minute=9, sender="bob@example.com"),
+        make_email("a3", "conv-a", minute=7, sender="carol@example.com"),
+        make_email("a2", "conv-a", minute=4),
+        make_email("b1", "conv-b", minute=3),
+        make_email("solo-2", minute=5),
+    ]
+
+
+@pytest.fixture
+def stub_ai():
+    """Stub AI client that classifies by email subject."""
+    ai = MagicMock()
+    ai.classify_email_async = AsyncMock(side_effect=lambda **kwargs: {
+        "category": "team_action" if "conv" in kwargs["subject"] else "fyi",
+        "confidence": 0.9,
+        "reasoning": f"Classified {kwargs['subject']}",
+    })
+    return ai
+
+
+@pytest.fixture
+def service():
+    """Email service over an isolated in-memory store."""
+    store = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield EmailService(MagicMock(), db=store)
+    store.close()
+
+
+def stored(service, email_id):
+    """Read a stored email row."""
+    with service.db.get_connection() as conn:
+        row = conn.execute("SELECT * FROM emails WHERE id = ?", (email_id,)).fetchone()
+    return dict(row) if row else None
+
+
+class TestGrouping:
+    """Tests for conversation grouping and digests."""
+
+    def test_groups_threads_oldest_first(self, conversation_emails):
+        """Test that threads are grouped, ordered, and singletons kept apart."""
+        groups = group_by_conversation(conversation_emails)
+
+        assert [[e["id"] for e in group] for group in groups] == [
+            ["a1", "a2", "a3"], ["solo-1"], ["b1", "b2"], ["solo-2"]
+        ]
+
+    def test_digest_lists_earlier_messages(self):
+        """Test that the digest names senders and truncates long bodies."""
+        earlier = [make_email("x1", "c"), make_email("x2", "c", sender="bob@example.com")]
+        earlier[1]["body"] = "word " * 100
+
+        digest = build_thread_digest(earlier)
+
+        assert "(2 total)" in digest
+        assert "alice@example.com" in digest
+        assert "bob@example.com" in digest
+        assert digest.endswith("...")
+
+    def test_digest_limits_messages(self):
+        """Test that only the most recent earlier messages are included."""
+        earlier = [make_email(f"x{i}", "c", minute=i) for i in range(6)]
+
+        digest = build_thread_digest(earlier, max_messages=2)
+
+        assert "Body of x5" in digest
+        assert "Body of x3" not in digest
+        assert build_thread_digest(earlier, max_messages=0) == ""
+
+
+class TestClassifyBatch:
+    """Tests for batch classification with and without thread mode."""
+
+    @pytest.mark.asyncio
+    async def test_thread_mode_classifies_latest_only(self, conversation_emails, stub_ai, service):
+        """Test one AI call per thread and inherited results for other members."""
+        outcome = await classify_batch(conversation_emails, stub_ai, service, user_id=1,
+                                       thread_mode=True)
+
+        assert outcome.ai_calls == 4
+        assert outcome.ai_calls_saved == 3
+        classified = {call.kwargs["subject"] for call in stub_ai.classify_email_async.call_args_list}
+        assert "Re: Subject conv-a" in classified
+
+        results = {r.email_id: r for r in outcome.results}
+        assert [r.email_id for r in outcome.results] == [e["id"] for e in conversation_emails]
+        assert results["a3"].inherited_from is None
+        assert results["a1"].inherited_from == "a3"
+        assert results["a2"].inherited_from == "a3"
+        assert results["b1"].inherited_from == "b2"
+        assert results["solo-1"].inherited_from is None
+        assert results["a1"].category == results["a3"].category == "team_action"
+
+    @pytest.mark.asyncio
+    async def test_thread_digest_passed_as_context(self, conversation_emails, stub_ai, service):
+        """Test that the latest message is classified with the earlier messages as context."""
+        await classify_batch(conversation_emails, stub_ai, service, user_id=1,
+                             thread_mode=True, context="Work mailbox")
+
+        contexts = [call.kwargs["context"] for call in stub_ai.classify_email_async.call_args_list]
+        thread_context = next(c for c in contexts if c and "Body of a1" in c)
+        assert thread_context.startswith("Work mailbox")
+        assert "Body of a2" in thread_context
+        assert "Work mailbox" == contexts[1]  # singleton gets no digest
+
+    @pytest.mark.asyncio
+    async def test_inherited_results_are_stored(self, conversation_emails, stub_ai, service):
+        """Test that thread members are stored with an inherited_from marker."""
+        await classify_batch(conversation_emails, stub_ai, service, user_id=1, thread_mode=True)
+
+        latest = stored(service, "a3")
+        member = stored(service, "a1")
+        assert latest["inherited_from"] is None
+        assert member["inherited_from"] == "a3"
+        assert member["category"] == latest["category"]
+        assert member["confidence"] == latest["confidence"]
+        assert member["conversation_id"] == "conv-a"
+        assert member["user_id"] == 1
+
+    @pytest.mark.asyncio
+    async def test_without_thread_mode_each_email_is_classified(self, conversation_emails,
+                                                                stub_ai, service):
+        """Test that thread mode off keeps per-email classification."""
+        outcome = await classify_batch(conversation_emails, stub_ai, service, user_id=1)
+
+        assert outcome.ai_calls == len(conversation_emails)
+        assert outcome.ai_calls_saved == 0
+        assert all(r.inherited_from is None for r in outcome.results)
+
+    @pytest.mark.asyncio
+    async def test_failed_classification_not_stored(self, stub_ai, service):
+        """Test that AI failures are reported but never written to the database."""
+        stub_ai.classify_email_async = AsyncMock(return_value={"error": "model unavailable"})
+        emails = [make_email("t1", "conv-t", minute=1), make_email("t2", "conv-t", minute=2)]
+
+        outcome = await classify_batch(emails, stub_ai, service, user_id=1, thread_mode=True)
+
+        assert [r.category for r in outcome.results] == ["unclassified", "unclassified"]
+        assert "model unavailable" in outcome.results[0].reasoning
+        assert stored(service, "t1") is None
+        assert stored(service, "t2") is None
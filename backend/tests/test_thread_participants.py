@@ -0,0 +1,202 @@
+"""Tests for the participants of a conversation thread."""
+
+import asyncio
+import json
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.dependencies import get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.thread_participants import summarize_participants
+
+USER_ID = 1
+
+MY_ADDRESS = "me@contoso.com"
+
+# A thread between the user, two colleagues, and a vendor
+THREAD = [
+    {
+        "id": "t-1", "sender": "Alice Smith <Alice@Contoso.com>",
+        "to_recipients": [MY_ADDRESS], "cc_recipients": ["vendor@fabrikam.com"],
+        "received_date": "2026-03-01 09:00:00",
+    },
+    {
+        "id": "t-2", "sender": "vendor@fabrikam.com",
+        "to_recipients": ["alice@contoso.com", MY_ADDRESS], "cc_recipients": [],
+        "received_date": "2026-03-02 10:00:00",
+    },
+    {
+        "id": "t-3", "sender": MY_ADDRESS,
+        "to_recipients": ["vendor@fabrikam.com"], "cc_recipients": ["bob@contoso.com", "Vendor@fabrikam.com"],
+        "received_date": "2026-03-03 11:00:00",
+    },
+]
+
+# The vendor's colleague replied after the last sync
+UNSYNCED = {
+    "id": "t-4", "subject": "Re: Renewal", "sender": "partner@fabrikam.com", "to": [MY_ADDRESS], "cc": [],
+    "received_time": "2026-03-04T08:00:00Z", "conversation_id": "thread-1", "folder": "Inbox", "is_read": False,
+}
+
+
+@pytest.fixture
+def store():
+    """In-memory store holding the synced part of the thread."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        for email in THREAD:
+            conn.execute(
+                """
+                INSERT INTO emails (id, subject, sender, to_recipients, cc_recipients, received_date,
+                                    conversation_id, user_id)
+                VALUES (?, 'Renewal', ?, ?, ?, ?, 'thread-1', ?)
+                """,
+                (email["id"], email["sender"], json.dumps(email["to_recipients"]),
+                 json.dumps(email["cc_recipients"]), email["received_date"], USER_ID)
+            )
+        conn.commit()
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def provider():
+    """Authenticated mock provider holding the whole thread."""
+    provider = MockEmailProvider()
+    provider.authenticate({})
+    provider.mock_emails = [
+        {**THREAD[2], "to": THREAD[2]["to_recipients"], "cc": THREAD[2]["cc_recipients"],
+         "conversation_id": "thread-1"},
+        UNSYNCED,
+    ]
+    return provider
+
+
+def by_address(participants):
+    """Participants keyed by address."""
+    return {participant.address: participant for participant in participants}
+
+
+class TestSummarizeParticipants:
+    """Tests for summarize_participants."""
+
+    def test_mixed_thread(self):
+        """Test counts, activity times, and internal flags across a mixed thread."""
+        participants = summarize_participants(THREAD, MY_ADDRESS)
+
+        assert [p.address for p in participants] == [
+            "me@contoso.com", "vendor@fabrikam.com", "alice@contoso.com", "bob@contoso.com"
+        ]
+        found = by_address(participants)
+        assert (found["me@contoso.com"].sent_count, found["me@contoso.com"].received_count) == (1, 2)
+        assert found["alice@contoso.com"].message_count == 2
+        assert found["alice@contoso.com"].first_activity == datetime(2026, 3, 1, 9, 0)
+        assert found["alice@contoso.com"].last_activity == datetime(2026, 3, 2, 10, 0)
+        assert found["bob@contoso.com"].first_activity == found["bob@contoso.com"].last_activity
+        assert {p.address: p.internal for p in participants} == {
+            "me@contoso.com": True, "vendor@fabrikam.com": False,
+            "alice@contoso.com": True, "bob@contoso.com": True,
+        }
+
+    def test_recipient_counted_once_per_message(self):
+        """Test that an address on both To and Cc of a message counts once."""
+        vendor = by_address(summarize_participants(THREAD, MY_ADDRESS))["vendor@fabrikam.com"]
+
+        assert (vendor.message_count, vendor.sent_count, vendor.received_count) == (3, 1, 2)
+
+    def test_unknown_address(self):
+        """Test that internal is None without the user's address."""
+        assert {p.internal for p in summarize_participants(THREAD)} == {None}
+
+    def test_provider_recipient_fallback(self):
+        """Test that provider emails without to/cc use the single recipient."""
+        participants = summarize_participants(
+            [{"id": "p-1", "sender": "a@x.com", "recipient": "b@x.com", "received_time": "2026-03-01T09:00:00Z"}],
+            "b@x.com"
+        )
+
+        assert [(p.address, p.internal) for p in participants] == [("a@x.com", True), ("b@x.com", True)]
+
+
+class TestGetConversationParticipants:
+    """Tests for EmailService.get_conversation_participants."""
+
+    def test_adds_unsynced_provider_messages(self, store, provider):
+        """Test that thread messages only the provider has are counted once."""
+        service = EmailService(provider, db=store)
+
+        result = asyncio.run(service.get_conversation_participants("thread-1", USER_ID, MY_ADDRESS))
+
+        assert result.message_count == 4
+        assert result.provider_only_count == 1
+        found = by_address(result.participants)
+        assert found["partner@fabrikam.com"].internal is False
+        assert found["partner@fabrikam.com"].first_activity == datetime(2026, 3, 4, 8, 0)
+        assert found["me@contoso.com"].received_count == 3
+        assert found["me@contoso.com"].sent_count == 1
+
+    def test_provider_unavailable(self, store):
+        """Test that the stored thread is used alone when the provider fails."""
+        service = EmailService(MockEmailProvider(), db=store)  # Not authenticated
+
+        result = asyncio.run(service.get_conversation_participants("thread-1", USER_ID, MY_ADDRESS))
+
+        assert result.message_count == 3
+        assert result.provider_only_count == 0
+        assert "partner@fabrikam.com" not in by_address(result.participants)
+
+    def test_other_users_emails_hidden(self, store):
+        """Test that another user's stored thread is not read."""
+        service = EmailService(MockEmailProvider(), db=store)
+
+        result = asyncio.run(service.get_conversation_participants("thread-1", USER_ID + 1, MY_ADDRESS))
+
+        assert result.message_count == 0
+        assert result.participants == []
+
+
+class TestEndpoint:
+    """Tests for GET /api/conversations/{conversation_id}/participants."""
+
+    @pytest.fixture
+    def client(self, store, provider):
+        """Client with auth and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="me", email=MY_ADDRESS,
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: EmailService(provider, db=store)
+        return TestClient(app)
+
+    def test_get_participants(self, client):
+        """Test that the participants are returned with the user's domain internal."""
+        response = client.get("/api/conversations/thread-1/participants")
+
+        assert response.status_code == 200
+        data = response.json()
+        assert data["conversation_id"] == "thread-1"
+        assert data["message_count"] == 4
+        assert data["provider_only_count"] == 1
+        assert {p["address"]: p["internal"] for p in data["participants"]} == {
+            "me@contoso.com": True, "vendor@fabrikam.com": False, "alice@contoso.com": True,
+            "bob@contoso.com": True, "partner@fabrikam.com": False,
+        }
+
+    def test_unknown_conversation(self, client):
+        """Test that a conversation nobody has is a 404."""
+        response = client.get("/api/conversations/nope/participants")
+
+        assert response.status_code == 404
+        assert response.json()["error"]["code"] == "not_found"
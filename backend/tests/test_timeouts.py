@@ -0,0 +1,89 @@
+"""Tests for per-route request time limits."""
+
+import asyncio
+
+import pytest
+from fastapi import FastAPI, Request
+from fastapi.testclient import TestClient
+
+from backend.core.errors import register_error_handlers
+from backend.core.timeouts import (
+    DEFAULT_TIMEOUT_SECONDS, ROUTE_TIMEOUTS, compile_timeouts, register_timeout_middleware, route_timeout
+)
+from backend.services.email_service import cancel_on_disconnect
+
+
+@pytest.mark.parametrize("path,seconds", [
+    ("/api/ai/classify", 120),
+    ("/api/ai/summarize/batch", 300),
+    ("/api/emails/batch-process", 300),
+    ("/api/emails/abc/move", 45),
+    ("/api/emails", 45),
+    ("/api/folders", 45),
+    ("/api/views/3/emails", 45),
+    ("/api/emails/abc/folder-suggestions", 120),
+    ("/api/tasks", DEFAULT_TIMEOUT_SECONDS),
+    ("/api/emailsettings", DEFAULT_TIMEOUT_SECONDS),
+    ("/api/processing/ws/p1", None),
+])
+def test_route_budgets(path, seconds):
+    """Test that each kind of route gets its budget from the table."""
+    assert route_timeout(path, compile_timeouts(ROUTE_TIMEOUTS)) == seconds
+
+
+class TestTimeoutMiddleware:
+    """Tests for cutting off requests that run past their budget."""
+
+    @pytest.fixture
+    def state(self):
+        """What the slow handler saw."""
+        return {}
+
+    @pytest.fixture
+    def client(self, state):
+        """App with a slow, a fast, and an exempt route under a tiny default budget."""
+        app = FastAPI()
+        register_error_handlers(app)
+        register_timeout_middleware(app, timeouts=((r"^/exempt$", None),), default_seconds=0.05)
+
+        async def slow_work():
+            try:
+                await asyncio.sleep(2)
+            except asyncio.CancelledError:
+                state["cancelled"] = True
+                raise
+
+        @app.get("/slow")
+        async def slow(request: Request):
+            await cancel_on_disconnect(request, slow_work())
+            return {"done": True}
+
+        @app.get("/fast")
+        async def fast():
+            await asyncio.sleep(0.01)
+            return {"done": True}
+
+        @app.get("/exempt")
+        async def exempt():
+            await asyncio.sleep(0.1)
+            return {"done": True}
+
+        return TestClient(app)
+
+    def test_over_budget_is_504_and_cancelled(self, client, state):
+        """Test that a request past its budget gets a 504 envelope and its work is cancelled."""
+        response = client.get("/slow", headers={"X-Request-ID": "req-slow"})
+
+        assert response.status_code == 504
+        assert response.json()["error"]["code"] == "timeout"
+        assert response.json()["error"]["request_id"] == "req-slow"
+        assert response.headers["X-Request-ID"] == "req-slow"
+        assert state == {"cancelled": True}
+
+    def test_within_budget(self, client):
+        """Test that requests finishing in time are untouched."""
+        assert client.get("/fast").json() == {"done": True}
+
+    def test_exempt_routes(self, client):
+        """Test that routes mapped to None may run past the default budget."""
+        assert client.get("/exempt").status_code == 200
@@ -0,0 +1,223 @@
+"""Tests for time zone aware "today", overdue, and due date handling."""
+
+import asyncio
+from datetime import date, datetime, timedelta, timezone
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.user_settings import router
+from backend.core.clock import Clock, clock_for, parse_timezone
+from backend.core.config import settings
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.task import TaskCreate
+from backend.models.user import UserInDB, UserSettingsUpdate
+from backend.services.action_items import category_task_fields, normalize_action_item, task_fields_for_item
+from backend.services.task_service import TaskService
+from backend.services.user_settings_service import UserSettingsService, get_user_settings_service
+
+USER_ID = 1
+NEW_YORK = parse_timezone("America/New_York")
+
+
+def utc(*args):
+    """Aware UTC datetime."""
+    return datetime(*args, tzinfo=timezone.utc)
+
+
+def elapsed(start, end):
+    """Real time between two aware datetimes (same-zone subtraction ignores DST)."""
+    return end.astimezone(timezone.utc) - start.astimezone(timezone.utc)
+
+
+class TestClock:
+    """Tests for Clock day boundaries."""
+
+    def test_spring_forward_day_is_23_hours(self):
+        """Test that the day clocks go forward is 23 hours long."""
+        clock = Clock(NEW_YORK)
+
+        start, end = clock.start_of_day(date(2024, 3, 10)), clock.end_of_day(date(2024, 3, 10))
+
+        assert (start.isoformat(), end.isoformat()) == ("2024-03-10T00:00:00-05:00", "2024-03-11T00:00:00-04:00")
+        assert elapsed(start, end) == timedelta(hours=23)
+
+    def test_fall_back_day_is_25_hours(self):
+        """Test that the day clocks go back is 25 hours long."""
+        clock = Clock(NEW_YORK)
+
+        start, end = clock.start_of_day(date(2024, 11, 3)), clock.end_of_day(date(2024, 11, 3))
+
+        assert elapsed(start, end) == timedelta(hours=25)
+
+    def test_naive_now_is_utc(self):
+        """Test that a naive reference time is read as UTC."""
+        clock = Clock(NEW_YORK, now=datetime(2024, 3, 11, 3, 30))
+
+        assert clock.now().isoformat() == "2024-03-10T23:30:00-04:00"
+        assert clock.today() == date(2024, 3, 10)
+
+    def test_without_zone_keeps_naive_local_times(self):
+        """Test that a clock without a zone behaves as before zones were configurable."""
+        clock = Clock(now=datetime(2024, 3, 10, 12, 0))
+
+        assert clock.end_of_day() == datetime(2024, 3, 11)
+        assert clock.localize(datetime(2024, 3, 10, 9, 0)).tzinfo is None
+
+    def test_unknown_zone(self):
+        """Test that unknown names are rejected, and fall back to local time for clocks."""
+        with pytest.raises(ValueError, match="Unknown time zone"):
+            parse_timezone("Mars/Olympus_Mons")
+        assert clock_for("Mars/Olympus_Mons").tz is None
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+@pytest.fixture
+def user_settings(store):
+    """User settings service with USER_ID in New York."""
+    service = UserSettingsService(db=store)
+    asyncio.run(service.update_settings(USER_ID, UserSettingsUpdate(timezone="America/New_York")))
+    return service
+
+
+@pytest.fixture
+def task_service(store, user_settings):
+    """Task service over the store."""
+    return TaskService(db=store)
+
+
+def add_tasks(task_service, due_dates, user_id=USER_ID):
+    """Create one open task per due date."""
+    for index, due_date in enumerate(due_dates):
+        asyncio.run(task_service.create_task(TaskCreate(title=f"task {index}", due_date=due_date), user_id))
+
+
+class TestDueCounts:
+    """Tests for overdue and due today in the user's time zone."""
+
+    # 23:30 on the spring-forward day in New York, already the next day in UTC
+    NOW = utc(2024, 3, 11, 3, 30)
+
+    def test_today_ends_at_local_midnight(self, task_service):
+        """Test that today ends at midnight EDT (04:00 UTC), not at UTC midnight or 24 hours after EST."""
+        add_tasks(task_service, [
+            utc(2024, 3, 11, 3, 0),       # 23:00 EDT, overdue
+            utc(2024, 3, 11, 3, 59, 59),  # 23:59:59 EDT, due today
+            utc(2024, 3, 11, 4, 0),       # midnight EDT, tomorrow
+            utc(2024, 3, 11, 4, 30),      # 00:30 EDT, which a 24-hour day from midnight EST would count
+        ])
+
+        assert asyncio.run(task_service.get_due_counts(USER_ID, now=self.NOW)) == {"overdue": 1, "due_today": 1}
+
+    def test_offsets_and_naive_utc_compare_alike(self, task_service):
+        """Test that due dates stored with an offset or as naive UTC count the same."""
+        add_tasks(task_service, [
+            datetime(2024, 3, 10, 23, 45, tzinfo=NEW_YORK),
+            datetime(2024, 3, 11, 3, 50),
+        ])
+
+        assert asyncio.run(task_service.get_due_counts(USER_ID, now=self.NOW)) == {"overdue": 0, "due_today": 2}
+
+    def test_fall_back_day(self, task_service):
+        """Test that the extra hour of the fall-back day is still today."""
+        add_tasks(task_service, [utc(2024, 11, 4, 4, 30)])  # 23:30 EST on November 3
+
+        counts = asyncio.run(task_service.get_due_counts(USER_ID, now=utc(2024, 11, 3, 5, 30)))  # 01:30 EDT
+
+        assert counts == {"overdue": 0, "due_today": 1}
+
+    def test_user_without_zone_uses_settings(self, task_service, monkeypatch):
+        """Test that users without a zone get settings.timezone."""
+        monkeypatch.setattr(settings, "timezone", "Asia/Tokyo")
+        add_tasks(task_service, [utc(2024, 3, 11, 10, 0)], user_id=USER_ID + 1)  # 19:00 on March 11 in Tokyo
+
+        # 05:00 on March 11 in Tokyo, still March 10 in UTC
+        counts = asyncio.run(task_service.get_due_counts(USER_ID + 1, now=utc(2024, 3, 10, 20, 0)))
+
+        assert counts == {"overdue": 0, "due_today": 1}
+
+
+class TestDueDateDefaults:
+    """Tests for end-of-day due dates in the user's time zone."""
+
+    @pytest.fixture(autouse=True)
+    def task_defaults(self, monkeypatch):
+        """Same-day due dates for team_action."""
+        monkeypatch.setattr(settings, "task_defaults", {"team_action": {"due_in_business_days": 0}})
+        monkeypatch.setattr(settings, "task_holidays", [])
+
+    def test_business_day_counted_from_local_today(self):
+        """Test that Friday evening in New York is still a business day there, though Saturday in UTC."""
+        clock = Clock(NEW_YORK, now=utc(2024, 3, 9, 2, 0))  # Friday 21:00 EST
+
+        due = category_task_fields("team_action", clock=clock)["due_date"]
+
+        assert due.isoformat() == "2024-03-08T23:59:59-05:00"
+
+    def test_deadline_is_local_wall_time(self):
+        """Test that a date-only deadline ends that day in the user's zone, after the DST change."""
+        item = normalize_action_item({"description": "Send deck", "deadline": "2024-03-10"})
+
+        fields = task_fields_for_item(item, "fyi", clock=Clock(NEW_YORK))
+
+        assert fields["due_date"].isoformat() == "2024-03-10T23:59:59-04:00"
+
+    def test_extracted_tasks_due_in_user_zone(self, task_service):
+        """Test that tasks created from action items are stored and returned with the user's offset."""
+        created = asyncio.run(task_service.create_tasks_from_action_items(
+            "email-1", [{"description": "Send deck", "deadline": "2024-03-10"}], USER_ID
+        ))
+
+        assert created[0].due_date.isoformat() == "2024-03-10T23:59:59-04:00"
+        counts = asyncio.run(task_service.get_due_counts(USER_ID, now=utc(2024, 3, 11, 3, 30)))
+        assert counts == {"overdue": 0, "due_today": 1}
+
+
+class TestUserSettingsAPI:
+    """Tests for GET and PATCH /api/user/settings."""
+
+    @pytest.fixture
+    def client(self, user_settings):
+        """Client with auth and the user settings service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="traveler", email="traveler@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_user_settings_service] = lambda: user_settings
+        return TestClient(app)
+
+    def test_get(self, client):
+        """Test that the user's zone is returned with the zone in effect."""
+        assert client.get("/api/user/settings").json() == {
+            "timezone": "America/New_York", "effective_timezone": "America/New_York"
+        }
+
+    def test_update(self, client):
+        """Test that a zone can be changed and cleared back to settings.timezone."""
+        assert client.patch("/api/user/settings", json={"timezone": "Europe/Berlin"}).json()["timezone"] == (
+            "Europe/Berlin"
+        )
+
+        cleared = client.patch("/api/user/settings", json={"timezone": None}).json()
+
+        assert cleared == {"timezone": None, "effective_timezone": settings.timezone}
+
+    def test_unknown_zone_rejected(self, client):
+        """Test that a name that is not an IANA zone is a 422."""
+        response = client.patch("/api/user/settings", json={"timezone": "Eastern"})
+
+        assert response.status_code == 422
+        assert client.get("/api/user/settings").json()["timezone"] == "America/New_York"
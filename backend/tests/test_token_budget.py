@@ -0,0 +1,190 @@
+"""Tests for per-run token budgets in the processing pipeline."""
+
+import asyncio
+from unittest.mock import AsyncMock, patch
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.processing import router
+from backend.core.config import settings
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.services.classification_store import ClassificationStore
+from backend.services.job_queue import JobQueue, JobStatus
+from backend.workers.email_processor import EmailProcessorWorker, MockEmailService, MockTaskService, tokens_used
+
+USER_ID = "user_1"
+
+EMAIL_IDS = ["first", "second", "third"]
+
+# Tokens the fake AI client reports for every call
+TOKENS_PER_CALL = 100
+
+
+class FakeAIService:
+    """Mock AI client reporting a fixed token count for each call."""
+
+    def __init__(self):
+        self.tokens_used = 0
+        self.calls = []
+
+    def _use(self, email_data):
+        self.tokens_used += TOKENS_PER_CALL
+        self.calls.append(email_data["id"])
+
+    async def analyze_email(self, email_data):
+        self._use(email_data)
+        return {"priority": "medium"}
+
+    async def extract_tasks(self, email_data):
+        self._use(email_data)
+        return []
+
+    async def categorize_email(self, email_data):
+        self._use(email_data)
+        return {"category": "fyi", "confidence": 0.9}
+
+
+@pytest.fixture
+def queue():
+    """Isolated job queue patched in for the worker and API."""
+    job_queue = JobQueue()
+    with patch("backend.workers.email_processor.job_queue", job_queue), \
+            patch("backend.api.processing.job_queue", job_queue):
+        yield job_queue
+
+
+@pytest.fixture
+def websocket():
+    """WebSocket manager with sends recorded."""
+    with patch("backend.workers.email_processor.websocket_manager") as manager:
+        manager.broadcast_job_status = AsyncMock()
+        manager.send_processing_complete = AsyncMock()
+        yield manager
+
+
+@pytest.fixture
+def worker():
+    """Worker with a token-counting fake AI client over an in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    worker = EmailProcessorWorker()
+    worker.ai_service = FakeAIService()
+    worker.email_service = MockEmailService()
+    worker.task_service = MockTaskService()
+    worker._classification_store = ClassificationStore(db=db)
+    yield worker
+    db.close()
+
+
+async def drain(queue, worker):
+    """Run every queued job, as the processing loop would."""
+    while (job := await queue.get_next_job()) is not None:
+        await worker._process_job(job)
+
+
+class TestTokensUsed:
+    """Tests for reading an AI service's token count."""
+
+    def test_services_without_tracking_count_zero(self):
+        """Test that services without a tokens_used count report none."""
+        assert tokens_used(object()) == 0
+        assert tokens_used(FakeAIService()) == 0
+
+
+class TestBudget:
+    """Tests for stopping a run at its token budget."""
+
+    @pytest.mark.asyncio
+    async def test_stops_dispatching_at_budget(self, queue, websocket, worker):
+        """Test that jobs after the budget is used up are skipped."""
+        pipeline_id = await queue.create_pipeline(EMAIL_IDS, USER_ID, max_tokens_budget=250)
+
+        await drain(queue, worker)
+
+        pipeline = await queue.get_pipeline(pipeline_id)
+        statuses = [job.status for job in pipeline.jobs]
+        assert statuses == [JobStatus.COMPLETED] * 3 + [JobStatus.SKIPPED_BUDGET] * 6
+        # The call that crossed the budget was already dispatched and finished
+        assert worker.ai_service.calls == ["first"] * 3
+        assert pipeline.tokens_consumed == 300
+        assert pipeline.status == "budget_exhausted"
+        assert pipeline.jobs[-1].progress.message == "Skipped: token budget exhausted"
+
+    @pytest.mark.asyncio
+    async def test_completion_event_reports_tokens(self, queue, websocket, worker):
+        """Test that the completion event carries consumed and budget tokens."""
+        pipeline_id = await queue.create_pipeline(EMAIL_IDS, USER_ID, max_tokens_budget=250)
+
+        await drain(queue, worker)
+
+        websocket.send_processing_complete.assert_awaited_once()
+        event = websocket.send_processing_complete.await_args.args[1]
+        assert event["status"] == "budget_exhausted"
+        assert (event["tokens_consumed"], event["max_tokens_budget"]) == (300, 250)
+
+    @pytest.mark.asyncio
+    async def test_unlimited_budget(self, queue, websocket, worker):
+        """Test that a zero budget runs every job."""
+        pipeline_id = await queue.create_pipeline(EMAIL_IDS, USER_ID)
+
+        await drain(queue, worker)
+
+        pipeline = await queue.get_pipeline(pipeline_id)
+        assert pipeline.status == "completed"
+        assert pipeline.tokens_consumed == 900
+
+    @pytest.mark.asyncio
+    async def test_requeued_retry_is_skipped(self, queue, websocket, worker):
+        """Test that a job re-queued for retry after the budget ran out is not dispatched."""
+        pipeline_id = await queue.create_pipeline(["first"], USER_ID, max_tokens_budget=100)
+        pipeline = await queue.get_pipeline(pipeline_id)
+        retried = pipeline.jobs[1]
+
+        await worker._process_job(await queue.get_next_job())
+        retried.status = JobStatus.QUEUED
+
+        assert await queue.get_next_job() is None
+        assert retried.status == JobStatus.SKIPPED_BUDGET
+
+
+class TestBudgetAPI:
+    """Tests for max_tokens_budget on POST /processing/start and the status endpoint."""
+
+    @pytest.fixture
+    def client(self, queue):
+        """Client with auth overridden and the worker not started."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: {"user_id": USER_ID}
+        with patch("backend.api.processing.email_processor_worker") as background_worker:
+            background_worker.start = AsyncMock()
+            yield TestClient(app)
+
+    def start(self, client, **options):
+        """Start a run over EMAIL_IDS and return the response data."""
+        return client.post("/api/processing/start", json={
+            "email_ids": EMAIL_IDS, "skip_already_processed": False, **options
+        }).json()
+
+    def test_budget_from_request(self, client, queue, websocket, worker):
+        """Test that the status reports consumed against the requested budget."""
+        data = self.start(client, max_tokens_budget=250)
+        assert data["max_tokens_budget"] == 250
+
+        asyncio.run(drain(queue, worker))
+
+        status = client.get(f"/api/processing/{data['pipeline_id']}/status").json()
+        assert status["status"] == "budget_exhausted"
+        assert (status["jobs_completed"], status["jobs_skipped"]) == (3, 6)
+        assert (status["tokens_consumed"], status["max_tokens_budget"]) == (300, 250)
+
+    def test_budget_defaults_from_settings(self, client, queue, monkeypatch):
+        """Test that runs without a budget use the configured default."""
+        monkeypatch.setattr(settings, "pipeline_max_tokens_budget", 200000)
+
+        assert self.start(client)["max_tokens_budget"] == 200000
+        assert self.start(client, max_tokens_budget=0)["max_tokens_budget"] == 0
@@ -0,0 +1,199 @@
+"""Tests for the training data export and offline classifier evaluation."""
+
+import asyncio
+import json
+from datetime import datetime
+from unittest.mock import AsyncMock, MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api.admin import router
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_service import EmailService
+from backend.services.training_data import sample_labeled
+
+USER_ID = 1
+PROMPT = "email_classifier_with_explanation@2.0"
+
+
+@pytest.fixture
+def service():
+    """Email service over a store with corrected, confirmed, untouched, and re-overwritten emails.
+
+    corrected: AI said fyi, the user corrected it to team_action
+    confirmed: AI said newsletter, the user set newsletter again
+    untouched: AI said fyi, nobody looked
+    overwritten: corrected to team_action, then the AI classified it as fyi again
+    """
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    with db.get_connection() as conn:
+        conn.executemany(
+            """
+            INSERT INTO emails (id, subject, sender, content, category, confidence, ai_category, ai_confidence,
+                                ai_prompt_version, user_id)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+            """,
+            [
+                ("corrected", "Server access", "ops@contoso.com",
+                 "Log in with password=hunter2 and tell dana@contoso.com.", "fyi", 0.7, "fyi", 0.7, PROMPT, USER_ID),
+                ("confirmed", "Weekly digest", "news@fabrikam.com", "This week in tech.",
+                 "newsletter", 0.6, "newsletter", 0.6, PROMPT, USER_ID),
+                ("untouched", "Lunch", "peer@contoso.com", "Pizza at noon.", "fyi", 0.9, "fyi", 0.9, PROMPT, USER_ID),
+                ("overwritten", "Standup", "lead@contoso.com", "Notes.", "fyi", 0.8, "fyi", 0.8, PROMPT, USER_ID),
+            ]
+        )
+        conn.execute(
+            "INSERT INTO classification_history (email_id, user_id, previous_category, category, source) "
+            "VALUES ('overwritten', ?, 'fyi', 'team_action', 'user')",
+            (USER_ID,)
+        )
+        conn.commit()
+    email_service = EmailService(MagicMock(), db=db)
+    asyncio.run(email_service.update_classifications(
+        USER_ID, [("corrected", "team_action"), ("confirmed", "newsletter")]
+    ))
+    yield email_service
+    db.close()
+
+
+@pytest.fixture
+def ai():
+    """Stub AI filing everything as team_action."""
+    stub = MagicMock()
+    stub.classify_email_async = AsyncMock(return_value={"category": "team_action", "confidence": 0.8})
+    return stub
+
+
+@pytest.fixture
+def client(service, ai):
+    """Client for the admin endpoints over the store and the stub AI."""
+    app = FastAPI()
+    register_error_handlers(app)
+    app.include_router(router, prefix="/api")
+    app.dependency_overrides[get_current_user] = lambda: UserInDB(
+        id=USER_ID, username="trainer", email="trainer@example.com", hashed_password="x", created_at=datetime.now()
+    )
+    app.dependency_overrides[get_email_service] = lambda: service
+    app.dependency_overrides[get_ai_service] = lambda: ai
+    return TestClient(app)
+
+
+def export(client, **params):
+    """Export and return the response with its parsed lines."""
+    response = client.get("/api/admin/training-export", params=params)
+    return response, [json.loads(line) for line in response.text.splitlines()]
+
+
+class TestTrainingExport:
+    """Tests for GET /api/admin/training-export."""
+
+    def test_only_human_labels_are_exported(self, client):
+        """Test that corrected and confirmed emails are exported with the AI's original answer."""
+        response, records = export(client)
+
+        assert response.status_code == 200
+        assert response.headers["content-type"].startswith("application/x-ndjson")
+        assert [r["email_id"] for r in records] == ["confirmed", "corrected"]
+        assert records[1] == {
+            "email_id": "corrected",
+            "subject": "Server access",
+            "sender": "ops@contoso.com",
+            "excerpt": "Log in with password=[REDACTED:password] and tell dana@contoso.com.",
+            "category": "team_action",
+            "label_source": "corrected",
+            "ai_category": "fyi",
+            "ai_confidence": 0.7,
+            "prompt_version": PROMPT,
+        }
+        assert (records[0]["category"], records[0]["label_source"]) == ("newsletter", "confirmed")
+
+    def test_secrets_masked_while_redaction_is_off(self, client, monkeypatch):
+        """Test that the export masks secrets even when AI-bound redaction is turned off."""
+        monkeypatch.setattr(settings, "redact_secrets", False)
+
+        _, records = export(client)
+
+        assert "hunter2" not in json.dumps(records)
+
+    def test_anonymized(self, client):
+        """Test that the anonymized level fakes IDs and addresses but keeps labels."""
+        _, records = export(client, redaction="anonymized")
+
+        corrected = next(r for r in records if r["label_source"] == "corrected")
+        assert corrected["email_id"].startswith("email-")
+        assert corrected["sender"].endswith(".invalid")
+        assert "dana@contoso.com" not in corrected["excerpt"]
+        assert "[REDACTED:password]" in corrected["excerpt"]
+        assert (corrected["category"], corrected["ai_category"]) == ("team_action", "fyi")
+
+    def test_size_cap_keeps_whole_lines(self, client):
+        """Test that the stream stops at the last whole line within max_bytes."""
+        first_line = client.get("/api/admin/training-export").content.split(b"\n")[0] + b"\n"
+
+        response, records = export(client, max_bytes=len(first_line) + 10)
+
+        assert response.content == first_line
+        assert len(records) == 1
+
+    def test_unknown_redaction_level(self, client):
+        """Test that an unknown redaction level is a validation error."""
+        assert client.get("/api/admin/training-export", params={"redaction": "none"}).status_code == 422
+
+
+class TestSampling:
+    """Tests for drawing the evaluation sample."""
+
+    def test_seed_picks_the_same_sample(self):
+        """Test that a seed gives the same sample whatever order the IDs came in."""
+        ids = [f"email-{n}" for n in range(50)]
+
+        sample = sample_labeled(ids, 10, seed=7)
+
+        assert sample == sample_labeled(list(reversed(ids)), 10, seed=7)
+        assert len(set(sample)) == 10 and set(sample) <= set(ids)
+
+    def test_sample_is_capped_at_the_population(self):
+        """Test that asking for more emails than are labeled samples each once."""
+        assert sorted(sample_labeled(["b", "a", "a"], 10, seed=1)) == ["a", "b"]
+        assert sample_labeled([], 10) == []
+
+
+class TestEvaluate:
+    """Tests for POST /api/admin/evaluate."""
+
+    def test_agreement_with_labels(self, client, service, ai):
+        """Test that agreement is reported overall and per category and nothing is stored."""
+        data = client.post("/api/admin/evaluate", json={"sample_size": 10, "seed": 3}).json()
+
+        assert (data["labeled"], data["sampled"], data["evaluated"], data["failed"]) == (2, 2, 2, 0)
+        assert (data["agreed"], data["agreement"], data["stored_agreement"]) == (1, 0.5, 0.5)
+        assert data["categories"] == [
+            {"category": "newsletter", "emails": 1, "agreed": 0, "agreement": 0.0},
+            {"category": "team_action", "emails": 1, "agreed": 1, "agreement": 1.0},
+        ]
+        assert data["disagreements"] == [
+            {"email_id": "confirmed", "category": "newsletter", "predicted": "team_action", "confidence": 0.8}
+        ]
+        assert ai.classify_email_async.await_count == 2
+        stored = asyncio.run(service.get_stored_emails(["confirmed"], USER_ID))["confirmed"]
+        assert (stored["category"], stored["ai_category"], stored["ai_confidence"]) == ("newsletter", "newsletter", 0.6)
+
+    def test_failures_are_left_out(self, client, ai):
+        """Test that emails the classifier fails on are counted apart from agreement."""
+        ai.classify_email_async = AsyncMock(side_effect=RuntimeError("model down"))
+
+        data = client.post("/api/admin/evaluate", json={"sample_size": 1}).json()
+
+        assert (data["sampled"], data["evaluated"], data["failed"], data["agreement"]) == (1, 0, 1, None)
+
+    def test_sample_size_is_bounded(self, client):
+        """Test that an empty or oversized sample is a validation error."""
+        assert client.post("/api/admin/evaluate", json={"sample_size": 0}).status_code == 422
+        assert client.post("/api/admin/evaluate", json={"sample_size": 1000}).status_code == 422
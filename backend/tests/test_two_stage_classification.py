@@ -0,0 +1,246 @@
+"""Tests for two-stage batch classification: a quick triage, then the full classifier when needed."""
+
+from datetime import datetime
+from unittest.mock import AsyncMock, MagicMock
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from backend.api import emails as emails_api
+from backend.api.auth import get_current_user
+from backend.core.config import settings
+from backend.core.dependencies import get_ai_service, get_email_service
+from backend.core.errors import register_error_handlers
+from backend.database.connection import DatabaseManager
+from backend.models.user import UserInDB
+from backend.services.email_service import EmailService
+from backend.services.thread_classifier import classify_batch
+from backend.services.triage import (
+    TRIAGE_CONTENT_CHARS, TRIAGE_REASONING, TRIAGE_TEMPLATE, build_triage_inputs, needs_detailed_pass,
+    parse_triage, template_deployment
+)
+
+USER_ID = 1
+CATEGORIES = ["fyi", "newsletter", "team_action", "required_personal_action"]
+
+# Subject -> what the triage says about the email
+TRIAGES = {
+    "Weekly digest": {"category": "newsletter", "confidence": 0.95},
+    "Build finished": {"category": "fyi", "confidence": 0.85},
+    "Maybe news": {"category": "fyi", "confidence": 0.4},
+    "Please review": {"category": "team_action", "confidence": 0.99},
+    "Garbled": {"error": "model down"},
+}
+
+
+def make_email(subject, conversation_id=None, minute=0):
+    """Build a provider-style email dict named after its subject."""
+    return {
+        "id": subject.lower().replace(" ", "-"),
+        "subject": subject,
+        "sender": "alice@example.com",
+        "body": "Some text.",
+        "received_time": f"2024-01-01T10:{minute:02d}:00Z",
+        "conversation_id": conversation_id,
+    }
+
+
+@pytest.fixture
+def fake_ai():
+    """Fake AI client triaging by subject and classifying everything in full as fyi."""
+    ai = MagicMock()
+
+    async def triage(subject, content, sender):
+        return dict(TRIAGES[subject])
+
+    ai.triage_email_async = AsyncMock(side_effect=triage)
+    ai.classify_email_async = AsyncMock(return_value={
+        "category": "fyi", "confidence": 0.9, "reasoning": "Looked closely"
+    })
+    return ai
+
+
+@pytest.fixture
+def store():
+    """Isolated in-memory store."""
+    db = DatabaseManager(DatabaseManager.MEMORY_PATH)
+    yield db
+    db.close()
+
+
+def detailed_subjects(ai):
+    """Subjects the full classifier was called for, in call order."""
+    return [call.kwargs["subject"] for call in ai.classify_email_async.await_args_list]
+
+
+class TestTriageParsing:
+    """Tests for the triage prompt's inputs, output, and routing."""
+
+    def test_inputs_are_short(self):
+        """Test that the triage gets the headers and only the opening of the body."""
+        inputs = build_triage_inputs("Q4", "bob@example.com", "Numbers\n\n" + "x" * 1000, CATEGORIES)
+
+        assert inputs["snippet"] == "Numbers " + "x" * (TRIAGE_CONTENT_CHARS - len("Numbers ")) + "..."
+        assert inputs["category_names"] == "fyi, newsletter, team_action, required_personal_action"
+
+    @pytest.mark.parametrize("raw", [
+        {"category": "fyi", "confidence": 1.7},
+        '```json\n{"category": "fyi", "confidence": 1}\n```',
+    ])
+    def test_parse(self, raw):
+        """Test that output is read from a dict or fenced JSON with the confidence clamped."""
+        assert parse_triage(raw, CATEGORIES) == {"category": "fyi", "confidence": 1.0}
+
+    @pytest.mark.parametrize("raw", [
+        "not json", {"category": "made_up", "confidence": 0.9}, {"category": "fyi"},
+        {"category": "fyi", "confidence": True}, ["fyi"],
+    ])
+    def test_parse_rejects(self, raw):
+        """Test that output without a known category and a numeric confidence is an error."""
+        with pytest.raises(ValueError):
+            parse_triage(raw, CATEGORIES)
+
+    @pytest.mark.parametrize("triage,expected", [
+        ({"category": "newsletter", "confidence": 0.8}, False),
+        ({"category": "newsletter", "confidence": 0.79}, True),
+        ({"category": "team_action", "confidence": 1.0}, True),
+        ({"category": "optional_action", "confidence": 1.0}, True),
+        ({"error": "model down"}, True),
+    ])
+    def test_needs_detailed_pass(self, triage, expected):
+        """Test that low confidence, action categories, and failures go on to the full classifier."""
+        assert needs_detailed_pass(triage, 0.8) is expected
+
+    def test_triage_deployment(self, monkeypatch):
+        """Test that only the triage template runs on triage_deployment, when one is set."""
+        assert template_deployment(TRIAGE_TEMPLATE) is None
+        monkeypatch.setattr(settings, "triage_deployment", "gpt-4o-mini")
+
+        assert template_deployment(TRIAGE_TEMPLATE) == "gpt-4o-mini"
+        assert template_deployment("email_classifier_with_explanation.prompty") is None
+
+
+class TestTwoStageBatch:
+    """Tests for classify_batch with the two_stage strategy."""
+
+    @pytest.mark.asyncio
+    async def test_only_unsettled_emails_reach_stage_two(self, fake_ai, store):
+        """Test that confident non-action triages are kept and the rest are classified in full."""
+        emails = [make_email(subject) for subject in TRIAGES]
+
+        outcome = await classify_batch(
+            emails, fake_ai, EmailService(MagicMock(), db=store), USER_ID,
+            strategy="two_stage", triage_threshold=0.8
+        )
+
+        assert detailed_subjects(fake_ai) == ["Maybe news", "Please review", "Garbled"]
+        assert fake_ai.triage_email_async.await_count == 5
+        results = {result.email_id: result for result in outcome.results}
+        assert (results["weekly-digest"].category, results["weekly-digest"].confidence) == ("newsletter", 0.95)
+        assert results["weekly-digest"].reasoning == TRIAGE_REASONING
+        assert [result.classification_stage for result in outcome.results] == [
+            "triage", "triage", "detailed", "detailed", "detailed"
+        ]
+        assert results["please-review"].reasoning == "Looked closely"
+        assert (outcome.ai_calls, outcome.triaged, outcome.detailed, outcome.failures) == (8, 5, 3, {})
+
+    @pytest.mark.asyncio
+    async def test_threshold(self, fake_ai, store):
+        """Test that a lower threshold keeps more triage results."""
+        emails = [make_email("Maybe news"), make_email("Build finished")]
+
+        await classify_batch(
+            emails, fake_ai, EmailService(MagicMock(), db=store), USER_ID,
+            strategy="two_stage", triage_threshold=0.3
+        )
+
+        fake_ai.classify_email_async.assert_not_called()
+
+    @pytest.mark.asyncio
+    async def test_token_savings(self, fake_ai, store):
+        """Test that settled emails save tokens and escalated ones cost more than single-stage."""
+        service = EmailService(MagicMock(), db=store)
+
+        settled = await classify_batch(
+            [make_email("Weekly digest")], fake_ai, service, USER_ID, strategy="two_stage", triage_threshold=0.8
+        )
+        escalated = await classify_batch(
+            [make_email("Please review")], fake_ai, service, USER_ID, strategy="two_stage", triage_threshold=0.8
+        )
+
+        assert 0 < settled.estimated_tokens < settled.estimated_single_stage_tokens
+        assert escalated.estimated_tokens > escalated.estimated_single_stage_tokens
+
+    @pytest.mark.asyncio
+    async def test_threads_triage_latest_message(self, fake_ai, store):
+        """Test that thread mode triages each conversation once and earlier messages inherit the result."""
+        emails = [make_email("Build finished", "conv", minute=1), make_email("Weekly digest", "conv", minute=2)]
+        emails[0]["id"] = "earlier"
+
+        outcome = await classify_batch(
+            emails, fake_ai, EmailService(MagicMock(), db=store), USER_ID, thread_mode=True,
+            strategy="two_stage", triage_threshold=0.8
+        )
+
+        assert fake_ai.triage_email_async.await_count == 1
+        assert [(result.category, result.inherited_from) for result in outcome.results] == [
+            ("newsletter", "weekly-digest"), ("newsletter", None)
+        ]
+
+    @pytest.mark.asyncio
+    async def test_single_strategy_skips_triage(self, fake_ai, store):
+        """Test that the default strategy classifies every email in full without triage."""
+        outcome = await classify_batch(
+            [make_email("Weekly digest")], fake_ai, EmailService(MagicMock(), db=store), USER_ID
+        )
+
+        fake_ai.triage_email_async.assert_not_called()
+        assert (outcome.ai_calls, outcome.triaged, outcome.results[0].classification_stage) == (1, 0, None)
+
+
+class TestBatchEndpoint:
+    """Tests for classification_strategy on POST /api/emails/batch-process."""
+
+    @pytest.fixture
+    def client(self, fake_ai, store):
+        """Client with auth, AI, and the email service overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(emails_api.router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=USER_ID, username="batcher", email="batcher@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_email_service] = lambda: EmailService(MagicMock(), db=store)
+        app.dependency_overrides[get_ai_service] = lambda: fake_ai
+        return TestClient(app)
+
+    def post(self, client, **options):
+        """Classify two raw emails and return the response."""
+        emails = [
+            {"subject": subject, "sender": "alice@example.com", "content": "Some text."}
+            for subject in ("Weekly digest", "Please review")
+        ]
+        return client.post("/api/emails/batch-process", json={"emails": emails, "thread_mode": False, **options})
+
+    def test_triage_stats_reported(self, client, fake_ai):
+        """Test that a two_stage batch reports how many emails needed the full classifier."""
+        data = self.post(client, classification_strategy="two_stage").json()
+
+        assert (data["classification_strategy"], data["ai_calls"]) == ("two_stage", 3)
+        triage = data["triage"]
+        assert (triage["triaged"], triage["detailed"]) == (2, 1)
+        assert triage["estimated_tokens_saved"] == triage["estimated_single_stage_tokens"] - triage["estimated_tokens"]
+        assert detailed_subjects(fake_ai) == ["Please review"]
+
+    def test_strategy_defaults_to_settings(self, client, monkeypatch):
+        """Test that without classification_strategy the configured one is used."""
+        assert self.post(client).json()["triage"] is None
+        monkeypatch.setattr(settings, "classification_strategy", "two_stage")
+
+        assert self.post(client).json()["triage"]["triaged"] == 2
+
+    def test_unknown_strategy(self, client):
+        """Test that an unknown strategy is a validation error."""
+        assert self.post(client, classification_strategy="three_stage").status_code == 422
@@ -0,0 +1,173 @@
+"""Tests for merging the primary and shared mailboxes into one paginated stream."""
+
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI, HTTPException
+from fastapi.testclient import TestClient
+
+from backend.api.auth import get_current_user
+from backend.api.emails import router
+from backend.core.errors import register_error_handlers
+from backend.models.user import UserInDB
+from backend.services.email_provider import MockEmailProvider
+from backend.services.email_service import EmailService
+from backend.services.unified_inbox import (
+    MailboxSource, decode_cursor, encode_cursor, get_mailbox_sources, get_unified_emails, received_sort_key
+)
+
+
+def make_emails(prefix, hours):
+    """Inbox emails received on 2024-01-01 at the given hours, newest first."""
+    return [
+        {
+            "id": f"{prefix}-{hour}",
+            "subject": f"Subject {prefix} {hour}",
+            "sender": f"{prefix}@example.com",
+            "body": "Hello",
+            "received_time": f"2024-01-01T{hour:02d}:00:00",
+            "is_read": False,
+            "categories": [],
+            "folder": "Inbox",
+        }
+        for hour in sorted(hours, reverse=True)
+    ]
+
+
+@pytest.fixture
+def sources():
+    """A primary mailbox and a shared mailbox with interleaved timestamps."""
+    primary = MockEmailProvider()
+    primary.authenticate({})
+    primary.mock_emails = make_emails("me", [1, 3, 5, 7, 9])
+    shared = MockEmailProvider()
+    shared.authenticate({})
+    shared.shared_mailboxes["team@example.com"] = make_emails("team", [2, 4, 6, 8])
+    return [
+        MailboxSource("me@example.com", EmailService(primary)),
+        MailboxSource("team@example.com", EmailService(shared), shared=True),
+    ]
+
+
+class FailingProvider(MockEmailProvider):
+    """Mailbox that cannot be read."""
+
+    def get_mailbox_emails(self, mailbox, folder_name="Inbox", count=50, offset=0):
+        raise HTTPException(status_code=404, detail=f"Mailbox {mailbox} not found")
+
+
+async def read_all(sources, limit):
+    """Follow next_cursor until the stream ends, returning every page."""
+    pages, cursor = [], None
+    while True:
+        page = await get_unified_emails(sources, limit=limit, cursor=cursor)
+        pages.append(page)
+        cursor = page["next_cursor"]
+        if cursor is None:
+            return pages
+
+
+class TestCursor:
+    """Tests for the composite cursor and sort key."""
+
+    def test_round_trip(self):
+        """Test that offsets survive encoding."""
+        assert decode_cursor(encode_cursor({"a": 2, "b": 0})) == {"a": 2, "b": 0}
+        assert decode_cursor(None) == {}
+
+    @pytest.mark.parametrize("cursor", ["not a cursor", encode_cursor({"a": -1}), "WzFd"])
+    def test_invalid(self, cursor):
+        """Test that malformed cursors are rejected."""
+        with pytest.raises(ValueError):
+            decode_cursor(cursor)
+
+    def test_sort_key_normalizes_timezones(self):
+        """Test that aware times compare in UTC and unreadable times sort last."""
+        assert received_sort_key({"received_time": "2024-01-01T10:00:00+02:00"}) == datetime(2024, 1, 1, 8)
+        assert received_sort_key({"received_time": "2024-01-01T08:00:00Z"}) == datetime(2024, 1, 1, 8)
+        assert received_sort_key({"received_time": "yesterday"}) == datetime.min
+
+
+class TestUnifiedEmails:
+    """Tests for merging mailboxes in the service."""
+
+    @pytest.mark.asyncio
+    async def test_merges_newest_first(self, sources):
+        """Test that emails interleave by received time and carry their mailbox."""
+        page = await get_unified_emails(sources, limit=4)
+
+        assert [email["id"] for email in page["emails"]] == ["me-9", "team-8", "me-7", "team-6"]
+        assert [email["mailbox"] for email in page["emails"]] == [
+            "me@example.com", "team@example.com", "me@example.com", "team@example.com"
+        ]
+        assert page["has_more"] is True
+        assert decode_cursor(page["next_cursor"]) == {"me@example.com": 2, "team@example.com": 2}
+        assert page["warnings"] == []
+
+    @pytest.mark.asyncio
+    async def test_pages_match_full_merge(self, sources):
+        """Test that following the cursor yields the full merge without gaps or repeats."""
+        full = (await get_unified_emails(sources, limit=100))["emails"]
+
+        pages = await read_all(sources, limit=3)
+
+        assert [email["id"] for page in pages for email in page["emails"]] == [email["id"] for email in full]
+        assert [len(page["emails"]) for page in pages] == [3, 3, 3]
+        assert pages[-1]["has_more"] is False
+
+    @pytest.mark.asyncio
+    async def test_cursor_is_stable(self, sources):
+        """Test that replaying a cursor returns the same page."""
+        first = await get_unified_emails(sources, limit=3)
+
+        again = await get_unified_emails(sources, limit=3, cursor=first["next_cursor"])
+        replay = await get_unified_emails(sources, limit=3, cursor=first["next_cursor"])
+
+        assert again == replay
+        assert [email["id"] for email in again["emails"]] == ["team-6", "me-5", "team-4"]
+
+    @pytest.mark.asyncio
+    async def test_failed_mailbox_becomes_warning(self, sources):
+        """Test that one failing mailbox is reported without failing the page."""
+        broken = FailingProvider()
+        broken.authenticate({})
+        sources.append(MailboxSource("gone@example.com", EmailService(broken), shared=True))
+
+        page = await get_unified_emails(sources, limit=20)
+
+        assert len(page["emails"]) == 9
+        assert page["warnings"] == [
+            {"mailbox": "gone@example.com", "error": "Mailbox gone@example.com not found"}
+        ]
+        assert page["next_cursor"] is None
+
+
+class TestUnifiedEndpoint:
+    """Tests for GET /emails/unified."""
+
+    @pytest.fixture
+    def client(self, sources):
+        """Client with auth and the mailbox sources overridden."""
+        app = FastAPI()
+        register_error_handlers(app)
+        app.include_router(router, prefix="/api")
+        app.dependency_overrides[get_current_user] = lambda: UserInDB(
+            id=1, username="merger", email="merger@example.com",
+            hashed_password="x", created_at=datetime.now()
+        )
+        app.dependency_overrides[get_mailbox_sources] = lambda: sources
+        return TestClient(app)
+
+    def test_paginates_with_cursor(self, client):
+        """Test that the endpoint pages through the merged stream."""
+        first = client.get("/api/emails/unified?limit=5").json()
+        second = client.get(f"/api/emails/unified?limit=5&cursor={first['next_cursor']}").json()
+
+        assert [email["id"] for email in first["emails"]] == ["me-9", "team-8", "me-7", "team-6", "me-5"]
+        assert [email["id"] for email in second["emails"]] == ["team-4", "me-3", "team-2", "me-1"]
+        assert second["next_cursor"] is None
+        assert second["has_more"] is False
+
+    def test_invalid_cursor(self, client):
+        """Test that a malformed cursor is a validation error."""
+        assert client.get("/api/emails/unified?cursor=bogus").status_code == 422
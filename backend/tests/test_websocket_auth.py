@@ -0,0 +1,148 @@
+"""Tests for WebSocket handshake checks, connection limits, and the idle timeout."""
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+from starlette.websockets import WebSocketDisconnect
+
+from backend.api.processing import router
+from backend.core.config import settings
+from backend.services.websocket_manager import (
+    CLOSE_IDLE_TIMEOUT,
+    CLOSE_INVALID_API_KEY,
+    CLOSE_ORIGIN_NOT_ALLOWED,
+    CLOSE_TOO_MANY_CONNECTIONS,
+    websocket_manager,
+)
+
+URL = "/api/processing/ws?user_id=1"
+API_KEY = "c8e1f4a29b"
+
+
+@pytest.fixture
+def client():
+    """Client with the processing router."""
+    app = FastAPI()
+    app.include_router(router, prefix="/api")
+    with TestClient(app) as client:
+        yield client
+    assert not websocket_manager.connection_manager.connections_by_ip
+
+
+def connected(ws):
+    """Read the connect messages and return the hello."""
+    assert ws.receive_json()["type"] == "connection_established"
+    return ws.receive_json()
+
+
+def refused(client, url=URL, **kwargs):
+    """Attempt a connection and return the close code it was refused with."""
+    with pytest.raises(WebSocketDisconnect) as refusal:
+        with client.websocket_connect(url, **kwargs) as ws:
+            ws.receive_json()
+    return refusal.value.code
+
+
+class TestOrigin:
+    """Tests for checking the Origin header against cors_origins."""
+
+    @pytest.fixture(autouse=True)
+    def allowed_origins(self, monkeypatch):
+        """Allow only the local frontend."""
+        monkeypatch.setattr(settings, "cors_origins", ["http://localhost:3000"])
+
+    def test_foreign_origin_refused(self, client):
+        """Test that a page from another origin cannot connect."""
+        assert refused(client, headers={"Origin": "https://evil.example.com"}) == CLOSE_ORIGIN_NOT_ALLOWED
+
+    def test_allowed_origin_accepted(self, client):
+        """Test that a configured origin connects, with or without a trailing slash."""
+        with client.websocket_connect(URL, headers={"Origin": "http://localhost:3000/"}) as ws:
+            assert connected(ws)["type"] == "hello"
+
+    def test_no_origin_accepted(self, client):
+        """Test that non-browser clients without an Origin header connect."""
+        with client.websocket_connect(URL) as ws:
+            assert connected(ws)["type"] == "hello"
+
+    def test_wildcard_allows_any(self, client, monkeypatch):
+        """Test that "*" in cors_origins allows every origin."""
+        monkeypatch.setattr(settings, "cors_origins", ["*"])
+
+        with client.websocket_connect(URL, headers={"Origin": "https://other.example.com"}) as ws:
+            assert connected(ws)["type"] == "hello"
+
+
+class TestApiKey:
+    """Tests for requiring websocket_api_key."""
+
+    @pytest.fixture(autouse=True)
+    def api_key(self, monkeypatch):
+        """Require API_KEY."""
+        monkeypatch.setattr(settings, "websocket_api_key", API_KEY)
+
+    def test_missing_key_refused(self, client):
+        """Test that a connection without the key is refused."""
+        assert refused(client) == CLOSE_INVALID_API_KEY
+
+    def test_wrong_key_refused(self, client):
+        """Test that a wrong key is refused in either place."""
+        assert refused(client, f"{URL}&api_key=guess") == CLOSE_INVALID_API_KEY
+        assert refused(client, subprotocols=["api-key.guess"]) == CLOSE_INVALID_API_KEY
+
+    def test_query_key_accepted(self, client):
+        """Test that the key is accepted as the api_key query parameter."""
+        with client.websocket_connect(f"{URL}&api_key={API_KEY}") as ws:
+            assert connected(ws)["type"] == "hello"
+
+    def test_subprotocol_key_accepted(self, client):
+        """Test that the key is accepted as a subprotocol, which the server selects."""
+        with client.websocket_connect(URL, subprotocols=["json", f"api-key.{API_KEY}"]) as ws:
+            assert ws.accepted_subprotocol == f"api-key.{API_KEY}"
+            assert connected(ws)["type"] == "hello"
+
+    def test_pipeline_endpoint_checked(self, client):
+        """Test that the pipeline endpoint checks the key before looking up the pipeline."""
+        assert refused(client, "/api/processing/ws/missing?user_id=1") == CLOSE_INVALID_API_KEY
+
+
+class TestConnectionLimits:
+    """Tests for the per-IP cap and the idle timeout."""
+
+    def test_per_ip_cap(self, client, monkeypatch):
+        """Test that an IP's connections beyond the cap are refused until one closes."""
+        monkeypatch.setattr(settings, "websocket_max_connections_per_ip", 2)
+
+        with client.websocket_connect(URL) as first:
+            connected(first)
+            with client.websocket_connect(URL) as second:
+                connected(second)
+                assert refused(client) == CLOSE_TOO_MANY_CONNECTIONS
+            assert websocket_manager.connection_manager.connections_by_ip == {"testclient": 1}
+            with client.websocket_connect(URL) as third:
+                assert connected(third)["type"] == "hello"
+
+    def test_idle_connection_pinged_then_closed(self, client, monkeypatch):
+        """Test that a silent client is pinged and then disconnected."""
+        monkeypatch.setattr(settings, "websocket_ping_interval_seconds", 0.05)
+        monkeypatch.setattr(settings, "websocket_idle_timeout_seconds", 0.12)
+
+        with client.websocket_connect(URL) as ws:
+            connected(ws)
+            assert ws.receive_json()["type"] == "ping"
+            assert ws.receive_json()["type"] == "ping"
+            with pytest.raises(WebSocketDisconnect) as closed:
+                ws.receive_json()
+
+        assert closed.value.code == CLOSE_IDLE_TIMEOUT
+
+    def test_pong_keeps_connection_open(self, client, monkeypatch):
+        """Test that answering pings resets the idle timeout."""
+        monkeypatch.setattr(settings, "websocket_ping_interval_seconds", 0.05)
+        monkeypatch.setattr(settings, "websocket_idle_timeout_seconds", 0.12)
+
+        with client.websocket_connect(URL) as ws:
+            connected(ws)
+            for _ in range(5):
+                assert ws.receive_json()["type"] == "ping"
+                ws.send_json({"type": "pong"})
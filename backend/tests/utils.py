@@ -7,7 +7,7 @@ generation.
 
 from typing import Dict, Any, List, Optional
 from unittest.mock import Mock, AsyncMock
-from datetime import datetime
+from datetime import datetime, timedelta
 
 
 def assert_email_structure(email: Dict[str, Any]) -> None:
@@ -349,3 +349,140 @@ def reset_all_mocks(*mocks: Mock) -> None:
     """
     for mock in mocks:
         mock.reset_mock()
+
+
+def parse_ics(text: str) -> Dict[str, Any]:
+    """Parse iCalendar text into its calendar properties and events.
+    
+    Checks the structure a subscribing client relies on: CRLF line endings,
+    folded lines of at most 75 octets, and balanced BEGIN/END blocks.
+    
+    Args:
+        text: iCalendar document
+        
+    Returns:
+        Dict with "calendar" (property name -> value) and "events" (one
+        property dict per VEVENT). Keys keep their parameters, e.g.
+        "DTSTART;VALUE=DATE".
+        
+    Raises:
+        AssertionError: If the document is malformed
+    """
+    assert text.endswith("\r\n"), "iCalendar lines must end with CRLF"
+    raw_lines = text[:-2].split("\r\n")
+    assert all("\n" not in line for line in raw_lines), "Bare LF in iCalendar text"
+    assert all(len(line.encode("utf-8")) <= 75 for line in raw_lines), "Line longer than 75 octets"
+    
+    lines: List[str] = []
+    for line in raw_lines:
+        if line.startswith(" "):
+            lines[-1] += line[1:]
+        else:
+            lines.append(line)
+    
+    calendar: Dict[str, str] = {}
+    events: List[Dict[str, str]] = []
+    stack: List[str] = []
+    for line in lines:
+        name, _, value = line.partition(":")
+        if name == "BEGIN":
+            stack.append(value)
+            if value == "VEVENT":
+                events.append({})
+        elif name == "END":
+            assert stack and stack.pop() == value, f"Unbalanced END:{value}"
+        elif stack == ["VCALENDAR"]:
+            calendar[name] = value
+        elif stack == ["VCALENDAR", "VEVENT"]:
+            events[-1][name] = value
+        else:
+            raise AssertionError(f"Property outside a component: {line}")
+    assert not stack, f"Unclosed components: {stack}"
+    return {"calendar": calendar, "events": events}
+
+
+SEED_CATEGORIES = ["required_personal_action", "team_action", "fyi", "newsletter", "optional_event", "spam_to_delete"]
+
+SEED_SENDERS = ["boss@example.com", "team@example.com", "news@example.org", "alerts@example.net", "friend@example.com"]
+
+
+def seed_emails(db, count: int, user_id: int = 1, now: Optional[datetime] = None) -> List[str]:
+    """Insert count stored emails in one batched transaction.
+    
+    Emails cycle through SEED_CATEGORIES and SEED_SENDERS, arrive one
+    minute apart going back from now, come in conversations of five, and
+    every third one is read, so filters and sorts see realistic spreads.
+    
+    Args:
+        db: DatabaseManager to insert into
+        count: Number of emails
+        user_id: Owner of the emails
+        now: Received time of the newest email (defaults to now)
+        
+    Returns:
+        The inserted email IDs, newest first
+    """
+    now = now or datetime.now()
+    ids = [f"seed-{user_id}-{i}" for i in range(count)]
+    with db.get_connection() as conn:
+        conn.executemany(
+            """
+            INSERT INTO emails (id, subject, sender, content, preview, received_date, category,
+                                confidence, folder, conversation_id, is_read, user_id)
+            VALUES (?, ?, ?, ?, ?, ?, ?, 0.9, 'Inbox', ?, ?, ?)
+            """,
+            [
+                (
+                    email_id,
+                    f"Status update {i}",
+                    SEED_SENDERS[i % len(SEED_SENDERS)],
+                    f"Notes for item {i}. " * 20,
+                    f"Notes for item {i}.",
+                    now - timedelta(minutes=i),
+                    SEED_CATEGORIES[i % len(SEED_CATEGORIES)],
+                    f"seed-conv-{user_id}-{i // 5}",
+                    int(i % 3 == 0),
+                    user_id,
+                )
+                for i, email_id in enumerate(ids)
+            ]
+        )
+        conn.commit()
+    return ids
+
+
+def seed_tasks(db, count: int, user_id: int = 1, now: Optional[datetime] = None) -> None:
+    """Insert count tasks in one batched transaction.
+    
+    Tasks cycle through statuses and priorities; due dates spread from a
+    week overdue to a week ahead of now, and every fourth task has none.
+    
+    Args:
+        db: DatabaseManager to insert into
+        count: Number of tasks
+        user_id: Owner of the tasks
+        now: Reference time for due dates (defaults to now)
+    """
+    now = now or datetime.now()
+    statuses = ["pending", "in_progress", "completed", "cancelled"]
+    priorities = ["low", "medium", "high"]
+    with db.get_connection() as conn:
+        conn.executemany(
+            """
+            INSERT INTO tasks (title, description, status, priority, due_date, created_at, user_id)
+            VALUES (?, ?, ?, ?, ?, ?, ?)
+            """,
+            [
+                (
+                    f"Follow up {i}",
+                    f"Follow up on item {i}",
+                    statuses[i % len(statuses)],
+                    priorities[i % len(priorities)],
+                    None if i % 4 == 3 else now + timedelta(hours=(i % 337) - 168),
+                    now - timedelta(minutes=i),
+                    user_id,
+                )
+                for i in range(count)
+            ]
+        )
+        conn.commit()
@@ -16,12 +16,90 @@ from pathlib import Path
 # Add src to Python path for existing service imports
 sys.path.insert(0, str(Path(__file__).parent.parent.parent / "src"))
 
+from backend.core.config import settings
+from backend.core.protection import PROTECTED_REASON, ai_allowed
+from backend.core.redaction import redact_email
+from backend.services.action_items import category_task_fields
+from backend.services.ai_traces import trace_email
+from backend.services.auto_tasks import create_auto_tasks, should_create_tasks
+from backend.services.category_suggestions import alternative_names
+from backend.services.dry_run import (
+    CLASSIFICATION_WRITES, EMAIL_WRITES, TASK_WRITES, SimulatedAIService, WriteInterceptor
+)
+from backend.services.event_bus import notify
 from backend.services.job_queue import job_queue, JobStatus, JobType, JobProgress
+from backend.services.user_settings_service import user_clock
 from backend.services.websocket_manager import websocket_manager
 
 logger = logging.getLogger(__name__)
 
 
+def tokens_used(ai_service) -> int:
+    """Total AI tokens an AI service has reported using so far.
+    
+    AI services report usage through a running tokens_used count; services
+    that do not track usage count as using none, so budgets never stop them.
+    """
+    return getattr(ai_service, "tokens_used", 0) or 0
+
+
+class JobSkipped(Exception):
+    """Raised by a job whose email must not be processed; the job is skipped, not failed."""
+    
+    def __init__(self, reason: str):
+        super().__init__(reason)
+        self.reason = reason
+
+
+def check_email_allowed(email_data: Dict[str, Any]):
+    """Skip the job when its email is rights-managed (see core.protection).
+    
+    Raises:
+        JobSkipped: If the email's content must not be sent to the AI
+    """
+    if not ai_allowed(email_data):
+        raise JobSkipped(PROTECTED_REASON)
+
+
+class JobServices:
+    """Services one job runs against.
+    
+    In a dry run the email, task, and classification services are wrapped
+    in write interceptors recording into the pipeline's change plan, and
+    simulate_ai swaps in canned AI responses.
+    """
+    
+    def __init__(self, worker: "EmailProcessorWorker", pipeline=None):
+        self._worker = worker
+        self._plan = pipeline.plan if pipeline is not None and pipeline.dry_run else None
+        simulate = pipeline is not None and pipeline.simulate_ai
+        self.ai = SimulatedAIService() if simulate else worker.ai_service
+    
+    def _wrap(self, service, writes):
+        """The service, or its write interceptor in a dry run."""
+        return service if self._plan is None else WriteInterceptor(service, self._plan, writes)
+    
+    @property
+    def email(self):
+        """Email service."""
+        return self._wrap(self._worker.email_service, EMAIL_WRITES)
+    
+    @property
+    def tasks(self):
+        """Task service used for extracted tasks."""
+        return self._wrap(self._worker.task_service, TASK_WRITES)
+    
+    @property
+    def auto_tasks(self):
+        """Database task service used for automatic tasks."""
+        return self._wrap(self._worker._get_auto_task_service(), TASK_WRITES)
+    
+    @property
+    def classifications(self):
+        """Store categorization results are saved to."""
+        return self._wrap(self._worker._get_classification_store(), CLASSIFICATION_WRITES)
+
+
 class EmailProcessorWorker:
     """Background worker for processing emails asynchronously."""
     
@@ -34,6 +112,9 @@ class EmailProcessorWorker:
         self.ai_service = self._get_ai_service()
         self.email_service = self._get_email_service()
         self.task_service = self._get_task_service()
+        self._auto_task_service = None
+        self._classification_store = None
+        self._blocking_store = None
         
         self.logger.info("EmailProcessorWorker initialized")
     
@@ -64,6 +145,36 @@ class EmailProcessorWorker:
             self.logger.warning("Task service not available, using mock")
             return MockTaskService()
     
+    def _get_auto_task_service(self):
+        """Get the database task service used for automatic tasks."""
+        if self._auto_task_service is None:
+            from backend.services.task_service import TaskService
+            self._auto_task_service = TaskService()
+        return self._auto_task_service
+    
+    def _get_classification_store(self):
+        """Get the store categorization results are saved to."""
+        if self._classification_store is None:
+            from backend.services.classification_store import ClassificationStore
+            self._classification_store = ClassificationStore()
+        return self._classification_store
+    
+    def _get_blocking_store(self):
+        """Get the store of blocking items found by holistic analysis."""
+        if self._blocking_store is None:
+            from backend.services.blocking_items import BlockingStore
+            self._blocking_store = BlockingStore()
+        return self._blocking_store
+    
+    async def _blocking_count(self, user_id) -> Optional[int]:
+        """Open blocking items of a pipeline's user, or None if they cannot be counted."""
+        try:
+            loop = asyncio.get_event_loop()
+            return await loop.run_in_executor(None, self._get_blocking_store().open_count, int(user_id))
+        except Exception as e:
+            self.logger.warning(f"Could not count blocking items for user {user_id}: {e}")
+            return None
+    
     async def start(self):
         """Start the background worker."""
         if self.is_running:
@@ -113,6 +224,8 @@ class EmailProcessorWorker:
     
     async def _process_job(self, job):
         """Process a single job."""
+        tokens_before = tokens_used(self.ai_service)
+        services = JobServices(self, await job_queue.get_pipeline_for_job(job.id))
         try:
             # Update job status
             await job_queue.update_job_progress(job.id, JobProgress(
@@ -130,14 +243,15 @@ class EmailProcessorWorker:
             })
             
             # Process based on job type
-            if job.type == JobType.EMAIL_ANALYSIS:
-                result = await self._process_email_analysis(job)
-            elif job.type == JobType.TASK_EXTRACTION:
-                result = await self._process_task_extraction(job)
-            elif job.type == JobType.CATEGORIZATION:
-                result = await self._process_categorization(job)
-            else:
-                raise ValueError(f"Unknown job type: {job.type}")
+            with trace_email(job.email_id, job.user_id):
+                if job.type == JobType.EMAIL_ANALYSIS:
+                    result = await self._process_email_analysis(job, services)
+                elif job.type == JobType.TASK_EXTRACTION:
+                    result = await self._process_task_extraction(job, services)
+                elif job.type == JobType.CATEGORIZATION:
+                    result = await self._process_categorization(job, services)
+                else:
+                    raise ValueError(f"Unknown job type: {job.type}")
             
             # Mark job as completed
             await job_queue.complete_job(job.id, result)
@@ -150,6 +264,16 @@ class EmailProcessorWorker:
                 "result": result
             })
             
+        except JobSkipped as e:
+            self.logger.info(f"Job {job.id} skipped: {e.reason}")
+            await job_queue.skip_job(job.id, e.reason)
+            
+            await websocket_manager.broadcast_job_status(job.id, {
+                "status": "skipped",
+                "progress": 100,
+                "message": f"{job.type.value} skipped: {e.reason}"
+            })
+            
         except Exception as e:
             self.logger.error(f"Job {job.id} failed: {e}")
             await job_queue.fail_job(job.id, str(e))
@@ -161,8 +285,48 @@ class EmailProcessorWorker:
                 "message": f"{job.type.value} failed: {str(e)}",
                 "error": str(e)
             })
+        
+        await job_queue.record_token_usage(job.id, tokens_used(self.ai_service) - tokens_before)
+        await self._announce_if_finished(job)
+    
+    async def _announce_if_finished(self, job):
+        """Send the completion event, with diff counts and token use, when the job's pipeline finishes."""
+        pipeline = await job_queue.get_pipeline_for_job(job.id)
+        if not pipeline or pipeline.status not in ("completed", "failed", "budget_exhausted"):
+            return
+        finished = {
+            JobStatus.COMPLETED, JobStatus.FAILED, JobStatus.CANCELLED, JobStatus.SKIPPED_BUDGET, JobStatus.SKIPPED
+        }
+        if job.status not in finished or any(j.status not in finished for j in pipeline.jobs):
+            return
+        
+        results = {
+            "status": pipeline.status,
+            "diff": pipeline.diff.summary(),
+            "tokens_consumed": pipeline.tokens_consumed,
+            "max_tokens_budget": pipeline.max_tokens_budget
+        }
+        if pipeline.plan is not None:
+            results["change_plan"] = pipeline.plan.to_dict()
+        await websocket_manager.send_processing_complete(pipeline.id, results)
+        
+        processed = sum(1 for j in pipeline.jobs if j.status == JobStatus.COMPLETED)
+        skipped = sum(1 for j in pipeline.jobs if j.status == JobStatus.SKIPPED)
+        blocking = await self._blocking_count(pipeline.user_id)
+        await notify(
+            "pipeline_completed",
+            "Processing finished" if pipeline.status == "completed" else "Processing stopped",
+            f"{processed} of {len(pipeline.jobs)} jobs completed"
+            + (f", {skipped} skipped" if skipped else "") + f" ({pipeline.status})"
+            + (f"; {blocking} waiting on you" if blocking else ""),
+            user_id=pipeline.user_id,
+            pipeline_id=pipeline.id,
+            status=pipeline.status,
+            diff=pipeline.diff.summary(),
+            blocking_count=blocking
+        )
     
-    async def _process_email_analysis(self, job) -> Dict[str, Any]:
+    async def _process_email_analysis(self, job, services: JobServices) -> Dict[str, Any]:
         """Process email AI analysis."""
         email_id = job.email_id
         
@@ -173,9 +337,10 @@ class EmailProcessorWorker:
             message="Retrieving email data..."
         ))
         
-        email_data = await self.email_service.get_email(email_id)
+        email_data = await services.email.get_email(email_id)
         if not email_data:
             raise ValueError(f"Email {email_id} not found")
+        check_email_allowed(email_data)
         
         # Step 2: Perform AI analysis
         await job_queue.update_job_progress(job.id, JobProgress(
@@ -184,7 +349,8 @@ class EmailProcessorWorker:
             message="Analyzing email content with AI..."
         ))
         
-        analysis_result = await self.ai_service.analyze_email(email_data)
+        # Secrets are masked in the copy sent to the AI; email_data stays as stored
+        analysis_result = await services.ai.analyze_email(redact_email(email_data)[0])
         
         # Step 3: Store results
         await job_queue.update_job_progress(job.id, JobProgress(
@@ -202,7 +368,7 @@ class EmailProcessorWorker:
         
         return stored_result
     
-    async def _process_task_extraction(self, job) -> Dict[str, Any]:
+    async def _process_task_extraction(self, job, services: JobServices) -> Dict[str, Any]:
         """Process task extraction from email."""
         email_id = job.email_id
         
@@ -213,9 +379,10 @@ class EmailProcessorWorker:
             message="Retrieving email analysis..."
         ))
         
-        email_data = await self.email_service.get_email(email_id)
+        email_data = await services.email.get_email(email_id)
         if not email_data:
             raise ValueError(f"Email {email_id} not found")
+        check_email_allowed(email_data)
         
         # Step 2: Extract tasks
         await job_queue.update_job_progress(job.id, JobProgress(
@@ -224,7 +391,7 @@ class EmailProcessorWorker:
             message="Extracting actionable tasks..."
         ))
         
-        tasks = await self.ai_service.extract_tasks(email_data)
+        tasks = await services.ai.extract_tasks(redact_email(email_data)[0])
         
         # Step 3: Create tasks in system
         await job_queue.update_job_progress(job.id, JobProgress(
@@ -233,15 +400,29 @@ class EmailProcessorWorker:
             message="Creating tasks in system..."
         ))
         
+        # Category defaults stand in for the priority and due date the AI left out,
+        # due at the end of the user's day
+        clock = await asyncio.get_event_loop().run_in_executor(
+            None, user_clock, self._get_auto_task_service().db, job.user_id
+        )
+        defaults = category_task_fields(email_data.get("category"), clock=clock)
         created_tasks = []
         for task_data in tasks:
-            task = await self.task_service.create_task({
-                **task_data,
+            fields = {**task_data}
+            for key, value in defaults.items():
+                if fields.get(key) is None:
+                    fields[key] = value
+            task = await services.tasks.create_task({
+                **fields,
                 "email_id": email_id,
                 "source": "email_processing"
             })
             created_tasks.append(task)
         
+        pipeline = await job_queue.get_pipeline_for_job(job.id)
+        if pipeline:
+            pipeline.diff.record_tasks(email_id, len(created_tasks))
+        
         return {
             "email_id": email_id,
             "tasks_created": len(created_tasks),
@@ -249,7 +430,7 @@ class EmailProcessorWorker:
             "processed_at": datetime.utcnow().isoformat()
         }
     
-    async def _process_categorization(self, job) -> Dict[str, Any]:
+    async def _process_categorization(self, job, services: JobServices) -> Dict[str, Any]:
         """Process email categorization."""
         email_id = job.email_id
         
@@ -260,9 +441,10 @@ class EmailProcessorWorker:
             message="Retrieving email data..."
         ))
         
-        email_data = await self.email_service.get_email(email_id)
+        email_data = await services.email.get_email(email_id)
         if not email_data:
             raise ValueError(f"Email {email_id} not found")
+        check_email_allowed(email_data)
         
         # Step 2: Determine category
         await job_queue.update_job_progress(job.id, JobProgress(
@@ -271,7 +453,7 @@ class EmailProcessorWorker:
             message="Determining email category..."
         ))
         
-        category_result = await self.ai_service.categorize_email(email_data)
+        category_result = await services.ai.categorize_email(redact_email(email_data)[0])
         
         # Step 3: Update email category
         await job_queue.update_job_progress(job.id, JobProgress(
@@ -280,11 +462,59 @@ class EmailProcessorWorker:
             message="Updating email category..."
         ))
         
-        await self.email_service.update_email_category(email_id, category_result)
+        await services.email.update_email_category(email_id, category_result)
+        
+        category = category_result.get("category")
+        needs_review = bool(category_result.get("needs_review"))
+        pipeline = await job_queue.get_pipeline_for_job(job.id)
+        previous = await services.classifications.save_classification(
+            {**email_data, "id": email_id},
+            job.user_id,
+            category,
+            category_result.get("confidence"),
+            needs_review,
+            model=pipeline.deployment if pipeline else None,
+            alternatives=alternative_names(category_result.get("alternatives")),
+            reasoning=category_result.get("reasoning"),
+            importance_score=category_result.get("importance_score"),
+            importance_justification=category_result.get("importance_justification")
+        )
+        if pipeline:
+            pipeline.diff.record_classification(email_id, previous, category, needs_review)
+        if category == "required_personal_action" and (previous or {}).get("category") != category:
+            await notify(
+                "action_required_email",
+                "Action required",
+                email_data.get("subject") or "(no subject)",
+                user_id=job.user_id,
+                email_id=email_id,
+                sender=email_data.get("sender"),
+                subject=email_data.get("subject")
+            )
+        
+        # Step 4: Create tasks automatically if the policy covers this category
+        tasks_created = 0
+        if should_create_tasks(settings.auto_create_tasks, category):
+            await job_queue.update_job_progress(job.id, JobProgress(
+                step="Categorization",
+                percentage=95,
+                message="Creating tasks from action items..."
+            ))
+            tasks_created = await create_auto_tasks(
+                {**email_data, "id": email_id},
+                category,
+                services.ai,
+                services.auto_tasks,
+                job.user_id,
+                settings.auto_create_tasks
+            )
+            if pipeline:
+                pipeline.diff.record_tasks(email_id, tasks_created)
         
         return {
             "email_id": email_id,
             "category": category_result,
+            "tasks_created": tasks_created,
             "processed_at": datetime.utcnow().isoformat()
         }
 
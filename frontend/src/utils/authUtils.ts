@@ -91,7 +91,12 @@ export const getAuthErrorMessage = (error: unknown): string => {
   }
   
   if (error && typeof error === 'object') {
-    const apiError = error as { data?: { message?: string; detail?: string } };
+    const apiError = error as {
+      data?: { error?: { message?: string }; message?: string; detail?: string };
+    };
+    if (apiError.data?.error?.message) {
+      return apiError.data.error.message;
+    }
     if (apiError.data?.message) {
       return apiError.data.message;
     }
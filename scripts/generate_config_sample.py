@@ -0,0 +1,105 @@
+#!/usr/bin/env python3
+"""Generate config.sample.yaml from the backend Settings class.
+
+Every setting is listed with its type, default and the comment that
+documents it in backend/core/config.py. Secret settings are listed as
+comments only, because they may not be set in the settings file.
+
+Run from the repository root after changing Settings:
+
+    python scripts/generate_config_sample.py
+"""
+
+import ast
+import inspect
+import json
+import sys
+from pathlib import Path
+
+PROJECT_ROOT = Path(__file__).parent.parent
+sys.path.insert(0, str(PROJECT_ROOT))
+
+from backend.core.config import SECRET_FIELDS, Settings  # noqa: E402
+
+OUTPUT_PATH = PROJECT_ROOT / "config.sample.yaml"
+
+HEADER = """\
+# Email Helper settings file (generated by scripts/generate_config_sample.py)
+#
+# Copy to config.yaml in the repository root, or point EMAIL_HELPER_CONFIG at
+# a YAML or JSON file. Precedence: environment variables > .env > this file >
+# built-in defaults. Secrets are shown commented out: set them as environment
+# variables or in the encrypted settings store instead.
+"""
+
+
+def read_field_comments():
+    """Map each Settings field to its section heading and inline comment."""
+    source_lines = inspect.getsource(Settings).splitlines()
+    tree = ast.parse(inspect.getsource(Settings))
+    class_def = tree.body[0]
+
+    comments = {}
+    section = None
+    previous_end = 0
+    for node in class_def.body:
+        if not isinstance(node, ast.AnnAssign) or not isinstance(node.target, ast.Name):
+            previous_end = getattr(node, "end_lineno", node.lineno)
+            continue
+
+        # A comment line directly above a group of fields names the section
+        for line in source_lines[previous_end:node.lineno - 1]:
+            stripped = line.strip()
+            if stripped.startswith("#"):
+                section = stripped.lstrip("# ").strip()
+
+        line = source_lines[node.lineno - 1]
+        inline = line.split("#", 1)[1].strip() if "#" in line.split("=", 1)[-1] else ""
+        comments[node.target.id] = (section, inline)
+        previous_end = node.end_lineno
+
+    return comments
+
+
+def format_type(annotation):
+    """Format a field annotation, e.g. Optional[str] as "str, optional"."""
+    args = [arg for arg in getattr(annotation, "__args__", ()) if arg is not type(None)]
+    if args and len(args) < len(annotation.__args__):
+        return f"{format_type(args[0])}, optional"
+    return getattr(annotation, "__name__", str(annotation).replace("typing.", ""))
+
+
+def format_value(value):
+    """Format a default value as YAML flow syntax."""
+    if value is None:
+        return "null"
+    return json.dumps(value)
+
+
+def render_sample():
+    """Render the sample settings file."""
+    comments = read_field_comments()
+    lines = [HEADER.rstrip("\n")]
+    current_section = None
+
+    for name, field in Settings.model_fields.items():
+        section, inline = comments.get(name, (None, ""))
+        if section and section != current_section:
+            lines.append(f"\n# --- {section} ---")
+            current_section = section
+
+        description = f"  # {format_type(field.annotation)}" + (f" - {inline}" if inline else "")
+        prefix = "# " if name in SECRET_FIELDS else ""
+        suffix = " (secret: environment only)" if name in SECRET_FIELDS else ""
+        lines.append(f"{prefix}{name}: {format_value(field.default)}{description}{suffix}")
+
+    return "\n".join(lines) + "\n"
+
+
+def main():
+    OUTPUT_PATH.write_text(render_sample(), encoding="utf-8")
+    print(f"Wrote {OUTPUT_PATH.relative_to(PROJECT_ROOT)}")
+
+
+if __name__ == "__main__":
+    main()
@@ -8,9 +8,11 @@ The adapter maintains full backward compatibility while providing a clean
 interface for dependency injection and testing.
 """
 
+import os
 import sys
+import tempfile
 from pathlib import Path
-from typing import List, Dict, Any, Optional
+from typing import List, Dict, Any, Optional, Tuple
 
 # Add src to Python path if needed
 sys.path.insert(0, str(Path(__file__).parent.parent))
@@ -18,6 +20,35 @@ sys.path.insert(0, str(Path(__file__).parent.parent))
 from core.interfaces import EmailProvider
 from outlook_manager import OutlookManager
 
+# MAPI PR_CONTENT_COUNT: a folder's item count, readable without opening Items
+PR_CONTENT_COUNT = "http://schemas.microsoft.com/mapi/proptag/0x36020003"
+
+# MAPI PR_ATTACH_CONTENT_ID and PR_ATTACH_MIME_TAG: an inline attachment's Content-ID and MIME type
+PR_ATTACH_CONTENT_ID = "http://schemas.microsoft.com/mapi/proptag/0x3712001F"
+PR_ATTACH_MIME_TAG = "http://schemas.microsoft.com/mapi/proptag/0x370E001F"
+
+# OlMailRecipientType values of Recipient.Type
+OL_TO = 1
+OL_CC = 2
+OL_BCC = 3
+
+# OlRuleType value of Rule.RuleType for rules on sent mail
+OL_RULE_SEND = 1
+
+# RuleConditions and RuleActions properties get_rules reports by name only;
+# sender, subject, and move-to-folder are read in full
+RULE_CONDITIONS = (
+    "Account", "AnyCategory", "Body", "BodyOrSubject", "CC", "Category", "FormName",
+    "FromAnyRSSFeed", "FromRssFeed", "HasAttachment", "Importance", "MeetingInviteOrUpdate",
+    "MessageHeader", "NotTo", "OnLocalMachine", "OnOtherMachine", "OnlyToMe",
+    "RecipientAddress", "SenderInAddressList", "SentTo", "Sensitivity", "ToMe", "ToOrCc",
+)
+RULE_ACTIONS = (
+    "AssignToCategory", "ClearCategories", "CopyToFolder", "Delete", "DeletePermanently",
+    "DesktopAlert", "Forward", "ForwardAsAttachment", "MarkAsTask", "NewItemAlert",
+    "NotifyDelivery", "NotifyRead", "PlaySound", "Redirect",
+)
+
 
 class OutlookEmailAdapter(EmailProvider):
     """Adapter wrapping OutlookManager to implement EmailProvider interface.
@@ -54,21 +85,76 @@ class OutlookEmailAdapter(EmailProvider):
         self.outlook_manager = outlook_manager or OutlookManager()
         self.connected = False
     
-    def connect(self) -> bool:
+    def connect(self, store_name: Optional[str] = None) -> bool:
         """Establish connection to Outlook application.
         
+        Args:
+            store_name: Display name of the store to bind to (defaults to
+                       the profile's default store)
+        
         Returns:
             bool: True if connection successful, False otherwise
+        
+        Raises:
+            LookupError: If the profile has no store named store_name
         """
         try:
-            self.outlook_manager.connect_to_outlook()
+            if store_name:
+                self.outlook_manager.connect_to_outlook(store_name=store_name)
+            else:
+                self.outlook_manager.connect_to_outlook()
             self.connected = True
             return True
+        except LookupError:
+            self.connected = False
+            raise
         except Exception as e:
             print(f"Failed to connect to Outlook: {e}")
             self.connected = False
             return False
     
+    def list_stores(self) -> List[Dict[str, Any]]:
+        """List the stores (mailboxes and data files) in the Outlook profile.
+        
+        Returns:
+            List of store dictionaries with name (display name), id
+            (StoreID), is_default, and selected
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        return self.outlook_manager.list_stores()
+    
+    def select_store(self, store_name: str) -> Dict[str, Any]:
+        """Bind folder and item operations to another store.
+        
+        Args:
+            store_name: Display name of the store (case-insensitive)
+        
+        Returns:
+            The selected store's dictionary, in the list_stores format
+        
+        Raises:
+            LookupError: If the profile has no store with that name
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        store = self.outlook_manager.select_store(store_name)
+        return {
+            'name': store.DisplayName,
+            'id': store.StoreID,
+            'is_default': store.StoreID == self.outlook_manager.namespace.DefaultStore.StoreID,
+            'selected': True
+        }
+    
+    def _get_item(self, email_id: str):
+        """Get an item by EntryID, looking in the selected store if there is one."""
+        store = self.outlook_manager.store
+        if store is None:
+            return self.outlook_manager.namespace.GetItemFromID(email_id)
+        return self.outlook_manager.namespace.GetItemFromID(email_id, store.StoreID)
+    
     def get_emails(
         self, 
         folder_name: str = "Inbox", 
@@ -91,6 +177,10 @@ class OutlookEmailAdapter(EmailProvider):
             - subject: Email subject line
             - sender: Sender email address
             - recipient: Primary recipient address
+            - to: To recipient addresses
+            - cc: Cc recipient addresses
+            - recipient_count: Number of To and Cc recipients
+            - attachments: Name and size in bytes of each attachment
             - body: Email body text (plain or HTML)
             - received_time: ISO format timestamp
             - is_read: Read status boolean
@@ -134,6 +224,54 @@ class OutlookEmailAdapter(EmailProvider):
             print(f"Error retrieving emails: {e}")
             return []
     
+    def get_shared_mailbox_emails(
+        self,
+        mailbox: str,
+        folder_name: str = "Inbox",
+        count: int = 50,
+        offset: int = 0
+    ) -> List[Dict[str, Any]]:
+        """Retrieve emails from a shared mailbox the profile has access to.
+        
+        Args:
+            mailbox: Display name or address of the shared mailbox
+            folder_name: "Inbox", or the name of a folder under the shared inbox
+            count: Maximum number of emails to retrieve
+            offset: Number of emails to skip (for pagination)
+        
+        Returns:
+            List of email dictionaries, newest first
+        
+        Raises:
+            RuntimeError: If not connected to Outlook
+            ValueError: If the mailbox cannot be resolved or opened
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        namespace = self.outlook_manager.namespace
+        recipient = namespace.CreateRecipient(mailbox)
+        if not recipient.Resolve():
+            raise ValueError(f"Could not resolve shared mailbox {mailbox}")
+        folder = namespace.GetSharedDefaultFolder(recipient, 6)  # 6 = olFolderInbox
+        if folder_name.lower() != "inbox":
+            folder = folder.Folders[folder_name]
+        
+        items = folder.Items
+        items.Sort("[ReceivedTime]", True)
+        
+        result = []
+        for i, email in enumerate(items):
+            if i < offset:
+                continue
+            if len(result) >= count:
+                break
+            try:
+                result.append(self._email_to_dict(email))
+            except Exception as e:
+                print(f"Error converting email: {e}")
+        return result
+    
     def move_email(self, email_id: str, destination_folder: str) -> bool:
         """Move an email to the specified folder.
         
@@ -149,7 +287,7 @@ class OutlookEmailAdapter(EmailProvider):
         
         try:
             # Get the email item by EntryID
-            email = self.outlook_manager.namespace.GetItemFromID(email_id)
+            email = self._get_item(email_id)
             
             # Get or create the destination folder
             target_folder = self.outlook_manager._get_or_create_folder(destination_folder)
@@ -175,7 +313,7 @@ class OutlookEmailAdapter(EmailProvider):
             raise RuntimeError("Not connected to Outlook. Call connect() first.")
         
         try:
-            email = self.outlook_manager.namespace.GetItemFromID(email_id)
+            email = self._get_item(email_id)
             
             # Try to get plain text body first, fall back to HTML
             body = getattr(email, 'Body', None)
@@ -188,11 +326,77 @@ class OutlookEmailAdapter(EmailProvider):
             print(f"Error retrieving email body: {e}")
             return ""
     
-    def get_folders(self) -> List[Dict[str, str]]:
-        """List available email folders.
+    def get_privacy_properties(self, email_id: str) -> Dict[str, Any]:
+        """Get the properties needed to judge an email before opening it.
+        
+        Args:
+            email_id: EntryID of the email
+        
+        Returns:
+            Dict with requests_read_receipt (bool), html_body (str, empty
+            for plain-text emails), and message_class, permission, and
+            attachments for judging rights management
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        try:
+            email = self._get_item(email_id)
+            properties = {
+                'requests_read_receipt': bool(getattr(email, 'ReadReceiptRequested', False)),
+                'html_body': getattr(email, 'HTMLBody', '') or '',
+                'message_class': getattr(email, 'MessageClass', '') or '',
+                'permission': getattr(email, 'Permission', 0) or 0,
+                'attachments': []
+            }
+            try:
+                properties['attachments'] = self._get_attachments(email)
+            except Exception:
+                pass
+            return properties
+            
+        except Exception as e:
+            print(f"Error retrieving email properties: {e}")
+            return {'requests_read_receipt': False, 'html_body': ''}
+    
+    def get_emails_by_ids(self, email_ids: List[str]) -> Dict[str, Dict[str, Any]]:
+        """Look up several emails by EntryID.
+        
+        Args:
+            email_ids: EntryIDs of the emails
+        
+        Returns:
+            Dict of EntryID to email dictionary (with the containing folder);
+            emails Outlook can no longer find are left out
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        found = {}
+        for email_id in email_ids:
+            try:
+                email = self._get_item(email_id)
+            except Exception:
+                continue
+            email_dict = self._email_to_dict(email)
+            try:
+                email_dict['folder'] = email.Parent.Name
+            except Exception:
+                pass
+            found[email_id] = email_dict
+        return found
+    
+    def get_folders(self) -> List[Dict[str, Any]]:
+        """List the mailbox's folders with their unread and total counts.
+        
+        The whole tree is read in one walk. Counts come from folder
+        properties, so Items collections are only opened for folders whose
+        count property cannot be read.
         
         Returns:
-            List of folder dictionaries with id and name
+            List of folder dictionaries with id, name, path (e.g.
+            "Inbox/Recruiters"), type, unread_count, and total_count,
+            parents before their subfolders
         """
         if not self.connected:
             raise RuntimeError("Not connected to Outlook. Call connect() first.")
@@ -200,28 +404,52 @@ class OutlookEmailAdapter(EmailProvider):
         try:
             folders = []
             inbox_parent = self.outlook_manager.inbox.Parent
-            
-            for folder in inbox_parent.Folders:
-                try:
-                    folders.append({
-                        'id': folder.EntryID,
-                        'name': folder.Name,
-                        'type': 'mail'
-                    })
-                except Exception:
-                    continue
-            
+            self._walk_folders(inbox_parent.Folders, "", folders)
             return folders
             
         except Exception as e:
             print(f"Error listing folders: {e}")
             return []
     
-    def mark_as_read(self, email_id: str) -> bool:
+    def _walk_folders(self, collection, parent_path: str, folders: List[Dict[str, Any]]):
+        """Append every folder in a collection, and their subfolders, to folders."""
+        for folder in collection:
+            try:
+                name = folder.Name
+                path = f"{parent_path}/{name}" if parent_path else name
+                folders.append({
+                    'id': folder.EntryID,
+                    'name': name,
+                    'path': path,
+                    'type': 'mail',
+                    'unread_count': folder.UnReadItemCount,
+                    'total_count': self._folder_item_count(folder)
+                })
+            except Exception:
+                continue
+            
+            try:
+                subfolders = folder.Folders
+                has_subfolders = subfolders.Count > 0
+            except Exception:
+                continue
+            if has_subfolders:
+                self._walk_folders(subfolders, path, folders)
+    
+    def _folder_item_count(self, folder) -> int:
+        """Read a folder's item count, opening Items only as a fallback."""
+        try:
+            return int(folder.PropertyAccessor.GetProperty(PR_CONTENT_COUNT))
+        except Exception:
+            return folder.Items.Count
+    
+    def mark_as_read(self, email_id: str, suppress_read_receipt: bool = False) -> bool:
         """Mark an email as read.
         
         Args:
             email_id: EntryID of the email
+            suppress_read_receipt: Clear the sender's read receipt request
+                first, so Outlook does not send a receipt when the item is saved
         
         Returns:
             bool: True if successful, False otherwise
@@ -230,7 +458,10 @@ class OutlookEmailAdapter(EmailProvider):
             raise RuntimeError("Not connected to Outlook. Call connect() first.")
         
         try:
-            email = self.outlook_manager.namespace.GetItemFromID(email_id)
+            email = self._get_item(email_id)
+            if suppress_read_receipt and getattr(email, 'ReadReceiptRequested', False):
+                # Must happen before UnRead changes; Outlook sends the receipt on save
+                email.ReadReceiptRequested = False
             email.UnRead = False
             email.Save()
             return True
@@ -254,7 +485,7 @@ class OutlookEmailAdapter(EmailProvider):
             raise RuntimeError("Not connected to Outlook. Call connect() first.")
         
         try:
-            email = self.outlook_manager.namespace.GetItemFromID(email_id)
+            email = self._get_item(email_id)
             
             # Use OutlookManager's categorization logic
             self.outlook_manager.categorize_email(email, category)
@@ -264,6 +495,132 @@ class OutlookEmailAdapter(EmailProvider):
             print(f"Error categorizing email: {e}")
             return False
     
+    def ensure_categories(self, colors: Dict[str, int], force_colors: bool = False) -> Dict[str, int]:
+        """Make sure the mailbox's master category list has each category.
+        
+        Missing categories are added with the requested color. Categories
+        the user already has keep their color unless force_colors is set.
+        
+        Args:
+            colors: Category name -> OlCategoryColor value
+            force_colors: Recolor existing categories that differ
+        
+        Returns:
+            Category name -> the OlCategoryColor it now has
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        # A selected store keeps its own master category list
+        master = (self.outlook_manager.store or self.outlook_manager.namespace).Categories
+        existing = {category.Name: category for category in master}
+        
+        effective = {}
+        for name, color in colors.items():
+            category = existing.get(name)
+            if category is None:
+                master.Add(name, color)
+            elif category.Color != color and force_colors:
+                category.Color = color
+            else:
+                color = category.Color
+            effective[name] = color
+        return effective
+    
+    def delete_email(self, email_id: str) -> bool:
+        """Delete an email. Outlook moves it to Deleted Items.
+        
+        Args:
+            email_id: EntryID of the email to delete
+        
+        Returns:
+            bool: True if successful, False otherwise
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        try:
+            email = self._get_item(email_id)
+            email.Delete()
+            return True
+            
+        except Exception as e:
+            print(f"Error deleting email: {e}")
+            return False
+    
+    def create_draft_reply(self, email_id: str, subject: str, body: str) -> Optional[str]:
+        """Save a reply to an email as a draft. The reply is never sent.
+        
+        Args:
+            email_id: EntryID of the email being replied to
+            subject: Reply subject
+            body: Reply text, placed above the quoted original
+        
+        Returns:
+            str: EntryID of the saved draft, or None on failure
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        try:
+            email = self._get_item(email_id)
+            reply = email.Reply()
+            reply.Subject = subject
+            reply.Body = f"{body}\n\n{reply.Body or ''}"
+            reply.Save()
+            return reply.EntryID
+            
+        except Exception as e:
+            print(f"Error saving draft reply: {e}")
+            return None
+    
+    def get_rules(self) -> List[Dict[str, Any]]:
+        """List the store's Outlook rules, in the order they run.
+        
+        Returns:
+            List of rule dictionaries with name, enabled, incoming,
+            senders (From recipients and sender address words),
+            subject_contains, move_to_folder (folder path below the store,
+            e.g. "Inbox/Newsletters", or None), other_conditions (enabled
+            conditions besides sender and subject, plus "Exceptions" when
+            the rule has any), and other_actions (enabled actions besides
+            moving and stopping)
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        store = self.outlook_manager.store or self.outlook_manager.namespace.DefaultStore
+        return [self._rule_to_dict(rule) for rule in store.GetRules()]
+    
+    def _rule_to_dict(self, rule) -> Dict[str, Any]:
+        """Describe an Outlook Rule with the conditions and actions get_rules reads."""
+        conditions = rule.Conditions
+        actions = rule.Actions
+        
+        senders = []
+        if conditions.From.Enabled:
+            senders.extend(recipient.Address for recipient in conditions.From.Recipients)
+        if conditions.SenderAddress.Enabled:
+            senders.extend(conditions.SenderAddress.Address)
+        subject = list(conditions.Subject.Text) if conditions.Subject.Enabled else []
+        
+        other_conditions = [name for name in RULE_CONDITIONS if _rule_part_enabled(conditions, name)]
+        exceptions = rule.Exceptions
+        if any(_rule_part_enabled(exceptions, name) for name in ("From", "SenderAddress", "Subject", *RULE_CONDITIONS)):
+            other_conditions.append("Exceptions")
+        
+        move = actions.MoveToFolder
+        return {
+            'name': rule.Name,
+            'enabled': bool(rule.Enabled),
+            'incoming': rule.RuleType != OL_RULE_SEND,
+            'senders': [str(sender) for sender in senders if sender],
+            'subject_contains': [str(text) for text in subject if text],
+            'move_to_folder': _store_relative_path(move.Folder.FolderPath) if move.Enabled and move.Folder else None,
+            'other_conditions': other_conditions,
+            'other_actions': [name for name in RULE_ACTIONS if _rule_part_enabled(actions, name)],
+        }
+    
     def _email_to_dict(self, email) -> Dict[str, Any]:
         """Convert Outlook email COM object to dictionary.
         
@@ -280,18 +637,32 @@ class OutlookEmailAdapter(EmailProvider):
                 'subject': getattr(email, 'Subject', 'No Subject'),
                 'sender': getattr(email, 'SenderEmailAddress', 'Unknown'),
                 'recipient': '',
+                'to': [],
+                'cc': [],
+                'recipient_count': 0,
                 'body': getattr(email, 'Body', '')[:500],  # Truncate for list view
                 'received_time': self._format_datetime(email.ReceivedTime),
                 'is_read': not email.UnRead,
                 'categories': self._get_categories(email),
-                'conversation_id': getattr(email, 'ConversationID', '')
+                'conversation_id': getattr(email, 'ConversationID', ''),
+                'requests_read_receipt': bool(getattr(email, 'ReadReceiptRequested', False)),
+                'message_class': getattr(email, 'MessageClass', '') or '',  # IPM.Note.rpmsg for IRM
+                'permission': getattr(email, 'Permission', 0) or 0
             }
             
-            # Extract recipient
+            # Extract recipients
+            try:
+                to, cc = self._get_recipients(email)
+                email_dict['to'] = to
+                email_dict['cc'] = cc
+                email_dict['recipient_count'] = len(to) + len(cc)
+                email_dict['recipient'] = (to or cc or [''])[0]
+            except Exception:
+                pass
+            
+            # Attachment metadata only; contents are never read
             try:
-                recipients = email.Recipients
-                if recipients.Count > 0:
-                    email_dict['recipient'] = recipients.Item(1).Address
+                email_dict['attachments'] = self._get_attachments(email)
             except Exception:
                 pass
             
@@ -301,6 +672,106 @@ class OutlookEmailAdapter(EmailProvider):
             print(f"Error converting email to dict: {e}")
             raise
     
+    def _get_recipients(self, email) -> Tuple[List[str], List[str]]:
+        """Read the To and Cc addresses from an email's Recipients collection.
+        
+        Bcc recipients are left out; only the sender's copy lists them.
+        
+        Args:
+            email: Outlook email COM object
+        
+        Returns:
+            To addresses and Cc addresses, in collection order
+        """
+        to, cc = [], []
+        recipients = email.Recipients
+        for index in range(1, recipients.Count + 1):  # COM collections are 1-based
+            recipient = recipients.Item(index)
+            address = getattr(recipient, 'Address', '') or ''
+            if not address:
+                continue
+            kind = getattr(recipient, 'Type', OL_TO)
+            if kind == OL_CC:
+                cc.append(address)
+            elif kind != OL_BCC:
+                to.append(address)
+        return to, cc
+    
+    def _get_attachments(self, email) -> List[Dict[str, Any]]:
+        """Read the name and size of each item in an email's Attachments collection.
+        
+        Args:
+            email: Outlook email COM object
+        
+        Returns:
+            {"name", "size"} dicts in collection order, size in bytes, with
+            content_id for inline attachments
+        """
+        attachments = []
+        collection = email.Attachments
+        for index in range(1, collection.Count + 1):  # COM collections are 1-based
+            attachment = collection.Item(index)
+            entry = {
+                'name': getattr(attachment, 'FileName', '') or '',
+                'size': int(getattr(attachment, 'Size', 0) or 0),
+            }
+            content_id = self._attachment_property(attachment, PR_ATTACH_CONTENT_ID).strip('<> ')
+            if content_id:
+                entry['content_id'] = content_id
+            attachments.append(entry)
+        return attachments
+    
+    def _attachment_property(self, attachment, property_tag: str) -> str:
+        """Read a string MAPI property of an attachment, or '' if it is not set."""
+        try:
+            value = attachment.PropertyAccessor.GetProperty(property_tag)
+        except Exception:
+            return ''
+        return value if isinstance(value, str) else ''
+    
+    def get_inline_attachment(self, email_id: str, content_id: str) -> Optional[Dict[str, Any]]:
+        """Read the bytes of the attachment an HTML body embeds as cid:content_id.
+        
+        Outlook only hands out attachment contents through SaveAsFile, so
+        the attachment is saved to a temporary directory, read, and deleted.
+        
+        Args:
+            email_id: EntryID of the email
+            content_id: Content-ID of the attachment, with or without angle brackets
+        
+        Returns:
+            Dict with name, content_type (from the attachment's MIME tag,
+            empty if Outlook has none), and data, or None if the email is
+            not found or none of its attachments has this Content-ID
+        """
+        if not self.connected:
+            raise RuntimeError("Not connected to Outlook. Call connect() first.")
+        
+        wanted = content_id.strip('<> ').lower()
+        if not wanted:
+            return None
+        try:
+            email = self._get_item(email_id)
+        except Exception:
+            return None  # Unknown EntryID
+        collection = email.Attachments
+        for index in range(1, collection.Count + 1):  # COM collections are 1-based
+            attachment = collection.Item(index)
+            if self._attachment_property(attachment, PR_ATTACH_CONTENT_ID).strip('<> ').lower() != wanted:
+                continue
+            name = getattr(attachment, 'FileName', '') or 'inline'
+            with tempfile.TemporaryDirectory() as directory:
+                path = os.path.join(directory, os.path.basename(name) or 'inline')
+                attachment.SaveAsFile(path)
+                with open(path, 'rb') as saved:
+                    data = saved.read()
+            return {
+                'name': name,
+                'content_type': self._attachment_property(attachment, PR_ATTACH_MIME_TAG),
+                'data': data,
+            }
+        return None
+    
     def _format_datetime(self, dt) -> str:
         """Format Outlook datetime to ISO string.
         
@@ -332,3 +803,17 @@ class OutlookEmailAdapter(EmailProvider):
             return []
         except Exception:
             return []
+
+
+def _rule_part_enabled(parts, name: str) -> bool:
+    """Whether a rule condition or action exists in this Outlook version and is enabled."""
+    try:
+        return bool(getattr(parts, name).Enabled)
+    except Exception:
+        return False
+
+
+def _store_relative_path(folder_path: str) -> str:
+    """Folder path below its store: \\\\Mailbox\\Inbox\\News -> Inbox/News."""
+    parts = [part for part in folder_path.split("\\") if part]
+    return "/".join(parts[1:]) or folder_path
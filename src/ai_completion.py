@@ -0,0 +1,100 @@
+"""Prompt completion transport for Email Helper.
+
+A completer runs one prompt request (see ai_prompts.PromptBuilder) against
+the model and returns the raw response. AIProcessor takes one as its
+completer argument, so tests can run the processor's fallback handling
+against a fake instead of Azure OpenAI.
+
+PromptyCompleter, the default, runs the template with promptflow when it
+is installed and with the prompty library otherwise, on the configured
+Azure OpenAI endpoint.
+"""
+
+from dataclasses import dataclass, field
+
+from azure_config import get_azure_config
+
+
+class CompleterUnavailableError(RuntimeError):
+    """Neither promptflow nor prompty is installed."""
+
+
+@dataclass
+class PromptRequest:
+    """One prompt to run.
+
+    Attributes:
+        template (str): Template file name, e.g. 'email_classifier.prompty'
+        path (str): File the template is loaded from
+        inputs (dict): Template variables
+        parameters (dict): Model parameters set over the template's own
+        deployment (str): Azure OpenAI deployment, or None for the configured one
+    """
+    template: str
+    path: str
+    inputs: dict = field(default_factory=dict)
+    parameters: dict = field(default_factory=dict)
+    deployment: str = None
+
+
+class PromptyCompleter:
+    """Runs prompt requests on Azure OpenAI through promptflow or prompty."""
+
+    def complete(self, request):
+        """Run a prompt request.
+
+        Args:
+            request (PromptRequest): The prompt to run.
+
+        Returns:
+            The model's response as the client library returns it.
+
+        Raises:
+            CompleterUnavailableError: If neither client library is installed.
+        """
+        azure_config = get_azure_config()
+        deployment = request.deployment or azure_config.deployment
+
+        try:
+            from promptflow.core import Prompty
+        except ImportError:
+            return self._complete_with_prompty(request, azure_config, deployment)
+
+        model_config = azure_config.get_promptflow_config()
+        model_config.azure_deployment = deployment
+        model = {'configuration': model_config}
+        if request.parameters:
+            # Merged over the template's own parameters when it is loaded
+            model['parameters'] = request.parameters
+        return Prompty.load(request.path, model=model)(**request.inputs)
+
+    def _complete_with_prompty(self, request, azure_config, deployment):
+        """Run a prompt request with the prompty library."""
+        try:
+            import prompty
+            import prompty.azure
+        except ImportError as e:
+            print(f"\n🚨 PROMPTY LIBRARY NOT AVAILABLE")
+            print(f"ImportError: {e}")
+            print(f"Prompty file: {request.template}")
+            raise CompleterUnavailableError(f"Prompty library unavailable: {e}")
+
+        p = prompty.load(request.path)
+        p.model.parameters = {**(p.model.parameters or {}), **request.parameters}
+        p.model.configuration["azure_endpoint"] = azure_config.endpoint
+        p.model.configuration["azure_deployment"] = deployment
+        p.model.configuration["api_version"] = azure_config.api_version
+
+        if azure_config.use_azure_credential():
+            from azure.identity import DefaultAzureCredential, get_bearer_token_provider
+            token_provider = get_bearer_token_provider(
+                DefaultAzureCredential(),
+                "https://cognitiveservices.azure.com/.default"
+            )
+            p.model.configuration["azure_ad_token_provider"] = token_provider
+            if "api_key" in p.model.configuration:
+                del p.model.configuration["api_key"]
+        else:
+            p.model.configuration["api_key"] = azure_config.get_api_key()
+
+        return prompty.run(p, inputs=request.inputs)
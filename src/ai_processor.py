@@ -14,7 +14,8 @@ The AIProcessor class manages:
 - Session management for processing workflows
 
 Key Components:
-- Prompty file parsing and template management
+- Prompty file parsing and template management (see ai_prompts, with
+  ai_completion for the model call and ai_responses for fallbacks)
 - User-specific data loading (job context, skills, role)
 - AI response processing with error handling and fallbacks
 - Classification accuracy tracking and improvement
@@ -28,8 +29,13 @@ may be unavailable or return malformed responses.
 
 import os
 import json
+import time
 from datetime import datetime
-from azure_config import get_azure_config
+from azure_config import is_auth_error
+from ai_completion import CompleterUnavailableError, PromptyCompleter
+from ai_prompts import PromptBuilder, parse_prompty_file
+from ai_responses import content_filter_fallback, execution_error_fallback, is_content_filter_error
+from classification_categories import BUILT_IN_CATEGORIES, category_prompt_inputs
 from accuracy_tracker import AccuracyTracker
 from data_recorder import DataRecorder
 from utils import (
@@ -86,7 +92,23 @@ class AIProcessor:
         'optional_event': 0.8          # 80% confidence for auto-approval
     }
     
-    def __init__(self, email_analyzer=None):
+    # Callable mapping a template file name to the file to run, or None to
+    # read templates from prompts_dir (the backend layers user overrides)
+    prompt_resolver = None
+    
+    # Callable mapping a template file name to the model parameters to run it
+    # with (temperature, max_tokens), or None to keep the template's own
+    parameter_resolver = None
+    
+    # Callable mapping a template file name to the Azure OpenAI deployment to
+    # run it on, or None (or a None result) for the configured deployment
+    deployment_resolver = None
+    
+    # Runs prompt requests (see ai_completion); pass a fake to __init__ to
+    # run without Azure OpenAI
+    completer = PromptyCompleter()
+    
+    def __init__(self, email_analyzer=None, completer=None):
         script_dir = os.path.dirname(os.path.abspath(__file__))
         project_root = os.path.dirname(script_dir)
         self.prompts_dir = os.path.join(project_root, 'prompts')
@@ -97,6 +119,8 @@ class AIProcessor:
         
         # Store reference to email analyzer for content similarity detection
         self.email_analyzer = email_analyzer
+        if completer is not None:
+            self.completer = completer
         
         # User feedback directory (alias for compatibility)
         self.user_feedback_dir = self.runtime_data_dir
@@ -118,6 +142,13 @@ class AIProcessor:
         self.accuracy_tracker = AccuracyTracker(runtime_base_dir)
         self.session_tracker = SessionTracker(self.accuracy_tracker)
         self.data_recorder = DataRecorder(self.runtime_data_dir)
+        
+        # Callables run after every prompt execution (see add_trace_hook)
+        self.trace_hooks = []
+        
+        # Raise Azure authentication failures instead of returning fallback
+        # responses, so callers can tell the user to sign in again
+        self.raise_auth_errors = False
     
     def get_username(self):
         """Get the user's username from configuration file.
@@ -146,17 +177,25 @@ class AIProcessor:
         Returns:
             list: List of category strings used by the email classification system.
         """
-        return [
-            'required_personal_action',
-            'team_action', 
-            'optional_action',
-            'work_relevant',
-            'fyi',
-            'newsletter',
-            'spam_to_delete',
-            'job_listing',
-            'optional_event'
-        ]
+        return [category['name'] for category in BUILT_IN_CATEGORIES]
+    
+    def prompty_path(self, prompty_file):
+        """Path of the file run for a prompty template name.
+        
+        Args:
+            prompty_file (str): Template file name, e.g. 'draft_reply.prompty'.
+            
+        Returns:
+            str: The prompt_resolver's file when one is set, otherwise the
+            template in prompts_dir.
+        """
+        return self.prompt_builder().path(prompty_file)
+    
+    def prompt_builder(self):
+        """Prompt builder over prompts_dir and the current resolvers."""
+        return PromptBuilder(
+            self.prompts_dir, self.prompt_resolver, self.parameter_resolver, self.deployment_resolver
+        )
     
     def parse_prompty_file(self, file_path):
         """Parse a prompty template file and extract the content.
@@ -178,210 +217,109 @@ class AIProcessor:
             >>> prompt = processor.parse_prompty_file('prompts/classifier.prompty')
             >>> print(prompt[:100])  # Show first 100 characters
         """
-        with open(file_path, 'r', encoding='utf-8') as f:
-            content = f.read()
-        
-        if content.startswith('---'):
-            parts = content.split('---', 2)
-            if len(parts) >= 3:
-                return parts[2].strip()
-            raise ValueError(f"Malformed YAML frontmatter in {file_path}")
-        return content
+        return parse_prompty_file(file_path)
     
-    def _repair_json_response(self, response_text):
-        """Repair malformed JSON responses from AI services.
+    def add_trace_hook(self, hook):
+        """Register a callable to run after every prompt execution.
         
-        This method attempts to fix common JSON formatting issues that can occur
-        in AI responses, such as missing closing braces, unterminated strings,
-        or truncated responses.
+        The hook is called with a dict holding template, inputs, parameters
+        (see model_parameters), response, duration_ms, and error; error is
+        set when the call failed or the response is a fallback. Hooks run on
+        the calling thread, and a failing hook never affects the prompt result.
         
         Args:
-            response_text (str): The potentially malformed JSON response text.
+            hook: Callable taking the event dict.
+        """
+        self.trace_hooks.append(hook)
+    
+    def render_prompty(self, prompty_file, inputs=None):
+        """Render a prompty template's prompt text with its inputs.
+        
+        Args:
+            prompty_file (str): Template file name in the prompts directory.
+            inputs (dict): Template variables.
             
         Returns:
-            str: The repaired JSON string, or None if no repair is possible.
+            str: The rendered prompt, or the unrendered template text if
+            jinja2 is unavailable.
         """
-        if not response_text or not response_text.strip():
-            return None
+        return self.prompt_builder().render(prompty_file, inputs)
+    
+    def model_parameters(self, prompty_file):
+        """Model parameters set over a template's own when it runs (see parameter_resolver).
         
-        response_text = response_text.strip()
+        Args:
+            prompty_file (str): Template file name in the prompts directory.
+            
+        Returns:
+            dict: Parameters replacing the template's values, e.g. {'temperature': 0.1}
+        """
+        return self.prompt_builder().parameters(prompty_file)
+    
+    def model_deployment(self, prompty_file):
+        """Deployment a template runs on instead of the configured one (see deployment_resolver).
         
-        # If it's already valid JSON, return as-is
-        try:
-            json.loads(response_text)
-            return response_text
-        except json.JSONDecodeError:
-            pass
-        
-        # For completely invalid JSON, return None
-        if not ('{' in response_text or '[' in response_text):
-            return None
-        
-        # Try common repairs
-        repaired = response_text
-        
-        # Clean up whitespace and control characters (but preserve normal whitespace)
-        repaired = ''.join(char for char in repaired if ord(char) >= 32 or char in '\n\t\r')
-        repaired = repaired.replace('\r\n', '\n').replace('\r', '\n')  # Normalize line endings
-        
-        # Fix the specific issue in the test: unterminated string ending with comma
-        import re
-        
-        # The test case has: "topic": "Unterminated string value,
-        # We need to add the missing closing quote before the comma
-        
-        # Pattern: finds lines that look like: "key": "value, (missing closing quote)
-        # followed by whitespace and then next line starting with "key":
-        def fix_unterminated_value(match):
-            key = match.group(1) 
-            value = match.group(2)
-            return f'"{key}": "{value}",'
-        
-        # Look for unterminated string values that end with comma
-        repaired = re.sub(r'"([^"]+)":\s*"([^"]*),\s*\n\s*"', lambda m: f'"{m.group(1)}": "{m.group(2)}",\n    "', repaired)
-        
-        # Fix missing closing brace/bracket
-        open_braces = repaired.count('{')
-        close_braces = repaired.count('}')
-        open_brackets = repaired.count('[')
-        close_brackets = repaired.count(']')
-        
-        # Add missing closing characters
-        if open_brackets > close_brackets:
-            repaired += ']' * (open_brackets - close_brackets)
-        if open_braces > close_braces:
-            repaired += '}' * (open_braces - close_braces)
-        
-        # Try to parse the repaired JSON
-        try:
-            json.loads(repaired)
-            return repaired
-        except json.JSONDecodeError as e:
-                # JSON still invalid after repair attempt
+        Args:
+            prompty_file (str): Template file name in the prompts directory.
             
-            # If still invalid, try to extract just the structure we need
-            if 'truly_relevant_actions' in repaired:
-                # Create a minimal valid structure
-                minimal = {
-                    "truly_relevant_actions": [],
-                    "superseded_actions": [],
-                    "duplicate_groups": [],
-                    "expired_items": []
-                }
-                return json.dumps(minimal)
-            return None
+        Returns:
+            str or None: Deployment name, or None for the configured deployment
+        """
+        return self.prompt_builder().deployment(prompty_file)
     
     def execute_prompty(self, prompty_file, inputs=None):
         if inputs is None:
             inputs = {}
+        if not self.trace_hooks:
+            return self._execute_prompty(prompty_file, inputs, {})
         
-        prompty_path = os.path.join(self.prompts_dir, prompty_file)
-        azure_config = get_azure_config()
-        
+        event = {
+            "template": prompty_file, "inputs": inputs, "response": None, "error": None,
+            "parameters": self.model_parameters(prompty_file)
+        }
+        started = time.perf_counter()
         try:
-            from promptflow.core import Prompty
-            model_config = azure_config.get_promptflow_config()
-            prompty_instance = Prompty.load(prompty_path, model={'configuration': model_config})
-            return prompty_instance(**inputs)
-            
-        except ImportError:
-            try:
-                import prompty
-                import prompty.azure
-                
-                p = prompty.load(prompty_path)
-                p.model.configuration["azure_endpoint"] = azure_config.endpoint
-                p.model.configuration["azure_deployment"] = azure_config.deployment  
-                p.model.configuration["api_version"] = azure_config.api_version
-                
-                if azure_config.use_azure_credential():
-                    from azure.identity import DefaultAzureCredential, get_bearer_token_provider
-                    token_provider = get_bearer_token_provider(
-                        DefaultAzureCredential(), 
-                        "https://cognitiveservices.azure.com/.default"
-                    )
-                    p.model.configuration["azure_ad_token_provider"] = token_provider
-                    if "api_key" in p.model.configuration:
-                        del p.model.configuration["api_key"]
-                else:
-                    p.model.configuration["api_key"] = azure_config.get_api_key()
-                
-                return prompty.run(p, inputs=inputs)
-                
-            except ImportError as e:
-                print(f"\n🚨 PROMPTY LIBRARY NOT AVAILABLE")
-                print(f"ImportError: {e}")
-                print(f"Prompty file: {prompty_file}")
-                raise RuntimeError(f"Prompty library unavailable: {e}")
-                
+            event["response"] = self._execute_prompty(prompty_file, inputs, event)
+            return event["response"]
         except Exception as e:
-            # Check if this is a content filter error
-            error_str = str(e).lower()
-            is_content_filter = any(phrase in error_str for phrase in [
-                'content_filter', 'content management policy', 'responsibleaipolicyviolation',
-                'jailbreak', 'filtered', 'badrequeesterror'
-            ])
-            
-            # Also check the error type
-            error_type = type(e).__name__.lower()
-            is_wrapped_openai_error = 'wrappedopenaierror' in error_type
+            event["error"] = str(e)
+            raise
+        finally:
+            event["duration_ms"] = (time.perf_counter() - started) * 1000
+            for hook in self.trace_hooks:
+                try:
+                    hook(event)
+                except Exception as e:
+                    print(f"⚠️  AI trace hook failed: {e}")
+    
+    def _execute_prompty(self, prompty_file, inputs, event):
+        """Run a prompty template, recording a swallowed failure in event["error"]."""
+        request = self.prompt_builder().request(prompty_file, inputs)
+        try:
+            return self.completer.complete(request)
+        except CompleterUnavailableError:
+            raise
+        except Exception as e:
+            if self.raise_auth_errors and is_auth_error(e):
+                event["error"] = str(e)
+                raise
             
-            if is_content_filter or is_wrapped_openai_error:
+            if is_content_filter_error(e):
                 print(f"\n⚠️  CONTENT FILTER BLOCKED: {prompty_file}")
                 print(f"Reason: Azure OpenAI content policy violation")
                 print(f"Error details: {str(e)[:200]}...")
+                event["error"] = f"Content filter: {e}"
                 # Return a safe fallback response instead of crashing
-                return self._get_content_filter_fallback(prompty_file, inputs)
-            else:
-                print(f"\n🚨 PROMPTY EXECUTION FAILED")
-                print(f"Error: {e}")
-                print(f"Prompty file: {prompty_file}")
-                print(f"Azure config: {azure_config}")
-                print(f"Inputs: {inputs}")
-                # Return fallback instead of raising exception
-                return self._get_execution_error_fallback(prompty_file, inputs)
-    
-    def _get_content_filter_fallback(self, prompty_file, inputs):
-        """Return appropriate fallback response when content filter blocks the request"""
-        if 'email_one_line_summary' in prompty_file:
-            subject = inputs.get('subject', 'Email')
-            return f"Summary blocked by content filter - {subject[:80]}"
-        elif 'event_relevance_assessment' in prompty_file:
-            return "Unable to assess relevance - content filter triggered"
-        elif 'email_classifier' in prompty_file:
-            return '{"category": "fyi", "explanation": "Classification blocked by content filter"}'
-        elif 'fyi_summary' in prompty_file:
-            subject = inputs.get('subject', 'Email')
-            return f"• Summary blocked by content filter - {subject[:80]}"
-        elif 'newsletter_summary' in prompty_file:
-            return "Newsletter summary blocked by content filter"
-        elif 'summerize_action_item' in prompty_file:
-            return '{"action_required": "Review email manually", "due_date": "No deadline", "explanation": "Content filter blocked analysis", "relevance": "Manual review needed", "links": []}'
-        elif 'holistic_inbox_analyzer' in prompty_file:
-            return '{"truly_relevant_actions": [], "superseded_actions": [], "duplicate_groups": [], "expired_items": []}'
-        else:
-            return "Content filter blocked - manual review required"
-    
-    def _get_execution_error_fallback(self, prompty_file, inputs):
-        """Return appropriate fallback response when AI execution fails"""
-        if 'email_one_line_summary' in prompty_file:
-            subject = inputs.get('subject', 'Email')
-            return f"AI unavailable - {subject[:80]}"
-        elif 'event_relevance_assessment' in prompty_file:
-            return "Unable to assess relevance - AI service unavailable"
-        elif 'email_classifier' in prompty_file:
-            return '{"category": "fyi", "explanation": "AI service unavailable for classification"}'
-        elif 'fyi_summary' in prompty_file:
-            subject = inputs.get('subject', 'Email')
-            return f"• AI unavailable - {subject[:80]}"
-        elif 'newsletter_summary' in prompty_file:
-            return "Newsletter summary unavailable - AI service error"
-        elif 'summerize_action_item' in prompty_file:
-            return '{"action_required": "Review email manually", "due_date": "No deadline", "explanation": "AI service unavailable", "relevance": "Manual review needed", "links": []}'
-        elif 'holistic_inbox_analyzer' in prompty_file:
-            return '{"truly_relevant_actions": [], "superseded_actions": [], "duplicate_groups": [], "expired_items": []}'
-        else:
-            return "AI processing unavailable"
+                return content_filter_fallback(prompty_file, inputs)
+            
+            print(f"\n🚨 PROMPTY EXECUTION FAILED")
+            print(f"Error: {e}")
+            print(f"Prompty file: {prompty_file}")
+            print(f"Deployment: {request.deployment or 'configured'}")
+            print(f"Inputs: {inputs}")
+            event["error"] = str(e)
+            # Return fallback instead of raising exception
+            return execution_error_fallback(prompty_file, inputs)
 
     def get_job_context(self):
         if os.path.exists(self.job_summary_file):
@@ -533,8 +471,15 @@ class AIProcessor:
         base_explanation = explanations.get(category, f"Classified as {category} based on email content analysis.")
         return f"{base_explanation} Subject: '{subject[:50]}...'"
 
-    def classify_email_with_explanation(self, email_content, learning_data):
-        """Enhanced email classification that returns both category and explanation"""
+    def classify_email_with_explanation(self, email_content, learning_data, categories=None):
+        """Enhanced email classification that returns both category and explanation
+        
+        Args:
+            email_content: Email dict (subject, sender, date, body)
+            learning_data: Past classifications used as few-shot examples
+            categories: Category dicts to classify into (see classification_categories);
+                defaults to the built-in categories
+        """
         # Get few-shot examples for better accuracy
         few_shot_examples = self.get_few_shot_examples(email_content, learning_data)
         
@@ -547,7 +492,9 @@ Learning History: {len(learning_data)} previous decisions"""
             for i, example in enumerate(few_shot_examples, 1):
                 context += f"\n{i}. Subject: '{example['subject']}' → Category: {example['category']}"
         
+        categories = categories or BUILT_IN_CATEGORIES
         inputs = self._create_email_inputs(email_content, context)
+        inputs.update(category_prompt_inputs(categories, inputs['username']))
         result = self.execute_prompty('email_classifier_with_explanation.prompty', inputs)
         
         if not result or result in ["AI processing unavailable", "AI processing failed"]:
@@ -578,6 +525,9 @@ Learning History: {len(learning_data)} previous decisions"""
             
             # Clean category and provide meaningful explanation if missing
             category = clean_ai_response(parsed.get('category', 'fyi')).lower()
+            if category not in {known['name'] for known in categories}:
+                print(f"⚠️ Unknown category '{category}' returned, using fallback")
+                return fallback_data
             explanation = parsed.get('explanation', '')
             
             # Ensure explanation is always present and meaningful
@@ -586,7 +536,9 @@ Learning History: {len(learning_data)} previous decisions"""
             
             return {
                 'category': category,
-                'explanation': explanation
+                'explanation': explanation,
+                'importance_score': parsed.get('importance_score'),
+                'importance_justification': parsed.get('importance_justification', '')
             }
             
         except Exception as e:
@@ -0,0 +1,86 @@
+"""Prompt building for Email Helper.
+
+PromptBuilder turns a template name and its inputs into the request a
+completer runs (see ai_completion): the file to load, the model parameters
+set over the template's own, and the deployment to run on. The resolvers
+are the ones AIProcessor exposes, so the backend's layered templates and
+per-template settings apply to every prompt.
+"""
+
+import os
+
+from ai_completion import PromptRequest
+
+
+def parse_prompty_file(file_path):
+    """Prompt text of a prompty file, without its YAML frontmatter.
+
+    Raises:
+        ValueError: If the file has malformed YAML frontmatter.
+    """
+    with open(file_path, 'r', encoding='utf-8') as f:
+        content = f.read()
+
+    if content.startswith('---'):
+        parts = content.split('---', 2)
+        if len(parts) >= 3:
+            return parts[2].strip()
+        raise ValueError(f"Malformed YAML frontmatter in {file_path}")
+    return content
+
+
+class PromptBuilder:
+    """Builds prompt requests from templates in a prompts directory.
+
+    Args:
+        prompts_dir (str): Directory of the bundled templates.
+        prompt_resolver: Callable mapping a template name to the file to run,
+            or None to read templates from prompts_dir.
+        parameter_resolver: Callable mapping a template name to model
+            parameters (temperature, max_tokens), or None to keep the template's.
+        deployment_resolver: Callable mapping a template name to a deployment,
+            or None (or a None result) for the configured deployment.
+    """
+
+    def __init__(self, prompts_dir, prompt_resolver=None, parameter_resolver=None, deployment_resolver=None):
+        self.prompts_dir = prompts_dir
+        self.prompt_resolver = prompt_resolver
+        self.parameter_resolver = parameter_resolver
+        self.deployment_resolver = deployment_resolver
+
+    def path(self, prompty_file):
+        """File run for a template name."""
+        if self.prompt_resolver is not None:
+            return str(self.prompt_resolver(prompty_file))
+        return os.path.join(self.prompts_dir, prompty_file)
+
+    def parameters(self, prompty_file):
+        """Model parameters replacing the template's values, e.g. {'temperature': 0.1}."""
+        if self.parameter_resolver is None:
+            return {}
+        return dict(self.parameter_resolver(prompty_file))
+
+    def deployment(self, prompty_file):
+        """Deployment a template runs on, or None for the configured deployment."""
+        if self.deployment_resolver is None:
+            return None
+        return self.deployment_resolver(prompty_file)
+
+    def render(self, prompty_file, inputs=None):
+        """Prompt text rendered with its inputs, or the unrendered text if jinja2 is unavailable."""
+        template = parse_prompty_file(self.path(prompty_file))
+        try:
+            import jinja2
+        except ImportError:
+            return template
+        return jinja2.Template(template).render(**(inputs or {}))
+
+    def request(self, prompty_file, inputs=None):
+        """Request running a template with its inputs."""
+        return PromptRequest(
+            template=prompty_file,
+            path=self.path(prompty_file),
+            inputs=inputs or {},
+            parameters=self.parameters(prompty_file),
+            deployment=self.deployment(prompty_file),
+        )
@@ -0,0 +1,140 @@
+"""Response decoding for Email Helper prompts.
+
+Helpers for what a completer (see ai_completion) returns, or fails to:
+
+- repair_json: fixes truncated or slightly malformed JSON responses
+- is_content_filter_error: tells Azure OpenAI content filter rejections
+  from other failures
+- content_filter_fallback / execution_error_fallback: the response each
+  template gets instead when its call was filtered or failed, in the shape
+  its caller parses (JSON for classifier, action item, and holistic
+  templates, text for summaries)
+"""
+
+import json
+import re
+
+CONTENT_FILTER_PHRASES = (
+    'content_filter', 'content management policy', 'responsibleaipolicyviolation',
+    'jailbreak', 'filtered', 'badrequeesterror'
+)
+
+EMPTY_HOLISTIC_ANALYSIS = json.dumps({
+    "truly_relevant_actions": [], "superseded_actions": [], "duplicate_groups": [], "expired_items": []
+})
+
+
+def repair_json(response_text):
+    """Repair a malformed JSON response.
+
+    Fixes unterminated string values followed by another key and adds
+    missing closing brackets, as left by truncated responses.
+
+    Args:
+        response_text (str): The potentially malformed JSON response text.
+
+    Returns:
+        str: The repaired JSON string (an empty holistic analysis when a
+        holistic response cannot be repaired), or None if no repair is possible.
+    """
+    if not response_text or not response_text.strip():
+        return None
+
+    response_text = response_text.strip()
+
+    try:
+        json.loads(response_text)
+        return response_text
+    except json.JSONDecodeError:
+        pass
+
+    if not ('{' in response_text or '[' in response_text):
+        return None
+
+    # Drop control characters (keeping normal whitespace) and normalize line endings
+    repaired = ''.join(char for char in response_text if ord(char) >= 32 or char in '\n\t\r')
+    repaired = repaired.replace('\r\n', '\n').replace('\r', '\n')
+
+    # "key": "value,<newline> "next": ... is missing the value's closing quote
+    repaired = re.sub(r'"([^"]+)":\s*"([^"]*),\s*\n\s*"', lambda m: f'"{m.group(1)}": "{m.group(2)}",\n    "', repaired)
+
+    open_braces = repaired.count('{')
+    close_braces = repaired.count('}')
+    open_brackets = repaired.count('[')
+    close_brackets = repaired.count(']')
+    if open_brackets > close_brackets:
+        repaired += ']' * (open_brackets - close_brackets)
+    if open_braces > close_braces:
+        repaired += '}' * (open_braces - close_braces)
+
+    try:
+        json.loads(repaired)
+        return repaired
+    except json.JSONDecodeError:
+        if 'truly_relevant_actions' in repaired:
+            return EMPTY_HOLISTIC_ANALYSIS
+        return None
+
+
+def is_content_filter_error(error):
+    """Whether a completion failure is an Azure OpenAI content filter rejection."""
+    error_str = str(error).lower()
+    if any(phrase in error_str for phrase in CONTENT_FILTER_PHRASES):
+        return True
+    return 'wrappedopenaierror' in type(error).__name__.lower()
+
+
+def content_filter_fallback(prompty_file, inputs):
+    """Response a template gets when the content filter blocked its request."""
+    if 'email_one_line_summary' in prompty_file:
+        subject = inputs.get('subject', 'Email')
+        return f"Summary blocked by content filter - {subject[:80]}"
+    elif 'event_relevance_assessment' in prompty_file:
+        return "Unable to assess relevance - content filter triggered"
+    elif 'email_classifier' in prompty_file:
+        return json.dumps({
+            "category": "fyi", "explanation": "Classification blocked by content filter", "importance_score": 3,
+            "importance_justification": "Default score - content filter blocked analysis"
+        })
+    elif 'fyi_summary' in prompty_file:
+        subject = inputs.get('subject', 'Email')
+        return f"• Summary blocked by content filter - {subject[:80]}"
+    elif 'newsletter_summary' in prompty_file:
+        return "Newsletter summary blocked by content filter"
+    elif 'summerize_action_item' in prompty_file:
+        return json.dumps({
+            "action_required": "Review email manually", "due_date": "No deadline",
+            "explanation": "Content filter blocked analysis", "relevance": "Manual review needed", "links": []
+        })
+    elif 'holistic_inbox_analyzer' in prompty_file:
+        return EMPTY_HOLISTIC_ANALYSIS
+    else:
+        return "Content filter blocked - manual review required"
+
+
+def execution_error_fallback(prompty_file, inputs):
+    """Response a template gets when its call failed."""
+    if 'email_one_line_summary' in prompty_file:
+        subject = inputs.get('subject', 'Email')
+        return f"AI unavailable - {subject[:80]}"
+    elif 'event_relevance_assessment' in prompty_file:
+        return "Unable to assess relevance - AI service unavailable"
+    elif 'email_classifier' in prompty_file:
+        return json.dumps({
+            "category": "fyi", "explanation": "AI service unavailable for classification", "importance_score": 3,
+            "importance_justification": "Default score - AI service unavailable"
+        })
+    elif 'fyi_summary' in prompty_file:
+        subject = inputs.get('subject', 'Email')
+        return f"• AI unavailable - {subject[:80]}"
+    elif 'newsletter_summary' in prompty_file:
+        return "Newsletter summary unavailable - AI service error"
+    elif 'summerize_action_item' in prompty_file:
+        return json.dumps({
+            "action_required": "Review email manually", "due_date": "No deadline",
+            "explanation": "AI service unavailable", "relevance": "Manual review needed", "links": []
+        })
+    elif 'holistic_inbox_analyzer' in prompty_file:
+        return EMPTY_HOLISTIC_ANALYSIS
+    else:
+        return "AI processing unavailable"
@@ -187,6 +187,42 @@ class AzureConfig:
             f")"
         )
 
+# Exception classes raised when no Azure token can be obtained (azure-identity,
+# azure-core) or the endpoint rejects the credential (openai)
+AUTH_ERROR_TYPES = frozenset({
+    'ClientAuthenticationError',
+    'CredentialUnavailableError',
+    'AuthenticationError',
+    'PermissionDeniedError',
+})
+
+# Endpoint statuses meaning the credential or API key was rejected
+AUTH_STATUS_CODES = frozenset({401, 403})
+
+# Message fragments of the same failures once wrapped (e.g. by promptflow)
+AUTH_ERROR_PATTERNS = (
+    'error code: 401',
+    'error code: 403',
+    'failed to retrieve a token',
+    "run 'az login'",
+)
+
+def is_auth_error(error: BaseException) -> bool:
+    """Check whether an error, or one it was raised from, is an Azure authentication failure"""
+    seen = set()
+    while error is not None and id(error) not in seen:
+        seen.add(id(error))
+        if any(cls.__name__ in AUTH_ERROR_TYPES for cls in type(error).__mro__):
+            return True
+        response = getattr(error, 'response', None)
+        if getattr(response, 'status_code', None) in AUTH_STATUS_CODES:
+            return True
+        message = str(error).lower()
+        if any(pattern in message for pattern in AUTH_ERROR_PATTERNS):
+            return True
+        error = error.__cause__ or error.__context__
+    return False
+
 # Global configuration instance
 _config_instance = None
 
@@ -0,0 +1,137 @@
+"""Email classification categories and their prompt rendering.
+
+The built-in categories below are the defaults the classifier knows. The
+FastAPI backend seeds its categories table from them and lets users add
+their own; both the backend and AIProcessor render whichever set applies
+into the category_rules and category_names inputs of
+email_classifier_with_explanation.prompty.
+
+Each category is a dict with:
+- name: snake_case identifier returned by the classifier
+- description: classification rules, one per line
+- priority: high, medium, or low (groups the rules in the prompt)
+- folder: Outlook folder the category is filed into, if any
+"""
+
+CATEGORY_PRIORITIES = ("high", "medium", "low")
+
+BUILT_IN_CATEGORIES = [
+    {
+        "name": "required_personal_action",
+        "priority": "high",
+        "folder": "Required Actions (Me)",
+        "description": "\n".join([
+            "Direct personal communication requiring action from {{username}}",
+            "Sent directly TO {{username}} from non-automated accounts",
+            "@ mentions requiring response",
+            "Manager communications needing action",
+            "Surveys/feedback requests with deadlines",
+            "Core responsibilities tied to job role context where {{username}} must act",
+        ]),
+    },
+    {
+        "name": "team_action",
+        "priority": "high",
+        "folder": "Team Actions",
+        "description": "\n".join([
+            "Only when our team must act",
+            "Code reviews, technical discussions where our team's input is requested",
+            "Partner coordination where our team must implement something",
+            "Debugging/perf diagnostics where our team is the owner or explicitly asked to do work",
+            "Infra/service issues assigned to our team",
+            "Emails with PRs/logs/dashboards explicitly requesting our team to review/act",
+            "Service flow/status reports for products we own that indicate a problem AND expect our team to act",
+        ]),
+    },
+    {
+        "name": "optional_action",
+        "priority": "medium",
+        "folder": "Optional Actions",
+        "description": "Optional surveys, trainings, documentation hygiene, quality initiatives",
+    },
+    {
+        "name": "job_listing",
+        "priority": "medium",
+        "folder": "Job Listings",
+        "description": "Job posts, recruiting outreach",
+    },
+    {
+        "name": "optional_event",
+        "priority": "medium",
+        "folder": "Optional Events",
+        "description": "Webinars, talks, conferences, trainings (not mandatory)",
+    },
+    {
+        "name": "work_relevant",
+        "priority": "low",
+        "folder": "Work Relevant",
+        "description": "\n".join([
+            "Work/tech emails aligned to job role context for awareness",
+            "Integrations, docs, performance reports with no action expected from our team",
+            "Work announcements, security/compliance info",
+            "Threads previously participated in (reference)",
+        ]),
+    },
+    {
+        "name": "fyi",
+        "priority": "low",
+        "folder": "FYI",
+        "description": "\n".join([
+            "General awareness only",
+            "Dependency service flow reports showing problems owned by other teams",
+            "Our outbound requests asking other teams to act, with no explicit action requested from our team",
+            "Policy updates, \"issue resolved\" notices",
+        ]),
+    },
+    {
+        "name": "newsletter",
+        "priority": "low",
+        "folder": "Newsletters",
+        "description": "Mass-distributed newsletters/digests/marketing",
+    },
+    {
+        "name": "spam_to_delete",
+        "priority": "low",
+        "folder": "ai_deleted",
+        "description": "\n".join([
+            "Unrelated, empty, or automated noise",
+            "Past invites with no relevance",
+            "External marketing not fitting newsletter",
+            "Flow/status reports for unrelated products or reports with no issues",
+        ]),
+    },
+]
+
+_PRIORITY_HEADINGS = {"high": "High Priority", "medium": "Medium Priority", "low": "Low Priority"}
+
+
+def render_category_rules(categories, username="the user"):
+    """Render categories as the prompt's classification rules, grouped by priority.
+
+    Args:
+        categories: Category dicts in display order
+        username: Name substituted for {{username}} in descriptions
+
+    Returns:
+        Markdown with a heading per priority and a bullet per description line
+    """
+    sections = []
+    for priority in CATEGORY_PRIORITIES:
+        blocks = []
+        for category in categories:
+            if category.get("priority", "low") != priority:
+                continue
+            description = (category.get("description") or "").replace("{{username}}", username)
+            lines = [line.strip() for line in description.splitlines() if line.strip()]
+            blocks.append("\n".join([f"**{category['name']}**", *(f"- {line}" for line in lines)]))
+        if blocks:
+            sections.append(f"### {_PRIORITY_HEADINGS[priority]}\n" + "\n\n".join(blocks))
+    return "\n\n".join(sections)
+
+
+def category_prompt_inputs(categories, username="the user"):
+    """category_rules and category_names inputs of the classifier prompt."""
+    return {
+        "category_rules": render_category_rules(categories, username),
+        "category_names": ", ".join(category["name"] for category in categories),
+    }
@@ -471,6 +471,9 @@ class EmailProcessingController:
                     suggestion['ai_suggestion'] = 'fyi'
                     suggestion['holistic_notes'] = suggestion.get('holistic_notes', [])
                     suggestion['holistic_notes'].append(f"Duplicate of {topic}")
+                    if keep_id:
+                        # Shows what the duplicate changed, e.g. in a resend "with corrections"
+                        suggestion['duplicate_diff_url'] = f"/api/emails/{keep_id}/diff/{archive_id}"
                     holistic_notes.append(f"Duplicate email archived: {topic}")
         
         # Update priority
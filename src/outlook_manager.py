@@ -83,15 +83,20 @@ class OutlookManager:
             )
         self.outlook = None
         self.namespace = None
+        self.store = None  # Selected store; None uses the profile's default store
         self.inbox = None
         self.folders = {}
         
-    def connect_to_outlook(self):
-        """Connect to Outlook application"""
+    def connect_to_outlook(self, store_name=None):
+        """Connect to Outlook application, optionally binding to a store by display name"""
         try:
             self.outlook = win32com.client.Dispatch("Outlook.Application")
             self.namespace = self.outlook.GetNamespace("MAPI")
             
+            if store_name:
+                self.select_store(store_name)
+                return
+            
             # Test accessing the default folder before storing it
             inbox = self.namespace.GetDefaultFolder(6)  # 6 = olFolderInbox
             
@@ -110,6 +115,60 @@ class OutlookManager:
             print(f"❌ Failed to connect to Outlook: {str(e)}")
             raise
     
+    def list_stores(self):
+        """List the profile's stores (mailboxes and data files) as dictionaries"""
+        if not self.namespace:
+            raise Exception("Not connected to Outlook. Call connect_to_outlook() first.")
+        
+        default_id = self.namespace.DefaultStore.StoreID
+        selected_id = self.store.StoreID if self.store else default_id
+        return [
+            {
+                'name': store.DisplayName,
+                'id': store.StoreID,
+                'is_default': store.StoreID == default_id,
+                'selected': store.StoreID == selected_id
+            }
+            for store in self.namespace.Stores
+        ]
+    
+    def select_store(self, store_name):
+        """Bind folder and item operations to the store with this display name
+        
+        Raises LookupError, naming the available stores, if the profile has
+        no store with that name (compared case-insensitively).
+        """
+        if not self.namespace:
+            raise Exception("Not connected to Outlook. Call connect_to_outlook() first.")
+        
+        names = []
+        for store in self.namespace.Stores:
+            names.append(store.DisplayName)
+            if store.DisplayName.strip().lower() == store_name.strip().lower():
+                break
+        else:
+            raise LookupError(
+                f"Outlook store '{store_name}' not found. Available stores: {', '.join(names) or 'none'}"
+            )
+        
+        inbox = store.GetDefaultFolder(6)  # 6 = olFolderInbox
+        try:
+            _ = inbox.Items.Count
+        except Exception as items_error:
+            raise Exception(f"Cannot access inbox items of store '{store.DisplayName}': {str(items_error)}")
+        
+        self.store = store
+        self.inbox = inbox
+        self.folders = {}
+        self._setup_outlook_folders()
+        return store
+    
+    def get_item(self, entry_id):
+        """Get an item by EntryID from the selected store"""
+        if self.store:
+            return self.namespace.GetItemFromID(entry_id, self.store.StoreID)
+        return self.namespace.GetItemFromID(entry_id)
+    
     def _setup_outlook_folders(self):
         """Set up Outlook folders for organizing emails with proper hierarchy"""
         try:
@@ -421,7 +480,7 @@ class OutlookManager:
         for entry_id in entry_ids:
             try:
                 # Get the email using its EntryID
-                email_item = self.namespace.GetItemFromID(entry_id)
+                email_item = self.get_item(entry_id)
                 
                 if email_item:
                     # Move to Done folder
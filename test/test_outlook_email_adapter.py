@@ -9,11 +9,15 @@ from unittest.mock import Mock, MagicMock, patch
 import sys
 from pathlib import Path
 from datetime import datetime
+from types import SimpleNamespace
 
 # Add src to path
 sys.path.insert(0, str(Path(__file__).parent.parent / "src"))
 
-from adapters.outlook_email_adapter import OutlookEmailAdapter
+from adapters.outlook_email_adapter import (
+    OL_BCC, OL_CC, OL_RULE_SEND, OL_TO, PR_ATTACH_CONTENT_ID, PR_ATTACH_MIME_TAG, PR_CONTENT_COUNT,
+    OutlookEmailAdapter
+)
 from core.interfaces import EmailProvider
 
 
@@ -26,6 +30,7 @@ class TestOutlookEmailAdapter(unittest.TestCase):
         self.mock_outlook_manager = Mock()
         self.mock_outlook_manager.namespace = Mock()
         self.mock_outlook_manager.inbox = Mock()
+        self.mock_outlook_manager.store = None
         
         # Create adapter with mocked manager
         self.adapter = OutlookEmailAdapter(outlook_manager=self.mock_outlook_manager)
@@ -203,6 +208,40 @@ class TestOutlookEmailAdapter(unittest.TestCase):
         self.assertEqual(folders[0]['name'], "Inbox")
         self.assertEqual(folders[1]['name'], "Archive")
     
+    def test_get_folders_walks_tree_once(self):
+        """Test nested folders are listed with counts read from folder properties."""
+        self.adapter.connected = True
+        
+        recruiters = self._create_mock_folder("f3", "Recruiters", unread=1, total=4)
+        inbox = self._create_mock_folder("f1", "Inbox", unread=5, total=20, subfolders=[recruiters])
+        archive = self._create_mock_folder("f2", "Archive", unread=0, total=300)
+        archive.PropertyAccessor.GetProperty.side_effect = Exception("Property not found")
+        archive.Items = Mock(Count=300)
+        
+        mock_parent = Mock()
+        mock_parent.Folders = FolderCollection([inbox, archive])
+        self.mock_outlook_manager.inbox.Parent = mock_parent
+        
+        folders = self.adapter.get_folders()
+        
+        self.assertEqual([f['path'] for f in folders], ["Inbox", "Inbox/Recruiters", "Archive"])
+        self.assertEqual(folders[0]['unread_count'], 5)
+        self.assertEqual(folders[0]['total_count'], 20)
+        self.assertEqual(folders[1]['total_count'], 4)
+        self.assertEqual(folders[2]['total_count'], 300)
+        inbox.PropertyAccessor.GetProperty.assert_called_once_with(PR_CONTENT_COUNT)
+    
+    def _create_mock_folder(self, entry_id, name, unread, total, subfolders=()):
+        """Create a mock Outlook folder whose Items must not be opened."""
+        folder = Mock()
+        folder.EntryID = entry_id
+        folder.Name = name
+        folder.UnReadItemCount = unread
+        folder.PropertyAccessor.GetProperty.return_value = total
+        folder.Folders = FolderCollection(subfolders)
+        folder.Items = Mock(spec=[])
+        return folder
+    
     def test_mark_as_read_success(self):
         """Test marking email as read."""
         self.adapter.connected = True
@@ -220,6 +259,251 @@ class TestOutlookEmailAdapter(unittest.TestCase):
         self.assertFalse(mock_email.UnRead)
         mock_email.Save.assert_called_once()
     
+    def test_mark_as_read_suppresses_read_receipt(self):
+        """Test the receipt request is cleared before the item is marked read and saved."""
+        self.adapter.connected = True
+        
+        mock_email = RecordingMailItem(ReadReceiptRequested=True, UnRead=True)
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(return_value=mock_email)
+        
+        result = self.adapter.mark_as_read("email_id", suppress_read_receipt=True)
+        
+        self.assertTrue(result)
+        self.assertEqual(mock_email.calls, [
+            ("set", "ReadReceiptRequested", False),
+            ("set", "UnRead", False),
+            ("call", "Save"),
+        ])
+    
+    def test_mark_as_read_keeps_receipt_by_default(self):
+        """Test the receipt request is left alone unless suppression is asked for."""
+        self.adapter.connected = True
+        
+        mock_email = RecordingMailItem(ReadReceiptRequested=True, UnRead=True)
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(return_value=mock_email)
+        
+        self.adapter.mark_as_read("email_id")
+        
+        self.assertEqual(mock_email.calls, [("set", "UnRead", False), ("call", "Save")])
+        self.assertTrue(mock_email.ReadReceiptRequested)
+    
+    def test_mark_as_read_suppress_without_receipt_request(self):
+        """Test nothing extra is written when no receipt was requested."""
+        self.adapter.connected = True
+        
+        mock_email = RecordingMailItem(ReadReceiptRequested=False, UnRead=True)
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(return_value=mock_email)
+        
+        self.adapter.mark_as_read("email_id", suppress_read_receipt=True)
+        
+        self.assertEqual(mock_email.calls, [("set", "UnRead", False), ("call", "Save")])
+    
+    def test_get_privacy_properties(self):
+        """Test reading the receipt request and HTML body."""
+        self.adapter.connected = True
+        
+        mock_email = Mock()
+        mock_email.ReadReceiptRequested = True
+        mock_email.HTMLBody = "<img src='https://cdn.example.com/a.png'>"
+        mock_email.MessageClass = "IPM.Note"
+        mock_email.Permission = 0
+        mock_email.Attachments = RecipientCollection([])
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(return_value=mock_email)
+        
+        properties = self.adapter.get_privacy_properties("email_id")
+        
+        self.assertEqual(properties, {
+            'requests_read_receipt': True,
+            'html_body': "<img src='https://cdn.example.com/a.png'>",
+            'message_class': "IPM.Note",
+            'permission': 0,
+            'attachments': []
+        })
+    
+    def test_email_to_dict_reports_read_receipt(self):
+        """Test converted emails report whether a read receipt was requested."""
+        mock_email = self._create_mock_email("id1", "Subject", "sender@example.com")
+        mock_email.ReadReceiptRequested = True
+        
+        self.assertTrue(self.adapter._email_to_dict(mock_email)['requests_read_receipt'])
+    
+    def test_email_to_dict_reports_rights_management(self):
+        """Test converted emails carry the message class and permission of IRM-protected mail."""
+        mock_email = self._create_mock_email("id1", "Subject", "sender@example.com")
+        mock_email.MessageClass = "IPM.Note.rpmsg.Microsoft.Voicemail.UM"
+        mock_email.Permission = 1  # olDoNotForward
+        
+        email_dict = self.adapter._email_to_dict(mock_email)
+        
+        self.assertEqual(
+            (email_dict['message_class'], email_dict['permission']),
+            ("IPM.Note.rpmsg.Microsoft.Voicemail.UM", 1)
+        )
+    
+    def test_email_to_dict_splits_to_and_cc(self):
+        """Test converted emails list To and Cc addresses and leave out Bcc."""
+        mock_email = self._create_mock_email("id1", "Subject", "sender@example.com")
+        mock_email.Recipients = RecipientCollection([
+            Mock(Address="me@example.com", Type=OL_TO),
+            Mock(Address="boss@example.com", Type=OL_CC),
+            Mock(Address="", Type=OL_TO),
+            Mock(Address="team@example.com", Type=OL_TO),
+            Mock(Address="hidden@example.com", Type=OL_BCC),
+        ])
+        
+        email_dict = self.adapter._email_to_dict(mock_email)
+        
+        self.assertEqual(email_dict['to'], ["me@example.com", "team@example.com"])
+        self.assertEqual(email_dict['cc'], ["boss@example.com"])
+        self.assertEqual(email_dict['recipient_count'], 3)
+        self.assertEqual(email_dict['recipient'], "me@example.com")
+    
+    def test_email_to_dict_without_recipients(self):
+        """Test emails whose recipients cannot be read still convert."""
+        mock_email = self._create_mock_email("id1", "Subject", "sender@example.com")
+        mock_email.Recipients = RecipientCollection([])
+        
+        email_dict = self.adapter._email_to_dict(mock_email)
+        
+        self.assertEqual((email_dict['to'], email_dict['cc'], email_dict['recipient_count']), ([], [], 0))
+        self.assertEqual(email_dict['recipient'], "")
+    
+    def test_email_to_dict_lists_attachments(self):
+        """Test converted emails carry each attachment's name and size."""
+        mock_email = self._create_mock_email("id1", "Subject", "sender@example.com")
+        mock_email.Attachments = RecipientCollection([
+            Mock(FileName="deck.pptx", Size=2048),
+            Mock(FileName="notes.txt", Size=10),
+        ])
+        
+        email_dict = self.adapter._email_to_dict(mock_email)
+        
+        self.assertEqual(email_dict['attachments'], [
+            {'name': "deck.pptx", 'size': 2048}, {'name': "notes.txt", 'size': 10}
+        ])
+    
+    def test_email_to_dict_records_inline_content_ids(self):
+        """Test inline attachments carry their Content-ID without angle brackets."""
+        mock_email = self._create_mock_email("id1", "Subject", "sender@example.com")
+        mock_email.Attachments = RecipientCollection([
+            InlineAttachment("logo.png", b"png", "<logo@01>"),
+            Mock(FileName="deck.pptx", Size=2048),
+        ])
+        
+        email_dict = self.adapter._email_to_dict(mock_email)
+        
+        self.assertEqual(email_dict['attachments'], [
+            {'name': "logo.png", 'size': 3, 'content_id': "logo@01"}, {'name': "deck.pptx", 'size': 2048}
+        ])
+    
+    def test_get_inline_attachment_saves_by_content_id(self):
+        """Test the attachment with a matching Content-ID is saved and its bytes returned."""
+        self.adapter.connected = True
+        mock_email = Mock()
+        mock_email.Attachments = RecipientCollection([
+            InlineAttachment("other.gif", b"gif", "<other@01>"),
+            InlineAttachment("logo.png", b"\x89PNG", "<Logo@01>", "image/png"),
+        ])
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(return_value=mock_email)
+        
+        found = self.adapter.get_inline_attachment("email_id", "logo@01")
+        
+        self.assertEqual(found, {'name': "logo.png", 'content_type': "image/png", 'data': b"\x89PNG"})
+        self.assertIsNone(self.adapter.get_inline_attachment("email_id", "missing@01"))
+    
+    def test_get_emails_by_ids_skips_missing(self):
+        """Test bulk lookup includes the folder and leaves out deleted emails."""
+        self.adapter.connected = True
+        
+        present = self._create_mock_email("id1", "Subject", "sender@example.com")
+        present.Categories = "FYI, Newsletter"
+        present.Parent.Name = "Projects"
+        
+        def get_item(entry_id):
+            if entry_id == "id1":
+                return present
+            raise Exception("The message interface has returned an unknown error")
+        
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(side_effect=get_item)
+        
+        found = self.adapter.get_emails_by_ids(["id1", "gone"])
+        
+        self.assertEqual(list(found), ["id1"])
+        self.assertEqual(found["id1"]["folder"], "Projects")
+        self.assertEqual(found["id1"]["categories"], ["FYI", "Newsletter"])
+    
+    def test_create_draft_reply_saves_without_sending(self):
+        """Test the reply is saved above the quoted original and never sent."""
+        self.adapter.connected = True
+        
+        reply = Mock()
+        reply.Body = "From: cfo@example.com\nQuoted original"
+        reply.EntryID = "draft_id"
+        mock_email = Mock()
+        mock_email.Reply.return_value = reply
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(return_value=mock_email)
+        
+        draft_id = self.adapter.create_draft_reply("email_id", "Re: Budget", "Confirmed.")
+        
+        self.assertEqual(draft_id, "draft_id")
+        self.assertEqual(reply.Subject, "Re: Budget")
+        self.assertEqual(reply.Body, "Confirmed.\n\nFrom: cfo@example.com\nQuoted original")
+        reply.Save.assert_called_once()
+        reply.Send.assert_not_called()
+    
+    def test_create_draft_reply_missing_email(self):
+        """Test a missing email saves nothing."""
+        self.adapter.connected = True
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(side_effect=Exception("not found"))
+        
+        self.assertIsNone(self.adapter.create_draft_reply("gone", "Re: Budget", "Confirmed."))
+    
+    def test_delete_email(self):
+        """Test deleting an email hands it to Outlook's Delete."""
+        self.adapter.connected = True
+        
+        mock_email = Mock()
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(return_value=mock_email)
+        
+        self.assertTrue(self.adapter.delete_email("email_id"))
+        mock_email.Delete.assert_called_once()
+    
+    def test_delete_email_missing(self):
+        """Test a missing email reports failure."""
+        self.adapter.connected = True
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(side_effect=Exception("not found"))
+        
+        self.assertFalse(self.adapter.delete_email("gone"))
+    
+    def test_get_shared_mailbox_emails(self):
+        """Test reading a shared inbox sorts newest first and pages."""
+        self.adapter.connected = True
+        
+        items = Mock()
+        items.__iter__ = Mock(return_value=iter([Mock(), Mock(), Mock()]))
+        shared_inbox = Mock()
+        shared_inbox.Items = items
+        namespace = self.mock_outlook_manager.namespace
+        namespace.CreateRecipient = Mock(return_value=Mock(Resolve=Mock(return_value=True)))
+        namespace.GetSharedDefaultFolder = Mock(return_value=shared_inbox)
+        self.adapter._email_to_dict = Mock(side_effect=lambda email: {"id": "e"})
+        
+        result = self.adapter.get_shared_mailbox_emails("team@example.com", count=1, offset=1)
+        
+        self.assertEqual(result, [{"id": "e"}])
+        namespace.CreateRecipient.assert_called_once_with("team@example.com")
+        items.Sort.assert_called_once_with("[ReceivedTime]", True)
+    
+    def test_get_shared_mailbox_unresolved(self):
+        """Test an unknown shared mailbox is rejected."""
+        self.adapter.connected = True
+        self.mock_outlook_manager.namespace.CreateRecipient = Mock(
+            return_value=Mock(Resolve=Mock(return_value=False))
+        )
+        
+        with self.assertRaises(ValueError):
+            self.adapter.get_shared_mailbox_emails("nobody@example.com")
+    
     def test_categorize_email_success(self):
         """Test email categorization."""
         self.adapter.connected = True
@@ -238,6 +522,128 @@ class TestOutlookEmailAdapter(unittest.TestCase):
             mock_email, "Work"
         )
     
+    def test_ensure_categories_adds_missing(self):
+        """Test that missing master categories are added and user colors kept."""
+        self.adapter.connected = True
+        master = CategoryCollection([Mock(Name="fyi", Color=5)])
+        self.mock_outlook_manager.namespace.Categories = master
+
+        colors = self.adapter.ensure_categories({"fyi": 8, "newsletter": 13})
+
+        self.assertEqual(colors, {"fyi": 5, "newsletter": 13})
+        self.assertEqual(master.added, [("newsletter", 13)])
+        self.assertEqual(master[0].Color, 5)
+
+    def test_ensure_categories_force_colors(self):
+        """Test that force_colors recolors existing categories."""
+        self.adapter.connected = True
+        master = CategoryCollection([Mock(Name="fyi", Color=5)])
+        self.mock_outlook_manager.namespace.Categories = master
+
+        colors = self.adapter.ensure_categories({"fyi": 8}, force_colors=True)
+
+        self.assertEqual(colors, {"fyi": 8})
+        self.assertEqual(master[0].Color, 8)
+        self.assertEqual(master.added, [])
+
+    def test_ensure_categories_requires_connection(self):
+        """Test that checking categories requires a connection."""
+        with self.assertRaises(RuntimeError):
+            self.adapter.ensure_categories({"fyi": 8})
+
+    def test_connect_missing_store_raises(self):
+        """Test that a store the profile does not have is reported, not swallowed."""
+        self.mock_outlook_manager.connect_to_outlook = Mock(
+            side_effect=LookupError("Outlook store 'Archive' not found")
+        )
+        
+        with self.assertRaises(LookupError):
+            self.adapter.connect(store_name="Archive")
+        
+        self.assertFalse(self.adapter.connected)
+        self.mock_outlook_manager.connect_to_outlook.assert_called_once_with(store_name="Archive")
+    
+    def test_selected_store_items(self):
+        """Test that items and categories are read from the selected store."""
+        self.adapter.connected = True
+        store = Mock(StoreID="store-archive")
+        store.Categories = CategoryCollection([])
+        self.mock_outlook_manager.store = store
+        self.mock_outlook_manager.namespace.GetItemFromID = Mock(return_value=Mock(Body="Archived"))
+        
+        self.assertEqual(self.adapter.get_email_body("email1"), "Archived")
+        self.adapter.ensure_categories({"fyi": 8})
+        
+        self.mock_outlook_manager.namespace.GetItemFromID.assert_called_once_with("email1", "store-archive")
+        self.assertEqual(store.Categories.added, [("fyi", 8)])
+    
+    def test_select_store(self):
+        """Test that selecting a store reports it as selected."""
+        self.adapter.connected = True
+        self.mock_outlook_manager.select_store = Mock(
+            return_value=Mock(DisplayName="Archive", StoreID="store-archive")
+        )
+        self.mock_outlook_manager.namespace.DefaultStore = Mock(StoreID="store-primary")
+        
+        store = self.adapter.select_store("archive")
+        
+        self.assertEqual(store, {
+            'name': 'Archive', 'id': 'store-archive', 'is_default': False, 'selected': True
+        })
+        self.mock_outlook_manager.select_store.assert_called_once_with("archive")
+
+    def test_get_rules(self):
+        """Test that sender, subject, and move-to-folder parts of rules are read."""
+        self.adapter.connected = True
+        newsletters = make_rule(
+            "Newsletters",
+            conditions=rule_parts(
+                From=SimpleNamespace(Enabled=True, Recipients=[Mock(Address="news@contoso.com")]),
+                SenderAddress=SimpleNamespace(Enabled=True, Address=("digest@",)),
+                Subject=SimpleNamespace(Enabled=True, Text=("Weekly",)),
+                HasAttachment=SimpleNamespace(Enabled=True),
+                Body=SimpleNamespace(Enabled=False)
+            ),
+            actions=rule_parts(
+                MoveToFolder=SimpleNamespace(
+                    Enabled=True, Folder=Mock(FolderPath="\\\\user@contoso.com\\Inbox\\Newsletters")
+                ),
+                MarkAsTask=SimpleNamespace(Enabled=True)
+            )
+        )
+        sent = make_rule("Sent copies", rule_type=OL_RULE_SEND, enabled=False)
+        self.mock_outlook_manager.namespace.DefaultStore = Mock(GetRules=Mock(return_value=[newsletters, sent]))
+        
+        rules = self.adapter.get_rules()
+        
+        self.assertEqual(rules[0], {
+            'name': 'Newsletters',
+            'enabled': True,
+            'incoming': True,
+            'senders': ['news@contoso.com', 'digest@'],
+            'subject_contains': ['Weekly'],
+            'move_to_folder': 'Inbox/Newsletters',
+            'other_conditions': ['HasAttachment'],
+            'other_actions': ['MarkAsTask'],
+        })
+        self.assertEqual(
+            (rules[1]['enabled'], rules[1]['incoming'], rules[1]['move_to_folder'], rules[1]['senders']),
+            (False, False, None, [])
+        )
+    
+    def test_get_rules_exceptions(self):
+        """Test that a rule with exceptions reports them as a condition."""
+        self.adapter.connected = True
+        rule = make_rule("Except boss", exceptions=rule_parts(From=SimpleNamespace(Enabled=True, Recipients=[])))
+        self.mock_outlook_manager.namespace.DefaultStore = Mock(GetRules=Mock(return_value=[rule]))
+        
+        self.assertEqual(self.adapter.get_rules()[0]['other_conditions'], ['Exceptions'])
+    
+    def test_get_rules_requires_connection(self):
+        """Test that listing rules requires a connection."""
+        with self.assertRaises(RuntimeError):
+            self.adapter.get_rules()
+
     def _create_mock_email(self, entry_id, subject, sender):
         """Helper to create mock email object."""
         mock_email = Mock()
@@ -249,6 +655,9 @@ class TestOutlookEmailAdapter(unittest.TestCase):
         mock_email.UnRead = False
         mock_email.Categories = ""
         mock_email.ConversationID = f"conv_{entry_id}"
+        mock_email.ReadReceiptRequested = False
+        mock_email.MessageClass = "IPM.Note"
+        mock_email.Permission = 0
         
         # Mock Recipients
         mock_recipient = Mock()
@@ -257,9 +666,86 @@ class TestOutlookEmailAdapter(unittest.TestCase):
         mock_recipients.Count = 1
         mock_recipients.Item = Mock(return_value=mock_recipient)
         mock_email.Recipients = mock_recipients
+        mock_email.Attachments = RecipientCollection([])
         
         return mock_email
 
 
+class RecordingMailItem:
+    """Fake MailItem that records property writes and method calls in order."""
+    
+    def __init__(self, **properties):
+        object.__setattr__(self, 'calls', [])
+        for name, value in properties.items():
+            object.__setattr__(self, name, value)
+    
+    def __setattr__(self, name, value):
+        self.calls.append(("set", name, value))
+        object.__setattr__(self, name, value)
+    
+    def Save(self):
+        self.calls.append(("call", "Save"))
+
+
+class RecipientCollection(list):
+    """Fake Recipients or Attachments collection: COM-style Count and 1-based Item."""
+    
+    @property
+    def Count(self):
+        return len(self)
+    
+    def Item(self, index):
+        return self[index - 1]
+
+
+class InlineAttachment:
+    """Fake Attachment with a Content-ID, saving its bytes like SaveAsFile."""
+    
+    def __init__(self, file_name, data, content_id, mime_type=""):
+        self.FileName = file_name
+        self.Size = len(data)
+        self.data = data
+        properties = {PR_ATTACH_CONTENT_ID: content_id, PR_ATTACH_MIME_TAG: mime_type}
+        self.PropertyAccessor = SimpleNamespace(GetProperty=properties.get)
+    
+    def SaveAsFile(self, path):
+        with open(path, 'wb') as saved:
+            saved.write(self.data)
+
+
+class FolderCollection(list):
+    """Fake Folders collection: iterable, with a COM-style Count."""
+    
+    @property
+    def Count(self):
+        return len(self)
+
+
+def rule_parts(**parts):
+    """Fake RuleConditions or RuleActions with disabled sender, subject, and move parts unless given."""
+    disabled = SimpleNamespace(Enabled=False)
+    defaults = {'From': disabled, 'SenderAddress': disabled, 'Subject': disabled, 'MoveToFolder': disabled}
+    return SimpleNamespace(**{**defaults, **parts})
+
+
+def make_rule(name, conditions=None, actions=None, exceptions=None, rule_type=0, enabled=True):
+    """Fake Outlook Rule."""
+    return SimpleNamespace(
+        Name=name, Enabled=enabled, RuleType=rule_type,
+        Conditions=conditions or rule_parts(), Actions=actions or rule_parts(), Exceptions=exceptions or rule_parts()
+    )
+
+
+class CategoryCollection(list):
+    """Fake Namespace.Categories collection recording added categories."""
+
+    def __init__(self, categories):
+        super().__init__(categories)
+        self.added = []
+
+    def Add(self, name, color):
+        self.added.append((name, color))
+
+
 if __name__ == '__main__':
     unittest.main()